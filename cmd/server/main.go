@@ -3,21 +3,63 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"strings"
 	"syscall"
 	"time"
-	"runtime"
 
+	"github.com/desponda/inbox-whisperer/internal/ai"
+	"github.com/desponda/inbox-whisperer/internal/analytics"
 	"github.com/desponda/inbox-whisperer/internal/api"
+	"github.com/desponda/inbox-whisperer/internal/apikeys"
+	"github.com/desponda/inbox-whisperer/internal/backfill"
+	"github.com/desponda/inbox-whisperer/internal/bulk"
 	"github.com/desponda/inbox-whisperer/internal/config"
+	"github.com/desponda/inbox-whisperer/internal/contacts"
 	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/digest"
+	"github.com/desponda/inbox-whisperer/internal/feedback"
+	"github.com/desponda/inbox-whisperer/internal/feeds"
+	"github.com/desponda/inbox-whisperer/internal/gmailpush"
+	"github.com/desponda/inbox-whisperer/internal/grpcapi"
+	"github.com/desponda/inbox-whisperer/internal/health"
+	"github.com/desponda/inbox-whisperer/internal/httpclient"
+	"github.com/desponda/inbox-whisperer/internal/integrations"
+	"github.com/desponda/inbox-whisperer/internal/jobs"
+	"github.com/desponda/inbox-whisperer/internal/legalhold"
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/oauthclient"
+	"github.com/desponda/inbox-whisperer/internal/offboarding"
+	"github.com/desponda/inbox-whisperer/internal/onboarding"
+	"github.com/desponda/inbox-whisperer/internal/passkeys"
+	"github.com/desponda/inbox-whisperer/internal/ratelimit"
+	"github.com/desponda/inbox-whisperer/internal/recategorize"
+	"github.com/desponda/inbox-whisperer/internal/reports"
+	"github.com/desponda/inbox-whisperer/internal/review"
+	"github.com/desponda/inbox-whisperer/internal/rules"
+	"github.com/desponda/inbox-whisperer/internal/scheduler"
 	"github.com/desponda/inbox-whisperer/internal/service"
+	"github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/imap"
+	"github.com/desponda/inbox-whisperer/internal/service/outlook"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
 	"github.com/desponda/inbox-whisperer/internal/session"
+	"github.com/desponda/inbox-whisperer/internal/status"
+	"github.com/desponda/inbox-whisperer/internal/suggestions"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/debuglog"
+	telemetryerrors "github.com/desponda/inbox-whisperer/internal/telemetry/errors"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/metrics"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/tracing"
+	"github.com/desponda/inbox-whisperer/internal/unsubscribe"
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
 )
 
 // zerologMiddleware logs each HTTP request using zerolog
@@ -30,13 +72,32 @@ func zerologMiddleware(next http.Handler) http.Handler {
 }
 
 func main() {
-	cfg := mustLoadConfig()
+	cfgStore := mustLoadConfig()
+	cfg := cfgStore.Current()
 	setupLogger(cfg)
-
-	buildSHA := os.Getenv("GIT_COMMIT")
-	if buildSHA == "" {
-		buildSHA = "unknown"
+	cfgStore.OnReload(func(c *config.AppConfig) error {
+		applyLogLevel(c.Server.LogLevel)
+		return nil
+	})
+	cfgStore.WatchSignals(context.Background())
+	if interval, err := cfg.Secrets.Interval(); err != nil {
+		log.Fatal().Err(err).Msg("Invalid secrets config")
+	} else if interval > 0 {
+		cfgStore.WatchSecrets(context.Background(), interval)
+	}
+	drain, err := cfg.Server.Drain()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid server config")
+	}
+	shutdownTimeout, err := cfg.Server.ShutdownGrace()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid server config")
 	}
+	if err := cfg.Residency.Check(cfg.Server.DBUrl); err != nil {
+		log.Fatal().Err(err).Msg("Storage is outside the configured data residency region")
+	}
+
+	buildSHA := buildSHA()
 	versionMsg := "*** BACKEND VERSION INFO *** sha=" + buildSHA + " go=" + runtime.Version() + " time=" + time.Now().Format(time.RFC3339)
 	log.Info().Str("build_sha", buildSHA).
 		Str("go_version", runtime.Version()).
@@ -47,14 +108,24 @@ func main() {
 
 	log.Info().Msg("Starting Inbox Whisperer server")
 
-	db := mustConnectDB(cfg)
+	shutdownTracing := mustSetupTracing(cfg.Tracing)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Warn().Err(err).Msg("Failed to flush traces")
+		}
+	}()
+
+	db := mustConnectDB(cfgStore)
 	defer db.Close()
 	log.Info().Msg("Database connection established")
 
-	r := setupRouter(db, cfg)
+	workerMonitor := health.NewMonitor()
+	r := setupRouter(db, cfgStore, workerMonitor)
 	srv := setupServer(cfg, r)
 
-	setupGracefulShutdown(srv)
+	setupGracefulShutdown(srv, workerMonitor, drain, shutdownTimeout)
 
 	log.Info().Msgf("Server is ready to handle requests at :%s", cfg.Server.Port)
 	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -62,7 +133,7 @@ func main() {
 	}
 }
 
-func mustLoadConfig() *config.AppConfig {
+func mustLoadConfig() *config.Store {
 	configPath := os.Getenv("CONFIG_FILE")
 	if configPath == "" {
 		configPath = "config.json"
@@ -72,56 +143,677 @@ func mustLoadConfig() *config.AppConfig {
 		log.Fatal().Err(err).Msg("Failed to load config")
 		os.Exit(1)
 	}
-	return cfg
+	store := config.NewStore(configPath, cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := store.UseSecrets(ctx, config.NewSecretsResolver()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to resolve secrets")
+	}
+	return store
 }
 
 func setupLogger(cfg *config.AppConfig) {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
-	if cfg != nil && cfg.Server.LogLevel != "" {
-		if level, err := zerolog.ParseLevel(cfg.Server.LogLevel); err == nil {
-			zerolog.SetGlobalLevel(level)
-		} else {
-			log.Warn().Str("level", cfg.Server.LogLevel).Msg("Invalid log level, using default")
-		}
+	debuglog.Setup(zerolog.ConsoleWriter{Out: os.Stderr})
+	if cfg != nil {
+		applyLogLevel(cfg.Server.LogLevel)
+	}
+}
+
+// applyLogLevel sets the global log level; an empty or invalid level keeps the current one
+func applyLogLevel(levelName string) {
+	if levelName == "" {
+		return
+	}
+	if level, err := zerolog.ParseLevel(levelName); err == nil {
+		debuglog.SetLevel(level)
+	} else {
+		log.Warn().Str("level", levelName).Msg("Invalid log level, using default")
+	}
+}
+
+// mustSetupTracing starts exporting traces when tracing.endpoint is set
+func mustSetupTracing(cfg config.TracingConfig) func(context.Context) error {
+	opts, err := cfg.Options()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid tracing config")
+	}
+	shutdown, err := tracing.Setup(context.Background(), opts)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up tracing")
+	}
+	if opts.Endpoint != "" {
+		log.Info().Str("endpoint", opts.Endpoint).Msg("Exporting traces")
 	}
+	return shutdown
 }
 
-func mustConnectDB(cfg *config.AppConfig) *data.DB {
+func mustConnectDB(cfgStore *config.Store) *data.DB {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	db, err := data.New(ctx, cfg.Server.DBUrl)
+	// Reading the URL per connection picks up rotated credentials
+	db, err := data.NewWithURLSource(ctx, func() string { return cfgStore.Current().Server.DBUrl })
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to connect to database")
 	}
 	return db
 }
 
-func setupRouter(db *data.DB, cfg *config.AppConfig) http.Handler {
+// buildSHA returns the git commit the binary was built from, as set by the deployment
+func buildSHA() string {
+	if sha := os.Getenv("GIT_COMMIT"); sha != "" {
+		return sha
+	}
+	return "unknown"
+}
+
+// newErrorReporter returns a Sentry-compatible reporter when a DSN is configured. An invalid
+// DSN is logged and disables reporting rather than stopping the server.
+func newErrorReporter(cfg config.ErrorReportingConfig, client *http.Client) telemetryerrors.Reporter {
+	if cfg.SentryDSN == "" {
+		return telemetryerrors.Nop{}
+	}
+	reporter, err := telemetryerrors.NewSentryReporter(cfg.SentryDSN, cfg.Environment, buildSHA())
+	if err != nil {
+		log.Error().Err(err).Msg("error reporting disabled")
+		return telemetryerrors.Nop{}
+	}
+	reporter.HTTPClient = client
+	return reporter
+}
+
+// newOutboundClient builds the HTTP client shared by calls to external services
+func newOutboundClient(cfg config.OutboundConfig) (*http.Client, error) {
+	opts, err := cfg.ClientOptions()
+	if err != nil {
+		return nil, err
+	}
+	client, err := httpclient.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	// Gmail API calls and the other external calls become spans of the request that made them
+	client.Transport = tracing.Transport(client.Transport)
+	return client, nil
+}
+
+// newRateLimiter builds the API rate limiter, or returns nil when no limit is configured
+func newRateLimiter(cfg config.RateLimitConfig) (*ratelimit.Limiter, error) {
+	perUser := ratelimit.Limit{PerMinute: cfg.UserPerMinute, Burst: cfg.UserBurst}
+	perIP := ratelimit.Limit{PerMinute: cfg.IPPerMinute, Burst: cfg.IPBurst}
+	for _, l := range []*ratelimit.Limit{&perUser, &perIP} {
+		if l.Burst == 0 {
+			l.Burst = l.PerMinute
+		}
+	}
+	if perUser.Unlimited() && perIP.Unlimited() {
+		return nil, nil
+	}
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RedisURL != "" {
+		redis, err := ratelimit.NewRedisStore(cfg.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+		store = redis
+	}
+	return ratelimit.NewLimiter(store, perUser, perIP), nil
+}
+
+// setupRouter builds the HTTP API; workerMonitor backs /readyz
+func setupRouter(db *data.DB, cfgStore *config.Store, workerMonitor *health.Monitor) http.Handler {
+	cfg := cfgStore.Current()
 	r := chi.NewRouter()
+	outbound, err := newOutboundClient(cfg.Outbound)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid outbound config")
+	}
+	workerHandler := api.NewWorkerHandler(workerMonitor)
+	maintenanceMode := maintenance.New()
+	if cfg.Server.MaintenanceMode {
+		maintenanceMode.Set(true, "", 0)
+	}
+	// A reload only flips the switch when the configured value changes, so it does not undo
+	// a toggle made through the admin API
+	configuredMaintenance := cfg.Server.MaintenanceMode
+	cfgStore.OnReload(func(c *config.AppConfig) error {
+		if c.Server.MaintenanceMode != configuredMaintenance {
+			configuredMaintenance = c.Server.MaintenanceMode
+			maintenanceMode.Set(configuredMaintenance, "", 0)
+		}
+		return nil
+	})
+	maintenanceHandler := api.NewMaintenanceHandler(maintenanceMode)
+	errorReporter := newErrorReporter(cfg.ErrorReporting, outbound)
+	// Tracing wraps the recoverer so the span of a panicking request records its 500
+	r.Use(tracing.Middleware)
+	r.Use(api.Recoverer(errorReporter))
 	r.Use(zerologMiddleware)
+	r.Use(api.BodyLimitMiddleware(api.DefaultBodyLimit))
 	// Session middleware
 	r.Use(session.Middleware)
+	// API keys let CLI clients act as a user without a session
+	var apiKeySvc *apikeys.Service
+	if db != nil {
+		apiKeySvc = apikeys.NewService(data.NewAPIKeyRepositoryFromPool(db.Pool), data.NewDeviceCodeRepositoryFromPool(db.Pool), cfg.Server.FrontendURL)
+		r.Use(api.APIKeyMiddleware(apiKeySvc))
+	}
+	// Limits apply per user, so they run once the session or API key has identified one
+	limiter, err := newRateLimiter(cfg.RateLimit)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid rate limit config")
+	}
+	if limiter != nil {
+		r.Use(api.RateLimitMiddleware(limiter, cfg.RateLimit.TrustForwardedFor))
+	}
+	// Users with passkeys confirm one before sensitive operations; stepUp guards those routes
+	stepUp := func(next http.Handler) http.Handler { return next }
+	var passkeyHandler *api.PasskeyHandler
+	if db != nil {
+		if rp, err := passkeys.RelyingPartyFromURL(cfg.Server.FrontendURL, "Inbox Whisperer"); err != nil {
+			log.Warn().Err(err).Msg("Passkeys are disabled")
+		} else {
+			passkeySvc := passkeys.NewService(data.NewPasskeyRepositoryFromPool(db.Pool), rp)
+			stepUp = api.RequireStepUp(passkeySvc)
+			passkeyHandler = api.NewPasskeyHandler(passkeySvc)
+		}
+	}
+	debugToggles := debuglog.New()
+	r.Use(api.DebugLogMiddleware(debugToggles))
+	r.Use(api.EncryptionKeyMiddleware)
+	r.Use(api.MaintenanceMiddleware(maintenanceMode))
+	// Read-only sessions may still unlock and lock the cache, which only changes the session
+	r.Use(api.ReadOnlyMiddleware("/api/users/me/encryption/unlock", "/api/users/me/encryption/lock"))
 
 	// Register OAuth2 endpoints
-	api.RegisterAuthRoutes(r, cfg, db)
+	var oauthStates data.OAuthStateRepository
+	if db != nil {
+		oauthStates = data.NewOAuthStateRepositoryFromPool(db.Pool)
+	}
+	authHandler := api.RegisterAuthRoutes(r, cfg, db, oauthStates, outbound)
 	// Only register Gmail API endpoints if db is not nil (prevents nil pointer dereference in tests)
 	if db != nil {
-		// Apply Auth and Token middleware to email API
-		r.With(api.AuthMiddleware, api.TokenMiddleware(db)).Route("/api/email", func(r chi.Router) {
-			r.Get("/messages", api.NewEmailHandler(service.NewMultiProviderEmailService(service.NewEmailProviderFactory()), db).FetchMessagesHandler)
-			r.Get("/messages/{id}", api.NewEmailHandler(service.NewMultiProviderEmailService(service.NewEmailProviderFactory()), db).GetMessageContentHandler)
+		// Rotated Google credentials override the configured ones; other replicas pick up a
+		// rotation on their next poll
+		var oauthClients *oauthclient.Store
+		if key := cfg.Google.CredentialKey; key != "" {
+			cipher, err := data.ParseCredentialKey(key)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Invalid Google credential key")
+			}
+			oauthClients = oauthclient.NewStore("google", *authHandler.OAuthConfig, data.NewOAuthClientRepositoryFromPool(db.Pool, cipher))
+			if err := oauthClients.Load(context.Background()); err != nil {
+				log.Fatal().Err(err).Msg("Failed to load rotated Google credentials")
+			}
+			oauthClients.Watch(context.Background(), time.Minute)
+			authHandler.Credentials = oauthClients
+		}
+		failedItems := data.NewFailedSyncItemRepositoryFromPool(db.Pool)
+		messageRepo := data.NewEmailMessageRepositoryFromPool(db.Pool)
+		gmailSvc := gmail.NewGmailService(messageRepo, nil)
+		gmailSvc.FailedItems = failedItems
+		gmailSvc.Maintenance = maintenanceMode
+		gmailSvc.Errors = errorReporter
+		gmailSvc.Breaker = provider.NewBreaker()
+		gmailSvc.SyncState = data.NewSyncStateRepositoryFromPool(db.Pool)
+		syncRuns := data.NewSyncRunRepositoryFromPool(db.Pool)
+		gmailSvc.SyncRuns = syncRuns
+		gmailSvc.Tx = db
+		settingsRepo := data.NewUserSettingsRepositoryFromPool(db.Pool)
+		gmailSvc.Settings = settingsRepo
+		encryptionKeys := data.NewEncryptionKeyRepositoryFromPool(db.Pool)
+		gmailSvc.EncryptionKeys = encryptionKeys
+		// Expired tokens are refreshed with the current app credentials and written back
+		refreshBase := oauthclient.TokenSourcer(authHandler.OAuthConfig)
+		if oauthClients != nil {
+			refreshBase = oauthClients
+		}
+		gmailSvc.Refresher = &oauthclient.Persisting{Base: refreshBase, Tokens: db, Provider: data.ProviderGmail}
+		gmailSvc.DropRawJSON = cfg.Storage.DropRawJSON
+		gmailSvc.HTTPClient = outbound
+		labelSvc := service.NewLabelService(data.NewLabelRepositoryFromPool(db.Pool), gmail.NewGmailProvider(gmailSvc))
+		ruleRepo := data.NewRuleRepositoryFromPool(db.Pool)
+		legalHolds := legalhold.NewService(data.NewLegalHoldRepositoryFromPool(db.Pool))
+		legalHolds.All = cfg.Storage.LegalHold
+		messageActions := service.NewMessageActionService(gmail.NewGmailProvider(gmailSvc))
+		messageActions.Messages = messageRepo
+		messageActions.Holds = legalHolds
+		threadMutes := service.NewThreadMuteService(gmail.NewGmailProvider(gmailSvc), data.NewMutedThreadRepositoryFromPool(db.Pool))
+		rulesEngine := rules.NewEngine(ruleRepo, labelSvc, messageRepo, messageActions)
+		rulesEngine.Muted = threadMutes
+		gmailSvc.Rules = rulesEngine
+		var llm ai.LLM
+		var openAI *ai.OpenAIClient
+		if cfg.OpenAI.APIKey != "" {
+			openAI = ai.NewOpenAIClient(cfg.OpenAI.APIKey, cfg.OpenAI.Model)
+			openAI.KeyFunc = func() string { return cfgStore.Current().OpenAI.APIKey }
+			openAI.HTTPClient = outbound
+			if cfg.OpenAI.BaseURL != "" {
+				openAI.BaseURL = strings.TrimRight(cfg.OpenAI.BaseURL, "/")
+			}
+			llm = openAI
+		}
+		aiGateway := ai.NewGateway(llm, settingsRepo, cfg.AI.LocalOnly)
+		aiGateway.UsageStore = data.NewAIUsageRepositoryFromPool(db.Pool)
+		aiGateway.Cache = data.NewAIResultRepositoryFromPool(db.Pool)
+		workerHandler.AI = aiGateway
+		aiGateway.SetBudget(ai.Budget{
+			UserDaily:     cfg.AI.UserDailyTokenBudget,
+			UserMonthly:   cfg.AI.UserMonthlyTokenBudget,
+			GlobalMonthly: cfg.AI.GlobalMonthlyTokenBudget,
+		})
+		shadowResults := data.NewShadowResultRepositoryFromPool(db.Pool)
+		if openAI != nil && cfg.OpenAI.ShadowModel != "" {
+			shadow := ai.NewOpenAIClient(cfg.OpenAI.APIKey, cfg.OpenAI.ShadowModel)
+			shadow.KeyFunc = openAI.KeyFunc
+			shadow.BaseURL = openAI.BaseURL
+			shadow.HTTPClient = outbound
+			aiGateway.Shadow = &ai.Shadow{LLM: shadow, Version: shadow.Model, ActiveVersion: openAI.Model, Store: shadowResults}
+		}
+		gmailSvc.Categorizer = aiGateway
+		factory := service.NewEmailProviderFactory()
+		factory.RegisterProvider(service.ProviderGmail, func(cfg service.ProviderConfig) (service.EmailProvider, error) {
+			return gmail.NewGmailProvider(gmailSvc), nil
+		})
+		factory.Accounts = db
+		if cfg.Outlook.ClientID != "" {
+			outlookProvider := outlook.NewOutlookProvider(outlook.NewOAuthConfig(cfg.Outlook), db)
+			outlookProvider.HTTPClient = outbound
+			factory.RegisterProvider(service.ProviderOutlook, func(service.ProviderConfig) (service.EmailProvider, error) {
+				return outlookProvider, nil
+			})
+			api.RegisterOutlookRoutes(r, cfg, db, oauthStates, outlookProvider)
+		}
+		var imapAccounts data.IMAPAccountRepository
+		if cfg.IMAP.CredentialKey != "" {
+			cipher, err := data.ParseCredentialKey(cfg.IMAP.CredentialKey)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Invalid IMAP credential key")
+			}
+			imapAccounts = data.NewIMAPAccountRepositoryFromPool(db.Pool, cipher)
+			imapProvider := imap.NewIMAPProvider(imapAccounts)
+			factory.RegisterProvider(service.ProviderIMAP, func(service.ProviderConfig) (service.EmailProvider, error) {
+				return imapProvider, nil
+			})
+			imapHandler := api.NewIMAPHandler(imapAccounts, imapProvider)
+			r.With(api.AuthMiddleware).Route("/api/users/me/imap", func(r chi.Router) {
+				r.Get("/", imapHandler.GetIMAPAccount)
+				r.Put("/", imapHandler.LinkIMAPAccount)
+				r.Delete("/", imapHandler.UnlinkIMAPAccount)
+			})
+		}
+		emailSvc := service.NewMultiProviderEmailService(factory)
+		emailSvc.Settings = settingsRepo
+		emailSvc.Tokens = db
+		messageCounter := data.NewMessageCounterFromPool(db.Pool)
+		emailHandler := api.NewEmailHandler(emailSvc, db)
+		emailHandler.Counter = messageCounter
+		aiHandler := api.NewAIHandler(aiGateway, emailSvc)
+		settingsHandler := api.NewSettingsHandler(settingsRepo, cfg.AI.LocalOnly)
+		settingsHandler.Messages = messageRepo
+		settingsHandler.Holds = legalHolds
+		encryptionHandler := api.NewEncryptionHandler(encryptionKeys)
+		encryptionHandler.Messages = messageRepo
+		encryptionHandler.Holds = legalHolds
+		syncHandler := api.NewSyncHandler(failedItems)
+		// The gRPC API is served from the same services; it only listens when a port is set
+		if port := cfg.Server.GRPCPort; port != "" {
+			serveGRPC(grpcapi.NewServer(grpcapi.Deps{
+				Keys:        apiKeySvc,
+				Emails:      emailSvc,
+				Tokens:      db,
+				FailedItems: failedItems,
+				Users:       service.NewUserService(db),
+				Reflection:  cfg.Server.GRPCReflection,
+			}), ":"+port)
+		}
+		accountHealth := service.NewAccountHealthService(db)
+		accountHealth.SyncState = gmailSvc.SyncState
+		accountHealth.FailedItems = failedItems
+		accountHealth.Breaker = gmailSvc.Breaker
+		accountHealth.MaxSyncAttempts = gmail.MaxSyncAttempts
+		accountHandler := api.NewAccountHandler(accountHealth)
+		labelHandler := api.NewLabelHandler(labelSvc)
+		ruleHandler := api.NewRuleHandler(ruleRepo)
+		suggestionSvc := suggestions.NewService(data.NewSuggestionRepositoryFromPool(db.Pool), ruleRepo)
+		suggestionHandler := api.NewSuggestionHandler(suggestionSvc)
+		messageActionHandler := api.NewMessageActionHandler(messageActions, messageRepo, suggestionSvc)
+		messageActionHandler.Labels = labelSvc
+		messageActionHandler.Updates = service.NewEmailUpdateService(gmail.NewGmailProvider(gmailSvc), data.NewEmailOverrideRepositoryFromPool(db.Pool), labelSvc, messageRepo)
+		threadHandler := api.NewThreadHandler(threadMutes)
+		threadHandler.Threads = data.NewThreadRepositoryFromPool(db.Pool)
+		threadHandler.Content = emailSvc
+		threadHandler.Counter = messageCounter
+		feedbackHandler := api.NewFeedbackHandler(feedback.NewService(data.NewCategoryFeedbackRepositoryFromPool(db.Pool), ruleRepo))
+		// Re-categorization runs, bulk actions and mailbox backfills share the job queue
+		jobQueue := jobs.NewQueue(data.NewJobRepositoryFromPool(db.Pool))
+		jobQueue.Health = workerMonitor.Register("jobs", jobQueue.Workers, health.DefaultStallAfter, jobQueue.Pending)
+		jobQueue.Maintenance = maintenanceMode
+		jobQueue.Errors = errorReporter
+		recategorizer := recategorize.NewRunner(data.NewRecategorizeJobRepositoryFromPool(db.Pool), messageRepo, aiGateway, jobQueue)
+		recategorizer.Health = jobQueue.Health
+		recategorizer.Maintenance = maintenanceMode
+		jobQueue.Register(models.JobKindRecategorize, recategorizer.Run, jobs.RetryPolicy{})
+		bulkRunner := bulk.NewRunner(data.NewBulkJobRepositoryFromPool(db.Pool), messageActions, messageRepo, db, jobQueue)
+		bulkRunner.Health = jobQueue.Health
+		bulkRunner.Maintenance = maintenanceMode
+		jobQueue.Register(models.JobKindBulk, bulkRunner.Run, jobs.RetryPolicy{})
+		mailboxBackfill := backfill.NewMailbox(gmailSvc, db, jobQueue)
+		mailboxBackfill.Maintenance = maintenanceMode
+		// Backfills resume from their last page, so they can afford more and slower retries
+		jobQueue.Register(models.JobKindMailboxBackfill, mailboxBackfill.Run,
+			jobs.RetryPolicy{MaxAttempts: 5, Backoff: time.Minute, MaxBackoff: time.Hour})
+		jobQueue.Start(context.Background())
+		jobHandler := api.NewJobHandler(jobQueue)
+		backfillHandler := api.NewBackfillHandler(mailboxBackfill)
+		bulkHandler := api.NewBulkHandler(bulkRunner)
+		backfills := data.NewMessageBackfillerFromPool(db.Pool)
+		startBackfill := func(name string, step backfill.Step) {
+			runner := backfill.NewRunner(name, step)
+			runner.Maintenance = maintenanceMode
+			runner.Errors = errorReporter
+			runner.Start(context.Background())
+		}
+		startBackfill("compress_bodies", backfills.CompressBodies)
+		startBackfill("parse_senders", backfills.ParseSenders)
+		if cfg.Storage.DropRawJSON && cfg.Storage.LegalHold {
+			log.Warn().Msg("storage.legal_hold is on: raw payloads are kept despite storage.drop_raw_json")
+		} else if cfg.Storage.DropRawJSON {
+			startBackfill("prune_raw_json", backfills.PruneRawJSON)
+		}
+		recategorizeHandler := api.NewRecategorizeHandler(recategorizer)
+		integrationSvc := integrations.NewService(integrations.DefaultRegistry(), data.NewIntegrationRepositoryFromPool(db.Pool), data.NewIntegrationDeliveryRepositoryFromPool(db.Pool))
+		integrationSvc.HTTPClient = outbound
+		integrationSvc.Health = workerMonitor.Register("integrations", 1, health.DefaultStallAfter, integrationSvc.Pending)
+		integrationSvc.Maintenance = maintenanceMode
+		integrationSvc.Errors = errorReporter
+		integrationSvc.Start(context.Background())
+		integrationHandler := api.NewIntegrationHandler(integrationSvc, emailSvc)
+		onboardingSvc := onboarding.NewService(data.NewOnboardingRepositoryFromPool(db.Pool))
+		onboardingSvc.Subscribe()
+		onboardingHandler := api.NewOnboardingHandler(onboardingSvc)
+		analyticsSvc := analytics.NewService(data.NewAnalyticsRepositoryFromPool(db.Pool))
+		analyticsSvc.Settings = settingsRepo
+		analyticsSvc.Subscribe()
+		statsHandler := api.NewStatsHandler(analyticsSvc)
+		unsubscriber := unsubscribe.NewService(messageRepo, data.NewUnsubscribeRepositoryFromPool(db.Pool), gmailSvc)
+		unsubscriber.HTTPClient = outbound
+		unsubscriber.Analytics = analyticsSvc
+		unsubscribeHandler := api.NewUnsubscribeHandler(unsubscriber)
+		reportSvc := reports.NewService(data.NewReportRepositoryFromPool(db.Pool), analyticsSvc, db, settingsRepo)
+		reportSvc.SetMailer(models.ReportViaAccount, reports.NewAccountMailer(db, db, gmailSvc))
+		if cfg.SMTP.Host != "" {
+			smtpMailer := reports.NewSMTPMailer(cfg.SMTP)
+			smtpMailer.PasswordFunc = func() string { return cfgStore.Current().SMTP.Password }
+			reportSvc.SetMailer(models.ReportViaSMTP, smtpMailer)
+		}
+		reportSvc.Health = workerMonitor.Register("weekly_reports", 1, health.DefaultStallAfter, reportSvc.Pending)
+		reportSvc.Maintenance = maintenanceMode
+		reportSvc.Errors = errorReporter
+		reportSvc.Start(context.Background())
+		reportHandler := api.NewReportHandler(reportSvc)
+		contactSvc := contacts.NewService(data.NewContactRepositoryFromPool(db.Pool), db)
+		contactSvc.Subscribe()
+		contactHandler := api.NewContactHandler(contactSvc)
+		reviewSvc := review.NewService(data.NewReviewSessionRepositoryFromPool(db.Pool), messageRepo, ruleRepo)
+		reviewSvc.Contacts = contactSvc
+		reviewHandler := api.NewReviewHandler(reviewSvc, messageActionHandler)
+		feedSvc := feeds.NewService(data.NewFeedRepositoryFromPool(db.Pool))
+		feedSvc.Subscribe()
+		feedHandler := api.NewFeedHandler(feedSvc)
+		syncTiers, err := scheduler.ParseTiers(cfg.Sync.Tiers)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid sync tiers")
+		}
+		syncScheduler, err := scheduler.New(data.NewSyncScheduleRepositoryFromPool(db.Pool), db, gmailSvc, syncTiers, cfg.Sync.DefaultTier)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid sync configuration")
+		}
+		syncScheduler.Health = workerMonitor.Register("sync_scheduler", 1, health.DefaultStallAfter, syncScheduler.Pending)
+		syncScheduler.Maintenance = maintenanceMode
+		syncScheduler.Errors = errorReporter
+		syncScheduler.DebugLog = debugToggles
+		syncScheduler.Start(context.Background())
+		digestSvc := digest.NewService(data.NewNewsletterDigestRepositoryFromPool(db.Pool), messageRepo, db, messageActions)
+		digestSvc.Health = workerMonitor.Register("newsletter_digest", 1, health.DefaultStallAfter, nil)
+		digestSvc.Maintenance = maintenanceMode
+		digestSvc.Errors = errorReporter
+		digestSvc.Start(context.Background())
+		if !cfg.Status.Disabled {
+			statusSvc := status.NewService(data.NewStatusCheckRepositoryFromPool(db.Pool))
+			if cfg.Status.RetentionDays > 0 {
+				statusSvc.Retention = time.Duration(cfg.Status.RetentionDays) * 24 * time.Hour
+			}
+			statusSvc.Add(status.ComponentAPI, status.PingCheck(db.Pool))
+			statusSvc.Add(status.ComponentSync, status.WorkerCheck(workerMonitor, "sync_scheduler", "gmail_watch"))
+			statusSvc.Add(status.ComponentAI, status.AICheck(aiGateway))
+			statusSvc.Add(status.ComponentProvider, status.ProviderCheck("gmail"))
+			statusSvc.Health = workerMonitor.Register("status_checks", 1, health.DefaultStallAfter, nil)
+			statusSvc.Start(context.Background())
+			r.Get("/api/status", api.NewStatusHandler(statusSvc).GetStatus)
+		}
+		// Push notifications sync a mailbox as soon as Gmail reports a change to it
+		if cfg.GmailPush.Topic != "" {
+			pushSvc := gmailpush.NewService(data.NewGmailWatchRepositoryFromPool(db.Pool), db, gmailSvc, gmailSvc, cfg.GmailPush.Topic)
+			pushSvc.Health = workerMonitor.Register("gmail_watch", 1, health.DefaultStallAfter, pushSvc.Pending)
+			pushSvc.Maintenance = maintenanceMode
+			pushSvc.Errors = errorReporter
+			pushSvc.DebugLog = debugToggles
+			pushSvc.Start(context.Background())
+			r.Post("/api/webhooks/gmail", api.NewGmailPushHandler(pushSvc, cfg.GmailPush.Token).Receive)
+		}
+		cfgStore.OnReload(func(c *config.AppConfig) error {
+			aiGateway.SetBudget(ai.Budget{
+				UserDaily:     c.AI.UserDailyTokenBudget,
+				UserMonthly:   c.AI.UserMonthlyTokenBudget,
+				GlobalMonthly: c.AI.GlobalMonthlyTokenBudget,
+			})
+			tiers, err := scheduler.ParseTiers(c.Sync.Tiers)
+			if err != nil {
+				return err
+			}
+			return syncScheduler.SetTiers(tiers, c.Sync.DefaultTier)
+		})
+		syncScheduleHandler := api.NewSyncScheduleHandler(syncScheduler)
+		debugLogHandler := api.NewDebugLogHandler(debugToggles)
+		roleHandler := api.NewRoleHandler(db, cfg.Server.AdminUserIDs)
+		legalHoldHandler := api.NewLegalHoldHandler(legalHolds)
+		shadowReportHandler := api.NewShadowReportHandler(shadowResults)
+		syncRunHandler := api.NewSyncRunHandler(syncRuns)
+		var offboardingHandler *api.OffboardingHandler
+		if key := cfg.Server.OffboardingSigningKey; key != "" {
+			if len(key) < offboarding.MinSigningKeyLength {
+				log.Fatal().Int("min_length", offboarding.MinSigningKeyLength).Msg("Offboarding signing key is too short")
+			}
+			offboarder := offboarding.NewService([]byte(key), service.NewUserService(db), db, messageRepo,
+				data.NewAPIKeyRepositoryFromPool(db.Pool), syncRuns)
+			if imapAccounts != nil {
+				offboarder.IMAP = imapAccounts
+			}
+			if r := cfg.Residency; r.Region != "" {
+				offboarder.Residency = &offboarding.Residency{Region: r.Region, Bucket: r.Bucket}
+			}
+			offboarder.Holds = legalHolds
+			offboardingHandler = api.NewOffboardingHandler(offboarder)
+		}
+		// Changes at the provider need the modify scope, which login does not ask for
+		requireModify := api.RequireScope(db, provider.FeatureModify)
+		// The email API lives under /api/emails; /api/email serves the same routes for older
+		// clients until server.disable_legacy_email_routes turns it off
+		emailRoutes := func(r chi.Router) {
+			r.Use(api.AuthMiddleware)
+			r.Post("/{id}/category", feedbackHandler.SubmitCategoryFeedback)
+			r.Group(func(r chi.Router) {
+				r.Use(api.TokenMiddleware(db))
+				r.Get("/messages", emailHandler.FetchMessagesHandler)
+				r.Get("/messages/{id}", emailHandler.GetMessageContentHandler)
+				r.Get("/messages/{id}/summary", aiHandler.SummarizeMessage)
+				r.Get("/sync/status", syncHandler.GetSyncStatus)
+				r.Post("/{id}/send-to/{integration}", integrationHandler.SendTo)
+				r.Post("/{id}/unsubscribe", unsubscribeHandler.Unsubscribe)
+				r.With(requireModify).Post("/bulk", bulkHandler.Enqueue)
+				r.Get("/bulk/{id}", bulkHandler.GetJob)
+				r.With(requireModify).Post("/{id}/archive", messageActionHandler.Archive)
+				r.With(requireModify).Patch("/{id}", messageActionHandler.UpdateEmail)
+				r.With(requireModify).Delete("/{id}", messageActionHandler.DeleteEmail)
+				r.With(requireModify).Post("/{id}/read", messageActionHandler.MarkRead)
+				r.With(requireModify).Put("/{id}/read", messageActionHandler.SetRead)
+				r.With(requireModify).Post("/{id}/actions", messageActionHandler.Act)
+			})
+		}
+		legacyEmail := api.NewLegacyRoutes("/api/email", "/api/emails", !cfg.Server.DisableLegacyEmailRoutes)
+		cfgStore.OnReload(func(c *config.AppConfig) error {
+			legacyEmail.SetEnabled(!c.Server.DisableLegacyEmailRoutes)
+			return nil
+		})
+		r.Route("/api/emails", emailRoutes)
+		r.With(legacyEmail.Middleware).Route("/api/email", emailRoutes)
+		r.With(api.AuthMiddleware, api.TokenMiddleware(db)).Route("/api/labels", func(r chi.Router) {
+			r.Get("/", labelHandler.ListLabels)
+			r.With(requireModify).Post("/", labelHandler.CreateLabel)
+			r.With(requireModify).Put("/{id}", labelHandler.UpdateLabel)
+		})
+		r.With(api.AuthMiddleware).Route("/api/rules", func(r chi.Router) {
+			r.Get("/", ruleHandler.ListRules)
+			r.Post("/", ruleHandler.CreateRule)
+			r.Delete("/{id}", ruleHandler.DeleteRule)
+			r.Get("/suggestions", suggestionHandler.ListSuggestions)
+			r.Post("/suggestions/{id}/accept", suggestionHandler.AcceptSuggestion)
+			r.Post("/suggestions/{id}/dismiss", suggestionHandler.DismissSuggestion)
+		})
+		r.With(api.AuthMiddleware, api.TokenMiddleware(db)).Route("/api/threads", func(r chi.Router) {
+			r.Get("/", threadHandler.ListThreads)
+			r.Get("/{id}", threadHandler.GetThread)
+			r.With(requireModify).Post("/{id}/mute", threadHandler.Mute)
+			r.With(requireModify).Post("/{id}/unmute", threadHandler.Unmute)
+			r.Get("/{id}/participants", contactHandler.ThreadParticipants)
+		})
+		r.With(api.AuthMiddleware, api.TokenMiddleware(db)).Route("/api/review-sessions", func(r chi.Router) {
+			r.Post("/", reviewHandler.CreateSession)
+			r.Get("/{id}", reviewHandler.GetSession)
+			r.Get("/{id}/next", reviewHandler.Next)
+			r.With(requireModify).Post("/{id}/actions", reviewHandler.Act)
+		})
+		r.With(api.AuthMiddleware).Route("/api/contacts", func(r chi.Router) {
+			r.Get("/", contactHandler.ListContacts)
+			r.Get("/{id}", contactHandler.GetContact)
+		})
+		r.With(api.AuthMiddleware).Route("/api/onboarding", func(r chi.Router) {
+			r.Get("/", onboardingHandler.GetOnboarding)
+			r.Post("/steps/{step}", onboardingHandler.CompleteStep)
+		})
+		r.With(api.AuthMiddleware).Get("/api/accounts/{id}/health", accountHandler.GetHealth)
+		r.With(api.AuthMiddleware).Get("/api/users/me/stats", statsHandler.GetMyStats)
+		r.With(api.AuthMiddleware).Route("/api/analytics", func(r chi.Router) {
+			r.Get("/senders", statsHandler.GetSenders)
+			r.Get("/volume", statsHandler.GetVolume)
+		})
+		apiKeyHandler := api.NewAPIKeyHandler(apiKeySvc)
+		r.With(api.AuthMiddleware).Route("/api/users/me/api-keys", func(r chi.Router) {
+			r.Get("/", apiKeyHandler.ListAPIKeys)
+			r.With(stepUp).Post("/", apiKeyHandler.CreateAPIKey)
+			r.Delete("/{id}", apiKeyHandler.RevokeAPIKey)
+		})
+		r.Post("/api/auth/device/code", apiKeyHandler.StartDeviceAuthorization)
+		r.Post("/api/auth/device/token", apiKeyHandler.PollDeviceToken)
+		r.With(api.AuthMiddleware, stepUp).Post("/api/auth/device/approve", apiKeyHandler.ApproveDevice)
+		r.With(api.AuthMiddleware).Post("/api/auth/device/deny", apiKeyHandler.DenyDevice)
+		r.With(api.AuthMiddleware).Get("/api/users/me/settings", settingsHandler.GetSettings)
+		r.With(api.AuthMiddleware).Get("/api/users/me/ai-usage", aiHandler.GetMyUsage)
+		r.With(api.AuthMiddleware).Put("/api/users/me/settings", settingsHandler.UpdateSettings)
+		r.With(api.AuthMiddleware).Route("/api/users/me/encryption", func(r chi.Router) {
+			r.Get("/", encryptionHandler.GetStatus)
+			r.Post("/", encryptionHandler.Enable)
+			r.Delete("/", encryptionHandler.Disable)
+			r.Post("/unlock", encryptionHandler.Unlock)
+			r.Post("/lock", encryptionHandler.Lock)
+		})
+		r.With(api.AuthMiddleware).Route("/api/users/me/integrations", func(r chi.Router) {
+			r.Get("/", integrationHandler.ListIntegrations)
+			r.Get("/deliveries", integrationHandler.ListDeliveries)
+			r.Get("/deliveries/{id}", integrationHandler.GetDelivery)
+			r.Put("/{integration}", integrationHandler.ConfigureIntegration)
+			r.Delete("/{integration}", integrationHandler.RemoveIntegration)
+		})
+		r.With(api.AuthMiddleware).Route("/api/users/me/reports/weekly", func(r chi.Router) {
+			r.Get("/", reportHandler.GetWeeklyReport)
+			r.Put("/", reportHandler.UpdateWeeklyReport)
+			r.Get("/preview", reportHandler.PreviewWeeklyReport)
+			r.Get("/deliveries", reportHandler.ListReportDeliveries)
+		})
+		if passkeyHandler != nil {
+			r.With(api.AuthMiddleware).Route("/api/users/me/passkeys", func(r chi.Router) {
+				r.Get("/", passkeyHandler.ListPasskeys)
+				// Adding or removing a passkey needs one of the existing ones
+				r.With(stepUp).Post("/options", passkeyHandler.CreationOptions)
+				r.With(stepUp).Post("/", passkeyHandler.Register)
+				r.With(stepUp).Delete("/{id}", passkeyHandler.DeletePasskey)
+			})
+			r.With(api.AuthMiddleware).Route("/api/auth/step-up", func(r chi.Router) {
+				r.Get("/", passkeyHandler.GetStepUp)
+				r.Post("/options", passkeyHandler.StepUpOptions)
+				r.Post("/", passkeyHandler.StepUp)
+			})
+		}
+		clientStateHandler := api.NewClientStateHandler(data.NewClientStateRepositoryFromPool(db.Pool))
+		r.With(api.AuthMiddleware).Route("/api/users/me/client-state", func(r chi.Router) {
+			r.Get("/", clientStateHandler.ListClientState)
+			r.Get("/{key}", clientStateHandler.GetClientState)
+			r.Put("/{key}", clientStateHandler.PutClientState)
+			r.Delete("/{key}", clientStateHandler.DeleteClientState)
+		})
+		r.With(api.AuthMiddleware).Route("/api/users/me/feeds", func(r chi.Router) {
+			r.Get("/", feedHandler.ListFeeds)
+			r.With(stepUp).Post("/", feedHandler.CreateFeed)
+			r.Delete("/{id}", feedHandler.DeleteFeed)
+		})
+		// Feed readers cannot sign in; the token in the URL authenticates the request
+		r.Get("/feeds/{token}.xml", feedHandler.ServeFeed)
+		r.With(api.AuthMiddleware).Post("/api/users/me/recategorize", recategorizeHandler.EnqueueMine)
+		r.With(api.AuthMiddleware).Get("/api/users/me/recategorize/{id}", recategorizeHandler.GetMyJob)
+		r.With(api.AuthMiddleware, api.TokenMiddleware(db)).Post("/api/users/me/backfill", backfillHandler.StartMine)
+		r.With(api.AuthMiddleware).Get("/api/jobs/{id}", jobHandler.GetMyJob)
+		r.With(api.AuthMiddleware, api.AdminOnly(cfg.Server.AdminUserIDs, db)).Route("/api/admin", func(r chi.Router) {
+			r.Post("/recategorize", recategorizeHandler.AdminEnqueue)
+			r.Get("/recategorize/{id}", recategorizeHandler.AdminGetJob)
+			r.Get("/jobs/{id}", jobHandler.AdminGetJob)
+			r.Get("/users/{id}/sync", syncScheduleHandler.AdminGet)
+			r.Put("/users/{id}/sync", syncScheduleHandler.AdminPut)
+			r.Get("/workers/status", workerHandler.AdminStatus)
+			r.Get("/maintenance", maintenanceHandler.AdminGet)
+			r.Put("/maintenance", maintenanceHandler.AdminPut)
+			r.Get("/debug-logging", debugLogHandler.AdminList)
+			r.Get("/categorizer/shadow-report", shadowReportHandler.AdminReport)
+			r.Get("/sync-runs", syncRunHandler.AdminList)
+			r.Get("/sync-runs/stats", syncRunHandler.AdminStats)
+			r.Put("/users/{id}/debug-logging", debugLogHandler.AdminEnable)
+			r.Delete("/users/{id}/debug-logging", debugLogHandler.AdminDisable)
+			r.Get("/admins", roleHandler.AdminListAdmins)
+			r.Put("/users/{id}/role", roleHandler.AdminSetRole)
+			r.Delete("/users/{id}/role", roleHandler.AdminRevokeRole)
+			r.Get("/legal-holds", legalHoldHandler.AdminListHolds)
+			r.Get("/users/{id}/legal-hold", legalHoldHandler.AdminGetHold)
+			r.Put("/users/{id}/legal-hold", legalHoldHandler.AdminPlaceHold)
+			r.With(stepUp).Delete("/users/{id}/legal-hold", legalHoldHandler.AdminReleaseHold)
+			if offboardingHandler != nil {
+				r.With(stepUp).Post("/offboard", offboardingHandler.AdminOffboard)
+			}
+			if oauthClients != nil {
+				oauthClientHandler := api.NewOAuthClientHandler(oauthClients)
+				r.Get("/oauth/google", oauthClientHandler.AdminGet)
+				r.Put("/oauth/google", oauthClientHandler.AdminPut)
+			}
 		})
 	}
 
-	h := api.NewUserHandler(service.NewUserService(db))
+	h := api.NewUserHandler(service.NewUserService(db), db, cfg.Server.AdminUserIDs)
 	r.Route("/users", func(r chi.Router) {
-		r.Get("/", h.ListUsers)
+		r.With(api.AuthMiddleware).Get("/", h.ListUsers)
 		r.Post("/", h.CreateUser)
 		// Only allow users to access/modify their own info (now via AuthMiddleware)
 		r.With(api.AuthMiddleware).Get("/{id}", h.GetUser)
 		r.With(api.AuthMiddleware).Put("/{id}", h.UpdateUser)
-		r.With(api.AuthMiddleware).Delete("/{id}", h.DeleteUser)
+		r.With(api.AuthMiddleware, stepUp).Delete("/{id}", h.DeleteUser)
 	})
 
 	// Register /api/users/me endpoint for current user info
@@ -133,6 +825,8 @@ func setupRouter(db *data.DB, cfg *config.AppConfig) http.Handler {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintln(w, "ok")
 	})
+	r.Get("/readyz", workerHandler.Ready)
+	r.Method(http.MethodGet, "/metrics", metrics.Handler())
 
 	return r
 }
@@ -146,13 +840,45 @@ func setupServer(cfg *config.AppConfig, handler http.Handler) *http.Server {
 	}
 }
 
-func setupGracefulShutdown(srv *http.Server) {
+// serveGRPC serves the gRPC API on addr in the background and stops it gracefully on SIGINT
+// or SIGTERM, letting in-flight calls finish
+func serveGRPC(srv *grpc.Server, addr string) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal().Err(err).Str("addr", addr).Msg("Could not listen for gRPC")
+	}
+	go func() {
+		log.Info().Msgf("gRPC API is ready to handle requests at %s", addr)
+		if err := srv.Serve(lis); err != nil {
+			log.Error().Err(err).Msg("gRPC server stopped")
+		}
+	}()
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-quit
+		srv.GracefulStop()
+	}()
+}
+
+// setupGracefulShutdown stops the server on SIGINT or SIGTERM. For the drain period /readyz
+// fails while requests are still served, so a rolling deploy moves traffic away before the
+// listener closes; keep-alives are disabled meanwhile so clients reconnect elsewhere. Handlers
+// holding long-lived connections should register with srv.RegisterOnShutdown to tell their
+// clients to reconnect, since Shutdown does not wait for hijacked connections.
+func setupGracefulShutdown(srv *http.Server, monitor *health.Monitor, drain, timeout time.Duration) {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-quit
+		monitor.Drain()
+		if drain > 0 {
+			log.Info().Dur("drain_period", drain).Msg("Draining before shutdown...")
+			srv.SetKeepAlivesEnabled(false)
+			time.Sleep(drain)
+		}
 		log.Info().Msg("Shutting down server...")
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Error().Err(err).Msg("Server forced to shutdown")
@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrBudgetExhausted means the user's or the deployment's token budget is used up
+var ErrBudgetExhausted = errors.New("ai: token budget exhausted")
+
+// Features recorded with token usage
+const (
+	FeatureSummarize  = "summarize"
+	FeatureCategorize = "categorize"
+)
+
+// Budget limits LLM token usage; a zero limit means unlimited
+type Budget struct {
+	UserDaily     int
+	UserMonthly   int
+	GlobalMonthly int
+}
+
+// SetBudget replaces the token budget; safe to call while the gateway is in use
+func (g *Gateway) SetBudget(b Budget) {
+	g.budget.Store(&b)
+}
+
+// CurrentBudget returns the token budget in effect
+func (g *Gateway) CurrentBudget() Budget {
+	if b := g.budget.Load(); b != nil {
+		return *b
+	}
+	return Budget{}
+}
+
+// UsageStore records and sums LLM token usage (see data.AIUsageRepository)
+type UsageStore interface {
+	RecordUsage(ctx context.Context, userID, feature string, promptTokens, completionTokens int) error
+	UserTokensSince(ctx context.Context, userID string, since time.Time) (int, error)
+	TotalTokensSince(ctx context.Context, since time.Time) (int, error)
+}
+
+// usageWindows returns the start of the current UTC day and month
+func usageWindows(now time.Time) (day, month time.Time) {
+	now = now.UTC()
+	day = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return day, month
+}
+
+// Usage returns the user's token usage against the configured budget
+func (g *Gateway) Usage(ctx context.Context, userID string) (*models.AIUsage, error) {
+	day, month := usageWindows(g.now())
+	budget := g.CurrentBudget()
+	u := &models.AIUsage{
+		Daily:   models.AIUsageWindow{Limit: budget.UserDaily, ResetsAt: day.AddDate(0, 0, 1)},
+		Monthly: models.AIUsageWindow{Limit: budget.UserMonthly, ResetsAt: month.AddDate(0, 1, 0)},
+	}
+	if g.UsageStore == nil {
+		return u, nil
+	}
+	var err error
+	if u.Daily.Used, err = g.UsageStore.UserTokensSince(ctx, userID, day); err != nil {
+		return nil, err
+	}
+	if u.Monthly.Used, err = g.UsageStore.UserTokensSince(ctx, userID, month); err != nil {
+		return nil, err
+	}
+	u.Exhausted = exceeded(u.Daily.Used, u.Daily.Limit) || exceeded(u.Monthly.Used, u.Monthly.Limit)
+	if !u.Exhausted && budget.GlobalMonthly > 0 {
+		total, err := g.UsageStore.TotalTokensSince(ctx, month)
+		if err != nil {
+			return nil, err
+		}
+		u.Exhausted = exceeded(total, budget.GlobalMonthly)
+	}
+	return u, nil
+}
+
+// checkBudget returns ErrBudgetExhausted if any budget is used up
+func (g *Gateway) checkBudget(ctx context.Context, userID string) error {
+	if g.UsageStore == nil || g.CurrentBudget() == (Budget{}) {
+		return nil
+	}
+	u, err := g.Usage(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("ai: load usage: %w", err)
+	}
+	if u.Exhausted {
+		return ErrBudgetExhausted
+	}
+	return nil
+}
+
+// recordUsage stores the tokens used by a completion; failures are logged, not returned
+func (g *Gateway) recordUsage(ctx context.Context, userID, feature string, resp *Response) {
+	if g.UsageStore == nil {
+		return
+	}
+	if err := g.UsageStore.RecordUsage(ctx, userID, feature, resp.PromptTokens, resp.CompletionTokens); err != nil {
+		log.Warn().Err(err).Str("user_id", userID).Msg("ai: failed to record token usage")
+	}
+}
+
+func exceeded(used, limit int) bool {
+	return limit > 0 && used >= limit
+}
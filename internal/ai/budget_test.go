@@ -0,0 +1,131 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+type usageRecord struct {
+	userID, feature string
+	tokens          int
+	at              time.Time
+}
+
+type fakeUsage struct {
+	records []usageRecord
+	now     time.Time
+}
+
+func (f *fakeUsage) RecordUsage(ctx context.Context, userID, feature string, promptTokens, completionTokens int) error {
+	f.records = append(f.records, usageRecord{userID, feature, promptTokens + completionTokens, f.now})
+	return nil
+}
+
+func (f *fakeUsage) UserTokensSince(ctx context.Context, userID string, since time.Time) (int, error) {
+	total := 0
+	for _, r := range f.records {
+		if r.userID == userID && !r.at.Before(since) {
+			total += r.tokens
+		}
+	}
+	return total, nil
+}
+
+func (f *fakeUsage) TotalTokensSince(ctx context.Context, since time.Time) (int, error) {
+	total := 0
+	for _, r := range f.records {
+		if !r.at.Before(since) {
+			total += r.tokens
+		}
+	}
+	return total, nil
+}
+
+type countingLLM struct {
+	calls int
+}
+
+func (c *countingLLM) Complete(ctx context.Context, req Request) (*Response, error) {
+	c.calls++
+	return &Response{Text: "Updates", PromptTokens: 80, CompletionTokens: 20}, nil
+}
+
+func TestGateway_BudgetEnforced(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	usage := &fakeUsage{now: now}
+	llm := &countingLLM{}
+	g := NewGateway(llm, fakeSettings{sharing: true}, false)
+	g.now = func() time.Time { return now }
+	g.UsageStore = usage
+	g.SetBudget(Budget{UserDaily: 150})
+	msg := &models.EmailMessage{Subject: "Your receipt"}
+
+	if _, err := g.Summarize(ctx, "u1", msg); err != nil {
+		t.Fatalf("first summary: %v", err)
+	}
+	if _, err := g.Summarize(ctx, "u1", msg); err != nil {
+		t.Fatalf("second summary: %v", err)
+	}
+	if _, err := g.Summarize(ctx, "u1", msg); !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("expected ErrBudgetExhausted after 200 tokens, got %v", err)
+	}
+	c, err := g.Categorize(ctx, "u1", msg)
+	if err != nil || c.Source != SourceHeuristic {
+		t.Errorf("expected heuristic categorization over budget, got %+v (err %v)", c, err)
+	}
+	if llm.calls != 2 {
+		t.Errorf("expected 2 LLM calls, got %d", llm.calls)
+	}
+	// Other users have their own daily budget
+	if _, err := g.Summarize(ctx, "u2", msg); err != nil {
+		t.Errorf("expected u2 to be within budget, got %v", err)
+	}
+
+	// The daily budget resets the next UTC day
+	g.now = func() time.Time { return now.Add(24 * time.Hour) }
+	if err := g.CheckExternal(ctx, "u1"); err != nil {
+		t.Errorf("expected budget to reset the next day, got %v", err)
+	}
+}
+
+func TestGateway_GlobalBudget(t *testing.T) {
+	ctx := context.Background()
+	usage := &fakeUsage{now: time.Now()}
+	g := NewGateway(&countingLLM{}, fakeSettings{sharing: true}, false)
+	g.UsageStore = usage
+	g.SetBudget(Budget{GlobalMonthly: 100})
+	if _, err := g.Summarize(ctx, "u1", &models.EmailMessage{}); err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	if err := g.CheckExternal(ctx, "u2"); !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("expected global budget to apply to every user, got %v", err)
+	}
+}
+
+func TestGateway_Usage(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	usage := &fakeUsage{now: now.AddDate(0, 0, -3)}
+	usage.RecordUsage(context.Background(), "u1", FeatureSummarize, 300, 0)
+	usage.now = now
+	usage.RecordUsage(context.Background(), "u1", FeatureSummarize, 40, 10)
+
+	g := NewGateway(nil, fakeSettings{}, false)
+	g.now = func() time.Time { return now }
+	g.UsageStore = usage
+	g.SetBudget(Budget{UserDaily: 1000, UserMonthly: 350})
+	u, err := g.Usage(context.Background(), "u1")
+	if err != nil {
+		t.Fatalf("Usage: %v", err)
+	}
+	if u.Daily.Used != 50 || u.Monthly.Used != 350 || !u.Exhausted {
+		t.Errorf("unexpected usage %+v", u)
+	}
+	if !u.Daily.ResetsAt.Equal(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)) || !u.Monthly.ResetsAt.Equal(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected reset times %v / %v", u.Daily.ResetsAt, u.Monthly.ResetsAt)
+	}
+}
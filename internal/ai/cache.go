@@ -0,0 +1,62 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// ResultCache stores AI results per message (see data.AIResultRepository)
+type ResultCache interface {
+	GetResult(ctx context.Context, userID, messageID, kind string) (*models.AIResult, error)
+	PutResult(ctx context.Context, result *models.AIResult) error
+}
+
+// ContentHash identifies the message content AI results are computed from (subject and the
+// body as promptBody reads it). A changed hash invalidates cached results.
+func ContentHash(msg *models.EmailMessage) string {
+	sum := sha256.Sum256([]byte(msg.Subject + "\x00" + promptBody(msg)))
+	return hex.EncodeToString(sum[:])
+}
+
+// cachedResult returns the cached result if it was computed from the same content; cache errors are treated as misses
+func (g *Gateway) cachedResult(ctx context.Context, userID, messageID, kind, hash string) *models.AIResult {
+	if g.Cache == nil || messageID == "" {
+		return nil
+	}
+	res, err := g.Cache.GetResult(ctx, userID, messageID, kind)
+	if err != nil {
+		log.Warn().Err(err).Str("user_id", userID).Str("kind", kind).Msg("ai: cache lookup failed")
+		return nil
+	}
+	if res == nil || res.ContentHash != hash {
+		return nil
+	}
+	return res
+}
+
+// storeResult caches a result; failures are logged, not returned
+func (g *Gateway) storeResult(ctx context.Context, res *models.AIResult) {
+	if g.Cache == nil || res.MessageID == "" {
+		return
+	}
+	if err := g.Cache.PutResult(ctx, res); err != nil {
+		log.Warn().Err(err).Str("user_id", res.UserID).Str("kind", res.Kind).Msg("ai: failed to cache result")
+	}
+}
+
+// LastSummary returns the message's cached summary even if the content has changed since it was
+// computed, for serving while the provider is down
+func (g *Gateway) LastSummary(ctx context.Context, userID, messageID string) (string, bool) {
+	if g.Cache == nil || messageID == "" {
+		return "", false
+	}
+	res, err := g.Cache.GetResult(ctx, userID, messageID, models.AIResultSummary)
+	if err != nil || res == nil {
+		return "", false
+	}
+	return res.Result, true
+}
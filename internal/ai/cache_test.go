@@ -0,0 +1,105 @@
+package ai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+type fakeCache struct {
+	results map[string]*models.AIResult
+}
+
+func (f *fakeCache) GetResult(ctx context.Context, userID, messageID, kind string) (*models.AIResult, error) {
+	return f.results[userID+"/"+messageID+"/"+kind], nil
+}
+
+func (f *fakeCache) PutResult(ctx context.Context, res *models.AIResult) error {
+	f.results[res.UserID+"/"+res.MessageID+"/"+res.Kind] = res
+	return nil
+}
+
+func TestContentHash(t *testing.T) {
+	a := &models.EmailMessage{Subject: "Hi", Body: "body"}
+	b := &models.EmailMessage{Subject: "Hi", Body: "body", Sender: "someone-else@example.com"}
+	c := &models.EmailMessage{Subject: "Hi", Body: "edited body"}
+	if ContentHash(a) != ContentHash(b) {
+		t.Error("expected hash to depend only on subject and body")
+	}
+	if ContentHash(a) == ContentHash(c) {
+		t.Error("expected hash to change when the body changes")
+	}
+}
+
+func TestGateway_CachesResultsByContentHash(t *testing.T) {
+	ctx := context.Background()
+	llm := &countingLLM{}
+	g := NewGateway(llm, fakeSettings{sharing: true}, false)
+	g.Cache = &fakeCache{results: map[string]*models.AIResult{}}
+	msg := &models.EmailMessage{EmailMessageID: "m1", Subject: "Invoice", Body: "Amount due"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := g.Summarize(ctx, "u1", msg); err != nil {
+			t.Fatalf("Summarize: %v", err)
+		}
+		if _, err := g.Categorize(ctx, "u1", msg); err != nil {
+			t.Fatalf("Categorize: %v", err)
+		}
+	}
+	if llm.calls != 2 {
+		t.Errorf("expected one summary and one categorization call, got %d", llm.calls)
+	}
+
+	// Changed content invalidates the cached results
+	msg.Body = "Amount due: updated"
+	if _, err := g.Summarize(ctx, "u1", msg); err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if llm.calls != 3 {
+		t.Errorf("expected changed body to be re-summarized, got %d calls", llm.calls)
+	}
+
+	// Cached summaries are served even when the budget is exhausted, but never without consent
+	g.SetBudget(Budget{UserDaily: 1})
+	g.UsageStore = &fakeUsage{records: []usageRecord{{userID: "u1", tokens: 10, at: g.now()}}}
+	if _, err := g.Summarize(ctx, "u1", msg); err != nil {
+		t.Errorf("expected cached summary over budget, got %v", err)
+	}
+	g.settings = fakeSettings{sharing: false}
+	if _, err := g.Summarize(ctx, "u1", msg); err != ErrConsentRequired {
+		t.Errorf("expected ErrConsentRequired, got %v", err)
+	}
+}
+
+func TestGateway_RecategorizeBypassesCache(t *testing.T) {
+	ctx := context.Background()
+	llm := &countingLLM{}
+	g := NewGateway(llm, fakeSettings{sharing: true}, false)
+	cache := &fakeCache{results: map[string]*models.AIResult{}}
+	g.Cache = cache
+	msg := &models.EmailMessage{EmailMessageID: "m1", Subject: "Invoice", Body: "Amount due"}
+
+	if _, err := g.Categorize(ctx, "u1", msg); err != nil {
+		t.Fatalf("Categorize: %v", err)
+	}
+	if _, err := g.Recategorize(ctx, "u1", msg); err != nil {
+		t.Fatalf("Recategorize: %v", err)
+	}
+	if llm.calls != 2 {
+		t.Errorf("expected Recategorize to call the provider again, got %d calls", llm.calls)
+	}
+	if len(cache.results) != 1 {
+		t.Errorf("expected the fresh result to replace the cached one, got %d entries", len(cache.results))
+	}
+}
+
+func TestEstimateCategorizationTokens(t *testing.T) {
+	if got := EstimateCategorizationTokens(0, 100); got != 0 {
+		t.Errorf("expected no tokens for no messages, got %d", got)
+	}
+	one, two := EstimateCategorizationTokens(1, 400), EstimateCategorizationTokens(2, 800)
+	if one <= 100 || two < 2*one || two > 2*one+1 {
+		t.Errorf("expected estimate to include prompt overhead and scale per message, got %d and %d", one, two)
+	}
+}
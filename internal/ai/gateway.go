@@ -0,0 +1,233 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/plaintext"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrLocalOnly means the deployment forbids sending content to external providers
+	ErrLocalOnly = errors.New("ai: local-only mode is enabled")
+	// ErrConsentRequired means the user has not enabled AI data sharing
+	ErrConsentRequired = errors.New("ai: user has not consented to AI data sharing")
+	// ErrUnavailable means no external provider is configured
+	ErrUnavailable = errors.New("ai: no external provider configured")
+	// ErrEncryptedCache means the user encrypts cached content, which AI features must not read
+	ErrEncryptedCache = errors.New("ai: message cache is encrypted")
+)
+
+// Categorization sources
+const (
+	SourceLLM       = "llm"
+	SourceHeuristic = "heuristic"
+	// SourceProvider means the provider's own classification (e.g. a Gmail tab) was adopted
+	SourceProvider = "provider"
+)
+
+// Categorization is the result of categorizing a message
+type Categorization struct {
+	Category   string
+	Confidence float64
+	Source     string
+}
+
+// SettingsStore provides the per-user consent setting
+type SettingsStore interface {
+	Get(ctx context.Context, userID string) (*models.UserSettings, error)
+}
+
+// Gateway routes AI work either to an external LLM or to local fallbacks,
+// depending on deploy-level and per-user privacy settings
+type Gateway struct {
+	llm       LLM
+	settings  SettingsStore
+	localOnly bool
+	// UsageStore records token usage for budgets; optional (usage is not tracked when nil)
+	UsageStore UsageStore
+	// budget is swapped on config reload; see SetBudget
+	budget atomic.Pointer[Budget]
+	// Cache stores results per message content hash so unchanged messages are never recomputed; optional
+	Cache ResultCache
+	// Shadow evaluates a candidate categorizer alongside the active one; optional
+	Shadow *Shadow
+	health providerHealth
+	now    func() time.Time
+}
+
+// NewGateway creates a Gateway; llm may be nil when no provider is configured
+func NewGateway(llm LLM, settings SettingsStore, localOnly bool) *Gateway {
+	return &Gateway{llm: llm, settings: settings, localOnly: localOnly, now: time.Now}
+}
+
+// LocalOnly reports whether the deployment forbids external providers
+func (g *Gateway) LocalOnly() bool {
+	return g.localOnly
+}
+
+// CheckConsent returns nil if the deployment and the user allow AI features that use message content
+func (g *Gateway) CheckConsent(ctx context.Context, userID string) error {
+	if g.localOnly {
+		return ErrLocalOnly
+	}
+	s, err := g.settings.Get(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("ai: load settings: %w", err)
+	}
+	if s.EncryptedCache {
+		return ErrEncryptedCache
+	}
+	if !s.AIDataSharing {
+		return ErrConsentRequired
+	}
+	return nil
+}
+
+// CheckExternal returns nil if the user's message content may be sent to the external provider now
+func (g *Gateway) CheckExternal(ctx context.Context, userID string) error {
+	if err := g.CheckConsent(ctx, userID); err != nil {
+		return err
+	}
+	if g.llm == nil {
+		return ErrUnavailable
+	}
+	return g.checkBudget(ctx, userID)
+}
+
+// Summarize returns a short summary of the message using the external provider.
+// Cached summaries are returned without calling the provider while the message content is unchanged.
+func (g *Gateway) Summarize(ctx context.Context, userID string, msg *models.EmailMessage) (string, error) {
+	if err := g.CheckConsent(ctx, userID); err != nil {
+		return "", err
+	}
+	hash := ContentHash(msg)
+	if cached := g.cachedResult(ctx, userID, msg.EmailMessageID, models.AIResultSummary, hash); cached != nil {
+		return cached.Result, nil
+	}
+	if err := g.CheckExternal(ctx, userID); err != nil {
+		return "", err
+	}
+	resp, err := g.complete(ctx, Request{
+		System:    "Summarize the email in at most three sentences. Reply with the summary only.",
+		Prompt:    messagePrompt(msg),
+		MaxTokens: 200,
+	})
+	if err != nil {
+		return "", err
+	}
+	g.recordUsage(ctx, userID, FeatureSummarize, resp)
+	summary := strings.TrimSpace(resp.Text)
+	g.storeResult(ctx, &models.AIResult{UserID: userID, MessageID: msg.EmailMessageID, Kind: models.AIResultSummary,
+		ContentHash: hash, Result: summary, Source: SourceLLM})
+	return summary, nil
+}
+
+// Categorize assigns one of Categories to the message. It uses the external provider
+// when allowed and within budget, and falls back to local heuristics otherwise or on provider failure.
+// Provider results are cached by content hash; heuristic results are cheap and never cached.
+func (g *Gateway) Categorize(ctx context.Context, userID string, msg *models.EmailMessage) (*Categorization, error) {
+	return g.categorize(ctx, userID, msg, true)
+}
+
+// Recategorize is like Categorize but ignores any cached result, so a changed model or prompt is
+// applied to messages whose content has not changed. The fresh result replaces the cached one.
+func (g *Gateway) Recategorize(ctx context.Context, userID string, msg *models.EmailMessage) (*Categorization, error) {
+	return g.categorize(ctx, userID, msg, false)
+}
+
+func (g *Gateway) categorize(ctx context.Context, userID string, msg *models.EmailMessage, useCache bool) (*Categorization, error) {
+	hash := ContentHash(msg)
+	if useCache {
+		if cached := g.cachedResult(ctx, userID, msg.EmailMessageID, models.AIResultCategory, hash); cached != nil {
+			return &Categorization{Category: cached.Result, Confidence: cached.Confidence, Source: cached.Source}, nil
+		}
+	}
+	if err := g.CheckExternal(ctx, userID); err != nil {
+		if !isPolicyError(err) {
+			return nil, err
+		}
+		return CategorizeHeuristic(msg), nil
+	}
+	resp, err := g.complete(ctx, Request{
+		System:    categorizeSystemPrompt,
+		Prompt:    messagePrompt(msg),
+		MaxTokens: categorizeMaxTokens,
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("user_id", userID).Msg("ai: categorization failed, using heuristics")
+		return CategorizeHeuristic(msg), nil
+	}
+	g.recordUsage(ctx, userID, FeatureCategorize, resp)
+	if category, ok := matchCategory(resp.Text); ok {
+		c := &Categorization{Category: category, Confidence: 0.8, Source: SourceLLM}
+		g.storeResult(ctx, &models.AIResult{UserID: userID, MessageID: msg.EmailMessageID, Kind: models.AIResultCategory,
+			ContentHash: hash, Result: c.Category, Confidence: c.Confidence, Source: c.Source})
+		g.runShadow(ctx, userID, msg, c)
+		return c, nil
+	}
+	return CategorizeHeuristic(msg), nil
+}
+
+// isPolicyError reports whether err means external providers are not allowed, not configured or over budget
+func isPolicyError(err error) bool {
+	return errors.Is(err, ErrLocalOnly) || errors.Is(err, ErrConsentRequired) || errors.Is(err, ErrUnavailable) ||
+		errors.Is(err, ErrBudgetExhausted) || errors.Is(err, ErrEncryptedCache)
+}
+
+// MaxPromptBody bounds how much of the body is sent to the provider
+const MaxPromptBody = 4000
+
+const categorizeMaxTokens = 10
+
+var categorizeSystemPrompt = "Classify the email into exactly one of these categories: " + strings.Join(Categories, ", ") +
+	". Reply with the category name only."
+
+// EstimateCategorizationTokens approximates the tokens needed to categorize count messages whose
+// prompt content (sender, subject and truncated body) totals contentChars characters. It assumes
+// roughly four characters per token, which is close enough for budgeting.
+func EstimateCategorizationTokens(count, contentChars int) int {
+	if count <= 0 {
+		return 0
+	}
+	overhead := len(categorizeSystemPrompt) + len(messagePrompt(&models.EmailMessage{}))
+	return (contentChars+count*overhead)/4 + count*categorizeMaxTokens
+}
+
+// promptBody is the message text AI features read: the plain text body, the HTML body converted
+// when the message has no text part, or the snippet when the body has not been fetched
+func promptBody(msg *models.EmailMessage) string {
+	if body := plaintext.Body(msg.Body, msg.HTMLBody); body != "" {
+		return body
+	}
+	return msg.Snippet
+}
+
+func messagePrompt(msg *models.EmailMessage) string {
+	body := plaintext.Truncate(promptBody(msg), MaxPromptBody)
+	prompt := fmt.Sprintf("From: %s\nSubject: %s\n", msg.Sender, msg.Subject)
+	// The provider's own classification is a useful hint, not a verdict
+	if msg.ProviderCategory != "" {
+		prompt += "Provider category: " + msg.ProviderCategory + "\n"
+	}
+	if msg.ProviderImportant {
+		prompt += "Marked important by the provider\n"
+	}
+	return prompt + "\n" + body
+}
+
+func matchCategory(text string) (string, bool) {
+	text = strings.Trim(strings.TrimSpace(text), ".\"'")
+	for _, c := range Categories {
+		if strings.EqualFold(text, c) {
+			return c, true
+		}
+	}
+	return "", false
+}
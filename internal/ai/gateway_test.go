@@ -0,0 +1,176 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+type fakeLLM struct {
+	text  string
+	err   error
+	calls int
+}
+
+func (f *fakeLLM) Complete(ctx context.Context, req Request) (*Response, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &Response{Text: f.text}, nil
+}
+
+type fakeSettings struct {
+	sharing   bool
+	encrypted bool
+}
+
+func (f fakeSettings) Get(ctx context.Context, userID string) (*models.UserSettings, error) {
+	return &models.UserSettings{UserID: userID, AIDataSharing: f.sharing, EncryptedCache: f.encrypted}, nil
+}
+
+func TestGateway_CheckExternal(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		llm       LLM
+		settings  fakeSettings
+		localOnly bool
+		want      error
+	}{
+		{"allowed", &fakeLLM{}, fakeSettings{sharing: true}, false, nil},
+		{"local only", &fakeLLM{}, fakeSettings{sharing: true}, true, ErrLocalOnly},
+		{"no consent", &fakeLLM{}, fakeSettings{}, false, ErrConsentRequired},
+		{"encrypted cache", &fakeLLM{}, fakeSettings{sharing: true, encrypted: true}, false, ErrEncryptedCache},
+		{"no provider", nil, fakeSettings{sharing: true}, false, ErrUnavailable},
+	}
+	for _, tt := range tests {
+		g := NewGateway(tt.llm, tt.settings, tt.localOnly)
+		if err := g.CheckExternal(ctx, "u1"); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestGateway_SummarizeRequiresConsent(t *testing.T) {
+	llm := &fakeLLM{text: "summary"}
+	g := NewGateway(llm, fakeSettings{sharing: false}, false)
+	if _, err := g.Summarize(context.Background(), "u1", &models.EmailMessage{Subject: "hi"}); !errors.Is(err, ErrConsentRequired) {
+		t.Errorf("expected ErrConsentRequired, got %v", err)
+	}
+	if llm.calls != 0 {
+		t.Errorf("expected no LLM calls without consent, got %d", llm.calls)
+	}
+
+	g = NewGateway(llm, fakeSettings{sharing: true}, false)
+	got, err := g.Summarize(context.Background(), "u1", &models.EmailMessage{Subject: "hi"})
+	if err != nil || got != "summary" {
+		t.Errorf("unexpected summary %q, err %v", got, err)
+	}
+}
+
+func TestGateway_Categorize(t *testing.T) {
+	ctx := context.Background()
+	msg := &models.EmailMessage{Sender: "shop@example.com", Subject: "50% off everything"}
+
+	llm := &fakeLLM{text: "social"}
+	got, err := NewGateway(llm, fakeSettings{sharing: true}, false).Categorize(ctx, "u1", msg)
+	if err != nil || got.Category != "Social" || got.Source != SourceLLM {
+		t.Errorf("expected LLM categorization, got %+v, err %v", got, err)
+	}
+
+	// local-only falls back to heuristics without calling the provider
+	llm = &fakeLLM{text: "Social"}
+	got, err = NewGateway(llm, fakeSettings{sharing: true}, true).Categorize(ctx, "u1", msg)
+	if err != nil || got.Category != "Promotions/Ads" || got.Source != SourceHeuristic {
+		t.Errorf("expected heuristic categorization, got %+v, err %v", got, err)
+	}
+	if llm.calls != 0 {
+		t.Errorf("expected no LLM calls in local-only mode, got %d", llm.calls)
+	}
+
+	// provider failure falls back to heuristics
+	llm = &fakeLLM{err: errors.New("boom")}
+	got, err = NewGateway(llm, fakeSettings{sharing: true}, false).Categorize(ctx, "u1", msg)
+	if err != nil || got.Source != SourceHeuristic {
+		t.Errorf("expected heuristic fallback, got %+v, err %v", got, err)
+	}
+}
+
+func TestCategorizeHeuristic(t *testing.T) {
+	tests := []struct {
+		msg  models.EmailMessage
+		want string
+	}{
+		{models.EmailMessage{Subject: "Action required: verify your account"}, "Important"},
+		{models.EmailMessage{Sender: "notifications@linkedin.com", Subject: "You appeared in 3 searches"}, "Social"},
+		{models.EmailMessage{Subject: "Your order has shipped"}, "Updates"},
+		{models.EmailMessage{Subject: "Weekly digest"}, "Forums"},
+		{models.EmailMessage{Subject: "Lunch tomorrow?"}, "Primary"},
+		{models.EmailMessage{Subject: "Your order has shipped", ProviderCategory: "Promotions/Ads"}, "Promotions/Ads"},
+	}
+	for _, tt := range tests {
+		if got := CategorizeHeuristic(&tt.msg); got.Category != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.msg.Subject, got.Category, tt.want)
+		}
+	}
+}
+
+type fakeShadowStore struct {
+	results []*models.ShadowResult
+}
+
+func (f *fakeShadowStore) RecordShadowResult(ctx context.Context, res *models.ShadowResult) error {
+	f.results = append(f.results, res)
+	return nil
+}
+
+func TestGateway_ShadowCategorizer(t *testing.T) {
+	store := &fakeShadowStore{}
+	shadowLLM := &fakeLLM{text: "Promotions/Ads"}
+	g := NewGateway(&fakeLLM{text: "Primary"}, fakeSettings{sharing: true}, false)
+	g.Shadow = &Shadow{LLM: shadowLLM, Version: "model-b", ActiveVersion: "model-a", Store: store}
+
+	c, err := g.Categorize(context.Background(), "u1", &models.EmailMessage{EmailMessageID: "m1", Subject: "standup"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Category != "Primary" {
+		t.Errorf("expected the active category to be returned, got %q", c.Category)
+	}
+	if len(store.results) != 1 {
+		t.Fatalf("expected one shadow result, got %d", len(store.results))
+	}
+	got := store.results[0]
+	if got.ActiveCategory != "Primary" || got.ShadowCategory != "Promotions/Ads" || got.ShadowVersion != "model-b" {
+		t.Errorf("unexpected shadow result %+v", got)
+	}
+
+	// Without consent the active categorizer stays local, and so does the shadow
+	g = NewGateway(&fakeLLM{text: "Primary"}, fakeSettings{sharing: false}, false)
+	g.Shadow = &Shadow{LLM: shadowLLM, Version: "model-b", Store: store}
+	if _, err := g.Categorize(context.Background(), "u1", &models.EmailMessage{EmailMessageID: "m2"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if shadowLLM.calls != 1 {
+		t.Errorf("expected no shadow call without consent, got %d calls", shadowLLM.calls)
+	}
+}
+
+func TestMessagePrompt_BodyFallbacks(t *testing.T) {
+	htmlOnly := &models.EmailMessage{Subject: "s", Snippet: "snip", HTMLBody: `<p>Hello <a href="https://example.com">there</a></p>`}
+	if got := messagePrompt(htmlOnly); !strings.HasSuffix(got, "\nHello there (https://example.com)") {
+		t.Errorf("expected the converted HTML body, got %q", got)
+	}
+	if got := messagePrompt(&models.EmailMessage{Snippet: "snip"}); !strings.HasSuffix(got, "\nsnip") {
+		t.Errorf("expected the snippet, got %q", got)
+	}
+	long := &models.EmailMessage{Body: strings.Repeat("é", MaxPromptBody)}
+	if got := messagePrompt(long); !utf8.ValidString(got) {
+		t.Error("expected the truncated body to stay valid UTF-8")
+	}
+}
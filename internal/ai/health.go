@@ -0,0 +1,98 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrProviderFailed wraps errors from the external provider itself (outages, rate limits,
+// bad responses), as opposed to policy errors that keep the provider from being called
+var ErrProviderFailed = errors.New("ai: provider request failed")
+
+// DefaultProviderRetryAfter is the retry hint given when a failed provider sent none
+const DefaultProviderRetryAfter = 30 * time.Second
+
+// Provider health states reported by ProviderStatus
+const (
+	ProviderOK       = "ok"
+	ProviderDegraded = "degraded"
+	// ProviderDisabled means no external provider is configured or the deployment is local-only
+	ProviderDisabled = "disabled"
+)
+
+// ProviderStatus is a point-in-time view of the external provider's health
+type ProviderStatus struct {
+	Status string `json:"status"`
+	// ConsecutiveFailures counts provider calls that failed since the last success
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+}
+
+// providerHealth records the outcome of provider calls
+type providerHealth struct {
+	mu          sync.Mutex
+	failures    int
+	lastErr     string
+	lastFailure time.Time
+}
+
+func (h *providerHealth) record(err error, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err == nil {
+		h.failures = 0
+		return
+	}
+	h.failures++
+	h.lastErr = err.Error()
+	h.lastFailure = now
+}
+
+// RetryAfter returns the back-off the provider asked for, DefaultProviderRetryAfter if it
+// gave none, or zero if err is not a provider failure
+func RetryAfter(err error) time.Duration {
+	if !errors.Is(err, ErrProviderFailed) {
+		return 0
+	}
+	var retryable *retryableError
+	if errors.As(err, &retryable) && retryable.retryAfter > 0 {
+		return min(retryable.retryAfter, maxOpenAIBackoff)
+	}
+	return DefaultProviderRetryAfter
+}
+
+// ProviderStatus reports whether the external provider is working. It is degraded while the
+// most recent call failed; callers fall back to heuristics or cached results meanwhile.
+func (g *Gateway) ProviderStatus() ProviderStatus {
+	if g.llm == nil || g.localOnly {
+		return ProviderStatus{Status: ProviderDisabled}
+	}
+	g.health.mu.Lock()
+	defer g.health.mu.Unlock()
+	st := ProviderStatus{Status: ProviderOK, ConsecutiveFailures: g.health.failures}
+	if g.health.failures > 0 {
+		st.Status = ProviderDegraded
+		st.LastError = g.health.lastErr
+		at := g.health.lastFailure
+		st.LastFailureAt = &at
+	}
+	return st
+}
+
+// complete calls the provider, recording the outcome and marking failures with ErrProviderFailed
+func (g *Gateway) complete(ctx context.Context, req Request) (*Response, error) {
+	resp, err := g.llm.Complete(ctx, req)
+	if ctx.Err() != nil && err != nil {
+		// The caller gave up; that says nothing about the provider
+		return nil, err
+	}
+	g.health.record(err, g.now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProviderFailed, err)
+	}
+	return resp, nil
+}
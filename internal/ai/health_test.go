@@ -0,0 +1,71 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestGateway_ProviderStatus(t *testing.T) {
+	ctx := context.Background()
+	msg := &models.EmailMessage{EmailMessageID: "m1", Subject: "Invoice", Body: "Amount due"}
+	llm := &fakeLLM{text: "Finance", err: errors.New("connection refused")}
+	g := NewGateway(llm, fakeSettings{sharing: true}, false)
+	if st := g.ProviderStatus(); st.Status != ProviderOK {
+		t.Fatalf("expected ok before any call, got %+v", st)
+	}
+
+	// Categorization falls back to heuristics and the provider is marked degraded
+	c, err := g.Categorize(ctx, "u1", msg)
+	if err != nil || c.Source != SourceHeuristic {
+		t.Fatalf("expected heuristic fallback, got %+v, %v", c, err)
+	}
+	if st := g.ProviderStatus(); st.Status != ProviderDegraded || st.ConsecutiveFailures != 1 || st.LastFailureAt == nil {
+		t.Errorf("expected degraded after a failure, got %+v", st)
+	}
+	_, err = g.Summarize(ctx, "u1", msg)
+	if !errors.Is(err, ErrProviderFailed) || RetryAfter(err) != DefaultProviderRetryAfter {
+		t.Errorf("expected ErrProviderFailed with the default retry hint, got %v (%s)", err, RetryAfter(err))
+	}
+
+	llm.err = nil
+	if _, err := g.Summarize(ctx, "u1", msg); err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if st := g.ProviderStatus(); st.Status != ProviderOK || st.ConsecutiveFailures != 0 {
+		t.Errorf("expected ok after a success, got %+v", st)
+	}
+
+	if st := NewGateway(nil, fakeSettings{}, false).ProviderStatus(); st.Status != ProviderDisabled {
+		t.Errorf("expected disabled without a provider, got %+v", st)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	limited := &retryableError{err: errors.New("429"), retryAfter: 5 * time.Second}
+	if got := RetryAfter(errors.Join(ErrProviderFailed, limited)); got != 5*time.Second {
+		t.Errorf("expected the provider's Retry-After, got %s", got)
+	}
+	if got := RetryAfter(ErrConsentRequired); got != 0 {
+		t.Errorf("expected no retry hint for policy errors, got %s", got)
+	}
+}
+
+func TestGateway_LastSummary(t *testing.T) {
+	ctx := context.Background()
+	g := NewGateway(&fakeLLM{text: "Amount due Friday."}, fakeSettings{sharing: true}, false)
+	g.Cache = &fakeCache{results: map[string]*models.AIResult{}}
+	if _, ok := g.LastSummary(ctx, "u1", "m1"); ok {
+		t.Error("expected no summary before one is computed")
+	}
+	if _, err := g.Summarize(ctx, "u1", &models.EmailMessage{EmailMessageID: "m1", Subject: "Invoice"}); err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	// LastSummary ignores the content hash, so this holds after the message changes too
+	if got, ok := g.LastSummary(ctx, "u1", "m1"); !ok || got != "Amount due Friday." {
+		t.Errorf("unexpected last summary %q, %v", got, ok)
+	}
+}
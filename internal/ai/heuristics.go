@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+// Categories are the seeded category names a message can be assigned to
+var Categories = []string{"Primary", "Promotions/Ads", "Social", "Updates", "Forums", "To Review", "Important", "Deferred"}
+
+// categoryRule assigns category when any keyword appears in the sender or subject/snippet
+type categoryRule struct {
+	category       string
+	senderKeywords []string
+	textKeywords   []string
+}
+
+// categoryRules are checked in order; the first match wins
+var categoryRules = []categoryRule{
+	{category: "Important", textKeywords: []string{"urgent", "action required", "asap", "immediately"}},
+	{category: "Social", senderKeywords: []string{"facebook", "linkedin", "twitter", "instagram", "x.com", "tiktok"}},
+	{category: "Forums", senderKeywords: []string{"googlegroups", "groups.io", "discourse", "lists."}, textKeywords: []string{"digest", "mailing list"}},
+	{category: "Updates", textKeywords: []string{"receipt", "invoice", "order", "confirmation", "shipped", "statement", "payment"}},
+	{category: "Promotions/Ads", senderKeywords: []string{"newsletter", "marketing", "promo"}, textKeywords: []string{"% off", "sale", "deal", "offer", "coupon", "unsubscribe"}},
+}
+
+// CategorizeHeuristic assigns a category using keyword matching only; no data leaves the server.
+// The provider's own category, when present, is the baseline and wins over keywords.
+func CategorizeHeuristic(msg *models.EmailMessage) *Categorization {
+	if msg.ProviderCategory != "" {
+		return &Categorization{Category: msg.ProviderCategory, Confidence: 0.6, Source: SourceProvider}
+	}
+	sender := strings.ToLower(msg.Sender)
+	text := strings.ToLower(msg.Subject + " " + msg.Snippet)
+	for _, r := range categoryRules {
+		if containsAny(sender, r.senderKeywords) || containsAny(text, r.textKeywords) {
+			return &Categorization{Category: r.category, Confidence: 0.5, Source: SourceHeuristic}
+		}
+	}
+	return &Categorization{Category: "Primary", Confidence: 0.3, Source: SourceHeuristic}
+}
+
+func containsAny(s string, keywords []string) bool {
+	for _, k := range keywords {
+		if strings.Contains(s, k) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+// Package ai is the single gateway between Inbox Whisperer and language models.
+// Every feature that sends message content to an external provider goes through
+// Gateway, which enforces the deploy-level local-only switch and per-user consent.
+package ai
+
+import "context"
+
+// Request is a single completion request
+type Request struct {
+	System    string
+	Prompt    string
+	MaxTokens int
+}
+
+// Response is the result of a completion request
+type Response struct {
+	Text             string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// LLM is implemented by external language model providers
+type LLM interface {
+	Complete(ctx context.Context, req Request) (*Response, error)
+}
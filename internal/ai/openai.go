@@ -0,0 +1,157 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultOpenAIModel is used when no model is configured
+const DefaultOpenAIModel = "gpt-4o-mini"
+
+const defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// DefaultOpenAIAttempts is how often a request is tried when rate limited or on server and
+// network errors
+const DefaultOpenAIAttempts = 3
+
+// openAIBackoff is the delay before the first retry; it doubles after each attempt and is
+// jittered by ±50%. A Retry-After from the API is used instead, up to maxOpenAIBackoff.
+var (
+	openAIBackoff    = 500 * time.Millisecond
+	maxOpenAIBackoff = 10 * time.Second
+)
+
+// OpenAIClient calls the OpenAI chat completions API
+type OpenAIClient struct {
+	APIKey     string
+	Model      string
+	BaseURL    string
+	HTTPClient *http.Client
+	// MaxAttempts bounds the tries per request; 0 or 1 means no retries
+	MaxAttempts int
+	// KeyFunc, if set, supplies the API key for each request instead of APIKey, so a rotated
+	// key takes effect without rebuilding the client
+	KeyFunc func() string
+}
+
+// NewOpenAIClient creates an OpenAIClient, using DefaultOpenAIModel if model is empty
+func NewOpenAIClient(apiKey, model string) *OpenAIClient {
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	return &OpenAIClient{APIKey: apiKey, Model: model, BaseURL: defaultOpenAIBaseURL, HTTPClient: http.DefaultClient, MaxAttempts: DefaultOpenAIAttempts}
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model     string        `json:"model"`
+	Messages  []chatMessage `json:"messages"`
+	MaxTokens int           `json:"max_tokens,omitempty"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// retryableError is a failed call worth repeating, with the API's Retry-After if it sent one
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// Complete sends req, retrying rate limits and server and network errors with backoff
+func (c *OpenAIClient) Complete(ctx context.Context, req Request) (*Response, error) {
+	body := chatRequest{Model: c.Model, MaxTokens: req.MaxTokens}
+	if req.System != "" {
+		body.Messages = append(body.Messages, chatMessage{Role: "system", Content: req.System})
+	}
+	body.Messages = append(body.Messages, chatMessage{Role: "user", Content: req.Prompt})
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	delay := openAIBackoff
+	for attempt := 1; ; attempt++ {
+		resp, err := c.complete(ctx, payload)
+		var retryable *retryableError
+		if err == nil || attempt >= c.MaxAttempts || !errors.As(err, &retryable) {
+			return resp, err
+		}
+		wait := delay/2 + rand.N(delay)
+		if retryable.retryAfter > 0 {
+			wait = min(retryable.retryAfter, maxOpenAIBackoff)
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, errors.Join(err, ctx.Err())
+		case <-t.C:
+		}
+		delay *= 2
+	}
+}
+
+// complete makes one chat completions call
+func (c *OpenAIClient) complete(ctx context.Context, payload []byte) (*Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	apiKey := c.APIKey
+	if c.KeyFunc != nil {
+		apiKey = c.KeyFunc()
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &retryableError{err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("openai: status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			secs, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+			return nil, &retryableError{err: err, retryAfter: time.Duration(secs) * time.Second}
+		}
+		return nil, err
+	}
+	var out chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("openai: decode response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return nil, fmt.Errorf("openai: empty response")
+	}
+	return &Response{
+		Text:             out.Choices[0].Message.Content,
+		PromptTokens:     out.Usage.PromptTokens,
+		CompletionTokens: out.Usage.CompletionTokens,
+	}, nil
+}
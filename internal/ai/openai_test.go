@@ -0,0 +1,118 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpenAIClient_Complete(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer key" {
+			t.Errorf("unexpected auth header %q", got)
+		}
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if req.Model != DefaultOpenAIModel || len(req.Messages) != 2 {
+			t.Errorf("unexpected request %+v", req)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hello"}}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`))
+	}))
+	defer srv.Close()
+
+	c := NewOpenAIClient("key", "")
+	c.BaseURL = srv.URL
+	resp, err := c.Complete(context.Background(), Request{System: "sys", Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if resp.Text != "hello" || resp.PromptTokens != 12 || resp.CompletionTokens != 3 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+// fastOpenAIRetries shortens the retry backoff for the rest of the test
+func fastOpenAIRetries(t *testing.T) {
+	backoff := openAIBackoff
+	openAIBackoff = time.Millisecond
+	t.Cleanup(func() { openAIBackoff = backoff })
+}
+
+func TestOpenAIClient_ErrorStatus(t *testing.T) {
+	fastOpenAIRetries(t)
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c := NewOpenAIClient("key", "m")
+	c.BaseURL = srv.URL
+	if _, err := c.Complete(context.Background(), Request{Prompt: "hi"}); err == nil {
+		t.Error("expected error for non-200 status")
+	}
+	if calls != DefaultOpenAIAttempts {
+		t.Errorf("expected %d attempts, got %d", DefaultOpenAIAttempts, calls)
+	}
+}
+
+func TestOpenAIClient_Retries(t *testing.T) {
+	fastOpenAIRetries(t)
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	c := NewOpenAIClient("key", "m")
+	c.BaseURL = srv.URL
+	resp, err := c.Complete(context.Background(), Request{Prompt: "hi"})
+	if err != nil || resp.Text != "ok" || calls != 2 {
+		t.Errorf("expected a retried success, got %+v, %v after %d calls", resp, err, calls)
+	}
+
+	// Client errors are not retried
+	calls = 0
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer bad.Close()
+	c.BaseURL = bad.URL
+	if _, err := c.Complete(context.Background(), Request{Prompt: "hi"}); err == nil || calls != 1 {
+		t.Errorf("expected one failed call, got %v after %d calls", err, calls)
+	}
+}
+
+func TestOpenAIClient_KeyFunc(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	c := NewOpenAIClient("stale", "m")
+	c.BaseURL = srv.URL
+	c.KeyFunc = func() string { return "rotated" }
+	if _, err := c.Complete(context.Background(), Request{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if auth != "Bearer rotated" {
+		t.Errorf("expected the rotated key to be used, got %q", auth)
+	}
+}
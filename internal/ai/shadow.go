@@ -0,0 +1,59 @@
+package ai
+
+import (
+	"context"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// FeatureCategorizeShadow is the token usage of shadow categorizations
+const FeatureCategorizeShadow = "categorize_shadow"
+
+// ShadowStore records shadow categorizations (see data.ShadowResultRepository)
+type ShadowStore interface {
+	RecordShadowResult(ctx context.Context, res *models.ShadowResult) error
+}
+
+// Shadow is a candidate categorizer, e.g. a newer model, evaluated against the active one.
+// Whenever the active categorizer asks the provider, the shadow categorizes the same message
+// and both answers are stored for comparison; users only ever see the active category.
+type Shadow struct {
+	LLM LLM
+	// Version and ActiveVersion name the shadow and active categorizers in reports
+	Version       string
+	ActiveVersion string
+	Store         ShadowStore
+}
+
+// runShadow categorizes msg with the shadow categorizer and records it next to active. It runs
+// only after the active categorizer used the provider, so consent and budget were just checked.
+// Failures are logged and never affect the active result.
+func (g *Gateway) runShadow(ctx context.Context, userID string, msg *models.EmailMessage, active *Categorization) {
+	if g.Shadow == nil || g.Shadow.LLM == nil || g.Shadow.Store == nil {
+		return
+	}
+	resp, err := g.Shadow.LLM.Complete(ctx, Request{
+		System:    categorizeSystemPrompt,
+		Prompt:    messagePrompt(msg),
+		MaxTokens: categorizeMaxTokens,
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("user_id", userID).Str("shadow_version", g.Shadow.Version).Msg("ai: shadow categorization failed")
+		return
+	}
+	g.recordUsage(ctx, userID, FeatureCategorizeShadow, resp)
+	// An answer that is not a category is stored as "" and counts as a disagreement
+	category, _ := matchCategory(resp.Text)
+	err = g.Shadow.Store.RecordShadowResult(ctx, &models.ShadowResult{
+		UserID:         userID,
+		MessageID:      msg.EmailMessageID,
+		ActiveVersion:  g.Shadow.ActiveVersion,
+		ShadowVersion:  g.Shadow.Version,
+		ActiveCategory: active.Category,
+		ShadowCategory: category,
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("user_id", userID).Msg("ai: failed to record shadow categorization")
+	}
+}
@@ -0,0 +1,161 @@
+// Package analytics records user activity and computes the triage metrics
+// behind GET /api/users/me/stats.
+package analytics
+
+import (
+	"context"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/notify"
+	"github.com/rs/zerolog/log"
+)
+
+// Actions recorded when Whisperer acts on a user's behalf
+const (
+	ActionArchived     = "archived"
+	ActionUnsubscribed = "unsubscribed"
+)
+
+// Rough per-action time savings used for the "you saved X minutes" estimate
+const (
+	minutesPerMessage     = 0.1 // skimming a message Whisperer already triaged
+	minutesPerArchive     = 0.2
+	minutesPerUnsubscribe = 2.0
+)
+
+// trendWeeks is how many weeks of inbox size history Stats returns
+const trendWeeks = 4
+
+// Bounds of the sender and volume analytics
+const (
+	// DefaultRange is the period analysed when the caller gives no start
+	DefaultRange = 30 * 24 * time.Hour
+	// MaxRange bounds the period, and so the length of the volume histogram
+	MaxRange           = 366 * 24 * time.Hour
+	DefaultSenderLimit = 20
+	MaxSenderLimit     = 100
+)
+
+// Service records activity and computes per-user stats
+type Service struct {
+	repo data.AnalyticsRepository
+	// Settings, if set, gives the timezone the volume histogram's days are in; UTC otherwise
+	Settings data.UserSettingsRepository
+	now      func() time.Time
+}
+
+func NewService(repo data.AnalyticsRepository) *Service {
+	return &Service{repo: repo, now: time.Now}
+}
+
+// Record logs an action taken on the user's behalf (e.g. ActionArchived)
+func (s *Service) Record(ctx context.Context, userID, action, targetType string) error {
+	return s.repo.RecordAction(ctx, userID, action, targetType)
+}
+
+// Subscribe samples the user's inbox size after each sync. It returns a function that removes the subscription.
+func (s *Service) Subscribe() (unsubscribe func()) {
+	return notify.Subscribe(notify.EventSyncComplete, func(ctx context.Context, userID string) {
+		if err := s.SampleInboxSize(ctx, userID); err != nil {
+			log.Error().Str("userID", userID).Err(err).Msg("analytics: failed to sample inbox size")
+		}
+	})
+}
+
+// SampleInboxSize records today's inbox size (cached message count) for the user
+func (s *Service) SampleInboxSize(ctx context.Context, userID string) error {
+	size, err := s.repo.CountMessages(ctx, userID)
+	if err != nil {
+		return err
+	}
+	now := s.now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return s.repo.RecordInboxSize(ctx, userID, day, size)
+}
+
+// Stats returns the user's activity over the last 7 days and the recent inbox size trend
+func (s *Service) Stats(ctx context.Context, userID string) (*models.UserStats, error) {
+	now := s.now()
+	since := now.Add(-7 * 24 * time.Hour)
+	stats := &models.UserStats{Since: since}
+
+	var err error
+	if stats.MessagesProcessed, err = s.repo.CountMessagesCachedSince(ctx, userID, since); err != nil {
+		return nil, err
+	}
+	if stats.Archived, err = s.repo.CountActionsSince(ctx, userID, ActionArchived, since); err != nil {
+		return nil, err
+	}
+	if stats.Unsubscribes, err = s.repo.CountActionsSince(ctx, userID, ActionUnsubscribed, since); err != nil {
+		return nil, err
+	}
+	if stats.InboxSizeTrend, err = s.repo.WeeklyInboxSizes(ctx, userID, now.AddDate(0, 0, -7*trendWeeks)); err != nil {
+		return nil, err
+	}
+	if stats.InboxSizeTrend == nil {
+		stats.InboxSizeTrend = []models.InboxSizePoint{}
+	}
+	stats.EstimatedMinutesSaved = float64(stats.MessagesProcessed)*minutesPerMessage +
+		float64(stats.Archived)*minutesPerArchive +
+		float64(stats.Unsubscribes)*minutesPerUnsubscribe
+	return stats, nil
+}
+
+// Senders returns who sent the user the most mail between from and to, busiest first
+func (s *Service) Senders(ctx context.Context, userID string, from, to time.Time, limit int) ([]models.SenderStat, error) {
+	stats, err := s.repo.SenderStats(ctx, userID, from.UnixMilli(), to.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		if stats[i].Messages > 0 {
+			stats[i].UnreadRatio = float64(stats[i].Unread) / float64(stats[i].Messages)
+		}
+	}
+	return stats, nil
+}
+
+// Volume returns how much mail the user received each day between from and to, days being in
+// the user's timezone. Days without mail are included with zero counts.
+func (s *Service) Volume(ctx context.Context, userID string, from, to time.Time) (*models.InboxVolume, error) {
+	loc := s.location(ctx, userID)
+	counted, err := s.repo.DailyVolume(ctx, userID, from.UnixMilli(), to.UnixMilli(), loc.String())
+	if err != nil {
+		return nil, err
+	}
+	byDay := make(map[string]models.VolumePoint, len(counted))
+	for _, p := range counted {
+		byDay[p.Day] = p
+	}
+	v := &models.InboxVolume{Timezone: loc.String(), Days: []models.VolumePoint{}}
+	first, last := from.In(loc), to.Add(-time.Millisecond).In(loc)
+	day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc)
+	for ; !day.After(last); day = day.AddDate(0, 0, 1) {
+		key := day.Format(time.DateOnly)
+		p, ok := byDay[key]
+		if !ok {
+			p = models.VolumePoint{Day: key}
+		}
+		v.Days = append(v.Days, p)
+	}
+	return v, nil
+}
+
+// location returns the user's timezone, falling back to UTC when it is unset or unreadable
+func (s *Service) location(ctx context.Context, userID string) *time.Location {
+	if s.Settings == nil {
+		return time.UTC
+	}
+	us, err := s.Settings.Get(ctx, userID)
+	if err != nil {
+		log.Warn().Err(err).Str("userID", userID).Msg("analytics: failed to load settings, using UTC")
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(us.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,136 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/notify"
+)
+
+type fakeRepo struct {
+	actions  map[string]int
+	cached   int
+	total    int
+	sampled  map[time.Time]int
+	weekly   []models.InboxSizePoint
+	gotSince time.Time
+	senders  []models.SenderStat
+	volume   []models.VolumePoint
+	gotZone  string
+}
+
+func (f *fakeRepo) RecordAction(ctx context.Context, userID, action, targetType string) error {
+	f.actions[action]++
+	return nil
+}
+func (f *fakeRepo) CountActionsSince(ctx context.Context, userID, action string, since time.Time) (int, error) {
+	return f.actions[action], nil
+}
+func (f *fakeRepo) CountMessagesCachedSince(ctx context.Context, userID string, since time.Time) (int, error) {
+	f.gotSince = since
+	return f.cached, nil
+}
+func (f *fakeRepo) CountMessages(ctx context.Context, userID string) (int, error) {
+	return f.total, nil
+}
+func (f *fakeRepo) RecordInboxSize(ctx context.Context, userID string, day time.Time, size int) error {
+	f.sampled[day] = size
+	return nil
+}
+func (f *fakeRepo) WeeklyInboxSizes(ctx context.Context, userID string, since time.Time) ([]models.InboxSizePoint, error) {
+	return f.weekly, nil
+}
+
+func (f *fakeRepo) SenderStats(ctx context.Context, userID string, from, to int64, limit int) ([]models.SenderStat, error) {
+	return f.senders, nil
+}
+func (f *fakeRepo) DailyVolume(ctx context.Context, userID string, from, to int64, timezone string) ([]models.VolumePoint, error) {
+	f.gotZone = timezone
+	return f.volume, nil
+}
+
+type fakeSettings struct{ timezone string }
+
+func (f fakeSettings) Get(ctx context.Context, userID string) (*models.UserSettings, error) {
+	return &models.UserSettings{UserID: userID, Timezone: f.timezone}, nil
+}
+func (f fakeSettings) Upsert(ctx context.Context, s *models.UserSettings) error {
+	return nil
+}
+
+func TestService_Stats(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	repo := &fakeRepo{actions: map[string]int{}, cached: 50, sampled: map[time.Time]int{}}
+	svc := NewService(repo)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		_ = svc.Record(ctx, "user1", ActionArchived, "email_message")
+	}
+	_ = svc.Record(ctx, "user1", ActionUnsubscribed, "sender")
+
+	stats, err := svc.Stats(ctx, "user1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.MessagesProcessed != 50 || stats.Archived != 10 || stats.Unsubscribes != 1 {
+		t.Errorf("unexpected counts: %+v", stats)
+	}
+	if !repo.gotSince.Equal(now.Add(-7 * 24 * time.Hour)) {
+		t.Errorf("expected a 7 day window, got since %v", repo.gotSince)
+	}
+	// 50*0.1 + 10*0.2 + 1*2.0
+	if stats.EstimatedMinutesSaved != 9 {
+		t.Errorf("expected 9 minutes saved, got %v", stats.EstimatedMinutesSaved)
+	}
+	if stats.InboxSizeTrend == nil {
+		t.Error("expected empty trend to be a non-nil slice")
+	}
+}
+
+func TestService_SamplesInboxSizeOnSync(t *testing.T) {
+	repo := &fakeRepo{total: 42, sampled: map[time.Time]int{}}
+	svc := NewService(repo)
+	svc.now = func() time.Time { return time.Date(2026, 10, 15, 23, 30, 0, 0, time.UTC) }
+	unsubscribe := svc.Subscribe()
+	defer unsubscribe()
+
+	notify.Publish(context.Background(), notify.EventSyncComplete, "user1")
+	if got := repo.sampled[time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)]; got != 42 {
+		t.Errorf("expected today's sample to be 42, got %v", repo.sampled)
+	}
+}
+
+func TestService_SendersAndVolume(t *testing.T) {
+	repo := &fakeRepo{
+		senders: []models.SenderStat{{Address: "news@shop.example", Messages: 8, Unread: 6}, {Address: "ann@example.com", Messages: 2}},
+		volume:  []models.VolumePoint{{Day: "2026-10-13", Messages: 4, Unread: 1}},
+	}
+	svc := NewService(repo)
+	ctx := context.Background()
+
+	senders, err := svc.Senders(ctx, "user1", time.Now().Add(-DefaultRange), time.Now(), DefaultSenderLimit)
+	if err != nil || len(senders) != 2 || senders[0].UnreadRatio != 0.75 || senders[1].UnreadRatio != 0 {
+		t.Fatalf("unexpected senders %+v (%v)", senders, err)
+	}
+
+	// Oct 12 22:00 to Oct 15 08:00 UTC is Oct 13 00:00 to Oct 15 10:00 in Berlin
+	svc.Settings = fakeSettings{timezone: "Europe/Berlin"}
+	from := time.Date(2026, 10, 12, 22, 0, 0, 0, time.UTC)
+	v, err := svc.Volume(ctx, "user1", from, from.Add(58*time.Hour))
+	if err != nil {
+		t.Fatalf("Volume failed: %v", err)
+	}
+	want := []models.VolumePoint{{Day: "2026-10-13", Messages: 4, Unread: 1}, {Day: "2026-10-14"}, {Day: "2026-10-15"}}
+	if v.Timezone != "Europe/Berlin" || repo.gotZone != "Europe/Berlin" || len(v.Days) != len(want) {
+		t.Fatalf("unexpected volume %+v", v)
+	}
+	for i := range want {
+		if v.Days[i] != want[i] {
+			t.Errorf("day %d: expected %+v, got %+v", i, want[i], v.Days[i])
+		}
+	}
+}
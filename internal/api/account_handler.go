@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// AccountHealthChecker reports a linked mailbox's health (see service.AccountHealthService)
+type AccountHealthChecker interface {
+	Check(ctx context.Context, userID, accountID string) (*service.AccountHealth, error)
+}
+
+type AccountHandler struct {
+	Health AccountHealthChecker
+}
+
+func NewAccountHandler(health AccountHealthChecker) *AccountHandler {
+	return &AccountHandler{Health: health}
+}
+
+// GetHealth handles GET /api/accounts/{id}/health
+func (h *AccountHandler) GetHealth(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	health, err := h.Health.Check(r.Context(), userID, chi.URLParam(r, "id"))
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "account not found")
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to check account health")
+		return
+	}
+	RespondJSON(w, http.StatusOK, health)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+type stubAccountHealth struct {
+	health *service.AccountHealth
+	err    error
+}
+
+func (s *stubAccountHealth) Check(ctx context.Context, userID, accountID string) (*service.AccountHealth, error) {
+	return s.health, s.err
+}
+
+func accountHealthRequest(userID, accountID string) *http.Request {
+	r := httptest.NewRequest("GET", "/api/accounts/"+accountID+"/health", nil)
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add("id", accountID)
+	ctx := context.WithValue(r.Context(), chi.RouteCtxKey, chiCtx)
+	if userID != "" {
+		ctx = ctxkeys.WithUserID(ctx, userID)
+	}
+	return r.WithContext(ctx)
+}
+
+func TestAccountHandler_GetHealth(t *testing.T) {
+	t.Run("unauthenticated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		NewAccountHandler(&stubAccountHealth{}).GetHealth(w, accountHealthRequest("", "gmail"))
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("reports status", func(t *testing.T) {
+		h := NewAccountHandler(&stubAccountHealth{health: &service.AccountHealth{
+			AccountID: "gmail", Status: service.AccountDegraded, Reasons: []string{"rate_limited"},
+		}})
+		w := httptest.NewRecorder()
+		h.GetHealth(w, accountHealthRequest("user1", "gmail"))
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp service.AccountHealth
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		require.Equal(t, service.AccountDegraded, resp.Status)
+		require.Equal(t, []string{"rate_limited"}, resp.Reasons)
+	})
+
+	t.Run("unknown account", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		NewAccountHandler(&stubAccountHealth{err: data.ErrNotFound}).GetHealth(w, accountHealthRequest("user1", "other"))
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("check error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		NewAccountHandler(&stubAccountHealth{err: errors.New("db down")}).GetHealth(w, accountHealthRequest("user1", "gmail"))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/desponda/inbox-whisperer/internal/ai"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/service"
+)
+
+// Error codes returned with AI policy errors so clients can explain why a feature is unavailable
+const (
+	ErrCodeAILocalOnly       = "ai_local_only"
+	ErrCodeAIConsentRequired = "ai_consent_required"
+	ErrCodeAIUnavailable     = "ai_unavailable"
+	ErrCodeAIBudgetExhausted = "ai_budget_exhausted"
+	ErrCodeAIProviderError   = "ai_provider_error"
+	ErrCodeAIEncryptedCache  = "ai_encrypted_cache"
+)
+
+type AIHandler struct {
+	Gateway *ai.Gateway
+	Emails  service.EmailService
+}
+
+func NewAIHandler(gateway *ai.Gateway, emails service.EmailService) *AIHandler {
+	return &AIHandler{Gateway: gateway, Emails: emails}
+}
+
+// SummaryResponse is the body of GET /api/emails/messages/{id}/summary
+type SummaryResponse struct {
+	ID      string `json:"id"`
+	Summary string `json:"summary"`
+	// Stale is set when the AI provider is down and the summary was cached for an earlier
+	// version of the message
+	Stale bool `json:"stale,omitempty"`
+}
+
+// SummarizeMessage handles GET /api/emails/messages/{id}/summary
+func (h *AIHandler) SummarizeMessage(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	id, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	tok := ctxkeys.Token(r.Context())
+	if tok == nil {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no token in context")
+		return
+	}
+	// Check consent before fetching content so a refusal never touches the message
+	if err := h.Gateway.CheckConsent(r.Context(), userID); err != nil {
+		writeAIError(w, err)
+		return
+	}
+	msg, err := h.Emails.FetchMessageContent(r.Context(), tok, id)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	summary, err := h.Gateway.Summarize(r.Context(), userID, msg)
+	if errors.Is(err, ai.ErrProviderFailed) {
+		if last, ok := h.Gateway.LastSummary(r.Context(), userID, id); ok {
+			RespondJSON(w, http.StatusOK, SummaryResponse{ID: id, Summary: last, Stale: true})
+			return
+		}
+	}
+	if err != nil {
+		writeAIError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusOK, SummaryResponse{ID: id, Summary: summary})
+}
+
+// GetMyUsage handles GET /api/users/me/ai-usage
+func (h *AIHandler) GetMyUsage(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	usage, err := h.Gateway.Usage(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load AI usage")
+		return
+	}
+	RespondJSON(w, http.StatusOK, usage)
+}
+
+// writeAIError maps ai gateway errors onto HTTP status codes with a machine-readable code
+func writeAIError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ai.ErrLocalOnly):
+		RespondErrorCode(w, http.StatusForbidden, ErrCodeAILocalOnly, "AI features that send message content to external providers are disabled on this server")
+	case errors.Is(err, ai.ErrConsentRequired):
+		RespondErrorCode(w, http.StatusForbidden, ErrCodeAIConsentRequired, "enable AI data sharing in your settings to use this feature")
+	case errors.Is(err, ai.ErrEncryptedCache):
+		RespondErrorCode(w, http.StatusForbidden, ErrCodeAIEncryptedCache, "AI features are off while your message cache is encrypted")
+	case errors.Is(err, ai.ErrBudgetExhausted):
+		RespondErrorCode(w, http.StatusTooManyRequests, ErrCodeAIBudgetExhausted, "AI token budget exhausted; try again after it resets")
+	case errors.Is(err, ai.ErrUnavailable):
+		RespondErrorCode(w, http.StatusServiceUnavailable, ErrCodeAIUnavailable, "no AI provider is configured")
+	case errors.Is(err, ai.ErrProviderFailed):
+		w.Header().Set("Retry-After", strconv.Itoa(int(ai.RetryAfter(err).Seconds())))
+		RespondErrorCode(w, http.StatusServiceUnavailable, ErrCodeAIProviderError, "AI provider is unavailable; try again later")
+	default:
+		RespondErrorCode(w, http.StatusBadGateway, ErrCodeAIProviderError, "AI provider request failed")
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ai"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/mocks"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/testutils"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+type stubLLM struct{}
+
+func (stubLLM) Complete(ctx context.Context, req ai.Request) (*ai.Response, error) {
+	return &ai.Response{Text: "A short summary."}, nil
+}
+
+func summaryRequest() *http.Request {
+	return testutils.NewAuthedRequest("GET", "/api/email/messages/m1/summary", nil, testutils.WithURLParam("id", "m1"))
+}
+
+func TestSummarizeMessage(t *testing.T) {
+	fetched := 0
+	emails := &mocks.MockEmailService{
+		FetchMessageContentFunc: func(ctx context.Context, token *oauth2.Token, id string) (*models.EmailMessage, error) {
+			fetched++
+			return &models.EmailMessage{EmailMessageID: id, Subject: "Hello"}, nil
+		},
+	}
+	tests := []struct {
+		name      string
+		llm       ai.LLM
+		sharing   bool
+		localOnly bool
+		status    int
+		code      string
+	}{
+		{"local only", stubLLM{}, true, true, http.StatusForbidden, ErrCodeAILocalOnly},
+		{"no consent", stubLLM{}, false, false, http.StatusForbidden, ErrCodeAIConsentRequired},
+		{"no provider", nil, true, false, http.StatusServiceUnavailable, ErrCodeAIUnavailable},
+	}
+	for _, tt := range tests {
+		repo := &stubSettingsRepo{settings: map[string]models.UserSettings{"user1": {AIDataSharing: tt.sharing}}}
+		h := NewAIHandler(ai.NewGateway(tt.llm, repo, tt.localOnly), emails)
+		w := httptest.NewRecorder()
+		h.SummarizeMessage(w, summaryRequest())
+		require.Equal(t, tt.status, w.Code, tt.name)
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		require.Equal(t, tt.code, body["code"], tt.name)
+	}
+	require.Equal(t, 1, fetched, "message content must only be fetched when AI is allowed")
+
+	repo := &stubSettingsRepo{settings: map[string]models.UserSettings{"user1": {AIDataSharing: true}}}
+	h := NewAIHandler(ai.NewGateway(stubLLM{}, repo, false), emails)
+	w := httptest.NewRecorder()
+	h.SummarizeMessage(w, summaryRequest())
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp SummaryResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Equal(t, "A short summary.", resp.Summary)
+}
+
+type downLLM struct{}
+
+func (downLLM) Complete(ctx context.Context, req ai.Request) (*ai.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+type stubResultCache struct {
+	summary *models.AIResult
+}
+
+func (s *stubResultCache) GetResult(ctx context.Context, userID, messageID, kind string) (*models.AIResult, error) {
+	return s.summary, nil
+}
+func (s *stubResultCache) PutResult(ctx context.Context, res *models.AIResult) error { return nil }
+
+func TestSummarizeMessage_ProviderDown(t *testing.T) {
+	emails := &mocks.MockEmailService{
+		FetchMessageContentFunc: func(ctx context.Context, token *oauth2.Token, id string) (*models.EmailMessage, error) {
+			return &models.EmailMessage{EmailMessageID: id, Subject: "Hello, edited"}, nil
+		},
+	}
+	repo := &stubSettingsRepo{settings: map[string]models.UserSettings{"user1": {AIDataSharing: true}}}
+	g := ai.NewGateway(downLLM{}, repo, false)
+	cache := &stubResultCache{}
+	g.Cache = cache
+	h := NewAIHandler(g, emails)
+
+	// Nothing cached: 503 with a retry hint
+	w := httptest.NewRecorder()
+	h.SummarizeMessage(w, summaryRequest())
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "30", w.Header().Get("Retry-After"))
+	require.Contains(t, w.Body.String(), ErrCodeAIProviderError)
+
+	// A summary of an earlier version of the message is served, marked stale
+	cache.summary = &models.AIResult{Kind: models.AIResultSummary, ContentHash: "old", Result: "An older summary."}
+	w = httptest.NewRecorder()
+	h.SummarizeMessage(w, summaryRequest())
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp SummaryResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Equal(t, SummaryResponse{ID: "m1", Summary: "An older summary.", Stale: true}, resp)
+}
+
+func TestGetMyUsage(t *testing.T) {
+	g := ai.NewGateway(stubLLM{}, &stubSettingsRepo{settings: map[string]models.UserSettings{}}, false)
+	g.SetBudget(ai.Budget{UserDaily: 1000})
+	h := NewAIHandler(g, &mocks.MockEmailService{})
+
+	r := httptest.NewRequest("GET", "/api/users/me/ai-usage", nil)
+	w := httptest.NewRecorder()
+	h.GetMyUsage(w, r.WithContext(ctxkeys.WithUserID(r.Context(), "user1")))
+	require.Equal(t, http.StatusOK, w.Code)
+	var usage models.AIUsage
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&usage))
+	require.Equal(t, 1000, usage.Daily.Limit)
+	require.False(t, usage.Exhausted)
+
+	w = httptest.NewRecorder()
+	h.GetMyUsage(w, r)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/apikeys"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+// maxAPIKeyNameLen bounds the user-chosen name of a key
+const maxAPIKeyNameLen = 100
+
+// CreateAPIKeyRequest is the body of POST /api/users/me/api-keys
+type CreateAPIKeyRequest struct {
+	Name string `json:"name"`
+}
+
+// CreatedAPIKey is a new key together with its secret, which is never shown again
+type CreatedAPIKey struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+// DeviceTokenRequest is the body of POST /api/auth/device/token
+type DeviceTokenRequest struct {
+	DeviceCode string `json:"device_code"`
+}
+
+// DeviceDecisionRequest is the body of POST /api/auth/device/approve and /deny
+type DeviceDecisionRequest struct {
+	UserCode string `json:"user_code"`
+}
+
+type APIKeyHandler struct {
+	Keys *apikeys.Service
+}
+
+func NewAPIKeyHandler(svc *apikeys.Service) *APIKeyHandler {
+	return &APIKeyHandler{Keys: svc}
+}
+
+// ListAPIKeys handles GET /api/users/me/api-keys
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	list, err := h.Keys.List(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load API keys")
+		return
+	}
+	if list == nil {
+		list = []*models.APIKey{}
+	}
+	RespondJSON(w, http.StatusOK, list)
+}
+
+// CreateAPIKey handles POST /api/users/me/api-keys
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	var req CreateAPIKeyRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxAPIKeyNameLen {
+		RespondError(w, http.StatusBadRequest, "name must be 1 to 100 characters")
+		return
+	}
+	key, raw, err := h.Keys.Create(r.Context(), userID, req.Name)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to create API key")
+		return
+	}
+	RespondJSON(w, http.StatusCreated, CreatedAPIKey{APIKey: key, Key: raw})
+}
+
+// RevokeAPIKey handles DELETE /api/users/me/api-keys/{id}
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	idParam, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid API key id")
+		return
+	}
+	if err := h.Keys.Revoke(r.Context(), userID, id); err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			RespondError(w, http.StatusNotFound, "API key not found")
+			return
+		}
+		RespondError(w, http.StatusInternalServerError, "failed to revoke API key")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// StartDeviceAuthorization handles POST /api/auth/device/code. It needs no session: the CLI
+// calls it and shows the user code to the user.
+func (h *APIKeyHandler) StartDeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	auth, err := h.Keys.StartDevice(r.Context())
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to start device authorization")
+		return
+	}
+	RespondJSON(w, http.StatusOK, auth)
+}
+
+// PollDeviceToken handles POST /api/auth/device/token. Until the user decides it fails with
+// the RFC 8628 error code in "code"; once approved it returns a new key, exactly once.
+func (h *APIKeyHandler) PollDeviceToken(w http.ResponseWriter, r *http.Request) {
+	var req DeviceTokenRequest
+	if err := DecodeJSON(r, &req); err != nil || req.DeviceCode == "" {
+		RespondBodyError(w, err, "device_code is required")
+		return
+	}
+	key, raw, err := h.Keys.PollDevice(r.Context(), req.DeviceCode)
+	switch {
+	case errors.Is(err, apikeys.ErrAuthorizationPending):
+		RespondErrorCode(w, http.StatusBadRequest, err.Error(), "the user has not approved the device yet")
+	case errors.Is(err, apikeys.ErrSlowDown):
+		RespondErrorCode(w, http.StatusBadRequest, err.Error(), "polling too often")
+	case errors.Is(err, apikeys.ErrAccessDenied):
+		RespondErrorCode(w, http.StatusBadRequest, err.Error(), "the user denied the device")
+	case errors.Is(err, apikeys.ErrExpiredToken):
+		RespondErrorCode(w, http.StatusBadRequest, err.Error(), "the device code expired or was already used")
+	case err != nil:
+		RespondError(w, http.StatusInternalServerError, "failed to check device authorization")
+	default:
+		RespondJSON(w, http.StatusOK, CreatedAPIKey{APIKey: key, Key: raw})
+	}
+}
+
+// ApproveDevice handles POST /api/auth/device/approve
+func (h *APIKeyHandler) ApproveDevice(w http.ResponseWriter, r *http.Request) {
+	h.decideDevice(w, r, true)
+}
+
+// DenyDevice handles POST /api/auth/device/deny
+func (h *APIKeyHandler) DenyDevice(w http.ResponseWriter, r *http.Request) {
+	h.decideDevice(w, r, false)
+}
+
+func (h *APIKeyHandler) decideDevice(w http.ResponseWriter, r *http.Request, approve bool) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	var req DeviceDecisionRequest
+	if err := DecodeJSON(r, &req); err != nil || req.UserCode == "" {
+		RespondBodyError(w, err, "user_code is required")
+		return
+	}
+	var err error
+	if approve {
+		err = h.Keys.ApproveDevice(r.Context(), req.UserCode, userID)
+	} else {
+		err = h.Keys.DenyDevice(r.Context(), req.UserCode)
+	}
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			RespondError(w, http.StatusNotFound, "unknown or expired user code")
+			return
+		}
+		RespondError(w, http.StatusInternalServerError, "failed to record decision")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
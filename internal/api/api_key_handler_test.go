@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/apikeys"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+type stubAPIKeyRepo struct {
+	keys map[string]*models.APIKey
+}
+
+func (s *stubAPIKeyRepo) Create(ctx context.Context, key *models.APIKey, keyHash string) error {
+	key.ID = int64(len(s.keys) + 1)
+	s.keys[keyHash] = key
+	return nil
+}
+func (s *stubAPIKeyRepo) ListByUser(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	var out []*models.APIKey
+	for _, k := range s.keys {
+		if k.UserID == userID {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
+func (s *stubAPIKeyRepo) Revoke(ctx context.Context, userID string, id int64) error {
+	for h, k := range s.keys {
+		if k.ID == id && k.UserID == userID {
+			delete(s.keys, h)
+			return nil
+		}
+	}
+	return data.ErrNotFound
+}
+func (s *stubAPIKeyRepo) Authenticate(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	if k, ok := s.keys[keyHash]; ok {
+		return k, nil
+	}
+	return nil, data.ErrNotFound
+}
+
+// stubDeviceRepo holds one device code at a time
+type stubDeviceRepo struct {
+	hash string
+	code *models.DeviceCode
+}
+
+func (s *stubDeviceRepo) Create(ctx context.Context, deviceCodeHash, userCode string, expiresAt time.Time) error {
+	s.hash, s.code = deviceCodeHash, &models.DeviceCode{UserCode: userCode, Status: models.DeviceCodePending, ExpiresAt: expiresAt}
+	return nil
+}
+func (s *stubDeviceRepo) Decide(ctx context.Context, userCode, userID string, approve bool) error {
+	if s.code == nil || s.code.UserCode != userCode || s.code.Status != models.DeviceCodePending {
+		return data.ErrNotFound
+	}
+	s.code.Status, s.code.UserID = models.DeviceCodeDenied, userID
+	if approve {
+		s.code.Status = models.DeviceCodeApproved
+	}
+	return nil
+}
+func (s *stubDeviceRepo) Poll(ctx context.Context, deviceCodeHash string) (*models.DeviceCode, error) {
+	if s.code == nil || s.hash != deviceCodeHash {
+		return nil, data.ErrNotFound
+	}
+	// No previous poll, so the handler test never hits slow_down
+	return &models.DeviceCode{UserCode: s.code.UserCode, Status: s.code.Status, UserID: s.code.UserID, ExpiresAt: s.code.ExpiresAt}, nil
+}
+func (s *stubDeviceRepo) Consume(ctx context.Context, deviceCodeHash string) (string, error) {
+	if s.code == nil || s.hash != deviceCodeHash || s.code.Status != models.DeviceCodeApproved {
+		return "", data.ErrNotFound
+	}
+	userID := s.code.UserID
+	s.code = nil
+	return userID, nil
+}
+
+func newTestAPIKeyService() *apikeys.Service {
+	return apikeys.NewService(&stubAPIKeyRepo{keys: map[string]*models.APIKey{}}, &stubDeviceRepo{}, "http://localhost:3000")
+}
+
+func TestAPIKeyHandler(t *testing.T) {
+	h := NewAPIKeyHandler(newTestAPIKeyService())
+
+	w := httptest.NewRecorder()
+	h.CreateAPIKey(w, testutils.NewAuthedRequest("POST", "/api/users/me/api-keys", strings.NewReader(`{"name":" "}`)))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.CreateAPIKey(w, testutils.NewAuthedRequest("POST", "/api/users/me/api-keys", strings.NewReader(`{"name":"laptop"}`)))
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	require.Equal(t, "laptop", created["name"])
+	require.True(t, strings.HasPrefix(created["key"].(string), apikeys.KeyPrefix))
+	require.NotContains(t, created, "user_id")
+
+	w = httptest.NewRecorder()
+	h.ListAPIKeys(w, testutils.NewAuthedRequest("GET", "/api/users/me/api-keys", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotContains(t, w.Body.String(), created["key"].(string), "the secret is only shown on creation")
+
+	w = httptest.NewRecorder()
+	h.RevokeAPIKey(w, testutils.NewAuthedRequest("DELETE", "/api/users/me/api-keys/1", nil, testutils.WithUser("user2"), testutils.WithURLParam("id", "1")))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	h.RevokeAPIKey(w, testutils.NewAuthedRequest("DELETE", "/api/users/me/api-keys/1", nil, testutils.WithURLParam("id", "1")))
+	require.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestAPIKeyHandler_DeviceFlow(t *testing.T) {
+	h := NewAPIKeyHandler(newTestAPIKeyService())
+	poll := func(deviceCode string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.PollDeviceToken(w, httptest.NewRequest("POST", "/api/auth/device/token", strings.NewReader(`{"device_code":"`+deviceCode+`"}`)))
+		return w
+	}
+
+	w := httptest.NewRecorder()
+	h.StartDeviceAuthorization(w, httptest.NewRequest("POST", "/api/auth/device/code", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var auth apikeys.DeviceAuthorization
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &auth))
+	require.Equal(t, "http://localhost:3000/device", auth.VerificationURI)
+
+	w = poll(auth.DeviceCode)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), `"code":"authorization_pending"`)
+
+	w = httptest.NewRecorder()
+	h.ApproveDevice(w, testutils.NewAuthedRequest("POST", "/api/auth/device/approve", strings.NewReader(`{"user_code":"XXXX-XXXX"}`)))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ApproveDevice(w, testutils.NewAuthedRequest("POST", "/api/auth/device/approve", strings.NewReader(`{"user_code":"`+auth.UserCode+`"}`)))
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w = poll(auth.DeviceCode)
+	require.Equal(t, http.StatusOK, w.Code)
+	var issued CreatedAPIKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+	require.Equal(t, apikeys.DeviceKeyName, issued.Name)
+
+	w = poll(auth.DeviceCode)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), `"code":"expired_token"`)
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	svc := newTestAPIKeyService()
+	_, raw, err := svc.Create(context.Background(), "user-1", "laptop")
+	require.NoError(t, err)
+	var gotUser string
+	h := APIKeyMiddleware(svc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser = ctxkeys.UserID(r.Context())
+	}))
+	serve := func(authorization string) int {
+		gotUser = ""
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, serve("Bearer "+raw))
+	require.Equal(t, "user-1", gotUser)
+	require.Equal(t, http.StatusUnauthorized, serve("Bearer "+apikeys.KeyPrefix+"revoked"))
+	// Requests without a key fall through to session authentication
+	require.Equal(t, http.StatusOK, serve(""))
+	require.Equal(t, "", gotUser)
+	require.Equal(t, http.StatusOK, serve("Bearer some-other-token"))
+	require.Equal(t, "", gotUser)
+}
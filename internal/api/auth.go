@@ -5,13 +5,21 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/desponda/inbox-whisperer/internal/config"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
 	"github.com/desponda/inbox-whisperer/internal/data"
 	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/notify"
+	gmail "github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/outlook"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
 	"github.com/desponda/inbox-whisperer/internal/session"
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog/log"
@@ -22,20 +30,43 @@ import (
 // AuthHandler holds the OAuth2 config and provides HTTP handlers for auth endpoints
 // (In production, you would inject user/session/token storage here as well)
 type AuthHandler struct {
-	OAuthConfig  *oauth2.Config
-	UserTokens   data.UserTokenRepository
-	FrontendURL  string
+	OAuthConfig *oauth2.Config
+	UserTokens  data.UserTokenRepository
+	FrontendURL string
+	// States makes OAuth states single-use and valid on any instance, bound to the browser by
+	// the oauth_state cookie. When nil, the state is kept in the session instead.
+	States data.OAuthStateRepository
+	// HTTPClient carries the token exchange and user info calls; optional (http.DefaultClient
+	// when nil)
+	HTTPClient *http.Client
+	// Outlook links Outlook mailboxes to signed-in users; optional (linking is off when nil)
+	Outlook *outlook.OutlookProvider
+	// Credentials, if set, replaces the client ID and secret of OAuthConfig with ones that can
+	// be rotated at runtime
+	Credentials OAuthCredentials
 }
 
+// OAuthCredentials serves OAuth app credentials that can be rotated at runtime (see
+// oauthclient.Store)
+type OAuthCredentials interface {
+	Config() *oauth2.Config
+	Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
+}
+
+// OAuthStateTTL is how long a login may take between redirecting to the provider and the callback
+const OAuthStateTTL = 10 * time.Minute
+
+// oauthStateCookie binds an issued state to the browser that started the login
+const oauthStateCookie = "oauth_state"
+
 // NewAuthHandler creates a new AuthHandler with the given app config
 func NewAuthHandler(cfg *config.AppConfig, userTokens data.UserTokenRepository) *AuthHandler {
-	defaultGoogleScopes := []string{"https://www.googleapis.com/auth/gmail.readonly", "openid", "profile", "email"}
 	return &AuthHandler{
 		OAuthConfig: &oauth2.Config{
 			ClientID:     cfg.Google.ClientID,
 			ClientSecret: cfg.Google.ClientSecret,
 			RedirectURL:  cfg.Google.RedirectURL,
-			Scopes:       defaultGoogleScopes,
+			Scopes:       gmail.LoginScopes,
 			Endpoint:     google.Endpoint,
 		},
 		UserTokens:  userTokens,
@@ -43,24 +74,97 @@ func NewAuthHandler(cfg *config.AppConfig, userTokens data.UserTokenRepository)
 	}
 }
 
-// HandleLogin starts the OAuth2 flow
+// HandleLogin starts the OAuth2 flow. With a feature query parameter it also asks for that
+// feature's scopes, keeping the ones already granted; see ConsentURL. A returnTo parameter
+// on the frontend's origin is where the callback sends the user; see resolveReturnTo.
+// read_only=true signs in with a read-only session, see ReadOnlyMiddleware.
 func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
-	// Generate a random state token for CSRF protection
-	state := generateRandomState(32)
+	readOnly := false
+	if raw := r.URL.Query().Get("read_only"); raw != "" {
+		var err error
+		if readOnly, err = strconv.ParseBool(raw); err != nil {
+			http.Error(w, "invalid read_only", http.StatusBadRequest)
+			return
+		}
+	}
+	returnTo := ""
+	if raw := r.URL.Query().Get("returnTo"); raw != "" {
+		var ok bool
+		if returnTo, ok = resolveReturnTo(h.FrontendURL, raw); !ok {
+			http.Error(w, "invalid returnTo", http.StatusBadRequest)
+			return
+		}
+	}
 
-	// Log state generation and session/cookie info (do not log secrets)
-	// Cookie check is no longer needed since we're not using session_id
-	// log.Debug().Str("handler", "HandleLogin").Str("generated_state", state).Msg("Generated OAuth state and preparing to store in session")
+	oauthCfg := h.oauthConfig()
+	opts := []oauth2.AuthCodeOption{oauth2.AccessTypeOffline}
+	if feature := provider.Feature(r.URL.Query().Get("feature")); feature != "" {
+		if readOnly {
+			http.Error(w, "read-only sessions cannot grant features", http.StatusBadRequest)
+			return
+		}
+		extra, ok := gmail.FeatureScopes[feature]
+		if !ok {
+			http.Error(w, "unknown feature", http.StatusBadRequest)
+			return
+		}
+		upgraded := *oauthCfg
+		upgraded.Scopes = append(append([]string{}, oauthCfg.Scopes...), extra...)
+		oauthCfg = &upgraded
+		opts = append(opts, oauth2.SetAuthURLParam("include_granted_scopes", "true"), oauth2.SetAuthURLParam("prompt", "consent"))
+		if hint := r.URL.Query().Get(AccountIDParam); hint != "" {
+			opts = append(opts, oauth2.SetAuthURLParam("login_hint", hint))
+		}
+	}
 
-	session.SetSessionValue(w, r, "oauth_state", state)
-	// log.Debug().Str("handler", "HandleLogin").Str("stored_state", state).Msg("Stored OAuth state in session")
+	state, err := h.issueState(w, r)
+	if err != nil {
+		log.Error().Str("handler", "HandleLogin").Err(err).Msg("Failed to store OAuth state")
+		http.Error(w, "failed to start login", http.StatusInternalServerError)
+		return
+	}
+	// Always written so a stale target from an earlier login is not reused
+	session.SetReturnTo(w, r, returnTo)
+	// Kept apart from the session's own flag, so starting a full login from a read-only
+	// session does not lift the restriction before the login completes
+	session.SetReadOnlyLogin(w, r, readOnly)
 
-	url := h.OAuthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	url := oauthCfg.AuthCodeURL(state, opts...)
 	// log.Debug().Str("handler", "HandleLogin").Str("redirect_url", url).Msg("Redirecting to OAuth provider")
 
 	http.Redirect(w, r, url, http.StatusFound)
 }
 
+// oauthConfig returns the Google OAuth config with the current client credentials
+func (h *AuthHandler) oauthConfig() *oauth2.Config {
+	if h.Credentials != nil {
+		return h.Credentials.Config()
+	}
+	return h.OAuthConfig
+}
+
+// issueState generates a random state token for CSRF protection and binds it to the browser,
+// for validateState to check on the callback
+func (h *AuthHandler) issueState(w http.ResponseWriter, r *http.Request) (string, error) {
+	state := generateRandomState(32)
+	if h.States == nil {
+		session.SetState(w, r, state)
+		return state, nil
+	}
+	if err := h.States.CreateState(r.Context(), state, time.Now().Add(OAuthStateTTL)); err != nil {
+		return "", err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   int(OAuthStateTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return state, nil
+}
+
 // generateRandomState generates a secure random string for OAuth2 state
 func generateRandomState(length int) string {
 	b := make([]byte, length)
@@ -75,6 +179,9 @@ func (h *AuthHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
 	// log.Debug().Str("handler", "HandleCallback").Msg("Received OAuth callback request")
 
 	ctx := r.Context()
+	if h.HTTPClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, h.HTTPClient)
+	}
 	code := r.URL.Query().Get("code")
 	if code == "" {
 		// log.Debug().Str("handler", "HandleCallback").Msg("Missing code in callback URL")
@@ -83,10 +190,14 @@ func (h *AuthHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	state := r.URL.Query().Get("state")
-	expectedState := session.GetSessionValue(r, "oauth_state")
-	// log.Debug().Str("handler", "HandleCallback").Str("received_state", state).Str("expected_state", expectedState).Msg("Comparing OAuth state values from callback and session")
-	if state == "" || expectedState == "" || state != expectedState {
-		log.Warn().Str("handler", "HandleCallback").Str("received_state", state).Str("expected_state", expectedState).Msg("Invalid or missing state parameter in callback")
+	valid, err := h.validateState(w, r, state)
+	if err != nil {
+		log.Error().Str("handler", "HandleCallback").Err(err).Msg("Failed to validate OAuth state")
+		http.Error(w, "failed to validate state", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		log.Warn().Str("handler", "HandleCallback").Str("received_state", state).Msg("Invalid or missing state parameter in callback")
 		session.ClearSession(w, r)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
@@ -112,18 +223,79 @@ func (h *AuthHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// log.Debug().Str("handler", "HandleCallback").Str("user_id", userID).Str("email", email).Msg("User authenticated, persisting user and token")
-	ensureUserExists(ctx, h.UserTokens, userID, email, tok)
+	email = ensureUserExists(ctx, h.UserTokens, userID, email, tok)
 
-	err = h.UserTokens.SaveUserToken(ctx, userID, tok)
+	err = h.UserTokens.SaveUserToken(ctx, userID, data.ProviderGmail, email, tok)
 	if err != nil {
 		log.Error().Str("handler", "HandleCallback").Str("user_id", userID).Err(err).Msg("Failed to persist user token")
 		http.Error(w, "failed to persist user token", http.StatusInternalServerError)
 		return
 	}
+	notify.Publish(ctx, notify.EventAccountLinked, userID)
 
+	// Read before the session is replaced; checked again in case the allowed origin changed
+	target := h.FrontendURL
+	if returnTo, ok := resolveReturnTo(h.FrontendURL, session.GetReturnTo(r)); ok {
+		target = returnTo
+	}
+	readOnly := session.GetReadOnlyLogin(r)
 	// log.Debug().Str("handler", "HandleCallback").Str("user_id", userID).Msg("Setting session token and redirecting to frontend")
 	setSessionToken(w, r, userID, tok.AccessToken)
-	http.Redirect(w, r, h.FrontendURL, http.StatusFound)
+	if readOnly {
+		session.SetReadOnly(w, r)
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// resolveReturnTo turns a post-login target into an absolute URL on the frontend's origin.
+// Paths are resolved against frontendURL; absolute URLs must share its scheme and host.
+// Anything else, including scheme-relative //host URLs and backslashes that browsers treat
+// as slashes, is rejected to prevent open redirects.
+func resolveReturnTo(frontendURL, returnTo string) (string, bool) {
+	if returnTo == "" || strings.ContainsAny(returnTo, "\\\x00\r\n\t") {
+		return "", false
+	}
+	base, err := url.Parse(frontendURL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return "", false
+	}
+	target, err := url.Parse(returnTo)
+	if err != nil || target.User != nil || target.Opaque != "" {
+		return "", false
+	}
+	if !target.IsAbs() {
+		if target.Host != "" || !strings.HasPrefix(target.Path, "/") {
+			return "", false
+		}
+		target = base.ResolveReference(target)
+	}
+	if !strings.EqualFold(target.Scheme, base.Scheme) || !strings.EqualFold(target.Host, base.Host) {
+		return "", false
+	}
+	return target.String(), true
+}
+
+// validateState checks the callback's state against the one issued to this browser. With
+// States it also consumes the state, so a replayed callback is rejected on every instance.
+func (h *AuthHandler) validateState(w http.ResponseWriter, r *http.Request, state string) (bool, error) {
+	if state == "" {
+		return false, nil
+	}
+	if h.States == nil {
+		return state == session.GetState(r), nil
+	}
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil || cookie.Value != state {
+		return false, nil
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	if err := h.States.ConsumeState(r.Context(), state); err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
 }
 
 func getUserIDAndEmail(ctx context.Context, tok *oauth2.Token) (string, string, error) {
@@ -141,12 +313,14 @@ func getUserIDAndEmail(ctx context.Context, tok *oauth2.Token) (string, string,
 	return userID, email, nil
 }
 
-func ensureUserExists(ctx context.Context, userTokens data.UserTokenRepository, userID, email string, tok *oauth2.Token) {
+// ensureUserExists creates the user row and returns the account's email, looked up from the
+// provider when the callback only yielded the user ID
+func ensureUserExists(ctx context.Context, userTokens data.UserTokenRepository, userID, email string, tok *oauth2.Token) string {
 	db, ok := userTokens.(interface {
 		Create(context.Context, *models.User) error
 	})
 	if !ok {
-		return
+		return email
 	}
 	if email == userID && tok != nil {
 		client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(tok))
@@ -171,6 +345,7 @@ func ensureUserExists(ctx context.Context, userTokens data.UserTokenRepository,
 	} else {
 		// log.Info().Str("user_id", userID).Str("email", email).Msg("User created successfully in ensureUserExists")
 	}
+	return email
 }
 
 func setSessionToken(w http.ResponseWriter, r *http.Request, userID, token string) {
@@ -181,6 +356,9 @@ func setSessionToken(w http.ResponseWriter, r *http.Request, userID, token strin
 
 // exchangeCodeForToken exchanges an OAuth2 code for a token
 var exchangeCodeForToken = func(h *AuthHandler, ctx context.Context, code string) (*oauth2.Token, error) {
+	if h.Credentials != nil {
+		return h.Credentials.Exchange(ctx, code)
+	}
 	tok, err := h.OAuthConfig.Exchange(ctx, code)
 	if err != nil {
 		return nil, err
@@ -209,9 +387,97 @@ var fetchGoogleUserID = func(ctx context.Context, tok *oauth2.Token, userinfoURL
 	return resp.Email, nil
 }
 
-// RegisterAuthRoutes adds the auth endpoints to the router
-func RegisterAuthRoutes(r chi.Router, cfg *config.AppConfig, userTokens data.UserTokenRepository) {
+// HandleOutlookLogin starts linking an Outlook mailbox to the signed-in user
+func (h *AuthHandler) HandleOutlookLogin(w http.ResponseWriter, r *http.Request) {
+	if h.Outlook == nil {
+		http.Error(w, "outlook linking is not configured", http.StatusNotFound)
+		return
+	}
+	state, err := h.issueState(w, r)
+	if err != nil {
+		log.Error().Str("handler", "HandleOutlookLogin").Err(err).Msg("Failed to store OAuth state")
+		http.Error(w, "failed to start linking", http.StatusInternalServerError)
+		return
+	}
+	url := h.Outlook.OAuthConfig.AuthCodeURL(state, oauth2.SetAuthURLParam("prompt", "select_account"))
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// HandleOutlookCallback handles the OAuth2 redirect from Microsoft: it stores the mailbox's token
+// for the signed-in user under the mailbox address and returns to the frontend
+func (h *AuthHandler) HandleOutlookCallback(w http.ResponseWriter, r *http.Request) {
+	if h.Outlook == nil {
+		http.Error(w, "outlook linking is not configured", http.StatusNotFound)
+		return
+	}
+	ctx := r.Context()
+	if h.HTTPClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, h.HTTPClient)
+	}
+	userID := ctxkeys.UserID(ctx)
+	if userID == "" {
+		http.Error(w, "not authenticated: no userID in context", http.StatusUnauthorized)
+		return
+	}
+	if msErr := r.URL.Query().Get("error"); msErr != "" {
+		// Typically access_denied when the user declines consent
+		http.Error(w, "outlook linking failed: "+msErr, http.StatusBadRequest)
+		return
+	}
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Error(w, "missing code", http.StatusBadRequest)
+		return
+	}
+	valid, err := h.validateState(w, r, r.URL.Query().Get("state"))
+	if err != nil {
+		log.Error().Str("handler", "HandleOutlookCallback").Err(err).Msg("Failed to validate OAuth state")
+		http.Error(w, "failed to validate state", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		http.Error(w, "invalid or expired state, please try linking again", http.StatusBadRequest)
+		return
+	}
+	tok, err := h.Outlook.OAuthConfig.Exchange(ctx, code)
+	if err != nil {
+		log.Error().Str("handler", "HandleOutlookCallback").Err(err).Msg("Token exchange failed")
+		http.Error(w, "token exchange failed", http.StatusBadGateway)
+		return
+	}
+	account, err := h.Outlook.Account(ctx, tok)
+	if err != nil {
+		log.Error().Str("handler", "HandleOutlookCallback").Err(err).Msg("Failed to read Outlook account")
+		http.Error(w, "failed to read Outlook account", http.StatusBadGateway)
+		return
+	}
+	if err := h.UserTokens.SaveUserToken(ctx, userID, data.ProviderOutlook, account, tok); err != nil {
+		log.Error().Str("handler", "HandleOutlookCallback").Str("user_id", userID).Err(err).Msg("Failed to persist Outlook token")
+		http.Error(w, "failed to persist token", http.StatusInternalServerError)
+		return
+	}
+	notify.Publish(ctx, notify.EventAccountLinked, userID)
+	http.Redirect(w, r, h.FrontendURL, http.StatusFound)
+}
+
+// RegisterOutlookRoutes adds the endpoints through which signed-in users link an Outlook mailbox;
+// states may be nil, see AuthHandler.States
+func RegisterOutlookRoutes(r chi.Router, cfg *config.AppConfig, userTokens data.UserTokenRepository, states data.OAuthStateRepository, p *outlook.OutlookProvider) {
+	h := NewAuthHandler(cfg, userTokens)
+	h.States = states
+	h.HTTPClient = p.HTTPClient
+	h.Outlook = p
+	r.With(AuthMiddleware).Get("/api/auth/outlook/login", h.HandleOutlookLogin)
+	r.With(AuthMiddleware).Get("/api/auth/outlook/callback", h.HandleOutlookCallback)
+}
+
+// RegisterAuthRoutes adds the auth endpoints to the router and returns their handler; states
+// may be nil, see AuthHandler.States
+func RegisterAuthRoutes(r chi.Router, cfg *config.AppConfig, userTokens data.UserTokenRepository, states data.OAuthStateRepository, client *http.Client) *AuthHandler {
 	h := NewAuthHandler(cfg, userTokens)
+	h.States = states
+	h.HTTPClient = client
 	r.Get("/api/auth/login", h.HandleLogin)
 	r.Get("/api/auth/callback", h.HandleCallback)
+	return h
 }
@@ -5,11 +5,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"net/http/httptest"
 	"testing"
 
 	"github.com/desponda/inbox-whisperer/internal/config"
+	"github.com/desponda/inbox-whisperer/internal/mocks"
 	"github.com/desponda/inbox-whisperer/internal/session"
+	"github.com/desponda/inbox-whisperer/internal/testutils"
 	"github.com/google/uuid"
 	"golang.org/x/oauth2"
 )
@@ -25,15 +26,8 @@ func TestHandleCallback_CSRFProtection(t *testing.T) {
 	}
 	defer func() { exchangeCodeForToken = originalExchange }()
 
-	// Setup test variables
-	var (
-		mux    = http.NewServeMux()
-		ts     = httptest.NewServer(session.Middleware(mux))
-		client *http.Client
-		jar    http.CookieJar
-		err    error
-	)
-	defer ts.Close()
+	mux := http.NewServeMux()
+	ts, client := testutils.NewSessionServer(t, mux)
 
 	// Create the auth handler with test config
 	appConfig := &config.AppConfig{
@@ -46,14 +40,14 @@ func TestHandleCallback_CSRFProtection(t *testing.T) {
 			FrontendURL: "http://localhost:5173",
 		},
 	}
-	h := NewAuthHandler(appConfig, &stubUserTokens{})
-	
+	h := NewAuthHandler(appConfig, &mocks.MockUserTokenRepository{})
+
 	// Register auth routes
 	mux.HandleFunc("/auth/callback", h.HandleCallback)
 	mux.HandleFunc("/setstate", func(w http.ResponseWriter, r *http.Request) {
 		// Create a session ID
 		sessionID := uuid.New().String()
-		
+
 		// Set the cookie first
 		http.SetCookie(w, &http.Cookie{
 			Name:     "session_id",
@@ -71,35 +65,21 @@ func TestHandleCallback_CSRFProtection(t *testing.T) {
 
 		// Create the session
 		session.SetSession(w, r, "testuser", "testtoken")
-		
+
 		// Set the state value in the session
-		session.SetSessionValue(w, r, "oauth_state", "goodstate")
-		
+		session.SetState(w, r, "goodstate")
+
 		// Verify the state was set
-		state := session.GetSessionValue(r, "oauth_state")
+		state := session.GetState(r)
 		fmt.Printf("[DEBUG] Set state value in session %s: %q\n", sessionID, state)
 		if state != "goodstate" {
 			t.Fatalf("state not set correctly, got %q", state)
 		}
 
-		_, err = w.Write([]byte("ok"))
-		if err != nil {
+		if _, err := w.Write([]byte("ok")); err != nil {
 			t.Fatalf("failed to write response: %v", err)
 		}
 	})
-	
-	// Create a cookie jar for the test client
-	jar, err = session.NewTestCookieJar()
-	if err != nil {
-		t.Fatalf("failed to create cookie jar: %v", err)
-	}
-	// Don't follow redirects in test
-	client = &http.Client{
-		Jar: jar,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
 
 	// Try with no state
 	resp, err := client.Get(ts.URL + "/auth/callback?code=good")
@@ -151,17 +131,6 @@ func TestHandleCallback_CSRFProtection(t *testing.T) {
 	}
 
 	// Verify state is still in session
-	state := session.GetSessionValue(resp3.Request, "oauth_state")
+	state := session.GetState(resp3.Request)
 	fmt.Printf("[DEBUG] State in session after callback: %q\n", state)
 }
-
-// stubUserTokens implements only SaveUserToken for test
-// (other methods are no-ops)
-type stubUserTokens struct{}
-
-func (s *stubUserTokens) SaveUserToken(ctx context.Context, userID string, tok *oauth2.Token) error {
-	return nil
-}
-func (s *stubUserTokens) GetUserToken(ctx context.Context, userID string) (*oauth2.Token, error) {
-	return &oauth2.Token{AccessToken: "tok"}, nil
-}
@@ -97,7 +97,7 @@ func TestAuth_FirstTimeLogin_CreatesUserAndToken(t *testing.T) {
 	if user.Email != fakeGoogle.Email {
 		t.Errorf("user email mismatch after first login: got %s, want %s", user.Email, fakeGoogle.Email)
 	}
-	tok, err := db.GetUserToken(context.Background(), fakeGoogle.UserID)
+	tok, err := db.GetUserToken(context.Background(), fakeGoogle.UserID, data.ProviderGmail, "")
 	if err != nil || tok == nil {
 		t.Fatalf("token not saved on first login: %v", err)
 	}
@@ -2,15 +2,22 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"golang.org/x/oauth2"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
 	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/mocks"
+	"github.com/desponda/inbox-whisperer/internal/service/outlook"
 	"github.com/desponda/inbox-whisperer/internal/session"
+	"github.com/desponda/inbox-whisperer/internal/testutils"
 )
 
 // Skipping TestExchangeCodeForToken as it requires refactor for dependency injection.
@@ -43,16 +50,37 @@ func TestHandleLogin(t *testing.T) {
 	}
 }
 
-// For error-injection, use a unique stub for SaveUserToken error case
-// Implements data.UserTokenRepository
-// (GetUserToken returns a dummy token)
-type stubFailUserTokens struct{}
+func TestHandleLogin_FeatureUpgrade(t *testing.T) {
+	h := &AuthHandler{OAuthConfig: &oauth2.Config{
+		ClientID: "dummy",
+		Scopes:   []string{"openid"},
+		Endpoint: oauth2.Endpoint{AuthURL: "http://localhost/auth", TokenURL: "http://localhost/token"},
+	}}
+	w := httptest.NewRecorder()
+	h.HandleLogin(w, httptest.NewRequest("GET", "/api/auth/login?feature=modify&account_id=me@example.com", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected redirect (302), got %d", w.Code)
+	}
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := loc.Query()
+	if got := q.Get("scope"); got != "openid https://www.googleapis.com/auth/gmail.modify" {
+		t.Errorf("unexpected scope %q", got)
+	}
+	if q.Get("include_granted_scopes") != "true" || q.Get("login_hint") != "me@example.com" {
+		t.Errorf("expected incremental consent params, got %s", loc.RawQuery)
+	}
+	if len(h.OAuthConfig.Scopes) != 1 {
+		t.Errorf("handler scopes were modified: %v", h.OAuthConfig.Scopes)
+	}
 
-func (s *stubFailUserTokens) SaveUserToken(ctx context.Context, userID string, tok *oauth2.Token) error {
-	return context.DeadlineExceeded
-}
-func (s *stubFailUserTokens) GetUserToken(ctx context.Context, userID string) (*oauth2.Token, error) {
-	return &oauth2.Token{AccessToken: "tok"}, nil
+	w = httptest.NewRecorder()
+	h.HandleLogin(w, httptest.NewRequest("GET", "/api/auth/login?feature=teleport", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown feature, got %d", w.Code)
+	}
 }
 
 func TestHandleCallback(t *testing.T) {
@@ -108,11 +136,11 @@ func TestHandleCallback(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			var userTokens data.UserTokenRepository
+			userTokens := &mocks.MockUserTokenRepository{}
 			if tc.failSave {
-				userTokens = &stubFailUserTokens{}
-			} else {
-				userTokens = &stubUserTokens{} // from auth_csrf_test.go
+				userTokens.SaveUserTokenFunc = func(ctx context.Context, userID, provider, accountID string, tok *oauth2.Token) error {
+					return context.DeadlineExceeded
+				}
 			}
 			h := &AuthHandler{
 				OAuthConfig: cfg,
@@ -141,7 +169,7 @@ func TestHandleCallback(t *testing.T) {
 					}
 				})
 				mux.HandleFunc("/setstatevalue", func(w http.ResponseWriter, r *http.Request) {
-					session.SetSessionValue(w, r, "oauth_state", "valid")
+					session.SetState(w, r, "valid")
 					if _, err := w.Write([]byte("ok")); err != nil {
 						t.Fatalf("failed to write response: %v", err)
 					}
@@ -149,15 +177,9 @@ func TestHandleCallback(t *testing.T) {
 				mux.HandleFunc("/api/auth/callback", func(w http.ResponseWriter, r *http.Request) {
 					h.HandleCallback(w, r)
 				})
-				ts := httptest.NewServer(session.Middleware(mux))
-				defer ts.Close()
-				jar, err := session.NewTestCookieJar()
-				if err != nil {
-					t.Fatalf("failed to create cookie jar: %v", err)
-				}
-				client := &http.Client{Jar: jar}
+				ts, client := testutils.NewSessionServer(t, mux)
 				// Create the session
-				_, err = client.Get(ts.URL + "/setstate")
+				_, err := client.Get(ts.URL + "/setstate")
 				if err != nil {
 					t.Fatalf("setstate failed: %v", err)
 				}
@@ -224,3 +246,247 @@ func TestFetchGoogleUserID(t *testing.T) {
 		t.Errorf("expected id 'abc123', got %v, %v", id, err)
 	}
 }
+
+// memStates is an in-memory data.OAuthStateRepository shared by every "instance" in a test
+type memStates struct{ states map[string]time.Time }
+
+func (m *memStates) CreateState(ctx context.Context, state string, expiresAt time.Time) error {
+	m.states[state] = expiresAt
+	return nil
+}
+
+func (m *memStates) ConsumeState(ctx context.Context, state string) error {
+	exp, ok := m.states[state]
+	delete(m.states, state)
+	if !ok || !time.Now().Before(exp) {
+		return data.ErrNotFound
+	}
+	return nil
+}
+
+func TestHandleCallback_StoredStates(t *testing.T) {
+	savedExchange := exchangeCodeForToken
+	// Failing the exchange shows the state was accepted without calling the provider
+	exchangeCodeForToken = func(h *AuthHandler, ctx context.Context, code string) (*oauth2.Token, error) {
+		return nil, context.DeadlineExceeded
+	}
+	defer func() { exchangeCodeForToken = savedExchange }()
+
+	states := &memStates{states: map[string]time.Time{}}
+	cfg := &oauth2.Config{ClientID: "dummy", Endpoint: oauth2.Endpoint{AuthURL: "http://localhost/auth"}}
+	// Two handlers stand in for two replicas sharing the database
+	login := &AuthHandler{OAuthConfig: cfg, States: states}
+	callback := &AuthHandler{OAuthConfig: cfg, States: states}
+
+	w := httptest.NewRecorder()
+	login.HandleLogin(w, httptest.NewRequest("GET", "/api/auth/login", nil))
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := loc.Query().Get("state")
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oauthStateCookie || cookies[0].Value != state {
+		t.Fatalf("expected the state cookie, got %v", cookies)
+	}
+
+	do := func(query string, cookie *http.Cookie) int {
+		r := httptest.NewRequest("GET", "/api/auth/callback"+query, nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		callback.HandleCallback(w, r)
+		return w.Code
+	}
+	if got := do("?code=c&state="+state, nil); got != http.StatusBadRequest {
+		t.Errorf("without the cookie: expected 400, got %d", got)
+	}
+	if got := do("?code=c&state="+state, &http.Cookie{Name: oauthStateCookie, Value: "other"}); got != http.StatusBadRequest {
+		t.Errorf("with another browser's cookie: expected 400, got %d", got)
+	}
+	if got := do("?code=c&state="+state, cookies[0]); got != http.StatusInternalServerError {
+		t.Errorf("valid state: expected the exchange to run (500), got %d", got)
+	}
+	if got := do("?code=c&state="+state, cookies[0]); got != http.StatusBadRequest {
+		t.Errorf("replayed state: expected 400, got %d", got)
+	}
+}
+
+func TestResolveReturnTo(t *testing.T) {
+	const frontend = "https://app.example.com"
+	tests := []struct {
+		returnTo string
+		want     string
+		ok       bool
+	}{
+		{"/inbox/123?tab=all#top", "https://app.example.com/inbox/123?tab=all#top", true},
+		{"https://app.example.com/settings", "https://app.example.com/settings", true},
+		{"HTTPS://APP.example.com/settings", "https://APP.example.com/settings", true},
+		{"", "", false},
+		{"inbox", "", false},
+		{"//evil.com/inbox", "", false},
+		{"/\\evil.com", "", false},
+		{"\\\\evil.com", "", false},
+		{"https://evil.com/inbox", "", false},
+		{"https://app.example.com.evil.com/", "", false},
+		{"https://app.example.com@evil.com/", "", false},
+		{"http://app.example.com/inbox", "", false},
+		{"https://app.example.com:8443/inbox", "", false},
+		{"javascript:alert(1)", "", false},
+		{"/inbox\r\nSet-Cookie: x=y", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := resolveReturnTo(frontend, tt.returnTo)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("resolveReturnTo(%q) = %q, %v; want %q, %v", tt.returnTo, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHandleLogin_ReturnTo(t *testing.T) {
+	h := &AuthHandler{
+		OAuthConfig: &oauth2.Config{ClientID: "dummy", Endpoint: oauth2.Endpoint{AuthURL: "http://localhost/auth"}},
+		FrontendURL: "http://localhost:5173",
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth/login", h.HandleLogin)
+	mux.HandleFunc("/returnto", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, session.GetReturnTo(r))
+	})
+	ts, client := testutils.NewSessionServer(t, mux)
+	get := func(path string) (int, string) {
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	// Create the session
+	get("/returnto")
+
+	if code, _ := get("/api/auth/login?returnTo=" + url.QueryEscape("/inbox/42")); code != http.StatusFound {
+		t.Fatalf("expected redirect (302), got %d", code)
+	}
+	if _, got := get("/returnto"); got != "http://localhost:5173/inbox/42" {
+		t.Errorf("stored returnTo = %q", got)
+	}
+
+	if code, _ := get("/api/auth/login?returnTo=" + url.QueryEscape("https://evil.com/")); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a foreign origin, got %d", code)
+	}
+	// A login without returnTo drops the earlier target
+	get("/api/auth/login")
+	if _, got := get("/returnto"); got != "" {
+		t.Errorf("expected the stored returnTo to be cleared, got %q", got)
+	}
+}
+
+func TestHandleLogin_ReadOnly(t *testing.T) {
+	h := &AuthHandler{
+		OAuthConfig: &oauth2.Config{ClientID: "dummy", Endpoint: oauth2.Endpoint{AuthURL: "http://localhost/auth"}},
+		FrontendURL: "http://localhost:5173",
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth/login", h.HandleLogin)
+	mux.HandleFunc("/flags", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%t %t", session.GetReadOnlyLogin(r), session.IsReadOnly(r))
+	})
+	mux.HandleFunc("/lock", func(w http.ResponseWriter, r *http.Request) {
+		session.SetReadOnly(w, r)
+	})
+	ts, client := testutils.NewSessionServer(t, mux)
+	get := func(path string) (int, string) {
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	// Create the session
+	get("/flags")
+
+	if code, _ := get("/api/auth/login?read_only=true"); code != http.StatusFound {
+		t.Fatalf("expected redirect (302), got %d", code)
+	}
+	if _, got := get("/flags"); got != "true false" {
+		t.Errorf("expected a pending read-only login only, got %q", got)
+	}
+	if code, _ := get("/api/auth/login?read_only=maybe"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad read_only, got %d", code)
+	}
+	if code, _ := get("/api/auth/login?read_only=1&feature=modify"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a read-only feature grant, got %d", code)
+	}
+
+	// Starting a full login does not lift an existing session's restriction
+	get("/lock")
+	get("/api/auth/login")
+	if _, got := get("/flags"); got != "false true" {
+		t.Errorf("expected the session to stay read-only, got %q", got)
+	}
+}
+
+func TestHandleOutlookCallback(t *testing.T) {
+	ms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/token":
+			io.WriteString(w, `{"access_token":"ms-access","refresh_token":"ms-refresh","token_type":"Bearer","expires_in":3600}`)
+		case "/me":
+			io.WriteString(w, `{"mail":"Me@Outlook.com"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ms.Close()
+	prov := outlook.NewOutlookProvider(&oauth2.Config{
+		ClientID: "ms",
+		Endpoint: oauth2.Endpoint{AuthURL: ms.URL + "/authorize", TokenURL: ms.URL + "/token"},
+	}, nil)
+	prov.BaseURL = ms.URL
+	var savedProvider, savedAccount string
+	tokens := &mocks.MockUserTokenRepository{
+		SaveUserTokenFunc: func(ctx context.Context, userID, provider, accountID string, token *oauth2.Token) error {
+			if userID != "user1" || token.AccessToken != "ms-access" {
+				t.Errorf("unexpected save for %s: %+v", userID, token)
+			}
+			savedProvider, savedAccount = provider, accountID
+			return nil
+		},
+	}
+	h := &AuthHandler{UserTokens: tokens, FrontendURL: "http://frontend", States: &memStates{states: map[string]time.Time{}}, Outlook: prov}
+
+	signedIn := func(target string) *http.Request {
+		r := httptest.NewRequest("GET", target, nil)
+		return r.WithContext(ctxkeys.WithUserID(r.Context(), "user1"))
+	}
+	w := httptest.NewRecorder()
+	h.HandleOutlookLogin(w, signedIn("/api/auth/outlook/login"))
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(loc.String(), ms.URL+"/authorize") {
+		t.Fatalf("expected a redirect to Microsoft, got %q", w.Header().Get("Location"))
+	}
+	state := loc.Query().Get("state")
+
+	r := signedIn("/api/auth/outlook/callback?code=c&state=" + state)
+	r.AddCookie(w.Result().Cookies()[0])
+	w = httptest.NewRecorder()
+	h.HandleOutlookCallback(w, r)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "http://frontend" {
+		t.Fatalf("expected a redirect to the frontend, got %d %q: %s", w.Code, w.Header().Get("Location"), w.Body)
+	}
+	if savedProvider != data.ProviderOutlook || savedAccount != "me@outlook.com" {
+		t.Errorf("expected the token stored under outlook/me@outlook.com, got %s/%s", savedProvider, savedAccount)
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleOutlookCallback(w, signedIn("/api/auth/outlook/callback?error=access_denied"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("declined consent: expected 400, got %d", w.Code)
+	}
+}
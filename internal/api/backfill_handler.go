@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/desponda/inbox-whisperer/internal/backfill"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+// MailboxBackfiller queues mailbox backfills (see backfill.Mailbox)
+type MailboxBackfiller interface {
+	Enqueue(ctx context.Context, userID, accountID string) (*models.Job, error)
+}
+
+type BackfillHandler struct {
+	Mailbox MailboxBackfiller
+}
+
+func NewBackfillHandler(mailbox MailboxBackfiller) *BackfillHandler {
+	return &BackfillHandler{Mailbox: mailbox}
+}
+
+// StartMine handles POST /api/users/me/backfill, answering 202 with the queued job; its
+// progress is at GET /api/jobs/{id}
+func (h *BackfillHandler) StartMine(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	job, err := h.Mailbox.Enqueue(r.Context(), userID, ctxkeys.AccountID(r.Context()))
+	if errors.Is(err, backfill.ErrMailboxActive) {
+		RespondErrorCode(w, http.StatusConflict, "backfill_job_active",
+			"mailbox backfill job "+strconv.FormatInt(job.ID, 10)+" is already "+job.Status)
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to enqueue mailbox backfill")
+		return
+	}
+	RespondJSON(w, http.StatusAccepted, job)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/bulk"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+)
+
+// BulkHandler queues bulk jobs. Its routes run behind RequireScope for the modify scope, like
+// the single-message actions.
+type BulkHandler struct {
+	Jobs *bulk.Runner
+}
+
+func NewBulkHandler(runner *bulk.Runner) *BulkHandler {
+	return &BulkHandler{Jobs: runner}
+}
+
+// BulkFilterRequest selects the messages of a bulk request; at least one field is required
+type BulkFilterRequest struct {
+	Sender   string `json:"sender"`
+	Category string `json:"category"`
+	// OlderThan is a number of days such as "30d", or a Go duration such as "12h"
+	OlderThan string `json:"older_than"`
+}
+
+// BulkRequest is the body of POST /api/emails/bulk
+type BulkRequest struct {
+	Action     string            `json:"action"`
+	Filter     BulkFilterRequest `json:"filter"`
+	ToCategory string            `json:"to_category"`
+}
+
+// Enqueue handles POST /api/emails/bulk, answering 202 with the queued job
+func (h *BulkHandler) Enqueue(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	var req BulkRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	olderThan, err := parseOlderThan(req.Filter.OlderThan)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	breq := bulk.Request{
+		UserID:     userID,
+		AccountID:  ctxkeys.AccountID(r.Context()),
+		Action:     req.Action,
+		ToCategory: req.ToCategory,
+		Sender:     req.Filter.Sender,
+		Category:   req.Filter.Category,
+		OlderThan:  olderThan,
+	}
+	if err := breq.Validate(); err != nil {
+		RespondError(w, http.StatusBadRequest, strings.TrimPrefix(err.Error(), bulk.ErrInvalidRequest.Error()+": "))
+		return
+	}
+	job, err := h.Jobs.Enqueue(r.Context(), breq)
+	switch {
+	case errors.Is(err, bulk.ErrJobActive):
+		RespondErrorCode(w, http.StatusConflict, "bulk_job_active",
+			"bulk job "+strconv.FormatInt(job.ID, 10)+" is already "+job.Status)
+	case err != nil:
+		RespondError(w, http.StatusInternalServerError, "failed to enqueue bulk job")
+	default:
+		RespondJSON(w, http.StatusAccepted, job)
+	}
+}
+
+// GetJob handles GET /api/emails/bulk/{id}; other users' jobs are reported as not found
+func (h *BulkHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	idParam, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid job id")
+		return
+	}
+	job, err := h.Jobs.Job(r.Context(), id)
+	if errors.Is(err, data.ErrNotFound) || (err == nil && job.UserID != userID) {
+		RespondError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load job")
+		return
+	}
+	RespondJSON(w, http.StatusOK, job)
+}
+
+// parseOlderThan reads a number of days such as "30d", or a Go duration; "" is no limit
+func parseOlderThan(v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	} else if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, errors.New("older_than must be a positive number of days such as 30d, or a duration such as 12h")
+}
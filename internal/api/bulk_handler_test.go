@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/bulk"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// stubBulkJobRepo stores jobs in memory; every filter matches 3 messages
+type stubBulkJobRepo struct {
+	jobs []*models.BulkJob
+}
+
+func (s *stubBulkJobRepo) CreateJob(ctx context.Context, j *models.BulkJob) error {
+	j.ID, j.Status = int64(len(s.jobs)+1), models.JobQueued
+	s.jobs = append(s.jobs, j)
+	return nil
+}
+func (s *stubBulkJobRepo) GetJob(ctx context.Context, id int64) (*models.BulkJob, error) {
+	if id < 1 || id > int64(len(s.jobs)) {
+		return nil, data.ErrNotFound
+	}
+	return s.jobs[id-1], nil
+}
+func (s *stubBulkJobRepo) UpdateJob(ctx context.Context, j *models.BulkJob) error { return nil }
+func (s *stubBulkJobRepo) ActiveJob(ctx context.Context, userID string) (*models.BulkJob, error) {
+	for _, j := range s.jobs {
+		if j.UserID == userID && !j.Finished() {
+			return j, nil
+		}
+	}
+	return nil, nil
+}
+func (s *stubBulkJobRepo) CountMatches(ctx context.Context, userID string, f models.BulkFilter) (int, error) {
+	return 3, nil
+}
+func (s *stubBulkJobRepo) Matches(ctx context.Context, userID string, f models.BulkFilter, afterInternalDate int64, afterID string, limit int) ([]*models.EmailMessage, error) {
+	return nil, nil
+}
+
+func bulkRequest(method, userID, id, body string) *http.Request {
+	r := httptest.NewRequest(method, "/api/emails/bulk", strings.NewReader(body))
+	ctx := ctxkeys.WithUserID(r.Context(), userID)
+	if id != "" {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+	}
+	return r.WithContext(ctx)
+}
+
+func TestBulkHandler_Enqueue(t *testing.T) {
+	h := NewBulkHandler(bulk.NewRunner(&stubBulkJobRepo{}, nil, nil, nil, &stubJobQueue{}))
+	serve := func(userID, body string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		h.Enqueue(rw, bulkRequest(http.MethodPost, userID, "", body))
+		return rw
+	}
+
+	require.Equal(t, http.StatusUnauthorized, serve("", `{}`).Code)
+	require.Equal(t, http.StatusBadRequest, serve("user1", `{"action":"archive"}`).Code)
+	require.Equal(t, http.StatusBadRequest, serve("user1", `{"action":"archive","filter":{"older_than":"soon"}}`).Code)
+	require.Equal(t, http.StatusBadRequest, serve("user1", `{"action":"recategorize","filter":{"sender":"a@x.example"}}`).Code)
+
+	rw := serve("user1", `{"action":"recategorize","to_category":"Receipts","filter":{"sender":"Shop@X.example","older_than":"30d"}}`)
+	require.Equal(t, http.StatusAccepted, rw.Code)
+	var job models.BulkJob
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&job))
+	require.Equal(t, int64(1), job.ID)
+	require.Equal(t, "shop@x.example", job.Filter.Sender)
+	require.Equal(t, "Receipts", job.ToCategory)
+	require.Equal(t, 3, job.Total)
+	require.Equal(t, int64(1), job.QueueJobID)
+	require.InDelta(t, time.Now().Add(-30*24*time.Hour).UnixMilli(), job.Filter.Before, float64(time.Minute.Milliseconds()))
+
+	rw = serve("user1", `{"action":"recategorize","to_category":"Receipts","filter":{"category":"updates"}}`)
+	require.Equal(t, http.StatusConflict, rw.Code)
+	require.Contains(t, rw.Body.String(), "bulk_job_active")
+}
+
+func TestBulkHandler_EnqueueNeedsModifyScope(t *testing.T) {
+	h := NewBulkHandler(bulk.NewRunner(&stubBulkJobRepo{}, nil, nil, nil, &stubJobQueue{}))
+	serve := func(granted []string) *httptest.ResponseRecorder {
+		r := chi.NewRouter()
+		r.With(RequireScope(stubScopes{granted: granted}, provider.FeatureModify)).Post("/api/emails/bulk", h.Enqueue)
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, bulkRequest(http.MethodPost, "user1", "", `{"action":"recategorize","to_category":"Receipts","filter":{"sender":"a@x.example"}}`))
+		return rw
+	}
+
+	// Login grants read access only
+	rw := serve(gmail.LoginScopes)
+	require.Equal(t, http.StatusForbidden, rw.Code)
+	require.Contains(t, rw.Body.String(), "missing_scope")
+
+	require.Equal(t, http.StatusAccepted, serve([]string{gmail.ScopeReadonly, gmail.ScopeModify}).Code)
+}
+
+func TestBulkHandler_GetJob(t *testing.T) {
+	repo := &stubBulkJobRepo{}
+	h := NewBulkHandler(bulk.NewRunner(repo, nil, nil, nil, &stubJobQueue{}))
+	require.NoError(t, repo.CreateJob(context.Background(), &models.BulkJob{UserID: "user1", Action: models.BulkArchive}))
+
+	for _, tc := range []struct {
+		userID, id string
+		want       int
+	}{
+		{"user1", "1", http.StatusOK},
+		{"user2", "1", http.StatusNotFound},
+		{"user1", "2", http.StatusNotFound},
+		{"user1", "abc", http.StatusBadRequest},
+	} {
+		rw := httptest.NewRecorder()
+		h.GetJob(rw, bulkRequest(http.MethodGet, tc.userID, tc.id, ""))
+		require.Equal(t, tc.want, rw.Code, "user %s job %s", tc.userID, tc.id)
+	}
+}
+
+func TestParseOlderThan(t *testing.T) {
+	for in, want := range map[string]time.Duration{"": 0, "7d": 7 * 24 * time.Hour, "12h": 12 * time.Hour} {
+		got, err := parseOlderThan(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"0d", "-1d", "xd", "-5h", "tomorrow"} {
+		_, err := parseOlderThan(in)
+		require.Error(t, err, in)
+	}
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Limits of the client state store
+const (
+	MaxClientStateKeys       = 50
+	MaxClientStateValueBytes = 16 << 10
+)
+
+// ErrCodeVersionConflict is returned when a client state write is based on an outdated version
+const ErrCodeVersionConflict = "version_conflict"
+
+// clientStateKey is what a client state key may look like
+var clientStateKey = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// ClientStateHandler lets the frontend sync small UI state documents across a user's devices
+type ClientStateHandler struct {
+	State data.ClientStateRepository
+}
+
+func NewClientStateHandler(state data.ClientStateRepository) *ClientStateHandler {
+	return &ClientStateHandler{State: state}
+}
+
+// ClientStateRequest is the body of PUT /api/users/me/client-state/{key}. Version is the version
+// the client last read, 0 when it creates the key.
+type ClientStateRequest struct {
+	Value   json.RawMessage `json:"value"`
+	Version int64           `json:"version"`
+}
+
+// ClientStateConflict answers a write based on an outdated version with the stored document, so
+// the client can merge and retry; Current is absent when the key was deleted
+type ClientStateConflict struct {
+	Error   string              `json:"error"`
+	Code    string              `json:"code"`
+	Current *models.ClientState `json:"current,omitempty"`
+}
+
+// ListClientState handles GET /api/users/me/client-state
+func (h *ClientStateHandler) ListClientState(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	list, err := h.State.List(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load client state")
+		return
+	}
+	if list == nil {
+		list = []*models.ClientState{}
+	}
+	RespondJSON(w, http.StatusOK, list)
+}
+
+// GetClientState handles GET /api/users/me/client-state/{key}
+func (h *ClientStateHandler) GetClientState(w http.ResponseWriter, r *http.Request) {
+	userID, key, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+	s, err := h.State.Get(r.Context(), userID, key)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "no client state under key")
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load client state")
+		return
+	}
+	RespondJSON(w, http.StatusOK, s)
+}
+
+// PutClientState handles PUT /api/users/me/client-state/{key}, refusing writes over a version
+// the client has not seen with 409
+func (h *ClientStateHandler) PutClientState(w http.ResponseWriter, r *http.Request) {
+	userID, key, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+	var req ClientStateRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	if len(req.Value) == 0 || string(req.Value) == "null" {
+		RespondError(w, http.StatusBadRequest, "value is required")
+		return
+	}
+	if len(req.Value) > MaxClientStateValueBytes {
+		RespondErrorCode(w, http.StatusRequestEntityTooLarge, "body_too_large", "value is over "+strconv.Itoa(MaxClientStateValueBytes>>10)+" KB")
+		return
+	}
+	if req.Version < 0 {
+		RespondError(w, http.StatusBadRequest, "version must not be negative")
+		return
+	}
+	if req.Version == 0 {
+		n, err := h.State.Count(r.Context(), userID)
+		if err != nil {
+			RespondError(w, http.StatusInternalServerError, "failed to save client state")
+			return
+		}
+		if n >= MaxClientStateKeys {
+			RespondError(w, http.StatusConflict, "too many client state keys: at most "+strconv.Itoa(MaxClientStateKeys))
+			return
+		}
+	}
+	s := &models.ClientState{UserID: userID, Key: key, Value: req.Value}
+	err := h.State.Put(r.Context(), s, req.Version)
+	if errors.Is(err, data.ErrConflict) {
+		current, err := h.State.Get(r.Context(), userID, key)
+		if err != nil && !errors.Is(err, data.ErrNotFound) {
+			RespondError(w, http.StatusInternalServerError, "failed to load client state")
+			return
+		}
+		RespondJSON(w, http.StatusConflict, ClientStateConflict{
+			Error:   "client state changed since version " + strconv.FormatInt(req.Version, 10),
+			Code:    ErrCodeVersionConflict,
+			Current: current,
+		})
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Str("key", key).Msg("failed to save client state")
+		RespondError(w, http.StatusInternalServerError, "failed to save client state")
+		return
+	}
+	RespondJSON(w, http.StatusOK, s)
+}
+
+// DeleteClientState handles DELETE /api/users/me/client-state/{key}
+func (h *ClientStateHandler) DeleteClientState(w http.ResponseWriter, r *http.Request) {
+	userID, key, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+	err := h.State.Delete(r.Context(), userID, key)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "no client state under key")
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to delete client state")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// target reads the user and key of a request, answering it when one is missing or invalid
+func (h *ClientStateHandler) target(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return "", "", false
+	}
+	key := chi.URLParam(r, "key")
+	if !clientStateKey.MatchString(key) {
+		RespondError(w, http.StatusBadRequest, "key must be 1 to 64 letters, digits, dots, dashes or underscores")
+		return "", "", false
+	}
+	return userID, key, true
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+type stubClientStateRepo struct {
+	state map[string]*models.ClientState
+}
+
+func (s *stubClientStateRepo) List(ctx context.Context, userID string) ([]*models.ClientState, error) {
+	var out []*models.ClientState
+	for _, st := range s.state {
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+func (s *stubClientStateRepo) Count(ctx context.Context, userID string) (int, error) {
+	return len(s.state), nil
+}
+
+func (s *stubClientStateRepo) Get(ctx context.Context, userID, key string) (*models.ClientState, error) {
+	st, ok := s.state[key]
+	if !ok {
+		return nil, data.ErrNotFound
+	}
+	return st, nil
+}
+
+func (s *stubClientStateRepo) Put(ctx context.Context, state *models.ClientState, expectedVersion int64) error {
+	var version int64
+	if cur, ok := s.state[state.Key]; ok {
+		version = cur.Version
+	}
+	if version != expectedVersion {
+		return data.ErrConflict
+	}
+	state.Version, state.UpdatedAt = version+1, time.Now()
+	s.state[state.Key] = state
+	return nil
+}
+
+func (s *stubClientStateRepo) Delete(ctx context.Context, userID, key string) error {
+	if _, ok := s.state[key]; !ok {
+		return data.ErrNotFound
+	}
+	delete(s.state, key)
+	return nil
+}
+
+func TestClientStateHandler(t *testing.T) {
+	repo := &stubClientStateRepo{state: map[string]*models.ClientState{}}
+	h := NewClientStateHandler(repo)
+	put := func(key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.PutClientState(w, testutils.NewAuthedRequest("PUT", "/api/users/me/client-state/"+key, strings.NewReader(body), testutils.WithURLParam("key", key)))
+		return w
+	}
+
+	w := put("bundles", `{"value":{"collapsed":["news"]},"version":0}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"version":1`)
+
+	w = put("bundles", `{"value":{"collapsed":[]},"version":1}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// A device still at version 1 is told what changed
+	w = put("bundles", `{"value":{"collapsed":["social"]},"version":1}`)
+	require.Equal(t, http.StatusConflict, w.Code)
+	var conflict ClientStateConflict
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &conflict))
+	require.Equal(t, ErrCodeVersionConflict, conflict.Code)
+	require.Equal(t, int64(2), conflict.Current.Version)
+	require.JSONEq(t, `{"collapsed":[]}`, string(conflict.Current.Value))
+
+	w = httptest.NewRecorder()
+	h.GetClientState(w, testutils.NewAuthedRequest("GET", "/api/users/me/client-state/bundles", nil, testutils.WithURLParam("key", "bundles")))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"value":{"collapsed":[]}`)
+
+	require.Equal(t, http.StatusBadRequest, put("bad/key", `{"value":1}`).Code)
+	require.Equal(t, http.StatusBadRequest, put("view", `{"version":0}`).Code)
+	require.Equal(t, http.StatusRequestEntityTooLarge, put("view", `{"value":"`+strings.Repeat("x", MaxClientStateValueBytes)+`"}`).Code)
+
+	for i := len(repo.state); i < MaxClientStateKeys; i++ {
+		repo.state[string(rune('a'+i%26))+strings.Repeat("x", i)] = &models.ClientState{Version: 1}
+	}
+	require.Equal(t, http.StatusConflict, put("one-too-many", `{"value":true}`).Code)
+
+	w = httptest.NewRecorder()
+	h.DeleteClientState(w, testutils.NewAuthedRequest("DELETE", "/api/users/me/client-state/bundles", nil, testutils.WithURLParam("key", "bundles")))
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w = httptest.NewRecorder()
+	h.GetClientState(w, testutils.NewAuthedRequest("GET", "/api/users/me/client-state/bundles", nil, testutils.WithURLParam("key", "bundles")))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
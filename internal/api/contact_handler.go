@@ -0,0 +1,83 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/desponda/inbox-whisperer/internal/contacts"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+)
+
+type ContactHandler struct {
+	Contacts *contacts.Service
+}
+
+func NewContactHandler(svc *contacts.Service) *ContactHandler {
+	return &ContactHandler{Contacts: svc}
+}
+
+// ListContacts handles GET /api/contacts
+func (h *ContactHandler) ListContacts(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	list, err := h.Contacts.List(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load contacts")
+		return
+	}
+	RespondJSON(w, http.StatusOK, list)
+}
+
+// GetContact handles GET /api/contacts/{id}, where id is the contact's email address
+func (h *ContactHandler) GetContact(w http.ResponseWriter, r *http.Request) {
+	userID, address, ok := contactParams(w, r)
+	if !ok {
+		return
+	}
+	c, err := h.Contacts.Get(r.Context(), userID, address)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "contact not found")
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load contact")
+		return
+	}
+	RespondJSON(w, http.StatusOK, c)
+}
+
+// ThreadParticipants handles GET /api/threads/{id}/participants
+func (h *ContactHandler) ThreadParticipants(w http.ResponseWriter, r *http.Request) {
+	userID, threadID, ok := contactParams(w, r)
+	if !ok {
+		return
+	}
+	participants, err := h.Contacts.Participants(r.Context(), userID, threadID)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "thread not found")
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load thread participants")
+		return
+	}
+	RespondJSON(w, http.StatusOK, participants)
+}
+
+func contactParams(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return "", "", false
+	}
+	id, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return "", "", false
+	}
+	return userID, id, true
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/contacts"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// stubContactRepo holds one thread between the user (no known address) and ann
+type stubContactRepo struct {
+	stats []*models.ContactStats
+}
+
+func (s *stubContactRepo) ThreadMessages(ctx context.Context, userID, threadID string) ([]*models.EmailMessage, error) {
+	if threadID != "" && threadID != "t1" {
+		return nil, nil
+	}
+	return []*models.EmailMessage{{ThreadID: "t1", Sender: "Ann <ann@example.com>", InternalDate: 1000}}, nil
+}
+func (s *stubContactRepo) ReplaceContactStats(ctx context.Context, userID string, stats []*models.ContactStats) error {
+	s.stats = stats
+	return nil
+}
+func (s *stubContactRepo) ListContacts(ctx context.Context, userID string) ([]*models.ContactStats, error) {
+	return s.stats, nil
+}
+func (s *stubContactRepo) GetContact(ctx context.Context, userID, address string) (*models.ContactStats, error) {
+	for _, c := range s.stats {
+		if c.Address == address {
+			return c, nil
+		}
+	}
+	return nil, data.ErrNotFound
+}
+
+func contactRequest(id string) *http.Request {
+	r := httptest.NewRequest("GET", "/api/contacts", nil)
+	ctx := ctxkeys.WithUserID(r.Context(), "user1")
+	if id != "" {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+	}
+	return r.WithContext(ctx)
+}
+
+func TestContactHandler(t *testing.T) {
+	svc := contacts.NewService(&stubContactRepo{}, nil)
+	require.NoError(t, svc.Refresh(context.Background(), "user1"))
+	h := NewContactHandler(svc)
+
+	rw := httptest.NewRecorder()
+	h.ListContacts(rw, contactRequest(""))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var list []models.ContactStats
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&list))
+	require.Len(t, list, 1)
+	require.Equal(t, "ann@example.com", list[0].Address)
+
+	rw = httptest.NewRecorder()
+	h.GetContact(rw, contactRequest("ann@example.com"))
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.GetContact(rw, contactRequest("bob@example.com"))
+	require.Equal(t, http.StatusNotFound, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.ThreadParticipants(rw, contactRequest("t1"))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var participants []models.ThreadParticipant
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&participants))
+	require.Len(t, participants, 1)
+
+	rw = httptest.NewRecorder()
+	h.ThreadParticipants(rw, contactRequest("t2"))
+	require.Equal(t, http.StatusNotFound, rw.Code)
+}
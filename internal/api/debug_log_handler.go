@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/telemetry/debuglog"
+)
+
+type DebugLogHandler struct {
+	Toggles *debuglog.Toggles
+}
+
+func NewDebugLogHandler(t *debuglog.Toggles) *DebugLogHandler {
+	return &DebugLogHandler{Toggles: t}
+}
+
+// DebugLogRequest turns on debug logging for a user
+type DebugLogRequest struct {
+	// TTLSeconds is how long it stays on; 0 means the default of 30 minutes, at most 24 hours
+	TTLSeconds int `json:"ttl_seconds"`
+}
+
+// AdminList handles GET /api/admin/debug-logging
+func (h *DebugLogHandler) AdminList(w http.ResponseWriter, r *http.Request) {
+	RespondJSON(w, http.StatusOK, h.Toggles.List())
+}
+
+// AdminEnable handles PUT /api/admin/users/{id}/debug-logging
+func (h *DebugLogHandler) AdminEnable(w http.ResponseWriter, r *http.Request) {
+	userID, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req DebugLogRequest
+	if r.ContentLength != 0 {
+		if err := DecodeJSON(r, &req); err != nil {
+			RespondBodyError(w, err, "invalid request body")
+			return
+		}
+	}
+	if req.TTLSeconds < 0 || time.Duration(req.TTLSeconds)*time.Second > debuglog.MaxTTL {
+		RespondError(w, http.StatusBadRequest, "ttl_seconds must be between 0 and 86400")
+		return
+	}
+	RespondJSON(w, http.StatusOK, h.Toggles.Enable(userID, time.Duration(req.TTLSeconds)*time.Second))
+}
+
+// AdminDisable handles DELETE /api/admin/users/{id}/debug-logging
+func (h *DebugLogHandler) AdminDisable(w http.ResponseWriter, r *http.Request) {
+	userID, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.Toggles.Disable(userID) {
+		RespondError(w, http.StatusNotFound, "debug logging is not on for this user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/debuglog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugLogHandler(t *testing.T) {
+	toggles := debuglog.New()
+	h := NewDebugLogHandler(toggles)
+
+	rw := httptest.NewRecorder()
+	h.AdminEnable(rw, recategorizeRequest(http.MethodPut, "admin", "user1", `{"ttl_seconds":90000}`))
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.AdminEnable(rw, recategorizeRequest(http.MethodPut, "admin", "user1", `{"ttl_seconds":600}`))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var toggle debuglog.Toggle
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&toggle))
+	require.Equal(t, "user1", toggle.UserID)
+	require.True(t, toggles.Enabled("user1"))
+
+	rw = httptest.NewRecorder()
+	h.AdminList(rw, recategorizeRequest(http.MethodGet, "admin", "", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var list []debuglog.Toggle
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&list))
+	require.Len(t, list, 1)
+
+	var marked string
+	mw := DebugLogMiddleware(toggles)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		marked = ctxkeys.DebugUser(r.Context())
+	}))
+	mw.ServeHTTP(httptest.NewRecorder(), recategorizeRequest(http.MethodGet, "user1", "", ""))
+	require.Equal(t, "user1", marked)
+	mw.ServeHTTP(httptest.NewRecorder(), recategorizeRequest(http.MethodGet, "user2", "", ""))
+	require.Empty(t, marked)
+
+	rw = httptest.NewRecorder()
+	h.AdminDisable(rw, recategorizeRequest(http.MethodDelete, "admin", "user1", ""))
+	require.Equal(t, http.StatusNoContent, rw.Code)
+	rw = httptest.NewRecorder()
+	h.AdminDisable(rw, recategorizeRequest(http.MethodDelete, "admin", "user1", ""))
+	require.Equal(t, http.StatusNotFound, rw.Code)
+}
@@ -4,40 +4,72 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
 	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/legalhold"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/render"
 	"github.com/desponda/inbox-whisperer/internal/service"
-	gmail "github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
 	"github.com/go-chi/chi/v5"
-	"golang.org/x/oauth2"
 )
 
 type EmailHandler struct {
 	Service    service.EmailService
 	UserTokens data.UserTokenRepository
+	// Renderer serves message bodies in the ?format a client asks for
+	Renderer *render.Renderer
+	// Counter, if set, fills the total estimate of ?envelope=true lists
+	Counter MessageCounter
 }
 
+// Limits of the message list
+const (
+	DefaultMessageLimit = 10
+	MaxMessageLimit     = 200
+)
+
 func NewEmailHandler(svc service.EmailService, userTokens data.UserTokenRepository) *EmailHandler {
-	return &EmailHandler{Service: svc, UserTokens: userTokens}
+	return &EmailHandler{Service: svc, UserTokens: userTokens, Renderer: render.NewRenderer(render.DefaultCacheSize)}
 }
 
 func (h *EmailHandler) FetchMessagesHandler(w http.ResponseWriter, r *http.Request) {
-	tokVal := r.Context().Value(ContextTokenKey)
-	tok, ok := tokVal.(*oauth2.Token)
-	if !ok || tok == nil {
+	tok := ctxkeys.Token(r.Context())
+	if tok == nil {
 		http.Error(w, "not authenticated: no token in context", http.StatusUnauthorized)
 		return
 	}
-	ctx := h.extractPagination(r)
+	envelope, err := wantsEnvelope(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, limit, err := h.extractPagination(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	pageInfo := &ctxkeys.PageInfo{}
+	ctx = ctxkeys.WithPageInfo(ctx, pageInfo)
 	msgs, err := h.Service.FetchMessages(ctx, tok)
+	if errors.Is(err, provider.ErrSyncing) {
+		respondSyncing(w, err)
+		return
+	}
 	if err != nil {
-		if errors.Is(err, gmail.ErrNotFound) {
-			http.Error(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeProviderError(w, err)
+		return
+	}
+	// Lists served straight from the provider page with its tokens; the body keeps its shape
+	if pageInfo.NextPageToken != "" {
+		w.Header().Set("X-Next-Page-Token", pageInfo.NextPageToken)
+	}
+	if envelope {
+		RespondJSON(w, http.StatusOK, h.messagePage(r.Context(), msgs, limit, pageInfo))
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -47,25 +79,63 @@ func (h *EmailHandler) FetchMessagesHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// messagePage wraps a message list for ?envelope=true. Cached lists continue after their last
+// message while pages are full; provider lists continue at the provider's token and have no
+// total.
+func (h *EmailHandler) messagePage(ctx context.Context, msgs []models.EmailMessage, limit int, info *ctxkeys.PageInfo) Page[models.EmailMessage] {
+	page := Page[models.EmailMessage]{Items: msgs, Limit: limit}
+	if page.Items == nil {
+		page.Items = []models.EmailMessage{}
+	}
+	if info.Passthrough {
+		if info.NextPageToken != "" {
+			page.NextCursor = Cursor{PageToken: info.NextPageToken}.Encode()
+		}
+		return page
+	}
+	if len(msgs) > 0 && len(msgs) >= limit {
+		last := msgs[len(msgs)-1]
+		page.NextCursor = Cursor{InternalDate: last.InternalDate, ID: last.EmailMessageID}.Encode()
+	}
+	if h.Counter != nil {
+		page.TotalEstimate = totalEstimate(ctx, h.Counter.CountMessages, ctxkeys.UserID(ctx))
+	}
+	return page
+}
+
+// GetMessageContentHandler handles GET /api/emails/messages/{id}. The message is returned as
+// JSON unless ?format=plain|html|markdown asks for just its body in that format.
 func (h *EmailHandler) GetMessageContentHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := ValidateIDParam(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	tokVal := r.Context().Value(ContextTokenKey)
-	tok, ok := tokVal.(*oauth2.Token)
-	if !ok || tok == nil {
+	var format render.Format
+	if v := r.URL.Query().Get("format"); v != "" {
+		if format, err = render.ParseFormat(v); err != nil {
+			RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	tok := ctxkeys.Token(r.Context())
+	if tok == nil {
 		http.Error(w, "not authenticated: no token in context", http.StatusUnauthorized)
 		return
 	}
 	msg, err := h.Service.FetchMessageContent(r.Context(), tok, id)
 	if err != nil {
-		if err.Error() == "not found" {
-			http.Error(w, "email not found", http.StatusNotFound)
-			return
+		writeProviderError(w, err)
+		return
+	}
+	if format != "" {
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if format == render.FormatHTML {
+			// The HTML is sanitized, but it is still mail from strangers served from our origin
+			w.Header().Set("Content-Security-Policy", "sandbox")
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		_, _ = w.Write([]byte(h.Renderer.Render(format, msg.Body, msg.HTMLBody)))
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -75,26 +145,94 @@ func (h *EmailHandler) GetMessageContentHandler(w http.ResponseWriter, r *http.R
 	}
 }
 
-type paginationKey struct{}
+// SyncingResponse is returned with 202 Accepted while a cold cache is being filled
+type SyncingResponse struct {
+	Syncing  bool                  `json:"syncing"`
+	Messages []models.EmailMessage `json:"messages"`
+	// RetryAfterSeconds repeats the Retry-After header for clients that cannot read headers
+	RetryAfterSeconds int `json:"retry_after_seconds"`
+}
 
-var (
-	paginationKeyAfterID   = paginationKey{}
-	paginationKeyAfterDate = paginationKey{}
-)
+// respondSyncing tells the client the inbox is still loading, rather than empty, and when to ask again
+func respondSyncing(w http.ResponseWriter, err error) {
+	retryAfter := int(provider.RetryAfter(err).Seconds())
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	RespondJSON(w, http.StatusAccepted, SyncingResponse{Syncing: true, Messages: []models.EmailMessage{}, RetryAfterSeconds: retryAfter})
+}
 
-func (h *EmailHandler) extractPagination(r *http.Request) context.Context {
+// MissingScopeResponse is the 403 body for an operation the account has not granted scopes for
+type MissingScopeResponse struct {
+	Error         string           `json:"error"`
+	Code          string           `json:"code"`
+	Feature       provider.Feature `json:"feature"`
+	MissingScopes []string         `json:"missing_scopes"`
+	// ConsentURL starts the OAuth flow asking for the missing scopes
+	ConsentURL string `json:"consent_url"`
+}
+
+// writeProviderError maps the provider error taxonomy onto HTTP status codes and a JSON error body
+func writeProviderError(w http.ResponseWriter, err error) {
+	var scopeErr *provider.ScopeError
+	switch {
+	case errors.As(err, &scopeErr):
+		RespondJSON(w, http.StatusForbidden, MissingScopeResponse{
+			Error: err.Error(), Code: "missing_scope", Feature: scopeErr.Feature, MissingScopes: scopeErr.Missing, ConsentURL: scopeErr.ConsentURL,
+		})
+	case errors.Is(err, provider.ErrMissingScope):
+		RespondErrorCode(w, http.StatusForbidden, "missing_scope", "email provider denied access: please grant the requested permissions again")
+	case errors.Is(err, provider.ErrNotFound):
+		RespondError(w, http.StatusNotFound, "not found")
+	case errors.Is(err, provider.ErrAuthExpired):
+		RespondError(w, http.StatusUnauthorized, "email provider authorization expired: please reconnect your account")
+	case errors.Is(err, provider.ErrRateLimited):
+		if d := provider.RetryAfter(err); d > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(d.Seconds())))
+		}
+		RespondError(w, http.StatusTooManyRequests, "email provider rate limit exceeded")
+	case errors.Is(err, provider.ErrTemporary):
+		RespondError(w, http.StatusServiceUnavailable, "email provider temporarily unavailable")
+	case errors.Is(err, provider.ErrInvalidRequest):
+		RespondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, provider.ErrConflict):
+		RespondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, provider.ErrUnsupported):
+		RespondError(w, http.StatusNotImplemented, err.Error())
+	case errors.Is(err, legalhold.ErrHeld):
+		RespondErrorCode(w, http.StatusLocked, ErrCodeLegalHold, err.Error())
+	default:
+		RespondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// extractPagination puts the page requested by ?cursor= (or after_internal_date and after_id,
+// or page_token) and ?limit= in the context, and returns the page size
+func (h *EmailHandler) extractPagination(r *http.Request) (context.Context, int, error) {
 	ctx := r.Context()
-	afterID := r.URL.Query().Get("after_id")
-	afterInternalDate := int64(0)
-	if v := r.URL.Query().Get("after_internal_date"); v != "" {
-		parsed, err := strconv.ParseInt(v, 10, 64)
-		if err == nil {
-			afterInternalDate = parsed
+	q := r.URL.Query()
+	c, err := pageCursor(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.PageToken == "" {
+		c.PageToken = q.Get("page_token")
+	}
+	ctx = ctxkeys.WithCursor(ctx, ctxkeys.Cursor{AfterID: c.ID, AfterInternalDate: c.InternalDate})
+	if c.PageToken != "" {
+		ctx = ctxkeys.WithPageToken(ctx, c.PageToken)
+	}
+	limit := DefaultMessageLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxMessageLimit {
+			return nil, 0, fmt.Errorf("limit must be between 1 and %d", MaxMessageLimit)
 		}
+		limit = n
+		ctx = ctxkeys.WithLimit(ctx, limit)
 	}
-	ctx = context.WithValue(ctx, paginationKeyAfterID, afterID)
-	ctx = context.WithValue(ctx, paginationKeyAfterDate, afterInternalDate)
-	return ctx
+	return ctx, limit, nil
 }
 
 // RegisterEmailRoutes adds the Email API endpoints
@@ -102,6 +240,6 @@ func (h *EmailHandler) extractPagination(r *http.Request) context.Context {
 func RegisterEmailRoutes(r chi.Router, userTokens data.UserTokenRepository, db *data.DB) {
 	factory := service.NewEmailProviderFactory()
 	h := NewEmailHandler(service.NewMultiProviderEmailService(factory), userTokens)
-	r.Get("/api/email/messages", h.FetchMessagesHandler)
-	r.Get("/api/email/messages/{id}", h.GetMessageContentHandler)
+	r.Get("/api/emails/messages", h.FetchMessagesHandler)
+	r.Get("/api/emails/messages/{id}", h.GetMessageContentHandler)
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/desponda/inbox-whisperer/internal/models"
 	"github.com/desponda/inbox-whisperer/internal/service"
 	"github.com/desponda/inbox-whisperer/internal/session"
+	"github.com/desponda/inbox-whisperer/internal/testutils"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
@@ -37,29 +38,21 @@ func TestEmailAPI_Integration_Unauthenticated(t *testing.T) {
 }
 
 func TestEmailAPI_Integration_MissingToken(t *testing.T) {
-	userTokens := &mockNoTokenUserTokenRepository{}
+	userTokens := &mocks.MockUserTokenRepository{
+		GetUserTokenFunc: func(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
+			return nil, data.ErrNotFound
+		},
+	}
 	r := setupTestRouterWithEmail(userTokens, &mocks.MockEmailService{})
 	w := httptest.NewRecorder()
-	req := httptest.NewRequest("GET", "/api/email/messages", nil)
-	ctx := context.WithValue(req.Context(), api.ContextUserIDKey, "user1")
-	req = req.WithContext(ctx)
-	r.ServeHTTP(w, req)
+	r.ServeHTTP(w, testutils.NewAuthedRequest("GET", "/api/email/messages", nil, testutils.WithToken(nil)))
 	resp := w.Result()
 	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
 }
 
-type mockNoTokenUserTokenRepository struct{}
-
-func (m *mockNoTokenUserTokenRepository) GetUserToken(ctx context.Context, userID string) (*oauth2.Token, error) {
-	return nil, nil
-}
-func (m *mockNoTokenUserTokenRepository) SaveUserToken(ctx context.Context, userID string, token *oauth2.Token) error {
-	return nil
-}
-
 func TestEmailAPI_Integration_Success(t *testing.T) {
 	userTokens := &mocks.MockUserTokenRepository{
-		GetUserTokenFunc: func(ctx context.Context, userID string) (*oauth2.Token, error) {
+		GetUserTokenFunc: func(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
 			if userID == "user1" {
 				return &oauth2.Token{AccessToken: "mock-token"}, nil
 			}
@@ -2,16 +2,21 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
 	"github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
 
 	"github.com/desponda/inbox-whisperer/internal/mocks"
 	"github.com/desponda/inbox-whisperer/internal/models"
-	"github.com/go-chi/chi/v5"
+	"github.com/desponda/inbox-whisperer/internal/testutils"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
 )
@@ -46,10 +51,7 @@ func TestFetchMessagesHandler_Authenticated_Success(t *testing.T) {
 	}
 	h := NewEmailHandler(mockSvc, &mocks.MockUserTokenRepository{})
 
-	r := httptest.NewRequest("GET", "/api/email/fetch", nil)
-	ctx := context.WithValue(r.Context(), ContextUserIDKey, "user1")
-	ctx = context.WithValue(ctx, ContextTokenKey, &oauth2.Token{AccessToken: "test-token"})
-	r = r.WithContext(ctx)
+	r := testutils.NewAuthedRequest("GET", "/api/email/fetch", nil)
 	w := httptest.NewRecorder()
 
 	h.FetchMessagesHandler(w, r)
@@ -66,10 +68,7 @@ func TestFetchMessagesHandler_ServiceError(t *testing.T) {
 	}
 	h := NewEmailHandler(mockSvc, &mocks.MockUserTokenRepository{})
 
-	r := httptest.NewRequest("GET", "/api/email/fetch", nil)
-	ctx := context.WithValue(r.Context(), ContextUserIDKey, "user1")
-	ctx = context.WithValue(ctx, ContextTokenKey, &oauth2.Token{AccessToken: "test-token"})
-	r = r.WithContext(ctx)
+	r := testutils.NewAuthedRequest("GET", "/api/email/fetch", nil)
 	w := httptest.NewRecorder()
 
 	h.FetchMessagesHandler(w, r)
@@ -78,6 +77,126 @@ func TestFetchMessagesHandler_ServiceError(t *testing.T) {
 	require.Equal(t, http.StatusNotFound, resp.StatusCode)
 }
 
+func TestFetchMessagesHandler_ColdCache(t *testing.T) {
+	mockSvc := &mocks.MockEmailService{
+		FetchMessagesFunc: func(ctx context.Context, token *oauth2.Token) ([]models.EmailMessage, error) {
+			return nil, &provider.Error{Kind: provider.ErrSyncing, RetryAfter: 5 * time.Second}
+		},
+	}
+	h := NewEmailHandler(mockSvc, &mocks.MockUserTokenRepository{})
+
+	r := testutils.NewAuthedRequest("GET", "/api/email/fetch", nil)
+	w := httptest.NewRecorder()
+
+	h.FetchMessagesHandler(w, r)
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Equal(t, "5", w.Header().Get("Retry-After"))
+	require.JSONEq(t, `{"syncing":true,"messages":[],"retry_after_seconds":5}`, w.Body.String())
+}
+
+func TestFetchMessagesHandler_PassthroughPageToken(t *testing.T) {
+	mockSvc := &mocks.MockEmailService{
+		FetchMessagesFunc: func(ctx context.Context, token *oauth2.Token) ([]models.EmailMessage, error) {
+			require.Equal(t, "p2", ctxkeys.PageToken(ctx))
+			ctxkeys.SetNextPageToken(ctx, "p3")
+			return []models.EmailMessage{{EmailMessageID: "m1"}}, nil
+		},
+	}
+	h := NewEmailHandler(mockSvc, &mocks.MockUserTokenRepository{})
+
+	r := testutils.NewAuthedRequest("GET", "/api/email/messages?page_token=p2", nil)
+	w := httptest.NewRecorder()
+
+	h.FetchMessagesHandler(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "p3", w.Header().Get("X-Next-Page-Token"))
+}
+
+type stubMessageCounter struct{ messages, threads int64 }
+
+func (s stubMessageCounter) CountMessages(ctx context.Context, userID string) (int64, error) {
+	return s.messages, nil
+}
+
+func (s stubMessageCounter) CountThreads(ctx context.Context, userID string) (int64, error) {
+	return s.threads, nil
+}
+
+func TestFetchMessagesHandler_Envelope(t *testing.T) {
+	var gotCursor ctxkeys.Cursor
+	mockSvc := &mocks.MockEmailService{
+		FetchMessagesFunc: func(ctx context.Context, token *oauth2.Token) ([]models.EmailMessage, error) {
+			gotCursor = ctxkeys.CursorFrom(ctx)
+			require.Equal(t, 2, ctxkeys.Limit(ctx))
+			return []models.EmailMessage{{EmailMessageID: "m1", InternalDate: 300}, {EmailMessageID: "m2", InternalDate: 200}}, nil
+		},
+	}
+	h := NewEmailHandler(mockSvc, &mocks.MockUserTokenRepository{})
+	h.Counter = stubMessageCounter{messages: 5}
+	fetch := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.FetchMessagesHandler(w, testutils.NewAuthedRequest("GET", "/api/emails/messages"+query, nil))
+		return w
+	}
+
+	w := fetch("?envelope=true&limit=2")
+	require.Equal(t, http.StatusOK, w.Code)
+	var page struct {
+		Items         []models.EmailMessage `json:"items"`
+		NextCursor    string                `json:"next_cursor"`
+		Limit         int                   `json:"limit"`
+		TotalEstimate *int64                `json:"total_estimate"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+	require.Len(t, page.Items, 2)
+	require.Equal(t, 2, page.Limit)
+	require.NotNil(t, page.TotalEstimate)
+	require.Equal(t, int64(5), *page.TotalEstimate)
+
+	// The cursor continues after the last message
+	require.Equal(t, http.StatusOK, fetch("?limit=2&cursor="+page.NextCursor).Code)
+	require.Equal(t, ctxkeys.Cursor{AfterInternalDate: 200, AfterID: "m2"}, gotCursor)
+
+	require.Equal(t, http.StatusBadRequest, fetch("?limit=2&cursor=bogus").Code)
+	require.Equal(t, http.StatusBadRequest, fetch("?limit=2&cursor="+page.NextCursor+"&after_id=m1").Code)
+	require.Equal(t, http.StatusBadRequest, fetch("?limit=2&envelope=maybe").Code)
+	require.Equal(t, http.StatusBadRequest, fetch("?limit=500").Code)
+}
+
+func TestFetchMessagesHandler_EnvelopePassthrough(t *testing.T) {
+	mockSvc := &mocks.MockEmailService{
+		FetchMessagesFunc: func(ctx context.Context, token *oauth2.Token) ([]models.EmailMessage, error) {
+			if ctxkeys.PageToken(ctx) == "p2" {
+				ctxkeys.SetNextPageToken(ctx, "")
+				return nil, nil
+			}
+			ctxkeys.SetNextPageToken(ctx, "p2")
+			return []models.EmailMessage{{EmailMessageID: "m1"}}, nil
+		},
+	}
+	h := NewEmailHandler(mockSvc, &mocks.MockUserTokenRepository{})
+	h.Counter = stubMessageCounter{messages: 5}
+
+	w := httptest.NewRecorder()
+	h.FetchMessagesHandler(w, testutils.NewAuthedRequest("GET", "/api/emails/messages?envelope=true", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	next := Cursor{PageToken: "p2"}.Encode()
+	require.JSONEq(t, `{"items":[`+messageJSON(t, models.EmailMessage{EmailMessageID: "m1"})+`],"next_cursor":"`+next+`","limit":10}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.FetchMessagesHandler(w, testutils.NewAuthedRequest("GET", "/api/emails/messages?envelope=true&cursor="+next, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"items":[],"limit":10}`, w.Body.String())
+}
+
+func messageJSON(t *testing.T, m models.EmailMessage) string {
+	b, err := json.Marshal(m)
+	require.NoError(t, err)
+	return string(b)
+}
+
 func TestGetMessageContentHandler_Authenticated_Success(t *testing.T) {
 	mockSvc := &mocks.MockEmailService{
 		FetchMessageContentFunc: func(ctx context.Context, token *oauth2.Token, id string) (*models.EmailMessage, error) {
@@ -86,43 +205,94 @@ func TestGetMessageContentHandler_Authenticated_Success(t *testing.T) {
 	}
 	h := NewEmailHandler(mockSvc, &mocks.MockUserTokenRepository{})
 
-	r := httptest.NewRequest("GET", "/api/email/messages/1", nil)
-	ctx := context.WithValue(r.Context(), ContextUserIDKey, "user1")
-	ctx = context.WithValue(ctx, ContextTokenKey, &oauth2.Token{AccessToken: "test-token"})
-	r = r.WithContext(ctx)
+	r := testutils.NewAuthedRequest("GET", "/api/email/messages/1", nil, testutils.WithURLParam("id", "1"))
 	w := httptest.NewRecorder()
 
-	// Simulate chi URL param
-	chiCtx := chi.NewRouteContext()
-	chiCtx.URLParams.Add("id", "1")
-	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, chiCtx))
-
 	h.GetMessageContentHandler(w, r)
 
 	resp := w.Result()
 	require.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestGetMessageContentHandler_Format(t *testing.T) {
+	mockSvc := &mocks.MockEmailService{
+		FetchMessageContentFunc: func(ctx context.Context, token *oauth2.Token, id string) (*models.EmailMessage, error) {
+			return &models.EmailMessage{ID: 1, Subject: "Test", HTMLBody: `<p>Hi <a href="https://example.com">there</a></p>`}, nil
+		},
+	}
+	h := NewEmailHandler(mockSvc, &mocks.MockUserTokenRepository{})
+	tests := []struct {
+		format, wantType, wantBody string
+		wantStatus                 int
+	}{
+		{"plain", "text/plain; charset=utf-8", "Hi there (https://example.com)", http.StatusOK},
+		{"markdown", "text/markdown; charset=utf-8", "Hi [there](https://example.com)", http.StatusOK},
+		{"html", "text/html; charset=utf-8", `<p>Hi <a href="https://example.com" rel="noopener noreferrer">there</a></p>`, http.StatusOK},
+		{"pdf", "application/json", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			r := testutils.NewAuthedRequest("GET", "/api/emails/messages/1?format="+tt.format, nil, testutils.WithURLParam("id", "1"))
+			w := httptest.NewRecorder()
+
+			h.GetMessageContentHandler(w, r)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			require.Equal(t, tt.wantType, w.Header().Get("Content-Type"))
+			if tt.wantStatus == http.StatusOK {
+				require.Equal(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestGetMessageContentHandler_ServiceError(t *testing.T) {
 	mockSvc := &mocks.MockEmailService{
 		FetchMessageContentFunc: func(ctx context.Context, token *oauth2.Token, id string) (*models.EmailMessage, error) {
-			return nil, fmt.Errorf("not found")
+			return nil, gmail.ErrNotFound
 		},
 	}
 	h := NewEmailHandler(mockSvc, &mocks.MockUserTokenRepository{})
 
-	r := httptest.NewRequest("GET", "/api/email/messages/1", nil)
-	ctx := context.WithValue(r.Context(), ContextUserIDKey, "user1")
-	ctx = context.WithValue(ctx, ContextTokenKey, &oauth2.Token{AccessToken: "test-token"})
-	r = r.WithContext(ctx)
+	r := testutils.NewAuthedRequest("GET", "/api/email/messages/1", nil, testutils.WithURLParam("id", "1"))
 	w := httptest.NewRecorder()
 
-	chiCtx := chi.NewRouteContext()
-	chiCtx.URLParams.Add("id", "1")
-	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, chiCtx))
-
 	h.GetMessageContentHandler(w, r)
 
 	resp := w.Result()
 	require.Equal(t, http.StatusNotFound, resp.StatusCode)
 }
+
+func TestGetMessageContentHandler_ProviderErrors(t *testing.T) {
+	native := errors.New("googleapi: Error")
+	tests := []struct {
+		name           string
+		err            error
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{"not found", fmt.Errorf("fetch: %w", gmail.ErrNotFound), http.StatusNotFound, ""},
+		{"auth expired", &provider.Error{Kind: provider.ErrAuthExpired, Err: native}, http.StatusUnauthorized, ""},
+		{"rate limited", &provider.Error{Kind: provider.ErrRateLimited, Err: native, RetryAfter: 30 * time.Second}, http.StatusTooManyRequests, "30"},
+		{"temporary", &provider.Error{Kind: provider.ErrTemporary, Err: native}, http.StatusServiceUnavailable, ""},
+		{"unclassified", native, http.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &mocks.MockEmailService{
+				FetchMessageContentFunc: func(ctx context.Context, token *oauth2.Token, id string) (*models.EmailMessage, error) {
+					return nil, tt.err
+				},
+			}
+			h := NewEmailHandler(mockSvc, &mocks.MockUserTokenRepository{})
+
+			r := testutils.NewAuthedRequest("GET", "/api/email/messages/1", nil, testutils.WithURLParam("id", "1"))
+			w := httptest.NewRecorder()
+
+			h.GetMessageContentHandler(w, r)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			require.Equal(t, tt.wantRetryAfter, w.Header().Get("Retry-After"))
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"crypto/ecdh"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/e2ee"
+	"github.com/desponda/inbox-whisperer/internal/legalhold"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/session"
+)
+
+// Error codes returned by the encryption endpoints
+const (
+	ErrCodeWrongPassphrase = "wrong_passphrase"
+	ErrCodeWeakPassphrase  = "weak_passphrase"
+)
+
+// EncryptionRequest is the body of the encryption endpoints that take a passphrase
+type EncryptionRequest struct {
+	Passphrase string `json:"passphrase"`
+}
+
+// EncryptionStatus is the body of GET /api/users/me/encryption
+type EncryptionStatus struct {
+	Enabled bool `json:"enabled"`
+	// Unlocked is true while this session holds the key, so cached bodies can be read
+	Unlocked bool `json:"unlocked"`
+	*models.EncryptionKey
+}
+
+// EncryptionHandler manages end-to-end encryption of a user's cached message bodies, see
+// package e2ee
+type EncryptionHandler struct {
+	Keys data.EncryptionKeyRepository
+	// Messages, if set, has the cache purged when encryption is turned on, so no plaintext stays
+	// behind, and the sealed bodies dropped when it is turned off
+	Messages data.EmailMessageRepository
+	// Holds keeps encryption from being turned on or off for users under legal hold, as either
+	// would drop their cached mail
+	Holds *legalhold.Service
+}
+
+func NewEncryptionHandler(keys data.EncryptionKeyRepository) *EncryptionHandler {
+	return &EncryptionHandler{Keys: keys}
+}
+
+// EncryptionKeyMiddleware puts the cache key unlocked in the session on the request context. It
+// runs after the session middleware.
+func EncryptionKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if raw := session.GetEncryptionKey(r); raw != nil {
+			if key, err := e2ee.PrivateKey(raw); err == nil {
+				r = r.WithContext(ctxkeys.WithEncryptionKey(r.Context(), key))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetStatus handles GET /api/users/me/encryption
+func (h *EncryptionHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	key, err := h.Keys.Get(r.Context(), userID)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondJSON(w, http.StatusOK, EncryptionStatus{})
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load encryption status")
+		return
+	}
+	RespondJSON(w, http.StatusOK, EncryptionStatus{
+		Enabled:       true,
+		Unlocked:      unlockedFor(r, key),
+		EncryptionKey: key,
+	})
+}
+
+// Enable handles POST /api/users/me/encryption. It creates the key from the passphrase, purges
+// what was cached in the clear so far (the next sync refills it) and unlocks this session.
+func (h *EncryptionHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	userID, req, ok := h.passphraseRequest(w, r)
+	if !ok {
+		return
+	}
+	if h.Messages != nil && !guardDeletion(w, r, h.Holds, userID, legalhold.ActionPurgeCache) {
+		return
+	}
+	salt, public, private, err := e2ee.NewKey(req.Passphrase)
+	if errors.Is(err, e2ee.ErrWeakPassphrase) {
+		RespondErrorCode(w, http.StatusBadRequest, ErrCodeWeakPassphrase, err.Error())
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to create encryption key")
+		return
+	}
+	key := &models.EncryptionKey{UserID: userID, Salt: salt, PublicKey: public}
+	if err := h.Keys.Create(r.Context(), key); errors.Is(err, data.ErrAlreadyExists) {
+		RespondError(w, http.StatusConflict, "encryption is already enabled")
+		return
+	} else if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to save encryption key")
+		return
+	}
+	if h.Messages != nil {
+		if err := h.Messages.DeleteMessagesForUser(r.Context(), userID); err != nil {
+			RespondError(w, http.StatusInternalServerError, "failed to purge cached messages")
+			return
+		}
+	}
+	session.SetEncryptionKey(w, r, private.Bytes())
+	RespondJSON(w, http.StatusCreated, EncryptionStatus{Enabled: true, Unlocked: true, EncryptionKey: key})
+}
+
+// Unlock handles POST /api/users/me/encryption/unlock
+func (h *EncryptionHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	userID, req, ok := h.passphraseRequest(w, r)
+	if !ok {
+		return
+	}
+	key, private, ok := h.checkPassphrase(w, r, userID, req.Passphrase)
+	if !ok {
+		return
+	}
+	session.SetEncryptionKey(w, r, private.Bytes())
+	RespondJSON(w, http.StatusOK, EncryptionStatus{Enabled: true, Unlocked: true, EncryptionKey: key})
+}
+
+// Lock handles POST /api/users/me/encryption/lock; signing out locks the cache as well
+func (h *EncryptionHandler) Lock(w http.ResponseWriter, r *http.Request) {
+	if ctxkeys.UserID(r.Context()) == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	session.ClearEncryptionKey(w, r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Disable handles DELETE /api/users/me/encryption. The passphrase is required so a stolen
+// session cannot turn encryption off; the sealed bodies are dropped with the key.
+func (h *EncryptionHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	userID, req, ok := h.passphraseRequest(w, r)
+	if !ok {
+		return
+	}
+	if _, _, ok := h.checkPassphrase(w, r, userID, req.Passphrase); !ok {
+		return
+	}
+	if h.Messages != nil && !guardDeletion(w, r, h.Holds, userID, legalhold.ActionClearContent) {
+		return
+	}
+	if err := h.Keys.Delete(r.Context(), userID); err != nil && !errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusInternalServerError, "failed to delete encryption key")
+		return
+	}
+	if h.Messages != nil {
+		if err := h.Messages.ClearMessageContent(r.Context(), userID); err != nil {
+			RespondError(w, http.StatusInternalServerError, "failed to purge cached message bodies")
+			return
+		}
+	}
+	session.ClearEncryptionKey(w, r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// passphraseRequest authenticates the request and decodes its passphrase. The key is kept in
+// the browser session, so API key requests, which have none, are refused.
+func (h *EncryptionHandler) passphraseRequest(w http.ResponseWriter, r *http.Request) (string, *EncryptionRequest, bool) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return "", nil, false
+	}
+	if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		RespondError(w, http.StatusBadRequest, "encryption keys can only be managed from a browser session")
+		return "", nil, false
+	}
+	var req EncryptionRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return "", nil, false
+	}
+	return userID, &req, true
+}
+
+// checkPassphrase loads the user's key and derives its private half from passphrase
+func (h *EncryptionHandler) checkPassphrase(w http.ResponseWriter, r *http.Request, userID, passphrase string) (*models.EncryptionKey, *ecdh.PrivateKey, bool) {
+	key, err := h.Keys.Get(r.Context(), userID)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "encryption is not enabled")
+		return nil, nil, false
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load encryption key")
+		return nil, nil, false
+	}
+	private, err := e2ee.Unlock(passphrase, key.Salt, key.PublicKey)
+	if errors.Is(err, e2ee.ErrWrongPassphrase) {
+		RespondErrorCode(w, http.StatusForbidden, ErrCodeWrongPassphrase, "wrong passphrase")
+		return nil, nil, false
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to unlock encryption key")
+		return nil, nil, false
+	}
+	return key, private, true
+}
+
+// unlockedFor reports whether the request's session holds the private half of key
+func unlockedFor(r *http.Request, key *models.EncryptionKey) bool {
+	private := ctxkeys.EncryptionKey(r.Context())
+	return private != nil && string(private.PublicKey().Bytes()) == string(key.PublicKey)
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/session"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryEncryptionKeys struct {
+	keys map[string]*models.EncryptionKey
+}
+
+func (m *memoryEncryptionKeys) Get(ctx context.Context, userID string) (*models.EncryptionKey, error) {
+	if k, ok := m.keys[userID]; ok {
+		return k, nil
+	}
+	return nil, data.ErrNotFound
+}
+
+func (m *memoryEncryptionKeys) Create(ctx context.Context, k *models.EncryptionKey) error {
+	if _, ok := m.keys[k.UserID]; ok {
+		return data.ErrAlreadyExists
+	}
+	m.keys[k.UserID] = k
+	return nil
+}
+
+func (m *memoryEncryptionKeys) Delete(ctx context.Context, userID string) error {
+	if _, ok := m.keys[userID]; !ok {
+		return data.ErrNotFound
+	}
+	delete(m.keys, userID)
+	return nil
+}
+
+func TestEncryptionHandler_Lifecycle(t *testing.T) {
+	keys := &memoryEncryptionKeys{keys: map[string]*models.EncryptionKey{}}
+	messages := &purgeRecordingRepo{}
+	h := NewEncryptionHandler(keys)
+	h.Messages = messages
+
+	r := chi.NewRouter()
+	r.Use(session.Middleware)
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(ctxkeys.WithUserID(r.Context(), "user1")))
+		})
+	})
+	r.Use(EncryptionKeyMiddleware)
+	r.Get("/api/users/me/encryption", h.GetStatus)
+	r.Post("/api/users/me/encryption", h.Enable)
+	r.Delete("/api/users/me/encryption", h.Disable)
+	r.Post("/api/users/me/encryption/unlock", h.Unlock)
+	r.Post("/api/users/me/encryption/lock", h.Lock)
+
+	var cookie *http.Cookie
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "session_id" {
+				cookie = c
+			}
+		}
+		return w
+	}
+	status := func() EncryptionStatus {
+		w := do("GET", "/api/users/me/encryption", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var s EncryptionStatus
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&s))
+		return s
+	}
+
+	require.False(t, status().Enabled)
+	w := do("POST", "/api/users/me/encryption", `{"passphrase":"short"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), ErrCodeWeakPassphrase)
+
+	w = do("POST", "/api/users/me/encryption", `{"passphrase":"correct horse battery"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, []string{"user1"}, messages.purged, "plaintext cached before enabling is purged")
+	s := status()
+	require.True(t, s.Enabled)
+	require.True(t, s.Unlocked, "enabling unlocks the session")
+	require.Equal(t, http.StatusConflict, do("POST", "/api/users/me/encryption", `{"passphrase":"correct horse battery"}`).Code)
+
+	require.Equal(t, http.StatusNoContent, do("POST", "/api/users/me/encryption/lock", "").Code)
+	require.False(t, status().Unlocked)
+	w = do("POST", "/api/users/me/encryption/unlock", `{"passphrase":"wrong horse battery"}`)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Contains(t, w.Body.String(), ErrCodeWrongPassphrase)
+	require.Equal(t, http.StatusOK, do("POST", "/api/users/me/encryption/unlock", `{"passphrase":"correct horse battery"}`).Code)
+	require.True(t, status().Unlocked)
+
+	require.Equal(t, http.StatusForbidden, do("DELETE", "/api/users/me/encryption", `{"passphrase":"wrong horse battery"}`).Code)
+	require.Equal(t, http.StatusNoContent, do("DELETE", "/api/users/me/encryption", `{"passphrase":"correct horse battery"}`).Code)
+	require.Equal(t, []string{"user1"}, messages.cleared, "sealed bodies are dropped with the key")
+	require.Equal(t, EncryptionStatus{}, status())
+}
+
+func TestEncryptionHandler_RefusesAPIKeys(t *testing.T) {
+	h := NewEncryptionHandler(&memoryEncryptionKeys{keys: map[string]*models.EncryptionKey{}})
+	req := httptest.NewRequest("POST", "/api/users/me/encryption", strings.NewReader(`{"passphrase":"correct horse battery"}`))
+	req.Header.Set("Authorization", "Bearer iw_secret")
+	w := httptest.NewRecorder()
+	h.Enable(w, req.WithContext(ctxkeys.WithUserID(req.Context(), "user1")))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
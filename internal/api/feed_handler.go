@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/feeds"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrCodeFeedExists is returned when the user already has a feed for the sender
+const ErrCodeFeedExists = "feed_exists"
+
+// CreateFeedRequest is the body of POST /api/users/me/feeds
+type CreateFeedRequest struct {
+	Sender string `json:"sender"`
+}
+
+// CreatedFeed is a new feed together with its URL path, which holds the token and is never shown
+// again. Feed readers need it prefixed with the API's origin.
+type CreatedFeed struct {
+	*models.Feed
+	Path string `json:"path"`
+}
+
+type FeedHandler struct {
+	Feeds *feeds.Service
+}
+
+func NewFeedHandler(svc *feeds.Service) *FeedHandler {
+	return &FeedHandler{Feeds: svc}
+}
+
+// ListFeeds handles GET /api/users/me/feeds
+func (h *FeedHandler) ListFeeds(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	list, err := h.Feeds.List(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load feeds")
+		return
+	}
+	if list == nil {
+		list = []*models.Feed{}
+	}
+	RespondJSON(w, http.StatusOK, list)
+}
+
+// CreateFeed handles POST /api/users/me/feeds
+func (h *FeedHandler) CreateFeed(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	var req CreateFeedRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	feed, token, err := h.Feeds.Create(r.Context(), userID, req.Sender)
+	switch {
+	case errors.Is(err, feeds.ErrInvalidSender):
+		RespondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, data.ErrAlreadyExists):
+		RespondErrorCode(w, http.StatusConflict, ErrCodeFeedExists, "a feed for this sender already exists")
+	case err != nil:
+		RespondError(w, http.StatusInternalServerError, "failed to create feed")
+	default:
+		RespondJSON(w, http.StatusCreated, CreatedFeed{Feed: feed, Path: "/feeds/" + token + ".xml"})
+	}
+}
+
+// DeleteFeed handles DELETE /api/users/me/feeds/{id}
+func (h *FeedHandler) DeleteFeed(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	idParam, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid feed id")
+		return
+	}
+	if err := h.Feeds.Delete(r.Context(), userID, id); err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			RespondError(w, http.StatusNotFound, "feed not found")
+			return
+		}
+		RespondError(w, http.StatusInternalServerError, "failed to delete feed")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ServeFeed handles GET /feeds/{token}.xml. It needs no session: the token in the URL is the
+// feed's only credential, so unknown tokens get a plain 404.
+func (h *FeedHandler) ServeFeed(w http.ResponseWriter, r *http.Request) {
+	feed, err := h.Feeds.Content(r.Context(), chi.URLParam(r, "token"))
+	if errors.Is(err, feeds.ErrInvalidToken) {
+		RespondError(w, http.StatusNotFound, "feed not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("feeds: failed to load feed")
+		RespondError(w, http.StatusInternalServerError, "failed to load feed")
+		return
+	}
+	var modified time.Time
+	if feed.GeneratedAt != nil {
+		modified = *feed.GeneratedAt
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-transform")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	http.ServeContent(w, r, "", modified, bytes.NewReader(feed.Content))
+}
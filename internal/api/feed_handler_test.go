@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/feeds"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// stubFeedRepo stores feeds in memory; every sender has one cached message
+type stubFeedRepo struct {
+	feeds map[string]*models.Feed
+}
+
+func (s *stubFeedRepo) Create(ctx context.Context, feed *models.Feed, tokenHash string) error {
+	for _, f := range s.feeds {
+		if f.UserID == feed.UserID && f.SenderAddress == feed.SenderAddress {
+			return data.ErrAlreadyExists
+		}
+	}
+	feed.ID = int64(len(s.feeds) + 1)
+	s.feeds[tokenHash] = feed
+	return nil
+}
+func (s *stubFeedRepo) ListByUser(ctx context.Context, userID string) ([]*models.Feed, error) {
+	return nil, nil
+}
+func (s *stubFeedRepo) Delete(ctx context.Context, userID string, id int64) error {
+	return data.ErrNotFound
+}
+func (s *stubFeedRepo) GetByToken(ctx context.Context, tokenHash string) (*models.Feed, error) {
+	if f, ok := s.feeds[tokenHash]; ok {
+		return f, nil
+	}
+	return nil, data.ErrNotFound
+}
+func (s *stubFeedRepo) SaveContent(ctx context.Context, id int64, content []byte, generatedAt time.Time) error {
+	return nil
+}
+func (s *stubFeedRepo) SenderMessages(ctx context.Context, userID, senderAddress string, limit int) ([]*models.EmailMessage, error) {
+	return []*models.EmailMessage{{EmailMessageID: "m1", SenderAddress: senderAddress, Subject: "Weekly issue"}}, nil
+}
+
+func TestFeedHandler(t *testing.T) {
+	h := NewFeedHandler(feeds.NewService(&stubFeedRepo{feeds: map[string]*models.Feed{}}))
+	r := chi.NewRouter()
+	r.Get("/feeds/{token}.xml", h.ServeFeed)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/users/me/feeds", strings.NewReader(body))
+		rw := httptest.NewRecorder()
+		h.CreateFeed(rw, req.WithContext(ctxkeys.WithUserID(req.Context(), "user1")))
+		return rw
+	}
+
+	rw := create(`{"sender":"nope"}`)
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = create(`{"sender":"news@example.com"}`)
+	require.Equal(t, http.StatusCreated, rw.Code)
+	var created CreatedFeed
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&created))
+	require.True(t, strings.HasPrefix(created.Path, "/feeds/"))
+
+	rw = create(`{"sender":"news@example.com"}`)
+	require.Equal(t, http.StatusConflict, rw.Code)
+	require.Contains(t, rw.Body.String(), ErrCodeFeedExists)
+
+	rw = httptest.NewRecorder()
+	r.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, created.Path, nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, "application/atom+xml; charset=utf-8", rw.Header().Get("Content-Type"))
+	require.Contains(t, rw.Body.String(), "Weekly issue")
+	require.NotEmpty(t, rw.Header().Get("Last-Modified"))
+
+	rw = httptest.NewRecorder()
+	r.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/feeds/unknown.xml", nil))
+	require.Equal(t, http.StatusNotFound, rw.Code)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/feedback"
+)
+
+// CategoryFeedbackRequest is the body of POST /api/emails/{id}/category
+type CategoryFeedbackRequest struct {
+	// Category is the correct category; required when Correct is false
+	Category string `json:"category"`
+	Correct  *bool  `json:"correct"`
+}
+
+type FeedbackHandler struct {
+	Feedback *feedback.Service
+}
+
+func NewFeedbackHandler(svc *feedback.Service) *FeedbackHandler {
+	return &FeedbackHandler{Feedback: svc}
+}
+
+// SubmitCategoryFeedback handles POST /api/emails/{id}/category
+func (h *FeedbackHandler) SubmitCategoryFeedback(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	id, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req CategoryFeedbackRequest
+	if err := DecodeJSON(r, &req); err != nil || req.Correct == nil {
+		RespondBodyError(w, err, "invalid request body: correct is required")
+		return
+	}
+	res, err := h.Feedback.Submit(r.Context(), userID, id, req.Category, *req.Correct)
+	switch {
+	case errors.Is(err, feedback.ErrInvalidFeedback):
+		RespondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, data.ErrNotFound):
+		RespondError(w, http.StatusNotFound, "message not found")
+	case err != nil:
+		RespondError(w, http.StatusInternalServerError, "failed to record feedback")
+	default:
+		RespondJSON(w, http.StatusOK, res)
+	}
+}
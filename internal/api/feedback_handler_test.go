@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/feedback"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+type stubFeedbackRepo struct{}
+
+func (stubFeedbackRepo) RecordFeedback(ctx context.Context, fb *models.CategoryFeedback) error {
+	if fb.MessageID == "missing" {
+		return data.ErrNotFound
+	}
+	fb.ID = 1
+	fb.Sender = "alerts@bank.example"
+	return nil
+}
+
+func (stubFeedbackRepo) CountCorrections(ctx context.Context, userID, sender, category string) (int, error) {
+	return 0, nil
+}
+
+func feedbackRequest(id, body string) *http.Request {
+	r := httptest.NewRequest("POST", "/api/emails/"+id+"/category", strings.NewReader(body))
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add("id", id)
+	ctx := context.WithValue(r.Context(), chi.RouteCtxKey, chiCtx)
+	ctx = ctxkeys.WithUserID(ctx, "user1")
+	return r.WithContext(ctx)
+}
+
+func TestSubmitCategoryFeedback(t *testing.T) {
+	h := NewFeedbackHandler(feedback.NewService(stubFeedbackRepo{}, &stubRuleRepo{}))
+
+	w := httptest.NewRecorder()
+	h.SubmitCategoryFeedback(w, feedbackRequest("m1", `{"category":"Finance","correct":false}`))
+	require.Equal(t, http.StatusOK, w.Code)
+	var res models.CategoryFeedbackResult
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	require.Equal(t, "Finance", res.Feedback.Category)
+	require.Equal(t, "alerts@bank.example", res.Feedback.Sender)
+
+	w = httptest.NewRecorder()
+	h.SubmitCategoryFeedback(w, feedbackRequest("m1", `{"category":"Finance"}`))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.SubmitCategoryFeedback(w, feedbackRequest("m1", `{"correct":false}`))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.SubmitCategoryFeedback(w, feedbackRequest("missing", `{"category":"Finance","correct":false}`))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/desponda/inbox-whisperer/internal/gmailpush"
+	"github.com/rs/zerolog/log"
+)
+
+// PushReceiver syncs the mailboxes Gmail push notifications are about (see gmailpush.Service)
+type PushReceiver interface {
+	HandlePush(ctx context.Context, n *gmailpush.Notification) error
+}
+
+type GmailPushHandler struct {
+	Service PushReceiver
+	// Token is the shared secret the Pub/Sub push subscription sends in its endpoint's query
+	Token string
+}
+
+func NewGmailPushHandler(service PushReceiver, token string) *GmailPushHandler {
+	return &GmailPushHandler{Service: service, Token: token}
+}
+
+// Receive handles POST /api/webhooks/gmail?token=: a Pub/Sub push of a Gmail mailbox change.
+// Pushes for accounts without a watch are acknowledged so Pub/Sub does not redeliver them.
+func (h *GmailPushHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if h.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) != 1 {
+		RespondError(w, http.StatusUnauthorized, "invalid push token")
+		return
+	}
+	// Pub/Sub adds fields of its own to the envelope, so unknown fields are allowed here
+	var push gmailpush.Push
+	if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+		RespondBodyError(w, err, "invalid push body")
+		return
+	}
+	n, err := push.Decode()
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = h.Service.HandlePush(r.Context(), n)
+	if errors.Is(err, gmailpush.ErrUnknownAccount) {
+		log.Debug().Str("messageID", push.Message.MessageID).Msg("gmail push for an account without a watch")
+		err = nil
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to handle push")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
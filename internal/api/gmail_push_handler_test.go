@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/gmailpush"
+	"github.com/stretchr/testify/require"
+)
+
+type stubPushReceiver struct {
+	pushed []string
+}
+
+func (s *stubPushReceiver) HandlePush(ctx context.Context, n *gmailpush.Notification) error {
+	if n.EmailAddress == "unknown@gmail.com" {
+		return gmailpush.ErrUnknownAccount
+	}
+	s.pushed = append(s.pushed, n.EmailAddress)
+	return nil
+}
+
+func pushBody(notification string) string {
+	data := base64.StdEncoding.EncodeToString([]byte(notification))
+	return `{"message":{"data":"` + data + `","messageId":"1","publishTime":"2026-10-15T12:00:00Z"},"subscription":"projects/p/subscriptions/gmail"}`
+}
+
+func TestGmailPushHandler_Receive(t *testing.T) {
+	svc := &stubPushReceiver{}
+	h := NewGmailPushHandler(svc, "secret")
+	post := func(token, body string) int {
+		rw := httptest.NewRecorder()
+		h.Receive(rw, httptest.NewRequest(http.MethodPost, "/api/webhooks/gmail?token="+token, strings.NewReader(body)))
+		return rw.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, post("wrong", pushBody(`{"emailAddress":"a@gmail.com","historyId":1}`)))
+	require.Equal(t, http.StatusBadRequest, post("secret", `{"message":{"data":"bm90IGpzb24="}}`))
+	require.Equal(t, http.StatusNoContent, post("secret", pushBody(`{"emailAddress":"a@gmail.com","historyId":1}`)))
+	require.Equal(t, http.StatusNoContent, post("secret", pushBody(`{"emailAddress":"unknown@gmail.com","historyId":1}`)))
+	require.Equal(t, []string{"a@gmail.com"}, svc.pushed)
+}
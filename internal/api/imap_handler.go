@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/notify"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/rs/zerolog/log"
+)
+
+// Error codes returned when linking an IMAP account
+const (
+	ErrCodeIMAPLoginFailed = "imap_login_failed"
+	ErrCodeIMAPUnreachable = "imap_unreachable"
+)
+
+// Default IMAP ports for implicit TLS and STARTTLS
+const (
+	imapTLSPort      = 993
+	imapStartTLSPort = 143
+)
+
+// LinkIMAPRequest is the body of PUT /api/users/me/imap
+type LinkIMAPRequest struct {
+	Host string `json:"host"`
+	// Port defaults to 993 with TLS and 143 without
+	Port int `json:"port"`
+	// TLS defaults to true; false upgrades the connection with STARTTLS instead
+	TLS      *bool  `json:"tls"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// IMAPVerifier checks that an account's settings work before they are saved (see
+// imap.IMAPProvider)
+type IMAPVerifier interface {
+	Verify(ctx context.Context, account *models.IMAPAccount) error
+}
+
+type IMAPHandler struct {
+	Accounts data.IMAPAccountRepository
+	Verifier IMAPVerifier
+}
+
+func NewIMAPHandler(accounts data.IMAPAccountRepository, verifier IMAPVerifier) *IMAPHandler {
+	return &IMAPHandler{Accounts: accounts, Verifier: verifier}
+}
+
+// GetIMAPAccount handles GET /api/users/me/imap
+func (h *IMAPHandler) GetIMAPAccount(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	account, err := h.Accounts.Get(r.Context(), userID)
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		RespondError(w, http.StatusNotFound, "no IMAP account linked")
+	case errors.Is(err, data.ErrCredentialUnreadable):
+		RespondError(w, http.StatusConflict, "the stored IMAP password can no longer be read: link the account again")
+	case err != nil:
+		RespondError(w, http.StatusInternalServerError, "failed to load IMAP account")
+	default:
+		RespondJSON(w, http.StatusOK, account)
+	}
+}
+
+// LinkIMAPAccount handles PUT /api/users/me/imap. The server is logged in to before anything is
+// saved; a failing login is reported and nothing changes.
+func (h *IMAPHandler) LinkIMAPAccount(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	var req LinkIMAPRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	account := &models.IMAPAccount{
+		UserID:   userID,
+		Host:     strings.TrimSpace(req.Host),
+		Port:     req.Port,
+		TLS:      req.TLS == nil || *req.TLS,
+		Username: req.Username,
+		Password: req.Password,
+	}
+	if account.Port == 0 {
+		account.Port = imapStartTLSPort
+		if account.TLS {
+			account.Port = imapTLSPort
+		}
+	}
+	switch {
+	case account.Host == "" || strings.ContainsAny(account.Host, " /"):
+		RespondError(w, http.StatusBadRequest, "host must be a host name or address")
+		return
+	case account.Port < 1 || account.Port > 65535:
+		RespondError(w, http.StatusBadRequest, "port must be between 1 and 65535")
+		return
+	case account.Username == "" || account.Password == "":
+		RespondError(w, http.StatusBadRequest, "username and password are required")
+		return
+	}
+	if err := h.Verifier.Verify(r.Context(), account); err != nil {
+		log.Warn().Err(err).Str("userID", userID).Str("host", account.Host).Msg("IMAP account check failed")
+		if errors.Is(err, provider.ErrAuthExpired) {
+			RespondErrorCode(w, http.StatusBadRequest, ErrCodeIMAPLoginFailed, "the IMAP server rejected the username or password")
+			return
+		}
+		RespondErrorCode(w, http.StatusBadRequest, ErrCodeIMAPUnreachable, "could not read the inbox on the IMAP server: "+err.Error())
+		return
+	}
+	if err := h.Accounts.Save(r.Context(), account); err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to save IMAP account")
+		return
+	}
+	notify.Publish(r.Context(), notify.EventAccountLinked, userID)
+	RespondJSON(w, http.StatusOK, account)
+}
+
+// UnlinkIMAPAccount handles DELETE /api/users/me/imap
+func (h *IMAPHandler) UnlinkIMAPAccount(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	err := h.Accounts.Delete(r.Context(), userID)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "no IMAP account linked")
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to remove IMAP account")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
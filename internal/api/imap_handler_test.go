@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/stretchr/testify/require"
+)
+
+type stubIMAPAccounts struct {
+	account *models.IMAPAccount
+}
+
+func (s *stubIMAPAccounts) Save(ctx context.Context, a *models.IMAPAccount) error {
+	s.account = a
+	return nil
+}
+func (s *stubIMAPAccounts) Get(ctx context.Context, userID string) (*models.IMAPAccount, error) {
+	if s.account == nil {
+		return nil, data.ErrNotFound
+	}
+	return s.account, nil
+}
+func (s *stubIMAPAccounts) Delete(ctx context.Context, userID string) error {
+	if s.account == nil {
+		return data.ErrNotFound
+	}
+	s.account = nil
+	return nil
+}
+
+// stubIMAPVerifier accepts the password "secret" only
+type stubIMAPVerifier struct{}
+
+func (stubIMAPVerifier) Verify(ctx context.Context, a *models.IMAPAccount) error {
+	if a.Password != "secret" {
+		return &provider.Error{Kind: provider.ErrAuthExpired}
+	}
+	return nil
+}
+
+func TestIMAPHandler(t *testing.T) {
+	accounts := &stubIMAPAccounts{}
+	h := NewIMAPHandler(accounts, stubIMAPVerifier{})
+	withUser := func(r *http.Request) *http.Request {
+		return r.WithContext(ctxkeys.WithUserID(r.Context(), "user1"))
+	}
+	put := func(body string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		h.LinkIMAPAccount(rw, withUser(httptest.NewRequest(http.MethodPut, "/api/users/me/imap", strings.NewReader(body))))
+		return rw
+	}
+
+	rw := httptest.NewRecorder()
+	h.GetIMAPAccount(rw, withUser(httptest.NewRequest(http.MethodGet, "/api/users/me/imap", nil)))
+	require.Equal(t, http.StatusNotFound, rw.Code)
+
+	require.Equal(t, http.StatusBadRequest, put(`{"host":"","username":"me","password":"secret"}`).Code)
+	require.Equal(t, http.StatusBadRequest, put(`{"host":"imap.example.com","port":70000,"username":"me","password":"secret"}`).Code)
+
+	rw = put(`{"host":"imap.example.com","username":"me","password":"wrong"}`)
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+	require.Contains(t, rw.Body.String(), ErrCodeIMAPLoginFailed)
+	require.Nil(t, accounts.account)
+
+	rw = put(`{"host":"imap.example.com","tls":false,"username":"me","password":"secret"}`)
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.NotContains(t, rw.Body.String(), "secret")
+	var saved models.IMAPAccount
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&saved))
+	require.Equal(t, 143, saved.Port)
+	require.False(t, saved.TLS)
+
+	rw = httptest.NewRecorder()
+	h.UnlinkIMAPAccount(rw, withUser(httptest.NewRequest(http.MethodDelete, "/api/users/me/imap", nil)))
+	require.Equal(t, http.StatusNoContent, rw.Code)
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/integrations"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// Error codes returned by the integration endpoints
+const (
+	ErrCodeUnknownIntegration       = "unknown_integration"
+	ErrCodeIntegrationNotConfigured = "integration_not_configured"
+)
+
+// recentDeliveries is how many deliveries GET /api/users/me/integrations/deliveries returns
+const recentDeliveries = 50
+
+// IntegrationsResponse is the body of GET /api/users/me/integrations
+type IntegrationsResponse struct {
+	Available  []string              `json:"available"`
+	Configured []*models.Integration `json:"configured"`
+}
+
+// ConfigureIntegrationRequest is the body of PUT /api/users/me/integrations/{integration}
+type ConfigureIntegrationRequest struct {
+	Config map[string]string `json:"config"`
+}
+
+type IntegrationHandler struct {
+	Integrations *integrations.Service
+	Emails       service.EmailService
+}
+
+func NewIntegrationHandler(svc *integrations.Service, emails service.EmailService) *IntegrationHandler {
+	return &IntegrationHandler{Integrations: svc, Emails: emails}
+}
+
+// ListIntegrations handles GET /api/users/me/integrations
+func (h *IntegrationHandler) ListIntegrations(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	configured, err := h.Integrations.Configured(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load integrations")
+		return
+	}
+	if configured == nil {
+		configured = []*models.Integration{}
+	}
+	RespondJSON(w, http.StatusOK, IntegrationsResponse{Available: h.Integrations.Available(), Configured: configured})
+}
+
+// ConfigureIntegration handles PUT /api/users/me/integrations/{integration}
+func (h *IntegrationHandler) ConfigureIntegration(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	var req ConfigureIntegrationRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	integration, err := h.Integrations.Configure(r.Context(), userID, chi.URLParam(r, "integration"), req.Config)
+	var cerr *integrations.ConfigError
+	switch {
+	case errors.As(err, &cerr):
+		RespondError(w, http.StatusBadRequest, "invalid config: "+cerr.Error())
+	case err != nil:
+		writeIntegrationError(w, err)
+	default:
+		RespondJSON(w, http.StatusOK, integration)
+	}
+}
+
+// RemoveIntegration handles DELETE /api/users/me/integrations/{integration}
+func (h *IntegrationHandler) RemoveIntegration(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	if err := h.Integrations.Remove(r.Context(), userID, chi.URLParam(r, "integration")); err != nil {
+		writeIntegrationError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SendTo handles POST /api/emails/{id}/send-to/{integration}. The message is fetched now and
+// delivered in the background; poll the returned delivery for the outcome.
+func (h *IntegrationHandler) SendTo(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	id, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	tok := ctxkeys.Token(r.Context())
+	if tok == nil {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no token in context")
+		return
+	}
+	name := chi.URLParam(r, "integration")
+	// Check the integration first so a refusal never touches the message
+	if err := h.Integrations.Ready(r.Context(), userID, name); err != nil {
+		writeIntegrationError(w, err)
+		return
+	}
+	msg, err := h.Emails.FetchMessageContent(r.Context(), tok, id)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	delivery, err := h.Integrations.Enqueue(r.Context(), userID, name, msg)
+	if err != nil {
+		writeIntegrationError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusAccepted, delivery)
+}
+
+// ListDeliveries handles GET /api/users/me/integrations/deliveries
+func (h *IntegrationHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	list, err := h.Integrations.Deliveries(r.Context(), userID, recentDeliveries)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load deliveries")
+		return
+	}
+	if list == nil {
+		list = []*models.IntegrationDelivery{}
+	}
+	RespondJSON(w, http.StatusOK, list)
+}
+
+// GetDelivery handles GET /api/users/me/integrations/deliveries/{id}
+func (h *IntegrationHandler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	idParam, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid delivery id")
+		return
+	}
+	delivery, err := h.Integrations.Delivery(r.Context(), userID, id)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "delivery not found")
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load delivery")
+		return
+	}
+	RespondJSON(w, http.StatusOK, delivery)
+}
+
+// writeIntegrationError maps integrations errors onto HTTP status codes with a machine-readable code
+func writeIntegrationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, integrations.ErrUnknownIntegration):
+		RespondErrorCode(w, http.StatusNotFound, ErrCodeUnknownIntegration, "unknown integration")
+	case errors.Is(err, integrations.ErrNotConfigured):
+		RespondErrorCode(w, http.StatusNotFound, ErrCodeIntegrationNotConfigured, "integration is not configured")
+	default:
+		RespondError(w, http.StatusInternalServerError, "integration request failed")
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/integrations"
+	"github.com/desponda/inbox-whisperer/internal/mocks"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// stubIntegrationRepo stores integration configs in memory
+type stubIntegrationRepo struct {
+	configs map[string]*models.Integration
+}
+
+func (s *stubIntegrationRepo) Get(ctx context.Context, userID, name string) (*models.Integration, error) {
+	if i, ok := s.configs[userID+"/"+name]; ok {
+		return i, nil
+	}
+	return nil, data.ErrNotFound
+}
+func (s *stubIntegrationRepo) List(ctx context.Context, userID string) ([]*models.Integration, error) {
+	var out []*models.Integration
+	for _, i := range s.configs {
+		if i.UserID == userID {
+			out = append(out, i)
+		}
+	}
+	return out, nil
+}
+func (s *stubIntegrationRepo) Upsert(ctx context.Context, i *models.Integration) error {
+	s.configs[i.UserID+"/"+i.Name] = i
+	return nil
+}
+func (s *stubIntegrationRepo) Delete(ctx context.Context, userID, name string) error {
+	if _, ok := s.configs[userID+"/"+name]; !ok {
+		return data.ErrNotFound
+	}
+	delete(s.configs, userID+"/"+name)
+	return nil
+}
+
+// stubDeliveryRepo stores deliveries in memory and never hands them to a worker
+type stubDeliveryRepo struct {
+	rows []*models.IntegrationDelivery
+}
+
+func (s *stubDeliveryRepo) Create(ctx context.Context, d *models.IntegrationDelivery) error {
+	d.ID, d.Status = int64(len(s.rows)+1), models.DeliveryQueued
+	s.rows = append(s.rows, d)
+	return nil
+}
+func (s *stubDeliveryRepo) Get(ctx context.Context, userID string, id int64) (*models.IntegrationDelivery, error) {
+	if id < 1 || id > int64(len(s.rows)) || s.rows[id-1].UserID != userID {
+		return nil, data.ErrNotFound
+	}
+	return s.rows[id-1], nil
+}
+func (s *stubDeliveryRepo) ListByUser(ctx context.Context, userID string, limit int) ([]*models.IntegrationDelivery, error) {
+	return s.rows, nil
+}
+func (s *stubDeliveryRepo) ClaimDue(ctx context.Context, now, abandonedBefore time.Time) (*models.IntegrationDelivery, error) {
+	return nil, nil
+}
+func (s *stubDeliveryRepo) Save(ctx context.Context, d *models.IntegrationDelivery) error { return nil }
+func (s *stubDeliveryRepo) Pending(ctx context.Context) (int, *time.Time, error) {
+	return 0, nil, nil
+}
+
+func integrationRequest(method, userID, body string, params map[string]string) *http.Request {
+	r := httptest.NewRequest(method, "/api/users/me/integrations", strings.NewReader(body))
+	ctx := ctxkeys.WithUserID(r.Context(), userID)
+	ctx = ctxkeys.WithToken(ctx, &oauth2.Token{AccessToken: "tok"})
+	chiCtx := chi.NewRouteContext()
+	for k, v := range params {
+		chiCtx.URLParams.Add(k, v)
+	}
+	ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+	return r.WithContext(ctx)
+}
+
+func TestIntegrationHandler_ConfigureAndSend(t *testing.T) {
+	fetched := 0
+	emails := &mocks.MockEmailService{
+		FetchMessageContentFunc: func(ctx context.Context, token *oauth2.Token, id string) (*models.EmailMessage, error) {
+			fetched++
+			return &models.EmailMessage{EmailMessageID: id, UserID: "user1", Subject: "Hello", Body: "hi"}, nil
+		},
+	}
+	svc := integrations.NewService(integrations.DefaultRegistry(), &stubIntegrationRepo{configs: map[string]*models.Integration{}}, &stubDeliveryRepo{})
+	h := NewIntegrationHandler(svc, emails)
+
+	rw := httptest.NewRecorder()
+	h.SendTo(rw, integrationRequest(http.MethodPost, "user1", "", map[string]string{"id": "m1", "integration": "readwise"}))
+	require.Equal(t, http.StatusNotFound, rw.Code)
+	require.Contains(t, rw.Body.String(), ErrCodeIntegrationNotConfigured)
+	require.Zero(t, fetched, "message must not be fetched for an unconfigured integration")
+
+	rw = httptest.NewRecorder()
+	h.ConfigureIntegration(rw, integrationRequest(http.MethodPut, "user1", `{"config":{}}`, map[string]string{"integration": "readwise"}))
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.ConfigureIntegration(rw, integrationRequest(http.MethodPut, "user1", `{"config":{"token":"secret"}}`, map[string]string{"integration": "readwise"}))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.NotContains(t, rw.Body.String(), "secret")
+
+	rw = httptest.NewRecorder()
+	h.ConfigureIntegration(rw, integrationRequest(http.MethodPut, "user1", `{"config":{}}`, map[string]string{"integration": "evernote"}))
+	require.Equal(t, http.StatusNotFound, rw.Code)
+	require.Contains(t, rw.Body.String(), ErrCodeUnknownIntegration)
+
+	rw = httptest.NewRecorder()
+	h.ListIntegrations(rw, integrationRequest(http.MethodGet, "user1", "", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var list IntegrationsResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&list))
+	require.Len(t, list.Configured, 1)
+	require.Contains(t, list.Available, "notion")
+
+	rw = httptest.NewRecorder()
+	h.SendTo(rw, integrationRequest(http.MethodPost, "user1", "", map[string]string{"id": "m1", "integration": "readwise"}))
+	require.Equal(t, http.StatusAccepted, rw.Code)
+	var d models.IntegrationDelivery
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&d))
+	require.Equal(t, models.DeliveryQueued, d.Status)
+	require.Equal(t, "m1", d.MessageID)
+
+	rw = httptest.NewRecorder()
+	h.GetDelivery(rw, integrationRequest(http.MethodGet, "user1", "", map[string]string{"id": "1"}))
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.GetDelivery(rw, integrationRequest(http.MethodGet, "user2", "", map[string]string{"id": "1"}))
+	require.Equal(t, http.StatusNotFound, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.RemoveIntegration(rw, integrationRequest(http.MethodDelete, "user1", "", map[string]string{"integration": "readwise"}))
+	require.Equal(t, http.StatusNoContent, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.RemoveIntegration(rw, integrationRequest(http.MethodDelete, "user1", "", map[string]string{"integration": "readwise"}))
+	require.Equal(t, http.StatusNotFound, rw.Code)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+// JobGetter loads job queue jobs (see jobs.Queue)
+type JobGetter interface {
+	Job(ctx context.Context, id int64) (*models.Job, error)
+}
+
+// JobHandler reports the status of background jobs of every kind
+type JobHandler struct {
+	Jobs JobGetter
+}
+
+func NewJobHandler(jobs JobGetter) *JobHandler {
+	return &JobHandler{Jobs: jobs}
+}
+
+// GetMyJob handles GET /api/jobs/{id}; other users' jobs are reported as not found
+func (h *JobHandler) GetMyJob(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	job, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+	if job.UserID != userID {
+		RespondError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	RespondJSON(w, http.StatusOK, job)
+}
+
+// AdminGetJob handles GET /api/admin/jobs/{id}
+func (h *JobHandler) AdminGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+	RespondJSON(w, http.StatusOK, job)
+}
+
+func (h *JobHandler) job(w http.ResponseWriter, r *http.Request) (*models.Job, bool) {
+	idParam, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid job id")
+		return nil, false
+	}
+	job, err := h.Jobs.Job(r.Context(), id)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "job not found")
+		return nil, false
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load job")
+		return nil, false
+	}
+	return job, true
+}
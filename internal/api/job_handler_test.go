@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/backfill"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+// stubJobQueue keeps queue jobs in memory, with IDs from 1
+type stubJobQueue struct {
+	jobs []*models.Job
+}
+
+func (s *stubJobQueue) Enqueue(ctx context.Context, kind, userID string, refID int64, payload any) (*models.Job, error) {
+	job := &models.Job{ID: int64(len(s.jobs) + 1), Kind: kind, UserID: userID, RefID: refID, Status: models.JobQueued, MaxAttempts: 3}
+	if payload != nil {
+		job.Payload, _ = json.Marshal(payload)
+	}
+	s.jobs = append(s.jobs, job)
+	return job, nil
+}
+func (s *stubJobQueue) Active(ctx context.Context, kind, userID string) (*models.Job, error) {
+	for _, j := range s.jobs {
+		if j.Kind == kind && j.UserID == userID && !j.Finished() {
+			return j, nil
+		}
+	}
+	return nil, nil
+}
+func (s *stubJobQueue) Save(ctx context.Context, job *models.Job) error { return nil }
+func (s *stubJobQueue) Job(ctx context.Context, id int64) (*models.Job, error) {
+	if id < 1 || id > int64(len(s.jobs)) {
+		return nil, data.ErrNotFound
+	}
+	return s.jobs[id-1], nil
+}
+
+func TestJobHandler_GetMyJob(t *testing.T) {
+	queue := &stubJobQueue{}
+	_, _ = queue.Enqueue(context.Background(), models.JobKindMailboxBackfill, "user1", 0, nil)
+	_, _ = queue.Enqueue(context.Background(), models.JobKindRecategorize, "", 7, nil)
+	h := NewJobHandler(queue)
+
+	for _, tc := range []struct {
+		userID, id string
+		want       int
+	}{
+		{"user1", "1", http.StatusOK},
+		{"user2", "1", http.StatusNotFound},
+		// All-users jobs are only visible to admins
+		{"user1", "2", http.StatusNotFound},
+		{"user1", "3", http.StatusNotFound},
+		{"user1", "abc", http.StatusBadRequest},
+		{"", "1", http.StatusUnauthorized},
+	} {
+		rw := httptest.NewRecorder()
+		h.GetMyJob(rw, bulkRequest(http.MethodGet, tc.userID, tc.id, ""))
+		require.Equal(t, tc.want, rw.Code, "user %q job %s", tc.userID, tc.id)
+	}
+
+	rw := httptest.NewRecorder()
+	h.AdminGetJob(rw, bulkRequest(http.MethodGet, "admin", "2", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var job models.Job
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&job))
+	require.Equal(t, models.JobKindRecategorize, job.Kind)
+	require.Equal(t, int64(7), job.RefID)
+	require.Equal(t, models.JobQueued, job.Status)
+}
+
+func TestBackfillHandler_StartMine(t *testing.T) {
+	queue := &stubJobQueue{}
+	h := NewBackfillHandler(backfill.NewMailbox(nil, nil, queue))
+
+	rw := httptest.NewRecorder()
+	h.StartMine(rw, bulkRequest(http.MethodPost, "user1", "", ""))
+	require.Equal(t, http.StatusAccepted, rw.Code)
+	var job models.Job
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&job))
+	require.Equal(t, models.JobKindMailboxBackfill, job.Kind)
+
+	rw = httptest.NewRecorder()
+	h.StartMine(rw, bulkRequest(http.MethodPost, "user1", "", ""))
+	require.Equal(t, http.StatusConflict, rw.Code)
+	require.Contains(t, rw.Body.String(), "backfill_job_active")
+
+	rw = httptest.NewRecorder()
+	h.StartMine(rw, bulkRequest(http.MethodPost, "", "", ""))
+	require.Equal(t, http.StatusUnauthorized, rw.Code)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
@@ -21,6 +22,42 @@ func DecodeJSON(r *http.Request, v interface{}) error {
 	return dec.Decode(v)
 }
 
+// RespondBodyError answers a failed DecodeJSON: 413 when the body went over the route's limit
+// (see BodyLimitMiddleware), and 400 with msg otherwise
+func RespondBodyError(w http.ResponseWriter, err error, msg string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		RespondBodyTooLarge(w)
+		return
+	}
+	RespondError(w, http.StatusBadRequest, msg)
+}
+
+// RespondBodyTooLarge writes the 413 for a request body over the route's limit
+func RespondBodyTooLarge(w http.ResponseWriter) {
+	RespondErrorCode(w, http.StatusRequestEntityTooLarge, "body_too_large", "request body too large")
+}
+
 func RespondError(w http.ResponseWriter, status int, msg string) {
 	RespondJSON(w, status, map[string]string{"error": msg})
 }
+
+// RespondErrorCode writes an error with a stable machine-readable code alongside the message
+func RespondErrorCode(w http.ResponseWriter, status int, code, msg string) {
+	RespondJSON(w, status, map[string]string{"error": msg, "code": code})
+}
+
+// Problem is an RFC 7807 problem details body
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// RespondProblem writes an application/problem+json response for status
+func RespondProblem(w http.ResponseWriter, status int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: detail})
+}
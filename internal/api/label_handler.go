@@ -0,0 +1,143 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"golang.org/x/oauth2"
+)
+
+// LabelsResponse is returned by GET /api/labels
+type LabelsResponse struct {
+	Labels       []*models.Label       `json:"labels"`
+	Capabilities provider.Capabilities `json:"capabilities"`
+}
+
+var labelColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type LabelHandler struct {
+	Service service.LabelServiceInterface
+}
+
+func NewLabelHandler(svc service.LabelServiceInterface) *LabelHandler {
+	return &LabelHandler{Service: svc}
+}
+
+// ListLabels handles GET /api/labels
+func (h *LabelHandler) ListLabels(w http.ResponseWriter, r *http.Request) {
+	userID, tok, ok := labelAuth(w, r)
+	if !ok {
+		return
+	}
+	labels, err := h.Service.ListLabels(r.Context(), userID, tok)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	if labels == nil {
+		labels = []*models.Label{}
+	}
+	RespondJSON(w, http.StatusOK, LabelsResponse{Labels: labels, Capabilities: h.Service.Capabilities()})
+}
+
+// CreateLabel handles POST /api/labels
+func (h *LabelHandler) CreateLabel(w http.ResponseWriter, r *http.Request) {
+	userID, tok, ok := labelAuth(w, r)
+	if !ok {
+		return
+	}
+	var create models.LabelCreate
+	if err := DecodeJSON(r, &create); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	create.Name = strings.TrimSpace(create.Name)
+	if create.Name == "" {
+		RespondError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	for _, c := range []string{create.BackgroundColor, create.TextColor} {
+		if c != "" && !labelColorPattern.MatchString(c) {
+			RespondError(w, http.StatusBadRequest, "colors must be hex values like #16a765")
+			return
+		}
+	}
+	label, err := h.Service.CreateLabel(r.Context(), userID, tok, create)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusCreated, label)
+}
+
+// UpdateLabel handles PUT /api/labels/{id}
+func (h *LabelHandler) UpdateLabel(w http.ResponseWriter, r *http.Request) {
+	id, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, tok, ok := labelAuth(w, r)
+	if !ok {
+		return
+	}
+	var update models.LabelUpdate
+	if err := DecodeJSON(r, &update); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	if err := validateLabelUpdate(&update); err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	label, err := h.Service.UpdateLabel(r.Context(), userID, tok, id, update)
+	if err != nil {
+		if errors.Is(err, service.ErrSystemLabel) {
+			RespondError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		writeProviderError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusOK, label)
+}
+
+func validateLabelUpdate(u *models.LabelUpdate) error {
+	if u.Name == nil && u.BackgroundColor == nil && u.TextColor == nil {
+		return errors.New("nothing to update: provide name, background_color or text_color")
+	}
+	if u.Name != nil {
+		trimmed := strings.TrimSpace(*u.Name)
+		if trimmed == "" {
+			return errors.New("name must not be empty")
+		}
+		u.Name = &trimmed
+	}
+	for _, c := range []*string{u.BackgroundColor, u.TextColor} {
+		if c != nil && !labelColorPattern.MatchString(*c) {
+			return errors.New("colors must be hex values like #16a765")
+		}
+	}
+	return nil
+}
+
+// labelAuth extracts the user ID and provider token, responding 401 if either is missing
+func labelAuth(w http.ResponseWriter, r *http.Request) (string, *oauth2.Token, bool) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return "", nil, false
+	}
+	tok := ctxkeys.Token(r.Context())
+	if tok == nil {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no token in context")
+		return "", nil, false
+	}
+	return userID, tok, true
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/desponda/inbox-whisperer/internal/testutils"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+type stubLabelService struct {
+	labels  []*models.Label
+	updated *models.LabelUpdate
+	err     error
+}
+
+func (s *stubLabelService) Capabilities() provider.Capabilities {
+	return provider.Capabilities{Labels: true, LabelColors: true, EditLabels: true}
+}
+func (s *stubLabelService) ListLabels(ctx context.Context, userID string, token *oauth2.Token) ([]*models.Label, error) {
+	return s.labels, s.err
+}
+func (s *stubLabelService) UpdateLabel(ctx context.Context, userID string, token *oauth2.Token, labelID string, update models.LabelUpdate) (*models.Label, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.updated = &update
+	return &models.Label{ProviderLabelID: labelID, Name: *update.Name, Type: models.LabelTypeUser}, nil
+}
+
+func (s *stubLabelService) CreateLabel(ctx context.Context, userID string, token *oauth2.Token, create models.LabelCreate) (*models.Label, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &models.Label{ProviderLabelID: "Label_9", Name: create.Name, Type: models.LabelTypeUser, BackgroundColor: create.BackgroundColor}, nil
+}
+
+func labelRequest(method, target, body, id string) *http.Request {
+	if id != "" {
+		return testutils.NewAuthedRequest(method, target, strings.NewReader(body), testutils.WithURLParam("id", id))
+	}
+	return testutils.NewAuthedRequest(method, target, strings.NewReader(body))
+}
+
+func TestListLabels(t *testing.T) {
+	h := NewLabelHandler(&stubLabelService{labels: []*models.Label{{ProviderLabelID: "Label_1", Name: "Finance", Type: models.LabelTypeUser, BackgroundColor: "#16a765"}}})
+	w := httptest.NewRecorder()
+	h.ListLabels(w, labelRequest("GET", "/api/labels", "", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp LabelsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.Labels, 1)
+	require.Equal(t, "#16a765", resp.Labels[0].BackgroundColor)
+	require.True(t, resp.Capabilities.EditLabels)
+
+	w = httptest.NewRecorder()
+	h.ListLabels(w, httptest.NewRequest("GET", "/api/labels", nil))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestUpdateLabel(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		err        error
+		wantStatus int
+	}{
+		{"success", `{"name":" Finance/Receipts ","background_color":"#16a765","text_color":"#ffffff"}`, nil, http.StatusOK},
+		{"empty update", `{}`, nil, http.StatusBadRequest},
+		{"bad color", `{"name":"x","background_color":"green"}`, nil, http.StatusBadRequest},
+		{"unknown field", `{"name":"x","visibility":"hide"}`, nil, http.StatusBadRequest},
+		{"system label", `{"name":"x"}`, service.ErrSystemLabel, http.StatusForbidden},
+		{"not found", `{"name":"x"}`, provider.ErrNotFound, http.StatusNotFound},
+		{"unsupported", `{"name":"x"}`, provider.ErrUnsupported, http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubLabelService{err: tt.err}
+			h := NewLabelHandler(svc)
+			w := httptest.NewRecorder()
+			h.UpdateLabel(w, labelRequest("PUT", "/api/labels/Label_1", tt.body, "Label_1"))
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				require.Equal(t, "Finance/Receipts", *svc.updated.Name)
+			}
+		})
+	}
+}
+
+func TestCreateLabel(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		err        error
+		wantStatus int
+	}{
+		{"success", `{"name":"Finance/Receipts","background_color":"#16a765","text_color":"#ffffff"}`, nil, http.StatusCreated},
+		{"missing name", `{"name":"  "}`, nil, http.StatusBadRequest},
+		{"bad color", `{"name":"x","text_color":"white"}`, nil, http.StatusBadRequest},
+		{"name taken", `{"name":"Finance"}`, &provider.Error{Kind: provider.ErrConflict}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewLabelHandler(&stubLabelService{err: tt.err})
+			w := httptest.NewRecorder()
+			h.CreateLabel(w, labelRequest("POST", "/api/labels", tt.body, ""))
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusCreated {
+				var l models.Label
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&l))
+				require.Equal(t, "Label_9", l.ProviderLabelID)
+			}
+		})
+	}
+}
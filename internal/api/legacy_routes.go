@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/desponda/inbox-whisperer/internal/telemetry/metrics"
+	"github.com/go-chi/chi/v5"
+)
+
+// LegacyRoutes serves a deprecated path prefix from the same handlers as its successor while
+// clients move over. Responses on the legacy prefix carry a Deprecation header and a Link to
+// the successor path, and each request is counted by route so operators can see when the
+// traffic has drained. Once disabled, the legacy prefix answers 410 Gone.
+type LegacyRoutes struct {
+	Prefix    string
+	Successor string
+	disabled  atomic.Bool
+}
+
+func NewLegacyRoutes(prefix, successor string, enabled bool) *LegacyRoutes {
+	l := &LegacyRoutes{Prefix: prefix, Successor: successor}
+	l.SetEnabled(enabled)
+	return l
+}
+
+// SetEnabled turns the legacy prefix on or off, e.g. on a config reload
+func (l *LegacyRoutes) SetEnabled(enabled bool) {
+	l.disabled.Store(!enabled)
+}
+
+// Middleware wraps the routes mounted at the legacy prefix
+func (l *LegacyRoutes) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		successor := l.Successor + strings.TrimPrefix(r.URL.Path, l.Prefix)
+		if l.disabled.Load() {
+			RespondErrorCode(w, http.StatusGone, "legacy_route_removed", "this endpoint moved to "+successor)
+			return
+		}
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+">; rel=\"successor-version\"")
+		next.ServeHTTP(w, r)
+		// The matched pattern keeps the label set small, unlike the raw path with its IDs
+		route := l.Prefix + "/*"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		metrics.ObserveLegacyRequest(route)
+	})
+}
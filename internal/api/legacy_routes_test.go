@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/telemetry/metrics"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegacyRoutes(t *testing.T) {
+	routes := func(r chi.Router) {
+		r.Get("/messages/{id}", func(w http.ResponseWriter, r *http.Request) {
+			RespondJSON(w, http.StatusOK, map[string]string{"id": chi.URLParam(r, "id")})
+		})
+	}
+	legacy := NewLegacyRoutes("/api/email", "/api/emails", true)
+	r := chi.NewRouter()
+	r.Route("/api/emails", routes)
+	r.With(legacy.Middleware).Route("/api/email", routes)
+	get := func(path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, path, nil))
+		return rw
+	}
+
+	rw := get("/api/emails/messages/m1")
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Empty(t, rw.Header().Get("Deprecation"))
+
+	rw = get("/api/email/messages/m1")
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.JSONEq(t, `{"id":"m1"}`, rw.Body.String())
+	require.Equal(t, "true", rw.Header().Get("Deprecation"))
+	require.Equal(t, `</api/emails/messages/m1>; rel="successor-version"`, rw.Header().Get("Link"))
+
+	var out strings.Builder
+	metrics.Write(&out)
+	require.Contains(t, out.String(), `inbox_whisperer_legacy_api_requests_total{route="/api/email/messages/{id}"} 1`)
+
+	legacy.SetEnabled(false)
+	rw = get("/api/email/messages/m1")
+	require.Equal(t, http.StatusGone, rw.Code)
+	require.Contains(t, rw.Body.String(), "legacy_route_removed")
+	require.Equal(t, http.StatusOK, get("/api/emails/messages/m1").Code)
+}
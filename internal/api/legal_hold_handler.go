@@ -0,0 +1,148 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/legalhold"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrCodeLegalHold is returned with 423 Locked for deletions refused by a legal hold
+const ErrCodeLegalHold = "legal_hold"
+
+// maxLegalHoldEvents bounds the audit trail returned for one user
+const maxLegalHoldEvents = 500
+
+// guardDeletion writes the error and returns false if the user's cached mail may not be deleted
+func guardDeletion(w http.ResponseWriter, r *http.Request, holds *legalhold.Service, userID, action string) bool {
+	err := holds.Guard(r.Context(), userID, action)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, legalhold.ErrHeld):
+		RespondErrorCode(w, http.StatusLocked, ErrCodeLegalHold, err.Error())
+	default:
+		log.Error().Err(err).Str("user_id", userID).Msg("failed to check legal hold")
+		RespondError(w, http.StatusInternalServerError, "failed to check legal hold")
+	}
+	return false
+}
+
+// LegalHoldHandler lets admins place and release legal holds
+type LegalHoldHandler struct {
+	Holds *legalhold.Service
+}
+
+func NewLegalHoldHandler(holds *legalhold.Service) *LegalHoldHandler {
+	return &LegalHoldHandler{Holds: holds}
+}
+
+// LegalHoldRequest is the body of PUT /api/admin/users/{id}/legal-hold
+type LegalHoldRequest struct {
+	Reason string `json:"reason"`
+}
+
+// LegalHoldList is the response of GET /api/admin/legal-holds
+type LegalHoldList struct {
+	// AllUsers is set when storage.legal_hold holds every user, whatever the holds listed
+	AllUsers bool                `json:"all_users"`
+	Holds    []*models.LegalHold `json:"holds"`
+}
+
+// LegalHoldStatus is the response of GET /api/admin/users/{id}/legal-hold
+type LegalHoldStatus struct {
+	Held   bool                     `json:"held"`
+	Hold   *models.LegalHold        `json:"hold,omitempty"`
+	Events []*models.LegalHoldEvent `json:"events"`
+}
+
+// AdminListHolds handles GET /api/admin/legal-holds
+func (h *LegalHoldHandler) AdminListHolds(w http.ResponseWriter, r *http.Request) {
+	holds, err := h.Holds.Repo.List(r.Context())
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to list legal holds")
+		return
+	}
+	if holds == nil {
+		holds = []*models.LegalHold{}
+	}
+	RespondJSON(w, http.StatusOK, LegalHoldList{AllUsers: h.Holds.All, Holds: holds})
+}
+
+// AdminGetHold handles GET /api/admin/users/{id}/legal-hold, returning the user's hold and its
+// audit trail, most recent first
+func (h *LegalHoldHandler) AdminGetHold(w http.ResponseWriter, r *http.Request) {
+	userID, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status := LegalHoldStatus{Held: h.Holds.All}
+	hold, err := h.Holds.Repo.Get(r.Context(), userID)
+	switch {
+	case err == nil:
+		status.Held, status.Hold = true, hold
+	case !errors.Is(err, data.ErrNotFound):
+		RespondError(w, http.StatusInternalServerError, "failed to load legal hold")
+		return
+	}
+	if status.Events, err = h.Holds.Repo.ListEvents(r.Context(), userID, maxLegalHoldEvents); err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load legal hold events")
+		return
+	}
+	if status.Events == nil {
+		status.Events = []*models.LegalHoldEvent{}
+	}
+	RespondJSON(w, http.StatusOK, status)
+}
+
+// AdminPlaceHold handles PUT /api/admin/users/{id}/legal-hold; placing a hold again updates
+// its reason
+func (h *LegalHoldHandler) AdminPlaceHold(w http.ResponseWriter, r *http.Request) {
+	userID, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req LegalHoldRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	hold, err := h.Holds.Place(r.Context(), userID, req.Reason)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("admin_id", ctxkeys.UserID(r.Context())).Str("user_id", userID).Msg("failed to place legal hold")
+		RespondError(w, http.StatusInternalServerError, "failed to place legal hold")
+		return
+	}
+	RespondJSON(w, http.StatusOK, hold)
+}
+
+// AdminReleaseHold handles DELETE /api/admin/users/{id}/legal-hold. Users stay held while
+// storage.legal_hold is on.
+func (h *LegalHoldHandler) AdminReleaseHold(w http.ResponseWriter, r *http.Request) {
+	userID, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = h.Holds.Release(r.Context(), userID)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "user is not under legal hold")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("admin_id", ctxkeys.UserID(r.Context())).Str("user_id", userID).Msg("failed to release legal hold")
+		RespondError(w, http.StatusInternalServerError, "failed to release legal hold")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/legalhold"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+type stubLegalHoldRepo struct {
+	users  map[string]bool
+	holds  map[string]*models.LegalHold
+	events []*models.LegalHoldEvent
+}
+
+func newStubLegalHoldRepo(users ...string) *stubLegalHoldRepo {
+	r := &stubLegalHoldRepo{users: map[string]bool{}, holds: map[string]*models.LegalHold{}}
+	for _, u := range users {
+		r.users[u] = true
+	}
+	return r
+}
+
+func (s *stubLegalHoldRepo) Place(ctx context.Context, hold *models.LegalHold) error {
+	if !s.users[hold.UserID] {
+		return data.ErrNotFound
+	}
+	s.holds[hold.UserID] = hold
+	return nil
+}
+
+func (s *stubLegalHoldRepo) Release(ctx context.Context, userID string) error {
+	if _, ok := s.holds[userID]; !ok {
+		return data.ErrNotFound
+	}
+	delete(s.holds, userID)
+	return nil
+}
+
+func (s *stubLegalHoldRepo) Get(ctx context.Context, userID string) (*models.LegalHold, error) {
+	h, ok := s.holds[userID]
+	if !ok {
+		return nil, data.ErrNotFound
+	}
+	return h, nil
+}
+
+func (s *stubLegalHoldRepo) List(ctx context.Context) ([]*models.LegalHold, error) {
+	var holds []*models.LegalHold
+	for _, h := range s.holds {
+		holds = append(holds, h)
+	}
+	return holds, nil
+}
+
+func (s *stubLegalHoldRepo) RecordEvent(ctx context.Context, e *models.LegalHoldEvent) error {
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *stubLegalHoldRepo) ListEvents(ctx context.Context, userID string, limit int) ([]*models.LegalHoldEvent, error) {
+	var events []*models.LegalHoldEvent
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if s.events[i].UserID == userID {
+			events = append(events, s.events[i])
+		}
+	}
+	return events, nil
+}
+
+func TestLegalHoldHandler(t *testing.T) {
+	repo := newStubLegalHoldRepo("user1")
+	h := NewLegalHoldHandler(legalhold.NewService(repo))
+
+	rw := httptest.NewRecorder()
+	h.AdminPlaceHold(rw, recategorizeRequest(http.MethodPut, "admin", "user1", `{"reason":"case 42"}`))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, "admin", repo.holds["user1"].PlacedBy)
+
+	rw = httptest.NewRecorder()
+	h.AdminListHolds(rw, recategorizeRequest(http.MethodGet, "admin", "", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var list LegalHoldList
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&list))
+	require.False(t, list.AllUsers)
+	require.Len(t, list.Holds, 1)
+
+	rw = httptest.NewRecorder()
+	h.AdminGetHold(rw, recategorizeRequest(http.MethodGet, "admin", "user1", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var status LegalHoldStatus
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&status))
+	require.True(t, status.Held)
+	require.Equal(t, "case 42", status.Hold.Reason)
+	require.Len(t, status.Events, 1)
+	require.Equal(t, models.LegalHoldPlaced, status.Events[0].Event)
+
+	rw = httptest.NewRecorder()
+	h.AdminReleaseHold(rw, recategorizeRequest(http.MethodDelete, "admin", "user1", ""))
+	require.Equal(t, http.StatusNoContent, rw.Code)
+	require.Empty(t, repo.holds)
+	require.Equal(t, models.LegalHoldReleased, repo.events[len(repo.events)-1].Event)
+
+	for name, tc := range map[string]struct {
+		req  *http.Request
+		want int
+	}{
+		"missing user": {recategorizeRequest(http.MethodPut, "admin", "ghost", `{"reason":"x"}`), http.StatusNotFound},
+		"not held":     {recategorizeRequest(http.MethodDelete, "admin", "user1", ""), http.StatusNotFound},
+		"bad body":     {recategorizeRequest(http.MethodPut, "admin", "user1", `{`), http.StatusBadRequest},
+	} {
+		rw = httptest.NewRecorder()
+		if tc.req.Method == http.MethodDelete {
+			h.AdminReleaseHold(rw, tc.req)
+		} else {
+			h.AdminPlaceHold(rw, tc.req)
+		}
+		require.Equal(t, tc.want, rw.Code, name)
+	}
+}
+
+func TestSettingsHandler_LegalHoldBlocksPurge(t *testing.T) {
+	repo := &stubSettingsRepo{settings: map[string]models.UserSettings{}}
+	messages := &purgeRecordingRepo{}
+	holds := newStubLegalHoldRepo("user1")
+	holds.holds["user1"] = &models.LegalHold{UserID: "user1"}
+	h := NewSettingsHandler(repo, false)
+	h.Messages = messages
+	h.Holds = legalhold.NewService(holds)
+	r := httptest.NewRequest("PUT", "/api/users/me/settings", strings.NewReader(`{"disable_local_cache":true}`))
+	w := httptest.NewRecorder()
+	h.UpdateSettings(w, r.WithContext(ctxkeys.WithUserID(r.Context(), "user1")))
+
+	require.Equal(t, http.StatusLocked, w.Code)
+	require.Contains(t, w.Body.String(), ErrCodeLegalHold)
+	require.False(t, repo.settings["user1"].DisableLocalCache)
+	require.Empty(t, messages.purged)
+	require.Len(t, holds.events, 1)
+	require.Equal(t, legalhold.ActionPurgeCache, holds.events[0].Action)
+	require.Equal(t, "user1", holds.events[0].Actor)
+
+	// Settings that drop nothing are still saved
+	r = httptest.NewRequest("PUT", "/api/users/me/settings", strings.NewReader(`{"timezone":"UTC"}`))
+	w = httptest.NewRecorder()
+	h.UpdateSettings(w, r.WithContext(ctxkeys.WithUserID(r.Context(), "user1")))
+	require.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+)
+
+type MaintenanceHandler struct {
+	Switch *maintenance.Switch
+}
+
+func NewMaintenanceHandler(sw *maintenance.Switch) *MaintenanceHandler {
+	return &MaintenanceHandler{Switch: sw}
+}
+
+// MaintenanceRequest turns maintenance mode on or off
+type MaintenanceRequest struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message"`
+	// RetryAfterSeconds is the client retry hint; 0 keeps the current one
+	RetryAfterSeconds int `json:"retry_after_seconds"`
+}
+
+// AdminGet handles GET /api/admin/maintenance
+func (h *MaintenanceHandler) AdminGet(w http.ResponseWriter, r *http.Request) {
+	RespondJSON(w, http.StatusOK, h.Switch.Status())
+}
+
+// AdminPut handles PUT /api/admin/maintenance
+func (h *MaintenanceHandler) AdminPut(w http.ResponseWriter, r *http.Request) {
+	var req MaintenanceRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	if req.RetryAfterSeconds < 0 {
+		RespondError(w, http.StatusBadRequest, "retry_after_seconds must not be negative")
+		return
+	}
+	RespondJSON(w, http.StatusOK, h.Switch.Set(req.Enabled, req.Message, time.Duration(req.RetryAfterSeconds)*time.Second))
+}
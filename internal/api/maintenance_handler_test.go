@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	sw := maintenance.New()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	mw := MaintenanceMiddleware(sw)(next)
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		mw.ServeHTTP(rw, httptest.NewRequest(method, path, nil))
+		return rw
+	}
+
+	require.Equal(t, http.StatusNoContent, serve(http.MethodPost, "/api/rules").Code)
+
+	sw.Set(true, "upgrading the database", 0)
+	rw := serve(http.MethodPost, "/api/rules")
+	require.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	require.Equal(t, "300", rw.Header().Get("Retry-After"))
+	require.Contains(t, rw.Body.String(), "upgrading the database")
+	require.Equal(t, http.StatusServiceUnavailable, serve(http.MethodDelete, "/api/rules/1").Code)
+	require.Equal(t, http.StatusNoContent, serve(http.MethodGet, "/api/email/messages").Code, "reads keep working")
+	require.Equal(t, http.StatusNoContent, serve(http.MethodPut, "/api/admin/maintenance").Code, "admins can turn it off")
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	h := NewMaintenanceHandler(maintenance.New())
+
+	rw := httptest.NewRecorder()
+	h.AdminPut(rw, httptest.NewRequest(http.MethodPut, "/api/admin/maintenance", strings.NewReader(`{"enabled":true,"retry_after_seconds":60}`)))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var st maintenance.Status
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&st))
+	require.True(t, st.Enabled)
+	require.Equal(t, 60, st.RetryAfterSeconds)
+
+	rw = httptest.NewRecorder()
+	h.AdminPut(rw, httptest.NewRequest(http.MethodPut, "/api/admin/maintenance", strings.NewReader(`{"enabled":false,"retry_after_seconds":-1}`)))
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.AdminGet(rw, httptest.NewRequest(http.MethodGet, "/api/admin/maintenance", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Contains(t, rw.Body.String(), `"enabled":true`)
+}
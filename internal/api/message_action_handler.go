@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service"
+	"github.com/desponda/inbox-whisperer/internal/suggestions"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+// MessageActioner archives, trashes and marks messages read or unread at the provider
+type MessageActioner interface {
+	Archive(ctx context.Context, userID string, token *oauth2.Token, messageID string) error
+	Trash(ctx context.Context, userID string, token *oauth2.Token, messageID string) error
+	MarkRead(ctx context.Context, userID string, token *oauth2.Token, messageID string) error
+	MarkUnread(ctx context.Context, userID string, token *oauth2.Token, messageID string) error
+}
+
+// MessageLabeler adds a label to a message by name (see service.LabelService)
+type MessageLabeler interface {
+	ApplyLabel(ctx context.Context, userID string, token *oauth2.Token, messageID, labelName string) error
+}
+
+// EmailUpdater applies a user's label, category and starred changes to a message (see
+// service.EmailUpdateService)
+type EmailUpdater interface {
+	Update(ctx context.Context, userID string, token *oauth2.Token, messageID string, update models.EmailUpdate) (*models.EmailUserOverride, error)
+}
+
+// Actions accepted by POST /api/emails/{id}/actions
+const (
+	MessageActionArchive    = "archive"
+	MessageActionTrash      = "trash"
+	MessageActionMarkRead   = "mark_read"
+	MessageActionMarkUnread = "mark_unread"
+	MessageActionApplyLabel = "apply_label"
+	// MessageActionMove applies the label and archives the message, like moving it to a folder
+	MessageActionMove = "move"
+)
+
+// MessageActionRequest is the body of POST /api/emails/{id}/actions; Label is required by
+// apply_label and move
+type MessageActionRequest struct {
+	Action string `json:"action"`
+	Label  string `json:"label,omitempty"`
+}
+
+type MessageActionHandler struct {
+	Actions     MessageActioner
+	Messages    data.EmailMessageRepository
+	Suggestions *suggestions.Service
+	// Labels, if set, enables the apply_label and move actions
+	Labels MessageLabeler
+	// Updates, if set, enables PATCH /api/emails/{id}
+	Updates EmailUpdater
+}
+
+func NewMessageActionHandler(actions MessageActioner, messages data.EmailMessageRepository, svc *suggestions.Service) *MessageActionHandler {
+	return &MessageActionHandler{Actions: actions, Messages: messages, Suggestions: svc}
+}
+
+// Archive handles POST /api/emails/{id}/archive
+func (h *MessageActionHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	h.handle(w, r, models.UserActionArchive, h.Actions.Archive)
+}
+
+// DeleteEmail handles DELETE /api/emails/{id}, moving the message to the provider's trash
+// and dropping it from the local cache. Providers that cannot trash messages get 501.
+func (h *MessageActionHandler) DeleteEmail(w http.ResponseWriter, r *http.Request) {
+	h.handle(w, r, "", h.Actions.Trash)
+}
+
+// MarkRead handles POST /api/emails/{id}/read
+func (h *MessageActionHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	h.handle(w, r, models.UserActionMarkRead, h.Actions.MarkRead)
+}
+
+// SetReadRequest is the body of PUT /api/emails/{id}/read
+type SetReadRequest struct {
+	Read *bool `json:"read"`
+}
+
+// SetRead handles PUT /api/emails/{id}/read, setting the message's read state at the provider
+// and in the local cache
+func (h *MessageActionHandler) SetRead(w http.ResponseWriter, r *http.Request) {
+	userID, tok, id, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+	var req SetReadRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	if req.Read == nil {
+		RespondError(w, http.StatusBadRequest, "read is required")
+		return
+	}
+	if *req.Read {
+		h.run(w, r, userID, tok, id, models.UserActionMarkRead, h.Actions.MarkRead)
+		return
+	}
+	h.run(w, r, userID, tok, id, "", h.Actions.MarkUnread)
+}
+
+// UpdateEmail handles PATCH /api/emails/{id}: adds and removes labels, overrides the category and
+// stars or unstars the message. The response lists which changes were also made at the provider.
+func (h *MessageActionHandler) UpdateEmail(w http.ResponseWriter, r *http.Request) {
+	userID, tok, id, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+	if h.Updates == nil {
+		RespondError(w, http.StatusNotImplemented, "message updates are not supported")
+		return
+	}
+	var update models.EmailUpdate
+	if err := DecodeJSON(r, &update); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	o, err := h.Updates.Update(r.Context(), userID, tok, id, update)
+	if errors.Is(err, service.ErrInvalidUpdate) {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "email not found")
+		return
+	}
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusOK, o)
+}
+
+// Act handles POST /api/emails/{id}/actions: one of the message actions, chosen by the body,
+// carried out at the user's provider
+func (h *MessageActionHandler) Act(w http.ResponseWriter, r *http.Request) {
+	userID, tok, id, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+	var req MessageActionRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	if err := h.act(r.Context(), userID, tok, id, req); err != nil {
+		writeActionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// actionError is an action request refused before it reaches the provider
+type actionError struct {
+	status int
+	msg    string
+}
+
+func (e *actionError) Error() string {
+	return e.msg
+}
+
+// writeActionError answers a failed act with the refusal or the provider's error
+func writeActionError(w http.ResponseWriter, err error) {
+	var ae *actionError
+	if errors.As(err, &ae) {
+		RespondError(w, ae.status, ae.msg)
+		return
+	}
+	writeProviderError(w, err)
+}
+
+// act carries out one of the actions of POST /api/emails/{id}/actions
+func (h *MessageActionHandler) act(ctx context.Context, userID string, tok *oauth2.Token, id string, req MessageActionRequest) error {
+	label := strings.TrimSpace(req.Label)
+	switch req.Action {
+	case MessageActionArchive:
+		return h.do(ctx, userID, tok, id, models.UserActionArchive, h.Actions.Archive)
+	case MessageActionTrash:
+		return h.do(ctx, userID, tok, id, "", h.Actions.Trash)
+	case MessageActionMarkRead:
+		return h.do(ctx, userID, tok, id, models.UserActionMarkRead, h.Actions.MarkRead)
+	case MessageActionMarkUnread:
+		return h.do(ctx, userID, tok, id, "", h.Actions.MarkUnread)
+	case MessageActionApplyLabel, MessageActionMove:
+		if label == "" {
+			return &actionError{http.StatusBadRequest, "label is required for " + req.Action}
+		}
+		if h.Labels == nil {
+			return &actionError{http.StatusNotImplemented, "labels are not supported"}
+		}
+		if err := h.Labels.ApplyLabel(ctx, userID, tok, id, label); err != nil {
+			return err
+		}
+		if req.Action == MessageActionMove {
+			return h.do(ctx, userID, tok, id, models.UserActionArchive, h.Actions.Archive)
+		}
+		return nil
+	default:
+		return &actionError{http.StatusBadRequest, "unknown action: use archive, trash, mark_read, mark_unread, apply_label or move"}
+	}
+}
+
+func (h *MessageActionHandler) handle(w http.ResponseWriter, r *http.Request, action string,
+	do func(ctx context.Context, userID string, token *oauth2.Token, messageID string) error) {
+	userID, tok, id, ok := h.target(w, r)
+	if !ok {
+		return
+	}
+	h.run(w, r, userID, tok, id, action, do)
+}
+
+// target reads the user, provider token and message ID of an action request, answering it
+// when one is missing
+func (h *MessageActionHandler) target(w http.ResponseWriter, r *http.Request) (string, *oauth2.Token, string, bool) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return "", nil, "", false
+	}
+	id, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return "", nil, "", false
+	}
+	tok := ctxkeys.Token(r.Context())
+	if tok == nil {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no token in context")
+		return "", nil, "", false
+	}
+	return userID, tok, id, true
+}
+
+// run carries out an action and answers the request
+func (h *MessageActionHandler) run(w http.ResponseWriter, r *http.Request, userID string, tok *oauth2.Token, id, action string,
+	do func(ctx context.Context, userID string, token *oauth2.Token, messageID string) error) {
+	if err := h.do(r.Context(), userID, tok, id, action, do); err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// do carries out an action and feeds it to the suggestion engine; an empty action is not
+// learned from
+func (h *MessageActionHandler) do(ctx context.Context, userID string, tok *oauth2.Token, id, action string,
+	do func(ctx context.Context, userID string, token *oauth2.Token, messageID string) error) error {
+	if err := do(ctx, userID, tok, id); err != nil {
+		return err
+	}
+	if action != "" {
+		h.observe(ctx, userID, action, id)
+	}
+	return nil
+}
+
+// observe feeds the action to the suggestion engine; the action already succeeded, so failures are only logged
+func (h *MessageActionHandler) observe(ctx context.Context, userID, action, messageID string) {
+	msg, err := h.Messages.GetMessageByID(ctx, userID, messageID)
+	if err != nil || msg == nil {
+		// Messages that were never synced have no sender or category to learn from
+		return
+	}
+	if err := h.Suggestions.Observe(ctx, userID, action, msg); err != nil {
+		log.Warn().Str("userID", userID).Str("action", action).Err(err).Msg("failed to record action for suggestions")
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/legalhold"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/desponda/inbox-whisperer/internal/suggestions"
+	"github.com/desponda/inbox-whisperer/internal/testutils"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+type stubMessageActions struct {
+	err      error
+	archived []string
+	read     []string
+	unread   []string
+	trashed  []string
+}
+
+func (s *stubMessageActions) Archive(ctx context.Context, userID string, token *oauth2.Token, messageID string) error {
+	s.archived = append(s.archived, messageID)
+	return s.err
+}
+func (s *stubMessageActions) Trash(ctx context.Context, userID string, token *oauth2.Token, messageID string) error {
+	s.trashed = append(s.trashed, messageID)
+	return s.err
+}
+func (s *stubMessageActions) MarkRead(ctx context.Context, userID string, token *oauth2.Token, messageID string) error {
+	s.read = append(s.read, messageID)
+	return s.err
+}
+func (s *stubMessageActions) MarkUnread(ctx context.Context, userID string, token *oauth2.Token, messageID string) error {
+	s.unread = append(s.unread, messageID)
+	return s.err
+}
+
+// stubMessageRepo returns every message as cached from sender
+type stubMessageRepo struct {
+	sender string
+}
+
+func (s *stubMessageRepo) UpsertMessage(ctx context.Context, msg *models.EmailMessage) error {
+	return nil
+}
+func (s *stubMessageRepo) GetMessageByID(ctx context.Context, userID, id string) (*models.EmailMessage, error) {
+	return &models.EmailMessage{EmailMessageID: id, Sender: s.sender}, nil
+}
+func (s *stubMessageRepo) GetMessagesForUser(ctx context.Context, userID string, limit, offset int) ([]*models.EmailMessage, error) {
+	return nil, nil
+}
+func (s *stubMessageRepo) GetMessagesForUserCursor(ctx context.Context, userID string, limit int, afterInternalDate int64, afterMsgID string) ([]*models.EmailMessage, error) {
+	return nil, nil
+}
+func (s *stubMessageRepo) DeleteMessagesForUser(ctx context.Context, userID string) error { return nil }
+func (s *stubMessageRepo) ClearMessageContent(ctx context.Context, userID string) error   { return nil }
+func (s *stubMessageRepo) SetCategory(ctx context.Context, userID, id, category string, confidence float64) error {
+	return nil
+}
+func (s *stubMessageRepo) SetRead(ctx context.Context, userID, emailMessageID string, read bool) error {
+	return nil
+}
+func (s *stubMessageRepo) SetArchived(ctx context.Context, userID, emailMessageID string) error {
+	return nil
+}
+func (s *stubMessageRepo) DeleteMessage(ctx context.Context, userID, emailMessageID string) error {
+	return nil
+}
+
+func messageActionRequest(id string) *http.Request {
+	return testutils.NewAuthedRequest("POST", "/api/emails/"+id+"/archive", nil, testutils.WithURLParam("id", id))
+}
+
+func TestMessageActionHandler_Archive(t *testing.T) {
+	actions := &stubMessageActions{}
+	repo := &stubSuggestionRepo{}
+	messages := &stubMessageRepo{sender: "news@shop.example"}
+	h := NewMessageActionHandler(actions, messages, suggestions.NewService(repo, &stubRuleRepo{}))
+
+	w := httptest.NewRecorder()
+	h.Archive(w, messageActionRequest("m1"))
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, []string{"m1"}, actions.archived)
+	require.Len(t, repo.observed, 1)
+	require.Equal(t, "news@shop.example", repo.observed[0].Sender)
+
+	// Provider failures are reported and not learned from
+	actions.err = provider.ErrNotFound
+	w = httptest.NewRecorder()
+	h.Archive(w, messageActionRequest("gone"))
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Len(t, repo.observed, 1)
+}
+
+func TestMessageActionHandler_SetRead(t *testing.T) {
+	actions := &stubMessageActions{}
+	repo := &stubSuggestionRepo{}
+	h := NewMessageActionHandler(actions, &stubMessageRepo{sender: "news@shop.example"}, suggestions.NewService(repo, &stubRuleRepo{}))
+	put := func(id, body string) int {
+		w := httptest.NewRecorder()
+		h.SetRead(w, testutils.NewAuthedRequest("PUT", "/api/emails/"+id+"/read", strings.NewReader(body), testutils.WithURLParam("id", id)))
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusNoContent, put("m1", `{"read":true}`))
+	require.Equal(t, http.StatusNoContent, put("m2", `{"read":false}`))
+	require.Equal(t, []string{"m1"}, actions.read)
+	require.Equal(t, []string{"m2"}, actions.unread)
+	require.Len(t, repo.observed, 1, "only marking read feeds the suggestion engine")
+
+	require.Equal(t, http.StatusBadRequest, put("m3", `{}`))
+	require.Equal(t, http.StatusBadRequest, put("m3", `{"read":"yes"}`))
+}
+
+func TestMessageActionHandler_DeleteEmail(t *testing.T) {
+	actions := &stubMessageActions{}
+	repo := &stubSuggestionRepo{}
+	h := NewMessageActionHandler(actions, &stubMessageRepo{sender: "news@shop.example"}, suggestions.NewService(repo, &stubRuleRepo{}))
+	del := func(id string) int {
+		w := httptest.NewRecorder()
+		h.DeleteEmail(w, testutils.NewAuthedRequest("DELETE", "/api/emails/"+id, nil, testutils.WithURLParam("id", id)))
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusNoContent, del("m1"))
+	require.Equal(t, []string{"m1"}, actions.trashed)
+	require.Empty(t, repo.observed, "deletions do not feed the suggestion engine")
+
+	actions.err = provider.ErrNotFound
+	require.Equal(t, http.StatusNotFound, del("gone"))
+	actions.err = provider.ErrUnsupported
+	require.Equal(t, http.StatusNotImplemented, del("m2"))
+	actions.err = &provider.ScopeError{Feature: provider.FeatureModify, Missing: []string{"https://www.googleapis.com/auth/gmail.modify"}}
+	require.Equal(t, http.StatusForbidden, del("m3"))
+	actions.err = legalhold.ErrHeld
+	require.Equal(t, http.StatusLocked, del("m4"))
+}
+
+type stubLabeler struct {
+	applied []string
+}
+
+func (s *stubLabeler) ApplyLabel(ctx context.Context, userID string, token *oauth2.Token, messageID, labelName string) error {
+	s.applied = append(s.applied, messageID+":"+labelName)
+	return nil
+}
+
+func TestMessageActionHandler_Act(t *testing.T) {
+	actions := &stubMessageActions{}
+	repo := &stubSuggestionRepo{}
+	h := NewMessageActionHandler(actions, &stubMessageRepo{sender: "news@shop.example"}, suggestions.NewService(repo, &stubRuleRepo{}))
+	act := func(id, body string) int {
+		w := httptest.NewRecorder()
+		h.Act(w, testutils.NewAuthedRequest("POST", "/api/emails/"+id+"/actions", strings.NewReader(body), testutils.WithURLParam("id", id)))
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusNotImplemented, act("m1", `{"action":"apply_label","label":"Receipts"}`))
+
+	labels := &stubLabeler{}
+	h.Labels = labels
+	require.Equal(t, http.StatusNoContent, act("m1", `{"action":"archive"}`))
+	require.Equal(t, http.StatusNoContent, act("m2", `{"action":"apply_label","label":" Receipts "}`))
+	require.Equal(t, http.StatusNoContent, act("m3", `{"action":"move","label":"Receipts"}`))
+	require.Equal(t, []string{"m1", "m3"}, actions.archived)
+	require.Equal(t, []string{"m2:Receipts", "m3:Receipts"}, labels.applied)
+	require.Len(t, repo.observed, 2, "archives feed the suggestion engine")
+
+	require.Equal(t, http.StatusBadRequest, act("m4", `{"action":"move"}`))
+	require.Equal(t, http.StatusBadRequest, act("m4", `{"action":"delete"}`))
+	require.Equal(t, http.StatusBadRequest, act("m4", `{"action":"archive","extra":true}`))
+}
+
+type stubEmailUpdater struct {
+	updates []models.EmailUpdate
+	err     error
+}
+
+func (s *stubEmailUpdater) Update(ctx context.Context, userID string, token *oauth2.Token, messageID string, update models.EmailUpdate) (*models.EmailUserOverride, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.updates = append(s.updates, update)
+	return &models.EmailUserOverride{EmailMessageID: messageID, Labels: update.Labels, Starred: update.Starred, ProviderSynced: []string{"starred"}}, nil
+}
+
+func TestMessageActionHandler_UpdateEmail(t *testing.T) {
+	h := NewMessageActionHandler(&stubMessageActions{}, &stubMessageRepo{}, suggestions.NewService(&stubSuggestionRepo{}, &stubRuleRepo{}))
+	patch := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.UpdateEmail(w, testutils.NewAuthedRequest("PATCH", "/api/emails/m1", strings.NewReader(body), testutils.WithURLParam("id", "m1")))
+		return w
+	}
+
+	require.Equal(t, http.StatusNotImplemented, patch(`{"starred":true}`).Code, "updates are off until wired")
+
+	updates := &stubEmailUpdater{}
+	h.Updates = updates
+	w := patch(`{"labels":["Work"],"starred":true}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, updates.updates, 1)
+	require.Equal(t, []string{"Work"}, updates.updates[0].Labels)
+	require.Contains(t, w.Body.String(), `"provider_synced":["starred"]`)
+
+	require.Equal(t, http.StatusBadRequest, patch(`{"flagged":true}`).Code, "unknown fields are rejected")
+	updates.err = fmt.Errorf("%w: nothing to update", service.ErrInvalidUpdate)
+	require.Equal(t, http.StatusBadRequest, patch(`{}`).Code)
+	updates.err = provider.ErrNotFound
+	require.Equal(t, http.StatusNotFound, patch(`{"starred":false}`).Code)
+	updates.err = data.ErrNotFound
+	require.Equal(t, http.StatusNotFound, patch(`{"starred":false}`).Code, "messages that are not cached")
+}
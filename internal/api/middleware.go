@@ -2,23 +2,35 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/desponda/inbox-whisperer/internal/apikeys"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
 	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/ratelimit"
+	gmail "github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
 	"github.com/desponda/inbox-whisperer/internal/session"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/debuglog"
+	telemetryerrors "github.com/desponda/inbox-whisperer/internal/telemetry/errors"
+	"github.com/rs/zerolog/log"
+	"io"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 )
 
-// Context keys for userID and token
-type contextKey string
-
-const (
-	ContextUserIDKey contextKey = "userID"
-	ContextTokenKey  contextKey = "userToken"
-)
-
-// AuthMiddleware ensures the user is authenticated and attaches userID to context
+// AuthMiddleware ensures the session carries a user; handlers read it with ctxkeys.UserID
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userID := session.GetUserID(r.Context())
+		userID := ctxkeys.UserID(r.Context())
 		if userID == "" {
 			// No user session, return 401
 
@@ -26,32 +38,345 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), ContextUserIDKey, userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		telemetryerrors.SetUser(r.Context(), userID)
+		next.ServeHTTP(w, r)
 	})
 }
 
-// TokenMiddleware fetches the user's token and attaches it to context
+// APIKeyAuthenticator resolves an API key to its owner
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, raw string) (*models.APIKey, error)
+}
+
+// APIKeyMiddleware authenticates requests carrying "Authorization: Bearer iw_..." as the
+// key's owner, so CLI clients reach the same routes as browser sessions. Other requests pass
+// through unchanged; an invalid key is rejected rather than treated as anonymous.
+func APIKeyMiddleware(keys APIKeyAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || !strings.HasPrefix(raw, apikeys.KeyPrefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key, err := keys.Authenticate(r.Context(), raw)
+			if errors.Is(err, apikeys.ErrInvalidKey) {
+				RespondError(w, http.StatusUnauthorized, "invalid API key")
+				return
+			}
+			if err != nil {
+				log.Error().Err(err).Msg("failed to authenticate API key")
+				RespondError(w, http.StatusInternalServerError, "failed to authenticate API key")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctxkeys.WithUserID(r.Context(), key.UserID)))
+		})
+	}
+}
+
+// DebugLogMiddleware marks requests of users with debug logging on, see package debuglog.
+// It runs after session and API key authentication.
+func DebugLogMiddleware(toggles *debuglog.Toggles) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(toggles.Context(r.Context(), ctxkeys.UserID(r.Context()))))
+		})
+	}
+}
+
+// Request body limits. JSON endpoints get DefaultBodyLimit; routes taking uploads raise it
+// with another BodyLimitMiddleware.
+const (
+	DefaultBodyLimit int64 = 1 << 20
+	UploadBodyLimit  int64 = 32 << 20
+)
+
+// limitedBody is a request body capped by BodyLimitMiddleware. It keeps the original body so
+// a route-level limit replaces the global one instead of nesting inside it.
+type limitedBody struct {
+	io.ReadCloser
+	orig io.ReadCloser
+}
+
+// BodyLimitMiddleware caps request bodies at limit bytes. Requests declaring a larger
+// Content-Length get 413 right away; others fail on read with *http.MaxBytesError, which
+// RespondBodyError turns into the same 413.
+func BodyLimitMiddleware(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				RespondBodyTooLarge(w)
+				return
+			}
+			body := r.Body
+			if lb, ok := body.(*limitedBody); ok {
+				body = lb.orig
+			}
+			if body != nil && body != http.NoBody {
+				r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, body, limit), orig: body}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AccountIDParam is the query parameter that picks which linked account a request acts on.
+// Without it the user's default Gmail account is used.
+const AccountIDParam = "account_id"
+
+// TokenMiddleware fetches the token of the requested account and attaches it, with the
+// account ID, to context
 func TokenMiddleware(userTokens data.UserTokenRepository) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			userID, ok := r.Context().Value(ContextUserIDKey).(string)
-			if !ok || userID == "" {
+			userID := ctxkeys.UserID(r.Context())
+			if userID == "" {
 
 				http.Error(w, "not authenticated: no userID in context", http.StatusUnauthorized)
 				return
 			}
 
-			tok, _ := userTokens.GetUserToken(r.Context(), userID)
+			accountID := r.URL.Query().Get(AccountIDParam)
+			tok, _ := userTokens.GetUserToken(r.Context(), userID, data.ProviderGmail, accountID)
 
 			if tok == nil {
-
+				if accountID != "" {
+					http.Error(w, "not authenticated: no token found for account", http.StatusUnauthorized)
+					return
+				}
 				http.Error(w, "not authenticated: no token found for user", http.StatusUnauthorized)
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), ContextTokenKey, tok)
+			ctx := ctxkeys.WithToken(r.Context(), tok)
+			ctx = ctxkeys.WithAccountID(ctx, accountID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// ConsentURL is where the user grants the scopes feature needs for an account; an empty
+// accountID leaves the account choice to the provider
+func ConsentURL(feature provider.Feature, accountID string) string {
+	q := url.Values{"feature": {string(feature)}}
+	if accountID != "" {
+		q.Set(AccountIDParam, accountID)
+	}
+	return "/api/auth/login?" + q.Encode()
+}
+
+// RequireScope rejects the request with a missing_scope error, carrying the consent URL, when
+// the requested account has not granted the scopes feature needs. Accounts whose scopes are
+// unknown pass. It must run after TokenMiddleware.
+func RequireScope(scopes data.TokenScopeRepository, feature provider.Feature) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if checkScope(w, r, scopes, feature) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// checkScope is RequireScope for handlers whose need for a scope depends on the request. It
+// writes the error response and returns false when the scope is missing.
+func checkScope(w http.ResponseWriter, r *http.Request, scopes data.TokenScopeRepository, feature provider.Feature) bool {
+	accountID := ctxkeys.AccountID(r.Context())
+	granted, err := scopes.GetGrantedScopes(r.Context(), ctxkeys.UserID(r.Context()), data.ProviderGmail, accountID)
+	if err != nil {
+		log.Error().Err(err).Str("feature", string(feature)).Msg("failed to load granted scopes")
+		RespondError(w, http.StatusInternalServerError, "failed to check granted scopes")
+		return false
+	}
+	if granted != nil {
+		if missing := gmail.MissingScopes(feature, granted); len(missing) > 0 {
+			writeProviderError(w, &provider.ScopeError{Feature: feature, Missing: missing, ConsentURL: ConsentURL(feature, accountID)})
+			return false
+		}
+	}
+	return true
+}
+
+// AdminOnly rejects requests from users that neither hold the admin role nor are in
+// adminUserIDs, which bootstraps the first admin. It must run after AuthMiddleware.
+func AdminOnly(adminUserIDs []string, roles data.UserRoleRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			admin, err := isAdmin(r, adminUserIDs, roles)
+			if err != nil {
+				RespondError(w, http.StatusInternalServerError, "failed to check user role")
+				return
+			}
+			if !admin {
+				RespondError(w, http.StatusForbidden, "forbidden: admin access required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isAdmin reports whether the request's user is in adminUserIDs or holds the admin role. The
+// role is read on every request rather than kept in the session, so a grant or revoke applies
+// at once on every replica, to API keys as well as sessions.
+func isAdmin(r *http.Request, adminUserIDs []string, roles data.UserRoleRepository) (bool, error) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		return false, nil
+	}
+	if slices.Contains(adminUserIDs, userID) {
+		return true, nil
+	}
+	role, err := roles.GetRole(r.Context(), userID)
+	if errors.Is(err, data.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("failed to look up user role")
+		return false, err
+	}
+	return role == models.RoleAdmin, nil
+}
+
+// MaintenanceMiddleware answers mutating requests with 503 and a Retry-After header while
+// maintenance mode is on. Reads keep working from the cache, and /api/admin stays open so
+// admins can turn the switch off.
+func MaintenanceMiddleware(sw *maintenance.Switch) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !sw.Active() || strings.HasPrefix(r.URL.Path, "/api/admin/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			st := sw.Status()
+			msg := st.Message
+			if msg == "" {
+				msg = "service is under maintenance; try again later"
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(st.RetryAfterSeconds))
+			RespondErrorCode(w, http.StatusServiceUnavailable, "maintenance", msg)
+		})
+	}
+}
+
+// ErrCodeRateLimited is returned by RateLimitMiddleware for requests over the limit
+const ErrCodeRateLimited = "rate_limited"
+
+// RateLimitMiddleware answers requests over the limit with 429 and a Retry-After header.
+// Signed-in users, by session or API key, are limited per user and anonymous requests per
+// client IP; health probes and metrics scrapes are exempt. A failing store lets requests
+// through rather than taking the API down with it. It runs after the session and API key
+// middleware.
+func RateLimitMiddleware(l *ratelimit.Limiter, trustForwardedFor bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/healthz", "/readyz", "/metrics":
+				next.ServeHTTP(w, r)
+				return
+			}
+			ok, wait, err := l.Allow(r.Context(), ctxkeys.UserID(r.Context()), clientIP(r, trustForwardedFor))
+			if err != nil {
+				log.Warn().Err(err).Msg("rate limit check failed; letting the request through")
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(wait.Seconds())))))
+				RespondErrorCode(w, http.StatusTooManyRequests, ErrCodeRateLimited, "too many requests; retry later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP is the address a request came from: the last X-Forwarded-For entry, added by the
+// proxy in front of the server, when trusted, and the peer address otherwise
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if forwarded := r.Header.Values("X-Forwarded-For"); trustForwardedFor && len(forwarded) > 0 {
+		hops := strings.Split(forwarded[len(forwarded)-1], ",")
+		if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ErrCodeReadOnlySession is returned by ReadOnlyMiddleware for writes from a read-only session
+const ErrCodeReadOnlySession = "read_only_session"
+
+// ReadOnlyMiddleware answers mutating requests from read-only sessions (see
+// AuthHandler.HandleLogin) with 403. Every change to the user's data or mailbox, including
+// provider actions, goes through a mutating method. allowed lists paths that only change the
+// session itself, such as unlocking the cache, and stay open. It runs after the session middleware.
+func ReadOnlyMiddleware(allowed ...string) func(http.Handler) http.Handler {
+	open := make(map[string]bool, len(allowed))
+	for _, p := range allowed {
+		open[p] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if open[r.URL.Path] || !session.IsReadOnly(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			RespondErrorCode(w, http.StatusForbidden, ErrCodeReadOnlySession, "this session is read-only; sign in again to make changes")
+		})
+	}
+}
+
+// Recoverer turns a panic in a handler into a 500 problem+json response, logs it with its
+// stack and sends it to reporter, tagged with the authenticated user if AuthMiddleware ran.
+// http.ErrAbortHandler is re-panicked so net/http can abort
+// the connection as intended. It should wrap every middleware that can panic; only tracing sits
+// outside it, so the request span records the 500 it writes.
+func Recoverer(reporter telemetryerrors.Reporter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(telemetryerrors.WithScope(r.Context()))
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				stack := debug.Stack()
+				log.Error().
+					Str("method", r.Method).
+					Str("path", r.URL.Path).
+					Bytes("stack", stack).
+					Msgf("panic: %v", rec)
+				reporter.Report(r.Context(), telemetryerrors.Event{
+					Level:   telemetryerrors.LevelFatal,
+					Message: fmt.Sprintf("panic: %v", rec),
+					Stack:   stack,
+					Method:  r.Method,
+					URL:     r.URL.Path,
+					UserID:  telemetryerrors.UserID(r.Context()),
+				})
+				// Upgraded connections have no usable response writer
+				if r.Header.Get("Connection") != "Upgrade" {
+					RespondProblem(w, http.StatusInternalServerError, "an unexpected error occurred")
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
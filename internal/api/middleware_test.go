@@ -0,0 +1,283 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/mocks"
+	"github.com/desponda/inbox-whisperer/internal/ratelimit"
+	gmail "github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/desponda/inbox-whisperer/internal/session"
+	telemetryerrors "github.com/desponda/inbox-whisperer/internal/telemetry/errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+type recordingReporter struct{ events []telemetryerrors.Event }
+
+func (r *recordingReporter) Report(ctx context.Context, ev telemetryerrors.Event) {
+	r.events = append(r.events, ev)
+}
+
+func TestRecoverer(t *testing.T) {
+	reporter := &recordingReporter{}
+	h := Recoverer(reporter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/boom" {
+			panic("boom")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	require.Equal(t, http.StatusNoContent, rw.Code)
+	require.Empty(t, reporter.events)
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/boom", nil))
+	require.Equal(t, http.StatusInternalServerError, rw.Code)
+	require.Equal(t, "application/problem+json", rw.Header().Get("Content-Type"))
+	var p Problem
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &p))
+	require.Equal(t, http.StatusInternalServerError, p.Status)
+	require.Equal(t, "Internal Server Error", p.Title)
+
+	require.Len(t, reporter.events, 1)
+	ev := reporter.events[0]
+	require.Equal(t, "panic: boom", ev.Message)
+	require.Equal(t, http.MethodPost, ev.Method)
+	require.Equal(t, "/boom", ev.URL)
+	require.NotEmpty(t, ev.Stack)
+	require.Empty(t, ev.UserID)
+}
+
+func TestRecoverer_TagsAuthenticatedUser(t *testing.T) {
+	reporter := &recordingReporter{}
+	h := Recoverer(reporter)(withSession("user-1", AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))))
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusInternalServerError, rw.Code)
+	require.Len(t, reporter.events, 1)
+	require.Equal(t, "user-1", reporter.events[0].UserID)
+}
+
+func withSession(userID string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(ctxkeys.WithUserID(r.Context(), userID)))
+	})
+}
+
+func TestRecoverer_AbortHandler(t *testing.T) {
+	h := Recoverer(telemetryerrors.Nop{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestTokenMiddleware_SelectsAccount(t *testing.T) {
+	tokens := &mocks.MockUserTokenRepository{GetUserTokenFunc: func(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
+		switch accountID {
+		case "":
+			return &oauth2.Token{AccessToken: "default"}, nil
+		case "work@example.com":
+			return &oauth2.Token{AccessToken: "work"}, nil
+		}
+		return nil, data.ErrNotFound
+	}}
+	var gotToken, gotAccount string
+	h := withSession("user-1", TokenMiddleware(tokens)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotToken = ctxkeys.Token(r.Context()).AccessToken
+		gotAccount = ctxkeys.AccountID(r.Context())
+	})))
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, "default", gotToken)
+	require.Equal(t, "", gotAccount)
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/?account_id=work@example.com", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, "work", gotToken)
+	require.Equal(t, "work@example.com", gotAccount)
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/?account_id=other@example.com", nil))
+	require.Equal(t, http.StatusUnauthorized, rw.Code)
+}
+
+type stubScopes struct {
+	granted []string
+	err     error
+}
+
+func (s stubScopes) GetGrantedScopes(ctx context.Context, userID, provider, accountID string) ([]string, error) {
+	return s.granted, s.err
+}
+
+func TestRequireScope(t *testing.T) {
+	serve := func(scopes stubScopes, target string) *httptest.ResponseRecorder {
+		h := withSession("user-1", RequireScope(scopes, provider.FeatureModify)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})))
+		r := httptest.NewRequest(http.MethodPost, target, nil)
+		r = r.WithContext(ctxkeys.WithAccountID(r.Context(), r.URL.Query().Get(AccountIDParam)))
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, r)
+		return rw
+	}
+
+	require.Equal(t, http.StatusNoContent, serve(stubScopes{granted: []string{gmail.ScopeModify}}, "/").Code)
+	// Unknown grants are left to the provider
+	require.Equal(t, http.StatusNoContent, serve(stubScopes{}, "/").Code)
+	require.Equal(t, http.StatusInternalServerError, serve(stubScopes{err: errors.New("db down")}, "/").Code)
+
+	rw := serve(stubScopes{granted: gmail.LoginScopes}, "/?account_id=work@example.com")
+	require.Equal(t, http.StatusForbidden, rw.Code)
+	var body MissingScopeResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&body))
+	require.Equal(t, "missing_scope", body.Code)
+	require.Equal(t, provider.FeatureModify, body.Feature)
+	require.Equal(t, []string{gmail.ScopeModify}, body.MissingScopes)
+	require.Equal(t, "/api/auth/login?account_id=work%40example.com&feature=modify", body.ConsentURL)
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	decode := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		if err := DecodeJSON(r, &req); err != nil {
+			RespondBodyError(w, err, "invalid request body")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h := BodyLimitMiddleware(16)(decode)
+	big := `{"name":"` + strings.Repeat("x", 32) + `"}`
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a":"b"}`)))
+	require.Equal(t, http.StatusNoContent, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(big)))
+	require.Equal(t, http.StatusRequestEntityTooLarge, rw.Code, "declared length over the limit")
+	require.Contains(t, rw.Body.String(), "body_too_large")
+
+	// Without a Content-Length the limit applies while reading
+	req := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(big)))
+	req.ContentLength = -1
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rw.Code, "streamed body over the limit")
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{`)))
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+
+	// A route-level limit replaces the global one
+	raised := BodyLimitMiddleware(16)(BodyLimitMiddleware(1024)(decode))
+	req = httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(big)))
+	req.ContentLength = -1
+	rw = httptest.NewRecorder()
+	raised.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusNoContent, rw.Code)
+}
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(session.Middleware)
+	r.Use(ReadOnlyMiddleware("/unlock"))
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	r.Get("/lock", func(w http.ResponseWriter, r *http.Request) { session.SetReadOnly(w, r) })
+	r.Get("/messages", ok)
+	r.Post("/messages", ok)
+	r.Post("/unlock", ok)
+
+	var cookie *http.Cookie
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "session_id" {
+				cookie = c
+			}
+		}
+		return w
+	}
+
+	require.Equal(t, http.StatusNoContent, do("POST", "/messages").Code)
+	do("GET", "/lock")
+	require.Equal(t, http.StatusNoContent, do("GET", "/messages").Code)
+	w := do("POST", "/messages")
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Contains(t, w.Body.String(), ErrCodeReadOnlySession)
+	require.Equal(t, http.StatusNoContent, do("POST", "/unlock").Code)
+}
+
+type failingRateStore struct{}
+
+func (failingRateStore) Take(ctx context.Context, key string, l ratelimit.Limit) (bool, time.Duration, error) {
+	return false, 0, errors.New("redis down")
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Limit{PerMinute: 60, Burst: 2}, ratelimit.Limit{PerMinute: 30, Burst: 1})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	mw := RateLimitMiddleware(limiter, true)(next)
+	serve := func(path, userID, forwardedFor string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if userID != "" {
+			r = r.WithContext(ctxkeys.WithUserID(r.Context(), userID))
+		}
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		rw := httptest.NewRecorder()
+		mw.ServeHTTP(rw, r)
+		return rw
+	}
+
+	require.Equal(t, http.StatusNoContent, serve("/api/emails", "", "1.2.3.4, 10.0.0.9").Code)
+	rw := serve("/api/emails", "", "5.6.7.8, 10.0.0.9")
+	require.Equal(t, http.StatusTooManyRequests, rw.Code, "the last hop is the client the proxy saw")
+	require.Equal(t, "2", rw.Header().Get("Retry-After"))
+	require.Contains(t, rw.Body.String(), ErrCodeRateLimited)
+	require.Equal(t, http.StatusNoContent, serve("/api/emails", "", "10.0.0.10").Code)
+	require.Equal(t, http.StatusNoContent, serve("/healthz", "", "10.0.0.9").Code, "probes are exempt")
+
+	require.Equal(t, http.StatusNoContent, serve("/api/emails", "u1", "10.0.0.9").Code, "signed-in users have their own bucket")
+	require.Equal(t, http.StatusNoContent, serve("/api/emails", "u1", "10.0.0.9").Code)
+	require.Equal(t, http.StatusTooManyRequests, serve("/api/emails", "u1", "10.0.0.9").Code)
+
+	mw = RateLimitMiddleware(ratelimit.NewLimiter(failingRateStore{}, ratelimit.Limit{PerMinute: 1, Burst: 1}, ratelimit.Limit{}), false)(next)
+	require.Equal(t, http.StatusNoContent, serve("/api/emails", "u1", "").Code, "a failing store lets requests through")
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "192.0.2.1:5000"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	require.Equal(t, "192.0.2.1", clientIP(r, false), "forwarded headers are ignored unless trusted")
+	require.Equal(t, "203.0.113.7", clientIP(r, true))
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/oauthclient"
+)
+
+// OAuthClientRotator reads and rotates OAuth app credentials (see oauthclient.Store)
+type OAuthClientRotator interface {
+	Status() *models.OAuthClientCredentials
+	Rotate(ctx context.Context, clientID, clientSecret string, grace time.Duration, rotatedBy string) (*models.OAuthClientCredentials, error)
+}
+
+type OAuthClientHandler struct {
+	Store OAuthClientRotator
+}
+
+func NewOAuthClientHandler(store OAuthClientRotator) *OAuthClientHandler {
+	return &OAuthClientHandler{Store: store}
+}
+
+// OAuthClientRotation carries new OAuth app credentials. GracePeriod is a Go duration
+// ("36h"); empty means oauthclient.DefaultGracePeriod and "0s" drops the old credentials at once.
+type OAuthClientRotation struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	GracePeriod  string `json:"grace_period,omitempty"`
+}
+
+// AdminGet handles GET /api/admin/oauth/google: the credentials in use, without secrets
+func (h *OAuthClientHandler) AdminGet(w http.ResponseWriter, r *http.Request) {
+	RespondJSON(w, http.StatusOK, h.Store.Status())
+}
+
+// AdminPut handles PUT /api/admin/oauth/google: switches to new credentials, keeping the old
+// ones as a fallback for token exchanges and refreshes during the grace period
+func (h *OAuthClientHandler) AdminPut(w http.ResponseWriter, r *http.Request) {
+	var req OAuthClientRotation
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	var grace time.Duration
+	if req.GracePeriod != "" {
+		d, err := time.ParseDuration(req.GracePeriod)
+		if err != nil || d < 0 {
+			RespondError(w, http.StatusBadRequest, "grace_period must be a non-negative duration such as \"24h\"")
+			return
+		}
+		// The store reads 0 as the default and a negative period as none
+		grace = d
+		if d == 0 {
+			grace = -1
+		}
+	}
+	status, err := h.Store.Rotate(r.Context(), req.ClientID, req.ClientSecret, grace, ctxkeys.UserID(r.Context()))
+	if errors.Is(err, oauthclient.ErrInvalidCredentials) {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to rotate credentials")
+		return
+	}
+	RespondJSON(w, http.StatusOK, status)
+}
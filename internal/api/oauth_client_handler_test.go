@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/oauthclient"
+	"github.com/desponda/inbox-whisperer/internal/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+type stubOAuthClientRotator struct {
+	clientID  string
+	grace     time.Duration
+	rotatedBy string
+}
+
+func (s *stubOAuthClientRotator) Status() *models.OAuthClientCredentials {
+	return &models.OAuthClientCredentials{Provider: "google", ClientID: s.clientID}
+}
+
+func (s *stubOAuthClientRotator) Rotate(ctx context.Context, clientID, clientSecret string, grace time.Duration, rotatedBy string) (*models.OAuthClientCredentials, error) {
+	if clientID == "" || clientSecret == "" {
+		return nil, fmt.Errorf("%w: client_id and client_secret are required", oauthclient.ErrInvalidCredentials)
+	}
+	s.clientID, s.grace, s.rotatedBy = clientID, grace, rotatedBy
+	return &models.OAuthClientCredentials{Provider: "google", ClientID: clientID, ClientSecret: clientSecret}, nil
+}
+
+func TestOAuthClientHandler(t *testing.T) {
+	store := &stubOAuthClientRotator{clientID: "old"}
+	h := NewOAuthClientHandler(store)
+	put := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.AdminPut(w, testutils.NewAuthedRequest("PUT", "/api/admin/oauth/google", strings.NewReader(body)))
+		return w
+	}
+
+	w := put(`{"client_id":"new","client_secret":"s3cret","grace_period":"36h"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 36*time.Hour, store.grace)
+	require.Equal(t, "user1", store.rotatedBy)
+	require.NotContains(t, w.Body.String(), "s3cret")
+
+	require.Equal(t, http.StatusOK, put(`{"client_id":"new","client_secret":"s"}`).Code)
+	require.Equal(t, time.Duration(0), store.grace)
+	require.Equal(t, http.StatusOK, put(`{"client_id":"new","client_secret":"s","grace_period":"0s"}`).Code)
+	require.Less(t, store.grace, time.Duration(0))
+
+	require.Equal(t, http.StatusBadRequest, put(`{"client_id":"new","client_secret":"s","grace_period":"soon"}`).Code)
+	require.Equal(t, http.StatusBadRequest, put(`{"client_id":"new"}`).Code)
+
+	w = httptest.NewRecorder()
+	h.AdminGet(w, testutils.NewAuthedRequest("GET", "/api/admin/oauth/google", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"client_id":"new"`)
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/offboarding"
+)
+
+// Offboarder offboards users (see offboarding.Service)
+type Offboarder interface {
+	Offboard(ctx context.Context, requestedBy string, userIDs []string) (*offboarding.SignedReport, error)
+}
+
+type OffboardingHandler struct {
+	Service Offboarder
+}
+
+func NewOffboardingHandler(svc Offboarder) *OffboardingHandler {
+	return &OffboardingHandler{Service: svc}
+}
+
+// OffboardRequest lists the users to offboard
+type OffboardRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// AdminOffboard handles POST /api/admin/offboard: revokes the users' credentials, purges their
+// mail, deactivates them and answers with the signed report of what was done
+func (h *OffboardingHandler) AdminOffboard(w http.ResponseWriter, r *http.Request) {
+	adminID := ctxkeys.UserID(r.Context())
+	var req OffboardRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	ids := make([]string, 0, len(req.UserIDs))
+	seen := map[string]bool{}
+	for _, id := range req.UserIDs {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		RespondError(w, http.StatusBadRequest, "user_ids is required")
+		return
+	}
+	if len(ids) > offboarding.MaxUsers {
+		RespondError(w, http.StatusBadRequest, fmt.Sprintf("at most %d users per request", offboarding.MaxUsers))
+		return
+	}
+	report, err := h.Service.Offboard(r.Context(), adminID, ids)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to offboard users")
+		return
+	}
+	RespondJSON(w, http.StatusOK, report)
+}
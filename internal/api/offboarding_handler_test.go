@@ -0,0 +1,42 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/offboarding"
+	"github.com/desponda/inbox-whisperer/internal/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+type stubOffboarder struct {
+	requestedBy string
+	userIDs     []string
+}
+
+func (s *stubOffboarder) Offboard(ctx context.Context, requestedBy string, userIDs []string) (*offboarding.SignedReport, error) {
+	s.requestedBy, s.userIDs = requestedBy, userIDs
+	return &offboarding.SignedReport{Algorithm: offboarding.SignatureAlgorithm, Signature: "sig"}, nil
+}
+
+func TestOffboardingHandler_AdminOffboard(t *testing.T) {
+	svc := &stubOffboarder{}
+	h := NewOffboardingHandler(svc)
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.AdminOffboard(w, testutils.NewAuthedRequest("POST", "/api/admin/offboard", strings.NewReader(body)))
+		return w
+	}
+
+	w := post(`{"user_ids":["u1"," u2 ","u1",""]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, []string{"u1", "u2"}, svc.userIDs)
+	require.Equal(t, "user1", svc.requestedBy)
+	require.Contains(t, w.Body.String(), `"signature":"sig"`)
+
+	require.Equal(t, http.StatusBadRequest, post(`{"user_ids":[]}`).Code)
+	require.Equal(t, http.StatusBadRequest, post(`{"users":["u1"]}`).Code)
+}
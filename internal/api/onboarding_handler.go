@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/onboarding"
+	"github.com/go-chi/chi/v5"
+)
+
+type OnboardingHandler struct {
+	Service *onboarding.Service
+}
+
+func NewOnboardingHandler(svc *onboarding.Service) *OnboardingHandler {
+	return &OnboardingHandler{Service: svc}
+}
+
+// GetOnboarding handles GET /api/onboarding
+func (h *OnboardingHandler) GetOnboarding(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	status, err := h.Service.Status(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load onboarding status")
+		return
+	}
+	RespondJSON(w, http.StatusOK, status)
+}
+
+// CompleteStep handles POST /api/onboarding/steps/{step}
+func (h *OnboardingHandler) CompleteStep(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	status, err := h.Service.CompleteStep(r.Context(), userID, chi.URLParam(r, "step"))
+	switch {
+	case errors.Is(err, onboarding.ErrUnknownStep):
+		RespondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, onboarding.ErrNotUserStep):
+		RespondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, onboarding.ErrStepLocked):
+		RespondError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		RespondError(w, http.StatusInternalServerError, "failed to update onboarding status")
+	default:
+		RespondJSON(w, http.StatusOK, status)
+	}
+}
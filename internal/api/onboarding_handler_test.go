@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/onboarding"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+type stubOnboardingRepo struct {
+	steps map[string]time.Time
+}
+
+func (s *stubOnboardingRepo) MarkStep(ctx context.Context, userID, step string) error {
+	s.steps[step] = time.Now()
+	return nil
+}
+func (s *stubOnboardingRepo) CompletedSteps(ctx context.Context, userID string) (map[string]time.Time, error) {
+	return s.steps, nil
+}
+
+func onboardingRequest(method, step string) *http.Request {
+	r := httptest.NewRequest(method, "/api/onboarding", nil)
+	ctx := ctxkeys.WithUserID(r.Context(), "user1")
+	if step != "" {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("step", step)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+	}
+	return r.WithContext(ctx)
+}
+
+func TestOnboardingHandler(t *testing.T) {
+	repo := &stubOnboardingRepo{steps: map[string]time.Time{onboarding.StepAccountLinked: time.Now()}}
+	h := NewOnboardingHandler(onboarding.NewService(repo))
+
+	w := httptest.NewRecorder()
+	h.GetOnboarding(w, onboardingRequest("GET", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+	var status models.OnboardingStatus
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+	require.Equal(t, onboarding.StepFirstSyncComplete, status.CurrentStep)
+
+	tests := []struct {
+		step       string
+		wantStatus int
+	}{
+		{onboarding.StepCategoriesReviewed, http.StatusConflict},
+		{onboarding.StepFirstSyncComplete, http.StatusForbidden},
+		{"bogus", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w = httptest.NewRecorder()
+		h.CompleteStep(w, onboardingRequest("POST", tt.step))
+		require.Equal(t, tt.wantStatus, w.Code, tt.step)
+	}
+
+	repo.steps[onboarding.StepFirstSyncComplete] = time.Now()
+	w = httptest.NewRecorder()
+	h.CompleteStep(w, onboardingRequest("POST", onboarding.StepCategoriesReviewed))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	h.GetOnboarding(w, httptest.NewRequest("GET", "/api/onboarding", nil))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
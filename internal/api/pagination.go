@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Page is the body of a list endpoint called with ?envelope=true. NextCursor is passed back as
+// ?cursor= for the next page and is empty on the last one. TotalEstimate counts everything the
+// list could return; it is left out when it is not known, e.g. for lists served straight from
+// the provider.
+type Page[T any] struct {
+	Items         []T    `json:"items"`
+	NextCursor    string `json:"next_cursor,omitempty"`
+	Limit         int    `json:"limit"`
+	TotalEstimate *int64 `json:"total_estimate,omitempty"`
+}
+
+// Cursor is where a page continues: after the item with InternalDate and ID in the local cache,
+// or at the provider's PageToken for lists served straight from the provider
+type Cursor struct {
+	InternalDate int64
+	ID           string
+	PageToken    string
+}
+
+// MessageCounter estimates list totals for Page.TotalEstimate (see data.MessageCounter)
+type MessageCounter interface {
+	CountMessages(ctx context.Context, userID string) (int64, error)
+	CountThreads(ctx context.Context, userID string) (int64, error)
+}
+
+// errInvalidCursor is answered with 400
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Encode returns the cursor in its opaque form, URL-safe base64 so it can go in a query string
+func (c Cursor) Encode() string {
+	raw := "c:" + strconv.FormatInt(c.InternalDate, 10) + ":" + c.ID
+	if c.PageToken != "" {
+		raw = "p:" + c.PageToken
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor made by Cursor.Encode
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, errInvalidCursor
+	}
+	kind, rest, _ := strings.Cut(string(raw), ":")
+	switch kind {
+	case "p":
+		if rest != "" {
+			return Cursor{PageToken: rest}, nil
+		}
+	case "c":
+		date, id, ok := strings.Cut(rest, ":")
+		n, err := strconv.ParseInt(date, 10, 64)
+		if ok && err == nil && id != "" {
+			return Cursor{InternalDate: n, ID: id}, nil
+		}
+	}
+	return Cursor{}, errInvalidCursor
+}
+
+// wantsEnvelope reports whether the request asked for a Page body with ?envelope=true
+func wantsEnvelope(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("envelope")
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, errors.New("envelope must be true or false")
+	}
+	return b, nil
+}
+
+// pageCursor reads ?cursor=, falling back to the after_internal_date and after_id pair that
+// clients assembled before cursors existed
+func pageCursor(r *http.Request) (Cursor, error) {
+	q := r.URL.Query()
+	if v := q.Get("cursor"); v != "" {
+		if q.Get("after_id") != "" || q.Get("after_internal_date") != "" || q.Get("page_token") != "" {
+			return Cursor{}, errors.New("cursor cannot be combined with after_internal_date, after_id or page_token")
+		}
+		return DecodeCursor(v)
+	}
+	c := Cursor{ID: q.Get("after_id")}
+	if v := q.Get("after_internal_date"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return Cursor{}, errors.New("after_internal_date must be an integer")
+		}
+		c.InternalDate = n
+	}
+	return c, nil
+}
+
+// totalEstimate runs count for Page.TotalEstimate, leaving the estimate out when it fails
+func totalEstimate(ctx context.Context, count func(context.Context, string) (int64, error), userID string) *int64 {
+	n, err := count(ctx, userID)
+	if err != nil {
+		log.Warn().Err(err).Str("user_id", userID).Msg("pagination: failed to count list total")
+		return nil
+	}
+	return &n
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	for _, c := range []Cursor{
+		{InternalDate: 1760000000000, ID: "18f2:a"},
+		{PageToken: "provider:token/2"},
+	} {
+		got, err := DecodeCursor(c.Encode())
+		require.NoError(t, err)
+		require.Equal(t, c, got)
+	}
+	for _, bad := range []string{"", "not base64!", Cursor{}.Encode(), "Yzp4OmlkMQ"} {
+		_, err := DecodeCursor(bad)
+		require.ErrorIs(t, err, errInvalidCursor, bad)
+	}
+}
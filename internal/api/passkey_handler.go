@@ -0,0 +1,289 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/passkeys"
+	"github.com/desponda/inbox-whisperer/internal/session"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrCodeStepUpRequired is returned by RequireStepUp when the session must confirm a passkey
+const ErrCodeStepUpRequired = "step_up_required"
+
+// StepUpChecker reports whether a user has passkeys and so must step up (see passkeys.Service)
+type StepUpChecker interface {
+	Enrolled(ctx context.Context, userID string) (bool, error)
+}
+
+// PasskeyService registers passkeys and verifies assertions (see passkeys.Service)
+type PasskeyService interface {
+	StepUpChecker
+	List(ctx context.Context, userID string) ([]*models.Passkey, error)
+	Delete(ctx context.Context, userID string, id int64) error
+	CreationOptions(ctx context.Context, userID, challenge string) (*passkeys.CreationOptions, error)
+	FinishRegistration(ctx context.Context, userID, challenge, name string, resp *passkeys.RegistrationResponse) (*models.Passkey, error)
+	RequestOptions(ctx context.Context, userID, challenge string) (*passkeys.RequestOptions, error)
+	FinishAssertion(ctx context.Context, userID, challenge string, resp *passkeys.AssertionResponse) error
+}
+
+// PasskeyRegistration is the body of POST /api/users/me/passkeys
+type PasskeyRegistration struct {
+	Name       string                         `json:"name"`
+	Credential *passkeys.RegistrationResponse `json:"credential"`
+}
+
+// StepUpStatus is the body of the step-up endpoints
+type StepUpStatus struct {
+	// Required is true once the user has a passkey
+	Required bool `json:"required"`
+	// VerifiedUntil is when this session's step-up lapses; absent if it has none
+	VerifiedUntil *time.Time `json:"verified_until,omitempty"`
+}
+
+// PasskeyHandler manages passkeys and the step-up verification made with them
+type PasskeyHandler struct {
+	Passkeys PasskeyService
+}
+
+func NewPasskeyHandler(svc PasskeyService) *PasskeyHandler {
+	return &PasskeyHandler{Passkeys: svc}
+}
+
+// RequireStepUp rejects requests from users with passkeys unless their session confirmed one
+// within passkeys.StepUpWindow. Users without passkeys pass, so step-up stays opt-in. It must
+// run after AuthMiddleware.
+func RequireStepUp(checker StepUpChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := ctxkeys.UserID(r.Context())
+			if stepUpUntil(r, userID) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			enrolled, err := checker.Enrolled(r.Context(), userID)
+			if err != nil {
+				log.Error().Err(err).Str("user_id", userID).Msg("failed to load passkeys")
+				RespondError(w, http.StatusInternalServerError, "failed to check step-up")
+				return
+			}
+			if enrolled {
+				RespondErrorCode(w, http.StatusForbidden, ErrCodeStepUpRequired, "confirm with a passkey to continue")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// stepUpUntil returns when the session's step-up for userID lapses, or nil if it has none in force
+func stepUpUntil(r *http.Request, userID string) *time.Time {
+	marker := session.GetStepUp(r)
+	if marker == nil || marker.UserID != userID {
+		return nil
+	}
+	until := marker.VerifiedAt.Add(passkeys.StepUpWindow)
+	if !time.Now().Before(until) {
+		return nil
+	}
+	return &until
+}
+
+// ListPasskeys handles GET /api/users/me/passkeys
+func (h *PasskeyHandler) ListPasskeys(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	list, err := h.Passkeys.List(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to list passkeys")
+		return
+	}
+	if list == nil {
+		list = []*models.Passkey{}
+	}
+	RespondJSON(w, http.StatusOK, list)
+}
+
+// CreationOptions handles POST /api/users/me/passkeys/options: starts a registration whose
+// challenge is kept in the session
+func (h *PasskeyHandler) CreationOptions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := sessionUser(w, r)
+	if !ok {
+		return
+	}
+	challenge, err := passkeys.NewChallenge()
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to create challenge")
+		return
+	}
+	opts, err := h.Passkeys.CreationOptions(r.Context(), userID, challenge)
+	if errors.Is(err, passkeys.ErrTooManyPasskeys) {
+		RespondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to start registration")
+		return
+	}
+	issueChallenge(w, r, userID, passkeys.CeremonyCreate, challenge)
+	RespondJSON(w, http.StatusOK, opts)
+}
+
+// Register handles POST /api/users/me/passkeys: verifies the new credential and stores it.
+// Registering counts as a step-up, since the user has just used the passkey.
+func (h *PasskeyHandler) Register(w http.ResponseWriter, r *http.Request) {
+	userID, ok := sessionUser(w, r)
+	if !ok {
+		return
+	}
+	var req PasskeyRegistration
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	if req.Credential == nil {
+		RespondError(w, http.StatusBadRequest, "credential is required")
+		return
+	}
+	challenge, ok := takeChallenge(w, r, userID, passkeys.CeremonyCreate)
+	if !ok {
+		return
+	}
+	p, err := h.Passkeys.FinishRegistration(r.Context(), userID, challenge, req.Name, req.Credential)
+	switch {
+	case errors.Is(err, passkeys.ErrInvalidResponse), errors.Is(err, passkeys.ErrInvalidName):
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, passkeys.ErrTooManyPasskeys), errors.Is(err, data.ErrAlreadyExists):
+		RespondError(w, http.StatusConflict, "passkey already registered or limit reached")
+		return
+	case err != nil:
+		RespondError(w, http.StatusInternalServerError, "failed to register passkey")
+		return
+	}
+	session.SetStepUp(w, r, session.StepUp{UserID: userID, VerifiedAt: time.Now()})
+	RespondJSON(w, http.StatusCreated, p)
+}
+
+// DeletePasskey handles DELETE /api/users/me/passkeys/{id}; removing the last passkey turns
+// step-up off
+func (h *PasskeyHandler) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid passkey id")
+		return
+	}
+	if err := h.Passkeys.Delete(r.Context(), userID, id); errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "passkey not found")
+		return
+	} else if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to delete passkey")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetStepUp handles GET /api/auth/step-up
+func (h *PasskeyHandler) GetStepUp(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	enrolled, err := h.Passkeys.Enrolled(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load passkeys")
+		return
+	}
+	RespondJSON(w, http.StatusOK, StepUpStatus{Required: enrolled, VerifiedUntil: stepUpUntil(r, userID)})
+}
+
+// StepUpOptions handles POST /api/auth/step-up/options: starts an assertion whose challenge is
+// kept in the session
+func (h *PasskeyHandler) StepUpOptions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := sessionUser(w, r)
+	if !ok {
+		return
+	}
+	challenge, err := passkeys.NewChallenge()
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to create challenge")
+		return
+	}
+	opts, err := h.Passkeys.RequestOptions(r.Context(), userID, challenge)
+	if errors.Is(err, passkeys.ErrNoPasskeys) {
+		RespondError(w, http.StatusConflict, "no passkeys registered")
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to start step-up")
+		return
+	}
+	issueChallenge(w, r, userID, passkeys.CeremonyGet, challenge)
+	RespondJSON(w, http.StatusOK, opts)
+}
+
+// StepUp handles POST /api/auth/step-up: verifies the assertion and marks the session as
+// stepped up for passkeys.StepUpWindow
+func (h *PasskeyHandler) StepUp(w http.ResponseWriter, r *http.Request) {
+	userID, ok := sessionUser(w, r)
+	if !ok {
+		return
+	}
+	var req passkeys.AssertionResponse
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	challenge, ok := takeChallenge(w, r, userID, passkeys.CeremonyGet)
+	if !ok {
+		return
+	}
+	if err := h.Passkeys.FinishAssertion(r.Context(), userID, challenge, &req); errors.Is(err, passkeys.ErrInvalidResponse) {
+		log.Warn().Err(err).Str("user_id", userID).Msg("passkey step-up rejected")
+		RespondError(w, http.StatusUnauthorized, "passkey verification failed")
+		return
+	} else if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to verify passkey")
+		return
+	}
+	now := time.Now()
+	session.SetStepUp(w, r, session.StepUp{UserID: userID, VerifiedAt: now})
+	until := now.Add(passkeys.StepUpWindow)
+	RespondJSON(w, http.StatusOK, StepUpStatus{Required: true, VerifiedUntil: &until})
+}
+
+// sessionUser returns the signed-in user of a browser session. Ceremonies keep their challenge
+// in the session, so API key requests cannot take part.
+func sessionUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		RespondError(w, http.StatusBadRequest, "passkeys can only be used from a browser session")
+		return "", false
+	}
+	return ctxkeys.UserID(r.Context()), true
+}
+
+func issueChallenge(w http.ResponseWriter, r *http.Request, userID, ceremony, challenge string) {
+	session.SetChallenge(w, r, session.Challenge{
+		UserID:    userID,
+		Ceremony:  ceremony,
+		Value:     challenge,
+		ExpiresAt: time.Now().Add(passkeys.ChallengeTTL),
+	})
+}
+
+// takeChallenge consumes the session's challenge for the ceremony, answering 400 if there is
+// no unexpired one for userID
+func takeChallenge(w http.ResponseWriter, r *http.Request, userID, ceremony string) (string, bool) {
+	c := session.TakeChallenge(w, r)
+	if c == nil || c.UserID != userID || c.Ceremony != ceremony || !time.Now().Before(c.ExpiresAt) {
+		RespondError(w, http.StatusBadRequest, "no pending passkey challenge; request new options")
+		return "", false
+	}
+	return c.Value, true
+}
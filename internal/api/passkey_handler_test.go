@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/passkeys"
+	"github.com/desponda/inbox-whisperer/internal/session"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// stubPasskeys accepts any answer to the challenge it last issued
+type stubPasskeys struct {
+	list      []*models.Passkey
+	challenge string
+}
+
+func (s *stubPasskeys) Enrolled(ctx context.Context, userID string) (bool, error) {
+	return len(s.list) > 0, nil
+}
+func (s *stubPasskeys) List(ctx context.Context, userID string) ([]*models.Passkey, error) {
+	return s.list, nil
+}
+func (s *stubPasskeys) Delete(ctx context.Context, userID string, id int64) error {
+	s.list = nil
+	return nil
+}
+func (s *stubPasskeys) CreationOptions(ctx context.Context, userID, challenge string) (*passkeys.CreationOptions, error) {
+	s.challenge = challenge
+	return &passkeys.CreationOptions{Challenge: challenge}, nil
+}
+func (s *stubPasskeys) FinishRegistration(ctx context.Context, userID, challenge, name string, resp *passkeys.RegistrationResponse) (*models.Passkey, error) {
+	if challenge != s.challenge {
+		return nil, passkeys.ErrInvalidResponse
+	}
+	p := &models.Passkey{ID: 1, UserID: userID, CredentialID: resp.RawID, Name: name}
+	s.list = append(s.list, p)
+	return p, nil
+}
+func (s *stubPasskeys) RequestOptions(ctx context.Context, userID, challenge string) (*passkeys.RequestOptions, error) {
+	if len(s.list) == 0 {
+		return nil, passkeys.ErrNoPasskeys
+	}
+	s.challenge = challenge
+	return &passkeys.RequestOptions{Challenge: challenge}, nil
+}
+func (s *stubPasskeys) FinishAssertion(ctx context.Context, userID, challenge string, resp *passkeys.AssertionResponse) error {
+	if challenge != s.challenge || resp.Response.Signature != "good" {
+		return passkeys.ErrInvalidResponse
+	}
+	return nil
+}
+
+func TestPasskeyHandler_StepUp(t *testing.T) {
+	svc := &stubPasskeys{}
+	h := NewPasskeyHandler(svc)
+	r := chi.NewRouter()
+	r.Use(session.Middleware)
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(ctxkeys.WithUserID(r.Context(), "user1")))
+		})
+	})
+	r.Get("/api/users/me/passkeys", h.ListPasskeys)
+	r.With(RequireStepUp(svc)).Post("/api/users/me/passkeys/options", h.CreationOptions)
+	r.With(RequireStepUp(svc)).Post("/api/users/me/passkeys", h.Register)
+	r.Get("/api/auth/step-up", h.GetStepUp)
+	r.Post("/api/auth/step-up/options", h.StepUpOptions)
+	r.Post("/api/auth/step-up", h.StepUp)
+	r.With(RequireStepUp(svc)).Post("/sensitive", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	var cookie *http.Cookie
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "session_id" {
+				cookie = c
+			}
+		}
+		return w
+	}
+
+	// Without passkeys nothing is asked for
+	require.Equal(t, http.StatusNoContent, do("POST", "/sensitive", "").Code)
+	require.Equal(t, http.StatusConflict, do("POST", "/api/auth/step-up/options", "").Code)
+	require.Equal(t, "[]\n", do("GET", "/api/users/me/passkeys", "").Body.String())
+
+	// Registering needs the challenge from the options and steps the session up
+	credential := `{"name":"laptop","credential":{"id":"cred","rawId":"cred","type":"public-key","response":{"clientDataJSON":"e30","attestationObject":"oA"}}}`
+	require.Equal(t, http.StatusBadRequest, do("POST", "/api/users/me/passkeys", credential).Code)
+	require.Equal(t, http.StatusOK, do("POST", "/api/users/me/passkeys/options", "").Code)
+	w := do("POST", "/api/users/me/passkeys", credential)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Contains(t, w.Body.String(), `"name":"laptop"`)
+	require.Equal(t, http.StatusNoContent, do("POST", "/sensitive", "").Code)
+
+	// A new session has to step up
+	cookie = nil
+	w = do("POST", "/sensitive", "")
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Contains(t, w.Body.String(), ErrCodeStepUpRequired)
+	require.Equal(t, http.StatusForbidden, do("POST", "/api/users/me/passkeys/options", "").Code, "adding a passkey needs a step-up too")
+
+	assertion := func(sig string) string {
+		return `{"id":"cred","rawId":"cred","type":"public-key","response":{"clientDataJSON":"e30","authenticatorData":"AA","signature":"` + sig + `"}}`
+	}
+	require.Equal(t, http.StatusBadRequest, do("POST", "/api/auth/step-up", assertion("good")).Code, "no challenge was issued")
+	require.Equal(t, http.StatusOK, do("POST", "/api/auth/step-up/options", "").Code)
+	require.Equal(t, http.StatusUnauthorized, do("POST", "/api/auth/step-up", assertion("bad")).Code)
+	require.Equal(t, http.StatusBadRequest, do("POST", "/api/auth/step-up", assertion("good")).Code, "a challenge is answered once")
+	require.Equal(t, http.StatusOK, do("POST", "/api/auth/step-up/options", "").Code)
+	require.Equal(t, http.StatusOK, do("POST", "/api/auth/step-up", assertion("good")).Code)
+	require.Equal(t, http.StatusNoContent, do("POST", "/sensitive", "").Code)
+
+	var status StepUpStatus
+	require.NoError(t, json.NewDecoder(do("GET", "/api/auth/step-up", "").Body).Decode(&status))
+	require.True(t, status.Required)
+	require.NotNil(t, status.VerifiedUntil)
+}
+
+func TestPasskeyHandler_RefusesAPIKeys(t *testing.T) {
+	h := NewPasskeyHandler(&stubPasskeys{})
+	req := httptest.NewRequest("POST", "/api/auth/step-up/options", nil)
+	req.Header.Set("Authorization", "Bearer iw_secret")
+	w := httptest.NewRecorder()
+	h.StepUpOptions(w, req.WithContext(ctxkeys.WithUserID(req.Context(), "user1")))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
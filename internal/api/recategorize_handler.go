@@ -0,0 +1,111 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/recategorize"
+)
+
+type RecategorizeHandler struct {
+	Jobs *recategorize.Runner
+}
+
+func NewRecategorizeHandler(runner *recategorize.Runner) *RecategorizeHandler {
+	return &RecategorizeHandler{Jobs: runner}
+}
+
+// RecategorizeRequest optionally limits an admin job to one user
+type RecategorizeRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// AdminEnqueue handles POST /api/admin/recategorize. An empty body re-categorizes every user.
+func (h *RecategorizeHandler) AdminEnqueue(w http.ResponseWriter, r *http.Request) {
+	adminID := ctxkeys.UserID(r.Context())
+	var req RecategorizeRequest
+	if err := DecodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	h.enqueue(w, r, adminID, req.UserID)
+}
+
+// AdminGetJob handles GET /api/admin/recategorize/{id}
+func (h *RecategorizeHandler) AdminGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+	RespondJSON(w, http.StatusOK, job)
+}
+
+// EnqueueMine handles POST /api/users/me/recategorize
+func (h *RecategorizeHandler) EnqueueMine(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	h.enqueue(w, r, userID, userID)
+}
+
+// GetMyJob handles GET /api/users/me/recategorize/{id}; other users' jobs are reported as not found
+func (h *RecategorizeHandler) GetMyJob(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	job, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+	if job.UserID != userID {
+		RespondError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	RespondJSON(w, http.StatusOK, job)
+}
+
+func (h *RecategorizeHandler) enqueue(w http.ResponseWriter, r *http.Request, requestedBy, userID string) {
+	job, err := h.Jobs.Enqueue(r.Context(), requestedBy, userID)
+	if errors.Is(err, recategorize.ErrJobActive) {
+		RespondErrorCode(w, http.StatusConflict, "recategorize_job_active",
+			"re-categorization job "+strconv.FormatInt(job.ID, 10)+" is already "+job.Status)
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to enqueue re-categorization job")
+		return
+	}
+	RespondJSON(w, http.StatusAccepted, job)
+}
+
+func (h *RecategorizeHandler) job(w http.ResponseWriter, r *http.Request) (*models.RecategorizeJob, bool) {
+	idParam, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid job id")
+		return nil, false
+	}
+	job, err := h.Jobs.Job(r.Context(), id)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "job not found")
+		return nil, false
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load job")
+		return nil, false
+	}
+	return job, true
+}
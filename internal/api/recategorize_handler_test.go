@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ai"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/recategorize"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// stubJobRepo stores jobs in memory; every user has 10 cached messages
+type stubJobRepo struct {
+	jobs []*models.RecategorizeJob
+}
+
+func (s *stubJobRepo) CreateJob(ctx context.Context, j *models.RecategorizeJob) error {
+	j.ID, j.Status = int64(len(s.jobs)+1), models.JobQueued
+	s.jobs = append(s.jobs, j)
+	return nil
+}
+func (s *stubJobRepo) GetJob(ctx context.Context, id int64) (*models.RecategorizeJob, error) {
+	if id < 1 || id > int64(len(s.jobs)) {
+		return nil, data.ErrNotFound
+	}
+	return s.jobs[id-1], nil
+}
+func (s *stubJobRepo) UpdateJob(ctx context.Context, j *models.RecategorizeJob) error { return nil }
+func (s *stubJobRepo) ActiveJob(ctx context.Context, userID string) (*models.RecategorizeJob, error) {
+	for _, j := range s.jobs {
+		if j.UserID == userID && !j.Finished() {
+			return j, nil
+		}
+	}
+	return nil, nil
+}
+func (s *stubJobRepo) UsersWithMessages(ctx context.Context) ([]string, error) {
+	return []string{"user1", "user2"}, nil
+}
+func (s *stubJobRepo) MessageStats(ctx context.Context, userID string, maxBody int) (int, int, error) {
+	return 10, 1000, nil
+}
+
+func newTestRecategorizeHandler(sharing map[string]bool) *RecategorizeHandler {
+	settings := &stubSettingsRepo{settings: map[string]models.UserSettings{}}
+	for id, ok := range sharing {
+		settings.settings[id] = models.UserSettings{AIDataSharing: ok}
+	}
+	gateway := ai.NewGateway(stubLLM{}, settings, false)
+	return NewRecategorizeHandler(recategorize.NewRunner(&stubJobRepo{}, nil, gateway, &stubJobQueue{}))
+}
+
+func recategorizeRequest(method, userID, id, body string) *http.Request {
+	r := httptest.NewRequest(method, "/api/recategorize", strings.NewReader(body))
+	ctx := ctxkeys.WithUserID(r.Context(), userID)
+	if id != "" {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+	}
+	return r.WithContext(ctx)
+}
+
+func TestRecategorizeHandler_AdminEnqueue(t *testing.T) {
+	h := newTestRecategorizeHandler(map[string]bool{"user1": true})
+
+	rw := httptest.NewRecorder()
+	h.AdminEnqueue(rw, recategorizeRequest(http.MethodPost, "admin", "", ""))
+	require.Equal(t, http.StatusAccepted, rw.Code)
+	var job models.RecategorizeJob
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&job))
+	require.Equal(t, models.JobQueued, job.Status)
+	require.Equal(t, 20, job.Total)
+	require.Equal(t, 10, job.AIMessages, "only the consenting user's messages are AI-backed")
+	require.Equal(t, ai.EstimateCategorizationTokens(10, 1000), job.EstimatedTokens)
+
+	rw = httptest.NewRecorder()
+	h.AdminEnqueue(rw, recategorizeRequest(http.MethodPost, "admin", "", ""))
+	require.Equal(t, http.StatusConflict, rw.Code)
+	require.Contains(t, rw.Body.String(), "recategorize_job_active")
+
+	rw = httptest.NewRecorder()
+	h.AdminEnqueue(rw, recategorizeRequest(http.MethodPost, "admin", "", `{"user_id":"user2"}`))
+	require.Equal(t, http.StatusAccepted, rw.Code)
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&job))
+	require.Equal(t, "user2", job.UserID)
+	require.Equal(t, 0, job.EstimatedTokens)
+
+	rw = httptest.NewRecorder()
+	h.AdminGetJob(rw, recategorizeRequest(http.MethodGet, "admin", "1", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+}
+
+func TestRecategorizeHandler_MyJobs(t *testing.T) {
+	h := newTestRecategorizeHandler(map[string]bool{"user1": true})
+
+	rw := httptest.NewRecorder()
+	h.EnqueueMine(rw, recategorizeRequest(http.MethodPost, "user1", "", ""))
+	require.Equal(t, http.StatusAccepted, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.GetMyJob(rw, recategorizeRequest(http.MethodGet, "user1", "1", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.GetMyJob(rw, recategorizeRequest(http.MethodGet, "user2", "1", ""))
+	require.Equal(t, http.StatusNotFound, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.GetMyJob(rw, recategorizeRequest(http.MethodGet, "user1", "abc", ""))
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+}
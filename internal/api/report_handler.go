@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/reports"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/rs/zerolog/log"
+)
+
+// Error codes returned by the weekly report endpoints
+const (
+	ErrCodeReportViaUnavailable = "report_via_unavailable"
+	ErrCodeNoLinkedAccount      = "no_linked_account"
+)
+
+// recentReportDeliveries is how many entries GET /api/users/me/reports/weekly/deliveries returns
+const recentReportDeliveries = 50
+
+type ReportHandler struct {
+	Reports *reports.Service
+}
+
+func NewReportHandler(svc *reports.Service) *ReportHandler {
+	return &ReportHandler{Reports: svc}
+}
+
+// GetWeeklyReport handles GET /api/users/me/reports/weekly
+func (h *ReportHandler) GetWeeklyReport(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	settings, err := h.Reports.Settings(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load report settings")
+		return
+	}
+	RespondJSON(w, http.StatusOK, settings)
+}
+
+// UpdateWeeklyReport handles PUT /api/users/me/reports/weekly
+func (h *ReportHandler) UpdateWeeklyReport(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	var req models.ReportSettings
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	settings, err := h.Reports.Configure(r.Context(), userID, &req)
+	if err != nil {
+		writeReportError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusOK, settings)
+}
+
+// PreviewWeeklyReport handles GET /api/users/me/reports/weekly/preview: the report the saved
+// settings would produce now, without sending it
+func (h *ReportHandler) PreviewWeeklyReport(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	msg, err := h.Reports.Preview(r.Context(), userID)
+	if err != nil {
+		log.Error().Err(err).Str("userID", userID).Msg("failed to compose report preview")
+		RespondError(w, http.StatusInternalServerError, "failed to compose report")
+		return
+	}
+	RespondJSON(w, http.StatusOK, msg)
+}
+
+// ListReportDeliveries handles GET /api/users/me/reports/weekly/deliveries
+func (h *ReportHandler) ListReportDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	list, err := h.Reports.Deliveries(r.Context(), userID, recentReportDeliveries)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load report deliveries")
+		return
+	}
+	if list == nil {
+		list = []*models.ReportDelivery{}
+	}
+	RespondJSON(w, http.StatusOK, list)
+}
+
+// writeReportError maps reports errors onto HTTP status codes. A missing send scope is reported
+// like any other, with a consent URL asking for it.
+func writeReportError(w http.ResponseWriter, err error) {
+	var scopeErr *provider.ScopeError
+	switch {
+	case errors.Is(err, reports.ErrInvalidSettings):
+		RespondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, reports.ErrViaUnavailable):
+		RespondErrorCode(w, http.StatusBadRequest, ErrCodeReportViaUnavailable, "this way of sending reports is not available")
+	case errors.Is(err, reports.ErrNoAccount):
+		RespondErrorCode(w, http.StatusBadRequest, ErrCodeNoLinkedAccount, "link a Gmail account to send reports from it")
+	case errors.As(err, &scopeErr):
+		scopeErr.ConsentURL = ConsentURL(scopeErr.Feature, "")
+		writeProviderError(w, scopeErr)
+	default:
+		RespondError(w, http.StatusInternalServerError, "failed to save report settings")
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/reports"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/stretchr/testify/require"
+)
+
+// stubReportRepo keeps one user's report settings in memory
+type stubReportRepo struct {
+	settings *models.ReportSettings
+}
+
+func (s *stubReportRepo) GetSettings(ctx context.Context, userID string) (*models.ReportSettings, error) {
+	if s.settings != nil {
+		return s.settings, nil
+	}
+	return &models.ReportSettings{UserID: userID, Via: models.ReportViaAccount, Weekday: 1}, nil
+}
+func (s *stubReportRepo) SaveSettings(ctx context.Context, settings *models.ReportSettings) error {
+	s.settings = settings
+	return nil
+}
+func (s *stubReportRepo) ClaimDue(ctx context.Context, now, leaseUntil time.Time) (*models.ReportSettings, error) {
+	return nil, nil
+}
+func (s *stubReportRepo) Reschedule(ctx context.Context, userID string, next time.Time, sentAt *time.Time) error {
+	return nil
+}
+func (s *stubReportRepo) LogDelivery(ctx context.Context, d *models.ReportDelivery) error { return nil }
+func (s *stubReportRepo) ListDeliveries(ctx context.Context, userID string, limit int) ([]*models.ReportDelivery, error) {
+	return nil, nil
+}
+func (s *stubReportRepo) Pending(ctx context.Context, now time.Time) (int, *time.Time, error) {
+	return 0, nil, nil
+}
+
+type stubStats struct{}
+
+func (stubStats) Stats(ctx context.Context, userID string) (*models.UserStats, error) {
+	return &models.UserStats{Since: time.Now().Add(-7 * 24 * time.Hour), Archived: 7}, nil
+}
+
+type stubUsers struct{}
+
+func (stubUsers) GetByID(ctx context.Context, id string) (*models.User, error) {
+	return &models.User{ID: id, Email: "me@example.com"}, nil
+}
+
+// stubMailer fails Ready with err
+type stubMailer struct {
+	err error
+}
+
+func (m *stubMailer) Ready(ctx context.Context, userID string) error { return m.err }
+func (m *stubMailer) Send(ctx context.Context, userID string, msg *reports.Message) error {
+	return nil
+}
+
+func TestReportHandler(t *testing.T) {
+	svc := reports.NewService(&stubReportRepo{}, stubStats{}, stubUsers{}, &stubSettingsRepo{})
+	mailer := &stubMailer{err: &provider.ScopeError{Feature: provider.FeatureSend, Missing: []string{"send"}}}
+	svc.SetMailer(models.ReportViaAccount, mailer)
+	h := NewReportHandler(svc)
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/users/me/reports/weekly", strings.NewReader(body))
+		rw := httptest.NewRecorder()
+		h.UpdateWeeklyReport(rw, req.WithContext(ctxkeys.WithUserID(req.Context(), "user1")))
+		return rw
+	}
+
+	rw := put(`{"enabled":true,"via":"account","weekday":9}`)
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = put(`{"enabled":true,"via":"smtp","weekday":1}`)
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+	require.Contains(t, rw.Body.String(), ErrCodeReportViaUnavailable)
+
+	rw = put(`{"enabled":true,"via":"account","weekday":1}`)
+	require.Equal(t, http.StatusForbidden, rw.Code)
+	var missing MissingScopeResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&missing))
+	require.Equal(t, "missing_scope", missing.Code)
+	require.Contains(t, missing.ConsentURL, "feature=send")
+
+	mailer.err = nil
+	rw = put(`{"enabled":true,"via":"account","weekday":1,"subject":"{archived} archived"}`)
+	require.Equal(t, http.StatusOK, rw.Code)
+	var saved models.ReportSettings
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&saved))
+	require.True(t, saved.Enabled)
+	require.NotNil(t, saved.NextDueAt)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users/me/reports/weekly/preview", nil)
+	rw = httptest.NewRecorder()
+	h.PreviewWeeklyReport(rw, req.WithContext(ctxkeys.WithUserID(req.Context(), "user1")))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var preview reports.Message
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&preview))
+	require.Equal(t, "me@example.com", preview.To)
+	require.Equal(t, "7 archived", preview.Subject)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/users/me/reports/weekly/deliveries", nil)
+	rw = httptest.NewRecorder()
+	h.ListReportDeliveries(rw, req.WithContext(ctxkeys.WithUserID(req.Context(), "user1")))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.JSONEq(t, `[]`, rw.Body.String())
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/review"
+	"github.com/rs/zerolog/log"
+)
+
+// ReviewHandler serves inbox zero review sessions
+type ReviewHandler struct {
+	Reviews *review.Service
+	// Actions carries out the decisions that change the message at the provider
+	Actions *MessageActionHandler
+}
+
+func NewReviewHandler(reviews *review.Service, actions *MessageActionHandler) *ReviewHandler {
+	return &ReviewHandler{Reviews: reviews, Actions: actions}
+}
+
+// ReviewSessionRequest is the optional body of POST /api/review-sessions; Size defaults to
+// review.DefaultSize and is capped at review.MaxSize
+type ReviewSessionRequest struct {
+	Size int `json:"size,omitempty"`
+}
+
+// ReviewActionRequest is the body of POST /api/review-sessions/{id}/actions: a message action,
+// keep to leave the message as it is, or skip to leave it for a later session
+type ReviewActionRequest struct {
+	MessageID string `json:"message_id"`
+	Action    string `json:"action"`
+	Label     string `json:"label,omitempty"`
+}
+
+// CreateSession handles POST /api/review-sessions, queueing the user's oldest unhandled messages
+func (h *ReviewHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	var req ReviewSessionRequest
+	if err := DecodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	if req.Size < 0 {
+		RespondError(w, http.StatusBadRequest, "size must not be negative")
+		return
+	}
+	s, err := h.Reviews.Start(r.Context(), userID, req.Size)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("failed to create review session")
+		RespondError(w, http.StatusInternalServerError, "failed to create review session")
+		return
+	}
+	RespondJSON(w, http.StatusCreated, s)
+}
+
+// GetSession handles GET /api/review-sessions/{id}
+func (h *ReviewHandler) GetSession(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+	s, err := h.Reviews.Get(r.Context(), userID, id)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "review session not found")
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to get review session")
+		return
+	}
+	RespondJSON(w, http.StatusOK, s)
+}
+
+// Next handles GET /api/review-sessions/{id}/next: the message to review next with its
+// suggested actions, or done once the queue is empty
+func (h *ReviewHandler) Next(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+	h.respondNext(w, r, userID, id)
+}
+
+// Act handles POST /api/review-sessions/{id}/actions: carries out the decision on the message
+// under review, which must be the one Next served, and answers with the next card
+func (h *ReviewHandler) Act(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := h.session(w, r)
+	if !ok {
+		return
+	}
+	var req ReviewActionRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	if req.MessageID == "" {
+		RespondError(w, http.StatusBadRequest, "message_id is required")
+		return
+	}
+	switch req.Action {
+	case models.ReviewKeep, models.ReviewSkip, MessageActionArchive, MessageActionTrash, MessageActionMarkRead,
+		MessageActionMarkUnread, MessageActionApplyLabel, MessageActionMove:
+	default:
+		RespondError(w, http.StatusBadRequest, "unknown action: use keep, skip, archive, trash, mark_read, mark_unread, apply_label or move")
+		return
+	}
+	card, err := h.Reviews.Next(r.Context(), userID, id)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "review session not found")
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load review session")
+		return
+	}
+	if card.Done || card.Item.EmailMessageID != req.MessageID {
+		RespondError(w, http.StatusConflict, "message is not the one under review")
+		return
+	}
+	if req.Action != models.ReviewKeep && req.Action != models.ReviewSkip {
+		tok := ctxkeys.Token(r.Context())
+		if tok == nil {
+			RespondError(w, http.StatusUnauthorized, "not authenticated: no token in context")
+			return
+		}
+		if err := h.Actions.act(r.Context(), userID, tok, req.MessageID, MessageActionRequest{Action: req.Action, Label: req.Label}); err != nil {
+			writeActionError(w, err)
+			return
+		}
+	}
+	err = h.Reviews.Decide(r.Context(), userID, id, req.MessageID, req.Action)
+	if errors.Is(err, data.ErrNotFound) {
+		// Another request decided the message first
+		RespondError(w, http.StatusConflict, "message is not the one under review")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Int64("session_id", id).Msg("failed to record review decision")
+		RespondError(w, http.StatusInternalServerError, "failed to record decision")
+		return
+	}
+	h.respondNext(w, r, userID, id)
+}
+
+func (h *ReviewHandler) respondNext(w http.ResponseWriter, r *http.Request, userID string, id int64) {
+	card, err := h.Reviews.Next(r.Context(), userID, id)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "review session not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Int64("session_id", id).Msg("failed to load next review message")
+		RespondError(w, http.StatusInternalServerError, "failed to load review session")
+		return
+	}
+	RespondJSON(w, http.StatusOK, card)
+}
+
+// session reads the user and session ID of a request, answering it when one is missing
+func (h *ReviewHandler) session(w http.ResponseWriter, r *http.Request) (string, int64, bool) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return "", 0, false
+	}
+	idParam, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return "", 0, false
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid review session id")
+		return "", 0, false
+	}
+	return userID, id, true
+}
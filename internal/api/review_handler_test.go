@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/review"
+	"github.com/desponda/inbox-whisperer/internal/suggestions"
+	"github.com/desponda/inbox-whisperer/internal/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+// stubReviewSessions holds session 1, queueing the given messages in order
+type stubReviewSessions struct {
+	items []*models.ReviewItem
+}
+
+func (s *stubReviewSessions) Create(ctx context.Context, userID string, limit int) (*models.ReviewSession, error) {
+	return s.Get(ctx, userID, 1)
+}
+
+func (s *stubReviewSessions) Get(ctx context.Context, userID string, id int64) (*models.ReviewSession, error) {
+	if id != 1 {
+		return nil, data.ErrNotFound
+	}
+	rs := &models.ReviewSession{ID: 1, Total: len(s.items)}
+	for _, it := range s.items {
+		if it.Action != "" {
+			rs.Reviewed++
+		}
+	}
+	return rs, nil
+}
+
+func (s *stubReviewSessions) NextItem(ctx context.Context, userID string, id int64) (*models.ReviewItem, error) {
+	if id != 1 {
+		return nil, data.ErrNotFound
+	}
+	for _, it := range s.items {
+		if it.Action == "" {
+			return it, nil
+		}
+	}
+	return nil, data.ErrNotFound
+}
+
+func (s *stubReviewSessions) Decide(ctx context.Context, userID string, id int64, messageID, action string) error {
+	for _, it := range s.items {
+		if it.EmailMessageID == messageID && it.Action == "" {
+			it.Action = action
+			return nil
+		}
+	}
+	return data.ErrNotFound
+}
+
+func TestReviewHandler(t *testing.T) {
+	sessions := &stubReviewSessions{items: []*models.ReviewItem{
+		{Position: 1, EmailMessageID: "m1"},
+		{Position: 2, EmailMessageID: "m2"},
+		{Position: 3, EmailMessageID: "m3"},
+	}}
+	messages := &stubMessageRepo{sender: "news@shop.example"}
+	actions := &stubMessageActions{}
+	h := NewReviewHandler(review.NewService(sessions, messages, &stubRuleRepo{}),
+		NewMessageActionHandler(actions, messages, suggestions.NewService(&stubSuggestionRepo{}, &stubRuleRepo{})))
+	act := func(body string) (int, review.Card) {
+		w := httptest.NewRecorder()
+		h.Act(w, testutils.NewAuthedRequest("POST", "/api/review-sessions/1/actions", strings.NewReader(body), testutils.WithURLParam("id", "1")))
+		var card review.Card
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &card))
+		}
+		return w.Code, card
+	}
+
+	w := httptest.NewRecorder()
+	h.CreateSession(w, testutils.NewAuthedRequest("POST", "/api/review-sessions", nil))
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Contains(t, w.Body.String(), `"total":3`)
+
+	w = httptest.NewRecorder()
+	h.Next(w, testutils.NewAuthedRequest("GET", "/api/review-sessions/1/next", nil, testutils.WithURLParam("id", "1")))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"message_id":"m1"`)
+
+	code, _ := act(`{"message_id":"m2","action":"archive"}`)
+	require.Equal(t, http.StatusConflict, code, "only the message under review can be decided")
+	code, _ = act(`{"message_id":"m1","action":"delete"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = act(`{"message_id":"m1","action":"apply_label"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Empty(t, sessions.items[0].Action, "refused actions are not recorded")
+
+	code, card := act(`{"message_id":"m1","action":"archive"}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"m1"}, actions.archived)
+	require.Equal(t, "m2", card.Item.EmailMessageID)
+	require.Equal(t, 1, card.Session.Reviewed)
+
+	code, card = act(`{"message_id":"m2","action":"keep"}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "m3", card.Item.EmailMessageID)
+	code, card = act(`{"message_id":"m3","action":"skip"}`)
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, card.Done)
+	require.Equal(t, []string{"m1"}, actions.archived, "keep and skip leave the message alone")
+
+	w = httptest.NewRecorder()
+	h.GetSession(w, testutils.NewAuthedRequest("GET", "/api/review-sessions/2", nil, testutils.WithURLParam("id", "2")))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// RoleHandler grants and revokes user roles
+type RoleHandler struct {
+	Roles data.UserRoleRepository
+	// AdminUserIDs are admins whatever their role (see AdminOnly); revoking their role is refused
+	// because it would not take their access away
+	AdminUserIDs []string
+}
+
+func NewRoleHandler(roles data.UserRoleRepository, adminUserIDs []string) *RoleHandler {
+	return &RoleHandler{Roles: roles, AdminUserIDs: adminUserIDs}
+}
+
+// RoleRequest is the body of PUT /api/admin/users/{id}/role
+type RoleRequest struct {
+	Role string `json:"role"`
+}
+
+// AdminListAdmins handles GET /api/admin/admins, listing the users granted the admin role
+func (h *RoleHandler) AdminListAdmins(w http.ResponseWriter, r *http.Request) {
+	users, err := h.Roles.ListByRole(r.Context(), models.RoleAdmin)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to list admins")
+		return
+	}
+	if users == nil {
+		users = []*models.User{}
+	}
+	RespondJSON(w, http.StatusOK, users)
+}
+
+// AdminSetRole handles PUT /api/admin/users/{id}/role
+func (h *RoleHandler) AdminSetRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req RoleRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	if !models.ValidRole(req.Role) {
+		RespondError(w, http.StatusBadRequest, "role must be user or admin")
+		return
+	}
+	h.setRole(w, r, userID, req.Role)
+}
+
+// AdminRevokeRole handles DELETE /api/admin/users/{id}/role, returning the user to the user role
+func (h *RoleHandler) AdminRevokeRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.setRole(w, r, userID, models.RoleUser)
+}
+
+func (h *RoleHandler) setRole(w http.ResponseWriter, r *http.Request, userID, role string) {
+	adminID := ctxkeys.UserID(r.Context())
+	if role != models.RoleAdmin {
+		// Keeps the last admin from locking everyone out by accident
+		if userID == adminID {
+			RespondError(w, http.StatusConflict, "admins cannot revoke their own role")
+			return
+		}
+		if slices.Contains(h.AdminUserIDs, userID) {
+			RespondError(w, http.StatusConflict, "user is an admin through server.admin_user_ids; remove them there")
+			return
+		}
+	}
+	err := h.Roles.SetRole(r.Context(), userID, role)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("failed to set user role")
+		RespondError(w, http.StatusInternalServerError, "failed to set role")
+		return
+	}
+	log.Info().Str("admin_id", adminID).Str("user_id", userID).Str("role", role).Msg("user role changed")
+	RespondJSON(w, http.StatusOK, RoleRequest{Role: role})
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+type stubRoleRepo struct {
+	roles map[string]string
+	err   error
+}
+
+func (s *stubRoleRepo) GetRole(ctx context.Context, id string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	role, ok := s.roles[id]
+	if !ok {
+		return "", data.ErrNotFound
+	}
+	return role, nil
+}
+
+func (s *stubRoleRepo) SetRole(ctx context.Context, id, role string) error {
+	if _, ok := s.roles[id]; !ok {
+		return data.ErrNotFound
+	}
+	s.roles[id] = role
+	return nil
+}
+
+func (s *stubRoleRepo) ListByRole(ctx context.Context, role string) ([]*models.User, error) {
+	var users []*models.User
+	for id, r := range s.roles {
+		if r == role {
+			users = append(users, &models.User{ID: id, Role: r})
+		}
+	}
+	return users, nil
+}
+
+func roleRequest(method, userID, id, body string) *http.Request {
+	r := httptest.NewRequest(method, "/api/admin/users", strings.NewReader(body))
+	ctx := ctxkeys.WithUserID(r.Context(), userID)
+	if id != "" {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+	}
+	return r.WithContext(ctx)
+}
+
+func TestAdminOnly(t *testing.T) {
+	roles := &stubRoleRepo{roles: map[string]string{"user1": models.RoleUser, "user2": models.RoleAdmin}}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	mw := AdminOnly([]string{"root"}, roles)(next)
+
+	for userID, want := range map[string]int{
+		"user1": http.StatusForbidden,
+		"ghost": http.StatusForbidden,
+		"root":  http.StatusNoContent, // configured, whatever the stored role
+		"user2": http.StatusNoContent, // granted the admin role
+	} {
+		rw := httptest.NewRecorder()
+		mw.ServeHTTP(rw, roleRequest(http.MethodPost, userID, "", ""))
+		require.Equal(t, want, rw.Code, userID)
+	}
+
+	// The role is read on every request, so a revoke applies to sessions and API keys already
+	// signed in
+	roles.roles["user2"] = models.RoleUser
+	rw := httptest.NewRecorder()
+	mw.ServeHTTP(rw, roleRequest(http.MethodPost, "user2", "", ""))
+	require.Equal(t, http.StatusForbidden, rw.Code)
+
+	roles.err = errors.New("db down")
+	rw = httptest.NewRecorder()
+	mw.ServeHTTP(rw, roleRequest(http.MethodPost, "user2", "", ""))
+	require.Equal(t, http.StatusInternalServerError, rw.Code)
+}
+
+func TestRoleHandler(t *testing.T) {
+	roles := &stubRoleRepo{roles: map[string]string{"admin": models.RoleAdmin, "user1": models.RoleUser, "root": models.RoleUser}}
+	h := NewRoleHandler(roles, []string{"root"})
+
+	rw := httptest.NewRecorder()
+	h.AdminSetRole(rw, roleRequest(http.MethodPut, "admin", "user1", `{"role":"admin"}`))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, models.RoleAdmin, roles.roles["user1"])
+
+	rw = httptest.NewRecorder()
+	h.AdminListAdmins(rw, roleRequest(http.MethodGet, "admin", "", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var admins []models.User
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&admins))
+	require.Len(t, admins, 2)
+
+	rw = httptest.NewRecorder()
+	h.AdminRevokeRole(rw, roleRequest(http.MethodDelete, "admin", "user1", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, models.RoleUser, roles.roles["user1"])
+
+	for name, tc := range map[string]struct {
+		req  *http.Request
+		want int
+	}{
+		"unknown role":       {roleRequest(http.MethodPut, "admin", "user1", `{"role":"owner"}`), http.StatusBadRequest},
+		"missing user":       {roleRequest(http.MethodPut, "admin", "ghost", `{"role":"admin"}`), http.StatusNotFound},
+		"own role":           {roleRequest(http.MethodDelete, "admin", "admin", ""), http.StatusConflict},
+		"configured admin":   {roleRequest(http.MethodDelete, "admin", "root", ""), http.StatusConflict},
+		"demote by set role": {roleRequest(http.MethodPut, "admin", "admin", `{"role":"user"}`), http.StatusConflict},
+	} {
+		rw = httptest.NewRecorder()
+		if tc.req.Method == http.MethodDelete {
+			h.AdminRevokeRole(rw, tc.req)
+		} else {
+			h.AdminSetRole(rw, tc.req)
+		}
+		require.Equal(t, tc.want, rw.Code, name)
+	}
+	require.Equal(t, models.RoleAdmin, roles.roles["admin"])
+}
+
+func TestUserHandler_AdminAccess(t *testing.T) {
+	svc := &mockUserService{
+		GetUserFunc: func(ctx context.Context, id string) (*models.User, error) {
+			return &models.User{ID: id}, nil
+		},
+		ListUsersFunc: func(ctx context.Context) ([]*models.User, error) {
+			return []*models.User{{ID: "user1"}, {ID: "user2"}}, nil
+		},
+	}
+	roles := &stubRoleRepo{roles: map[string]string{"user1": models.RoleUser, "user2": models.RoleAdmin}}
+	h := NewUserHandler(svc, roles, []string{"root"})
+
+	rw := httptest.NewRecorder()
+	h.ListUsers(rw, roleRequest(http.MethodGet, "user1", "", ""))
+	require.Equal(t, http.StatusForbidden, rw.Code)
+
+	for _, r := range []*http.Request{roleRequest(http.MethodGet, "user2", "", ""), roleRequest(http.MethodGet, "root", "", "")} {
+		rw = httptest.NewRecorder()
+		h.ListUsers(rw, r)
+		require.Equal(t, http.StatusOK, rw.Code)
+		var users []models.User
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&users))
+		require.Len(t, users, 2)
+	}
+
+	rw = httptest.NewRecorder()
+	h.GetUser(rw, roleRequest(http.MethodGet, "user1", "user2", ""))
+	require.Equal(t, http.StatusForbidden, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.GetUser(rw, roleRequest(http.MethodGet, "user2", "user1", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+}
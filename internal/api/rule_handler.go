@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/rules"
+)
+
+// CreateRuleRequest is the body of POST /api/rules
+type CreateRuleRequest struct {
+	Name       string                 `json:"name"`
+	Conditions []models.RuleCondition `json:"conditions"`
+	Actions    []models.RuleAction    `json:"actions"`
+	// Enabled defaults to true when omitted
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+type RuleHandler struct {
+	Rules data.RuleRepository
+}
+
+func NewRuleHandler(repo data.RuleRepository) *RuleHandler {
+	return &RuleHandler{Rules: repo}
+}
+
+// ListRules handles GET /api/rules
+func (h *RuleHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	list, err := h.Rules.ListByUser(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load rules")
+		return
+	}
+	if list == nil {
+		list = []*models.Rule{}
+	}
+	RespondJSON(w, http.StatusOK, list)
+}
+
+// CreateRule handles POST /api/rules
+func (h *RuleHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	var req CreateRuleRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	rule := &models.Rule{
+		UserID:     userID,
+		Name:       req.Name,
+		Conditions: req.Conditions,
+		Actions:    req.Actions,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+	}
+	if err := rules.Validate(rule); err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.Rules.Create(r.Context(), rule); err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to create rule")
+		return
+	}
+	RespondJSON(w, http.StatusCreated, rule)
+}
+
+// DeleteRule handles DELETE /api/rules/{id}
+func (h *RuleHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	idParam, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid rule id")
+		return
+	}
+	if err := h.Rules.Delete(r.Context(), userID, id); err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			RespondError(w, http.StatusNotFound, "rule not found")
+			return
+		}
+		RespondError(w, http.StatusInternalServerError, "failed to delete rule")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
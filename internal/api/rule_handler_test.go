@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+type stubRuleRepo struct {
+	created []*models.Rule
+}
+
+func (s *stubRuleRepo) Create(ctx context.Context, rule *models.Rule) error {
+	rule.ID = int64(len(s.created) + 1)
+	s.created = append(s.created, rule)
+	return nil
+}
+func (s *stubRuleRepo) ListByUser(ctx context.Context, userID string) ([]*models.Rule, error) {
+	return s.created, nil
+}
+func (s *stubRuleRepo) Delete(ctx context.Context, userID string, id int64) error {
+	if id > int64(len(s.created)) {
+		return data.ErrNotFound
+	}
+	return nil
+}
+
+func ruleRequest(method, body, id string) *http.Request {
+	r := httptest.NewRequest(method, "/api/rules", strings.NewReader(body))
+	ctx := ctxkeys.WithUserID(r.Context(), "user1")
+	if id != "" {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+	}
+	return r.WithContext(ctx)
+}
+
+func TestRuleHandler(t *testing.T) {
+	repo := &stubRuleRepo{}
+	h := NewRuleHandler(repo)
+
+	w := httptest.NewRecorder()
+	h.CreateRule(w, ruleRequest("POST", `{"name":"Receipts","conditions":[{"field":"subject","contains":"receipt"}],"actions":[{"type":"apply_label","label":"Finance/Receipts"}]}`, ""))
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Len(t, repo.created, 1)
+	require.True(t, repo.created[0].Enabled)
+	require.Equal(t, "user1", repo.created[0].UserID)
+
+	w = httptest.NewRecorder()
+	h.CreateRule(w, ruleRequest("POST", `{"name":"Bad","conditions":[{"field":"body","contains":"x"}],"actions":[{"type":"apply_label","label":"X"}]}`, ""))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ListRules(w, ruleRequest("GET", "", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "Finance/Receipts")
+
+	w = httptest.NewRecorder()
+	h.DeleteRule(w, ruleRequest("DELETE", "", "1"))
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	h.DeleteRule(w, ruleRequest("DELETE", "", "99"))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	h.DeleteRule(w, ruleRequest("DELETE", "", "abc"))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ListRules(w, httptest.NewRequest("GET", "/api/rules", nil))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/legalhold"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/scheduler"
+)
+
+type SettingsHandler struct {
+	Repo data.UserSettingsRepository
+	// LocalOnly is the deploy-level AI switch; it overrides AIDataSharing for every user
+	LocalOnly bool
+	// Messages, if set, has cached content purged when the user narrows what may be cached
+	Messages data.EmailMessageRepository
+	// Holds refuses those purges for users under legal hold, leaving the settings unchanged
+	Holds *legalhold.Service
+}
+
+func NewSettingsHandler(repo data.UserSettingsRepository, localOnly bool) *SettingsHandler {
+	return &SettingsHandler{Repo: repo, LocalOnly: localOnly}
+}
+
+// SettingsResponse is the user's settings plus the deploy-level settings that constrain them
+type SettingsResponse struct {
+	*models.UserSettings
+	AILocalOnly bool `json:"ai_local_only"`
+}
+
+// SettingsUpdate is the body of PUT /api/users/me/settings
+type SettingsUpdate struct {
+	AIDataSharing     *bool `json:"ai_data_sharing"`
+	DisableLocalCache *bool `json:"disable_local_cache"`
+	MetadataOnlyCache *bool `json:"metadata_only_cache"`
+	// Delivery window; see models.UserSettings
+	Timezone          *string `json:"timezone"`
+	WorkingHoursStart *string `json:"working_hours_start"`
+	WorkingHoursEnd   *string `json:"working_hours_end"`
+	WeekendPause      *bool   `json:"weekend_pause"`
+	// DigestCategories replaces the categories gathered into the daily newsletter digest
+	DigestCategories *[]string `json:"digest_categories"`
+}
+
+// Limits on the digest categories
+const (
+	MaxDigestCategories    = 20
+	MaxDigestCategoryBytes = 64
+)
+
+// GetSettings handles GET /api/users/me/settings
+func (h *SettingsHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	s, err := h.Repo.Get(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load settings")
+		return
+	}
+	RespondJSON(w, http.StatusOK, SettingsResponse{UserSettings: s, AILocalOnly: h.LocalOnly})
+}
+
+// UpdateSettings handles PUT /api/users/me/settings
+func (h *SettingsHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	var req SettingsUpdate
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	s, err := h.Repo.Get(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load settings")
+		return
+	}
+	if req.AIDataSharing != nil {
+		s.AIDataSharing = *req.AIDataSharing
+	}
+	purge, clearContent := false, false
+	if req.DisableLocalCache != nil {
+		purge = *req.DisableLocalCache && !s.DisableLocalCache
+		s.DisableLocalCache = *req.DisableLocalCache
+	}
+	if req.MetadataOnlyCache != nil {
+		clearContent = *req.MetadataOnlyCache && !s.MetadataOnlyCache
+		s.MetadataOnlyCache = *req.MetadataOnlyCache
+	}
+	if req.Timezone != nil {
+		s.Timezone = *req.Timezone
+	}
+	if req.WorkingHoursStart != nil {
+		s.WorkingHoursStart = *req.WorkingHoursStart
+	}
+	if req.WorkingHoursEnd != nil {
+		s.WorkingHoursEnd = *req.WorkingHoursEnd
+	}
+	if req.WeekendPause != nil {
+		s.WeekendPause = *req.WeekendPause
+	}
+	if req.DigestCategories != nil {
+		categories, err := digestCategories(*req.DigestCategories)
+		if err != nil {
+			RespondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.DigestCategories = categories
+	}
+	if _, err := scheduler.NewDeliveryWindow(s); err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if (purge || clearContent) && h.Messages != nil {
+		action := legalhold.ActionClearContent
+		if purge {
+			action = legalhold.ActionPurgeCache
+		}
+		if !guardDeletion(w, r, h.Holds, userID, action) {
+			return
+		}
+	}
+	if err := h.Repo.Upsert(r.Context(), s); err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to save settings")
+		return
+	}
+	// Opting out of caching also drops what was cached so far; syncs stop writing from now on
+	if purge && h.Messages != nil {
+		if err := h.Messages.DeleteMessagesForUser(r.Context(), userID); err != nil {
+			RespondError(w, http.StatusInternalServerError, "failed to purge cached messages")
+			return
+		}
+	} else if clearContent && h.Messages != nil {
+		// Switching to metadata-only drops the bodies cached so far
+		if err := h.Messages.ClearMessageContent(r.Context(), userID); err != nil {
+			RespondError(w, http.StatusInternalServerError, "failed to purge cached message bodies")
+			return
+		}
+	}
+	RespondJSON(w, http.StatusOK, SettingsResponse{UserSettings: s, AILocalOnly: h.LocalOnly})
+}
+
+// digestCategories trims and checks the categories of a settings update, dropping duplicates
+func digestCategories(in []string) ([]string, error) {
+	out := make([]string, 0, len(in))
+	for _, c := range in {
+		c = strings.TrimSpace(c)
+		if c == "" || len(c) > MaxDigestCategoryBytes {
+			return nil, fmt.Errorf("digest categories must be non-empty and at most %d bytes", MaxDigestCategoryBytes)
+		}
+		if !slices.Contains(out, c) {
+			out = append(out, c)
+		}
+	}
+	if len(out) > MaxDigestCategories {
+		return nil, fmt.Errorf("at most %d digest categories are allowed", MaxDigestCategories)
+	}
+	return out, nil
+}
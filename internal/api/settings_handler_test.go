@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+type stubSettingsRepo struct {
+	settings map[string]models.UserSettings
+}
+
+func (s *stubSettingsRepo) Get(ctx context.Context, userID string) (*models.UserSettings, error) {
+	v := s.settings[userID]
+	v.UserID = userID
+	return &v, nil
+}
+
+func (s *stubSettingsRepo) Upsert(ctx context.Context, settings *models.UserSettings) error {
+	s.settings[settings.UserID] = *settings
+	return nil
+}
+
+func TestSettingsHandler(t *testing.T) {
+	repo := &stubSettingsRepo{settings: map[string]models.UserSettings{}}
+	h := NewSettingsHandler(repo, true)
+	withUser := func(r *http.Request) *http.Request {
+		return r.WithContext(ctxkeys.WithUserID(r.Context(), "user1"))
+	}
+
+	w := httptest.NewRecorder()
+	h.GetSettings(w, withUser(httptest.NewRequest("GET", "/api/users/me/settings", nil)))
+	require.Equal(t, http.StatusOK, w.Code)
+	var got map[string]interface{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	require.Equal(t, false, got["ai_data_sharing"])
+	require.Equal(t, true, got["ai_local_only"])
+
+	w = httptest.NewRecorder()
+	h.UpdateSettings(w, withUser(httptest.NewRequest("PUT", "/api/users/me/settings", strings.NewReader(`{"ai_data_sharing":true}`))))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.True(t, repo.settings["user1"].AIDataSharing)
+
+	w = httptest.NewRecorder()
+	h.UpdateSettings(w, withUser(httptest.NewRequest("PUT", "/api/users/me/settings", strings.NewReader(`{"unknown":1}`))))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.UpdateSettings(w, withUser(httptest.NewRequest("PUT", "/api/users/me/settings",
+		strings.NewReader(`{"timezone":"Europe/Berlin","working_hours_start":"09:00","working_hours_end":"17:30","weekend_pause":true}`))))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "Europe/Berlin", repo.settings["user1"].Timezone)
+	require.True(t, repo.settings["user1"].WeekendPause)
+
+	w = httptest.NewRecorder()
+	h.UpdateSettings(w, withUser(httptest.NewRequest("PUT", "/api/users/me/settings", strings.NewReader(`{"working_hours_end":"25:00"}`))))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, "17:30", repo.settings["user1"].WorkingHoursEnd, "invalid windows are not saved")
+
+	w = httptest.NewRecorder()
+	h.UpdateSettings(w, withUser(httptest.NewRequest("PUT", "/api/users/me/settings",
+		strings.NewReader(`{"digest_categories":[" Promotions/Ads","Social","Promotions/Ads"]}`))))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, []string{"Promotions/Ads", "Social"}, repo.settings["user1"].DigestCategories)
+
+	w = httptest.NewRecorder()
+	h.UpdateSettings(w, withUser(httptest.NewRequest("PUT", "/api/users/me/settings", strings.NewReader(`{"digest_categories":[""]}`))))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Len(t, repo.settings["user1"].DigestCategories, 2, "invalid categories are not saved")
+
+	w = httptest.NewRecorder()
+	h.GetSettings(w, httptest.NewRequest("GET", "/api/users/me/settings", nil))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// purgeRecordingRepo records which users had their cached messages or bodies deleted
+type purgeRecordingRepo struct {
+	stubMessageRepo
+	purged  []string
+	cleared []string
+}
+
+func (p *purgeRecordingRepo) DeleteMessagesForUser(ctx context.Context, userID string) error {
+	p.purged = append(p.purged, userID)
+	return nil
+}
+
+func (p *purgeRecordingRepo) ClearMessageContent(ctx context.Context, userID string) error {
+	p.cleared = append(p.cleared, userID)
+	return nil
+}
+
+func TestSettingsHandler_DisableLocalCachePurgesMessages(t *testing.T) {
+	repo := &stubSettingsRepo{settings: map[string]models.UserSettings{}}
+	messages := &purgeRecordingRepo{}
+	h := NewSettingsHandler(repo, false)
+	h.Messages = messages
+	put := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("PUT", "/api/users/me/settings", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.UpdateSettings(w, r.WithContext(ctxkeys.WithUserID(r.Context(), "user1")))
+		return w
+	}
+
+	w := put(`{"disable_local_cache":true}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.True(t, repo.settings["user1"].DisableLocalCache)
+	require.Equal(t, []string{"user1"}, messages.purged)
+
+	// Already disabled: nothing left to purge
+	require.Equal(t, http.StatusOK, put(`{"disable_local_cache":true}`).Code)
+	require.Len(t, messages.purged, 1)
+
+	require.Equal(t, http.StatusOK, put(`{"disable_local_cache":false}`).Code)
+	require.False(t, repo.settings["user1"].DisableLocalCache)
+	require.Len(t, messages.purged, 1)
+}
+
+func TestSettingsHandler_MetadataOnlyClearsBodies(t *testing.T) {
+	repo := &stubSettingsRepo{settings: map[string]models.UserSettings{}}
+	messages := &purgeRecordingRepo{}
+	h := NewSettingsHandler(repo, false)
+	h.Messages = messages
+	r := httptest.NewRequest("PUT", "/api/users/me/settings", strings.NewReader(`{"metadata_only_cache":true}`))
+	w := httptest.NewRecorder()
+	h.UpdateSettings(w, r.WithContext(ctxkeys.WithUserID(r.Context(), "user1")))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var got map[string]interface{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	require.Equal(t, true, got["metadata_only_cache"])
+	require.Equal(t, []string{"user1"}, messages.cleared)
+	require.Empty(t, messages.purged)
+}
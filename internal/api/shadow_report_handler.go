@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+)
+
+// DefaultShadowReportDays is the report window when days is not given
+const DefaultShadowReportDays = 7
+
+type ShadowReportHandler struct {
+	Results data.ShadowResultRepository
+	now     func() time.Time
+}
+
+func NewShadowReportHandler(results data.ShadowResultRepository) *ShadowReportHandler {
+	return &ShadowReportHandler{Results: results, now: time.Now}
+}
+
+// AdminReport handles GET /api/admin/categorizer/shadow-report: how often shadow categorizers
+// agreed with the active one over the last days (1 to 90)
+func (h *ShadowReportHandler) AdminReport(w http.ResponseWriter, r *http.Request) {
+	days := DefaultShadowReportDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 90 {
+			RespondError(w, http.StatusBadRequest, "days must be between 1 and 90")
+			return
+		}
+		days = n
+	}
+	report, err := h.Results.Agreement(r.Context(), h.now().AddDate(0, 0, -days))
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load shadow report")
+		return
+	}
+	RespondJSON(w, http.StatusOK, report)
+}
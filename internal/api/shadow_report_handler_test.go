@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+type stubShadowResults struct {
+	since time.Time
+}
+
+func (s *stubShadowResults) RecordShadowResult(ctx context.Context, res *models.ShadowResult) error {
+	return nil
+}
+
+func (s *stubShadowResults) Agreement(ctx context.Context, since time.Time) ([]models.ShadowAgreement, error) {
+	s.since = since
+	return []models.ShadowAgreement{{ShadowVersion: "b", ActiveVersion: "a", Total: 4, Agreed: 3, AgreementRate: 0.75}}, nil
+}
+
+func TestShadowReportHandler(t *testing.T) {
+	repo := &stubShadowResults{}
+	h := NewShadowReportHandler(repo)
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+
+	rw := httptest.NewRecorder()
+	h.AdminReport(rw, httptest.NewRequest(http.MethodGet, "/api/admin/categorizer/shadow-report", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, now.AddDate(0, 0, -DefaultShadowReportDays), repo.since)
+	var report []models.ShadowAgreement
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&report))
+	require.Len(t, report, 1)
+	require.Equal(t, 0.75, report[0].AgreementRate)
+
+	rw = httptest.NewRecorder()
+	h.AdminReport(rw, httptest.NewRequest(http.MethodGet, "/api/admin/categorizer/shadow-report?days=30", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, now.AddDate(0, 0, -30), repo.since)
+
+	rw = httptest.NewRecorder()
+	h.AdminReport(rw, httptest.NewRequest(http.MethodGet, "/api/admin/categorizer/shadow-report?days=0", nil))
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/analytics"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+type StatsHandler struct {
+	Analytics *analytics.Service
+}
+
+func NewStatsHandler(svc *analytics.Service) *StatsHandler {
+	return &StatsHandler{Analytics: svc}
+}
+
+// GetMyStats handles GET /api/users/me/stats
+func (h *StatsHandler) GetMyStats(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	stats, err := h.Analytics.Stats(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to compute stats")
+		return
+	}
+	RespondJSON(w, http.StatusOK, stats)
+}
+
+// SenderStatsResponse is the body of GET /api/analytics/senders
+type SenderStatsResponse struct {
+	From    time.Time           `json:"from"`
+	To      time.Time           `json:"to"`
+	Senders []models.SenderStat `json:"senders"`
+}
+
+// VolumeResponse is the body of GET /api/analytics/volume
+type VolumeResponse struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	*models.InboxVolume
+}
+
+// GetSenders handles GET /api/analytics/senders: who sent the most mail between ?from and ?to,
+// busiest first, up to ?limit senders
+func (h *StatsHandler) GetSenders(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	from, to, err := analyticsRange(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := analytics.DefaultSenderLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > analytics.MaxSenderLimit {
+			RespondError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", analytics.MaxSenderLimit))
+			return
+		}
+		limit = n
+	}
+	senders, err := h.Analytics.Senders(r.Context(), userID, from, to, limit)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to compute sender stats")
+		return
+	}
+	RespondJSON(w, http.StatusOK, SenderStatsResponse{From: from, To: to, Senders: senders})
+}
+
+// GetVolume handles GET /api/analytics/volume: how much mail arrived each day between ?from
+// and ?to, days being in the user's timezone
+func (h *StatsHandler) GetVolume(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	from, to, err := analyticsRange(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	volume, err := h.Analytics.Volume(r.Context(), userID, from, to)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to compute volume")
+		return
+	}
+	RespondJSON(w, http.StatusOK, VolumeResponse{From: from, To: to, InboxVolume: volume})
+}
+
+// analyticsRange reads the ?from and ?to RFC 3339 times. to defaults to now and from to
+// analytics.DefaultRange before to; the period may not exceed analytics.MaxRange.
+func analyticsRange(r *http.Request) (time.Time, time.Time, error) {
+	q := r.URL.Query()
+	to := time.Now().UTC().Truncate(time.Second)
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("to must be an RFC 3339 time")
+		}
+		to = t
+	}
+	from := to.Add(-analytics.DefaultRange)
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("from must be an RFC 3339 time")
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from must be before to")
+	}
+	if to.Sub(from) > analytics.MaxRange {
+		return time.Time{}, time.Time{}, errors.New("the period may not exceed 366 days")
+	}
+	return from, to, nil
+}
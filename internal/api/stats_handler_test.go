@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/analytics"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+type stubAnalyticsRepo struct {
+	err      error
+	from, to int64
+	limit    int
+}
+
+func (s *stubAnalyticsRepo) RecordAction(ctx context.Context, userID, action, targetType string) error {
+	return nil
+}
+func (s *stubAnalyticsRepo) CountActionsSince(ctx context.Context, userID, action string, since time.Time) (int, error) {
+	return 3, s.err
+}
+func (s *stubAnalyticsRepo) CountMessagesCachedSince(ctx context.Context, userID string, since time.Time) (int, error) {
+	return 20, s.err
+}
+func (s *stubAnalyticsRepo) CountMessages(ctx context.Context, userID string) (int, error) {
+	return 0, s.err
+}
+func (s *stubAnalyticsRepo) RecordInboxSize(ctx context.Context, userID string, day time.Time, size int) error {
+	return nil
+}
+func (s *stubAnalyticsRepo) WeeklyInboxSizes(ctx context.Context, userID string, since time.Time) ([]models.InboxSizePoint, error) {
+	return []models.InboxSizePoint{{WeekStart: since, AverageSize: 120}}, s.err
+}
+
+func (s *stubAnalyticsRepo) SenderStats(ctx context.Context, userID string, from, to int64, limit int) ([]models.SenderStat, error) {
+	s.from, s.to, s.limit = from, to, limit
+	return []models.SenderStat{{Address: "news@shop.example", Messages: 4, Unread: 1}}, s.err
+}
+func (s *stubAnalyticsRepo) DailyVolume(ctx context.Context, userID string, from, to int64, timezone string) ([]models.VolumePoint, error) {
+	return []models.VolumePoint{{Day: "2026-10-14", Messages: 3}}, s.err
+}
+
+func TestGetMyStats(t *testing.T) {
+	h := NewStatsHandler(analytics.NewService(&stubAnalyticsRepo{}))
+	r := httptest.NewRequest("GET", "/api/users/me/stats", nil)
+	r = r.WithContext(ctxkeys.WithUserID(r.Context(), "user1"))
+	w := httptest.NewRecorder()
+	h.GetMyStats(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats models.UserStats
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+	require.Equal(t, 20, stats.MessagesProcessed)
+	require.Equal(t, 3, stats.Archived)
+	require.Len(t, stats.InboxSizeTrend, 1)
+
+	h = NewStatsHandler(analytics.NewService(&stubAnalyticsRepo{err: errors.New("db down")}))
+	w = httptest.NewRecorder()
+	h.GetMyStats(w, r)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = httptest.NewRecorder()
+	h.GetMyStats(w, httptest.NewRequest("GET", "/api/users/me/stats", nil))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestStatsHandler_SendersAndVolume(t *testing.T) {
+	repo := &stubAnalyticsRepo{}
+	h := NewStatsHandler(analytics.NewService(repo))
+	get := func(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", target, nil)
+		r = r.WithContext(ctxkeys.WithUserID(r.Context(), "user1"))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	w := get(h.GetSenders, "/api/analytics/senders?from=2026-10-01T00:00:00Z&to=2026-10-15T00:00:00Z&limit=5")
+	require.Equal(t, http.StatusOK, w.Code)
+	var senders SenderStatsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&senders))
+	require.Len(t, senders.Senders, 1)
+	require.Equal(t, 0.25, senders.Senders[0].UnreadRatio)
+	require.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC).UnixMilli(), repo.from)
+	require.Equal(t, 5, repo.limit)
+
+	// The period defaults to the last 30 days
+	require.Equal(t, http.StatusOK, get(h.GetSenders, "/api/analytics/senders").Code)
+	require.Equal(t, analytics.DefaultRange.Milliseconds(), repo.to-repo.from)
+	require.Equal(t, analytics.DefaultSenderLimit, repo.limit)
+
+	w = get(h.GetVolume, "/api/analytics/volume?from=2026-10-13T00:00:00Z&to=2026-10-15T00:00:00Z")
+	require.Equal(t, http.StatusOK, w.Code)
+	var volume struct {
+		Timezone string               `json:"timezone"`
+		Days     []models.VolumePoint `json:"days"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&volume))
+	require.Equal(t, "UTC", volume.Timezone)
+	require.Equal(t, []models.VolumePoint{{Day: "2026-10-13"}, {Day: "2026-10-14", Messages: 3}}, volume.Days)
+
+	for _, target := range []string{
+		"/api/analytics/senders?limit=0",
+		"/api/analytics/senders?from=yesterday",
+		"/api/analytics/senders?from=2026-10-15T00:00:00Z&to=2026-10-01T00:00:00Z",
+		"/api/analytics/senders?from=2024-01-01T00:00:00Z&to=2026-01-01T00:00:00Z",
+	} {
+		require.Equal(t, http.StatusBadRequest, get(h.GetSenders, target).Code, target)
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/desponda/inbox-whisperer/internal/status"
+)
+
+// StatusReporter builds the public status page (see status.Service)
+type StatusReporter interface {
+	Report(ctx context.Context) *status.Report
+}
+
+type StatusHandler struct {
+	Status StatusReporter
+}
+
+func NewStatusHandler(s StatusReporter) *StatusHandler {
+	return &StatusHandler{Status: s}
+}
+
+// GetStatus handles GET /api/status, the public status page: each component's current state
+// and rolling uptime. It needs no authentication and may be cached by proxies for 30 seconds.
+func (h *StatusHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=30")
+	RespondJSON(w, http.StatusOK, h.Status.Report(r.Context()))
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/status"
+	"github.com/stretchr/testify/require"
+)
+
+type fixedStatus struct{ report *status.Report }
+
+func (f fixedStatus) Report(ctx context.Context) *status.Report {
+	return f.report
+}
+
+func TestStatusHandler(t *testing.T) {
+	h := NewStatusHandler(fixedStatus{&status.Report{Status: status.Degraded, Components: []status.ComponentStatus{
+		{Name: status.ComponentAI, Status: status.Degraded, Uptime: map[string]float64{"24h": 99.5}},
+	}}})
+	w := httptest.NewRecorder()
+	h.GetStatus(w, httptest.NewRequest("GET", "/api/status", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "public, max-age=30", w.Header().Get("Cache-Control"))
+	var got status.Report
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	require.Equal(t, status.Degraded, got.Status)
+	require.Equal(t, 99.5, got.Components[0].Uptime["24h"])
+}
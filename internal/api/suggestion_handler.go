@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/rules"
+	"github.com/desponda/inbox-whisperer/internal/suggestions"
+)
+
+type SuggestionHandler struct {
+	Suggestions *suggestions.Service
+}
+
+func NewSuggestionHandler(svc *suggestions.Service) *SuggestionHandler {
+	return &SuggestionHandler{Suggestions: svc}
+}
+
+// ListSuggestions handles GET /api/rules/suggestions
+func (h *SuggestionHandler) ListSuggestions(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	list, err := h.Suggestions.Suggestions(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load rule suggestions")
+		return
+	}
+	RespondJSON(w, http.StatusOK, list)
+}
+
+// AcceptSuggestion handles POST /api/rules/suggestions/{id}/accept
+func (h *SuggestionHandler) AcceptSuggestion(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := suggestionParams(w, r)
+	if !ok {
+		return
+	}
+	rule, err := h.Suggestions.Accept(r.Context(), userID, id)
+	if err != nil {
+		writeSuggestionError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusCreated, rule)
+}
+
+// DismissSuggestion handles POST /api/rules/suggestions/{id}/dismiss
+func (h *SuggestionHandler) DismissSuggestion(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := suggestionParams(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Suggestions.Dismiss(r.Context(), userID, id); err != nil {
+		writeSuggestionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func suggestionParams(w http.ResponseWriter, r *http.Request) (string, int64, bool) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return "", 0, false
+	}
+	idParam, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return "", 0, false
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, "invalid suggestion id")
+		return "", 0, false
+	}
+	return userID, id, true
+}
+
+func writeSuggestionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		RespondError(w, http.StatusNotFound, "suggestion not found")
+	case errors.Is(err, suggestions.ErrAlreadyDecided):
+		RespondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, rules.ErrInvalidRule):
+		RespondError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		RespondError(w, http.StatusInternalServerError, "failed to update rule suggestion")
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/suggestions"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// stubSuggestionRepo reports one sender archived often enough to suggest a rule
+type stubSuggestionRepo struct {
+	observed    []*models.UserAction
+	suggestions []*models.RuleSuggestion
+}
+
+func (s *stubSuggestionRepo) RecordAction(ctx context.Context, a *models.UserAction) error {
+	s.observed = append(s.observed, a)
+	return nil
+}
+func (s *stubSuggestionRepo) CountActionsBySender(ctx context.Context, userID, action string, min int) ([]models.ActionCount, error) {
+	if action != models.UserActionArchive {
+		return nil, nil
+	}
+	return []models.ActionCount{{Sender: "news@shop.example", Count: 4}}, nil
+}
+func (s *stubSuggestionRepo) CountActionsByCategory(ctx context.Context, userID, action string, min int) ([]models.ActionCount, error) {
+	return nil, nil
+}
+func (s *stubSuggestionRepo) CountCorrections(ctx context.Context, userID string, min int) ([]models.ActionCount, error) {
+	return nil, nil
+}
+func (s *stubSuggestionRepo) UpsertSuggestion(ctx context.Context, sug *models.RuleSuggestion) error {
+	for _, existing := range s.suggestions {
+		if existing.Key == sug.Key {
+			sug.ID, sug.Status = existing.ID, existing.Status
+			return nil
+		}
+	}
+	sug.ID, sug.Status = int64(len(s.suggestions)+1), models.SuggestionPending
+	s.suggestions = append(s.suggestions, sug)
+	return nil
+}
+func (s *stubSuggestionRepo) ListSuggestions(ctx context.Context, userID, status string) ([]*models.RuleSuggestion, error) {
+	var out []*models.RuleSuggestion
+	for _, sug := range s.suggestions {
+		if sug.Status == status {
+			out = append(out, sug)
+		}
+	}
+	return out, nil
+}
+func (s *stubSuggestionRepo) GetSuggestion(ctx context.Context, userID string, id int64) (*models.RuleSuggestion, error) {
+	if id < 1 || id > int64(len(s.suggestions)) {
+		return nil, data.ErrNotFound
+	}
+	return s.suggestions[id-1], nil
+}
+func (s *stubSuggestionRepo) SetSuggestionStatus(ctx context.Context, userID string, id int64, status string, ruleID *int64) error {
+	s.suggestions[id-1].Status = status
+	return nil
+}
+
+func suggestionRequest(method, id string) *http.Request {
+	r := httptest.NewRequest(method, "/api/rules/suggestions", nil)
+	ctx := ctxkeys.WithUserID(r.Context(), "user1")
+	if id != "" {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+	}
+	return r.WithContext(ctx)
+}
+
+func TestSuggestionHandler(t *testing.T) {
+	ruleRepo := &stubRuleRepo{}
+	h := NewSuggestionHandler(suggestions.NewService(&stubSuggestionRepo{}, ruleRepo))
+
+	w := httptest.NewRecorder()
+	h.ListSuggestions(w, suggestionRequest("GET", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+	var list []models.RuleSuggestion
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	require.Len(t, list, 1)
+	require.Equal(t, models.RuleActionArchive, list[0].Rule.Actions[0].Type)
+
+	w = httptest.NewRecorder()
+	h.AcceptSuggestion(w, suggestionRequest("POST", "1"))
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Len(t, ruleRepo.created, 1)
+
+	w = httptest.NewRecorder()
+	h.DismissSuggestion(w, suggestionRequest("POST", "1"))
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	w = httptest.NewRecorder()
+	h.DismissSuggestion(w, suggestionRequest("POST", "7"))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	h.AcceptSuggestion(w, suggestionRequest("POST", "abc"))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/notify"
+	gmail "github.com/desponda/inbox-whisperer/internal/service/gmail"
+)
+
+// SyncStatusResponse is returned by GET /api/email/sync/status
+type SyncStatusResponse struct {
+	// SyncComplete is true once per finished background sync (cleared on read)
+	SyncComplete bool `json:"sync_complete"`
+	// FailedItems lists messages that exhausted their retry attempts
+	FailedItems []*models.FailedSyncItem `json:"failed_items"`
+}
+
+type SyncHandler struct {
+	FailedItems data.FailedSyncItemRepository
+}
+
+func NewSyncHandler(failedItems data.FailedSyncItemRepository) *SyncHandler {
+	return &SyncHandler{FailedItems: failedItems}
+}
+
+// GetSyncStatus handles GET /api/email/sync/status
+func (h *SyncHandler) GetSyncStatus(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	failed, err := h.FailedItems.ListExhausted(r.Context(), userID, gmail.MaxSyncAttempts)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load sync failures")
+		return
+	}
+	if failed == nil {
+		failed = []*models.FailedSyncItem{}
+	}
+	RespondJSON(w, http.StatusOK, SyncStatusResponse{
+		SyncComplete: notify.CheckAndClearGmailSyncStatus(userID),
+		FailedItems:  failed,
+	})
+}
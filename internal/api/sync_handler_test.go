@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/notify"
+	"github.com/stretchr/testify/require"
+)
+
+type stubFailedItems struct {
+	exhausted []*models.FailedSyncItem
+	err       error
+}
+
+func (s *stubFailedItems) RecordFailure(ctx context.Context, userID, emailMessageID, stage, errMsg string) error {
+	return nil
+}
+func (s *stubFailedItems) ListRetryable(ctx context.Context, userID string, maxAttempts, limit int) ([]*models.FailedSyncItem, error) {
+	return nil, nil
+}
+func (s *stubFailedItems) ListExhausted(ctx context.Context, userID string, maxAttempts int) ([]*models.FailedSyncItem, error) {
+	return s.exhausted, s.err
+}
+func (s *stubFailedItems) Resolve(ctx context.Context, userID, emailMessageID string) error {
+	return nil
+}
+
+func TestGetSyncStatus(t *testing.T) {
+	t.Run("unauthenticated", func(t *testing.T) {
+		h := NewSyncHandler(&stubFailedItems{})
+		w := httptest.NewRecorder()
+		h.GetSyncStatus(w, httptest.NewRequest("GET", "/api/email/sync/status", nil))
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("surfaces exhausted failures", func(t *testing.T) {
+		notify.SetGmailSyncStatus("user1")
+		h := NewSyncHandler(&stubFailedItems{exhausted: []*models.FailedSyncItem{
+			{EmailMessageID: "m1", Stage: models.SyncStageFetch, LastError: "boom", Attempts: 5},
+		}})
+		r := httptest.NewRequest("GET", "/api/email/sync/status", nil)
+		r = r.WithContext(ctxkeys.WithUserID(r.Context(), "user1"))
+		w := httptest.NewRecorder()
+		h.GetSyncStatus(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp SyncStatusResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		require.True(t, resp.SyncComplete)
+		require.Len(t, resp.FailedItems, 1)
+		require.Equal(t, "m1", resp.FailedItems[0].EmailMessageID)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		h := NewSyncHandler(&stubFailedItems{err: errors.New("db down")})
+		r := httptest.NewRequest("GET", "/api/email/sync/status", nil)
+		r = r.WithContext(ctxkeys.WithUserID(r.Context(), "user1"))
+		w := httptest.NewRecorder()
+		h.GetSyncStatus(w, r)
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
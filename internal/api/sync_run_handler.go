@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+)
+
+// Defaults and limits for the sync run endpoints
+const (
+	DefaultSyncRunDays  = 1
+	DefaultSyncRunLimit = 100
+	MaxSyncRunLimit     = 1000
+)
+
+type SyncRunHandler struct {
+	Runs data.SyncRunRepository
+	now  func() time.Time
+}
+
+func NewSyncRunHandler(runs data.SyncRunRepository) *SyncRunHandler {
+	return &SyncRunHandler{Runs: runs, now: time.Now}
+}
+
+// AdminList handles GET /api/admin/sync-runs: the latest sync runs over the last days (1 to 90),
+// optionally for one user, newest first
+func (h *SyncRunHandler) AdminList(w http.ResponseWriter, r *http.Request) {
+	since, ok := h.since(w, r)
+	if !ok {
+		return
+	}
+	limit := DefaultSyncRunLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxSyncRunLimit {
+			RespondError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+	runs, err := h.Runs.ListSyncRuns(r.Context(), r.URL.Query().Get("user_id"), since, limit)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to list sync runs")
+		return
+	}
+	RespondJSON(w, http.StatusOK, runs)
+}
+
+// AdminStats handles GET /api/admin/sync-runs/stats: sync run totals per provider over the
+// last days (1 to 90)
+func (h *SyncRunHandler) AdminStats(w http.ResponseWriter, r *http.Request) {
+	since, ok := h.since(w, r)
+	if !ok {
+		return
+	}
+	stats, err := h.Runs.SyncRunStats(r.Context(), since)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load sync run stats")
+		return
+	}
+	RespondJSON(w, http.StatusOK, stats)
+}
+
+// since parses the days query parameter, responding with 400 when it is out of range
+func (h *SyncRunHandler) since(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	days := DefaultSyncRunDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 90 {
+			RespondError(w, http.StatusBadRequest, "days must be between 1 and 90")
+			return time.Time{}, false
+		}
+		days = n
+	}
+	return h.now().AddDate(0, 0, -days), true
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+type stubSyncRuns struct {
+	userID string
+	since  time.Time
+	limit  int
+}
+
+func (s *stubSyncRuns) RecordSyncRun(ctx context.Context, run *models.SyncRun) error { return nil }
+
+func (s *stubSyncRuns) ListSyncRuns(ctx context.Context, userID string, since time.Time, limit int) ([]models.SyncRun, error) {
+	s.userID, s.since, s.limit = userID, since, limit
+	return []models.SyncRun{{ID: 1, UserID: "u1", Provider: "gmail", Listed: 3}}, nil
+}
+
+func (s *stubSyncRuns) SyncRunStats(ctx context.Context, since time.Time) ([]models.SyncRunStats, error) {
+	s.since = since
+	return []models.SyncRunStats{{Provider: "gmail", Runs: 2, FailedRuns: 1}}, nil
+}
+
+func TestSyncRunHandler(t *testing.T) {
+	repo := &stubSyncRuns{}
+	h := NewSyncRunHandler(repo)
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+
+	rw := httptest.NewRecorder()
+	h.AdminList(rw, httptest.NewRequest(http.MethodGet, "/api/admin/sync-runs?user_id=u1&days=7&limit=5", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, "u1", repo.userID)
+	require.Equal(t, now.AddDate(0, 0, -7), repo.since)
+	require.Equal(t, 5, repo.limit)
+	var runs []models.SyncRun
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&runs))
+	require.Len(t, runs, 1)
+
+	rw = httptest.NewRecorder()
+	h.AdminList(rw, httptest.NewRequest(http.MethodGet, "/api/admin/sync-runs?limit=5000", nil))
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.AdminStats(rw, httptest.NewRequest(http.MethodGet, "/api/admin/sync-runs/stats", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, now.AddDate(0, 0, -DefaultSyncRunDays), repo.since)
+	var stats []models.SyncRunStats
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&stats))
+	require.Equal(t, 1, stats[0].FailedRuns)
+
+	rw = httptest.NewRecorder()
+	h.AdminStats(rw, httptest.NewRequest(http.MethodGet, "/api/admin/sync-runs/stats?days=91", nil))
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/scheduler"
+)
+
+type SyncScheduleHandler struct {
+	Scheduler *scheduler.Scheduler
+}
+
+func NewSyncScheduleHandler(s *scheduler.Scheduler) *SyncScheduleHandler {
+	return &SyncScheduleHandler{Scheduler: s}
+}
+
+// SyncScheduleRequest assigns a tier and optionally overrides its interval; a null
+// interval_seconds clears the override and 0 makes the user manual-only
+type SyncScheduleRequest struct {
+	Tier            string `json:"tier"`
+	IntervalSeconds *int   `json:"interval_seconds"`
+}
+
+// SyncScheduleResponse is a user's schedule with the interval the scheduler applies
+type SyncScheduleResponse struct {
+	*models.SyncSchedule
+	EffectiveIntervalSeconds int `json:"effective_interval_seconds"`
+}
+
+// AdminGet handles GET /api/admin/users/{id}/sync
+func (h *SyncScheduleHandler) AdminGet(w http.ResponseWriter, r *http.Request) {
+	userID, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sched, err := h.Scheduler.Schedule(r.Context(), userID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load sync schedule")
+		return
+	}
+	h.respond(w, sched)
+}
+
+// AdminPut handles PUT /api/admin/users/{id}/sync
+func (h *SyncScheduleHandler) AdminPut(w http.ResponseWriter, r *http.Request) {
+	userID, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req SyncScheduleRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		RespondBodyError(w, err, "invalid request body")
+		return
+	}
+	sched := &models.SyncSchedule{UserID: userID, Tier: req.Tier, IntervalSeconds: req.IntervalSeconds}
+	err = h.Scheduler.SetSchedule(r.Context(), sched)
+	if errors.Is(err, scheduler.ErrUnknownTier) || errors.Is(err, scheduler.ErrInvalidInterval) {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to save sync schedule")
+		return
+	}
+	h.respond(w, sched)
+}
+
+func (h *SyncScheduleHandler) respond(w http.ResponseWriter, sched *models.SyncSchedule) {
+	RespondJSON(w, http.StatusOK, SyncScheduleResponse{
+		SyncSchedule:             sched,
+		EffectiveIntervalSeconds: int(h.Scheduler.Interval(sched).Seconds()),
+	})
+}
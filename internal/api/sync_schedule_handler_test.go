@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/scheduler"
+	"github.com/stretchr/testify/require"
+)
+
+type stubSyncScheduleRepo struct {
+	byUser map[string]*models.SyncSchedule
+}
+
+func (s *stubSyncScheduleRepo) ListSyncCandidates(ctx context.Context) ([]*models.SyncSchedule, error) {
+	return nil, nil
+}
+func (s *stubSyncScheduleRepo) GetSchedule(ctx context.Context, userID string) (*models.SyncSchedule, error) {
+	if sched, ok := s.byUser[userID]; ok {
+		return sched, nil
+	}
+	return &models.SyncSchedule{UserID: userID}, nil
+}
+func (s *stubSyncScheduleRepo) SetSchedule(ctx context.Context, sched *models.SyncSchedule) error {
+	sched.UpdatedAt = time.Now()
+	s.byUser[sched.UserID] = sched
+	return nil
+}
+func (s *stubSyncScheduleRepo) MarkSynced(ctx context.Context, userID string, at time.Time) error {
+	return nil
+}
+
+func TestSyncScheduleHandler(t *testing.T) {
+	sched, err := scheduler.New(&stubSyncScheduleRepo{byUser: map[string]*models.SyncSchedule{}}, nil, nil, scheduler.DefaultTiers(), "")
+	require.NoError(t, err)
+	h := NewSyncScheduleHandler(sched)
+
+	rw := httptest.NewRecorder()
+	h.AdminGet(rw, recategorizeRequest(http.MethodGet, "admin", "user1", ""))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var resp struct {
+		Tier                     string `json:"tier"`
+		IntervalSeconds          *int   `json:"interval_seconds"`
+		EffectiveIntervalSeconds int    `json:"effective_interval_seconds"`
+	}
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+	require.Equal(t, 900, resp.EffectiveIntervalSeconds, "users without a tier get the default tier")
+
+	rw = httptest.NewRecorder()
+	h.AdminPut(rw, recategorizeRequest(http.MethodPut, "admin", "user1", `{"tier":"paid"}`))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+	require.Equal(t, "paid", resp.Tier)
+	require.Equal(t, 120, resp.EffectiveIntervalSeconds)
+
+	rw = httptest.NewRecorder()
+	h.AdminPut(rw, recategorizeRequest(http.MethodPut, "admin", "user1", `{"tier":"paid","interval_seconds":0}`))
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+	require.Equal(t, 0, resp.EffectiveIntervalSeconds, "an override of 0 makes the user manual-only")
+
+	rw = httptest.NewRecorder()
+	h.AdminPut(rw, recategorizeRequest(http.MethodPut, "admin", "user1", `{"tier":"gold"}`))
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.AdminPut(rw, recategorizeRequest(http.MethodPut, "admin", "user1", `{"interval_seconds":-1}`))
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+}
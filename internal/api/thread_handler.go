@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"golang.org/x/oauth2"
+)
+
+// ThreadMuter mutes and unmutes conversations (see service.ThreadMuteService)
+type ThreadMuter interface {
+	Mute(ctx context.Context, userID string, token *oauth2.Token, threadID string) (*models.MutedThread, error)
+	Unmute(ctx context.Context, userID string, token *oauth2.Token, threadID string) error
+}
+
+// MessageContentFetcher loads a message's full content from the provider (see
+// service.EmailService)
+type MessageContentFetcher interface {
+	FetchMessageContent(ctx context.Context, token *oauth2.Token, id string) (*models.EmailMessage, error)
+}
+
+// Limits of the thread endpoints
+const (
+	DefaultThreadLimit = 50
+	MaxThreadLimit     = 200
+	// MaxFullThreadMessages bounds the provider fetches of GET /api/threads/{id}?full=true;
+	// only the latest messages of longer threads get their bodies
+	MaxFullThreadMessages = 50
+)
+
+type ThreadHandler struct {
+	Mutes ThreadMuter
+	// Threads and Content serve the thread list and detail endpoints
+	Threads data.ThreadRepository
+	Content MessageContentFetcher
+	// Counter, if set, fills the total estimate of ?envelope=true lists
+	Counter MessageCounter
+}
+
+func NewThreadHandler(mutes ThreadMuter) *ThreadHandler {
+	return &ThreadHandler{Mutes: mutes}
+}
+
+// ListThreads handles GET /api/threads: cached messages grouped into conversations, most
+// recently active first. Pages continue from after_internal_date and after_id, the latest
+// internal date and ID of the previous page's last thread, or from the next_cursor of an
+// ?envelope=true page.
+func (h *ThreadHandler) ListThreads(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	q := r.URL.Query()
+	limit := DefaultThreadLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxThreadLimit {
+			RespondError(w, http.StatusBadRequest, "limit must be between 1 and 200")
+			return
+		}
+		limit = n
+	}
+	envelope, err := wantsEnvelope(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	c, err := pageCursor(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if c.PageToken != "" {
+		RespondError(w, http.StatusBadRequest, errInvalidCursor.Error())
+		return
+	}
+	if q.Get("cursor") == "" && (c.ID == "") != (q.Get("after_internal_date") == "") {
+		RespondError(w, http.StatusBadRequest, "after_internal_date and after_id must be given together")
+		return
+	}
+	threads, err := h.Threads.ListThreads(r.Context(), userID, limit, c.InternalDate, c.ID)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to list threads")
+		return
+	}
+	if !envelope {
+		RespondJSON(w, http.StatusOK, threads)
+		return
+	}
+	page := Page[*models.ThreadSummary]{Items: threads, Limit: limit}
+	if len(threads) > 0 && len(threads) >= limit {
+		last := threads[len(threads)-1]
+		page.NextCursor = Cursor{InternalDate: last.LatestInternalDate, ID: last.ThreadID}.Encode()
+	}
+	if h.Counter != nil {
+		page.TotalEstimate = totalEstimate(r.Context(), h.Counter.CountThreads, userID)
+	}
+	RespondJSON(w, http.StatusOK, page)
+}
+
+// GetThread handles GET /api/threads/{id}: the thread's cached messages, oldest first. With
+// full=true the bodies of its latest messages are fetched from the provider.
+func (h *ThreadHandler) GetThread(w http.ResponseWriter, r *http.Request) {
+	userID, threadID, tok, ok := threadParams(w, r)
+	if !ok {
+		return
+	}
+	full := false
+	if v := r.URL.Query().Get("full"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			RespondError(w, http.StatusBadRequest, "full must be true or false")
+			return
+		}
+		full = b
+	}
+	thread, err := h.Threads.GetThread(r.Context(), userID, threadID)
+	if errors.Is(err, data.ErrNotFound) {
+		RespondError(w, http.StatusNotFound, "thread not found")
+		return
+	}
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to load thread")
+		return
+	}
+	if full {
+		for i := max(0, len(thread.Messages)-MaxFullThreadMessages); i < len(thread.Messages); i++ {
+			m := &thread.Messages[i]
+			msg, err := h.Content.FetchMessageContent(r.Context(), tok, m.ID)
+			if err != nil {
+				writeProviderError(w, err)
+				return
+			}
+			m.Body, m.HTMLBody = msg.Body, msg.HTMLBody
+		}
+	}
+	RespondJSON(w, http.StatusOK, thread)
+}
+
+// Mute handles POST /api/threads/{id}/mute
+func (h *ThreadHandler) Mute(w http.ResponseWriter, r *http.Request) {
+	userID, threadID, tok, ok := threadParams(w, r)
+	if !ok {
+		return
+	}
+	muted, err := h.Mutes.Mute(r.Context(), userID, tok, threadID)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+	RespondJSON(w, http.StatusOK, muted)
+}
+
+// Unmute handles POST /api/threads/{id}/unmute
+func (h *ThreadHandler) Unmute(w http.ResponseWriter, r *http.Request) {
+	userID, threadID, tok, ok := threadParams(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Mutes.Unmute(r.Context(), userID, tok, threadID); err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			RespondError(w, http.StatusNotFound, "thread is not muted")
+			return
+		}
+		writeProviderError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func threadParams(w http.ResponseWriter, r *http.Request) (string, string, *oauth2.Token, bool) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return "", "", nil, false
+	}
+	threadID, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return "", "", nil, false
+	}
+	tok := ctxkeys.Token(r.Context())
+	if tok == nil {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no token in context")
+		return "", "", nil, false
+	}
+	return userID, threadID, tok, true
+}
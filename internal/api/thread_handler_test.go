@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/desponda/inbox-whisperer/internal/testutils"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+type stubThreadMuter struct {
+	muted map[string]bool
+	err   error
+}
+
+func (s *stubThreadMuter) Mute(ctx context.Context, userID string, token *oauth2.Token, threadID string) (*models.MutedThread, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.muted[threadID] = true
+	return &models.MutedThread{UserID: userID, ThreadID: threadID}, nil
+}
+func (s *stubThreadMuter) Unmute(ctx context.Context, userID string, token *oauth2.Token, threadID string) error {
+	if !s.muted[threadID] {
+		return data.ErrNotFound
+	}
+	delete(s.muted, threadID)
+	return nil
+}
+
+func TestThreadHandler_MuteAndUnmute(t *testing.T) {
+	mutes := &stubThreadMuter{muted: map[string]bool{}}
+	h := NewThreadHandler(mutes)
+
+	rw := httptest.NewRecorder()
+	h.Mute(rw, messageActionRequest("t1"))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var muted models.MutedThread
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&muted))
+	require.Equal(t, "t1", muted.ThreadID)
+
+	rw = httptest.NewRecorder()
+	h.Unmute(rw, messageActionRequest("t1"))
+	require.Equal(t, http.StatusNoContent, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.Unmute(rw, messageActionRequest("t1"))
+	require.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestThreadHandler_MuteProviderError(t *testing.T) {
+	h := NewThreadHandler(&stubThreadMuter{err: provider.ErrUnsupported})
+	rw := httptest.NewRecorder()
+	h.Mute(rw, messageActionRequest("t1"))
+	require.Equal(t, http.StatusNotImplemented, rw.Code)
+}
+
+type stubThreadRepo struct {
+	threads   map[string]*models.Thread
+	afterDate int64
+	afterID   string
+	gotLimit  int
+}
+
+func (s *stubThreadRepo) ListThreads(ctx context.Context, userID string, limit int, afterInternalDate int64, afterID string) ([]*models.ThreadSummary, error) {
+	s.gotLimit, s.afterDate, s.afterID = limit, afterInternalDate, afterID
+	out := []*models.ThreadSummary{}
+	for _, t := range s.threads {
+		out = append(out, &t.ThreadSummary)
+	}
+	return out, nil
+}
+
+func (s *stubThreadRepo) GetThread(ctx context.Context, userID, threadID string) (*models.Thread, error) {
+	t, ok := s.threads[threadID]
+	if !ok {
+		return nil, data.ErrNotFound
+	}
+	cp := *t
+	cp.Messages = append([]models.ThreadMessage(nil), t.Messages...)
+	return &cp, nil
+}
+
+type stubContentFetcher struct {
+	fetched []string
+	err     error
+}
+
+func (s *stubContentFetcher) FetchMessageContent(ctx context.Context, token *oauth2.Token, id string) (*models.EmailMessage, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.fetched = append(s.fetched, id)
+	return &models.EmailMessage{EmailMessageID: id, Body: "body of " + id}, nil
+}
+
+func newThreadTestHandler() (*ThreadHandler, *stubThreadRepo, *stubContentFetcher) {
+	repo := &stubThreadRepo{threads: map[string]*models.Thread{
+		"t1": {
+			ThreadSummary: models.ThreadSummary{ThreadID: "t1", Subject: "Lunch?", MessageCount: 2, UnreadCount: 1, Participants: []string{"ann@example.com"}},
+			Messages:      []models.ThreadMessage{{ID: "m1", IsRead: true}, {ID: "m2"}},
+		},
+	}}
+	content := &stubContentFetcher{}
+	h := NewThreadHandler(&stubThreadMuter{muted: map[string]bool{}})
+	h.Threads, h.Content = repo, content
+	return h, repo, content
+}
+
+func TestThreadHandler_ListThreads(t *testing.T) {
+	h, repo, _ := newThreadTestHandler()
+	list := func(query string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		h.ListThreads(rw, testutils.NewAuthedRequest("GET", "/api/threads"+query, nil))
+		return rw
+	}
+
+	rw := list("")
+	require.Equal(t, http.StatusOK, rw.Code)
+	var threads []models.ThreadSummary
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&threads))
+	require.Len(t, threads, 1)
+	require.Equal(t, 1, threads[0].UnreadCount)
+	require.Equal(t, DefaultThreadLimit, repo.gotLimit)
+
+	require.Equal(t, http.StatusOK, list("?limit=10&after_internal_date=400&after_id=t9").Code)
+	require.Equal(t, 10, repo.gotLimit)
+	require.Equal(t, int64(400), repo.afterDate)
+	require.Equal(t, "t9", repo.afterID)
+
+	require.Equal(t, http.StatusBadRequest, list("?limit=0").Code)
+	require.Equal(t, http.StatusBadRequest, list("?after_id=t9").Code, "the cursor needs both parts")
+	require.Equal(t, http.StatusBadRequest, list("?after_internal_date=soon&after_id=t9").Code)
+}
+
+func TestThreadHandler_ListThreadsEnvelope(t *testing.T) {
+	h, repo, _ := newThreadTestHandler()
+	h.Counter = stubMessageCounter{threads: 3}
+	repo.threads["t1"].LatestInternalDate = 400
+	list := func(query string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		h.ListThreads(rw, testutils.NewAuthedRequest("GET", "/api/threads"+query, nil))
+		return rw
+	}
+
+	rw := list("?envelope=true&limit=1")
+	require.Equal(t, http.StatusOK, rw.Code)
+	var page Page[models.ThreadSummary]
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&page))
+	require.Len(t, page.Items, 1)
+	require.Equal(t, 1, page.Limit)
+	require.Equal(t, int64(3), *page.TotalEstimate)
+
+	require.Equal(t, http.StatusOK, list("?cursor="+page.NextCursor).Code)
+	require.Equal(t, int64(400), repo.afterDate)
+	require.Equal(t, "t1", repo.afterID)
+
+	// A short page is the last one
+	rw = list("?envelope=true")
+	page = Page[models.ThreadSummary]{}
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&page))
+	require.Empty(t, page.NextCursor)
+
+	require.Equal(t, http.StatusBadRequest, list("?cursor="+Cursor{PageToken: "p2"}.Encode()).Code)
+}
+
+func TestThreadHandler_GetThread(t *testing.T) {
+	h, _, content := newThreadTestHandler()
+	get := func(id, query string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		h.GetThread(rw, testutils.NewAuthedRequest("GET", "/api/threads/"+id+query, nil, testutils.WithURLParam("id", id)))
+		return rw
+	}
+
+	rw := get("t1", "")
+	require.Equal(t, http.StatusOK, rw.Code)
+	var thread models.Thread
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&thread))
+	require.Equal(t, "Lunch?", thread.Subject)
+	require.Len(t, thread.Messages, 2)
+	require.Empty(t, thread.Messages[0].Body)
+	require.Empty(t, content.fetched, "cached threads are served without the provider")
+
+	rw = get("t1", "?full=true")
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&thread))
+	require.Equal(t, []string{"m1", "m2"}, content.fetched)
+	require.Equal(t, "body of m2", thread.Messages[1].Body)
+
+	require.Equal(t, http.StatusNotFound, get("missing", "").Code)
+	require.Equal(t, http.StatusBadRequest, get("t1", "?full=maybe").Code)
+	content.err = provider.ErrAuthExpired
+	require.Equal(t, http.StatusUnauthorized, get("t1", "?full=true").Code)
+}
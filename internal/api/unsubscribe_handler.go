@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/unsubscribe"
+	"golang.org/x/oauth2"
+)
+
+// Unsubscriber unsubscribes users from the sender of a message (see unsubscribe.Service)
+type Unsubscriber interface {
+	Unsubscribe(ctx context.Context, userID string, token *oauth2.Token, messageID string) (*models.Unsubscribe, error)
+}
+
+// UnsubscribeHandler serves the unsubscribe assistant
+type UnsubscribeHandler struct {
+	Unsubscribes Unsubscriber
+}
+
+func NewUnsubscribeHandler(unsubscribes Unsubscriber) *UnsubscribeHandler {
+	return &UnsubscribeHandler{Unsubscribes: unsubscribes}
+}
+
+// Unsubscribe handles POST /api/emails/{id}/unsubscribe, carrying out the unsubscribe the
+// message's List-Unsubscribe headers offer. A sender that refuses the request is answered with
+// 200 and a failed status, so the client can show the outcome.
+func (h *UnsubscribeHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
+		return
+	}
+	id, err := ValidateIDParam(r)
+	if err != nil {
+		RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	tok := ctxkeys.Token(r.Context())
+	if tok == nil {
+		RespondError(w, http.StatusUnauthorized, "not authenticated: no token in context")
+		return
+	}
+	outcome, err := h.Unsubscribes.Unsubscribe(r.Context(), userID, tok, id)
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		RespondError(w, http.StatusNotFound, "message not found")
+	case errors.Is(err, unsubscribe.ErrNotOffered), errors.Is(err, unsubscribe.ErrManualOnly):
+		RespondError(w, http.StatusUnprocessableEntity, err.Error())
+	case err != nil:
+		writeProviderError(w, err)
+	default:
+		RespondJSON(w, http.StatusOK, outcome)
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/desponda/inbox-whisperer/internal/unsubscribe"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+type stubUnsubscriber struct{ errs map[string]error }
+
+func (s stubUnsubscriber) Unsubscribe(ctx context.Context, userID string, token *oauth2.Token, messageID string) (*models.Unsubscribe, error) {
+	if err := s.errs[messageID]; err != nil {
+		return nil, err
+	}
+	return &models.Unsubscribe{SenderAddress: "news@example.com", EmailMessageID: messageID,
+		Method: models.UnsubscribeOneClick, Status: models.UnsubscribeSucceeded}, nil
+}
+
+func TestUnsubscribeHandler_Unsubscribe(t *testing.T) {
+	h := NewUnsubscribeHandler(stubUnsubscriber{errs: map[string]error{
+		"gone":   data.ErrNotFound,
+		"none":   unsubscribe.ErrNotOffered,
+		"manual": unsubscribe.ErrManualOnly,
+		"scope":  &provider.ScopeError{Feature: provider.FeatureSend, Missing: []string{"send"}},
+	}})
+	for id, want := range map[string]int{
+		"m1":     http.StatusOK,
+		"gone":   http.StatusNotFound,
+		"none":   http.StatusUnprocessableEntity,
+		"manual": http.StatusUnprocessableEntity,
+		"scope":  http.StatusForbidden,
+	} {
+		w := httptest.NewRecorder()
+		h.Unsubscribe(w, messageActionRequest(id))
+		require.Equal(t, want, w.Code, id)
+	}
+
+	w := httptest.NewRecorder()
+	h.Unsubscribe(w, messageActionRequest("m1"))
+	require.JSONEq(t, `{"sender_address":"news@example.com","email_message_id":"m1","method":"one_click","status":"succeeded","attempted_at":"0001-01-01T00:00:00Z"}`, w.Body.String())
+}
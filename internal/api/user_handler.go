@@ -1,11 +1,14 @@
 package api
 
 import (
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
 	"github.com/desponda/inbox-whisperer/internal/service"
 	"github.com/desponda/inbox-whisperer/internal/session"
 	"github.com/go-chi/chi/v5"
-	"net/http"
 	"github.com/rs/zerolog/log"
+	"net/http"
 )
 
 // GetMe handles GET /api/users/me
@@ -15,7 +18,7 @@ func (h *UserHandler) GetMe(w http.ResponseWriter, r *http.Request) {
 	if cookie, err := r.Cookie("session_id"); err == nil {
 		sessionID = cookie.Value
 	}
-	userID := session.GetUserID(r.Context())
+	userID := ctxkeys.UserID(r.Context())
 	if userID == "" {
 		log.Debug().Str("session_id", sessionID).Msg("GetMe: not authenticated, no userID in session")
 		session.ClearSession(w, r)
@@ -29,17 +32,53 @@ func (h *UserHandler) GetMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Debug().Str("session_id", sessionID).Str("user_id", userID).Msg("GetMe: user authenticated and found")
-	RespondJSON(w, http.StatusOK, user)
+	RespondJSON(w, http.StatusOK, Me{User: user, ReadOnly: session.IsReadOnly(r)})
+}
+
+// Me is the body of GET /api/users/me
+type Me struct {
+	*models.User
+	// ReadOnly is true for sessions signed in with read_only=true, which cannot make changes
+	ReadOnly bool `json:"read_only"`
 }
 
 type UserHandler struct {
 	Service service.UserServiceInterface
+	// AdminUserIDs are admins whatever their role, as for AdminOnly
+	AdminUserIDs []string
+	// Roles looks up the role of users not in AdminUserIDs
+	Roles data.UserRoleRepository
+}
+
+// requireAdmin reports whether the request's user may act on other users, like AdminOnly,
+// answering the request when they may not
+func (h *UserHandler) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	admin, err := isAdmin(r, h.AdminUserIDs, h.Roles)
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to check user role")
+		return false
+	}
+	if !admin {
+		RespondError(w, http.StatusForbidden, "forbidden")
+	}
+	return admin
 }
 
 // ListUsers handles GET /users
 // Only admin should be able to list all users
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	RespondError(w, http.StatusForbidden, "forbidden")
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	users, err := h.Service.ListUsers(r.Context())
+	if err != nil {
+		RespondError(w, http.StatusInternalServerError, "failed to list users")
+		return
+	}
+	if users == nil {
+		users = []*models.User{}
+	}
+	RespondJSON(w, http.StatusOK, users)
 }
 
 // UpdateUser handles PUT /users/{id}
@@ -50,11 +89,8 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		RespondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	userIDVal := r.Context().Value(ContextUserIDKey)
-
-	userID, ok := userIDVal.(string)
-	if !ok || userID == "" {
-
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
 		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
 		return
 	}
@@ -66,7 +102,7 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		// Add safe fields here if/when model expands
 	}
 	if err := DecodeJSON(r, &req); err != nil {
-		RespondError(w, http.StatusBadRequest, "invalid request body")
+		RespondBodyError(w, err, "invalid request body")
 		return
 	}
 	// No updatable fields; respond with error
@@ -81,11 +117,8 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		RespondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	userIDVal := r.Context().Value(ContextUserIDKey)
-
-	userID, ok := userIDVal.(string)
-	if !ok || userID == "" {
-
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
 		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
 		return
 	}
@@ -101,15 +134,15 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func NewUserHandler(svc service.UserServiceInterface) *UserHandler {
-	return &UserHandler{Service: svc}
+func NewUserHandler(svc service.UserServiceInterface, roles data.UserRoleRepository, adminUserIDs []string) *UserHandler {
+	return &UserHandler{Service: svc, Roles: roles, AdminUserIDs: adminUserIDs}
 }
 
 // RequireSameUser is middleware that ensures the session user matches the {id} param
 func RequireSameUser(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
-		sessionUserID := session.GetUserID(r.Context())
+		sessionUserID := ctxkeys.UserID(r.Context())
 		if id == "" || sessionUserID == "" || id != sessionUserID {
 			RespondError(w, http.StatusForbidden, "forbidden")
 			return
@@ -119,22 +152,19 @@ func RequireSameUser(next http.Handler) http.Handler {
 }
 
 // GET /users/{id}
+// Users may read themselves; admins may read anyone
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	id, err := ValidateIDParam(r)
 	if err != nil {
 		RespondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	userIDVal := r.Context().Value(ContextUserIDKey)
-
-	userID, ok := userIDVal.(string)
-	if !ok || userID == "" {
-
+	userID := ctxkeys.UserID(r.Context())
+	if userID == "" {
 		RespondError(w, http.StatusUnauthorized, "not authenticated: no userID in context")
 		return
 	}
-	if id != userID {
-		RespondError(w, http.StatusForbidden, "forbidden")
+	if id != userID && !h.requireAdmin(w, r) {
 		return
 	}
 	user, err := h.Service.GetUser(r.Context(), id)
@@ -146,7 +176,7 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 }
 
 // POST /users
-// Only admin should be able to create users directly
+// Users are created when they first sign in, so not even admins create them directly
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	RespondError(w, http.StatusForbidden, "forbidden")
 }
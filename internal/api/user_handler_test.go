@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
 	"github.com/desponda/inbox-whisperer/internal/models"
 	"github.com/desponda/inbox-whisperer/internal/session"
 	"github.com/go-chi/chi/v5"
@@ -106,7 +107,7 @@ func TestUserHandler_GetUser(t *testing.T) {
 				req.AddCookie(c)
 			}
 			// Inject user ID into context for authenticated session
-			ctx := context.WithValue(req.Context(), ContextUserIDKey, tc.id)
+			ctx := ctxkeys.WithUserID(req.Context(), tc.id)
 			req = req.WithContext(ctx)
 			w := httptest.NewRecorder()
 			rWithSession := session.Middleware(r)
@@ -146,7 +147,7 @@ func TestUserHandler_UpdateUser(t *testing.T) {
 		req.AddCookie(c)
 	}
 	// Inject user ID into context for authenticated session
-	ctx := context.WithValue(req.Context(), ContextUserIDKey, "a")
+	ctx := ctxkeys.WithUserID(req.Context(), "a")
 	req = req.WithContext(ctx)
 	w := httptest.NewRecorder()
 	rWithSession := session.Middleware(r)
@@ -184,7 +185,7 @@ func TestUserHandler_DeleteUser(t *testing.T) {
 		req.AddCookie(c)
 	}
 	// Inject user ID into context for authenticated session
-	ctx := context.WithValue(req.Context(), ContextUserIDKey, "a")
+	ctx := ctxkeys.WithUserID(req.Context(), "a")
 	req = req.WithContext(ctx)
 	w := httptest.NewRecorder()
 	rWithSession := session.Middleware(r)
@@ -228,7 +229,7 @@ func TestUserHandler_DeleteUserServiceError(t *testing.T) {
 		req.AddCookie(c)
 	}
 	// Inject user ID into context for authenticated session
-	ctx := context.WithValue(req.Context(), ContextUserIDKey, "a")
+	ctx := ctxkeys.WithUserID(req.Context(), "a")
 	req = req.WithContext(ctx)
 	w := httptest.NewRecorder()
 	rWithSession := session.Middleware(r)
@@ -2,7 +2,7 @@ package api
 
 import (
 	"errors"
-	"github.com/desponda/inbox-whisperer/internal/session"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
 	"github.com/go-chi/chi/v5"
 	"net/http"
 )
@@ -18,7 +18,7 @@ func ValidateIDParam(r *http.Request) (string, error) {
 
 // ValidateAuth ensures the user is authenticated and returns the userID, or an error.
 func ValidateAuth(r *http.Request) (string, error) {
-	userID := session.GetUserID(r.Context())
+	userID := ctxkeys.UserID(r.Context())
 	if userID == "" {
 		return "", errors.New("not authenticated: no user session")
 	}
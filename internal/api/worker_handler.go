@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/desponda/inbox-whisperer/internal/ai"
+	"github.com/desponda/inbox-whisperer/internal/health"
+)
+
+// AIHealth reports the external AI provider's health (see ai.Gateway)
+type AIHealth interface {
+	ProviderStatus() ai.ProviderStatus
+}
+
+type WorkerHandler struct {
+	Monitor *health.Monitor
+	// AI, if set, adds the AI provider's health to /readyz; a degraded provider is reported
+	// but does not fail readiness, since AI features fall back to heuristics and cached results
+	AI AIHealth
+}
+
+func NewWorkerHandler(m *health.Monitor) *WorkerHandler {
+	return &WorkerHandler{Monitor: m}
+}
+
+// ReadyResponse is the body of a successful GET /readyz
+type ReadyResponse struct {
+	Status string `json:"status"`
+	// AI is the AI provider's status: ok, degraded or disabled; omitted when AI is not set up
+	AI string `json:"ai,omitempty"`
+}
+
+// WorkersStatusResponse lists every background worker; Ready is false if any is stuck
+type WorkersStatusResponse struct {
+	Ready   bool                  `json:"ready"`
+	Workers []health.WorkerStatus `json:"workers"`
+}
+
+// AdminStatus handles GET /api/admin/workers/status
+func (h *WorkerHandler) AdminStatus(w http.ResponseWriter, r *http.Request) {
+	RespondJSON(w, http.StatusOK, WorkersStatusResponse{
+		Ready:   h.Monitor.Ready() == nil,
+		Workers: h.Monitor.Status(r.Context()),
+	})
+}
+
+// Ready handles GET /readyz, failing with 503 while any worker is stuck so orchestrators
+// stop routing to (and eventually restart) the instance
+func (h *WorkerHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	if err := h.Monitor.Ready(); err != nil {
+		RespondError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	resp := ReadyResponse{Status: "ok"}
+	if h.AI != nil {
+		resp.AI = h.AI.ProviderStatus().Status
+	}
+	RespondJSON(w, http.StatusOK, resp)
+}
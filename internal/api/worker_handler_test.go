@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ai"
+	"github.com/desponda/inbox-whisperer/internal/health"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerHandler(t *testing.T) {
+	monitor := health.NewMonitor()
+	monitor.Register("recategorize", 1, health.DefaultStallAfter, nil).Record("recategorize_job", nil)
+	h := NewWorkerHandler(monitor)
+
+	rw := httptest.NewRecorder()
+	h.AdminStatus(rw, httptest.NewRequest(http.MethodGet, "/api/admin/workers/status", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	var resp WorkersStatusResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+	require.True(t, resp.Ready)
+	require.Len(t, resp.Workers, 1)
+	require.Equal(t, int64(1), resp.Workers[0].Jobs["recategorize_job"].Attempted)
+
+	rw = httptest.NewRecorder()
+	h.Ready(rw, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	// A worker that has not reported within its stall threshold is stuck
+	monitor.Register("sync_scheduler", 1, time.Nanosecond, nil)
+	time.Sleep(time.Millisecond)
+	rw = httptest.NewRecorder()
+	h.Ready(rw, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	require.Contains(t, rw.Body.String(), "sync_scheduler")
+}
+
+type stubAIHealth struct {
+	status string
+}
+
+func (s stubAIHealth) ProviderStatus() ai.ProviderStatus { return ai.ProviderStatus{Status: s.status} }
+
+func TestWorkerHandler_ReadyReportsDegradedAI(t *testing.T) {
+	h := NewWorkerHandler(health.NewMonitor())
+	h.AI = stubAIHealth{status: ai.ProviderDegraded}
+
+	rw := httptest.NewRecorder()
+	h.Ready(rw, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusOK, rw.Code, "a degraded AI provider must not fail readiness")
+	var resp ReadyResponse
+	require.NoError(t, json.NewDecoder(rw.Body).Decode(&resp))
+	require.Equal(t, ReadyResponse{Status: "ok", AI: ai.ProviderDegraded}, resp)
+}
@@ -0,0 +1,213 @@
+// Package apikeys issues and checks API keys, and runs the device authorization flow
+// (RFC 8628) through which a CLI obtains one: the CLI starts a device code, the user approves
+// its user code in a signed-in browser, and the CLI's next poll receives a new key.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+const (
+	// KeyPrefix starts every API key, so keys are recognisable in headers and leaked logs
+	KeyPrefix = "iw_"
+	// DeviceCodeTTL is how long a device code can be approved and polled
+	DeviceCodeTTL = 10 * time.Minute
+	// PollInterval is the minimum time between polls of one device code
+	PollInterval = 5 * time.Second
+	// DeviceKeyName names keys issued through the device flow when the CLI gives no name
+	DeviceKeyName = "CLI"
+
+	// displayPrefixLen is how much of a key is kept in the clear to identify it
+	displayPrefixLen = 8
+	// userCodeAlphabet has no vowels, so codes cannot spell words, and no look-alike digits
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLen      = 8
+)
+
+// Device flow poll outcomes; the messages are the RFC 8628 error codes
+var (
+	ErrAuthorizationPending = errors.New("authorization_pending")
+	ErrSlowDown             = errors.New("slow_down")
+	ErrAccessDenied         = errors.New("access_denied")
+	ErrExpiredToken         = errors.New("expired_token")
+)
+
+// ErrInvalidKey is returned by Authenticate for unknown, revoked and malformed keys
+var ErrInvalidKey = errors.New("invalid API key")
+
+// DeviceAuthorization is returned when a device flow starts
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// Service issues API keys and runs the device flow
+type Service struct {
+	keys    data.APIKeyRepository
+	devices data.DeviceCodeRepository
+	// verificationURI is the page where users enter user codes
+	verificationURI string
+	now             func() time.Time
+}
+
+// NewService creates a Service; frontendURL hosts the /device page where codes are approved
+func NewService(keys data.APIKeyRepository, devices data.DeviceCodeRepository, frontendURL string) *Service {
+	return &Service{
+		keys:            keys,
+		devices:         devices,
+		verificationURI: strings.TrimSuffix(frontendURL, "/") + "/device",
+		now:             time.Now,
+	}
+}
+
+// Create issues a key for the user. The raw key is only available here; only its hash is stored.
+func (s *Service) Create(ctx context.Context, userID, name string) (*models.APIKey, string, error) {
+	raw, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+	raw = KeyPrefix + raw
+	key := &models.APIKey{UserID: userID, Name: name, Prefix: raw[:len(KeyPrefix)+displayPrefixLen]}
+	if err := s.keys.Create(ctx, key, hash(raw)); err != nil {
+		return nil, "", err
+	}
+	return key, raw, nil
+}
+
+// List returns the user's active keys
+func (s *Service) List(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	return s.keys.ListByUser(ctx, userID)
+}
+
+// Revoke disables one of the user's keys; returns data.ErrNotFound if it is not theirs or already revoked
+func (s *Service) Revoke(ctx context.Context, userID string, id int64) error {
+	return s.keys.Revoke(ctx, userID, id)
+}
+
+// Authenticate returns the key raw identifies
+func (s *Service) Authenticate(ctx context.Context, raw string) (*models.APIKey, error) {
+	if !strings.HasPrefix(raw, KeyPrefix) {
+		return nil, ErrInvalidKey
+	}
+	key, err := s.keys.Authenticate(ctx, hash(raw))
+	if errors.Is(err, data.ErrNotFound) {
+		return nil, ErrInvalidKey
+	}
+	return key, err
+}
+
+// StartDevice begins a device authorization
+func (s *Service) StartDevice(ctx context.Context) (*DeviceAuthorization, error) {
+	deviceCode, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	userCode, err := randomUserCode()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.devices.Create(ctx, hash(deviceCode), userCode, s.now().Add(DeviceCodeTTL)); err != nil {
+		return nil, err
+	}
+	return &DeviceAuthorization{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         s.verificationURI,
+		VerificationURIComplete: s.verificationURI + "?user_code=" + userCode,
+		ExpiresIn:               int(DeviceCodeTTL / time.Second),
+		Interval:                int(PollInterval / time.Second),
+	}, nil
+}
+
+// ApproveDevice binds the device code behind userCode to the user. Returns data.ErrNotFound for
+// unknown, expired and already decided codes.
+func (s *Service) ApproveDevice(ctx context.Context, userCode, userID string) error {
+	return s.devices.Decide(ctx, NormalizeUserCode(userCode), userID, true)
+}
+
+// DenyDevice rejects the device code behind userCode
+func (s *Service) DenyDevice(ctx context.Context, userCode string) error {
+	return s.devices.Decide(ctx, NormalizeUserCode(userCode), "", false)
+}
+
+// PollDevice exchanges an approved device code for a new key. Until then it returns one of
+// ErrAuthorizationPending, ErrSlowDown, ErrAccessDenied or ErrExpiredToken.
+func (s *Service) PollDevice(ctx context.Context, deviceCode string) (*models.APIKey, string, error) {
+	h := hash(deviceCode)
+	code, err := s.devices.Poll(ctx, h)
+	if errors.Is(err, data.ErrNotFound) {
+		// Consumed and purged codes look the same to the client as expired ones
+		return nil, "", ErrExpiredToken
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	now := s.now()
+	switch {
+	case !now.Before(code.ExpiresAt):
+		return nil, "", ErrExpiredToken
+	case code.LastPolledAt != nil && now.Sub(*code.LastPolledAt) < PollInterval:
+		return nil, "", ErrSlowDown
+	case code.Status == models.DeviceCodeDenied:
+		return nil, "", ErrAccessDenied
+	case code.Status != models.DeviceCodeApproved:
+		return nil, "", ErrAuthorizationPending
+	}
+	userID, err := s.devices.Consume(ctx, h)
+	if errors.Is(err, data.ErrNotFound) {
+		// A concurrent poll got the key
+		return nil, "", ErrExpiredToken
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return s.Create(ctx, userID, DeviceKeyName)
+}
+
+// NormalizeUserCode accepts user codes typed in lower case, with or without the hyphen
+func NormalizeUserCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != userCodeLen {
+		return code
+	}
+	return code[:userCodeLen/2] + "-" + code[userCodeLen/2:]
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func randomUserCode() (string, error) {
+	b := make([]byte, userCodeLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		// 256 is not a multiple of 20, so this is slightly biased; irrelevant for a 10-minute code
+		b[i] = userCodeAlphabet[int(b[i])%len(userCodeAlphabet)]
+	}
+	return NormalizeUserCode(string(b)), nil
+}
@@ -0,0 +1,200 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+type memKeys struct {
+	byHash  map[string]*models.APIKey
+	revoked map[int64]bool
+}
+
+func (m *memKeys) Create(ctx context.Context, key *models.APIKey, keyHash string) error {
+	key.ID = int64(len(m.byHash) + 1)
+	m.byHash[keyHash] = key
+	return nil
+}
+func (m *memKeys) ListByUser(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	var out []*models.APIKey
+	for _, k := range m.byHash {
+		if k.UserID == userID && !m.revoked[k.ID] {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
+func (m *memKeys) Revoke(ctx context.Context, userID string, id int64) error {
+	for _, k := range m.byHash {
+		if k.ID == id && k.UserID == userID && !m.revoked[id] {
+			m.revoked[id] = true
+			return nil
+		}
+	}
+	return data.ErrNotFound
+}
+func (m *memKeys) Authenticate(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	k, ok := m.byHash[keyHash]
+	if !ok || m.revoked[k.ID] {
+		return nil, data.ErrNotFound
+	}
+	return k, nil
+}
+
+type memDevices struct {
+	now   func() time.Time
+	codes map[string]*models.DeviceCode
+}
+
+func (m *memDevices) Create(ctx context.Context, deviceCodeHash, userCode string, expiresAt time.Time) error {
+	m.codes[deviceCodeHash] = &models.DeviceCode{UserCode: userCode, Status: models.DeviceCodePending, ExpiresAt: expiresAt}
+	return nil
+}
+func (m *memDevices) Decide(ctx context.Context, userCode, userID string, approve bool) error {
+	for _, c := range m.codes {
+		if c.UserCode == userCode && c.Status == models.DeviceCodePending && m.now().Before(c.ExpiresAt) {
+			c.Status, c.UserID = models.DeviceCodeDenied, userID
+			if approve {
+				c.Status = models.DeviceCodeApproved
+			}
+			return nil
+		}
+	}
+	return data.ErrNotFound
+}
+func (m *memDevices) Poll(ctx context.Context, deviceCodeHash string) (*models.DeviceCode, error) {
+	c, ok := m.codes[deviceCodeHash]
+	if !ok {
+		return nil, data.ErrNotFound
+	}
+	prev := *c
+	now := m.now()
+	c.LastPolledAt = &now
+	return &prev, nil
+}
+func (m *memDevices) Consume(ctx context.Context, deviceCodeHash string) (string, error) {
+	c, ok := m.codes[deviceCodeHash]
+	if !ok || c.Status != models.DeviceCodeApproved {
+		return "", data.ErrNotFound
+	}
+	delete(m.codes, deviceCodeHash)
+	return c.UserID, nil
+}
+
+func newTestService() (*Service, *time.Time) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	s := NewService(&memKeys{byHash: map[string]*models.APIKey{}, revoked: map[int64]bool{}},
+		&memDevices{now: clock, codes: map[string]*models.DeviceCode{}}, "https://app.example.com/")
+	s.now = clock
+	return s, &now
+}
+
+func TestCreateAuthenticateRevoke(t *testing.T) {
+	s, _ := newTestService()
+	ctx := context.Background()
+	key, raw, err := s.Create(ctx, "user1", "laptop")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !strings.HasPrefix(raw, KeyPrefix) || !strings.HasPrefix(raw, key.Prefix) || len(key.Prefix) != len(KeyPrefix)+displayPrefixLen {
+		t.Errorf("unexpected key %q with prefix %q", raw, key.Prefix)
+	}
+	got, err := s.Authenticate(ctx, raw)
+	if err != nil || got.UserID != "user1" {
+		t.Fatalf("expected key of user1, got %+v, %v", got, err)
+	}
+	for _, bad := range []string{"", "not-a-key", raw + "x"} {
+		if _, err := s.Authenticate(ctx, bad); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Authenticate(%q) = %v, want ErrInvalidKey", bad, err)
+		}
+	}
+	if err := s.Revoke(ctx, "user2", key.ID); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("expected another user's revoke to fail, got %v", err)
+	}
+	if err := s.Revoke(ctx, "user1", key.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := s.Authenticate(ctx, raw); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected revoked key to be rejected, got %v", err)
+	}
+}
+
+func TestDeviceFlow(t *testing.T) {
+	s, now := newTestService()
+	ctx := context.Background()
+	auth, err := s.StartDevice(ctx)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if auth.VerificationURI != "https://app.example.com/device" || auth.VerificationURIComplete != auth.VerificationURI+"?user_code="+auth.UserCode {
+		t.Errorf("unexpected verification URIs %+v", auth)
+	}
+	if _, _, err := s.PollDevice(ctx, auth.DeviceCode); !errors.Is(err, ErrAuthorizationPending) {
+		t.Errorf("expected pending, got %v", err)
+	}
+	if _, _, err := s.PollDevice(ctx, auth.DeviceCode); !errors.Is(err, ErrSlowDown) {
+		t.Errorf("expected slow_down on an immediate second poll, got %v", err)
+	}
+	if err := s.ApproveDevice(ctx, strings.ToLower(strings.ReplaceAll(auth.UserCode, "-", "")), "user1"); err != nil {
+		t.Fatalf("approve with a normalized code: %v", err)
+	}
+	if err := s.DenyDevice(ctx, auth.UserCode); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("expected a decided code to be final, got %v", err)
+	}
+	*now = now.Add(PollInterval)
+	key, raw, err := s.PollDevice(ctx, auth.DeviceCode)
+	if err != nil || key.UserID != "user1" || key.Name != DeviceKeyName {
+		t.Fatalf("expected a key for user1, got %+v, %v", key, err)
+	}
+	if got, err := s.Authenticate(ctx, raw); err != nil || got.ID != key.ID {
+		t.Errorf("expected the issued key to authenticate, got %+v, %v", got, err)
+	}
+	*now = now.Add(PollInterval)
+	if _, _, err := s.PollDevice(ctx, auth.DeviceCode); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("expected a consumed code to be gone, got %v", err)
+	}
+}
+
+func TestDeviceFlowDeniedAndExpired(t *testing.T) {
+	s, now := newTestService()
+	ctx := context.Background()
+	denied, _ := s.StartDevice(ctx)
+	expired, _ := s.StartDevice(ctx)
+	if err := s.DenyDevice(ctx, denied.UserCode); err != nil {
+		t.Fatalf("deny: %v", err)
+	}
+	if _, _, err := s.PollDevice(ctx, denied.DeviceCode); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("expected access_denied, got %v", err)
+	}
+	*now = now.Add(DeviceCodeTTL)
+	if err := s.ApproveDevice(ctx, expired.UserCode, "user1"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("expected an expired code not to be approvable, got %v", err)
+	}
+	if _, _, err := s.PollDevice(ctx, expired.DeviceCode); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("expected expired_token, got %v", err)
+	}
+}
+
+func TestNormalizeUserCode(t *testing.T) {
+	for in, want := range map[string]string{
+		"bcdf-ghjk": "BCDF-GHJK",
+		"BCDFGHJK":  "BCDF-GHJK",
+		"bcdf ghjk": "BCDF-GHJK",
+		"bcd":       "BCD",
+	} {
+		if got := NormalizeUserCode(in); got != want {
+			t.Errorf("NormalizeUserCode(%q) = %q, want %q", in, got, want)
+		}
+	}
+	code, err := randomUserCode()
+	if err != nil || len(code) != userCodeLen+1 || strings.Trim(strings.Replace(code, "-", "", 1), userCodeAlphabet) != "" {
+		t.Errorf("unexpected user code %q, %v", code, err)
+	}
+}
@@ -0,0 +1,90 @@
+// Package backfill runs data migrations that cannot be done in SQL alone, such as dropping raw
+// payloads once storage.drop_raw_json is on or compressing bodies stored before compression.
+// Each runs batch by batch in the background, and every batch commits on its own, so an
+// interrupted run loses nothing and the next one picks up where it stopped.
+//
+// It also fills users' caches with their whole mailboxes on request (see Mailbox).
+package backfill
+
+import (
+	"context"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+	telemetryerrors "github.com/desponda/inbox-whisperer/internal/telemetry/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Defaults for a runner's pace
+const (
+	DefaultBatchSize = 500
+	DefaultInterval  = time.Second
+)
+
+// Step processes up to limit items and returns how many it processed; fewer than limit means
+// the backfill is done
+type Step func(ctx context.Context, limit int) (int64, error)
+
+// Runner repeats a Step until the backfill is done
+type Runner struct {
+	name string
+	step Step
+
+	// BatchSize is the limit passed to each step
+	BatchSize int
+	// Interval is the delay between batches, keeping the job from monopolising the database
+	Interval time.Duration
+	// Maintenance, if set, pauses the runner while it is on
+	Maintenance *maintenance.Switch
+	// Errors, if set, receives a failed run
+	Errors telemetryerrors.Reporter
+}
+
+// NewRunner creates a Runner for step; name identifies it in logs and error reports
+func NewRunner(name string, step Step) *Runner {
+	return &Runner{name: name, step: step, BatchSize: DefaultBatchSize, Interval: DefaultInterval}
+}
+
+// Start runs the backfill in the background until it is done or ctx is cancelled
+func (r *Runner) Start(ctx context.Context) {
+	go func() {
+		if err := r.Run(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Str("backfill", r.name).Msg("backfill: run failed")
+			telemetryerrors.Capture(ctx, r.Errors, err, "", map[string]string{"job_type": "backfill_" + r.name})
+		}
+	}()
+}
+
+// Run processes batches until the step reports a short batch
+func (r *Runner) Run(ctx context.Context) error {
+	var total int64
+	for {
+		if err := maintenance.Wait(ctx, r.Maintenance, nil); err != nil {
+			return err
+		}
+		n, err := r.step(ctx, r.BatchSize)
+		if err != nil {
+			return err
+		}
+		total += n
+		if n < int64(r.BatchSize) {
+			log.Info().Str("backfill", r.name).Int64("processed", total).Msg("backfill: done")
+			return nil
+		}
+		if !sleep(ctx, r.Interval) {
+			return ctx.Err()
+		}
+	}
+}
+
+// sleep waits for d and reports whether ctx is still live
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
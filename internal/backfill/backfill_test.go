@@ -0,0 +1,66 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeStore holds a count of items left to process
+type fakeStore struct {
+	remaining int64
+	calls     int
+	err       error
+}
+
+func (f *fakeStore) step(ctx context.Context, limit int) (int64, error) {
+	f.calls++
+	if f.err != nil {
+		return 0, f.err
+	}
+	n := min(f.remaining, int64(limit))
+	f.remaining -= n
+	return n, nil
+}
+
+func TestRunner_RunPrunesInBatches(t *testing.T) {
+	store := &fakeStore{remaining: 25}
+	p := NewRunner("test", store.step)
+	p.BatchSize, p.Interval = 10, time.Millisecond
+
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if store.remaining != 0 || store.calls != 3 {
+		t.Errorf("expected everything processed in 3 batches, got %d left after %d", store.remaining, store.calls)
+	}
+
+	// A full final batch takes one more empty batch to notice the end
+	store = &fakeStore{remaining: 20}
+	p = NewRunner("test", store.step)
+	p.BatchSize, p.Interval = 10, time.Millisecond
+	if err := p.Run(context.Background()); err != nil || store.calls != 3 {
+		t.Errorf("expected 3 batches, got %d (%v)", store.calls, err)
+	}
+}
+
+func TestRunner_RunStopsOnError(t *testing.T) {
+	boom := errors.New("db down")
+	store := &fakeStore{remaining: 25, err: boom}
+	p := NewRunner("test", store.step)
+	if err := p.Run(context.Background()); !errors.Is(err, boom) || store.calls != 1 {
+		t.Errorf("expected the run to stop at the first error, got %v after %d calls", err, store.calls)
+	}
+}
+
+func TestRunner_RunStopsWhenCancelled(t *testing.T) {
+	store := &fakeStore{remaining: 100}
+	p := NewRunner("test", store.step)
+	p.BatchSize, p.Interval = 10, time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Run(ctx); !errors.Is(err, context.Canceled) || store.calls != 1 {
+		t.Errorf("expected the run to stop after one batch, got %v after %d calls", err, store.calls)
+	}
+}
@@ -0,0 +1,116 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/jobs"
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+// ErrMailboxActive is returned when the user already has a mailbox backfill queued or running
+var ErrMailboxActive = errors.New("a mailbox backfill is already queued or running")
+
+// DefaultMailboxInterval is the delay between pages of a mailbox backfill, leaving the
+// provider's quota to the regular syncs
+const DefaultMailboxInterval = 2 * time.Second
+
+// PageBackfiller caches one page of a user's message list (see gmail.GmailService.BackfillPage)
+type PageBackfiller interface {
+	BackfillPage(ctx context.Context, userID string, token *oauth2.Token, pageToken string) (next string, cached int, err error)
+}
+
+// TokenSource loads the token a backfill reads with (see data.UserTokenRepository)
+type TokenSource interface {
+	GetUserToken(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error)
+}
+
+// Queue runs the backfills in the background (see jobs.Queue)
+type Queue interface {
+	Enqueue(ctx context.Context, kind, userID string, refID int64, payload any) (*models.Job, error)
+	Active(ctx context.Context, kind, userID string) (*models.Job, error)
+	Save(ctx context.Context, job *models.Job) error
+}
+
+// mailboxPayload is the payload of a models.JobKindMailboxBackfill job
+type mailboxPayload struct {
+	AccountID string `json:"account_id,omitempty"`
+}
+
+// Mailbox caches users' whole mailboxes, where the regular syncs only keep the newest messages
+// current. Each backfill is a job queue job that walks the message list page by page, keeping
+// the next page token as its checkpoint, so a retried or interrupted backfill resumes where it
+// stopped.
+type Mailbox struct {
+	pages  PageBackfiller
+	tokens TokenSource
+	queue  Queue
+
+	// Interval is the delay between pages
+	Interval time.Duration
+	// Maintenance, if set, pauses running backfills while it is on
+	Maintenance *maintenance.Switch
+}
+
+func NewMailbox(pages PageBackfiller, tokens TokenSource, queue Queue) *Mailbox {
+	return &Mailbox{pages: pages, tokens: tokens, queue: queue, Interval: DefaultMailboxInterval}
+}
+
+// Enqueue queues a backfill of one of the user's accounts, the default one for an empty
+// accountID. If the user already has one pending it is returned together with ErrMailboxActive.
+func (m *Mailbox) Enqueue(ctx context.Context, userID, accountID string) (*models.Job, error) {
+	active, err := m.queue.Active(ctx, models.JobKindMailboxBackfill, userID)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		return active, ErrMailboxActive
+	}
+	return m.queue.Enqueue(ctx, models.JobKindMailboxBackfill, userID, 0, mailboxPayload{AccountID: accountID})
+}
+
+// Run is the job queue's handler for models.JobKindMailboxBackfill
+func (m *Mailbox) Run(ctx context.Context, job *models.Job) error {
+	var p mailboxPayload
+	if len(job.Payload) > 0 {
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return jobs.Permanent(fmt.Errorf("invalid payload: %w", err))
+		}
+	}
+	token, err := m.tokens.GetUserToken(ctx, job.UserID, data.ProviderGmail, p.AccountID)
+	if errors.Is(err, data.ErrNotFound) {
+		// The account was unlinked
+		return jobs.Permanent(fmt.Errorf("failed to load token: %w", err))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load token: %w", err)
+	}
+	for {
+		if err := maintenance.Wait(ctx, m.Maintenance, nil); err != nil {
+			return err
+		}
+		next, cached, err := m.pages.BackfillPage(ctx, job.UserID, token, job.Checkpoint)
+		if err != nil {
+			return err
+		}
+		job.Processed += cached
+		job.Checkpoint = next
+		if err := m.queue.Save(ctx, job); err != nil {
+			log.Error().Err(err).Int64("job_id", job.ID).Msg("backfill: failed to save mailbox backfill progress")
+		}
+		if next == "" {
+			log.Info().Int64("job_id", job.ID).Int("cached", job.Processed).Msg("backfill: mailbox done")
+			return nil
+		}
+		if !sleep(ctx, m.Interval) {
+			return ctx.Err()
+		}
+	}
+}
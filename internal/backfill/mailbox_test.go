@@ -0,0 +1,103 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/jobs"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"golang.org/x/oauth2"
+)
+
+// fakePages serves a mailbox of three pages, "" -> "p2" -> "p3" -> ""
+type fakePages struct {
+	requested []string
+	failOn    string
+}
+
+func (f *fakePages) BackfillPage(ctx context.Context, userID string, token *oauth2.Token, pageToken string) (string, int, error) {
+	f.requested = append(f.requested, pageToken)
+	if pageToken == f.failOn {
+		return "", 0, errors.New("provider down")
+	}
+	next := map[string]string{"": "p2", "p2": "p3", "p3": ""}[pageToken]
+	return next, 10, nil
+}
+
+type fakeTokens struct{ accounts map[string]bool }
+
+func (f fakeTokens) GetUserToken(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
+	if !f.accounts[accountID] {
+		return nil, data.ErrNotFound
+	}
+	return &oauth2.Token{AccessToken: "t"}, nil
+}
+
+// fakeQueue keeps queue jobs in memory
+type fakeQueue struct {
+	jobs  []*models.Job
+	saves int
+}
+
+func (f *fakeQueue) Enqueue(ctx context.Context, kind, userID string, refID int64, payload any) (*models.Job, error) {
+	raw, _ := json.Marshal(payload)
+	job := &models.Job{ID: int64(len(f.jobs) + 1), Kind: kind, UserID: userID, Payload: raw, Status: models.JobQueued}
+	f.jobs = append(f.jobs, job)
+	return job, nil
+}
+
+func (f *fakeQueue) Active(ctx context.Context, kind, userID string) (*models.Job, error) {
+	for _, j := range f.jobs {
+		if j.Kind == kind && j.UserID == userID && !j.Finished() {
+			return j, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeQueue) Save(ctx context.Context, job *models.Job) error {
+	f.saves++
+	return nil
+}
+
+func TestMailbox_RunResumesFromCheckpoint(t *testing.T) {
+	pages, queue := &fakePages{failOn: "p3"}, &fakeQueue{}
+	m := NewMailbox(pages, fakeTokens{map[string]bool{"acct": true}}, queue)
+	m.Interval = 0
+	ctx := context.Background()
+
+	job, err := m.Enqueue(ctx, "u1", "acct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := m.Enqueue(ctx, "u1", ""); !errors.Is(err, ErrMailboxActive) || again.ID != job.ID {
+		t.Errorf("expected the pending backfill returned, got %+v (%v)", again, err)
+	}
+
+	if err := m.Run(ctx, job); err == nil || jobs.IsPermanent(err) {
+		t.Fatalf("expected a retryable error, got %v", err)
+	}
+	if job.Checkpoint != "p3" || job.Processed != 20 || queue.saves != 2 {
+		t.Errorf("expected the progress saved up to the failed page, got %+v after %d saves", job, queue.saves)
+	}
+
+	pages.failOn = "none"
+	if err := m.Run(ctx, job); err != nil {
+		t.Fatalf("expected the retry to finish, got %v", err)
+	}
+	if job.Checkpoint != "" || job.Processed != 30 || len(pages.requested) != 4 || pages.requested[3] != "p3" {
+		t.Errorf("expected the retry to resume at p3, got %+v after %v", job, pages.requested)
+	}
+}
+
+func TestMailbox_RunUnlinkedAccountIsPermanent(t *testing.T) {
+	queue := &fakeQueue{}
+	m := NewMailbox(&fakePages{}, fakeTokens{}, queue)
+	job, _ := m.Enqueue(context.Background(), "u1", "gone")
+	if err := m.Run(context.Background(), job); !jobs.IsPermanent(err) || !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("expected a permanent failure, got %v", err)
+	}
+}
@@ -0,0 +1,324 @@
+// Package bulk applies one action to every cached message of a user that matches a filter, e.g.
+// archiving everything from a sender. Jobs are stored in the database with their progress and
+// run by the job queue (see package jobs), each at a throttled rate so large clean-ups stay
+// within the provider's quotas.
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/health"
+	"github.com/desponda/inbox-whisperer/internal/jobs"
+	"github.com/desponda/inbox-whisperer/internal/legalhold"
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrJobActive is returned when the user already has a bulk job queued or running
+	ErrJobActive = errors.New("a bulk job is already queued or running")
+	// ErrInvalidRequest wraps the reasons a job is refused before it is queued
+	ErrInvalidRequest = errors.New("invalid bulk request")
+)
+
+// Defaults for the worker's pace
+const (
+	DefaultBatchSize = 100
+	DefaultInterval  = 100 * time.Millisecond
+)
+
+// MaxCategoryLength bounds the category BulkRecategorize assigns, as for category feedback
+const MaxCategoryLength = 100
+
+// JobTypeMessage is the job type of single messages reported to the health monitor
+const JobTypeMessage = "bulk_message"
+
+// MessageActioner takes actions at the provider (see service.MessageActionService)
+type MessageActioner interface {
+	Archive(ctx context.Context, userID string, token *oauth2.Token, messageID string) error
+	Trash(ctx context.Context, userID string, token *oauth2.Token, messageID string) error
+	MarkRead(ctx context.Context, userID string, token *oauth2.Token, messageID string) error
+}
+
+// CategorySetter stores a message's category (see data.EmailMessageRepository)
+type CategorySetter interface {
+	SetCategory(ctx context.Context, userID, emailMessageID, category string, confidence float64) error
+}
+
+// TokenSource loads the token a job acts with (see data.UserTokenRepository)
+type TokenSource interface {
+	GetUserToken(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error)
+}
+
+// Queue runs the jobs in the background (see jobs.Queue)
+type Queue interface {
+	Enqueue(ctx context.Context, kind, userID string, refID int64, payload any) (*models.Job, error)
+	Save(ctx context.Context, job *models.Job) error
+}
+
+// Request describes a job to enqueue
+type Request struct {
+	UserID    string
+	AccountID string
+	Action    string
+	// ToCategory is required for models.BulkRecategorize and ignored otherwise
+	ToCategory string
+	Sender     string
+	Category   string
+	// OlderThan matches messages that arrived more than this long before the job is enqueued
+	OlderThan time.Duration
+}
+
+// Runner enqueues bulk jobs and runs them for the job queue
+type Runner struct {
+	jobs       data.BulkJobRepository
+	actions    MessageActioner
+	categories CategorySetter
+	tokens     TokenSource
+	queue      Queue
+
+	// BatchSize is how many messages are loaded (and progress persisted) at a time
+	BatchSize int
+	// Interval is the minimum delay between acting on two messages, bounding provider request rates
+	Interval time.Duration
+	// Health, if set, receives heartbeats and message outcomes
+	Health *health.Worker
+	// Maintenance, if set, pauses running jobs while it is on
+	Maintenance *maintenance.Switch
+
+	now func() time.Time
+}
+
+func NewRunner(jobs data.BulkJobRepository, actions MessageActioner, categories CategorySetter, tokens TokenSource, queue Queue) *Runner {
+	return &Runner{
+		jobs:       jobs,
+		actions:    actions,
+		categories: categories,
+		tokens:     tokens,
+		queue:      queue,
+		BatchSize:  DefaultBatchSize,
+		Interval:   DefaultInterval,
+		now:        time.Now,
+	}
+}
+
+// Enqueue validates req and queues a job for it, counting the messages it matches. A request
+// without any filter is refused so a single call cannot act on the whole mailbox. If the user
+// already has a pending job it is returned together with ErrJobActive.
+func (r *Runner) Enqueue(ctx context.Context, req Request) (*models.BulkJob, error) {
+	job, err := r.newJob(req)
+	if err != nil {
+		return nil, err
+	}
+	active, err := r.jobs.ActiveJob(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		return active, ErrJobActive
+	}
+	if job.Total, err = r.jobs.CountMatches(ctx, job.UserID, job.Filter); err != nil {
+		return nil, err
+	}
+	if err := r.jobs.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+	task, err := r.queue.Enqueue(ctx, models.JobKindBulk, job.UserID, job.ID, nil)
+	if err != nil {
+		// Nothing will run the job; fail it so it does not block the next one
+		job.Status, job.Error = models.JobFailed, "failed to queue the job"
+		r.save(ctx, job, nil)
+		return nil, err
+	}
+	job.QueueJobID = task.ID
+	return job, nil
+}
+
+// Validate reports why req would be refused, wrapping ErrInvalidRequest
+func (req Request) Validate() error {
+	switch req.Action {
+	case models.BulkArchive, models.BulkDelete, models.BulkMarkRead:
+	case models.BulkRecategorize:
+		to := strings.TrimSpace(req.ToCategory)
+		if to == "" {
+			return fmt.Errorf("%w: to_category is required for %s", ErrInvalidRequest, req.Action)
+		}
+		if len(to) > MaxCategoryLength {
+			return fmt.Errorf("%w: to_category must be at most %d characters", ErrInvalidRequest, MaxCategoryLength)
+		}
+	default:
+		return fmt.Errorf("%w: action must be one of %s", ErrInvalidRequest, strings.Join(models.BulkActions, ", "))
+	}
+	if req.OlderThan < 0 {
+		return fmt.Errorf("%w: older_than must be positive", ErrInvalidRequest)
+	}
+	if strings.TrimSpace(req.Sender) == "" && strings.TrimSpace(req.Category) == "" && req.OlderThan == 0 {
+		return fmt.Errorf("%w: at least one of sender, category or older_than is required", ErrInvalidRequest)
+	}
+	return nil
+}
+
+func (r *Runner) newJob(req Request) (*models.BulkJob, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	job := &models.BulkJob{
+		UserID:    req.UserID,
+		AccountID: req.AccountID,
+		Action:    req.Action,
+		Filter: models.BulkFilter{
+			Sender:   strings.ToLower(strings.TrimSpace(req.Sender)),
+			Category: strings.TrimSpace(req.Category),
+		},
+	}
+	if req.Action == models.BulkRecategorize {
+		job.ToCategory = strings.TrimSpace(req.ToCategory)
+	}
+	if req.OlderThan > 0 {
+		job.Filter.Before = r.now().Add(-req.OlderThan).UnixMilli()
+	}
+	return job, nil
+}
+
+// Job returns a job by ID; data.ErrNotFound if it does not exist
+func (r *Runner) Job(ctx context.Context, id int64) (*models.BulkJob, error) {
+	return r.jobs.GetJob(ctx, id)
+}
+
+// Run is the job queue's handler for models.JobKindBulk: it runs the job task points at.
+// Interrupted and retried jobs restart from the beginning, which only revisits the messages
+// still matching.
+func (r *Runner) Run(ctx context.Context, task *models.Job) error {
+	job, err := r.jobs.GetJob(ctx, task.RefID)
+	if errors.Is(err, data.ErrNotFound) {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	started := r.now().UTC()
+	job.Status, job.StartedAt, job.FinishedAt, job.Processed, job.Failed, job.Error = models.JobRunning, &started, nil, 0, 0, ""
+	r.save(ctx, job, task)
+
+	err = r.process(ctx, job, task)
+	if ctx.Err() != nil {
+		// Shutting down: leave the job running; the queue runs it again
+		return ctx.Err()
+	}
+	switch {
+	case err == nil:
+		finished := r.now().UTC()
+		job.Status, job.Total, job.FinishedAt = models.JobCompleted, job.Processed, &finished
+		log.Info().Int64("job_id", job.ID).Str("action", job.Action).Int("processed", job.Processed).
+			Int("failed", job.Failed).Msg("bulk: job completed")
+	case jobs.WillRetry(task, err):
+		job.Status, job.Error = models.JobQueued, err.Error()
+	default:
+		finished := r.now().UTC()
+		job.Status, job.Error, job.FinishedAt = models.JobFailed, err.Error(), &finished
+	}
+	r.save(context.Background(), job, task)
+	return err
+}
+
+func (r *Runner) process(ctx context.Context, job *models.BulkJob, task *models.Job) error {
+	var token *oauth2.Token
+	if job.Action != models.BulkRecategorize {
+		var err error
+		token, err = r.tokens.GetUserToken(ctx, job.UserID, data.ProviderGmail, job.AccountID)
+		if errors.Is(err, data.ErrNotFound) {
+			// The account was unlinked
+			return jobs.Permanent(fmt.Errorf("failed to load token: %w", err))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load token: %w", err)
+		}
+	}
+	var tick <-chan time.Time
+	if r.Interval > 0 && job.Action != models.BulkRecategorize {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	var afterDate int64
+	var afterID string
+	for {
+		batch, err := r.jobs.Matches(ctx, job.UserID, job.Filter, afterDate, afterID, r.BatchSize)
+		if err != nil {
+			return err
+		}
+		for _, msg := range batch {
+			if err := maintenance.Wait(ctx, r.Maintenance, r.Health.Beat); err != nil {
+				return err
+			}
+			if tick != nil {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-tick:
+				}
+			}
+			err := r.apply(ctx, job, token, msg.EmailMessageID)
+			if errors.Is(err, provider.ErrMissingScope) || errors.Is(err, legalhold.ErrHeld) {
+				// Every other message would be refused the same way
+				return jobs.Permanent(err)
+			}
+			if err != nil {
+				job.Failed++
+				log.Warn().Err(err).Int64("job_id", job.ID).Str("message_id", msg.EmailMessageID).Msg("bulk: action failed")
+			}
+			r.Health.Record(JobTypeMessage, err)
+			job.Processed++
+		}
+		r.save(ctx, job, task)
+		if len(batch) < r.BatchSize {
+			return nil
+		}
+		last := batch[len(batch)-1]
+		afterDate, afterID = last.InternalDate, last.EmailMessageID
+	}
+}
+
+// apply takes the job's action on one message. Messages already gone count as done.
+func (r *Runner) apply(ctx context.Context, job *models.BulkJob, token *oauth2.Token, messageID string) error {
+	var err error
+	switch job.Action {
+	case models.BulkArchive:
+		err = r.actions.Archive(ctx, job.UserID, token, messageID)
+	case models.BulkDelete:
+		err = r.actions.Trash(ctx, job.UserID, token, messageID)
+	case models.BulkMarkRead:
+		err = r.actions.MarkRead(ctx, job.UserID, token, messageID)
+	case models.BulkRecategorize:
+		// Stored with full confidence, like a category the user set by hand
+		err = r.categories.SetCategory(ctx, job.UserID, messageID, job.ToCategory, 1)
+	default:
+		return fmt.Errorf("unknown bulk action %q", job.Action)
+	}
+	if errors.Is(err, provider.ErrNotFound) || errors.Is(err, data.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// save writes the job's progress, and copies it to the queue job running it, if any
+func (r *Runner) save(ctx context.Context, job *models.BulkJob, task *models.Job) {
+	if err := r.jobs.UpdateJob(ctx, job); err != nil {
+		log.Error().Err(err).Int64("job_id", job.ID).Msg("bulk: failed to save job progress")
+	}
+	if task == nil {
+		return
+	}
+	task.Total, task.Processed, task.Failed = job.Total, job.Processed, job.Failed
+	if err := r.queue.Save(ctx, task); err != nil {
+		log.Error().Err(err).Int64("job_id", task.ID).Msg("bulk: failed to save queue job progress")
+	}
+}
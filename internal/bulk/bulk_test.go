@@ -0,0 +1,214 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/jobs"
+	"github.com/desponda/inbox-whisperer/internal/legalhold"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"golang.org/x/oauth2"
+)
+
+// fakeJobs stores jobs in memory and matches the messages in slice order
+type fakeJobs struct {
+	jobs     []*models.BulkJob
+	messages []*models.EmailMessage
+	saves    int
+}
+
+func (f *fakeJobs) CreateJob(ctx context.Context, j *models.BulkJob) error {
+	j.ID, j.Status = int64(len(f.jobs)+1), models.JobQueued
+	f.jobs = append(f.jobs, j)
+	return nil
+}
+
+func (f *fakeJobs) GetJob(ctx context.Context, id int64) (*models.BulkJob, error) {
+	for _, j := range f.jobs {
+		if j.ID == id {
+			return j, nil
+		}
+	}
+	return nil, data.ErrNotFound
+}
+
+func (f *fakeJobs) UpdateJob(ctx context.Context, j *models.BulkJob) error {
+	f.saves++
+	return nil
+}
+
+func (f *fakeJobs) ActiveJob(ctx context.Context, userID string) (*models.BulkJob, error) {
+	for _, j := range f.jobs {
+		if j.UserID == userID && !j.Finished() {
+			return j, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeJobs) CountMatches(ctx context.Context, userID string, filter models.BulkFilter) (int, error) {
+	return len(f.messages), nil
+}
+
+func (f *fakeJobs) Matches(ctx context.Context, userID string, filter models.BulkFilter, afterInternalDate int64, afterID string, limit int) ([]*models.EmailMessage, error) {
+	start := 0
+	for i, m := range f.messages {
+		if m.EmailMessageID == afterID {
+			start = i + 1
+		}
+	}
+	end := start + limit
+	if end > len(f.messages) {
+		end = len(f.messages)
+	}
+	return f.messages[start:end], nil
+}
+
+// fakeQueue hands out queue jobs and counts the progress saved to them
+type fakeQueue struct {
+	tasks []*models.Job
+	saves int
+}
+
+func (f *fakeQueue) Enqueue(ctx context.Context, kind, userID string, refID int64, payload any) (*models.Job, error) {
+	task := &models.Job{ID: int64(len(f.tasks) + 100), Kind: kind, UserID: userID, RefID: refID, Status: models.JobQueued, MaxAttempts: 3}
+	f.tasks = append(f.tasks, task)
+	return task, nil
+}
+
+func (f *fakeQueue) Save(ctx context.Context, job *models.Job) error {
+	f.saves++
+	return nil
+}
+
+// run runs the queue's next job as its first attempt
+func (f *fakeQueue) run(r *Runner) error {
+	task := f.tasks[len(f.tasks)-1]
+	task.Attempts = 1
+	return r.Run(context.Background(), task)
+}
+
+type fakeActions struct {
+	done []string
+	fail map[string]error
+}
+
+func (f *fakeActions) act(action, id string) error {
+	if err := f.fail[id]; err != nil {
+		return err
+	}
+	f.done = append(f.done, action+":"+id)
+	return nil
+}
+
+func (f *fakeActions) Archive(ctx context.Context, userID string, token *oauth2.Token, id string) error {
+	return f.act("archive", id)
+}
+
+func (f *fakeActions) Trash(ctx context.Context, userID string, token *oauth2.Token, id string) error {
+	return f.act("trash", id)
+}
+
+func (f *fakeActions) MarkRead(ctx context.Context, userID string, token *oauth2.Token, id string) error {
+	return f.act("read", id)
+}
+
+func (f *fakeActions) SetCategory(ctx context.Context, userID, id, category string, confidence float64) error {
+	return f.act(category, id)
+}
+
+type fakeTokens struct{}
+
+func (fakeTokens) GetUserToken(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: "t"}, nil
+}
+
+func newTestRunner(ids ...string) (*Runner, *fakeJobs, *fakeActions, *fakeQueue) {
+	store := &fakeJobs{}
+	for i, id := range ids {
+		store.messages = append(store.messages, &models.EmailMessage{EmailMessageID: id, InternalDate: int64(100 - i)})
+	}
+	actions := &fakeActions{fail: map[string]error{}}
+	queue := &fakeQueue{}
+	r := NewRunner(store, actions, actions, fakeTokens{}, queue)
+	r.BatchSize, r.Interval = 2, 0
+	r.now = func() time.Time { return time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC) }
+	return r, store, actions, queue
+}
+
+func TestEnqueue_Validates(t *testing.T) {
+	r, _, _, queue := newTestRunner("m1", "m2")
+	ctx := context.Background()
+	for _, req := range []Request{
+		{UserID: "u1", Action: "explode", Sender: "a@x.example"},
+		{UserID: "u1", Action: models.BulkArchive},
+		{UserID: "u1", Action: models.BulkRecategorize, Sender: "a@x.example"},
+		{UserID: "u1", Action: models.BulkArchive, OlderThan: -time.Hour},
+	} {
+		if _, err := r.Enqueue(ctx, req); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("%+v: expected an invalid request, got %v", req, err)
+		}
+	}
+
+	job, err := r.Enqueue(ctx, Request{UserID: "u1", Action: models.BulkArchive, Sender: " News@X.example ", OlderThan: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	want := models.BulkFilter{Sender: "news@x.example", Before: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC).UnixMilli()}
+	if job.Filter != want || job.Total != 2 || job.Status != models.JobQueued {
+		t.Errorf("unexpected job %+v", job)
+	}
+	if len(queue.tasks) != 1 || queue.tasks[0].Kind != models.JobKindBulk || queue.tasks[0].RefID != job.ID || job.QueueJobID != queue.tasks[0].ID {
+		t.Errorf("expected the job on the queue, got %+v for %+v", queue.tasks, job)
+	}
+	if active, err := r.Enqueue(ctx, Request{UserID: "u1", Action: models.BulkMarkRead, Category: "promotions"}); !errors.Is(err, ErrJobActive) || active.ID != job.ID {
+		t.Errorf("expected the pending job to be reported, got %+v (%v)", active, err)
+	}
+}
+
+func TestRun_AppliesActionAndCountsFailures(t *testing.T) {
+	r, store, actions, queue := newTestRunner("m1", "m2", "m3", "m4", "m5")
+	actions.fail["m2"] = errors.New("boom")
+	actions.fail["m3"] = provider.ErrNotFound
+	ctx := context.Background()
+	if _, err := r.Enqueue(ctx, Request{UserID: "u1", Action: models.BulkRecategorize, ToCategory: "Receipts", Sender: "shop@x.example"}); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if err := queue.run(r); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	job := store.jobs[0]
+	if job.Status != models.JobCompleted || job.Processed != 5 || job.Failed != 1 || job.Total != 5 {
+		t.Errorf("unexpected job %+v", job)
+	}
+	if task := queue.tasks[0]; task.Processed != 5 || task.Failed != 1 {
+		t.Errorf("expected the progress copied to the queue job, got %+v", task)
+	}
+	if len(actions.done) != 3 || actions.done[0] != "Receipts:m1" {
+		t.Errorf("unexpected actions %v", actions.done)
+	}
+	// Once per batch of 2, plus at the start and end
+	if store.saves != 5 {
+		t.Errorf("expected 5 saves, got %d", store.saves)
+	}
+}
+
+func TestRun_FailsWhenEveryMessageWouldBeRefused(t *testing.T) {
+	r, store, actions, queue := newTestRunner("m1", "m2")
+	actions.fail["m1"] = legalhold.ErrHeld
+	ctx := context.Background()
+	if _, err := r.Enqueue(ctx, Request{UserID: "u1", Action: models.BulkDelete, Category: "promotions"}); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	err := queue.run(r)
+
+	// Retrying would meet the same hold
+	if job := store.jobs[0]; !jobs.IsPermanent(err) || job.Status != models.JobFailed || job.Error == "" || len(actions.done) != 0 {
+		t.Errorf("expected the job to stop at the hold for good, got %+v and %v (err %v)", job, actions.done, err)
+	}
+}
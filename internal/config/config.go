@@ -4,16 +4,199 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/httpclient"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/tracing"
 )
 
 type GoogleConfig struct {
 	ClientID     string `json:"client_id"`
 	ClientSecret string `json:"client_secret"`
 	RedirectURL  string `json:"redirect_url"`
+	// CredentialKey (base64, 32 bytes) seals client credentials rotated through the admin
+	// API; rotation is off while it is empty
+	CredentialKey string `json:"credential_key"`
+}
+
+// OutlookConfig is the Microsoft identity platform app through which users link Outlook
+// mailboxes; linking is off while ClientID is empty
+type OutlookConfig struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RedirectURL  string `json:"redirect_url"`
+	// Tenant restricts sign-in to one directory; empty means "common" (work, school and
+	// personal accounts)
+	Tenant string `json:"tenant"`
+}
+
+// GmailPushConfig enables Gmail push notifications through Google Pub/Sub, so new mail is synced
+// within seconds instead of on the next scheduled sync; pushes are off while Topic is empty
+type GmailPushConfig struct {
+	// Topic is the Pub/Sub topic Gmail publishes mailbox changes to, as
+	// projects/<project>/topics/<topic>. gmail-api-push@system.gserviceaccount.com needs
+	// permission to publish to it.
+	Topic string `json:"topic"`
+	// Token authenticates pushes: the topic's push subscription must deliver to
+	// /api/webhooks/gmail?token=<Token>
+	Token string `json:"token"`
+}
+
+// IMAPConfig enables linking mailboxes on other hosts over IMAP; linking is off while
+// CredentialKey is empty
+type IMAPConfig struct {
+	// CredentialKey is a base64-encoded 32-byte key the IMAP passwords are encrypted with at
+	// rest. Stored passwords cannot be read after it changes; users have to link again.
+	CredentialKey string `json:"credential_key"`
 }
 
 type OpenAIConfig struct {
 	APIKey string `json:"api_key"`
+	Model  string `json:"model"` // defaults to ai.DefaultOpenAIModel
+	// BaseURL points the client at another OpenAI-compatible API, e.g. a self-hosted model
+	// server; defaults to OpenAI's
+	BaseURL string `json:"base_url"`
+	// ShadowModel, if set, categorizes alongside Model in shadow mode so the two can be
+	// compared before switching; its categories are stored for reports only
+	ShadowModel string `json:"shadow_model"`
+}
+
+// AIConfig holds deploy-level AI settings
+type AIConfig struct {
+	// LocalOnly forbids sending message content to external LLM providers for every user
+	LocalOnly bool `json:"local_only"`
+	// Token budgets for LLM calls; 0 means unlimited
+	UserDailyTokenBudget     int `json:"user_daily_token_budget"`
+	UserMonthlyTokenBudget   int `json:"user_monthly_token_budget"`
+	GlobalMonthlyTokenBudget int `json:"global_monthly_token_budget"`
+}
+
+// SyncConfig controls how often the scheduler syncs each user's mailbox
+type SyncConfig struct {
+	// DefaultTier applies to users without an assigned tier; defaults to "free"
+	DefaultTier string `json:"default_tier"`
+	// Tiers maps tier names to sync intervals as Go durations (e.g. "15m"); "0" means manual-only.
+	// Entries override or extend the built-in free, paid and manual tiers.
+	Tiers map[string]string `json:"tiers"`
+}
+
+// ErrorReportingConfig configures where unexpected errors, such as recovered panics, are sent
+type ErrorReportingConfig struct {
+	// SentryDSN enables reporting to Sentry or a compatible server; empty disables reporting
+	SentryDSN string `json:"sentry_dsn"`
+	// Environment tags each event, e.g. "production" or "staging"
+	Environment string `json:"environment"`
+}
+
+// StorageConfig controls what is kept of cached messages
+type StorageConfig struct {
+	// DropRawJSON stops caching raw provider payloads and prunes the ones already stored;
+	// message headers are still kept on their own
+	DropRawJSON bool `json:"drop_raw_json"`
+	// LegalHold puts every user under legal hold: no cached mail is deleted or pruned, whoever
+	// asks. Individual users can be held instead through the admin API.
+	LegalHold bool `json:"legal_hold"`
+}
+
+// TracingConfig exports OpenTelemetry traces over OTLP/HTTP; tracing is off while Endpoint is empty
+type TracingConfig struct {
+	// Endpoint is the collector's OTLP/HTTP URL, e.g. http://otel-collector:4318
+	Endpoint string `json:"endpoint"`
+	// Headers are sent with every export as comma-separated key=value pairs, e.g. a vendor's API key
+	Headers string `json:"headers"`
+	// ServiceName names this deployment in the traces; empty means inbox-whisperer
+	ServiceName string `json:"service_name"`
+	// SampleRatio is the share of new traces recorded, from 0 to 1; 0 records them all
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+// Options returns the tracing options for the config
+func (c TracingConfig) Options() (tracing.Options, error) {
+	opts := tracing.Options{Endpoint: c.Endpoint, ServiceName: c.ServiceName, SampleRatio: c.SampleRatio}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return opts, fmt.Errorf("tracing.sample_ratio must be between 0 and 1, got %v", c.SampleRatio)
+	}
+	for _, pair := range strings.Split(c.Headers, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return opts, fmt.Errorf("tracing.headers: %q is not key=value", pair)
+		}
+		if opts.Headers == nil {
+			opts.Headers = map[string]string{}
+		}
+		opts.Headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return opts, nil
+}
+
+// RateLimitConfig throttles API requests with token buckets; each limit is off while its
+// per-minute rate is 0
+type RateLimitConfig struct {
+	// UserPerMinute is the sustained rate allowed per signed-in user and UserBurst how many
+	// requests may arrive at once; the burst defaults to a minute's worth
+	UserPerMinute int `json:"user_per_minute"`
+	UserBurst     int `json:"user_burst"`
+	// IPPerMinute and IPBurst limit anonymous requests per client IP the same way
+	IPPerMinute int `json:"ip_per_minute"`
+	IPBurst     int `json:"ip_burst"`
+	// RedisURL shares the limits between instances: redis://[[user]:password@]host[:port][/db],
+	// or rediss:// for TLS. Empty keeps them in memory, per instance.
+	RedisURL string `json:"redis_url"`
+	// TrustForwardedFor takes the client IP from the last X-Forwarded-For entry, for servers
+	// behind a reverse proxy; otherwise every client would share the proxy's limit
+	TrustForwardedFor bool `json:"trust_forwarded_for"`
+}
+
+// StatusConfig is the public status page, GET /api/status
+type StatusConfig struct {
+	// Disabled turns the endpoint and its health checks off
+	Disabled bool `json:"disabled"`
+	// RetentionDays is how long check results are kept for the uptime history; 0 means 90
+	RetentionDays int `json:"retention_days"`
+}
+
+// SMTPConfig is the deployment's mail server, which weekly reports can be sent through instead
+// of the user's own account; it is off while Host is empty
+type SMTPConfig struct {
+	Host string `json:"host"`
+	// Port defaults to 587; the connection is upgraded with STARTTLS when the server offers it
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// From is the sender address of every message
+	From string `json:"from"`
+}
+
+// OutboundConfig configures the HTTP client used for calls to Gmail, the LLM provider and
+// error reporting
+type OutboundConfig struct {
+	// Timeout is a Go duration bounding each request; empty means httpclient.DefaultTimeout
+	Timeout string `json:"timeout"`
+	// ProxyURL is an egress proxy for all outbound calls; empty falls back to HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY
+	ProxyURL string `json:"proxy_url"`
+	// CABundle is the path of a PEM file of extra trusted CAs, e.g. for a TLS-inspecting proxy
+	CABundle string `json:"ca_bundle"`
+	// MaxConnsPerHost caps open connections to one host; 0 means the default
+	MaxConnsPerHost int `json:"max_conns_per_host"`
+}
+
+// ClientOptions returns the httpclient options for the config
+func (c OutboundConfig) ClientOptions() (httpclient.Options, error) {
+	opts := httpclient.Options{ProxyURL: c.ProxyURL, CABundle: c.CABundle, MaxConnsPerHost: c.MaxConnsPerHost}
+	if c.Timeout != "" {
+		d, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return opts, fmt.Errorf("outbound.timeout: %w", err)
+		}
+		opts.Timeout = d
+	}
+	return opts, nil
 }
 
 type ServerConfig struct {
@@ -21,12 +204,74 @@ type ServerConfig struct {
 	DBUrl       string `json:"db_url"`
 	LogLevel    string `json:"log_level"` // e.g. "info", "debug", "warn", "error"
 	FrontendURL string `json:"frontend_url"`
+	// AdminUserIDs may call the /api/admin endpoints
+	AdminUserIDs []string `json:"admin_user_ids"`
+	// MaintenanceMode starts the server with mutating endpoints and background syncs paused
+	MaintenanceMode bool `json:"maintenance_mode"`
+	// DrainPeriod is a Go duration for which /readyz fails after SIGTERM before the server stops
+	// accepting requests, so load balancers move traffic away first; empty means no drain
+	DrainPeriod string `json:"drain_period"`
+	// ShutdownTimeout is a Go duration bounding how long in-flight requests get to finish once
+	// draining ends; empty means DefaultShutdownTimeout
+	ShutdownTimeout string `json:"shutdown_timeout"`
+	// DisableLegacyEmailRoutes stops serving the deprecated /api/email tree, which mirrors
+	// /api/emails; turn it on once the legacy request metric shows no more traffic
+	DisableLegacyEmailRoutes bool `json:"disable_legacy_email_routes"`
+	// OffboardingSigningKey signs the reports of POST /api/admin/offboard, which is only served
+	// when it is set; at least 32 characters
+	OffboardingSigningKey string `json:"offboarding_signing_key"`
+	// GRPCPort serves the gRPC API (api/proto) on its own port; empty leaves it off
+	GRPCPort string `json:"grpc_port"`
+	// GRPCReflection lets tools such as grpcurl discover the gRPC API; meant for development
+	GRPCReflection bool `json:"grpc_reflection"`
+}
+
+// DefaultShutdownTimeout is how long in-flight requests get to finish when
+// server.shutdown_timeout is not set
+const DefaultShutdownTimeout = 10 * time.Second
+
+// Drain returns the configured drain period
+func (c ServerConfig) Drain() (time.Duration, error) {
+	if c.DrainPeriod == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.DrainPeriod)
+	if err != nil {
+		return 0, fmt.Errorf("server.drain_period: %w", err)
+	}
+	return d, nil
+}
+
+// ShutdownGrace returns the configured shutdown timeout
+func (c ServerConfig) ShutdownGrace() (time.Duration, error) {
+	if c.ShutdownTimeout == "" {
+		return DefaultShutdownTimeout, nil
+	}
+	d, err := time.ParseDuration(c.ShutdownTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("server.shutdown_timeout: %w", err)
+	}
+	return d, nil
 }
 
 type AppConfig struct {
-	Google GoogleConfig `json:"google"`
-	OpenAI OpenAIConfig `json:"openai"`
-	Server ServerConfig `json:"server"`
+	Google         GoogleConfig         `json:"google"`
+	Outlook        OutlookConfig        `json:"outlook"`
+	GmailPush      GmailPushConfig      `json:"gmail_push"`
+	IMAP           IMAPConfig           `json:"imap"`
+	OpenAI         OpenAIConfig         `json:"openai"`
+	AI             AIConfig             `json:"ai"`
+	Sync           SyncConfig           `json:"sync"`
+	Secrets        SecretsConfig        `json:"secrets"`
+	ErrorReporting ErrorReportingConfig `json:"error_reporting"`
+	Storage        StorageConfig        `json:"storage"`
+	Residency      ResidencyConfig      `json:"residency"`
+	Outbound       OutboundConfig       `json:"outbound"`
+	SMTP           SMTPConfig           `json:"smtp"`
+	RateLimit      RateLimitConfig      `json:"rate_limit"`
+	Tracing        TracingConfig        `json:"tracing"`
+	Status         StatusConfig         `json:"status"`
+	Server         ServerConfig         `json:"server"`
 }
 
 func LoadConfig(path string) (*AppConfig, error) {
@@ -56,18 +301,137 @@ func LoadConfig(path string) (*AppConfig, error) {
 	fmt.Fprintf(os.Stderr, "[WARN] Config file not found at %s, attempting to load from environment variables\n", path)
 	cfg := AppConfig{
 		Google: GoogleConfig{
-			ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-			ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-			RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URL"),
+			ClientID:      os.Getenv("GOOGLE_CLIENT_ID"),
+			ClientSecret:  os.Getenv("GOOGLE_CLIENT_SECRET"),
+			RedirectURL:   os.Getenv("GOOGLE_REDIRECT_URL"),
+			CredentialKey: os.Getenv("GOOGLE_CREDENTIAL_KEY"),
+		},
+		Outlook: OutlookConfig{
+			ClientID:     os.Getenv("OUTLOOK_CLIENT_ID"),
+			ClientSecret: os.Getenv("OUTLOOK_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OUTLOOK_REDIRECT_URL"),
+			Tenant:       os.Getenv("OUTLOOK_TENANT"),
+		},
+		GmailPush: GmailPushConfig{
+			Topic: os.Getenv("GMAIL_PUSH_TOPIC"),
+			Token: os.Getenv("GMAIL_PUSH_TOKEN"),
+		},
+		IMAP: IMAPConfig{
+			CredentialKey: os.Getenv("IMAP_CREDENTIAL_KEY"),
 		},
 		OpenAI: OpenAIConfig{
-			APIKey: os.Getenv("OPENAI_API_KEY"),
+			APIKey:      os.Getenv("OPENAI_API_KEY"),
+			Model:       os.Getenv("OPENAI_MODEL"),
+			BaseURL:     os.Getenv("OPENAI_BASE_URL"),
+			ShadowModel: os.Getenv("OPENAI_SHADOW_MODEL"),
+		},
+		AI: AIConfig{
+			LocalOnly:                envBool("AI_LOCAL_ONLY"),
+			UserDailyTokenBudget:     envInt("AI_USER_DAILY_TOKEN_BUDGET"),
+			UserMonthlyTokenBudget:   envInt("AI_USER_MONTHLY_TOKEN_BUDGET"),
+			GlobalMonthlyTokenBudget: envInt("AI_GLOBAL_MONTHLY_TOKEN_BUDGET"),
+		},
+		Sync: SyncConfig{
+			DefaultTier: os.Getenv("SYNC_DEFAULT_TIER"),
+		},
+		Secrets: SecretsConfig{
+			RefreshInterval: os.Getenv("SECRETS_REFRESH_INTERVAL"),
+		},
+		ErrorReporting: ErrorReportingConfig{
+			SentryDSN:   os.Getenv("SENTRY_DSN"),
+			Environment: os.Getenv("SENTRY_ENVIRONMENT"),
+		},
+		Storage: StorageConfig{
+			DropRawJSON: envBool("STORAGE_DROP_RAW_JSON"),
+			LegalHold:   envBool("STORAGE_LEGAL_HOLD"),
+		},
+		Residency: ResidencyConfig{
+			Region:         os.Getenv("RESIDENCY_REGION"),
+			Hosts:          envList("RESIDENCY_HOSTS"),
+			Bucket:         os.Getenv("RESIDENCY_BUCKET"),
+			BucketEndpoint: os.Getenv("RESIDENCY_BUCKET_ENDPOINT"),
+			SecondaryDBURL: os.Getenv("RESIDENCY_SECONDARY_DB_URL"),
+		},
+		Outbound: OutboundConfig{
+			Timeout:         os.Getenv("OUTBOUND_TIMEOUT"),
+			ProxyURL:        os.Getenv("OUTBOUND_PROXY_URL"),
+			CABundle:        os.Getenv("OUTBOUND_CA_BUNDLE"),
+			MaxConnsPerHost: envInt("OUTBOUND_MAX_CONNS_PER_HOST"),
+		},
+		SMTP: SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     envInt("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		},
+		RateLimit: RateLimitConfig{
+			UserPerMinute:     envInt("RATE_LIMIT_USER_PER_MINUTE"),
+			UserBurst:         envInt("RATE_LIMIT_USER_BURST"),
+			IPPerMinute:       envInt("RATE_LIMIT_IP_PER_MINUTE"),
+			IPBurst:           envInt("RATE_LIMIT_IP_BURST"),
+			RedisURL:          os.Getenv("RATE_LIMIT_REDIS_URL"),
+			TrustForwardedFor: envBool("RATE_LIMIT_TRUST_FORWARDED_FOR"),
+		},
+		Tracing: TracingConfig{
+			Endpoint:    os.Getenv("TRACING_ENDPOINT"),
+			Headers:     os.Getenv("TRACING_HEADERS"),
+			ServiceName: os.Getenv("TRACING_SERVICE_NAME"),
+			SampleRatio: envFloat("TRACING_SAMPLE_RATIO"),
+		},
+		Status: StatusConfig{
+			Disabled:      envBool("STATUS_DISABLED"),
+			RetentionDays: envInt("STATUS_RETENTION_DAYS"),
 		},
 		Server: ServerConfig{
-			Port:     os.Getenv("SERVER_PORT"),
-			DBUrl:    os.Getenv("DATABASE_URL"),
-			LogLevel: os.Getenv("LOG_LEVEL"),
+			Port:            os.Getenv("SERVER_PORT"),
+			DBUrl:           os.Getenv("DATABASE_URL"),
+			LogLevel:        os.Getenv("LOG_LEVEL"),
+			AdminUserIDs:    envList("ADMIN_USER_IDS"),
+			MaintenanceMode: envBool("MAINTENANCE_MODE"),
+			DrainPeriod:     os.Getenv("SERVER_DRAIN_PERIOD"),
+			ShutdownTimeout: os.Getenv("SERVER_SHUTDOWN_TIMEOUT"),
+
+			DisableLegacyEmailRoutes: envBool("DISABLE_LEGACY_EMAIL_ROUTES"),
+			OffboardingSigningKey:    os.Getenv("OFFBOARDING_SIGNING_KEY"),
+			GRPCPort:                 os.Getenv("GRPC_PORT"),
+			GRPCReflection:           envBool("GRPC_REFLECTION"),
 		},
 	}
 	return &cfg, nil
 }
+
+// envBool parses a boolean environment variable, treating unset or invalid values as false
+func envBool(key string) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	return err == nil && v
+}
+
+// envInt parses an integer environment variable, treating unset or invalid values as 0
+func envInt(key string) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// envFloat parses a decimal environment variable, treating unset or invalid values as 0
+func envFloat(key string) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// envList parses a comma-separated environment variable, dropping empty entries
+func envList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
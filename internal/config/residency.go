@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ResidencyConfig pins stored data to one region, for deployments that must keep it there
+// (e.g. EU-only); checks are off while Region is empty
+type ResidencyConfig struct {
+	// Region names the region data must stay in, e.g. "eu"; it is recorded in exports
+	Region string `json:"region"`
+	// Hosts are the host names located in the region; an entry starting with "." matches
+	// every host in that domain (".eu-west-1.rds.amazonaws.com")
+	Hosts []string `json:"hosts"`
+	// Bucket and BucketEndpoint are the region's object storage, which exports are written to
+	Bucket         string `json:"bucket"`
+	BucketEndpoint string `json:"bucket_endpoint"`
+	// SecondaryDBURL is the region's read replica, if it has one
+	SecondaryDBURL string `json:"secondary_db_url"`
+}
+
+// Check verifies that every storage endpoint, the primary database at dbURL included, is one
+// of the region's hosts. Run it after secrets are resolved.
+func (c ResidencyConfig) Check(dbURL string) error {
+	if c.Region == "" {
+		return nil
+	}
+	if len(c.Hosts) == 0 {
+		return fmt.Errorf("residency: region %q lists no hosts", c.Region)
+	}
+	// Hosts of the endpoints in use, keyed by the setting they come from
+	hosts := map[string][]string{}
+	var err error
+	if hosts["server.db_url"], err = dbHosts(dbURL); err != nil {
+		return fmt.Errorf("residency: server.db_url: %w", err)
+	}
+	if c.SecondaryDBURL != "" {
+		if hosts["residency.secondary_db_url"], err = dbHosts(c.SecondaryDBURL); err != nil {
+			return fmt.Errorf("residency: residency.secondary_db_url: %w", err)
+		}
+	}
+	if c.BucketEndpoint != "" {
+		u, err := url.Parse(c.BucketEndpoint)
+		if err != nil || u.Hostname() == "" {
+			return fmt.Errorf("residency: residency.bucket_endpoint %q is not a URL", c.BucketEndpoint)
+		}
+		hosts["residency.bucket_endpoint"] = []string{u.Hostname()}
+	}
+	for _, name := range []string{"server.db_url", "residency.secondary_db_url", "residency.bucket_endpoint"} {
+		for _, h := range hosts[name] {
+			if !c.inRegion(h) {
+				return fmt.Errorf("residency: %s host %q is outside region %q", name, h, c.Region)
+			}
+		}
+	}
+	return nil
+}
+
+// inRegion reports whether host is one of the region's hosts. Unix sockets are on this
+// machine and always count as in the region.
+func (c ResidencyConfig) inRegion(host string) bool {
+	host = strings.ToLower(host)
+	if strings.HasPrefix(host, "/") {
+		return true
+	}
+	for _, h := range c.Hosts {
+		h = strings.ToLower(h)
+		if host == h || (strings.HasPrefix(h, ".") && strings.HasSuffix(host, h)) {
+			return true
+		}
+	}
+	return false
+}
+
+// dbHosts returns the hosts of a Postgres URL or keyword/value DSN, fallbacks included
+func dbHosts(dsn string) ([]string, error) {
+	pc, err := pgconn.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	hosts := []string{pc.Host}
+	for _, fb := range pc.Fallbacks {
+		hosts = append(hosts, fb.Host)
+	}
+	return hosts, nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/secrets"
+)
+
+// DefaultSecretsRefreshInterval is how often secret references are re-resolved when
+// secrets.refresh_interval is not set
+const DefaultSecretsRefreshInterval = 5 * time.Minute
+
+// SecretsConfig controls how secret references in the config are refreshed
+type SecretsConfig struct {
+	// RefreshInterval is a Go duration; "0" disables refreshing
+	RefreshInterval string `json:"refresh_interval"`
+}
+
+// Interval returns the configured refresh interval; 0 means never refresh
+func (c SecretsConfig) Interval() (time.Duration, error) {
+	if c.RefreshInterval == "" {
+		return DefaultSecretsRefreshInterval, nil
+	}
+	d, err := time.ParseDuration(c.RefreshInterval)
+	if err != nil {
+		return 0, fmt.Errorf("secrets.refresh_interval: %w", err)
+	}
+	return d, nil
+}
+
+// NewSecretsResolver returns a resolver for the env and file schemes plus "gcpkms" (Cloud
+// KMS with application default credentials) and, when VAULT_ADDR is set, "vault" using
+// VAULT_TOKEN
+func NewSecretsResolver() *secrets.Resolver {
+	r := secrets.NewResolver()
+	r.Register("gcpkms", secrets.NewKMSProvider())
+	if addr := os.Getenv("VAULT_ADDR"); addr != "" {
+		r.Register("vault", secrets.NewVaultProvider(addr, os.Getenv("VAULT_TOKEN")))
+	}
+	return r
+}
+
+// secretFields returns the config fields that may hold secret references
+func secretFields(cfg *AppConfig) map[string]*string {
+	return map[string]*string{
+		"google.client_id":               &cfg.Google.ClientID,
+		"google.client_secret":           &cfg.Google.ClientSecret,
+		"google.credential_key":          &cfg.Google.CredentialKey,
+		"outlook.client_secret":          &cfg.Outlook.ClientSecret,
+		"gmail_push.token":               &cfg.GmailPush.Token,
+		"imap.credential_key":            &cfg.IMAP.CredentialKey,
+		"openai.api_key":                 &cfg.OpenAI.APIKey,
+		"smtp.password":                  &cfg.SMTP.Password,
+		"rate_limit.redis_url":           &cfg.RateLimit.RedisURL,
+		"tracing.headers":                &cfg.Tracing.Headers,
+		"server.db_url":                  &cfg.Server.DBUrl,
+		"server.offboarding_signing_key": &cfg.Server.OffboardingSigningKey,
+		"residency.secondary_db_url":     &cfg.Residency.SecondaryDBURL,
+	}
+}
+
+// ResolveSecrets replaces secret references in the credential fields of cfg with their values
+func ResolveSecrets(ctx context.Context, cfg *AppConfig, r *secrets.Resolver) error {
+	for name, field := range secretFields(cfg) {
+		v, err := r.Resolve(ctx, *field)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		*field = v
+	}
+	return nil
+}
+
+// copySecrets copies the credential fields from src into dst
+func copySecrets(dst, src *AppConfig) {
+	srcFields := secretFields(src)
+	for name, field := range secretFields(dst) {
+		*field = *srcFields[name]
+	}
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/secrets"
+	"github.com/rs/zerolog/log"
+)
+
+// Store holds the current configuration as an immutable snapshot. Reload swaps in a new
+// snapshot atomically and hands it to subscribers, so operators can tune settings without
+// a restart. Only the fields copied by applyReloadable change on reload; the rest keep the
+// values the process started with, except secrets, which RefreshSecrets re-resolves.
+type Store struct {
+	path        string
+	current     atomic.Pointer[AppConfig]
+	mu          sync.Mutex
+	subscribers []func(*AppConfig) error
+	// raw is the config as loaded, before secret references were resolved
+	raw      *AppConfig
+	resolver *secrets.Resolver
+}
+
+// NewStore creates a Store serving cfg, which Reload re-reads from path
+func NewStore(path string, cfg *AppConfig) *Store {
+	s := &Store{path: path, raw: cfg}
+	s.current.Store(cfg)
+	return s
+}
+
+// Current returns the current snapshot; callers must not modify it
+func (s *Store) Current() *AppConfig {
+	return s.current.Load()
+}
+
+// OnReload registers fn to apply each reloaded snapshot. fn should validate before changing
+// anything, so that a rejected value leaves its component on the previous settings.
+func (s *Store) OnReload(fn func(*AppConfig) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// UseSecrets resolves the secret references in the config with r and keeps r for
+// RefreshSecrets. It fails if any reference cannot be resolved.
+func (s *Store) UseSecrets(ctx context.Context, r *secrets.Resolver) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resolved := *s.raw
+	if err := ResolveSecrets(ctx, &resolved, r); err != nil {
+		return err
+	}
+	next := *s.Current()
+	copySecrets(&next, &resolved)
+	s.resolver = r
+	s.current.Store(&next)
+	return nil
+}
+
+// RefreshSecrets resolves the secret references again and, if any value changed, swaps in a
+// new snapshot and notifies subscribers. It does nothing if UseSecrets was not called.
+func (s *Store) RefreshSecrets(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resolver == nil {
+		return nil
+	}
+	resolved := *s.raw
+	if err := ResolveSecrets(ctx, &resolved, s.resolver); err != nil {
+		return err
+	}
+	cur := s.Current()
+	next := *cur
+	copySecrets(&next, &resolved)
+	if next.Google == cur.Google && next.Outlook == cur.Outlook && next.GmailPush == cur.GmailPush && next.IMAP == cur.IMAP && next.OpenAI == cur.OpenAI && next.SMTP == cur.SMTP && next.Server.DBUrl == cur.Server.DBUrl &&
+		next.Residency.SecondaryDBURL == cur.Residency.SecondaryDBURL {
+		return nil
+	}
+	log.Info().Msg("config: secrets rotated")
+	s.current.Store(&next)
+	return s.notify(&next)
+}
+
+// Reload re-reads the config and applies the reloadable settings: the log level, AI token
+// budgets, sync tiers and maintenance mode. Changes to other settings are logged and ignored
+// until restart. Subscriber errors are joined and returned; the other subscribers still apply.
+func (s *Store) Reload() error {
+	loaded, err := LoadConfig(s.path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ignored := restartOnlyChanges(s.raw, loaded); len(ignored) > 0 {
+		log.Warn().Strs("settings", ignored).Msg("config: changed settings require a restart and were not applied")
+	}
+	raw := *s.raw
+	applyReloadable(&raw, loaded)
+	s.raw = &raw
+	next := *s.Current()
+	applyReloadable(&next, loaded)
+	s.current.Store(&next)
+	return s.notify(&next)
+}
+
+func (s *Store) notify(cfg *AppConfig) error {
+	var errs []error
+	for _, fn := range s.subscribers {
+		if err := fn(cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WatchSignals reloads the config on SIGHUP until ctx is cancelled
+func (s *Store) WatchSignals(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if err := s.Reload(); err != nil {
+					log.Error().Err(err).Msg("config: reload failed")
+					continue
+				}
+				log.Info().Msg("config: reloaded")
+			}
+		}
+	}()
+}
+
+// WatchSecrets calls RefreshSecrets every interval until ctx is cancelled. A failed refresh
+// keeps the current secrets.
+func (s *Store) WatchSecrets(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.RefreshSecrets(ctx); err != nil {
+					log.Error().Err(err).Msg("config: secret refresh failed")
+				}
+			}
+		}
+	}()
+}
+
+// applyReloadable copies the runtime-tunable settings from loaded into cfg
+func applyReloadable(cfg, loaded *AppConfig) {
+	cfg.Server.LogLevel = loaded.Server.LogLevel
+	cfg.Server.MaintenanceMode = loaded.Server.MaintenanceMode
+	cfg.Server.DisableLegacyEmailRoutes = loaded.Server.DisableLegacyEmailRoutes
+	cfg.AI.UserDailyTokenBudget = loaded.AI.UserDailyTokenBudget
+	cfg.AI.UserMonthlyTokenBudget = loaded.AI.UserMonthlyTokenBudget
+	cfg.AI.GlobalMonthlyTokenBudget = loaded.AI.GlobalMonthlyTokenBudget
+	cfg.Sync = loaded.Sync
+}
+
+// restartOnlyChanges returns the names of restart-only settings that differ between two
+// unresolved configs
+func restartOnlyChanges(cur, loaded *AppConfig) []string {
+	var ignored []string
+	restartOnly := []struct {
+		name      string
+		cur, next any
+	}{
+		{"google", cur.Google, loaded.Google},
+		{"outlook", cur.Outlook, loaded.Outlook},
+		{"gmail_push", cur.GmailPush, loaded.GmailPush},
+		{"imap", cur.IMAP, loaded.IMAP},
+		{"openai", cur.OpenAI, loaded.OpenAI},
+		{"ai.local_only", cur.AI.LocalOnly, loaded.AI.LocalOnly},
+		{"secrets", cur.Secrets, loaded.Secrets},
+		{"error_reporting", cur.ErrorReporting, loaded.ErrorReporting},
+		{"storage", cur.Storage, loaded.Storage},
+		{"residency", cur.Residency, loaded.Residency},
+		{"outbound", cur.Outbound, loaded.Outbound},
+		{"smtp", cur.SMTP, loaded.SMTP},
+		{"rate_limit", cur.RateLimit, loaded.RateLimit},
+		{"tracing", cur.Tracing, loaded.Tracing},
+		{"status", cur.Status, loaded.Status},
+		{"server.port", cur.Server.Port, loaded.Server.Port},
+		{"server.db_url", cur.Server.DBUrl, loaded.Server.DBUrl},
+		{"server.admin_user_ids", cur.Server.AdminUserIDs, loaded.Server.AdminUserIDs},
+		{"server.offboarding_signing_key", cur.Server.OffboardingSigningKey, loaded.Server.OffboardingSigningKey},
+		{"server.grpc_port", cur.Server.GRPCPort, loaded.Server.GRPCPort},
+		{"server.grpc_reflection", cur.Server.GRPCReflection, loaded.Server.GRPCReflection},
+	}
+	for _, f := range restartOnly {
+		if !reflect.DeepEqual(f.cur, f.next) {
+			ignored = append(ignored, f.name)
+		}
+	}
+	return ignored
+}
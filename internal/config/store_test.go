@@ -0,0 +1,149 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/secrets"
+)
+
+func writeConfig(t *testing.T, path, text string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+}
+
+func TestStore_ReloadAppliesOnlyReloadableSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, `{"server":{"port":"8080","log_level":"info"},"ai":{"user_daily_token_budget":100}}`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	store := NewStore(path, cfg)
+	var applied *AppConfig
+	store.OnReload(func(c *AppConfig) error { applied = c; return nil })
+
+	writeConfig(t, path, `{"server":{"port":"9090","log_level":"debug","disable_legacy_email_routes":true},"ai":{"user_daily_token_budget":500},"sync":{"default_tier":"paid"}}`)
+	if err := store.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	cur := store.Current()
+	if applied != cur {
+		t.Error("expected subscribers to receive the new snapshot")
+	}
+	if cur.Server.LogLevel != "debug" || !cur.Server.DisableLegacyEmailRoutes || cur.AI.UserDailyTokenBudget != 500 || cur.Sync.DefaultTier != "paid" {
+		t.Errorf("expected reloadable settings to change, got %+v", cur)
+	}
+	if cur.Server.Port != "8080" {
+		t.Errorf("expected the port to require a restart, got %s", cur.Server.Port)
+	}
+	if cfg.Server.LogLevel != "info" {
+		t.Error("expected the previous snapshot to be left untouched")
+	}
+}
+
+func TestStore_ReloadErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, `{"server":{"log_level":"info"}}`)
+	cfg, _ := LoadConfig(path)
+	store := NewStore(path, cfg)
+	calls := 0
+	store.OnReload(func(c *AppConfig) error { calls++; return errors.New("rejected") })
+	store.OnReload(func(c *AppConfig) error { calls++; return nil })
+
+	if err := store.Reload(); err == nil || calls != 2 {
+		t.Errorf("expected the subscriber error after running every subscriber, got %v (%d calls)", err, calls)
+	}
+
+	writeConfig(t, path, `{not json}`)
+	if err := store.Reload(); err == nil {
+		t.Error("expected malformed config to fail the reload")
+	}
+	if store.Current().Server.LogLevel != "info" {
+		t.Error("expected a failed reload to keep the current snapshot")
+	}
+}
+
+func TestStore_SecretsResolvedAndRefreshed(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "openai_key")
+	writeConfig(t, keyPath, "key-1\n")
+	path := filepath.Join(dir, "config.json")
+	writeConfig(t, path, `{"openai":{"api_key":"file:`+keyPath+`"},"server":{"db_url":"env:TEST_DB_URL","log_level":"info"}}`)
+	t.Setenv("TEST_DB_URL", "postgres://u:p1@db/app")
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	store := NewStore(path, cfg)
+	if err := store.UseSecrets(context.Background(), secrets.NewResolver()); err != nil {
+		t.Fatalf("UseSecrets failed: %v", err)
+	}
+	if cur := store.Current(); cur.OpenAI.APIKey != "key-1" || cur.Server.DBUrl != "postgres://u:p1@db/app" {
+		t.Errorf("expected resolved secrets, got %+v", cur)
+	}
+
+	notified := 0
+	store.OnReload(func(c *AppConfig) error { notified++; return nil })
+	if err := store.RefreshSecrets(context.Background()); err != nil || notified != 0 {
+		t.Errorf("expected no notification without rotation, got %d (err %v)", notified, err)
+	}
+	writeConfig(t, keyPath, "key-2")
+	if err := store.RefreshSecrets(context.Background()); err != nil || notified != 1 {
+		t.Errorf("expected one notification after rotation, got %d (err %v)", notified, err)
+	}
+	if store.Current().OpenAI.APIKey != "key-2" {
+		t.Errorf("expected the rotated key, got %q", store.Current().OpenAI.APIKey)
+	}
+
+	// A reload keeps resolved secrets and does not report unchanged references
+	writeConfig(t, path, `{"openai":{"api_key":"file:`+keyPath+`"},"server":{"db_url":"env:TEST_DB_URL","log_level":"debug"}}`)
+	if err := store.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if cur := store.Current(); cur.OpenAI.APIKey != "key-2" || cur.Server.LogLevel != "debug" {
+		t.Errorf("unexpected snapshot after reload %+v", cur)
+	}
+
+	os.Remove(keyPath)
+	if err := store.RefreshSecrets(context.Background()); err == nil || store.Current().OpenAI.APIKey != "key-2" {
+		t.Errorf("expected a failed refresh to keep the current key, got %v", err)
+	}
+}
+
+func TestSecretsConfig_Interval(t *testing.T) {
+	if d, err := (SecretsConfig{}).Interval(); err != nil || d != DefaultSecretsRefreshInterval {
+		t.Errorf("expected the default interval, got %v (%v)", d, err)
+	}
+	if d, err := (SecretsConfig{RefreshInterval: "0"}).Interval(); err != nil || d != 0 {
+		t.Errorf("expected refreshing to be disabled, got %v (%v)", d, err)
+	}
+	if _, err := (SecretsConfig{RefreshInterval: "soon"}).Interval(); err == nil {
+		t.Error("expected an invalid interval to be rejected")
+	}
+}
+
+func TestServerConfig_DrainAndShutdown(t *testing.T) {
+	if d, err := (ServerConfig{}).Drain(); err != nil || d != 0 {
+		t.Errorf("expected no drain by default, got %v (%v)", d, err)
+	}
+	if d, err := (ServerConfig{}).ShutdownGrace(); err != nil || d != DefaultShutdownTimeout {
+		t.Errorf("expected the default shutdown timeout, got %v (%v)", d, err)
+	}
+	c := ServerConfig{DrainPeriod: "15s", ShutdownTimeout: "1m"}
+	if d, err := c.Drain(); err != nil || d != 15*time.Second {
+		t.Errorf("expected a 15s drain, got %v (%v)", d, err)
+	}
+	if d, err := c.ShutdownGrace(); err != nil || d != time.Minute {
+		t.Errorf("expected a 1m shutdown timeout, got %v (%v)", d, err)
+	}
+	if _, err := (ServerConfig{DrainPeriod: "a while"}).Drain(); err == nil {
+		t.Error("expected an invalid drain period to be rejected")
+	}
+}
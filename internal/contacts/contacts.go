@@ -0,0 +1,207 @@
+// Package contacts derives per-correspondent conversation metrics from cached threads:
+// who takes part in them and how long replies take in each direction. The aggregates
+// back the contacts API and are meant as inputs for prioritization.
+package contacts
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/notify"
+	"github.com/rs/zerolog/log"
+)
+
+// UserLookup resolves a user's own address, so their messages can be told apart from contacts'
+type UserLookup interface {
+	GetByID(ctx context.Context, id string) (*models.User, error)
+}
+
+// Service computes and serves per-contact aggregates
+type Service struct {
+	repo  data.ContactRepository
+	users UserLookup
+}
+
+func NewService(repo data.ContactRepository, users UserLookup) *Service {
+	return &Service{repo: repo, users: users}
+}
+
+// Subscribe recomputes the user's aggregates after each sync. It returns a function that removes the subscription.
+func (s *Service) Subscribe() (unsubscribe func()) {
+	return notify.Subscribe(notify.EventSyncComplete, func(ctx context.Context, userID string) {
+		if err := s.Refresh(ctx, userID); err != nil {
+			log.Error().Str("userID", userID).Err(err).Msg("contacts: failed to refresh contact stats")
+		}
+	})
+}
+
+// Refresh recomputes the user's aggregates from their cached threads
+func (s *Service) Refresh(ctx context.Context, userID string) error {
+	msgs, err := s.repo.ThreadMessages(ctx, userID, "")
+	if err != nil {
+		return err
+	}
+	self, err := s.selfAddress(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.repo.ReplaceContactStats(ctx, userID, Compute(self, msgs))
+}
+
+// List returns the user's contacts, most recently heard from first
+func (s *Service) List(ctx context.Context, userID string) ([]*models.ContactStats, error) {
+	return s.repo.ListContacts(ctx, userID)
+}
+
+// Get returns the aggregates for one address; data.ErrNotFound if there are none
+func (s *Service) Get(ctx context.Context, userID, address string) (*models.ContactStats, error) {
+	addr, _ := models.ParseAddress(address)
+	return s.repo.GetContact(ctx, userID, addr)
+}
+
+// Participants lists everyone in a cached thread; data.ErrNotFound if no messages of the thread are cached
+func (s *Service) Participants(ctx context.Context, userID, threadID string) ([]models.ThreadParticipant, error) {
+	msgs, err := s.repo.ThreadMessages(ctx, userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, data.ErrNotFound
+	}
+	self, err := s.selfAddress(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	byAddr := map[string]*models.ThreadParticipant{}
+	participant := func(addr, name string) *models.ThreadParticipant {
+		p, ok := byAddr[addr]
+		if !ok {
+			p = &models.ThreadParticipant{Address: addr, IsUser: addr == self}
+			byAddr[addr] = p
+		}
+		if p.Name == "" {
+			p.Name = name
+		}
+		return p
+	}
+	for _, msg := range msgs {
+		if addr, name := sender(msg); addr != "" {
+			participant(addr, name).Messages++
+		}
+		for _, r := range models.ParseAddressList(msg.Recipient) {
+			participant(r.Address, r.Name)
+		}
+	}
+	out := make([]models.ThreadParticipant, 0, len(byAddr))
+	for _, p := range byAddr {
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Messages != out[j].Messages {
+			return out[i].Messages > out[j].Messages
+		}
+		return out[i].Address < out[j].Address
+	})
+	return out, nil
+}
+
+// selfAddress returns the user's own normalized address, or "" if unknown. Without it every
+// message counts as received and no reply latencies can be measured.
+func (s *Service) selfAddress(ctx context.Context, userID string) (string, error) {
+	if s.users == nil {
+		return "", nil
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil || user == nil {
+		return "", err
+	}
+	addr, _ := models.ParseAddress(user.Email)
+	return addr, nil
+}
+
+// Compute aggregates thread messages (ordered by thread, then date) per contact. A reply is the
+// next message in a thread when it comes from the other side: the user answering a contact, or a
+// contact answering the user. Messages between two contacts are not counted as replies.
+func Compute(self string, msgs []*models.EmailMessage) []*models.ContactStats {
+	type acc struct {
+		stats               *models.ContactStats
+		threads             map[string]bool
+		userReplyTotal      int64
+		contactReplyTotal   int64
+		lastMessageInternal int64
+	}
+	contacts := map[string]*acc{}
+	contact := func(addr, name string) *acc {
+		a, ok := contacts[addr]
+		if !ok {
+			a = &acc{stats: &models.ContactStats{Address: addr}, threads: map[string]bool{}}
+			contacts[addr] = a
+		}
+		if a.stats.Name == "" {
+			a.stats.Name = name
+		}
+		return a
+	}
+
+	var prev *models.EmailMessage
+	var prevFrom string
+	for _, msg := range msgs {
+		from, name := sender(msg)
+		if from != "" && from != self {
+			a := contact(from, name)
+			a.stats.MessagesReceived++
+			a.threads[msg.ThreadID] = true
+			if msg.InternalDate > a.lastMessageInternal {
+				a.lastMessageInternal = msg.InternalDate
+			}
+		}
+		for _, r := range models.ParseAddressList(msg.Recipient) {
+			if r.Address != self {
+				contact(r.Address, r.Name).threads[msg.ThreadID] = true
+			}
+		}
+		if prev != nil && prev.ThreadID == msg.ThreadID && self != "" && from != "" && prevFrom != "" {
+			latency := (msg.InternalDate - prev.InternalDate) / 1000
+			switch {
+			case from == self && prevFrom != self:
+				a := contact(prevFrom, "")
+				a.stats.UserReplies++
+				a.userReplyTotal += latency
+			case from != self && prevFrom == self:
+				a := contact(from, name)
+				a.stats.ContactReplies++
+				a.contactReplyTotal += latency
+			}
+		}
+		prev, prevFrom = msg, from
+	}
+
+	out := make([]*models.ContactStats, 0, len(contacts))
+	for _, a := range contacts {
+		a.stats.Threads = len(a.threads)
+		if a.stats.UserReplies > 0 {
+			a.stats.AvgUserReplySeconds = a.userReplyTotal / int64(a.stats.UserReplies)
+		}
+		if a.stats.ContactReplies > 0 {
+			a.stats.AvgContactReplySeconds = a.contactReplyTotal / int64(a.stats.ContactReplies)
+		}
+		if a.lastMessageInternal > 0 {
+			last := time.UnixMilli(a.lastMessageInternal).UTC()
+			a.stats.LastMessageAt = &last
+		}
+		out = append(out, a.stats)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address < out[j].Address })
+	return out
+}
+
+// sender returns the parsed sender of msg, parsing the raw header for messages read without it
+func sender(msg *models.EmailMessage) (string, string) {
+	if msg.SenderAddress != "" {
+		return msg.SenderAddress, msg.SenderName
+	}
+	return models.ParseAddress(msg.Sender)
+}
@@ -0,0 +1,131 @@
+package contacts
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func msg(thread, from, to string, seconds int64) *models.EmailMessage {
+	return &models.EmailMessage{ThreadID: thread, Sender: from, Recipient: to, InternalDate: seconds * 1000}
+}
+
+func TestCompute(t *testing.T) {
+	msgs := []*models.EmailMessage{
+		// t1: Ann writes, the user answers after 10 minutes, Ann answers after 2 minutes
+		msg("t1", "Ann <Ann@example.com>", "me@example.com", 1000),
+		msg("t1", "Me <me@example.com>", "ann@example.com", 1600),
+		msg("t1", "ann@example.com", "me@example.com, bob@example.com", 1720),
+		// t2: Ann writes again and Bob, not the user, answers
+		msg("t2", "ann@example.com", "me@example.com", 5000),
+		msg("t2", "bob@example.com", "me@example.com", 5100),
+		// t3: the user answers Ann after 30 minutes
+		msg("t3", "ann@example.com", "", 9000),
+		msg("t3", "me@example.com", "", 10800),
+	}
+	stats := Compute("me@example.com", msgs)
+	if len(stats) != 2 {
+		t.Fatalf("expected ann and bob, got %+v", stats)
+	}
+	ann, bob := stats[0], stats[1]
+	if ann.Address != "ann@example.com" || ann.Name != "Ann" || ann.MessagesReceived != 4 || ann.Threads != 3 {
+		t.Errorf("unexpected ann stats %+v", ann)
+	}
+	if ann.UserReplies != 2 || ann.AvgUserReplySeconds != 1200 {
+		t.Errorf("expected 2 user replies averaging 1200s, got %d and %d", ann.UserReplies, ann.AvgUserReplySeconds)
+	}
+	if ann.ContactReplies != 1 || ann.AvgContactReplySeconds != 120 {
+		t.Errorf("expected 1 contact reply of 120s, got %d and %d", ann.ContactReplies, ann.AvgContactReplySeconds)
+	}
+	if ann.LastMessageAt == nil || ann.LastMessageAt.Unix() != 9000 {
+		t.Errorf("expected last message at 9000, got %v", ann.LastMessageAt)
+	}
+	// Bob was copied on t1 and wrote in t2, but never answered the user directly
+	if bob.MessagesReceived != 1 || bob.Threads != 2 || bob.ContactReplies != 0 {
+		t.Errorf("unexpected bob stats %+v", bob)
+	}
+}
+
+func TestCompute_UnknownUserAddress(t *testing.T) {
+	stats := Compute("", []*models.EmailMessage{msg("t1", "ann@example.com", "", 1), msg("t1", "me@example.com", "", 2)})
+	for _, c := range stats {
+		if c.UserReplies != 0 || c.ContactReplies != 0 {
+			t.Errorf("expected no replies without the user's address, got %+v", c)
+		}
+	}
+}
+
+type fakeRepo struct {
+	msgs  []*models.EmailMessage
+	saved []*models.ContactStats
+}
+
+func (f *fakeRepo) ThreadMessages(ctx context.Context, userID, threadID string) ([]*models.EmailMessage, error) {
+	var out []*models.EmailMessage
+	for _, m := range f.msgs {
+		if threadID == "" || m.ThreadID == threadID {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeRepo) ReplaceContactStats(ctx context.Context, userID string, stats []*models.ContactStats) error {
+	f.saved = stats
+	return nil
+}
+
+func (f *fakeRepo) ListContacts(ctx context.Context, userID string) ([]*models.ContactStats, error) {
+	return f.saved, nil
+}
+
+func (f *fakeRepo) GetContact(ctx context.Context, userID, address string) (*models.ContactStats, error) {
+	for _, c := range f.saved {
+		if c.Address == address {
+			return c, nil
+		}
+	}
+	return nil, data.ErrNotFound
+}
+
+type fakeUsers struct{}
+
+func (fakeUsers) GetByID(ctx context.Context, id string) (*models.User, error) {
+	return &models.User{ID: id, Email: "Me@Example.com"}, nil
+}
+
+func TestService(t *testing.T) {
+	repo := &fakeRepo{msgs: []*models.EmailMessage{
+		msg("t1", "Ann <ann@example.com>", "me@example.com, Bob <bob@example.com>", 100),
+		msg("t1", "me@example.com", "ann@example.com", 160),
+	}}
+	svc := NewService(repo, fakeUsers{})
+	ctx := context.Background()
+
+	if err := svc.Refresh(ctx, "u1"); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	ann, err := svc.Get(ctx, "u1", "Ann <ANN@example.com>")
+	if err != nil || ann.UserReplies != 1 || ann.AvgUserReplySeconds != 60 {
+		t.Errorf("unexpected ann stats %+v (err %v)", ann, err)
+	}
+
+	participants, err := svc.Participants(ctx, "u1", "t1")
+	if err != nil {
+		t.Fatalf("Participants failed: %v", err)
+	}
+	if len(participants) != 3 || participants[0].Address != "ann@example.com" || participants[0].Name != "Ann" {
+		t.Errorf("unexpected participants %+v", participants)
+	}
+	for _, p := range participants {
+		if p.IsUser != (p.Address == "me@example.com") {
+			t.Errorf("unexpected is_user for %+v", p)
+		}
+	}
+	if _, err := svc.Participants(ctx, "u1", "missing"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an uncached thread, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+// Package ctxkeys is the one place request-scoped values are stored in a context.Context.
+// Keys are unexported so values can only be set and read through the typed helpers below,
+// which keeps producers and consumers in different packages from drifting apart.
+package ctxkeys
+
+import (
+	"context"
+	"crypto/ecdh"
+
+	"golang.org/x/oauth2"
+)
+
+type key int
+
+const (
+	userIDKey key = iota
+	tokenKey
+	accountIDKey
+	sessionIDKey
+	sessionTokenKey
+	cursorKey
+	limitKey
+	pageTokenKey
+	pageInfoKey
+	debugKey
+	encryptionKeyKey
+)
+
+// WithUserID returns a copy of ctx carrying the authenticated user's ID
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// UserID returns the user ID set by WithUserID, or "" when there is none
+func UserID(ctx context.Context) string {
+	id, _ := ctx.Value(userIDKey).(string)
+	return id
+}
+
+// WithToken returns a copy of ctx carrying the user's OAuth token
+func WithToken(ctx context.Context, tok *oauth2.Token) context.Context {
+	return context.WithValue(ctx, tokenKey, tok)
+}
+
+// Token returns the OAuth token set by WithToken, or nil when there is none
+func Token(ctx context.Context) *oauth2.Token {
+	tok, _ := ctx.Value(tokenKey).(*oauth2.Token)
+	return tok
+}
+
+// WithAccountID returns a copy of ctx carrying the provider account the request's token belongs to
+func WithAccountID(ctx context.Context, accountID string) context.Context {
+	return context.WithValue(ctx, accountIDKey, accountID)
+}
+
+// AccountID returns the account ID set by WithAccountID, or "" when there is none
+func AccountID(ctx context.Context) string {
+	id, _ := ctx.Value(accountIDKey).(string)
+	return id
+}
+
+// WithSessionID returns a copy of ctx carrying the session cookie's ID
+func WithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionIDKey, sessionID)
+}
+
+// SessionID returns the session ID set by WithSessionID, or "" when there is none
+func SessionID(ctx context.Context) string {
+	id, _ := ctx.Value(sessionIDKey).(string)
+	return id
+}
+
+// WithSessionToken returns a copy of ctx carrying the access token held in the session store
+func WithSessionToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, sessionTokenKey, token)
+}
+
+// SessionToken returns the token set by WithSessionToken, or "" when there is none
+func SessionToken(ctx context.Context) string {
+	tok, _ := ctx.Value(sessionTokenKey).(string)
+	return tok
+}
+
+// Cursor is the keyset position a cached listing continues after
+type Cursor struct {
+	AfterID           string
+	AfterInternalDate int64
+}
+
+// WithCursor returns a copy of ctx carrying the listing cursor
+func WithCursor(ctx context.Context, c Cursor) context.Context {
+	return context.WithValue(ctx, cursorKey, c)
+}
+
+// CursorFrom returns the cursor set by WithCursor; the zero Cursor starts from the newest message
+func CursorFrom(ctx context.Context) Cursor {
+	c, _ := ctx.Value(cursorKey).(Cursor)
+	return c
+}
+
+// WithLimit returns a copy of ctx carrying the requested page size
+func WithLimit(ctx context.Context, limit int) context.Context {
+	return context.WithValue(ctx, limitKey, limit)
+}
+
+// Limit returns the page size set by WithLimit, or 0 when the callee should use its default
+func Limit(ctx context.Context) int {
+	l, _ := ctx.Value(limitKey).(int)
+	return l
+}
+
+// WithPageToken returns a copy of ctx carrying the provider page token a client passed back
+// when listing without the local cache
+func WithPageToken(ctx context.Context, pageToken string) context.Context {
+	return context.WithValue(ctx, pageTokenKey, pageToken)
+}
+
+// PageToken returns the token set by WithPageToken, or "" for the first page
+func PageToken(ctx context.Context) string {
+	tok, _ := ctx.Value(pageTokenKey).(string)
+	return tok
+}
+
+// PageInfo describes where a passthrough listing can continue
+type PageInfo struct {
+	// NextPageToken is the provider's token for the next page; empty on the last page
+	NextPageToken string
+	// Passthrough is set once the list was served straight from the provider
+	Passthrough bool
+}
+
+// WithPageInfo returns a copy of ctx carrying info for a callee to fill in; the caller keeps
+// the pointer and reads it once the call returns
+func WithPageInfo(ctx context.Context, info *PageInfo) context.Context {
+	return context.WithValue(ctx, pageInfoKey, info)
+}
+
+// SetNextPageToken records token in the PageInfo attached to ctx and marks the list as
+// passthrough; it is a no-op when the caller did not ask for page info
+func SetNextPageToken(ctx context.Context, token string) {
+	if info, ok := ctx.Value(pageInfoKey).(*PageInfo); ok && info != nil {
+		info.NextPageToken, info.Passthrough = token, true
+	}
+}
+
+// WithDebug returns a copy of ctx marked for debug logging on behalf of userID, see package
+// debuglog
+func WithDebug(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, debugKey, userID)
+}
+
+// DebugUser returns the user ID set by WithDebug, or "" when ctx is not marked
+func DebugUser(ctx context.Context) string {
+	id, _ := ctx.Value(debugKey).(string)
+	return id
+}
+
+// WithEncryptionKey returns a copy of ctx carrying the user's unlocked cache key, see package e2ee
+func WithEncryptionKey(ctx context.Context, key *ecdh.PrivateKey) context.Context {
+	return context.WithValue(ctx, encryptionKeyKey, key)
+}
+
+// EncryptionKey returns the key set by WithEncryptionKey, or nil while the cache is locked
+func EncryptionKey(ctx context.Context) *ecdh.PrivateKey {
+	key, _ := ctx.Value(encryptionKeyKey).(*ecdh.PrivateKey)
+	return key
+}
@@ -0,0 +1,65 @@
+package ctxkeys
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tok := &oauth2.Token{AccessToken: "tok"}
+	ctx := context.Background()
+	ctx = WithUserID(ctx, "user1")
+	ctx = WithToken(ctx, tok)
+	ctx = WithAccountID(ctx, "me@example.com")
+	ctx = WithSessionID(ctx, "sess")
+	ctx = WithSessionToken(ctx, "raw")
+	ctx = WithCursor(ctx, Cursor{AfterID: "m1", AfterInternalDate: 42})
+	ctx = WithLimit(ctx, 25)
+	ctx = WithPageToken(ctx, "p2")
+
+	if got := UserID(ctx); got != "user1" {
+		t.Errorf("UserID = %q", got)
+	}
+	if got := Token(ctx); got != tok {
+		t.Errorf("Token = %v", got)
+	}
+	if got := AccountID(ctx); got != "me@example.com" {
+		t.Errorf("AccountID = %q", got)
+	}
+	if got := SessionID(ctx); got != "sess" {
+		t.Errorf("SessionID = %q", got)
+	}
+	if got := SessionToken(ctx); got != "raw" {
+		t.Errorf("SessionToken = %q", got)
+	}
+	if got := CursorFrom(ctx); got != (Cursor{AfterID: "m1", AfterInternalDate: 42}) {
+		t.Errorf("CursorFrom = %+v", got)
+	}
+	if got := Limit(ctx); got != 25 {
+		t.Errorf("Limit = %d", got)
+	}
+	if got := PageToken(ctx); got != "p2" {
+		t.Errorf("PageToken = %q", got)
+	}
+}
+
+func TestZeroValuesWhenUnset(t *testing.T) {
+	ctx := context.Background()
+	if UserID(ctx) != "" || Token(ctx) != nil || AccountID(ctx) != "" || SessionID(ctx) != "" || SessionToken(ctx) != "" ||
+		CursorFrom(ctx) != (Cursor{}) || Limit(ctx) != 0 || PageToken(ctx) != "" {
+		t.Error("expected zero values from an empty context")
+	}
+	// No PageInfo attached: must not panic
+	SetNextPageToken(ctx, "p3")
+}
+
+func TestSetNextPageToken(t *testing.T) {
+	info := &PageInfo{}
+	ctx := WithPageInfo(context.Background(), info)
+	SetNextPageToken(ctx, "p3")
+	if info.NextPageToken != "p3" {
+		t.Errorf("NextPageToken = %q", info.NextPageToken)
+	}
+}
@@ -0,0 +1,53 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AIResultRepository caches AI outputs per message
+type AIResultRepository interface {
+	// GetResult returns the cached result, or nil if there is none
+	GetResult(ctx context.Context, userID, messageID, kind string) (*models.AIResult, error)
+	PutResult(ctx context.Context, result *models.AIResult) error
+}
+
+type aiResultRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewAIResultRepositoryFromPool creates an AIResultRepository using a pgxpool.Pool
+func NewAIResultRepositoryFromPool(pool *pgxpool.Pool) AIResultRepository {
+	return &aiResultRepository{pool: pool}
+}
+
+func (r *aiResultRepository) GetResult(ctx context.Context, userID, messageID, kind string) (*models.AIResult, error) {
+	res := models.AIResult{UserID: userID, MessageID: messageID, Kind: kind}
+	err := r.pool.QueryRow(ctx, `SELECT content_hash, result, COALESCE(confidence, 0), source, created_at FROM ai_results
+		WHERE user_id=$1 AND message_id=$2 AND kind=$3`, userID, messageID, kind).
+		Scan(&res.ContentHash, &res.Result, &res.Confidence, &res.Source, &res.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (r *aiResultRepository) PutResult(ctx context.Context, res *models.AIResult) error {
+	_, err := r.pool.Exec(ctx, `INSERT INTO ai_results (user_id, message_id, kind, content_hash, result, confidence, source, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (user_id, message_id, kind) DO UPDATE SET
+		content_hash = EXCLUDED.content_hash,
+		result = EXCLUDED.result,
+		confidence = EXCLUDED.confidence,
+		source = EXCLUDED.source,
+		created_at = NOW()`,
+		res.UserID, res.MessageID, res.Kind, res.ContentHash, res.Result, res.Confidence, res.Source)
+	return err
+}
@@ -0,0 +1,38 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestAIResultRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewAIResultRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+
+	got, err := repo.GetResult(ctx, "ai-user-1", "m1", models.AIResultSummary)
+	if err != nil || got != nil {
+		t.Fatalf("expected no cached result, got %+v (err %v)", got, err)
+	}
+	res := &models.AIResult{UserID: "ai-user-1", MessageID: "m1", Kind: models.AIResultSummary, ContentHash: "h1", Result: "first", Source: "llm"}
+	if err := repo.PutResult(ctx, res); err != nil {
+		t.Fatalf("PutResult failed: %v", err)
+	}
+	res.ContentHash, res.Result = "h2", "second"
+	if err := repo.PutResult(ctx, res); err != nil {
+		t.Fatalf("PutResult overwrite failed: %v", err)
+	}
+	got, err = repo.GetResult(ctx, "ai-user-1", "m1", models.AIResultSummary)
+	if err != nil || got == nil {
+		t.Fatalf("GetResult failed: %+v (err %v)", got, err)
+	}
+	if got.ContentHash != "h2" || got.Result != "second" {
+		t.Errorf("expected overwritten result, got %+v", got)
+	}
+	if other, _ := repo.GetResult(ctx, "ai-user-1", "m1", models.AIResultCategory); other != nil {
+		t.Errorf("expected kinds to be cached separately, got %+v", other)
+	}
+}
@@ -0,0 +1,46 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AIUsageRepository records LLM token usage
+type AIUsageRepository interface {
+	RecordUsage(ctx context.Context, userID, feature string, promptTokens, completionTokens int) error
+	// UserTokensSince returns the total tokens used by the user since the given time
+	UserTokensSince(ctx context.Context, userID string, since time.Time) (int, error)
+	// TotalTokensSince returns the total tokens used by all users since the given time
+	TotalTokensSince(ctx context.Context, since time.Time) (int, error)
+}
+
+type aiUsageRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewAIUsageRepositoryFromPool creates an AIUsageRepository using a pgxpool.Pool
+func NewAIUsageRepositoryFromPool(pool *pgxpool.Pool) AIUsageRepository {
+	return &aiUsageRepository{pool: pool}
+}
+
+func (r *aiUsageRepository) RecordUsage(ctx context.Context, userID, feature string, promptTokens, completionTokens int) error {
+	_, err := r.pool.Exec(ctx, `INSERT INTO ai_usage (user_id, feature, prompt_tokens, completion_tokens) VALUES ($1, $2, $3, $4)`,
+		userID, feature, promptTokens, completionTokens)
+	return err
+}
+
+func (r *aiUsageRepository) UserTokensSince(ctx context.Context, userID string, since time.Time) (int, error) {
+	var total int
+	err := r.pool.QueryRow(ctx, `SELECT COALESCE(SUM(prompt_tokens + completion_tokens), 0) FROM ai_usage WHERE user_id=$1 AND created_at >= $2`,
+		userID, since).Scan(&total)
+	return total, err
+}
+
+func (r *aiUsageRepository) TotalTokensSince(ctx context.Context, since time.Time) (int, error) {
+	var total int
+	err := r.pool.QueryRow(ctx, `SELECT COALESCE(SUM(prompt_tokens + completion_tokens), 0) FROM ai_usage WHERE created_at >= $1`,
+		since).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,34 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAIUsageRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewAIUsageRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	since := time.Now().Add(-time.Hour)
+
+	if err := repo.RecordUsage(ctx, "usage-user-1", "summarize", 100, 20); err != nil {
+		t.Fatalf("RecordUsage failed: %v", err)
+	}
+	if err := repo.RecordUsage(ctx, "usage-user-2", "categorize", 50, 5); err != nil {
+		t.Fatalf("RecordUsage failed: %v", err)
+	}
+	got, err := repo.UserTokensSince(ctx, "usage-user-1", since)
+	if err != nil || got != 120 {
+		t.Errorf("expected 120 tokens for user 1, got %d (err %v)", got, err)
+	}
+	total, err := repo.TotalTokensSince(ctx, since)
+	if err != nil || total < 175 {
+		t.Errorf("expected at least 175 total tokens, got %d (err %v)", total, err)
+	}
+	got, err = repo.UserTokensSince(ctx, "usage-user-1", time.Now().Add(time.Hour))
+	if err != nil || got != 0 {
+		t.Errorf("expected no usage in the future window, got %d (err %v)", got, err)
+	}
+}
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AnalyticsRepository stores and aggregates user activity (action_logs and inbox size samples)
+type AnalyticsRepository interface {
+	RecordAction(ctx context.Context, userID, action, targetType string) error
+	CountActionsSince(ctx context.Context, userID, action string, since time.Time) (int, error)
+	CountMessagesCachedSince(ctx context.Context, userID string, since time.Time) (int, error)
+	CountMessages(ctx context.Context, userID string) (int, error)
+	RecordInboxSize(ctx context.Context, userID string, day time.Time, size int) error
+	// WeeklyInboxSizes averages daily samples per week (weeks start Monday), oldest first
+	WeeklyInboxSizes(ctx context.Context, userID string, since time.Time) ([]models.InboxSizePoint, error)
+	// SenderStats counts the cached messages received in [from, to), in milliseconds, per
+	// sender address, busiest first; UnreadRatio is left to the caller
+	SenderStats(ctx context.Context, userID string, from, to int64, limit int) ([]models.SenderStat, error)
+	// DailyVolume counts the cached messages received in [from, to) per day in the timezone
+	// (an IANA name), oldest first; days without messages are left out
+	DailyVolume(ctx context.Context, userID string, from, to int64, timezone string) ([]models.VolumePoint, error)
+}
+
+type analyticsRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewAnalyticsRepositoryFromPool creates an AnalyticsRepository using a pgxpool.Pool
+func NewAnalyticsRepositoryFromPool(pool *pgxpool.Pool) AnalyticsRepository {
+	return &analyticsRepository{pool: pool}
+}
+
+func (r *analyticsRepository) RecordAction(ctx context.Context, userID, action, targetType string) error {
+	_, err := r.pool.Exec(ctx, `INSERT INTO action_logs (user_id, action, target_type) VALUES ($1, $2, $3)`, userID, action, targetType)
+	return err
+}
+
+func (r *analyticsRepository) CountActionsSince(ctx context.Context, userID, action string, since time.Time) (int, error) {
+	var n int
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM action_logs WHERE user_id=$1 AND action=$2 AND created_at >= $3`,
+		userID, action, since).Scan(&n)
+	return n, err
+}
+
+func (r *analyticsRepository) CountMessagesCachedSince(ctx context.Context, userID string, since time.Time) (int, error) {
+	var n int
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM email_messages WHERE user_id=$1 AND cached_at >= $2`, userID, since).Scan(&n)
+	return n, err
+}
+
+func (r *analyticsRepository) CountMessages(ctx context.Context, userID string) (int, error) {
+	var n int
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM email_messages WHERE user_id=$1`, userID).Scan(&n)
+	return n, err
+}
+
+func (r *analyticsRepository) RecordInboxSize(ctx context.Context, userID string, day time.Time, size int) error {
+	_, err := r.pool.Exec(ctx, `INSERT INTO inbox_size_snapshots (user_id, day, size) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, day) DO UPDATE SET size = EXCLUDED.size`, userID, day, size)
+	return err
+}
+
+func (r *analyticsRepository) WeeklyInboxSizes(ctx context.Context, userID string, since time.Time) ([]models.InboxSizePoint, error) {
+	rows, err := r.pool.Query(ctx, `SELECT date_trunc('week', day)::timestamp AS week, AVG(size)::float8
+		FROM inbox_size_snapshots WHERE user_id=$1 AND day >= $2
+		GROUP BY week ORDER BY week`, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var points []models.InboxSizePoint
+	for rows.Next() {
+		var p models.InboxSizePoint
+		if err := rows.Scan(&p.WeekStart, &p.AverageSize); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// notDigest leaves out the server-generated digest messages, which no sender sent
+const notDigest = `email_message_id NOT LIKE '` + models.DigestMessagePrefix + `%'`
+
+func (r *analyticsRepository) SenderStats(ctx context.Context, userID string, from, to int64, limit int) ([]models.SenderStat, error) {
+	rows, err := r.pool.Query(ctx, `SELECT COALESCE(NULLIF(sender_address, ''), sender, '') AS address,
+			MAX(COALESCE(sender_name, '')), COUNT(*), COUNT(*) FILTER (WHERE NOT is_read), MAX(internal_date)
+		FROM email_messages
+		WHERE user_id=$1 AND internal_date >= $2 AND internal_date < $3 AND `+notDigest+`
+		GROUP BY address ORDER BY 3 DESC, address LIMIT $4`, userID, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stats := []models.SenderStat{}
+	for rows.Next() {
+		var s models.SenderStat
+		if err := rows.Scan(&s.Address, &s.Name, &s.Messages, &s.Unread, &s.LatestInternalDate); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+func (r *analyticsRepository) DailyVolume(ctx context.Context, userID string, from, to int64, timezone string) ([]models.VolumePoint, error) {
+	rows, err := r.pool.Query(ctx, `SELECT to_char(to_timestamp(internal_date / 1000.0) AT TIME ZONE $4, 'YYYY-MM-DD') AS day,
+			COUNT(*), COUNT(*) FILTER (WHERE NOT is_read)
+		FROM email_messages
+		WHERE user_id=$1 AND internal_date >= $2 AND internal_date < $3 AND `+notDigest+`
+		GROUP BY day ORDER BY day`, userID, from, to, timezone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var points []models.VolumePoint
+	for rows.Next() {
+		var p models.VolumePoint
+		if err := rows.Scan(&p.Day, &p.Messages, &p.Unread); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
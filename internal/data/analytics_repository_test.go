@@ -0,0 +1,95 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestAnalyticsRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	userID := "user-analytics-1"
+	if err := db.Create(ctx, &models.User{ID: userID, Email: "analytics@example.com"}); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+	repo := NewAnalyticsRepositoryFromPool(db.Pool)
+	weekAgo := time.Now().Add(-7 * 24 * time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if err := repo.RecordAction(ctx, userID, "archived", "email_message"); err != nil {
+			t.Fatalf("RecordAction failed: %v", err)
+		}
+	}
+	if n, err := repo.CountActionsSince(ctx, userID, "archived", weekAgo); err != nil || n != 2 {
+		t.Errorf("expected 2 archived actions, got %d, %v", n, err)
+	}
+	if n, err := repo.CountActionsSince(ctx, userID, "unsubscribed", weekAgo); err != nil || n != 0 {
+		t.Errorf("expected 0 unsubscribes, got %d, %v", n, err)
+	}
+
+	monday := time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC)
+	for i, size := range []int{100, 80, 60} {
+		if err := repo.RecordInboxSize(ctx, userID, monday.AddDate(0, 0, i), size); err != nil {
+			t.Fatalf("RecordInboxSize failed: %v", err)
+		}
+	}
+	// Later samples on the same day replace earlier ones
+	if err := repo.RecordInboxSize(ctx, userID, monday.AddDate(0, 0, 7), 50); err != nil {
+		t.Fatalf("RecordInboxSize failed: %v", err)
+	}
+	if err := repo.RecordInboxSize(ctx, userID, monday.AddDate(0, 0, 7), 40); err != nil {
+		t.Fatalf("RecordInboxSize failed: %v", err)
+	}
+	points, err := repo.WeeklyInboxSizes(ctx, userID, monday)
+	if err != nil {
+		t.Fatalf("WeeklyInboxSizes failed: %v", err)
+	}
+	if len(points) != 2 || points[0].AverageSize != 80 || points[1].AverageSize != 40 {
+		t.Errorf("unexpected weekly sizes: %+v", points)
+	}
+}
+
+func TestAnalyticsRepository_SendersAndVolume(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	userID := "user-analytics-2"
+	if err := db.Create(ctx, &models.User{ID: userID, Email: "analytics2@example.com"}); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+	repo := NewAnalyticsRepositoryFromPool(db.Pool)
+	messages := NewEmailMessageRepositoryFromPool(db.Pool)
+
+	day := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	for i, m := range []*models.EmailMessage{
+		{Sender: "Shop <news@shop.example>", InternalDate: day.Add(1 * time.Hour).UnixMilli()},
+		{Sender: "Shop <news@shop.example>", InternalDate: day.Add(23 * time.Hour).UnixMilli(), IsRead: true},
+		{Sender: "Ann <ann@example.com>", InternalDate: day.Add(25 * time.Hour).UnixMilli()},
+		{Sender: "Old <old@example.com>", InternalDate: day.Add(-48 * time.Hour).UnixMilli()},
+	} {
+		m.UserID, m.EmailMessageID, m.CachedAt = userID, fmt.Sprintf("m%d", i), time.Now()
+		if err := messages.UpsertMessage(ctx, m); err != nil {
+			t.Fatalf("UpsertMessage failed: %v", err)
+		}
+	}
+	from, to := day.UnixMilli(), day.Add(48*time.Hour).UnixMilli()
+
+	senders, err := repo.SenderStats(ctx, userID, from, to, 10)
+	if err != nil || len(senders) != 2 {
+		t.Fatalf("expected 2 senders in range, got %+v (%v)", senders, err)
+	}
+	if s := senders[0]; s.Address != "news@shop.example" || s.Name != "Shop" || s.Messages != 2 || s.Unread != 1 {
+		t.Errorf("expected the shop first, got %+v", s)
+	}
+
+	// 23:00 UTC on the 14th is already the 15th in Berlin
+	volume, err := repo.DailyVolume(ctx, userID, from, to, "Europe/Berlin")
+	if err != nil || len(volume) != 2 || volume[0].Day != "2026-10-14" || volume[0].Messages != 1 || volume[1].Messages != 2 {
+		t.Errorf("unexpected volume %+v (%v)", volume, err)
+	}
+}
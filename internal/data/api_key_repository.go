@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// APIKeyRepository stores API keys by the hash of the key
+type APIKeyRepository interface {
+	// Create stores key under keyHash and fills in its ID and CreatedAt
+	Create(ctx context.Context, key *models.APIKey, keyHash string) error
+	// ListByUser returns the user's active keys, newest first
+	ListByUser(ctx context.Context, userID string) ([]*models.APIKey, error)
+	// Revoke returns ErrNotFound if the user has no active key with the ID
+	Revoke(ctx context.Context, userID string, id int64) error
+	// Authenticate returns the active key with keyHash and records that it was used.
+	// Returns ErrNotFound for unknown and revoked keys.
+	Authenticate(ctx context.Context, keyHash string) (*models.APIKey, error)
+}
+
+type apiKeyRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewAPIKeyRepositoryFromPool creates an APIKeyRepository using a pgxpool.Pool
+func NewAPIKeyRepositoryFromPool(pool *pgxpool.Pool) APIKeyRepository {
+	return &apiKeyRepository{pool: pool}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *models.APIKey, keyHash string) error {
+	return r.pool.QueryRow(ctx, `INSERT INTO api_keys (user_id, name, prefix, key_hash) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`, key.UserID, key.Name, key.Prefix, keyHash).Scan(&key.ID, &key.CreatedAt)
+}
+
+func (r *apiKeyRepository) ListByUser(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id, user_id, name, prefix, created_at, last_used_at FROM api_keys
+		WHERE user_id=$1 AND revoked_at IS NULL ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*models.APIKey
+	for rows.Next() {
+		var k models.APIKey
+		if err := rows.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.CreatedAt, &k.LastUsedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &k)
+	}
+	return out, rows.Err()
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, userID string, id int64) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE api_keys SET revoked_at=$3 WHERE user_id=$1 AND id=$2 AND revoked_at IS NULL`,
+		userID, id, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) Authenticate(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var k models.APIKey
+	err := r.pool.QueryRow(ctx, `UPDATE api_keys SET last_used_at=$2 WHERE key_hash=$1 AND revoked_at IS NULL
+		RETURNING id, user_id, name, prefix, created_at, last_used_at`, keyHash, time.Now().UTC()).
+		Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.CreatedAt, &k.LastUsedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestAPIKeyRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewAPIKeyRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	user := &models.User{ID: "user-api-keys-1", Email: "keys@example.com", CreatedAt: time.Now()}
+	if err := db.Create(ctx, user); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+
+	key := &models.APIKey{UserID: user.ID, Name: "laptop", Prefix: "iw_abcdefgh"}
+	if err := repo.Create(ctx, key, "hash-1"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if key.ID == 0 || key.CreatedAt.IsZero() {
+		t.Fatalf("expected ID and CreatedAt to be set, got %+v", key)
+	}
+	got, err := repo.Authenticate(ctx, "hash-1")
+	if err != nil || got.ID != key.ID || got.UserID != user.ID || got.LastUsedAt == nil {
+		t.Fatalf("expected the key with LastUsedAt set, got %+v, %v", got, err)
+	}
+	list, err := repo.ListByUser(ctx, user.ID)
+	if err != nil || len(list) != 1 || list[0].Name != "laptop" {
+		t.Fatalf("expected one key, got %+v, %v", list, err)
+	}
+
+	if err := repo.Revoke(ctx, "someone-else", key.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected another user's revoke to fail, got %v", err)
+	}
+	if err := repo.Revoke(ctx, user.ID, key.ID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if err := repo.Revoke(ctx, user.ID, key.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a second revoke to fail, got %v", err)
+	}
+	if _, err := repo.Authenticate(ctx, "hash-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a revoked key to be rejected, got %v", err)
+	}
+	if list, _ := repo.ListByUser(ctx, user.ID); len(list) != 0 {
+		t.Errorf("expected revoked keys to be hidden, got %+v", list)
+	}
+}
+
+func TestDeviceCodeRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewDeviceCodeRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	user := &models.User{ID: "user-device-1", Email: "device@example.com", CreatedAt: time.Now()}
+	if err := db.Create(ctx, user); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+
+	if err := repo.Create(ctx, "dev-1", "BCDF-GHJK", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	code, err := repo.Poll(ctx, "dev-1")
+	if err != nil || code.Status != models.DeviceCodePending || code.LastPolledAt != nil {
+		t.Fatalf("expected a pending code polled for the first time, got %+v, %v", code, err)
+	}
+	if code, _ := repo.Poll(ctx, "dev-1"); code == nil || code.LastPolledAt == nil {
+		t.Errorf("expected the previous poll to be returned, got %+v", code)
+	}
+	if _, err := repo.Consume(ctx, "dev-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a pending code not to be consumable, got %v", err)
+	}
+	if err := repo.Decide(ctx, "BCDF-GHJK", user.ID, true); err != nil {
+		t.Fatalf("Decide failed: %v", err)
+	}
+	if err := repo.Decide(ctx, "BCDF-GHJK", "", false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a decided code to be final, got %v", err)
+	}
+	userID, err := repo.Consume(ctx, "dev-1")
+	if err != nil || userID != user.ID {
+		t.Fatalf("expected %s, got %q, %v", user.ID, userID, err)
+	}
+	if _, err := repo.Poll(ctx, "dev-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a consumed code to be gone, got %v", err)
+	}
+
+	if err := repo.Create(ctx, "dev-2", "CDFG-HJKL", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := repo.Decide(ctx, "CDFG-HJKL", "", false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an expired code not to be decidable, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BulkJobRepository stores bulk action jobs and selects the messages they act on
+type BulkJobRepository interface {
+	// CreateJob inserts a queued job; ID and CreatedAt are filled in
+	CreateJob(ctx context.Context, job *models.BulkJob) error
+	// GetJob returns ErrNotFound if there is no job with the given ID
+	GetJob(ctx context.Context, id int64) (*models.BulkJob, error)
+	// UpdateJob writes the job's status, progress, error and timestamps
+	UpdateJob(ctx context.Context, job *models.BulkJob) error
+	// ActiveJob returns the user's queued or running job, or nil
+	ActiveJob(ctx context.Context, userID string) (*models.BulkJob, error)
+
+	// CountMatches counts the user's cached messages matching filter
+	CountMatches(ctx context.Context, userID string, filter models.BulkFilter) (int, error)
+	// Matches returns up to limit of the user's cached messages matching filter, newest first,
+	// continuing after the given internal date and ID. Only EmailMessageID and InternalDate are
+	// filled in.
+	Matches(ctx context.Context, userID string, filter models.BulkFilter, afterInternalDate int64, afterID string, limit int) ([]*models.EmailMessage, error)
+}
+
+type bulkJobRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewBulkJobRepositoryFromPool creates a BulkJobRepository using a pgxpool.Pool
+func NewBulkJobRepositoryFromPool(pool *pgxpool.Pool) BulkJobRepository {
+	return &bulkJobRepository{pool: pool}
+}
+
+// bulkJobColumns ends with the ID of the queue job running the bulk job
+const bulkJobColumns = `id, user_id, account_id, action, to_category, sender, category, before_internal_date, status, total, processed, failed, error, created_at, started_at, finished_at,
+	(SELECT COALESCE(MAX(q.id), 0) FROM jobs q WHERE q.kind='` + models.JobKindBulk + `' AND q.ref_id=bulk_jobs.id)`
+
+func scanBulkJob(row pgx.Row) (*models.BulkJob, error) {
+	var j models.BulkJob
+	if err := row.Scan(&j.ID, &j.UserID, &j.AccountID, &j.Action, &j.ToCategory, &j.Filter.Sender, &j.Filter.Category,
+		&j.Filter.Before, &j.Status, &j.Total, &j.Processed, &j.Failed, &j.Error, &j.CreatedAt, &j.StartedAt, &j.FinishedAt, &j.QueueJobID); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (r *bulkJobRepository) CreateJob(ctx context.Context, j *models.BulkJob) error {
+	if j.Status == "" {
+		j.Status = models.JobQueued
+	}
+	return r.pool.QueryRow(ctx, `INSERT INTO bulk_jobs (user_id, account_id, action, to_category, sender, category, before_internal_date, status, total)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at`,
+		j.UserID, j.AccountID, j.Action, j.ToCategory, j.Filter.Sender, j.Filter.Category, j.Filter.Before, j.Status, j.Total,
+	).Scan(&j.ID, &j.CreatedAt)
+}
+
+func (r *bulkJobRepository) GetJob(ctx context.Context, id int64) (*models.BulkJob, error) {
+	j, err := scanBulkJob(r.pool.QueryRow(ctx, `SELECT `+bulkJobColumns+` FROM bulk_jobs WHERE id=$1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return j, err
+}
+
+func (r *bulkJobRepository) UpdateJob(ctx context.Context, j *models.BulkJob) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE bulk_jobs SET status=$2, total=$3, processed=$4, failed=$5,
+		error=$6, started_at=$7, finished_at=$8 WHERE id=$1`,
+		j.ID, j.Status, j.Total, j.Processed, j.Failed, j.Error, j.StartedAt, j.FinishedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *bulkJobRepository) ActiveJob(ctx context.Context, userID string) (*models.BulkJob, error) {
+	j, err := scanBulkJob(r.pool.QueryRow(ctx, `SELECT `+bulkJobColumns+` FROM bulk_jobs
+		WHERE user_id=$1 AND status IN ('queued', 'running') ORDER BY id LIMIT 1`, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return j, err
+}
+
+// bulkMatch is true for the messages of user $1 matching the filter in $2 (sender), $3
+// (category) and $4 (before); server-generated digests are never matched
+const bulkMatch = `user_id=$1 AND ` + notDigest + `
+	AND ($2 = '' OR sender_address = $2)
+	AND ($3 = '' OR COALESCE(NULLIF(category, ''), provider_category) = $3)
+	AND ($4 = 0 OR internal_date < $4)`
+
+func (r *bulkJobRepository) CountMatches(ctx context.Context, userID string, f models.BulkFilter) (int, error) {
+	var n int
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM email_messages WHERE `+bulkMatch,
+		userID, f.Sender, f.Category, f.Before).Scan(&n)
+	return n, err
+}
+
+func (r *bulkJobRepository) Matches(ctx context.Context, userID string, f models.BulkFilter, afterInternalDate int64, afterID string, limit int) ([]*models.EmailMessage, error) {
+	rows, err := r.pool.Query(ctx, `SELECT email_message_id, internal_date FROM email_messages
+		WHERE `+bulkMatch+` AND ($5 = '' OR (internal_date, email_message_id) < ($6, $5))
+		ORDER BY internal_date DESC, email_message_id DESC LIMIT $7`,
+		userID, f.Sender, f.Category, f.Before, afterID, afterInternalDate, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*models.EmailMessage
+	for rows.Next() {
+		m := &models.EmailMessage{UserID: userID}
+		if err := rows.Scan(&m.EmailMessageID, &m.InternalDate); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestBulkJobRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewBulkJobRepositoryFromPool(db.Pool)
+	messages := NewEmailMessageRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "bulk-user-1"
+	if err := db.Create(ctx, &models.User{ID: userID, Email: "bulk@example.com", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+
+	for _, msg := range []*models.EmailMessage{
+		{UserID: userID, EmailMessageID: "m1", ThreadID: "t1", Sender: "News <news@x.example>", InternalDate: 1000},
+		{UserID: userID, EmailMessageID: "m2", ThreadID: "t2", Sender: "news@x.example", InternalDate: 3000},
+		{UserID: userID, EmailMessageID: "m3", ThreadID: "t3", Sender: "friend@x.example", InternalDate: 2000, ProviderCategory: "social"},
+		{UserID: userID, EmailMessageID: models.DigestMessagePrefix + "1", ThreadID: "t4", Sender: "news@x.example", InternalDate: 4000},
+	} {
+		if err := messages.UpsertMessage(ctx, msg); err != nil {
+			t.Fatalf("UpsertMessage failed: %v", err)
+		}
+	}
+	if n, err := repo.CountMatches(ctx, userID, models.BulkFilter{Sender: "news@x.example"}); err != nil || n != 2 {
+		t.Errorf("expected 2 messages from the sender, digests excluded, got %d (err %v)", n, err)
+	}
+	if n, err := repo.CountMatches(ctx, userID, models.BulkFilter{Category: "social", Before: 2500}); err != nil || n != 1 {
+		t.Errorf("expected 1 older social message, got %d (err %v)", n, err)
+	}
+	page, err := repo.Matches(ctx, userID, models.BulkFilter{Sender: "news@x.example"}, 0, "", 1)
+	if err != nil || len(page) != 1 || page[0].EmailMessageID != "m2" {
+		t.Fatalf("expected the newest match first, got %+v (err %v)", page, err)
+	}
+	page, err = repo.Matches(ctx, userID, models.BulkFilter{Sender: "news@x.example"}, page[0].InternalDate, page[0].EmailMessageID, 10)
+	if err != nil || len(page) != 1 || page[0].EmailMessageID != "m1" {
+		t.Errorf("expected the next match after the cursor, got %+v (err %v)", page, err)
+	}
+
+	job := &models.BulkJob{UserID: userID, Action: models.BulkArchive, Filter: models.BulkFilter{Sender: "news@x.example", Before: 5000}, Total: 2}
+	if err := repo.CreateJob(ctx, job); err != nil {
+		t.Fatalf("CreateJob failed: %v", err)
+	}
+	if job.ID == 0 || job.Status != models.JobQueued {
+		t.Errorf("unexpected job after create %+v", job)
+	}
+	active, err := repo.ActiveJob(ctx, userID)
+	if err != nil || active == nil || active.ID != job.ID || active.Filter != job.Filter {
+		t.Errorf("expected active job %+v, got %+v (err %v)", job, active, err)
+	}
+
+	now := time.Now().UTC()
+	job.Status, job.Processed, job.Failed, job.StartedAt, job.FinishedAt = models.JobCompleted, 2, 1, &now, &now
+	if err := repo.UpdateJob(ctx, job); err != nil {
+		t.Fatalf("UpdateJob failed: %v", err)
+	}
+	got, err := repo.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("GetJob failed: %v", err)
+	}
+	if got.Status != models.JobCompleted || got.Processed != 2 || got.Failed != 1 || got.FinishedAt == nil {
+		t.Errorf("unexpected job after update %+v", got)
+	}
+	if active, _ := repo.ActiveJob(ctx, userID); active != nil {
+		t.Errorf("expected no active job once completed, got %+v", active)
+	}
+	if _, err := repo.GetJob(ctx, job.ID+1000); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for missing job, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CategoryFeedbackRepository stores categorization feedback
+type CategoryFeedbackRepository interface {
+	// RecordFeedback stores the feedback and updates the message's category in one transaction.
+	// It fills in Sender, PreviousCategory (and Category when confirming) from the cached message
+	// and returns ErrNotFound if the message is not cached.
+	RecordFeedback(ctx context.Context, fb *models.CategoryFeedback) error
+	// CountCorrections counts corrections of messages from sender to category
+	CountCorrections(ctx context.Context, userID, sender, category string) (int, error)
+}
+
+type categoryFeedbackRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewCategoryFeedbackRepositoryFromPool creates a CategoryFeedbackRepository using a pgxpool.Pool
+func NewCategoryFeedbackRepositoryFromPool(pool *pgxpool.Pool) CategoryFeedbackRepository {
+	return &categoryFeedbackRepository{pool: pool}
+}
+
+func (r *categoryFeedbackRepository) RecordFeedback(ctx context.Context, fb *models.CategoryFeedback) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var sender string
+	err = tx.QueryRow(ctx, `SELECT COALESCE(NULLIF(sender_address, ''), sender, ''), COALESCE(category, '') FROM email_messages
+		WHERE user_id=$1 AND email_message_id=$2 FOR UPDATE`, fb.UserID, fb.MessageID).
+		Scan(&sender, &fb.PreviousCategory)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	fb.Sender = senderAddress(sender)
+	if fb.Correct && fb.Category == "" {
+		fb.Category = fb.PreviousCategory
+	}
+	err = tx.QueryRow(ctx, `INSERT INTO category_feedback (user_id, message_id, sender, previous_category, category, correct)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		fb.UserID, fb.MessageID, fb.Sender, fb.PreviousCategory, fb.Category, fb.Correct,
+	).Scan(&fb.ID, &fb.CreatedAt)
+	if err != nil {
+		return err
+	}
+	// User feedback is authoritative, so it is stored with full confidence
+	if _, err := tx.Exec(ctx, `UPDATE email_messages SET category=$3, categorization_confidence=1
+		WHERE user_id=$1 AND email_message_id=$2`, fb.UserID, fb.MessageID, fb.Category); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *categoryFeedbackRepository) CountCorrections(ctx context.Context, userID, sender, category string) (int, error) {
+	var n int
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM category_feedback
+		WHERE user_id=$1 AND sender=$2 AND category=$3 AND NOT correct`, userID, sender, category).Scan(&n)
+	return n, err
+}
+
+// senderAddress normalizes a From header or bare address to its lower-cased address
+func senderAddress(from string) string {
+	address, _ := models.ParseAddress(from)
+	return address
+}
@@ -0,0 +1,64 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestCategoryFeedbackRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewCategoryFeedbackRepositoryFromPool(db.Pool)
+	messages := NewEmailMessageRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "feedback-user-1"
+
+	msg := &models.EmailMessage{
+		UserID:         userID,
+		EmailMessageID: "fb-m1",
+		Sender:         "Bank Alerts <Alerts@Bank.example>",
+		Subject:        "Statement ready",
+		CachedAt:       time.Now(),
+		Category:       sql.NullString{String: "Updates", Valid: true},
+	}
+	if err := messages.UpsertMessage(ctx, msg); err != nil {
+		t.Fatalf("UpsertMessage failed: %v", err)
+	}
+
+	fb := &models.CategoryFeedback{UserID: userID, MessageID: "fb-m1", Category: "Finance"}
+	if err := repo.RecordFeedback(ctx, fb); err != nil {
+		t.Fatalf("RecordFeedback failed: %v", err)
+	}
+	if fb.Sender != "alerts@bank.example" || fb.PreviousCategory != "Updates" || fb.ID == 0 {
+		t.Errorf("unexpected feedback %+v", fb)
+	}
+	got, err := messages.GetMessageByID(ctx, userID, "fb-m1")
+	if err != nil {
+		t.Fatalf("GetMessageByID failed: %v", err)
+	}
+	if got.Category.String != "Finance" || got.CategorizationConfidence.Float64 != 1 {
+		t.Errorf("expected message recategorized as Finance, got %+v / %+v", got.Category, got.CategorizationConfidence)
+	}
+	n, err := repo.CountCorrections(ctx, userID, "alerts@bank.example", "Finance")
+	if err != nil || n != 1 {
+		t.Errorf("expected 1 correction, got %d (err %v)", n, err)
+	}
+
+	confirm := &models.CategoryFeedback{UserID: userID, MessageID: "fb-m1", Correct: true}
+	if err := repo.RecordFeedback(ctx, confirm); err != nil {
+		t.Fatalf("RecordFeedback (confirm) failed: %v", err)
+	}
+	if confirm.Category != "Finance" {
+		t.Errorf("expected confirmation of the current category, got %q", confirm.Category)
+	}
+
+	err = repo.RecordFeedback(ctx, &models.CategoryFeedback{UserID: userID, MessageID: "missing", Category: "Finance"})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for unknown message, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ClientStateRepository stores the frontend's per-user key-value documents
+type ClientStateRepository interface {
+	// List returns the user's documents by key
+	List(ctx context.Context, userID string) ([]*models.ClientState, error)
+	// Count returns how many keys the user has
+	Count(ctx context.Context, userID string) (int, error)
+	// Get returns ErrNotFound if the user has nothing under key
+	Get(ctx context.Context, userID, key string) (*models.ClientState, error)
+	// Put writes state if the stored version is still expectedVersion, 0 for a key that does not
+	// exist yet, and fills in the new Version and UpdatedAt. Returns ErrConflict otherwise.
+	Put(ctx context.Context, state *models.ClientState, expectedVersion int64) error
+	// Delete returns ErrNotFound if the user has nothing under key
+	Delete(ctx context.Context, userID, key string) error
+}
+
+type clientStateRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewClientStateRepositoryFromPool creates a ClientStateRepository using a pgxpool.Pool
+func NewClientStateRepositoryFromPool(pool *pgxpool.Pool) ClientStateRepository {
+	return &clientStateRepository{pool: pool}
+}
+
+func (r *clientStateRepository) List(ctx context.Context, userID string) ([]*models.ClientState, error) {
+	rows, err := r.pool.Query(ctx, `SELECT user_id, key, value, version, updated_at FROM client_state
+		WHERE user_id=$1 ORDER BY key`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*models.ClientState
+	for rows.Next() {
+		var s models.ClientState
+		if err := rows.Scan(&s.UserID, &s.Key, &s.Value, &s.Version, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &s)
+	}
+	return out, rows.Err()
+}
+
+func (r *clientStateRepository) Count(ctx context.Context, userID string) (int, error) {
+	var n int
+	err := r.pool.QueryRow(ctx, `SELECT count(*) FROM client_state WHERE user_id=$1`, userID).Scan(&n)
+	return n, err
+}
+
+func (r *clientStateRepository) Get(ctx context.Context, userID, key string) (*models.ClientState, error) {
+	var s models.ClientState
+	err := r.pool.QueryRow(ctx, `SELECT user_id, key, value, version, updated_at FROM client_state
+		WHERE user_id=$1 AND key=$2`, userID, key).Scan(&s.UserID, &s.Key, &s.Value, &s.Version, &s.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *clientStateRepository) Put(ctx context.Context, state *models.ClientState, expectedVersion int64) error {
+	var row pgx.Row
+	if expectedVersion == 0 {
+		row = r.pool.QueryRow(ctx, `INSERT INTO client_state (user_id, key, value) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, key) DO NOTHING RETURNING version, updated_at`,
+			state.UserID, state.Key, state.Value)
+	} else {
+		row = r.pool.QueryRow(ctx, `UPDATE client_state SET value=$3, version=version+1, updated_at=now()
+			WHERE user_id=$1 AND key=$2 AND version=$4 RETURNING version, updated_at`,
+			state.UserID, state.Key, state.Value, expectedVersion)
+	}
+	err := row.Scan(&state.Version, &state.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (r *clientStateRepository) Delete(ctx context.Context, userID, key string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM client_state WHERE user_id=$1 AND key=$2`, userID, key)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestClientStateRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewClientStateRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+
+	user := &models.User{ID: "state-user", Email: "state@example.com", CreatedAt: time.Now()}
+	if err := db.Create(ctx, user); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	s := &models.ClientState{UserID: user.ID, Key: "view", Value: []byte(`{"selected":"inbox"}`)}
+	if err := repo.Put(ctx, s, 0); err != nil || s.Version != 1 {
+		t.Fatalf("expected version 1, got %d (err %v)", s.Version, err)
+	}
+	if err := repo.Put(ctx, &models.ClientState{UserID: user.ID, Key: "view", Value: []byte(`{}`)}, 0); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict creating an existing key, got %v", err)
+	}
+	s.Value = []byte(`{"selected":"archive"}`)
+	if err := repo.Put(ctx, s, 1); err != nil || s.Version != 2 {
+		t.Fatalf("expected version 2, got %d (err %v)", s.Version, err)
+	}
+	if err := repo.Put(ctx, s, 1); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict writing over an outdated version, got %v", err)
+	}
+	got, err := repo.Get(ctx, user.ID, "view")
+	if err != nil || got.Version != 2 || string(got.Value) != `{"selected": "archive"}` {
+		t.Errorf("expected the second write, got %+v (err %v)", got, err)
+	}
+	if n, err := repo.Count(ctx, user.ID); err != nil || n != 1 {
+		t.Errorf("expected 1 key, got %d (err %v)", n, err)
+	}
+	if list, err := repo.List(ctx, user.ID); err != nil || len(list) != 1 {
+		t.Errorf("expected 1 document, got %v (err %v)", list, err)
+	}
+	if err := repo.Delete(ctx, user.ID, "view"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := repo.Get(ctx, user.ID, "view"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after Delete, got %v", err)
+	}
+	if err := repo.Delete(ctx, user.ID, "view"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting twice, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ContactRepository reads cached threads and stores the per-contact aggregates computed from them
+type ContactRepository interface {
+	// ThreadMessages returns the sender, recipient and date of cached messages in one thread, or in
+	// every thread when threadID is empty, ordered by thread and then date
+	ThreadMessages(ctx context.Context, userID, threadID string) ([]*models.EmailMessage, error)
+	// ReplaceContactStats swaps the user's stored aggregates for stats
+	ReplaceContactStats(ctx context.Context, userID string, stats []*models.ContactStats) error
+	// ListContacts returns the user's contacts, most recently heard from first
+	ListContacts(ctx context.Context, userID string) ([]*models.ContactStats, error)
+	// GetContact returns ErrNotFound if the user has no aggregates for address
+	GetContact(ctx context.Context, userID, address string) (*models.ContactStats, error)
+}
+
+type contactRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewContactRepositoryFromPool creates a ContactRepository using a pgxpool.Pool
+func NewContactRepositoryFromPool(pool *pgxpool.Pool) ContactRepository {
+	return &contactRepository{pool: pool}
+}
+
+func (r *contactRepository) ThreadMessages(ctx context.Context, userID, threadID string) ([]*models.EmailMessage, error) {
+	rows, err := r.pool.Query(ctx, `SELECT email_message_id, thread_id, COALESCE(sender, ''), COALESCE(sender_address, ''), COALESCE(sender_name, ''),
+		COALESCE(recipient, ''), internal_date
+		FROM email_messages WHERE user_id=$1 AND thread_id <> '' AND ($2 = '' OR thread_id = $2)
+		ORDER BY thread_id, internal_date, email_message_id`, userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var msgs []*models.EmailMessage
+	for rows.Next() {
+		msg := &models.EmailMessage{UserID: userID}
+		if err := rows.Scan(&msg.EmailMessageID, &msg.ThreadID, &msg.Sender, &msg.SenderAddress, &msg.SenderName, &msg.Recipient, &msg.InternalDate); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, rows.Err()
+}
+
+func (r *contactRepository) ReplaceContactStats(ctx context.Context, userID string, stats []*models.ContactStats) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM contact_stats WHERE user_id=$1`, userID); err != nil {
+		return err
+	}
+	for _, c := range stats {
+		if _, err := tx.Exec(ctx, `INSERT INTO contact_stats (user_id, address, name, messages_received, threads,
+			user_replies, avg_user_reply_seconds, contact_replies, avg_contact_reply_seconds, last_message_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			userID, c.Address, c.Name, c.MessagesReceived, c.Threads,
+			c.UserReplies, c.AvgUserReplySeconds, c.ContactReplies, c.AvgContactReplySeconds, c.LastMessageAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+const contactColumns = `user_id, address, name, messages_received, threads, user_replies, avg_user_reply_seconds,
+	contact_replies, avg_contact_reply_seconds, last_message_at, updated_at`
+
+func scanContact(row pgx.Row) (*models.ContactStats, error) {
+	var c models.ContactStats
+	if err := row.Scan(&c.UserID, &c.Address, &c.Name, &c.MessagesReceived, &c.Threads, &c.UserReplies, &c.AvgUserReplySeconds,
+		&c.ContactReplies, &c.AvgContactReplySeconds, &c.LastMessageAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *contactRepository) ListContacts(ctx context.Context, userID string) ([]*models.ContactStats, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+contactColumns+` FROM contact_stats
+		WHERE user_id=$1 ORDER BY last_message_at DESC NULLS LAST, address`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*models.ContactStats
+	for rows.Next() {
+		c, err := scanContact(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (r *contactRepository) GetContact(ctx context.Context, userID, address string) (*models.ContactStats, error) {
+	c, err := scanContact(r.pool.QueryRow(ctx, `SELECT `+contactColumns+` FROM contact_stats
+		WHERE user_id=$1 AND address=$2`, userID, address))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return c, err
+}
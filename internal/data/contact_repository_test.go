@@ -0,0 +1,53 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestContactRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewContactRepositoryFromPool(db.Pool)
+	messages := NewEmailMessageRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "contact-user-1"
+
+	for _, msg := range []*models.EmailMessage{
+		{UserID: userID, EmailMessageID: "m2", ThreadID: "t1", Sender: "me@example.com", InternalDate: 2000},
+		{UserID: userID, EmailMessageID: "m1", ThreadID: "t1", Sender: "Ann <ann@example.com>", InternalDate: 1000},
+		{UserID: userID, EmailMessageID: "m3", ThreadID: "t2", Sender: "bob@example.com", InternalDate: 500},
+	} {
+		if err := messages.UpsertMessage(ctx, msg); err != nil {
+			t.Fatalf("UpsertMessage failed: %v", err)
+		}
+	}
+	thread, err := repo.ThreadMessages(ctx, userID, "t1")
+	if err != nil || len(thread) != 2 || thread[0].EmailMessageID != "m1" {
+		t.Fatalf("expected t1 messages oldest first, got %+v (err %v)", thread, err)
+	}
+	all, err := repo.ThreadMessages(ctx, userID, "")
+	if err != nil || len(all) != 3 {
+		t.Errorf("expected 3 messages across threads, got %d (err %v)", len(all), err)
+	}
+
+	last := time.Now().UTC().Truncate(time.Second)
+	stats := []*models.ContactStats{{Address: "ann@example.com", Name: "Ann", MessagesReceived: 1, Threads: 1, UserReplies: 1, AvgUserReplySeconds: 1, LastMessageAt: &last}}
+	if err := repo.ReplaceContactStats(ctx, userID, stats); err != nil {
+		t.Fatalf("ReplaceContactStats failed: %v", err)
+	}
+	if err := repo.ReplaceContactStats(ctx, userID, stats); err != nil {
+		t.Fatalf("ReplaceContactStats (again) failed: %v", err)
+	}
+	list, err := repo.ListContacts(ctx, userID)
+	if err != nil || len(list) != 1 || list[0].UserReplies != 1 {
+		t.Errorf("unexpected contacts %+v (err %v)", list, err)
+	}
+	if _, err := repo.GetContact(ctx, userID, "nobody@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
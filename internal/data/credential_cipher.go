@@ -0,0 +1,70 @@
+package data
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// CredentialKeySize is the length of the key given to NewCredentialCipher
+const CredentialKeySize = 32
+
+// ErrCredentialUnreadable means a stored credential could not be decrypted, usually because the
+// key changed since it was stored
+var ErrCredentialUnreadable = errors.New("stored credential cannot be decrypted")
+
+// CredentialCipher encrypts credentials kept at rest, such as IMAP passwords, with AES-256-GCM
+// under a deploy-level key. Each value is bound to its owner so rows cannot be swapped.
+type CredentialCipher struct {
+	aead cipher.AEAD
+}
+
+// NewCredentialCipher returns a cipher for a CredentialKeySize-byte key
+func NewCredentialCipher(key []byte) (*CredentialCipher, error) {
+	if len(key) != CredentialKeySize {
+		return nil, fmt.Errorf("credential key must be %d bytes, got %d", CredentialKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &CredentialCipher{aead: aead}, nil
+}
+
+// ParseCredentialKey returns a cipher for a base64-encoded key, as configured
+func ParseCredentialKey(encoded string) (*CredentialCipher, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("credential key is not base64: %w", err)
+	}
+	return NewCredentialCipher(key)
+}
+
+// Seal encrypts plaintext for owner; the nonce is prepended to the result
+func (c *CredentialCipher) Seal(owner string, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, []byte(owner)), nil
+}
+
+// Open decrypts a value sealed for owner; ErrCredentialUnreadable if it cannot be
+func (c *CredentialCipher) Open(owner string, sealed []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, ErrCredentialUnreadable
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:n], sealed[n:], []byte(owner))
+	if err != nil {
+		return nil, ErrCredentialUnreadable
+	}
+	return plaintext, nil
+}
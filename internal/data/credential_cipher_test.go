@@ -0,0 +1,36 @@
+package data
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestCredentialCipher(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, CredentialKeySize)
+	c, err := ParseCredentialKey(base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		t.Fatalf("ParseCredentialKey failed: %v", err)
+	}
+	sealed, err := c.Seal("user1", []byte("hunter2"))
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("hunter2")) {
+		t.Fatal("sealed value contains the plaintext")
+	}
+	if got, err := c.Open("user1", sealed); err != nil || string(got) != "hunter2" {
+		t.Errorf("Open = %q, %v", got, err)
+	}
+	if _, err := c.Open("user2", sealed); !errors.Is(err, ErrCredentialUnreadable) {
+		t.Errorf("opening another user's value: expected ErrCredentialUnreadable, got %v", err)
+	}
+	other, _ := NewCredentialCipher(bytes.Repeat([]byte{8}, CredentialKeySize))
+	if _, err := other.Open("user1", sealed); !errors.Is(err, ErrCredentialUnreadable) {
+		t.Errorf("opening with another key: expected ErrCredentialUnreadable, got %v", err)
+	}
+	if _, err := ParseCredentialKey(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Error("expected an error for a short key")
+	}
+}
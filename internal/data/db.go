@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"github.com/desponda/inbox-whisperer/internal/telemetry/tracing"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -12,10 +14,27 @@ type DB struct {
 }
 
 func New(ctx context.Context, dbURL string) (*DB, error) {
-	cfg, err := pgxpool.ParseConfig(dbURL)
+	return NewWithURLSource(ctx, func() string { return dbURL })
+}
+
+// NewWithURLSource connects like New, but reads the URL again for every new connection and
+// takes its user and password from it, so rotated database credentials are picked up
+// without a restart. Established connections keep their credentials until recycled.
+func NewWithURLSource(ctx context.Context, dbURL func() string) (*DB, error) {
+	cfg, err := pgxpool.ParseConfig(dbURL())
 	if err != nil {
 		return nil, err
 	}
+	cfg.BeforeConnect = func(ctx context.Context, conn *pgx.ConnConfig) error {
+		current, err := pgx.ParseConfig(dbURL())
+		if err != nil {
+			return err
+		}
+		conn.User, conn.Password = current.User, current.Password
+		return nil
+	}
+	// Queries show up as spans of the request or job that ran them, when tracing is on
+	cfg.ConnConfig.Tracer = tracing.QueryTracer{}
 	cfg.MaxConns = 10
 	cfg.MinConns = 1
 	cfg.MaxConnLifetime = time.Hour
@@ -0,0 +1,91 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DeviceCodeRepository stores device authorizations in progress, by the hash of the device code
+type DeviceCodeRepository interface {
+	// Create stores a pending code. Expired codes are purged as a side effect.
+	Create(ctx context.Context, deviceCodeHash, userCode string, expiresAt time.Time) error
+	// Decide approves (binding it to userID) or denies a pending, unexpired code. Returns
+	// ErrNotFound if there is no such code.
+	Decide(ctx context.Context, userCode, userID string, approve bool) error
+	// Poll records a poll and returns the code as it was before it. Returns ErrNotFound for
+	// unknown codes.
+	Poll(ctx context.Context, deviceCodeHash string) (*models.DeviceCode, error)
+	// Consume deletes an approved code and returns the user it was approved for, so only one
+	// poll can exchange it. Returns ErrNotFound if it is not approved.
+	Consume(ctx context.Context, deviceCodeHash string) (string, error)
+}
+
+type deviceCodeRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewDeviceCodeRepositoryFromPool creates a DeviceCodeRepository using a pgxpool.Pool
+func NewDeviceCodeRepositoryFromPool(pool *pgxpool.Pool) DeviceCodeRepository {
+	return &deviceCodeRepository{pool: pool}
+}
+
+func (r *deviceCodeRepository) Create(ctx context.Context, deviceCodeHash, userCode string, expiresAt time.Time) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM device_codes WHERE expires_at <= NOW()`); err != nil {
+		return err
+	}
+	_, err := r.pool.Exec(ctx, `INSERT INTO device_codes (device_code_hash, user_code, expires_at) VALUES ($1, $2, $3)`,
+		deviceCodeHash, userCode, expiresAt.UTC())
+	return err
+}
+
+func (r *deviceCodeRepository) Decide(ctx context.Context, userCode, userID string, approve bool) error {
+	status := models.DeviceCodeDenied
+	if approve {
+		status = models.DeviceCodeApproved
+	}
+	tag, err := r.pool.Exec(ctx, `UPDATE device_codes SET status=$3, user_id=NULLIF($2, '')
+		WHERE user_code=$1 AND status='pending' AND expires_at > NOW()`, userCode, userID, status)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *deviceCodeRepository) Poll(ctx context.Context, deviceCodeHash string) (*models.DeviceCode, error) {
+	var c models.DeviceCode
+	var userID *string
+	// The subquery reads the row as it was before this update
+	err := r.pool.QueryRow(ctx, `UPDATE device_codes d SET last_polled_at=NOW()
+		FROM (SELECT device_code_hash, last_polled_at FROM device_codes WHERE device_code_hash=$1 FOR UPDATE) prev
+		WHERE d.device_code_hash=prev.device_code_hash
+		RETURNING d.user_code, d.status, d.user_id, d.expires_at, prev.last_polled_at`, deviceCodeHash).
+		Scan(&c.UserCode, &c.Status, &userID, &c.ExpiresAt, &c.LastPolledAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if userID != nil {
+		c.UserID = *userID
+	}
+	return &c, nil
+}
+
+func (r *deviceCodeRepository) Consume(ctx context.Context, deviceCodeHash string) (string, error) {
+	var userID string
+	err := r.pool.QueryRow(ctx, `DELETE FROM device_codes WHERE device_code_hash=$1 AND status='approved'
+		RETURNING user_id`, deviceCodeHash).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return userID, err
+}
@@ -2,21 +2,59 @@ package data
 
 import (
 	"context"
+	"fmt"
 	"github.com/desponda/inbox-whisperer/internal/models"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type EmailMessageRepository interface {
+	// UpsertMessage caches a message. A stored category with full confidence was set by the user
+	// or a rule and is kept.
 	UpsertMessage(ctx context.Context, msg *models.EmailMessage) error
 	GetMessageByID(ctx context.Context, userID, emailMessageID string) (*models.EmailMessage, error)
 	GetMessagesForUser(ctx context.Context, userID string, limit, offset int) ([]*models.EmailMessage, error)
 	GetMessagesForUserCursor(ctx context.Context, userID string, limit int, afterInternalDate int64, afterMsgID string) ([]*models.EmailMessage, error)
 	DeleteMessagesForUser(ctx context.Context, userID string) error
+	// ClearMessageContent drops the cached bodies and raw payloads of the user's messages,
+	// keeping headers and snippets
+	ClearMessageContent(ctx context.Context, userID string) error
+	// SetCategory overwrites a cached message's category; returns ErrNotFound if the message is not cached
+	SetCategory(ctx context.Context, userID, emailMessageID, category string, confidence float64) error
+	// SetRead records a read state change made at the provider; returns ErrNotFound if the
+	// message is not cached
+	SetRead(ctx context.Context, userID, emailMessageID string, read bool) error
+	// SetArchived records an archive made at the provider by dropping INBOX from the cached
+	// labels; returns ErrNotFound if the message is not cached
+	SetArchived(ctx context.Context, userID, emailMessageID string) error
+	// DeleteMessage removes a message the provider deleted or trashed from the cache; returns
+	// ErrNotFound if the message is not cached
+	DeleteMessage(ctx context.Context, userID, emailMessageID string) error
+}
+
+// MessageBackfiller rewrites cached messages in batches, for data migrations too involved for SQL
+type MessageBackfiller interface {
+	// PruneRawJSON drops the raw payload of up to limit messages, first copying their headers
+	// into the headers column, and returns how many it pruned (see storage.drop_raw_json). The
+	// messages of users under legal hold are left as they are.
+	PruneRawJSON(ctx context.Context, limit int) (int64, error)
+	// CompressBodies compresses the bodies of up to limit messages stored before compression,
+	// and returns how many it rewrote
+	CompressBodies(ctx context.Context, limit int) (int64, error)
+	// ParseSenders fills the sender address and name of up to limit messages cached before
+	// they were parsed at sync time, and returns how many it filled
+	ParseSenders(ctx context.Context, limit int) (int64, error)
+}
+
+// MessageCounter counts a user's cached messages, for the totals of paginated lists
+type MessageCounter interface {
+	CountMessages(ctx context.Context, userID string) (int64, error)
+	// CountThreads counts the conversations ThreadRepository.ListThreads pages through
+	CountThreads(ctx context.Context, userID string) (int64, error)
 }
 
 type emailMessageRepository struct {
-	pool *pgxpool.Pool
+	pool querier
 }
 
 // NewEmailMessageRepositoryFromPool creates a repository using a pgxpool.Pool (only implementation)
@@ -24,25 +62,87 @@ func NewEmailMessageRepositoryFromPool(pool *pgxpool.Pool) EmailMessageRepositor
 	return &emailMessageRepository{pool: pool}
 }
 
+// metadataOnlyCache is true when the user of the statement ($1) chose metadata-only caching.
+// Writes check it themselves so no caller can store content against that choice.
+const metadataOnlyCache = `EXISTS (SELECT 1 FROM user_settings WHERE user_id=$1 AND metadata_only_cache)`
+
+// NewMessageBackfillerFromPool creates a MessageBackfiller using a pgxpool.Pool
+func NewMessageBackfillerFromPool(pool *pgxpool.Pool) MessageBackfiller {
+	return &emailMessageRepository{pool: pool}
+}
+
+// NewMessageCounterFromPool creates a MessageCounter using a pgxpool.Pool
+func NewMessageCounterFromPool(pool *pgxpool.Pool) MessageCounter {
+	return &emailMessageRepository{pool: pool}
+}
+
+func (r *emailMessageRepository) CountMessages(ctx context.Context, userID string) (int64, error) {
+	var n int64
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM email_messages WHERE user_id=$1`, userID).Scan(&n)
+	return n, err
+}
+
+func (r *emailMessageRepository) CountThreads(ctx context.Context, userID string) (int64, error) {
+	var n int64
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(DISTINCT thread_id) FROM email_messages
+		WHERE user_id=$1 AND COALESCE(thread_id, '') <> ''`, userID).Scan(&n)
+	return n, err
+}
+
 func (r *emailMessageRepository) UpsertMessage(ctx context.Context, msg *models.EmailMessage) error {
+	if msg.SenderAddress == "" {
+		msg.ParseSender()
+	}
+	if msg.ListUnsubscribe == nil {
+		msg.ParseListUnsubscribe()
+	}
+	body, err := encodeBody(msg.Body)
+	if err != nil {
+		return err
+	}
+	htmlBody, err := encodeBody(msg.HTMLBody)
+	if err != nil {
+		return err
+	}
 	query := `INSERT INTO email_messages
-		(user_id, email_message_id, thread_id, subject, sender, recipient, snippet, body, internal_date, history_id, cached_at, last_fetched_at, category, categorization_confidence, raw_json)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15)
+		(user_id, email_message_id, thread_id, subject, sender, recipient, snippet, body, internal_date, history_id, cached_at, last_fetched_at, category, categorization_confidence, raw_json, provider_category, provider_important, content_hash, label_hash, changed_at, headers, html_body, sender_address, sender_name, size_estimate, has_attachments, is_read, list_unsubscribe)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,
+			CASE WHEN ` + metadataOnlyCache + ` THEN NULL ELSE $8::bytea END,
+			$9,$10,$11,$12,$13,$14,
+			CASE WHEN ` + metadataOnlyCache + ` THEN NULL ELSE $15::jsonb END,
+			$16,$17,$18,$19,$20,$21,
+			CASE WHEN ` + metadataOnlyCache + ` THEN NULL ELSE $22::bytea END,
+			$23,$24,$25,$26,$27,$28)
 		ON CONFLICT (user_id, email_message_id) DO UPDATE SET
 		thread_id=EXCLUDED.thread_id,
 		subject=EXCLUDED.subject,
 		sender=EXCLUDED.sender,
+		sender_address=EXCLUDED.sender_address,
+		sender_name=EXCLUDED.sender_name,
+		size_estimate=EXCLUDED.size_estimate,
+		has_attachments=EXCLUDED.has_attachments,
 		recipient=EXCLUDED.recipient,
 		snippet=EXCLUDED.snippet,
 		body=EXCLUDED.body,
+		html_body=EXCLUDED.html_body,
 		internal_date=EXCLUDED.internal_date,
 		history_id=EXCLUDED.history_id,
 		cached_at=EXCLUDED.cached_at,
 		last_fetched_at=EXCLUDED.last_fetched_at,
-		category=EXCLUDED.category,
-		categorization_confidence=EXCLUDED.categorization_confidence,
-		raw_json=EXCLUDED.raw_json`
-	_, err := r.pool.Exec(ctx, query,
+		category=CASE WHEN email_messages.categorization_confidence >= 1 THEN email_messages.category
+			ELSE COALESCE(EXCLUDED.category, email_messages.category) END,
+		categorization_confidence=CASE WHEN email_messages.categorization_confidence >= 1 THEN email_messages.categorization_confidence
+			ELSE COALESCE(EXCLUDED.categorization_confidence, email_messages.categorization_confidence) END,
+		raw_json=EXCLUDED.raw_json,
+		provider_category=EXCLUDED.provider_category,
+		provider_important=EXCLUDED.provider_important,
+		is_read=EXCLUDED.is_read,
+		content_hash=COALESCE(NULLIF(EXCLUDED.content_hash, ''), email_messages.content_hash),
+		label_hash=COALESCE(NULLIF(EXCLUDED.label_hash, ''), email_messages.label_hash),
+		changed_at=COALESCE(EXCLUDED.changed_at, email_messages.changed_at),
+		headers=COALESCE(EXCLUDED.headers, email_messages.headers),
+		list_unsubscribe=COALESCE(EXCLUDED.list_unsubscribe, email_messages.list_unsubscribe)`
+	_, err = r.pool.Exec(ctx, query,
 		msg.UserID,
 		msg.EmailMessageID,
 		msg.ThreadID,
@@ -50,7 +150,7 @@ func (r *emailMessageRepository) UpsertMessage(ctx context.Context, msg *models.
 		msg.Sender,
 		msg.Recipient,
 		msg.Snippet,
-		msg.Body,
+		body,
 		msg.InternalDate,
 		msg.HistoryID,
 		msg.CachedAt,
@@ -58,23 +158,67 @@ func (r *emailMessageRepository) UpsertMessage(ctx context.Context, msg *models.
 		msg.Category,
 		msg.CategorizationConfidence,
 		msg.RawJSON,
+		msg.ProviderCategory,
+		msg.ProviderImportant,
+		msg.ContentHash,
+		msg.LabelHash,
+		msg.ChangedAt,
+		nullableHeaders(msg.Headers),
+		htmlBody,
+		msg.SenderAddress,
+		msg.SenderName,
+		msg.SizeEstimate,
+		msg.HasAttachments,
+		msg.IsRead,
+		msg.ListUnsubscribe,
 	)
 	return err
 }
 
-func (r *emailMessageRepository) GetMessageByID(ctx context.Context, userID, emailMessageID string) (*models.EmailMessage, error) {
-	query := `SELECT id, user_id, email_message_id, thread_id, subject, sender, recipient, snippet, body, internal_date, history_id, cached_at, last_fetched_at, category, categorization_confidence, raw_json FROM email_messages WHERE user_id=$1 AND email_message_id=$2`
-	row := r.pool.QueryRow(ctx, query, userID, emailMessageID)
+// nullableHeaders stores missing headers as NULL, so writers without them keep the stored ones
+func nullableHeaders(h []models.MessageHeader) any {
+	if len(h) == 0 {
+		return nil
+	}
+	return h
+}
+
+// messageColumns are the columns scanMessage reads, in order
+const messageColumns = `id, user_id, email_message_id, thread_id, subject, sender, COALESCE(sender_address, ''), COALESCE(sender_name, ''), recipient, snippet, body, html_body, internal_date, history_id, cached_at, last_fetched_at, category, categorization_confidence, raw_json, provider_category, provider_important, content_hash, label_hash, changed_at, headers, COALESCE(size_estimate, 0), COALESCE(has_attachments, false), is_read, list_unsubscribe`
+
+// scanMessage reads a row of messageColumns, decoding the stored bodies
+func scanMessage(row pgx.Row) (*models.EmailMessage, error) {
 	var msg models.EmailMessage
-	err := row.Scan(&msg.ID, &msg.UserID, &msg.EmailMessageID, &msg.ThreadID, &msg.Subject, &msg.Sender, &msg.Recipient, &msg.Snippet, &msg.Body, &msg.InternalDate, &msg.HistoryID, &msg.CachedAt, &msg.LastFetchedAt, &msg.Category, &msg.CategorizationConfidence, &msg.RawJSON)
+	var body, htmlBody []byte
+	err := row.Scan(&msg.ID, &msg.UserID, &msg.EmailMessageID, &msg.ThreadID, &msg.Subject, &msg.Sender, &msg.SenderAddress, &msg.SenderName, &msg.Recipient, &msg.Snippet, &body, &htmlBody, &msg.InternalDate, &msg.HistoryID, &msg.CachedAt, &msg.LastFetchedAt, &msg.Category, &msg.CategorizationConfidence, &msg.RawJSON, &msg.ProviderCategory, &msg.ProviderImportant, &msg.ContentHash, &msg.LabelHash, &msg.ChangedAt, &msg.Headers, &msg.SizeEstimate, &msg.HasAttachments, &msg.IsRead, &msg.ListUnsubscribe)
 	if err != nil {
 		return nil, err
 	}
+	if msg.SenderAddress == "" {
+		// Not reached by the parse_senders backfill yet
+		msg.ParseSender()
+	}
+	if msg.ListUnsubscribe == nil {
+		// Cached before the headers were parsed
+		msg.ParseListUnsubscribe()
+	}
+	msg.ParseRecipients()
+	if msg.Body, err = decodeBody(body); err != nil {
+		return nil, err
+	}
+	if msg.HTMLBody, err = decodeBody(htmlBody); err != nil {
+		return nil, err
+	}
 	return &msg, nil
 }
 
+func (r *emailMessageRepository) GetMessageByID(ctx context.Context, userID, emailMessageID string) (*models.EmailMessage, error) {
+	query := `SELECT ` + messageColumns + ` FROM email_messages WHERE user_id=$1 AND email_message_id=$2`
+	return scanMessage(r.pool.QueryRow(ctx, query, userID, emailMessageID))
+}
+
 func (r *emailMessageRepository) GetMessagesForUser(ctx context.Context, userID string, limit, offset int) ([]*models.EmailMessage, error) {
-	query := `SELECT id, user_id, email_message_id, thread_id, subject, sender, recipient, snippet, body, internal_date, history_id, cached_at, last_fetched_at, category, categorization_confidence, raw_json FROM email_messages WHERE user_id=$1 ORDER BY internal_date DESC, email_message_id DESC LIMIT $2 OFFSET $3`
+	query := `SELECT ` + messageColumns + ` FROM email_messages WHERE user_id=$1 ORDER BY internal_date DESC, email_message_id DESC LIMIT $2 OFFSET $3`
 	rows, err := r.pool.Query(ctx, query, userID, limit, offset)
 	if err != nil {
 		return nil, err
@@ -82,12 +226,11 @@ func (r *emailMessageRepository) GetMessagesForUser(ctx context.Context, userID
 	defer rows.Close()
 	var msgs []*models.EmailMessage
 	for rows.Next() {
-		var msg models.EmailMessage
-		err := rows.Scan(&msg.ID, &msg.UserID, &msg.EmailMessageID, &msg.ThreadID, &msg.Subject, &msg.Sender, &msg.Recipient, &msg.Snippet, &msg.Body, &msg.InternalDate, &msg.HistoryID, &msg.CachedAt, &msg.LastFetchedAt, &msg.Category, &msg.CategorizationConfidence, &msg.RawJSON)
+		msg, err := scanMessage(rows)
 		if err != nil {
 			return nil, err
 		}
-		msgs = append(msgs, &msg)
+		msgs = append(msgs, msg)
 	}
 	return msgs, nil
 }
@@ -99,10 +242,10 @@ func (r *emailMessageRepository) GetMessagesForUserCursor(ctx context.Context, u
 		err   error
 	)
 	if afterInternalDate > 0 && afterMsgID != "" {
-		query = `SELECT id, user_id, email_message_id, thread_id, subject, sender, recipient, snippet, body, internal_date, history_id, cached_at, last_fetched_at, category, categorization_confidence, raw_json FROM email_messages WHERE user_id=$1 AND (internal_date, email_message_id) < ($2, $3) ORDER BY internal_date DESC, email_message_id DESC LIMIT $4`
+		query = `SELECT ` + messageColumns + ` FROM email_messages WHERE user_id=$1 AND (internal_date, email_message_id) < ($2, $3) ORDER BY internal_date DESC, email_message_id DESC LIMIT $4`
 		rows, err = r.pool.Query(ctx, query, userID, afterInternalDate, afterMsgID, limit)
 	} else {
-		query = `SELECT id, user_id, email_message_id, thread_id, subject, sender, recipient, snippet, body, internal_date, history_id, cached_at, last_fetched_at, category, categorization_confidence, raw_json FROM email_messages WHERE user_id=$1 ORDER BY internal_date DESC, email_message_id DESC LIMIT $2`
+		query = `SELECT ` + messageColumns + ` FROM email_messages WHERE user_id=$1 ORDER BY internal_date DESC, email_message_id DESC LIMIT $2`
 		rows, err = r.pool.Query(ctx, query, userID, limit)
 	}
 	if err != nil {
@@ -111,12 +254,11 @@ func (r *emailMessageRepository) GetMessagesForUserCursor(ctx context.Context, u
 	defer rows.Close()
 	var msgs []*models.EmailMessage
 	for rows.Next() {
-		var msg models.EmailMessage
-		err := rows.Scan(&msg.ID, &msg.UserID, &msg.EmailMessageID, &msg.ThreadID, &msg.Subject, &msg.Sender, &msg.Recipient, &msg.Snippet, &msg.Body, &msg.InternalDate, &msg.HistoryID, &msg.CachedAt, &msg.LastFetchedAt, &msg.Category, &msg.CategorizationConfidence, &msg.RawJSON)
+		msg, err := scanMessage(rows)
 		if err != nil {
 			return nil, err
 		}
-		msgs = append(msgs, &msg)
+		msgs = append(msgs, msg)
 	}
 	return msgs, nil
 }
@@ -125,3 +267,148 @@ func (r *emailMessageRepository) DeleteMessagesForUser(ctx context.Context, user
 	_, err := r.pool.Exec(ctx, `DELETE FROM email_messages WHERE user_id=$1`, userID)
 	return err
 }
+
+func (r *emailMessageRepository) ClearMessageContent(ctx context.Context, userID string) error {
+	_, err := r.pool.Exec(ctx, `UPDATE email_messages SET body=NULL, html_body=NULL, raw_json=NULL WHERE user_id=$1`, userID)
+	return err
+}
+
+func (r *emailMessageRepository) PruneRawJSON(ctx context.Context, limit int) (int64, error) {
+	tag, err := r.pool.Exec(ctx, `UPDATE email_messages
+		SET headers = COALESCE(headers, raw_json->'payload'->'headers'), raw_json = NULL
+		WHERE id IN (SELECT id FROM email_messages WHERE raw_json IS NOT NULL
+			AND user_id NOT IN (SELECT user_id FROM legal_holds) LIMIT $1)`, limit)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *emailMessageRepository) CompressBodies(ctx context.Context, limit int) (int64, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id, body, html_body FROM email_messages
+		WHERE (get_byte(body, 0) = $1 AND length(body) > $2) OR (get_byte(html_body, 0) = $1 AND length(html_body) > $2)
+		LIMIT $3`, int(codecRaw), compressThreshold, limit)
+	if err != nil {
+		return 0, err
+	}
+	type stored struct {
+		id             int64
+		body, htmlBody []byte
+	}
+	var batch []stored
+	for rows.Next() {
+		var s stored
+		if err := rows.Scan(&s.id, &s.body, &s.htmlBody); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, s := range batch {
+		body, err := reencodeBody(s.body)
+		if err != nil {
+			return 0, fmt.Errorf("message %d: %w", s.id, err)
+		}
+		htmlBody, err := reencodeBody(s.htmlBody)
+		if err != nil {
+			return 0, fmt.Errorf("message %d: %w", s.id, err)
+		}
+		// A message rewritten since it was read is left alone; the new write is already encoded
+		if _, err := r.pool.Exec(ctx, `UPDATE email_messages SET body=$2, html_body=$3
+			WHERE id=$1 AND body IS NOT DISTINCT FROM $4 AND html_body IS NOT DISTINCT FROM $5`,
+			s.id, body, htmlBody, s.body, s.htmlBody); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(batch)), nil
+}
+
+func (r *emailMessageRepository) ParseSenders(ctx context.Context, limit int) (int64, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id, COALESCE(sender, '') FROM email_messages WHERE sender_address IS NULL LIMIT $1`, limit)
+	if err != nil {
+		return 0, err
+	}
+	type stored struct {
+		id     int64
+		sender string
+	}
+	var batch []stored
+	for rows.Next() {
+		var s stored
+		if err := rows.Scan(&s.id, &s.sender); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, s := range batch {
+		address, name := models.ParseAddress(s.sender)
+		// A message rewritten since it was read already has its sender parsed
+		if _, err := r.pool.Exec(ctx, `UPDATE email_messages SET sender_address=$2, sender_name=$3
+			WHERE id=$1 AND sender_address IS NULL`, s.id, address, name); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(batch)), nil
+}
+
+func (r *emailMessageRepository) SetCategory(ctx context.Context, userID, emailMessageID, category string, confidence float64) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE email_messages SET category=$3, categorization_confidence=$4 WHERE user_id=$1 AND email_message_id=$2`,
+		userID, emailMessageID, category, confidence)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *emailMessageRepository) SetRead(ctx context.Context, userID, emailMessageID string, read bool) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE email_messages SET is_read=$3 WHERE user_id=$1 AND email_message_id=$2`,
+		userID, emailMessageID, read)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetArchived rewrites the labels in the raw payload, when one is kept, and clears the label hash
+// so the next sync stores the provider's labels without reporting the archive as an update
+func (r *emailMessageRepository) SetArchived(ctx context.Context, userID, emailMessageID string) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE email_messages SET label_hash='',
+		raw_json=CASE WHEN jsonb_typeof(raw_json->'labelIds')='array'
+			THEN jsonb_set(raw_json, '{labelIds}', COALESCE(
+				(SELECT jsonb_agg(l) FROM jsonb_array_elements(raw_json->'labelIds') AS l WHERE l <> '"INBOX"'), '[]'::jsonb))
+			ELSE raw_json END
+		WHERE user_id=$1 AND email_message_id=$2`, userID, emailMessageID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *emailMessageRepository) DeleteMessage(ctx context.Context, userID, emailMessageID string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM email_messages WHERE user_id=$1 AND email_message_id=$2`, userID, emailMessageID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
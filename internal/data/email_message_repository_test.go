@@ -3,6 +3,9 @@ package data
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -80,6 +83,46 @@ func TestEmailMessageRepository_CRUD(t *testing.T) {
 		t.Errorf("expected at least 1 message with cursor, got 0")
 	}
 
+	// Read state
+	if err := repo.SetRead(ctx, msg.UserID, msg.EmailMessageID, false); err != nil {
+		t.Fatalf("SetRead failed: %v", err)
+	}
+	if got, err = repo.GetMessageByID(ctx, msg.UserID, msg.EmailMessageID); err != nil || got.IsRead {
+		t.Errorf("expected unread after SetRead, got %+v, %v", got, err)
+	}
+	if err := repo.SetRead(ctx, msg.UserID, "missing", true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetRead on an uncached message: got %v, want ErrNotFound", err)
+	}
+
+	// Archive drops INBOX from the cached labels
+	archived := *msg
+	archived.EmailMessageID, archived.RawJSON, archived.LabelHash = "archived", []byte(`{"labelIds":["INBOX","STARRED"]}`), "l1"
+	if err := repo.UpsertMessage(ctx, &archived); err != nil {
+		t.Fatalf("UpsertMessage (archived) failed: %v", err)
+	}
+	if err := repo.SetArchived(ctx, msg.UserID, "archived"); err != nil {
+		t.Fatalf("SetArchived failed: %v", err)
+	}
+	if got, err = repo.GetMessageByID(ctx, msg.UserID, "archived"); err != nil || string(got.RawJSON) != `{"labelIds": ["STARRED"]}` || got.LabelHash != "" {
+		t.Errorf("expected INBOX dropped and the label hash cleared, got %+v, %v", got, err)
+	}
+	if err := repo.SetArchived(ctx, msg.UserID, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetArchived on an uncached message: got %v, want ErrNotFound", err)
+	}
+
+	// Delete one
+	other := *msg
+	other.EmailMessageID = "trashed"
+	if err := repo.UpsertMessage(ctx, &other); err != nil {
+		t.Fatalf("UpsertMessage failed: %v", err)
+	}
+	if err := repo.DeleteMessage(ctx, msg.UserID, "trashed"); err != nil {
+		t.Fatalf("DeleteMessage failed: %v", err)
+	}
+	if err := repo.DeleteMessage(ctx, msg.UserID, "trashed"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeleteMessage: got %v, want ErrNotFound", err)
+	}
+
 	// Delete for user
 	err = repo.DeleteMessagesForUser(ctx, msg.UserID)
 	if err != nil {
@@ -93,3 +136,324 @@ func TestEmailMessageRepository_CRUD(t *testing.T) {
 		t.Errorf("expected 0 messages after delete, got %d", len(msgs))
 	}
 }
+
+func TestEmailMessageRepository_ChangeTracking(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewEmailMessageRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+
+	msg := &models.EmailMessage{UserID: "user-uuid-1", EmailMessageID: "msg-1", CachedAt: time.Now(), RawJSON: []byte("{}"), ContentHash: "h1", LabelHash: "l1"}
+	if err := repo.UpsertMessage(ctx, msg); err != nil {
+		t.Fatalf("UpsertMessage failed: %v", err)
+	}
+	changed := sql.NullTime{Time: time.Now().UTC().Truncate(time.Second), Valid: true}
+	msg.ContentHash, msg.LabelHash, msg.ChangedAt = "h2", "l2", changed
+	if err := repo.UpsertMessage(ctx, msg); err != nil {
+		t.Fatalf("UpsertMessage (changed) failed: %v", err)
+	}
+
+	// Writers that do not hash or stamp keep the stored values
+	msg.ContentHash, msg.LabelHash, msg.ChangedAt = "", "", sql.NullTime{}
+	if err := repo.UpsertMessage(ctx, msg); err != nil {
+		t.Fatalf("UpsertMessage (unhashed) failed: %v", err)
+	}
+	got, err := repo.GetMessageByID(ctx, msg.UserID, msg.EmailMessageID)
+	if err != nil {
+		t.Fatalf("GetMessageByID failed: %v", err)
+	}
+	if got.ContentHash != "h2" || got.LabelHash != "l2" || !got.ChangedAt.Valid || !got.ChangedAt.Time.Equal(changed.Time) {
+		t.Errorf("expected hashes h2/l2 changed at %v, got %q/%q at %+v", changed.Time, got.ContentHash, got.LabelHash, got.ChangedAt)
+	}
+
+	// A category the user set, stored with full confidence, survives a re-sync's categorization
+	if err := repo.SetCategory(ctx, msg.UserID, msg.EmailMessageID, "Personal", 1); err != nil {
+		t.Fatalf("SetCategory failed: %v", err)
+	}
+	msg.Category = sql.NullString{String: "Promotions", Valid: true}
+	msg.CategorizationConfidence = sql.NullFloat64{Float64: 0.7, Valid: true}
+	if err := repo.UpsertMessage(ctx, msg); err != nil {
+		t.Fatalf("UpsertMessage (recategorized) failed: %v", err)
+	}
+	got, err = repo.GetMessageByID(ctx, msg.UserID, msg.EmailMessageID)
+	if err != nil {
+		t.Fatalf("GetMessageByID failed: %v", err)
+	}
+	if got.Category.String != "Personal" || got.CategorizationConfidence.Float64 != 1 {
+		t.Errorf("expected the user's category to be kept, got %+v / %+v", got.Category, got.CategorizationConfidence)
+	}
+}
+
+func TestEmailMessageRepository_MetadataOnlyCache(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewEmailMessageRepositoryFromPool(db.Pool)
+	settings := NewUserSettingsRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+
+	msg := &models.EmailMessage{UserID: "user-uuid-1", EmailMessageID: "msg-1", Subject: "Hi", Body: "secret", CachedAt: time.Now(), RawJSON: []byte(`{"id":"msg-1"}`)}
+	if err := repo.UpsertMessage(ctx, msg); err != nil {
+		t.Fatalf("UpsertMessage failed: %v", err)
+	}
+	if err := repo.ClearMessageContent(ctx, msg.UserID); err != nil {
+		t.Fatalf("ClearMessageContent failed: %v", err)
+	}
+	got, err := repo.GetMessageByID(ctx, msg.UserID, msg.EmailMessageID)
+	if err != nil {
+		t.Fatalf("GetMessageByID failed: %v", err)
+	}
+	if got.Subject != "Hi" || got.Body != "" || got.RawJSON != nil {
+		t.Errorf("expected only metadata to remain, got %+v", got)
+	}
+
+	// Writes for a metadata-only user drop the content whatever the caller passes
+	if err := settings.Upsert(ctx, &models.UserSettings{UserID: msg.UserID, MetadataOnlyCache: true}); err != nil {
+		t.Fatalf("settings Upsert failed: %v", err)
+	}
+	if err := repo.UpsertMessage(ctx, msg); err != nil {
+		t.Fatalf("UpsertMessage (metadata only) failed: %v", err)
+	}
+	got, err = repo.GetMessageByID(ctx, msg.UserID, msg.EmailMessageID)
+	if err != nil {
+		t.Fatalf("GetMessageByID failed: %v", err)
+	}
+	if got.Subject != "Hi" || got.Body != "" || got.RawJSON != nil {
+		t.Errorf("expected the body and payload not to be stored, got %+v", got)
+	}
+}
+
+func TestEmailMessageRepository_PruneRawJSON(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewEmailMessageRepositoryFromPool(db.Pool)
+	pruner := NewMessageBackfillerFromPool(db.Pool)
+	ctx := context.Background()
+
+	// Cached before headers were stored apart: only the raw payload has them
+	raw := []byte(`{"id":"msg-1","payload":{"headers":[{"name":"Subject","value":"Hi"}]}}`)
+	msg := &models.EmailMessage{UserID: "user-uuid-1", EmailMessageID: "msg-1", CachedAt: time.Now(), RawJSON: raw}
+	if err := repo.UpsertMessage(ctx, msg); err != nil {
+		t.Fatalf("UpsertMessage failed: %v", err)
+	}
+	got, err := repo.GetMessageByID(ctx, msg.UserID, msg.EmailMessageID)
+	if err != nil {
+		t.Fatalf("GetMessageByID failed: %v", err)
+	}
+	if got.Headers != nil || got.Header("subject") != "Hi" {
+		t.Errorf("expected the header from the raw payload, got %+v", got)
+	}
+
+	n, err := pruner.PruneRawJSON(ctx, 10)
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 message pruned, got %d (%v)", n, err)
+	}
+	got, err = repo.GetMessageByID(ctx, msg.UserID, msg.EmailMessageID)
+	if err != nil {
+		t.Fatalf("GetMessageByID failed: %v", err)
+	}
+	if got.RawJSON != nil || got.Header("Subject") != "Hi" {
+		t.Errorf("expected the payload dropped and the header kept, got %+v", got)
+	}
+	if n, err := pruner.PruneRawJSON(ctx, 10); err != nil || n != 0 {
+		t.Errorf("expected nothing left to prune, got %d (%v)", n, err)
+	}
+}
+
+func TestEmailMessageRepository_CompressedBodies(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewEmailMessageRepositoryFromPool(db.Pool)
+	backfills := NewMessageBackfillerFromPool(db.Pool)
+	ctx := context.Background()
+
+	html := newsletterHTML(20)
+	msg := &models.EmailMessage{UserID: "user-uuid-1", EmailMessageID: "msg-1", Body: "plain", HTMLBody: html, CachedAt: time.Now()}
+	if err := repo.UpsertMessage(ctx, msg); err != nil {
+		t.Fatalf("UpsertMessage failed: %v", err)
+	}
+	got, err := repo.GetMessageByID(ctx, msg.UserID, msg.EmailMessageID)
+	if err != nil {
+		t.Fatalf("GetMessageByID failed: %v", err)
+	}
+	if got.Body != "plain" || got.HTMLBody != html {
+		t.Errorf("expected bodies to round trip, got %q and %d bytes of HTML", got.Body, len(got.HTMLBody))
+	}
+
+	// A large body stored raw, as the migration converts existing rows, is compressed by the backfill
+	legacy := append([]byte{codecRaw}, html...)
+	if _, err := db.Pool.Exec(ctx, `UPDATE email_messages SET body=$1 WHERE email_message_id='msg-1'`, legacy); err != nil {
+		t.Fatalf("storing a legacy body failed: %v", err)
+	}
+	if n, err := backfills.CompressBodies(ctx, 10); err != nil || n != 1 {
+		t.Fatalf("expected 1 message compressed, got %d (%v)", n, err)
+	}
+	var stored []byte
+	if err := db.Pool.QueryRow(ctx, `SELECT body FROM email_messages WHERE email_message_id='msg-1'`).Scan(&stored); err != nil {
+		t.Fatalf("reading the stored body failed: %v", err)
+	}
+	if stored[0] != codecGzip || len(stored) >= len(legacy) {
+		t.Errorf("expected a smaller gzipped body, got codec %d and %d bytes", stored[0], len(stored))
+	}
+	if got, err := repo.GetMessageByID(ctx, msg.UserID, msg.EmailMessageID); err != nil || got.Body != html {
+		t.Errorf("expected the compressed body to decode, got %v", err)
+	}
+	if n, err := backfills.CompressBodies(ctx, 10); err != nil || n != 0 {
+		t.Errorf("expected nothing left to compress, got %d (%v)", n, err)
+	}
+}
+
+func TestEmailMessageRepository_ParseSenders(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewEmailMessageRepositoryFromPool(db.Pool)
+	backfills := NewMessageBackfillerFromPool(db.Pool)
+	ctx := context.Background()
+
+	msg := &models.EmailMessage{UserID: "user-uuid-1", EmailMessageID: "msg-1", Sender: "Ann Lee <Ann@Example.com>", CachedAt: time.Now()}
+	if err := repo.UpsertMessage(ctx, msg); err != nil {
+		t.Fatalf("UpsertMessage failed: %v", err)
+	}
+	var address, name *string
+	if err := db.Pool.QueryRow(ctx, `SELECT sender_address, sender_name FROM email_messages WHERE email_message_id='msg-1'`).Scan(&address, &name); err != nil {
+		t.Fatalf("reading the sender failed: %v", err)
+	}
+	if address == nil || *address != "ann@example.com" || name == nil || *name != "Ann Lee" {
+		t.Errorf("expected the sender parsed on write, got %v and %v", address, name)
+	}
+
+	// Cached before senders were parsed
+	if _, err := db.Pool.Exec(ctx, `UPDATE email_messages SET sender_address=NULL, sender_name=NULL`); err != nil {
+		t.Fatalf("clearing the parsed sender failed: %v", err)
+	}
+	if got, err := repo.GetMessageByID(ctx, msg.UserID, msg.EmailMessageID); err != nil || got.SenderAddress != "ann@example.com" {
+		t.Errorf("expected reads to parse an unparsed sender, got %+v (%v)", got, err)
+	}
+	if n, err := backfills.ParseSenders(ctx, 10); err != nil || n != 1 {
+		t.Fatalf("expected 1 sender parsed, got %d (%v)", n, err)
+	}
+	if n, err := backfills.ParseSenders(ctx, 10); err != nil || n != 0 {
+		t.Errorf("expected nothing left to parse, got %d (%v)", n, err)
+	}
+}
+
+// benchMessage is a synced message of typical size; n orders messages by internal date
+func benchMessage(userID string, n int) *models.EmailMessage {
+	return &models.EmailMessage{
+		UserID:         userID,
+		EmailMessageID: fmt.Sprintf("msg-%06d", n),
+		ThreadID:       fmt.Sprintf("thread-%06d", n/3),
+		Subject:        "Weekly roundup",
+		Sender:         "News <news@example.com>",
+		Recipient:      "me@example.com",
+		Snippet:        "The stories you missed this week",
+		Body:           strings.Repeat("Weekly roundup: the stories you missed this week. ", 40),
+		HTMLBody:       newsletterHTML(10),
+		InternalDate:   1700000000000 + int64(n)*1000,
+		HistoryID:      int64(n),
+		CachedAt:       time.Now(),
+	}
+}
+
+// seedMessages caches n messages for userID in one transaction
+func seedMessages(b *testing.B, db *DB, userID string, n int) {
+	b.Helper()
+	err := db.WithTx(context.Background(), func(tx *Tx) error {
+		for i := 0; i < n; i++ {
+			if err := tx.Messages.UpsertMessage(context.Background(), benchMessage(userID, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatalf("seeding messages failed: %v", err)
+	}
+}
+
+// BenchmarkGetMessagesForUserCursor pages through a 5,000 message mailbox next to another
+// user's, from the top and from 4,000 messages in
+func BenchmarkGetMessagesForUserCursor(b *testing.B) {
+	db, cleanup := SetupTestDB(b)
+	defer cleanup()
+	seedMessages(b, db, "bench-user", 5000)
+	seedMessages(b, db, "other-user", 5000)
+	repo := NewEmailMessageRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	deep := benchMessage("bench-user", 1000)
+	for name, cursor := range map[string]*models.EmailMessage{"first-page": {}, "deep-page": deep} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				msgs, err := repo.GetMessagesForUserCursor(ctx, "bench-user", 50, cursor.InternalDate, cursor.EmailMessageID)
+				if err != nil || len(msgs) != 50 {
+					b.Fatalf("got %d messages, err %v", len(msgs), err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkUpsertPage writes sync pages of 100 messages in one transaction each, as syncPage
+// does, both as new messages and as re-syncs of cached ones
+func BenchmarkUpsertPage(b *testing.B) {
+	db, cleanup := SetupTestDB(b)
+	defer cleanup()
+	ctx := context.Background()
+	const pageSize = 100
+	upsertPage := func(b *testing.B, first int) {
+		err := db.WithTx(ctx, func(tx *Tx) error {
+			for j := 0; j < pageSize; j++ {
+				if err := tx.Messages.UpsertMessage(ctx, benchMessage("bench-user", first+j)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	// IDs past the update page, never reused across the runs of the benchmark
+	next := pageSize
+	b.Run("insert", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			upsertPage(b, next)
+			next += pageSize
+		}
+		b.ReportMetric(float64(b.N*pageSize)/b.Elapsed().Seconds(), "msgs/s")
+	})
+	b.Run("update", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			upsertPage(b, 0)
+		}
+		b.ReportMetric(float64(b.N*pageSize)/b.Elapsed().Seconds(), "msgs/s")
+	})
+}
+
+func TestMessageCounter(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewEmailMessageRepositoryFromPool(db.Pool)
+	counter := NewMessageCounterFromPool(db.Pool)
+	ctx := context.Background()
+
+	for _, m := range []*models.EmailMessage{
+		{EmailMessageID: "m1", ThreadID: "t1"},
+		{EmailMessageID: "m2", ThreadID: "t1"},
+		{EmailMessageID: "m3", ThreadID: "t2"},
+		{EmailMessageID: "m4"},
+	} {
+		m.UserID, m.CachedAt = "user-uuid-1", time.Now()
+		if err := repo.UpsertMessage(ctx, m); err != nil {
+			t.Fatalf("UpsertMessage failed: %v", err)
+		}
+	}
+	if n, err := counter.CountMessages(ctx, "user-uuid-1"); err != nil || n != 4 {
+		t.Errorf("expected 4 messages, got %d (%v)", n, err)
+	}
+	if n, err := counter.CountThreads(ctx, "user-uuid-1"); err != nil || n != 2 {
+		t.Errorf("expected 2 threads, got %d (%v)", n, err)
+	}
+	if n, err := counter.CountMessages(ctx, "someone-else"); err != nil || n != 0 {
+		t.Errorf("expected no messages for another user, got %d (%v)", n, err)
+	}
+}
@@ -0,0 +1,68 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EmailOverrideRepository stores the changes users make to individual messages
+type EmailOverrideRepository interface {
+	// Apply merges update into the message's override, creating it if needed, and returns the
+	// result. Label names are removed whatever their case.
+	Apply(ctx context.Context, userID, emailMessageID string, update models.EmailUpdate) (*models.EmailUserOverride, error)
+	// Get returns ErrNotFound if the user never changed the message
+	Get(ctx context.Context, userID, emailMessageID string) (*models.EmailUserOverride, error)
+}
+
+type emailOverrideRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewEmailOverrideRepositoryFromPool creates an EmailOverrideRepository using a pgxpool.Pool
+func NewEmailOverrideRepositoryFromPool(pool *pgxpool.Pool) EmailOverrideRepository {
+	return &emailOverrideRepository{pool: pool}
+}
+
+func (r *emailOverrideRepository) Apply(ctx context.Context, userID, emailMessageID string, update models.EmailUpdate) (*models.EmailUserOverride, error) {
+	labels := update.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+	removed := make([]string, len(update.RemoveLabels))
+	for i, l := range update.RemoveLabels {
+		removed[i] = strings.ToLower(l)
+	}
+	o := &models.EmailUserOverride{UserID: userID, EmailMessageID: emailMessageID}
+	err := r.pool.QueryRow(ctx, `INSERT INTO email_user_overrides (user_id, email_message_id, labels, category, starred)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, email_message_id) DO UPDATE SET
+			labels = ARRAY(SELECT DISTINCT l FROM unnest(email_user_overrides.labels || EXCLUDED.labels) AS l
+				WHERE lower(l) <> ALL($6::text[]) ORDER BY l),
+			category = COALESCE(EXCLUDED.category, email_user_overrides.category),
+			starred = COALESCE(EXCLUDED.starred, email_user_overrides.starred),
+			updated_at = NOW()
+		RETURNING labels, category, starred, updated_at`,
+		userID, emailMessageID, labels, update.Category, update.Starred, removed).Scan(&o.Labels, &o.Category, &o.Starred, &o.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (r *emailOverrideRepository) Get(ctx context.Context, userID, emailMessageID string) (*models.EmailUserOverride, error) {
+	o := &models.EmailUserOverride{UserID: userID, EmailMessageID: emailMessageID}
+	err := r.pool.QueryRow(ctx, `SELECT labels, category, starred, updated_at FROM email_user_overrides
+		WHERE user_id=$1 AND email_message_id=$2`, userID, emailMessageID).Scan(&o.Labels, &o.Category, &o.Starred, &o.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
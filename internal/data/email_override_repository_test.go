@@ -0,0 +1,48 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestEmailOverrideRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewEmailOverrideRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "override-user-1"
+	if err := db.Create(ctx, &models.User{ID: userID, Email: "override@example.com", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+
+	if _, err := repo.Get(ctx, userID, "m1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound before any update, got %v", err)
+	}
+	category, starred := "Updates", true
+	if _, err := repo.Apply(ctx, userID, "m1", models.EmailUpdate{Labels: []string{"Work"}, Category: &category, Starred: &starred}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	// A later update adds labels and keeps the fields it leaves out
+	o, err := repo.Apply(ctx, userID, "m1", models.EmailUpdate{Labels: []string{"Receipts", "Work"}})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(o.Labels) != 2 || o.Labels[0] != "Receipts" || o.Labels[1] != "Work" {
+		t.Errorf("expected merged labels, got %v", o.Labels)
+	}
+	if o.Category == nil || *o.Category != "Updates" || o.Starred == nil || !*o.Starred {
+		t.Errorf("expected category and starred to be kept, got %+v", o)
+	}
+	got, err := repo.Get(ctx, userID, "m1")
+	if err != nil || len(got.Labels) != 2 || *got.Category != "Updates" {
+		t.Errorf("unexpected override %+v (err %v)", got, err)
+	}
+	// Removed labels are matched whatever their case
+	if o, err = repo.Apply(ctx, userID, "m1", models.EmailUpdate{RemoveLabels: []string{"work"}}); err != nil || len(o.Labels) != 1 || o.Labels[0] != "Receipts" {
+		t.Errorf("expected Work removed, got %+v (err %v)", o, err)
+	}
+}
@@ -0,0 +1,62 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EncryptionKeyRepository stores the public half of users' cache encryption keys
+type EncryptionKeyRepository interface {
+	// Get returns the user's key, or ErrNotFound if the cache is not encrypted
+	Get(ctx context.Context, userID string) (*models.EncryptionKey, error)
+	// Create stores a key; returns ErrAlreadyExists if the user has one
+	Create(ctx context.Context, key *models.EncryptionKey) error
+	// Delete removes the user's key; returns ErrNotFound if there is none
+	Delete(ctx context.Context, userID string) error
+}
+
+type encryptionKeyRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewEncryptionKeyRepositoryFromPool creates an EncryptionKeyRepository using a pgxpool.Pool
+func NewEncryptionKeyRepositoryFromPool(pool *pgxpool.Pool) EncryptionKeyRepository {
+	return &encryptionKeyRepository{pool: pool}
+}
+
+func (r *encryptionKeyRepository) Get(ctx context.Context, userID string) (*models.EncryptionKey, error) {
+	k := &models.EncryptionKey{UserID: userID}
+	err := r.pool.QueryRow(ctx, `SELECT salt, public_key, created_at FROM user_encryption_keys WHERE user_id=$1`, userID).
+		Scan(&k.Salt, &k.PublicKey, &k.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (r *encryptionKeyRepository) Create(ctx context.Context, k *models.EncryptionKey) error {
+	err := r.pool.QueryRow(ctx, `INSERT INTO user_encryption_keys (user_id, salt, public_key) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO NOTHING RETURNING created_at`, k.UserID, k.Salt, k.PublicKey).Scan(&k.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+func (r *encryptionKeyRepository) Delete(ctx context.Context, userID string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM user_encryption_keys WHERE user_id=$1`, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestEncryptionKeyRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewEncryptionKeyRepositoryFromPool(db.Pool)
+	settings := NewUserSettingsRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	user := &models.User{ID: "user-e2ee-1", Email: "e2ee@example.com", CreatedAt: time.Now()}
+	if err := db.Create(ctx, user); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+
+	if _, err := repo.Get(ctx, user.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	key := &models.EncryptionKey{UserID: user.ID, Salt: []byte("salt"), PublicKey: []byte("public")}
+	if err := repo.Create(ctx, key); err != nil || key.CreatedAt.IsZero() {
+		t.Fatalf("Create = %v, %+v", err, key)
+	}
+	if err := repo.Create(ctx, key); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+	got, err := repo.Get(ctx, user.ID)
+	if err != nil || string(got.Salt) != "salt" || string(got.PublicKey) != "public" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	if s, err := settings.Get(ctx, user.ID); err != nil || !s.EncryptedCache {
+		t.Errorf("expected settings to report the encrypted cache, got %+v, %v", s, err)
+	}
+
+	if err := repo.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := repo.Delete(ctx, user.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound on second delete, got %v", err)
+	}
+	if s, err := settings.Get(ctx, user.ID); err != nil || s.EncryptedCache {
+		t.Errorf("expected the encrypted cache to be off, got %+v, %v", s, err)
+	}
+}
@@ -0,0 +1,13 @@
+package data
+
+import "errors"
+
+// ErrNotFound is returned by repositories when the requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrAlreadyExists is returned by repositories when a row that may exist only once already does
+var ErrAlreadyExists = errors.New("already exists")
+
+// ErrConflict is returned by repositories when a write was based on a version of the row that is
+// no longer current
+var ErrConflict = errors.New("version conflict")
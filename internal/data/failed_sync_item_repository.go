@@ -0,0 +1,76 @@
+package data
+
+import (
+	"context"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// FailedSyncItemRepository stores messages that failed to sync (dead-letter queue)
+type FailedSyncItemRepository interface {
+	RecordFailure(ctx context.Context, userID, emailMessageID, stage, errMsg string) error
+	ListRetryable(ctx context.Context, userID string, maxAttempts, limit int) ([]*models.FailedSyncItem, error)
+	ListExhausted(ctx context.Context, userID string, maxAttempts int) ([]*models.FailedSyncItem, error)
+	Resolve(ctx context.Context, userID, emailMessageID string) error
+}
+
+type failedSyncItemRepository struct {
+	pool querier
+}
+
+// NewFailedSyncItemRepositoryFromPool creates a FailedSyncItemRepository using a pgxpool.Pool
+func NewFailedSyncItemRepositoryFromPool(pool *pgxpool.Pool) FailedSyncItemRepository {
+	return &failedSyncItemRepository{pool: pool}
+}
+
+// RecordFailure inserts a failure or bumps the attempt count of an existing one
+func (r *failedSyncItemRepository) RecordFailure(ctx context.Context, userID, emailMessageID, stage, errMsg string) error {
+	_, err := r.pool.Exec(ctx, `INSERT INTO failed_sync_items (user_id, email_message_id, stage, last_error)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, email_message_id) DO UPDATE SET
+		stage = EXCLUDED.stage,
+		last_error = EXCLUDED.last_error,
+		attempts = failed_sync_items.attempts + 1,
+		last_failed_at = NOW()`,
+		userID, emailMessageID, stage, errMsg,
+	)
+	return err
+}
+
+// ListRetryable returns failures that have not yet used up their attempts, oldest first
+func (r *failedSyncItemRepository) ListRetryable(ctx context.Context, userID string, maxAttempts, limit int) ([]*models.FailedSyncItem, error) {
+	return r.list(ctx, `SELECT id, user_id, email_message_id, stage, last_error, attempts, first_failed_at, last_failed_at
+		FROM failed_sync_items WHERE user_id=$1 AND attempts < $2 ORDER BY last_failed_at ASC LIMIT $3`,
+		userID, maxAttempts, limit)
+}
+
+// ListExhausted returns failures that will no longer be retried automatically
+func (r *failedSyncItemRepository) ListExhausted(ctx context.Context, userID string, maxAttempts int) ([]*models.FailedSyncItem, error) {
+	return r.list(ctx, `SELECT id, user_id, email_message_id, stage, last_error, attempts, first_failed_at, last_failed_at
+		FROM failed_sync_items WHERE user_id=$1 AND attempts >= $2 ORDER BY last_failed_at DESC`,
+		userID, maxAttempts)
+}
+
+// Resolve removes a failure once the message has been cached successfully
+func (r *failedSyncItemRepository) Resolve(ctx context.Context, userID, emailMessageID string) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM failed_sync_items WHERE user_id=$1 AND email_message_id=$2`, userID, emailMessageID)
+	return err
+}
+
+func (r *failedSyncItemRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.FailedSyncItem, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*models.FailedSyncItem
+	for rows.Next() {
+		var item models.FailedSyncItem
+		if err := rows.Scan(&item.ID, &item.UserID, &item.EmailMessageID, &item.Stage, &item.LastError, &item.Attempts, &item.FirstFailedAt, &item.LastFailedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
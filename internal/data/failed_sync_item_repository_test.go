@@ -0,0 +1,52 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestFailedSyncItemRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewFailedSyncItemRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "user-failed-1"
+
+	// First failure creates the row, second bumps attempts
+	if err := repo.RecordFailure(ctx, userID, "msg-1", models.SyncStageFetch, "timeout"); err != nil {
+		t.Fatalf("RecordFailure failed: %v", err)
+	}
+	if err := repo.RecordFailure(ctx, userID, "msg-1", models.SyncStageUpsert, "pool exhausted"); err != nil {
+		t.Fatalf("RecordFailure (repeat) failed: %v", err)
+	}
+
+	items, err := repo.ListRetryable(ctx, userID, 3, 10)
+	if err != nil {
+		t.Fatalf("ListRetryable failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Attempts != 2 || items[0].Stage != models.SyncStageUpsert {
+		t.Fatalf("unexpected retryable items: %+v", items)
+	}
+
+	// With a cap of 2 the item is exhausted rather than retryable
+	exhausted, err := repo.ListExhausted(ctx, userID, 2)
+	if err != nil {
+		t.Fatalf("ListExhausted failed: %v", err)
+	}
+	if len(exhausted) != 1 || exhausted[0].LastError != "pool exhausted" {
+		t.Fatalf("unexpected exhausted items: %+v", exhausted)
+	}
+
+	if err := repo.Resolve(ctx, userID, "msg-1"); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	items, err = repo.ListRetryable(ctx, userID, 3, 10)
+	if err != nil {
+		t.Fatalf("ListRetryable after resolve failed: %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("expected no items after resolve, got %d", len(items))
+	}
+}
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// FeedRepository stores sender feeds by the hash of their token, and reads the messages they render
+type FeedRepository interface {
+	// Create stores feed under tokenHash and fills in its ID and CreatedAt. Returns ErrAlreadyExists
+	// if the user already has a feed for the sender.
+	Create(ctx context.Context, feed *models.Feed, tokenHash string) error
+	// ListByUser returns the user's feeds without their content, oldest first
+	ListByUser(ctx context.Context, userID string) ([]*models.Feed, error)
+	// Delete returns ErrNotFound if the user has no feed with the ID
+	Delete(ctx context.Context, userID string, id int64) error
+	// GetByToken returns the feed with tokenHash, including its content, or ErrNotFound
+	GetByToken(ctx context.Context, tokenHash string) (*models.Feed, error)
+	// SaveContent stores a feed's rendered document
+	SaveContent(ctx context.Context, id int64, content []byte, generatedAt time.Time) error
+	// SenderMessages returns the user's most recent cached messages from senderAddress, newest first
+	SenderMessages(ctx context.Context, userID, senderAddress string, limit int) ([]*models.EmailMessage, error)
+}
+
+type feedRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewFeedRepositoryFromPool creates a FeedRepository using a pgxpool.Pool
+func NewFeedRepositoryFromPool(pool *pgxpool.Pool) FeedRepository {
+	return &feedRepository{pool: pool}
+}
+
+func (r *feedRepository) Create(ctx context.Context, feed *models.Feed, tokenHash string) error {
+	err := r.pool.QueryRow(ctx, `INSERT INTO feeds (user_id, sender_address, token_hash) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, sender_address) DO NOTHING RETURNING id, created_at`,
+		feed.UserID, feed.SenderAddress, tokenHash).Scan(&feed.ID, &feed.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+func (r *feedRepository) ListByUser(ctx context.Context, userID string) ([]*models.Feed, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id, user_id, sender_address, generated_at, created_at FROM feeds
+		WHERE user_id=$1 ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*models.Feed
+	for rows.Next() {
+		var f models.Feed
+		if err := rows.Scan(&f.ID, &f.UserID, &f.SenderAddress, &f.GeneratedAt, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &f)
+	}
+	return out, rows.Err()
+}
+
+func (r *feedRepository) Delete(ctx context.Context, userID string, id int64) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM feeds WHERE user_id=$1 AND id=$2`, userID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *feedRepository) GetByToken(ctx context.Context, tokenHash string) (*models.Feed, error) {
+	var f models.Feed
+	err := r.pool.QueryRow(ctx, `SELECT id, user_id, sender_address, content, generated_at, created_at FROM feeds
+		WHERE token_hash=$1`, tokenHash).Scan(&f.ID, &f.UserID, &f.SenderAddress, &f.Content, &f.GeneratedAt, &f.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r *feedRepository) SaveContent(ctx context.Context, id int64, content []byte, generatedAt time.Time) error {
+	_, err := r.pool.Exec(ctx, `UPDATE feeds SET content=$2, generated_at=$3 WHERE id=$1`, id, content, generatedAt)
+	return err
+}
+
+func (r *feedRepository) SenderMessages(ctx context.Context, userID, senderAddress string, limit int) ([]*models.EmailMessage, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+messageColumns+` FROM email_messages
+		WHERE user_id=$1 AND sender_address=$2 ORDER BY internal_date DESC, email_message_id DESC LIMIT $3`,
+		userID, senderAddress, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var msgs []*models.EmailMessage
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, rows.Err()
+}
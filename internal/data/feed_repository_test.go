@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestFeedRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewFeedRepositoryFromPool(db.Pool)
+	messages := NewEmailMessageRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	user := &models.User{ID: "user-feed-1", Email: "feed@example.com", CreatedAt: time.Now()}
+	if err := db.Create(ctx, user); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+	for i, sender := range []string{"News <news@example.com>", "other@example.com", "news@example.com"} {
+		msg := &models.EmailMessage{UserID: user.ID, EmailMessageID: string(rune('a' + i)), Sender: sender, Subject: "s",
+			InternalDate: int64(i+1) * 1000, CachedAt: time.Now()}
+		msg.ParseSender()
+		if err := messages.UpsertMessage(ctx, msg); err != nil {
+			t.Fatalf("UpsertMessage failed: %v", err)
+		}
+	}
+
+	feed := &models.Feed{UserID: user.ID, SenderAddress: "news@example.com"}
+	if err := repo.Create(ctx, feed, "hash1"); err != nil || feed.ID == 0 {
+		t.Fatalf("Create = %v, %+v", err, feed)
+	}
+	if err := repo.Create(ctx, &models.Feed{UserID: user.ID, SenderAddress: "news@example.com"}, "hash2"); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	msgs, err := repo.SenderMessages(ctx, user.ID, "news@example.com", 10)
+	if err != nil || len(msgs) != 2 || msgs[0].EmailMessageID != "c" {
+		t.Fatalf("SenderMessages = %+v, %v", msgs, err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := repo.SaveContent(ctx, feed.ID, []byte("<feed/>"), now); err != nil {
+		t.Fatalf("SaveContent failed: %v", err)
+	}
+	got, err := repo.GetByToken(ctx, "hash1")
+	if err != nil || string(got.Content) != "<feed/>" || got.GeneratedAt == nil {
+		t.Fatalf("GetByToken = %+v, %v", got, err)
+	}
+	if _, err := repo.GetByToken(ctx, "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if list, err := repo.ListByUser(ctx, user.ID); err != nil || len(list) != 1 || list[0].Content != nil {
+		t.Errorf("ListByUser = %+v, %v", list, err)
+	}
+
+	if err := repo.Delete(ctx, user.ID, feed.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := repo.Delete(ctx, user.ID, feed.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound on second delete, got %v", err)
+	}
+}
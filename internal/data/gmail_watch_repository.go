@@ -0,0 +1,107 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// GmailWatchRepository stores the Gmail push notification watch of each user's default Gmail
+// account (the one syncs read, see GetUserToken)
+type GmailWatchRepository interface {
+	// SaveWatch inserts or replaces the user's watch; UpdatedAt is filled in
+	SaveWatch(ctx context.Context, w *models.GmailWatch) error
+	// FindWatchByAccount returns the watch on a Gmail address, compared case-insensitively.
+	// Returns ErrNotFound if no user has a watch on it.
+	FindWatchByAccount(ctx context.Context, accountID string) (*models.GmailWatch, error)
+	// ListDueWatches returns up to limit watches to register: users with a Gmail token and no
+	// watch, a watch due for renewal at now, or a watch on an account that is no longer their
+	// default. AccountID is the default account; the other fields are the stored watch's.
+	ListDueWatches(ctx context.Context, now time.Time, limit int) ([]models.GmailWatch, error)
+	// PendingWatches counts the watches ListDueWatches would return and the earliest renewal
+	// time among those that were registered before
+	PendingWatches(ctx context.Context, now time.Time) (int, *time.Time, error)
+}
+
+type gmailWatchRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewGmailWatchRepositoryFromPool creates a GmailWatchRepository using a pgxpool.Pool
+func NewGmailWatchRepositoryFromPool(pool *pgxpool.Pool) GmailWatchRepository {
+	return &gmailWatchRepository{pool: pool}
+}
+
+// dueWatches joins each user's default Gmail account to their watch, keeping those due at $1
+const dueWatches = `FROM (SELECT DISTINCT ON (user_id) user_id, account_id FROM user_tokens
+		WHERE provider = 'gmail' ORDER BY user_id, created_at, account_id) t
+	LEFT JOIN gmail_watches w ON w.user_id = t.user_id
+	WHERE w.user_id IS NULL OR w.renew_after <= $1 OR w.account_id <> t.account_id`
+
+func (r *gmailWatchRepository) SaveWatch(ctx context.Context, w *models.GmailWatch) error {
+	return r.pool.QueryRow(ctx, `INSERT INTO gmail_watches
+		(user_id, account_id, history_id, expires_at, renew_after, error, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+		account_id = EXCLUDED.account_id,
+		history_id = EXCLUDED.history_id,
+		expires_at = EXCLUDED.expires_at,
+		renew_after = EXCLUDED.renew_after,
+		error = EXCLUDED.error,
+		updated_at = NOW()
+		RETURNING updated_at`,
+		w.UserID, w.AccountID, w.HistoryID, utcOrNil(w.ExpiresAt), w.RenewAfter.UTC(), w.Error,
+	).Scan(&w.UpdatedAt)
+}
+
+func (r *gmailWatchRepository) FindWatchByAccount(ctx context.Context, accountID string) (*models.GmailWatch, error) {
+	w := &models.GmailWatch{}
+	err := r.pool.QueryRow(ctx, `SELECT user_id, account_id, history_id, expires_at, renew_after, error, updated_at
+		FROM gmail_watches WHERE LOWER(account_id) = LOWER($1)
+		ORDER BY updated_at DESC LIMIT 1`, accountID).
+		Scan(&w.UserID, &w.AccountID, &w.HistoryID, &w.ExpiresAt, &w.RenewAfter, &w.Error, &w.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (r *gmailWatchRepository) ListDueWatches(ctx context.Context, now time.Time, limit int) ([]models.GmailWatch, error) {
+	rows, err := r.pool.Query(ctx, `SELECT t.user_id, t.account_id, COALESCE(w.history_id, 0), w.expires_at,
+		w.renew_after, COALESCE(w.error, ''), w.updated_at `+dueWatches+`
+		ORDER BY w.renew_after NULLS FIRST, t.user_id LIMIT $2`, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.GmailWatch{}
+	for rows.Next() {
+		var w models.GmailWatch
+		var renewAfter, updatedAt *time.Time
+		if err := rows.Scan(&w.UserID, &w.AccountID, &w.HistoryID, &w.ExpiresAt, &renewAfter, &w.Error, &updatedAt); err != nil {
+			return nil, err
+		}
+		if renewAfter != nil {
+			w.RenewAfter = *renewAfter
+		}
+		if updatedAt != nil {
+			w.UpdatedAt = *updatedAt
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+func (r *gmailWatchRepository) PendingWatches(ctx context.Context, now time.Time) (int, *time.Time, error) {
+	var n int
+	var oldest *time.Time
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*), MIN(w.renew_after) `+dueWatches, now.UTC()).Scan(&n, &oldest)
+	return n, oldest, err
+}
@@ -0,0 +1,62 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"golang.org/x/oauth2"
+)
+
+func TestGmailWatchRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewGmailWatchRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	for _, id := range []string{"user-watch-1", "user-watch-2"} {
+		if err := db.Create(ctx, &models.User{ID: id, Email: id + "@example.com", CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Create user failed: %v", err)
+		}
+		if err := db.SaveUserToken(ctx, id, ProviderGmail, id+"@gmail.com", &oauth2.Token{AccessToken: "tok"}); err != nil {
+			t.Fatalf("SaveUserToken failed: %v", err)
+		}
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// Users with a Gmail token and no watch are due
+	due, err := repo.ListDueWatches(ctx, now, 10)
+	if err != nil || len(due) != 2 || due[0].AccountID != "user-watch-1@gmail.com" || !due[0].RenewAfter.IsZero() {
+		t.Fatalf("ListDueWatches = %+v, %v", due, err)
+	}
+	if n, oldest, err := repo.PendingWatches(ctx, now); err != nil || n != 2 || oldest != nil {
+		t.Fatalf("PendingWatches = %d, %v, %v", n, oldest, err)
+	}
+
+	expires := now.Add(7 * 24 * time.Hour)
+	w := &models.GmailWatch{UserID: "user-watch-1", AccountID: "user-watch-1@gmail.com", HistoryID: 42,
+		ExpiresAt: &expires, RenewAfter: expires.Add(-24 * time.Hour)}
+	if err := repo.SaveWatch(ctx, w); err != nil || w.UpdatedAt.IsZero() {
+		t.Fatalf("SaveWatch failed: %v", err)
+	}
+	failed := &models.GmailWatch{UserID: "user-watch-2", AccountID: "user-watch-2@gmail.com", RenewAfter: now.Add(-time.Minute), Error: "forbidden"}
+	if err := repo.SaveWatch(ctx, failed); err != nil {
+		t.Fatalf("SaveWatch failed: %v", err)
+	}
+	due, err = repo.ListDueWatches(ctx, now, 10)
+	if err != nil || len(due) != 1 || due[0].UserID != "user-watch-2" || due[0].Error != "forbidden" {
+		t.Fatalf("ListDueWatches after saving = %+v, %v", due, err)
+	}
+	if n, oldest, err := repo.PendingWatches(ctx, now); err != nil || n != 1 || oldest == nil || !oldest.Equal(failed.RenewAfter) {
+		t.Fatalf("PendingWatches = %d, %v, %v", n, oldest, err)
+	}
+
+	got, err := repo.FindWatchByAccount(ctx, "User-Watch-1@Gmail.com")
+	if err != nil || got.UserID != "user-watch-1" || got.HistoryID != 42 || got.ExpiresAt == nil || !got.ExpiresAt.Equal(expires) {
+		t.Fatalf("FindWatchByAccount = %+v, %v", got, err)
+	}
+	if _, err := repo.FindWatchByAccount(ctx, "nobody@gmail.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
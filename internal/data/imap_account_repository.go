@@ -0,0 +1,80 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ProviderIMAP is the provider name of mailboxes linked over IMAP; it matches service.ProviderIMAP
+const ProviderIMAP = "imap"
+
+// IMAPAccountRepository stores each user's IMAP mailbox, with the password encrypted at rest
+type IMAPAccountRepository interface {
+	// Save inserts or replaces the user's account
+	Save(ctx context.Context, account *models.IMAPAccount) error
+	// Get returns the user's account with its password decrypted, or ErrNotFound. The error
+	// wraps ErrCredentialUnreadable if the password cannot be decrypted.
+	Get(ctx context.Context, userID string) (*models.IMAPAccount, error)
+	// Delete removes the user's account, or returns ErrNotFound
+	Delete(ctx context.Context, userID string) error
+}
+
+type imapAccountRepository struct {
+	pool   *pgxpool.Pool
+	cipher *CredentialCipher
+}
+
+// NewIMAPAccountRepositoryFromPool creates an IMAPAccountRepository using a pgxpool.Pool;
+// passwords are encrypted with cipher
+func NewIMAPAccountRepositoryFromPool(pool *pgxpool.Pool, cipher *CredentialCipher) IMAPAccountRepository {
+	return &imapAccountRepository{pool: pool, cipher: cipher}
+}
+
+func (r *imapAccountRepository) Save(ctx context.Context, a *models.IMAPAccount) error {
+	sealed, err := r.cipher.Seal(a.UserID, []byte(a.Password))
+	if err != nil {
+		return err
+	}
+	return r.pool.QueryRow(ctx, `INSERT INTO imap_accounts (user_id, host, port, tls, username, password)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET host=EXCLUDED.host, port=EXCLUDED.port, tls=EXCLUDED.tls,
+			username=EXCLUDED.username, password=EXCLUDED.password, updated_at=NOW()
+		RETURNING created_at, updated_at`,
+		a.UserID, a.Host, a.Port, a.TLS, a.Username, sealed,
+	).Scan(&a.CreatedAt, &a.UpdatedAt)
+}
+
+func (r *imapAccountRepository) Get(ctx context.Context, userID string) (*models.IMAPAccount, error) {
+	a := &models.IMAPAccount{UserID: userID}
+	var sealed []byte
+	err := r.pool.QueryRow(ctx, `SELECT host, port, tls, username, password, created_at, updated_at
+		FROM imap_accounts WHERE user_id=$1`, userID).
+		Scan(&a.Host, &a.Port, &a.TLS, &a.Username, &sealed, &a.CreatedAt, &a.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	password, err := r.cipher.Open(userID, sealed)
+	if err != nil {
+		return nil, err
+	}
+	a.Password = string(password)
+	return a, nil
+}
+
+func (r *imapAccountRepository) Delete(ctx context.Context, userID string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM imap_accounts WHERE user_id=$1`, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"golang.org/x/oauth2"
+)
+
+func TestIMAPAccountRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	cipher, err := NewCredentialCipher(bytes.Repeat([]byte{1}, CredentialKeySize))
+	if err != nil {
+		t.Fatalf("NewCredentialCipher failed: %v", err)
+	}
+	repo := NewIMAPAccountRepositoryFromPool(db.Pool, cipher)
+	user := &models.User{ID: "user-imap-1", Email: "imap@example.com", CreatedAt: time.Now()}
+	if err := db.Create(ctx, user); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+	if err := db.SaveUserToken(ctx, user.ID, ProviderGmail, "imap@example.com", &oauth2.Token{AccessToken: "g"}); err != nil {
+		t.Fatalf("SaveUserToken failed: %v", err)
+	}
+
+	if _, err := repo.Get(ctx, user.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	account := &models.IMAPAccount{UserID: user.ID, Host: "imap.example.com", Port: 993, TLS: true, Username: "me", Password: "hunter2"}
+	if err := repo.Save(ctx, account); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	var stored []byte
+	if err := db.Pool.QueryRow(ctx, `SELECT password FROM imap_accounts WHERE user_id=$1`, user.ID).Scan(&stored); err != nil {
+		t.Fatalf("reading the stored password failed: %v", err)
+	}
+	if bytes.Contains(stored, []byte("hunter2")) {
+		t.Error("password is stored in the clear")
+	}
+	got, err := repo.Get(ctx, user.ID)
+	if err != nil || got.Host != "imap.example.com" || got.Port != 993 || !got.TLS || got.Password != "hunter2" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	if linked, err := db.LinkedProviders(ctx, user.ID); err != nil || !reflect.DeepEqual(linked, []string{ProviderGmail, ProviderIMAP}) {
+		t.Errorf("LinkedProviders = %v, %v", linked, err)
+	}
+
+	if err := repo.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := repo.Delete(ctx, user.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// IntegrationDeliveryRepository stores deliveries to send-to integrations; queued rows are the
+// delivery queue
+type IntegrationDeliveryRepository interface {
+	// Create inserts a queued delivery; ID and timestamps are filled in
+	Create(ctx context.Context, delivery *models.IntegrationDelivery) error
+	// Get returns the user's delivery, or ErrNotFound
+	Get(ctx context.Context, userID string, id int64) (*models.IntegrationDelivery, error)
+	// ListByUser returns the user's most recent deliveries, newest first
+	ListByUser(ctx context.Context, userID string, limit int) ([]*models.IntegrationDelivery, error)
+	// ClaimDue marks the oldest queued delivery due at now as running and returns it, or nil when
+	// none is due. Deliveries still running since before abandonedBefore belonged to a worker that
+	// stopped and are claimed again. Concurrent workers never claim the same delivery.
+	ClaimDue(ctx context.Context, now, abandonedBefore time.Time) (*models.IntegrationDelivery, error)
+	// Save writes the delivery's status, attempts, error, link and schedule. The item is cleared
+	// once the delivery has finished.
+	Save(ctx context.Context, delivery *models.IntegrationDelivery) error
+	// Pending returns how many deliveries are queued or running and when the oldest was created
+	Pending(ctx context.Context) (int, *time.Time, error)
+}
+
+type integrationDeliveryRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewIntegrationDeliveryRepositoryFromPool creates an IntegrationDeliveryRepository using a pgxpool.Pool
+func NewIntegrationDeliveryRepositoryFromPool(pool *pgxpool.Pool) IntegrationDeliveryRepository {
+	return &integrationDeliveryRepository{pool: pool}
+}
+
+const deliveryColumns = `id, user_id, message_id, integration, status, attempts, error, external_url, item,
+	next_attempt_at, created_at, updated_at, delivered_at`
+
+func scanDelivery(row pgx.Row) (*models.IntegrationDelivery, error) {
+	var d models.IntegrationDelivery
+	if err := row.Scan(&d.ID, &d.UserID, &d.MessageID, &d.Integration, &d.Status, &d.Attempts, &d.Error,
+		&d.ExternalURL, &d.Item, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt, &d.DeliveredAt); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *integrationDeliveryRepository) Create(ctx context.Context, d *models.IntegrationDelivery) error {
+	if d.Status == "" {
+		d.Status = models.DeliveryQueued
+	}
+	return r.pool.QueryRow(ctx, `INSERT INTO integration_deliveries (user_id, message_id, integration, status, item)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, next_attempt_at, created_at, updated_at`,
+		d.UserID, d.MessageID, d.Integration, d.Status, d.Item,
+	).Scan(&d.ID, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt)
+}
+
+func (r *integrationDeliveryRepository) Get(ctx context.Context, userID string, id int64) (*models.IntegrationDelivery, error) {
+	d, err := scanDelivery(r.pool.QueryRow(ctx, `SELECT `+deliveryColumns+` FROM integration_deliveries
+		WHERE user_id=$1 AND id=$2`, userID, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return d, err
+}
+
+func (r *integrationDeliveryRepository) ListByUser(ctx context.Context, userID string, limit int) ([]*models.IntegrationDelivery, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+deliveryColumns+` FROM integration_deliveries
+		WHERE user_id=$1 ORDER BY id DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*models.IntegrationDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (r *integrationDeliveryRepository) ClaimDue(ctx context.Context, now, abandonedBefore time.Time) (*models.IntegrationDelivery, error) {
+	d, err := scanDelivery(r.pool.QueryRow(ctx, `UPDATE integration_deliveries SET status='running', updated_at=NOW()
+		WHERE id = (SELECT id FROM integration_deliveries
+			WHERE (status='queued' AND next_attempt_at <= $1) OR (status='running' AND updated_at < $2)
+			ORDER BY next_attempt_at, id LIMIT 1 FOR UPDATE SKIP LOCKED)
+		RETURNING `+deliveryColumns, now.UTC(), abandonedBefore.UTC()))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return d, err
+}
+
+func (r *integrationDeliveryRepository) Save(ctx context.Context, d *models.IntegrationDelivery) error {
+	if d.Finished() {
+		d.Item = nil
+	}
+	tag, err := r.pool.Exec(ctx, `UPDATE integration_deliveries SET status=$2, attempts=$3, error=$4, external_url=$5,
+		item=$6, next_attempt_at=$7, delivered_at=$8, updated_at=NOW() WHERE id=$1`,
+		d.ID, d.Status, d.Attempts, d.Error, d.ExternalURL, d.Item, d.NextAttemptAt.UTC(), d.DeliveredAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *integrationDeliveryRepository) Pending(ctx context.Context) (int, *time.Time, error) {
+	var count int
+	var oldest *time.Time
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*), MIN(created_at) FROM integration_deliveries
+		WHERE status IN ('queued', 'running')`).Scan(&count, &oldest)
+	return count, oldest, err
+}
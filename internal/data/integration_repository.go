@@ -0,0 +1,79 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// IntegrationRepository stores users' send-to integration configs
+type IntegrationRepository interface {
+	// Get returns ErrNotFound if the user has not configured the integration
+	Get(ctx context.Context, userID, name string) (*models.Integration, error)
+	// List returns the user's configured integrations by name
+	List(ctx context.Context, userID string) ([]*models.Integration, error)
+	// Upsert creates or replaces the config; CreatedAt and UpdatedAt are filled in
+	Upsert(ctx context.Context, integration *models.Integration) error
+	// Delete returns ErrNotFound if the user has not configured the integration
+	Delete(ctx context.Context, userID, name string) error
+}
+
+type integrationRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewIntegrationRepositoryFromPool creates an IntegrationRepository using a pgxpool.Pool
+func NewIntegrationRepositoryFromPool(pool *pgxpool.Pool) IntegrationRepository {
+	return &integrationRepository{pool: pool}
+}
+
+func (r *integrationRepository) Get(ctx context.Context, userID, name string) (*models.Integration, error) {
+	i := &models.Integration{UserID: userID, Name: name}
+	err := r.pool.QueryRow(ctx, `SELECT config, created_at, updated_at FROM user_integrations
+		WHERE user_id=$1 AND integration=$2`, userID, name).Scan(&i.Config, &i.CreatedAt, &i.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (r *integrationRepository) List(ctx context.Context, userID string) ([]*models.Integration, error) {
+	rows, err := r.pool.Query(ctx, `SELECT integration, config, created_at, updated_at FROM user_integrations
+		WHERE user_id=$1 ORDER BY integration`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*models.Integration
+	for rows.Next() {
+		i := &models.Integration{UserID: userID}
+		if err := rows.Scan(&i.Name, &i.Config, &i.CreatedAt, &i.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, i)
+	}
+	return out, rows.Err()
+}
+
+func (r *integrationRepository) Upsert(ctx context.Context, i *models.Integration) error {
+	return r.pool.QueryRow(ctx, `INSERT INTO user_integrations (user_id, integration, config) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, integration) DO UPDATE SET config=EXCLUDED.config, updated_at=NOW()
+		RETURNING created_at, updated_at`, i.UserID, i.Name, i.Config).Scan(&i.CreatedAt, &i.UpdatedAt)
+}
+
+func (r *integrationRepository) Delete(ctx context.Context, userID, name string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM user_integrations WHERE user_id=$1 AND integration=$2`, userID, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// JobRepository stores the job queue. Times are passed in rather than taken from the database
+// clock, so they compare with the UTC times the queue writes.
+type JobRepository interface {
+	// CreateJob inserts a queued job; ID, Status and CreatedAt are filled in, and RunAt if unset
+	CreateJob(ctx context.Context, job *models.Job) error
+	// GetJob returns ErrNotFound if there is no job with the given ID
+	GetJob(ctx context.Context, id int64) (*models.Job, error)
+	// UpdateJob writes the job's status, attempts, progress, checkpoint, error and times
+	UpdateJob(ctx context.Context, job *models.Job) error
+	// ActiveJob returns the user's queued or running job of the given kind, or nil
+	ActiveJob(ctx context.Context, kind, userID string) (*models.Job, error)
+	// ClaimJob marks the next due job of one of the given kinds running, counting an attempt and
+	// leasing it until lockedUntil, and returns it; nil if none is due. Due jobs are queued ones
+	// whose RunAt has passed and running ones whose lease expired.
+	ClaimJob(ctx context.Context, kinds []string, now, lockedUntil time.Time) (*models.Job, error)
+	// ExtendLease keeps a running job leased until lockedUntil
+	ExtendLease(ctx context.Context, id int64, lockedUntil time.Time) error
+	// PendingJobs counts queued and running jobs and returns when the oldest was created
+	PendingJobs(ctx context.Context) (int, *time.Time, error)
+}
+
+type jobRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewJobRepositoryFromPool creates a JobRepository using a pgxpool.Pool
+func NewJobRepositoryFromPool(pool *pgxpool.Pool) JobRepository {
+	return &jobRepository{pool: pool}
+}
+
+const queueJobColumns = `id, kind, COALESCE(user_id, ''), ref_id, payload, status, attempts, max_attempts, total, processed, failed, checkpoint, error, run_at, created_at, started_at, finished_at`
+
+func scanQueueJob(row pgx.Row) (*models.Job, error) {
+	var j models.Job
+	if err := row.Scan(&j.ID, &j.Kind, &j.UserID, &j.RefID, &j.Payload, &j.Status, &j.Attempts, &j.MaxAttempts,
+		&j.Total, &j.Processed, &j.Failed, &j.Checkpoint, &j.Error, &j.RunAt, &j.CreatedAt, &j.StartedAt, &j.FinishedAt); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (r *jobRepository) CreateJob(ctx context.Context, j *models.Job) error {
+	j.Status = models.JobQueued
+	if j.RunAt.IsZero() {
+		j.RunAt = time.Now().UTC()
+	}
+	payload := j.Payload
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+	return r.pool.QueryRow(ctx, `INSERT INTO jobs (kind, user_id, ref_id, payload, status, max_attempts, total, run_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8) RETURNING id, created_at`,
+		j.Kind, j.UserID, j.RefID, payload, j.Status, j.MaxAttempts, j.Total, j.RunAt,
+	).Scan(&j.ID, &j.CreatedAt)
+}
+
+func (r *jobRepository) GetJob(ctx context.Context, id int64) (*models.Job, error) {
+	j, err := scanQueueJob(r.pool.QueryRow(ctx, `SELECT `+queueJobColumns+` FROM jobs WHERE id=$1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return j, err
+}
+
+func (r *jobRepository) UpdateJob(ctx context.Context, j *models.Job) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE jobs SET status=$2, attempts=$3, total=$4, processed=$5, failed=$6,
+		checkpoint=$7, error=$8, run_at=$9, started_at=$10, finished_at=$11 WHERE id=$1`,
+		j.ID, j.Status, j.Attempts, j.Total, j.Processed, j.Failed, j.Checkpoint, j.Error, j.RunAt, j.StartedAt, j.FinishedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *jobRepository) ActiveJob(ctx context.Context, kind, userID string) (*models.Job, error) {
+	j, err := scanQueueJob(r.pool.QueryRow(ctx, `SELECT `+queueJobColumns+` FROM jobs
+		WHERE kind=$1 AND user_id=$2 AND status IN ('queued', 'running') ORDER BY id LIMIT 1`, kind, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return j, err
+}
+
+func (r *jobRepository) ClaimJob(ctx context.Context, kinds []string, now, lockedUntil time.Time) (*models.Job, error) {
+	j, err := scanQueueJob(r.pool.QueryRow(ctx, `UPDATE jobs SET status='running', attempts=attempts+1,
+			started_at=$2, locked_until=$3
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE kind = ANY($1) AND ((status='queued' AND run_at <= $2) OR (status='running' AND locked_until < $2))
+			ORDER BY run_at, id LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+queueJobColumns, kinds, now, lockedUntil))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return j, err
+}
+
+func (r *jobRepository) ExtendLease(ctx context.Context, id int64, lockedUntil time.Time) error {
+	_, err := r.pool.Exec(ctx, `UPDATE jobs SET locked_until=$2 WHERE id=$1 AND status='running'`, id, lockedUntil)
+	return err
+}
+
+func (r *jobRepository) PendingJobs(ctx context.Context) (int, *time.Time, error) {
+	var n int
+	var oldest *time.Time
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*), MIN(created_at) FROM jobs WHERE status IN ('queued', 'running')`).Scan(&n, &oldest)
+	return n, oldest, err
+}
@@ -0,0 +1,80 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestJobRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewJobRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "job-user-1"
+	if err := db.Create(ctx, &models.User{ID: userID, Email: "jobs@example.com", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	job := &models.Job{Kind: models.JobKindMailboxBackfill, UserID: userID, Payload: []byte(`{"account_id":"a"}`), MaxAttempts: 3, RunAt: now}
+	if err := repo.CreateJob(ctx, job); err != nil {
+		t.Fatalf("CreateJob failed: %v", err)
+	}
+	later := &models.Job{Kind: models.JobKindRecategorize, RefID: 7, MaxAttempts: 1, RunAt: now.Add(time.Hour)}
+	if err := repo.CreateJob(ctx, later); err != nil {
+		t.Fatalf("CreateJob failed: %v", err)
+	}
+	if job.ID == 0 || job.Status != models.JobQueued {
+		t.Errorf("unexpected job after create %+v", job)
+	}
+	if active, err := repo.ActiveJob(ctx, models.JobKindMailboxBackfill, userID); err != nil || active == nil || active.ID != job.ID {
+		t.Errorf("expected active job %d, got %+v (err %v)", job.ID, active, err)
+	}
+	if n, oldest, err := repo.PendingJobs(ctx); err != nil || n != 2 || oldest == nil {
+		t.Errorf("expected 2 pending jobs, got %d, %v (err %v)", n, oldest, err)
+	}
+
+	// Only due jobs of the given kinds are claimed
+	if claimed, err := repo.ClaimJob(ctx, []string{models.JobKindBulk}, now, now.Add(time.Minute)); err != nil || claimed != nil {
+		t.Fatalf("expected no bulk job to claim, got %+v (err %v)", claimed, err)
+	}
+	kinds := []string{models.JobKindMailboxBackfill, models.JobKindRecategorize}
+	claimed, err := repo.ClaimJob(ctx, kinds, now, now.Add(time.Minute))
+	if err != nil || claimed == nil || claimed.ID != job.ID || claimed.Status != models.JobRunning || claimed.Attempts != 1 || string(claimed.Payload) != `{"account_id": "a"}` {
+		t.Fatalf("expected the due job claimed, got %+v (err %v)", claimed, err)
+	}
+	if again, err := repo.ClaimJob(ctx, kinds, now, now.Add(time.Minute)); err != nil || again != nil {
+		t.Errorf("expected the leased job left alone, got %+v (err %v)", again, err)
+	}
+	// A lease that ran out is claimed again
+	again, err := repo.ClaimJob(ctx, kinds, now.Add(2*time.Minute), now.Add(3*time.Minute))
+	if err != nil || again == nil || again.ID != job.ID || again.Attempts != 2 {
+		t.Fatalf("expected the expired lease reclaimed, got %+v (err %v)", again, err)
+	}
+	if err := repo.ExtendLease(ctx, job.ID, now.Add(time.Hour)); err != nil {
+		t.Fatalf("ExtendLease failed: %v", err)
+	}
+
+	finished := now.Add(time.Minute)
+	again.Status, again.Processed, again.Checkpoint, again.FinishedAt = models.JobCompleted, 40, "p3", &finished
+	if err := repo.UpdateJob(ctx, again); err != nil {
+		t.Fatalf("UpdateJob failed: %v", err)
+	}
+	got, err := repo.GetJob(ctx, job.ID)
+	if err != nil || got.Status != models.JobCompleted || got.Processed != 40 || got.Checkpoint != "p3" || got.UserID != userID || got.FinishedAt == nil {
+		t.Errorf("unexpected job after update %+v (err %v)", got, err)
+	}
+	if got, err := repo.GetJob(ctx, later.ID); err != nil || got.UserID != "" || got.RefID != 7 {
+		t.Errorf("expected the all-users job, got %+v (err %v)", got, err)
+	}
+	if active, err := repo.ActiveJob(ctx, models.JobKindMailboxBackfill, userID); err != nil || active != nil {
+		t.Errorf("expected no active job, got %+v (err %v)", active, err)
+	}
+	if _, err := repo.GetJob(ctx, 999999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
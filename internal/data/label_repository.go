@@ -0,0 +1,118 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LabelRepository caches provider labels per user
+type LabelRepository interface {
+	UpsertLabel(ctx context.Context, label *models.Label) error
+	// ReplaceLabels swaps the user's cached labels for the given set (labels removed at the provider are dropped)
+	ReplaceLabels(ctx context.Context, userID string, labels []*models.Label) error
+	ListLabels(ctx context.Context, userID string) ([]*models.Label, error)
+	// GetLabel returns nil, nil if the label is not cached
+	GetLabel(ctx context.Context, userID, providerLabelID string) (*models.Label, error)
+	// GetLabelByName matches case-insensitively and returns nil, nil if no label has the name
+	GetLabelByName(ctx context.Context, userID, name string) (*models.Label, error)
+}
+
+type labelRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewLabelRepositoryFromPool creates a LabelRepository using a pgxpool.Pool
+func NewLabelRepositoryFromPool(pool *pgxpool.Pool) LabelRepository {
+	return &labelRepository{pool: pool}
+}
+
+const upsertLabelSQL = `INSERT INTO labels (user_id, provider_label_id, name, type, background_color, text_color, message_list_visibility, label_list_visibility, cached_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+	ON CONFLICT (user_id, provider_label_id) DO UPDATE SET
+	name = EXCLUDED.name,
+	type = EXCLUDED.type,
+	background_color = EXCLUDED.background_color,
+	text_color = EXCLUDED.text_color,
+	message_list_visibility = EXCLUDED.message_list_visibility,
+	label_list_visibility = EXCLUDED.label_list_visibility,
+	cached_at = NOW()`
+
+func (r *labelRepository) UpsertLabel(ctx context.Context, label *models.Label) error {
+	_, err := r.pool.Exec(ctx, upsertLabelSQL, labelArgs(label)...)
+	return err
+}
+
+func (r *labelRepository) ReplaceLabels(ctx context.Context, userID string, labels []*models.Label) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	ids := make([]string, 0, len(labels))
+	for _, l := range labels {
+		l.UserID = userID
+		if _, err := tx.Exec(ctx, upsertLabelSQL, labelArgs(l)...); err != nil {
+			return err
+		}
+		ids = append(ids, l.ProviderLabelID)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM labels WHERE user_id=$1 AND NOT (provider_label_id = ANY($2))`, userID, ids); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *labelRepository) ListLabels(ctx context.Context, userID string) ([]*models.Label, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+labelColumns+` FROM labels WHERE user_id=$1 ORDER BY type, name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var labels []*models.Label
+	for rows.Next() {
+		l, err := scanLabel(rows)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, l)
+	}
+	return labels, rows.Err()
+}
+
+func (r *labelRepository) GetLabel(ctx context.Context, userID, providerLabelID string) (*models.Label, error) {
+	row := r.pool.QueryRow(ctx, `SELECT `+labelColumns+` FROM labels WHERE user_id=$1 AND provider_label_id=$2`, userID, providerLabelID)
+	l, err := scanLabel(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return l, err
+}
+
+func (r *labelRepository) GetLabelByName(ctx context.Context, userID, name string) (*models.Label, error) {
+	row := r.pool.QueryRow(ctx, `SELECT `+labelColumns+` FROM labels WHERE user_id=$1 AND lower(name)=lower($2) LIMIT 1`, userID, name)
+	l, err := scanLabel(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return l, err
+}
+
+const labelColumns = `id, user_id, provider_label_id, name, type, COALESCE(background_color, ''), COALESCE(text_color, ''),
+	COALESCE(message_list_visibility, ''), COALESCE(label_list_visibility, ''), cached_at`
+
+func labelArgs(l *models.Label) []interface{} {
+	return []interface{}{l.UserID, l.ProviderLabelID, l.Name, l.Type, l.BackgroundColor, l.TextColor, l.MessageListVisibility, l.LabelListVisibility}
+}
+
+func scanLabel(row pgx.Row) (*models.Label, error) {
+	var l models.Label
+	if err := row.Scan(&l.ID, &l.UserID, &l.ProviderLabelID, &l.Name, &l.Type, &l.BackgroundColor, &l.TextColor,
+		&l.MessageListVisibility, &l.LabelListVisibility, &l.CachedAt); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
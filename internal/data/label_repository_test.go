@@ -0,0 +1,64 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestLabelRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewLabelRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "user-labels-1"
+
+	labels := []*models.Label{
+		{ProviderLabelID: "INBOX", Name: "INBOX", Type: models.LabelTypeSystem},
+		{ProviderLabelID: "Label_1", Name: "Finance", Type: models.LabelTypeUser, BackgroundColor: "#16a765", TextColor: "#ffffff"},
+		{ProviderLabelID: "Label_2", Name: "Old", Type: models.LabelTypeUser},
+	}
+	if err := repo.ReplaceLabels(ctx, userID, labels); err != nil {
+		t.Fatalf("ReplaceLabels failed: %v", err)
+	}
+
+	// Labels removed at the provider are dropped on the next replace
+	if err := repo.ReplaceLabels(ctx, userID, labels[:2]); err != nil {
+		t.Fatalf("ReplaceLabels (second) failed: %v", err)
+	}
+	got, err := repo.ListLabels(ctx, userID)
+	if err != nil {
+		t.Fatalf("ListLabels failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 labels, got %d", len(got))
+	}
+
+	l, err := repo.GetLabel(ctx, userID, "Label_1")
+	if err != nil || l == nil {
+		t.Fatalf("GetLabel failed: %v", err)
+	}
+	if l.BackgroundColor != "#16a765" || l.Type != models.LabelTypeUser {
+		t.Errorf("unexpected label: %+v", l)
+	}
+
+	l.Name = "Finance/Receipts"
+	if err := repo.UpsertLabel(ctx, l); err != nil {
+		t.Fatalf("UpsertLabel failed: %v", err)
+	}
+	l, _ = repo.GetLabel(ctx, userID, "Label_1")
+	if l.Name != "Finance/Receipts" {
+		t.Errorf("expected renamed label, got %q", l.Name)
+	}
+
+	byName, err := repo.GetLabelByName(ctx, userID, "finance/receipts")
+	if err != nil || byName == nil || byName.ProviderLabelID != "Label_1" {
+		t.Errorf("expected case-insensitive name lookup to find Label_1, got %+v, %v", byName, err)
+	}
+
+	missing, err := repo.GetLabel(ctx, userID, "Label_2")
+	if err != nil || missing != nil {
+		t.Errorf("expected nil, nil for removed label, got %+v, %v", missing, err)
+	}
+}
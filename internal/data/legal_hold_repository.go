@@ -0,0 +1,110 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LegalHoldRepository stores legal holds and their audit trail
+type LegalHoldRepository interface {
+	// Place puts the user under hold and fills in PlacedBy and PlacedAt; placing a hold again
+	// updates its reason and keeps the original placement. Returns ErrNotFound for an unknown user.
+	Place(ctx context.Context, hold *models.LegalHold) error
+	// Release returns ErrNotFound if the user was not held
+	Release(ctx context.Context, userID string) error
+	// Get returns the user's hold, or ErrNotFound if there is none
+	Get(ctx context.Context, userID string) (*models.LegalHold, error)
+	List(ctx context.Context) ([]*models.LegalHold, error)
+	RecordEvent(ctx context.Context, e *models.LegalHoldEvent) error
+	// ListEvents returns the user's most recent events first
+	ListEvents(ctx context.Context, userID string, limit int) ([]*models.LegalHoldEvent, error)
+}
+
+type legalHoldRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewLegalHoldRepositoryFromPool creates a LegalHoldRepository using a pgxpool.Pool
+func NewLegalHoldRepositoryFromPool(pool *pgxpool.Pool) LegalHoldRepository {
+	return &legalHoldRepository{pool: pool}
+}
+
+func (r *legalHoldRepository) Place(ctx context.Context, hold *models.LegalHold) error {
+	err := r.pool.QueryRow(ctx, `INSERT INTO legal_holds (user_id, reason, placed_by)
+		SELECT id, $2, $3 FROM users WHERE id=$1
+		ON CONFLICT (user_id) DO UPDATE SET reason = EXCLUDED.reason
+		RETURNING placed_by, placed_at`, hold.UserID, hold.Reason, hold.PlacedBy).Scan(&hold.PlacedBy, &hold.PlacedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *legalHoldRepository) Release(ctx context.Context, userID string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM legal_holds WHERE user_id=$1`, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *legalHoldRepository) Get(ctx context.Context, userID string) (*models.LegalHold, error) {
+	h := &models.LegalHold{}
+	err := r.pool.QueryRow(ctx, `SELECT user_id, reason, placed_by, placed_at FROM legal_holds WHERE user_id=$1`,
+		userID).Scan(&h.UserID, &h.Reason, &h.PlacedBy, &h.PlacedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (r *legalHoldRepository) List(ctx context.Context) ([]*models.LegalHold, error) {
+	rows, err := r.pool.Query(ctx, `SELECT user_id, reason, placed_by, placed_at FROM legal_holds ORDER BY placed_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var holds []*models.LegalHold
+	for rows.Next() {
+		h := &models.LegalHold{}
+		if err := rows.Scan(&h.UserID, &h.Reason, &h.PlacedBy, &h.PlacedAt); err != nil {
+			return nil, err
+		}
+		holds = append(holds, h)
+	}
+	return holds, rows.Err()
+}
+
+func (r *legalHoldRepository) RecordEvent(ctx context.Context, e *models.LegalHoldEvent) error {
+	return r.pool.QueryRow(ctx, `INSERT INTO legal_hold_events (user_id, event, action, actor, detail)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`,
+		e.UserID, e.Event, e.Action, e.Actor, e.Detail).Scan(&e.ID, &e.CreatedAt)
+}
+
+func (r *legalHoldRepository) ListEvents(ctx context.Context, userID string, limit int) ([]*models.LegalHoldEvent, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id, user_id, event, action, actor, detail, created_at
+		FROM legal_hold_events WHERE user_id=$1 ORDER BY created_at DESC, id DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []*models.LegalHoldEvent
+	for rows.Next() {
+		e := &models.LegalHoldEvent{}
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Event, &e.Action, &e.Actor, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
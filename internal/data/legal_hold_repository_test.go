@@ -0,0 +1,70 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestLegalHoldRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewLegalHoldRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+
+	user := &models.User{ID: "hold-user", Email: "hold@example.com", CreatedAt: time.Now()}
+	if err := db.Create(ctx, user); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := repo.Place(ctx, &models.LegalHold{UserID: "nobody", PlacedBy: "admin"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound holding an unknown user, got %v", err)
+	}
+	first := &models.LegalHold{UserID: user.ID, Reason: "case 1", PlacedBy: "admin-1"}
+	if err := repo.Place(ctx, first); err != nil {
+		t.Fatalf("Place failed: %v", err)
+	}
+	again := &models.LegalHold{UserID: user.ID, Reason: "case 2", PlacedBy: "admin-2"}
+	if err := repo.Place(ctx, again); err != nil {
+		t.Fatalf("Place again failed: %v", err)
+	}
+	if again.PlacedBy != "admin-1" || !again.PlacedAt.Equal(first.PlacedAt) {
+		t.Errorf("expected placing again to keep the original placement, got %+v", again)
+	}
+	got, err := repo.Get(ctx, user.ID)
+	if err != nil || got.Reason != "case 2" {
+		t.Errorf("expected the updated reason, got %+v (err %v)", got, err)
+	}
+	holds, err := repo.List(ctx)
+	if err != nil || len(holds) != 1 {
+		t.Errorf("expected 1 hold, got %v (err %v)", holds, err)
+	}
+	if err := db.Delete(ctx, user.ID); err == nil {
+		t.Error("expected deleting a held user to be refused")
+	}
+
+	for _, e := range []*models.LegalHoldEvent{
+		{UserID: user.ID, Event: models.LegalHoldPlaced, Actor: "admin-1"},
+		{UserID: user.ID, Event: models.LegalHoldBlocked, Action: "trash", Actor: user.ID},
+	} {
+		if err := repo.RecordEvent(ctx, e); err != nil {
+			t.Fatalf("RecordEvent failed: %v", err)
+		}
+	}
+	events, err := repo.ListEvents(ctx, user.ID, 10)
+	if err != nil || len(events) != 2 || events[0].Event != models.LegalHoldBlocked {
+		t.Errorf("expected 2 events, most recent first, got %+v (err %v)", events, err)
+	}
+
+	if err := repo.Release(ctx, user.ID); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, err := repo.Get(ctx, user.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after release, got %v", err)
+	}
+	if err := repo.Release(ctx, user.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound releasing twice, got %v", err)
+	}
+}
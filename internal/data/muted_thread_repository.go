@@ -0,0 +1,76 @@
+package data
+
+import (
+	"context"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MutedThreadRepository stores the threads a user has muted
+type MutedThreadRepository interface {
+	// Mute records the thread as muted; muting an already muted thread keeps the original row
+	Mute(ctx context.Context, userID, threadID string) (*models.MutedThread, error)
+	// Unmute returns ErrNotFound if the thread was not muted
+	Unmute(ctx context.Context, userID, threadID string) error
+	IsMuted(ctx context.Context, userID, threadID string) (bool, error)
+	// ThreadMessageIDs returns the IDs of the cached messages in a thread
+	ThreadMessageIDs(ctx context.Context, userID, threadID string) ([]string, error)
+}
+
+type mutedThreadRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewMutedThreadRepositoryFromPool creates a MutedThreadRepository using a pgxpool.Pool
+func NewMutedThreadRepositoryFromPool(pool *pgxpool.Pool) MutedThreadRepository {
+	return &mutedThreadRepository{pool: pool}
+}
+
+func (r *mutedThreadRepository) Mute(ctx context.Context, userID, threadID string) (*models.MutedThread, error) {
+	m := &models.MutedThread{UserID: userID, ThreadID: threadID}
+	// The no-op update makes RETURNING yield the existing row
+	err := r.pool.QueryRow(ctx, `INSERT INTO muted_threads (user_id, thread_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, thread_id) DO UPDATE SET thread_id = EXCLUDED.thread_id
+		RETURNING created_at`, userID, threadID).Scan(&m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *mutedThreadRepository) Unmute(ctx context.Context, userID, threadID string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM muted_threads WHERE user_id=$1 AND thread_id=$2`, userID, threadID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mutedThreadRepository) IsMuted(ctx context.Context, userID, threadID string) (bool, error) {
+	var muted bool
+	err := r.pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM muted_threads WHERE user_id=$1 AND thread_id=$2)`,
+		userID, threadID).Scan(&muted)
+	return muted, err
+}
+
+func (r *mutedThreadRepository) ThreadMessageIDs(ctx context.Context, userID, threadID string) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `SELECT email_message_id FROM email_messages
+		WHERE user_id=$1 AND thread_id=$2 ORDER BY internal_date`, userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
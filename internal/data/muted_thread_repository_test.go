@@ -0,0 +1,48 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestMutedThreadRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewMutedThreadRepositoryFromPool(db.Pool)
+	messages := NewEmailMessageRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "mute-user-1"
+
+	for _, id := range []string{"m1", "m2"} {
+		if err := messages.UpsertMessage(ctx, &models.EmailMessage{UserID: userID, EmailMessageID: id, ThreadID: "t1"}); err != nil {
+			t.Fatalf("UpsertMessage failed: %v", err)
+		}
+	}
+	first, err := repo.Mute(ctx, userID, "t1")
+	if err != nil {
+		t.Fatalf("Mute failed: %v", err)
+	}
+	again, err := repo.Mute(ctx, userID, "t1")
+	if err != nil || !again.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("expected muting twice to keep the original row, got %+v (err %v)", again, err)
+	}
+	if muted, err := repo.IsMuted(ctx, userID, "t1"); err != nil || !muted {
+		t.Errorf("expected t1 to be muted (err %v)", err)
+	}
+	ids, err := repo.ThreadMessageIDs(ctx, userID, "t1")
+	if err != nil || len(ids) != 2 {
+		t.Errorf("expected 2 thread messages, got %v (err %v)", ids, err)
+	}
+	if err := repo.Unmute(ctx, userID, "t1"); err != nil {
+		t.Fatalf("Unmute failed: %v", err)
+	}
+	if muted, _ := repo.IsMuted(ctx, userID, "t1"); muted {
+		t.Error("expected t1 to be unmuted")
+	}
+	if err := repo.Unmute(ctx, userID, "t1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound unmuting twice, got %v", err)
+	}
+}
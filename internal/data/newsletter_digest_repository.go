@@ -0,0 +1,106 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NewsletterDigestRepository tracks the messages archived into users' daily newsletter digests
+type NewsletterDigestRepository interface {
+	// ListEnabledUsers returns the settings of users with digest categories whose messages are
+	// fully cached; the digest message cannot be stored for the others
+	ListEnabledUsers(ctx context.Context) ([]*models.UserSettings, error)
+	// Candidates returns up to limit cached messages received since sinceMillis (provider
+	// internal date) in one of categories that have not been digested yet, oldest first. A
+	// message's Whisperer category wins over the provider's.
+	Candidates(ctx context.Context, userID string, categories []string, sinceMillis int64, limit int) ([]*models.EmailMessage, error)
+	// Add records a message as archived into the digest of item.Day; adding it again is a no-op
+	Add(ctx context.Context, item *models.DigestItem) error
+	// Items returns the messages in the user's digest for day, in the order they were archived,
+	// with the subject, sender and snippet they are cached with
+	Items(ctx context.Context, userID string, day time.Time) ([]*models.DigestItem, error)
+}
+
+type newsletterDigestRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewNewsletterDigestRepositoryFromPool creates a NewsletterDigestRepository using a pgxpool.Pool
+func NewNewsletterDigestRepositoryFromPool(pool *pgxpool.Pool) NewsletterDigestRepository {
+	return &newsletterDigestRepository{pool: pool}
+}
+
+func (r *newsletterDigestRepository) ListEnabledUsers(ctx context.Context) ([]*models.UserSettings, error) {
+	rows, err := r.pool.Query(ctx, `SELECT user_id, timezone, digest_categories FROM user_settings s
+		WHERE cardinality(digest_categories) > 0 AND NOT disable_local_cache AND NOT metadata_only_cache
+		AND NOT EXISTS (SELECT 1 FROM user_encryption_keys k WHERE k.user_id=s.user_id)
+		ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*models.UserSettings
+	for rows.Next() {
+		var s models.UserSettings
+		if err := rows.Scan(&s.UserID, &s.Timezone, &s.DigestCategories); err != nil {
+			return nil, err
+		}
+		out = append(out, &s)
+	}
+	return out, rows.Err()
+}
+
+func (r *newsletterDigestRepository) Candidates(ctx context.Context, userID string, categories []string, sinceMillis int64, limit int) ([]*models.EmailMessage, error) {
+	rows, err := r.pool.Query(ctx, `SELECT m.email_message_id, m.thread_id, m.subject, m.sender, m.snippet, m.internal_date
+		FROM email_messages m
+		WHERE m.user_id=$1 AND m.internal_date >= $3
+		AND COALESCE(NULLIF(m.category, ''), m.provider_category) = ANY($2)
+		AND m.email_message_id NOT LIKE $4
+		AND NOT EXISTS (SELECT 1 FROM newsletter_digest_items d WHERE d.user_id=m.user_id AND d.email_message_id=m.email_message_id)
+		ORDER BY m.internal_date, m.email_message_id
+		LIMIT $5`, userID, categories, sinceMillis, models.DigestMessagePrefix+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*models.EmailMessage
+	for rows.Next() {
+		m := &models.EmailMessage{UserID: userID}
+		if err := rows.Scan(&m.EmailMessageID, &m.ThreadID, &m.Subject, &m.Sender, &m.Snippet, &m.InternalDate); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+func (r *newsletterDigestRepository) Add(ctx context.Context, item *models.DigestItem) error {
+	_, err := r.pool.Exec(ctx, `INSERT INTO newsletter_digest_items (user_id, email_message_id, day)
+		VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, item.UserID, item.EmailMessageID, item.Day)
+	return err
+}
+
+func (r *newsletterDigestRepository) Items(ctx context.Context, userID string, day time.Time) ([]*models.DigestItem, error) {
+	// Messages trashed since are dropped from the cache and so from the digest
+	rows, err := r.pool.Query(ctx, `SELECT d.email_message_id, d.day, d.archived_at, m.subject, m.sender, m.snippet
+		FROM newsletter_digest_items d
+		JOIN email_messages m ON m.user_id=d.user_id AND m.email_message_id=d.email_message_id
+		WHERE d.user_id=$1 AND d.day=$2
+		ORDER BY d.archived_at, d.email_message_id`, userID, day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*models.DigestItem
+	for rows.Next() {
+		it := &models.DigestItem{UserID: userID}
+		if err := rows.Scan(&it.EmailMessageID, &it.Day, &it.ArchivedAt, &it.Subject, &it.Sender, &it.Snippet); err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
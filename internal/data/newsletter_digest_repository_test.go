@@ -0,0 +1,70 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestNewsletterDigestRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewNewsletterDigestRepositoryFromPool(db.Pool)
+	messages := NewEmailMessageRepositoryFromPool(db.Pool)
+	settings := NewUserSettingsRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+
+	user := &models.User{ID: "digest-user", Email: "digest@example.com", CreatedAt: time.Now()}
+	if err := db.Create(ctx, user); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := settings.Upsert(ctx, &models.UserSettings{UserID: user.ID, DigestCategories: []string{"Promotions/Ads"}}); err != nil {
+		t.Fatalf("Upsert settings failed: %v", err)
+	}
+	promo := sql.NullString{String: "Promotions/Ads", Valid: true}
+	for i, m := range []*models.EmailMessage{
+		{EmailMessageID: "promo", Category: promo, InternalDate: 200},
+		{EmailMessageID: "tab-only", ProviderCategory: "Promotions/Ads", InternalDate: 150},
+		{EmailMessageID: "overridden", Category: sql.NullString{String: "Primary", Valid: true}, ProviderCategory: "Promotions/Ads", InternalDate: 160},
+		{EmailMessageID: "yesterday", Category: promo, InternalDate: 50},
+		{EmailMessageID: models.DigestMessagePrefix + "2026-10-15", Category: promo, InternalDate: 300},
+	} {
+		m.ID = int64(i + 1)
+		m.UserID = user.ID
+		m.ThreadID = "t-" + m.EmailMessageID
+		m.Subject = "subject " + m.EmailMessageID
+		if err := messages.UpsertMessage(ctx, m); err != nil {
+			t.Fatalf("UpsertMessage failed: %v", err)
+		}
+	}
+
+	users, err := repo.ListEnabledUsers(ctx)
+	if err != nil || len(users) != 1 || users[0].UserID != user.ID || len(users[0].DigestCategories) != 1 {
+		t.Fatalf("expected the user with digest categories, got %+v (err %v)", users, err)
+	}
+	msgs, err := repo.Candidates(ctx, user.ID, []string{"Promotions/Ads"}, 100, 10)
+	if err != nil {
+		t.Fatalf("Candidates failed: %v", err)
+	}
+	if len(msgs) != 2 || msgs[0].EmailMessageID != "tab-only" || msgs[1].EmailMessageID != "promo" {
+		t.Fatalf("expected today's promotions oldest first, got %+v", msgs)
+	}
+
+	day := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	for _, id := range []string{"promo", "promo"} {
+		if err := repo.Add(ctx, &models.DigestItem{UserID: user.ID, EmailMessageID: id, Day: day}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	msgs, err = repo.Candidates(ctx, user.ID, []string{"Promotions/Ads"}, 100, 10)
+	if err != nil || len(msgs) != 1 || msgs[0].EmailMessageID != "tab-only" {
+		t.Errorf("expected digested messages to be left out, got %+v (err %v)", msgs, err)
+	}
+	items, err := repo.Items(ctx, user.ID, day)
+	if err != nil || len(items) != 1 || items[0].Subject != "subject promo" || !items[0].Day.Equal(day) {
+		t.Errorf("expected the digested message, got %+v (err %v)", items, err)
+	}
+}
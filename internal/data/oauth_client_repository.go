@@ -0,0 +1,83 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OAuthClientRepository stores OAuth app credentials per provider, with the secrets encrypted at rest
+type OAuthClientRepository interface {
+	// Save inserts or replaces the provider's credentials
+	Save(ctx context.Context, creds *models.OAuthClientCredentials) error
+	// Get returns the provider's credentials with the secrets decrypted, or ErrNotFound. The
+	// error wraps ErrCredentialUnreadable if a secret cannot be decrypted.
+	Get(ctx context.Context, provider string) (*models.OAuthClientCredentials, error)
+}
+
+type oauthClientRepository struct {
+	pool   *pgxpool.Pool
+	cipher *CredentialCipher
+}
+
+// NewOAuthClientRepositoryFromPool creates an OAuthClientRepository using a pgxpool.Pool;
+// secrets are encrypted with cipher
+func NewOAuthClientRepositoryFromPool(pool *pgxpool.Pool, cipher *CredentialCipher) OAuthClientRepository {
+	return &oauthClientRepository{pool: pool, cipher: cipher}
+}
+
+// Secrets are sealed for the provider and client ID, so neither can be swapped for another row's
+func secretOwner(provider, clientID string) string {
+	return provider + "/" + clientID
+}
+
+func (r *oauthClientRepository) Save(ctx context.Context, c *models.OAuthClientCredentials) error {
+	secret, err := r.cipher.Seal(secretOwner(c.Provider, c.ClientID), []byte(c.ClientSecret))
+	if err != nil {
+		return err
+	}
+	var previous []byte
+	if c.PreviousClientID != "" {
+		if previous, err = r.cipher.Seal(secretOwner(c.Provider, c.PreviousClientID), []byte(c.PreviousClientSecret)); err != nil {
+			return err
+		}
+	}
+	return r.pool.QueryRow(ctx, `INSERT INTO oauth_client_credentials (provider, client_id, client_secret,
+			previous_client_id, previous_client_secret, previous_valid_until, rotated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (provider) DO UPDATE SET client_id=EXCLUDED.client_id, client_secret=EXCLUDED.client_secret,
+			previous_client_id=EXCLUDED.previous_client_id, previous_client_secret=EXCLUDED.previous_client_secret,
+			previous_valid_until=EXCLUDED.previous_valid_until, rotated_by=EXCLUDED.rotated_by, rotated_at=NOW()
+		RETURNING rotated_at`,
+		c.Provider, c.ClientID, secret, c.PreviousClientID, previous, c.PreviousValidUntil, c.RotatedBy,
+	).Scan(&c.RotatedAt)
+}
+
+func (r *oauthClientRepository) Get(ctx context.Context, provider string) (*models.OAuthClientCredentials, error) {
+	c := &models.OAuthClientCredentials{Provider: provider}
+	var secret, previous []byte
+	err := r.pool.QueryRow(ctx, `SELECT client_id, client_secret, previous_client_id, previous_client_secret,
+		previous_valid_until, rotated_by, rotated_at FROM oauth_client_credentials WHERE provider=$1`, provider).
+		Scan(&c.ClientID, &secret, &c.PreviousClientID, &previous, &c.PreviousValidUntil, &c.RotatedBy, &c.RotatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	plain, err := r.cipher.Open(secretOwner(provider, c.ClientID), secret)
+	if err != nil {
+		return nil, err
+	}
+	c.ClientSecret = string(plain)
+	if c.PreviousClientID != "" {
+		if plain, err = r.cipher.Open(secretOwner(provider, c.PreviousClientID), previous); err != nil {
+			return nil, err
+		}
+		c.PreviousClientSecret = string(plain)
+	}
+	return c, nil
+}
@@ -0,0 +1,49 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestOAuthClientRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	cipher, err := NewCredentialCipher(bytes.Repeat([]byte{2}, CredentialKeySize))
+	if err != nil {
+		t.Fatalf("NewCredentialCipher failed: %v", err)
+	}
+	repo := NewOAuthClientRepositoryFromPool(db.Pool, cipher)
+
+	if _, err := repo.Get(ctx, "google"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	creds := &models.OAuthClientCredentials{Provider: "google", ClientID: "new-id", ClientSecret: "new-secret",
+		PreviousClientID: "old-id", PreviousClientSecret: "old-secret", PreviousValidUntil: &until, RotatedBy: "admin"}
+	if err := repo.Save(ctx, creds); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	var stored []byte
+	if err := db.Pool.QueryRow(ctx, `SELECT client_secret FROM oauth_client_credentials WHERE provider='google'`).Scan(&stored); err != nil {
+		t.Fatalf("reading the stored secret failed: %v", err)
+	}
+	if bytes.Contains(stored, []byte("new-secret")) {
+		t.Error("secret is stored in the clear")
+	}
+	got, err := repo.Get(ctx, "google")
+	if err != nil || got.ClientSecret != "new-secret" || got.PreviousClientSecret != "old-secret" || !got.PreviousValidUntil.Equal(until) {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+
+	// A key change leaves the stored secrets unreadable rather than wrong
+	other, _ := NewCredentialCipher(bytes.Repeat([]byte{3}, CredentialKeySize))
+	if _, err := NewOAuthClientRepositoryFromPool(db.Pool, other).Get(ctx, "google"); !errors.Is(err, ErrCredentialUnreadable) {
+		t.Errorf("expected ErrCredentialUnreadable, got %v", err)
+	}
+}
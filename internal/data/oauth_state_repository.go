@@ -0,0 +1,46 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OAuthStateRepository stores issued OAuth states so any instance can validate a callback,
+// and each state is accepted only once
+type OAuthStateRepository interface {
+	// CreateState stores state until expiresAt. Expired states are purged as a side effect.
+	CreateState(ctx context.Context, state string, expiresAt time.Time) error
+	// ConsumeState deletes state. Returns ErrNotFound if it was never issued, was already
+	// consumed or has expired.
+	ConsumeState(ctx context.Context, state string) error
+}
+
+type oauthStateRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewOAuthStateRepositoryFromPool creates an OAuthStateRepository using a pgxpool.Pool
+func NewOAuthStateRepositoryFromPool(pool *pgxpool.Pool) OAuthStateRepository {
+	return &oauthStateRepository{pool: pool}
+}
+
+func (r *oauthStateRepository) CreateState(ctx context.Context, state string, expiresAt time.Time) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM oauth_states WHERE expires_at <= now()`); err != nil {
+		return err
+	}
+	_, err := r.pool.Exec(ctx, `INSERT INTO oauth_states (state, expires_at) VALUES ($1, $2)`, state, expiresAt.UTC())
+	return err
+}
+
+func (r *oauthStateRepository) ConsumeState(ctx context.Context, state string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM oauth_states WHERE state = $1 AND expires_at > now()`, state)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
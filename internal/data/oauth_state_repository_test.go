@@ -0,0 +1,43 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOAuthStateRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewOAuthStateRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+
+	if err := repo.CreateState(ctx, "s1", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("CreateState failed: %v", err)
+	}
+	if err := repo.ConsumeState(ctx, "s1"); err != nil {
+		t.Fatalf("ConsumeState failed: %v", err)
+	}
+	if err := repo.ConsumeState(ctx, "s1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a replayed state to be rejected, got %v", err)
+	}
+	if err := repo.ConsumeState(ctx, "never-issued"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an unknown state to be rejected, got %v", err)
+	}
+
+	if err := repo.CreateState(ctx, "expired", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("CreateState failed: %v", err)
+	}
+	if err := repo.ConsumeState(ctx, "expired"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an expired state to be rejected, got %v", err)
+	}
+	// Creating another state purges the expired one
+	if err := repo.CreateState(ctx, "s2", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("CreateState failed: %v", err)
+	}
+	var n int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*) FROM oauth_states WHERE state = 'expired'`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("expected the expired state to be purged, got %d (err %v)", n, err)
+	}
+}
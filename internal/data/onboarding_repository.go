@@ -0,0 +1,49 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OnboardingRepository records completed onboarding steps
+type OnboardingRepository interface {
+	// MarkStep records a step as complete; completing it again keeps the original time
+	MarkStep(ctx context.Context, userID, step string) error
+	// CompletedSteps returns the completion time of each completed step
+	CompletedSteps(ctx context.Context, userID string) (map[string]time.Time, error)
+}
+
+type onboardingRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewOnboardingRepositoryFromPool creates an OnboardingRepository using a pgxpool.Pool
+func NewOnboardingRepositoryFromPool(pool *pgxpool.Pool) OnboardingRepository {
+	return &onboardingRepository{pool: pool}
+}
+
+func (r *onboardingRepository) MarkStep(ctx context.Context, userID, step string) error {
+	_, err := r.pool.Exec(ctx, `INSERT INTO user_onboarding (user_id, step) VALUES ($1, $2)
+		ON CONFLICT (user_id, step) DO NOTHING`, userID, step)
+	return err
+}
+
+func (r *onboardingRepository) CompletedSteps(ctx context.Context, userID string) (map[string]time.Time, error) {
+	rows, err := r.pool.Query(ctx, `SELECT step, completed_at FROM user_onboarding WHERE user_id=$1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	steps := make(map[string]time.Time)
+	for rows.Next() {
+		var step string
+		var at time.Time
+		if err := rows.Scan(&step, &at); err != nil {
+			return nil, err
+		}
+		steps[step] = at
+	}
+	return steps, rows.Err()
+}
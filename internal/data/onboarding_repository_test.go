@@ -0,0 +1,35 @@
+package data
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOnboardingRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewOnboardingRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "user-onboarding-1"
+
+	if err := repo.MarkStep(ctx, userID, "account_linked"); err != nil {
+		t.Fatalf("MarkStep failed: %v", err)
+	}
+	steps, err := repo.CompletedSteps(ctx, userID)
+	if err != nil {
+		t.Fatalf("CompletedSteps failed: %v", err)
+	}
+	first := steps["account_linked"]
+	if first.IsZero() {
+		t.Fatalf("expected account_linked to be complete, got %v", steps)
+	}
+
+	// Completing a step again is a no-op
+	if err := repo.MarkStep(ctx, userID, "account_linked"); err != nil {
+		t.Fatalf("MarkStep (repeat) failed: %v", err)
+	}
+	steps, _ = repo.CompletedSteps(ctx, userID)
+	if len(steps) != 1 || !steps["account_linked"].Equal(first) {
+		t.Errorf("expected original completion time to be kept, got %v", steps)
+	}
+}
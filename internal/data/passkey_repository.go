@@ -0,0 +1,101 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PasskeyRepository stores users' passkeys
+type PasskeyRepository interface {
+	// Create stores p and fills in its ID and CreatedAt. Returns ErrAlreadyExists if the
+	// credential is already registered.
+	Create(ctx context.Context, p *models.Passkey) error
+	// ListByUser returns the user's passkeys, oldest first
+	ListByUser(ctx context.Context, userID string) ([]*models.Passkey, error)
+	// GetByCredentialID returns ErrNotFound unless the user has a passkey with the credential ID
+	GetByCredentialID(ctx context.Context, userID, credentialID string) (*models.Passkey, error)
+	// RecordUse stores the sign count of a verified assertion and when it was made
+	RecordUse(ctx context.Context, id int64, signCount uint32) error
+	// Delete returns ErrNotFound if the user has no passkey with the ID
+	Delete(ctx context.Context, userID string, id int64) error
+}
+
+type passkeyRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewPasskeyRepositoryFromPool creates a PasskeyRepository using a pgxpool.Pool
+func NewPasskeyRepositoryFromPool(pool *pgxpool.Pool) PasskeyRepository {
+	return &passkeyRepository{pool: pool}
+}
+
+const passkeyColumns = `id, user_id, credential_id, public_key, algorithm, sign_count, name, created_at, last_used_at`
+
+func scanPasskey(row pgx.Row) (*models.Passkey, error) {
+	var p models.Passkey
+	var signCount int64
+	if err := row.Scan(&p.ID, &p.UserID, &p.CredentialID, &p.PublicKey, &p.Algorithm, &signCount, &p.Name,
+		&p.CreatedAt, &p.LastUsedAt); err != nil {
+		return nil, err
+	}
+	p.SignCount = uint32(signCount)
+	return &p, nil
+}
+
+func (r *passkeyRepository) Create(ctx context.Context, p *models.Passkey) error {
+	err := r.pool.QueryRow(ctx, `INSERT INTO passkeys (user_id, credential_id, public_key, algorithm, sign_count, name)
+		VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (credential_id) DO NOTHING RETURNING id, created_at`,
+		p.UserID, p.CredentialID, p.PublicKey, p.Algorithm, int64(p.SignCount), p.Name).Scan(&p.ID, &p.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+func (r *passkeyRepository) ListByUser(ctx context.Context, userID string) ([]*models.Passkey, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+passkeyColumns+` FROM passkeys WHERE user_id=$1 ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*models.Passkey
+	for rows.Next() {
+		p, err := scanPasskey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (r *passkeyRepository) GetByCredentialID(ctx context.Context, userID, credentialID string) (*models.Passkey, error) {
+	p, err := scanPasskey(r.pool.QueryRow(ctx, `SELECT `+passkeyColumns+` FROM passkeys WHERE user_id=$1 AND credential_id=$2`,
+		userID, credentialID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return p, err
+}
+
+func (r *passkeyRepository) RecordUse(ctx context.Context, id int64, signCount uint32) error {
+	_, err := r.pool.Exec(ctx, `UPDATE passkeys SET sign_count=$2, last_used_at=$3 WHERE id=$1`,
+		id, int64(signCount), time.Now().UTC())
+	return err
+}
+
+func (r *passkeyRepository) Delete(ctx context.Context, userID string, id int64) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM passkeys WHERE user_id=$1 AND id=$2`, userID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
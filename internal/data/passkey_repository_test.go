@@ -0,0 +1,58 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestPasskeyRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewPasskeyRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	user := &models.User{ID: "user-passkeys-1", Email: "passkeys@example.com", CreatedAt: time.Now()}
+	if err := db.Create(ctx, user); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+
+	p := &models.Passkey{UserID: user.ID, CredentialID: "cred-1", PublicKey: []byte{1, 2, 3}, Algorithm: -7, SignCount: 4, Name: "laptop"}
+	if err := repo.Create(ctx, p); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if p.ID == 0 || p.CreatedAt.IsZero() {
+		t.Fatalf("expected ID and CreatedAt to be set, got %+v", p)
+	}
+	dup := &models.Passkey{UserID: user.ID, CredentialID: "cred-1", PublicKey: []byte{1}, Algorithm: -7, Name: "again"}
+	if err := repo.Create(ctx, dup); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("expected a duplicate credential to be rejected, got %v", err)
+	}
+
+	if err := repo.RecordUse(ctx, p.ID, 9); err != nil {
+		t.Fatalf("RecordUse failed: %v", err)
+	}
+	got, err := repo.GetByCredentialID(ctx, user.ID, "cred-1")
+	if err != nil || got.SignCount != 9 || got.LastUsedAt == nil || string(got.PublicKey) != "\x01\x02\x03" {
+		t.Fatalf("expected the passkey with the new sign count, got %+v, %v", got, err)
+	}
+	if _, err := repo.GetByCredentialID(ctx, "someone-else", "cred-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected another user's lookup to fail, got %v", err)
+	}
+	list, err := repo.ListByUser(ctx, user.ID)
+	if err != nil || len(list) != 1 || list[0].Name != "laptop" {
+		t.Fatalf("expected one passkey, got %+v, %v", list, err)
+	}
+
+	if err := repo.Delete(ctx, "someone-else", p.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected another user's delete to fail, got %v", err)
+	}
+	if err := repo.Delete(ctx, user.ID, p.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if list, _ := repo.ListByUser(ctx, user.ID); len(list) != 0 {
+		t.Errorf("expected no passkeys after delete, got %+v", list)
+	}
+}
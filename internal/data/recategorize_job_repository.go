@@ -0,0 +1,121 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RecategorizeJobRepository stores batch re-categorization jobs and the message statistics used
+// to size them
+type RecategorizeJobRepository interface {
+	// CreateJob inserts a queued job; ID and CreatedAt are filled in
+	CreateJob(ctx context.Context, job *models.RecategorizeJob) error
+	// GetJob returns ErrNotFound if there is no job with the given ID
+	GetJob(ctx context.Context, id int64) (*models.RecategorizeJob, error)
+	// UpdateJob writes the job's status, progress, error and timestamps
+	UpdateJob(ctx context.Context, job *models.RecategorizeJob) error
+	// ActiveJob returns the queued or running job for userID ("" for all-users jobs), or nil
+	ActiveJob(ctx context.Context, userID string) (*models.RecategorizeJob, error)
+
+	// UsersWithMessages returns the IDs of users with cached messages
+	UsersWithMessages(ctx context.Context) ([]string, error)
+	// MessageStats returns how many messages a user has cached and the total length of the
+	// content a categorization prompt includes (sender, subject and body or snippet)
+	MessageStats(ctx context.Context, userID string, maxBody int) (count, contentChars int, err error)
+}
+
+type recategorizeJobRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewRecategorizeJobRepositoryFromPool creates a RecategorizeJobRepository using a pgxpool.Pool
+func NewRecategorizeJobRepositoryFromPool(pool *pgxpool.Pool) RecategorizeJobRepository {
+	return &recategorizeJobRepository{pool: pool}
+}
+
+// jobColumns ends with the ID of the queue job running the re-categorization job
+const jobColumns = `id, user_id, requested_by, status, total, processed, failed, ai_messages, estimated_tokens, error, created_at, started_at, finished_at,
+	(SELECT COALESCE(MAX(q.id), 0) FROM jobs q WHERE q.kind='` + models.JobKindRecategorize + `' AND q.ref_id=recategorize_jobs.id)`
+
+func scanJob(row pgx.Row) (*models.RecategorizeJob, error) {
+	var j models.RecategorizeJob
+	if err := row.Scan(&j.ID, &j.UserID, &j.RequestedBy, &j.Status, &j.Total, &j.Processed, &j.Failed,
+		&j.AIMessages, &j.EstimatedTokens, &j.Error, &j.CreatedAt, &j.StartedAt, &j.FinishedAt, &j.QueueJobID); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (r *recategorizeJobRepository) CreateJob(ctx context.Context, j *models.RecategorizeJob) error {
+	if j.Status == "" {
+		j.Status = models.JobQueued
+	}
+	return r.pool.QueryRow(ctx, `INSERT INTO recategorize_jobs (user_id, requested_by, status, total, ai_messages, estimated_tokens)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		j.UserID, j.RequestedBy, j.Status, j.Total, j.AIMessages, j.EstimatedTokens,
+	).Scan(&j.ID, &j.CreatedAt)
+}
+
+func (r *recategorizeJobRepository) GetJob(ctx context.Context, id int64) (*models.RecategorizeJob, error) {
+	j, err := scanJob(r.pool.QueryRow(ctx, `SELECT `+jobColumns+` FROM recategorize_jobs WHERE id=$1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return j, err
+}
+
+func (r *recategorizeJobRepository) UpdateJob(ctx context.Context, j *models.RecategorizeJob) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE recategorize_jobs SET status=$2, total=$3, processed=$4, failed=$5,
+		error=$6, started_at=$7, finished_at=$8 WHERE id=$1`,
+		j.ID, j.Status, j.Total, j.Processed, j.Failed, j.Error, j.StartedAt, j.FinishedAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *recategorizeJobRepository) ActiveJob(ctx context.Context, userID string) (*models.RecategorizeJob, error) {
+	j, err := scanJob(r.pool.QueryRow(ctx, `SELECT `+jobColumns+` FROM recategorize_jobs
+		WHERE user_id=$1 AND status IN ('queued', 'running') ORDER BY id LIMIT 1`, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return j, err
+}
+
+func (r *recategorizeJobRepository) UsersWithMessages(ctx context.Context) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `SELECT DISTINCT user_id FROM email_messages ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+func (r *recategorizeJobRepository) MessageStats(ctx context.Context, userID string, maxBody int) (int, int, error) {
+	var count, chars int
+	// Compressed bodies cannot be measured in SQL and count as maxBody, an upper bound
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*), COALESCE(SUM(
+			LENGTH(COALESCE(sender, '')) + LENGTH(COALESCE(subject, '')) +
+			LEAST(CASE
+				WHEN body IS NULL THEN LENGTH(COALESCE(snippet, ''))
+				WHEN get_byte(body, 0) = 0 THEN LENGTH(body) - 1
+				ELSE $2 END, $2)), 0)
+		FROM email_messages WHERE user_id=$1`, userID, maxBody).Scan(&count, &chars)
+	return count, chars, err
+}
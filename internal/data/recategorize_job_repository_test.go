@@ -0,0 +1,71 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestRecategorizeJobRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewRecategorizeJobRepositoryFromPool(db.Pool)
+	messages := NewEmailMessageRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "recat-user-1"
+
+	for _, msg := range []*models.EmailMessage{
+		{UserID: userID, EmailMessageID: "m1", ThreadID: "t1", Sender: "a@x.example", Subject: "hello", Body: "0123456789"},
+		{UserID: userID, EmailMessageID: "m2", ThreadID: "t2", Sender: "b@x.example", Subject: "hi", Snippet: "snip"},
+	} {
+		if err := messages.UpsertMessage(ctx, msg); err != nil {
+			t.Fatalf("UpsertMessage failed: %v", err)
+		}
+	}
+	count, chars, err := repo.MessageStats(ctx, userID, 5)
+	if err != nil {
+		t.Fatalf("MessageStats failed: %v", err)
+	}
+	// Bodies are capped at 5 characters: (11+5+5) + (11+2+4)
+	if count != 2 || chars != 38 {
+		t.Errorf("expected 2 messages and 38 chars, got %d and %d", count, chars)
+	}
+	users, err := repo.UsersWithMessages(ctx)
+	if err != nil || len(users) == 0 {
+		t.Errorf("expected users with messages, got %v (err %v)", users, err)
+	}
+
+	job := &models.RecategorizeJob{UserID: userID, RequestedBy: userID, Total: 2, AIMessages: 2, EstimatedTokens: 100}
+	if err := repo.CreateJob(ctx, job); err != nil {
+		t.Fatalf("CreateJob failed: %v", err)
+	}
+	if job.ID == 0 || job.Status != models.JobQueued {
+		t.Errorf("unexpected job after create %+v", job)
+	}
+	active, err := repo.ActiveJob(ctx, userID)
+	if err != nil || active == nil || active.ID != job.ID {
+		t.Errorf("expected active job %d, got %+v (err %v)", job.ID, active, err)
+	}
+
+	now := time.Now().UTC()
+	job.Status, job.Processed, job.StartedAt, job.FinishedAt = models.JobCompleted, 2, &now, &now
+	if err := repo.UpdateJob(ctx, job); err != nil {
+		t.Fatalf("UpdateJob failed: %v", err)
+	}
+	got, err := repo.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("GetJob failed: %v", err)
+	}
+	if got.Status != models.JobCompleted || got.Processed != 2 || got.FinishedAt == nil {
+		t.Errorf("unexpected job after update %+v", got)
+	}
+	if active, _ := repo.ActiveJob(ctx, userID); active != nil {
+		t.Errorf("expected no active job once completed, got %+v", active)
+	}
+	if _, err := repo.GetJob(ctx, job.ID+1000); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for missing job, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ReportRepository stores weekly report settings and the log of sent reports. Enabled settings
+// with a due next_due_at are the report queue.
+type ReportRepository interface {
+	// GetSettings returns the user's report settings, or defaults if none were saved
+	GetSettings(ctx context.Context, userID string) (*models.ReportSettings, error)
+	// SaveSettings inserts or replaces the user's report settings, including the schedule
+	SaveSettings(ctx context.Context, settings *models.ReportSettings) error
+	// ClaimDue returns the enabled settings due longest before now and moves their next_due_at
+	// to leaseUntil, so a worker that stops mid-send is retried then; nil when none is due.
+	// Concurrent workers never claim the same settings.
+	ClaimDue(ctx context.Context, now, leaseUntil time.Time) (*models.ReportSettings, error)
+	// Reschedule sets when the user's next report is due and, if sentAt is not nil, when the
+	// last one was sent. It does nothing if reports were turned off meanwhile.
+	Reschedule(ctx context.Context, userID string, next time.Time, sentAt *time.Time) error
+	// LogDelivery records a send attempt; ID and CreatedAt are filled in
+	LogDelivery(ctx context.Context, delivery *models.ReportDelivery) error
+	// ListDeliveries returns the user's most recent send attempts, newest first
+	ListDeliveries(ctx context.Context, userID string, limit int) ([]*models.ReportDelivery, error)
+	// Pending returns how many reports are due at now and since when the oldest has been due
+	Pending(ctx context.Context, now time.Time) (int, *time.Time, error)
+}
+
+type reportRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewReportRepositoryFromPool creates a ReportRepository using a pgxpool.Pool
+func NewReportRepositoryFromPool(pool *pgxpool.Pool) ReportRepository {
+	return &reportRepository{pool: pool}
+}
+
+const reportSettingsColumns = `user_id, enabled, via, weekday, subject, intro, sections, next_due_at, last_sent_at, updated_at`
+
+func scanReportSettings(row pgx.Row) (*models.ReportSettings, error) {
+	var s models.ReportSettings
+	if err := row.Scan(&s.UserID, &s.Enabled, &s.Via, &s.Weekday, &s.Subject, &s.Intro, &s.Sections,
+		&s.NextDueAt, &s.LastSentAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *reportRepository) GetSettings(ctx context.Context, userID string) (*models.ReportSettings, error) {
+	s, err := scanReportSettings(r.pool.QueryRow(ctx, `SELECT `+reportSettingsColumns+` FROM report_settings
+		WHERE user_id=$1`, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &models.ReportSettings{UserID: userID, Via: models.ReportViaAccount, Weekday: int(time.Monday)}, nil
+	}
+	return s, err
+}
+
+func (r *reportRepository) SaveSettings(ctx context.Context, s *models.ReportSettings) error {
+	sections := s.Sections
+	if sections == nil {
+		sections = []string{}
+	}
+	return r.pool.QueryRow(ctx, `INSERT INTO report_settings (user_id, enabled, via, weekday, subject, intro, sections,
+			next_due_at, last_sent_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET enabled=EXCLUDED.enabled, via=EXCLUDED.via, weekday=EXCLUDED.weekday,
+			subject=EXCLUDED.subject, intro=EXCLUDED.intro, sections=EXCLUDED.sections,
+			next_due_at=EXCLUDED.next_due_at, last_sent_at=EXCLUDED.last_sent_at, updated_at=NOW()
+		RETURNING updated_at`,
+		s.UserID, s.Enabled, s.Via, s.Weekday, s.Subject, s.Intro, sections, utcOrNil(s.NextDueAt), utcOrNil(s.LastSentAt),
+	).Scan(&s.UpdatedAt)
+}
+
+func (r *reportRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time) (*models.ReportSettings, error) {
+	s, err := scanReportSettings(r.pool.QueryRow(ctx, `UPDATE report_settings SET next_due_at=$2
+		WHERE user_id = (SELECT user_id FROM report_settings
+			WHERE enabled AND next_due_at <= $1
+			ORDER BY next_due_at LIMIT 1 FOR UPDATE SKIP LOCKED)
+		RETURNING `+reportSettingsColumns, now.UTC(), leaseUntil.UTC()))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return s, err
+}
+
+func (r *reportRepository) Reschedule(ctx context.Context, userID string, next time.Time, sentAt *time.Time) error {
+	_, err := r.pool.Exec(ctx, `UPDATE report_settings SET next_due_at=$2, last_sent_at=COALESCE($3, last_sent_at)
+		WHERE user_id=$1 AND enabled`, userID, next.UTC(), utcOrNil(sentAt))
+	return err
+}
+
+func (r *reportRepository) LogDelivery(ctx context.Context, d *models.ReportDelivery) error {
+	return r.pool.QueryRow(ctx, `INSERT INTO report_deliveries (user_id, via, recipient, subject, status, error, period_start)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`,
+		d.UserID, d.Via, d.Recipient, d.Subject, d.Status, d.Error, d.PeriodStart.UTC(),
+	).Scan(&d.ID, &d.CreatedAt)
+}
+
+func (r *reportRepository) ListDeliveries(ctx context.Context, userID string, limit int) ([]*models.ReportDelivery, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id, user_id, via, recipient, subject, status, error, period_start, created_at
+		FROM report_deliveries WHERE user_id=$1 ORDER BY id DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*models.ReportDelivery
+	for rows.Next() {
+		var d models.ReportDelivery
+		if err := rows.Scan(&d.ID, &d.UserID, &d.Via, &d.Recipient, &d.Subject, &d.Status, &d.Error,
+			&d.PeriodStart, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &d)
+	}
+	return out, rows.Err()
+}
+
+func (r *reportRepository) Pending(ctx context.Context, now time.Time) (int, *time.Time, error) {
+	var count int
+	var oldest *time.Time
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*), MIN(next_due_at) FROM report_settings
+		WHERE enabled AND next_due_at <= $1`, now.UTC()).Scan(&count, &oldest)
+	return count, oldest, err
+}
+
+// utcOrNil converts t to UTC for a TIMESTAMP column, keeping nil as NULL
+func utcOrNil(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
@@ -0,0 +1,63 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestReportRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewReportRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	user := &models.User{ID: "user-report-1", Email: "report@example.com", CreatedAt: time.Now()}
+	if err := db.Create(ctx, user); err != nil {
+		t.Fatalf("Create user failed: %v", err)
+	}
+
+	defaults, err := repo.GetSettings(ctx, user.ID)
+	if err != nil || defaults.Enabled || defaults.Via != models.ReportViaAccount || defaults.Weekday != int(time.Monday) {
+		t.Fatalf("GetSettings defaults = %+v, %v", defaults, err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	due := now.Add(-time.Minute)
+	settings := &models.ReportSettings{UserID: user.ID, Enabled: true, Via: models.ReportViaSMTP, Weekday: 3,
+		Subject: "Week", Sections: []string{models.ReportSectionArchived}, NextDueAt: &due}
+	if err := repo.SaveSettings(ctx, settings); err != nil {
+		t.Fatalf("SaveSettings failed: %v", err)
+	}
+	if n, oldest, err := repo.Pending(ctx, now); err != nil || n != 1 || oldest == nil {
+		t.Errorf("Pending = %d, %v, %v", n, oldest, err)
+	}
+
+	lease := now.Add(time.Hour)
+	claimed, err := repo.ClaimDue(ctx, now, lease)
+	if err != nil || claimed == nil || claimed.Subject != "Week" || len(claimed.Sections) != 1 || !claimed.NextDueAt.Equal(lease) {
+		t.Fatalf("ClaimDue = %+v, %v", claimed, err)
+	}
+	if again, err := repo.ClaimDue(ctx, now, lease); err != nil || again != nil {
+		t.Errorf("second ClaimDue = %+v, %v", again, err)
+	}
+
+	next := now.Add(7 * 24 * time.Hour)
+	if err := repo.Reschedule(ctx, user.ID, next, &now); err != nil {
+		t.Fatalf("Reschedule failed: %v", err)
+	}
+	got, err := repo.GetSettings(ctx, user.ID)
+	if err != nil || !got.NextDueAt.Equal(next) || got.LastSentAt == nil || !got.LastSentAt.Equal(now) {
+		t.Errorf("GetSettings after Reschedule = %+v, %v", got, err)
+	}
+
+	d := &models.ReportDelivery{UserID: user.ID, Via: models.ReportViaSMTP, Recipient: user.Email, Subject: "Week",
+		Status: models.ReportSent, PeriodStart: now.Add(-7 * 24 * time.Hour)}
+	if err := repo.LogDelivery(ctx, d); err != nil || d.ID == 0 {
+		t.Fatalf("LogDelivery = %v, %+v", err, d)
+	}
+	if list, err := repo.ListDeliveries(ctx, user.ID, 10); err != nil || len(list) != 1 || list[0].Status != models.ReportSent {
+		t.Errorf("ListDeliveries = %+v, %v", list, err)
+	}
+}
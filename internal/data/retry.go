@@ -0,0 +1,74 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/telemetry/metrics"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// BackgroundWriteAttempts is how often RetryBackgroundWrite tries a write before dropping it
+const BackgroundWriteAttempts = 3
+
+// backgroundWriteBackoff is the delay before the first retry; it doubles after each attempt
+// and is jittered by ±50% so writes that failed together do not retry together
+var backgroundWriteBackoff = 100 * time.Millisecond
+
+// transientSQLStates are Postgres errors that may succeed when retried: serialization
+// failures, deadlocks, too many connections and server shutdowns
+var transientSQLStates = map[string]bool{
+	"40001": true,
+	"40P01": true,
+	"53300": true,
+	"57P01": true,
+	"57P03": true,
+}
+
+// IsTransient reports whether err is a database error that may succeed if retried, such as a
+// lost connection or an exhausted pool
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || pgconn.SafeToRetry(err) || pgconn.Timeout(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Class 08 is connection exceptions
+		return transientSQLStates[pgErr.Code] || len(pgErr.Code) == 5 && pgErr.Code[:2] == "08"
+	}
+	var connErr *pgconn.ConnectError
+	return errors.As(err, &connErr)
+}
+
+// RetryBackgroundWrite runs a fire-and-forget write, retrying transient errors up to
+// BackgroundWriteAttempts times with jittered backoff. It stops early when ctx is done. A
+// write that still fails is counted as dropped under op and its last error returned, for
+// the caller to log.
+func RetryBackgroundWrite(ctx context.Context, op string, write func(ctx context.Context) error) error {
+	delay := backgroundWriteBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = write(ctx); err == nil {
+			return nil
+		}
+		if attempt == BackgroundWriteAttempts || !IsTransient(err) {
+			break
+		}
+		t := time.NewTimer(delay/2 + rand.N(delay))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			metrics.ObserveDroppedWrite(op)
+			return errors.Join(err, ctx.Err())
+		case <-t.C:
+		}
+		delay *= 2
+	}
+	metrics.ObserveDroppedWrite(op)
+	return err
+}
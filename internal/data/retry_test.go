@@ -0,0 +1,103 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/telemetry/metrics"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("boom"), false},
+		{ErrNotFound, false},
+		{context.DeadlineExceeded, true},
+		{fmt.Errorf("upsert: %w", &pgconn.PgError{Code: "53300"}), true},
+		{&pgconn.PgError{Code: "40001"}, true},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{&pgconn.PgError{Code: "23505"}, false},
+	}
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryBackgroundWrite(t *testing.T) {
+	defer func(d time.Duration) { backgroundWriteBackoff = d }(backgroundWriteBackoff)
+	backgroundWriteBackoff = time.Millisecond
+	transient := &pgconn.PgError{Code: "53300"}
+
+	t.Run("recovers", func(t *testing.T) {
+		calls := 0
+		err := RetryBackgroundWrite(context.Background(), "test_recovers", func(ctx context.Context) error {
+			calls++
+			if calls < BackgroundWriteAttempts {
+				return transient
+			}
+			return nil
+		})
+		if err != nil || calls != BackgroundWriteAttempts {
+			t.Errorf("got %v after %d calls", err, calls)
+		}
+		if dropped(t, "test_recovers") {
+			t.Error("recovered write counted as dropped")
+		}
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		calls := 0
+		err := RetryBackgroundWrite(context.Background(), "test_gives_up", func(ctx context.Context) error {
+			calls++
+			return transient
+		})
+		if !errors.Is(err, transient) || calls != BackgroundWriteAttempts {
+			t.Errorf("got %v after %d calls", err, calls)
+		}
+		if !dropped(t, "test_gives_up") {
+			t.Error("expected the write to be counted as dropped")
+		}
+	})
+
+	t.Run("permanent errors are not retried", func(t *testing.T) {
+		calls := 0
+		_ = RetryBackgroundWrite(context.Background(), "test_permanent", func(ctx context.Context) error {
+			calls++
+			return &pgconn.PgError{Code: "23505"}
+		})
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+	})
+
+	t.Run("stops when canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		err := RetryBackgroundWrite(ctx, "test_canceled", func(ctx context.Context) error {
+			calls++
+			cancel()
+			return transient
+		})
+		if !errors.Is(err, context.Canceled) || calls != 1 {
+			t.Errorf("got %v after %d calls", err, calls)
+		}
+	})
+}
+
+func dropped(t *testing.T, op string) bool {
+	t.Helper()
+	var buf bytes.Buffer
+	metrics.Write(&buf)
+	return strings.Contains(buf.String(), `inbox_whisperer_background_writes_dropped_total{op="`+op+`"} 1`)
+}
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ReviewSessionRepository stores review sessions and their queues
+type ReviewSessionRepository interface {
+	// Create queues up to limit of the user's oldest unhandled messages: unread, not acted on
+	// through the API and not decided in an earlier session other than by skipping. A session
+	// with nothing to review is created completed.
+	Create(ctx context.Context, userID string, limit int) (*models.ReviewSession, error)
+	// Get returns ErrNotFound if the user has no such session
+	Get(ctx context.Context, userID string, id int64) (*models.ReviewSession, error)
+	// NextItem returns the first pending item, or ErrNotFound once every item is decided
+	NextItem(ctx context.Context, userID string, id int64) (*models.ReviewItem, error)
+	// Decide records the decision on a pending message, completing the session with its last
+	// one. Returns ErrNotFound if the message is not pending in the session.
+	Decide(ctx context.Context, userID string, id int64, emailMessageID, action string) error
+}
+
+type reviewSessionRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewReviewSessionRepositoryFromPool creates a ReviewSessionRepository using a pgxpool.Pool
+func NewReviewSessionRepositoryFromPool(pool *pgxpool.Pool) ReviewSessionRepository {
+	return &reviewSessionRepository{pool: pool}
+}
+
+// completeReviewSession marks session $1 completed once none of its items is pending
+const completeReviewSession = `UPDATE review_sessions SET completed_at = now()
+	WHERE id=$1 AND completed_at IS NULL
+	AND NOT EXISTS (SELECT 1 FROM review_session_items WHERE session_id=$1 AND action='')`
+
+func (r *reviewSessionRepository) Create(ctx context.Context, userID string, limit int) (*models.ReviewSession, error) {
+	var id int64
+	err := withTx(ctx, r.pool, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, `INSERT INTO review_sessions (user_id) VALUES ($1) RETURNING id`, userID).Scan(&id); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `INSERT INTO review_session_items (session_id, position, email_message_id)
+			SELECT $2, row_number() OVER (ORDER BY internal_date, email_message_id), email_message_id
+			FROM (SELECT m.email_message_id, m.internal_date FROM email_messages m
+				WHERE m.user_id=$1 AND NOT m.is_read
+				AND NOT EXISTS (SELECT 1 FROM user_actions a WHERE a.user_id=$1 AND a.message_id=m.email_message_id)
+				AND NOT EXISTS (SELECT 1 FROM review_session_items i JOIN review_sessions s ON s.id=i.session_id
+					WHERE s.user_id=$1 AND i.email_message_id=m.email_message_id AND i.action NOT IN ('', 'skip'))
+				ORDER BY m.internal_date, m.email_message_id LIMIT $3) queued`, userID, id, limit)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, completeReviewSession, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, userID, id)
+}
+
+func (r *reviewSessionRepository) Get(ctx context.Context, userID string, id int64) (*models.ReviewSession, error) {
+	s := &models.ReviewSession{UserID: userID}
+	err := r.pool.QueryRow(ctx, `SELECT s.id, s.created_at, s.completed_at,
+			count(i.position), count(i.position) FILTER (WHERE i.action <> '')
+		FROM review_sessions s LEFT JOIN review_session_items i ON i.session_id = s.id
+		WHERE s.id=$2 AND s.user_id=$1 GROUP BY s.id`, userID, id).
+		Scan(&s.ID, &s.CreatedAt, &s.CompletedAt, &s.Total, &s.Reviewed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *reviewSessionRepository) NextItem(ctx context.Context, userID string, id int64) (*models.ReviewItem, error) {
+	item := &models.ReviewItem{}
+	err := r.pool.QueryRow(ctx, `SELECT i.position, i.email_message_id
+		FROM review_session_items i JOIN review_sessions s ON s.id = i.session_id
+		WHERE s.id=$2 AND s.user_id=$1 AND i.action=''
+		ORDER BY i.position LIMIT 1`, userID, id).Scan(&item.Position, &item.EmailMessageID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *reviewSessionRepository) Decide(ctx context.Context, userID string, id int64, emailMessageID, action string) error {
+	return withTx(ctx, r.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `UPDATE review_session_items i SET action=$4, acted_at=now()
+			FROM review_sessions s
+			WHERE s.id = i.session_id AND s.id=$2 AND s.user_id=$1 AND i.email_message_id=$3 AND i.action=''`,
+			userID, id, emailMessageID, action)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+		_, err = tx.Exec(ctx, completeReviewSession, id)
+		return err
+	})
+}
@@ -0,0 +1,86 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestReviewSessionRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewReviewSessionRepositoryFromPool(db.Pool)
+	messages := NewEmailMessageRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+
+	user := &models.User{ID: "review-user", Email: "review@example.com", CreatedAt: time.Now()}
+	if err := db.Create(ctx, user); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	for i, m := range []*models.EmailMessage{
+		{EmailMessageID: "old", InternalDate: 100},
+		{EmailMessageID: "older", InternalDate: 50},
+		{EmailMessageID: "read", InternalDate: 10, IsRead: true},
+		{EmailMessageID: "acted", InternalDate: 20},
+		{EmailMessageID: "newest", InternalDate: 300},
+	} {
+		m.ID = int64(i + 1)
+		m.UserID = user.ID
+		m.ThreadID = "t-" + m.EmailMessageID
+		if err := messages.UpsertMessage(ctx, m); err != nil {
+			t.Fatalf("UpsertMessage failed: %v", err)
+		}
+	}
+	if err := NewSuggestionRepositoryFromPool(db.Pool).RecordAction(ctx, &models.UserAction{UserID: user.ID, Action: models.UserActionArchive, MessageID: "acted"}); err != nil {
+		t.Fatalf("RecordAction failed: %v", err)
+	}
+
+	s, err := repo.Create(ctx, user.ID, 2)
+	if err != nil {
+		t.Fatalf("Create session failed: %v", err)
+	}
+	if s.Total != 2 || s.Reviewed != 0 || s.CompletedAt != nil {
+		t.Errorf("expected 2 pending messages, got %+v", s)
+	}
+	item, err := repo.NextItem(ctx, user.ID, s.ID)
+	if err != nil || item.EmailMessageID != "older" {
+		t.Fatalf("expected the oldest unread, unhandled message first, got %+v (err %v)", item, err)
+	}
+	if _, err := repo.Get(ctx, "someone-else", s.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for another user's session, got %v", err)
+	}
+	if err := repo.Decide(ctx, user.ID, s.ID, "newest", models.ReviewKeep); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound deciding a message not queued, got %v", err)
+	}
+	if err := repo.Decide(ctx, user.ID, s.ID, "older", models.ReviewKeep); err != nil {
+		t.Fatalf("Decide failed: %v", err)
+	}
+	if err := repo.Decide(ctx, user.ID, s.ID, "older", models.ReviewSkip); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound deciding twice, got %v", err)
+	}
+	if err := repo.Decide(ctx, user.ID, s.ID, "old", models.ReviewSkip); err != nil {
+		t.Fatalf("Decide failed: %v", err)
+	}
+	if _, err := repo.NextItem(ctx, user.ID, s.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound once every message is decided, got %v", err)
+	}
+	s, err = repo.Get(ctx, user.ID, s.ID)
+	if err != nil || s.Reviewed != 2 || s.CompletedAt == nil {
+		t.Errorf("expected the session completed, got %+v (err %v)", s, err)
+	}
+
+	// Kept messages are done with; skipped ones come back
+	next, err := repo.Create(ctx, user.ID, 10)
+	if err != nil {
+		t.Fatalf("Create session failed: %v", err)
+	}
+	if next.Total != 2 {
+		t.Errorf("expected the skipped and the newest message queued, got %+v", next)
+	}
+	if item, err := repo.NextItem(ctx, user.ID, next.ID); err != nil || item.EmailMessageID != "old" {
+		t.Errorf("expected the skipped message first, got %+v (err %v)", item, err)
+	}
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RuleRepository stores user-defined rules
+type RuleRepository interface {
+	Create(ctx context.Context, rule *models.Rule) error
+	ListByUser(ctx context.Context, userID string) ([]*models.Rule, error)
+	// Delete returns ErrNotFound if the user has no rule with the given ID
+	Delete(ctx context.Context, userID string, id int64) error
+}
+
+type ruleRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewRuleRepositoryFromPool creates a RuleRepository using a pgxpool.Pool
+func NewRuleRepositoryFromPool(pool *pgxpool.Pool) RuleRepository {
+	return &ruleRepository{pool: pool}
+}
+
+// Create inserts the rule and fills in its ID and CreatedAt
+func (r *ruleRepository) Create(ctx context.Context, rule *models.Rule) error {
+	conditions, err := json.Marshal(rule.Conditions)
+	if err != nil {
+		return err
+	}
+	actions, err := json.Marshal(rule.Actions)
+	if err != nil {
+		return err
+	}
+	return r.pool.QueryRow(ctx, `INSERT INTO rules (user_id, name, conditions, actions, enabled)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`,
+		rule.UserID, rule.Name, conditions, actions, rule.Enabled,
+	).Scan(&rule.ID, &rule.CreatedAt)
+}
+
+func (r *ruleRepository) ListByUser(ctx context.Context, userID string) ([]*models.Rule, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id, user_id, name, conditions, actions, enabled, created_at
+		FROM rules WHERE user_id=$1 ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rules []*models.Rule
+	for rows.Next() {
+		var rule models.Rule
+		var conditions, actions []byte
+		if err := rows.Scan(&rule.ID, &rule.UserID, &rule.Name, &conditions, &actions, &rule.Enabled, &rule.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(conditions, &rule.Conditions); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(actions, &rule.Actions); err != nil {
+			return nil, err
+		}
+		rules = append(rules, &rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *ruleRepository) Delete(ctx context.Context, userID string, id int64) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM rules WHERE user_id=$1 AND id=$2`, userID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestRuleRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewRuleRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "user-rules-1"
+
+	rule := &models.Rule{
+		UserID:     userID,
+		Name:       "Receipts",
+		Conditions: []models.RuleCondition{{Field: models.RuleFieldSubject, Contains: "receipt"}},
+		Actions:    []models.RuleAction{{Type: models.RuleActionApplyLabel, Label: "Finance/Receipts"}},
+		Enabled:    true,
+	}
+	if err := repo.Create(ctx, rule); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if rule.ID == 0 || rule.CreatedAt.IsZero() {
+		t.Fatalf("expected ID and CreatedAt to be set, got %+v", rule)
+	}
+
+	rules, err := repo.ListByUser(ctx, userID)
+	if err != nil {
+		t.Fatalf("ListByUser failed: %v", err)
+	}
+	if len(rules) != 1 || rules[0].Actions[0].Label != "Finance/Receipts" || rules[0].Conditions[0].Contains != "receipt" {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+
+	if err := repo.Delete(ctx, "other-user", rule.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting another user's rule, got %v", err)
+	}
+	if err := repo.Delete(ctx, userID, rule.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+}
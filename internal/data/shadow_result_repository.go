@@ -0,0 +1,76 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ShadowResultRepository stores shadow categorizations and aggregates them into agreement reports
+type ShadowResultRepository interface {
+	// RecordShadowResult stores the result, replacing an earlier one for the message and shadow version
+	RecordShadowResult(ctx context.Context, res *models.ShadowResult) error
+	// Agreement reports per shadow and active version pair over results recorded since
+	Agreement(ctx context.Context, since time.Time) ([]models.ShadowAgreement, error)
+}
+
+type shadowResultRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewShadowResultRepositoryFromPool creates a ShadowResultRepository using a pgxpool.Pool
+func NewShadowResultRepositoryFromPool(pool *pgxpool.Pool) ShadowResultRepository {
+	return &shadowResultRepository{pool: pool}
+}
+
+func (r *shadowResultRepository) RecordShadowResult(ctx context.Context, res *models.ShadowResult) error {
+	_, err := r.pool.Exec(ctx, `INSERT INTO categorizer_shadow_results
+		(user_id, message_id, shadow_version, active_version, active_category, shadow_category, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (user_id, message_id, shadow_version) DO UPDATE SET
+		active_version = EXCLUDED.active_version,
+		active_category = EXCLUDED.active_category,
+		shadow_category = EXCLUDED.shadow_category,
+		created_at = NOW()`,
+		res.UserID, res.MessageID, res.ShadowVersion, res.ActiveVersion, res.ActiveCategory, res.ShadowCategory)
+	return err
+}
+
+func (r *shadowResultRepository) Agreement(ctx context.Context, since time.Time) ([]models.ShadowAgreement, error) {
+	rows, err := r.pool.Query(ctx, `SELECT shadow_version, active_version, active_category,
+		COUNT(*), COUNT(*) FILTER (WHERE shadow_category = active_category)
+		FROM categorizer_shadow_results WHERE created_at >= $1
+		GROUP BY shadow_version, active_version, active_category
+		ORDER BY shadow_version, active_version, active_category`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.ShadowAgreement{}
+	for rows.Next() {
+		var shadow, active string
+		var c models.ShadowCategoryAgreement
+		if err := rows.Scan(&shadow, &active, &c.Category, &c.Total, &c.Agreed); err != nil {
+			return nil, err
+		}
+		c.AgreementRate = agreementRate(c.Agreed, c.Total)
+		if n := len(out); n == 0 || out[n-1].ShadowVersion != shadow || out[n-1].ActiveVersion != active {
+			out = append(out, models.ShadowAgreement{ShadowVersion: shadow, ActiveVersion: active})
+		}
+		a := &out[len(out)-1]
+		a.Total += c.Total
+		a.Agreed += c.Agreed
+		a.AgreementRate = agreementRate(a.Agreed, a.Total)
+		a.ByCategory = append(a.ByCategory, c)
+	}
+	return out, rows.Err()
+}
+
+func agreementRate(agreed, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(agreed) / float64(total)
+}
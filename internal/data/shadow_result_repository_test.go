@@ -0,0 +1,38 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestShadowResultRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewShadowResultRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	since := time.Now().Add(-time.Hour)
+
+	for _, res := range []models.ShadowResult{
+		{UserID: "shadow-user", MessageID: "m1", ActiveCategory: "Primary", ShadowCategory: "Primary"},
+		{UserID: "shadow-user", MessageID: "m2", ActiveCategory: "Primary", ShadowCategory: "Updates"},
+		{UserID: "shadow-user", MessageID: "m3", ActiveCategory: "Updates", ShadowCategory: "Updates"},
+	} {
+		res.ActiveVersion, res.ShadowVersion = "gpt-a", "gpt-b"
+		if err := repo.RecordShadowResult(ctx, &res); err != nil {
+			t.Fatalf("RecordShadowResult failed: %v", err)
+		}
+	}
+	report, err := repo.Agreement(ctx, since)
+	if err != nil {
+		t.Fatalf("Agreement failed: %v", err)
+	}
+	if len(report) != 1 || report[0].Total != 3 || report[0].Agreed != 2 || len(report[0].ByCategory) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if primary := report[0].ByCategory[0]; primary.Category != "Primary" || primary.AgreementRate != 0.5 {
+		t.Errorf("unexpected Primary agreement %+v", primary)
+	}
+}
@@ -0,0 +1,67 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// StatusCheckRepository stores the results of the public status page's health checks
+type StatusCheckRepository interface {
+	// Record stores one check run: the state of each component at checkedAt
+	Record(ctx context.Context, checkedAt time.Time, states map[string]string) error
+	// Uptime returns, per component, the percentage of checks since the given time that did not
+	// find it down. Components without checks in the window are left out.
+	Uptime(ctx context.Context, since time.Time) (map[string]float64, error)
+	// Prune deletes the checks made before the given time and returns how many it deleted
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+type statusCheckRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewStatusCheckRepositoryFromPool creates a StatusCheckRepository using a pgxpool.Pool
+func NewStatusCheckRepositoryFromPool(pool *pgxpool.Pool) StatusCheckRepository {
+	return &statusCheckRepository{pool: pool}
+}
+
+func (r *statusCheckRepository) Record(ctx context.Context, checkedAt time.Time, states map[string]string) error {
+	components := make([]string, 0, len(states))
+	values := make([]string, 0, len(states))
+	for component, state := range states {
+		components = append(components, component)
+		values = append(values, state)
+	}
+	_, err := r.pool.Exec(ctx, `INSERT INTO status_checks (component, state, checked_at)
+		SELECT c, s, $3 FROM unnest($1::text[], $2::text[]) AS t(c, s)`, components, values, checkedAt)
+	return err
+}
+
+func (r *statusCheckRepository) Uptime(ctx context.Context, since time.Time) (map[string]float64, error) {
+	rows, err := r.pool.Query(ctx, `SELECT component, 100.0 * count(*) FILTER (WHERE state <> 'down') / count(*)
+		FROM status_checks WHERE checked_at >= $1 GROUP BY component`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]float64{}
+	for rows.Next() {
+		var component string
+		var pct float64
+		if err := rows.Scan(&component, &pct); err != nil {
+			return nil, err
+		}
+		out[component] = pct
+	}
+	return out, rows.Err()
+}
+
+func (r *statusCheckRepository) Prune(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM status_checks WHERE checked_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
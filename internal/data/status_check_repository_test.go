@@ -0,0 +1,33 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStatusCheckRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewStatusCheckRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	for i, state := range []string{"operational", "down", "degraded", "operational"} {
+		at := now.Add(-time.Duration(i) * time.Hour)
+		if err := repo.Record(ctx, at, map[string]string{"api": state, "sync": "operational"}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	up, err := repo.Uptime(ctx, now.Add(-90*time.Minute))
+	if err != nil {
+		t.Fatalf("Uptime failed: %v", err)
+	}
+	if up["api"] != 50 || up["sync"] != 100 {
+		t.Errorf("unexpected uptime %v", up)
+	}
+	n, err := repo.Prune(ctx, now.Add(-150*time.Minute))
+	if err != nil || n != 2 {
+		t.Errorf("expected the oldest run's 2 checks to be pruned, got %d (err %v)", n, err)
+	}
+}
@@ -0,0 +1,154 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SuggestionRepository stores the manual action history and the rule suggestions derived from it
+type SuggestionRepository interface {
+	// RecordAction stores a manual action; the sender is normalized to its address
+	RecordAction(ctx context.Context, action *models.UserAction) error
+	// CountActionsBySender returns senders the action was taken on at least min times
+	CountActionsBySender(ctx context.Context, userID, action string, min int) ([]models.ActionCount, error)
+	// CountActionsByCategory returns categories the action was taken on at least min times
+	CountActionsByCategory(ctx context.Context, userID, action string, min int) ([]models.ActionCount, error)
+	// CountCorrections returns sender/category pairs the user corrected at least min times
+	CountCorrections(ctx context.Context, userID string, min int) ([]models.ActionCount, error)
+
+	// UpsertSuggestion inserts a pending suggestion or refreshes its evidence. Decided suggestions
+	// are left unchanged. ID and Status are filled in from the stored row.
+	UpsertSuggestion(ctx context.Context, s *models.RuleSuggestion) error
+	ListSuggestions(ctx context.Context, userID, status string) ([]*models.RuleSuggestion, error)
+	// GetSuggestion returns ErrNotFound if the user has no suggestion with the given ID
+	GetSuggestion(ctx context.Context, userID string, id int64) (*models.RuleSuggestion, error)
+	SetSuggestionStatus(ctx context.Context, userID string, id int64, status string, ruleID *int64) error
+}
+
+type suggestionRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewSuggestionRepositoryFromPool creates a SuggestionRepository using a pgxpool.Pool
+func NewSuggestionRepositoryFromPool(pool *pgxpool.Pool) SuggestionRepository {
+	return &suggestionRepository{pool: pool}
+}
+
+func (r *suggestionRepository) RecordAction(ctx context.Context, a *models.UserAction) error {
+	a.Sender = senderAddress(a.Sender)
+	return r.pool.QueryRow(ctx, `INSERT INTO user_actions (user_id, action, message_id, sender, category)
+		VALUES ($1, $2, $3, $4, $5) RETURNING created_at`,
+		a.UserID, a.Action, a.MessageID, a.Sender, a.Category,
+	).Scan(&a.CreatedAt)
+}
+
+func (r *suggestionRepository) CountActionsBySender(ctx context.Context, userID, action string, min int) ([]models.ActionCount, error) {
+	return r.counts(ctx, `SELECT sender, '', COUNT(*) FROM user_actions
+		WHERE user_id=$1 AND action=$2 AND sender <> '' GROUP BY sender HAVING COUNT(*) >= $3 ORDER BY COUNT(*) DESC`,
+		userID, action, min)
+}
+
+func (r *suggestionRepository) CountActionsByCategory(ctx context.Context, userID, action string, min int) ([]models.ActionCount, error) {
+	return r.counts(ctx, `SELECT '', category, COUNT(*) FROM user_actions
+		WHERE user_id=$1 AND action=$2 AND category <> '' GROUP BY category HAVING COUNT(*) >= $3 ORDER BY COUNT(*) DESC`,
+		userID, action, min)
+}
+
+func (r *suggestionRepository) CountCorrections(ctx context.Context, userID string, min int) ([]models.ActionCount, error) {
+	return r.counts(ctx, `SELECT sender, category, COUNT(*) FROM category_feedback
+		WHERE user_id=$1 AND NOT correct AND sender <> '' GROUP BY sender, category HAVING COUNT(*) >= $2 ORDER BY COUNT(*) DESC`,
+		userID, min)
+}
+
+func (r *suggestionRepository) counts(ctx context.Context, query string, args ...interface{}) ([]models.ActionCount, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.ActionCount
+	for rows.Next() {
+		var c models.ActionCount
+		if err := rows.Scan(&c.Sender, &c.Category, &c.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (r *suggestionRepository) UpsertSuggestion(ctx context.Context, s *models.RuleSuggestion) error {
+	rule, err := json.Marshal(s.Rule)
+	if err != nil {
+		return err
+	}
+	// The no-op update on decided rows makes RETURNING yield the existing row
+	return r.pool.QueryRow(ctx, `INSERT INTO rule_suggestions (user_id, key, rule, reason, evidence)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, key) DO UPDATE SET
+		rule = CASE WHEN rule_suggestions.status = 'pending' THEN EXCLUDED.rule ELSE rule_suggestions.rule END,
+		reason = CASE WHEN rule_suggestions.status = 'pending' THEN EXCLUDED.reason ELSE rule_suggestions.reason END,
+		evidence = CASE WHEN rule_suggestions.status = 'pending' THEN EXCLUDED.evidence ELSE rule_suggestions.evidence END,
+		updated_at = NOW()
+		RETURNING id, status, created_at`,
+		s.UserID, s.Key, rule, s.Reason, s.Evidence,
+	).Scan(&s.ID, &s.Status, &s.CreatedAt)
+}
+
+const suggestionColumns = `id, user_id, key, rule, reason, evidence, status, rule_id, created_at`
+
+func scanSuggestion(row pgx.Row) (*models.RuleSuggestion, error) {
+	var s models.RuleSuggestion
+	var rule []byte
+	if err := row.Scan(&s.ID, &s.UserID, &s.Key, &rule, &s.Reason, &s.Evidence, &s.Status, &s.RuleID, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rule, &s.Rule); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *suggestionRepository) ListSuggestions(ctx context.Context, userID, status string) ([]*models.RuleSuggestion, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+suggestionColumns+` FROM rule_suggestions
+		WHERE user_id=$1 AND status=$2 ORDER BY evidence DESC, id`, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*models.RuleSuggestion
+	for rows.Next() {
+		s, err := scanSuggestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *suggestionRepository) GetSuggestion(ctx context.Context, userID string, id int64) (*models.RuleSuggestion, error) {
+	s, err := scanSuggestion(r.pool.QueryRow(ctx, `SELECT `+suggestionColumns+` FROM rule_suggestions
+		WHERE user_id=$1 AND id=$2`, userID, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return s, err
+}
+
+func (r *suggestionRepository) SetSuggestionStatus(ctx context.Context, userID string, id int64, status string, ruleID *int64) error {
+	tag, err := r.pool.Exec(ctx, `UPDATE rule_suggestions SET status=$3, rule_id=$4, updated_at=NOW()
+		WHERE user_id=$1 AND id=$2`, userID, id, status, ruleID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestSuggestionRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewSuggestionRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "suggest-user-1"
+
+	for i, id := range []string{"m1", "m2", "m3"} {
+		a := &models.UserAction{UserID: userID, Action: models.UserActionArchive, MessageID: id, Sender: "News <News@Shop.example>", Category: "Promotions/Ads"}
+		if i == 2 {
+			a.Sender = "someone@else.example"
+		}
+		if err := repo.RecordAction(ctx, a); err != nil {
+			t.Fatalf("RecordAction failed: %v", err)
+		}
+	}
+	bySender, err := repo.CountActionsBySender(ctx, userID, models.UserActionArchive, 2)
+	if err != nil {
+		t.Fatalf("CountActionsBySender failed: %v", err)
+	}
+	if len(bySender) != 1 || bySender[0].Sender != "news@shop.example" || bySender[0].Count != 2 {
+		t.Errorf("unexpected sender counts %+v", bySender)
+	}
+	byCategory, err := repo.CountActionsByCategory(ctx, userID, models.UserActionArchive, 3)
+	if err != nil || len(byCategory) != 1 || byCategory[0].Count != 3 {
+		t.Errorf("unexpected category counts %+v (err %v)", byCategory, err)
+	}
+
+	s := &models.RuleSuggestion{UserID: userID, Key: "archive|from|news@shop.example", Reason: "r", Evidence: 2,
+		Rule: models.Rule{Name: "Archive news", Actions: []models.RuleAction{{Type: models.RuleActionArchive}}}}
+	if err := repo.UpsertSuggestion(ctx, s); err != nil {
+		t.Fatalf("UpsertSuggestion failed: %v", err)
+	}
+	if s.ID == 0 || s.Status != models.SuggestionPending {
+		t.Errorf("unexpected suggestion after insert %+v", s)
+	}
+	if err := repo.SetSuggestionStatus(ctx, userID, s.ID, models.SuggestionDismissed, nil); err != nil {
+		t.Fatalf("SetSuggestionStatus failed: %v", err)
+	}
+	again := &models.RuleSuggestion{UserID: userID, Key: s.Key, Reason: "r", Evidence: 5, Rule: s.Rule}
+	if err := repo.UpsertSuggestion(ctx, again); err != nil {
+		t.Fatalf("UpsertSuggestion (again) failed: %v", err)
+	}
+	if again.ID != s.ID || again.Status != models.SuggestionDismissed {
+		t.Errorf("expected dismissed suggestion to stay dismissed, got %+v", again)
+	}
+	pending, err := repo.ListSuggestions(ctx, userID, models.SuggestionPending)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("expected no pending suggestions, got %+v (err %v)", pending, err)
+	}
+	got, err := repo.GetSuggestion(ctx, userID, s.ID)
+	if err != nil || got.Evidence != 2 || got.Rule.Name != "Archive news" {
+		t.Errorf("unexpected stored suggestion %+v (err %v)", got, err)
+	}
+	if _, err := repo.GetSuggestion(ctx, "other-user", s.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for another user's suggestion, got %v", err)
+	}
+}
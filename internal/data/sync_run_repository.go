@@ -0,0 +1,81 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SyncRunRepository stores sync run summaries and aggregates them for the admin stats
+type SyncRunRepository interface {
+	// RecordSyncRun stores the run and sets its ID
+	RecordSyncRun(ctx context.Context, run *models.SyncRun) error
+	// ListSyncRuns returns up to limit runs started since, newest first; an empty userID lists
+	// every user's runs
+	ListSyncRuns(ctx context.Context, userID string, since time.Time, limit int) ([]models.SyncRun, error)
+	// SyncRunStats aggregates runs started since, per provider
+	SyncRunStats(ctx context.Context, since time.Time) ([]models.SyncRunStats, error)
+}
+
+type syncRunRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewSyncRunRepositoryFromPool creates a SyncRunRepository using a pgxpool.Pool
+func NewSyncRunRepositoryFromPool(pool *pgxpool.Pool) SyncRunRepository {
+	return &syncRunRepository{pool: pool}
+}
+
+func (r *syncRunRepository) RecordSyncRun(ctx context.Context, run *models.SyncRun) error {
+	return r.pool.QueryRow(ctx, `INSERT INTO sync_runs
+		(user_id, provider, started_at, duration_ms, listed, fetched, upserted, skipped, errors, quota_hits, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`,
+		run.UserID, run.Provider, run.StartedAt.UTC(), run.DurationMS, run.Listed, run.Fetched, run.Upserted,
+		run.Skipped, run.Errors, run.QuotaHits, run.Error).Scan(&run.ID)
+}
+
+func (r *syncRunRepository) ListSyncRuns(ctx context.Context, userID string, since time.Time, limit int) ([]models.SyncRun, error) {
+	rows, err := r.pool.Query(ctx, `SELECT id, user_id, provider, started_at, duration_ms, listed, fetched,
+		upserted, skipped, errors, quota_hits, error
+		FROM sync_runs WHERE ($1 = '' OR user_id = $1) AND started_at >= $2
+		ORDER BY started_at DESC, id DESC LIMIT $3`, userID, since.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.SyncRun{}
+	for rows.Next() {
+		var run models.SyncRun
+		if err := rows.Scan(&run.ID, &run.UserID, &run.Provider, &run.StartedAt, &run.DurationMS, &run.Listed,
+			&run.Fetched, &run.Upserted, &run.Skipped, &run.Errors, &run.QuotaHits, &run.Error); err != nil {
+			return nil, err
+		}
+		run.StartedAt = run.StartedAt.UTC()
+		out = append(out, run)
+	}
+	return out, rows.Err()
+}
+
+func (r *syncRunRepository) SyncRunStats(ctx context.Context, since time.Time) ([]models.SyncRunStats, error) {
+	rows, err := r.pool.Query(ctx, `SELECT provider, COUNT(*), COUNT(*) FILTER (WHERE error <> ''),
+		COUNT(DISTINCT user_id), SUM(listed), SUM(fetched), SUM(upserted), SUM(skipped), SUM(errors),
+		SUM(quota_hits), AVG(duration_ms)::FLOAT8, MAX(duration_ms)
+		FROM sync_runs WHERE started_at >= $1
+		GROUP BY provider ORDER BY provider`, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.SyncRunStats{}
+	for rows.Next() {
+		var s models.SyncRunStats
+		if err := rows.Scan(&s.Provider, &s.Runs, &s.FailedRuns, &s.Users, &s.Listed, &s.Fetched, &s.Upserted,
+			&s.Skipped, &s.Errors, &s.QuotaHits, &s.AvgDurationMS, &s.MaxDurationMS); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
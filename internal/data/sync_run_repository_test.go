@@ -0,0 +1,54 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestSyncRunRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewSyncRunRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	for _, id := range []string{"user-syncrun-1", "user-syncrun-2"} {
+		if err := db.Create(ctx, &models.User{ID: id, Email: id + "@example.com", CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Create user failed: %v", err)
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	runs := []*models.SyncRun{
+		{UserID: "user-syncrun-1", Provider: "gmail", StartedAt: now.Add(-2 * time.Minute), DurationMS: 100, Listed: 10, Fetched: 9, Upserted: 8, Skipped: 1, Errors: 1},
+		{UserID: "user-syncrun-1", Provider: "gmail", StartedAt: now.Add(-time.Minute), DurationMS: 300, Listed: 5, QuotaHits: 1, Error: "rate limited"},
+		{UserID: "user-syncrun-2", Provider: "gmail", StartedAt: now, DurationMS: 200, Listed: 3, Fetched: 3, Upserted: 3},
+		{UserID: "user-syncrun-2", Provider: "gmail", StartedAt: now.Add(-48 * time.Hour), DurationMS: 900},
+	}
+	for _, run := range runs {
+		if err := repo.RecordSyncRun(ctx, run); err != nil || run.ID == 0 {
+			t.Fatalf("RecordSyncRun failed: %v (id %d)", err, run.ID)
+		}
+	}
+	since := now.Add(-time.Hour)
+
+	listed, err := repo.ListSyncRuns(ctx, "user-syncrun-1", since, 10)
+	if err != nil || len(listed) != 2 || listed[0].Error != "rate limited" || !listed[1].StartedAt.Equal(runs[0].StartedAt) {
+		t.Fatalf("ListSyncRuns = %+v, %v", listed, err)
+	}
+	all, err := repo.ListSyncRuns(ctx, "", since, 2)
+	if err != nil || len(all) != 2 || all[0].UserID != "user-syncrun-2" {
+		t.Fatalf("ListSyncRuns for every user = %+v, %v", all, err)
+	}
+
+	stats, err := repo.SyncRunStats(ctx, since)
+	if err != nil || len(stats) != 1 {
+		t.Fatalf("SyncRunStats = %+v, %v", stats, err)
+	}
+	want := models.SyncRunStats{Provider: "gmail", Runs: 3, FailedRuns: 1, Users: 2, Listed: 18, Fetched: 12,
+		Upserted: 11, Skipped: 1, Errors: 1, QuotaHits: 1, AvgDurationMS: 200, MaxDurationMS: 300}
+	if stats[0] != want {
+		t.Errorf("SyncRunStats = %+v, want %+v", stats[0], want)
+	}
+}
@@ -0,0 +1,81 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SyncScheduleRepository stores per-user sync tiers, overrides and the last scheduled sync
+type SyncScheduleRepository interface {
+	// ListSyncCandidates returns the schedule of every user with a stored provider token;
+	// users without settings get the zero schedule
+	ListSyncCandidates(ctx context.Context) ([]*models.SyncSchedule, error)
+	// GetSchedule returns the user's schedule, or the zero schedule if none was saved
+	GetSchedule(ctx context.Context, userID string) (*models.SyncSchedule, error)
+	// SetSchedule saves the tier and override; UpdatedAt and LastSyncedAt are filled in
+	SetSchedule(ctx context.Context, s *models.SyncSchedule) error
+	MarkSynced(ctx context.Context, userID string, at time.Time) error
+}
+
+type syncScheduleRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewSyncScheduleRepositoryFromPool creates a SyncScheduleRepository using a pgxpool.Pool
+func NewSyncScheduleRepositoryFromPool(pool *pgxpool.Pool) SyncScheduleRepository {
+	return &syncScheduleRepository{pool: pool}
+}
+
+func (r *syncScheduleRepository) ListSyncCandidates(ctx context.Context) ([]*models.SyncSchedule, error) {
+	rows, err := r.pool.Query(ctx, `SELECT t.user_id, COALESCE(s.tier, ''), s.interval_seconds, s.last_synced_at
+		FROM (SELECT DISTINCT user_id FROM user_tokens) t LEFT JOIN user_sync_settings s ON s.user_id = t.user_id ORDER BY s.last_synced_at NULLS FIRST, t.user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*models.SyncSchedule
+	for rows.Next() {
+		var s models.SyncSchedule
+		if err := rows.Scan(&s.UserID, &s.Tier, &s.IntervalSeconds, &s.LastSyncedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &s)
+	}
+	return out, rows.Err()
+}
+
+func (r *syncScheduleRepository) GetSchedule(ctx context.Context, userID string) (*models.SyncSchedule, error) {
+	s := &models.SyncSchedule{UserID: userID}
+	err := r.pool.QueryRow(ctx, `SELECT tier, interval_seconds, last_synced_at, updated_at FROM user_sync_settings WHERE user_id=$1`, userID).
+		Scan(&s.Tier, &s.IntervalSeconds, &s.LastSyncedAt, &s.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *syncScheduleRepository) SetSchedule(ctx context.Context, s *models.SyncSchedule) error {
+	return r.pool.QueryRow(ctx, `INSERT INTO user_sync_settings (user_id, tier, interval_seconds, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+		tier = EXCLUDED.tier,
+		interval_seconds = EXCLUDED.interval_seconds,
+		updated_at = NOW()
+		RETURNING last_synced_at, updated_at`,
+		s.UserID, s.Tier, s.IntervalSeconds,
+	).Scan(&s.LastSyncedAt, &s.UpdatedAt)
+}
+
+func (r *syncScheduleRepository) MarkSynced(ctx context.Context, userID string, at time.Time) error {
+	_, err := r.pool.Exec(ctx, `INSERT INTO user_sync_settings (user_id, last_synced_at) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET last_synced_at = EXCLUDED.last_synced_at`, userID, at)
+	return err
+}
@@ -0,0 +1,48 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"golang.org/x/oauth2"
+)
+
+func TestSyncScheduleRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewSyncScheduleRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "sync-user-1"
+
+	if err := db.SaveUserToken(ctx, userID, ProviderGmail, "user@example.com", &oauth2.Token{AccessToken: "tok"}); err != nil {
+		t.Fatalf("SaveUserToken failed: %v", err)
+	}
+	s, err := repo.GetSchedule(ctx, userID)
+	if err != nil || s.Tier != "" || s.IntervalSeconds != nil {
+		t.Errorf("expected the zero schedule, got %+v (err %v)", s, err)
+	}
+	manual := 0
+	if err := repo.SetSchedule(ctx, &models.SyncSchedule{UserID: userID, Tier: "paid", IntervalSeconds: &manual}); err != nil {
+		t.Fatalf("SetSchedule failed: %v", err)
+	}
+	at := time.Now().UTC().Truncate(time.Second)
+	if err := repo.MarkSynced(ctx, userID, at); err != nil {
+		t.Fatalf("MarkSynced failed: %v", err)
+	}
+	candidates, err := repo.ListSyncCandidates(ctx)
+	if err != nil {
+		t.Fatalf("ListSyncCandidates failed: %v", err)
+	}
+	var found *models.SyncSchedule
+	for _, c := range candidates {
+		if c.UserID == userID {
+			found = c
+		}
+	}
+	if found == nil || found.Tier != "paid" || found.IntervalSeconds == nil || *found.IntervalSeconds != 0 ||
+		found.LastSyncedAt == nil || !found.LastSyncedAt.Equal(at) {
+		t.Errorf("unexpected candidate %+v", found)
+	}
+}
@@ -0,0 +1,53 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SyncStateRepository stores each user's provider sync cursor. Write it through DB.WithTx
+// together with the page's messages so the cursor never runs ahead of (or behind) the cache.
+type SyncStateRepository interface {
+	// GetSyncState returns the user's cursor, or the zero state if none was saved
+	GetSyncState(ctx context.Context, userID string) (*models.SyncState, error)
+	// SaveSyncState stores the cursor; UpdatedAt is filled in
+	SaveSyncState(ctx context.Context, s *models.SyncState) error
+}
+
+type syncStateRepository struct {
+	pool querier
+}
+
+// NewSyncStateRepositoryFromPool creates a SyncStateRepository using a pgxpool.Pool
+func NewSyncStateRepositoryFromPool(pool *pgxpool.Pool) SyncStateRepository {
+	return &syncStateRepository{pool: pool}
+}
+
+func (r *syncStateRepository) GetSyncState(ctx context.Context, userID string) (*models.SyncState, error) {
+	s := &models.SyncState{UserID: userID}
+	err := r.pool.QueryRow(ctx, `SELECT page_token, history_id, updated_at FROM sync_state WHERE user_id=$1`, userID).
+		Scan(&s.PageToken, &s.HistoryID, &s.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *syncStateRepository) SaveSyncState(ctx context.Context, s *models.SyncState) error {
+	return r.pool.QueryRow(ctx, `INSERT INTO sync_state (user_id, page_token, history_id, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+		page_token = EXCLUDED.page_token,
+		history_id = GREATEST(sync_state.history_id, EXCLUDED.history_id),
+		updated_at = NOW()
+		RETURNING history_id, updated_at`,
+		s.UserID, s.PageToken, s.HistoryID,
+	).Scan(&s.HistoryID, &s.UpdatedAt)
+}
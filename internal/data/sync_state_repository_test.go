@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestSyncStateRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewSyncStateRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+
+	s, err := repo.GetSyncState(ctx, "user-1")
+	if err != nil || s.PageToken != "" || s.HistoryID != 0 {
+		t.Fatalf("expected the zero state, got %+v (err %v)", s, err)
+	}
+	if err := repo.SaveSyncState(ctx, &models.SyncState{UserID: "user-1", PageToken: "p2", HistoryID: 50}); err != nil {
+		t.Fatalf("SaveSyncState failed: %v", err)
+	}
+	// The history ID never moves backwards
+	if err := repo.SaveSyncState(ctx, &models.SyncState{UserID: "user-1", PageToken: "p3", HistoryID: 40}); err != nil {
+		t.Fatalf("SaveSyncState failed: %v", err)
+	}
+	s, err = repo.GetSyncState(ctx, "user-1")
+	if err != nil || s.PageToken != "p3" || s.HistoryID != 50 || s.UpdatedAt.IsZero() {
+		t.Errorf("expected page p3 at history 50, got %+v (err %v)", s, err)
+	}
+}
+
+func TestDB_WithTx(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	msg := func(id string) *models.EmailMessage {
+		return &models.EmailMessage{UserID: "user-1", EmailMessageID: id, CachedAt: time.Now(), RawJSON: []byte("{}")}
+	}
+
+	// A failed page leaves neither its messages nor its cursor behind
+	errPage := errors.New("crash mid-page")
+	err := db.WithTx(ctx, func(tx *Tx) error {
+		if err := tx.Messages.UpsertMessage(ctx, msg("m1")); err != nil {
+			return err
+		}
+		if err := tx.SyncState.SaveSyncState(ctx, &models.SyncState{UserID: "user-1", PageToken: "p2"}); err != nil {
+			return err
+		}
+		return errPage
+	})
+	if !errors.Is(err, errPage) {
+		t.Fatalf("expected the page error, got %v", err)
+	}
+	messages := NewEmailMessageRepositoryFromPool(db.Pool)
+	states := NewSyncStateRepositoryFromPool(db.Pool)
+	if _, err := messages.GetMessageByID(ctx, "user-1", "m1"); err == nil {
+		t.Error("expected the rolled back message not to be stored")
+	}
+	if s, _ := states.GetSyncState(ctx, "user-1"); s.PageToken != "" {
+		t.Errorf("expected the rolled back cursor not to be stored, got %+v", s)
+	}
+
+	// A completed page commits both
+	err = db.WithTx(ctx, func(tx *Tx) error {
+		if err := tx.Messages.UpsertMessage(ctx, msg("m1")); err != nil {
+			return err
+		}
+		return tx.SyncState.SaveSyncState(ctx, &models.SyncState{UserID: "user-1", PageToken: "p2"})
+	})
+	if err != nil {
+		t.Fatalf("WithTx failed: %v", err)
+	}
+	if _, err := messages.GetMessageByID(ctx, "user-1", "m1"); err != nil {
+		t.Errorf("expected the committed message to be stored: %v", err)
+	}
+	if s, _ := states.GetSyncState(ctx, "user-1"); s.PageToken != "p2" {
+		t.Errorf("expected the committed cursor, got %+v", s)
+	}
+}
@@ -24,7 +24,7 @@ func fileExists(path string) bool {
 	return err == nil
 }
 
-func SetupTestDB(t *testing.T) (*DB, func()) {
+func SetupTestDB(t testing.TB) (*DB, func()) {
 	log := func(msg string, args ...interface{}) {
 		fmt.Printf("[SetupTestDB] "+msg+"\n", args...)
 	}
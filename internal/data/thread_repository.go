@@ -0,0 +1,117 @@
+package data
+
+import (
+	"context"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ThreadRepository groups cached messages into conversations by thread ID
+type ThreadRepository interface {
+	// ListThreads returns up to limit threads, most recently active first. Pass the latest
+	// internal date and ID of the last thread of a page to get the next one; zero and "" start
+	// from the top.
+	ListThreads(ctx context.Context, userID string, limit int, afterInternalDate int64, afterID string) ([]*models.ThreadSummary, error)
+	// GetThread returns ErrNotFound if no cached message belongs to the thread
+	GetThread(ctx context.Context, userID, threadID string) (*models.Thread, error)
+}
+
+type threadRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewThreadRepositoryFromPool creates a ThreadRepository using a pgxpool.Pool
+func NewThreadRepositoryFromPool(pool *pgxpool.Pool) ThreadRepository {
+	return &threadRepository{pool: pool}
+}
+
+func (r *threadRepository) ListThreads(ctx context.Context, userID string, limit int, afterInternalDate int64, afterID string) ([]*models.ThreadSummary, error) {
+	rows, err := r.pool.Query(ctx, `WITH page AS (
+			SELECT thread_id,
+				COUNT(*) AS messages,
+				COUNT(*) FILTER (WHERE NOT is_read) AS unread,
+				COALESCE(MAX(internal_date), 0) AS latest,
+				(ARRAY_AGG(COALESCE(subject, '') ORDER BY internal_date, email_message_id))[1] AS subject,
+				(ARRAY_AGG(COALESCE(snippet, '') ORDER BY internal_date DESC, email_message_id DESC))[1] AS snippet
+			FROM email_messages WHERE user_id=$1 AND COALESCE(thread_id, '') <> ''
+			GROUP BY thread_id
+			HAVING $3 = '' OR (COALESCE(MAX(internal_date), 0), thread_id) < ($2, $3)
+			ORDER BY latest DESC, thread_id DESC LIMIT $4)
+		SELECT p.thread_id, p.subject, p.snippet, p.latest, p.messages, p.unread,
+			ARRAY(SELECT s.address FROM (
+				SELECT COALESCE(NULLIF(m.sender_address, ''), m.sender, '') AS address, MIN(m.internal_date) AS first
+				FROM email_messages m WHERE m.user_id=$1 AND m.thread_id=p.thread_id GROUP BY 1) s
+				WHERE s.address <> '' ORDER BY s.first, s.address)
+		FROM page p ORDER BY p.latest DESC, p.thread_id DESC`, userID, afterInternalDate, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	threads := []*models.ThreadSummary{}
+	for rows.Next() {
+		t := &models.ThreadSummary{}
+		if err := rows.Scan(&t.ThreadID, &t.Subject, &t.LatestSnippet, &t.LatestInternalDate, &t.MessageCount, &t.UnreadCount, &t.Participants); err != nil {
+			return nil, err
+		}
+		threads = append(threads, t)
+	}
+	return threads, rows.Err()
+}
+
+func (r *threadRepository) GetThread(ctx context.Context, userID, threadID string) (*models.Thread, error) {
+	rows, err := r.pool.Query(ctx, `SELECT email_message_id, COALESCE(subject, ''), COALESCE(sender, ''),
+		COALESCE(sender_address, ''), COALESCE(sender_name, ''), COALESCE(snippet, ''), COALESCE(internal_date, 0), is_read
+		FROM email_messages WHERE user_id=$1 AND thread_id=$2
+		ORDER BY internal_date, email_message_id`, userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var msgs []models.ThreadMessage
+	for rows.Next() {
+		var m models.ThreadMessage
+		if err := rows.Scan(&m.ID, &m.Subject, &m.Sender, &m.SenderAddress, &m.SenderName, &m.Snippet, &m.InternalDate, &m.IsRead); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, ErrNotFound
+	}
+	return newThread(threadID, msgs), nil
+}
+
+// newThread builds a thread from its messages, oldest first, summarizing them the way
+// ListThreads does
+func newThread(threadID string, msgs []models.ThreadMessage) *models.Thread {
+	t := &models.Thread{ThreadSummary: models.ThreadSummary{ThreadID: threadID, Participants: []string{}}, Messages: msgs}
+	seen := map[string]bool{}
+	for i, m := range msgs {
+		if i == 0 {
+			t.Subject = m.Subject
+		}
+		if !m.IsRead {
+			t.UnreadCount++
+		}
+		address := m.SenderAddress
+		if address == "" {
+			address = strings.TrimSpace(m.Sender)
+		}
+		if address != "" && !seen[address] {
+			seen[address] = true
+			t.Participants = append(t.Participants, address)
+		}
+	}
+	t.MessageCount = len(msgs)
+	if len(msgs) > 0 {
+		latest := msgs[len(msgs)-1]
+		t.LatestSnippet = latest.Snippet
+		t.LatestInternalDate = latest.InternalDate
+	}
+	return t
+}
@@ -0,0 +1,72 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestThreadRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewThreadRepositoryFromPool(db.Pool)
+	messages := NewEmailMessageRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "thread-user-1"
+
+	for _, m := range []*models.EmailMessage{
+		{EmailMessageID: "a1", ThreadID: "ta", Subject: "Lunch?", SenderAddress: "ann@example.com", Snippet: "Are you free", InternalDate: 100, IsRead: true},
+		{EmailMessageID: "a2", ThreadID: "ta", Subject: "Re: Lunch?", SenderAddress: "bob@example.com", Snippet: "Sure, noon", InternalDate: 300},
+		{EmailMessageID: "a3", ThreadID: "ta", Subject: "Re: Lunch?", SenderAddress: "ann@example.com", Snippet: "See you", InternalDate: 400},
+		{EmailMessageID: "b1", ThreadID: "tb", Subject: "Invoice", SenderAddress: "billing@example.com", Snippet: "Your invoice", InternalDate: 200, IsRead: true},
+	} {
+		m.UserID = userID
+		if err := messages.UpsertMessage(ctx, m); err != nil {
+			t.Fatalf("UpsertMessage failed: %v", err)
+		}
+	}
+
+	threads, err := repo.ListThreads(ctx, userID, 1, 0, "")
+	if err != nil || len(threads) != 1 {
+		t.Fatalf("expected one thread, got %v (err %v)", threads, err)
+	}
+	ta := threads[0]
+	if ta.ThreadID != "ta" || ta.Subject != "Lunch?" || ta.LatestSnippet != "See you" || ta.MessageCount != 3 || ta.UnreadCount != 2 {
+		t.Errorf("unexpected summary: %+v", ta)
+	}
+	if len(ta.Participants) != 2 || ta.Participants[0] != "ann@example.com" || ta.Participants[1] != "bob@example.com" {
+		t.Errorf("expected participants in order of appearance, got %v", ta.Participants)
+	}
+	next, err := repo.ListThreads(ctx, userID, 10, ta.LatestInternalDate, ta.ThreadID)
+	if err != nil || len(next) != 1 || next[0].ThreadID != "tb" {
+		t.Errorf("expected the second page to hold tb, got %v (err %v)", next, err)
+	}
+
+	thread, err := repo.GetThread(ctx, userID, "ta")
+	if err != nil {
+		t.Fatalf("GetThread failed: %v", err)
+	}
+	if len(thread.Messages) != 3 || thread.Messages[0].ID != "a1" || thread.UnreadCount != 2 {
+		t.Errorf("unexpected thread: %+v", thread)
+	}
+	if _, err := repo.GetThread(ctx, userID, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestNewThread(t *testing.T) {
+	thread := newThread("t1", []models.ThreadMessage{
+		{ID: "m1", Subject: "Hello", Sender: "Ann <ann@example.com>", SenderAddress: "ann@example.com", InternalDate: 1, IsRead: true},
+		{ID: "m2", Subject: "Re: Hello", Sender: "noreply", Snippet: "latest", InternalDate: 2},
+		{ID: "m3", Subject: "Re: Hello", SenderAddress: "ann@example.com", Snippet: "newest", InternalDate: 3},
+	})
+	if thread.Subject != "Hello" || thread.MessageCount != 3 || thread.UnreadCount != 2 || thread.LatestSnippet != "newest" || thread.LatestInternalDate != 3 {
+		t.Errorf("unexpected summary: %+v", thread.ThreadSummary)
+	}
+	// Senders whose address could not be parsed are listed by their raw header
+	if len(thread.Participants) != 2 || thread.Participants[1] != "noreply" {
+		t.Errorf("unexpected participants: %v", thread.Participants)
+	}
+}
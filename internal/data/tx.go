@@ -0,0 +1,55 @@
+package data
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// querier is implemented by both *pgxpool.Pool and pgx.Tx, so a repository can run on the
+// pool or inside a transaction
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Tx holds repositories bound to a single transaction
+type Tx struct {
+	Messages    EmailMessageRepository
+	SyncState   SyncStateRepository
+	FailedItems FailedSyncItemRepository
+}
+
+// TxRunner runs fn in a transaction, committing only if fn returns nil
+type TxRunner interface {
+	WithTx(ctx context.Context, fn func(tx *Tx) error) error
+}
+
+// WithTx runs fn with repositories bound to a new transaction. The transaction commits if fn
+// returns nil and rolls back if it returns an error or panics.
+func (db *DB) WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	return withTx(ctx, db.Pool, func(tx pgx.Tx) error {
+		return fn(&Tx{
+			Messages:    &emailMessageRepository{pool: tx},
+			SyncState:   &syncStateRepository{pool: tx},
+			FailedItems: &failedSyncItemRepository{pool: tx},
+		})
+	})
+}
+
+// withTx runs fn in a transaction on q; when q is itself a transaction this is a savepoint
+func withTx(ctx context.Context, q querier, fn func(tx pgx.Tx) error) error {
+	tx, err := q.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rolling back a committed transaction is a no-op
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
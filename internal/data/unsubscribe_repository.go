@@ -0,0 +1,33 @@
+package data
+
+import (
+	"context"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UnsubscribeRepository stores the outcome of the latest unsubscribe from each sender
+type UnsubscribeRepository interface {
+	// Record stores u, replacing the previous outcome for its sender, and fills u.AttemptedAt
+	Record(ctx context.Context, u *models.Unsubscribe) error
+}
+
+type unsubscribeRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewUnsubscribeRepositoryFromPool creates an UnsubscribeRepository using a pgxpool.Pool
+func NewUnsubscribeRepositoryFromPool(pool *pgxpool.Pool) UnsubscribeRepository {
+	return &unsubscribeRepository{pool: pool}
+}
+
+func (r *unsubscribeRepository) Record(ctx context.Context, u *models.Unsubscribe) error {
+	return r.pool.QueryRow(ctx, `INSERT INTO unsubscribes (user_id, sender_address, email_message_id, method, status, detail)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, sender_address) DO UPDATE SET
+			email_message_id=EXCLUDED.email_message_id, method=EXCLUDED.method, status=EXCLUDED.status,
+			detail=EXCLUDED.detail, attempted_at=now()
+		RETURNING attempted_at`,
+		u.UserID, u.SenderAddress, u.EmailMessageID, u.Method, u.Status, u.Detail).Scan(&u.AttemptedAt)
+}
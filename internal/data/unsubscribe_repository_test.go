@@ -0,0 +1,40 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestUnsubscribeRepository_Record(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewUnsubscribeRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+
+	user := &models.User{ID: "unsub-user", Email: "unsub@example.com", CreatedAt: time.Now()}
+	if err := db.Create(ctx, user); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	u := &models.Unsubscribe{UserID: user.ID, SenderAddress: "news@example.com", EmailMessageID: "m1",
+		Method: models.UnsubscribeOneClick, Status: models.UnsubscribeFailed, Detail: "HTTP 500"}
+	if err := repo.Record(ctx, u); err != nil || u.AttemptedAt.IsZero() {
+		t.Fatalf("Record failed: %v (attempted at %v)", err, u.AttemptedAt)
+	}
+	// A retry replaces the outcome
+	u = &models.Unsubscribe{UserID: user.ID, SenderAddress: "news@example.com", EmailMessageID: "m2",
+		Method: models.UnsubscribeMailto, Status: models.UnsubscribeSucceeded}
+	if err := repo.Record(ctx, u); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	var n int
+	var status string
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*), max(status) FROM unsubscribes WHERE user_id=$1`, user.ID).Scan(&n, &status); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if n != 1 || status != models.UnsubscribeSucceeded {
+		t.Errorf("expected one succeeded outcome, got %d %q", n, status)
+	}
+}
@@ -2,7 +2,10 @@ package data
 
 import (
 	"context"
+	"errors"
+
 	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
 )
 
 // UserRepository defines DB operations for users (interface for service layer)
@@ -14,18 +17,42 @@ type UserRepository interface {
 	Delete(ctx context.Context, id string) error
 }
 
+// UserRoleRepository assigns user roles (see models.RoleAdmin)
+type UserRoleRepository interface {
+	// GetRole returns the user's role; ErrNotFound if there is no such user
+	GetRole(ctx context.Context, id string) (string, error)
+	// SetRole changes the user's role; ErrNotFound if there is no such user
+	SetRole(ctx context.Context, id, role string) error
+	// ListByRole lists the users holding role, oldest first
+	ListByRole(ctx context.Context, role string) ([]*models.User, error)
+}
+
 // PostgresUserRepository implements UserRepository for Postgres
 // (implements all methods on *DB)
 func (db *DB) List(ctx context.Context) ([]*models.User, error) {
-	rows, err := db.Pool.Query(ctx, `SELECT id, email, created_at FROM users`)
+	rows, err := db.Pool.Query(ctx, `SELECT id, email, created_at, deactivated, role FROM users`)
+	if err != nil {
+		return nil, err
+	}
+	return scanUsers(rows)
+}
+
+// ListByRole lists the users holding role, oldest first
+func (db *DB) ListByRole(ctx context.Context, role string) ([]*models.User, error) {
+	rows, err := db.Pool.Query(ctx,
+		`SELECT id, email, created_at, deactivated, role FROM users WHERE role = $1 ORDER BY created_at, id`, role)
 	if err != nil {
 		return nil, err
 	}
+	return scanUsers(rows)
+}
+
+func scanUsers(rows pgx.Rows) ([]*models.User, error) {
 	defer rows.Close()
 	var users []*models.User
 	for rows.Next() {
 		var u models.User
-		if err := rows.Scan(&u.ID, &u.Email, &u.CreatedAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.Email, &u.CreatedAt, &u.Deactivated, &u.Role); err != nil {
 			return nil, err
 		}
 		users = append(users, &u)
@@ -33,6 +60,28 @@ func (db *DB) List(ctx context.Context) ([]*models.User, error) {
 	return users, rows.Err()
 }
 
+// GetRole returns the user's role; ErrNotFound if there is no such user
+func (db *DB) GetRole(ctx context.Context, id string) (string, error) {
+	var role string
+	err := db.Pool.QueryRow(ctx, `SELECT role FROM users WHERE id = $1`, id).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return role, err
+}
+
+// SetRole changes the user's role; ErrNotFound if there is no such user
+func (db *DB) SetRole(ctx context.Context, id, role string) error {
+	tag, err := db.Pool.Exec(ctx, `UPDATE users SET role = $1 WHERE id = $2`, role, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // Update updates user fields, including Deactivated for soft delete
 func (db *DB) Update(ctx context.Context, user *models.User) error {
 	_, err := db.Pool.Exec(ctx,
@@ -48,9 +97,12 @@ func (db *DB) Delete(ctx context.Context, id string) error {
 }
 
 func (db *DB) GetByID(ctx context.Context, id string) (*models.User, error) {
-	row := db.Pool.QueryRow(ctx, `SELECT id, email, created_at FROM users WHERE id = $1`, id)
+	row := db.Pool.QueryRow(ctx, `SELECT id, email, created_at, deactivated, role FROM users WHERE id = $1`, id)
 	var user models.User
-	if err := row.Scan(&user.ID, &user.Email, &user.CreatedAt); err != nil {
+	if err := row.Scan(&user.ID, &user.Email, &user.CreatedAt, &user.Deactivated, &user.Role); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 	return &user, nil
@@ -2,6 +2,7 @@ package data
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -71,7 +72,42 @@ func TestUserRepository_CRUD(t *testing.T) {
 		t.Fatalf("Delete failed: %v", err)
 	}
 	_, err = repo.GetByID(ctx, user.ID)
-	if err == nil {
-		t.Errorf("expected error after delete, got nil")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestUserRepository_Roles(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	user := &models.User{ID: "roles-user", Email: "roles@example.com", CreatedAt: time.Now()}
+	if err := db.Create(ctx, user); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	got, err := db.GetByID(ctx, user.ID)
+	if err != nil || got.Role != models.RoleUser {
+		t.Fatalf("expected new users to get the user role, got %+v (err %v)", got, err)
+	}
+
+	if err := db.SetRole(ctx, user.ID, models.RoleAdmin); err != nil {
+		t.Fatalf("SetRole failed: %v", err)
+	}
+	if role, err := db.GetRole(ctx, user.ID); err != nil || role != models.RoleAdmin {
+		t.Errorf("expected GetRole to return the granted role, got %q (err %v)", role, err)
+	}
+	if _, err := db.GetRole(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound from GetRole for a missing user, got %v", err)
+	}
+	admins, err := db.ListByRole(ctx, models.RoleAdmin)
+	if err != nil || len(admins) != 1 || admins[0].ID != user.ID || admins[0].Role != models.RoleAdmin {
+		t.Errorf("expected %s to be the only admin, got %+v (err %v)", user.ID, admins, err)
+	}
+	if err := db.SetRole(ctx, user.ID, "owner"); err == nil {
+		t.Error("expected an unknown role to be rejected")
+	}
+	if err := db.SetRole(ctx, "missing", models.RoleAdmin); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing user, got %v", err)
 	}
 }
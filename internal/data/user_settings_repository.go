@@ -0,0 +1,74 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UserSettingsRepository stores per-user settings
+type UserSettingsRepository interface {
+	// Get returns the user's settings, or defaults if none were saved
+	Get(ctx context.Context, userID string) (*models.UserSettings, error)
+	Upsert(ctx context.Context, settings *models.UserSettings) error
+}
+
+type userSettingsRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewUserSettingsRepositoryFromPool creates a UserSettingsRepository using a pgxpool.Pool
+func NewUserSettingsRepositoryFromPool(pool *pgxpool.Pool) UserSettingsRepository {
+	return &userSettingsRepository{pool: pool}
+}
+
+// encryptedCache derives UserSettings.EncryptedCache from the key table, the single source of truth
+const encryptedCache = `EXISTS (SELECT 1 FROM user_encryption_keys WHERE user_id=$1)`
+
+func (r *userSettingsRepository) Get(ctx context.Context, userID string) (*models.UserSettings, error) {
+	s := &models.UserSettings{UserID: userID, DigestCategories: []string{}}
+	err := r.pool.QueryRow(ctx, `SELECT ai_data_sharing, disable_local_cache, metadata_only_cache,
+		timezone, working_hours_start, working_hours_end, weekend_pause, digest_categories, updated_at, `+encryptedCache+`
+		FROM user_settings WHERE user_id=$1`, userID).
+		Scan(&s.AIDataSharing, &s.DisableLocalCache, &s.MetadataOnlyCache,
+			&s.Timezone, &s.WorkingHoursStart, &s.WorkingHoursEnd, &s.WeekendPause, &s.DigestCategories, &s.UpdatedAt, &s.EncryptedCache)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Encryption can be turned on before any other setting was saved
+		return s, r.pool.QueryRow(ctx, `SELECT `+encryptedCache, userID).Scan(&s.EncryptedCache)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *userSettingsRepository) Upsert(ctx context.Context, s *models.UserSettings) error {
+	return r.pool.QueryRow(ctx, `INSERT INTO user_settings (user_id, ai_data_sharing, disable_local_cache, metadata_only_cache,
+			timezone, working_hours_start, working_hours_end, weekend_pause, digest_categories, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+		ai_data_sharing = EXCLUDED.ai_data_sharing,
+		disable_local_cache = EXCLUDED.disable_local_cache,
+		metadata_only_cache = EXCLUDED.metadata_only_cache,
+		timezone = EXCLUDED.timezone,
+		working_hours_start = EXCLUDED.working_hours_start,
+		working_hours_end = EXCLUDED.working_hours_end,
+		weekend_pause = EXCLUDED.weekend_pause,
+		digest_categories = EXCLUDED.digest_categories,
+		updated_at = NOW()
+		RETURNING updated_at`,
+		s.UserID, s.AIDataSharing, s.DisableLocalCache, s.MetadataOnlyCache,
+		s.Timezone, s.WorkingHoursStart, s.WorkingHoursEnd, s.WeekendPause, digestCategories(s),
+	).Scan(&s.UpdatedAt)
+}
+
+// digestCategories keeps a nil list from being written as NULL
+func digestCategories(s *models.UserSettings) []string {
+	if s.DigestCategories == nil {
+		return []string{}
+	}
+	return s.DigestCategories
+}
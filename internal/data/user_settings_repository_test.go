@@ -0,0 +1,40 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestUserSettingsRepository(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	repo := NewUserSettingsRepositoryFromPool(db.Pool)
+	ctx := context.Background()
+	userID := "user-settings-1"
+
+	s, err := repo.Get(ctx, userID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if s.AIDataSharing || s.DisableLocalCache {
+		t.Error("expected AI data sharing and local cache opt-out to default to off")
+	}
+
+	if err := repo.Upsert(ctx, &models.UserSettings{UserID: userID, AIDataSharing: true, DisableLocalCache: true,
+		Timezone: "Europe/Berlin", WorkingHoursStart: "09:00", WorkingHoursEnd: "17:00", WeekendPause: true,
+		DigestCategories: []string{"Promotions/Ads"}}); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	s, err = repo.Get(ctx, userID)
+	if err != nil {
+		t.Fatalf("Get after upsert failed: %v", err)
+	}
+	if !s.AIDataSharing || !s.DisableLocalCache || s.UpdatedAt.IsZero() {
+		t.Errorf("unexpected settings after upsert: %+v", s)
+	}
+	if s.Timezone != "Europe/Berlin" || s.WorkingHoursStart != "09:00" || s.WorkingHoursEnd != "17:00" || !s.WeekendPause || len(s.DigestCategories) != 1 {
+		t.Errorf("unexpected settings after upsert: %+v", s)
+	}
+}
@@ -3,33 +3,95 @@ package data
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"golang.org/x/oauth2"
 )
 
+// ProviderGmail is the provider name tokens for Gmail accounts are stored under; it matches
+// service.ProviderGmail
+const ProviderGmail = "gmail"
+
+// ProviderOutlook is the provider name tokens for Outlook accounts are stored under; it matches
+// service.ProviderOutlook
+const ProviderOutlook = "outlook"
+
+// UserTokenRepository stores OAuth tokens per (user, provider, account). The account ID is
+// the provider's identifier for the mailbox, e.g. the Gmail address.
 type UserTokenRepository interface {
-	SaveUserToken(ctx context.Context, userID string, token *oauth2.Token) error
-	GetUserToken(ctx context.Context, userID string) (*oauth2.Token, error)
+	// SaveUserToken inserts or replaces the token of one account; accountID must not be empty.
+	// The granted scopes are taken from the token response's scope field when it has one and
+	// kept as they were otherwise.
+	SaveUserToken(ctx context.Context, userID, provider, accountID string, token *oauth2.Token) error
+	// GetUserToken returns the token of one account. An empty accountID selects the user's
+	// default account for the provider, the first one linked. Returns ErrNotFound if there
+	// is no such token.
+	GetUserToken(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error)
+}
+
+// TokenScopeRepository reads the OAuth scopes an account granted
+type TokenScopeRepository interface {
+	// GetGrantedScopes returns the account's granted scopes, nil if they are unknown. An empty
+	// accountID selects the default account as in GetUserToken. Returns ErrNotFound if there
+	// is no such token.
+	GetGrantedScopes(ctx context.Context, userID, provider, accountID string) ([]string, error)
+}
+
+// TokenRefreshRepository stores tokens refreshed away from the request that loaded them
+type TokenRefreshRepository interface {
+	// UpdateRefreshedToken replaces the token of the user's account whose stored token carries
+	// refreshToken, so callers need not know which account it belongs to. Returns ErrNotFound
+	// if no account has it, e.g. because the account was unlinked in the meantime.
+	UpdateRefreshedToken(ctx context.Context, userID, provider, refreshToken string, token *oauth2.Token) error
 }
 
-func (db *DB) SaveUserToken(ctx context.Context, userID string, token *oauth2.Token) error {
+// LinkedProviderRepository lists which providers a user has linked accounts for
+type LinkedProviderRepository interface {
+	// LinkedProviders returns the providers the user holds tokens or an IMAP account for, in
+	// the order they were first linked
+	LinkedProviders(ctx context.Context, userID string) ([]string, error)
+}
+
+// TokenPurgeRepository removes all of a user's stored tokens
+type TokenPurgeRepository interface {
+	// DeleteUserTokens deletes the user's tokens for every provider and account and returns
+	// how many there were
+	DeleteUserTokens(ctx context.Context, userID string) (int64, error)
+}
+
+func (db *DB) SaveUserToken(ctx context.Context, userID, provider, accountID string, token *oauth2.Token) error {
+	if accountID == "" {
+		return errors.New("save user token: empty account ID")
+	}
 	tokBytes, err := json.Marshal(token)
 	if err != nil {
 		return err
 	}
-	_, err = db.Pool.Exec(ctx, `INSERT INTO user_tokens (user_id, token_json, updated_at)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (user_id) DO UPDATE SET token_json = $2, updated_at = $3`,
-		userID, string(tokBytes), time.Now().UTC(),
+	var scopes []string
+	if s, ok := token.Extra("scope").(string); ok && s != "" {
+		scopes = strings.Fields(s)
+	}
+	_, err = db.Pool.Exec(ctx, `INSERT INTO user_tokens (user_id, provider, account_id, token_json, updated_at, granted_scopes)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, provider, account_id) DO UPDATE SET token_json = $4, updated_at = $5,
+			granted_scopes = COALESCE($6, user_tokens.granted_scopes)`,
+		userID, provider, accountID, string(tokBytes), time.Now().UTC(), scopes,
 	)
 	return err
 }
 
-func (db *DB) GetUserToken(ctx context.Context, userID string) (*oauth2.Token, error) {
-	row := db.Pool.QueryRow(ctx, `SELECT token_json FROM user_tokens WHERE user_id = $1`, userID)
+func (db *DB) GetUserToken(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
+	row := db.Pool.QueryRow(ctx, `SELECT token_json FROM user_tokens
+		WHERE user_id = $1 AND provider = $2 AND ($3 = '' OR account_id = $3)
+		ORDER BY created_at, account_id LIMIT 1`, userID, provider, accountID)
 	var tokenJSON string
 	if err := row.Scan(&tokenJSON); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 	var token oauth2.Token
@@ -38,3 +100,72 @@ func (db *DB) GetUserToken(ctx context.Context, userID string) (*oauth2.Token, e
 	}
 	return &token, nil
 }
+
+func (db *DB) UpdateRefreshedToken(ctx context.Context, userID, provider, refreshToken string, token *oauth2.Token) error {
+	if refreshToken == "" {
+		return ErrNotFound
+	}
+	tokBytes, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	var scopes []string
+	if s, ok := token.Extra("scope").(string); ok && s != "" {
+		scopes = strings.Fields(s)
+	}
+	tag, err := db.Pool.Exec(ctx, `UPDATE user_tokens SET token_json = $4, updated_at = $5,
+			granted_scopes = COALESCE($6, granted_scopes)
+		WHERE user_id = $1 AND provider = $2 AND token_json::jsonb->>'refresh_token' = $3`,
+		userID, provider, refreshToken, string(tokBytes), time.Now().UTC(), scopes,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (db *DB) GetGrantedScopes(ctx context.Context, userID, provider, accountID string) ([]string, error) {
+	var scopes []string
+	err := db.Pool.QueryRow(ctx, `SELECT granted_scopes FROM user_tokens
+		WHERE user_id = $1 AND provider = $2 AND ($3 = '' OR account_id = $3)
+		ORDER BY created_at, account_id LIMIT 1`, userID, provider, accountID).Scan(&scopes)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return scopes, nil
+}
+
+func (db *DB) LinkedProviders(ctx context.Context, userID string) ([]string, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT provider FROM (
+			SELECT provider, MIN(created_at) AS linked_at FROM user_tokens WHERE user_id = $1 GROUP BY provider
+			UNION ALL
+			SELECT '`+ProviderIMAP+`', created_at FROM imap_accounts WHERE user_id = $1
+		) linked ORDER BY linked_at, provider`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (db *DB) DeleteUserTokens(ctx context.Context, userID string) (int64, error) {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM user_tokens WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -2,6 +2,8 @@ package data
 
 import (
 	"context"
+	"errors"
+	"reflect"
 	"testing"
 	"time"
 
@@ -21,11 +23,11 @@ func TestUserTokenRepository_SaveAndGet(t *testing.T) {
 	}
 	userID := "user_123"
 
-	if err := db.SaveUserToken(ctx, userID, tok); err != nil {
+	if err := db.SaveUserToken(ctx, userID, ProviderGmail, "user@example.com", tok); err != nil {
 		t.Fatalf("SaveUserToken failed: %v", err)
 	}
 
-	got, err := db.GetUserToken(ctx, userID)
+	got, err := db.GetUserToken(ctx, userID, ProviderGmail, "user@example.com")
 	if err != nil {
 		t.Fatalf("GetUserToken failed: %v", err)
 	}
@@ -33,3 +35,109 @@ func TestUserTokenRepository_SaveAndGet(t *testing.T) {
 		t.Errorf("got %+v, want %+v", got, tok)
 	}
 }
+
+func TestUserTokenRepository_PerAccount(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	userID := "user_multi"
+
+	if err := db.SaveUserToken(ctx, userID, ProviderGmail, "first@example.com", &oauth2.Token{AccessToken: "first"}); err != nil {
+		t.Fatalf("SaveUserToken failed: %v", err)
+	}
+	if err := db.SaveUserToken(ctx, userID, ProviderGmail, "second@example.com", &oauth2.Token{AccessToken: "second"}); err != nil {
+		t.Fatalf("SaveUserToken failed: %v", err)
+	}
+
+	got, err := db.GetUserToken(ctx, userID, ProviderGmail, "second@example.com")
+	if err != nil || got.AccessToken != "second" {
+		t.Errorf("second account: got %+v, %v", got, err)
+	}
+	// The first linked account is the default
+	got, err = db.GetUserToken(ctx, userID, ProviderGmail, "")
+	if err != nil || got.AccessToken != "first" {
+		t.Errorf("default account: got %+v, %v", got, err)
+	}
+	if _, err := db.GetUserToken(ctx, userID, ProviderGmail, "other@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown account: got %v, want ErrNotFound", err)
+	}
+	if _, err := db.GetUserToken(ctx, userID, ProviderOutlook, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("other provider: got %v, want ErrNotFound", err)
+	}
+	if err := db.SaveUserToken(ctx, userID, ProviderOutlook, "me@outlook.com", &oauth2.Token{AccessToken: "ms"}); err != nil {
+		t.Fatalf("SaveUserToken failed: %v", err)
+	}
+	if linked, err := db.LinkedProviders(ctx, userID); err != nil || !reflect.DeepEqual(linked, []string{ProviderGmail, ProviderOutlook}) {
+		t.Errorf("LinkedProviders = %v, %v", linked, err)
+	}
+	if err := db.SaveUserToken(ctx, userID, ProviderGmail, "", &oauth2.Token{}); err == nil {
+		t.Error("expected an error for an empty account ID")
+	}
+	if n, err := db.DeleteUserTokens(ctx, userID); err != nil || n != 3 {
+		t.Errorf("DeleteUserTokens = %d, %v; want 3", n, err)
+	}
+	if _, err := db.GetUserToken(ctx, userID, ProviderGmail, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("after DeleteUserTokens: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestUserTokenRepository_GrantedScopes(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	userID := "user_scopes"
+
+	granted := (&oauth2.Token{AccessToken: "a"}).WithExtra(map[string]interface{}{"scope": "openid https://www.googleapis.com/auth/gmail.modify"})
+	if err := db.SaveUserToken(ctx, userID, ProviderGmail, "me@example.com", granted); err != nil {
+		t.Fatalf("SaveUserToken failed: %v", err)
+	}
+	want := []string{"openid", "https://www.googleapis.com/auth/gmail.modify"}
+	got, err := db.GetGrantedScopes(ctx, userID, ProviderGmail, "")
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetGrantedScopes = %v, %v; want %v", got, err, want)
+	}
+
+	// A refreshed token without a scope field keeps the recorded grant
+	if err := db.SaveUserToken(ctx, userID, ProviderGmail, "me@example.com", &oauth2.Token{AccessToken: "b"}); err != nil {
+		t.Fatalf("SaveUserToken failed: %v", err)
+	}
+	got, err = db.GetGrantedScopes(ctx, userID, ProviderGmail, "me@example.com")
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("after refresh GetGrantedScopes = %v, %v; want %v", got, err, want)
+	}
+
+	if _, err := db.GetGrantedScopes(ctx, userID, ProviderGmail, "other@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown account: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestUserTokenRepository_UpdateRefreshedToken(t *testing.T) {
+	db, cleanup := SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	userID := "user_refresh"
+
+	if err := db.SaveUserToken(ctx, userID, ProviderGmail, "first@example.com", &oauth2.Token{AccessToken: "a1", RefreshToken: "r1"}); err != nil {
+		t.Fatalf("SaveUserToken failed: %v", err)
+	}
+	if err := db.SaveUserToken(ctx, userID, ProviderGmail, "second@example.com", &oauth2.Token{AccessToken: "a2", RefreshToken: "r2"}); err != nil {
+		t.Fatalf("SaveUserToken failed: %v", err)
+	}
+
+	// The refresh token picks the account, not the default one
+	if err := db.UpdateRefreshedToken(ctx, userID, ProviderGmail, "r2", &oauth2.Token{AccessToken: "a2-new", RefreshToken: "r2"}); err != nil {
+		t.Fatalf("UpdateRefreshedToken failed: %v", err)
+	}
+	if got, err := db.GetUserToken(ctx, userID, ProviderGmail, "second@example.com"); err != nil || got.AccessToken != "a2-new" {
+		t.Errorf("second account: got %+v, %v", got, err)
+	}
+	if got, err := db.GetUserToken(ctx, userID, ProviderGmail, "first@example.com"); err != nil || got.AccessToken != "a1" {
+		t.Errorf("first account changed: got %+v, %v", got, err)
+	}
+	if err := db.UpdateRefreshedToken(ctx, userID, ProviderGmail, "unknown", &oauth2.Token{AccessToken: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown refresh token: got %v, want ErrNotFound", err)
+	}
+	if err := db.UpdateRefreshedToken(ctx, "other_user", ProviderGmail, "r1", &oauth2.Token{AccessToken: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("other user: got %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,220 @@
+// Package digest gathers the newsletters a user receives during the day into a single
+// "Today's newsletters" message. Messages in the categories the user picked are archived at the
+// provider as they arrive and listed, with links to the originals, in a message the server writes
+// into the user's cache, one per local day.
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/health"
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	telemetryerrors "github.com/desponda/inbox-whisperer/internal/telemetry/errors"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+// DefaultTick is how often new messages are gathered into the digests
+const DefaultTick = 5 * time.Minute
+
+// batchSize bounds how many messages are archived for one user per tick
+const batchSize = 100
+
+// JobTypeDigest is the job type reported to the health monitor
+const JobTypeDigest = "newsletter_digest"
+
+// Sender is the From of the digest messages
+const Sender = "Inbox Whisperer <digest@inbox-whisperer.invalid>"
+
+const gmailLink = "https://mail.google.com/mail/u/0/#all/"
+
+// Archiver archives a message at the user's provider (see service.MessageActionService)
+type Archiver interface {
+	Archive(ctx context.Context, userID string, token *oauth2.Token, messageID string) error
+}
+
+// MessageCache stores the digest messages
+type MessageCache interface {
+	UpsertMessage(ctx context.Context, msg *models.EmailMessage) error
+}
+
+// Service archives newsletters into the users' daily digests
+type Service struct {
+	repo     data.NewsletterDigestRepository
+	messages MessageCache
+	tokens   data.UserTokenRepository
+	archiver Archiver
+
+	// Tick is how often new messages are gathered
+	Tick time.Duration
+	// Health, if set, receives heartbeats and run outcomes
+	Health *health.Worker
+	// Maintenance, if set, pauses the worker while it is on
+	Maintenance *maintenance.Switch
+	// Errors, if set, receives failed runs
+	Errors telemetryerrors.Reporter
+
+	now func() time.Time
+}
+
+func NewService(repo data.NewsletterDigestRepository, messages MessageCache, tokens data.UserTokenRepository, archiver Archiver) *Service {
+	return &Service{
+		repo:     repo,
+		messages: messages,
+		tokens:   tokens,
+		archiver: archiver,
+		Tick:     DefaultTick,
+		now:      time.Now,
+	}
+}
+
+// Start runs the worker until ctx is cancelled
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.Tick)
+		defer ticker.Stop()
+		for {
+			s.RunDue(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunDue archives the messages that arrived since the start of each user's local day in their
+// digest categories, refreshes the users' digest messages, and returns how many messages were
+// archived. A user whose archive fails is left for the next tick.
+func (s *Service) RunDue(ctx context.Context) int {
+	s.Health.Beat()
+	if s.Maintenance.Active() {
+		return 0
+	}
+	users, err := s.repo.ListEnabledUsers(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("digest: failed to list users")
+		return 0
+	}
+	archived := 0
+	for _, us := range users {
+		if ctx.Err() != nil {
+			break
+		}
+		n, err := s.runUser(ctx, us)
+		archived += n
+		if err != nil {
+			log.Warn().Err(err).Str("userID", us.UserID).Msg("digest: run failed")
+			if ctx.Err() == nil && !errors.Is(err, maintenance.ErrActive) && !errors.Is(err, provider.ErrCircuitOpen) {
+				telemetryerrors.Capture(ctx, s.Errors, err, us.UserID, map[string]string{"job_type": JobTypeDigest})
+			}
+		}
+		if n > 0 || err != nil {
+			s.Health.Record(JobTypeDigest, err)
+		}
+	}
+	return archived
+}
+
+// runUser archives the user's new candidates and, if any were, rewrites the day's digest
+func (s *Service) runUser(ctx context.Context, us *models.UserSettings) (int, error) {
+	loc, err := time.LoadLocation(us.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := s.now().In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+	msgs, err := s.repo.Candidates(ctx, us.UserID, us.DigestCategories, start.UnixMilli(), batchSize)
+	if err != nil || len(msgs) == 0 {
+		return 0, err
+	}
+	token, err := s.tokens.GetUserToken(ctx, us.UserID, data.ProviderGmail, "")
+	if err != nil {
+		return 0, fmt.Errorf("no usable token: %w", err)
+	}
+	archived := 0
+	for _, m := range msgs {
+		if err = s.archiver.Archive(ctx, us.UserID, token, m.EmailMessageID); err != nil {
+			break
+		}
+		if err = s.repo.Add(ctx, &models.DigestItem{UserID: us.UserID, EmailMessageID: m.EmailMessageID, Day: day}); err != nil {
+			break
+		}
+		archived++
+	}
+	if archived > 0 {
+		// What was archived is listed even when a later archive failed
+		if rerr := s.rebuild(ctx, us.UserID, day, local); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	return archived, err
+}
+
+// rebuild writes the user's digest message for day, dated now so it comes first in the list
+func (s *Service) rebuild(ctx context.Context, userID string, day, now time.Time) error {
+	items, err := s.repo.Items(ctx, userID, day)
+	if err != nil || len(items) == 0 {
+		return err
+	}
+	return s.messages.UpsertMessage(ctx, Compose(userID, day, now, items))
+}
+
+// Compose writes the digest message listing items, the messages archived into the digest for
+// day, with a link to each in Gmail
+func Compose(userID string, day, now time.Time, items []*models.DigestItem) *models.EmailMessage {
+	id := models.DigestMessageID(day)
+	subject := fmt.Sprintf("Today's newsletters (%d)", len(items))
+	var text, htm strings.Builder
+	fmt.Fprintf(&text, "%d newsletters arrived today and were archived:\n\n", len(items))
+	fmt.Fprintf(&htm, "<p>%d newsletters arrived today and were archived:</p>\n<ul>\n", len(items))
+	senders := make([]string, 0, len(items))
+	for _, it := range items {
+		from := senderName(it.Sender)
+		senders = append(senders, from)
+		fmt.Fprintf(&text, "- %s: %s\n  %s\n", from, it.Subject, gmailLink+it.EmailMessageID)
+		fmt.Fprintf(&htm, "<li><a href=\"%s\">%s</a> &mdash; %s<br><small>%s</small></li>\n",
+			html.EscapeString(gmailLink+it.EmailMessageID), html.EscapeString(it.Subject), html.EscapeString(from), html.EscapeString(it.Snippet))
+	}
+	htm.WriteString("</ul>\n")
+	m := &models.EmailMessage{
+		UserID:         userID,
+		EmailMessageID: id,
+		ThreadID:       id,
+		Subject:        subject,
+		Sender:         Sender,
+		Snippet:        strings.Join(senders, ", "),
+		Body:           text.String(),
+		HTMLBody:       htm.String(),
+		InternalDate:   now.UnixMilli(),
+		Date:           now.Format(time.RFC1123Z),
+		CachedAt:       now,
+	}
+	m.LastFetchedAt.Time, m.LastFetchedAt.Valid = now, true
+	m.ParseSender()
+	return m
+}
+
+// senderName is the display name of a From header, or the header itself without one
+func senderName(from string) string {
+	m := models.EmailMessage{Sender: from}
+	m.ParseSender()
+	if m.SenderName != "" {
+		return m.SenderName
+	}
+	if m.SenderAddress != "" {
+		return m.SenderAddress
+	}
+	return from
+}
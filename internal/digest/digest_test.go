@@ -0,0 +1,126 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"golang.org/x/oauth2"
+)
+
+// memoryDigests keeps digest items in memory; candidates are every message not yet digested
+type memoryDigests struct {
+	users    []*models.UserSettings
+	messages []*models.EmailMessage
+	items    []*models.DigestItem
+	since    int64
+}
+
+func (m *memoryDigests) ListEnabledUsers(ctx context.Context) ([]*models.UserSettings, error) {
+	return m.users, nil
+}
+
+func (m *memoryDigests) Candidates(ctx context.Context, userID string, categories []string, sinceMillis int64, limit int) ([]*models.EmailMessage, error) {
+	m.since = sinceMillis
+	var out []*models.EmailMessage
+	for _, msg := range m.messages {
+		if !slices.ContainsFunc(m.items, func(it *models.DigestItem) bool { return it.EmailMessageID == msg.EmailMessageID }) {
+			out = append(out, msg)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryDigests) Add(ctx context.Context, item *models.DigestItem) error {
+	m.items = append(m.items, item)
+	return nil
+}
+
+func (m *memoryDigests) Items(ctx context.Context, userID string, day time.Time) ([]*models.DigestItem, error) {
+	var out []*models.DigestItem
+	for _, it := range m.items {
+		if it.Day.Equal(day) {
+			msg := m.messages[slices.IndexFunc(m.messages, func(msg *models.EmailMessage) bool { return msg.EmailMessageID == it.EmailMessageID })]
+			out = append(out, &models.DigestItem{EmailMessageID: it.EmailMessageID, Day: it.Day, Subject: msg.Subject, Sender: msg.Sender})
+		}
+	}
+	return out, nil
+}
+
+type fakeCache struct{ upserted []*models.EmailMessage }
+
+func (f *fakeCache) UpsertMessage(ctx context.Context, msg *models.EmailMessage) error {
+	f.upserted = append(f.upserted, msg)
+	return nil
+}
+
+type fakeTokens struct{}
+
+func (fakeTokens) SaveUserToken(ctx context.Context, userID, provider, accountID string, token *oauth2.Token) error {
+	return nil
+}
+
+func (fakeTokens) GetUserToken(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: "tok-" + userID}, nil
+}
+
+type fakeArchiver struct {
+	archived []string
+	failOn   string
+}
+
+func (f *fakeArchiver) Archive(ctx context.Context, userID string, token *oauth2.Token, messageID string) error {
+	if messageID == f.failOn {
+		return errors.New("boom")
+	}
+	f.archived = append(f.archived, messageID)
+	return nil
+}
+
+func TestService_RunDue(t *testing.T) {
+	repo := &memoryDigests{
+		users: []*models.UserSettings{{UserID: "u1", Timezone: "Europe/Berlin", DigestCategories: []string{"Promotions/Ads"}}},
+		messages: []*models.EmailMessage{
+			{EmailMessageID: "m1", Subject: "Weekly <deals>", Sender: "Shop <news@shop.example>"},
+			{EmailMessageID: "m2", Subject: "Issue 42", Sender: "letters@blog.example"},
+		},
+	}
+	cache := &fakeCache{}
+	archiver := &fakeArchiver{failOn: "m2"}
+	s := NewService(repo, cache, fakeTokens{}, archiver)
+	// 23:30 UTC is already the next day in Berlin
+	s.now = func() time.Time { return time.Date(2026, 10, 15, 23, 30, 0, 0, time.UTC) }
+
+	if n := s.RunDue(context.Background()); n != 1 {
+		t.Fatalf("expected the message before the failure to be archived, got %d", n)
+	}
+	if want := time.Date(2026, 10, 16, 0, 0, 0, 0, time.FixedZone("CEST", 2*3600)).UnixMilli(); repo.since != want {
+		t.Errorf("expected candidates since local midnight %d, got %d", want, repo.since)
+	}
+	if len(cache.upserted) != 1 {
+		t.Fatalf("expected the digest to be written, got %d", len(cache.upserted))
+	}
+	d := cache.upserted[0]
+	if d.EmailMessageID != "whisperer-digest-2026-10-16" || d.Subject != "Today's newsletters (1)" || d.Snippet != "Shop" {
+		t.Errorf("unexpected digest %q %q %q", d.EmailMessageID, d.Subject, d.Snippet)
+	}
+	if !strings.Contains(d.HTMLBody, "Weekly &lt;deals&gt;") || !strings.Contains(d.Body, gmailLink+"m1") {
+		t.Errorf("expected escaped subjects and links to the originals, got %q / %q", d.HTMLBody, d.Body)
+	}
+
+	// The next run picks up where the failed one stopped and adds to the same digest
+	archiver.failOn = ""
+	if n := s.RunDue(context.Background()); n != 1 {
+		t.Fatalf("expected the remaining message to be archived, got %d", n)
+	}
+	if d := cache.upserted[1]; d.EmailMessageID != "whisperer-digest-2026-10-16" || d.Subject != "Today's newsletters (2)" {
+		t.Errorf("unexpected digest %q %q", d.EmailMessageID, d.Subject)
+	}
+	if n := s.RunDue(context.Background()); n != 0 || len(cache.upserted) != 2 {
+		t.Errorf("expected nothing new to leave the digest alone, got %d archived and %d writes", n, len(cache.upserted))
+	}
+}
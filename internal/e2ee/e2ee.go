@@ -0,0 +1,173 @@
+// Package e2ee encrypts cached message bodies so that only the user can read them.
+//
+// A user's passphrase derives an X25519 key pair. The public key and the derivation salt are
+// stored; the private key never is. It lives only in the user's session while they have
+// unlocked it. Syncs therefore keep sealing new bodies to the public key without the
+// passphrase, and the server operator cannot read what was cached.
+//
+// Each body is sealed with a fresh ephemeral key: AES-256-GCM under a key derived with HKDF from
+// the X25519 shared secret. Sealed values are text, so they fit the existing body columns.
+package e2ee
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Prefix marks a sealed value; the suffix is the base64 of ephemeral public key, nonce and ciphertext
+const Prefix = "e2ee:v1:"
+
+// MinPassphraseLength is the shortest passphrase accepted when a key is created
+const MinPassphraseLength = 12
+
+// PBKDF2 parameters. The iteration count follows OWASP's current recommendation for
+// PBKDF2-HMAC-SHA256 and makes each unlock attempt take a noticeable fraction of a second.
+const (
+	pbkdf2Iterations = 600_000
+	saltSize         = 16
+)
+
+var hkdfInfo = "inbox-whisperer body " + Prefix
+
+var (
+	ErrWeakPassphrase  = fmt.Errorf("e2ee: passphrase must be at least %d characters", MinPassphraseLength)
+	ErrWrongPassphrase = errors.New("e2ee: wrong passphrase")
+	ErrMalformed       = errors.New("e2ee: malformed sealed value")
+)
+
+// NewKey derives a key pair from a new passphrase with a fresh salt. It returns the salt and
+// public key to store, and the private key to keep in the session.
+func NewKey(passphrase string) (salt, public []byte, private *ecdh.PrivateKey, err error) {
+	if len(passphrase) < MinPassphraseLength {
+		return nil, nil, nil, ErrWeakPassphrase
+	}
+	salt = make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, nil, err
+	}
+	private, err = derive(passphrase, salt)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return salt, private.PublicKey().Bytes(), private, nil
+}
+
+// Unlock derives the private key from passphrase and checks it against the stored public key
+func Unlock(passphrase string, salt, public []byte) (*ecdh.PrivateKey, error) {
+	private, err := derive(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(private.PublicKey().Bytes(), public) {
+		return nil, ErrWrongPassphrase
+	}
+	return private, nil
+}
+
+// PrivateKey parses a private key previously returned by NewKey or Unlock (as Bytes)
+func PrivateKey(raw []byte) (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().NewPrivateKey(raw)
+}
+
+func derive(passphrase string, salt []byte) (*ecdh.PrivateKey, error) {
+	seed, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPrivateKey(seed)
+}
+
+// IsSealed reports whether s was produced by Seal
+func IsSealed(s string) bool {
+	return strings.HasPrefix(s, Prefix)
+}
+
+// Seal encrypts plaintext to the public key. The empty string stays empty, so an absent body
+// is still recognizable as such.
+func Seal(public []byte, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	recipient, err := ecdh.X25519().NewPublicKey(public)
+	if err != nil {
+		return "", fmt.Errorf("e2ee: public key: %w", err)
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(shared, ephemeral.PublicKey().Bytes(), public)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	out := append(ephemeral.PublicKey().Bytes(), nonce...)
+	out = aead.Seal(out, nonce, []byte(plaintext), nil)
+	return Prefix + base64.RawStdEncoding.EncodeToString(out), nil
+}
+
+// Open decrypts a value produced by Seal. Values that are not sealed are returned unchanged.
+func Open(private *ecdh.PrivateKey, sealed string) (string, error) {
+	if !IsSealed(sealed) {
+		return sealed, nil
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(sealed, Prefix))
+	if err != nil {
+		return "", ErrMalformed
+	}
+	const keySize = 32
+	if len(raw) < keySize {
+		return "", ErrMalformed
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(raw[:keySize])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	shared, err := private.ECDH(ephemeral)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(shared, raw[:keySize], private.PublicKey().Bytes())
+	if err != nil {
+		return "", err
+	}
+	rest := raw[keySize:]
+	if len(rest) < aead.NonceSize() {
+		return "", ErrMalformed
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("e2ee: open: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// newAEAD derives the content key from the shared secret, bound to both public keys
+func newAEAD(shared, ephemeral, recipient []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, shared, append(append([]byte{}, ephemeral...), recipient...), hkdfInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,60 @@
+package e2ee
+
+import (
+	"crypto/ecdh"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	salt, public, private, err := NewKey("correct horse battery")
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+	sealed, err := Seal(public, "meet at noon")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "noon") {
+		t.Fatalf("expected an opaque sealed value, got %q", sealed)
+	}
+	again, _ := Seal(public, "meet at noon")
+	if again == sealed {
+		t.Error("expected each seal to use a fresh ephemeral key")
+	}
+
+	unlocked, err := Unlock("correct horse battery", salt, public)
+	if err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	fromSession, err := PrivateKey(private.Bytes())
+	if err != nil {
+		t.Fatalf("PrivateKey: %v", err)
+	}
+	for _, k := range []*ecdh.PrivateKey{private, unlocked, fromSession} {
+		if got, err := Open(k, sealed); err != nil || got != "meet at noon" {
+			t.Errorf("Open = %q, %v", got, err)
+		}
+	}
+
+	if _, err := Unlock("wrong horse battery", salt, public); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase, got %v", err)
+	}
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if _, err := Open(unlocked, tampered); err == nil {
+		t.Error("expected a tampered value to fail to open")
+	}
+	if got, err := Open(unlocked, "plain"); err != nil || got != "plain" {
+		t.Errorf("expected unsealed values to pass through, got %q, %v", got, err)
+	}
+	if got, err := Seal(public, ""); err != nil || got != "" {
+		t.Errorf("expected the empty body to stay empty, got %q, %v", got, err)
+	}
+}
+
+func TestNewKey_WeakPassphrase(t *testing.T) {
+	if _, _, _, err := NewKey("short"); !errors.Is(err, ErrWeakPassphrase) {
+		t.Errorf("expected ErrWeakPassphrase, got %v", err)
+	}
+}
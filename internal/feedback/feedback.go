@@ -0,0 +1,94 @@
+// Package feedback records users' corrections to message categorization and
+// turns repeated corrections into rule suggestions.
+package feedback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+// ErrInvalidFeedback is returned for malformed feedback
+var ErrInvalidFeedback = errors.New("invalid feedback")
+
+// SuggestionThreshold is how many corrections of one sender to the same category trigger a rule suggestion
+const SuggestionThreshold = 2
+
+const maxCategoryLength = 100
+
+// Service records categorization feedback
+type Service struct {
+	repo  data.CategoryFeedbackRepository
+	rules data.RuleRepository
+}
+
+func NewService(repo data.CategoryFeedbackRepository, rules data.RuleRepository) *Service {
+	return &Service{repo: repo, rules: rules}
+}
+
+// Submit records that messageID's category is correct, or should be category instead.
+// The message is updated immediately. Repeated corrections for the same sender produce a suggested rule.
+func (s *Service) Submit(ctx context.Context, userID, messageID, category string, correct bool) (*models.CategoryFeedbackResult, error) {
+	category = strings.TrimSpace(category)
+	if !correct && category == "" {
+		return nil, fmt.Errorf("%w: category is required for a correction", ErrInvalidFeedback)
+	}
+	if len(category) > maxCategoryLength {
+		return nil, fmt.Errorf("%w: category is too long", ErrInvalidFeedback)
+	}
+	fb := &models.CategoryFeedback{UserID: userID, MessageID: messageID, Category: category, Correct: correct}
+	if err := s.repo.RecordFeedback(ctx, fb); err != nil {
+		return nil, err
+	}
+	res := &models.CategoryFeedbackResult{Feedback: fb}
+	if correct || fb.Sender == "" {
+		return res, nil
+	}
+	suggestion, err := s.suggestRule(ctx, fb)
+	if err != nil {
+		// The feedback is stored; a missing suggestion is not worth failing the request
+		return res, nil
+	}
+	res.SuggestedRule = suggestion
+	return res, nil
+}
+
+// suggestRule proposes "always categorize sender as category" once the user has made the
+// same correction SuggestionThreshold times, unless an equivalent rule already exists
+func (s *Service) suggestRule(ctx context.Context, fb *models.CategoryFeedback) (*models.Rule, error) {
+	n, err := s.repo.CountCorrections(ctx, fb.UserID, fb.Sender, fb.Category)
+	if err != nil || n < SuggestionThreshold {
+		return nil, err
+	}
+	existing, err := s.rules.ListByUser(ctx, fb.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range existing {
+		if categorizesSender(r, fb.Sender, fb.Category) {
+			return nil, nil
+		}
+	}
+	return &models.Rule{
+		Name:       fmt.Sprintf("Categorize %s as %s", fb.Sender, fb.Category),
+		Conditions: []models.RuleCondition{{Field: models.RuleFieldFrom, Contains: fb.Sender}},
+		Actions:    []models.RuleAction{{Type: models.RuleActionSetCategory, Category: fb.Category}},
+		Enabled:    true,
+	}, nil
+}
+
+func categorizesSender(r *models.Rule, sender, category string) bool {
+	if len(r.Conditions) != 1 || r.Conditions[0].Field != models.RuleFieldFrom || !strings.EqualFold(r.Conditions[0].Contains, sender) {
+		return false
+	}
+	for _, a := range r.Actions {
+		if a.Type == models.RuleActionSetCategory && a.Category == category {
+			return true
+		}
+	}
+	return false
+}
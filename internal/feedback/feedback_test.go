@@ -0,0 +1,94 @@
+package feedback
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+type fakeFeedbackRepo struct {
+	recorded []*models.CategoryFeedback
+}
+
+func (f *fakeFeedbackRepo) RecordFeedback(ctx context.Context, fb *models.CategoryFeedback) error {
+	if fb.MessageID == "missing" {
+		return data.ErrNotFound
+	}
+	fb.Sender = "alerts@bank.example"
+	fb.PreviousCategory = "Updates"
+	if fb.Correct && fb.Category == "" {
+		fb.Category = fb.PreviousCategory
+	}
+	f.recorded = append(f.recorded, fb)
+	return nil
+}
+
+func (f *fakeFeedbackRepo) CountCorrections(ctx context.Context, userID, sender, category string) (int, error) {
+	n := 0
+	for _, fb := range f.recorded {
+		if !fb.Correct && fb.Sender == sender && fb.Category == category {
+			n++
+		}
+	}
+	return n, nil
+}
+
+type fakeRuleRepo struct {
+	rules []*models.Rule
+}
+
+func (f *fakeRuleRepo) Create(ctx context.Context, rule *models.Rule) error { return nil }
+func (f *fakeRuleRepo) ListByUser(ctx context.Context, userID string) ([]*models.Rule, error) {
+	return f.rules, nil
+}
+func (f *fakeRuleRepo) Delete(ctx context.Context, userID string, id int64) error { return nil }
+
+func TestSubmit_SuggestsRuleAfterRepeatedCorrections(t *testing.T) {
+	ctx := context.Background()
+	rules := &fakeRuleRepo{}
+	svc := NewService(&fakeFeedbackRepo{}, rules)
+
+	res, err := svc.Submit(ctx, "u1", "m1", "Finance", false)
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if res.Feedback.PreviousCategory != "Updates" || res.SuggestedRule != nil {
+		t.Errorf("expected no suggestion after one correction, got %+v", res)
+	}
+	res, err = svc.Submit(ctx, "u1", "m2", "Finance", false)
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	rule := res.SuggestedRule
+	if rule == nil {
+		t.Fatal("expected a rule suggestion after two corrections")
+	}
+	if rule.Conditions[0].Contains != "alerts@bank.example" || rule.Actions[0].Type != models.RuleActionSetCategory || rule.Actions[0].Category != "Finance" {
+		t.Errorf("unexpected suggestion %+v", rule)
+	}
+
+	// No suggestion once the user has an equivalent rule
+	rules.rules = []*models.Rule{rule}
+	res, _ = svc.Submit(ctx, "u1", "m3", "Finance", false)
+	if res.SuggestedRule != nil {
+		t.Errorf("expected no suggestion when the rule exists, got %+v", res.SuggestedRule)
+	}
+}
+
+func TestSubmit_Validation(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(&fakeFeedbackRepo{}, &fakeRuleRepo{})
+	if _, err := svc.Submit(ctx, "u1", "m1", " ", false); !errors.Is(err, ErrInvalidFeedback) {
+		t.Errorf("expected ErrInvalidFeedback for a correction without category, got %v", err)
+	}
+	res, err := svc.Submit(ctx, "u1", "m1", "", true)
+	if err != nil || res.Feedback.Category != "Updates" {
+		t.Errorf("expected confirmation of the current category, got %+v (err %v)", res, err)
+	}
+	if _, err := svc.Submit(ctx, "u1", "missing", "Finance", false); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/e2ee"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/render"
+)
+
+// gmailLink opens a message in Gmail's web UI
+const gmailLink = "https://mail.google.com/mail/u/0/#all/"
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Updated   string   `xml:"updated"`
+	Published string   `xml:"published"`
+	Link      atomLink `xml:"link"`
+	Content   atomText `xml:"content"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Render builds the Atom document for feed from msgs, newest first. HTML bodies are sanitized;
+// bodies sealed by an encrypted cache are left out, as the feed reader cannot open them.
+func Render(feed *models.Feed, msgs []*models.EmailMessage, now time.Time) ([]byte, error) {
+	doc := atomFeed{
+		ID:      fmt.Sprintf("urn:inbox-whisperer:feed:%d", feed.ID),
+		Title:   feed.SenderAddress,
+		Updated: now.UTC().Format(time.RFC3339),
+		Author:  atomPerson{Name: feed.SenderAddress, Email: feed.SenderAddress},
+	}
+	if len(msgs) > 0 {
+		if name := msgs[0].SenderName; name != "" {
+			doc.Title, doc.Author.Name = name, name
+		}
+		doc.Updated = received(msgs[0]).Format(time.RFC3339)
+	}
+	for _, msg := range msgs {
+		at := received(msg).Format(time.RFC3339)
+		title := msg.Subject
+		if title == "" {
+			title = "(no subject)"
+		}
+		doc.Entries = append(doc.Entries, atomEntry{
+			ID:        "urn:inbox-whisperer:message:" + msg.EmailMessageID,
+			Title:     title,
+			Updated:   at,
+			Published: at,
+			Link:      atomLink{Href: gmailLink + msg.EmailMessageID, Rel: "alternate"},
+			Content:   atomText{Type: "html", Body: content(msg)},
+		})
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// content returns the entry body as HTML
+func content(msg *models.EmailMessage) string {
+	switch {
+	case e2ee.IsSealed(msg.Body) || e2ee.IsSealed(msg.HTMLBody):
+		return "<p><em>This message is stored encrypted and can only be read in Inbox Whisperer.</em></p>"
+	case msg.HTMLBody != "":
+		return render.SanitizeHTML(msg.HTMLBody)
+	case msg.Body != "":
+		return "<pre>" + html.EscapeString(msg.Body) + "</pre>"
+	default:
+		return "<p>" + html.EscapeString(msg.Snippet) + "</p>"
+	}
+}
+
+func received(msg *models.EmailMessage) time.Time {
+	if msg.InternalDate > 0 {
+		return time.UnixMilli(msg.InternalDate).UTC()
+	}
+	return msg.CachedAt.UTC()
+}
@@ -0,0 +1,146 @@
+// Package feeds serves Atom feeds of the messages a user receives from one sender, so
+// newsletters can be read in a feed reader. A feed's URL carries a random token that is its only
+// credential; only the token's hash is stored. Feeds are rendered when created and again after
+// each sync, and served from the stored copy.
+package feeds
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/notify"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxEntries is how many of the sender's most recent messages a feed shows
+const MaxEntries = 50
+
+var (
+	// ErrInvalidToken is returned by Content for unknown and malformed tokens
+	ErrInvalidToken = errors.New("invalid feed token")
+	// ErrInvalidSender is returned by Create when the sender is not an email address
+	ErrInvalidSender = errors.New("sender must be an email address")
+)
+
+// Service creates feeds and keeps their rendered documents current
+type Service struct {
+	repo data.FeedRepository
+	now  func() time.Time
+}
+
+func NewService(repo data.FeedRepository) *Service {
+	return &Service{repo: repo, now: time.Now}
+}
+
+// Subscribe regenerates the user's feeds after each sync. It returns a function that removes the subscription.
+func (s *Service) Subscribe() (unsubscribe func()) {
+	return notify.Subscribe(notify.EventSyncComplete, func(ctx context.Context, userID string) {
+		if err := s.Refresh(ctx, userID); err != nil {
+			log.Error().Str("userID", userID).Err(err).Msg("feeds: failed to regenerate feeds")
+		}
+	})
+}
+
+// Create adds a feed of the user's messages from sender and returns it with its token. The
+// token is only available here. Returns data.ErrAlreadyExists if the user has a feed for sender.
+func (s *Service) Create(ctx context.Context, userID, sender string) (*models.Feed, string, error) {
+	sender = strings.ToLower(strings.TrimSpace(sender))
+	if at := strings.LastIndex(sender, "@"); at < 1 || at == len(sender)-1 || strings.ContainsAny(sender, " <>") {
+		return nil, "", ErrInvalidSender
+	}
+	token, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+	feed := &models.Feed{UserID: userID, SenderAddress: sender}
+	if err := s.repo.Create(ctx, feed, hash(token)); err != nil {
+		return nil, "", err
+	}
+	if err := s.generate(ctx, feed); err != nil {
+		return nil, "", err
+	}
+	return feed, token, nil
+}
+
+// List returns the user's feeds
+func (s *Service) List(ctx context.Context, userID string) ([]*models.Feed, error) {
+	return s.repo.ListByUser(ctx, userID)
+}
+
+// Delete removes a feed, which stops its URL working; returns data.ErrNotFound for unknown IDs
+func (s *Service) Delete(ctx context.Context, userID string, id int64) error {
+	return s.repo.Delete(ctx, userID, id)
+}
+
+// Content returns the feed with token, including its rendered document
+func (s *Service) Content(ctx context.Context, token string) (*models.Feed, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+	feed, err := s.repo.GetByToken(ctx, hash(token))
+	if errors.Is(err, data.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if feed.Content == nil {
+		if err := s.generate(ctx, feed); err != nil {
+			return nil, err
+		}
+	}
+	return feed, nil
+}
+
+// Refresh regenerates each of the user's feeds from their cached messages
+func (s *Service) Refresh(ctx context.Context, userID string) error {
+	feeds, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, feed := range feeds {
+		if err := s.generate(ctx, feed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generate renders feed and stores the result on it and in the repository
+func (s *Service) generate(ctx context.Context, feed *models.Feed) error {
+	msgs, err := s.repo.SenderMessages(ctx, feed.UserID, feed.SenderAddress, MaxEntries)
+	if err != nil {
+		return err
+	}
+	now := s.now().UTC()
+	content, err := Render(feed, msgs, now)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.SaveContent(ctx, feed.ID, content, now); err != nil {
+		return err
+	}
+	feed.Content, feed.GeneratedAt = content, &now
+	return nil
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,134 @@
+package feeds
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestRender(t *testing.T) {
+	feed := &models.Feed{ID: 7, SenderAddress: "news@example.com"}
+	msgs := []*models.EmailMessage{
+		{EmailMessageID: "m2", SenderName: "Example News", Subject: "Issue 2", HTMLBody: `<p>Two<script>x</script></p>`, InternalDate: 1_700_000_000_000},
+		{EmailMessageID: "m1", Subject: "Issue 1 <&>", Body: "One & only", InternalDate: 1_699_000_000_000},
+		{EmailMessageID: "m0", Subject: "Locked", Body: "e2ee:v1:abc"},
+	}
+	out, err := Render(feed, msgs, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc atomFeed
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, out)
+	}
+	if doc.Title != "Example News" || doc.Updated != "2023-11-14T22:13:20Z" || len(doc.Entries) != 3 {
+		t.Fatalf("unexpected feed %+v", doc)
+	}
+	if e := doc.Entries[0]; e.Content.Body != "<p>Two</p>" || e.Link.Href != gmailLink+"m2" {
+		t.Errorf("unexpected first entry %+v", e)
+	}
+	if e := doc.Entries[1]; e.Title != "Issue 1 <&>" || e.Content.Body != "<pre>One &amp; only</pre>" {
+		t.Errorf("unexpected second entry %+v", e)
+	}
+	if strings.Contains(doc.Entries[2].Content.Body, "e2ee:") {
+		t.Errorf("sealed body leaked into the feed: %q", doc.Entries[2].Content.Body)
+	}
+}
+
+type memoryRepo struct {
+	feeds map[string]*models.Feed // by token hash
+	msgs  []*models.EmailMessage
+}
+
+func (m *memoryRepo) Create(ctx context.Context, feed *models.Feed, tokenHash string) error {
+	for _, f := range m.feeds {
+		if f.UserID == feed.UserID && f.SenderAddress == feed.SenderAddress {
+			return data.ErrAlreadyExists
+		}
+	}
+	feed.ID = int64(len(m.feeds) + 1)
+	m.feeds[tokenHash] = feed
+	return nil
+}
+func (m *memoryRepo) ListByUser(ctx context.Context, userID string) ([]*models.Feed, error) {
+	var out []*models.Feed
+	for _, f := range m.feeds {
+		if f.UserID == userID {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+func (m *memoryRepo) Delete(ctx context.Context, userID string, id int64) error {
+	for h, f := range m.feeds {
+		if f.UserID == userID && f.ID == id {
+			delete(m.feeds, h)
+			return nil
+		}
+	}
+	return data.ErrNotFound
+}
+func (m *memoryRepo) GetByToken(ctx context.Context, tokenHash string) (*models.Feed, error) {
+	if f, ok := m.feeds[tokenHash]; ok {
+		return f, nil
+	}
+	return nil, data.ErrNotFound
+}
+func (m *memoryRepo) SaveContent(ctx context.Context, id int64, content []byte, generatedAt time.Time) error {
+	return nil
+}
+func (m *memoryRepo) SenderMessages(ctx context.Context, userID, senderAddress string, limit int) ([]*models.EmailMessage, error) {
+	var out []*models.EmailMessage
+	for _, msg := range m.msgs {
+		if msg.UserID == userID && msg.SenderAddress == senderAddress {
+			out = append(out, msg)
+		}
+	}
+	return out, nil
+}
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	repo := &memoryRepo{feeds: map[string]*models.Feed{}}
+	repo.msgs = []*models.EmailMessage{{UserID: "u1", EmailMessageID: "m1", SenderAddress: "news@example.com", Subject: "First"}}
+	svc := NewService(repo)
+
+	if _, _, err := svc.Create(ctx, "u1", "not an address"); !errors.Is(err, ErrInvalidSender) {
+		t.Fatalf("expected ErrInvalidSender, got %v", err)
+	}
+	feed, token, err := svc.Create(ctx, "u1", " News@Example.com ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.SenderAddress != "news@example.com" || token == "" || !strings.Contains(string(feed.Content), "First") {
+		t.Fatalf("unexpected feed %+v", feed)
+	}
+	if _, _, err := svc.Create(ctx, "u1", "news@example.com"); !errors.Is(err, data.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	repo.msgs = append(repo.msgs, &models.EmailMessage{UserID: "u1", EmailMessageID: "m2", SenderAddress: "news@example.com", Subject: "Second"})
+	if err := svc.Refresh(ctx, "u1"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := svc.Content(ctx, token)
+	if err != nil || !strings.Contains(string(got.Content), "Second") {
+		t.Fatalf("expected regenerated feed, got %v %s", err, got.Content)
+	}
+	if _, err := svc.Content(ctx, token+"x"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+
+	if err := svc.Delete(ctx, "u1", feed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Content(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken after delete, got %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+// Package gmailpush keeps a Gmail push notification watch registered for every user's Gmail
+// account and syncs a mailbox as soon as Google Pub/Sub reports a change to it, so new mail
+// reaches the cache within seconds instead of on the next scheduled sync.
+package gmailpush
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/health"
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/scheduler"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/debuglog"
+	telemetryerrors "github.com/desponda/inbox-whisperer/internal/telemetry/errors"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrInvalidPush means a push body is not a Gmail notification
+	ErrInvalidPush = errors.New("invalid Gmail push notification")
+	// ErrUnknownAccount means a push names an account no user has a watch on
+	ErrUnknownAccount = errors.New("no watch on the pushed Gmail account")
+)
+
+// Renewal pacing. Watches are renewed a day before Gmail expires them; a failed registration
+// is retried after retryAfter.
+const (
+	idlePoll     = 10 * time.Minute
+	renewEarly   = 24 * time.Hour
+	retryAfter   = time.Hour
+	renewBatch   = 50
+	watchTimeout = 30 * time.Second
+)
+
+// Job types reported to the health monitor
+const (
+	JobTypeWatch = "gmail_watch"
+	JobTypePush  = "gmail_push_sync"
+)
+
+// Watcher registers Gmail watches (see gmail.GmailService.Watch)
+type Watcher interface {
+	Watch(ctx context.Context, token *oauth2.Token, topic string) (uint64, time.Time, error)
+}
+
+// Service renews users' Gmail watches and syncs mailboxes Gmail reports changes to
+type Service struct {
+	watches data.GmailWatchRepository
+	tokens  data.UserTokenRepository
+	watcher Watcher
+	syncer  scheduler.Syncer
+	topic   string
+
+	// Health, if set, receives heartbeats and renewal and sync outcomes
+	Health *health.Worker
+	// Maintenance, if set, pauses renewals and push-triggered syncs while it is on
+	Maintenance *maintenance.Switch
+	// Errors, if set, receives failed registrations and syncs
+	Errors telemetryerrors.Reporter
+	// DebugLog, if set, marks syncs of users with debug logging on
+	DebugLog *debuglog.Toggles
+
+	mu sync.Mutex
+	// syncing holds the users with a push-triggered sync running, and whether another push
+	// arrived for them since it started
+	syncing map[string]bool
+	wg      sync.WaitGroup
+	now     func() time.Time
+}
+
+// NewService creates a Service publishing watches to topic
+func NewService(watches data.GmailWatchRepository, tokens data.UserTokenRepository, watcher Watcher, syncer scheduler.Syncer, topic string) *Service {
+	return &Service{
+		watches: watches,
+		tokens:  tokens,
+		watcher: watcher,
+		syncer:  syncer,
+		topic:   topic,
+		syncing: map[string]bool{},
+		now:     time.Now,
+	}
+}
+
+// Pending reports the watches due for registration, for the worker monitor
+func (s *Service) Pending(ctx context.Context) (int, *time.Time, error) {
+	return s.watches.PendingWatches(ctx, s.now())
+}
+
+// Start runs the renewal worker until ctx is cancelled
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(idlePoll)
+		defer ticker.Stop()
+		for {
+			s.RenewDue(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RenewDue registers every due watch and returns how many it attempted
+func (s *Service) RenewDue(ctx context.Context) int {
+	attempted := 0
+	for ctx.Err() == nil {
+		s.Health.Beat()
+		if err := maintenance.Wait(ctx, s.Maintenance, s.Health.Beat); err != nil {
+			break
+		}
+		due, err := s.watches.ListDueWatches(ctx, s.now(), renewBatch)
+		if err != nil {
+			log.Error().Err(err).Msg("gmailpush: failed to list due watches")
+			break
+		}
+		for i := range due {
+			if ctx.Err() != nil {
+				break
+			}
+			s.renew(ctx, &due[i])
+			attempted++
+		}
+		if len(due) < renewBatch {
+			break
+		}
+	}
+	return attempted
+}
+
+// renew registers one watch and stores the outcome; a failure is retried after retryAfter
+func (s *Service) renew(ctx context.Context, w *models.GmailWatch) {
+	err := s.register(ctx, w)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		w.Error, w.RenewAfter = err.Error(), s.now().Add(retryAfter)
+		log.Warn().Err(err).Str("userID", w.UserID).Msg("gmailpush: watch registration failed")
+		telemetryerrors.Capture(ctx, s.Errors, err, w.UserID, map[string]string{"job_type": JobTypeWatch})
+	}
+	s.Health.Record(JobTypeWatch, err)
+	if err := s.watches.SaveWatch(ctx, w); err != nil {
+		log.Error().Err(err).Str("userID", w.UserID).Msg("gmailpush: failed to save watch")
+	}
+}
+
+func (s *Service) register(ctx context.Context, w *models.GmailWatch) error {
+	token, err := s.tokens.GetUserToken(ctx, w.UserID, data.ProviderGmail, w.AccountID)
+	if err != nil {
+		return fmt.Errorf("load token: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, watchTimeout)
+	defer cancel()
+	historyID, expires, err := s.watcher.Watch(ctx, token, s.topic)
+	if err != nil {
+		return err
+	}
+	w.HistoryID, w.ExpiresAt, w.RenewAfter, w.Error = int64(historyID), &expires, expires.Add(-renewEarly), ""
+	return nil
+}
+
+// Push is a Pub/Sub push request body
+type Push struct {
+	Message struct {
+		Data      string `json:"data"`
+		MessageID string `json:"messageId"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+// Notification is what Gmail publishes on a mailbox change
+type Notification struct {
+	EmailAddress string `json:"emailAddress"`
+	HistoryID    uint64 `json:"historyId"`
+}
+
+// Decode returns the Gmail notification carried by a push
+func (p *Push) Decode() (*Notification, error) {
+	raw, err := base64.StdEncoding.DecodeString(p.Message.Data)
+	if err != nil {
+		// Pub/Sub pads its base64, but tolerate senders that do not
+		if raw, err = base64.RawStdEncoding.DecodeString(p.Message.Data); err != nil {
+			return nil, ErrInvalidPush
+		}
+	}
+	var n Notification
+	if err := json.Unmarshal(raw, &n); err != nil || n.EmailAddress == "" {
+		return nil, ErrInvalidPush
+	}
+	return &n, nil
+}
+
+// HandlePush starts a sync of the mailbox a notification is about and returns without waiting
+// for it. Pushes for a mailbox that is already syncing run one more sync once it finishes, so
+// no change is missed and bursts of pushes do not pile up syncs.
+func (s *Service) HandlePush(ctx context.Context, n *Notification) error {
+	w, err := s.watches.FindWatchByAccount(ctx, n.EmailAddress)
+	if errors.Is(err, data.ErrNotFound) {
+		return ErrUnknownAccount
+	}
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if _, running := s.syncing[w.UserID]; running {
+		s.syncing[w.UserID] = true
+		s.mu.Unlock()
+		return nil
+	}
+	s.syncing[w.UserID] = false
+	s.mu.Unlock()
+
+	ctx = context.WithoutCancel(ctx)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			s.sync(ctx, w)
+			s.mu.Lock()
+			if s.syncing[w.UserID] {
+				s.syncing[w.UserID] = false
+				s.mu.Unlock()
+				continue
+			}
+			delete(s.syncing, w.UserID)
+			s.mu.Unlock()
+			return
+		}
+	}()
+	return nil
+}
+
+// sync runs one push-triggered sync of the watched account
+func (s *Service) sync(ctx context.Context, w *models.GmailWatch) {
+	if s.Maintenance.Active() {
+		return
+	}
+	token, err := s.tokens.GetUserToken(ctx, w.UserID, data.ProviderGmail, w.AccountID)
+	if err == nil {
+		err = s.syncer.SyncUser(s.DebugLog.Context(ctx, w.UserID), w.UserID, token)
+	}
+	s.Health.Record(JobTypePush, err)
+	if err != nil {
+		log.Warn().Err(err).Str("userID", w.UserID).Msg("gmailpush: sync failed")
+		if !errors.Is(err, maintenance.ErrActive) && !errors.Is(err, provider.ErrCircuitOpen) {
+			telemetryerrors.Capture(ctx, s.Errors, err, w.UserID, map[string]string{"job_type": JobTypePush})
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package gmailpush
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"golang.org/x/oauth2"
+)
+
+type fakeWatches struct {
+	due   []models.GmailWatch
+	saved map[string]models.GmailWatch
+}
+
+func (f *fakeWatches) SaveWatch(ctx context.Context, w *models.GmailWatch) error {
+	if f.saved == nil {
+		f.saved = map[string]models.GmailWatch{}
+	}
+	f.saved[w.UserID] = *w
+	return nil
+}
+
+func (f *fakeWatches) FindWatchByAccount(ctx context.Context, accountID string) (*models.GmailWatch, error) {
+	for _, w := range f.saved {
+		if strings.EqualFold(w.AccountID, accountID) {
+			return &w, nil
+		}
+	}
+	return nil, data.ErrNotFound
+}
+
+func (f *fakeWatches) ListDueWatches(ctx context.Context, now time.Time, limit int) ([]models.GmailWatch, error) {
+	due := f.due
+	f.due = nil
+	return due, nil
+}
+
+func (f *fakeWatches) PendingWatches(ctx context.Context, now time.Time) (int, *time.Time, error) {
+	return len(f.due), nil, nil
+}
+
+type fakeTokens struct{}
+
+func (fakeTokens) SaveUserToken(ctx context.Context, userID, provider, accountID string, token *oauth2.Token) error {
+	return nil
+}
+
+func (fakeTokens) GetUserToken(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
+	if userID == "revoked" {
+		return nil, data.ErrNotFound
+	}
+	return &oauth2.Token{AccessToken: userID}, nil
+}
+
+type fakeWatcher struct {
+	expires time.Time
+}
+
+func (f fakeWatcher) Watch(ctx context.Context, token *oauth2.Token, topic string) (uint64, time.Time, error) {
+	if topic != "projects/p/topics/gmail" {
+		return 0, time.Time{}, errors.New("wrong topic")
+	}
+	return 77, f.expires, nil
+}
+
+// fakeSyncer blocks each sync until release is signalled, counting syncs per user
+type fakeSyncer struct {
+	mu      sync.Mutex
+	syncs   map[string]int
+	started chan struct{}
+	release chan struct{}
+}
+
+func (f *fakeSyncer) SyncUser(ctx context.Context, userID string, token *oauth2.Token) error {
+	f.started <- struct{}{}
+	<-f.release
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.syncs[userID]++
+	return nil
+}
+
+func TestRenewDue(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	expires := now.Add(7 * 24 * time.Hour)
+	watches := &fakeWatches{due: []models.GmailWatch{
+		{UserID: "u1", AccountID: "u1@gmail.com", Error: "earlier failure"},
+		{UserID: "revoked", AccountID: "revoked@gmail.com"},
+	}}
+	s := NewService(watches, fakeTokens{}, fakeWatcher{expires: expires}, nil, "projects/p/topics/gmail")
+	s.now = func() time.Time { return now }
+
+	if n := s.RenewDue(context.Background()); n != 2 {
+		t.Fatalf("expected 2 renewals attempted, got %d", n)
+	}
+	ok := watches.saved["u1"]
+	if ok.HistoryID != 77 || ok.ExpiresAt == nil || !ok.ExpiresAt.Equal(expires) || !ok.RenewAfter.Equal(expires.Add(-renewEarly)) || ok.Error != "" {
+		t.Errorf("unexpected registered watch %+v", ok)
+	}
+	failed := watches.saved["revoked"]
+	if failed.Error == "" || !failed.RenewAfter.Equal(now.Add(retryAfter)) || failed.ExpiresAt != nil {
+		t.Errorf("unexpected failed watch %+v", failed)
+	}
+}
+
+func TestHandlePush(t *testing.T) {
+	watches := &fakeWatches{saved: map[string]models.GmailWatch{"u1": {UserID: "u1", AccountID: "u1@gmail.com"}}}
+	syncer := &fakeSyncer{syncs: map[string]int{}, started: make(chan struct{}, 10), release: make(chan struct{})}
+	s := NewService(watches, fakeTokens{}, nil, syncer, "projects/p/topics/gmail")
+	ctx := context.Background()
+
+	var push Push
+	push.Message.Data = base64.StdEncoding.EncodeToString([]byte(`{"emailAddress":"U1@gmail.com","historyId":9}`))
+	n, err := push.Decode()
+	if err != nil || n.EmailAddress != "U1@gmail.com" || n.HistoryID != 9 {
+		t.Fatalf("Decode = %+v, %v", n, err)
+	}
+	push.Message.Data = "not base64!"
+	if _, err := push.Decode(); !errors.Is(err, ErrInvalidPush) {
+		t.Errorf("expected ErrInvalidPush, got %v", err)
+	}
+	if err := s.HandlePush(ctx, &Notification{EmailAddress: "other@gmail.com"}); !errors.Is(err, ErrUnknownAccount) {
+		t.Errorf("expected ErrUnknownAccount, got %v", err)
+	}
+
+	// Pushes during a running sync collapse into one follow-up sync
+	if err := s.HandlePush(ctx, n); err != nil {
+		t.Fatalf("HandlePush failed: %v", err)
+	}
+	<-syncer.started
+	for i := 0; i < 3; i++ {
+		if err := s.HandlePush(ctx, n); err != nil {
+			t.Fatalf("HandlePush failed: %v", err)
+		}
+	}
+	syncer.release <- struct{}{}
+	<-syncer.started
+	syncer.release <- struct{}{}
+	s.wg.Wait()
+	if syncer.syncs["u1"] != 2 {
+		t.Errorf("expected the first sync and one follow-up, got %d", syncer.syncs["u1"])
+	}
+}
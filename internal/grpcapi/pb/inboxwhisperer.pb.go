@@ -0,0 +1,841 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: inboxwhisperer/v1/inboxwhisperer.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type EmailAddress struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Address       string                 `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmailAddress) Reset() {
+	*x = EmailAddress{}
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmailAddress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmailAddress) ProtoMessage() {}
+
+func (x *EmailAddress) ProtoReflect() protoreflect.Message {
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmailAddress.ProtoReflect.Descriptor instead.
+func (*EmailAddress) Descriptor() ([]byte, []int) {
+	return file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *EmailAddress) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *EmailAddress) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type EmailMessage struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id is the provider's message ID
+	Id       string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ThreadId string `protobuf:"bytes,2,opt,name=thread_id,json=threadId,proto3" json:"thread_id,omitempty"`
+	Subject  string `protobuf:"bytes,3,opt,name=subject,proto3" json:"subject,omitempty"`
+	// sender is the raw From header
+	Sender        string          `protobuf:"bytes,4,opt,name=sender,proto3" json:"sender,omitempty"`
+	SenderAddress string          `protobuf:"bytes,5,opt,name=sender_address,json=senderAddress,proto3" json:"sender_address,omitempty"`
+	SenderName    string          `protobuf:"bytes,6,opt,name=sender_name,json=senderName,proto3" json:"sender_name,omitempty"`
+	Recipient     string          `protobuf:"bytes,7,opt,name=recipient,proto3" json:"recipient,omitempty"`
+	Cc            []*EmailAddress `protobuf:"bytes,8,rep,name=cc,proto3" json:"cc,omitempty"`
+	ReplyTo       []*EmailAddress `protobuf:"bytes,9,rep,name=reply_to,json=replyTo,proto3" json:"reply_to,omitempty"`
+	Snippet       string          `protobuf:"bytes,10,opt,name=snippet,proto3" json:"snippet,omitempty"`
+	// body and html_body are only set by GetMessage
+	Body     string `protobuf:"bytes,11,opt,name=body,proto3" json:"body,omitempty"`
+	HtmlBody string `protobuf:"bytes,12,opt,name=html_body,json=htmlBody,proto3" json:"html_body,omitempty"`
+	// internal_date is the provider's receive time in milliseconds since the epoch
+	InternalDate   int64 `protobuf:"varint,13,opt,name=internal_date,json=internalDate,proto3" json:"internal_date,omitempty"`
+	SizeEstimate   int64 `protobuf:"varint,14,opt,name=size_estimate,json=sizeEstimate,proto3" json:"size_estimate,omitempty"`
+	HasAttachments bool  `protobuf:"varint,15,opt,name=has_attachments,json=hasAttachments,proto3" json:"has_attachments,omitempty"`
+	IsRead         bool  `protobuf:"varint,16,opt,name=is_read,json=isRead,proto3" json:"is_read,omitempty"`
+	// category is empty until the message is categorized
+	Category      string `protobuf:"bytes,17,opt,name=category,proto3" json:"category,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EmailMessage) Reset() {
+	*x = EmailMessage{}
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EmailMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EmailMessage) ProtoMessage() {}
+
+func (x *EmailMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EmailMessage.ProtoReflect.Descriptor instead.
+func (*EmailMessage) Descriptor() ([]byte, []int) {
+	return file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *EmailMessage) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *EmailMessage) GetThreadId() string {
+	if x != nil {
+		return x.ThreadId
+	}
+	return ""
+}
+
+func (x *EmailMessage) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *EmailMessage) GetSender() string {
+	if x != nil {
+		return x.Sender
+	}
+	return ""
+}
+
+func (x *EmailMessage) GetSenderAddress() string {
+	if x != nil {
+		return x.SenderAddress
+	}
+	return ""
+}
+
+func (x *EmailMessage) GetSenderName() string {
+	if x != nil {
+		return x.SenderName
+	}
+	return ""
+}
+
+func (x *EmailMessage) GetRecipient() string {
+	if x != nil {
+		return x.Recipient
+	}
+	return ""
+}
+
+func (x *EmailMessage) GetCc() []*EmailAddress {
+	if x != nil {
+		return x.Cc
+	}
+	return nil
+}
+
+func (x *EmailMessage) GetReplyTo() []*EmailAddress {
+	if x != nil {
+		return x.ReplyTo
+	}
+	return nil
+}
+
+func (x *EmailMessage) GetSnippet() string {
+	if x != nil {
+		return x.Snippet
+	}
+	return ""
+}
+
+func (x *EmailMessage) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *EmailMessage) GetHtmlBody() string {
+	if x != nil {
+		return x.HtmlBody
+	}
+	return ""
+}
+
+func (x *EmailMessage) GetInternalDate() int64 {
+	if x != nil {
+		return x.InternalDate
+	}
+	return 0
+}
+
+func (x *EmailMessage) GetSizeEstimate() int64 {
+	if x != nil {
+		return x.SizeEstimate
+	}
+	return 0
+}
+
+func (x *EmailMessage) GetHasAttachments() bool {
+	if x != nil {
+		return x.HasAttachments
+	}
+	return false
+}
+
+func (x *EmailMessage) GetIsRead() bool {
+	if x != nil {
+		return x.IsRead
+	}
+	return false
+}
+
+func (x *EmailMessage) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+type ListMessagesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// account_id selects a linked mailbox; empty means the default one
+	AccountId string `protobuf:"bytes,1,opt,name=account_id,json=accountId,proto3" json:"account_id,omitempty"`
+	// page_token continues a listing served from the provider
+	PageToken string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	// after_id and after_internal_date continue a listing served from the cache
+	AfterId           string `protobuf:"bytes,3,opt,name=after_id,json=afterId,proto3" json:"after_id,omitempty"`
+	AfterInternalDate int64  `protobuf:"varint,4,opt,name=after_internal_date,json=afterInternalDate,proto3" json:"after_internal_date,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *ListMessagesRequest) Reset() {
+	*x = ListMessagesRequest{}
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMessagesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMessagesRequest) ProtoMessage() {}
+
+func (x *ListMessagesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMessagesRequest.ProtoReflect.Descriptor instead.
+func (*ListMessagesRequest) Descriptor() ([]byte, []int) {
+	return file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListMessagesRequest) GetAccountId() string {
+	if x != nil {
+		return x.AccountId
+	}
+	return ""
+}
+
+func (x *ListMessagesRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListMessagesRequest) GetAfterId() string {
+	if x != nil {
+		return x.AfterId
+	}
+	return ""
+}
+
+func (x *ListMessagesRequest) GetAfterInternalDate() int64 {
+	if x != nil {
+		return x.AfterInternalDate
+	}
+	return 0
+}
+
+type ListMessagesResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Messages []*EmailMessage        `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	// next_page_token is set when the provider has more messages
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMessagesResponse) Reset() {
+	*x = ListMessagesResponse{}
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMessagesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMessagesResponse) ProtoMessage() {}
+
+func (x *ListMessagesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMessagesResponse.ProtoReflect.Descriptor instead.
+func (*ListMessagesResponse) Descriptor() ([]byte, []int) {
+	return file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListMessagesResponse) GetMessages() []*EmailMessage {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+func (x *ListMessagesResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type GetMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	AccountId     string                 `protobuf:"bytes,2,opt,name=account_id,json=accountId,proto3" json:"account_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMessageRequest) Reset() {
+	*x = GetMessageRequest{}
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMessageRequest) ProtoMessage() {}
+
+func (x *GetMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMessageRequest.ProtoReflect.Descriptor instead.
+func (*GetMessageRequest) Descriptor() ([]byte, []int) {
+	return file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetMessageRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GetMessageRequest) GetAccountId() string {
+	if x != nil {
+		return x.AccountId
+	}
+	return ""
+}
+
+type GetSyncStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSyncStatusRequest) Reset() {
+	*x = GetSyncStatusRequest{}
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSyncStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSyncStatusRequest) ProtoMessage() {}
+
+func (x *GetSyncStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSyncStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetSyncStatusRequest) Descriptor() ([]byte, []int) {
+	return file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescGZIP(), []int{5}
+}
+
+type FailedSyncItem struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	EmailMessageId string                 `protobuf:"bytes,1,opt,name=email_message_id,json=emailMessageId,proto3" json:"email_message_id,omitempty"`
+	Stage          string                 `protobuf:"bytes,2,opt,name=stage,proto3" json:"stage,omitempty"`
+	LastError      string                 `protobuf:"bytes,3,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	Attempts       int32                  `protobuf:"varint,4,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	FirstFailedAt  *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=first_failed_at,json=firstFailedAt,proto3" json:"first_failed_at,omitempty"`
+	LastFailedAt   *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=last_failed_at,json=lastFailedAt,proto3" json:"last_failed_at,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *FailedSyncItem) Reset() {
+	*x = FailedSyncItem{}
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FailedSyncItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FailedSyncItem) ProtoMessage() {}
+
+func (x *FailedSyncItem) ProtoReflect() protoreflect.Message {
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FailedSyncItem.ProtoReflect.Descriptor instead.
+func (*FailedSyncItem) Descriptor() ([]byte, []int) {
+	return file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *FailedSyncItem) GetEmailMessageId() string {
+	if x != nil {
+		return x.EmailMessageId
+	}
+	return ""
+}
+
+func (x *FailedSyncItem) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *FailedSyncItem) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *FailedSyncItem) GetAttempts() int32 {
+	if x != nil {
+		return x.Attempts
+	}
+	return 0
+}
+
+func (x *FailedSyncItem) GetFirstFailedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FirstFailedAt
+	}
+	return nil
+}
+
+func (x *FailedSyncItem) GetLastFailedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastFailedAt
+	}
+	return nil
+}
+
+type SyncStatus struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// sync_complete is true once per finished background sync (cleared on read)
+	SyncComplete bool `protobuf:"varint,1,opt,name=sync_complete,json=syncComplete,proto3" json:"sync_complete,omitempty"`
+	// failed_items lists messages that exhausted their retry attempts
+	FailedItems   []*FailedSyncItem `protobuf:"bytes,2,rep,name=failed_items,json=failedItems,proto3" json:"failed_items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncStatus) Reset() {
+	*x = SyncStatus{}
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncStatus) ProtoMessage() {}
+
+func (x *SyncStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncStatus.ProtoReflect.Descriptor instead.
+func (*SyncStatus) Descriptor() ([]byte, []int) {
+	return file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SyncStatus) GetSyncComplete() bool {
+	if x != nil {
+		return x.SyncComplete
+	}
+	return false
+}
+
+func (x *SyncStatus) GetFailedItems() []*FailedSyncItem {
+	if x != nil {
+		return x.FailedItems
+	}
+	return nil
+}
+
+type GetMeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMeRequest) Reset() {
+	*x = GetMeRequest{}
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMeRequest) ProtoMessage() {}
+
+func (x *GetMeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMeRequest.ProtoReflect.Descriptor instead.
+func (*GetMeRequest) Descriptor() ([]byte, []int) {
+	return file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescGZIP(), []int{8}
+}
+
+type User struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Email         string                 `protobuf:"bytes,2,opt,name=email,proto3" json:"email,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *User) Reset() {
+	*x = User{}
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *User) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*User) ProtoMessage() {}
+
+func (x *User) ProtoReflect() protoreflect.Message {
+	mi := &file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use User.ProtoReflect.Descriptor instead.
+func (*User) Descriptor() ([]byte, []int) {
+	return file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *User) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *User) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *User) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+var File_inboxwhisperer_v1_inboxwhisperer_proto protoreflect.FileDescriptor
+
+const file_inboxwhisperer_v1_inboxwhisperer_proto_rawDesc = "" +
+	"\n" +
+	"&inboxwhisperer/v1/inboxwhisperer.proto\x12\x11inboxwhisperer.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"<\n" +
+	"\fEmailAddress\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\aaddress\x18\x02 \x01(\tR\aaddress\"\xb3\x04\n" +
+	"\fEmailMessage\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1b\n" +
+	"\tthread_id\x18\x02 \x01(\tR\bthreadId\x12\x18\n" +
+	"\asubject\x18\x03 \x01(\tR\asubject\x12\x16\n" +
+	"\x06sender\x18\x04 \x01(\tR\x06sender\x12%\n" +
+	"\x0esender_address\x18\x05 \x01(\tR\rsenderAddress\x12\x1f\n" +
+	"\vsender_name\x18\x06 \x01(\tR\n" +
+	"senderName\x12\x1c\n" +
+	"\trecipient\x18\a \x01(\tR\trecipient\x12/\n" +
+	"\x02cc\x18\b \x03(\v2\x1f.inboxwhisperer.v1.EmailAddressR\x02cc\x12:\n" +
+	"\breply_to\x18\t \x03(\v2\x1f.inboxwhisperer.v1.EmailAddressR\areplyTo\x12\x18\n" +
+	"\asnippet\x18\n" +
+	" \x01(\tR\asnippet\x12\x12\n" +
+	"\x04body\x18\v \x01(\tR\x04body\x12\x1b\n" +
+	"\thtml_body\x18\f \x01(\tR\bhtmlBody\x12#\n" +
+	"\rinternal_date\x18\r \x01(\x03R\finternalDate\x12#\n" +
+	"\rsize_estimate\x18\x0e \x01(\x03R\fsizeEstimate\x12'\n" +
+	"\x0fhas_attachments\x18\x0f \x01(\bR\x0ehasAttachments\x12\x17\n" +
+	"\ais_read\x18\x10 \x01(\bR\x06isRead\x12\x1a\n" +
+	"\bcategory\x18\x11 \x01(\tR\bcategory\"\x9e\x01\n" +
+	"\x13ListMessagesRequest\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x01 \x01(\tR\taccountId\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x12\x19\n" +
+	"\bafter_id\x18\x03 \x01(\tR\aafterId\x12.\n" +
+	"\x13after_internal_date\x18\x04 \x01(\x03R\x11afterInternalDate\"{\n" +
+	"\x14ListMessagesResponse\x12;\n" +
+	"\bmessages\x18\x01 \x03(\v2\x1f.inboxwhisperer.v1.EmailMessageR\bmessages\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"B\n" +
+	"\x11GetMessageRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1d\n" +
+	"\n" +
+	"account_id\x18\x02 \x01(\tR\taccountId\"\x16\n" +
+	"\x14GetSyncStatusRequest\"\x91\x02\n" +
+	"\x0eFailedSyncItem\x12(\n" +
+	"\x10email_message_id\x18\x01 \x01(\tR\x0eemailMessageId\x12\x14\n" +
+	"\x05stage\x18\x02 \x01(\tR\x05stage\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\x03 \x01(\tR\tlastError\x12\x1a\n" +
+	"\battempts\x18\x04 \x01(\x05R\battempts\x12B\n" +
+	"\x0ffirst_failed_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\rfirstFailedAt\x12@\n" +
+	"\x0elast_failed_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\flastFailedAt\"w\n" +
+	"\n" +
+	"SyncStatus\x12#\n" +
+	"\rsync_complete\x18\x01 \x01(\bR\fsyncComplete\x12D\n" +
+	"\ffailed_items\x18\x02 \x03(\v2!.inboxwhisperer.v1.FailedSyncItemR\vfailedItems\"\x0e\n" +
+	"\fGetMeRequest\"g\n" +
+	"\x04User\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05email\x18\x02 \x01(\tR\x05email\x129\n" +
+	"\n" +
+	"created_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt2\xc4\x01\n" +
+	"\fEmailService\x12_\n" +
+	"\fListMessages\x12&.inboxwhisperer.v1.ListMessagesRequest\x1a'.inboxwhisperer.v1.ListMessagesResponse\x12S\n" +
+	"\n" +
+	"GetMessage\x12$.inboxwhisperer.v1.GetMessageRequest\x1a\x1f.inboxwhisperer.v1.EmailMessage2f\n" +
+	"\vSyncService\x12W\n" +
+	"\rGetSyncStatus\x12'.inboxwhisperer.v1.GetSyncStatusRequest\x1a\x1d.inboxwhisperer.v1.SyncStatus2P\n" +
+	"\vUserService\x12A\n" +
+	"\x05GetMe\x12\x1f.inboxwhisperer.v1.GetMeRequest\x1a\x17.inboxwhisperer.v1.UserB9Z7github.com/desponda/inbox-whisperer/internal/grpcapi/pbb\x06proto3"
+
+var (
+	file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescOnce sync.Once
+	file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescData []byte
+)
+
+func file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescGZIP() []byte {
+	file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescOnce.Do(func() {
+		file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_inboxwhisperer_v1_inboxwhisperer_proto_rawDesc), len(file_inboxwhisperer_v1_inboxwhisperer_proto_rawDesc)))
+	})
+	return file_inboxwhisperer_v1_inboxwhisperer_proto_rawDescData
+}
+
+var file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_inboxwhisperer_v1_inboxwhisperer_proto_goTypes = []any{
+	(*EmailAddress)(nil),          // 0: inboxwhisperer.v1.EmailAddress
+	(*EmailMessage)(nil),          // 1: inboxwhisperer.v1.EmailMessage
+	(*ListMessagesRequest)(nil),   // 2: inboxwhisperer.v1.ListMessagesRequest
+	(*ListMessagesResponse)(nil),  // 3: inboxwhisperer.v1.ListMessagesResponse
+	(*GetMessageRequest)(nil),     // 4: inboxwhisperer.v1.GetMessageRequest
+	(*GetSyncStatusRequest)(nil),  // 5: inboxwhisperer.v1.GetSyncStatusRequest
+	(*FailedSyncItem)(nil),        // 6: inboxwhisperer.v1.FailedSyncItem
+	(*SyncStatus)(nil),            // 7: inboxwhisperer.v1.SyncStatus
+	(*GetMeRequest)(nil),          // 8: inboxwhisperer.v1.GetMeRequest
+	(*User)(nil),                  // 9: inboxwhisperer.v1.User
+	(*timestamppb.Timestamp)(nil), // 10: google.protobuf.Timestamp
+}
+var file_inboxwhisperer_v1_inboxwhisperer_proto_depIdxs = []int32{
+	0,  // 0: inboxwhisperer.v1.EmailMessage.cc:type_name -> inboxwhisperer.v1.EmailAddress
+	0,  // 1: inboxwhisperer.v1.EmailMessage.reply_to:type_name -> inboxwhisperer.v1.EmailAddress
+	1,  // 2: inboxwhisperer.v1.ListMessagesResponse.messages:type_name -> inboxwhisperer.v1.EmailMessage
+	10, // 3: inboxwhisperer.v1.FailedSyncItem.first_failed_at:type_name -> google.protobuf.Timestamp
+	10, // 4: inboxwhisperer.v1.FailedSyncItem.last_failed_at:type_name -> google.protobuf.Timestamp
+	6,  // 5: inboxwhisperer.v1.SyncStatus.failed_items:type_name -> inboxwhisperer.v1.FailedSyncItem
+	10, // 6: inboxwhisperer.v1.User.created_at:type_name -> google.protobuf.Timestamp
+	2,  // 7: inboxwhisperer.v1.EmailService.ListMessages:input_type -> inboxwhisperer.v1.ListMessagesRequest
+	4,  // 8: inboxwhisperer.v1.EmailService.GetMessage:input_type -> inboxwhisperer.v1.GetMessageRequest
+	5,  // 9: inboxwhisperer.v1.SyncService.GetSyncStatus:input_type -> inboxwhisperer.v1.GetSyncStatusRequest
+	8,  // 10: inboxwhisperer.v1.UserService.GetMe:input_type -> inboxwhisperer.v1.GetMeRequest
+	3,  // 11: inboxwhisperer.v1.EmailService.ListMessages:output_type -> inboxwhisperer.v1.ListMessagesResponse
+	1,  // 12: inboxwhisperer.v1.EmailService.GetMessage:output_type -> inboxwhisperer.v1.EmailMessage
+	7,  // 13: inboxwhisperer.v1.SyncService.GetSyncStatus:output_type -> inboxwhisperer.v1.SyncStatus
+	9,  // 14: inboxwhisperer.v1.UserService.GetMe:output_type -> inboxwhisperer.v1.User
+	11, // [11:15] is the sub-list for method output_type
+	7,  // [7:11] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_inboxwhisperer_v1_inboxwhisperer_proto_init() }
+func file_inboxwhisperer_v1_inboxwhisperer_proto_init() {
+	if File_inboxwhisperer_v1_inboxwhisperer_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_inboxwhisperer_v1_inboxwhisperer_proto_rawDesc), len(file_inboxwhisperer_v1_inboxwhisperer_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   3,
+		},
+		GoTypes:           file_inboxwhisperer_v1_inboxwhisperer_proto_goTypes,
+		DependencyIndexes: file_inboxwhisperer_v1_inboxwhisperer_proto_depIdxs,
+		MessageInfos:      file_inboxwhisperer_v1_inboxwhisperer_proto_msgTypes,
+	}.Build()
+	File_inboxwhisperer_v1_inboxwhisperer_proto = out.File
+	file_inboxwhisperer_v1_inboxwhisperer_proto_goTypes = nil
+	file_inboxwhisperer_v1_inboxwhisperer_proto_depIdxs = nil
+}
@@ -0,0 +1,82 @@
+// Package grpcapi serves the gRPC API defined in api/proto for internal tooling and native
+// clients. It runs on its own port next to the REST API and is backed by the same services;
+// callers authenticate with the API keys the REST API accepts.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/apikeys"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/grpcapi/pb"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// APIKeyAuthenticator resolves an API key to its owner (see apikeys.Service)
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, raw string) (*models.APIKey, error)
+}
+
+// UserGetter loads a user (see service.UserService)
+type UserGetter interface {
+	GetUser(ctx context.Context, id string) (*models.User, error)
+}
+
+// Deps are what the gRPC services are served from
+type Deps struct {
+	Keys        APIKeyAuthenticator
+	Emails      service.EmailService
+	Tokens      data.UserTokenRepository
+	FailedItems data.FailedSyncItemRepository
+	Users       UserGetter
+	// Reflection registers the reflection service so tools like grpcurl can discover the API
+	// without the .proto files; meant for development
+	Reflection bool
+}
+
+// NewServer returns a gRPC server with the email, sync and user services registered
+func NewServer(d Deps) *grpc.Server {
+	s := grpc.NewServer(grpc.UnaryInterceptor(authInterceptor(d.Keys)))
+	pb.RegisterEmailServiceServer(s, &emailServer{tokens: d.Tokens, emails: d.Emails})
+	pb.RegisterSyncServiceServer(s, &syncServer{failedItems: d.FailedItems})
+	pb.RegisterUserServiceServer(s, &userServer{users: d.Users})
+	if d.Reflection {
+		reflection.Register(s)
+	}
+	return s
+}
+
+// authInterceptor authenticates each call with the API key in its "authorization: Bearer
+// iw_..." metadata and runs it as the key's owner, like api.APIKeyMiddleware
+func authInterceptor(keys APIKeyAuthenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var raw string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get("authorization"); len(v) > 0 {
+				raw, _ = strings.CutPrefix(v[0], "Bearer ")
+			}
+		}
+		if raw == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing API key")
+		}
+		key, err := keys.Authenticate(ctx, raw)
+		if errors.Is(err, apikeys.ErrInvalidKey) {
+			return nil, status.Error(codes.Unauthenticated, "invalid API key")
+		}
+		if err != nil {
+			log.Error().Err(err).Str("method", info.FullMethod).Msg("failed to authenticate API key")
+			return nil, status.Error(codes.Internal, "failed to authenticate API key")
+		}
+		return handler(ctxkeys.WithUserID(ctx, key.UserID), req)
+	}
+}
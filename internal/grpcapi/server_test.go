@@ -0,0 +1,136 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/apikeys"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/grpcapi/pb"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeKeys struct{}
+
+func (fakeKeys) Authenticate(ctx context.Context, raw string) (*models.APIKey, error) {
+	if raw != "iw_good" {
+		return nil, apikeys.ErrInvalidKey
+	}
+	return &models.APIKey{UserID: "u1"}, nil
+}
+
+type fakeTokens struct{}
+
+func (fakeTokens) SaveUserToken(ctx context.Context, userID, provider, accountID string, tok *oauth2.Token) error {
+	return nil
+}
+func (fakeTokens) GetUserToken(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
+	if accountID != "" && accountID != "me@example.com" {
+		return nil, data.ErrNotFound
+	}
+	return &oauth2.Token{AccessToken: "tok-" + userID}, nil
+}
+
+type fakeEmails struct{ gotPageToken string }
+
+func (f *fakeEmails) FetchMessages(ctx context.Context, tok *oauth2.Token) ([]models.EmailMessage, error) {
+	f.gotPageToken = ctxkeys.PageToken(ctx)
+	ctxkeys.SetNextPageToken(ctx, "next")
+	return []models.EmailMessage{{EmailMessageID: "m1", Subject: "Hello", Cc: []models.EmailAddress{{Address: "cc@example.com"}}}}, nil
+}
+func (f *fakeEmails) FetchMessageContent(ctx context.Context, tok *oauth2.Token, id string) (*models.EmailMessage, error) {
+	if id != "m1" {
+		return nil, provider.ErrNotFound
+	}
+	return &models.EmailMessage{EmailMessageID: "m1", Body: "body of " + tok.AccessToken}, nil
+}
+
+type fakeFailedItems struct{ data.FailedSyncItemRepository }
+
+func (fakeFailedItems) ListExhausted(ctx context.Context, userID string, maxAttempts int) ([]*models.FailedSyncItem, error) {
+	return []*models.FailedSyncItem{{EmailMessageID: "m2", Stage: "fetch", Attempts: maxAttempts, LastFailedAt: time.Unix(1700000000, 0)}}, nil
+}
+
+type fakeUsers struct{}
+
+func (fakeUsers) GetUser(ctx context.Context, id string) (*models.User, error) {
+	return &models.User{ID: id, Email: id + "@example.com"}, nil
+}
+
+func dial(t *testing.T, emails *fakeEmails) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(Deps{Keys: fakeKeys{}, Emails: emails, Tokens: fakeTokens{}, FailedItems: fakeFailedItems{}, Users: fakeUsers{}})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServer_RequiresAPIKey(t *testing.T) {
+	users := pb.NewUserServiceClient(dial(t, &fakeEmails{}))
+	for key, want := range map[string]codes.Code{"": codes.Unauthenticated, "iw_bad": codes.Unauthenticated, "iw_good": codes.OK} {
+		ctx := context.Background()
+		if key != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+key)
+		}
+		me, err := users.GetMe(ctx, &pb.GetMeRequest{})
+		if status.Code(err) != want {
+			t.Errorf("key %q: got %v, want %v", key, err, want)
+		}
+		if err == nil && (me.GetId() != "u1" || me.GetEmail() != "u1@example.com") {
+			t.Errorf("unexpected user %v", me)
+		}
+	}
+}
+
+func TestServer_EmailAndSync(t *testing.T) {
+	emails := &fakeEmails{}
+	conn := dial(t, emails)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer iw_good")
+	client := pb.NewEmailServiceClient(conn)
+
+	list, err := client.ListMessages(ctx, &pb.ListMessagesRequest{PageToken: "p2"})
+	if err != nil {
+		t.Fatalf("ListMessages failed: %v", err)
+	}
+	if len(list.GetMessages()) != 1 || list.GetMessages()[0].GetSubject() != "Hello" || list.GetMessages()[0].GetCc()[0].GetAddress() != "cc@example.com" {
+		t.Errorf("unexpected messages %v", list.GetMessages())
+	}
+	if list.GetNextPageToken() != "next" || emails.gotPageToken != "p2" {
+		t.Errorf("expected page tokens to pass through, got next %q and request %q", list.GetNextPageToken(), emails.gotPageToken)
+	}
+	if _, err := client.ListMessages(ctx, &pb.ListMessagesRequest{AccountId: "other@example.com"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected an unlinked account to be unauthenticated, got %v", err)
+	}
+
+	msg, err := client.GetMessage(ctx, &pb.GetMessageRequest{Id: "m1"})
+	if err != nil || msg.GetBody() != "body of tok-u1" {
+		t.Errorf("unexpected message %v (err %v)", msg, err)
+	}
+	if _, err := client.GetMessage(ctx, &pb.GetMessageRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+
+	sync, err := pb.NewSyncServiceClient(conn).GetSyncStatus(ctx, &pb.GetSyncStatusRequest{})
+	if err != nil || len(sync.GetFailedItems()) != 1 || sync.GetFailedItems()[0].GetEmailMessageId() != "m2" || sync.GetFailedItems()[0].GetLastFailedAt().GetSeconds() != 1700000000 {
+		t.Errorf("unexpected sync status %v (err %v)", sync, err)
+	}
+}
@@ -0,0 +1,165 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/grpcapi/pb"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/notify"
+	"github.com/desponda/inbox-whisperer/internal/service"
+	gmail "github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type emailServer struct {
+	pb.UnimplementedEmailServiceServer
+	tokens data.UserTokenRepository
+	emails service.EmailService
+}
+
+// withToken loads the token of the requested account as api.TokenMiddleware does
+func (s *emailServer) withToken(ctx context.Context, accountID string) (context.Context, error) {
+	tok, _ := s.tokens.GetUserToken(ctx, ctxkeys.UserID(ctx), data.ProviderGmail, accountID)
+	if tok == nil {
+		return nil, status.Error(codes.Unauthenticated, "no token found for account")
+	}
+	return ctxkeys.WithAccountID(ctxkeys.WithToken(ctx, tok), accountID), nil
+}
+
+func (s *emailServer) ListMessages(ctx context.Context, req *pb.ListMessagesRequest) (*pb.ListMessagesResponse, error) {
+	ctx, err := s.withToken(ctx, req.GetAccountId())
+	if err != nil {
+		return nil, err
+	}
+	ctx = ctxkeys.WithCursor(ctx, ctxkeys.Cursor{AfterID: req.GetAfterId(), AfterInternalDate: req.GetAfterInternalDate()})
+	if req.GetPageToken() != "" {
+		ctx = ctxkeys.WithPageToken(ctx, req.GetPageToken())
+	}
+	pageInfo := &ctxkeys.PageInfo{}
+	ctx = ctxkeys.WithPageInfo(ctx, pageInfo)
+	msgs, err := s.emails.FetchMessages(ctx, ctxkeys.Token(ctx))
+	if err != nil {
+		return nil, providerStatus(err)
+	}
+	resp := &pb.ListMessagesResponse{NextPageToken: pageInfo.NextPageToken}
+	for i := range msgs {
+		resp.Messages = append(resp.Messages, toEmailMessage(&msgs[i]))
+	}
+	return resp, nil
+}
+
+func (s *emailServer) GetMessage(ctx context.Context, req *pb.GetMessageRequest) (*pb.EmailMessage, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	ctx, err := s.withToken(ctx, req.GetAccountId())
+	if err != nil {
+		return nil, err
+	}
+	msg, err := s.emails.FetchMessageContent(ctx, ctxkeys.Token(ctx), req.GetId())
+	if err != nil {
+		return nil, providerStatus(err)
+	}
+	return toEmailMessage(msg), nil
+}
+
+type syncServer struct {
+	pb.UnimplementedSyncServiceServer
+	failedItems data.FailedSyncItemRepository
+}
+
+func (s *syncServer) GetSyncStatus(ctx context.Context, req *pb.GetSyncStatusRequest) (*pb.SyncStatus, error) {
+	userID := ctxkeys.UserID(ctx)
+	failed, err := s.failedItems.ListExhausted(ctx, userID, gmail.MaxSyncAttempts)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to load sync failures")
+	}
+	resp := &pb.SyncStatus{SyncComplete: notify.CheckAndClearGmailSyncStatus(userID)}
+	for _, f := range failed {
+		resp.FailedItems = append(resp.FailedItems, &pb.FailedSyncItem{
+			EmailMessageId: f.EmailMessageID,
+			Stage:          f.Stage,
+			LastError:      f.LastError,
+			Attempts:       int32(f.Attempts),
+			FirstFailedAt:  timestamppb.New(f.FirstFailedAt),
+			LastFailedAt:   timestamppb.New(f.LastFailedAt),
+		})
+	}
+	return resp, nil
+}
+
+type userServer struct {
+	pb.UnimplementedUserServiceServer
+	users UserGetter
+}
+
+func (s *userServer) GetMe(ctx context.Context, req *pb.GetMeRequest) (*pb.User, error) {
+	user, err := s.users.GetUser(ctx, ctxkeys.UserID(ctx))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	return &pb.User{Id: user.ID, Email: user.Email, CreatedAt: timestamppb.New(user.CreatedAt)}, nil
+}
+
+func toEmailMessage(m *models.EmailMessage) *pb.EmailMessage {
+	return &pb.EmailMessage{
+		Id:             m.EmailMessageID,
+		ThreadId:       m.ThreadID,
+		Subject:        m.Subject,
+		Sender:         m.Sender,
+		SenderAddress:  m.SenderAddress,
+		SenderName:     m.SenderName,
+		Recipient:      m.Recipient,
+		Cc:             toAddresses(m.Cc),
+		ReplyTo:        toAddresses(m.ReplyTo),
+		Snippet:        m.Snippet,
+		Body:           m.Body,
+		HtmlBody:       m.HTMLBody,
+		InternalDate:   m.InternalDate,
+		SizeEstimate:   m.SizeEstimate,
+		HasAttachments: m.HasAttachments,
+		IsRead:         m.IsRead,
+		Category:       m.Category.String,
+	}
+}
+
+func toAddresses(in []models.EmailAddress) []*pb.EmailAddress {
+	var out []*pb.EmailAddress
+	for _, a := range in {
+		out = append(out, &pb.EmailAddress{Name: a.Name, Address: a.Address})
+	}
+	return out
+}
+
+// providerStatus maps provider errors onto gRPC codes the way api.writeProviderError maps
+// them onto HTTP statuses
+func providerStatus(err error) error {
+	var scopeErr *provider.ScopeError
+	switch {
+	case errors.Is(err, provider.ErrSyncing):
+		return status.Error(codes.Unavailable, "mailbox is still syncing; retry shortly")
+	case errors.As(err, &scopeErr), errors.Is(err, provider.ErrMissingScope):
+		return status.Error(codes.PermissionDenied, "email provider denied access: please grant the requested permissions again")
+	case errors.Is(err, provider.ErrNotFound):
+		return status.Error(codes.NotFound, "not found")
+	case errors.Is(err, provider.ErrAuthExpired):
+		return status.Error(codes.Unauthenticated, "email provider authorization expired: please reconnect your account")
+	case errors.Is(err, provider.ErrRateLimited):
+		return status.Error(codes.ResourceExhausted, "email provider rate limit exceeded")
+	case errors.Is(err, provider.ErrTemporary):
+		return status.Error(codes.Unavailable, "email provider temporarily unavailable")
+	case errors.Is(err, provider.ErrInvalidRequest):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, provider.ErrConflict):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, provider.ErrUnsupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
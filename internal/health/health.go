@@ -0,0 +1,199 @@
+// Package health tracks background workers so operators can see their backlog and failure
+// rates, and so a worker that stops making progress fails readiness instead of silently
+// halting syncs. Workers report a heartbeat whenever they make progress or go idle.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultStallAfter is how long a worker may go without a heartbeat before it counts as stuck
+const DefaultStallAfter = 10 * time.Minute
+
+// QueueFunc reports a worker's pending work: how many items wait and since when the oldest
+// has been waiting (nil if unknown or none)
+type QueueFunc func(ctx context.Context) (depth int, oldest *time.Time, err error)
+
+// JobStats counts job outcomes of one type since the process started
+type JobStats struct {
+	Attempted   int64   `json:"attempted"`
+	Failed      int64   `json:"failed"`
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// WorkerStatus is a point-in-time view of one worker
+type WorkerStatus struct {
+	Name       string `json:"name"`
+	PoolSize   int    `json:"pool_size"`
+	QueueDepth int    `json:"queue_depth"`
+	// OldestPendingSeconds is how long the oldest pending item has been waiting
+	OldestPendingSeconds int64                `json:"oldest_pending_seconds"`
+	LastHeartbeat        *time.Time           `json:"last_heartbeat,omitempty"`
+	Stuck                bool                 `json:"stuck"`
+	Jobs                 map[string]*JobStats `json:"jobs"`
+	Error                string               `json:"error,omitempty"`
+}
+
+// ErrDraining is returned by Ready once the instance has started shutting down
+var ErrDraining = errors.New("draining: instance is shutting down")
+
+// Monitor holds the registered workers
+type Monitor struct {
+	mu       sync.Mutex
+	workers  []*Worker
+	draining bool
+	now      func() time.Time
+}
+
+func NewMonitor() *Monitor {
+	return &Monitor{now: time.Now}
+}
+
+// Register adds a worker. It counts as stuck once stallAfter passes without a heartbeat;
+// a worker that has not beaten yet is measured from registration. queue may be nil.
+func (m *Monitor) Register(name string, poolSize int, stallAfter time.Duration, queue QueueFunc) *Worker {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := &Worker{
+		name:       name,
+		poolSize:   poolSize,
+		stallAfter: stallAfter,
+		queue:      queue,
+		registered: m.now(),
+		jobs:       map[string]*JobStats{},
+		now:        m.now,
+	}
+	m.workers = append(m.workers, w)
+	return w
+}
+
+// Status reports every worker, sorted by name
+func (m *Monitor) Status(ctx context.Context) []WorkerStatus {
+	m.mu.Lock()
+	workers := append([]*Worker(nil), m.workers...)
+	m.mu.Unlock()
+	out := make([]WorkerStatus, 0, len(workers))
+	for _, w := range workers {
+		out = append(out, w.status(ctx))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Drain makes Ready fail from now on, so orchestrators stop routing to an instance that is
+// about to shut down
+func (m *Monitor) Drain() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.draining = true
+}
+
+// Ready returns ErrDraining after Drain, otherwise an error naming the stuck workers, if any
+func (m *Monitor) Ready() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.draining {
+		return ErrDraining
+	}
+	var stuck []string
+	for _, w := range m.workers {
+		if w.stuck() {
+			stuck = append(stuck, w.name)
+		}
+	}
+	if len(stuck) > 0 {
+		return fmt.Errorf("workers not making progress: %s", strings.Join(stuck, ", "))
+	}
+	return nil
+}
+
+// Worker receives heartbeats and job outcomes from one background worker. Its methods are
+// no-ops on a nil Worker, so workers can run unmonitored.
+type Worker struct {
+	name       string
+	poolSize   int
+	stallAfter time.Duration
+	queue      QueueFunc
+	registered time.Time
+
+	mu       sync.Mutex
+	lastBeat time.Time
+	jobs     map[string]*JobStats
+	now      func() time.Time
+}
+
+// Beat records that the worker is alive and making progress
+func (w *Worker) Beat() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.lastBeat = w.now()
+	w.mu.Unlock()
+}
+
+// Record counts the outcome of a job of the given type; it also counts as a heartbeat
+func (w *Worker) Record(jobType string, err error) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastBeat = w.now()
+	s, ok := w.jobs[jobType]
+	if !ok {
+		s = &JobStats{}
+		w.jobs[jobType] = s
+	}
+	s.Attempted++
+	if err != nil {
+		s.Failed++
+	}
+	s.FailureRate = float64(s.Failed) / float64(s.Attempted)
+}
+
+func (w *Worker) stuck() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	last := w.lastBeat
+	if last.IsZero() {
+		last = w.registered
+	}
+	return w.stallAfter > 0 && w.now().Sub(last) > w.stallAfter
+}
+
+func (w *Worker) status(ctx context.Context) WorkerStatus {
+	st := WorkerStatus{Name: w.name, PoolSize: w.poolSize, Stuck: w.stuck(), Jobs: map[string]*JobStats{}}
+	w.mu.Lock()
+	if !w.lastBeat.IsZero() {
+		last := w.lastBeat.UTC()
+		st.LastHeartbeat = &last
+	}
+	for jobType, s := range w.jobs {
+		copied := *s
+		st.Jobs[jobType] = &copied
+	}
+	w.mu.Unlock()
+	if w.queue != nil {
+		depth, oldest, err := w.queue(ctx)
+		if err != nil {
+			log.Error().Err(err).Str("worker", w.name).Msg("health: failed to read worker queue")
+			st.Error = "failed to read queue"
+		}
+		st.QueueDepth = depth
+		if oldest != nil {
+			if age := w.now().Sub(*oldest); age > 0 {
+				st.OldestPendingSeconds = int64(age.Seconds())
+			}
+		}
+	}
+	return st
+}
@@ -0,0 +1,65 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+const testJobType = "sync"
+
+func TestMonitor_StatusAndReady(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	m := NewMonitor()
+	m.now = func() time.Time { return now }
+	oldest := now.Add(-90 * time.Second)
+	scheduler := m.Register("sync_scheduler", 1, 10*time.Minute, func(ctx context.Context) (int, *time.Time, error) {
+		return 4, &oldest, nil
+	})
+	jobs := m.Register("recategorize", 1, 10*time.Minute, nil)
+
+	scheduler.Record(testJobType, nil)
+	scheduler.Record(testJobType, errors.New("boom"))
+	scheduler.Record(testJobType, nil)
+	scheduler.Record(testJobType, nil)
+	if err := m.Ready(); err != nil {
+		t.Errorf("expected freshly registered workers to be ready, got %v", err)
+	}
+
+	status := m.Status(context.Background())
+	if len(status) != 2 || status[0].Name != "recategorize" || status[1].Name != "sync_scheduler" {
+		t.Fatalf("expected workers sorted by name, got %+v", status)
+	}
+	s := status[1]
+	if s.QueueDepth != 4 || s.OldestPendingSeconds != 90 || s.PoolSize != 1 {
+		t.Errorf("unexpected queue status %+v", s)
+	}
+	if js := s.Jobs[testJobType]; js == nil || js.Attempted != 4 || js.Failed != 1 || js.FailureRate != 0.25 {
+		t.Errorf("unexpected job stats %+v", js)
+	}
+
+	now = now.Add(11 * time.Minute)
+	jobs.Beat()
+	err := m.Ready()
+	if err == nil || err.Error() != "workers not making progress: sync_scheduler" {
+		t.Errorf("expected only the silent worker to be stuck, got %v", err)
+	}
+}
+
+func TestWorker_NilIsNoop(t *testing.T) {
+	var w *Worker
+	w.Beat()
+	w.Record(testJobType, errors.New("ignored"))
+}
+
+func TestMonitor_Drain(t *testing.T) {
+	m := NewMonitor()
+	if err := m.Ready(); err != nil {
+		t.Fatalf("expected a new monitor to be ready, got %v", err)
+	}
+	m.Drain()
+	if err := m.Ready(); !errors.Is(err, ErrDraining) {
+		t.Errorf("expected ErrDraining, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+// Package httpclient builds the HTTP client shared by outbound calls (Gmail, the LLM provider,
+// error reporting) so they get timeouts, bounded connection pools and the deployment's proxy
+// and CA settings instead of http.DefaultClient.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Defaults for unset Options fields
+const (
+	DefaultTimeout         = 30 * time.Second
+	DefaultMaxConnsPerHost = 32
+)
+
+// Options configures New
+type Options struct {
+	// Timeout bounds a whole request, including reading the body; 0 means DefaultTimeout
+	Timeout time.Duration
+	// ProxyURL sends every request through this proxy. When empty, HTTP_PROXY, HTTPS_PROXY
+	// and NO_PROXY from the environment apply.
+	ProxyURL string
+	// CABundle is a PEM file of extra trusted CAs, added to the system pool
+	CABundle string
+	// MaxConnsPerHost caps open connections to one host; 0 means DefaultMaxConnsPerHost
+	MaxConnsPerHost int
+}
+
+// New returns a client for opts
+func New(opts Options) (*http.Client, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxConnsPerHost <= 0 {
+		opts.MaxConnsPerHost = DefaultMaxConnsPerHost
+	}
+	proxy := http.ProxyFromEnvironment
+	if opts.ProxyURL != "" {
+		u, err := url.Parse(opts.ProxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url %q", opts.ProxyURL)
+		}
+		proxy = http.ProxyURL(u)
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.CABundle != "" {
+		pool, err := loadCABundle(opts.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: opts.Timeout,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   opts.MaxConnsPerHost,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+	}
+	return &http.Client{Transport: transport, Timeout: opts.Timeout}, nil
+}
+
+// loadCABundle returns the system pool with the certificates in path added
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ca bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("ca bundle %s: no certificates found", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,83 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewDefaults(t *testing.T) {
+	client, err := New(Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client.Timeout != DefaultTimeout {
+		t.Errorf("expected default timeout, got %v", client.Timeout)
+	}
+	if got := client.Transport.(*http.Transport).MaxConnsPerHost; got != DefaultMaxConnsPerHost {
+		t.Errorf("expected default max conns per host, got %d", got)
+	}
+}
+
+func TestNewProxy(t *testing.T) {
+	client, err := New(Options{ProxyURL: "http://proxy.internal:3128", Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "https://gmail.googleapis.com/", nil)
+	proxy, err := client.Transport.(*http.Transport).Proxy(req)
+	if err != nil || proxy == nil || proxy.Host != "proxy.internal:3128" {
+		t.Errorf("expected requests to go through the proxy, got %v, %v", proxy, err)
+	}
+	if _, err := New(Options{ProxyURL: "not a url"}); err == nil {
+		t.Error("expected error for invalid proxy url")
+	}
+}
+
+func TestNewCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	untrusted, err := New(Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := untrusted.Get(srv.URL); err == nil {
+		t.Fatal("expected the test server's certificate to be untrusted by default")
+	}
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	writeCert(t, srv, path)
+	client, err := New(Options{CABundle: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected the bundle to be trusted: %v", err)
+	}
+	resp.Body.Close()
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("nothing here"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(Options{CABundle: empty}); err == nil {
+		t.Error("expected error for a bundle without certificates")
+	}
+}
+
+// writeCert writes the test server's certificate to path as PEM
+func writeCert(t *testing.T, srv *httptest.Server, path string) {
+	t.Helper()
+	block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(path, block, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,190 @@
+// Package integrations sends messages to note-taking and read-later services ("send this email
+// to Notion"). Each service is a Target. Users store their credentials per target, and
+// deliveries are queued in the database and worked through by Service in the background, so a
+// slow or failing service never holds up the request that asked for the delivery.
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+var (
+	// ErrUnknownIntegration means no target is registered under the name
+	ErrUnknownIntegration = errors.New("unknown integration")
+	// ErrNotConfigured means the user has not stored credentials for the target
+	ErrNotConfigured = errors.New("integration is not configured")
+)
+
+// ConfigError describes an invalid integration config
+type ConfigError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// Target delivers messages to one service
+type Target interface {
+	// Name is the path segment the target is addressed by, e.g. "notion"
+	Name() string
+	// Validate checks a user's config (credentials and options) before it is saved, returning a
+	// *ConfigError for a missing or malformed field
+	Validate(config map[string]string) error
+	// Deliver sends item and returns a link to what the service created, or "" if it gives none.
+	// Errors wrapped with Permanent are not retried.
+	Deliver(ctx context.Context, client *http.Client, config map[string]string, item *models.IntegrationItem) (string, error)
+}
+
+// Registry holds the available targets by name
+type Registry struct {
+	targets map[string]Target
+}
+
+// NewRegistry returns a registry of targets
+func NewRegistry(targets ...Target) *Registry {
+	r := &Registry{targets: make(map[string]Target, len(targets))}
+	for _, t := range targets {
+		r.targets[t.Name()] = t
+	}
+	return r
+}
+
+// DefaultRegistry returns the built-in targets
+func DefaultRegistry() *Registry {
+	return NewRegistry(&Notion{}, &Obsidian{}, &Readwise{})
+}
+
+// Get returns the named target, or ErrUnknownIntegration
+func (r *Registry) Get(name string) (Target, error) {
+	t, ok := r.targets[name]
+	if !ok {
+		return nil, ErrUnknownIntegration
+	}
+	return t, nil
+}
+
+// Names returns the registered target names, sorted
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.targets))
+	for name := range r.targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// permanentError marks a delivery failure that retrying cannot fix, e.g. revoked credentials
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// gmailLink links to the message in Gmail's web UI
+const gmailLink = "https://mail.google.com/mail/u/0/#all/"
+
+// NewItem formats a message for delivery. The plain-text body is preferred; messages without one
+// fall back to the snippet.
+func NewItem(msg *models.EmailMessage) *models.IntegrationItem {
+	item := &models.IntegrationItem{
+		MessageID:  msg.EmailMessageID,
+		Title:      msg.Subject,
+		Author:     msg.SenderName,
+		AuthorAddr: msg.SenderAddress,
+		URL:        gmailLink + msg.EmailMessageID,
+		Text:       msg.Body,
+		HTML:       msg.HTMLBody,
+	}
+	if item.Title == "" {
+		item.Title = "(no subject)"
+	}
+	if item.Author == "" {
+		item.Author = msg.SenderAddress
+	}
+	if item.Text == "" {
+		item.Text = msg.Snippet
+	}
+	if msg.InternalDate > 0 {
+		item.ReceivedAt = time.UnixMilli(msg.InternalDate).UTC()
+	}
+	return item
+}
+
+// maxErrorBody bounds how much of a service's error response is kept in the delivery error
+const maxErrorBody = 512
+
+// doJSON sends a request with a JSON body (nil for none) and decodes a JSON response into out
+// (nil to discard it). Client errors other than 429 are permanent.
+func doJSON(ctx context.Context, client *http.Client, method, url string, header http.Header, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return Permanent(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return Permanent(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		err := fmt.Errorf("%s %s: %s: %s", method, req.URL.Host, resp.Status, strings.TrimSpace(string(msg)))
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return Permanent(err)
+		}
+		return err
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// required returns a ConfigError for the first of fields missing from config
+func required(config map[string]string, fields ...string) error {
+	for _, f := range fields {
+		if strings.TrimSpace(config[f]) == "" {
+			return &ConfigError{Field: f, Reason: "is required"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+var testItem = &models.IntegrationItem{
+	MessageID:  "m1",
+	Title:      "Weekly digest: Go 1.24",
+	Author:     "Go Weekly",
+	AuthorAddr: "news@golangweekly.com",
+	URL:        gmailLink + "m1",
+	Text:       "First paragraph.\n\nSecond paragraph.",
+	ReceivedAt: time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC),
+}
+
+func TestNewItem(t *testing.T) {
+	item := NewItem(&models.EmailMessage{EmailMessageID: "m1", SenderAddress: "a@example.com", Snippet: "hi", InternalDate: 1_700_000_000_000})
+	if item.Title != "(no subject)" || item.Author != "a@example.com" || item.Text != "hi" || item.URL != gmailLink+"m1" {
+		t.Errorf("unexpected item %+v", item)
+	}
+	if !item.ReceivedAt.Equal(time.UnixMilli(1_700_000_000_000)) {
+		t.Errorf("unexpected received time %v", item.ReceivedAt)
+	}
+}
+
+func TestNotion_Deliver(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pages" || r.Header.Get("Authorization") != "Bearer secret_x" || r.Header.Get("Notion-Version") == "" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"url":"https://www.notion.so/page-1"}`))
+	}))
+	defer srv.Close()
+	n := &Notion{BaseURL: srv.URL}
+	if err := n.Validate(map[string]string{"token": "secret_x"}); err == nil {
+		t.Error("expected a missing database_id to be rejected")
+	}
+	link, err := n.Deliver(context.Background(), nil, map[string]string{"token": "secret_x", "database_id": "db1"}, testItem)
+	if err != nil || link != "https://www.notion.so/page-1" {
+		t.Fatalf("Deliver = %q, %v", link, err)
+	}
+	if got["parent"].(map[string]any)["database_id"] != "db1" || len(got["children"].([]any)) != 3 {
+		t.Errorf("unexpected page %v", got)
+	}
+	if _, ok := got["properties"].(map[string]any)["Name"]; !ok {
+		t.Errorf("expected the default title property, got %v", got["properties"])
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	long := strings.Repeat("é", 2500)
+	chunks := splitText("short\n\n"+long, 2000)
+	if len(chunks) != 3 || chunks[0] != "short" || len([]rune(chunks[1])) != 2000 || len([]rune(chunks[2])) != 500 {
+		t.Errorf("unexpected chunks %d", len(chunks))
+	}
+}
+
+func TestReadwise_DeliverErrors(t *testing.T) {
+	status := http.StatusUnauthorized
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token rw" {
+			t.Errorf("unexpected auth %q", r.Header.Get("Authorization"))
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["url"] != testItem.URL || !strings.Contains(body["html"].(string), "<pre>First paragraph.") {
+			t.Errorf("unexpected body %v", body)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"url":"https://read.readwise.io/read/1"}`))
+	}))
+	defer srv.Close()
+	rw := &Readwise{BaseURL: srv.URL}
+	cfg := map[string]string{"token": "rw"}
+
+	if _, err := rw.Deliver(context.Background(), nil, cfg, testItem); !IsPermanent(err) {
+		t.Errorf("expected a rejected token to be permanent, got %v", err)
+	}
+	status = http.StatusTooManyRequests
+	if _, err := rw.Deliver(context.Background(), nil, cfg, testItem); err == nil || IsPermanent(err) {
+		t.Errorf("expected rate limiting to be retried, got %v", err)
+	}
+	status = http.StatusCreated
+	if link, err := rw.Deliver(context.Background(), nil, cfg, testItem); err != nil || link != "https://read.readwise.io/read/1" {
+		t.Errorf("Deliver = %q, %v", link, err)
+	}
+}
+
+func TestObsidian(t *testing.T) {
+	var path, note string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		note = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	o := &Obsidian{}
+	for _, cfg := range []map[string]string{
+		{"url": "http://notes.example.com", "api_key": "k"},
+		{"url": "https://127.0.0.1:27124", "api_key": "k"},
+		{"url": "https://localhost:27124", "api_key": "k"},
+		{"url": "https://notes.example.com", "api_key": "k", "folder": "../etc"},
+	} {
+		var cerr *ConfigError
+		if err := o.Validate(cfg); !errors.As(err, &cerr) {
+			t.Errorf("expected %v to be rejected, got %v", cfg, err)
+		}
+	}
+	if err := o.Validate(map[string]string{"url": "https://notes.example.com", "api_key": "k"}); err != nil {
+		t.Errorf("expected a public https URL to pass, got %v", err)
+	}
+
+	o.AllowPrivate = true
+	if _, err := o.Deliver(context.Background(), nil, map[string]string{"url": srv.URL, "api_key": "k", "folder": "Mail"}, testItem); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if path != "/vault/Mail/2026-10-15 Weekly digest Go 1.24.md" {
+		t.Errorf("unexpected note path %q", path)
+	}
+	if !strings.HasPrefix(note, "---\ntitle: \"Weekly digest: Go 1.24\"\n") || !strings.HasSuffix(note, "Second paragraph.\n") {
+		t.Errorf("unexpected note %q", note)
+	}
+}
@@ -0,0 +1,97 @@
+package integrations
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+// Notion adds a page to a database. Config: "token" (an internal integration secret shared with
+// the database), "database_id", and optionally "title_property", the database's title column
+// ("Name" by default).
+type Notion struct {
+	// BaseURL overrides the API endpoint (tests)
+	BaseURL string
+}
+
+const (
+	notionAPI     = "https://api.notion.com/v1"
+	notionVersion = "2022-06-28"
+	// Notion caps rich text at 2000 characters and a page create at 100 child blocks
+	notionMaxText   = 2000
+	notionMaxBlocks = 100
+)
+
+func (n *Notion) Name() string { return "notion" }
+
+func (n *Notion) Validate(config map[string]string) error {
+	return required(config, "token", "database_id")
+}
+
+func (n *Notion) Deliver(ctx context.Context, client *http.Client, config map[string]string, item *models.IntegrationItem) (string, error) {
+	base := n.BaseURL
+	if base == "" {
+		base = notionAPI
+	}
+	titleProp := config["title_property"]
+	if titleProp == "" {
+		titleProp = "Name"
+	}
+	children := []any{paragraph("From " + item.Author + " · " + item.URL)}
+	for _, chunk := range splitText(item.Text, notionMaxText) {
+		if len(children) == notionMaxBlocks {
+			break
+		}
+		children = append(children, paragraph(chunk))
+	}
+	body := map[string]any{
+		"parent": map[string]string{"database_id": config["database_id"]},
+		"properties": map[string]any{
+			titleProp: map[string]any{"title": []any{richText(item.Title)}},
+		},
+		"children": children,
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+config["token"])
+	header.Set("Notion-Version", notionVersion)
+	var resp struct {
+		URL string `json:"url"`
+	}
+	if err := doJSON(ctx, client, http.MethodPost, base+"/pages", header, body, &resp); err != nil {
+		return "", err
+	}
+	return resp.URL, nil
+}
+
+func richText(s string) map[string]any {
+	return map[string]any{"type": "text", "text": map[string]string{"content": s}}
+}
+
+func paragraph(s string) map[string]any {
+	return map[string]any{
+		"object":    "block",
+		"type":      "paragraph",
+		"paragraph": map[string]any{"rich_text": []any{richText(s)}},
+	}
+}
+
+// splitText splits s into chunks of at most max characters, preferring paragraph breaks
+func splitText(s string, max int) []string {
+	var out []string
+	for _, para := range strings.Split(strings.TrimSpace(s), "\n\n") {
+		para = strings.TrimSpace(para)
+		for para != "" {
+			if utf8.RuneCountInString(para) <= max {
+				out = append(out, para)
+				break
+			}
+			runes := []rune(para)
+			out = append(out, string(runes[:max]))
+			para = string(runes[max:])
+		}
+	}
+	return out
+}
@@ -0,0 +1,131 @@
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+// Obsidian writes the message as a Markdown note through the Local REST API community plugin.
+// Config: "url", where the plugin is reachable from this server over HTTPS (e.g. through a
+// tunnel), "api_key", and optionally "folder" within the vault ("Inbox" by default).
+type Obsidian struct {
+	// AllowPrivate permits URLs on loopback and private addresses (tests); off, users cannot
+	// point the server at internal services
+	AllowPrivate bool
+}
+
+func (o *Obsidian) Name() string { return "obsidian" }
+
+func (o *Obsidian) Validate(config map[string]string) error {
+	if err := required(config, "url", "api_key"); err != nil {
+		return err
+	}
+	u, err := url.Parse(config["url"])
+	if err != nil || u.Host == "" {
+		return &ConfigError{Field: "url", Reason: "must be an absolute URL"}
+	}
+	if u.Scheme != "https" && !o.AllowPrivate {
+		return &ConfigError{Field: "url", Reason: "must use https"}
+	}
+	if !o.AllowPrivate && privateHost(u.Hostname()) {
+		return &ConfigError{Field: "url", Reason: "must not point at a local or private address"}
+	}
+	if strings.Contains(config["folder"], "..") {
+		return &ConfigError{Field: "folder", Reason: "must stay inside the vault"}
+	}
+	return nil
+}
+
+// privateHost reports whether host is a loopback, private or link-local address, or localhost.
+// Names are not resolved, so a public name pointing at a private address still passes.
+func privateHost(host string) bool {
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified())
+}
+
+func (o *Obsidian) Deliver(ctx context.Context, client *http.Client, config map[string]string, item *models.IntegrationItem) (string, error) {
+	// Configs are validated when saved, but the rules may have tightened since
+	if err := o.Validate(config); err != nil {
+		return "", Permanent(err)
+	}
+	folder := config["folder"]
+	if folder == "" {
+		folder = "Inbox"
+	}
+	note := path.Join(folder, noteName(item)+".md")
+	target := strings.TrimRight(config["url"], "/") + "/vault/" + escapePath(note)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, strings.NewReader(Markdown(item)))
+	if err != nil {
+		return "", Permanent(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+config["api_key"])
+	req.Header.Set("Content-Type", "text/markdown")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("PUT %s: %s", req.URL.Host, resp.Status)
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return "", Permanent(err)
+		}
+		return "", err
+	}
+	return "", nil
+}
+
+var unsafeNoteChars = regexp.MustCompile(`[\\/:*?"<>|#^\[\]]+`)
+
+// noteName builds a file name from the received date and subject
+func noteName(item *models.IntegrationItem) string {
+	name := strings.Join(strings.Fields(unsafeNoteChars.ReplaceAllString(item.Title, " ")), " ")
+	if r := []rune(name); len(r) > 100 {
+		name = strings.TrimSpace(string(r[:100]))
+	}
+	if !item.ReceivedAt.IsZero() {
+		name = item.ReceivedAt.Format("2006-01-02") + " " + name
+	}
+	return name
+}
+
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Markdown renders item as a note with YAML front matter
+func Markdown(item *models.IntegrationItem) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %q\n", item.Title)
+	fmt.Fprintf(&b, "author: %q\n", item.Author)
+	if item.AuthorAddr != "" {
+		fmt.Fprintf(&b, "email: %q\n", item.AuthorAddr)
+	}
+	if !item.ReceivedAt.IsZero() {
+		fmt.Fprintf(&b, "received: %s\n", item.ReceivedAt.Format("2006-01-02T15:04:05Z07:00"))
+	}
+	fmt.Fprintf(&b, "source: %q\n", item.URL)
+	b.WriteString("---\n\n")
+	b.WriteString(strings.TrimSpace(item.Text))
+	b.WriteString("\n")
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package integrations
+
+import (
+	"context"
+	"html"
+	"net/http"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+// Readwise saves the message to Readwise Reader. Config: "token", a Readwise access token.
+type Readwise struct {
+	// BaseURL overrides the API endpoint (tests)
+	BaseURL string
+}
+
+const readwiseAPI = "https://readwise.io/api/v3"
+
+func (rw *Readwise) Name() string { return "readwise" }
+
+func (rw *Readwise) Validate(config map[string]string) error {
+	return required(config, "token")
+}
+
+func (rw *Readwise) Deliver(ctx context.Context, client *http.Client, config map[string]string, item *models.IntegrationItem) (string, error) {
+	base := rw.BaseURL
+	if base == "" {
+		base = readwiseAPI
+	}
+	content := item.HTML
+	if content == "" {
+		content = "<pre>" + html.EscapeString(item.Text) + "</pre>"
+	}
+	body := map[string]any{
+		"url":               item.URL,
+		"html":              content,
+		"should_clean_html": true,
+		"title":             item.Title,
+		"author":            item.Author,
+		"category":          "email",
+		"saved_using":       "Inbox Whisperer",
+	}
+	if !item.ReceivedAt.IsZero() {
+		body["published_date"] = item.ReceivedAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Token "+config["token"])
+	var resp struct {
+		URL string `json:"url"`
+	}
+	if err := doJSON(ctx, client, http.MethodPost, base+"/save/", header, body, &resp); err != nil {
+		return "", err
+	}
+	return resp.URL, nil
+}
@@ -0,0 +1,247 @@
+package integrations
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/health"
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	telemetryerrors "github.com/desponda/inbox-whisperer/internal/telemetry/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultMaxAttempts is how many times a delivery is tried before it is marked failed
+const DefaultMaxAttempts = 4
+
+// Worker pacing. A delivery still running after abandonAfter was claimed by a worker that
+// stopped and is tried again.
+const (
+	idlePoll     = 30 * time.Second
+	abandonAfter = 10 * time.Minute
+	// deliverTimeout bounds one attempt, on top of the HTTP client's own timeout
+	deliverTimeout = time.Minute
+)
+
+// JobTypeDelivery is the job type reported to the health monitor
+const JobTypeDelivery = "integration_delivery"
+
+// Service stores users' integration configs, queues deliveries and works through the queue
+type Service struct {
+	registry   *Registry
+	configs    data.IntegrationRepository
+	deliveries data.IntegrationDeliveryRepository
+
+	// HTTPClient carries calls to the integrations; optional (http.DefaultClient when nil)
+	HTTPClient *http.Client
+	// MaxAttempts caps tries per delivery; failures back off exponentially from a minute
+	MaxAttempts int
+	// Health, if set, receives heartbeats and delivery outcomes
+	Health *health.Worker
+	// Maintenance, if set, pauses the worker while it is on
+	Maintenance *maintenance.Switch
+	// Errors, if set, receives deliveries that failed for good
+	Errors telemetryerrors.Reporter
+
+	wake chan struct{}
+	now  func() time.Time
+}
+
+func NewService(registry *Registry, configs data.IntegrationRepository, deliveries data.IntegrationDeliveryRepository) *Service {
+	return &Service{
+		registry:    registry,
+		configs:     configs,
+		deliveries:  deliveries,
+		MaxAttempts: DefaultMaxAttempts,
+		wake:        make(chan struct{}, 1),
+		now:         time.Now,
+	}
+}
+
+// Available returns the names of the integrations users can configure
+func (s *Service) Available() []string {
+	return s.registry.Names()
+}
+
+// Configured returns the user's configured integrations
+func (s *Service) Configured(ctx context.Context, userID string) ([]*models.Integration, error) {
+	return s.configs.List(ctx, userID)
+}
+
+// Configure validates and stores the user's config for an integration, replacing any earlier one
+func (s *Service) Configure(ctx context.Context, userID, name string, config map[string]string) (*models.Integration, error) {
+	t, err := s.registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Validate(config); err != nil {
+		return nil, err
+	}
+	integration := &models.Integration{UserID: userID, Name: name, Config: config}
+	if err := s.configs.Upsert(ctx, integration); err != nil {
+		return nil, err
+	}
+	return integration, nil
+}
+
+// Remove deletes the user's config; queued deliveries to it fail on their next attempt
+func (s *Service) Remove(ctx context.Context, userID, name string) error {
+	err := s.configs.Delete(ctx, userID, name)
+	if errors.Is(err, data.ErrNotFound) {
+		return ErrNotConfigured
+	}
+	return err
+}
+
+// Ready returns ErrUnknownIntegration or ErrNotConfigured unless the user can send to the
+// named integration
+func (s *Service) Ready(ctx context.Context, userID, name string) error {
+	if _, err := s.registry.Get(name); err != nil {
+		return err
+	}
+	_, err := s.configs.Get(ctx, userID, name)
+	if errors.Is(err, data.ErrNotFound) {
+		return ErrNotConfigured
+	}
+	return err
+}
+
+// Enqueue queues msg for delivery to the named integration. It fails like Ready before anything
+// is queued.
+func (s *Service) Enqueue(ctx context.Context, userID, name string, msg *models.EmailMessage) (*models.IntegrationDelivery, error) {
+	if err := s.Ready(ctx, userID, name); err != nil {
+		return nil, err
+	}
+	d := &models.IntegrationDelivery{
+		UserID:      userID,
+		MessageID:   msg.EmailMessageID,
+		Integration: name,
+		Item:        NewItem(msg),
+	}
+	if err := s.deliveries.Create(ctx, d); err != nil {
+		return nil, err
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return d, nil
+}
+
+// Delivery returns one of the user's deliveries; data.ErrNotFound if there is no such delivery
+func (s *Service) Delivery(ctx context.Context, userID string, id int64) (*models.IntegrationDelivery, error) {
+	return s.deliveries.Get(ctx, userID, id)
+}
+
+// Deliveries returns the user's most recent deliveries, newest first
+func (s *Service) Deliveries(ctx context.Context, userID string, limit int) ([]*models.IntegrationDelivery, error) {
+	return s.deliveries.ListByUser(ctx, userID, limit)
+}
+
+// Pending reports the number of unfinished deliveries and when the oldest was created
+func (s *Service) Pending(ctx context.Context) (int, *time.Time, error) {
+	return s.deliveries.Pending(ctx)
+}
+
+// Start runs the worker until ctx is cancelled
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(idlePoll)
+		defer ticker.Stop()
+		for {
+			s.drain(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wake:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// drain delivers due deliveries until none are left
+func (s *Service) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		s.Health.Beat()
+		if err := maintenance.Wait(ctx, s.Maintenance, s.Health.Beat); err != nil {
+			return
+		}
+		now := s.now()
+		d, err := s.deliveries.ClaimDue(ctx, now, now.Add(-abandonAfter))
+		if err != nil {
+			log.Error().Err(err).Msg("integrations: failed to claim a delivery")
+			return
+		}
+		if d == nil {
+			return
+		}
+		s.deliver(ctx, d)
+	}
+}
+
+// deliver makes one attempt and records the outcome
+func (s *Service) deliver(ctx context.Context, d *models.IntegrationDelivery) {
+	err := s.attempt(ctx, d)
+	if ctx.Err() != nil {
+		// Shutting down: the delivery stays running and is claimed again once abandoned
+		return
+	}
+	d.Attempts++
+	now := s.now().UTC()
+	switch {
+	case err == nil:
+		d.Status, d.Error, d.DeliveredAt = models.DeliveryDelivered, "", &now
+	case IsPermanent(err) || d.Attempts >= s.MaxAttempts:
+		d.Status, d.Error = models.DeliveryFailed, err.Error()
+		telemetryerrors.Capture(ctx, s.Errors, err, d.UserID, map[string]string{"job_type": JobTypeDelivery, "integration": d.Integration})
+	default:
+		d.Status, d.Error = models.DeliveryQueued, err.Error()
+		d.NextAttemptAt = now.Add(retryDelay(d.Attempts))
+	}
+	s.Health.Record(JobTypeDelivery, err)
+	if err != nil {
+		log.Warn().Err(err).Int64("delivery_id", d.ID).Str("integration", d.Integration).Int("attempts", d.Attempts).
+			Msg("integrations: delivery failed")
+	}
+	if err := s.deliveries.Save(context.Background(), d); err != nil {
+		log.Error().Err(err).Int64("delivery_id", d.ID).Msg("integrations: failed to save delivery")
+	}
+}
+
+func (s *Service) attempt(ctx context.Context, d *models.IntegrationDelivery) error {
+	t, err := s.registry.Get(d.Integration)
+	if err != nil {
+		return Permanent(err)
+	}
+	cfg, err := s.configs.Get(ctx, d.UserID, d.Integration)
+	if errors.Is(err, data.ErrNotFound) {
+		return Permanent(ErrNotConfigured)
+	}
+	if err != nil {
+		return err
+	}
+	if d.Item == nil {
+		return Permanent(errors.New("delivery has no content"))
+	}
+	ctx, cancel := context.WithTimeout(ctx, deliverTimeout)
+	defer cancel()
+	link, err := t.Deliver(ctx, s.HTTPClient, cfg.Config, d.Item)
+	if err != nil {
+		return err
+	}
+	d.ExternalURL = link
+	return nil
+}
+
+// retryDelay is the wait before the next attempt: 1, 4, 16... minutes
+func retryDelay(attempts int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempts; i++ {
+		delay *= 4
+	}
+	return delay
+}
@@ -0,0 +1,153 @@
+package integrations
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+type memoryConfigs struct {
+	configs map[string]*models.Integration
+}
+
+func (m *memoryConfigs) Get(ctx context.Context, userID, name string) (*models.Integration, error) {
+	if i, ok := m.configs[userID+"/"+name]; ok {
+		return i, nil
+	}
+	return nil, data.ErrNotFound
+}
+func (m *memoryConfigs) List(ctx context.Context, userID string) ([]*models.Integration, error) {
+	return nil, nil
+}
+func (m *memoryConfigs) Upsert(ctx context.Context, i *models.Integration) error {
+	m.configs[i.UserID+"/"+i.Name] = i
+	return nil
+}
+func (m *memoryConfigs) Delete(ctx context.Context, userID, name string) error {
+	delete(m.configs, userID+"/"+name)
+	return nil
+}
+
+type memoryDeliveries struct {
+	rows []*models.IntegrationDelivery
+}
+
+func (m *memoryDeliveries) Create(ctx context.Context, d *models.IntegrationDelivery) error {
+	d.ID, d.Status = int64(len(m.rows)+1), models.DeliveryQueued
+	m.rows = append(m.rows, d)
+	return nil
+}
+func (m *memoryDeliveries) Get(ctx context.Context, userID string, id int64) (*models.IntegrationDelivery, error) {
+	return nil, data.ErrNotFound
+}
+func (m *memoryDeliveries) ListByUser(ctx context.Context, userID string, limit int) ([]*models.IntegrationDelivery, error) {
+	return m.rows, nil
+}
+func (m *memoryDeliveries) ClaimDue(ctx context.Context, now, abandonedBefore time.Time) (*models.IntegrationDelivery, error) {
+	for _, d := range m.rows {
+		if d.Status == models.DeliveryQueued && !d.NextAttemptAt.After(now) {
+			d.Status = models.DeliveryRunning
+			return d, nil
+		}
+	}
+	return nil, nil
+}
+func (m *memoryDeliveries) Save(ctx context.Context, d *models.IntegrationDelivery) error {
+	if d.Finished() {
+		d.Item = nil
+	}
+	return nil
+}
+func (m *memoryDeliveries) Pending(ctx context.Context) (int, *time.Time, error) { return 0, nil, nil }
+
+// fakeTarget fails with errs in turn, then succeeds
+type fakeTarget struct {
+	errs      []error
+	delivered []*models.IntegrationItem
+}
+
+func (f *fakeTarget) Name() string                            { return "fake" }
+func (f *fakeTarget) Validate(config map[string]string) error { return required(config, "token") }
+func (f *fakeTarget) Deliver(ctx context.Context, client *http.Client, config map[string]string, item *models.IntegrationItem) (string, error) {
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return "", err
+	}
+	f.delivered = append(f.delivered, item)
+	return "https://example.com/1", nil
+}
+
+func TestService_EnqueueAndDeliver(t *testing.T) {
+	ctx := context.Background()
+	target := &fakeTarget{errs: []error{errors.New("timeout")}}
+	deliveries := &memoryDeliveries{}
+	svc := NewService(NewRegistry(target), &memoryConfigs{configs: map[string]*models.Integration{}}, deliveries)
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	msg := &models.EmailMessage{EmailMessageID: "m1", Subject: "Hello", Body: "body"}
+
+	if _, err := svc.Enqueue(ctx, "u1", "nope", msg); !errors.Is(err, ErrUnknownIntegration) {
+		t.Errorf("expected ErrUnknownIntegration, got %v", err)
+	}
+	if _, err := svc.Enqueue(ctx, "u1", "fake", msg); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("expected ErrNotConfigured, got %v", err)
+	}
+	var cerr *ConfigError
+	if _, err := svc.Configure(ctx, "u1", "fake", map[string]string{}); !errors.As(err, &cerr) {
+		t.Errorf("expected a ConfigError, got %v", err)
+	}
+	if _, err := svc.Configure(ctx, "u1", "fake", map[string]string{"token": "t"}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	d, err := svc.Enqueue(ctx, "u1", "fake", msg)
+	if err != nil || d.Item.Title != "Hello" {
+		t.Fatalf("Enqueue = %+v, %v", d, err)
+	}
+
+	// The first attempt fails and is retried after a minute
+	svc.drain(ctx)
+	if d.Status != models.DeliveryQueued || d.Attempts != 1 || d.Error != "timeout" || !d.NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("expected a scheduled retry, got %+v", d)
+	}
+	svc.drain(ctx)
+	if d.Attempts != 1 {
+		t.Fatalf("expected no attempt before the retry is due, got %+v", d)
+	}
+	now = now.Add(time.Minute)
+	svc.drain(ctx)
+	if d.Status != models.DeliveryDelivered || d.ExternalURL != "https://example.com/1" || d.Item != nil || d.DeliveredAt == nil {
+		t.Errorf("expected the delivery to succeed, got %+v", d)
+	}
+	if len(target.delivered) != 1 || target.delivered[0].Text != "body" {
+		t.Errorf("unexpected deliveries %+v", target.delivered)
+	}
+}
+
+func TestService_PermanentFailure(t *testing.T) {
+	ctx := context.Background()
+	target := &fakeTarget{errs: []error{Permanent(errors.New("401 Unauthorized"))}}
+	deliveries := &memoryDeliveries{}
+	configs := &memoryConfigs{configs: map[string]*models.Integration{}}
+	svc := NewService(NewRegistry(target), configs, deliveries)
+	svc.Configure(ctx, "u1", "fake", map[string]string{"token": "t"})
+	d, _ := svc.Enqueue(ctx, "u1", "fake", &models.EmailMessage{EmailMessageID: "m1"})
+
+	svc.drain(ctx)
+	if d.Status != models.DeliveryFailed || d.Attempts != 1 || d.Item != nil {
+		t.Errorf("expected the delivery to fail without retries, got %+v", d)
+	}
+
+	// A delivery whose integration was removed in the meantime fails too
+	d, _ = svc.Enqueue(ctx, "u1", "fake", &models.EmailMessage{EmailMessageID: "m2"})
+	svc.Remove(ctx, "u1", "fake")
+	svc.drain(ctx)
+	if d.Status != models.DeliveryFailed || d.Error != ErrNotConfigured.Error() {
+		t.Errorf("expected the delivery to fail, got %+v", d)
+	}
+}
@@ -0,0 +1,297 @@
+// Package jobs is the background job queue. Jobs are rows in Postgres, which lets any server
+// process enqueue work and any other run it: a pool of workers claims due jobs, leasing each so
+// that the job of a worker that died is picked up again once the lease runs out, and failed
+// runs are retried with exponential backoff up to the kind's attempt limit.
+//
+// The kinds keep their own records of the work where they need more than the queue's progress
+// counters (see bulk.Runner and recategorize.Runner); the queue job points at it with RefID.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/health"
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	telemetryerrors "github.com/desponda/inbox-whisperer/internal/telemetry/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Defaults for the Queue
+const (
+	DefaultWorkers      = 2
+	DefaultLease        = 5 * time.Minute
+	DefaultPollInterval = 5 * time.Second
+)
+
+// DefaultRetryPolicy is used for kinds registered without a policy
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, Backoff: 30 * time.Second, MaxBackoff: 30 * time.Minute}
+
+// Handler runs a claimed job. It may update the job's progress and checkpoint and save them
+// with Queue.Save; the queue sets the status, error and times once it returns. A run cut short
+// by ctx being cancelled is handed back to the queue without counting as an attempt.
+type Handler func(ctx context.Context, job *models.Job) error
+
+// RetryPolicy bounds how often and how soon a failed job is run again
+type RetryPolicy struct {
+	// MaxAttempts counts the first run; 1 disables retries
+	MaxAttempts int
+	// Backoff is the delay before the first retry; it doubles for each one after
+	Backoff time.Duration
+	// MaxBackoff caps the delay
+	MaxBackoff time.Duration
+}
+
+// Delay returns how long to wait before the retry following the given attempt
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// permanentError marks an error that retrying cannot fix
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job fails without further attempts, e.g. when its input is gone
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// WillRetry reports whether the queue will run job again after its current run failed with err.
+// Handlers keeping their own record of the work use it to tell a failure from a pending retry.
+func WillRetry(job *models.Job, err error) bool {
+	return err != nil && !IsPermanent(err) && job.Attempts < job.MaxAttempts
+}
+
+type kind struct {
+	handler Handler
+	policy  RetryPolicy
+}
+
+// Queue enqueues jobs and, once started, runs them with a pool of workers
+type Queue struct {
+	repo  data.JobRepository
+	kinds map[string]kind
+
+	// Workers is how many jobs run at once in this process
+	Workers int
+	// Lease is how long a claimed job stays claimed without its worker renewing it; a job whose
+	// worker died is run again once it passes
+	Lease time.Duration
+	// PollInterval is how often idle workers look for due jobs, e.g. retries or jobs enqueued by
+	// another process
+	PollInterval time.Duration
+	// Health, if set, receives heartbeats and job outcomes by kind
+	Health *health.Worker
+	// Maintenance, if set, stops new jobs from starting while it is on
+	Maintenance *maintenance.Switch
+	// Errors, if set, receives jobs that failed for good
+	Errors telemetryerrors.Reporter
+
+	wake chan struct{}
+	now  func() time.Time
+}
+
+func NewQueue(repo data.JobRepository) *Queue {
+	return &Queue{
+		repo:         repo,
+		kinds:        map[string]kind{},
+		Workers:      DefaultWorkers,
+		Lease:        DefaultLease,
+		PollInterval: DefaultPollInterval,
+		wake:         make(chan struct{}, 1),
+		now:          time.Now,
+	}
+}
+
+// Register sets the handler and retry policy for a kind; a zero policy means DefaultRetryPolicy.
+// Only registered kinds can be enqueued, and this process only runs the kinds it registered.
+// Register must be called before Start.
+func (q *Queue) Register(name string, h Handler, policy RetryPolicy) {
+	if policy.MaxAttempts == 0 {
+		policy = DefaultRetryPolicy
+	}
+	q.kinds[name] = kind{handler: h, policy: policy}
+}
+
+// Enqueue queues a job of a registered kind. userID is empty for jobs covering all users; refID
+// points at the kind's own record, if any; payload, if not nil, is stored as JSON.
+func (q *Queue) Enqueue(ctx context.Context, kindName, userID string, refID int64, payload any) (*models.Job, error) {
+	k, ok := q.kinds[kindName]
+	if !ok {
+		return nil, fmt.Errorf("jobs: unknown kind %q", kindName)
+	}
+	job := &models.Job{Kind: kindName, UserID: userID, RefID: refID, MaxAttempts: k.policy.MaxAttempts, RunAt: q.now().UTC()}
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		job.Payload = raw
+	}
+	if err := q.repo.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Job returns a job by ID; data.ErrNotFound if it does not exist
+func (q *Queue) Job(ctx context.Context, id int64) (*models.Job, error) {
+	return q.repo.GetJob(ctx, id)
+}
+
+// Active returns the user's queued or running job of a kind, or nil
+func (q *Queue) Active(ctx context.Context, kindName, userID string) (*models.Job, error) {
+	return q.repo.ActiveJob(ctx, kindName, userID)
+}
+
+// Save writes a running job's progress and checkpoint
+func (q *Queue) Save(ctx context.Context, job *models.Job) error {
+	return q.repo.UpdateJob(ctx, job)
+}
+
+// Pending reports the number of unfinished jobs and when the oldest was created
+func (q *Queue) Pending(ctx context.Context) (int, *time.Time, error) {
+	return q.repo.PendingJobs(ctx)
+}
+
+// Start runs the workers until ctx is cancelled. Jobs left running by a previous process are
+// run again once their lease expires.
+func (q *Queue) Start(ctx context.Context) {
+	names := make([]string, 0, len(q.kinds))
+	for name := range q.kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	slots := make(chan struct{}, q.Workers)
+	go func() {
+		ticker := time.NewTicker(q.PollInterval)
+		defer ticker.Stop()
+		for {
+			q.Health.Beat()
+			q.dispatch(ctx, names, slots)
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.wake:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// dispatch claims due jobs while a worker slot is free, running each in its own goroutine
+func (q *Queue) dispatch(ctx context.Context, names []string, slots chan struct{}) {
+	for ctx.Err() == nil && !q.Maintenance.Active() {
+		select {
+		case slots <- struct{}{}:
+		default:
+			return
+		}
+		now := q.now().UTC()
+		job, err := q.repo.ClaimJob(ctx, names, now, now.Add(q.Lease))
+		if err != nil || job == nil {
+			<-slots
+			if err != nil {
+				log.Error().Err(err).Msg("jobs: failed to claim a job")
+			}
+			return
+		}
+		go func() {
+			defer func() {
+				<-slots
+				select {
+				case q.wake <- struct{}{}:
+				default:
+				}
+			}()
+			q.run(ctx, job)
+		}()
+	}
+}
+
+// run runs a claimed job, renewing its lease meanwhile, and records the outcome
+func (q *Queue) run(ctx context.Context, job *models.Job) {
+	k := q.kinds[job.Kind]
+	runCtx, stop := context.WithCancel(ctx)
+	leased := make(chan struct{})
+	go func() {
+		defer close(leased)
+		q.renewLease(runCtx, job.ID)
+	}()
+	err := k.handler(runCtx, job)
+	stop()
+	<-leased
+
+	if ctx.Err() != nil {
+		// Shutting down: hand the job back without counting the attempt
+		job.Status, job.Attempts = models.JobQueued, job.Attempts-1
+		q.save(context.Background(), job)
+		return
+	}
+	now := q.now().UTC()
+	switch {
+	case err == nil:
+		job.Status, job.Error, job.FinishedAt = models.JobCompleted, "", &now
+		log.Info().Int64("job_id", job.ID).Str("kind", job.Kind).Int("attempt", job.Attempts).Msg("jobs: job completed")
+	case WillRetry(job, err):
+		job.Status, job.Error, job.RunAt = models.JobQueued, err.Error(), now.Add(k.policy.Delay(job.Attempts))
+		log.Warn().Err(err).Int64("job_id", job.ID).Str("kind", job.Kind).Int("attempt", job.Attempts).
+			Time("retry_at", job.RunAt).Msg("jobs: job failed, will retry")
+	default:
+		job.Status, job.Error, job.FinishedAt = models.JobFailed, err.Error(), &now
+		telemetryerrors.Capture(ctx, q.Errors, err, job.UserID, map[string]string{"job_type": job.Kind})
+		log.Error().Err(err).Int64("job_id", job.ID).Str("kind", job.Kind).Int("attempt", job.Attempts).Msg("jobs: job failed")
+	}
+	q.Health.Record(job.Kind, err)
+	q.save(context.Background(), job)
+}
+
+// renewLease extends a running job's lease every third of the lease until ctx is cancelled
+func (q *Queue) renewLease(ctx context.Context, id int64) {
+	ticker := time.NewTicker(q.Lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := q.repo.ExtendLease(ctx, id, q.now().UTC().Add(q.Lease)); err != nil && ctx.Err() == nil {
+				log.Warn().Err(err).Int64("job_id", id).Msg("jobs: failed to renew lease")
+			}
+		}
+	}
+}
+
+func (q *Queue) save(ctx context.Context, job *models.Job) {
+	if err := q.repo.UpdateJob(ctx, job); err != nil {
+		log.Error().Err(err).Int64("job_id", job.ID).Msg("jobs: failed to save job")
+	}
+}
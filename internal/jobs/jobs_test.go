@@ -0,0 +1,218 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+// memoryJobs keeps queue jobs in memory
+type memoryJobs struct {
+	mu   sync.Mutex
+	jobs []models.Job
+}
+
+func (m *memoryJobs) CreateJob(ctx context.Context, j *models.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j.ID, j.Status = int64(len(m.jobs)+1), models.JobQueued
+	m.jobs = append(m.jobs, *j)
+	return nil
+}
+
+func (m *memoryJobs) GetJob(ctx context.Context, id int64) (*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > int64(len(m.jobs)) {
+		return nil, data.ErrNotFound
+	}
+	j := m.jobs[id-1]
+	return &j, nil
+}
+
+func (m *memoryJobs) UpdateJob(ctx context.Context, j *models.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[j.ID-1] = *j
+	return nil
+}
+
+func (m *memoryJobs) ActiveJob(ctx context.Context, kind, userID string) (*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.Kind == kind && j.UserID == userID && !j.Finished() {
+			return &j, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryJobs) ClaimJob(ctx context.Context, kinds []string, now, lockedUntil time.Time) (*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.jobs {
+		j := &m.jobs[i]
+		if j.Status == models.JobQueued && !j.RunAt.After(now) {
+			j.Status, j.Attempts, j.StartedAt = models.JobRunning, j.Attempts+1, &now
+			claimed := *j
+			return &claimed, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryJobs) ExtendLease(ctx context.Context, id int64, lockedUntil time.Time) error {
+	return nil
+}
+
+func (m *memoryJobs) PendingJobs(ctx context.Context) (int, *time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, j := range m.jobs {
+		if !j.Finished() {
+			n++
+		}
+	}
+	return n, nil, nil
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := p.Delay(attempt); got != want {
+			t.Errorf("attempt %d: expected %v, got %v", attempt, want, got)
+		}
+	}
+}
+
+func TestWillRetry(t *testing.T) {
+	job := &models.Job{Attempts: 1, MaxAttempts: 2}
+	boom := errors.New("boom")
+	if WillRetry(job, nil) || !WillRetry(job, boom) || WillRetry(job, Permanent(boom)) {
+		t.Error("expected only a plain error to be retried")
+	}
+	if !errors.Is(Permanent(boom), boom) || Permanent(nil) != nil {
+		t.Error("expected Permanent to wrap the error")
+	}
+	job.Attempts = 2
+	if WillRetry(job, boom) {
+		t.Error("expected no retry once the attempts run out")
+	}
+}
+
+func TestQueue_Enqueue(t *testing.T) {
+	q := NewQueue(&memoryJobs{})
+	if _, err := q.Enqueue(context.Background(), "unknown", "u1", 0, nil); err == nil {
+		t.Error("expected an unregistered kind to be refused")
+	}
+	q.Register("test", func(ctx context.Context, job *models.Job) error { return nil }, RetryPolicy{})
+	job, err := q.Enqueue(context.Background(), "test", "u1", 7, map[string]string{"a": "b"})
+	if err != nil || job.ID != 1 || job.MaxAttempts != DefaultRetryPolicy.MaxAttempts || string(job.Payload) != `{"a":"b"}` {
+		t.Fatalf("unexpected job %+v (%v)", job, err)
+	}
+	if active, err := q.Active(context.Background(), "test", "u1"); err != nil || active == nil || active.ID != job.ID {
+		t.Errorf("expected the job active, got %+v (%v)", active, err)
+	}
+}
+
+func TestQueue_RunRetriesThenFails(t *testing.T) {
+	repo := &memoryJobs{}
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	q := NewQueue(repo)
+	q.now = func() time.Time { return now }
+	calls := 0
+	q.Register("test", func(ctx context.Context, job *models.Job) error {
+		calls++
+		job.Processed = calls
+		return errors.New("provider down")
+	}, RetryPolicy{MaxAttempts: 2, Backoff: time.Minute, MaxBackoff: time.Hour})
+	ctx := context.Background()
+	if _, err := q.Enqueue(ctx, "test", "u1", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	claim := func() *models.Job {
+		job, err := repo.ClaimJob(ctx, []string{"test"}, q.now(), q.now().Add(q.Lease))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return job
+	}
+	q.run(ctx, claim())
+	job, _ := q.Job(ctx, 1)
+	if job.Status != models.JobQueued || job.Error != "provider down" || !job.RunAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("expected a retry in a minute, got %+v", job)
+	}
+	if claim() != nil {
+		t.Fatal("expected the retry to wait for its run_at")
+	}
+
+	now = now.Add(time.Minute)
+	q.run(ctx, claim())
+	job, _ = q.Job(ctx, 1)
+	if job.Status != models.JobFailed || job.Attempts != 2 || job.FinishedAt == nil || job.Processed != 2 {
+		t.Errorf("expected the job failed after 2 attempts, got %+v", job)
+	}
+}
+
+func TestQueue_RunRequeuesOnShutdown(t *testing.T) {
+	repo := &memoryJobs{}
+	q := NewQueue(repo)
+	ctx, cancel := context.WithCancel(context.Background())
+	q.Register("test", func(ctx context.Context, job *models.Job) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}, RetryPolicy{MaxAttempts: 1})
+	if _, err := q.Enqueue(ctx, "test", "", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	job, _ := repo.ClaimJob(ctx, []string{"test"}, time.Now(), time.Now())
+	q.run(ctx, job)
+	if job, _ = q.Job(context.Background(), 1); job.Status != models.JobQueued || job.Attempts != 0 {
+		t.Errorf("expected the interrupted job handed back without using its attempt, got %+v", job)
+	}
+}
+
+func TestQueue_Start(t *testing.T) {
+	repo := &memoryJobs{}
+	q := NewQueue(repo)
+	done := make(chan int64, 2)
+	q.Register("test", func(ctx context.Context, job *models.Job) error {
+		done <- job.ID
+		return nil
+	}, RetryPolicy{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	for i := 0; i < 2; i++ {
+		if _, err := q.Enqueue(ctx, "test", "u1", 0, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the enqueued jobs to run")
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if n, _, _ := q.Pending(ctx); n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the jobs completed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
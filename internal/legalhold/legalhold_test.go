@@ -0,0 +1,131 @@
+package legalhold
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+type fakeRepo struct {
+	holds  map[string]*models.LegalHold
+	events []*models.LegalHoldEvent
+	getErr error
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{holds: map[string]*models.LegalHold{}}
+}
+
+func (r *fakeRepo) Place(ctx context.Context, hold *models.LegalHold) error {
+	if hold.UserID == "unknown" {
+		return data.ErrNotFound
+	}
+	r.holds[hold.UserID] = hold
+	return nil
+}
+
+func (r *fakeRepo) Release(ctx context.Context, userID string) error {
+	if _, ok := r.holds[userID]; !ok {
+		return data.ErrNotFound
+	}
+	delete(r.holds, userID)
+	return nil
+}
+
+func (r *fakeRepo) Get(ctx context.Context, userID string) (*models.LegalHold, error) {
+	if r.getErr != nil {
+		return nil, r.getErr
+	}
+	h, ok := r.holds[userID]
+	if !ok {
+		return nil, data.ErrNotFound
+	}
+	return h, nil
+}
+
+func (r *fakeRepo) List(ctx context.Context) ([]*models.LegalHold, error) {
+	var holds []*models.LegalHold
+	for _, h := range r.holds {
+		holds = append(holds, h)
+	}
+	return holds, nil
+}
+
+func (r *fakeRepo) RecordEvent(ctx context.Context, e *models.LegalHoldEvent) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *fakeRepo) ListEvents(ctx context.Context, userID string, limit int) ([]*models.LegalHoldEvent, error) {
+	return r.events, nil
+}
+
+func TestGuard(t *testing.T) {
+	repo := newFakeRepo()
+	s := NewService(repo)
+	admin := ctxkeys.WithUserID(context.Background(), "admin-1")
+
+	if err := s.Guard(context.Background(), "u1", ActionTrash); err != nil {
+		t.Fatalf("expected no hold, got %v", err)
+	}
+	if _, err := s.Place(admin, "u1", "case 7"); err != nil {
+		t.Fatalf("Place failed: %v", err)
+	}
+	if repo.holds["u1"].PlacedBy != "admin-1" {
+		t.Errorf("expected the hold to be placed by the signed-in admin, got %+v", repo.holds["u1"])
+	}
+	if err := s.Guard(context.Background(), "u1", ActionPurgeCache); !errors.Is(err, ErrHeld) {
+		t.Errorf("expected ErrHeld, got %v", err)
+	}
+	if err := s.Guard(context.Background(), "u2", ActionTrash); err != nil {
+		t.Errorf("expected other users not to be held, got %v", err)
+	}
+	if len(repo.events) != 2 {
+		t.Fatalf("expected placed and blocked events, got %+v", repo.events)
+	}
+	blocked := repo.events[1]
+	if blocked.Event != models.LegalHoldBlocked || blocked.Action != ActionPurgeCache || blocked.Actor != SystemActor {
+		t.Errorf("unexpected blocked event %+v", blocked)
+	}
+
+	if err := s.Release(admin, "u1"); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if err := s.Guard(context.Background(), "u1", ActionTrash); err != nil {
+		t.Errorf("expected the released user to be deletable, got %v", err)
+	}
+	if err := s.Release(admin, "u1"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("expected ErrNotFound releasing twice, got %v", err)
+	}
+	if _, err := s.Place(admin, "unknown", ""); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("expected ErrNotFound holding an unknown user, got %v", err)
+	}
+}
+
+func TestGuard_FailsClosed(t *testing.T) {
+	repo := newFakeRepo()
+	repo.getErr = errors.New("db down")
+	err := NewService(repo).Guard(context.Background(), "u1", ActionTrash)
+	if err == nil || errors.Is(err, ErrHeld) {
+		t.Errorf("expected the lookup error to refuse the deletion, got %v", err)
+	}
+}
+
+func TestGuard_All(t *testing.T) {
+	s := NewService(newFakeRepo())
+	s.All = true
+	if err := s.Guard(context.Background(), "anyone", ActionOffboardPurge); !errors.Is(err, ErrHeld) {
+		t.Errorf("expected every user to be held, got %v", err)
+	}
+}
+
+func TestGuard_Nil(t *testing.T) {
+	var s *Service
+	if err := s.Guard(context.Background(), "u1", ActionTrash); err != nil {
+		t.Errorf("expected a nil Service to hold nobody, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSwitch(t *testing.T) {
+	var unset *Switch
+	if unset.Active() {
+		t.Error("expected a nil switch to be inactive")
+	}
+
+	s := New()
+	if s.Active() || s.Status().RetryAfterSeconds != 300 {
+		t.Errorf("expected maintenance off with the default hint, got %+v", s.Status())
+	}
+	st := s.Set(true, "migrating", 30*time.Second)
+	if !s.Active() || !st.Enabled || st.Message != "migrating" || st.RetryAfterSeconds != 30 || st.Since == nil {
+		t.Errorf("unexpected status %+v", st)
+	}
+	since := *st.Since
+	if again := s.Set(true, "still migrating", 0); !again.Since.Equal(since) || again.RetryAfterSeconds != 30 {
+		t.Errorf("expected staying on to keep the start time and hint, got %+v", again)
+	}
+	if st := s.Set(false, "", 0); s.Active() || st.Since != nil || st.Message != "" {
+		t.Errorf("expected maintenance off, got %+v", st)
+	}
+}
+
+func TestWait(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	if err := Wait(context.Background(), nil, nil); err != nil {
+		t.Errorf("expected a nil switch not to wait, got %v", err)
+	}
+
+	s := New()
+	s.Set(true, "", 0)
+	beats := 0
+	if err := Wait(context.Background(), s, func() {
+		if beats++; beats == 3 {
+			s.Set(false, "", 0)
+		}
+	}); err != nil || beats != 3 {
+		t.Errorf("expected to wait until maintenance ended, beating meanwhile; got %d beats (err %v)", beats, err)
+	}
+
+	s.Set(true, "", 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Wait(ctx, s, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context's error while paused, got %v", err)
+	}
+}
@@ -6,20 +6,20 @@ import (
 )
 
 type MockUserTokenRepository struct {
-	GetUserTokenFunc  func(ctx context.Context, userID string) (*oauth2.Token, error)
-	SaveUserTokenFunc func(ctx context.Context, userID string, token *oauth2.Token) error
+	GetUserTokenFunc  func(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error)
+	SaveUserTokenFunc func(ctx context.Context, userID, provider, accountID string, token *oauth2.Token) error
 }
 
-func (m *MockUserTokenRepository) GetUserToken(ctx context.Context, userID string) (*oauth2.Token, error) {
+func (m *MockUserTokenRepository) GetUserToken(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
 	if m.GetUserTokenFunc != nil {
-		return m.GetUserTokenFunc(ctx, userID)
+		return m.GetUserTokenFunc(ctx, userID, provider, accountID)
 	}
 	return &oauth2.Token{AccessToken: "mock-token"}, nil
 }
 
-func (m *MockUserTokenRepository) SaveUserToken(ctx context.Context, userID string, token *oauth2.Token) error {
+func (m *MockUserTokenRepository) SaveUserToken(ctx context.Context, userID, provider, accountID string, token *oauth2.Token) error {
 	if m.SaveUserTokenFunc != nil {
-		return m.SaveUserTokenFunc(ctx, userID, token)
+		return m.SaveUserTokenFunc(ctx, userID, provider, accountID, token)
 	}
 	return nil
 }
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// ParseAddress splits a header address such as `"Ann Lee" <Ann@Example.com>` into its
+// lower-cased address and display name, decoding encoded words in the name. A value
+// net/mail rejects is kept whole as the address, lower-cased, with no name.
+func ParseAddress(v string) (address, name string) {
+	if addr, err := mail.ParseAddress(v); err == nil {
+		return strings.ToLower(addr.Address), addr.Name
+	}
+	return strings.ToLower(strings.TrimSpace(v)), ""
+}
+
+// EmailAddress is one parsed entry of an address header
+type EmailAddress struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address"`
+}
+
+// ParseAddressList parses an address header such as To or Cc, lower-casing the addresses.
+// An empty or malformed header yields nil.
+func ParseAddressList(v string) []EmailAddress {
+	if strings.TrimSpace(v) == "" {
+		return nil
+	}
+	list, err := mail.ParseAddressList(v)
+	if err != nil {
+		return nil
+	}
+	out := make([]EmailAddress, len(list))
+	for i, a := range list {
+		out[i] = EmailAddress{Name: a.Name, Address: strings.ToLower(a.Address)}
+	}
+	return out
+}
+
+// ParseSender fills SenderAddress and SenderName from Sender
+func (m *EmailMessage) ParseSender() {
+	m.SenderAddress, m.SenderName = ParseAddress(m.Sender)
+}
+
+// ParseRecipients fills Cc, Bcc and ReplyTo from the message headers
+func (m *EmailMessage) ParseRecipients() {
+	m.Cc = ParseAddressList(m.Header("Cc"))
+	m.Bcc = ParseAddressList(m.Header("Bcc"))
+	m.ReplyTo = ParseAddressList(m.Header("Reply-To"))
+}
+
+// ReplyRecipients returns who a reply to the message goes to: the Reply-To addresses when the
+// sender set them, otherwise the sender
+func (m *EmailMessage) ReplyRecipients() []EmailAddress {
+	if len(m.ReplyTo) > 0 {
+		return m.ReplyTo
+	}
+	address, name := m.SenderAddress, m.SenderName
+	if address == "" {
+		address, name = ParseAddress(m.Sender)
+	}
+	if address == "" {
+		return nil
+	}
+	return []EmailAddress{{Name: name, Address: address}}
+}
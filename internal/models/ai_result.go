@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// AI result kinds
+const (
+	AIResultSummary  = "summary"
+	AIResultCategory = "category"
+)
+
+// AIResult is a cached AI output for a message, valid while the message content hash matches
+type AIResult struct {
+	UserID      string
+	MessageID   string
+	Kind        string
+	ContentHash string
+	Result      string
+	Confidence  float64
+	Source      string
+	CreatedAt   time.Time
+}
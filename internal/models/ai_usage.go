@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// AIUsageWindow is token usage against a budget over one period
+type AIUsageWindow struct {
+	Used int `json:"used"`
+	// Limit is the token budget for the period; 0 means unlimited
+	Limit    int       `json:"limit"`
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+// AIUsage is a user's LLM token usage for the current day and month
+type AIUsage struct {
+	Daily     AIUsageWindow `json:"daily"`
+	Monthly   AIUsageWindow `json:"monthly"`
+	Exhausted bool          `json:"exhausted"`
+}
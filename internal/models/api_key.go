@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// APIKey lets a CLI or script call the API as a user without a browser session. Only a hash
+// of the key is stored; Prefix is kept so users can tell their keys apart.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Device code states
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+)
+
+// DeviceCode is a pending device authorization: a CLI polls with the device code while the
+// user approves the user code in the browser
+type DeviceCode struct {
+	UserCode string
+	Status   string
+	// UserID is set once the code is approved
+	UserID    string
+	ExpiresAt time.Time
+	// LastPolledAt is the poll before the current one, nil on the first poll
+	LastPolledAt *time.Time
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Bulk actions
+const (
+	BulkArchive      = "archive"
+	BulkDelete       = "delete"
+	BulkMarkRead     = "mark_read"
+	BulkRecategorize = "recategorize"
+)
+
+// BulkActions lists the actions a bulk job can apply
+var BulkActions = []string{BulkArchive, BulkDelete, BulkMarkRead, BulkRecategorize}
+
+// BulkFilter selects the cached messages a bulk job acts on. Empty fields match every message.
+type BulkFilter struct {
+	// Sender is a lower-cased sender address
+	Sender string `json:"sender,omitempty"`
+	// Category matches the assigned category or, for messages without one, the provider's
+	Category string `json:"category,omitempty"`
+	// Before matches messages that arrived before this internal date, in milliseconds
+	Before int64 `json:"before,omitempty"`
+}
+
+// BulkJob applies one action to every cached message of a user matching a filter
+type BulkJob struct {
+	ID     int64  `json:"id"`
+	UserID string `json:"-"`
+	// AccountID is the linked account the job acts through; empty for the default account
+	AccountID string     `json:"account_id,omitempty"`
+	Action    string     `json:"action"`
+	Filter    BulkFilter `json:"filter"`
+	// ToCategory is the category BulkRecategorize assigns
+	ToCategory string     `json:"to_category,omitempty"`
+	Status     string     `json:"status"`
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Failed     int        `json:"failed"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// QueueJobID is the job queue's job running this one (see Job)
+	QueueJobID int64 `json:"queue_job_id,omitempty"`
+}
+
+// Finished reports whether the job has stopped running
+func (j *BulkJob) Finished() bool {
+	return j.Status == JobCompleted || j.Status == JobFailed
+}
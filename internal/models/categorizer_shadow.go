@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// ShadowResult pairs the active categorizer's category for a message with the one a candidate
+// categorizer running in shadow mode chose. Shadow categories are never shown to users.
+type ShadowResult struct {
+	UserID         string
+	MessageID      string
+	ActiveVersion  string
+	ShadowVersion  string
+	ActiveCategory string
+	ShadowCategory string
+	CreatedAt      time.Time
+}
+
+// ShadowAgreement is how often a shadow categorizer agreed with the active one
+type ShadowAgreement struct {
+	ShadowVersion string                    `json:"shadow_version"`
+	ActiveVersion string                    `json:"active_version"`
+	Total         int                       `json:"total"`
+	Agreed        int                       `json:"agreed"`
+	AgreementRate float64                   `json:"agreement_rate"`
+	ByCategory    []ShadowCategoryAgreement `json:"by_category"`
+}
+
+// ShadowCategoryAgreement is the agreement for messages the active categorizer put in Category
+type ShadowCategoryAgreement struct {
+	Category      string  `json:"category"`
+	Total         int     `json:"total"`
+	Agreed        int     `json:"agreed"`
+	AgreementRate float64 `json:"agreement_rate"`
+}
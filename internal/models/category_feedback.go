@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// CategoryFeedback is a user's confirmation or correction of a message's category
+type CategoryFeedback struct {
+	ID        int64  `json:"id"`
+	UserID    string `json:"-"`
+	MessageID string `json:"message_id"`
+	// Sender is the normalized sender address of the message
+	Sender           string    `json:"sender"`
+	PreviousCategory string    `json:"previous_category"`
+	Category         string    `json:"category"`
+	Correct          bool      `json:"correct"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// CategoryFeedbackResult is returned after feedback is recorded
+type CategoryFeedbackResult struct {
+	Feedback *CategoryFeedback `json:"feedback"`
+	// SuggestedRule is an unsaved rule proposed from repeated corrections; create it via POST /api/rules to accept
+	SuggestedRule *Rule `json:"suggested_rule,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ClientState is a JSON document the frontend keeps under a key for the user, synced across
+// their devices. Version starts at 1 and goes up with every write.
+type ClientState struct {
+	UserID    string          `json:"-"`
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	Version   int64           `json:"version"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// ContactStats aggregates a user's conversations with one correspondent
+type ContactStats struct {
+	UserID  string `json:"-"`
+	Address string `json:"address"` // normalized email address
+	Name    string `json:"name,omitempty"`
+	// MessagesReceived counts messages from the contact; Threads counts conversations they took part in
+	MessagesReceived int `json:"messages_received"`
+	Threads          int `json:"threads"`
+	// UserReplies is how often the user answered the contact, taking AvgUserReplySeconds on average
+	UserReplies         int   `json:"user_replies"`
+	AvgUserReplySeconds int64 `json:"avg_user_reply_seconds"`
+	// ContactReplies is how often the contact answered the user, taking AvgContactReplySeconds on average
+	ContactReplies         int        `json:"contact_replies"`
+	AvgContactReplySeconds int64      `json:"avg_contact_reply_seconds"`
+	LastMessageAt          *time.Time `json:"last_message_at,omitempty"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+}
+
+// ThreadParticipant is one correspondent in a conversation
+type ThreadParticipant struct {
+	Address  string `json:"address"`
+	Name     string `json:"name,omitempty"`
+	Messages int    `json:"messages"` // messages sent by the participant in the thread
+	IsUser   bool   `json:"is_user"`
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"database/sql"
 	"encoding/json"
+	"strings"
 	"time"
 )
 
@@ -13,17 +14,73 @@ type EmailMessage struct {
 	EmailMessageID           string // Unified message ID (was GmailMessageID)
 	ThreadID                 string
 	Subject                  string
-	Sender                   string
+	Sender                   string // Raw From header
+	SenderAddress            string // Lower-cased address parsed from Sender at sync time (see ParseSender)
+	SenderName               string // Display name parsed from Sender
 	Recipient                string
+	Cc                       []EmailAddress // Parsed from headers, see ParseRecipients
+	Bcc                      []EmailAddress // Only present on mail the user sent
+	ReplyTo                  []EmailAddress
+	ListUnsubscribe          *ListUnsubscribe // Parsed at sync time, see ParseListUnsubscribe; nil when not offered
 	Snippet                  string
 	Body                     string // Plain text email body
 	HTMLBody                 string // HTML part of email, if present
 	InternalDate             int64
+	SizeEstimate             int64 // Provider's estimate of the message size in bytes; 0 if unknown
+	HasAttachments           bool
 	Date                     string // RFC 2822/3339 date string (provider-agnostic)
 	HistoryID                int64
 	CachedAt                 time.Time
 	LastFetchedAt            sql.NullTime
 	Category                 sql.NullString
 	CategorizationConfidence sql.NullFloat64
-	RawJSON                  json.RawMessage
+	// ProviderCategory is the provider's own classification (e.g. Gmail's category tabs) mapped onto
+	// Whisperer category names; empty when the provider gave none. Kept apart from Category so the two
+	// can be compared.
+	ProviderCategory string
+	// ProviderImportant is the provider's importance marker (Gmail's IMPORTANT label)
+	ProviderImportant bool
+	// IsRead is the provider's read state (Gmail: no UNREAD label)
+	IsRead bool
+	// ContentHash fingerprints the provider's copy of the message's headers and bodies; empty for
+	// rows cached before change tracking
+	ContentHash string
+	// LabelHash fingerprints the provider's labels, kept apart from ContentHash so label changes
+	// are reported as updates without re-categorizing; empty for rows not hashed yet
+	LabelHash string
+	// ChangedAt is when a re-sync last found the provider's copy changed
+	ChangedAt sql.NullTime
+	// Headers are the provider's message headers, stored apart from RawJSON so they survive when
+	// raw payloads are not kept; nil for rows cached before they were stored
+	Headers []MessageHeader
+	// RawJSON is the provider's full payload; nil when raw payloads are not kept
+	RawJSON json.RawMessage
+}
+
+// MessageHeader is a message header as the provider sent it
+type MessageHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Header returns the first value of the named header, matched case-insensitively, or "" if
+// absent. Messages cached before headers were stored apart fall back to their raw Gmail payload.
+func (m *EmailMessage) Header(name string) string {
+	headers := m.Headers
+	if headers == nil && len(m.RawJSON) > 0 {
+		var raw struct {
+			Payload struct {
+				Headers []MessageHeader `json:"headers"`
+			} `json:"payload"`
+		}
+		if err := json.Unmarshal(m.RawJSON, &raw); err == nil {
+			headers = raw.Payload.Headers
+		}
+	}
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
 }
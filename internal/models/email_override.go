@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// EmailUpdate is a user's change to one message; nil fields are left unchanged. Labels are
+// added to the message's existing labels and RemoveLabels taken off it.
+type EmailUpdate struct {
+	Labels       []string `json:"labels,omitempty"`
+	RemoveLabels []string `json:"remove_labels,omitempty"`
+	Category     *string  `json:"category,omitempty"`
+	Starred      *bool    `json:"starred,omitempty"`
+}
+
+// EmailUserOverride is everything the user has changed about a message. Category and Starred
+// are nil when the user has not overridden them.
+type EmailUserOverride struct {
+	UserID         string    `json:"-"`
+	EmailMessageID string    `json:"id"`
+	Labels         []string  `json:"labels"`
+	Category       *string   `json:"category,omitempty"`
+	Starred        *bool     `json:"starred,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	// ProviderSynced names the fields of the latest update that were also applied at the
+	// provider; the rest are only stored here
+	ProviderSynced []string `json:"provider_synced"`
+}
@@ -3,12 +3,52 @@ package models
 // EmailSummary is a provider-agnostic summary DTO for list endpoints
 // (subject, sender, snippet, date, etc.)
 type EmailSummary struct {
-	ID           string
-	ThreadID     string
-	Subject      string
-	Sender       string
-	Snippet      string
-	InternalDate int64
-	Date         string
-	Provider     string
+	ID            string
+	ThreadID      string
+	Subject       string
+	Sender        string
+	SenderAddress string // Parsed from Sender, see EmailMessage.ParseSender
+	SenderName    string
+	Snippet       string
+	InternalDate  int64
+	// SizeEstimate and HasAttachments are as on EmailMessage
+	SizeEstimate   int64
+	HasAttachments bool
+	Date           string
+	Provider       string
+	// Category is the Whisperer category; ProviderCategory and ProviderImportant are the
+	// provider's own signals (see EmailMessage)
+	Category          string
+	ProviderCategory  string
+	ProviderImportant bool
+	IsRead            bool
+}
+
+// SummaryPage is one page of summaries listed straight from a provider
+type SummaryPage struct {
+	Summaries []EmailSummary
+	// NextPageToken is the provider's token for the following page; empty on the last page
+	NextPageToken string
+}
+
+// Summary returns the summary of a cached message; Provider is left for the caller to set
+func (m *EmailMessage) Summary() EmailSummary {
+	return EmailSummary{
+		ID:             m.EmailMessageID,
+		ThreadID:       m.ThreadID,
+		Snippet:        m.Snippet,
+		Sender:         m.Sender,
+		SenderAddress:  m.SenderAddress,
+		SenderName:     m.SenderName,
+		Subject:        m.Subject,
+		InternalDate:   m.InternalDate,
+		SizeEstimate:   m.SizeEstimate,
+		HasAttachments: m.HasAttachments,
+		Date:           m.Date,
+
+		Category:          m.Category.String,
+		ProviderCategory:  m.ProviderCategory,
+		ProviderImportant: m.ProviderImportant,
+		IsRead:            m.IsRead,
+	}
 }
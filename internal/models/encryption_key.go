@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// EncryptionKey is the stored half of a user's cache encryption key (see package e2ee). The
+// private key is derived from the user's passphrase and is never stored.
+type EncryptionKey struct {
+	UserID    string    `json:"-"`
+	Salt      []byte    `json:"-"`
+	PublicKey []byte    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Sync stages at which a message can fail
+const (
+	SyncStageFetch  = "fetch"
+	SyncStageUpsert = "upsert"
+)
+
+// FailedSyncItem records a message that could not be fetched or cached during sync
+type FailedSyncItem struct {
+	ID             int64     `json:"-"`
+	UserID         string    `json:"-"`
+	EmailMessageID string    `json:"email_message_id"`
+	Stage          string    `json:"stage"`
+	LastError      string    `json:"last_error"`
+	Attempts       int       `json:"attempts"`
+	FirstFailedAt  time.Time `json:"first_failed_at"`
+	LastFailedAt   time.Time `json:"last_failed_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Feed is an Atom feed of the messages a user received from one sender (see package feeds)
+type Feed struct {
+	ID            int64  `json:"id"`
+	UserID        string `json:"-"`
+	SenderAddress string `json:"sender_address"`
+	// Content is the rendered Atom document; nil until the feed is first generated
+	Content     []byte     `json:"-"`
+	GeneratedAt *time.Time `json:"generated_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// GmailWatch is the push notification watch registered for a user's Gmail account
+type GmailWatch struct {
+	UserID string `json:"-"`
+	// AccountID is the Gmail address the watch is on; pushes name it
+	AccountID string `json:"account_id"`
+	// HistoryID is the mailbox history ID when the watch was registered
+	HistoryID int64 `json:"history_id"`
+	// ExpiresAt is when Gmail stops sending pushes; nil until a registration succeeded
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// RenewAfter is when the watch is registered again
+	RenewAfter time.Time `json:"renew_after"`
+	// Error is why the last registration failed; empty when it succeeded
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IMAPAccount is a mailbox on another host linked over IMAP with a username and password (see
+// package imap). The password is never returned by the API.
+type IMAPAccount struct {
+	UserID string `json:"-"`
+	Host   string `json:"host"`
+	Port   int    `json:"port"`
+	// TLS connects with implicit TLS (usually port 993); otherwise the connection is upgraded
+	// with STARTTLS (usually port 143). Mail is never read over a plaintext connection.
+	TLS       bool      `json:"tls"`
+	Username  string    `json:"username"`
+	Password  string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Delivery statuses; a delivery being retried is queued again until it runs out of attempts
+const (
+	DeliveryQueued    = "queued"
+	DeliveryRunning   = "running"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Integration is a user's configuration for one send-to integration (see package integrations).
+// Config holds credentials and is never returned by the API.
+type Integration struct {
+	UserID    string            `json:"-"`
+	Name      string            `json:"integration"`
+	Config    map[string]string `json:"-"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// IntegrationItem is a message formatted for delivery to an integration
+type IntegrationItem struct {
+	MessageID  string    `json:"message_id"`
+	Title      string    `json:"title"`
+	Author     string    `json:"author"`
+	AuthorAddr string    `json:"author_address"`
+	URL        string    `json:"url"`
+	Text       string    `json:"text"`
+	HTML       string    `json:"html,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// IntegrationDelivery tracks sending one message to one integration
+type IntegrationDelivery struct {
+	ID          int64  `json:"id"`
+	UserID      string `json:"-"`
+	MessageID   string `json:"message_id"`
+	Integration string `json:"integration"`
+	Status      string `json:"status"`
+	Attempts    int    `json:"attempts"`
+	Error       string `json:"error,omitempty"`
+	// ExternalURL links to what the integration created, when it returns a link
+	ExternalURL string `json:"external_url,omitempty"`
+	// Item is the content to deliver; nil once the delivery has finished
+	Item          *IntegrationItem `json:"-"`
+	NextAttemptAt time.Time        `json:"next_attempt_at"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+	DeliveredAt   *time.Time       `json:"delivered_at,omitempty"`
+}
+
+// Finished reports whether the delivery will not be attempted again
+func (d *IntegrationDelivery) Finished() bool {
+	return d.Status == DeliveryDelivered || d.Status == DeliveryFailed
+}
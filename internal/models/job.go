@@ -0,0 +1,45 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job kinds run by the job queue
+const (
+	JobKindBulk            = "bulk"
+	JobKindRecategorize    = "recategorize"
+	JobKindMailboxBackfill = "mailbox_backfill"
+)
+
+// Job is a unit of background work in the job queue (see package jobs)
+type Job struct {
+	ID     int64  `json:"id"`
+	Kind   string `json:"kind"`
+	UserID string `json:"-"`
+	// RefID is the kind's own record of the work, e.g. the BulkJob for JobKindBulk; 0 if none
+	RefID int64 `json:"ref_id,omitempty"`
+	// Payload holds the kind's parameters
+	Payload json.RawMessage `json:"-"`
+	Status  string          `json:"status"`
+	// Attempts counts the runs so far, including the current one
+	Attempts    int `json:"attempts"`
+	MaxAttempts int `json:"max_attempts"`
+	Total       int `json:"total"`
+	Processed   int `json:"processed"`
+	Failed      int `json:"failed"`
+	// Checkpoint is where a retried or interrupted job resumes, in a form of the kind's choosing
+	Checkpoint string `json:"-"`
+	// Error is the last run's error; it is kept while a retry is pending
+	Error string `json:"error,omitempty"`
+	// RunAt is when a queued job becomes due, later than CreatedAt while a retry is pending
+	RunAt      time.Time  `json:"run_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job has stopped for good
+func (j *Job) Finished() bool {
+	return j.Status == JobCompleted || j.Status == JobFailed
+}
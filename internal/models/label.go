@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Label types: system labels are owned by the provider and cannot be edited
+const (
+	LabelTypeSystem = "system"
+	LabelTypeUser   = "user"
+)
+
+// Label is a cached provider label (Gmail label, Outlook category, etc.)
+type Label struct {
+	ID                    int64     `json:"-"`
+	UserID                string    `json:"-"`
+	ProviderLabelID       string    `json:"id"`
+	Name                  string    `json:"name"`
+	Type                  string    `json:"type"`
+	BackgroundColor       string    `json:"background_color,omitempty"`
+	TextColor             string    `json:"text_color,omitempty"`
+	MessageListVisibility string    `json:"message_list_visibility,omitempty"` // show or hide
+	LabelListVisibility   string    `json:"label_list_visibility,omitempty"`   // labelShow, labelShowIfUnread or labelHide
+	CachedAt              time.Time `json:"cached_at"`
+}
+
+// LabelUpdate holds the user-editable label fields; nil fields are left unchanged
+type LabelUpdate struct {
+	Name            *string `json:"name,omitempty"`
+	BackgroundColor *string `json:"background_color,omitempty"`
+	TextColor       *string `json:"text_color,omitempty"`
+}
+
+// LabelCreate holds the fields for a new user label; colors are optional
+type LabelCreate struct {
+	Name            string `json:"name"`
+	BackgroundColor string `json:"background_color,omitempty"`
+	TextColor       string `json:"text_color,omitempty"`
+}
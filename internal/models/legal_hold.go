@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// LegalHold keeps a user's cached mail from being deleted or pruned until it is released
+type LegalHold struct {
+	UserID   string    `json:"user_id"`
+	Reason   string    `json:"reason"`
+	PlacedBy string    `json:"placed_by"`
+	PlacedAt time.Time `json:"placed_at"`
+}
+
+// Legal hold events
+const (
+	LegalHoldPlaced   = "placed"
+	LegalHoldReleased = "released"
+	// LegalHoldBlocked records a deletion refused because of a hold
+	LegalHoldBlocked = "blocked"
+)
+
+// LegalHoldEvent is an entry in the audit trail of a user's legal holds
+type LegalHoldEvent struct {
+	ID     int64  `json:"id"`
+	UserID string `json:"user_id"`
+	Event  string `json:"event"`
+	// Action is the deletion that was blocked, for blocked events
+	Action    string    `json:"action,omitempty"`
+	Actor     string    `json:"actor"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
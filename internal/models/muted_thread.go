@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// MutedThread is a conversation whose current and future messages are kept out of the inbox
+type MutedThread struct {
+	UserID    string    `json:"-"`
+	ThreadID  string    `json:"thread_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
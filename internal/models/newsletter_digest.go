@@ -0,0 +1,32 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// DigestMessagePrefix starts the IDs of the "Today's newsletters" messages the server writes
+// into the cache. They exist at no provider, so actions on them are carried out locally.
+const DigestMessagePrefix = "whisperer-digest-"
+
+// DigestMessageID is the ID of the user's digest message for the local day
+func DigestMessageID(day time.Time) string {
+	return DigestMessagePrefix + day.Format(time.DateOnly)
+}
+
+// IsDigestMessage reports whether id is a server-generated digest message
+func IsDigestMessage(id string) bool {
+	return strings.HasPrefix(id, DigestMessagePrefix)
+}
+
+// DigestItem is a message archived into a day's newsletter digest
+type DigestItem struct {
+	UserID         string
+	EmailMessageID string
+	// Day is the user's local day the digest is for, at midnight UTC
+	Day        time.Time
+	Subject    string
+	Sender     string
+	Snippet    string
+	ArchivedAt time.Time
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// OAuthClientCredentials are an OAuth app's client credentials as rotated through the admin
+// API, together with the credentials they replaced. Secrets are never returned by the API.
+type OAuthClientCredentials struct {
+	Provider     string `json:"provider"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"-"`
+	// PreviousClientID and PreviousClientSecret are still tried, after the current ones, until
+	// PreviousValidUntil
+	PreviousClientID     string     `json:"previous_client_id,omitempty"`
+	PreviousClientSecret string     `json:"-"`
+	PreviousValidUntil   *time.Time `json:"previous_valid_until,omitempty"`
+	RotatedBy            string     `json:"rotated_by"`
+	RotatedAt            time.Time  `json:"rotated_at"`
+}
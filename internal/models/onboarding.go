@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// OnboardingStep is the state of a single onboarding step
+type OnboardingStep struct {
+	Name        string     `json:"name"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// OnboardingStatus is a user's progress through guided setup
+type OnboardingStatus struct {
+	Steps []OnboardingStep `json:"steps"`
+	// CurrentStep is the first incomplete step, empty once onboarding is complete
+	CurrentStep string `json:"current_step,omitempty"`
+	Complete    bool   `json:"complete"`
+}
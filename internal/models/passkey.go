@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Passkey is a WebAuthn credential a user registered to confirm sensitive operations.
+// CredentialID is the authenticator's credential ID, base64url-encoded without padding.
+type Passkey struct {
+	ID           int64  `json:"id"`
+	UserID       string `json:"-"`
+	CredentialID string `json:"credential_id"`
+	// PublicKey is the DER-encoded SubjectPublicKeyInfo of the credential
+	PublicKey []byte `json:"-"`
+	// Algorithm is the COSE algorithm identifier of PublicKey (-7 ES256, -8 EdDSA, -257 RS256)
+	Algorithm  int        `json:"algorithm"`
+	SignCount  uint32     `json:"-"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Job statuses, shared by the job queue and the records of the kinds it runs
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// RecategorizeJob re-runs categorization over cached messages for one user, or all users when
+// UserID is empty
+type RecategorizeJob struct {
+	ID          int64  `json:"id"`
+	UserID      string `json:"user_id,omitempty"`
+	RequestedBy string `json:"-"`
+	Status      string `json:"status"`
+	Total       int    `json:"total"`
+	Processed   int    `json:"processed"`
+	Failed      int    `json:"failed"`
+	// AIMessages is how many messages belong to users whose categorization may use an external LLM
+	AIMessages int `json:"ai_messages"`
+	// EstimatedTokens is the LLM token estimate for AIMessages, taken when the job was enqueued
+	EstimatedTokens int        `json:"estimated_tokens"`
+	Error           string     `json:"error,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	// QueueJobID is the job queue's job running this one (see Job)
+	QueueJobID int64 `json:"queue_job_id,omitempty"`
+}
+
+// Finished reports whether the job has stopped running
+func (j *RecategorizeJob) Finished() bool {
+	return j.Status == JobCompleted || j.Status == JobFailed
+}
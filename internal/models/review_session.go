@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// ReviewSession is a guided triage pass over a user's oldest unhandled messages
+type ReviewSession struct {
+	ID     int64  `json:"id"`
+	UserID string `json:"-"`
+	// Total is the number of messages queued, Reviewed how many have been decided
+	Total       int        `json:"total"`
+	Reviewed    int        `json:"reviewed"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// Review decisions that leave the message at the provider as it is
+const (
+	ReviewKeep = "keep"
+	// ReviewSkip leaves the message undecided, so a later session queues it again
+	ReviewSkip = "skip"
+)
+
+// ReviewItem is a message queued in a review session
+type ReviewItem struct {
+	Position       int    `json:"position"`
+	EmailMessageID string `json:"message_id"`
+	// Action is the decision taken on the message; empty while it is pending
+	Action  string     `json:"action,omitempty"`
+	ActedAt *time.Time `json:"acted_at,omitempty"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Rule condition fields
+const (
+	RuleFieldFrom     = "from"
+	RuleFieldSubject  = "subject"
+	RuleFieldSnippet  = "snippet"
+	RuleFieldCategory = "category"
+)
+
+// Rule action types
+const (
+	RuleActionApplyLabel  = "apply_label"
+	RuleActionSetCategory = "set_category"
+	RuleActionArchive     = "archive"
+	RuleActionMarkRead    = "mark_read"
+)
+
+// RuleCondition matches when Field contains the given text (case-insensitive)
+type RuleCondition struct {
+	Field    string `json:"field"`
+	Contains string `json:"contains"`
+}
+
+// RuleAction is performed on every message a rule matches
+type RuleAction struct {
+	Type string `json:"type"`
+	// Label is the label name for apply_label; it is created at the provider if missing
+	Label string `json:"label,omitempty"`
+	// Category is the category name for set_category
+	Category string `json:"category,omitempty"`
+}
+
+// Rule is a user-defined automation; a message matches when all conditions match
+type Rule struct {
+	ID         int64           `json:"id"`
+	UserID     string          `json:"-"`
+	Name       string          `json:"name"`
+	Conditions []RuleCondition `json:"conditions"`
+	Actions    []RuleAction    `json:"actions"`
+	Enabled    bool            `json:"enabled"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Manual actions observed for rule suggestions
+const (
+	UserActionArchive  = "archive"
+	UserActionMarkRead = "mark_read"
+)
+
+// UserAction is a manual action the user took on a message
+type UserAction struct {
+	UserID    string
+	Action    string
+	MessageID string
+	Sender    string // normalized sender address
+	Category  string
+	CreatedAt time.Time
+}
+
+// ActionCount is how often an action (or correction) was repeated for a sender and/or category
+type ActionCount struct {
+	Sender   string
+	Category string
+	Count    int
+}
+
+// Rule suggestion statuses
+const (
+	SuggestionPending   = "pending"
+	SuggestionAccepted  = "accepted"
+	SuggestionDismissed = "dismissed"
+)
+
+// RuleSuggestion is a rule proposed from the user's repeated behavior
+type RuleSuggestion struct {
+	ID     int64  `json:"id"`
+	UserID string `json:"-"`
+	// Key identifies what is being proposed so decided suggestions are not offered again
+	Key      string `json:"-"`
+	Rule     Rule   `json:"rule"`
+	Reason   string `json:"reason"`
+	Evidence int    `json:"evidence"`
+	Status   string `json:"status"`
+	// RuleID is the rule created when the suggestion was accepted
+	RuleID    *int64    `json:"rule_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// SyncRun summarizes one provider sync run for a user
+type SyncRun struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"user_id"`
+	Provider  string    `json:"provider"`
+	StartedAt time.Time `json:"started_at"`
+	// DurationMS is how long the run took, in milliseconds
+	DurationMS int64 `json:"duration_ms"`
+	// Listed counts the messages the provider listed, Fetched those fetched, Upserted those
+	// written to the cache and Skipped those the provider no longer has, which are removed from
+	// the cache
+	Listed   int `json:"listed"`
+	Fetched  int `json:"fetched"`
+	Upserted int `json:"upserted"`
+	Skipped  int `json:"skipped"`
+	// Errors counts messages that failed and were dead-lettered
+	Errors int `json:"errors"`
+	// QuotaHits counts provider calls refused for rate or quota limits
+	QuotaHits int `json:"quota_hits"`
+	// Error is why the run stopped early; empty when it completed
+	Error string `json:"error,omitempty"`
+}
+
+// SyncRunStats aggregates the sync runs of one provider
+type SyncRunStats struct {
+	Provider      string  `json:"provider"`
+	Runs          int     `json:"runs"`
+	FailedRuns    int     `json:"failed_runs"`
+	Users         int     `json:"users"`
+	Listed        int     `json:"listed"`
+	Fetched       int     `json:"fetched"`
+	Upserted      int     `json:"upserted"`
+	Skipped       int     `json:"skipped"`
+	Errors        int     `json:"errors"`
+	QuotaHits     int     `json:"quota_hits"`
+	AvgDurationMS float64 `json:"avg_duration_ms"`
+	MaxDurationMS int64   `json:"max_duration_ms"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// SyncSchedule is a user's background sync tier and any admin override
+type SyncSchedule struct {
+	UserID string `json:"user_id"`
+	// Tier is empty when the user is on the deploy's default tier
+	Tier string `json:"tier"`
+	// IntervalSeconds overrides the tier's interval when set; 0 means manual-only
+	IntervalSeconds *int       `json:"interval_seconds"`
+	LastSyncedAt    *time.Time `json:"last_synced_at,omitempty"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
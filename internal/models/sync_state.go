@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// SyncState is a user's provider sync cursor, committed together with each synced page
+type SyncState struct {
+	UserID string
+	// PageToken is where an interrupted sync resumes; empty means start from the newest messages
+	PageToken string
+	// HistoryID is the highest provider history ID committed
+	HistoryID int64
+	UpdatedAt time.Time
+}
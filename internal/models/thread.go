@@ -0,0 +1,35 @@
+package models
+
+// ThreadSummary describes one conversation in the thread list
+type ThreadSummary struct {
+	ThreadID string `json:"id"`
+	// Subject is the subject of the first message
+	Subject string `json:"subject"`
+	// Participants are the senders' addresses in the order they joined the conversation
+	Participants       []string `json:"participants"`
+	LatestSnippet      string   `json:"latest_snippet"`
+	LatestInternalDate int64    `json:"latest_internal_date"`
+	MessageCount       int      `json:"message_count"`
+	UnreadCount        int      `json:"unread_count"`
+}
+
+// ThreadMessage is one message of a thread. Body and HTMLBody are only set when the thread is
+// fetched in full from the provider.
+type ThreadMessage struct {
+	ID            string `json:"id"`
+	Subject       string `json:"subject"`
+	Sender        string `json:"sender"`
+	SenderAddress string `json:"sender_address"`
+	SenderName    string `json:"sender_name,omitempty"`
+	Snippet       string `json:"snippet"`
+	InternalDate  int64  `json:"internal_date"`
+	IsRead        bool   `json:"is_read"`
+	Body          string `json:"body,omitempty"`
+	HTMLBody      string `json:"html_body,omitempty"`
+}
+
+// Thread is a conversation with its cached messages, oldest first
+type Thread struct {
+	ThreadSummary
+	Messages []ThreadMessage `json:"messages"`
+}
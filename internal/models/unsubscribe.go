@@ -0,0 +1,78 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ListUnsubscribe is how a message's sender takes unsubscribe requests (RFC 2369 and RFC 8058)
+type ListUnsubscribe struct {
+	// Mailto is the mailto: URI to send the request to
+	Mailto string `json:"mailto,omitempty"`
+	// URL is the https URI to open, or to POST to when OneClick is set
+	URL string `json:"url,omitempty"`
+	// OneClick is set when List-Unsubscribe-Post offers the one-click POST to URL
+	OneClick bool `json:"one_click,omitempty"`
+}
+
+// ParseListUnsubscribe reads the values of the List-Unsubscribe and List-Unsubscribe-Post
+// headers. It keeps the first mailto: and https: URI; plain http and other schemes are
+// ignored. It returns nil when neither is offered.
+func ParseListUnsubscribe(header, post string) *ListUnsubscribe {
+	var l ListUnsubscribe
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if !strings.HasPrefix(part, "<") || !strings.HasSuffix(part, ">") {
+			continue
+		}
+		raw := strings.TrimSpace(part[1 : len(part)-1])
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(u.Scheme) {
+		case "mailto":
+			if l.Mailto == "" && u.Opaque != "" {
+				l.Mailto = raw
+			}
+		case "https":
+			if l.URL == "" && u.Host != "" {
+				l.URL = raw
+			}
+		}
+	}
+	if l.Mailto == "" && l.URL == "" {
+		return nil
+	}
+	l.OneClick = l.URL != "" && strings.EqualFold(strings.TrimSpace(post), "List-Unsubscribe=One-Click")
+	return &l
+}
+
+// ParseListUnsubscribe fills ListUnsubscribe from the message headers
+func (m *EmailMessage) ParseListUnsubscribe() {
+	m.ListUnsubscribe = ParseListUnsubscribe(m.Header("List-Unsubscribe"), m.Header("List-Unsubscribe-Post"))
+}
+
+// Unsubscribe methods
+const (
+	UnsubscribeOneClick = "one_click"
+	UnsubscribeMailto   = "mailto"
+)
+
+// Unsubscribe outcomes
+const (
+	UnsubscribeSucceeded = "succeeded"
+	UnsubscribeFailed    = "failed"
+)
+
+// Unsubscribe is the outcome of the latest unsubscribe from a sender
+type Unsubscribe struct {
+	UserID         string    `json:"-"`
+	SenderAddress  string    `json:"sender_address"`
+	EmailMessageID string    `json:"email_message_id"`
+	Method         string    `json:"method"`
+	Status         string    `json:"status"`
+	Detail         string    `json:"detail,omitempty"`
+	AttemptedAt    time.Time `json:"attempted_at"`
+}
@@ -11,4 +11,17 @@ type User struct {
 	Email       string    `json:"email"`
 	CreatedAt   time.Time `json:"created_at"`
 	Deactivated bool      `json:"deactivated"`
+	// Role is RoleUser or RoleAdmin
+	Role string `json:"role,omitempty"`
+}
+
+// User roles; admins may call the /api/admin endpoints
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// ValidRole reports whether role is one of the roles above
+func ValidRole(role string) bool {
+	return role == RoleUser || role == RoleAdmin
 }
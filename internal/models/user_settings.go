@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// UserSettings holds per-user preferences; the zero value is the default for new users
+type UserSettings struct {
+	UserID string `json:"-"`
+	// AIDataSharing allows message content to be sent to external LLM providers
+	AIDataSharing bool `json:"ai_data_sharing"`
+	// DisableLocalCache keeps the user's messages out of the database: lists are proxied to the
+	// provider page by page and background syncs are skipped
+	DisableLocalCache bool `json:"disable_local_cache"`
+	// MetadataOnlyCache caches headers and snippets only; bodies and raw payloads are fetched
+	// from the provider on every read and never stored
+	MetadataOnlyCache bool `json:"metadata_only_cache"`
+	// Timezone is an IANA zone name (e.g. "Europe/Berlin") for the working hours; empty is UTC
+	Timezone string `json:"timezone"`
+	// WorkingHoursStart and WorkingHoursEnd ("HH:MM") bound when digests and notifications are
+	// delivered; both empty means any time. See scheduler.DeliveryWindow.
+	WorkingHoursStart string `json:"working_hours_start"`
+	WorkingHoursEnd   string `json:"working_hours_end"`
+	// WeekendPause defers deliveries falling on a Saturday or Sunday to the next weekday
+	WeekendPause bool `json:"weekend_pause"`
+	// DigestCategories are the categories whose messages are archived during the day and
+	// gathered into a single "Today's newsletters" message; empty turns the digest off
+	DigestCategories []string `json:"digest_categories"`
+	// EncryptedCache is set while the user has a cache encryption key; it is read-only here and
+	// changed through the encryption endpoints. Cached bodies are sealed and AI features are off.
+	EncryptedCache bool      `json:"encrypted_cache"`
+	UpdatedAt      time.Time `json:"updated_at,omitempty"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// InboxSizePoint is the average inbox size over one week
+type InboxSizePoint struct {
+	WeekStart   time.Time `json:"week_start"`
+	AverageSize float64   `json:"average_size"`
+}
+
+// UserStats summarizes a user's triage activity over the last 7 days
+type UserStats struct {
+	Since                 time.Time        `json:"since"`
+	MessagesProcessed     int              `json:"messages_processed"`
+	Archived              int              `json:"archived"`
+	Unsubscribes          int              `json:"unsubscribes"`
+	InboxSizeTrend        []InboxSizePoint `json:"inbox_size_trend"`
+	EstimatedMinutesSaved float64          `json:"estimated_minutes_saved"`
+}
+
+// SenderStat is how much mail one sender sent the user over a period
+type SenderStat struct {
+	Address string `json:"address"`
+	Name    string `json:"name,omitempty"`
+	// Messages counts the sender's cached messages, Unread those still unread
+	Messages    int     `json:"messages"`
+	Unread      int     `json:"unread"`
+	UnreadRatio float64 `json:"unread_ratio"`
+	// LatestInternalDate is when the sender's latest message arrived, in milliseconds
+	LatestInternalDate int64 `json:"latest_internal_date"`
+}
+
+// VolumePoint is the mail the user received on one day
+type VolumePoint struct {
+	// Day is the date in the user's timezone, as YYYY-MM-DD
+	Day      string `json:"day"`
+	Messages int    `json:"messages"`
+	Unread   int    `json:"unread"`
+}
+
+// InboxVolume is the user's daily mail volume over a period, one point per day
+type InboxVolume struct {
+	Timezone string        `json:"timezone"`
+	Days     []VolumePoint `json:"days"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// Ways a weekly report can be sent
+const (
+	// ReportViaAccount sends from the user's own linked Gmail account to itself
+	ReportViaAccount = "account"
+	// ReportViaSMTP sends through the deployment's mail server
+	ReportViaSMTP = "smtp"
+)
+
+// Weekly report sections
+const (
+	ReportSectionProcessed    = "processed"
+	ReportSectionArchived     = "archived"
+	ReportSectionUnsubscribes = "unsubscribes"
+	ReportSectionTimeSaved    = "time_saved"
+	ReportSectionInboxTrend   = "inbox_trend"
+)
+
+// ReportSections lists every section in the order a report without chosen sections uses
+var ReportSections = []string{ReportSectionProcessed, ReportSectionArchived, ReportSectionUnsubscribes,
+	ReportSectionTimeSaved, ReportSectionInboxTrend}
+
+// ReportSettings is a user's opt-in to the weekly report email; the zero value (besides Via and
+// Weekday) is the default for users who never saved any
+type ReportSettings struct {
+	UserID  string `json:"-"`
+	Enabled bool   `json:"enabled"`
+	// Via is ReportViaAccount or ReportViaSMTP
+	Via string `json:"via"`
+	// Weekday is the day the report goes out (0 is Sunday), at the start of the user's
+	// delivery window
+	Weekday int `json:"weekday"`
+	// Subject and Intro replace the default subject line and opening paragraph when set; both
+	// may use the placeholders of reports.Compose
+	Subject string `json:"subject"`
+	Intro   string `json:"intro"`
+	// Sections picks and orders the report sections; empty means all of ReportSections
+	Sections   []string   `json:"sections"`
+	NextDueAt  *time.Time `json:"next_due_at,omitempty"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at,omitempty"`
+}
+
+// Report delivery statuses
+const (
+	ReportSent   = "sent"
+	ReportFailed = "failed"
+)
+
+// ReportDelivery records one attempt to send a weekly report
+type ReportDelivery struct {
+	ID          int64     `json:"id"`
+	UserID      string    `json:"-"`
+	Via         string    `json:"via"`
+	Recipient   string    `json:"recipient"`
+	Subject     string    `json:"subject"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	PeriodStart time.Time `json:"period_start"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"context"
+	"sync"
+)
+
+// Events published by services so other subsystems can react without direct dependencies
+const (
+	EventAccountLinked  = "account_linked"  // a provider account was authorized
+	EventSyncComplete   = "sync_complete"   // a provider sync run finished
+	EventMessageUpdated = "message_updated" // a cached message changed on the provider; see MessageID
+)
+
+type messageIDKey struct{}
+
+// WithMessageID attaches the message an event is about to ctx
+func WithMessageID(ctx context.Context, messageID string) context.Context {
+	return context.WithValue(ctx, messageIDKey{}, messageID)
+}
+
+// MessageID returns the message an event is about, or "" for account-wide events
+func MessageID(ctx context.Context) string {
+	id, _ := ctx.Value(messageIDKey{}).(string)
+	return id
+}
+
+// Handler reacts to an event for a user. Handlers run synchronously in the publisher's goroutine.
+type Handler func(ctx context.Context, userID string)
+
+var subscribers = struct {
+	m        sync.RWMutex
+	handlers map[string]map[int]Handler
+	nextID   int
+}{handlers: make(map[string]map[int]Handler)}
+
+// Subscribe registers a handler for an event and returns a function that removes it
+func Subscribe(event string, h Handler) (unsubscribe func()) {
+	subscribers.m.Lock()
+	defer subscribers.m.Unlock()
+	if subscribers.handlers[event] == nil {
+		subscribers.handlers[event] = make(map[int]Handler)
+	}
+	id := subscribers.nextID
+	subscribers.nextID++
+	subscribers.handlers[event][id] = h
+	return func() {
+		subscribers.m.Lock()
+		defer subscribers.m.Unlock()
+		delete(subscribers.handlers[event], id)
+	}
+}
+
+// Publish calls every handler subscribed to the event
+func Publish(ctx context.Context, event, userID string) {
+	subscribers.m.RLock()
+	handlers := make([]Handler, 0, len(subscribers.handlers[event]))
+	for _, h := range subscribers.handlers[event] {
+		handlers = append(handlers, h)
+	}
+	subscribers.m.RUnlock()
+	for _, h := range handlers {
+		h(ctx, userID)
+	}
+}
@@ -0,0 +1,195 @@
+// Package oauthclient holds the OAuth app credentials (client ID and secret) the server signs
+// users in and refreshes their tokens with. Admins can rotate them at runtime; the new
+// credentials are stored encrypted and picked up by every instance, and the old ones stay in
+// use as a fallback for a grace period so refreshes that still need them keep working.
+package oauthclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+// MaxGracePeriod bounds how long replaced credentials stay usable
+const MaxGracePeriod = 7 * 24 * time.Hour
+
+// DefaultGracePeriod applies when a rotation does not give one
+const DefaultGracePeriod = 24 * time.Hour
+
+// ErrInvalidCredentials is returned for a rotation with a missing client ID or secret, or a
+// grace period out of range
+var ErrInvalidCredentials = errors.New("invalid oauth client credentials")
+
+// Store serves the current credentials of one provider's OAuth app
+type Store struct {
+	provider string
+	repo     data.OAuthClientRepository
+	// base carries the endpoint, redirect URL and scopes, and the configured credentials used
+	// until some are rotated in
+	base oauth2.Config
+
+	mu     sync.RWMutex
+	stored *models.OAuthClientCredentials
+	now    func() time.Time
+}
+
+// NewStore creates a Store for provider's OAuth app, starting from the configured base
+func NewStore(provider string, base oauth2.Config, repo data.OAuthClientRepository) *Store {
+	return &Store{provider: provider, base: base, repo: repo, now: time.Now}
+}
+
+// Load reads the stored credentials; without any, the configured ones stay in use
+func (s *Store) Load(ctx context.Context) error {
+	c, err := s.repo.Get(ctx, s.provider)
+	if errors.Is(err, data.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("oauthclient: load %s credentials: %w", s.provider, err)
+	}
+	s.mu.Lock()
+	s.stored = c
+	s.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the stored credentials every interval until ctx is cancelled, so rotations
+// made through another instance are picked up. A failed load keeps the current credentials.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Load(ctx); err != nil {
+					log.Error().Err(err).Msg("oauthclient: reload failed")
+				}
+			}
+		}
+	}()
+}
+
+// Config returns the OAuth config with the current credentials
+func (s *Store) Config() *oauth2.Config {
+	return s.configs()[0]
+}
+
+// configs returns the config with the current credentials, followed by the one with the
+// previous credentials while they are within their grace period
+func (s *Store) configs() []*oauth2.Config {
+	s.mu.RLock()
+	stored := s.stored
+	s.mu.RUnlock()
+	cur := s.base
+	if stored == nil {
+		return []*oauth2.Config{&cur}
+	}
+	cur.ClientID, cur.ClientSecret = stored.ClientID, stored.ClientSecret
+	out := []*oauth2.Config{&cur}
+	if stored.PreviousClientID != "" && stored.PreviousValidUntil != nil && s.now().Before(*stored.PreviousValidUntil) {
+		prev := s.base
+		prev.ClientID, prev.ClientSecret = stored.PreviousClientID, stored.PreviousClientSecret
+		out = append(out, &prev)
+	}
+	return out
+}
+
+// Status returns the current credentials without their secrets. Provider is empty while the
+// configured credentials are in use.
+func (s *Store) Status() *models.OAuthClientCredentials {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.stored == nil {
+		return &models.OAuthClientCredentials{ClientID: s.base.ClientID}
+	}
+	status := *s.stored
+	return &status
+}
+
+// Rotate stores new credentials and switches to them. The credentials they replace stay usable
+// as a fallback for grace (DefaultGracePeriod when 0); a negative grace drops them at once.
+func (s *Store) Rotate(ctx context.Context, clientID, clientSecret string, grace time.Duration, rotatedBy string) (*models.OAuthClientCredentials, error) {
+	clientID, clientSecret = strings.TrimSpace(clientID), strings.TrimSpace(clientSecret)
+	if clientID == "" || clientSecret == "" {
+		return nil, fmt.Errorf("%w: client_id and client_secret are required", ErrInvalidCredentials)
+	}
+	if grace > MaxGracePeriod {
+		return nil, fmt.Errorf("%w: the grace period is at most %s", ErrInvalidCredentials, MaxGracePeriod)
+	}
+	if grace == 0 {
+		grace = DefaultGracePeriod
+	}
+	cur := s.Config()
+	next := &models.OAuthClientCredentials{Provider: s.provider, ClientID: clientID, ClientSecret: clientSecret, RotatedBy: rotatedBy}
+	if grace > 0 && (cur.ClientID != clientID || cur.ClientSecret != clientSecret) {
+		until := s.now().Add(grace).UTC()
+		next.PreviousClientID, next.PreviousClientSecret, next.PreviousValidUntil = cur.ClientID, cur.ClientSecret, &until
+	}
+	if err := s.repo.Save(ctx, next); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.stored = next
+	s.mu.Unlock()
+	log.Info().Str("provider", s.provider).Str("client_id", clientID).Str("rotated_by", rotatedBy).Msg("oauthclient: credentials rotated")
+	status := *next
+	return &status, nil
+}
+
+// Exchange trades an authorization code for a token, falling back to the previous credentials
+// when the provider rejects the current ones
+func (s *Store) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	var err error
+	for _, cfg := range s.configs() {
+		var tok *oauth2.Token
+		if tok, err = cfg.Exchange(ctx, code, opts...); err == nil || !isClientRejected(err) {
+			return tok, err
+		}
+	}
+	return nil, err
+}
+
+// TokenSource returns a source that serves tok until it expires and then refreshes it, falling
+// back to the previous credentials when the provider rejects the current ones
+func (s *Store) TokenSource(ctx context.Context, tok *oauth2.Token) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(tok, &refresher{ctx: ctx, store: s, refreshToken: tok.RefreshToken})
+}
+
+type refresher struct {
+	ctx          context.Context
+	store        *Store
+	refreshToken string
+}
+
+func (r *refresher) Token() (*oauth2.Token, error) {
+	if r.refreshToken == "" {
+		return nil, errors.New("oauthclient: token expired and has no refresh token")
+	}
+	var err error
+	for _, cfg := range r.store.configs() {
+		var tok *oauth2.Token
+		tok, err = cfg.TokenSource(r.ctx, &oauth2.Token{RefreshToken: r.refreshToken}).Token()
+		if err == nil || !isClientRejected(err) {
+			return tok, err
+		}
+	}
+	return nil, err
+}
+
+// isClientRejected reports whether the provider refused the client credentials themselves,
+// rather than the code or token they were sent with
+func isClientRejected(err error) bool {
+	var re *oauth2.RetrieveError
+	return errors.As(err, &re) && (re.ErrorCode == "invalid_client" || re.ErrorCode == "unauthorized_client")
+}
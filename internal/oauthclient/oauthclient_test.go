@@ -0,0 +1,126 @@
+package oauthclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"golang.org/x/oauth2"
+)
+
+type fakeRepo struct {
+	saved *models.OAuthClientCredentials
+}
+
+func (f *fakeRepo) Save(ctx context.Context, c *models.OAuthClientCredentials) error {
+	cp := *c
+	f.saved = &cp
+	return nil
+}
+
+func (f *fakeRepo) Get(ctx context.Context, provider string) (*models.OAuthClientCredentials, error) {
+	if f.saved == nil {
+		return nil, data.ErrNotFound
+	}
+	cp := *f.saved
+	return &cp, nil
+}
+
+// tokenServer issues tokens only to the clients in secrets, answering others with invalid_client
+func tokenServer(t *testing.T, secrets map[string]string) (*httptest.Server, *[]string) {
+	var tried []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		tried = append(tried, id)
+		w.Header().Set("Content-Type", "application/json")
+		if secrets[id] == "" || secrets[id] != secret {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "issued-to-" + id, "token_type": "Bearer", "expires_in": 3600})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &tried
+}
+
+func newTestStore(srv *httptest.Server, repo *fakeRepo) *Store {
+	base := oauth2.Config{ClientID: "old", ClientSecret: "old-secret",
+		Endpoint: oauth2.Endpoint{TokenURL: srv.URL, AuthStyle: oauth2.AuthStyleInHeader}}
+	return NewStore("google", base, repo)
+}
+
+func TestStore_RotateFallsBackDuringGrace(t *testing.T) {
+	ctx := context.Background()
+	// The provider has not accepted the new secret yet
+	srv, tried := tokenServer(t, map[string]string{"old": "old-secret"})
+	repo := &fakeRepo{}
+	s := newTestStore(srv, repo)
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	rotated, err := s.Rotate(ctx, "new", "new-secret", time.Hour, "admin")
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if rotated.PreviousClientID != "old" || !rotated.PreviousValidUntil.Equal(now.Add(time.Hour)) || repo.saved.ClientSecret != "new-secret" {
+		t.Errorf("unexpected rotation: %+v", rotated)
+	}
+	if s.Config().ClientID != "new" {
+		t.Errorf("expected the new client to be current, got %s", s.Config().ClientID)
+	}
+
+	tok, err := s.TokenSource(ctx, &oauth2.Token{AccessToken: "expired", RefreshToken: "r", Expiry: now.Add(-time.Minute)}).Token()
+	if err != nil || tok.AccessToken != "issued-to-old" {
+		t.Fatalf("expected a refresh with the previous credentials, got %v (err %v)", tok, err)
+	}
+	if len(*tried) != 2 || (*tried)[0] != "new" {
+		t.Errorf("expected the new credentials to be tried first, got %v", *tried)
+	}
+	if _, err := s.Exchange(ctx, "code"); err != nil {
+		t.Errorf("expected the exchange to fall back, got %v", err)
+	}
+
+	// Once the grace period is over only the new credentials are tried
+	now = now.Add(2 * time.Hour)
+	*tried = nil
+	_, err = s.TokenSource(ctx, &oauth2.Token{RefreshToken: "r", Expiry: now.Add(-time.Minute)}).Token()
+	var re *oauth2.RetrieveError
+	if !errors.As(err, &re) || len(*tried) != 1 {
+		t.Errorf("expected the refresh to fail with the new credentials only, got %v after %v", err, *tried)
+	}
+}
+
+func TestStore_LoadAndValidation(t *testing.T) {
+	ctx := context.Background()
+	srv, _ := tokenServer(t, nil)
+	repo := &fakeRepo{}
+	s := newTestStore(srv, repo)
+	if err := s.Load(ctx); err != nil || s.Config().ClientID != "old" || s.Status().Provider != "" {
+		t.Fatalf("expected the configured credentials without stored ones, got %+v (err %v)", s.Status(), err)
+	}
+	if _, err := s.Rotate(ctx, "new", "secret", MaxGracePeriod+time.Hour, "admin"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected a grace period over the maximum to be rejected, got %v", err)
+	}
+	if _, err := s.Rotate(ctx, "new", " ", 0, "admin"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected a blank secret to be rejected, got %v", err)
+	}
+	if _, err := s.Rotate(ctx, "new", "secret", -1, "admin"); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if repo.saved.PreviousClientID != "" {
+		t.Errorf("expected a negative grace to drop the previous credentials, got %+v", repo.saved)
+	}
+
+	// Another instance picks up the rotation on its next load
+	other := newTestStore(srv, repo)
+	if err := other.Load(ctx); err != nil || other.Config().ClientID != "new" || other.Config().ClientSecret != "secret" {
+		t.Errorf("expected the stored credentials after Load, got %+v (err %v)", other.Config(), err)
+	}
+}
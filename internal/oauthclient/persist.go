@@ -0,0 +1,72 @@
+package oauthclient
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/metrics"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+// TokenSourcer builds token sources that refresh a token once it expires; *Store and
+// *oauth2.Config both are
+type TokenSourcer interface {
+	TokenSource(ctx context.Context, tok *oauth2.Token) oauth2.TokenSource
+}
+
+// Persisting refreshes expired tokens through Base and writes each refreshed token back, so
+// later requests and background syncs start from it instead of refreshing again. The token
+// is stored for the user in the context (see ctxkeys.UserID) on the account holding the same
+// refresh token. A failed refresh is logged and counted (see metrics.ObserveTokenRefresh);
+// when the provider revoked the grant the error is returned as is, so callers ask the user
+// to sign in again.
+type Persisting struct {
+	Base     TokenSourcer
+	Tokens   data.TokenRefreshRepository
+	Provider string
+}
+
+// TokenSource returns a source that serves tok until it expires and then refreshes and stores it
+func (p *Persisting) TokenSource(ctx context.Context, tok *oauth2.Token) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(tok, &persistingSource{ctx: ctx, p: p, refreshToken: tok.RefreshToken})
+}
+
+type persistingSource struct {
+	ctx          context.Context
+	p            *Persisting
+	refreshToken string
+}
+
+func (s *persistingSource) Token() (*oauth2.Token, error) {
+	userID := ctxkeys.UserID(s.ctx)
+	tok, err := s.p.Base.TokenSource(s.ctx, &oauth2.Token{RefreshToken: s.refreshToken}).Token()
+	if err != nil {
+		result := "error"
+		if IsGrantRevoked(err) {
+			result = "revoked"
+		}
+		metrics.ObserveTokenRefresh(s.p.Provider, result)
+		log.Warn().Err(err).Str("user_id", userID).Str("provider", s.p.Provider).Str("result", result).Msg("token refresh failed")
+		return nil, err
+	}
+	metrics.ObserveTokenRefresh(s.p.Provider, "ok")
+	if userID == "" {
+		log.Warn().Str("provider", s.p.Provider).Msg("refreshed token not stored: no user in context")
+		return tok, nil
+	}
+	// A failed write only costs another refresh next time, so the request goes ahead
+	if err := s.p.Tokens.UpdateRefreshedToken(s.ctx, userID, s.p.Provider, s.refreshToken, tok); err != nil {
+		log.Warn().Err(err).Str("user_id", userID).Str("provider", s.p.Provider).Msg("failed to store refreshed token")
+	}
+	return tok, nil
+}
+
+// IsGrantRevoked reports whether a refresh failed because the refresh token is no longer
+// valid (revoked, expired or the user changed their password), so only signing in again helps
+func IsGrantRevoked(err error) bool {
+	var re *oauth2.RetrieveError
+	return errors.As(err, &re) && re.ErrorCode == "invalid_grant"
+}
@@ -0,0 +1,82 @@
+package oauthclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/metrics"
+	"golang.org/x/oauth2"
+)
+
+type fakeTokens struct {
+	userID, refreshToken string
+	saved                *oauth2.Token
+}
+
+func (f *fakeTokens) UpdateRefreshedToken(ctx context.Context, userID, provider, refreshToken string, tok *oauth2.Token) error {
+	if provider != data.ProviderGmail {
+		return data.ErrNotFound
+	}
+	f.userID, f.refreshToken, f.saved = userID, refreshToken, tok
+	return nil
+}
+
+func TestPersisting_StoresRefreshedToken(t *testing.T) {
+	// The provider answers "revoked" to the refresh token "gone" and issues a token otherwise
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		if r.Form.Get("refresh_token") == "gone" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "fresh", "token_type": "Bearer", "expires_in": 3600})
+	}))
+	defer srv.Close()
+	tokens := &fakeTokens{}
+	p := &Persisting{
+		Base:     &oauth2.Config{ClientID: "id", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: srv.URL}},
+		Tokens:   tokens,
+		Provider: data.ProviderGmail,
+	}
+	ctx := ctxkeys.WithUserID(context.Background(), "u1")
+	expired := time.Now().Add(-time.Minute)
+
+	// A valid token is served as it is
+	tok, err := p.TokenSource(ctx, &oauth2.Token{AccessToken: "current", RefreshToken: "r", Expiry: time.Now().Add(time.Hour)}).Token()
+	if err != nil || tok.AccessToken != "current" || tokens.saved != nil {
+		t.Fatalf("expected the current token without a refresh, got %v (err %v, saved %v)", tok, err, tokens.saved)
+	}
+
+	tok, err = p.TokenSource(ctx, &oauth2.Token{AccessToken: "stale", RefreshToken: "r", Expiry: expired}).Token()
+	if err != nil || tok.AccessToken != "fresh" {
+		t.Fatalf("expected a refreshed token, got %v (err %v)", tok, err)
+	}
+	if tokens.saved == nil || tokens.saved.AccessToken != "fresh" || tokens.saved.RefreshToken != "r" || tokens.userID != "u1" || tokens.refreshToken != "r" {
+		t.Errorf("expected the refreshed token to be stored for u1 under r, got %+v for %q/%q", tokens.saved, tokens.userID, tokens.refreshToken)
+	}
+
+	_, err = p.TokenSource(ctx, &oauth2.Token{AccessToken: "stale", RefreshToken: "gone", Expiry: expired}).Token()
+	if !IsGrantRevoked(err) {
+		t.Errorf("expected a revoked grant, got %v", err)
+	}
+
+	var out strings.Builder
+	metrics.Write(&out)
+	for _, want := range []string{
+		`inbox_whisperer_token_refreshes_total{provider="gmail",result="ok"} 1`,
+		`inbox_whisperer_token_refreshes_total{provider="gmail",result="revoked"} 1`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, out.String())
+		}
+	}
+}
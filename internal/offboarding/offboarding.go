@@ -0,0 +1,237 @@
+// Package offboarding removes users' data when they leave an organisation: it revokes their
+// stored credentials, purges their cached mail, exports their audit records and deactivates
+// them, then hands back a signed report of what was done so the deletion can be evidenced later.
+package offboarding
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/legalhold"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxUsers bounds how many users one run offboards
+const MaxUsers = 500
+
+// SignatureAlgorithm names how reports are signed
+const SignatureAlgorithm = "HMAC-SHA256"
+
+// MinSigningKeyLength is the shortest signing key accepted, in bytes
+const MinSigningKeyLength = 32
+
+// Outcomes of offboarding one user
+const (
+	StatusOffboarded = "offboarded"
+	StatusNotFound   = "not_found"
+	// StatusFailed means a step failed; the steps before it were carried out and the run can
+	// be repeated, as every step is idempotent
+	StatusFailed = "failed"
+)
+
+// Users looks users up and deactivates them (see service.UserService)
+type Users interface {
+	GetUser(ctx context.Context, id string) (*models.User, error)
+	DeactivateUser(ctx context.Context, id string) error
+}
+
+// Messages purges a user's cached mail
+type Messages interface {
+	DeleteMessagesForUser(ctx context.Context, userID string) error
+}
+
+// APIKeys lists and revokes a user's API keys
+type APIKeys interface {
+	ListByUser(ctx context.Context, userID string) ([]*models.APIKey, error)
+	Revoke(ctx context.Context, userID string, id int64) error
+}
+
+// IMAPAccounts removes a user's stored IMAP credentials
+type IMAPAccounts interface {
+	Delete(ctx context.Context, userID string) error
+}
+
+// AuditLog lists a user's sync runs, the record of when their mail was read
+type AuditLog interface {
+	ListSyncRuns(ctx context.Context, userID string, since time.Time, limit int) ([]models.SyncRun, error)
+}
+
+// maxAuditRecords bounds the sync runs exported per user
+const maxAuditRecords = 10000
+
+// UserReport is what was done for one user
+type UserReport struct {
+	UserID string `json:"user_id"`
+	Status string `json:"status"`
+	// TokensRevoked counts the OAuth tokens deleted, one per linked account
+	TokensRevoked      int64 `json:"tokens_revoked"`
+	APIKeysRevoked     int   `json:"api_keys_revoked"`
+	IMAPAccountRemoved bool  `json:"imap_account_removed"`
+	MessagesPurged     bool  `json:"messages_purged"`
+	Deactivated        bool  `json:"deactivated"`
+	// LegalHold means the user's messages were kept because the user is under legal hold; the
+	// other steps were carried out
+	LegalHold bool `json:"legal_hold,omitempty"`
+	// AuditRecords are the user's sync runs, exported before the user is deactivated
+	AuditRecords []models.SyncRun `json:"audit_records"`
+	Error        string           `json:"error,omitempty"`
+}
+
+// Residency records where the offboarded data was stored, for deployments pinned to a region
+type Residency struct {
+	Region string `json:"region"`
+	Bucket string `json:"bucket,omitempty"`
+}
+
+// Report covers one offboarding run
+type Report struct {
+	GeneratedAt time.Time    `json:"generated_at"`
+	RequestedBy string       `json:"requested_by"`
+	Residency   *Residency   `json:"residency,omitempty"`
+	Users       []UserReport `json:"users"`
+}
+
+// SignedReport is a Report with a signature over its JSON encoding. Verify checks it.
+type SignedReport struct {
+	Report    Report `json:"report"`
+	Algorithm string `json:"algorithm"`
+	// Signature is the base64 HMAC of the report's JSON encoding under the signing key
+	Signature string `json:"signature"`
+}
+
+// Service offboards users
+type Service struct {
+	Users    Users
+	Tokens   data.TokenPurgeRepository
+	Messages Messages
+	APIKeys  APIKeys
+	// IMAP is optional; it is nil when IMAP accounts are not enabled
+	IMAP  IMAPAccounts
+	Audit AuditLog
+	// Residency, if set, is recorded in every report
+	Residency *Residency
+	// Holds keeps the messages of users under legal hold
+	Holds *legalhold.Service
+
+	key []byte
+	now func() time.Time
+}
+
+// NewService creates a Service that signs its reports with key
+func NewService(key []byte, users Users, tokens data.TokenPurgeRepository, messages Messages, apiKeys APIKeys, audit AuditLog) *Service {
+	return &Service{Users: users, Tokens: tokens, Messages: messages, APIKeys: apiKeys, Audit: audit, key: key, now: time.Now}
+}
+
+// Offboard offboards each user in turn and returns the signed report. A user whose offboarding
+// fails is reported as failed and does not stop the others.
+func (s *Service) Offboard(ctx context.Context, requestedBy string, userIDs []string) (*SignedReport, error) {
+	if len(userIDs) > MaxUsers {
+		return nil, fmt.Errorf("offboarding: at most %d users per run", MaxUsers)
+	}
+	report := Report{GeneratedAt: s.now().UTC(), RequestedBy: requestedBy, Residency: s.Residency, Users: make([]UserReport, 0, len(userIDs))}
+	for _, id := range userIDs {
+		ur := s.offboardUser(ctx, id)
+		if ur.Error != "" {
+			log.Error().Str("userID", id).Str("error", ur.Error).Msg("offboarding: user failed")
+		} else {
+			log.Info().Str("userID", id).Str("status", ur.Status).Str("requested_by", requestedBy).Msg("offboarding: user done")
+		}
+		report.Users = append(report.Users, ur)
+	}
+	return s.sign(report)
+}
+
+func (s *Service) offboardUser(ctx context.Context, userID string) UserReport {
+	ur := UserReport{UserID: userID, Status: StatusFailed, AuditRecords: []models.SyncRun{}}
+	fail := func(step string, err error) UserReport {
+		ur.Error = step + ": " + err.Error()
+		return ur
+	}
+	if _, err := s.Users.GetUser(ctx, userID); err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			ur.Status = StatusNotFound
+			return ur
+		}
+		return fail("load user", err)
+	}
+	// Export first, so the records survive whatever a later step does
+	runs, err := s.Audit.ListSyncRuns(ctx, userID, time.Time{}, maxAuditRecords)
+	if err != nil {
+		return fail("export audit records", err)
+	}
+	ur.AuditRecords = runs
+	if ur.TokensRevoked, err = s.Tokens.DeleteUserTokens(ctx, userID); err != nil {
+		return fail("revoke tokens", err)
+	}
+	keys, err := s.APIKeys.ListByUser(ctx, userID)
+	if err != nil {
+		return fail("list api keys", err)
+	}
+	for _, k := range keys {
+		if err := s.APIKeys.Revoke(ctx, userID, k.ID); err != nil && !errors.Is(err, data.ErrNotFound) {
+			return fail("revoke api keys", err)
+		}
+		ur.APIKeysRevoked++
+	}
+	if s.IMAP != nil {
+		if err := s.IMAP.Delete(ctx, userID); err != nil && !errors.Is(err, data.ErrNotFound) {
+			return fail("remove imap account", err)
+		}
+		ur.IMAPAccountRemoved = true
+	}
+	switch err := s.Holds.Guard(ctx, userID, legalhold.ActionOffboardPurge); {
+	case errors.Is(err, legalhold.ErrHeld):
+		ur.LegalHold = true
+	case err != nil:
+		return fail("check legal hold", err)
+	default:
+		if err := s.Messages.DeleteMessagesForUser(ctx, userID); err != nil {
+			return fail("purge messages", err)
+		}
+		ur.MessagesPurged = true
+	}
+	if err := s.Users.DeactivateUser(ctx, userID); err != nil {
+		return fail("deactivate user", err)
+	}
+	ur.Deactivated = true
+	ur.Status = StatusOffboarded
+	return ur
+}
+
+func (s *Service) sign(report Report) (*SignedReport, error) {
+	sig, err := signature(s.key, report)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedReport{Report: report, Algorithm: SignatureAlgorithm, Signature: sig}, nil
+}
+
+func signature(key []byte, report Report) (string, error) {
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return "", fmt.Errorf("offboarding: encode report: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Verify reports whether the signature of r was made with key over its report
+func Verify(key []byte, r *SignedReport) bool {
+	if r.Algorithm != SignatureAlgorithm {
+		return false
+	}
+	want, err := signature(key, r.Report)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(want), []byte(r.Signature))
+}
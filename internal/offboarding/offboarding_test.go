@@ -0,0 +1,201 @@
+package offboarding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/legalhold"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+type fakeStore struct {
+	users       map[string]bool
+	deactivated []string
+	tokens      map[string]int64
+	keys        map[string][]*models.APIKey
+	revoked     []int64
+	imap        []string
+	purged      []string
+	purgeErr    error
+	runs        map[string][]models.SyncRun
+}
+
+func (f *fakeStore) GetUser(ctx context.Context, id string) (*models.User, error) {
+	if !f.users[id] {
+		return nil, data.ErrNotFound
+	}
+	return &models.User{ID: id}, nil
+}
+func (f *fakeStore) DeactivateUser(ctx context.Context, id string) error {
+	f.deactivated = append(f.deactivated, id)
+	return nil
+}
+func (f *fakeStore) DeleteUserTokens(ctx context.Context, userID string) (int64, error) {
+	n := f.tokens[userID]
+	delete(f.tokens, userID)
+	return n, nil
+}
+func (f *fakeStore) DeleteMessagesForUser(ctx context.Context, userID string) error {
+	if f.purgeErr != nil {
+		return f.purgeErr
+	}
+	f.purged = append(f.purged, userID)
+	return nil
+}
+func (f *fakeStore) ListByUser(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	return f.keys[userID], nil
+}
+func (f *fakeStore) Revoke(ctx context.Context, userID string, id int64) error {
+	f.revoked = append(f.revoked, id)
+	return nil
+}
+func (f *fakeStore) Delete(ctx context.Context, userID string) error {
+	f.imap = append(f.imap, userID)
+	return data.ErrNotFound
+}
+func (f *fakeStore) ListSyncRuns(ctx context.Context, userID string, since time.Time, limit int) ([]models.SyncRun, error) {
+	return f.runs[userID], nil
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		users:  map[string]bool{"u1": true, "u2": true},
+		tokens: map[string]int64{"u1": 2},
+		keys:   map[string][]*models.APIKey{"u1": {{ID: 7}, {ID: 8}}},
+		runs: map[string][]models.SyncRun{
+			"u1": {{ID: 1, UserID: "u1", Provider: "gmail", StartedAt: time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC), Upserted: 40}},
+		},
+	}
+}
+
+func newTestService(f *fakeStore) *Service {
+	s := NewService([]byte("signing-key"), f, f, f, f, f)
+	s.IMAP = f
+	s.now = func() time.Time { return time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC) }
+	return s
+}
+
+func TestService_Offboard(t *testing.T) {
+	f := newFakeStore()
+	signed, err := newTestService(f).Offboard(context.Background(), "admin", []string{"u1", "ghost"})
+	if err != nil {
+		t.Fatalf("Offboard failed: %v", err)
+	}
+	if len(signed.Report.Users) != 2 {
+		t.Fatalf("expected a report per user, got %+v", signed.Report.Users)
+	}
+	u1 := signed.Report.Users[0]
+	if u1.Status != StatusOffboarded || u1.TokensRevoked != 2 || u1.APIKeysRevoked != 2 || !u1.IMAPAccountRemoved ||
+		!u1.MessagesPurged || !u1.Deactivated || len(u1.AuditRecords) != 1 {
+		t.Errorf("unexpected report for u1: %+v", u1)
+	}
+	if len(f.tokens) != 0 || len(f.revoked) != 2 || len(f.purged) != 1 || len(f.deactivated) != 1 {
+		t.Errorf("unexpected side effects: %+v", f)
+	}
+	if ghost := signed.Report.Users[1]; ghost.Status != StatusNotFound || ghost.MessagesPurged {
+		t.Errorf("unexpected report for an unknown user: %+v", ghost)
+	}
+	if signed.Report.RequestedBy != "admin" || signed.Algorithm != SignatureAlgorithm {
+		t.Errorf("unexpected report header: %+v", signed)
+	}
+}
+
+func TestService_OffboardContinuesAfterFailure(t *testing.T) {
+	f := newFakeStore()
+	f.purgeErr = errors.New("db down")
+	signed, err := newTestService(f).Offboard(context.Background(), "admin", []string{"u1", "u2"})
+	if err != nil {
+		t.Fatalf("Offboard failed: %v", err)
+	}
+	for _, ur := range signed.Report.Users {
+		if ur.Status != StatusFailed || ur.Error != "purge messages: db down" || ur.Deactivated {
+			t.Errorf("unexpected report for %s: %+v", ur.UserID, ur)
+		}
+	}
+	// Credentials are revoked before the purge fails
+	if signed.Report.Users[0].TokensRevoked != 2 {
+		t.Errorf("expected tokens revoked before the failure, got %+v", signed.Report.Users[0])
+	}
+}
+
+// heldRepo holds every user
+type heldRepo struct {
+	data.LegalHoldRepository
+	events []*models.LegalHoldEvent
+}
+
+func (r *heldRepo) Get(ctx context.Context, userID string) (*models.LegalHold, error) {
+	return &models.LegalHold{UserID: userID}, nil
+}
+
+func (r *heldRepo) RecordEvent(ctx context.Context, e *models.LegalHoldEvent) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+func TestService_OffboardKeepsHeldMessages(t *testing.T) {
+	f := newFakeStore()
+	holds := &heldRepo{}
+	s := newTestService(f)
+	s.Holds = legalhold.NewService(holds)
+	signed, err := s.Offboard(context.Background(), "admin", []string{"u1"})
+	if err != nil {
+		t.Fatalf("Offboard failed: %v", err)
+	}
+	u1 := signed.Report.Users[0]
+	if u1.Status != StatusOffboarded || !u1.LegalHold || u1.MessagesPurged || !u1.Deactivated || u1.TokensRevoked != 2 {
+		t.Errorf("unexpected report for a held user: %+v", u1)
+	}
+	if len(f.purged) != 0 {
+		t.Errorf("expected held messages to be kept, purged %v", f.purged)
+	}
+	if len(holds.events) != 1 || holds.events[0].Action != legalhold.ActionOffboardPurge {
+		t.Errorf("expected the blocked purge to be recorded, got %+v", holds.events)
+	}
+}
+
+func TestService_OffboardRejectsLargeRuns(t *testing.T) {
+	if _, err := newTestService(newFakeStore()).Offboard(context.Background(), "admin", make([]string, MaxUsers+1)); err == nil {
+		t.Error("expected an error above MaxUsers")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	s := newTestService(newFakeStore())
+	s.Residency = &Residency{Region: "eu", Bucket: "iw-exports-eu"}
+	signed, err := s.Offboard(context.Background(), "admin", []string{"u1"})
+	if err != nil {
+		t.Fatalf("Offboard failed: %v", err)
+	}
+	// The signature survives the round trip through JSON that an archived report takes
+	raw, err := json.Marshal(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored SignedReport
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if !Verify([]byte("signing-key"), &stored) {
+		t.Error("expected the stored report to verify")
+	}
+	if Verify([]byte("other-key"), &stored) {
+		t.Error("expected a different key to fail verification")
+	}
+	if stored.Report.Residency == nil || stored.Report.Residency.Region != "eu" {
+		t.Errorf("expected the residency to be recorded, got %+v", stored.Report.Residency)
+	}
+	stored.Report.Users[0].MessagesPurged = false
+	if Verify([]byte("signing-key"), &stored) {
+		t.Error("expected a tampered report to fail verification")
+	}
+	stored.Report.Users[0].MessagesPurged = true
+	stored.Report.Residency.Region = "us"
+	if Verify([]byte("signing-key"), &stored) {
+		t.Error("expected a report with altered residency to fail verification")
+	}
+}
@@ -0,0 +1,134 @@
+// Package onboarding tracks each user's progress through guided setup.
+// Steps driven by backend activity are recorded from notify events; the
+// remaining steps are completed explicitly by the user.
+package onboarding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/notify"
+	"github.com/rs/zerolog/log"
+)
+
+// Onboarding steps, in the order they are presented
+const (
+	StepAccountLinked      = "account_linked"
+	StepFirstSyncComplete  = "first_sync_complete"
+	StepCategoriesReviewed = "categories_reviewed"
+	StepDigestConfigured   = "digest_configured"
+)
+
+// Steps lists every onboarding step in order
+var Steps = []string{StepAccountLinked, StepFirstSyncComplete, StepCategoriesReviewed, StepDigestConfigured}
+
+var (
+	ErrUnknownStep = errors.New("unknown onboarding step")
+	// ErrNotUserStep is returned when a user tries to complete a step recorded by the backend
+	ErrNotUserStep = errors.New("onboarding step is completed automatically")
+	// ErrStepLocked is returned when a step's prerequisite is not complete yet
+	ErrStepLocked = errors.New("onboarding step prerequisites are not complete")
+)
+
+// prerequisites maps a step to the step that must be complete first
+var prerequisites = map[string]string{
+	StepFirstSyncComplete:  StepAccountLinked,
+	StepCategoriesReviewed: StepFirstSyncComplete,
+	StepDigestConfigured:   StepFirstSyncComplete,
+}
+
+// userSteps can be completed through the API; the others follow from service events
+var userSteps = map[string]bool{
+	StepCategoriesReviewed: true,
+	StepDigestConfigured:   true,
+}
+
+// eventSteps maps notify events to the step they complete
+var eventSteps = map[string]string{
+	notify.EventAccountLinked: StepAccountLinked,
+	notify.EventSyncComplete:  StepFirstSyncComplete,
+}
+
+// Service reads and advances onboarding state
+type Service struct {
+	repo data.OnboardingRepository
+}
+
+func NewService(repo data.OnboardingRepository) *Service {
+	return &Service{repo: repo}
+}
+
+// Subscribe records backend-driven steps when the matching events are published.
+// It returns a function that removes the subscriptions.
+func (s *Service) Subscribe() (unsubscribe func()) {
+	var unsubs []func()
+	for event, step := range eventSteps {
+		step := step
+		unsubs = append(unsubs, notify.Subscribe(event, func(ctx context.Context, userID string) {
+			if err := s.repo.MarkStep(ctx, userID, step); err != nil {
+				log.Error().Str("userID", userID).Str("step", step).Err(err).Msg("onboarding: failed to record step")
+			}
+		}))
+	}
+	return func() {
+		for _, u := range unsubs {
+			u()
+		}
+	}
+}
+
+// Status returns every step with its completion state and the current step
+func (s *Service) Status(ctx context.Context, userID string) (*models.OnboardingStatus, error) {
+	completed, err := s.repo.CompletedSteps(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &models.OnboardingStatus{Steps: make([]models.OnboardingStep, 0, len(Steps))}
+	for _, name := range Steps {
+		step := models.OnboardingStep{Name: name}
+		if at, ok := completed[name]; ok {
+			step.Completed = true
+			step.CompletedAt = &at
+		} else if status.CurrentStep == "" {
+			status.CurrentStep = name
+		}
+		status.Steps = append(status.Steps, step)
+	}
+	status.Complete = status.CurrentStep == ""
+	return status, nil
+}
+
+// CompleteStep marks a user-driven step complete once its prerequisite is done
+func (s *Service) CompleteStep(ctx context.Context, userID, step string) (*models.OnboardingStatus, error) {
+	if !isStep(step) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStep, step)
+	}
+	if !userSteps[step] {
+		return nil, ErrNotUserStep
+	}
+	completed, err := s.repo.CompletedSteps(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if req := prerequisites[step]; req != "" {
+		if _, ok := completed[req]; !ok {
+			return nil, fmt.Errorf("%w: %s must be complete first", ErrStepLocked, req)
+		}
+	}
+	if err := s.repo.MarkStep(ctx, userID, step); err != nil {
+		return nil, err
+	}
+	return s.Status(ctx, userID)
+}
+
+func isStep(step string) bool {
+	for _, s := range Steps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
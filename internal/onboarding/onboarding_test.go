@@ -0,0 +1,76 @@
+package onboarding
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/notify"
+)
+
+type fakeRepo struct {
+	steps map[string]map[string]time.Time
+}
+
+func (f *fakeRepo) MarkStep(ctx context.Context, userID, step string) error {
+	if f.steps[userID] == nil {
+		f.steps[userID] = map[string]time.Time{}
+	}
+	if _, ok := f.steps[userID][step]; !ok {
+		f.steps[userID][step] = time.Now()
+	}
+	return nil
+}
+
+func (f *fakeRepo) CompletedSteps(ctx context.Context, userID string) (map[string]time.Time, error) {
+	return f.steps[userID], nil
+}
+
+func TestService_EventsAdvanceOnboarding(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(&fakeRepo{steps: map[string]map[string]time.Time{}})
+	unsubscribe := svc.Subscribe()
+	defer unsubscribe()
+
+	status, err := svc.Status(ctx, "user1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.CurrentStep != StepAccountLinked || status.Complete || len(status.Steps) != len(Steps) {
+		t.Fatalf("unexpected initial status: %+v", status)
+	}
+
+	// User-driven steps are locked until the first sync has finished
+	if _, err := svc.CompleteStep(ctx, "user1", StepCategoriesReviewed); !errors.Is(err, ErrStepLocked) {
+		t.Errorf("expected ErrStepLocked, got %v", err)
+	}
+
+	notify.Publish(ctx, notify.EventAccountLinked, "user1")
+	notify.Publish(ctx, notify.EventSyncComplete, "user1")
+	status, _ = svc.Status(ctx, "user1")
+	if status.CurrentStep != StepCategoriesReviewed {
+		t.Fatalf("expected categories_reviewed to be current, got %+v", status)
+	}
+
+	if _, err := svc.CompleteStep(ctx, "user1", StepDigestConfigured); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status, err = svc.CompleteStep(ctx, "user1", StepCategoriesReviewed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Complete || status.CurrentStep != "" {
+		t.Errorf("expected onboarding complete, got %+v", status)
+	}
+}
+
+func TestService_CompleteStepRejectsInvalidSteps(t *testing.T) {
+	svc := NewService(&fakeRepo{steps: map[string]map[string]time.Time{}})
+	if _, err := svc.CompleteStep(context.Background(), "user1", "bogus"); !errors.Is(err, ErrUnknownStep) {
+		t.Errorf("expected ErrUnknownStep, got %v", err)
+	}
+	if _, err := svc.CompleteStep(context.Background(), "user1", StepFirstSyncComplete); !errors.Is(err, ErrNotUserStep) {
+		t.Errorf("expected ErrNotUserStep, got %v", err)
+	}
+}
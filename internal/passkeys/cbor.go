@@ -0,0 +1,123 @@
+package passkeys
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// maxCBORDepth bounds nesting so a hostile attestation cannot exhaust the stack
+const maxCBORDepth = 8
+
+var errCBOR = errors.New("malformed CBOR")
+
+// decodeCBOR decodes the first CBOR item in b and returns it with the bytes that follow. It
+// reads the subset WebAuthn uses (RFC 8949 with definite lengths): integers become int64,
+// byte strings []byte, text strings string, arrays []any and maps map[any]any. Tags are
+// dropped and simple values become bool or nil.
+func decodeCBOR(b []byte) (any, []byte, error) {
+	return decodeCBORItem(b, 0)
+}
+
+func decodeCBORItem(b []byte, depth int) (any, []byte, error) {
+	if depth > maxCBORDepth {
+		return nil, nil, fmt.Errorf("%w: nested too deeply", errCBOR)
+	}
+	if len(b) == 0 {
+		return nil, nil, fmt.Errorf("%w: unexpected end", errCBOR)
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	b = b[1:]
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, b, nil
+		case 21:
+			return true, b, nil
+		case 22, 23:
+			return nil, b, nil
+		}
+		return nil, nil, fmt.Errorf("%w: unsupported simple value %d", errCBOR, info)
+	}
+	n, b, err := cborArgument(info, b)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch major {
+	case 0:
+		if n > 1<<63-1 {
+			return nil, nil, fmt.Errorf("%w: integer overflows int64", errCBOR)
+		}
+		return int64(n), b, nil
+	case 1:
+		if n > 1<<63-1 {
+			return nil, nil, fmt.Errorf("%w: integer overflows int64", errCBOR)
+		}
+		return -1 - int64(n), b, nil
+	case 2, 3:
+		if n > uint64(len(b)) {
+			return nil, nil, fmt.Errorf("%w: string runs past the end", errCBOR)
+		}
+		if major == 3 {
+			return string(b[:n]), b[n:], nil
+		}
+		return append([]byte(nil), b[:n]...), b[n:], nil
+	case 4:
+		// Every item takes at least one byte, which bounds the allocation
+		if n > uint64(len(b)) {
+			return nil, nil, fmt.Errorf("%w: array runs past the end", errCBOR)
+		}
+		out := make([]any, 0, n)
+		for i := uint64(0); i < n; i++ {
+			var v any
+			if v, b, err = decodeCBORItem(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			out = append(out, v)
+		}
+		return out, b, nil
+	case 5:
+		if n > uint64(len(b))/2 {
+			return nil, nil, fmt.Errorf("%w: map runs past the end", errCBOR)
+		}
+		out := make(map[any]any, n)
+		for i := uint64(0); i < n; i++ {
+			var k, v any
+			if k, b, err = decodeCBORItem(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("%w: unsupported map key %T", errCBOR, k)
+			}
+			if v, b, err = decodeCBORItem(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			out[k] = v
+		}
+		return out, b, nil
+	case 6:
+		return decodeCBORItem(b, depth+1)
+	}
+	return nil, nil, fmt.Errorf("%w: unsupported major type %d", errCBOR, major)
+}
+
+// cborArgument reads the argument that follows an initial byte with additional info info
+func cborArgument(info byte, b []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), b, nil
+	case info == 24 && len(b) >= 1:
+		return uint64(b[0]), b[1:], nil
+	case info == 25 && len(b) >= 2:
+		return uint64(binary.BigEndian.Uint16(b)), b[2:], nil
+	case info == 26 && len(b) >= 4:
+		return uint64(binary.BigEndian.Uint32(b)), b[4:], nil
+	case info == 27 && len(b) >= 8:
+		return binary.BigEndian.Uint64(b), b[8:], nil
+	case info >= 28:
+		return 0, nil, fmt.Errorf("%w: indefinite lengths are not supported", errCBOR)
+	}
+	return 0, nil, fmt.Errorf("%w: unexpected end", errCBOR)
+}
@@ -0,0 +1,343 @@
+// Package passkeys registers WebAuthn credentials (passkeys) and verifies the assertions users
+// make with them to step up before sensitive operations. Registrations request attestation
+// "none", so the authenticator's make and model are not checked.
+package passkeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+const (
+	// ChallengeTTL is how long a ceremony can take between its options and its answer
+	ChallengeTTL = 5 * time.Minute
+	// StepUpWindow is how long a verified assertion allows sensitive operations
+	StepUpWindow = 5 * time.Minute
+	// MaxPasskeys caps the passkeys of one user
+	MaxPasskeys = 10
+	// MaxNameLength caps a passkey's name, in characters
+	MaxNameLength = 100
+	// DefaultName names passkeys registered without a name
+	DefaultName = "Passkey"
+
+	// CeremonyCreate and CeremonyGet are the client data types of registration and assertion
+	CeremonyCreate = "webauthn.create"
+	CeremonyGet    = "webauthn.get"
+
+	challengeBytes = 32
+)
+
+var (
+	// ErrInvalidResponse is returned for answers that fail verification
+	ErrInvalidResponse = errors.New("invalid passkey response")
+	// ErrNoPasskeys is returned when an assertion is requested from a user without passkeys
+	ErrNoPasskeys = errors.New("no passkeys registered")
+	// ErrTooManyPasskeys is returned when registering more than MaxPasskeys
+	ErrTooManyPasskeys = errors.New("too many passkeys")
+	// ErrInvalidName is returned for passkey names longer than MaxNameLength
+	ErrInvalidName = errors.New("invalid passkey name")
+)
+
+// The options and answers below follow the JSON forms of WebAuthn Level 3
+// (PublicKeyCredential.parseCreationOptionsFromJSON and PublicKeyCredential.toJSON), so
+// browsers can pass them through unchanged.
+
+// CredentialDescriptor identifies a credential in options
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// CredentialParameter offers a key algorithm to the authenticator
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// RelyingPartyEntity names the relying party in creation options
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity is the account a credential is created for; ID is the base64url user handle
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// AuthenticatorSelection states what kind of authenticator is wanted
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions starts a registration (PublicKeyCredentialCreationOptionsJSON)
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions starts an assertion (PublicKeyCredentialRequestOptionsJSON)
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int64                  `json:"timeout"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// credential holds the fields toJSON writes around every response
+type credential struct {
+	ID                      string         `json:"id"`
+	RawID                   string         `json:"rawId"`
+	Type                    string         `json:"type"`
+	AuthenticatorAttachment string         `json:"authenticatorAttachment,omitempty"`
+	ClientExtensionResults  map[string]any `json:"clientExtensionResults,omitempty"`
+}
+
+// RegistrationResponse is a new credential (RegistrationResponseJSON)
+type RegistrationResponse struct {
+	credential
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports,omitempty"`
+		// Written by toJSON; the key is read from the attestation object instead
+		AuthenticatorData  string `json:"authenticatorData,omitempty"`
+		PublicKey          string `json:"publicKey,omitempty"`
+		PublicKeyAlgorithm int    `json:"publicKeyAlgorithm,omitempty"`
+	} `json:"response"`
+}
+
+// AssertionResponse is a signed assertion (AuthenticationResponseJSON)
+type AssertionResponse struct {
+	credential
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+// Service registers passkeys and verifies assertions for one relying party
+type Service struct {
+	repo data.PasskeyRepository
+	rp   RelyingParty
+}
+
+// NewService creates a Service for the relying party rp
+func NewService(repo data.PasskeyRepository, rp RelyingParty) *Service {
+	return &Service{repo: repo, rp: rp}
+}
+
+// NewChallenge returns a random challenge, base64url-encoded
+func NewChallenge() (string, error) {
+	b := make([]byte, challengeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encodeBase64(b), nil
+}
+
+// Enrolled reports whether the user has registered a passkey, and so must step up before
+// sensitive operations
+func (s *Service) Enrolled(ctx context.Context, userID string) (bool, error) {
+	list, err := s.repo.ListByUser(ctx, userID)
+	return len(list) > 0, err
+}
+
+// List returns the user's passkeys
+func (s *Service) List(ctx context.Context, userID string) ([]*models.Passkey, error) {
+	return s.repo.ListByUser(ctx, userID)
+}
+
+// Delete removes one of the user's passkeys; returns data.ErrNotFound if there is none with the ID
+func (s *Service) Delete(ctx context.Context, userID string, id int64) error {
+	return s.repo.Delete(ctx, userID, id)
+}
+
+// CreationOptions returns the options for registering a passkey, answered by FinishRegistration
+func (s *Service) CreationOptions(ctx context.Context, userID, challenge string) (*CreationOptions, error) {
+	existing, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= MaxPasskeys {
+		return nil, ErrTooManyPasskeys
+	}
+	opts := &CreationOptions{
+		Challenge: challenge,
+		RP:        RelyingPartyEntity{ID: s.rp.ID, Name: s.rp.Name},
+		// Inbox Whisperer knows users by ID; the name only labels the passkey in the browser
+		User:                   UserEntity{ID: encodeBase64([]byte(userID)), Name: userID, DisplayName: s.rp.Name},
+		Timeout:                ChallengeTTL.Milliseconds(),
+		ExcludeCredentials:     descriptors(existing),
+		AuthenticatorSelection: AuthenticatorSelection{ResidentKey: "preferred", UserVerification: "preferred"},
+		Attestation:            "none",
+	}
+	for _, alg := range supportedAlgorithms {
+		opts.PubKeyCredParams = append(opts.PubKeyCredParams, CredentialParameter{Type: "public-key", Alg: alg})
+	}
+	return opts, nil
+}
+
+// FinishRegistration verifies a new credential against the challenge of CreationOptions and
+// stores it as a passkey named name
+func (s *Service) FinishRegistration(ctx context.Context, userID, challenge, name string, resp *RegistrationResponse) (*models.Passkey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = DefaultName
+	}
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		return nil, fmt.Errorf("%w: at most %d characters", ErrInvalidName, MaxNameLength)
+	}
+	if resp.Type != "public-key" {
+		return nil, fmt.Errorf("%w: credential type is %q", ErrInvalidResponse, resp.Type)
+	}
+	clientDataJSON, err := decodeBase64(resp.Response.ClientDataJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%w: client data is not base64", ErrInvalidResponse)
+	}
+	if err := s.rp.checkClientData(clientDataJSON, CeremonyCreate, challenge); err != nil {
+		return nil, err
+	}
+	attestation, err := decodeBase64(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation object is not base64", ErrInvalidResponse)
+	}
+	rawAuthData, err := authDataFromAttestation(attestation)
+	if err != nil {
+		return nil, err
+	}
+	authData, err := s.rp.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.credentialID == nil {
+		return nil, fmt.Errorf("%w: no credential was created", ErrInvalidResponse)
+	}
+	credentialID := encodeBase64(authData.credentialID)
+	if rawID, err := decodeBase64(resp.RawID); err != nil || encodeBase64(rawID) != credentialID {
+		return nil, fmt.Errorf("%w: credential ID does not match the authenticator data", ErrInvalidResponse)
+	}
+	pub, alg, err := parseCOSEKey(authData.credentialKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: credential public key: %v", ErrInvalidResponse, err)
+	}
+	spki, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("%w: credential public key: %v", ErrInvalidResponse, err)
+	}
+
+	existing, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= MaxPasskeys {
+		return nil, ErrTooManyPasskeys
+	}
+	p := &models.Passkey{
+		UserID:       userID,
+		CredentialID: credentialID,
+		PublicKey:    spki,
+		Algorithm:    alg,
+		SignCount:    authData.signCount,
+		Name:         name,
+	}
+	if err := s.repo.Create(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// RequestOptions returns the options for an assertion with one of the user's passkeys,
+// answered by FinishAssertion. Returns ErrNoPasskeys if the user has none.
+func (s *Service) RequestOptions(ctx context.Context, userID, challenge string) (*RequestOptions, error) {
+	existing, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) == 0 {
+		return nil, ErrNoPasskeys
+	}
+	return &RequestOptions{
+		Challenge:        challenge,
+		RPID:             s.rp.ID,
+		Timeout:          ChallengeTTL.Milliseconds(),
+		AllowCredentials: descriptors(existing),
+		UserVerification: "preferred",
+	}, nil
+}
+
+// FinishAssertion verifies an assertion against the challenge of RequestOptions and records
+// the passkey's use
+func (s *Service) FinishAssertion(ctx context.Context, userID, challenge string, resp *AssertionResponse) error {
+	if resp.Type != "public-key" {
+		return fmt.Errorf("%w: credential type is %q", ErrInvalidResponse, resp.Type)
+	}
+	rawID, err := decodeBase64(resp.RawID)
+	if err != nil || len(rawID) == 0 {
+		return fmt.Errorf("%w: bad credential ID", ErrInvalidResponse)
+	}
+	p, err := s.repo.GetByCredentialID(ctx, userID, encodeBase64(rawID))
+	if errors.Is(err, data.ErrNotFound) {
+		return fmt.Errorf("%w: unknown credential", ErrInvalidResponse)
+	}
+	if err != nil {
+		return err
+	}
+	clientDataJSON, err := decodeBase64(resp.Response.ClientDataJSON)
+	if err != nil {
+		return fmt.Errorf("%w: client data is not base64", ErrInvalidResponse)
+	}
+	if err := s.rp.checkClientData(clientDataJSON, CeremonyGet, challenge); err != nil {
+		return err
+	}
+	rawAuthData, err := decodeBase64(resp.Response.AuthenticatorData)
+	if err != nil {
+		return fmt.Errorf("%w: authenticator data is not base64", ErrInvalidResponse)
+	}
+	authData, err := s.rp.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return err
+	}
+	sig, err := decodeBase64(resp.Response.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: signature is not base64", ErrInvalidResponse)
+	}
+	if err := verifySignature(p.PublicKey, p.Algorithm, rawAuthData, clientDataJSON, sig); err != nil {
+		return err
+	}
+	// Authenticators that keep a counter must increase it; one that goes back suggests a
+	// cloned authenticator (WebAuthn §6.1.1). Synced passkeys always report 0.
+	if (authData.signCount != 0 || p.SignCount != 0) && authData.signCount <= p.SignCount {
+		return fmt.Errorf("%w: sign count went from %d to %d", ErrInvalidResponse, p.SignCount, authData.signCount)
+	}
+	return s.repo.RecordUse(ctx, p.ID, authData.signCount)
+}
+
+func descriptors(passkeys []*models.Passkey) []CredentialDescriptor {
+	out := make([]CredentialDescriptor, 0, len(passkeys))
+	for _, p := range passkeys {
+		out = append(out, CredentialDescriptor{Type: "public-key", ID: p.CredentialID})
+	}
+	return out
+}
@@ -0,0 +1,287 @@
+package passkeys
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+type memPasskeys struct {
+	list []*models.Passkey
+}
+
+func (m *memPasskeys) Create(ctx context.Context, p *models.Passkey) error {
+	for _, q := range m.list {
+		if q.CredentialID == p.CredentialID {
+			return data.ErrAlreadyExists
+		}
+	}
+	p.ID = int64(len(m.list) + 1)
+	m.list = append(m.list, p)
+	return nil
+}
+func (m *memPasskeys) ListByUser(ctx context.Context, userID string) ([]*models.Passkey, error) {
+	var out []*models.Passkey
+	for _, p := range m.list {
+		if p.UserID == userID {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+func (m *memPasskeys) GetByCredentialID(ctx context.Context, userID, credentialID string) (*models.Passkey, error) {
+	for _, p := range m.list {
+		if p.UserID == userID && p.CredentialID == credentialID {
+			return p, nil
+		}
+	}
+	return nil, data.ErrNotFound
+}
+func (m *memPasskeys) RecordUse(ctx context.Context, id int64, signCount uint32) error {
+	m.list[id-1].SignCount = signCount
+	return nil
+}
+func (m *memPasskeys) Delete(ctx context.Context, userID string, id int64) error {
+	return data.ErrNotFound
+}
+
+// cborHead encodes a CBOR initial byte and argument
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 256:
+		return []byte{major<<5 | 24, byte(n)}
+	default:
+		b := []byte{major<<5 | 25, 0, 0}
+		binary.BigEndian.PutUint16(b[1:], uint16(n))
+		return b
+	}
+}
+
+func cborInt(v int64) []byte {
+	if v < 0 {
+		return cborHead(1, uint64(-1-v))
+	}
+	return cborHead(0, uint64(v))
+}
+
+func cborBytes(b []byte) []byte { return append(cborHead(2, uint64(len(b))), b...) }
+func cborText(s string) []byte  { return append(cborHead(3, uint64(len(s))), s...) }
+
+// cborMap encodes alternating, already encoded keys and values
+func cborMap(kv ...[]byte) []byte {
+	out := cborHead(5, uint64(len(kv)/2))
+	for _, b := range kv {
+		out = append(out, b...)
+	}
+	return out
+}
+
+// authenticator is a software authenticator for one credential
+type authenticator struct {
+	rpID      string
+	origin    string
+	credID    []byte
+	ecKey     *ecdsa.PrivateKey
+	edKey     ed25519.PrivateKey
+	signCount uint32
+}
+
+func newAuthenticator(t *testing.T, ed bool) *authenticator {
+	t.Helper()
+	a := &authenticator{rpID: "mail.example.com", origin: "https://mail.example.com", credID: []byte("credential-1")}
+	var err error
+	if ed {
+		_, a.edKey, err = ed25519.GenerateKey(rand.Reader)
+	} else {
+		a.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func (a *authenticator) coseKey() []byte {
+	if a.edKey != nil {
+		return cborMap(cborInt(1), cborInt(1), cborInt(3), cborInt(AlgEdDSA), cborInt(-1), cborInt(6),
+			cborInt(-2), cborBytes(a.edKey.Public().(ed25519.PublicKey)))
+	}
+	x, y := make([]byte, 32), make([]byte, 32)
+	a.ecKey.X.FillBytes(x)
+	a.ecKey.Y.FillBytes(y)
+	return cborMap(cborInt(1), cborInt(2), cborInt(3), cborInt(AlgES256), cborInt(-1), cborInt(1),
+		cborInt(-2), cborBytes(x), cborInt(-3), cborBytes(y))
+}
+
+func (a *authenticator) authData(attested bool) []byte {
+	h := sha256.Sum256([]byte(a.rpID))
+	out := append(h[:], flagUserPresent)
+	out = binary.BigEndian.AppendUint32(out, a.signCount)
+	if attested {
+		out[32] |= flagAttestedCredData
+		out = append(out, make([]byte, 16)...)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(a.credID)))
+		out = append(out, a.credID...)
+		out = append(out, a.coseKey()...)
+	}
+	return out
+}
+
+func (a *authenticator) clientData(ceremony, challenge string) []byte {
+	b, _ := json.Marshal(map[string]any{"type": ceremony, "challenge": challenge, "origin": a.origin})
+	return b
+}
+
+func (a *authenticator) register(challenge string) *RegistrationResponse {
+	resp := &RegistrationResponse{}
+	resp.ID, resp.RawID, resp.Type = encodeBase64(a.credID), encodeBase64(a.credID), "public-key"
+	resp.Response.ClientDataJSON = encodeBase64(a.clientData(CeremonyCreate, challenge))
+	attestation := cborMap(cborText("fmt"), cborText("none"), cborText("attStmt"), cborMap(),
+		cborText("authData"), cborBytes(a.authData(true)))
+	resp.Response.AttestationObject = encodeBase64(attestation)
+	return resp
+}
+
+func (a *authenticator) assert(t *testing.T, challenge string) *AssertionResponse {
+	t.Helper()
+	a.signCount++
+	authData, clientData := a.authData(false), a.clientData(CeremonyGet, challenge)
+	cdHash := sha256.Sum256(clientData)
+	signed := append(append([]byte{}, authData...), cdHash[:]...)
+	var sig []byte
+	if a.edKey != nil {
+		sig = ed25519.Sign(a.edKey, signed)
+	} else {
+		digest := sha256.Sum256(signed)
+		var err error
+		if sig, err = ecdsa.SignASN1(rand.Reader, a.ecKey, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp := &AssertionResponse{}
+	resp.ID, resp.RawID, resp.Type = encodeBase64(a.credID), encodeBase64(a.credID), "public-key"
+	resp.Response.ClientDataJSON = encodeBase64(clientData)
+	resp.Response.AuthenticatorData = encodeBase64(authData)
+	resp.Response.Signature = encodeBase64(sig)
+	return resp
+}
+
+func TestRegisterAndAssert(t *testing.T) {
+	for _, ed := range []bool{false, true} {
+		ctx := context.Background()
+		rp, err := RelyingPartyFromURL("https://mail.example.com/app", "Inbox Whisperer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo := &memPasskeys{}
+		svc := NewService(repo, rp)
+		a := newAuthenticator(t, ed)
+
+		if enrolled, _ := svc.Enrolled(ctx, "u1"); enrolled {
+			t.Fatal("expected no passkeys yet")
+		}
+		if _, err := svc.RequestOptions(ctx, "u1", "c"); !errors.Is(err, ErrNoPasskeys) {
+			t.Fatalf("expected ErrNoPasskeys, got %v", err)
+		}
+		challenge, _ := NewChallenge()
+		opts, err := svc.CreationOptions(ctx, "u1", challenge)
+		if err != nil || opts.RP.ID != "mail.example.com" || opts.Challenge != challenge || len(opts.PubKeyCredParams) != 3 {
+			t.Fatalf("unexpected creation options %+v, %v", opts, err)
+		}
+		other, _ := NewChallenge()
+		if _, err := svc.FinishRegistration(ctx, "u1", other, "", a.register(challenge)); !errors.Is(err, ErrInvalidResponse) {
+			t.Fatalf("expected a stale challenge to be rejected, got %v", err)
+		}
+		p, err := svc.FinishRegistration(ctx, "u1", challenge, " laptop ", a.register(challenge))
+		if err != nil {
+			t.Fatalf("FinishRegistration failed: %v", err)
+		}
+		if p.Name != "laptop" || p.CredentialID != encodeBase64(a.credID) || (ed && p.Algorithm != AlgEdDSA) || (!ed && p.Algorithm != AlgES256) {
+			t.Fatalf("unexpected passkey %+v", p)
+		}
+		if _, err := svc.FinishRegistration(ctx, "u1", challenge, "", a.register(challenge)); !errors.Is(err, data.ErrAlreadyExists) {
+			t.Errorf("expected a second registration of the credential to fail, got %v", err)
+		}
+
+		challenge, _ = NewChallenge()
+		ro, err := svc.RequestOptions(ctx, "u1", challenge)
+		if err != nil || len(ro.AllowCredentials) != 1 || ro.AllowCredentials[0].ID != p.CredentialID {
+			t.Fatalf("unexpected request options %+v, %v", ro, err)
+		}
+		if err := svc.FinishAssertion(ctx, "u1", challenge, a.assert(t, challenge)); err != nil {
+			t.Fatalf("FinishAssertion failed: %v", err)
+		}
+		if p.SignCount != 1 {
+			t.Errorf("expected the sign count to be recorded, got %d", p.SignCount)
+		}
+		if err := svc.FinishAssertion(ctx, "u2", challenge, a.assert(t, challenge)); !errors.Is(err, ErrInvalidResponse) {
+			t.Errorf("expected another user's assertion to fail, got %v", err)
+		}
+
+		// A replayed counter suggests a cloned authenticator
+		a.signCount = 0
+		if err := svc.FinishAssertion(ctx, "u1", challenge, a.assert(t, challenge)); !errors.Is(err, ErrInvalidResponse) {
+			t.Errorf("expected a replayed sign count to be rejected, got %v", err)
+		}
+		a.signCount = 5
+		tampered := a.assert(t, challenge)
+		tampered.Response.ClientDataJSON = encodeBase64(a.clientData(CeremonyGet, other))
+		if err := svc.FinishAssertion(ctx, "u1", challenge, tampered); !errors.Is(err, ErrInvalidResponse) {
+			t.Errorf("expected a mismatched challenge to be rejected, got %v", err)
+		}
+		a.origin = "https://evil.example.com"
+		if err := svc.FinishAssertion(ctx, "u1", challenge, a.assert(t, challenge)); !errors.Is(err, ErrInvalidResponse) {
+			t.Errorf("expected another origin to be rejected, got %v", err)
+		}
+	}
+}
+
+func TestFinishRegistrationLimits(t *testing.T) {
+	ctx := context.Background()
+	rp := RelyingParty{ID: "mail.example.com", Name: "Inbox Whisperer", Origin: "https://mail.example.com"}
+	repo := &memPasskeys{}
+	svc := NewService(repo, rp)
+	a := newAuthenticator(t, false)
+	long := make([]byte, MaxNameLength+1)
+	for i := range long {
+		long[i] = 'x'
+	}
+	if _, err := svc.FinishRegistration(ctx, "u1", "c", string(long), a.register("c")); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("expected a long name to be rejected, got %v", err)
+	}
+	for i := 0; i < MaxPasskeys; i++ {
+		repo.list = append(repo.list, &models.Passkey{ID: int64(i + 1), UserID: "u1", CredentialID: string(rune('a' + i))})
+	}
+	if _, err := svc.CreationOptions(ctx, "u1", "c"); !errors.Is(err, ErrTooManyPasskeys) {
+		t.Errorf("expected ErrTooManyPasskeys, got %v", err)
+	}
+}
+
+func TestDecodeCBORRejectsMalformedInput(t *testing.T) {
+	for name, in := range map[string][]byte{
+		"empty":           {},
+		"truncated bytes": {0x45, 1, 2},
+		"indefinite":      {0x5f},
+		"huge array":      {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	} {
+		if _, _, err := decodeCBOR(in); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	v, rest, err := decodeCBOR(append(cborMap(cborInt(-3), cborText("x")), 0xff))
+	if err != nil || len(rest) != 1 || v.(map[any]any)[int64(-3)] != "x" {
+		t.Errorf("unexpected decode %v, %v, %v", v, rest, err)
+	}
+}
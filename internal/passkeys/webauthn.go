@@ -0,0 +1,240 @@
+package passkeys
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+)
+
+// COSE algorithm identifiers of the supported credential keys
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// supportedAlgorithms is offered to authenticators in order of preference
+var supportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// Authenticator data flags (WebAuthn §6.1)
+const (
+	flagUserPresent      = 0x01
+	flagAttestedCredData = 0x40
+)
+
+// minRSABits rejects weak RSA credential keys
+const minRSABits = 2048
+
+// RelyingParty is the site passkeys are scoped to: browsers only use a passkey on its RP ID
+// (a domain) and report the origin that asked for it
+type RelyingParty struct {
+	ID     string
+	Name   string
+	Origin string
+}
+
+// RelyingPartyFromURL derives the relying party from the frontend URL, where the WebAuthn
+// ceremonies run
+func RelyingPartyFromURL(frontendURL, name string) (RelyingParty, error) {
+	u, err := url.Parse(frontendURL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return RelyingParty{}, fmt.Errorf("frontend URL %q is not an absolute http(s) URL", frontendURL)
+	}
+	return RelyingParty{ID: u.Hostname(), Name: name, Origin: u.Scheme + "://" + u.Host}, nil
+}
+
+// clientData is the part of CollectedClientData that is checked
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// authenticatorData is parsed authenticator data; credentialID and credentialKey are only set
+// when a credential was created
+type authenticatorData struct {
+	rpIDHash      []byte
+	flags         byte
+	signCount     uint32
+	credentialID  []byte
+	credentialKey []byte
+}
+
+// decodeBase64 accepts base64url with or without padding, as well as standard base64
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	if b, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+func encodeBase64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// checkClientData verifies the ceremony type, challenge and origin of clientDataJSON
+func (rp RelyingParty) checkClientData(raw []byte, ceremony, challenge string) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("%w: client data is not JSON", ErrInvalidResponse)
+	}
+	if cd.Type != ceremony {
+		return fmt.Errorf("%w: client data type is %q, want %q", ErrInvalidResponse, cd.Type, ceremony)
+	}
+	got, err := decodeBase64(cd.Challenge)
+	want, _ := decodeBase64(challenge)
+	if err != nil || len(want) == 0 || subtle.ConstantTimeCompare(got, want) != 1 {
+		return fmt.Errorf("%w: challenge does not match", ErrInvalidResponse)
+	}
+	if cd.Origin != rp.Origin {
+		return fmt.Errorf("%w: origin %q is not %q", ErrInvalidResponse, cd.Origin, rp.Origin)
+	}
+	return nil
+}
+
+// parseAuthenticatorData parses authenticator data (WebAuthn §6.1) and checks that it is
+// scoped to the relying party and that the user was present
+func (rp RelyingParty) parseAuthenticatorData(b []byte) (*authenticatorData, error) {
+	if len(b) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data is too short", ErrInvalidResponse)
+	}
+	ad := &authenticatorData{rpIDHash: b[:32], flags: b[32], signCount: binary.BigEndian.Uint32(b[33:37])}
+	want := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(ad.rpIDHash, want[:]) {
+		return nil, fmt.Errorf("%w: authenticator data is for another relying party", ErrInvalidResponse)
+	}
+	if ad.flags&flagUserPresent == 0 {
+		return nil, fmt.Errorf("%w: user presence was not confirmed", ErrInvalidResponse)
+	}
+	if ad.flags&flagAttestedCredData == 0 {
+		return ad, nil
+	}
+	// AAGUID (16 bytes), credential ID length (2), credential ID, then the COSE key
+	rest := b[37:]
+	if len(rest) < 18 {
+		return nil, fmt.Errorf("%w: attested credential data is too short", ErrInvalidResponse)
+	}
+	n := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if n == 0 || n > len(rest) {
+		return nil, fmt.Errorf("%w: bad credential ID length", ErrInvalidResponse)
+	}
+	ad.credentialID, rest = rest[:n], rest[n:]
+	// Extensions may follow the key; keep only the key's own bytes
+	_, tail, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: credential public key: %v", ErrInvalidResponse, err)
+	}
+	ad.credentialKey = rest[:len(rest)-len(tail)]
+	return ad, nil
+}
+
+// authDataFromAttestation returns the authenticator data inside an attestation object. The
+// attestation statement is not verified: passkeys are requested with attestation "none".
+func authDataFromAttestation(attestationObject []byte) ([]byte, error) {
+	v, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation object: %v", ErrInvalidResponse, err)
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation object is not a map", ErrInvalidResponse)
+	}
+	authData, ok := m["authData"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation object has no authData", ErrInvalidResponse)
+	}
+	return authData, nil
+}
+
+// parseCOSEKey converts a COSE_Key (RFC 9053) to a public key and its algorithm
+func parseCOSEKey(b []byte) (crypto.PublicKey, int, error) {
+	v, _, err := decodeCBOR(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return nil, 0, fmt.Errorf("COSE key is not a map")
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+	bytesParam := func(label int64) []byte {
+		b, _ := m[label].([]byte)
+		return b
+	}
+	switch {
+	case kty == 2 && alg == AlgES256:
+		// EC2 on P-256: -1 curve, -2 x, -3 y
+		if crv, _ := m[int64(-1)].(int64); crv != 1 {
+			return nil, 0, fmt.Errorf("unsupported EC2 curve %d", crv)
+		}
+		x, y := bytesParam(-2), bytesParam(-3)
+		if len(x) != 32 || len(y) != 32 {
+			return nil, 0, fmt.Errorf("bad P-256 coordinates")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, 0, fmt.Errorf("P-256 point: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, AlgES256, nil
+	case kty == 1 && alg == AlgEdDSA:
+		// OKP: -1 curve (6 is Ed25519), -2 x
+		if crv, _ := m[int64(-1)].(int64); crv != 6 {
+			return nil, 0, fmt.Errorf("unsupported OKP curve %d", crv)
+		}
+		x := bytesParam(-2)
+		if len(x) != ed25519.PublicKeySize {
+			return nil, 0, fmt.Errorf("bad Ed25519 key")
+		}
+		return ed25519.PublicKey(x), AlgEdDSA, nil
+	case kty == 3 && alg == AlgRS256:
+		// RSA: -1 n, -2 e
+		n, e := new(big.Int).SetBytes(bytesParam(-1)), new(big.Int).SetBytes(bytesParam(-2))
+		if n.BitLen() < minRSABits || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, 0, fmt.Errorf("bad or weak RSA key")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, AlgRS256, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported key type %d with algorithm %d", kty, alg)
+}
+
+// verifySignature checks an assertion signature over authData || SHA-256(clientDataJSON)
+// with a key stored by FinishRegistration
+func verifySignature(spki []byte, alg int, authData, clientDataJSON, sig []byte) error {
+	pub, err := x509.ParsePKIXPublicKey(spki)
+	if err != nil {
+		return fmt.Errorf("stored public key: %w", err)
+	}
+	cdHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authData...), cdHash[:]...)
+	digest := sha256.Sum256(signed)
+	ok := false
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		ok = alg == AlgES256 && ecdsa.VerifyASN1(key, digest[:], sig)
+	case ed25519.PublicKey:
+		ok = alg == AlgEdDSA && ed25519.Verify(key, signed, sig)
+	case *rsa.PublicKey:
+		ok = alg == AlgRS256 && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	}
+	if !ok {
+		return fmt.Errorf("%w: signature does not verify", ErrInvalidResponse)
+	}
+	return nil
+}
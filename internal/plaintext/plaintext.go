@@ -0,0 +1,73 @@
+// Package plaintext turns message bodies into the plain text that snippets, categorization
+// and summaries are built from: it decodes declared charsets to UTF-8 and converts HTML-only
+// messages to readable text, keeping links and list structure.
+package plaintext
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/desponda/inbox-whisperer/internal/render"
+	"golang.org/x/net/html/charset"
+)
+
+// Decode converts b from the named charset to UTF-8. Text without a charset, or with one that
+// is not recognized, is kept when it is valid UTF-8 and read as Windows-1252 otherwise, which is
+// what mail clients assume for undeclared 8-bit text.
+func Decode(b []byte, label string) string {
+	label = strings.TrimSpace(label)
+	if label == "" || strings.EqualFold(label, "us-ascii") {
+		if utf8.Valid(b) {
+			return string(b)
+		}
+		label = "windows-1252"
+	}
+	r, err := charset.NewReaderLabel(label, bytes.NewReader(b))
+	if err != nil {
+		if utf8.Valid(b) {
+			return string(b)
+		}
+		r, _ = charset.NewReaderLabel("windows-1252", bytes.NewReader(b))
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return string(b)
+	}
+	return string(out)
+}
+
+// CharsetReader converts input in the named charset to UTF-8, for mime.WordDecoder
+func CharsetReader(label string, input io.Reader) (io.Reader, error) {
+	return charset.NewReaderLabel(label, input)
+}
+
+// Snippet is the start of text with whitespace collapsed, at most n runes long
+func Snippet(text string, n int) string {
+	s := strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// Truncate shortens s to at most n bytes without splitting a multi-byte character
+func Truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// Body is the plain text of a message: its text part, or its HTML part converted (see
+// render.PlainText) when it has no text part
+func Body(text, htmlBody string) string {
+	if strings.TrimSpace(text) != "" || htmlBody == "" {
+		return text
+	}
+	return render.PlainText(htmlBody)
+}
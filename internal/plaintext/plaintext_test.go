@@ -0,0 +1,41 @@
+package plaintext
+
+import (
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	if got := Decode([]byte{'c', 'a', 'f', 0xe9}, "ISO-8859-1"); got != "café" {
+		t.Errorf("latin-1: got %q", got)
+	}
+	if got := Decode([]byte{0x93, 'q', 0x94}, ""); got != "“q”" {
+		t.Errorf("undeclared 8-bit: got %q", got)
+	}
+	if got := Decode([]byte{0x82, 0xb1, 0x82, 0xf1}, "shift_jis"); got != "こん" {
+		t.Errorf("shift_jis: got %q", got)
+	}
+	if got := Decode([]byte("héllo"), "x-unknown"); got != "héllo" {
+		t.Errorf("unknown charset: got %q", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := Truncate("héllo", 2); got != "h" {
+		t.Errorf("got %q, want the multi-byte é dropped whole", got)
+	}
+	if got := Truncate("abc", 5); got != "abc" {
+		t.Errorf("got %q", got)
+	}
+	if got := Snippet("  a\n b  c ", 3); got != "a b" {
+		t.Errorf("snippet: got %q", got)
+	}
+}
+
+func TestBody(t *testing.T) {
+	if got := Body("text", "<p>html</p>"); got != "text" {
+		t.Errorf("got %q, want the text part", got)
+	}
+	if got := Body(" \r\n", "<p>html</p>"); got != "html" {
+		t.Errorf("got %q, want the converted HTML part", got)
+	}
+}
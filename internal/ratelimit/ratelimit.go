@@ -0,0 +1,119 @@
+// Package ratelimit throttles API requests with token buckets, one per signed-in user and one
+// per client IP for anonymous requests, so a single client syncing aggressively cannot exhaust
+// the Gmail quota or the database pool. Buckets live in a Store: in memory for a single
+// instance, or in Redis when several instances must share the limits.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket: Burst requests at once, refilled at PerMinute requests a minute.
+// A Limit with either at zero never limits.
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+// Unlimited reports whether l lets every request through
+func (l Limit) Unlimited() bool {
+	return l.PerMinute <= 0 || l.Burst <= 0
+}
+
+// rate is the refill rate in tokens per second
+func (l Limit) rate() float64 {
+	return float64(l.PerMinute) / 60
+}
+
+// Store keeps token buckets
+type Store interface {
+	// Take removes a token from the bucket at key, creating it full if needed. When the bucket
+	// is empty it reports false and how long until the next token.
+	Take(ctx context.Context, key string, l Limit) (ok bool, retryAfter time.Duration, err error)
+}
+
+// Limiter checks requests against the per-user and per-IP limits
+type Limiter struct {
+	Store   Store
+	PerUser Limit
+	PerIP   Limit
+}
+
+func NewLimiter(store Store, perUser, perIP Limit) *Limiter {
+	return &Limiter{Store: store, PerUser: perUser, PerIP: perIP}
+}
+
+// Allow takes a token for a request: from the user's bucket when userID is set, so users
+// behind one NAT do not share a limit, and from the client IP's bucket otherwise
+func (l *Limiter) Allow(ctx context.Context, userID, ip string) (bool, time.Duration, error) {
+	key, limit := "ip:"+ip, l.PerIP
+	if userID != "" {
+		key, limit = "user:"+userID, l.PerUser
+	}
+	if limit.Unlimited() {
+		return true, 0, nil
+	}
+	return l.Store.Take(ctx, key, limit)
+}
+
+// sweepInterval is how often MemoryStore drops buckets that have refilled
+const sweepInterval = time.Minute
+
+// MemoryStore keeps buckets in process memory; limits are per instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, l Limit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.refill(now, l)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	wait := time.Duration(math.Ceil((1 - b.tokens) / l.rate() * float64(time.Second)))
+	return false, wait, nil
+}
+
+// sweep drops the buckets that are full again, which behave like new ones
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*b.limit.rate() >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func (b *bucket) refill(now time.Time, l Limit) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(l.Burst), b.tokens+elapsed*l.rate())
+	}
+	b.updated, b.limit = now, l
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	l := Limit{PerMinute: 60, Burst: 3}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if ok, _, _ := s.Take(ctx, "user:u1", l); !ok {
+			t.Fatalf("expected request %d within the burst to pass", i+1)
+		}
+	}
+	ok, wait, err := s.Take(ctx, "user:u1", l)
+	if err != nil || ok || wait != time.Second {
+		t.Errorf("expected a 1s wait once the burst is spent, got ok=%v wait=%v err=%v", ok, wait, err)
+	}
+	if ok, _, _ := s.Take(ctx, "user:u2", l); !ok {
+		t.Error("expected other users to have their own bucket")
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	if ok, _, _ := s.Take(ctx, "user:u1", l); !ok {
+		t.Error("expected a token after the refill")
+	}
+	if ok, wait, _ := s.Take(ctx, "user:u1", l); ok || wait != 500*time.Millisecond {
+		t.Errorf("expected the half token left to need 500ms, got ok=%v wait=%v", ok, wait)
+	}
+
+	// Buckets that have refilled are swept, the others kept
+	now = now.Add(2 * time.Minute)
+	s.Take(ctx, "user:u3", Limit{PerMinute: 1, Burst: 1000})
+	if _, ok := s.buckets["user:u1"]; ok {
+		t.Error("expected the full bucket to be swept")
+	}
+	if _, ok := s.buckets["user:u3"]; !ok {
+		t.Error("expected the bucket in use to be kept")
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	l := NewLimiter(NewMemoryStore(), Limit{PerMinute: 60, Burst: 2}, Limit{PerMinute: 60, Burst: 1})
+	ctx := context.Background()
+
+	if ok, _, _ := l.Allow(ctx, "", "10.0.0.1"); !ok {
+		t.Fatal("expected the first anonymous request to pass")
+	}
+	if ok, _, _ := l.Allow(ctx, "", "10.0.0.1"); ok {
+		t.Error("expected the IP limit to apply to anonymous requests")
+	}
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := l.Allow(ctx, "u1", "10.0.0.1"); !ok {
+			t.Errorf("expected signed-in request %d to use the user's bucket", i+1)
+		}
+	}
+	if ok, _, _ := l.Allow(ctx, "u1", "10.0.0.1"); ok {
+		t.Error("expected the user limit to apply")
+	}
+
+	l.PerUser = Limit{}
+	if ok, _, _ := l.Allow(ctx, "u1", "10.0.0.1"); !ok {
+		t.Error("expected a zero limit never to limit")
+	}
+}
@@ -0,0 +1,271 @@
+package ratelimit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRedisTimeout bounds dialing Redis and each command
+const DefaultRedisTimeout = 500 * time.Millisecond
+
+// maxIdleRedisConns is how many connections RedisStore keeps open between requests
+const maxIdleRedisConns = 16
+
+// takeScript refills and takes from a bucket stored as a hash of tokens and the time they were
+// counted. It reads the server's clock, so instances with skewed clocks agree, and lets idle
+// buckets expire once they would be full again. Returns {1, 0} or {0, milliseconds to wait}.
+const takeScript = `
+local rate = tonumber(ARGV[1]) / 60
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1]) or burst
+local ts = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+else
+  wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('EXPIRE', KEYS[1], math.ceil(burst / rate) + 1)
+if wait > 0 then
+  return {0, wait}
+end
+return {1, 0}
+`
+
+// RedisStore keeps buckets in Redis (5 or later), so every instance shares the limits. It
+// speaks the Redis protocol directly and keeps a few connections open between requests.
+type RedisStore struct {
+	// Prefix namespaces the keys, so the store can share a Redis with other applications
+	Prefix string
+	// Timeout bounds dialing and each command; a request's earlier deadline wins
+	Timeout time.Duration
+
+	addr     string
+	username string
+	password string
+	db       int
+	tls      *tls.Config
+	idle     chan *redisConn
+}
+
+// NewRedisStore returns a store for the server at rawURL, in the form
+// redis://[[user]:password@]host[:port][/db], or rediss:// for TLS. Nothing is dialed until
+// the first Take.
+func NewRedisStore(rawURL string) (*RedisStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("ratelimit: invalid redis url: %w", err)
+	}
+	s := &RedisStore{Prefix: "inbox-whisperer:ratelimit:", Timeout: DefaultRedisTimeout, idle: make(chan *redisConn, maxIdleRedisConns)}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		s.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	default:
+		return nil, fmt.Errorf("ratelimit: redis url must use redis:// or rediss://, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("ratelimit: redis url has no host")
+	}
+	s.addr = u.Host
+	if u.Port() == "" {
+		s.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		s.username = u.User.Username()
+		s.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if s.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("ratelimit: invalid redis database %q", db)
+		}
+	}
+	return s, nil
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, l Limit) (bool, time.Duration, error) {
+	reply, err := s.do(ctx, "EVAL", takeScript, "1", s.Prefix+key, strconv.Itoa(l.PerMinute), strconv.Itoa(l.Burst))
+	if err != nil {
+		return false, 0, err
+	}
+	r, ok := reply.([]any)
+	if !ok || len(r) != 2 {
+		return false, 0, fmt.Errorf("ratelimit: unexpected redis reply %v", reply)
+	}
+	allowed, _ := r[0].(int64)
+	waitMS, _ := r[1].(int64)
+	return allowed == 1, time.Duration(waitMS) * time.Millisecond, nil
+}
+
+// Close closes the idle connections
+func (s *RedisStore) Close() error {
+	for {
+		select {
+		case c := <-s.idle:
+			c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// redisError is an error reply from the server; the connection stays usable after one
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (s *RedisStore) do(ctx context.Context, args ...string) (any, error) {
+	c, err := s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.do(s.deadline(ctx), args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		c.Close()
+		return nil, fmt.Errorf("ratelimit: redis: %w", err)
+	}
+	select {
+	case s.idle <- c:
+	default:
+		c.Close()
+	}
+	return reply, err
+}
+
+func (s *RedisStore) deadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(s.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		return d
+	}
+	return deadline
+}
+
+// conn returns an idle connection or dials a new one, authenticated and on the right database
+func (s *RedisStore) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-s.idle:
+		return c, nil
+	default:
+	}
+	ctx, cancel := context.WithDeadline(ctx, s.deadline(ctx))
+	defer cancel()
+	var (
+		nc  net.Conn
+		err error
+	)
+	if s.tls != nil {
+		nc, err = (&tls.Dialer{Config: s.tls}).DialContext(ctx, "tcp", s.addr)
+	} else {
+		nc, err = (&net.Dialer{}).DialContext(ctx, "tcp", s.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ratelimit: dial redis: %w", err)
+	}
+	c := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+	var setup [][]string
+	switch {
+	case s.username != "":
+		setup = append(setup, []string{"AUTH", s.username, s.password})
+	case s.password != "":
+		setup = append(setup, []string{"AUTH", s.password})
+	}
+	if s.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.db)})
+	}
+	for _, args := range setup {
+		if _, err := c.do(s.deadline(ctx), args...); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("ratelimit: redis %s: %w", args[0], err)
+		}
+	}
+	return c, nil
+}
+
+func (c *redisConn) do(deadline time.Time, args ...string) (any, error) {
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := c.Write(b.Bytes()); err != nil {
+		return nil, err
+	}
+	return readReply(c.r)
+}
+
+// readReply reads one RESP reply: strings, integers, bulk strings (nil when absent) and arrays
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		// An error element is returned after the whole array is read, so the connection stays in step
+		out := make([]any, n)
+		var replyErr error
+		for i := range out {
+			v, err := readReply(r)
+			var re redisError
+			switch {
+			case errors.As(err, &re):
+				replyErr = err
+			case err != nil:
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, replyErr
+	}
+	return nil, fmt.Errorf("unknown reply type %q", kind)
+}
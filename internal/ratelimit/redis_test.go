@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRedis answers each command with the next canned reply and records the commands
+type fakeRedis struct {
+	ln       net.Listener
+	replies  []string
+	commands chan []string
+}
+
+func newFakeRedis(t *testing.T, replies ...string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	f := &fakeRedis{ln: ln, replies: replies, commands: make(chan []string, len(replies))}
+	go f.serve()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+func (f *fakeRedis) serve() {
+	conn, err := f.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for _, reply := range f.replies {
+		cmd, err := readReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, a := range cmd.([]any) {
+			args = append(args, a.(string))
+		}
+		f.commands <- args
+		conn.Write([]byte(reply))
+	}
+}
+
+func TestRedisStore_Take(t *testing.T) {
+	f := newFakeRedis(t, "+OK\r\n", "+OK\r\n", "*2\r\n:1\r\n:0\r\n", "*2\r\n:0\r\n:1500\r\n", "-NOSCRIPT boom\r\n")
+	s, err := NewRedisStore("redis://:secret@" + f.ln.Addr().String() + "/2")
+	if err != nil {
+		t.Fatalf("NewRedisStore failed: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	l := Limit{PerMinute: 60, Burst: 5}
+
+	ok, wait, err := s.Take(ctx, "user:u1", l)
+	if err != nil || !ok || wait != 0 {
+		t.Fatalf("expected the first take to pass, got ok=%v wait=%v err=%v", ok, wait, err)
+	}
+	if cmd := <-f.commands; strings.Join(cmd, " ") != "AUTH secret" {
+		t.Errorf("expected AUTH first, got %v", cmd)
+	}
+	if cmd := <-f.commands; strings.Join(cmd, " ") != "SELECT 2" {
+		t.Errorf("expected SELECT of the url's database, got %v", cmd)
+	}
+	cmd := <-f.commands
+	if cmd[0] != "EVAL" || cmd[3] != "inbox-whisperer:ratelimit:user:u1" || cmd[4] != "60" || cmd[5] != "5" {
+		t.Errorf("unexpected EVAL %v", cmd[2:])
+	}
+
+	// The connection is reused and stays usable after an error reply
+	ok, wait, err = s.Take(ctx, "user:u1", l)
+	if err != nil || ok || wait != 1500*time.Millisecond {
+		t.Errorf("expected a 1.5s wait, got ok=%v wait=%v err=%v", ok, wait, err)
+	}
+	var replyErr redisError
+	if _, _, err := s.Take(ctx, "user:u1", l); !errors.As(err, &replyErr) {
+		t.Errorf("expected the error reply, got %v", err)
+	}
+	if len(s.idle) != 1 {
+		t.Errorf("expected the connection back in the pool, got %d idle", len(s.idle))
+	}
+}
+
+func TestNewRedisStore(t *testing.T) {
+	s, err := NewRedisStore("rediss://cache.internal")
+	if err != nil || s.addr != "cache.internal:6379" || s.tls == nil {
+		t.Errorf("expected TLS on the default port, got %+v (err %v)", s, err)
+	}
+	for _, bad := range []string{"http://cache:6379", "redis://", "redis://cache/x"} {
+		if _, err := NewRedisStore(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Package recategorize re-runs categorization over cached messages after the categorizer's
+// model or prompts change. Jobs are stored in the database with their progress and run by the
+// job queue (see package jobs), each at a throttled rate.
+package recategorize
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ai"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/health"
+	"github.com/desponda/inbox-whisperer/internal/jobs"
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrJobActive is returned when a job for the same scope is already queued or running
+var ErrJobActive = errors.New("a re-categorization job is already queued or running")
+
+// Defaults for the worker's pace
+const (
+	DefaultBatchSize = 100
+	DefaultInterval  = 200 * time.Millisecond
+)
+
+// JobTypeMessage is the job type of single messages reported to the health monitor
+const JobTypeMessage = "recategorize_message"
+
+// Categorizer classifies a message without consulting cached results. *ai.Gateway implements it.
+type Categorizer interface {
+	Recategorize(ctx context.Context, userID string, msg *models.EmailMessage) (*ai.Categorization, error)
+	// CheckExternal reports whether the user's messages may currently be sent to an external LLM
+	CheckExternal(ctx context.Context, userID string) error
+}
+
+// MessageStore is the subset of data.EmailMessageRepository the worker needs
+type MessageStore interface {
+	GetMessagesForUserCursor(ctx context.Context, userID string, limit int, afterInternalDate int64, afterMsgID string) ([]*models.EmailMessage, error)
+	SetCategory(ctx context.Context, userID, emailMessageID, category string, confidence float64) error
+}
+
+// Queue runs the jobs in the background (see jobs.Queue)
+type Queue interface {
+	Enqueue(ctx context.Context, kind, userID string, refID int64, payload any) (*models.Job, error)
+	Save(ctx context.Context, job *models.Job) error
+}
+
+// Runner enqueues re-categorization jobs and runs them for the job queue
+type Runner struct {
+	jobs        data.RecategorizeJobRepository
+	messages    MessageStore
+	categorizer Categorizer
+	queue       Queue
+
+	// BatchSize is how many messages are loaded (and progress persisted) at a time
+	BatchSize int
+	// Interval is the minimum delay between categorizing two messages, bounding LLM request rates
+	Interval time.Duration
+	// Health, if set, receives heartbeats and message outcomes
+	Health *health.Worker
+	// Maintenance, if set, pauses running jobs while it is on
+	Maintenance *maintenance.Switch
+
+	now func() time.Time
+}
+
+func NewRunner(jobs data.RecategorizeJobRepository, messages MessageStore, categorizer Categorizer, queue Queue) *Runner {
+	return &Runner{
+		jobs:        jobs,
+		messages:    messages,
+		categorizer: categorizer,
+		queue:       queue,
+		BatchSize:   DefaultBatchSize,
+		Interval:    DefaultInterval,
+		now:         time.Now,
+	}
+}
+
+// Enqueue queues a job for userID, or for all users when userID is empty. The job carries an
+// estimate of the LLM tokens it will spend on users whose messages may be sent to a provider.
+// If a job for the same scope is already pending it is returned together with ErrJobActive.
+func (r *Runner) Enqueue(ctx context.Context, requestedBy, userID string) (*models.RecategorizeJob, error) {
+	active, err := r.jobs.ActiveJob(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		return active, ErrJobActive
+	}
+	users, err := r.users(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	job := &models.RecategorizeJob{UserID: userID, RequestedBy: requestedBy}
+	aiChars := 0
+	for _, u := range users {
+		count, chars, err := r.jobs.MessageStats(ctx, u, ai.MaxPromptBody)
+		if err != nil {
+			return nil, err
+		}
+		job.Total += count
+		if r.categorizer.CheckExternal(ctx, u) == nil {
+			job.AIMessages += count
+			aiChars += chars
+		}
+	}
+	job.EstimatedTokens = ai.EstimateCategorizationTokens(job.AIMessages, aiChars)
+	if err := r.jobs.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+	task, err := r.queue.Enqueue(ctx, models.JobKindRecategorize, userID, job.ID, nil)
+	if err != nil {
+		// Nothing will run the job; fail it so it does not block the next one
+		job.Status, job.Error = models.JobFailed, "failed to queue the job"
+		r.save(ctx, job, nil)
+		return nil, err
+	}
+	job.QueueJobID = task.ID
+	return job, nil
+}
+
+// Job returns a job by ID; data.ErrNotFound if it does not exist
+func (r *Runner) Job(ctx context.Context, id int64) (*models.RecategorizeJob, error) {
+	return r.jobs.GetJob(ctx, id)
+}
+
+// Run is the job queue's handler for models.JobKindRecategorize: it runs the job task points
+// at. Interrupted and retried jobs restart from the beginning.
+func (r *Runner) Run(ctx context.Context, task *models.Job) error {
+	job, err := r.jobs.GetJob(ctx, task.RefID)
+	if errors.Is(err, data.ErrNotFound) {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	started := r.now().UTC()
+	job.Status, job.StartedAt, job.FinishedAt, job.Processed, job.Failed, job.Error = models.JobRunning, &started, nil, 0, 0, ""
+	r.save(ctx, job, task)
+
+	err = r.process(ctx, job, task)
+	if ctx.Err() != nil {
+		// Shutting down: leave the job running; the queue runs it again
+		return ctx.Err()
+	}
+	switch {
+	case err == nil:
+		finished := r.now().UTC()
+		job.Status, job.Total, job.FinishedAt = models.JobCompleted, job.Processed, &finished
+		log.Info().Int64("job_id", job.ID).Int("processed", job.Processed).Int("failed", job.Failed).Msg("recategorize: job completed")
+	case jobs.WillRetry(task, err):
+		job.Status, job.Error = models.JobQueued, err.Error()
+	default:
+		finished := r.now().UTC()
+		job.Status, job.Error, job.FinishedAt = models.JobFailed, err.Error(), &finished
+	}
+	r.save(context.Background(), job, task)
+	return err
+}
+
+func (r *Runner) process(ctx context.Context, job *models.RecategorizeJob, task *models.Job) error {
+	users, err := r.users(ctx, job.UserID)
+	if err != nil {
+		return err
+	}
+	var tick <-chan time.Time
+	if r.Interval > 0 {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for _, userID := range users {
+		var afterDate int64
+		var afterID string
+		for {
+			batch, err := r.messages.GetMessagesForUserCursor(ctx, userID, r.BatchSize, afterDate, afterID)
+			if err != nil {
+				return err
+			}
+			for _, msg := range batch {
+				if err := maintenance.Wait(ctx, r.Maintenance, r.Health.Beat); err != nil {
+					return err
+				}
+				if tick != nil {
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case <-tick:
+					}
+				}
+				err := r.recategorize(ctx, userID, msg)
+				if err != nil {
+					job.Failed++
+				}
+				r.Health.Record(JobTypeMessage, err)
+				job.Processed++
+			}
+			r.save(ctx, job, task)
+			if len(batch) < r.BatchSize {
+				break
+			}
+			last := batch[len(batch)-1]
+			afterDate, afterID = last.InternalDate, last.EmailMessageID
+		}
+	}
+	return nil
+}
+
+// recategorize updates one message, returning an error if it could not be categorized or saved.
+// Categories set by the user or a rule are stored with full confidence and left alone.
+func (r *Runner) recategorize(ctx context.Context, userID string, msg *models.EmailMessage) error {
+	if msg.CategorizationConfidence.Valid && msg.CategorizationConfidence.Float64 >= 1 {
+		return nil
+	}
+	c, err := r.categorizer.Recategorize(ctx, userID, msg)
+	if err != nil {
+		log.Warn().Err(err).Str("user_id", userID).Str("message_id", msg.EmailMessageID).Msg("recategorize: categorization failed")
+		return err
+	}
+	if err := r.messages.SetCategory(ctx, userID, msg.EmailMessageID, c.Category, c.Confidence); err != nil && !errors.Is(err, data.ErrNotFound) {
+		log.Warn().Err(err).Str("user_id", userID).Str("message_id", msg.EmailMessageID).Msg("recategorize: failed to save category")
+		return err
+	}
+	return nil
+}
+
+func (r *Runner) users(ctx context.Context, userID string) ([]string, error) {
+	if userID != "" {
+		return []string{userID}, nil
+	}
+	return r.jobs.UsersWithMessages(ctx)
+}
+
+// save writes the job's progress, and copies it to the queue job running it, if any
+func (r *Runner) save(ctx context.Context, job *models.RecategorizeJob, task *models.Job) {
+	if err := r.jobs.UpdateJob(ctx, job); err != nil {
+		log.Error().Err(err).Int64("job_id", job.ID).Msg("recategorize: failed to save job progress")
+	}
+	if task == nil {
+		return
+	}
+	task.Total, task.Processed, task.Failed = job.Total, job.Processed, job.Failed
+	if err := r.queue.Save(ctx, task); err != nil {
+		log.Error().Err(err).Int64("job_id", task.ID).Msg("recategorize: failed to save queue job progress")
+	}
+}
@@ -0,0 +1,228 @@
+package recategorize
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ai"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/health"
+	"github.com/desponda/inbox-whisperer/internal/jobs"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+type fakeJobs struct {
+	jobs  []*models.RecategorizeJob
+	stats map[string][2]int
+	saves int
+}
+
+func (f *fakeJobs) CreateJob(ctx context.Context, j *models.RecategorizeJob) error {
+	j.ID = int64(len(f.jobs) + 1)
+	j.Status = models.JobQueued
+	f.jobs = append(f.jobs, j)
+	return nil
+}
+
+func (f *fakeJobs) GetJob(ctx context.Context, id int64) (*models.RecategorizeJob, error) {
+	for _, j := range f.jobs {
+		if j.ID == id {
+			return j, nil
+		}
+	}
+	return nil, data.ErrNotFound
+}
+
+func (f *fakeJobs) UpdateJob(ctx context.Context, j *models.RecategorizeJob) error {
+	f.saves++
+	return nil
+}
+
+func (f *fakeJobs) ActiveJob(ctx context.Context, userID string) (*models.RecategorizeJob, error) {
+	for _, j := range f.jobs {
+		if j.UserID == userID && !j.Finished() {
+			return j, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeJobs) UsersWithMessages(ctx context.Context) ([]string, error) {
+	return []string{"u1", "u2"}, nil
+}
+
+func (f *fakeJobs) MessageStats(ctx context.Context, userID string, maxBody int) (int, int, error) {
+	s := f.stats[userID]
+	return s[0], s[1], nil
+}
+
+// fakeQueue hands out queue jobs and counts the progress saved to them
+type fakeQueue struct {
+	tasks []*models.Job
+	saves int
+}
+
+func (f *fakeQueue) Enqueue(ctx context.Context, kind, userID string, refID int64, payload any) (*models.Job, error) {
+	task := &models.Job{ID: int64(len(f.tasks) + 100), Kind: kind, UserID: userID, RefID: refID, Status: models.JobQueued, MaxAttempts: 3}
+	f.tasks = append(f.tasks, task)
+	return task, nil
+}
+
+func (f *fakeQueue) Save(ctx context.Context, job *models.Job) error {
+	f.saves++
+	return nil
+}
+
+// fakeMessages pages through messages in slice order, keyed by message ID
+type fakeMessages struct {
+	byUser map[string][]*models.EmailMessage
+	set    map[string]string
+	err    error
+}
+
+func (f *fakeMessages) GetMessagesForUserCursor(ctx context.Context, userID string, limit int, afterInternalDate int64, afterMsgID string) ([]*models.EmailMessage, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	msgs := f.byUser[userID]
+	start := 0
+	if afterMsgID != "" {
+		for i, m := range msgs {
+			if m.EmailMessageID == afterMsgID {
+				start = i + 1
+			}
+		}
+	}
+	end := start + limit
+	if end > len(msgs) {
+		end = len(msgs)
+	}
+	return msgs[start:end], nil
+}
+
+func (f *fakeMessages) SetCategory(ctx context.Context, userID, emailMessageID, category string, confidence float64) error {
+	f.set[emailMessageID] = category
+	return nil
+}
+
+type fakeCategorizer struct {
+	external map[string]bool
+	fail     map[string]bool
+}
+
+func (f *fakeCategorizer) Recategorize(ctx context.Context, userID string, msg *models.EmailMessage) (*ai.Categorization, error) {
+	if f.fail[msg.EmailMessageID] {
+		return nil, errors.New("boom")
+	}
+	return &ai.Categorization{Category: "Updates", Confidence: 0.8, Source: ai.SourceLLM}, nil
+}
+
+func (f *fakeCategorizer) CheckExternal(ctx context.Context, userID string) error {
+	if f.external[userID] {
+		return nil
+	}
+	return ai.ErrConsentRequired
+}
+
+func TestEnqueue_EstimatesOnlyExternalUsers(t *testing.T) {
+	jobs := &fakeJobs{stats: map[string][2]int{"u1": {10, 4000}, "u2": {5, 1000}}}
+	queue := &fakeQueue{}
+	r := NewRunner(jobs, &fakeMessages{}, &fakeCategorizer{external: map[string]bool{"u1": true}}, queue)
+
+	job, err := r.Enqueue(context.Background(), "admin", "")
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if job.Total != 15 || job.AIMessages != 10 {
+		t.Errorf("expected 15 messages with 10 AI-backed, got %+v", job)
+	}
+	if want := ai.EstimateCategorizationTokens(10, 4000); job.EstimatedTokens != want || want <= 1000 {
+		t.Errorf("expected estimate %d, got %d", want, job.EstimatedTokens)
+	}
+	if len(queue.tasks) != 1 || queue.tasks[0].RefID != job.ID || queue.tasks[0].UserID != "" || job.QueueJobID != queue.tasks[0].ID {
+		t.Errorf("expected the job queued for all users, got %+v for %+v", queue.tasks, job)
+	}
+
+	again, err := r.Enqueue(context.Background(), "admin", "")
+	if !errors.Is(err, ErrJobActive) || again.ID != job.ID {
+		t.Errorf("expected ErrJobActive with job %d, got %+v (err %v)", job.ID, again, err)
+	}
+	if _, err := r.Enqueue(context.Background(), "u2", "u2"); err != nil {
+		t.Errorf("expected a per-user job to be allowed alongside the all-users job, got %v", err)
+	}
+}
+
+func TestRun_RecategorizesAllPages(t *testing.T) {
+	var msgs []*models.EmailMessage
+	for i := 0; i < 5; i++ {
+		msgs = append(msgs, &models.EmailMessage{EmailMessageID: fmt.Sprintf("m%d", i), InternalDate: int64(100 - i)})
+	}
+	msgs[1].CategorizationConfidence = sql.NullFloat64{Float64: 1, Valid: true} // corrected by the user
+	jobs := &fakeJobs{stats: map[string][2]int{"u1": {5, 0}}}
+	messages := &fakeMessages{byUser: map[string][]*models.EmailMessage{"u1": msgs}, set: map[string]string{}}
+	queue := &fakeQueue{}
+	r := NewRunner(jobs, messages, &fakeCategorizer{fail: map[string]bool{"m3": true}}, queue)
+	r.BatchSize, r.Interval = 2, 0
+	monitor := health.NewMonitor()
+	r.Health = monitor.Register("jobs", 1, health.DefaultStallAfter, nil)
+
+	job, err := r.Enqueue(context.Background(), "u1", "u1")
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	task := queue.tasks[0]
+	task.Attempts = 1
+	if err := r.Run(context.Background(), task); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if job.Status != models.JobCompleted || job.Processed != 5 || job.Failed != 1 || job.FinishedAt == nil {
+		t.Errorf("unexpected job after run %+v", job)
+	}
+	if task.Total != 5 || task.Processed != 5 || task.Failed != 1 {
+		t.Errorf("expected the progress copied to the queue job, got %+v", task)
+	}
+	if len(messages.set) != 3 {
+		t.Errorf("expected 3 messages updated, got %v", messages.set)
+	}
+	if _, ok := messages.set["m1"]; ok {
+		t.Error("expected user-corrected message to be left alone")
+	}
+	stats := monitor.Status(context.Background())[0]
+	if m := stats.Jobs[JobTypeMessage]; m == nil || m.Attempted != 5 || m.Failed != 1 {
+		t.Errorf("unexpected message stats %+v", m)
+	}
+	if jobs.saves < 4 || queue.saves != jobs.saves {
+		t.Errorf("expected progress to be saved per batch to both, got %d and %d saves", jobs.saves, queue.saves)
+	}
+}
+
+func TestRun_RequeuesUntilAttemptsRunOut(t *testing.T) {
+	store := &fakeJobs{stats: map[string][2]int{"u1": {5, 0}}}
+	messages := &fakeMessages{err: errors.New("db down")}
+	queue := &fakeQueue{}
+	r := NewRunner(store, messages, &fakeCategorizer{}, queue)
+	ctx := context.Background()
+
+	job, err := r.Enqueue(ctx, "u1", "u1")
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	task := queue.tasks[0]
+	task.Attempts = 1
+	if err := r.Run(ctx, task); err == nil || job.Status != models.JobQueued || job.Error == "" || job.FinishedAt != nil {
+		t.Errorf("expected the job queued for a retry, got %+v (err %v)", job, err)
+	}
+	task.Attempts = task.MaxAttempts
+	if err := r.Run(ctx, task); err == nil || job.Status != models.JobFailed || job.FinishedAt == nil {
+		t.Errorf("expected the last attempt to fail the job, got %+v (err %v)", job, err)
+	}
+
+	// A job that no longer exists is not retried
+	if err := r.Run(ctx, &models.Job{RefID: 999, Attempts: 1, MaxAttempts: 3}); !jobs.IsPermanent(err) {
+		t.Errorf("expected a permanent error for a missing job, got %v", err)
+	}
+}
@@ -0,0 +1,369 @@
+package render
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// droppedTags are removed together with everything inside them
+var droppedTags = map[string]bool{
+	"head": true, "iframe": true, "math": true, "noscript": true, "object": true,
+	"script": true, "style": true, "svg": true, "template": true, "title": true,
+}
+
+// paragraphTags are separated from the surrounding text by a blank line, blockTags by a line break
+var (
+	paragraphTags = map[string]bool{
+		"blockquote": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+		"p": true, "pre": true, "table": true,
+	}
+	blockTags = map[string]bool{
+		"address": true, "article": true, "aside": true, "dd": true, "div": true, "dl": true,
+		"dt": true, "figcaption": true, "figure": true, "footer": true, "form": true, "header": true,
+		"main": true, "nav": true, "section": true, "tr": true,
+	}
+)
+
+// inlineMarks are the inline elements kept as Markdown emphasis
+var inlineMarks = map[string]string{
+	"b": "**", "strong": "**", "em": "_", "i": "_", "s": "~~", "del": "~~", "strike": "~~",
+}
+
+var (
+	// mdEscaper escapes the characters that would otherwise start Markdown (or raw HTML) inline
+	// syntax
+	mdEscaper = strings.NewReplacer(
+		`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`,
+	)
+	// mdLinkEscaper keeps a link address from ending the Markdown link early
+	mdLinkEscaper = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29")
+)
+
+// htmlList is an open <ul> or <ol>; next is the number of the next ordered item
+type htmlList struct {
+	ordered bool
+	next    int
+}
+
+// mark is an open inline element: open is written before its first word, so elements without
+// text leave nothing behind, and close after its last
+type mark struct {
+	tag     string
+	open    string
+	close   string
+	written bool
+}
+
+// PlainText converts an HTML body to plain text. Paragraphs and headings are separated by blank
+// lines, list items keep their bullets or numbers (indented when nested), quotes are prefixed
+// with "> " and links are followed by their address when it differs from the link text.
+// Scripts, styles and images are dropped.
+func PlainText(s string) string {
+	return convertHTML(s, false)
+}
+
+// Markdown converts an HTML body to Markdown: headings, emphasis, links, lists, quotes, code
+// and rules keep their structure, layout tables become one line per row, and scripts, styles
+// and images are dropped. Links are kept for web and mail addresses only.
+func Markdown(s string) string {
+	return convertHTML(s, true)
+}
+
+// convertHTML walks an HTML body once for both PlainText and Markdown. The block structure is
+// the same for both; markdown adds the inline syntax (emphasis, links, headings, code) and
+// escapes text that would read as Markdown.
+func convertHTML(s string, markdown bool) string {
+	w := &textWriter{}
+	z := html.NewTokenizer(strings.NewReader(s))
+	var (
+		skip   int // depth inside droppedTags
+		pre    int // depth inside <pre>
+		code   int // depth inside <code>
+		lists  []htmlList
+		href   string // plain text: the open link's address
+		linkAt int    // plain text: output length when the open link started
+	)
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		t := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedTags[t.Data] {
+				if tt == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			switch {
+			case t.Data == "br":
+				w.lineBreak()
+			case t.Data == "hr":
+				w.breakLines(2)
+				w.word("---")
+				w.breakLines(2)
+			case t.Data == "li":
+				w.breakLines(1)
+				marker := "-"
+				if n := len(lists); n > 0 && lists[n-1].ordered {
+					marker = strconv.Itoa(lists[n-1].next) + "."
+					lists[n-1].next++
+				}
+				if n := len(lists); n > 1 {
+					w.word(strings.Repeat("  ", n-1) + marker)
+				} else {
+					w.word(marker)
+				}
+				w.space = true
+			case t.Data == "td" || t.Data == "th":
+				w.space = true
+			case t.Data == "ul" || t.Data == "ol":
+				w.breakLines(listBreak(len(lists)))
+			case paragraphTags[t.Data]:
+				w.breakLines(2)
+			case blockTags[t.Data]:
+				w.breakLines(1)
+			}
+			if tt == html.SelfClosingTagToken {
+				continue
+			}
+			switch t.Data {
+			case "ul", "ol":
+				lists = append(lists, htmlList{ordered: t.Data == "ol", next: 1})
+			case "blockquote":
+				w.quote++
+			case "pre":
+				if markdown && pre == 0 {
+					w.word("```")
+					w.lineBreak()
+				}
+				pre++
+			case "code":
+				if markdown && pre == 0 {
+					w.marks = append(w.marks, mark{tag: "code", open: "`", close: "`"})
+				}
+				code++
+			case "a":
+				switch target := linkTarget(t); {
+				case !markdown:
+					href, linkAt = target, w.b.Len()
+				case target != "":
+					w.marks = append(w.marks, mark{tag: "a", open: "[", close: "](" + mdLinkEscaper.Replace(target) + ")"})
+				}
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				if markdown {
+					level := int(t.Data[1] - '0')
+					w.marks = append(w.marks, mark{tag: t.Data, open: strings.Repeat("#", level) + " "})
+				}
+			default:
+				if m, ok := inlineMarks[t.Data]; ok && markdown && pre == 0 && code == 0 {
+					w.marks = append(w.marks, mark{tag: t.Data, open: m, close: m})
+				}
+			}
+		case html.EndTagToken:
+			if droppedTags[t.Data] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			switch t.Data {
+			case "ul", "ol":
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+				}
+				w.breakLines(listBreak(len(lists)))
+			case "blockquote":
+				if w.quote > 0 {
+					w.breakLines(2)
+					w.quote--
+				}
+			case "pre":
+				if pre > 0 {
+					pre--
+					if markdown && pre == 0 {
+						w.breakLines(1)
+						w.word("```")
+					}
+				}
+			case "code":
+				if code > 0 {
+					code--
+				}
+			case "a":
+				if href != "" {
+					text := strings.TrimSpace(w.b.String()[linkAt:])
+					if text != href && text != strings.TrimPrefix(href, "mailto:") {
+						w.space = true
+						w.word("(" + href + ")")
+					}
+					href = ""
+				}
+			}
+			w.closeMark(t.Data)
+			if paragraphTags[t.Data] {
+				w.breakLines(2)
+			} else if blockTags[t.Data] || t.Data == "li" {
+				w.breakLines(1)
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			if pre > 0 {
+				w.preformatted(t.Data)
+			} else {
+				w.text(t.Data, markdown && code == 0)
+			}
+		}
+	}
+	return strings.TrimSpace(w.b.String())
+}
+
+// listBreak is the line breaks around a list: nested lists stay with their item
+func listBreak(depth int) int {
+	if depth > 0 {
+		return 1
+	}
+	return 2
+}
+
+// linkTarget is the address of an <a> worth keeping: web and mail links only
+func linkTarget(t html.Token) string {
+	for _, a := range t.Attr {
+		if a.Key != "href" {
+			continue
+		}
+		href := strings.TrimSpace(a.Val)
+		u, err := url.Parse(href)
+		if err != nil {
+			return ""
+		}
+		switch strings.ToLower(u.Scheme) {
+		case "http", "https", "mailto":
+			return href
+		}
+	}
+	return ""
+}
+
+// textWriter collapses whitespace the way a browser would, tracks line breaks so block
+// elements never stack up more than one blank line, and wraps words in the open inline marks
+type textWriter struct {
+	b        strings.Builder
+	newlines int  // trailing line breaks written
+	space    bool // whitespace seen since the last word
+	quote    int  // blockquote depth
+	marks    []mark
+}
+
+func (w *textWriter) text(s string, escape bool) {
+	if s == "" {
+		return
+	}
+	if r, _ := utf8.DecodeRuneInString(s); unicode.IsSpace(r) {
+		w.space = true
+	}
+	for i, f := range strings.Fields(s) {
+		if i > 0 {
+			w.space = true
+		}
+		if escape {
+			f = mdEscaper.Replace(f)
+		}
+		w.word(f)
+	}
+	if r, _ := utf8.DecodeLastRuneInString(s); unicode.IsSpace(r) {
+		w.space = true
+	}
+}
+
+func (w *textWriter) word(s string) {
+	switch {
+	case w.b.Len() == 0:
+	case w.newlines > 0:
+	case w.space:
+		w.b.WriteByte(' ')
+	}
+	w.linePrefix()
+	for i := range w.marks {
+		if !w.marks[i].written {
+			w.b.WriteString(w.marks[i].open)
+			w.marks[i].written = true
+		}
+	}
+	w.b.WriteString(s)
+	w.newlines, w.space = 0, false
+}
+
+// closeMark ends the innermost open mark of tag, closing the ones opened inside it as well
+func (w *textWriter) closeMark(tag string) {
+	for i := len(w.marks) - 1; i >= 0; i-- {
+		if w.marks[i].tag != tag {
+			continue
+		}
+		for j := len(w.marks) - 1; j >= i; j-- {
+			if w.marks[j].written {
+				w.b.WriteString(w.marks[j].close)
+			}
+		}
+		w.marks = w.marks[:i]
+		return
+	}
+}
+
+func (w *textWriter) preformatted(s string) {
+	for i, line := range strings.Split(s, "\n") {
+		if i > 0 {
+			w.b.WriteByte('\n')
+			w.newlines++
+		}
+		if line != "" {
+			w.linePrefix()
+			w.b.WriteString(line)
+			w.newlines = 0
+		}
+	}
+	w.space = false
+}
+
+// linePrefix writes the quote markers when at the start of a line
+func (w *textWriter) linePrefix() {
+	if w.quote > 0 && (w.b.Len() == 0 || w.newlines > 0) {
+		w.b.WriteString(strings.Repeat("> ", w.quote))
+	}
+}
+
+// lineBreak always ends the line, so consecutive <br>s can open blank lines
+func (w *textWriter) lineBreak() {
+	if w.newlines < 2 {
+		w.b.WriteByte('\n')
+		w.newlines++
+	}
+	w.space = false
+}
+
+// breakLines ends the current line with at least n line breaks, without adding any at the start
+func (w *textWriter) breakLines(n int) {
+	if w.b.Len() == 0 {
+		return
+	}
+	for w.newlines < n {
+		w.b.WriteByte('\n')
+		w.newlines++
+	}
+	w.space = false
+}
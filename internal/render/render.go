@@ -0,0 +1,124 @@
+// Package render converts HTML message bodies: to plain text for snippets, categorization and
+// summaries (see plaintext.Body), to Markdown for notes apps and LLM prompts, and to the
+// sanitized HTML that feeds and API clients can show safely. Its Renderer serves bodies in the
+// format a client asks for, keeping conversions in a small in-memory cache, as clients tend to
+// fetch the same message repeatedly.
+package render
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+)
+
+// Format is a representation of a message body
+type Format string
+
+const (
+	FormatPlain    Format = "plain"
+	FormatHTML     Format = "html"
+	FormatMarkdown Format = "markdown"
+)
+
+// ContentType is the media type a body in f is served with
+func (f Format) ContentType() string {
+	switch f {
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// ParseFormat reads a format name as accepted by the ?format query parameter
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatPlain, FormatHTML, FormatMarkdown:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q: use plain, html or markdown", s)
+}
+
+// DefaultCacheSize is how many converted bodies a Renderer keeps
+const DefaultCacheSize = 512
+
+// Renderer converts message bodies, remembering the most recent conversions of HTML bodies.
+// A nil *Renderer converts without caching.
+type Renderer struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List // of *entry, most recently used first
+	entries map[cacheKey]*list.Element
+}
+
+type cacheKey struct {
+	format Format
+	sum    [sha256.Size]byte
+}
+
+type entry struct {
+	key cacheKey
+	out string
+}
+
+// NewRenderer returns a Renderer caching up to maxEntries conversions
+func NewRenderer(maxEntries int) *Renderer {
+	return &Renderer{max: maxEntries, order: list.New(), entries: make(map[cacheKey]*list.Element)}
+}
+
+// Render returns a message body in format f. Plain text is the text part, or the HTML part
+// converted (see PlainText) when there is none; HTML is the HTML part sanitized (see
+// SanitizeHTML), or the text part escaped and preformatted; Markdown is the HTML part converted
+// (see Markdown), or the text part as is.
+func (r *Renderer) Render(f Format, text, htmlBody string) string {
+	// Text-only bodies are cheap to convert and not worth a cache slot
+	if htmlBody == "" || r == nil || r.max <= 0 {
+		return convert(f, text, htmlBody)
+	}
+	key := cacheKey{format: f, sum: sha256.Sum256([]byte(text + "\x00" + htmlBody))}
+	r.mu.Lock()
+	if el, ok := r.entries[key]; ok {
+		r.order.MoveToFront(el)
+		out := el.Value.(*entry).out
+		r.mu.Unlock()
+		return out
+	}
+	r.mu.Unlock()
+
+	out := convert(f, text, htmlBody)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[key]; !ok {
+		r.entries[key] = r.order.PushFront(&entry{key: key, out: out})
+		for r.order.Len() > r.max {
+			oldest := r.order.Back()
+			r.order.Remove(oldest)
+			delete(r.entries, oldest.Value.(*entry).key)
+		}
+	}
+	return out
+}
+
+func convert(f Format, text, htmlBody string) string {
+	switch f {
+	case FormatHTML:
+		if htmlBody != "" {
+			return SanitizeHTML(htmlBody)
+		}
+		return "<pre>" + html.EscapeString(text) + "</pre>"
+	case FormatMarkdown:
+		if htmlBody != "" {
+			return Markdown(htmlBody)
+		}
+		return text
+	}
+	if strings.TrimSpace(text) != "" || htmlBody == "" {
+		return text
+	}
+	return PlainText(htmlBody)
+}
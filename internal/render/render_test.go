@@ -0,0 +1,165 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlainText(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{
+			name: "paragraphs and headings",
+			in:   "<html><head><title>Hi</title><style>p{}</style></head><body><h1>Weekly  news</h1><p>First\n  line<br>second line</p><p>Next</p></body></html>",
+			want: "Weekly news\n\nFirst line\nsecond line\n\nNext",
+		},
+		{
+			name: "links",
+			in:   `<p>Read <a href="https://example.com/a">the post</a> or <a href="https://example.com/b">https://example.com/b</a>, mail <a href="mailto:me@example.com">me@example.com</a>. <a href="javascript:x()">Skip</a></p>`,
+			want: "Read the post (https://example.com/a) or https://example.com/b, mail me@example.com. Skip",
+		},
+		{
+			name: "lists",
+			in:   "<ul><li>One</li><li>Two<ol><li>a</li><li>b</li></ol></li></ul><p>After</p>",
+			want: "- One\n- Two\n  1. a\n  2. b\n\nAfter",
+		},
+		{
+			name: "quotes and entities",
+			in:   "<p>Caf&eacute; &amp; cr&egrave;me</p><blockquote><p>Quoted</p>text</blockquote>Done",
+			want: "Café & crème\n\n> Quoted\n\n> text\n\nDone",
+		},
+		{
+			name: "tables and scripts",
+			in:   "<table><tr><td>Price</td><td>9&nbsp;€</td></tr><tr><td>Total</td><td>10</td></tr></table><script>alert(1)</script>",
+			want: "Price 9 €\nTotal 10",
+		},
+		{
+			name: "preformatted",
+			in:   "<pre>a  b\n  c</pre>",
+			want: "a  b\n  c",
+		},
+		{
+			name: "non-latin text",
+			in:   "<div>こんにちは、<b>世界</b></div><div>Привет</div>",
+			want: "こんにちは、世界\nПривет",
+		},
+	}
+	for _, c := range cases {
+		if got := PlainText(c.in); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{
+			name: "headings and paragraphs",
+			in:   "<html><head><title>Hi</title><style>p{}</style></head><body><h1>Weekly  news</h1><p>First\n  line<br>second line</p><h3>More</h3><p>Next</p></body></html>",
+			want: "# Weekly news\n\nFirst line\nsecond line\n\n### More\n\nNext",
+		},
+		{
+			name: "emphasis and links",
+			in:   `<p>Read <a href="https://example.com/a">the <b>new</b> post</a>, <em>now</em>. <a href="javascript:x()">Skip</a><strong></strong></p>`,
+			want: "Read [the **new** post](https://example.com/a), _now_. Skip",
+		},
+		{
+			name: "lists",
+			in:   "<ul><li>One</li><li>Two<ol><li>a</li><li>b</li></ol></li></ul><p>After</p>",
+			want: "- One\n- Two\n  1. a\n  2. b\n\nAfter",
+		},
+		{
+			name: "quotes and escaping",
+			in:   "<p>2*3 &amp; a_b [x] &lt;tag&gt;</p><blockquote><p>Quoted</p>text</blockquote>",
+			want: "2\\*3 & a\\_b \\[x\\] \\<tag>\n\n> Quoted\n\n> text",
+		},
+		{
+			name: "code",
+			in:   "<p>Run <code>go test ./...</code></p><pre>if a &lt; b {\n\treturn\n}</pre><p>Done</p>",
+			want: "Run `go test ./...`\n\n```\nif a < b {\n\treturn\n}\n```\n\nDone",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Markdown(c.in); got != c.want {
+				t.Errorf("Markdown() =\n%q\nwant\n%q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeHTML(t *testing.T) {
+	cases := map[string]string{
+		`<p onclick="x()">Hi <b>there</b></p>`:                      `<p>Hi <b>there</b></p>`,
+		`<script>alert(1)</script>text`:                             `text`,
+		`<style>p{}</style><div>a<img src="https://t/p.gif"></div>`: `<div>a</div>`,
+		`<a href="javascript:alert(1)">x</a>`:                       `<a rel="noopener noreferrer">x</a>`,
+		`<a href="https://example.com/?a=1&b=2">x</a>`:              `<a href="https://example.com/?a=1&amp;b=2" rel="noopener noreferrer">x</a>`,
+		`<form><input value="x">5 &lt; 6</form>`:                    `5 &lt; 6`,
+		`<svg><a href="https://x">in svg</a></svg>after`:            `after`,
+	}
+	for in, want := range cases {
+		if got := SanitizeHTML(in); got != want {
+			t.Errorf("SanitizeHTML(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// BenchmarkSanitizeHTML sanitizes a newsletter-sized body with the markup it strips
+func BenchmarkSanitizeHTML(b *testing.B) {
+	body := "<html><head><style>td{padding:8px}</style></head><body><table>" +
+		strings.Repeat(`<tr><td onclick="track()"><img src="https://t.example.com/p.gif"><a href="https://example.com/story?id=1&amp;utm=x">Story</a><p style="color:red">Summary of the story.</p></td></tr>`, 200) +
+		"</table><script>track()</script></body></html>"
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if SanitizeHTML(body) == "" {
+			b.Fatal("expected output")
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	const htmlBody = `<p>Hello <b>there</b><script>alert(1)</script></p>`
+	cases := []struct {
+		format         Format
+		text, htmlBody string
+		want           string
+	}{
+		{FormatPlain, "", htmlBody, "Hello there"},
+		{FormatPlain, "Text part", htmlBody, "Text part"},
+		{FormatHTML, "", htmlBody, "<p>Hello <b>there</b></p>"},
+		{FormatHTML, "a < b", "", "<pre>a &lt; b</pre>"},
+		{FormatMarkdown, "", htmlBody, "Hello **there**"},
+		{FormatMarkdown, "Text part", "", "Text part"},
+	}
+	r := NewRenderer(DefaultCacheSize)
+	for _, c := range cases {
+		if got := r.Render(c.format, c.text, c.htmlBody); got != c.want {
+			t.Errorf("Render(%s, %q, %q) = %q, want %q", c.format, c.text, c.htmlBody, got, c.want)
+		}
+	}
+}
+
+func TestRenderer_Cache(t *testing.T) {
+	r := NewRenderer(2)
+	for _, body := range []string{"<p>a</p>", "<p>b</p>", "<p>a</p>", "<p>c</p>"} {
+		r.Render(FormatMarkdown, "", body)
+	}
+	// "a" was used after "b", so "b" is the one evicted
+	var cached []string
+	for el := r.order.Front(); el != nil; el = el.Next() {
+		cached = append(cached, el.Value.(*entry).out)
+	}
+	if strings.Join(cached, ",") != "c,a" || len(r.entries) != 2 {
+		t.Errorf("expected c and a to be cached, got %v", cached)
+	}
+
+	var uncached *Renderer
+	if got := uncached.Render(FormatPlain, "", "<p>x</p>"); got != "x" {
+		t.Errorf("nil Renderer rendered %q, want x", got)
+	}
+}
@@ -0,0 +1,100 @@
+package render
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedTags are the elements kept by SanitizeHTML, each with the attributes it may keep.
+// Images are dropped: in newsletters they are mostly tracking pixels.
+var allowedTags = map[string][]string{
+	"a": {"href", "title"}, "abbr": {"title"}, "b": nil, "blockquote": nil, "br": nil, "code": nil,
+	"div": nil, "em": nil, "h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"hr": nil, "i": nil, "li": nil, "ol": nil, "p": nil, "pre": nil, "s": nil, "span": nil,
+	"strong": nil, "sub": nil, "sup": nil, "table": nil, "tbody": nil, "td": {"colspan", "rowspan"},
+	"tfoot": nil, "th": {"colspan", "rowspan"}, "thead": nil, "tr": nil, "u": nil, "ul": nil,
+}
+
+// allowedSchemes are the link schemes SanitizeHTML keeps; relative links are dropped too, as they
+// have nothing to resolve against in a feed reader or a client showing a single message
+var allowedSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// SanitizeHTML reduces an email's HTML to a small set of formatting elements that feed readers
+// and API clients can show safely: scripts, styles, event handlers, forms, images and non-http
+// links are removed, text is kept
+func SanitizeHTML(s string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	skip := 0 // depth inside droppedTags
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return ""
+			}
+			return b.String()
+		}
+		t := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedTags[t.Data] {
+				if tt == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			attrs, ok := allowedTags[t.Data]
+			if !ok {
+				continue
+			}
+			b.WriteString("<" + t.Data)
+			for _, a := range t.Attr {
+				if a.Namespace != "" || !contains(attrs, a.Key) {
+					continue
+				}
+				if a.Key == "href" && !safeLink(a.Val) {
+					continue
+				}
+				b.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+			}
+			if t.Data == "a" {
+				b.WriteString(` rel="noopener noreferrer"`)
+			}
+			b.WriteString(">")
+		case html.EndTagToken:
+			if droppedTags[t.Data] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if _, ok := allowedTags[t.Data]; ok && skip == 0 {
+				b.WriteString("</" + t.Data + ">")
+			}
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(html.EscapeString(t.Data))
+			}
+		}
+	}
+}
+
+func safeLink(href string) bool {
+	u, err := url.Parse(strings.TrimSpace(href))
+	return err == nil && allowedSchemes[strings.ToLower(u.Scheme)]
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+// Defaults for settings that leave the subject or intro empty
+const (
+	DefaultSubject = "Your inbox this week: {minutes_saved} minutes saved"
+	DefaultIntro   = "Here is what Inbox Whisperer did for your inbox since {since}."
+)
+
+// Placeholders lists what Compose replaces in the subject and intro
+var Placeholders = []string{"{since}", "{processed}", "{archived}", "{unsubscribes}", "{minutes_saved}"}
+
+const footer = "You get this email because weekly reports are on in your Inbox Whisperer settings."
+
+// Message is a plain-text email
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+}
+
+// Compose writes the weekly report for stats to the address to, using the subject, intro and
+// sections from settings
+func Compose(to string, settings *models.ReportSettings, stats *models.UserStats) *Message {
+	r := strings.NewReplacer(
+		"{since}", stats.Since.Format("Jan 2"),
+		"{processed}", fmt.Sprint(stats.MessagesProcessed),
+		"{archived}", fmt.Sprint(stats.Archived),
+		"{unsubscribes}", fmt.Sprint(stats.Unsubscribes),
+		"{minutes_saved}", fmt.Sprintf("%.0f", stats.EstimatedMinutesSaved),
+	)
+	subject, intro := settings.Subject, settings.Intro
+	if subject == "" {
+		subject = DefaultSubject
+	}
+	if intro == "" {
+		intro = DefaultIntro
+	}
+	sections := settings.Sections
+	if len(sections) == 0 {
+		sections = models.ReportSections
+	}
+
+	var b strings.Builder
+	b.WriteString(r.Replace(intro))
+	b.WriteString("\n\n")
+	for _, section := range sections {
+		writeSection(&b, section, stats)
+	}
+	b.WriteString("\n-- \n")
+	b.WriteString(footer)
+	b.WriteString("\n")
+	return &Message{To: to, Subject: r.Replace(subject), Text: b.String()}
+}
+
+func writeSection(b *strings.Builder, section string, stats *models.UserStats) {
+	switch section {
+	case models.ReportSectionProcessed:
+		fmt.Fprintf(b, "Messages processed: %d\n", stats.MessagesProcessed)
+	case models.ReportSectionArchived:
+		fmt.Fprintf(b, "Archived: %d\n", stats.Archived)
+	case models.ReportSectionUnsubscribes:
+		fmt.Fprintf(b, "Unsubscribed: %d\n", stats.Unsubscribes)
+	case models.ReportSectionTimeSaved:
+		fmt.Fprintf(b, "Estimated time saved: %.0f minutes\n", stats.EstimatedMinutesSaved)
+	case models.ReportSectionInboxTrend:
+		if len(stats.InboxSizeTrend) == 0 {
+			b.WriteString("Inbox size by week: no data yet\n")
+			return
+		}
+		b.WriteString("Inbox size by week:\n")
+		for _, p := range stats.InboxSizeTrend {
+			fmt.Fprintf(b, "  %s: %.0f\n", p.WeekStart.Format("Jan 2"), p.AverageSize)
+		}
+	}
+}
+
+// Bytes encodes the message as RFC 2822 with a quoted-printable UTF-8 body. An empty from leaves
+// the From header out for the sending service to fill in.
+func (m *Message) Bytes(from string, date time.Time) []byte {
+	var b bytes.Buffer
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(m.Text, "\n", "\r\n")))
+	qp.Close()
+	return b.Bytes()
+}
@@ -0,0 +1,78 @@
+package reports
+
+import (
+	"mime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func testStats() *models.UserStats {
+	return &models.UserStats{
+		Since:                 time.Date(2026, 10, 8, 9, 0, 0, 0, time.UTC),
+		MessagesProcessed:     120,
+		Archived:              45,
+		Unsubscribes:          3,
+		EstimatedMinutesSaved: 61.6,
+		InboxSizeTrend:        []models.InboxSizePoint{{WeekStart: time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC), AverageSize: 210.4}},
+	}
+}
+
+func TestComposeDefaults(t *testing.T) {
+	msg := Compose("me@example.com", &models.ReportSettings{}, testStats())
+	if msg.To != "me@example.com" || msg.Subject != "Your inbox this week: 62 minutes saved" {
+		t.Errorf("unexpected header fields: %+v", msg)
+	}
+	for _, want := range []string{"since Oct 8.", "Messages processed: 120", "Archived: 45", "Unsubscribed: 3",
+		"Estimated time saved: 62 minutes", "  Oct 5: 210", footer} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("report text lacks %q:\n%s", want, msg.Text)
+		}
+	}
+}
+
+func TestComposeCustomized(t *testing.T) {
+	settings := &models.ReportSettings{
+		Subject:  "{archived} archived",
+		Intro:    "Hi! {processed} messages.",
+		Sections: []string{models.ReportSectionUnsubscribes, models.ReportSectionArchived},
+	}
+	msg := Compose("me@example.com", settings, testStats())
+	if msg.Subject != "45 archived" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+	if !strings.HasPrefix(msg.Text, "Hi! 120 messages.\n\nUnsubscribed: 3\nArchived: 45\n") {
+		t.Errorf("unexpected text:\n%s", msg.Text)
+	}
+	if strings.Contains(msg.Text, "Messages processed") {
+		t.Error("unselected section was included")
+	}
+}
+
+func TestMessageBytes(t *testing.T) {
+	msg := &Message{To: "me@example.com", Subject: "Grüße", Text: "line one\nline two"}
+	raw := string(msg.Bytes("reports@example.com", time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)))
+	head, body, ok := strings.Cut(raw, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("no header/body separator:\n%s", raw)
+	}
+	for _, want := range []string{"From: reports@example.com", "To: me@example.com", "Date: Thu, 15 Oct 2026 09:00:00 +0000",
+		"Content-Transfer-Encoding: quoted-printable"} {
+		if !strings.Contains(head, want) {
+			t.Errorf("headers lack %q:\n%s", want, head)
+		}
+	}
+	subject := head[strings.Index(head, "Subject: ")+len("Subject: "):]
+	subject = subject[:strings.Index(subject, "\r\n")]
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(subject); err != nil || decoded != "Grüße" {
+		t.Errorf("Subject decodes to %q, %v", decoded, err)
+	}
+	if body != "line one\r\nline two" {
+		t.Errorf("body = %q", body)
+	}
+	if strings.Contains(string(msg.Bytes("", time.Now())), "From:") {
+		t.Error("empty from should leave the header out")
+	}
+}
@@ -0,0 +1,117 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/config"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"golang.org/x/oauth2"
+)
+
+// ErrNoAccount means the user has no linked Gmail account to send reports from
+var ErrNoAccount = errors.New("no linked Gmail account")
+
+// defaultSMTPPort is the submission port used when smtp.port is not set
+const defaultSMTPPort = 587
+
+// Mailer sends reports one way (see models.ReportViaAccount and models.ReportViaSMTP)
+type Mailer interface {
+	// Ready returns an error if the mailer cannot send on the user's behalf right now
+	Ready(ctx context.Context, userID string) error
+	Send(ctx context.Context, userID string, msg *Message) error
+}
+
+// RawSender sends an RFC 2822 message from the token's mailbox (see gmail.GmailService.SendRaw)
+type RawSender interface {
+	SendRaw(ctx context.Context, token *oauth2.Token, raw []byte) error
+}
+
+// AccountMailer sends reports from the user's default Gmail account, which must have granted
+// the send scope
+type AccountMailer struct {
+	tokens data.UserTokenRepository
+	scopes data.TokenScopeRepository
+	sender RawSender
+	now    func() time.Time
+}
+
+func NewAccountMailer(tokens data.UserTokenRepository, scopes data.TokenScopeRepository, sender RawSender) *AccountMailer {
+	return &AccountMailer{tokens: tokens, scopes: scopes, sender: sender, now: time.Now}
+}
+
+// Ready returns ErrNoAccount without a linked account and a *provider.ScopeError when the send
+// scope is known to be missing. Accounts whose scopes are unknown pass.
+func (m *AccountMailer) Ready(ctx context.Context, userID string) error {
+	granted, err := m.scopes.GetGrantedScopes(ctx, userID, data.ProviderGmail, "")
+	if errors.Is(err, data.ErrNotFound) {
+		return ErrNoAccount
+	}
+	if err != nil {
+		return err
+	}
+	if granted != nil {
+		if missing := gmail.MissingScopes(provider.FeatureSend, granted); len(missing) > 0 {
+			return &provider.ScopeError{Feature: provider.FeatureSend, Missing: missing}
+		}
+	}
+	return nil
+}
+
+func (m *AccountMailer) Send(ctx context.Context, userID string, msg *Message) error {
+	if err := m.Ready(ctx, userID); err != nil {
+		return err
+	}
+	tok, err := m.tokens.GetUserToken(ctx, userID, data.ProviderGmail, "")
+	if errors.Is(err, data.ErrNotFound) {
+		return ErrNoAccount
+	}
+	if err != nil {
+		return err
+	}
+	return m.sender.SendRaw(ctx, tok, msg.Bytes("", m.now()))
+}
+
+// SMTPMailer sends reports through the deployment's mail server. net/smtp takes no context, so
+// a send is bounded only by the server's own timeouts.
+type SMTPMailer struct {
+	cfg config.SMTPConfig
+	// PasswordFunc, if set, returns the current password so rotated secrets apply without a
+	// restart; cfg.Password is used otherwise
+	PasswordFunc func() string
+
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	now      func() time.Time
+}
+
+func NewSMTPMailer(cfg config.SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg, sendMail: smtp.SendMail, now: time.Now}
+}
+
+// Ready always succeeds: the server is shared by every user
+func (m *SMTPMailer) Ready(context.Context, string) error {
+	return nil
+}
+
+func (m *SMTPMailer) Send(_ context.Context, _ string, msg *Message) error {
+	port := m.cfg.Port
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		password := m.cfg.Password
+		if m.PasswordFunc != nil {
+			password = m.PasswordFunc()
+		}
+		auth = smtp.PlainAuth("", m.cfg.Username, password, m.cfg.Host)
+	}
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(port))
+	return m.sendMail(addr, auth, m.cfg.From, []string{msg.To}, msg.Bytes(m.cfg.From, m.now()))
+}
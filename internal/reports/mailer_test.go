@@ -0,0 +1,89 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/config"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"golang.org/x/oauth2"
+)
+
+// fakeAccount holds one user's Gmail token and granted scopes
+type fakeAccount struct {
+	userID  string
+	granted []string
+	sent    [][]byte
+}
+
+func (f *fakeAccount) GetUserToken(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
+	if userID != f.userID {
+		return nil, data.ErrNotFound
+	}
+	return &oauth2.Token{AccessToken: "tok"}, nil
+}
+func (f *fakeAccount) SaveUserToken(ctx context.Context, userID, provider, accountID string, token *oauth2.Token) error {
+	return nil
+}
+func (f *fakeAccount) GetGrantedScopes(ctx context.Context, userID, provider, accountID string) ([]string, error) {
+	if userID != f.userID {
+		return nil, data.ErrNotFound
+	}
+	return f.granted, nil
+}
+func (f *fakeAccount) SendRaw(ctx context.Context, token *oauth2.Token, raw []byte) error {
+	f.sent = append(f.sent, raw)
+	return nil
+}
+
+func TestAccountMailer(t *testing.T) {
+	account := &fakeAccount{userID: "u1", granted: []string{gmail.ScopeReadonly}}
+	m := NewAccountMailer(account, account, account)
+	msg := &Message{To: "me@example.com", Subject: "s", Text: "t"}
+
+	if err := m.Send(context.Background(), "u2", msg); !errors.Is(err, ErrNoAccount) {
+		t.Errorf("expected ErrNoAccount, got %v", err)
+	}
+	var scopeErr *provider.ScopeError
+	if err := m.Send(context.Background(), "u1", msg); !errors.As(err, &scopeErr) || scopeErr.Feature != provider.FeatureSend {
+		t.Errorf("expected a send scope error, got %v", err)
+	}
+	if len(account.sent) != 0 {
+		t.Fatal("sent without the send scope")
+	}
+
+	account.granted = append(account.granted, gmail.ScopeSend)
+	if err := m.Send(context.Background(), "u1", msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(account.sent) != 1 || strings.Contains(string(account.sent[0]), "From:") {
+		t.Errorf("unexpected raw message: %q", account.sent)
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	m := NewSMTPMailer(config.SMTPConfig{Host: "mail.example.com", Username: "bot", Password: "old", From: "reports@example.com"})
+	m.PasswordFunc = func() string { return "rotated" }
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg []byte
+	var gotAuth smtp.Auth
+	m.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotAuth, gotFrom, gotTo, gotMsg = addr, a, from, to, msg
+		return nil
+	}
+	if err := m.Send(context.Background(), "u1", &Message{To: "me@example.com", Subject: "s", Text: "t"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if gotAddr != "mail.example.com:587" || gotFrom != "reports@example.com" || len(gotTo) != 1 || gotTo[0] != "me@example.com" {
+		t.Errorf("unexpected envelope: %s %s %v", gotAddr, gotFrom, gotTo)
+	}
+	if gotAuth == nil || !strings.Contains(string(gotMsg), "From: reports@example.com") {
+		t.Errorf("unexpected auth or message: %v %q", gotAuth, gotMsg)
+	}
+}
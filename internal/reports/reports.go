@@ -0,0 +1,308 @@
+// Package reports emails users an opt-in weekly summary of their triage stats, sent from their
+// own Gmail account or through the deployment's mail server
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/health"
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/scheduler"
+	telemetryerrors "github.com/desponda/inbox-whisperer/internal/telemetry/errors"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrInvalidSettings wraps every validation failure of Configure
+	ErrInvalidSettings = errors.New("invalid report settings")
+	// ErrViaUnavailable means the deployment has no mailer for the chosen way of sending
+	ErrViaUnavailable = errors.New("report delivery method is not available")
+)
+
+// Limits on the customizable text
+const (
+	MaxSubjectLength = 200
+	MaxIntroLength   = 2000
+)
+
+// Worker pacing. A claimed report whose worker stopped before rescheduling it is tried again
+// after sendLease.
+const (
+	idlePoll    = time.Minute
+	sendLease   = 30 * time.Minute
+	sendTimeout = time.Minute
+)
+
+// JobTypeReport is the job type reported to the health monitor
+const JobTypeReport = "weekly_report"
+
+// StatsSource computes a user's weekly stats (see analytics.Service)
+type StatsSource interface {
+	Stats(ctx context.Context, userID string) (*models.UserStats, error)
+}
+
+// UserLookup resolves the address reports are sent to
+type UserLookup interface {
+	GetByID(ctx context.Context, id string) (*models.User, error)
+}
+
+// Service stores users' report settings and sends each report when it is due
+type Service struct {
+	repo     data.ReportRepository
+	stats    StatsSource
+	users    UserLookup
+	settings data.UserSettingsRepository
+	mailers  map[string]Mailer
+
+	// Health, if set, receives heartbeats and send outcomes
+	Health *health.Worker
+	// Maintenance, if set, pauses the worker while it is on
+	Maintenance *maintenance.Switch
+	// Errors, if set, receives failed sends
+	Errors telemetryerrors.Reporter
+
+	now func() time.Time
+}
+
+func NewService(repo data.ReportRepository, stats StatsSource, users UserLookup, settings data.UserSettingsRepository) *Service {
+	return &Service{
+		repo:     repo,
+		stats:    stats,
+		users:    users,
+		settings: settings,
+		mailers:  map[string]Mailer{},
+		now:      time.Now,
+	}
+}
+
+// SetMailer makes reports sendable one way (models.ReportViaAccount or models.ReportViaSMTP)
+func (s *Service) SetMailer(via string, m Mailer) {
+	s.mailers[via] = m
+}
+
+// Settings returns the user's report settings
+func (s *Service) Settings(ctx context.Context, userID string) (*models.ReportSettings, error) {
+	return s.repo.GetSettings(ctx, userID)
+}
+
+// Configure validates and stores the user's report settings. Saving enabled settings schedules
+// the next report for the coming chosen weekday; an error from the mailer's Ready is returned
+// as is so the caller can tell the user what to fix.
+func (s *Service) Configure(ctx context.Context, userID string, in *models.ReportSettings) (*models.ReportSettings, error) {
+	if err := validate(in); err != nil {
+		return nil, err
+	}
+	cur, err := s.repo.GetSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	next := &models.ReportSettings{
+		UserID:     userID,
+		Enabled:    in.Enabled,
+		Via:        in.Via,
+		Weekday:    in.Weekday,
+		Subject:    in.Subject,
+		Intro:      in.Intro,
+		Sections:   in.Sections,
+		LastSentAt: cur.LastSentAt,
+	}
+	if next.Enabled {
+		m, ok := s.mailers[next.Via]
+		if !ok {
+			return nil, ErrViaUnavailable
+		}
+		if err := m.Ready(ctx, userID); err != nil {
+			return nil, err
+		}
+		window, loc := s.window(ctx, userID)
+		due := nextDue(s.now(), time.Weekday(next.Weekday), window, loc)
+		next.NextDueAt = &due
+	}
+	if err := s.repo.SaveSettings(ctx, next); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+func validate(in *models.ReportSettings) error {
+	if in.Via != models.ReportViaAccount && in.Via != models.ReportViaSMTP {
+		return fmt.Errorf("%w: via must be %q or %q", ErrInvalidSettings, models.ReportViaAccount, models.ReportViaSMTP)
+	}
+	if in.Weekday < 0 || in.Weekday > 6 {
+		return fmt.Errorf("%w: weekday must be 0 (Sunday) to 6 (Saturday)", ErrInvalidSettings)
+	}
+	if len(in.Subject) > MaxSubjectLength || strings.ContainsAny(in.Subject, "\r\n") {
+		return fmt.Errorf("%w: subject must be one line of at most %d bytes", ErrInvalidSettings, MaxSubjectLength)
+	}
+	if len(in.Intro) > MaxIntroLength {
+		return fmt.Errorf("%w: intro must be at most %d bytes", ErrInvalidSettings, MaxIntroLength)
+	}
+	for i, section := range in.Sections {
+		if !slices.Contains(models.ReportSections, section) {
+			return fmt.Errorf("%w: unknown section %q", ErrInvalidSettings, section)
+		}
+		if slices.Contains(in.Sections[:i], section) {
+			return fmt.Errorf("%w: section %q is listed twice", ErrInvalidSettings, section)
+		}
+	}
+	return nil
+}
+
+// Preview composes the report the user would get now with their saved settings
+func (s *Service) Preview(ctx context.Context, userID string) (*Message, error) {
+	settings, err := s.repo.GetSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.compose(ctx, settings)
+}
+
+func (s *Service) compose(ctx context.Context, settings *models.ReportSettings) (*Message, error) {
+	user, err := s.users.GetByID(ctx, settings.UserID)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.stats.Stats(ctx, settings.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return Compose(user.Email, settings, stats), nil
+}
+
+// Deliveries returns the user's most recent send attempts, newest first
+func (s *Service) Deliveries(ctx context.Context, userID string, limit int) ([]*models.ReportDelivery, error) {
+	return s.repo.ListDeliveries(ctx, userID, limit)
+}
+
+// Pending reports how many reports are due and since when the oldest has been due
+func (s *Service) Pending(ctx context.Context) (int, *time.Time, error) {
+	return s.repo.Pending(ctx, s.now())
+}
+
+// Start runs the worker until ctx is cancelled
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(idlePoll)
+		defer ticker.Stop()
+		for {
+			s.drain(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// drain sends due reports until none are left
+func (s *Service) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		s.Health.Beat()
+		if err := maintenance.Wait(ctx, s.Maintenance, s.Health.Beat); err != nil {
+			return
+		}
+		now := s.now()
+		settings, err := s.repo.ClaimDue(ctx, now, now.Add(sendLease))
+		if err != nil {
+			log.Error().Err(err).Msg("reports: failed to claim a report")
+			return
+		}
+		if settings == nil {
+			return
+		}
+		s.run(ctx, settings)
+	}
+}
+
+// run sends one claimed report, or defers it to the user's delivery window, and schedules the
+// next. A failed send is logged and not retried; the next report goes out the following week.
+func (s *Service) run(ctx context.Context, settings *models.ReportSettings) {
+	window, loc := s.window(ctx, settings.UserID)
+	now := s.now()
+	if !window.Allowed(now) {
+		s.reschedule(settings.UserID, window.Next(now), nil)
+		return
+	}
+	d, err := s.send(ctx, settings)
+	if ctx.Err() != nil {
+		// Shutting down: the claim lapses and the report is tried again then
+		return
+	}
+	sentAt := s.now()
+	d.UserID, d.Via, d.Status = settings.UserID, settings.Via, models.ReportSent
+	if err != nil {
+		d.Status, d.Error = models.ReportFailed, err.Error()
+		log.Warn().Err(err).Str("userID", settings.UserID).Str("via", settings.Via).Msg("reports: send failed")
+		telemetryerrors.Capture(ctx, s.Errors, err, settings.UserID, map[string]string{"job_type": JobTypeReport, "via": settings.Via})
+	}
+	s.Health.Record(JobTypeReport, err)
+	if err := s.repo.LogDelivery(context.Background(), d); err != nil {
+		log.Error().Err(err).Str("userID", settings.UserID).Msg("reports: failed to log delivery")
+	}
+	var sent *time.Time
+	if err == nil {
+		sent = &sentAt
+	}
+	s.reschedule(settings.UserID, nextDue(sentAt, time.Weekday(settings.Weekday), window, loc), sent)
+}
+
+// send composes and sends the report; the returned delivery carries what is known of the
+// message even when sending fails
+func (s *Service) send(ctx context.Context, settings *models.ReportSettings) (*models.ReportDelivery, error) {
+	d := &models.ReportDelivery{PeriodStart: s.now().Add(-7 * 24 * time.Hour)}
+	m, ok := s.mailers[settings.Via]
+	if !ok {
+		return d, ErrViaUnavailable
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	msg, err := s.compose(ctx, settings)
+	if err != nil {
+		return d, err
+	}
+	d.Recipient, d.Subject = msg.To, msg.Subject
+	return d, m.Send(ctx, settings.UserID, msg)
+}
+
+func (s *Service) reschedule(userID string, next time.Time, sentAt *time.Time) {
+	if err := s.repo.Reschedule(context.Background(), userID, next, sentAt); err != nil {
+		log.Error().Err(err).Str("userID", userID).Msg("reports: failed to schedule the next report")
+	}
+}
+
+// window returns the user's delivery window and time zone, falling back to any time in UTC
+// when their settings cannot be read
+func (s *Service) window(ctx context.Context, userID string) (*scheduler.DeliveryWindow, *time.Location) {
+	us, err := s.settings.Get(ctx, userID)
+	if err == nil {
+		var w *scheduler.DeliveryWindow
+		if w, err = scheduler.NewDeliveryWindow(us); err == nil {
+			// NewDeliveryWindow has already checked the zone
+			loc, _ := time.LoadLocation(us.Timezone)
+			return w, loc
+		}
+	}
+	log.Warn().Err(err).Str("userID", userID).Msg("reports: using the default delivery window")
+	w, _ := scheduler.NewDeliveryWindow(&models.UserSettings{})
+	return w, time.UTC
+}
+
+// nextDue returns the start of the delivery window on the first local weekday after the day
+// of t
+func nextDue(t time.Time, weekday time.Weekday, window *scheduler.DeliveryWindow, loc *time.Location) time.Time {
+	local := t.In(loc)
+	days := (int(weekday) - int(local.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	midnight := time.Date(local.Year(), local.Month(), local.Day()+days, 0, 0, 0, 0, loc)
+	return window.Next(midnight)
+}
@@ -0,0 +1,193 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+type memoryReports struct {
+	settings   map[string]*models.ReportSettings
+	deliveries []*models.ReportDelivery
+}
+
+func (m *memoryReports) GetSettings(ctx context.Context, userID string) (*models.ReportSettings, error) {
+	if s, ok := m.settings[userID]; ok {
+		return s, nil
+	}
+	return &models.ReportSettings{UserID: userID, Via: models.ReportViaAccount, Weekday: 1}, nil
+}
+func (m *memoryReports) SaveSettings(ctx context.Context, s *models.ReportSettings) error {
+	m.settings[s.UserID] = s
+	return nil
+}
+func (m *memoryReports) ClaimDue(ctx context.Context, now, leaseUntil time.Time) (*models.ReportSettings, error) {
+	for _, s := range m.settings {
+		if s.Enabled && s.NextDueAt != nil && !s.NextDueAt.After(now) {
+			s.NextDueAt = &leaseUntil
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+func (m *memoryReports) Reschedule(ctx context.Context, userID string, next time.Time, sentAt *time.Time) error {
+	s := m.settings[userID]
+	s.NextDueAt = &next
+	if sentAt != nil {
+		s.LastSentAt = sentAt
+	}
+	return nil
+}
+func (m *memoryReports) LogDelivery(ctx context.Context, d *models.ReportDelivery) error {
+	d.ID = int64(len(m.deliveries) + 1)
+	m.deliveries = append(m.deliveries, d)
+	return nil
+}
+func (m *memoryReports) ListDeliveries(ctx context.Context, userID string, limit int) ([]*models.ReportDelivery, error) {
+	return m.deliveries, nil
+}
+func (m *memoryReports) Pending(ctx context.Context, now time.Time) (int, *time.Time, error) {
+	return 0, nil, nil
+}
+
+type fixedStats struct{}
+
+func (fixedStats) Stats(ctx context.Context, userID string) (*models.UserStats, error) {
+	return testStats(), nil
+}
+
+type fixedUsers struct{}
+
+func (fixedUsers) GetByID(ctx context.Context, id string) (*models.User, error) {
+	return &models.User{ID: id, Email: id + "@example.com"}, nil
+}
+
+type fixedSettings struct {
+	settings *models.UserSettings
+}
+
+func (f fixedSettings) Get(ctx context.Context, userID string) (*models.UserSettings, error) {
+	return f.settings, nil
+}
+func (f fixedSettings) Upsert(ctx context.Context, s *models.UserSettings) error { return nil }
+
+// fakeMailer records sent messages and fails with err
+type fakeMailer struct {
+	ready error
+	err   error
+	sent  []*Message
+}
+
+func (f *fakeMailer) Ready(ctx context.Context, userID string) error { return f.ready }
+func (f *fakeMailer) Send(ctx context.Context, userID string, msg *Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func newTestService(us *models.UserSettings, now time.Time) (*Service, *memoryReports, *fakeMailer) {
+	repo := &memoryReports{settings: map[string]*models.ReportSettings{}}
+	s := NewService(repo, fixedStats{}, fixedUsers{}, fixedSettings{us})
+	mailer := &fakeMailer{}
+	s.SetMailer(models.ReportViaAccount, mailer)
+	s.now = func() time.Time { return now }
+	return s, repo, mailer
+}
+
+func TestConfigure(t *testing.T) {
+	// Thursday 15 October 2026, 18:00 in Berlin
+	now := time.Date(2026, 10, 15, 16, 0, 0, 0, time.UTC)
+	us := &models.UserSettings{Timezone: "Europe/Berlin", WorkingHoursStart: "09:00", WorkingHoursEnd: "17:00"}
+	s, _, mailer := newTestService(us, now)
+	ctx := context.Background()
+
+	for _, bad := range []*models.ReportSettings{
+		{Via: "pigeon"},
+		{Via: models.ReportViaAccount, Weekday: 7},
+		{Via: models.ReportViaAccount, Subject: "two\nlines"},
+		{Via: models.ReportViaAccount, Sections: []string{"weather"}},
+		{Via: models.ReportViaAccount, Sections: []string{models.ReportSectionArchived, models.ReportSectionArchived}},
+	} {
+		if _, err := s.Configure(ctx, "u1", bad); !errors.Is(err, ErrInvalidSettings) {
+			t.Errorf("Configure(%+v) = %v, want ErrInvalidSettings", bad, err)
+		}
+	}
+	if _, err := s.Configure(ctx, "u1", &models.ReportSettings{Enabled: true, Via: models.ReportViaSMTP}); !errors.Is(err, ErrViaUnavailable) {
+		t.Errorf("expected ErrViaUnavailable, got %v", err)
+	}
+	mailer.ready = ErrNoAccount
+	if _, err := s.Configure(ctx, "u1", &models.ReportSettings{Enabled: true, Via: models.ReportViaAccount}); !errors.Is(err, ErrNoAccount) {
+		t.Errorf("expected the mailer's error, got %v", err)
+	}
+	mailer.ready = nil
+
+	got, err := s.Configure(ctx, "u1", &models.ReportSettings{Enabled: true, Via: models.ReportViaAccount, Weekday: int(time.Monday)})
+	if err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	// Monday 19 October, 09:00 in Berlin
+	if want := time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC); got.NextDueAt == nil || !got.NextDueAt.Equal(want) {
+		t.Errorf("NextDueAt = %v, want %v", got.NextDueAt, want)
+	}
+
+	got, err = s.Configure(ctx, "u1", &models.ReportSettings{Via: models.ReportViaSMTP})
+	if err != nil || got.NextDueAt != nil {
+		t.Errorf("disabling = %+v, %v", got, err)
+	}
+}
+
+func TestDrainSendsDueReports(t *testing.T) {
+	now := time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)
+	s, repo, mailer := newTestService(&models.UserSettings{}, now)
+	due := now.Add(-time.Minute)
+	repo.settings["u1"] = &models.ReportSettings{UserID: "u1", Enabled: true, Via: models.ReportViaAccount, Weekday: 1, NextDueAt: &due}
+
+	s.drain(context.Background())
+	if len(mailer.sent) != 1 || mailer.sent[0].To != "u1@example.com" {
+		t.Fatalf("sent = %+v", mailer.sent)
+	}
+	if len(repo.deliveries) != 1 || repo.deliveries[0].Status != models.ReportSent || repo.deliveries[0].Recipient != "u1@example.com" {
+		t.Errorf("deliveries = %+v", repo.deliveries)
+	}
+	settings := repo.settings["u1"]
+	if want := time.Date(2026, 10, 26, 0, 0, 0, 0, time.UTC); !settings.NextDueAt.Equal(want) || settings.LastSentAt == nil {
+		t.Errorf("rescheduled to %v (last sent %v), want %v", settings.NextDueAt, settings.LastSentAt, want)
+	}
+}
+
+func TestDrainLogsFailures(t *testing.T) {
+	now := time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)
+	s, repo, mailer := newTestService(&models.UserSettings{}, now)
+	mailer.err = errors.New("boom")
+	due := now.Add(-time.Minute)
+	repo.settings["u1"] = &models.ReportSettings{UserID: "u1", Enabled: true, Via: models.ReportViaAccount, Weekday: 1, NextDueAt: &due}
+
+	s.drain(context.Background())
+	if len(repo.deliveries) != 1 || repo.deliveries[0].Status != models.ReportFailed || repo.deliveries[0].Error != "boom" {
+		t.Errorf("deliveries = %+v", repo.deliveries)
+	}
+	if settings := repo.settings["u1"]; settings.LastSentAt != nil || !settings.NextDueAt.After(now) {
+		t.Errorf("failed send should move on to next week without a last sent time: %+v", settings)
+	}
+}
+
+func TestDrainDefersOutsideDeliveryWindow(t *testing.T) {
+	// Saturday
+	now := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	s, repo, mailer := newTestService(&models.UserSettings{WeekendPause: true}, now)
+	due := now.Add(-time.Minute)
+	repo.settings["u1"] = &models.ReportSettings{UserID: "u1", Enabled: true, Via: models.ReportViaAccount, Weekday: 6, NextDueAt: &due}
+
+	s.drain(context.Background())
+	if len(mailer.sent) != 0 || len(repo.deliveries) != 0 {
+		t.Fatalf("sent during the weekend pause: %+v", mailer.sent)
+	}
+	if want := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC); !repo.settings["u1"].NextDueAt.Equal(want) {
+		t.Errorf("deferred to %v, want %v", repo.settings["u1"].NextDueAt, want)
+	}
+}
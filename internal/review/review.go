@@ -0,0 +1,172 @@
+// Package review runs inbox zero review sessions: a queue of the user's oldest unhandled
+// messages served one at a time, each with the actions the rules and the message's signals
+// suggest, until every message has been decided.
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/rules"
+	"github.com/jackc/pgx/v5"
+)
+
+// Session sizes: DefaultSize when none is asked for, at most MaxSize
+const (
+	DefaultSize = 50
+	MaxSize     = 200
+)
+
+// Where a suggestion comes from
+const (
+	SourceRule     = "rule"
+	SourceProvider = "provider"
+	SourceContact  = "contact"
+	SourceCategory = "category"
+)
+
+// ContactLookup returns what is known of a correspondent (see contacts.Service)
+type ContactLookup interface {
+	Get(ctx context.Context, userID, address string) (*models.ContactStats, error)
+}
+
+// Suggestion is an action proposed for the message under review. Action is one of the message
+// actions or keep; Label is set for apply_label.
+type Suggestion struct {
+	Action string `json:"action"`
+	Label  string `json:"label,omitempty"`
+	Reason string `json:"reason"`
+	Source string `json:"source"`
+}
+
+// Card is what a client shows next: the message under review and its suggestions, or Done once
+// the queue is empty
+type Card struct {
+	Session     *models.ReviewSession `json:"session"`
+	Done        bool                  `json:"done"`
+	Item        *models.ReviewItem    `json:"item,omitempty"`
+	Message     *models.EmailSummary  `json:"message,omitempty"`
+	Suggestions []Suggestion          `json:"suggestions,omitempty"`
+}
+
+// Service creates sessions and serves their queues
+type Service struct {
+	Sessions data.ReviewSessionRepository
+	Messages data.EmailMessageRepository
+	Rules    data.RuleRepository
+	// Contacts, if set, suggests keeping mail from people the user replies to
+	Contacts ContactLookup
+}
+
+func NewService(sessions data.ReviewSessionRepository, messages data.EmailMessageRepository, rules data.RuleRepository) *Service {
+	return &Service{Sessions: sessions, Messages: messages, Rules: rules}
+}
+
+// Start queues up to size of the user's oldest unhandled messages, DefaultSize when size is
+// not positive and at most MaxSize
+func (s *Service) Start(ctx context.Context, userID string, size int) (*models.ReviewSession, error) {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	size = min(size, MaxSize)
+	return s.Sessions.Create(ctx, userID, size)
+}
+
+// Get returns the user's session; data.ErrNotFound if there is none
+func (s *Service) Get(ctx context.Context, userID string, id int64) (*models.ReviewSession, error) {
+	return s.Sessions.Get(ctx, userID, id)
+}
+
+// Next returns the card for the first pending message of the session. Messages no longer in the
+// cache, because they were deleted or moved since the session started, are skipped.
+func (s *Service) Next(ctx context.Context, userID string, id int64) (*Card, error) {
+	for {
+		item, err := s.Sessions.NextItem(ctx, userID, id)
+		if errors.Is(err, data.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		msg, err := s.Messages.GetMessageByID(ctx, userID, item.EmailMessageID)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && msg == nil) {
+			if err := s.Sessions.Decide(ctx, userID, id, item.EmailMessageID, models.ReviewSkip); err != nil && !errors.Is(err, data.ErrNotFound) {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("review: load message: %w", err)
+		}
+		session, err := s.Sessions.Get(ctx, userID, id)
+		if err != nil {
+			return nil, err
+		}
+		summary := msg.Summary()
+		return &Card{Session: session, Item: item, Message: &summary, Suggestions: s.suggest(ctx, userID, msg)}, nil
+	}
+	session, err := s.Sessions.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return &Card{Session: session, Done: true}, nil
+}
+
+// Decide records the decision taken on a message of the session; data.ErrNotFound if the
+// message is not pending in it
+func (s *Service) Decide(ctx context.Context, userID string, id int64, messageID, action string) error {
+	return s.Sessions.Decide(ctx, userID, id, messageID, action)
+}
+
+// suggest proposes actions for msg, the user's own rules first. Lookups that fail only cost
+// suggestions, so they are skipped.
+func (s *Service) suggest(ctx context.Context, userID string, msg *models.EmailMessage) []Suggestion {
+	var out []Suggestion
+	add := func(sg Suggestion) {
+		for _, o := range out {
+			if o.Action == sg.Action && o.Label == sg.Label {
+				return
+			}
+		}
+		out = append(out, sg)
+	}
+	if s.Rules != nil {
+		if list, err := s.Rules.ListByUser(ctx, userID); err == nil {
+			for _, rule := range list {
+				if !rule.Enabled || !rules.Matches(rule, msg) {
+					continue
+				}
+				for _, a := range rule.Actions {
+					switch a.Type {
+					case models.RuleActionArchive, models.RuleActionMarkRead:
+						add(Suggestion{Action: a.Type, Reason: "matches your rule " + rule.Name, Source: SourceRule})
+					case models.RuleActionApplyLabel:
+						add(Suggestion{Action: a.Type, Label: a.Label, Reason: "matches your rule " + rule.Name, Source: SourceRule})
+					}
+				}
+			}
+		}
+	}
+	if msg.ProviderImportant {
+		add(Suggestion{Action: models.ReviewKeep, Reason: "marked important by your provider", Source: SourceProvider})
+	}
+	if s.Contacts != nil && msg.SenderAddress != "" {
+		if c, err := s.Contacts.Get(ctx, userID, msg.SenderAddress); err == nil && c.UserReplies > 0 {
+			add(Suggestion{Action: models.ReviewKeep, Reason: "you have replied to this sender", Source: SourceContact})
+		}
+	}
+	category := msg.Category.String
+	if category == "" {
+		category = msg.ProviderCategory
+	}
+	switch category {
+	case "Promotions/Ads", "Social", "Forums":
+		add(Suggestion{Action: models.RuleActionArchive, Reason: "categorized as " + category, Source: SourceCategory})
+	case "Updates":
+		add(Suggestion{Action: models.RuleActionMarkRead, Reason: "categorized as " + category, Source: SourceCategory})
+	}
+	return out
+}
@@ -0,0 +1,156 @@
+package review
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeSessions queues the messages it is given, in order, for session 1
+type fakeSessions struct {
+	items []*models.ReviewItem
+	limit int
+}
+
+func (f *fakeSessions) Create(ctx context.Context, userID string, limit int) (*models.ReviewSession, error) {
+	f.limit = limit
+	return f.Get(ctx, userID, 1)
+}
+
+func (f *fakeSessions) Get(ctx context.Context, userID string, id int64) (*models.ReviewSession, error) {
+	if id != 1 {
+		return nil, data.ErrNotFound
+	}
+	s := &models.ReviewSession{ID: 1, UserID: userID, Total: len(f.items)}
+	for _, it := range f.items {
+		if it.Action != "" {
+			s.Reviewed++
+		}
+	}
+	return s, nil
+}
+
+func (f *fakeSessions) NextItem(ctx context.Context, userID string, id int64) (*models.ReviewItem, error) {
+	for _, it := range f.items {
+		if it.Action == "" {
+			return it, nil
+		}
+	}
+	return nil, data.ErrNotFound
+}
+
+func (f *fakeSessions) Decide(ctx context.Context, userID string, id int64, messageID, action string) error {
+	for _, it := range f.items {
+		if it.EmailMessageID == messageID && it.Action == "" {
+			it.Action = action
+			return nil
+		}
+	}
+	return data.ErrNotFound
+}
+
+type fakeMessages struct {
+	data.EmailMessageRepository
+	msgs map[string]*models.EmailMessage
+}
+
+func (f *fakeMessages) GetMessageByID(ctx context.Context, userID, id string) (*models.EmailMessage, error) {
+	if m, ok := f.msgs[id]; ok {
+		return m, nil
+	}
+	return nil, pgx.ErrNoRows
+}
+
+type fakeRules struct {
+	data.RuleRepository
+	rules []*models.Rule
+}
+
+func (f *fakeRules) ListByUser(ctx context.Context, userID string) ([]*models.Rule, error) {
+	return f.rules, nil
+}
+
+type fakeContacts map[string]*models.ContactStats
+
+func (f fakeContacts) Get(ctx context.Context, userID, address string) (*models.ContactStats, error) {
+	if c, ok := f[address]; ok {
+		return c, nil
+	}
+	return nil, data.ErrNotFound
+}
+
+func TestService_Start(t *testing.T) {
+	sessions := &fakeSessions{}
+	s := NewService(sessions, nil, nil)
+	for size, want := range map[int]int{0: DefaultSize, 10: 10, MaxSize + 1: MaxSize} {
+		if _, err := s.Start(context.Background(), "u1", size); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		if sessions.limit != want {
+			t.Errorf("size %d: expected %d queued at most, got %d", size, want, sessions.limit)
+		}
+	}
+}
+
+func TestService_Next(t *testing.T) {
+	sessions := &fakeSessions{items: []*models.ReviewItem{
+		{Position: 1, EmailMessageID: "gone"},
+		{Position: 2, EmailMessageID: "m1"},
+	}}
+	messages := &fakeMessages{msgs: map[string]*models.EmailMessage{
+		"m1": {
+			EmailMessageID:    "m1",
+			Sender:            "Deals <deals@shop.example>",
+			SenderAddress:     "deals@shop.example",
+			Subject:           "50% off",
+			Category:          sql.NullString{String: "Promotions/Ads", Valid: true},
+			ProviderImportant: true,
+		},
+	}}
+	rules := &fakeRules{rules: []*models.Rule{
+		{Name: "shop", Enabled: true, Conditions: []models.RuleCondition{{Field: models.RuleFieldFrom, Contains: "shop.example"}},
+			Actions: []models.RuleAction{{Type: models.RuleActionApplyLabel, Label: "Shopping"}, {Type: models.RuleActionArchive}}},
+		{Name: "off", Enabled: false, Conditions: []models.RuleCondition{{Field: models.RuleFieldFrom, Contains: "shop"}},
+			Actions: []models.RuleAction{{Type: models.RuleActionMarkRead}}},
+	}}
+	s := NewService(sessions, messages, rules)
+	s.Contacts = fakeContacts{"deals@shop.example": {UserReplies: 2}}
+	ctx := context.Background()
+
+	card, err := s.Next(ctx, "u1", 1)
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if card.Done || card.Message == nil || card.Message.ID != "m1" {
+		t.Fatalf("expected the missing message skipped and m1 served, got %+v", card)
+	}
+	if sessions.items[0].Action != models.ReviewSkip {
+		t.Errorf("expected the missing message to be skipped, got %q", sessions.items[0].Action)
+	}
+	want := []Suggestion{
+		{Action: models.RuleActionApplyLabel, Label: "Shopping", Source: SourceRule},
+		{Action: models.RuleActionArchive, Source: SourceRule},
+		{Action: models.ReviewKeep, Source: SourceProvider},
+	}
+	if len(card.Suggestions) != len(want) {
+		t.Fatalf("expected %d suggestions, got %+v", len(want), card.Suggestions)
+	}
+	for i, w := range want {
+		got := card.Suggestions[i]
+		if got.Action != w.Action || got.Label != w.Label || got.Source != w.Source {
+			t.Errorf("suggestion %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+
+	if err := s.Decide(ctx, "u1", 1, "m1", models.RuleActionArchive); err != nil {
+		t.Fatalf("Decide failed: %v", err)
+	}
+	card, err = s.Next(ctx, "u1", 1)
+	if err != nil || !card.Done || card.Session.Reviewed != 2 {
+		t.Errorf("expected the session done with both reviewed, got %+v (err %v)", card, err)
+	}
+}
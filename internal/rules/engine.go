@@ -0,0 +1,185 @@
+// Package rules evaluates user-defined rules against newly synced messages.
+package rules
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+// ErrInvalidRule is returned by Validate for malformed rules
+var ErrInvalidRule = errors.New("invalid rule")
+
+var validFields = map[string]bool{
+	models.RuleFieldFrom:     true,
+	models.RuleFieldSubject:  true,
+	models.RuleFieldSnippet:  true,
+	models.RuleFieldCategory: true,
+}
+
+// LabelApplier adds a label (by name, creating it on demand) to a message
+type LabelApplier interface {
+	ApplyLabel(ctx context.Context, userID string, token *oauth2.Token, messageID, labelName string) error
+}
+
+// CategorySetter overwrites a cached message's category
+type CategorySetter interface {
+	SetCategory(ctx context.Context, userID, emailMessageID, category string, confidence float64) error
+}
+
+// MessageActioner archives messages and marks them read at the provider
+type MessageActioner interface {
+	Archive(ctx context.Context, userID string, token *oauth2.Token, messageID string) error
+	MarkRead(ctx context.Context, userID string, token *oauth2.Token, messageID string) error
+}
+
+// MutedThreads reports whether a user muted a thread
+type MutedThreads interface {
+	IsMuted(ctx context.Context, userID, threadID string) (bool, error)
+}
+
+// Engine runs a user's enabled rules and performs their actions
+type Engine struct {
+	repo       data.RuleRepository
+	labels     LabelApplier
+	categories CategorySetter
+	messages   MessageActioner
+
+	// Muted, when set, archives new messages in threads the user muted; optional
+	Muted MutedThreads
+}
+
+func NewEngine(repo data.RuleRepository, labels LabelApplier, categories CategorySetter, messages MessageActioner) *Engine {
+	return &Engine{repo: repo, labels: labels, categories: categories, messages: messages}
+}
+
+// Validate checks that a rule has a name, at least one well-formed condition and action
+func Validate(rule *models.Rule) error {
+	if strings.TrimSpace(rule.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	if len(rule.Conditions) == 0 {
+		return fmt.Errorf("%w: at least one condition is required", ErrInvalidRule)
+	}
+	for _, c := range rule.Conditions {
+		if !validFields[c.Field] {
+			return fmt.Errorf("%w: unknown condition field %q", ErrInvalidRule, c.Field)
+		}
+		if strings.TrimSpace(c.Contains) == "" {
+			return fmt.Errorf("%w: condition on %q needs a contains value", ErrInvalidRule, c.Field)
+		}
+	}
+	if len(rule.Actions) == 0 {
+		return fmt.Errorf("%w: at least one action is required", ErrInvalidRule)
+	}
+	for _, a := range rule.Actions {
+		switch a.Type {
+		case models.RuleActionApplyLabel:
+			if strings.TrimSpace(a.Label) == "" {
+				return fmt.Errorf("%w: apply_label needs a label name", ErrInvalidRule)
+			}
+		case models.RuleActionSetCategory:
+			if strings.TrimSpace(a.Category) == "" {
+				return fmt.Errorf("%w: set_category needs a category name", ErrInvalidRule)
+			}
+		case models.RuleActionArchive, models.RuleActionMarkRead:
+		default:
+			return fmt.Errorf("%w: unknown action type %q", ErrInvalidRule, a.Type)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether every condition of the rule matches the message
+func Matches(rule *models.Rule, msg *models.EmailMessage) bool {
+	for _, c := range rule.Conditions {
+		var value string
+		switch c.Field {
+		case models.RuleFieldFrom:
+			value = msg.Sender
+		case models.RuleFieldSubject:
+			value = msg.Subject
+		case models.RuleFieldSnippet:
+			value = msg.Snippet
+		case models.RuleFieldCategory:
+			value = msg.Category.String
+		default:
+			return false
+		}
+		if !strings.Contains(strings.ToLower(value), strings.ToLower(c.Contains)) {
+			return false
+		}
+	}
+	return len(rule.Conditions) > 0
+}
+
+// ApplyRules runs the user's enabled rules against msg. Every matching rule's actions
+// are attempted; failures are logged and the first one is returned.
+func (e *Engine) ApplyRules(ctx context.Context, userID string, token *oauth2.Token, msg *models.EmailMessage) error {
+	var firstErr error
+	if e.threadMuted(ctx, userID, msg) {
+		if err := e.messages.Archive(ctx, userID, token, msg.EmailMessageID); err != nil {
+			log.Error().Str("userID", userID).Str("threadID", msg.ThreadID).Str("messageID", msg.EmailMessageID).Err(err).Msg("ApplyRules: archiving muted thread message failed")
+			firstErr = err
+		}
+	}
+	rules, err := e.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if !rule.Enabled || !Matches(rule, msg) {
+			continue
+		}
+		for _, action := range rule.Actions {
+			if err := e.apply(ctx, userID, token, msg, action); err != nil {
+				log.Error().Str("userID", userID).Int64("ruleID", rule.ID).Str("messageID", msg.EmailMessageID).Err(err).Msg("ApplyRules: action failed")
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+	}
+	return firstErr
+}
+
+// threadMuted reports whether msg belongs to a thread the user muted; lookup failures count as not muted
+func (e *Engine) threadMuted(ctx context.Context, userID string, msg *models.EmailMessage) bool {
+	if e.Muted == nil || msg.ThreadID == "" {
+		return false
+	}
+	muted, err := e.Muted.IsMuted(ctx, userID, msg.ThreadID)
+	if err != nil {
+		log.Error().Str("userID", userID).Str("threadID", msg.ThreadID).Err(err).Msg("ApplyRules: muted thread lookup failed")
+		return false
+	}
+	return muted
+}
+
+func (e *Engine) apply(ctx context.Context, userID string, token *oauth2.Token, msg *models.EmailMessage, action models.RuleAction) error {
+	switch action.Type {
+	case models.RuleActionApplyLabel:
+		return e.labels.ApplyLabel(ctx, userID, token, msg.EmailMessageID, action.Label)
+	case models.RuleActionSetCategory:
+		// User rules are authoritative, so they override any automatic categorization
+		if err := e.categories.SetCategory(ctx, userID, msg.EmailMessageID, action.Category, 1); err != nil {
+			return err
+		}
+		msg.Category = sql.NullString{String: action.Category, Valid: true}
+		msg.CategorizationConfidence = sql.NullFloat64{Float64: 1, Valid: true}
+		return nil
+	case models.RuleActionArchive:
+		return e.messages.Archive(ctx, userID, token, msg.EmailMessageID)
+	case models.RuleActionMarkRead:
+		return e.messages.MarkRead(ctx, userID, token, msg.EmailMessageID)
+	default:
+		return fmt.Errorf("%w: unknown action type %q", ErrInvalidRule, action.Type)
+	}
+}
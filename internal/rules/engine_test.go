@@ -0,0 +1,199 @@
+package rules
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"golang.org/x/oauth2"
+)
+
+type fakeRuleRepo struct {
+	rules []*models.Rule
+}
+
+func (f *fakeRuleRepo) Create(ctx context.Context, rule *models.Rule) error { return nil }
+func (f *fakeRuleRepo) ListByUser(ctx context.Context, userID string) ([]*models.Rule, error) {
+	return f.rules, nil
+}
+func (f *fakeRuleRepo) Delete(ctx context.Context, userID string, id int64) error { return nil }
+
+type fakeCategories struct {
+	set map[string]string // msgID -> category
+}
+
+func (f *fakeCategories) SetCategory(ctx context.Context, userID, emailMessageID, category string, confidence float64) error {
+	if f.set == nil {
+		f.set = map[string]string{}
+	}
+	f.set[emailMessageID] = category
+	return nil
+}
+
+type fakeMessages struct {
+	archived, read []string
+}
+
+func (f *fakeMessages) Archive(ctx context.Context, userID string, token *oauth2.Token, messageID string) error {
+	f.archived = append(f.archived, messageID)
+	return nil
+}
+
+func (f *fakeMessages) MarkRead(ctx context.Context, userID string, token *oauth2.Token, messageID string) error {
+	f.read = append(f.read, messageID)
+	return nil
+}
+
+type fakeLabels struct {
+	applied map[string][]string // msgID -> label names
+	err     error
+}
+
+func (f *fakeLabels) ApplyLabel(ctx context.Context, userID string, token *oauth2.Token, messageID, labelName string) error {
+	if f.err != nil {
+		return f.err
+	}
+	if f.applied == nil {
+		f.applied = map[string][]string{}
+	}
+	f.applied[messageID] = append(f.applied[messageID], labelName)
+	return nil
+}
+
+func receiptsRule(enabled bool) *models.Rule {
+	return &models.Rule{
+		ID:   1,
+		Name: "Receipts",
+		Conditions: []models.RuleCondition{
+			{Field: models.RuleFieldSubject, Contains: "receipt"},
+			{Field: models.RuleFieldFrom, Contains: "@shop.example"},
+		},
+		Actions: []models.RuleAction{{Type: models.RuleActionApplyLabel, Label: "Finance/Receipts"}},
+		Enabled: enabled,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(receiptsRule(true)); err != nil {
+		t.Fatalf("expected valid rule, got %v", err)
+	}
+	bad := []*models.Rule{
+		{Name: "", Conditions: receiptsRule(true).Conditions, Actions: receiptsRule(true).Actions},
+		{Name: "x", Actions: receiptsRule(true).Actions},
+		{Name: "x", Conditions: []models.RuleCondition{{Field: "body", Contains: "x"}}, Actions: receiptsRule(true).Actions},
+		{Name: "x", Conditions: receiptsRule(true).Conditions, Actions: []models.RuleAction{{Type: models.RuleActionApplyLabel}}},
+		{Name: "x", Conditions: receiptsRule(true).Conditions, Actions: []models.RuleAction{{Type: "delete"}}},
+		{Name: "x", Conditions: receiptsRule(true).Conditions, Actions: []models.RuleAction{{Type: models.RuleActionSetCategory}}},
+	}
+	for i, r := range bad {
+		if err := Validate(r); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("case %d: expected ErrInvalidRule, got %v", i, err)
+		}
+	}
+}
+
+func TestEngine_ApplyRules(t *testing.T) {
+	ctx := context.Background()
+	labels := &fakeLabels{}
+	engine := NewEngine(&fakeRuleRepo{rules: []*models.Rule{receiptsRule(true)}}, labels, nil, nil)
+
+	match := &models.EmailMessage{EmailMessageID: "m1", Subject: "Your Receipt #42", Sender: "Orders <orders@shop.example>"}
+	miss := &models.EmailMessage{EmailMessageID: "m2", Subject: "Your receipt", Sender: "someone@else.example"}
+	for _, msg := range []*models.EmailMessage{match, miss} {
+		if err := engine.ApplyRules(ctx, "user1", nil, msg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := labels.applied["m1"]; len(got) != 1 || got[0] != "Finance/Receipts" {
+		t.Errorf("expected m1 labelled Finance/Receipts, got %v", got)
+	}
+	if _, ok := labels.applied["m2"]; ok {
+		t.Error("expected m2 not to match (all conditions must match)")
+	}
+
+	// Disabled rules are skipped
+	labels = &fakeLabels{}
+	engine = NewEngine(&fakeRuleRepo{rules: []*models.Rule{receiptsRule(false)}}, labels, nil, nil)
+	_ = engine.ApplyRules(ctx, "user1", nil, match)
+	if len(labels.applied) != 0 {
+		t.Errorf("expected disabled rule not to run, got %v", labels.applied)
+	}
+
+	// Action failures are reported
+	engine = NewEngine(&fakeRuleRepo{rules: []*models.Rule{receiptsRule(true)}}, &fakeLabels{err: errors.New("boom")}, nil, nil)
+	if err := engine.ApplyRules(ctx, "user1", nil, match); err == nil {
+		t.Error("expected action error to be returned")
+	}
+}
+
+func TestEngine_SetCategory(t *testing.T) {
+	rule := &models.Rule{
+		Name:       "Bank is Finance",
+		Conditions: []models.RuleCondition{{Field: models.RuleFieldFrom, Contains: "alerts@bank.example"}},
+		Actions:    []models.RuleAction{{Type: models.RuleActionSetCategory, Category: "Finance"}},
+		Enabled:    true,
+	}
+	categories := &fakeCategories{}
+	engine := NewEngine(&fakeRuleRepo{rules: []*models.Rule{rule}}, &fakeLabels{}, categories, nil)
+	msg := &models.EmailMessage{EmailMessageID: "m1", Sender: "Bank <alerts@bank.example>"}
+	if err := engine.ApplyRules(context.Background(), "user1", nil, msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if categories.set["m1"] != "Finance" || msg.Category.String != "Finance" {
+		t.Errorf("expected m1 categorized as Finance, got %v / %+v", categories.set, msg.Category)
+	}
+}
+
+func TestEngine_ArchiveAndMarkRead(t *testing.T) {
+	archiveNewsletters := &models.Rule{
+		Name:       "Archive newsletters",
+		Conditions: []models.RuleCondition{{Field: models.RuleFieldFrom, Contains: "news@shop.example"}},
+		Actions:    []models.RuleAction{{Type: models.RuleActionArchive}},
+		Enabled:    true,
+	}
+	readPromotions := &models.Rule{
+		Name:       "Mark promotions read",
+		Conditions: []models.RuleCondition{{Field: models.RuleFieldCategory, Contains: "Promotions/Ads"}},
+		Actions:    []models.RuleAction{{Type: models.RuleActionMarkRead}},
+		Enabled:    true,
+	}
+	for _, r := range []*models.Rule{archiveNewsletters, readPromotions} {
+		if err := Validate(r); err != nil {
+			t.Fatalf("expected valid rule, got %v", err)
+		}
+	}
+	messages := &fakeMessages{}
+	engine := NewEngine(&fakeRuleRepo{rules: []*models.Rule{archiveNewsletters, readPromotions}}, &fakeLabels{}, &fakeCategories{}, messages)
+	msg := &models.EmailMessage{EmailMessageID: "m1", Sender: "news@shop.example", Category: sql.NullString{String: "Promotions/Ads", Valid: true}}
+	if err := engine.ApplyRules(context.Background(), "user1", nil, msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(messages.archived) != 1 || len(messages.read) != 1 {
+		t.Errorf("expected m1 archived and marked read, got %+v", messages)
+	}
+}
+
+type fakeMuted map[string]bool
+
+func (f fakeMuted) IsMuted(ctx context.Context, userID, threadID string) (bool, error) {
+	return f[threadID], nil
+}
+
+func TestEngine_ArchivesMutedThreads(t *testing.T) {
+	messages := &fakeMessages{}
+	engine := NewEngine(&fakeRuleRepo{}, &fakeLabels{}, &fakeCategories{}, messages)
+	engine.Muted = fakeMuted{"t1": true}
+	for _, msg := range []*models.EmailMessage{
+		{EmailMessageID: "m1", ThreadID: "t1"},
+		{EmailMessageID: "m2", ThreadID: "t2"},
+	} {
+		if err := engine.ApplyRules(context.Background(), "user1", nil, msg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(messages.archived) != 1 || messages.archived[0] != "m1" {
+		t.Errorf("expected only the muted thread's message to be archived, got %v", messages.archived)
+	}
+}
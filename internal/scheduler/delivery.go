@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	// The backend image has no zoneinfo; embed it so user time zones resolve
+	_ "time/tzdata"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+var ErrInvalidDeliveryWindow = errors.New("invalid delivery window")
+
+// DeliveryWindow is when a user accepts digests and notifications, from their working hours
+// and weekend pause settings. Anything due outside it is deferred to Next.
+type DeliveryWindow struct {
+	loc          *time.Location
+	start, end   int // minutes after local midnight; equal means all day
+	weekendPause bool
+}
+
+// NewDeliveryWindow returns the user's delivery window. Without working hours every time of
+// day is allowed; a start after the end spans midnight (e.g. 22:00-06:00).
+func NewDeliveryWindow(s *models.UserSettings) (*DeliveryWindow, error) {
+	w := &DeliveryWindow{loc: time.UTC, weekendPause: s.WeekendPause}
+	if s.Timezone != "" {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidDeliveryWindow, s.Timezone)
+		}
+		w.loc = loc
+	}
+	if (s.WorkingHoursStart == "") != (s.WorkingHoursEnd == "") {
+		return nil, fmt.Errorf("%w: working hours need both a start and an end", ErrInvalidDeliveryWindow)
+	}
+	if s.WorkingHoursStart == "" {
+		return w, nil
+	}
+	var err error
+	if w.start, err = parseClock(s.WorkingHoursStart); err != nil {
+		return nil, err
+	}
+	if w.end, err = parseClock(s.WorkingHoursEnd); err != nil {
+		return nil, err
+	}
+	if w.start == w.end {
+		return nil, fmt.Errorf("%w: working hours must not start and end at the same time", ErrInvalidDeliveryWindow)
+	}
+	return w, nil
+}
+
+// parseClock parses "HH:MM" into minutes after midnight
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not an HH:MM time", ErrInvalidDeliveryWindow, v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Allowed reports whether something may be delivered at t
+func (w *DeliveryWindow) Allowed(t time.Time) bool {
+	t = t.In(w.loc)
+	if w.weekendPause && (t.Weekday() == time.Saturday || t.Weekday() == time.Sunday) {
+		return false
+	}
+	if w.start == w.end {
+		return true
+	}
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// Next returns the earliest time at or after t when something may be delivered
+func (w *DeliveryWindow) Next(t time.Time) time.Time {
+	if w.Allowed(t) {
+		return t
+	}
+	// Allowed periods only ever begin at a window start or at a local midnight (when a weekend
+	// pause ends), so checking those for the coming week finds the next one
+	local := t.In(w.loc)
+	for day := 0; day <= 7; day++ {
+		y, m, d := local.Year(), local.Month(), local.Day()+day
+		midnight := time.Date(y, m, d, 0, 0, 0, 0, w.loc)
+		opens := time.Date(y, m, d, w.start/60, w.start%60, 0, 0, w.loc)
+		for _, c := range []time.Time{midnight, opens} {
+			if c.After(t) && w.Allowed(c) {
+				return c
+			}
+		}
+	}
+	return t
+}
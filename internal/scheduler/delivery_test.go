@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+func TestDeliveryWindow(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour, minute int) time.Time {
+		// October 2026: the 16th is a Friday
+		return time.Date(2026, time.October, day, hour, minute, 0, 0, berlin)
+	}
+	w, err := NewDeliveryWindow(&models.UserSettings{
+		Timezone: "Europe/Berlin", WorkingHoursStart: "09:00", WorkingHoursEnd: "17:30", WeekendPause: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name     string
+		now, due time.Time
+	}{
+		{"inside working hours", at(15, 10, 0), at(15, 10, 0)},
+		{"before the start", at(15, 7, 0), at(15, 9, 0)},
+		{"after the end", at(15, 17, 30), at(16, 9, 0)},
+		{"friday evening waits for monday", at(16, 18, 0), at(19, 9, 0)},
+		{"weekend", at(17, 12, 0), at(19, 9, 0)},
+		{"other time zones are converted", at(15, 8, 0).UTC(), at(15, 9, 0)},
+	}
+	for _, tt := range tests {
+		if got := w.Next(tt.now); !got.Equal(tt.due) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.due, got)
+		}
+	}
+}
+
+func TestDeliveryWindow_Overnight(t *testing.T) {
+	w, err := NewDeliveryWindow(&models.UserSettings{WorkingHoursStart: "22:00", WorkingHoursEnd: "06:00", WeekendPause: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sunday := time.Date(2026, time.October, 18, 23, 0, 0, 0, time.UTC)
+	if got, want := w.Next(sunday), time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("expected the window to resume at Monday midnight, got %v", got)
+	}
+	monday := time.Date(2026, time.October, 19, 5, 59, 0, 0, time.UTC)
+	if !w.Allowed(monday) {
+		t.Error("expected early Monday to be inside the overnight window")
+	}
+}
+
+func TestNewDeliveryWindow_Invalid(t *testing.T) {
+	for _, s := range []models.UserSettings{
+		{Timezone: "Mars/Olympus"},
+		{WorkingHoursStart: "09:00"},
+		{WorkingHoursStart: "9am", WorkingHoursEnd: "17:00"},
+		{WorkingHoursStart: "09:00", WorkingHoursEnd: "09:00"},
+	} {
+		if _, err := NewDeliveryWindow(&s); !errors.Is(err, ErrInvalidDeliveryWindow) {
+			t.Errorf("%+v: expected ErrInvalidDeliveryWindow, got %v", s, err)
+		}
+	}
+	w, err := NewDeliveryWindow(&models.UserSettings{})
+	if err != nil || !w.Allowed(time.Date(2026, time.October, 17, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected default settings to allow any time, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+// Package scheduler runs background mailbox syncs on a per-user cadence. Each user belongs to a
+// sync tier (e.g. free every 15 minutes, paid every 2 minutes, manual-only) and admins may
+// override a user's interval, which lets operators bound provider quota consumption.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/health"
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/debuglog"
+	telemetryerrors "github.com/desponda/inbox-whisperer/internal/telemetry/errors"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+// Built-in tiers; an interval of 0 means the user is only synced on request
+const (
+	TierFree   = "free"
+	TierPaid   = "paid"
+	TierManual = "manual"
+)
+
+// DefaultTick is how often the scheduler looks for users that are due
+const DefaultTick = time.Minute
+
+// JobTypeSync is the job type reported to the health monitor for each user sync
+const JobTypeSync = "sync"
+
+var (
+	ErrUnknownTier     = errors.New("unknown sync tier")
+	ErrInvalidInterval = errors.New("sync interval must not be negative")
+)
+
+// DefaultTiers returns the built-in tier intervals
+func DefaultTiers() map[string]time.Duration {
+	return map[string]time.Duration{
+		TierFree:   15 * time.Minute,
+		TierPaid:   2 * time.Minute,
+		TierManual: 0,
+	}
+}
+
+// ParseTiers merges configured tiers, given as Go durations, into the built-in ones
+func ParseTiers(configured map[string]string) (map[string]time.Duration, error) {
+	tiers := DefaultTiers()
+	for name, v := range configured {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("sync tier %q: %w", name, err)
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("sync tier %q: %w", name, ErrInvalidInterval)
+		}
+		tiers[name] = d
+	}
+	return tiers, nil
+}
+
+// Syncer fetches the latest messages for a user. *gmail.GmailService implements it.
+type Syncer interface {
+	SyncUser(ctx context.Context, userID string, token *oauth2.Token) error
+}
+
+// Scheduler periodically syncs every user with a stored token whose interval has elapsed
+type Scheduler struct {
+	schedules data.SyncScheduleRepository
+	tokens    data.UserTokenRepository
+	syncer    Syncer
+
+	mu          sync.RWMutex
+	tiers       map[string]time.Duration
+	defaultTier string
+
+	// Tick is how often due users are looked up
+	Tick time.Duration
+	// Health, if set, receives heartbeats and sync outcomes
+	Health *health.Worker
+	// Maintenance, if set, pauses scheduled syncs while it is on
+	Maintenance *maintenance.Switch
+	// Errors, if set, receives sync failures
+	Errors telemetryerrors.Reporter
+	// DebugLog, if set, marks syncs of users with debug logging on
+	DebugLog *debuglog.Toggles
+
+	now func() time.Time
+}
+
+// New creates a Scheduler; defaultTier applies to users without one and must be in tiers
+func New(schedules data.SyncScheduleRepository, tokens data.UserTokenRepository, syncer Syncer, tiers map[string]time.Duration, defaultTier string) (*Scheduler, error) {
+	s := &Scheduler{
+		schedules: schedules,
+		tokens:    tokens,
+		syncer:    syncer,
+		Tick:      DefaultTick,
+		now:       time.Now,
+	}
+	if err := s.SetTiers(tiers, defaultTier); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetTiers replaces the tier intervals and default tier, e.g. on config reload. On error the
+// current tiers are kept. Users on a tier that no longer exists fall back to the default tier.
+func (s *Scheduler) SetTiers(tiers map[string]time.Duration, defaultTier string) error {
+	if defaultTier == "" {
+		defaultTier = TierFree
+	}
+	if _, ok := tiers[defaultTier]; !ok {
+		return fmt.Errorf("default sync tier %q: %w", defaultTier, ErrUnknownTier)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tiers, s.defaultTier = tiers, defaultTier
+	return nil
+}
+
+// Interval returns the effective sync interval of a schedule: the override if set, else the
+// user's tier, else the default tier. 0 means manual-only.
+func (s *Scheduler) Interval(sched *models.SyncSchedule) time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if sched.IntervalSeconds != nil {
+		return time.Duration(*sched.IntervalSeconds) * time.Second
+	}
+	if d, ok := s.tiers[sched.Tier]; ok {
+		return d
+	}
+	return s.tiers[s.defaultTier]
+}
+
+// Schedule returns the user's stored schedule
+func (s *Scheduler) Schedule(ctx context.Context, userID string) (*models.SyncSchedule, error) {
+	return s.schedules.GetSchedule(ctx, userID)
+}
+
+// SetSchedule validates and saves a user's tier and override. An empty tier selects the default tier.
+func (s *Scheduler) SetSchedule(ctx context.Context, sched *models.SyncSchedule) error {
+	s.mu.RLock()
+	_, ok := s.tiers[sched.Tier]
+	s.mu.RUnlock()
+	if sched.Tier != "" && !ok {
+		return ErrUnknownTier
+	}
+	if sched.IntervalSeconds != nil && *sched.IntervalSeconds < 0 {
+		return ErrInvalidInterval
+	}
+	return s.schedules.SetSchedule(ctx, sched)
+}
+
+// Pending reports how many users are due for a sync and since when the longest-waiting one
+// has been due. Users that were never synced are due but have no due time.
+func (s *Scheduler) Pending(ctx context.Context) (int, *time.Time, error) {
+	candidates, err := s.schedules.ListSyncCandidates(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	now := s.now()
+	depth := 0
+	var oldest *time.Time
+	for _, sched := range candidates {
+		if !s.due(sched, now) {
+			continue
+		}
+		depth++
+		if sched.LastSyncedAt != nil {
+			dueAt := sched.LastSyncedAt.Add(s.Interval(sched))
+			if oldest == nil || dueAt.Before(*oldest) {
+				oldest = &dueAt
+			}
+		}
+	}
+	return depth, oldest, nil
+}
+
+// Start runs the scheduler until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.Tick)
+		defer ticker.Stop()
+		for {
+			s.RunDue(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunDue syncs, one at a time, every user whose interval has elapsed since their last
+// scheduled sync, and returns how many were attempted. Failed attempts still count as a
+// sync so a failing user is retried on their normal cadence rather than every tick.
+func (s *Scheduler) RunDue(ctx context.Context) int {
+	s.Health.Beat()
+	if s.Maintenance.Active() {
+		return 0
+	}
+	candidates, err := s.schedules.ListSyncCandidates(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("scheduler: failed to list sync candidates")
+		return 0
+	}
+	attempted := 0
+	for _, sched := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
+		now := s.now().UTC()
+		if !s.due(sched, now) {
+			continue
+		}
+		attempted++
+		if err := s.schedules.MarkSynced(ctx, sched.UserID, now); err != nil {
+			log.Error().Err(err).Str("userID", sched.UserID).Msg("scheduler: failed to record sync")
+			continue
+		}
+		token, err := s.tokens.GetUserToken(ctx, sched.UserID, data.ProviderGmail, "")
+		if err != nil {
+			log.Warn().Err(err).Str("userID", sched.UserID).Msg("scheduler: no usable token")
+			s.Health.Record(JobTypeSync, err)
+			continue
+		}
+		err = s.syncer.SyncUser(s.DebugLog.Context(ctx, sched.UserID), sched.UserID, token)
+		if err != nil {
+			log.Warn().Err(err).Str("userID", sched.UserID).Msg("scheduler: sync failed")
+			if ctx.Err() == nil && !errors.Is(err, maintenance.ErrActive) && !errors.Is(err, provider.ErrCircuitOpen) {
+				telemetryerrors.Capture(ctx, s.Errors, err, sched.UserID, map[string]string{"job_type": JobTypeSync})
+			}
+		}
+		s.Health.Record(JobTypeSync, err)
+	}
+	return attempted
+}
+
+func (s *Scheduler) due(sched *models.SyncSchedule, now time.Time) bool {
+	interval := s.Interval(sched)
+	return interval > 0 && (sched.LastSyncedAt == nil || now.Sub(*sched.LastSyncedAt) >= interval)
+}
@@ -0,0 +1,229 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	telemetryerrors "github.com/desponda/inbox-whisperer/internal/telemetry/errors"
+	"golang.org/x/oauth2"
+)
+
+type fakeSchedules struct {
+	byUser map[string]*models.SyncSchedule
+}
+
+func (f *fakeSchedules) ListSyncCandidates(ctx context.Context) ([]*models.SyncSchedule, error) {
+	var out []*models.SyncSchedule
+	for _, s := range f.byUser {
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func (f *fakeSchedules) GetSchedule(ctx context.Context, userID string) (*models.SyncSchedule, error) {
+	if s, ok := f.byUser[userID]; ok {
+		return s, nil
+	}
+	return &models.SyncSchedule{UserID: userID}, nil
+}
+
+func (f *fakeSchedules) SetSchedule(ctx context.Context, s *models.SyncSchedule) error {
+	f.byUser[s.UserID] = s
+	return nil
+}
+
+func (f *fakeSchedules) MarkSynced(ctx context.Context, userID string, at time.Time) error {
+	f.byUser[userID].LastSyncedAt = &at
+	return nil
+}
+
+type fakeTokens struct{}
+
+func (fakeTokens) SaveUserToken(ctx context.Context, userID, provider, accountID string, token *oauth2.Token) error {
+	return nil
+}
+
+func (fakeTokens) GetUserToken(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: "tok-" + userID}, nil
+}
+
+type fakeSyncer struct {
+	synced []string
+	err    error
+}
+
+func (f *fakeSyncer) SyncUser(ctx context.Context, userID string, token *oauth2.Token) error {
+	f.synced = append(f.synced, userID)
+	return f.err
+}
+
+type recordingReporter struct{ events []telemetryerrors.Event }
+
+func (r *recordingReporter) Report(ctx context.Context, ev telemetryerrors.Event) {
+	r.events = append(r.events, ev)
+}
+
+func intPtr(v int) *int { return &v }
+
+func TestParseTiers(t *testing.T) {
+	tiers, err := ParseTiers(map[string]string{"paid": "5m", "enterprise": "30s"})
+	if err != nil {
+		t.Fatalf("ParseTiers failed: %v", err)
+	}
+	if tiers[TierFree] != 15*time.Minute || tiers[TierPaid] != 5*time.Minute || tiers["enterprise"] != 30*time.Second {
+		t.Errorf("unexpected tiers %v", tiers)
+	}
+	if _, err := ParseTiers(map[string]string{"free": "often"}); err == nil {
+		t.Error("expected an invalid duration to be rejected")
+	}
+	if _, err := ParseTiers(map[string]string{"free": "-1m"}); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("expected ErrInvalidInterval, got %v", err)
+	}
+}
+
+func TestNew_UnknownDefaultTier(t *testing.T) {
+	if _, err := New(nil, nil, nil, DefaultTiers(), "gold"); !errors.Is(err, ErrUnknownTier) {
+		t.Errorf("expected ErrUnknownTier, got %v", err)
+	}
+}
+
+func TestRunDue_HonoursTiersAndOverrides(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) *time.Time { t := now.Add(-d); return &t }
+	schedules := &fakeSchedules{byUser: map[string]*models.SyncSchedule{
+		"new":        {UserID: "new"},
+		"free-due":   {UserID: "free-due", LastSyncedAt: ago(20 * time.Minute)},
+		"free-early": {UserID: "free-early", LastSyncedAt: ago(5 * time.Minute)},
+		"paid-due":   {UserID: "paid-due", Tier: TierPaid, LastSyncedAt: ago(3 * time.Minute)},
+		"manual":     {UserID: "manual", Tier: TierManual},
+		"overridden": {UserID: "overridden", Tier: TierPaid, IntervalSeconds: intPtr(3600), LastSyncedAt: ago(10 * time.Minute)},
+		"paused":     {UserID: "paused", IntervalSeconds: intPtr(0)},
+	}}
+	syncer := &fakeSyncer{}
+	s, err := New(schedules, fakeTokens{}, syncer, DefaultTiers(), "")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	s.now = func() time.Time { return now }
+
+	if n := s.RunDue(context.Background()); n != 3 {
+		t.Errorf("expected 3 syncs, got %d (%v)", n, syncer.synced)
+	}
+	want := map[string]bool{"new": true, "free-due": true, "paid-due": true}
+	for _, id := range syncer.synced {
+		if !want[id] {
+			t.Errorf("unexpected sync of %s", id)
+		}
+	}
+	if !schedules.byUser["new"].LastSyncedAt.Equal(now) {
+		t.Error("expected the sync attempt to be recorded")
+	}
+	if n := s.RunDue(context.Background()); n != 0 {
+		t.Errorf("expected no syncs right after a run, got %d", n)
+	}
+}
+
+func TestSetSchedule_Validates(t *testing.T) {
+	schedules := &fakeSchedules{byUser: map[string]*models.SyncSchedule{}}
+	s, _ := New(schedules, fakeTokens{}, &fakeSyncer{}, DefaultTiers(), TierFree)
+	ctx := context.Background()
+
+	if err := s.SetSchedule(ctx, &models.SyncSchedule{UserID: "u", Tier: "gold"}); !errors.Is(err, ErrUnknownTier) {
+		t.Errorf("expected ErrUnknownTier, got %v", err)
+	}
+	if err := s.SetSchedule(ctx, &models.SyncSchedule{UserID: "u", IntervalSeconds: intPtr(-5)}); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("expected ErrInvalidInterval, got %v", err)
+	}
+	sched := &models.SyncSchedule{UserID: "u", Tier: TierPaid}
+	if err := s.SetSchedule(ctx, sched); err != nil {
+		t.Fatalf("SetSchedule failed: %v", err)
+	}
+	if got := s.Interval(sched); got != 2*time.Minute {
+		t.Errorf("expected the paid interval, got %v", got)
+	}
+}
+
+func TestPending(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) *time.Time { t := now.Add(-d); return &t }
+	schedules := &fakeSchedules{byUser: map[string]*models.SyncSchedule{
+		"new":      {UserID: "new"},
+		"overdue":  {UserID: "overdue", LastSyncedAt: ago(25 * time.Minute)},
+		"due":      {UserID: "due", LastSyncedAt: ago(16 * time.Minute)},
+		"not-due":  {UserID: "not-due", LastSyncedAt: ago(time.Minute)},
+		"manual":   {UserID: "manual", Tier: TierManual},
+		"paid-due": {UserID: "paid-due", Tier: TierPaid, LastSyncedAt: ago(2 * time.Minute)},
+	}}
+	s, _ := New(schedules, fakeTokens{}, &fakeSyncer{}, DefaultTiers(), TierFree)
+	s.now = func() time.Time { return now }
+
+	depth, oldest, err := s.Pending(context.Background())
+	if err != nil {
+		t.Fatalf("Pending failed: %v", err)
+	}
+	if depth != 4 {
+		t.Errorf("expected 4 due users, got %d", depth)
+	}
+	if oldest == nil || !oldest.Equal(now.Add(-10*time.Minute)) {
+		t.Errorf("expected the overdue user to have waited 10m, got %v", oldest)
+	}
+}
+
+func TestSetTiers(t *testing.T) {
+	s, _ := New(&fakeSchedules{}, fakeTokens{}, &fakeSyncer{}, DefaultTiers(), TierFree)
+	sched := &models.SyncSchedule{UserID: "u"}
+
+	if err := s.SetTiers(map[string]time.Duration{"basic": time.Hour}, "gold"); !errors.Is(err, ErrUnknownTier) {
+		t.Errorf("expected ErrUnknownTier, got %v", err)
+	}
+	if got := s.Interval(sched); got != 15*time.Minute {
+		t.Errorf("expected a rejected update to keep the current tiers, got %v", got)
+	}
+	if err := s.SetTiers(map[string]time.Duration{"basic": time.Hour}, "basic"); err != nil {
+		t.Fatalf("SetTiers failed: %v", err)
+	}
+	if got := s.Interval(&models.SyncSchedule{UserID: "u", Tier: TierPaid}); got != time.Hour {
+		t.Errorf("expected a removed tier to fall back to the new default, got %v", got)
+	}
+}
+
+func TestRunDue_PausedDuringMaintenance(t *testing.T) {
+	schedules := &fakeSchedules{byUser: map[string]*models.SyncSchedule{"new": {UserID: "new"}}}
+	syncer := &fakeSyncer{}
+	s, _ := New(schedules, fakeTokens{}, syncer, DefaultTiers(), TierFree)
+	s.Maintenance = maintenance.New()
+	s.Maintenance.Set(true, "", 0)
+
+	if n := s.RunDue(context.Background()); n != 0 || len(syncer.synced) != 0 {
+		t.Errorf("expected no syncs during maintenance, got %v", syncer.synced)
+	}
+	s.Maintenance.Set(false, "", 0)
+	if n := s.RunDue(context.Background()); n != 1 {
+		t.Errorf("expected the sync to run after maintenance, got %d", n)
+	}
+}
+
+func TestRunDue_ReportsSyncFailures(t *testing.T) {
+	schedules := &fakeSchedules{byUser: map[string]*models.SyncSchedule{"u1": {UserID: "u1"}}}
+	syncer := &fakeSyncer{err: errors.New("gmail unavailable")}
+	s, _ := New(schedules, fakeTokens{}, syncer, DefaultTiers(), TierFree)
+	reporter := &recordingReporter{}
+	s.Errors = reporter
+
+	s.RunDue(context.Background())
+	if len(reporter.events) != 1 || reporter.events[0].UserID != "u1" || reporter.events[0].Tags["job_type"] != JobTypeSync {
+		t.Fatalf("expected one reported sync failure for u1, got %+v", reporter.events)
+	}
+
+	// Syncs refused for maintenance are expected and not reported
+	schedules.byUser["u1"].LastSyncedAt = nil
+	syncer.err = maintenance.ErrActive
+	s.RunDue(context.Background())
+	if len(reporter.events) != 1 {
+		t.Errorf("expected maintenance refusals to go unreported, got %+v", reporter.events)
+	}
+}
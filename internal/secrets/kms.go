@@ -0,0 +1,52 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/cloudkms/v1"
+	"google.golang.org/api/option"
+)
+
+// KMSProvider decrypts secrets with Google Cloud KMS, so encrypted values can live in the
+// config file. References look like "<crypto key resource name>#<base64 ciphertext>".
+// Credentials come from the environment (application default credentials) unless options
+// say otherwise.
+type KMSProvider struct {
+	opts []option.ClientOption
+
+	once    sync.Once
+	service *cloudkms.Service
+	err     error
+}
+
+func NewKMSProvider(opts ...option.ClientOption) *KMSProvider {
+	return &KMSProvider{opts: opts}
+}
+
+func (k *KMSProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	key, ciphertext, ok := strings.Cut(ref, "#")
+	if !ok || key == "" || ciphertext == "" {
+		return "", fmt.Errorf("kms reference must be <key name>#<base64 ciphertext>")
+	}
+	k.once.Do(func() {
+		// The client outlives this call, so it must not be bound to ctx
+		k.service, k.err = cloudkms.NewService(context.Background(), k.opts...)
+	})
+	if k.err != nil {
+		return "", fmt.Errorf("create kms client: %w", k.err)
+	}
+	resp, err := k.service.Projects.Locations.KeyRings.CryptoKeys.
+		Decrypt(key, &cloudkms.DecryptRequest{Ciphertext: ciphertext}).Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return "", fmt.Errorf("decode kms plaintext: %w", err)
+	}
+	return string(plaintext), nil
+}
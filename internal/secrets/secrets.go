@@ -0,0 +1,100 @@
+// Package secrets resolves secret references in configuration values. A value such as
+// "env:OPENAI_API_KEY", "file:/run/secrets/db_url", "vault:secret/data/app#db_url" or
+// "gcpkms:projects/p/locations/l/keyRings/r/cryptoKeys/k#<base64 ciphertext>" is replaced by
+// the secret it points to; any other value is used literally, so plain configs keep working.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound means the referenced secret does not exist
+var ErrNotFound = errors.New("secret not found")
+
+// Provider looks up secrets of one scheme; ref is the part after "<scheme>:"
+type Provider interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// Resolver dispatches references to the provider registered for their scheme
+type Resolver struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+}
+
+// NewResolver creates a Resolver with the env and file providers registered
+func NewResolver() *Resolver {
+	return &Resolver{providers: map[string]Provider{
+		"env":  EnvProvider{},
+		"file": FileProvider{},
+	}}
+}
+
+// Register adds or replaces the provider for a scheme
+func (r *Resolver) Register(scheme string, p Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[scheme] = p
+}
+
+// Resolve returns the secret value references, or value itself if it is not a reference
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	p, ref, ok := r.lookup(value)
+	if !ok {
+		return value, nil
+	}
+	secret, err := p.Resolve(ctx, ref)
+	if err != nil {
+		scheme, _, _ := strings.Cut(value, ":")
+		return "", fmt.Errorf("resolve %s secret: %w", scheme, err)
+	}
+	return secret, nil
+}
+
+// IsRef reports whether value is a reference to a registered scheme
+func (r *Resolver) IsRef(value string) bool {
+	_, _, ok := r.lookup(value)
+	return ok
+}
+
+func (r *Resolver) lookup(value string) (Provider, string, bool) {
+	scheme, ref, ok := strings.Cut(value, ":")
+	if !ok {
+		return nil, "", false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.providers[scheme]
+	return p, ref, ok
+}
+
+// EnvProvider reads secrets from environment variables
+type EnvProvider struct{}
+
+func (EnvProvider) Resolve(ctx context.Context, name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s", ErrNotFound, name)
+	}
+	return v, nil
+}
+
+// FileProvider reads secrets from files, e.g. mounted Kubernetes or Docker secrets. A
+// trailing newline is dropped.
+type FileProvider struct{}
+
+func (FileProvider) Resolve(ctx context.Context, path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: file %s", ErrNotFound, path)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
@@ -0,0 +1,109 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/option"
+)
+
+func TestResolver_EnvFileAndLiterals(t *testing.T) {
+	ctx := context.Background()
+	r := NewResolver()
+	t.Setenv("TEST_SECRET", "from-env")
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"env:TEST_SECRET":                 "from-env",
+		"file:" + path:                    "from-file",
+		"plain-value":                     "plain-value",
+		"postgres://u:p@localhost:5432/d": "postgres://u:p@localhost:5432/d",
+	}
+	for in, want := range cases {
+		got, err := r.Resolve(ctx, in)
+		if err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := r.Resolve(ctx, "env:TEST_SECRET_MISSING"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing variable, got %v", err)
+	}
+	if _, err := r.Resolve(ctx, "file:"+path+".missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing file, got %v", err)
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/app":
+			w.Write([]byte(`{"data":{"data":{"api_key":"v2-value"},"metadata":{"version":3}}}`))
+		case "/v1/kv/app":
+			w.Write([]byte(`{"data":{"api_key":"v1-value"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	r := NewResolver()
+	r.Register("vault", NewVaultProvider(srv.URL+"/", "root"))
+	ctx := context.Background()
+
+	if got, err := r.Resolve(ctx, "vault:secret/data/app#api_key"); err != nil || got != "v2-value" {
+		t.Errorf("expected KV v2 value, got %q (%v)", got, err)
+	}
+	if got, err := r.Resolve(ctx, "vault:kv/app#api_key"); err != nil || got != "v1-value" {
+		t.Errorf("expected KV v1 value, got %q (%v)", got, err)
+	}
+	if _, err := r.Resolve(ctx, "vault:secret/data/app#other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing field, got %v", err)
+	}
+	if _, err := r.Resolve(ctx, "vault:secret/data/missing#api_key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing path, got %v", err)
+	}
+	if _, err := r.Resolve(ctx, "vault:secret/data/app"); err == nil {
+		t.Error("expected a reference without a field to be rejected")
+	}
+}
+
+func TestKMSProvider(t *testing.T) {
+	ciphertext := base64.StdEncoding.EncodeToString([]byte("encrypted"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/cryptoKeys/app:decrypt") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req struct {
+			Ciphertext string `json:"ciphertext"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Ciphertext != ciphertext {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"plaintext": base64.StdEncoding.EncodeToString([]byte("s3cret"))})
+	}))
+	defer srv.Close()
+	r := NewResolver()
+	r.Register("gcpkms", NewKMSProvider(option.WithEndpoint(srv.URL), option.WithoutAuthentication()))
+
+	got, err := r.Resolve(context.Background(), "gcpkms:projects/p/locations/global/keyRings/r/cryptoKeys/app#"+ciphertext)
+	if err != nil || got != "s3cret" {
+		t.Errorf("expected decrypted secret, got %q (%v)", got, err)
+	}
+}
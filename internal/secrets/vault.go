@@ -0,0 +1,71 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// VaultProvider reads secrets from HashiCorp Vault's KV engine over its HTTP API. References
+// look like "secret/data/inbox-whisperer#openai_api_key": the API path below /v1/ and the
+// field to return. Both KV v1 and v2 responses are understood.
+type VaultProvider struct {
+	Addr       string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewVaultProvider creates a VaultProvider for the server at addr (e.g. https://vault:8200)
+func NewVaultProvider(addr, token string) *VaultProvider {
+	return &VaultProvider{Addr: strings.TrimRight(addr, "/"), Token: token, HTTPClient: http.DefaultClient}
+}
+
+type vaultResponse struct {
+	Data map[string]json.RawMessage `json:"data"`
+}
+
+func (v *VaultProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("vault reference %q must be <path>#<field>", ref)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.Addr+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	resp, err := v.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: vault path %s", ErrNotFound, path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s for %s", resp.Status, path)
+	}
+	var body vaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode vault response: %w", err)
+	}
+	data := body.Data
+	// KV v2 nests the secret's fields under data.data
+	if nested, ok := data["data"]; ok {
+		var inner map[string]json.RawMessage
+		if err := json.Unmarshal(nested, &inner); err == nil {
+			data = inner
+		}
+	}
+	raw, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("%w: field %s in vault path %s", ErrNotFound, field, path)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("vault field %s is not a string", field)
+	}
+	return value, nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+)
+
+// AccountStatus summarizes a mailbox's health for display
+type AccountStatus string
+
+const (
+	AccountOK       AccountStatus = "ok"
+	AccountDegraded AccountStatus = "degraded"
+	// AccountActionRequired means the user must act, e.g. reconnect the account
+	AccountActionRequired AccountStatus = "action-required"
+)
+
+// StaleSyncAfter is how long an account may go without a successful sync before it counts as degraded
+const StaleSyncAfter = 24 * time.Hour
+
+// failedItemsLimit bounds how many pending sync failures are counted
+const failedItemsLimit = 1000
+
+// AccountHealth combines the signals behind an account's status. Reasons lists why the
+// status is not ok, as stable codes the frontend can map to messages.
+type AccountHealth struct {
+	AccountID string                 `json:"account_id"`
+	Provider  ProviderType           `json:"provider"`
+	Status    AccountStatus          `json:"status"`
+	Reasons   []string               `json:"reasons"`
+	Breaker   provider.BreakerStatus `json:"breaker"`
+	// TokenValid is false when no token is stored, or it expired and cannot be refreshed
+	TokenValid bool `json:"token_valid"`
+	// RetryingItems are messages whose sync failed and will be retried
+	RetryingItems int `json:"retrying_items"`
+	// FailedItems are messages whose sync failed for good
+	FailedItems        int        `json:"failed_items"`
+	LastSuccessfulSync *time.Time `json:"last_successful_sync,omitempty"`
+}
+
+// AccountHealthService reports the health of a user's linked mailbox. Accounts are the
+// user's Gmail accounts by account ID; the provider name "gmail" stands for the default one.
+// Sync state, failed items and the breaker are still tracked per user, so they apply to
+// every account. SyncState, FailedItems and Breaker are optional; their signals are left
+// out when nil.
+type AccountHealthService struct {
+	Tokens      data.UserTokenRepository
+	SyncState   data.SyncStateRepository
+	FailedItems data.FailedSyncItemRepository
+	Breaker     *provider.Breaker
+	// MaxSyncAttempts is where a failed item stops being retried
+	MaxSyncAttempts int
+
+	now func() time.Time
+}
+
+func NewAccountHealthService(tokens data.UserTokenRepository) *AccountHealthService {
+	return &AccountHealthService{Tokens: tokens, now: time.Now}
+}
+
+// Check returns the health of the user's account. Returns data.ErrNotFound for unknown accounts.
+func (s *AccountHealthService) Check(ctx context.Context, userID, accountID string) (*AccountHealth, error) {
+	h := &AccountHealth{AccountID: accountID, Provider: ProviderGmail, Reasons: []string{}}
+	var actionRequired, degraded bool
+
+	tokenAccount := accountID
+	if accountID == string(ProviderGmail) {
+		tokenAccount = ""
+	}
+	tok, err := s.Tokens.GetUserToken(ctx, userID, data.ProviderGmail, tokenAccount)
+	switch {
+	case errors.Is(err, data.ErrNotFound) && tokenAccount != "":
+		return nil, data.ErrNotFound
+	case errors.Is(err, data.ErrNotFound):
+		h.Reasons = append(h.Reasons, "token_missing")
+		actionRequired = true
+	case err != nil:
+		return nil, err
+	case !tok.Expiry.IsZero() && !tok.Expiry.After(s.now()) && tok.RefreshToken == "":
+		h.Reasons = append(h.Reasons, "token_expired")
+		actionRequired = true
+	default:
+		h.TokenValid = true
+	}
+
+	h.Breaker = s.Breaker.Status(userID)
+	switch {
+	case h.Breaker.Reason == "auth_expired":
+		h.Reasons = append(h.Reasons, "authorization_revoked")
+		actionRequired = true
+	case h.Breaker.State == provider.BreakerOpen:
+		h.Reasons = append(h.Reasons, "rate_limited")
+		degraded = true
+	}
+
+	if s.FailedItems != nil {
+		retrying, err := s.FailedItems.ListRetryable(ctx, userID, s.MaxSyncAttempts, failedItemsLimit)
+		if err != nil {
+			return nil, err
+		}
+		failed, err := s.FailedItems.ListExhausted(ctx, userID, s.MaxSyncAttempts)
+		if err != nil {
+			return nil, err
+		}
+		h.RetryingItems, h.FailedItems = len(retrying), len(failed)
+		if h.FailedItems > 0 {
+			h.Reasons = append(h.Reasons, "sync_failures")
+			degraded = true
+		}
+	}
+
+	if s.SyncState != nil {
+		state, err := s.SyncState.GetSyncState(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		// A mailbox that has not finished its first sync yet is not stale
+		if !state.UpdatedAt.IsZero() {
+			last := state.UpdatedAt
+			h.LastSuccessfulSync = &last
+			if s.now().Sub(last) > StaleSyncAfter {
+				h.Reasons = append(h.Reasons, "sync_stale")
+				degraded = true
+			}
+		}
+	}
+
+	switch {
+	case actionRequired:
+		h.Status = AccountActionRequired
+	case degraded:
+		h.Status = AccountDegraded
+	default:
+		h.Status = AccountOK
+	}
+	return h, nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/mocks"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"golang.org/x/oauth2"
+)
+
+type fakeHealthSyncState struct{ updatedAt time.Time }
+
+func (f *fakeHealthSyncState) GetSyncState(ctx context.Context, userID string) (*models.SyncState, error) {
+	return &models.SyncState{UserID: userID, UpdatedAt: f.updatedAt}, nil
+}
+func (f *fakeHealthSyncState) SaveSyncState(ctx context.Context, s *models.SyncState) error {
+	return nil
+}
+
+type fakeHealthFailedItems struct{ retrying, exhausted int }
+
+func (f *fakeHealthFailedItems) RecordFailure(ctx context.Context, userID, emailMessageID, stage, errMsg string) error {
+	return nil
+}
+func (f *fakeHealthFailedItems) ListRetryable(ctx context.Context, userID string, maxAttempts, limit int) ([]*models.FailedSyncItem, error) {
+	return make([]*models.FailedSyncItem, f.retrying), nil
+}
+func (f *fakeHealthFailedItems) ListExhausted(ctx context.Context, userID string, maxAttempts int) ([]*models.FailedSyncItem, error) {
+	return make([]*models.FailedSyncItem, f.exhausted), nil
+}
+func (f *fakeHealthFailedItems) Resolve(ctx context.Context, userID, emailMessageID string) error {
+	return nil
+}
+
+func TestAccountHealthService_Check(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	valid := &oauth2.Token{AccessToken: "a", RefreshToken: "r", Expiry: now.Add(-time.Hour)}
+	tests := []struct {
+		name        string
+		token       *oauth2.Token
+		tokenErr    error
+		breakerErr  error
+		lastSync    time.Time
+		failed      *fakeHealthFailedItems
+		wantStatus  AccountStatus
+		wantReasons []string
+	}{
+		{"healthy", valid, nil, nil, now.Add(-time.Hour), &fakeHealthFailedItems{retrying: 2}, AccountOK, []string{}},
+		{"never synced is not stale", valid, nil, nil, time.Time{}, &fakeHealthFailedItems{}, AccountOK, []string{}},
+		{"no token", nil, data.ErrNotFound, nil, now, &fakeHealthFailedItems{}, AccountActionRequired, []string{"token_missing"}},
+		{"expired without refresh token", &oauth2.Token{AccessToken: "a", Expiry: now.Add(-time.Hour)}, nil, nil, now, &fakeHealthFailedItems{}, AccountActionRequired, []string{"token_expired"}},
+		{"revoked", valid, nil, provider.ErrAuthExpired, now, &fakeHealthFailedItems{}, AccountActionRequired, []string{"authorization_revoked"}},
+		{"rate limited", valid, nil, provider.ErrRateLimited, now, &fakeHealthFailedItems{}, AccountDegraded, []string{"rate_limited"}},
+		{"stale and failing", valid, nil, nil, now.Add(-StaleSyncAfter - time.Minute), &fakeHealthFailedItems{exhausted: 1}, AccountDegraded, []string{"sync_failures", "sync_stale"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := &mocks.MockUserTokenRepository{GetUserTokenFunc: func(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
+				return tt.token, tt.tokenErr
+			}}
+			s := NewAccountHealthService(tokens)
+			s.now = func() time.Time { return now }
+			s.SyncState = &fakeHealthSyncState{updatedAt: tt.lastSync}
+			s.FailedItems = tt.failed
+			s.Breaker = provider.NewBreaker()
+			if tt.breakerErr != nil {
+				s.Breaker.Record("user1", tt.breakerErr)
+			}
+			h, err := s.Check(context.Background(), "user1", "gmail")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.Status != tt.wantStatus || !reflect.DeepEqual(h.Reasons, tt.wantReasons) {
+				t.Errorf("got %s %v, want %s %v", h.Status, h.Reasons, tt.wantStatus, tt.wantReasons)
+			}
+			if h.RetryingItems != tt.failed.retrying || h.FailedItems != tt.failed.exhausted {
+				t.Errorf("unexpected item counts %d/%d", h.RetryingItems, h.FailedItems)
+			}
+		})
+	}
+}
+
+func TestAccountHealthService_AccountSelection(t *testing.T) {
+	var requested []string
+	tokens := &mocks.MockUserTokenRepository{GetUserTokenFunc: func(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
+		requested = append(requested, accountID)
+		if accountID != "" && accountID != "work@example.com" {
+			return nil, data.ErrNotFound
+		}
+		return &oauth2.Token{AccessToken: "a"}, nil
+	}}
+	s := NewAccountHealthService(tokens)
+	if _, err := s.Check(context.Background(), "user1", "outlook-1"); !errors.Is(err, data.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	h, err := s.Check(context.Background(), "user1", "work@example.com")
+	if err != nil || h.AccountID != "work@example.com" || h.Status != AccountOK {
+		t.Fatalf("got %+v, %v", h, err)
+	}
+	// "gmail" stands for the default account
+	if _, err := s.Check(context.Background(), "user1", "gmail"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(requested, []string{"outlook-1", "work@example.com", ""}) {
+		t.Errorf("requested accounts %v", requested)
+	}
+}
@@ -1,10 +1,13 @@
 package service
 
 import (
+	"container/list"
 	"context"
 	"errors"
+	"io"
 	"sync"
 
+	"github.com/desponda/inbox-whisperer/internal/data"
 	"github.com/desponda/inbox-whisperer/internal/service/gmail"
 )
 
@@ -15,39 +18,65 @@ type ProviderType string
 const (
 	ProviderGmail   ProviderType = "gmail"
 	ProviderOutlook ProviderType = "outlook"
+	ProviderIMAP    ProviderType = "imap"
 )
 
-// ProviderConfig represents a user's linked provider account (simplified)
+// DefaultMaxCachedProviders bounds the providers an EmailProviderFactory keeps between lookups
+const DefaultMaxCachedProviders = 1000
+
+// ProviderConfig is a provider a user has linked. Credentials are not carried here: providers
+// load them per call from the token and IMAP account stores.
 type ProviderConfig struct {
 	UserID string
 	Type   ProviderType
-	// ...tokens, config, etc.
 }
 
-// EmailProviderFactory returns providers for a user
-// (in real usage, would query DB for user's linked accounts)
+// EmailProviderFactory returns the providers a user has linked. Links come from Accounts, so
+// they survive restarts and are shared by all replicas. Providers are built lazily, on a user's
+// first lookup, and kept in a least-recently-used cache of MaxCached entries. Safe for
+// concurrent use.
 type EmailProviderFactory struct {
 	mu       sync.RWMutex
 	creators map[ProviderType]func(cfg ProviderConfig) (EmailProvider, error)
-	// In-memory mapping for demo; replace with DB in prod
+	// linked holds links added with LinkProvider, on top of the stored ones
 	linked map[string][]ProviderConfig // userID -> []ProviderConfig
+	// Accounts lists the providers users linked through OAuth or IMAP; optional (only
+	// LinkProvider links count when nil)
+	Accounts data.LinkedProviderRepository
+	// MaxCached bounds the built providers kept between lookups. Evicted providers that
+	// implement io.Closer, e.g. to release a per-user HTTP client, are closed.
+	MaxCached int
+
+	cacheMu sync.Mutex
+	cache   map[string]*list.Element // userID/type -> element of lru
+	lru     *list.List               // of *cachedProvider, most recently used first
+}
+
+type cachedProvider struct {
+	key      string
+	provider EmailProvider
 }
 
 func NewEmailProviderFactory() *EmailProviderFactory {
 	return &EmailProviderFactory{
-		creators: make(map[ProviderType]func(cfg ProviderConfig) (EmailProvider, error)),
-		linked:   make(map[string][]ProviderConfig),
+		creators:  make(map[ProviderType]func(cfg ProviderConfig) (EmailProvider, error)),
+		linked:    make(map[string][]ProviderConfig),
+		MaxCached: DefaultMaxCachedProviders,
+		cache:     make(map[string]*list.Element),
+		lru:       list.New(),
 	}
 }
 
-// RegisterProvider allows registration of a provider constructor
+// RegisterProvider allows registration of a provider constructor. A constructor handing the same
+// provider to every user must not return an io.Closer, as evicting one user's entry closes it.
 func (f *EmailProviderFactory) RegisterProvider(ptype ProviderType, creator func(cfg ProviderConfig) (EmailProvider, error)) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	f.creators[ptype] = creator
 }
 
-// LinkProvider links a provider to a user (demo, not persistent)
+// LinkProvider links a provider to a user for the life of the process, e.g. in tests or for
+// providers without stored accounts; persistent links come from Accounts
 func (f *EmailProviderFactory) LinkProvider(userID string, cfg ProviderConfig) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -56,22 +85,27 @@ func (f *EmailProviderFactory) LinkProvider(userID string, cfg ProviderConfig) {
 
 func (f *EmailProviderFactory) ProvidersForUser(ctx context.Context, userID string) ([]EmailProvider, error) {
 	f.mu.RLock()
-	linked := f.linked[userID]
+	linked := append([]ProviderConfig{}, f.linked[userID]...)
 	f.mu.RUnlock()
+	if f.Accounts != nil {
+		stored, err := f.Accounts.LinkedProviders(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range stored {
+			if !hasType(linked, ProviderType(p)) {
+				linked = append(linked, ProviderConfig{UserID: userID, Type: ProviderType(p)})
+			}
+		}
+	}
 	if len(linked) == 0 {
 		return nil, errors.New("no providers linked for user")
 	}
 	providers := make([]EmailProvider, 0, len(linked))
 	for _, cfg := range linked {
-		f.mu.RLock()
-		creator, ok := f.creators[cfg.Type]
-		f.mu.RUnlock()
-		if !ok {
-			continue // skip unknown provider
-		}
-		prov, err := creator(cfg)
-		if err != nil {
-			continue // skip on error
+		prov, err := f.provider(userID, cfg)
+		if err != nil || prov == nil {
+			continue // skip unknown providers and creator errors
 		}
 		providers = append(providers, prov)
 	}
@@ -80,3 +114,61 @@ func (f *EmailProviderFactory) ProvidersForUser(ctx context.Context, userID stri
 	}
 	return providers, nil
 }
+
+// provider returns the cached provider for a user's link, building it on a miss. Failed builds
+// are not cached, so the next lookup tries again.
+func (f *EmailProviderFactory) provider(userID string, cfg ProviderConfig) (EmailProvider, error) {
+	key := userID + "/" + string(cfg.Type)
+	f.cacheMu.Lock()
+	if el, ok := f.cache[key]; ok {
+		f.lru.MoveToFront(el)
+		f.cacheMu.Unlock()
+		return el.Value.(*cachedProvider).provider, nil
+	}
+	f.cacheMu.Unlock()
+
+	f.mu.RLock()
+	creator, ok := f.creators[cfg.Type]
+	f.mu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	prov, err := creator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	f.cacheMu.Lock()
+	defer f.cacheMu.Unlock()
+	if el, ok := f.cache[key]; ok {
+		// Built concurrently by another lookup; keep the first
+		if prov != el.Value.(*cachedProvider).provider {
+			closeProvider(prov)
+		}
+		f.lru.MoveToFront(el)
+		return el.Value.(*cachedProvider).provider, nil
+	}
+	f.cache[key] = f.lru.PushFront(&cachedProvider{key: key, provider: prov})
+	for f.MaxCached > 0 && f.lru.Len() > f.MaxCached {
+		oldest := f.lru.Remove(f.lru.Back()).(*cachedProvider)
+		delete(f.cache, oldest.key)
+		closeProvider(oldest.provider)
+	}
+	return prov, nil
+}
+
+// closeProvider releases an evicted provider's resources, if it holds any
+func closeProvider(p EmailProvider) {
+	if c, ok := p.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
+
+func hasType(cfgs []ProviderConfig, t ProviderType) bool {
+	for _, c := range cfgs {
+		if c.Type == t {
+			return true
+		}
+	}
+	return false
+}
@@ -2,22 +2,33 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
 	"github.com/desponda/inbox-whisperer/internal/models"
 	"github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/oauth2"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
-type CtxKeyUserID struct{}
-type CtxKeyLimit struct{}
-
 var summaryCache sync.Map // per-user summary cache
 
 type MultiProviderEmailService struct {
 	Factory *EmailProviderFactory
+	// Settings is read for the local cache opt-out; optional (lists always use the cache when nil)
+	Settings data.UserSettingsRepository
+	// Tokens, if set, supplies the Gmail token for the user and account each call is for, in
+	// place of the token passed in, so a token picked up from the wrong context is never sent
+	// to another mailbox; optional (the passed token is used when nil)
+	Tokens data.UserTokenRepository
 }
 
 func NewMultiProviderEmailService(factory *EmailProviderFactory) *MultiProviderEmailService {
@@ -25,42 +36,73 @@ func NewMultiProviderEmailService(factory *EmailProviderFactory) *MultiProviderE
 }
 
 func (s *MultiProviderEmailService) FetchMessages(ctx context.Context, token *oauth2.Token) ([]models.EmailMessage, error) {
-	userIDVal := ctx.Value(CtxKeyUserID{})
-	if userIDVal == nil {
-		return nil, fmt.Errorf("userID context key missing")
+	ctx, span := tracing.Start(ctx, "email.FetchMessages")
+	defer span.End()
+	userID := ctxkeys.UserID(ctx)
+	if userID == "" {
+		return nil, fmt.Errorf("no user ID in context")
 	}
-	userID, ok := userIDVal.(string)
-	if !ok {
-		return nil, fmt.Errorf("userID context key not a string")
+	token, err := s.accountToken(ctx, userID, ctxkeys.AccountID(ctx), token)
+	if err != nil {
+		return nil, err
 	}
 	providers, err := s.Factory.ProvidersForUser(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 	limit := 10
-	if l, ok := ctx.Value(CtxKeyLimit{}).(int); ok && l > 0 {
+	if l := ctxkeys.Limit(ctx); l > 0 {
 		limit = l
 	}
+	if s.Settings != nil {
+		settings, err := s.Settings.Get(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if settings.DisableLocalCache {
+			return s.fetchPassthrough(ctx, token, providers, limit)
+		}
+	}
 	params := gmail.FetchParams{Limit: limit} // limit extracted from context, default 10
 	allSummaries := make([]models.EmailSummary, 0)
+	var syncErr error
 	for _, prov := range providers {
-		summaries, err := prov.FetchSummaries(ctx, userID, params)
+		pctx, pspan := tracing.Start(ctx, "provider.FetchSummaries", providerAttr(prov))
+		summaries, err := prov.FetchSummaries(pctx, userID, params)
+		tracing.End(pspan, err)
 		if err != nil {
+			if errors.Is(err, provider.ErrSyncing) {
+				syncErr = err
+			}
 			continue // skip errored providers
 		}
 		for _, s := range summaries {
 			allSummaries = append(allSummaries, models.EmailSummary{
-				ID:           s.ID,
-				ThreadID:     s.ThreadID,
-				Subject:      s.Subject,
-				Sender:       s.Sender,
-				Snippet:      s.Snippet,
-				InternalDate: s.InternalDate,
-				Date:         "", // Gmail summary doesn't yet provide Date
-				Provider:     s.Provider,
+				ID:             s.ID,
+				ThreadID:       s.ThreadID,
+				Subject:        s.Subject,
+				Sender:         s.Sender,
+				SenderAddress:  s.SenderAddress,
+				SenderName:     s.SenderName,
+				Snippet:        s.Snippet,
+				InternalDate:   s.InternalDate,
+				SizeEstimate:   s.SizeEstimate,
+				HasAttachments: s.HasAttachments,
+				Date:           "", // Gmail summary doesn't yet provide Date
+				Provider:       s.Provider,
+
+				Category:          s.Category,
+				ProviderCategory:  s.ProviderCategory,
+				ProviderImportant: s.ProviderImportant,
+				IsRead:            s.IsRead,
 			})
 		}
 	}
+	// Nothing to show yet because a mailbox is still on its first sync: say so rather than
+	// returning an empty inbox
+	if len(allSummaries) == 0 && syncErr != nil {
+		return nil, syncErr
+	}
 	// Deduplicate by ID (favor newest InternalDate)
 	deduped := make(map[string]models.EmailSummary)
 	for _, s := range allSummaries {
@@ -76,9 +118,13 @@ func (s *MultiProviderEmailService) FetchMessages(ctx context.Context, token *oa
 	sort.Slice(result, func(i, j int) bool { return result[i].InternalDate > result[j].InternalDate })
 	// Caching summary results for efficiency
 	cacheKey := userID
-	if l, ok := ctx.Value(CtxKeyLimit{}).(int); ok && l > 0 {
+	if l := ctxkeys.Limit(ctx); l > 0 {
 		cacheKey = fmt.Sprintf("%s:%d", userID, l)
 	}
+	// Later pages are cached apart from the first
+	if c := ctxkeys.CursorFrom(ctx); c.AfterID != "" {
+		cacheKey = fmt.Sprintf("%s:%d:%s", cacheKey, c.AfterInternalDate, c.AfterID)
+	}
 	type cacheEntry struct {
 		Summaries []models.EmailMessage
 		Expires   time.Time
@@ -90,58 +136,160 @@ func (s *MultiProviderEmailService) FetchMessages(ctx context.Context, token *oa
 		}
 	}
 
-	// Convert to []models.EmailMessage for now
-	final := make([]models.EmailMessage, len(result))
-	for i, s := range result {
+	final := toMessages(result)
+	// Update cache for this user/limit
+	summaryCache.Store(cacheKey, cacheEntry{
+		Summaries: final,
+		Expires:   time.Now().Add(60 * time.Second),
+	})
+	return final, nil
+}
+
+// providerAttr names the provider on its spans
+func providerAttr(prov gmail.EmailProvider) attribute.KeyValue {
+	return attribute.String("provider", strings.TrimPrefix(fmt.Sprintf("%T", prov), "*"))
+}
+
+// fetchPassthrough lists a page straight from a provider for users who disabled local caching.
+// Page tokens belong to a single provider, so the first linked provider that negotiates
+// passthrough serves the list; providers without it are skipped, since they can only serve
+// from the cache. Results are not kept in the in-memory summary cache either.
+func (s *MultiProviderEmailService) fetchPassthrough(ctx context.Context, token *oauth2.Token, providers []gmail.EmailProvider, limit int) ([]models.EmailMessage, error) {
+	pageToken := ctxkeys.PageToken(ctx)
+	for _, prov := range providers {
+		pp, ok := prov.(provider.PassthroughProvider)
+		if !ok || !prov.Capabilities().Passthrough {
+			continue
+		}
+		pctx, pspan := tracing.Start(ctx, "provider.ListPassthrough", providerAttr(prov))
+		page, err := pp.ListPassthrough(pctx, token, pageToken, limit)
+		tracing.End(pspan, err)
+		if err != nil {
+			return nil, err
+		}
+		ctxkeys.SetNextPageToken(ctx, page.NextPageToken)
+		return toMessages(page.Summaries), nil
+	}
+	return nil, fmt.Errorf("%w: no linked provider can list without the local cache", provider.ErrUnsupported)
+}
+
+// Convert to []models.EmailMessage for now
+func toMessages(summaries []models.EmailSummary) []models.EmailMessage {
+	final := make([]models.EmailMessage, len(summaries))
+	for i, s := range summaries {
 		final[i] = models.EmailMessage{
 			EmailMessageID: s.ID,
 			ThreadID:       s.ThreadID,
 			Subject:        s.Subject,
 			Sender:         s.Sender,
+			SenderAddress:  s.SenderAddress,
+			SenderName:     s.SenderName,
 			Snippet:        s.Snippet,
 			InternalDate:   s.InternalDate,
+			SizeEstimate:   s.SizeEstimate,
+			HasAttachments: s.HasAttachments,
 			Date:           s.Date,
+
+			Category:          sql.NullString{String: s.Category, Valid: s.Category != ""},
+			ProviderCategory:  s.ProviderCategory,
+			ProviderImportant: s.ProviderImportant,
+			IsRead:            s.IsRead,
 			// ...other fields
 		}
 	}
-	// Update cache for this user/limit
-	summaryCache.Store(cacheKey, cacheEntry{
-		Summaries: final,
-		Expires:   time.Now().Add(60 * time.Second),
-	})
-	return final, nil
+	return final
 }
 
 // FetchMessageContent fetches the full message from the right provider.
 func (s *MultiProviderEmailService) FetchMessageContent(ctx context.Context, token *oauth2.Token, id string) (*models.EmailMessage, error) {
-	userIDVal := ctx.Value(CtxKeyUserID{})
-	if userIDVal == nil {
-		return nil, fmt.Errorf("userID context key missing")
+	userID := ctxkeys.UserID(ctx)
+	if userID == "" {
+		return nil, fmt.Errorf("no user ID in context")
+	}
+	token, err := s.accountToken(ctx, userID, ctxkeys.AccountID(ctx), token)
+	if err != nil {
+		return nil, err
+	}
+	return s.fetchMessageContent(ctx, userID, token, id)
+}
+
+// FetchMessageContentFor fetches a message of the user's account ("" for their default
+// account) without reading the user or token from ctx, for background jobs and other callers
+// acting outside the user's session. It requires Tokens.
+func (s *MultiProviderEmailService) FetchMessageContentFor(ctx context.Context, userID, accountID, id string) (*models.EmailMessage, error) {
+	if s.Tokens == nil {
+		return nil, errors.New("no token store to resolve the account token")
 	}
-	userID, ok := userIDVal.(string)
-	if !ok {
-		return nil, fmt.Errorf("userID context key not a string")
+	token, err := s.accountToken(ctx, userID, accountID, nil)
+	if err != nil {
+		return nil, err
 	}
+	return s.fetchMessageContent(ctx, userID, token, id)
+}
+
+// accountToken returns the Gmail token to use for the user's account: loaded from Tokens when
+// set, and fallback otherwise. A user without Gmail gets a nil token, since other providers
+// load their own credentials; an unknown account is ErrNotFound.
+func (s *MultiProviderEmailService) accountToken(ctx context.Context, userID, accountID string, fallback *oauth2.Token) (*oauth2.Token, error) {
+	if s.Tokens == nil {
+		return fallback, nil
+	}
+	token, err := s.Tokens.GetUserToken(ctx, userID, data.ProviderGmail, accountID)
+	if errors.Is(err, data.ErrNotFound) {
+		if accountID != "" {
+			return nil, fmt.Errorf("%w: no linked account %s", provider.ErrNotFound, accountID)
+		}
+		return nil, nil
+	}
+	return token, err
+}
+
+func (s *MultiProviderEmailService) fetchMessageContent(ctx context.Context, userID string, token *oauth2.Token, id string) (*models.EmailMessage, error) {
+	ctx, span := tracing.Start(ctx, "email.FetchMessageContent")
+	defer span.End()
+	// Providers read the user from ctx for their caches
+	ctx = ctxkeys.WithUserID(ctx, userID)
 	providers, err := s.Factory.ProvidersForUser(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
+	// Report the most informative failure: a provider error beats "not found" from the others
+	lastErr := provider.ErrNotFound
 	for _, prov := range providers {
-		msg, err := prov.FetchMessage(ctx, token, id)
-		if err == nil {
-			return &models.EmailMessage{
-				EmailMessageID: msg.EmailMessageID,
-				ThreadID:       msg.ThreadID,
-				Subject:        msg.Subject,
-				Sender:         msg.Sender,
-				Recipient:      msg.Recipient,
-				Snippet:        msg.Snippet,
-				Body:           msg.Body,
-				InternalDate:   msg.InternalDate,
-				Date:           msg.Date,
-				// ...other fields
-			}, nil
+		pctx, pspan := tracing.Start(ctx, "provider.FetchMessage", providerAttr(prov))
+		msg, err := prov.FetchMessage(pctx, token, id)
+		if errors.Is(err, provider.ErrNotFound) {
+			// Expected of every provider but the message's own
+			tracing.End(pspan, nil)
+		} else {
+			tracing.End(pspan, err)
 		}
+		if err != nil {
+			if !errors.Is(err, provider.ErrNotFound) {
+				lastErr = err
+			}
+			continue
+		}
+		return &models.EmailMessage{
+			EmailMessageID: msg.EmailMessageID,
+			ThreadID:       msg.ThreadID,
+			Subject:        msg.Subject,
+			Sender:         msg.Sender,
+			SenderAddress:  msg.SenderAddress,
+			SenderName:     msg.SenderName,
+			Recipient:      msg.Recipient,
+			Cc:             msg.Cc,
+			Bcc:            msg.Bcc,
+			ReplyTo:        msg.ReplyTo,
+			Snippet:        msg.Snippet,
+			Body:           msg.Body,
+			InternalDate:   msg.InternalDate,
+			SizeEstimate:   msg.SizeEstimate,
+			HasAttachments: msg.HasAttachments,
+			Date:           msg.Date,
+			IsRead:         msg.IsRead,
+			// ...other fields
+		}, nil
 	}
-	return nil, err
+	return nil, lastErr
 }
@@ -2,9 +2,14 @@ package service_test
 
 import (
 	"context"
+	"errors"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
 	"github.com/desponda/inbox-whisperer/internal/models"
 	"github.com/desponda/inbox-whisperer/internal/service"
 	"github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"golang.org/x/oauth2"
 	"testing"
 )
 
@@ -18,6 +23,9 @@ func (d *dummyProvider) FetchSummaries(ctx context.Context, userID string, param
 func (d *dummyProvider) FetchMessage(ctx context.Context, token interface{}, messageID string) (*models.EmailMessage, error) {
 	return &models.EmailMessage{}, nil
 }
+func (d *dummyProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{}
+}
 
 func TestMultiProviderEmailService_FetchMessages_DeduplicationAndSorting(t *testing.T) {
 	factory := service.NewEmailProviderFactory()
@@ -34,7 +42,7 @@ func TestMultiProviderEmailService_FetchMessages_DeduplicationAndSorting(t *test
 	factory.LinkProvider("user", service.ProviderConfig{UserID: "user", Type: service.ProviderGmail})
 	factory.LinkProvider("user", service.ProviderConfig{UserID: "user", Type: service.ProviderOutlook})
 	svc := service.NewMultiProviderEmailService(factory)
-	ctx := context.WithValue(context.Background(), service.CtxKeyUserID{}, "user")
+	ctx := ctxkeys.WithUserID(context.Background(), "user")
 	msgs, err := svc.FetchMessages(ctx, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -49,3 +57,228 @@ func TestMultiProviderEmailService_FetchMessages_DeduplicationAndSorting(t *test
 		t.Errorf("expected order c then b, got %+v, %+v", msgs[1], msgs[2])
 	}
 }
+
+// passthroughProvider serves pages keyed by page token and counts cached listings
+type passthroughProvider struct {
+	dummyProvider
+	pages       map[string]*models.SummaryPage
+	cachedLists int
+}
+
+func (p *passthroughProvider) FetchSummaries(ctx context.Context, userID string, params gmail.FetchParams) ([]models.EmailSummary, error) {
+	p.cachedLists++
+	return nil, nil
+}
+func (p *passthroughProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{Passthrough: true}
+}
+func (p *passthroughProvider) ListPassthrough(ctx context.Context, token *oauth2.Token, pageToken string, limit int) (*models.SummaryPage, error) {
+	return p.pages[pageToken], nil
+}
+
+type staticSettings struct {
+	disableLocalCache bool
+}
+
+func (s staticSettings) Get(ctx context.Context, userID string) (*models.UserSettings, error) {
+	return &models.UserSettings{UserID: userID, DisableLocalCache: s.disableLocalCache}, nil
+}
+func (s staticSettings) Upsert(ctx context.Context, settings *models.UserSettings) error { return nil }
+
+func TestMultiProviderEmailService_FetchMessages_Passthrough(t *testing.T) {
+	factory := service.NewEmailProviderFactory()
+	cacheOnly := &dummyProvider{summaries: []models.EmailSummary{{ID: "cached", InternalDate: 500}}}
+	direct := &passthroughProvider{pages: map[string]*models.SummaryPage{
+		"":   {Summaries: []models.EmailSummary{{ID: "a", InternalDate: 200}}, NextPageToken: "p2"},
+		"p2": {Summaries: []models.EmailSummary{{ID: "b", InternalDate: 100}}},
+	}}
+	factory.RegisterProvider(service.ProviderOutlook, func(cfg service.ProviderConfig) (service.EmailProvider, error) { return cacheOnly, nil })
+	factory.RegisterProvider(service.ProviderGmail, func(cfg service.ProviderConfig) (service.EmailProvider, error) { return direct, nil })
+	factory.LinkProvider("private", service.ProviderConfig{UserID: "private", Type: service.ProviderOutlook})
+	factory.LinkProvider("private", service.ProviderConfig{UserID: "private", Type: service.ProviderGmail})
+	svc := service.NewMultiProviderEmailService(factory)
+	svc.Settings = staticSettings{disableLocalCache: true}
+
+	list := func(pageToken string) ([]models.EmailMessage, *ctxkeys.PageInfo) {
+		info := &ctxkeys.PageInfo{}
+		ctx := ctxkeys.WithUserID(context.Background(), "private")
+		ctx = ctxkeys.WithPageToken(ctx, pageToken)
+		ctx = ctxkeys.WithPageInfo(ctx, info)
+		msgs, err := svc.FetchMessages(ctx, &oauth2.Token{AccessToken: "tok"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return msgs, info
+	}
+	msgs, info := list("")
+	if len(msgs) != 1 || msgs[0].EmailMessageID != "a" || info.NextPageToken != "p2" {
+		t.Fatalf("first page: got %+v, next %q", msgs, info.NextPageToken)
+	}
+	msgs, info = list("p2")
+	if len(msgs) != 1 || msgs[0].EmailMessageID != "b" || info.NextPageToken != "" {
+		t.Fatalf("second page: got %+v, next %q", msgs, info.NextPageToken)
+	}
+	if direct.cachedLists != 0 {
+		t.Errorf("expected no cached listing, got %d", direct.cachedLists)
+	}
+}
+
+func TestMultiProviderEmailService_FetchMessages_PassthroughUnsupported(t *testing.T) {
+	factory := service.NewEmailProviderFactory()
+	factory.RegisterProvider(service.ProviderGmail, func(cfg service.ProviderConfig) (service.EmailProvider, error) {
+		return &dummyProvider{summaries: []models.EmailSummary{{ID: "cached"}}}, nil
+	})
+	factory.LinkProvider("private2", service.ProviderConfig{UserID: "private2", Type: service.ProviderGmail})
+	svc := service.NewMultiProviderEmailService(factory)
+	svc.Settings = staticSettings{disableLocalCache: true}
+	ctx := ctxkeys.WithUserID(context.Background(), "private2")
+	if _, err := svc.FetchMessages(ctx, nil); !errors.Is(err, provider.ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+// linkedAccounts reports the same stored providers for every user
+type linkedAccounts []string
+
+func (l linkedAccounts) LinkedProviders(ctx context.Context, userID string) ([]string, error) {
+	return l, nil
+}
+
+func TestEmailProviderFactory_StoredAccounts(t *testing.T) {
+	factory := service.NewEmailProviderFactory()
+	factory.RegisterProvider(service.ProviderOutlook, func(cfg service.ProviderConfig) (service.EmailProvider, error) {
+		return &dummyProvider{summaries: []models.EmailSummary{{ID: "ms", Provider: "outlook"}}}, nil
+	})
+	if _, err := factory.ProvidersForUser(context.Background(), "stored"); err == nil {
+		t.Fatal("expected an error without linked providers")
+	}
+	factory.Accounts = linkedAccounts{"outlook", "unknown"}
+	svc := service.NewMultiProviderEmailService(factory)
+	msgs, err := svc.FetchMessages(ctxkeys.WithUserID(context.Background(), "stored"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].EmailMessageID != "ms" {
+		t.Errorf("expected the outlook message, got %+v", msgs)
+	}
+}
+
+// tokenRecordingProvider records the token and context user each message fetch ran with
+type tokenRecordingProvider struct {
+	dummyProvider
+	token  *oauth2.Token
+	ctxUID string
+}
+
+func (p *tokenRecordingProvider) FetchMessage(ctx context.Context, token interface{}, messageID string) (*models.EmailMessage, error) {
+	p.token, _ = token.(*oauth2.Token)
+	p.ctxUID = ctxkeys.UserID(ctx)
+	return &models.EmailMessage{EmailMessageID: messageID}, nil
+}
+
+// userTokens holds one Gmail token per user and account ("" is the default account)
+type userTokens map[string]*oauth2.Token
+
+func (u userTokens) SaveUserToken(ctx context.Context, userID, provider, accountID string, token *oauth2.Token) error {
+	return nil
+}
+
+func (u userTokens) GetUserToken(ctx context.Context, userID, provider, accountID string) (*oauth2.Token, error) {
+	if tok, ok := u[userID+"/"+accountID]; ok {
+		return tok, nil
+	}
+	return nil, data.ErrNotFound
+}
+
+func TestMultiProviderEmailService_ResolvesAccountTokens(t *testing.T) {
+	prov := &tokenRecordingProvider{}
+	factory := service.NewEmailProviderFactory()
+	factory.RegisterProvider(service.ProviderGmail, func(cfg service.ProviderConfig) (service.EmailProvider, error) { return prov, nil })
+	factory.LinkProvider("alice", service.ProviderConfig{UserID: "alice", Type: service.ProviderGmail})
+	factory.LinkProvider("bob", service.ProviderConfig{UserID: "bob", Type: service.ProviderGmail})
+	alice := &oauth2.Token{AccessToken: "alice"}
+	aliceWork := &oauth2.Token{AccessToken: "alice-work"}
+	bob := &oauth2.Token{AccessToken: "bob"}
+	svc := service.NewMultiProviderEmailService(factory)
+	svc.Tokens = userTokens{"alice/": alice, "alice/work@example.com": aliceWork, "bob/": bob}
+
+	// A token left in the request by someone else's session, e.g. an admin acting as bob, is
+	// not sent to bob's mailbox
+	ctx := ctxkeys.WithUserID(context.Background(), "bob")
+	if _, err := svc.FetchMessageContent(ctx, alice, "m1"); err != nil {
+		t.Fatalf("FetchMessageContent failed: %v", err)
+	}
+	if prov.token != bob {
+		t.Errorf("expected bob's own token, got %+v", prov.token)
+	}
+
+	// The requested account picks that account's token
+	ctx = ctxkeys.WithAccountID(ctxkeys.WithUserID(context.Background(), "alice"), "work@example.com")
+	if _, err := svc.FetchMessageContent(ctx, nil, "m1"); err != nil || prov.token != aliceWork {
+		t.Errorf("expected the work account's token, got %+v, %v", prov.token, err)
+	}
+	ctx = ctxkeys.WithAccountID(ctxkeys.WithUserID(context.Background(), "alice"), "other@example.com")
+	if _, err := svc.FetchMessageContent(ctx, alice, "m1"); !errors.Is(err, provider.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an account the user has not linked, got %v", err)
+	}
+
+	// Background jobs name the user and account instead of relying on ctx, which may carry
+	// another user's identity
+	ctx = ctxkeys.WithToken(ctxkeys.WithUserID(context.Background(), "bob"), bob)
+	if _, err := svc.FetchMessageContentFor(ctx, "alice", "", "m2"); err != nil {
+		t.Fatalf("FetchMessageContentFor failed: %v", err)
+	}
+	if prov.token != alice || prov.ctxUID != "alice" {
+		t.Errorf("expected alice's token and user, got %+v for %q", prov.token, prov.ctxUID)
+	}
+}
+
+// closingProvider counts its Close calls
+type closingProvider struct {
+	dummyProvider
+	closed int
+}
+
+func (p *closingProvider) Close() error {
+	p.closed++
+	return nil
+}
+
+func TestEmailProviderFactory_CachesProvidersWithEviction(t *testing.T) {
+	factory := service.NewEmailProviderFactory()
+	factory.MaxCached = 2
+	built := map[string]*closingProvider{}
+	factory.RegisterProvider(service.ProviderGmail, func(cfg service.ProviderConfig) (service.EmailProvider, error) {
+		p := &closingProvider{}
+		built[cfg.UserID] = p
+		return p, nil
+	})
+	ctx := context.Background()
+	lookup := func(userID string) service.EmailProvider {
+		t.Helper()
+		providers, err := factory.ProvidersForUser(ctx, userID)
+		if err != nil || len(providers) != 1 {
+			t.Fatalf("%s: expected one provider, got %v (%v)", userID, providers, err)
+		}
+		return providers[0]
+	}
+	for _, u := range []string{"a", "b", "c"} {
+		factory.LinkProvider(u, service.ProviderConfig{UserID: u, Type: service.ProviderGmail})
+	}
+
+	first := lookup("a")
+	lookup("b")
+	if lookup("a") != first || len(built) != 2 {
+		t.Fatalf("expected a's provider reused, got %d built", len(built))
+	}
+	// c evicts b, the least recently used, and closes it
+	lookup("c")
+	if built["b"].closed != 1 || built["a"].closed != 0 {
+		t.Errorf("expected b closed and a kept, got b %d, a %d", built["b"].closed, built["a"].closed)
+	}
+	// b is rebuilt on its next lookup, evicting a
+	lookup("b")
+	if built["b"].closed != 0 || built["a"].closed != 1 || len(built) != 3 {
+		t.Errorf("expected a fresh b and a closed, got b %d, a %d", built["b"].closed, built["a"].closed)
+	}
+}
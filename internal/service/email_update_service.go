@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/oauth2"
+)
+
+// LabelApplier adds and removes message labels by name (see LabelService.ApplyLabel)
+type LabelApplier interface {
+	ApplyLabel(ctx context.Context, userID string, token *oauth2.Token, messageID, labelName string) error
+	RemoveLabel(ctx context.Context, userID string, token *oauth2.Token, messageID, labelName string) error
+}
+
+// CategoryStore is the local message cache a category override is written to
+type CategoryStore interface {
+	GetMessageByID(ctx context.Context, userID, emailMessageID string) (*models.EmailMessage, error)
+	SetCategory(ctx context.Context, userID, emailMessageID, category string, confidence float64) error
+}
+
+// Limits on a single message update
+const (
+	maxUpdateLabels    = 20
+	maxOverrideNameLen = 100
+)
+
+// EmailUpdateService applies users' changes to individual messages: labels, a category override
+// and the starred flag. Every change is stored as a user override; the ones the provider supports
+// are applied there as well. The category override is Whisperer's own: it never leaves the
+// server and is written to the cached message, where lists and the detail view read it.
+type EmailUpdateService struct {
+	provider EmailProvider
+	repo     data.EmailOverrideRepository
+	labels   LabelApplier
+	messages CategoryStore
+}
+
+func NewEmailUpdateService(p EmailProvider, repo data.EmailOverrideRepository, labels LabelApplier, messages CategoryStore) *EmailUpdateService {
+	return &EmailUpdateService{provider: p, repo: repo, labels: labels, messages: messages}
+}
+
+// Update applies update to the message, at the provider first so a provider failure leaves
+// nothing recorded. Returns ErrInvalidUpdate if the update is empty or malformed, and
+// data.ErrNotFound if the message is not cached.
+func (s *EmailUpdateService) Update(ctx context.Context, userID string, token *oauth2.Token, messageID string, update models.EmailUpdate) (*models.EmailUserOverride, error) {
+	update, err := normalizeUpdate(update)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := s.messages.GetMessageByID(ctx, userID, messageID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && msg == nil) {
+		return nil, data.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	synced := []string{}
+	caps := s.provider.Capabilities()
+	if len(update.Labels)+len(update.RemoveLabels) > 0 && caps.ApplyLabels && s.labels != nil {
+		for _, name := range update.Labels {
+			if err := s.labels.ApplyLabel(ctx, userID, token, messageID, name); err != nil {
+				return nil, err
+			}
+		}
+		for _, name := range update.RemoveLabels {
+			if err := s.labels.RemoveLabel(ctx, userID, token, messageID, name); err != nil {
+				return nil, err
+			}
+		}
+		synced = append(synced, "labels")
+	}
+	if sp, ok := s.provider.(provider.StarProvider); ok && update.Starred != nil && caps.Star {
+		if err := sp.SetStarred(ctx, token, messageID, *update.Starred); err != nil {
+			return nil, err
+		}
+		synced = append(synced, "starred")
+	}
+	if update.Category != nil {
+		if err := s.messages.SetCategory(ctx, userID, messageID, *update.Category, 1); err != nil {
+			return nil, err
+		}
+	}
+	o, err := s.repo.Apply(ctx, userID, messageID, update)
+	if err != nil {
+		return nil, err
+	}
+	o.ProviderSynced = synced
+	return o, nil
+}
+
+// normalizeUpdate trims names and drops duplicate labels
+func normalizeUpdate(u models.EmailUpdate) (models.EmailUpdate, error) {
+	if len(u.Labels) == 0 && len(u.RemoveLabels) == 0 && u.Category == nil && u.Starred == nil {
+		return u, fmt.Errorf("%w: nothing to update", ErrInvalidUpdate)
+	}
+	if len(u.Labels)+len(u.RemoveLabels) > maxUpdateLabels {
+		return u, fmt.Errorf("%w: at most %d labels per update", ErrInvalidUpdate, maxUpdateLabels)
+	}
+	var err error
+	if u.Labels, err = normalizeLabels(u.Labels); err != nil {
+		return u, err
+	}
+	if u.RemoveLabels, err = normalizeLabels(u.RemoveLabels); err != nil {
+		return u, err
+	}
+	for _, l := range u.RemoveLabels {
+		if slices.ContainsFunc(u.Labels, func(a string) bool { return strings.EqualFold(a, l) }) {
+			return u, fmt.Errorf("%w: label %q is both added and removed", ErrInvalidUpdate, l)
+		}
+	}
+	if u.Category != nil {
+		c := strings.TrimSpace(*u.Category)
+		if c == "" || len(c) > maxOverrideNameLen {
+			return u, fmt.Errorf("%w: category must be 1 to %d characters", ErrInvalidUpdate, maxOverrideNameLen)
+		}
+		u.Category = &c
+	}
+	return u, nil
+}
+
+// normalizeLabels trims label names and drops duplicates, whatever their case
+func normalizeLabels(names []string) ([]string, error) {
+	var labels []string
+	seen := map[string]bool{}
+	for _, l := range names {
+		l = strings.TrimSpace(l)
+		if l == "" || len(l) > maxOverrideNameLen {
+			return nil, fmt.Errorf("%w: label names must be 1 to %d characters", ErrInvalidUpdate, maxOverrideNameLen)
+		}
+		if !seen[strings.ToLower(l)] {
+			seen[strings.ToLower(l)] = true
+			labels = append(labels, l)
+		}
+	}
+	return labels, nil
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/oauth2"
+)
+
+type fakeOverrideRepo struct {
+	overrides map[string]*models.EmailUserOverride
+}
+
+func (f *fakeOverrideRepo) Apply(ctx context.Context, userID, emailMessageID string, update models.EmailUpdate) (*models.EmailUserOverride, error) {
+	o := f.overrides[emailMessageID]
+	if o == nil {
+		o = &models.EmailUserOverride{UserID: userID, EmailMessageID: emailMessageID}
+		f.overrides[emailMessageID] = o
+	}
+	o.Labels = append(o.Labels, update.Labels...)
+	o.Labels = slices.DeleteFunc(o.Labels, func(l string) bool {
+		return slices.ContainsFunc(update.RemoveLabels, func(r string) bool { return strings.EqualFold(l, r) })
+	})
+	if update.Category != nil {
+		o.Category = update.Category
+	}
+	if update.Starred != nil {
+		o.Starred = update.Starred
+	}
+	return o, nil
+}
+
+func (f *fakeOverrideRepo) Get(ctx context.Context, userID, emailMessageID string) (*models.EmailUserOverride, error) {
+	if o := f.overrides[emailMessageID]; o != nil {
+		return o, nil
+	}
+	return nil, data.ErrNotFound
+}
+
+type fakeLabelApplier struct {
+	applied []string
+	removed []string
+}
+
+func (f *fakeLabelApplier) ApplyLabel(ctx context.Context, userID string, token *oauth2.Token, messageID, labelName string) error {
+	f.applied = append(f.applied, labelName)
+	return nil
+}
+
+func (f *fakeLabelApplier) RemoveLabel(ctx context.Context, userID string, token *oauth2.Token, messageID, labelName string) error {
+	f.removed = append(f.removed, labelName)
+	return nil
+}
+
+// fakeCategoryStore holds cached messages by ID
+type fakeCategoryStore map[string]*models.EmailMessage
+
+func (f fakeCategoryStore) GetMessageByID(ctx context.Context, userID, emailMessageID string) (*models.EmailMessage, error) {
+	if m := f[emailMessageID]; m != nil {
+		return m, nil
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (f fakeCategoryStore) SetCategory(ctx context.Context, userID, emailMessageID, category string, confidence float64) error {
+	m := f[emailMessageID]
+	if m == nil {
+		return data.ErrNotFound
+	}
+	m.Category = sql.NullString{String: category, Valid: true}
+	m.CategorizationConfidence = sql.NullFloat64{Float64: confidence, Valid: true}
+	return nil
+}
+
+type fakeStarProvider struct {
+	fakeActionProvider
+	starred map[string]bool
+	err     error
+}
+
+func (p *fakeStarProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{Labels: true, ApplyLabels: true, Star: true}
+}
+
+func (p *fakeStarProvider) SetStarred(ctx context.Context, token *oauth2.Token, messageID string, starred bool) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.starred[messageID] = starred
+	return nil
+}
+
+func TestEmailUpdateService_Update(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeOverrideRepo{overrides: map[string]*models.EmailUserOverride{}}
+	labels := &fakeLabelApplier{}
+	p := &fakeStarProvider{starred: map[string]bool{}}
+	messages := fakeCategoryStore{"m1": {EmailMessageID: "m1", Category: sql.NullString{String: "Personal", Valid: true}}, "m2": {EmailMessageID: "m2"}}
+	svc := NewEmailUpdateService(p, repo, labels, messages)
+
+	category, starred := " Updates ", true
+	o, err := svc.Update(ctx, "u1", nil, "m1", models.EmailUpdate{Labels: []string{"Work", " work ", "Receipts"}, Category: &category, Starred: &starred})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if len(labels.applied) != 2 || labels.applied[0] != "Work" || labels.applied[1] != "Receipts" {
+		t.Errorf("expected deduplicated labels applied at the provider, got %v", labels.applied)
+	}
+	if !p.starred["m1"] {
+		t.Error("expected m1 starred at the provider")
+	}
+	if *o.Category != "Updates" || len(o.ProviderSynced) != 2 {
+		t.Errorf("unexpected override: %+v", o)
+	}
+	if messages["m1"].Category.String != "Updates" {
+		t.Errorf("expected the category override on the cached message, got %+v", messages["m1"].Category)
+	}
+
+	o, err = svc.Update(ctx, "u1", nil, "m1", models.EmailUpdate{RemoveLabels: []string{"work"}})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if len(labels.removed) != 1 || len(o.Labels) != 1 || o.Labels[0] != "Receipts" || o.ProviderSynced[0] != "labels" {
+		t.Errorf("expected Work removed at the provider and locally, removed %v, got %+v", labels.removed, o)
+	}
+
+	// Messages that are not cached are refused before reaching the provider
+	if _, err := svc.Update(ctx, "u1", nil, "m9", models.EmailUpdate{Category: &category}); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown message, got %v", err)
+	}
+	if _, ok := repo.overrides["m9"]; ok {
+		t.Error("expected no override for an unknown message")
+	}
+
+	// A provider failure records nothing
+	p.err = &provider.Error{Kind: provider.ErrTemporary, Err: errors.New("503")}
+	if _, err := svc.Update(ctx, "u1", nil, "m2", models.EmailUpdate{Starred: &starred}); !errors.Is(err, provider.ErrTemporary) {
+		t.Errorf("expected the provider error, got %v", err)
+	}
+	if _, err := repo.Get(ctx, "u1", "m2"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("expected no override after a provider failure, got %v", err)
+	}
+
+	for name, u := range map[string]models.EmailUpdate{
+		"empty":          {},
+		"blank label":    {Labels: []string{" "}},
+		"add and remove": {Labels: []string{"Work"}, RemoveLabels: []string{"WORK"}},
+		"blank category": func() models.EmailUpdate {
+			c := ""
+			return models.EmailUpdate{Category: &c}
+		}(),
+	} {
+		if _, err := svc.Update(ctx, "u1", nil, "m1", u); !errors.Is(err, ErrInvalidUpdate) {
+			t.Errorf("%s: expected ErrInvalidUpdate, got %v", name, err)
+		}
+	}
+}
+
+func TestEmailUpdateService_StoresUnsupportedChangesLocally(t *testing.T) {
+	repo := &fakeOverrideRepo{overrides: map[string]*models.EmailUserOverride{}}
+	// fakeActionProvider applies labels but cannot star
+	svc := NewEmailUpdateService(&fakeActionProvider{}, repo, &fakeLabelApplier{}, fakeCategoryStore{"m1": {EmailMessageID: "m1"}})
+	starred := true
+	o, err := svc.Update(context.Background(), "u1", nil, "m1", models.EmailUpdate{Starred: &starred})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if o.Starred == nil || !*o.Starred || len(o.ProviderSynced) != 0 {
+		t.Errorf("expected starred stored locally only, got %+v", o)
+	}
+}
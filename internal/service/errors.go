@@ -1 +1,9 @@
 package service
+
+import "errors"
+
+// ErrSystemLabel is returned when attempting to modify a provider-owned system label
+var ErrSystemLabel = errors.New("system labels cannot be modified")
+
+// ErrInvalidUpdate is returned when a message update is empty or malformed
+var ErrInvalidUpdate = errors.New("invalid message update")
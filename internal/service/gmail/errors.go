@@ -1 +1,115 @@
 package gmail
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/metrics"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// ErrNotFound is returned when Gmail reports that a message does not exist.
+// It is the shared provider sentinel so callers need not import this package to match it.
+var ErrNotFound = provider.ErrNotFound
+
+// rateLimitReasons are the googleapi error reasons Gmail uses for quota exhaustion on a 403
+var rateLimitReasons = map[string]bool{
+	"rateLimitExceeded":     true,
+	"userRateLimitExceeded": true,
+	"quotaExceeded":         true,
+}
+
+// scopeReasons are the googleapi error reasons for a token that lacks the call's scope. Access is
+// normally gated on granted scopes before calling Gmail; this catches grants that changed since.
+var scopeReasons = map[string]bool{
+	"insufficientPermissions":         true,
+	"ACCESS_TOKEN_SCOPE_INSUFFICIENT": true,
+}
+
+// classifyError maps a Gmail API error onto the provider error taxonomy.
+// Errors that do not match a known category are returned unchanged.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		// An unavailable token endpoint is retried; otherwise the refresh was rejected and the
+		// user must re-authorize
+		if retrieveErr.Response != nil && retrieveErr.Response.StatusCode >= http.StatusInternalServerError {
+			return &provider.Error{Kind: provider.ErrTemporary, Err: err}
+		}
+		return &provider.Error{Kind: provider.ErrAuthExpired, Err: err}
+	}
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	switch {
+	case apiErr.Code == http.StatusNotFound:
+		return ErrNotFound
+	case apiErr.Code == http.StatusBadRequest:
+		return &provider.Error{Kind: provider.ErrInvalidRequest, Err: err}
+	case apiErr.Code == http.StatusConflict:
+		return &provider.Error{Kind: provider.ErrConflict, Err: err}
+	case apiErr.Code == http.StatusUnauthorized:
+		return &provider.Error{Kind: provider.ErrAuthExpired, Err: err}
+	case apiErr.Code == http.StatusForbidden && hasReason(apiErr, scopeReasons):
+		return &provider.Error{Kind: provider.ErrMissingScope, Err: err}
+	case apiErr.Code == http.StatusTooManyRequests, apiErr.Code == http.StatusForbidden && hasReason(apiErr, rateLimitReasons):
+		return &provider.Error{Kind: provider.ErrRateLimited, Err: err, RetryAfter: parseRetryAfter(apiErr.Header)}
+	case apiErr.Code >= http.StatusInternalServerError:
+		return &provider.Error{Kind: provider.ErrTemporary, Err: err, RetryAfter: parseRetryAfter(apiErr.Header)}
+	}
+	return err
+}
+
+func hasReason(apiErr *googleapi.Error, reasons map[string]bool) bool {
+	for _, item := range apiErr.Errors {
+		if reasons[item.Reason] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds; HTTP-date values are ignored
+func parseRetryAfter(h http.Header) time.Duration {
+	if h == nil {
+		return 0
+	}
+	secs, err := strconv.Atoi(h.Get("Retry-After"))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// callStatus is the metrics status class of a Gmail API call's outcome; quota rejections
+// count as rate limited whether Gmail answered 429 or 403
+func callStatus(err error) string {
+	if err == nil {
+		return metrics.Status2xx
+	}
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return metrics.Status4xx
+	}
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return metrics.StatusError
+	}
+	switch {
+	case apiErr.Code == http.StatusTooManyRequests, apiErr.Code == http.StatusForbidden && hasReason(apiErr, rateLimitReasons):
+		return metrics.StatusRateLimited
+	case apiErr.Code >= http.StatusInternalServerError:
+		return metrics.Status5xx
+	case apiErr.Code >= http.StatusBadRequest:
+		return metrics.Status4xx
+	}
+	return metrics.Status2xx
+}
@@ -0,0 +1,75 @@
+package gmail
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/metrics"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+func TestClassifyError(t *testing.T) {
+	retryHeader := http.Header{}
+	retryHeader.Set("Retry-After", "12")
+	tests := []struct {
+		name           string
+		err            error
+		wantKind       error
+		wantRetryAfter time.Duration
+	}{
+		{"404", &googleapi.Error{Code: 404}, provider.ErrNotFound, 0},
+		{"401", &googleapi.Error{Code: 401}, provider.ErrAuthExpired, 0},
+		{"token refresh rejected", &oauth2.RetrieveError{ErrorCode: "invalid_grant"}, provider.ErrAuthExpired, 0},
+		{"token endpoint unavailable", &oauth2.RetrieveError{Response: &http.Response{StatusCode: 503}}, provider.ErrTemporary, 0},
+		{"429 with Retry-After", &googleapi.Error{Code: 429, Header: retryHeader}, provider.ErrRateLimited, 12 * time.Second},
+		{"403 quota", &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "userRateLimitExceeded"}}}, provider.ErrRateLimited, 0},
+		{"403 insufficient scope", &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "insufficientPermissions"}}}, provider.ErrMissingScope, 0},
+		{"503", &googleapi.Error{Code: 503}, provider.ErrTemporary, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyError(tt.err)
+			if !errors.Is(got, tt.wantKind) {
+				t.Fatalf("expected %v, got %v", tt.wantKind, got)
+			}
+			if rd := provider.RetryAfter(got); rd != tt.wantRetryAfter {
+				t.Errorf("expected RetryAfter %v, got %v", tt.wantRetryAfter, rd)
+			}
+		})
+	}
+
+	// Errors outside the taxonomy pass through untouched
+	forbidden := &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "domainPolicy"}}}
+	if got := classifyError(forbidden); got != error(forbidden) {
+		t.Errorf("expected unclassified error to pass through, got %v", got)
+	}
+	plain := errors.New("boom")
+	if got := classifyError(plain); got != plain {
+		t.Errorf("expected plain error to pass through, got %v", got)
+	}
+}
+
+func TestCallStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, metrics.Status2xx},
+		{&googleapi.Error{Code: 404}, metrics.Status4xx},
+		{&googleapi.Error{Code: 429}, metrics.StatusRateLimited},
+		{&googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "quotaExceeded"}}}, metrics.StatusRateLimited},
+		{&googleapi.Error{Code: 403}, metrics.Status4xx},
+		{&googleapi.Error{Code: 502}, metrics.Status5xx},
+		{&oauth2.RetrieveError{ErrorCode: "invalid_grant"}, metrics.Status4xx},
+		{errors.New("connection reset"), metrics.StatusError},
+	}
+	for _, tt := range tests {
+		if got := callStatus(tt.err); got != tt.want {
+			t.Errorf("callStatus(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
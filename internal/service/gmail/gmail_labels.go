@@ -0,0 +1,239 @@
+package gmail
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+type UsersLabelsListCall interface {
+	Do(...googleapi.CallOption) (*gmail.ListLabelsResponse, error)
+}
+
+type UsersLabelsPatchCall interface {
+	Do(...googleapi.CallOption) (*gmail.Label, error)
+}
+
+type UsersLabelsCreateCall interface {
+	Do(...googleapi.CallOption) (*gmail.Label, error)
+}
+
+type UsersMessagesModifyCall interface {
+	Do(...googleapi.CallOption) (*gmail.Message, error)
+}
+
+type UsersMessagesTrashCall interface {
+	Do(...googleapi.CallOption) (*gmail.Message, error)
+}
+
+// GmailLabelsAPI defines the subset of the Gmail labels API used by GmailService.
+type GmailLabelsAPI interface {
+	UsersLabelsList(userID string) UsersLabelsListCall
+	UsersLabelsPatch(userID, labelID string, label *gmail.Label) UsersLabelsPatchCall
+	UsersLabelsCreate(userID string, label *gmail.Label) UsersLabelsCreateCall
+	UsersMessagesModify(userID, msgID string, req *gmail.ModifyMessageRequest) UsersMessagesModifyCall
+	UsersMessagesTrash(userID, msgID string) UsersMessagesTrashCall
+}
+
+// ListLabels fetches all labels (system and user) for the token's account
+func (s *GmailService) ListLabels(ctx context.Context, token *oauth2.Token) ([]*models.Label, error) {
+	var call UsersLabelsListCall
+	if s.LabelsAPI != nil {
+		call = s.LabelsAPI.UsersLabelsList("me")
+	} else {
+		client, err := s.getGmailClient(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		call = client.Users.Labels.List("me")
+	}
+	resp, err := doCall("labels.list", call.Do)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	labels := make([]*models.Label, 0, len(resp.Labels))
+	for _, l := range resp.Labels {
+		if l != nil {
+			labels = append(labels, toModelLabel(l))
+		}
+	}
+	return labels, nil
+}
+
+// UpdateLabel renames and/or recolors a user label. Gmail only accepts colors
+// from its fixed palette and requires both colors to be set together.
+func (s *GmailService) UpdateLabel(ctx context.Context, token *oauth2.Token, labelID string, update models.LabelUpdate) (*models.Label, error) {
+	patch := &gmail.Label{}
+	if update.Name != nil {
+		patch.Name = *update.Name
+	}
+	if update.BackgroundColor != nil || update.TextColor != nil {
+		patch.Color = &gmail.LabelColor{}
+		if update.BackgroundColor != nil {
+			patch.Color.BackgroundColor = *update.BackgroundColor
+		}
+		if update.TextColor != nil {
+			patch.Color.TextColor = *update.TextColor
+		}
+	}
+	var call UsersLabelsPatchCall
+	if s.LabelsAPI != nil {
+		call = s.LabelsAPI.UsersLabelsPatch("me", labelID, patch)
+	} else {
+		client, err := s.getGmailClient(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		call = client.Users.Labels.Patch("me", labelID, patch)
+	}
+	l, err := doCall("labels.patch", call.Do)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	return toModelLabel(l), nil
+}
+
+// CreateLabel creates a user label visible in both the label list and message list
+func (s *GmailService) CreateLabel(ctx context.Context, token *oauth2.Token, create models.LabelCreate) (*models.Label, error) {
+	label := &gmail.Label{
+		Name:                  create.Name,
+		LabelListVisibility:   "labelShow",
+		MessageListVisibility: "show",
+	}
+	if create.BackgroundColor != "" || create.TextColor != "" {
+		label.Color = &gmail.LabelColor{BackgroundColor: create.BackgroundColor, TextColor: create.TextColor}
+	}
+	var call UsersLabelsCreateCall
+	if s.LabelsAPI != nil {
+		call = s.LabelsAPI.UsersLabelsCreate("me", label)
+	} else {
+		client, err := s.getGmailClient(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		call = client.Users.Labels.Create("me", label)
+	}
+	l, err := doCall("labels.create", call.Do)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	return toModelLabel(l), nil
+}
+
+// ApplyLabel adds a label to a message
+func (s *GmailService) ApplyLabel(ctx context.Context, token *oauth2.Token, messageID, labelID string) error {
+	return s.modifyMessage(ctx, token, messageID, &gmail.ModifyMessageRequest{AddLabelIds: []string{labelID}})
+}
+
+// RemoveLabel takes a label off a message
+func (s *GmailService) RemoveLabel(ctx context.Context, token *oauth2.Token, messageID, labelID string) error {
+	return s.modifyMessage(ctx, token, messageID, &gmail.ModifyMessageRequest{RemoveLabelIds: []string{labelID}})
+}
+
+// Archive removes a message from the inbox
+func (s *GmailService) Archive(ctx context.Context, token *oauth2.Token, messageID string) error {
+	return s.modifyMessage(ctx, token, messageID, &gmail.ModifyMessageRequest{RemoveLabelIds: []string{"INBOX"}})
+}
+
+// MarkRead marks a message as read
+func (s *GmailService) MarkRead(ctx context.Context, token *oauth2.Token, messageID string) error {
+	return s.modifyMessage(ctx, token, messageID, &gmail.ModifyMessageRequest{RemoveLabelIds: []string{"UNREAD"}})
+}
+
+// MarkUnread marks a message as unread
+func (s *GmailService) MarkUnread(ctx context.Context, token *oauth2.Token, messageID string) error {
+	return s.modifyMessage(ctx, token, messageID, &gmail.ModifyMessageRequest{AddLabelIds: []string{"UNREAD"}})
+}
+
+// SetStarred stars or unstars a message
+func (s *GmailService) SetStarred(ctx context.Context, token *oauth2.Token, messageID string, starred bool) error {
+	req := &gmail.ModifyMessageRequest{RemoveLabelIds: []string{"STARRED"}}
+	if starred {
+		req = &gmail.ModifyMessageRequest{AddLabelIds: []string{"STARRED"}}
+	}
+	return s.modifyMessage(ctx, token, messageID, req)
+}
+
+// Trash moves a message to the trash; Gmail deletes it for good after 30 days
+func (s *GmailService) Trash(ctx context.Context, token *oauth2.Token, messageID string) error {
+	var call UsersMessagesTrashCall
+	if s.LabelsAPI != nil {
+		call = s.LabelsAPI.UsersMessagesTrash("me", messageID)
+	} else {
+		client, err := s.getGmailClient(ctx, token)
+		if err != nil {
+			return err
+		}
+		call = client.Users.Messages.Trash("me", messageID)
+	}
+	if _, err := doCall("messages.trash", call.Do); err != nil {
+		return classifyError(err)
+	}
+	return nil
+}
+
+func (s *GmailService) modifyMessage(ctx context.Context, token *oauth2.Token, messageID string, req *gmail.ModifyMessageRequest) error {
+	var call UsersMessagesModifyCall
+	if s.LabelsAPI != nil {
+		call = s.LabelsAPI.UsersMessagesModify("me", messageID, req)
+	} else {
+		client, err := s.getGmailClient(ctx, token)
+		if err != nil {
+			return err
+		}
+		call = client.Users.Messages.Modify("me", messageID, req)
+	}
+	if _, err := doCall("messages.modify", call.Do); err != nil {
+		return classifyError(err)
+	}
+	return nil
+}
+
+// gmailCategoryLabels maps Gmail's category tab labels onto Whisperer category names
+var gmailCategoryLabels = map[string]string{
+	"CATEGORY_PERSONAL":   "Primary",
+	"CATEGORY_SOCIAL":     "Social",
+	"CATEGORY_PROMOTIONS": "Promotions/Ads",
+	"CATEGORY_UPDATES":    "Updates",
+	"CATEGORY_FORUMS":     "Forums",
+}
+
+// applyLabelSignals copies Gmail's own category tab, IMPORTANT marker and read state onto the
+// message
+func applyLabelSignals(msg *models.EmailMessage, labelIDs []string) {
+	msg.IsRead = true
+	for _, id := range labelIDs {
+		if id == "UNREAD" {
+			msg.IsRead = false
+		} else if id == "IMPORTANT" {
+			msg.ProviderImportant = true
+		} else if category, ok := gmailCategoryLabels[id]; ok {
+			msg.ProviderCategory = category
+		}
+	}
+}
+
+// toModelLabel converts a Gmail label into the cached label model
+func toModelLabel(l *gmail.Label) *models.Label {
+	label := &models.Label{
+		ProviderLabelID:       l.Id,
+		Name:                  l.Name,
+		Type:                  models.LabelTypeUser,
+		MessageListVisibility: l.MessageListVisibility,
+		LabelListVisibility:   l.LabelListVisibility,
+		CachedAt:              time.Now(),
+	}
+	if strings.EqualFold(l.Type, "system") {
+		label.Type = models.LabelTypeSystem
+	}
+	if l.Color != nil {
+		label.BackgroundColor = l.Color.BackgroundColor
+		label.TextColor = l.Color.TextColor
+	}
+	return label
+}
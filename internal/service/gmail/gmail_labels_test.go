@@ -0,0 +1,253 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+type mockLabelsAPI struct {
+	labels   []*gmail.Label
+	patched  *gmail.Label
+	created  *gmail.Label
+	modified map[string][]string // msgID -> added label IDs
+	trashed  []string
+	err      error
+}
+
+type mockLabelsListCall struct{ m *mockLabelsAPI }
+
+func (c *mockLabelsListCall) Do(...googleapi.CallOption) (*gmail.ListLabelsResponse, error) {
+	if c.m.err != nil {
+		return nil, c.m.err
+	}
+	return &gmail.ListLabelsResponse{Labels: c.m.labels}, nil
+}
+
+type mockLabelsPatchCall struct {
+	m     *mockLabelsAPI
+	id    string
+	label *gmail.Label
+}
+
+func (c *mockLabelsPatchCall) Do(...googleapi.CallOption) (*gmail.Label, error) {
+	if c.m.err != nil {
+		return nil, c.m.err
+	}
+	c.m.patched = c.label
+	return &gmail.Label{Id: c.id, Name: c.label.Name, Type: "user", Color: c.label.Color}, nil
+}
+
+type mockLabelsCreateCall struct {
+	m     *mockLabelsAPI
+	label *gmail.Label
+}
+
+func (c *mockLabelsCreateCall) Do(...googleapi.CallOption) (*gmail.Label, error) {
+	if c.m.err != nil {
+		return nil, c.m.err
+	}
+	c.m.created = c.label
+	return &gmail.Label{Id: "Label_new", Name: c.label.Name, Type: "user", Color: c.label.Color}, nil
+}
+
+type mockMessagesModifyCall struct {
+	m     *mockLabelsAPI
+	msgID string
+	req   *gmail.ModifyMessageRequest
+}
+
+func (c *mockMessagesModifyCall) Do(...googleapi.CallOption) (*gmail.Message, error) {
+	if c.m.err != nil {
+		return nil, c.m.err
+	}
+	if c.m.modified == nil {
+		c.m.modified = map[string][]string{}
+	}
+	c.m.modified[c.msgID] = append(c.m.modified[c.msgID], c.req.AddLabelIds...)
+	for _, id := range c.req.RemoveLabelIds {
+		c.m.modified[c.msgID] = append(c.m.modified[c.msgID], "-"+id)
+	}
+	return &gmail.Message{Id: c.msgID}, nil
+}
+
+func (m *mockLabelsAPI) UsersLabelsCreate(userID string, label *gmail.Label) UsersLabelsCreateCall {
+	return &mockLabelsCreateCall{m: m, label: label}
+}
+
+func (m *mockLabelsAPI) UsersMessagesModify(userID, msgID string, req *gmail.ModifyMessageRequest) UsersMessagesModifyCall {
+	return &mockMessagesModifyCall{m: m, msgID: msgID, req: req}
+}
+
+type mockMessagesTrashCall struct {
+	m     *mockLabelsAPI
+	msgID string
+}
+
+func (c *mockMessagesTrashCall) Do(...googleapi.CallOption) (*gmail.Message, error) {
+	if c.m.err != nil {
+		return nil, c.m.err
+	}
+	c.m.trashed = append(c.m.trashed, c.msgID)
+	return &gmail.Message{Id: c.msgID, LabelIds: []string{"TRASH"}}, nil
+}
+
+func (m *mockLabelsAPI) UsersMessagesTrash(userID, msgID string) UsersMessagesTrashCall {
+	return &mockMessagesTrashCall{m: m, msgID: msgID}
+}
+
+func (m *mockLabelsAPI) UsersLabelsList(userID string) UsersLabelsListCall {
+	return &mockLabelsListCall{m: m}
+}
+
+func (m *mockLabelsAPI) UsersLabelsPatch(userID, labelID string, label *gmail.Label) UsersLabelsPatchCall {
+	return &mockLabelsPatchCall{m: m, id: labelID, label: label}
+}
+
+func TestGmailService_ListLabels(t *testing.T) {
+	api := &mockLabelsAPI{labels: []*gmail.Label{
+		{Id: "INBOX", Name: "INBOX", Type: "system"},
+		{Id: "Label_1", Name: "Finance", Type: "user", LabelListVisibility: "labelShow", MessageListVisibility: "show",
+			Color: &gmail.LabelColor{BackgroundColor: "#16a765", TextColor: "#ffffff"}},
+	}}
+	svc := &GmailService{LabelsAPI: api}
+	labels, err := svc.ListLabels(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(labels) != 2 {
+		t.Fatalf("expected 2 labels, got %d", len(labels))
+	}
+	if labels[0].Type != models.LabelTypeSystem {
+		t.Errorf("expected INBOX to be a system label, got %q", labels[0].Type)
+	}
+	if l := labels[1]; l.Type != models.LabelTypeUser || l.BackgroundColor != "#16a765" || l.TextColor != "#ffffff" || l.LabelListVisibility != "labelShow" {
+		t.Errorf("unexpected user label: %+v", l)
+	}
+
+	api.err = &googleapi.Error{Code: 401}
+	if _, err := svc.ListLabels(context.Background(), nil); !errors.Is(err, provider.ErrAuthExpired) {
+		t.Errorf("expected ErrAuthExpired, got %v", err)
+	}
+}
+
+func TestGmailService_UpdateLabel(t *testing.T) {
+	api := &mockLabelsAPI{}
+	svc := &GmailService{LabelsAPI: api}
+	name, bg, fg := "Finance/Receipts", "#16a765", "#ffffff"
+	l, err := svc.UpdateLabel(context.Background(), nil, "Label_1", models.LabelUpdate{Name: &name, BackgroundColor: &bg, TextColor: &fg})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if api.patched.Name != name || api.patched.Color == nil || api.patched.Color.BackgroundColor != bg {
+		t.Errorf("unexpected patch: %+v", api.patched)
+	}
+	if l.ProviderLabelID != "Label_1" || l.Name != name || l.TextColor != fg {
+		t.Errorf("unexpected label: %+v", l)
+	}
+
+	// Name-only updates leave colors untouched
+	if _, err := svc.UpdateLabel(context.Background(), nil, "Label_1", models.LabelUpdate{Name: &name}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if api.patched.Color != nil {
+		t.Errorf("expected no color in patch, got %+v", api.patched.Color)
+	}
+
+	// Colors outside Gmail's palette are rejected by the API
+	api.err = &googleapi.Error{Code: 400}
+	if _, err := svc.UpdateLabel(context.Background(), nil, "Label_1", models.LabelUpdate{BackgroundColor: &bg}); !errors.Is(err, provider.ErrInvalidRequest) {
+		t.Errorf("expected ErrInvalidRequest, got %v", err)
+	}
+}
+
+func TestGmailService_CreateAndApplyLabel(t *testing.T) {
+	api := &mockLabelsAPI{}
+	svc := &GmailService{LabelsAPI: api}
+	l, err := svc.CreateLabel(context.Background(), nil, models.LabelCreate{Name: "Finance/Receipts"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if l.ProviderLabelID != "Label_new" || l.Type != models.LabelTypeUser {
+		t.Errorf("unexpected label: %+v", l)
+	}
+	if api.created.Color != nil || api.created.LabelListVisibility != "labelShow" {
+		t.Errorf("unexpected create request: %+v", api.created)
+	}
+
+	if err := svc.ApplyLabel(context.Background(), nil, "msg1", "Label_new"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := api.modified["msg1"]; len(got) != 1 || got[0] != "Label_new" {
+		t.Errorf("expected Label_new added to msg1, got %v", got)
+	}
+
+	api.err = &googleapi.Error{Code: 404}
+	if err := svc.ApplyLabel(context.Background(), nil, "gone", "Label_new"); !errors.Is(err, provider.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGmailService_ArchiveAndMarkRead(t *testing.T) {
+	api := &mockLabelsAPI{}
+	svc := &GmailService{LabelsAPI: api}
+	if err := svc.Archive(context.Background(), nil, "msg1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.MarkRead(context.Background(), nil, "msg1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := api.modified["msg1"]; len(got) != 2 || got[0] != "-INBOX" || got[1] != "-UNREAD" {
+		t.Errorf("expected INBOX and UNREAD removed from msg1, got %v", got)
+	}
+}
+
+func TestGmailService_SetStarred(t *testing.T) {
+	api := &mockLabelsAPI{}
+	svc := &GmailService{LabelsAPI: api}
+	if err := svc.SetStarred(context.Background(), nil, "msg1", true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.SetStarred(context.Background(), nil, "msg1", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := api.modified["msg1"]; len(got) != 2 || got[0] != "STARRED" || got[1] != "-STARRED" {
+		t.Errorf("expected STARRED added then removed, got %v", got)
+	}
+}
+
+func TestGmailService_Trash(t *testing.T) {
+	api := &mockLabelsAPI{}
+	svc := &GmailService{LabelsAPI: api}
+	if err := svc.Trash(context.Background(), nil, "msg1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(api.trashed) != 1 || api.trashed[0] != "msg1" {
+		t.Errorf("expected msg1 trashed, got %v", api.trashed)
+	}
+	api.err = &googleapi.Error{Code: 404}
+	if err := svc.Trash(context.Background(), nil, "gone"); !errors.Is(err, provider.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestApplyLabelSignals(t *testing.T) {
+	msg := &models.EmailMessage{}
+	applyLabelSignals(msg, []string{"INBOX", "IMPORTANT", "CATEGORY_PROMOTIONS", "Label_1"})
+	if msg.ProviderCategory != "Promotions/Ads" || !msg.ProviderImportant {
+		t.Errorf("expected Promotions/Ads and important, got %q and %v", msg.ProviderCategory, msg.ProviderImportant)
+	}
+	if !msg.IsRead {
+		t.Error("expected a message without UNREAD to be read")
+	}
+	plain := &models.EmailMessage{}
+	applyLabelSignals(plain, []string{"INBOX", "UNREAD"})
+	if plain.ProviderCategory != "" || plain.ProviderImportant || plain.IsRead {
+		t.Errorf("expected no provider signals and unread, got %+v", plain)
+	}
+}
@@ -4,17 +4,12 @@ import (
 	"context"
 	"errors"
 
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
 	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
 	"golang.org/x/oauth2"
 )
 
-type ctxKey string
-
-const (
-	ctxKeyUserID ctxKey = "user_id"
-	ctxKeyLimit  ctxKey = "limit"
-)
-
 type FetchParams struct {
 	AfterID           string
 	AfterInternalDate int64
@@ -24,6 +19,7 @@ type FetchParams struct {
 type EmailProvider interface {
 	FetchSummaries(ctx context.Context, userID string, params FetchParams) ([]models.EmailSummary, error)
 	FetchMessage(ctx context.Context, userToken interface{}, messageID string) (*models.EmailMessage, error)
+	Capabilities() provider.Capabilities
 }
 
 type GmailProvider struct {
@@ -35,28 +31,39 @@ func NewGmailProvider(svc *GmailService) *GmailProvider {
 }
 
 func (g *GmailProvider) FetchSummaries(ctx context.Context, userID string, params FetchParams) ([]models.EmailSummary, error) {
-	ctx = context.WithValue(ctx, ctxKeyUserID, userID)
-	ctx = context.WithValue(ctx, ctxKeyLimit, params.Limit)
+	ctx = ctxkeys.WithUserID(ctx, userID)
+	ctx = ctxkeys.WithLimit(ctx, params.Limit)
+	ctx = ctxkeys.WithCursor(ctx, ctxkeys.Cursor{AfterID: params.AfterID, AfterInternalDate: params.AfterInternalDate})
 	msgs, err := g.Service.FetchMessages(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	summaries := make([]models.EmailSummary, 0, len(msgs))
-	for _, m := range msgs {
-		summaries = append(summaries, models.EmailSummary{
-			ID:           m.EmailMessageID,
-			ThreadID:     m.ThreadID,
-			Snippet:      m.Snippet,
-			Sender:       m.Sender,
-			Subject:      m.Subject,
-			InternalDate: m.InternalDate,
-			Date:         m.Date,
-			Provider:     "gmail",
-		})
+	for i := range msgs {
+		summaries = append(summaries, toSummary(&msgs[i]))
 	}
 	return summaries, nil
 }
 
+// ListPassthrough lists a page of summaries straight from Gmail, bypassing the local cache
+func (g *GmailProvider) ListPassthrough(ctx context.Context, token *oauth2.Token, pageToken string, limit int) (*models.SummaryPage, error) {
+	msgs, next, err := g.Service.ListPassthrough(ctx, token, pageToken, limit)
+	if err != nil {
+		return nil, err
+	}
+	page := &models.SummaryPage{Summaries: make([]models.EmailSummary, 0, len(msgs)), NextPageToken: next}
+	for _, m := range msgs {
+		page.Summaries = append(page.Summaries, toSummary(m))
+	}
+	return page, nil
+}
+
+func toSummary(m *models.EmailMessage) models.EmailSummary {
+	s := m.Summary()
+	s.Provider = "gmail"
+	return s
+}
+
 func (g *GmailProvider) FetchMessage(ctx context.Context, userToken interface{}, messageID string) (*models.EmailMessage, error) {
 	token, ok := userToken.(*oauth2.Token)
 	if !ok {
@@ -68,3 +75,49 @@ func (g *GmailProvider) FetchMessage(ctx context.Context, userToken interface{},
 	}
 	return msg, nil
 }
+
+// Capabilities reports the optional features supported by Gmail
+func (g *GmailProvider) Capabilities() provider.Capabilities {
+	// Gmail's mute is not exposed by the API, so muted threads are handled by the rules engine
+	return provider.Capabilities{Labels: true, LabelColors: true, EditLabels: true, ApplyLabels: true, Passthrough: true, Trash: true, Star: true}
+}
+
+func (g *GmailProvider) ListLabels(ctx context.Context, token *oauth2.Token) ([]*models.Label, error) {
+	return g.Service.ListLabels(ctx, token)
+}
+
+func (g *GmailProvider) UpdateLabel(ctx context.Context, token *oauth2.Token, labelID string, update models.LabelUpdate) (*models.Label, error) {
+	return g.Service.UpdateLabel(ctx, token, labelID, update)
+}
+
+func (g *GmailProvider) CreateLabel(ctx context.Context, token *oauth2.Token, create models.LabelCreate) (*models.Label, error) {
+	return g.Service.CreateLabel(ctx, token, create)
+}
+
+func (g *GmailProvider) ApplyLabel(ctx context.Context, token *oauth2.Token, messageID, labelID string) error {
+	return g.Service.ApplyLabel(ctx, token, messageID, labelID)
+}
+
+func (g *GmailProvider) RemoveLabel(ctx context.Context, token *oauth2.Token, messageID, labelID string) error {
+	return g.Service.RemoveLabel(ctx, token, messageID, labelID)
+}
+
+func (g *GmailProvider) Archive(ctx context.Context, token *oauth2.Token, messageID string) error {
+	return g.Service.Archive(ctx, token, messageID)
+}
+
+func (g *GmailProvider) MarkRead(ctx context.Context, token *oauth2.Token, messageID string) error {
+	return g.Service.MarkRead(ctx, token, messageID)
+}
+
+func (g *GmailProvider) MarkUnread(ctx context.Context, token *oauth2.Token, messageID string) error {
+	return g.Service.MarkUnread(ctx, token, messageID)
+}
+
+func (g *GmailProvider) SetStarred(ctx context.Context, token *oauth2.Token, messageID string, starred bool) error {
+	return g.Service.SetStarred(ctx, token, messageID, starred)
+}
+
+func (g *GmailProvider) Trash(ctx context.Context, token *oauth2.Token, messageID string) error {
+	return g.Service.Trash(ctx, token, messageID)
+}
@@ -6,8 +6,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
 	"github.com/desponda/inbox-whisperer/internal/models"
-	"github.com/desponda/inbox-whisperer/internal/session"
 	"golang.org/x/oauth2"
 	gmailapi "google.golang.org/api/gmail/v1"
 )
@@ -34,13 +34,26 @@ func (f *fakeRepo) GetMessagesForUserCursor(ctx context.Context, userID string,
 }
 
 func (f *fakeRepo) DeleteMessagesForUser(ctx context.Context, userID string) error { return nil }
+func (f *fakeRepo) ClearMessageContent(ctx context.Context, userID string) error   { return nil }
+func (f *fakeRepo) SetCategory(ctx context.Context, userID, emailMessageID, category string, confidence float64) error {
+	return nil
+}
+func (f *fakeRepo) SetRead(ctx context.Context, userID, emailMessageID string, read bool) error {
+	return nil
+}
+func (f *fakeRepo) SetArchived(ctx context.Context, userID, emailMessageID string) error {
+	return nil
+}
+func (f *fakeRepo) DeleteMessage(ctx context.Context, userID, emailMessageID string) error {
+	return nil
+}
 
 func TestGmailProvider_FetchSummaries(t *testing.T) {
 	repo := &fakeRepo{}
 	svc := NewGmailService(repo, &mockGmailAPI{})
 	provider := NewGmailProvider(svc)
 	ctx := context.Background()
-	ctx = session.ContextWithUserID(ctx, "user1")
+	ctx = ctxkeys.WithUserID(ctx, "user1")
 	params := FetchParams{Limit: 10}
 
 	summaries, err := provider.FetchSummaries(ctx, "user1", params)
@@ -81,6 +94,19 @@ func (f *fakeRepoWithError) GetMessagesForUserCursor(ctx context.Context, userID
 func (f *fakeRepoWithError) DeleteMessagesForUser(ctx context.Context, userID string) error {
 	return nil
 }
+func (f *fakeRepoWithError) ClearMessageContent(ctx context.Context, userID string) error { return nil }
+func (f *fakeRepoWithError) SetCategory(ctx context.Context, userID, emailMessageID, category string, confidence float64) error {
+	return nil
+}
+func (f *fakeRepoWithError) SetRead(ctx context.Context, userID, emailMessageID string, read bool) error {
+	return nil
+}
+func (f *fakeRepoWithError) SetArchived(ctx context.Context, userID, emailMessageID string) error {
+	return nil
+}
+func (f *fakeRepoWithError) DeleteMessage(ctx context.Context, userID, emailMessageID string) error {
+	return nil
+}
 func (f *fakeRepoWithError) SaveUserToken(ctx context.Context, userID, provider string, token interface{}) error {
 	return nil
 }
@@ -108,6 +134,19 @@ func (f *fakeRepoForFetch) GetMessagesForUserCursor(ctx context.Context, userID
 func (f *fakeRepoForFetch) DeleteMessagesForUser(ctx context.Context, userID string) error {
 	return nil
 }
+func (f *fakeRepoForFetch) ClearMessageContent(ctx context.Context, userID string) error { return nil }
+func (f *fakeRepoForFetch) SetCategory(ctx context.Context, userID, emailMessageID, category string, confidence float64) error {
+	return nil
+}
+func (f *fakeRepoForFetch) SetRead(ctx context.Context, userID, emailMessageID string, read bool) error {
+	return nil
+}
+func (f *fakeRepoForFetch) SetArchived(ctx context.Context, userID, emailMessageID string) error {
+	return nil
+}
+func (f *fakeRepoForFetch) DeleteMessage(ctx context.Context, userID, emailMessageID string) error {
+	return nil
+}
 
 func (f *fakeRepoForFetch) SaveUserToken(ctx context.Context, userID, provider string, token interface{}) error {
 	return nil
@@ -142,7 +181,7 @@ func TestGmailProvider_FetchMessage(t *testing.T) {
 	svc := NewGmailService(repo, mockAPI)
 	provider := &GmailProvider{Service: svc}
 	ctx := context.Background()
-	ctx = session.ContextWithUserID(ctx, "user1")
+	ctx = ctxkeys.WithUserID(ctx, "user1")
 	tok := &oauth2.Token{AccessToken: "dummy"}
 
 	// Simulate cache miss (repo returns nil)
@@ -0,0 +1,23 @@
+package gmail
+
+import (
+	"context"
+	"encoding/base64"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/gmail/v1"
+)
+
+// SendRaw sends an RFC 2822 message from the token's mailbox. Gmail fills in the From header
+// when the message has none. It needs ScopeSend or a scope implying it.
+func (s *GmailService) SendRaw(ctx context.Context, token *oauth2.Token, raw []byte) error {
+	client, err := s.getGmailClient(ctx, token)
+	if err != nil {
+		return err
+	}
+	msg := &gmail.Message{Raw: base64.URLEncoding.EncodeToString(raw)}
+	if _, err := doCall("messages.send", client.Users.Messages.Send("me", msg).Do); err != nil {
+		return classifyError(err)
+	}
+	return nil
+}
@@ -2,18 +2,38 @@ package gmail
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/desponda/inbox-whisperer/internal/ai"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
 	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/e2ee"
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
 	"github.com/desponda/inbox-whisperer/internal/models"
 	"github.com/desponda/inbox-whisperer/internal/notify"
-	"github.com/desponda/inbox-whisperer/internal/session"
+	"github.com/desponda/inbox-whisperer/internal/plaintext"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/debuglog"
+	telemetryerrors "github.com/desponda/inbox-whisperer/internal/telemetry/errors"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/metrics"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/tracing"
 
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/oauth2"
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/api/googleapi"
@@ -23,12 +43,27 @@ import (
 // EnableBackgroundSync controls whether FetchMessages launches background sync goroutine.
 var EnableBackgroundSync = true
 
-var ErrNotFound = errors.New("not found")
+// MaxSyncAttempts caps how many times a failed message is retried before it is
+// left in the dead-letter table for the sync status endpoint to surface.
+var MaxSyncAttempts = 5
 
-// extractUserIDFromContext gets the user ID from context
-func extractUserIDFromContext(ctx context.Context) string {
-	return session.GetUserID(ctx)
-}
+// ColdCacheRetryAfter is how long clients are asked to wait before listing again while a
+// user's first sync is filling an empty cache
+var ColdCacheRetryAfter = 5 * time.Second
+
+// SyncCooldown is how soon after a user's sync finishes a page load may start another; loads
+// within it are served from the cache. Scheduled and push syncs (SyncUser) are not held back.
+var SyncCooldown = 30 * time.Second
+
+// SyncPagesPerRun caps how many pages of the message list one sync run walks. The default of 1
+// turns multi-page walks off: a run caches the newest page and the history ID, later runs only
+// fetch changes, and older mail is cached on request by mailbox backfills (see
+// backfill.Mailbox). When raised, the next page's token is stored as the cursor after each page
+// but the last of the run, so an interrupted walk resumes where it stopped.
+var SyncPagesPerRun = 1
+
+// retryBatchSize bounds how many failed messages are retried per sync run.
+const retryBatchSize = 50
 
 // GmailService fetches and caches Gmail messages for a user (DB-backed, 1-min TTL)
 //go:generate mockgen -destination=internal/service/mocks/mock_gmail_api.go -package=mocks . GmailAPI
@@ -44,15 +79,75 @@ type UsersMessagesListCall interface {
 	Do(...googleapi.CallOption) (*gmail.ListMessagesResponse, error)
 }
 
+type UsersHistoryListCall interface {
+	Do(...googleapi.CallOption) (*gmail.ListHistoryResponse, error)
+}
+
 // GmailAPI defines the subset of the Google Gmail API used by GmailService.
 type GmailAPI interface {
 	UsersMessagesGet(userID, msgID string) UsersMessagesGetCall
-	UsersMessagesList(userID string) UsersMessagesListCall
+	// UsersMessagesList lists a page of messages; an empty pageToken lists the newest
+	UsersMessagesList(userID, pageToken string) UsersMessagesListCall
+	// UsersHistoryList lists a page of mailbox changes after startHistoryID
+	UsersHistoryList(userID string, startHistoryID uint64, pageToken string) UsersHistoryListCall
 }
 
 type GmailService struct {
 	Repo     data.EmailMessageRepository
 	GmailAPI GmailAPI
+	// FailedItems records messages that failed to sync; optional (failures are only logged when nil)
+	FailedItems data.FailedSyncItemRepository
+	// LabelsAPI overrides the real Gmail client for label calls (tests)
+	LabelsAPI GmailLabelsAPI
+	// Rules runs user rules against newly synced messages; optional
+	Rules RuleApplier
+	// Categorizer assigns a category to newly synced messages; optional
+	Categorizer Categorizer
+	// Maintenance pauses syncs (and the rule actions they trigger) while it is on; optional
+	Maintenance *maintenance.Switch
+	// Errors receives background sync failures; optional
+	Errors telemetryerrors.Reporter
+	// Breaker pauses a user's syncs after rate limiting or expired authorization; optional
+	Breaker *provider.Breaker
+	// SyncState stores the sync cursor; optional (every run starts from the newest messages when nil)
+	SyncState data.SyncStateRepository
+	// SyncRuns stores a summary of each sync run; optional (runs are only logged when nil)
+	SyncRuns data.SyncRunRepository
+	// Tx, if set, writes each synced page's messages and cursor in a single transaction
+	Tx data.TxRunner
+	// Settings is read for the local cache opt-out; optional (caching is always on when nil)
+	Settings data.UserSettingsRepository
+	// EncryptionKeys holds the keys cached bodies are sealed to (see package e2ee); optional
+	// (bodies are cached in the clear when nil)
+	EncryptionKeys data.EncryptionKeyRepository
+	// DropRawJSON stops caching raw Gmail payloads; headers are still cached on their own
+	DropRawJSON bool
+	// HTTPClient carries Gmail API calls; optional (http.DefaultClient when nil)
+	HTTPClient *http.Client
+	// Refresher refreshes expired access tokens and stores the refreshed ones (see
+	// oauthclient.Persisting); optional (tokens are used as they are when nil)
+	Refresher TokenRefresher
+
+	// inFlight maps the user IDs with a sync running to a channel closed when it finishes, so
+	// at most one sync per user runs at a time
+	inFlight sync.Map
+	// lastSynced maps user IDs to when their last sync finished, for SyncCooldown
+	lastSynced sync.Map
+}
+
+// Categorizer assigns a category to a message (see ai.Gateway)
+type Categorizer interface {
+	Categorize(ctx context.Context, userID string, msg *models.EmailMessage) (*ai.Categorization, error)
+}
+
+// RuleApplier runs user-defined rules (e.g. auto-labeling) against a synced message
+type RuleApplier interface {
+	ApplyRules(ctx context.Context, userID string, token *oauth2.Token, msg *models.EmailMessage) error
+}
+
+// TokenRefresher builds token sources that refresh a user's token when it expires
+type TokenRefresher interface {
+	TokenSource(ctx context.Context, tok *oauth2.Token) oauth2.TokenSource
 }
 
 // NewGmailService constructs a GmailService with explicit dependency injection.
@@ -61,9 +156,21 @@ func NewGmailService(repo data.EmailMessageRepository, api GmailAPI) *GmailServi
 }
 
 // getGmailClient creates a Gmail API client from an OAuth2 token
-func getGmailClient(ctx context.Context, token *oauth2.Token) (*gmail.Service, error) {
+func (s *GmailService) getGmailClient(ctx context.Context, token *oauth2.Token) (*gmail.Service, error) {
+	if s.HTTPClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, s.HTTPClient)
+	}
 	ts := oauth2.StaticTokenSource(token)
-	return gmail.NewService(ctx, option.WithTokenSource(ts))
+	if s.Refresher != nil {
+		ts = s.Refresher.TokenSource(ctx, token)
+	}
+	if s.HTTPClient == nil {
+		return gmail.NewService(ctx, option.WithTokenSource(ts))
+	}
+	// oauth2 builds on the client in ctx but does not carry over its timeout
+	client := oauth2.NewClient(ctx, ts)
+	client.Timeout = s.HTTPClient.Timeout
+	return gmail.NewService(ctx, option.WithHTTPClient(client))
 }
 
 // MessageSummary is a minimal summary of a Gmail message
@@ -81,11 +188,29 @@ type MessageSummary struct {
 // MessageContent is the full content of a Gmail message
 
 // FetchMessageContent fetches the full content of a Gmail message by ID, using cache if fresh.
+// For users with metadata-only caching the body always comes from Gmail and only the
+// metadata is written back.
 func (s *GmailService) FetchMessageContent(ctx context.Context, token *oauth2.Token, id string) (*models.EmailMessage, error) {
-	userID := extractUserIDFromContext(ctx)
+	userID := ctxkeys.UserID(ctx)
+	cacheDisabled, metadataOnly := s.cachePolicy(ctx, userID)
+	publicKey, err := s.cacheKey(ctx, userID)
+	if err != nil {
+		log.Printf("failed to read cache key for user %s, not caching: %v", userID, err)
+		cacheDisabled = true
+	}
 	cached, err := s.Repo.GetMessageByID(ctx, userID, id)
-	if err == nil && cached != nil && time.Since(cached.CachedAt) < time.Minute {
-		return cached, nil
+	if models.IsDigestMessage(id) {
+		// Digests are written by the server and exist only in the cache
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && cached == nil) {
+			return nil, provider.ErrNotFound
+		}
+		return cached, err
+	}
+	if err == nil && cached != nil && time.Since(cached.CachedAt) < time.Minute && !metadataOnly {
+		// Sealed bodies are served from the cache only while the session holds the key
+		if opened, ok := openContent(ctx, cached); ok {
+			return opened, nil
+		}
 	}
 	msg, err := s.fetchGmailMessage(ctx, token, id)
 	if err != nil {
@@ -99,23 +224,192 @@ func (s *GmailService) FetchMessageContent(ctx context.Context, token *oauth2.To
 		Sender:         getHeader(msg.Payload.Headers, "From"),
 		Recipient:      getHeader(msg.Payload.Headers, "To"),
 		Snippet:        msg.Snippet,
-		Body:           extractPlainTextBody(msg.Payload),
+		Body:           plaintext.Body(extractPlainTextBody(msg.Payload), extractHTMLBody(msg.Payload)),
 		HTMLBody:       extractHTMLBody(msg.Payload),
 		InternalDate:   msg.InternalDate,
+		SizeEstimate:   msg.SizeEstimate,
+		HasAttachments: hasAttachments(msg.Payload),
 		Date:           getHeader(msg.Payload.Headers, "Date"),
 		HistoryID:      int64(msg.HistoryId),
 		CachedAt:       time.Now(),
 		RawJSON:        mustMarshalRawJSON(msg),
+		Headers:        messageHeaders(msg.Payload),
 	}
-	// Update cache asynchronously (log error if any)
+	dbMsg.ParseSender()
+	dbMsg.ParseRecipients()
+	applyLabelSignals(dbMsg, msg.LabelIds)
+	dbMsg.ContentHash = contentHash(msg)
+	dbMsg.LabelHash = labelHash(msg)
+	updated := markIfUpdated(cached, dbMsg)
+	if cacheDisabled {
+		return dbMsg, nil
+	}
+	toCache := dbMsg
+	if metadataOnly {
+		toCache = withoutContent(dbMsg)
+	} else if publicKey != nil {
+		if toCache, err = sealContent(dbMsg, publicKey); err != nil {
+			log.Printf("failed to seal message %s, not caching: %v", id, err)
+			return dbMsg, nil
+		}
+	} else if s.DropRawJSON {
+		toCache = withoutRawJSON(dbMsg)
+	}
+	// Update cache asynchronously (log error if any). The write outlives the request, so it
+	// must not be canceled with it.
+	ctx = context.WithoutCancel(ctx)
 	go func() {
-		if err := s.Repo.UpsertMessage(ctx, dbMsg); err != nil {
+		err := data.RetryBackgroundWrite(ctx, "message_cache_upsert", func(ctx context.Context) error {
+			return s.Repo.UpsertMessage(ctx, toCache)
+		})
+		if err != nil {
 			log.Printf("failed to upsert message: %v", err)
+			return
+		}
+		if updated {
+			notify.Publish(notify.WithMessageID(ctx, dbMsg.EmailMessageID), notify.EventMessageUpdated, userID)
 		}
 	}()
 	return dbMsg, nil
 }
 
+// withoutContent returns a copy of msg without its bodies and raw payload, for metadata-only caching
+func withoutContent(msg *models.EmailMessage) *models.EmailMessage {
+	stripped := *msg
+	stripped.Body = ""
+	stripped.HTMLBody = ""
+	stripped.RawJSON = nil
+	return &stripped
+}
+
+// sealContent returns a copy of msg for an encrypted cache: bodies sealed to publicKey, and the
+// raw payload and snippet, which quote the body, dropped
+func sealContent(msg *models.EmailMessage, publicKey []byte) (*models.EmailMessage, error) {
+	sealed := *msg
+	var err error
+	if sealed.Body, err = e2ee.Seal(publicKey, msg.Body); err != nil {
+		return nil, err
+	}
+	if sealed.HTMLBody, err = e2ee.Seal(publicKey, msg.HTMLBody); err != nil {
+		return nil, err
+	}
+	sealed.RawJSON = nil
+	sealed.Snippet = ""
+	return &sealed, nil
+}
+
+// openContent returns msg with sealed bodies opened with the session's key. It reports false
+// when they cannot be opened, and the caller should fetch from the provider instead.
+func openContent(ctx context.Context, msg *models.EmailMessage) (*models.EmailMessage, bool) {
+	if !e2ee.IsSealed(msg.Body) && !e2ee.IsSealed(msg.HTMLBody) {
+		return msg, true
+	}
+	key := ctxkeys.EncryptionKey(ctx)
+	if key == nil {
+		return nil, false
+	}
+	opened := *msg
+	var err error
+	if opened.Body, err = e2ee.Open(key, msg.Body); err != nil {
+		log.Printf("failed to open cached message %s: %v", msg.EmailMessageID, err)
+		return nil, false
+	}
+	if opened.HTMLBody, err = e2ee.Open(key, msg.HTMLBody); err != nil {
+		log.Printf("failed to open cached message %s: %v", msg.EmailMessageID, err)
+		return nil, false
+	}
+	return &opened, true
+}
+
+// withoutRawJSON returns a copy of msg without its raw payload
+func withoutRawJSON(msg *models.EmailMessage) *models.EmailMessage {
+	stripped := *msg
+	stripped.RawJSON = nil
+	return &stripped
+}
+
+// messageHeaders copies a Gmail payload's headers
+func messageHeaders(payload *gmail.MessagePart) []models.MessageHeader {
+	if payload == nil {
+		return nil
+	}
+	headers := make([]models.MessageHeader, 0, len(payload.Headers))
+	for _, h := range payload.Headers {
+		if h != nil {
+			headers = append(headers, models.MessageHeader{Name: h.Name, Value: h.Value})
+		}
+	}
+	return headers
+}
+
+// hasAttachments reports whether any part of the message is a file, inline or attached
+func hasAttachments(part *gmail.MessagePart) bool {
+	if part == nil {
+		return false
+	}
+	if part.Filename != "" {
+		return true
+	}
+	for _, p := range part.Parts {
+		if hasAttachments(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// contentHash fingerprints the content of a Gmail message a re-sync can change: headers, snippet
+// and bodies. Draft edits produce a new hash; label changes do not, see labelHash.
+func contentHash(msg *gmail.Message) string {
+	h := sha256.New()
+	var headers []*gmail.MessagePartHeader
+	if msg.Payload != nil {
+		headers = msg.Payload.Headers
+	}
+	for _, part := range []string{
+		getHeader(headers, "Subject"),
+		getHeader(headers, "From"),
+		getHeader(headers, "To"),
+		msg.Snippet,
+		extractPlainTextBody(msg.Payload),
+		extractHTMLBody(msg.Payload),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// labelHash fingerprints a Gmail message's labels, so reading, starring or archiving it is
+// detected as an update without looking like new content
+func labelHash(msg *gmail.Message) string {
+	labels := append([]string(nil), msg.LabelIds...)
+	sort.Strings(labels)
+	sum := sha256.Sum256([]byte(strings.Join(labels, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// contentChanged reports whether msg's content differs from the cached copy's. Rows cached
+// before hashing have nothing to compare against.
+func contentChanged(cached, msg *models.EmailMessage) bool {
+	return cached != nil && cached.ContentHash != "" && cached.ContentHash != msg.ContentHash
+}
+
+// markIfUpdated sets msg.ChangedAt when its content or label hash differs from the cached
+// copy's and reports whether it did. New messages and rows cached before hashing are not updates.
+func markIfUpdated(cached, msg *models.EmailMessage) bool {
+	if cached == nil {
+		return false
+	}
+	msg.ChangedAt = cached.ChangedAt
+	labelsChanged := cached.LabelHash != "" && cached.LabelHash != msg.LabelHash
+	if !contentChanged(cached, msg) && !labelsChanged {
+		return false
+	}
+	msg.ChangedAt = sql.NullTime{Time: msg.CachedAt, Valid: true}
+	return true
+}
+
 // mustMarshalRawJSON marshals a Gmail message to json.RawMessage (for RawJSON field), returns empty on error
 func mustMarshalRawJSON(msg *gmail.Message) json.RawMessage {
 	b, err := json.Marshal(msg)
@@ -125,58 +419,54 @@ func mustMarshalRawJSON(msg *gmail.Message) json.RawMessage {
 	return b
 }
 
+// doCall runs a Gmail API call, recording it in the provider call metrics under method
+func doCall[T any](method string, do func(...googleapi.CallOption) (T, error)) (T, error) {
+	start := time.Now()
+	v, err := do()
+	metrics.ObserveProviderCall("gmail", method, callStatus(err), time.Since(start))
+	return v, err
+}
+
 // fetchGmailMessage fetches a Gmail message using the Gmail API or injected mock
 func (s *GmailService) fetchGmailMessage(ctx context.Context, token *oauth2.Token, id string) (*gmail.Message, error) {
 	// Prefer injected GmailAPI if present
 	if s.GmailAPI == nil {
-		return fetchGmailMessageClient(ctx, token, id)
+		return s.fetchGmailMessageClient(ctx, token, id)
 	}
 	call := s.GmailAPI.UsersMessagesGet("me", id)
-	msg, err := call.Do()
+	msg, err := doCall("messages.get", call.Do)
 	if err != nil {
-		if isNotFoundError(err) {
-			return nil, ErrNotFound
-		}
-		return nil, err
+		return nil, classifyError(err)
 	}
 	return msg, nil
 }
 
-// isNotFoundError returns true if the error represents a Gmail 404 not found
-func isNotFoundError(err error) bool {
-	return err != nil && strings.Contains(err.Error(), "404")
-}
-
 // fetchGmailMessageClient fetches a Gmail message using the real Gmail client
-func fetchGmailMessageClient(ctx context.Context, token *oauth2.Token, id string) (*gmail.Message, error) {
-	client, err := getGmailClient(ctx, token)
+func (s *GmailService) fetchGmailMessageClient(ctx context.Context, token *oauth2.Token, id string) (*gmail.Message, error) {
+	client, err := s.getGmailClient(ctx, token)
 	if err != nil {
 		return nil, err
 	}
-	msg, err := client.Users.Messages.Get("me", id).Format("full").Do()
+	msg, err := doCall("messages.get", client.Users.Messages.Get("me", id).Format("full").Do)
 	if err != nil {
-		if isNotFoundError(err) {
-			return nil, ErrNotFound
-		}
-		return nil, err
+		return nil, classifyError(err)
 	}
 	return msg, nil
 }
 
-type CtxKeyAfterID struct{}
-type CtxKeyAfterInternalDate struct{}
-
 // FetchMessages returns only cached summaries (no full content/body) for a fast inbox load.
 // It triggers a background sync with Gmail to fetch new/updated summaries.
 // After sync, subsequent calls will see fresh data. Full content is fetched via FetchMessageContent.
 func (s *GmailService) FetchMessages(ctx context.Context, token *oauth2.Token) ([]models.EmailMessage, error) {
-	userID := extractUserIDFromContext(ctx)
-	afterID, _ := ctx.Value(CtxKeyAfterID{}).(string)
-	afterInternalDate, _ := ctx.Value(CtxKeyAfterInternalDate{}).(int64)
-	pageSize := 10 // could be param
+	userID := ctxkeys.UserID(ctx)
+	cursor := ctxkeys.CursorFrom(ctx)
+	pageSize := 10
+	if l := ctxkeys.Limit(ctx); l > 0 {
+		pageSize = l
+	}
 
 	// 1. Return cached summaries instantly
-	msgs, err := s.fetchUserMessages(ctx, userID, pageSize, afterInternalDate, afterID)
+	msgs, err := s.fetchUserMessages(ctx, userID, pageSize, cursor.AfterInternalDate, cursor.AfterID)
 	if err != nil {
 		return nil, err
 	}
@@ -189,24 +479,96 @@ func (s *GmailService) FetchMessages(ctx context.Context, token *oauth2.Token) (
 				ThreadID:       m.ThreadID,
 				Subject:        m.Subject,
 				Sender:         m.Sender,
+				SenderAddress:  m.SenderAddress,
+				SenderName:     m.SenderName,
 				Snippet:        m.Snippet,
 				InternalDate:   m.InternalDate,
+				SizeEstimate:   m.SizeEstimate,
+				HasAttachments: m.HasAttachments,
 				Date:           m.Date,
+
+				Category:                 m.Category,
+				CategorizationConfidence: m.CategorizationConfidence,
+				ProviderCategory:         m.ProviderCategory,
+				ProviderImportant:        m.ProviderImportant,
+				IsRead:                   m.IsRead,
+				ChangedAt:                m.ChangedAt,
 			}
 		}
 	}
 
 	// 2. Trigger background sync for fresh Gmail data
-	if token != nil && EnableBackgroundSync {
-		go func() {
-			_ = s.syncLatestSummariesFromGmail(ctx, token, userID)
-			// Log error, but do not block user experience
-		}()
-	}
+	syncing := s.startBackgroundSync(ctx, token, userID)
 
+	// An empty first page before any sync has completed is a cold cache, not an empty inbox
+	if len(result) == 0 && cursor.AfterID == "" && syncing && s.neverSynced(ctx, userID) {
+		return nil, &provider.Error{Kind: provider.ErrSyncing, RetryAfter: ColdCacheRetryAfter}
+	}
 	return result, nil
 }
 
+// startBackgroundSync starts a sync for the user unless one is already running or the last
+// one finished within SyncCooldown, and reports whether a sync is in flight. Skipped starts
+// are counted (see metrics.ObserveSuppressedSync). The sync outlives the request that
+// triggered it.
+func (s *GmailService) startBackgroundSync(ctx context.Context, token *oauth2.Token, userID string) bool {
+	if token == nil || !EnableBackgroundSync {
+		return false
+	}
+	if last, ok := s.lastSynced.Load(userID); ok && time.Since(last.(time.Time)) < SyncCooldown {
+		metrics.ObserveSuppressedSync("gmail", "cooldown")
+		return false
+	}
+	done := make(chan struct{})
+	if _, running := s.inFlight.LoadOrStore(userID, done); running {
+		metrics.ObserveSuppressedSync("gmail", "in_flight")
+		return true
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer s.finishSync(userID, done)
+		// Report the error, but do not block user experience
+		err := s.syncLatestSummariesFromGmail(ctx, token, userID)
+		if err != nil && !errors.Is(err, maintenance.ErrActive) && !errors.Is(err, provider.ErrCircuitOpen) {
+			telemetryerrors.Capture(ctx, s.Errors, err, userID, map[string]string{"job_type": "gmail_sync"})
+		}
+	}()
+	return true
+}
+
+// waitForSync claims the user's sync slot, first waiting for a running sync to finish
+func (s *GmailService) waitForSync(ctx context.Context, userID string) (chan struct{}, error) {
+	done := make(chan struct{})
+	for {
+		running, loaded := s.inFlight.LoadOrStore(userID, done)
+		if !loaded {
+			return done, nil
+		}
+		select {
+		case <-running.(chan struct{}):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// finishSync releases the user's sync slot taken with done
+func (s *GmailService) finishSync(userID string, done chan struct{}) {
+	s.lastSynced.Store(userID, time.Now())
+	s.inFlight.Delete(userID)
+	close(done)
+}
+
+// neverSynced reports whether no sync page has ever been committed for the user. Without a
+// SyncState store it cannot tell, and reports false.
+func (s *GmailService) neverSynced(ctx context.Context, userID string) bool {
+	if s.SyncState == nil {
+		return false
+	}
+	state, err := s.SyncState.GetSyncState(ctx, userID)
+	return err == nil && state.UpdatedAt.IsZero()
+}
+
 // fetchUserMessages fetches messages for a user with cursor-based pagination (from DB only)
 func (s *GmailService) fetchUserMessages(ctx context.Context, userID string, pageSize int, afterInternalDate int64, afterID string) ([]*models.EmailMessage, error) {
 	if afterID != "" && afterInternalDate > 0 {
@@ -215,63 +577,651 @@ func (s *GmailService) fetchUserMessages(ctx context.Context, userID string, pag
 	return s.Repo.GetMessagesForUserCursor(ctx, userID, pageSize, 0, "")
 }
 
+// SyncUser fetches the latest message summaries for a user and updates the cache.
+// It is the entry point for scheduled syncs, which run outside any user session.
+func (s *GmailService) SyncUser(ctx context.Context, userID string, token *oauth2.Token) error {
+	// Background runs have no request user; a token refreshed during the run is stored for it
+	if ctxkeys.UserID(ctx) == "" {
+		ctx = ctxkeys.WithUserID(ctx, userID)
+	}
+	// Runs after a sync started by a page load rather than alongside it, so the change that
+	// triggered this one is not missed
+	done, err := s.waitForSync(ctx, userID)
+	if err != nil {
+		return err
+	}
+	defer s.finishSync(userID, done)
+	return s.syncLatestSummariesFromGmail(ctx, token, userID)
+}
+
 // syncLatestSummariesFromGmail fetches the latest message summaries from Gmail API and upserts them into the DB.
 // This is run in the background after each inbox load for best UX.
-func (s *GmailService) syncLatestSummariesFromGmail(ctx context.Context, token *oauth2.Token, userID string) error {
-	var listCall UsersMessagesListCall
-	var getCall func(msgID string) UsersMessagesGetCall
+// Each listed page is written as a unit (see syncPage); a run covers at most SyncPagesPerRun
+// pages, resuming from the stored cursor if the previous run was interrupted. Once a walk has
+// finished and a history ID is stored, runs fetch only the messages changed since (see
+// syncHistory). Each run ends with one summary (see finishSyncRun).
+func (s *GmailService) syncLatestSummariesFromGmail(ctx context.Context, token *oauth2.Token, userID string) (err error) {
+	// Syncs started by a page load continue its trace; scheduled ones start their own
+	ctx, span := tracing.Start(ctx, "gmail.Sync", attribute.String("enduser.id", userID))
+	defer func() { tracing.End(span, err) }()
+	if s.Maintenance.Active() {
+		return maintenance.ErrActive
+	}
+	// Users who opted out of caching are served by ListPassthrough; there is nothing to sync
+	if s.localCacheDisabled(ctx, userID) {
+		return nil
+	}
+	if err := s.Breaker.Allow(userID); err != nil {
+		return err
+	}
+	start := time.Now()
+	run := &models.SyncRun{UserID: userID, Provider: "gmail", StartedAt: start.UTC()}
+	defer func() {
+		s.Breaker.Record(userID, err)
+		metrics.ObserveSync("gmail", time.Since(start), run.Upserted, err)
+		s.finishSyncRun(ctx, run, time.Since(start), err)
+	}()
+	listCall, getCall, err := s.messageCalls(ctx, token)
+	if err != nil {
+		return err
+	}
 
-	if s.GmailAPI != nil {
-		listCall = s.GmailAPI.UsersMessagesList("me")
-		getCall = func(msgID string) UsersMessagesGetCall {
-			return s.GmailAPI.UsersMessagesGet("me", msgID)
+	if err := s.retryFailedSyncItems(ctx, token, run, getCall); err != nil {
+		return err
+	}
+
+	state, err := s.syncState(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if state.HistoryID > 0 && state.PageToken == "" {
+		err := s.syncHistory(ctx, token, state, run, getCall)
+		switch {
+		case err == nil:
+			s.notifySynced(ctx, userID)
+			return nil
+		case !errors.Is(err, ErrNotFound):
+			return err
 		}
-	} else {
-		client, err := getGmailClient(ctx, token)
+		// Gmail no longer keeps history that far back; walk the list from the newest messages
+		state.HistoryID = 0
+	}
+	for page := 1; ; page++ {
+		resp, err := doCall("messages.list", listCall(state.PageToken, 0).Do)
 		if err != nil {
+			return classifyError(err)
+		}
+		// The cursor only points past this page while the walk continues; otherwise the
+		// next run starts from the newest messages again
+		next := ""
+		if page < SyncPagesPerRun {
+			next = resp.NextPageToken
+		}
+		run.Listed += len(resp.Messages)
+		if err := s.syncPage(ctx, token, state, run, resp.Messages, next, getCall); err != nil {
 			return err
 		}
-		listCall = client.Users.Messages.List("me")
-		getCall = func(msgID string) UsersMessagesGetCall {
-			return client.Users.Messages.Get("me", msgID)
+		if next == "" {
+			break
 		}
 	}
+	s.notifySynced(ctx, userID)
+	return nil
+}
 
-	resp, err := listCall.Do()
+// BackfillPage caches one page of the user's whole message list, starting at pageToken (empty
+// for the newest messages), and returns the token of the next page, empty after the last, and
+// how many messages it cached. Messages already cached are skipped. Unlike syncs it leaves the
+// sync cursor alone, so a backfill can walk the mailbox while the regular syncs keep it current.
+func (s *GmailService) BackfillPage(ctx context.Context, userID string, token *oauth2.Token, pageToken string) (string, int, error) {
+	if ctxkeys.UserID(ctx) == "" {
+		ctx = ctxkeys.WithUserID(ctx, userID)
+	}
+	if s.localCacheDisabled(ctx, userID) {
+		return "", 0, nil
+	}
+	listCall, getCall, err := s.messageCalls(ctx, token)
 	if err != nil {
-		return err
+		return "", 0, err
 	}
+	resp, err := doCall("messages.list", listCall(pageToken, 0).Do)
+	if err != nil {
+		return "", 0, classifyError(err)
+	}
+	cached := 0
 	for _, msg := range resp.Messages {
 		if msg == nil {
 			continue
 		}
-		get := getCall(msg.Id)
-		msg, err := get.Do()
-		if err != nil || msg == nil {
+		if m, err := s.Repo.GetMessageByID(ctx, userID, msg.Id); err == nil && m != nil {
 			continue
-		} // skip errored messages
-		dbMsg := &models.EmailMessage{
-			UserID:         userID,
-			EmailMessageID: msg.Id,
-			ThreadID:       msg.ThreadId,
-			Subject:        getHeader(msg.Payload.Headers, "Subject"),
-			Sender:         getHeader(msg.Payload.Headers, "From"),
-			Snippet:        msg.Snippet,
-			InternalDate:   msg.InternalDate,
-			Date:           getHeader(msg.Payload.Headers, "Date"),
-			CachedAt:       time.Now(),
-			RawJSON:        mustMarshalRawJSON(msg),
-		}
-		_ = s.Repo.UpsertMessage(ctx, dbMsg)
-	}
-	// Notify client (poll endpoint) after sync completes for instant refresh
-	userID = extractUserIDFromContext(ctx)
+		}
+		stage, err := s.syncMessage(ctx, token, userID, msg.Id, getCall)
+		switch {
+		case err == nil:
+			cached++
+		case errors.Is(err, ErrNotFound):
+			// Deleted between list and get
+		case abortsSync(err):
+			return "", cached, err
+		default:
+			log.Printf("backfill: failed to %s message %s for user %s: %v", stage, msg.Id, userID, err)
+		}
+	}
+	return resp.NextPageToken, cached, nil
+}
+
+// notifySynced tells clients (poll endpoint and event stream) a sync completed, for instant refresh
+func (s *GmailService) notifySynced(ctx context.Context, userID string) {
 	if userID != "" {
 		notify.SetGmailSyncStatus(userID)
+		notify.Publish(ctx, notify.EventSyncComplete, userID)
+	}
+}
+
+// syncHistory fetches and writes the messages added or changed since state's history ID, as
+// one page, and advances the history ID to the mailbox's latest. Deleted messages are skipped
+// when they fail to fetch. ErrNotFound means the history ID is too old for Gmail to answer from.
+func (s *GmailService) syncHistory(ctx context.Context, token *oauth2.Token, state *models.SyncState, run *models.SyncRun, getCall func(msgID string) UsersMessagesGetCall) error {
+	historyCall, err := s.historyCall(ctx, token)
+	if err != nil {
+		return err
+	}
+	var changed []*gmail.Message
+	seen := map[string]bool{}
+	latest := state.HistoryID
+	pageToken := ""
+	for {
+		resp, err := doCall("history.list", historyCall(uint64(state.HistoryID), pageToken).Do)
+		if err != nil {
+			return classifyError(err)
+		}
+		for _, h := range resp.History {
+			for _, msg := range h.Messages {
+				if msg != nil && !seen[msg.Id] {
+					seen[msg.Id] = true
+					changed = append(changed, &gmail.Message{Id: msg.Id})
+				}
+			}
+		}
+		latest = max(latest, int64(resp.HistoryId))
+		if resp.NextPageToken == "" {
+			break
+		}
+		pageToken = resp.NextPageToken
+	}
+	run.Listed += len(changed)
+	// The page is written together with the new history ID, so a failed write retries every change
+	next := *state
+	next.HistoryID = latest
+	if err := s.syncPage(ctx, token, &next, run, changed, "", getCall); err != nil {
+		return err
+	}
+	*state = next
+	return nil
+}
+
+// historyCall returns the history list call to use: the injected GmailAPI if set, otherwise a
+// client for token
+func (s *GmailService) historyCall(ctx context.Context, token *oauth2.Token) (func(startHistoryID uint64, pageToken string) UsersHistoryListCall, error) {
+	if s.GmailAPI != nil {
+		return func(startHistoryID uint64, pageToken string) UsersHistoryListCall {
+			return s.GmailAPI.UsersHistoryList("me", startHistoryID, pageToken)
+		}, nil
+	}
+	client, err := s.getGmailClient(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return func(startHistoryID uint64, pageToken string) UsersHistoryListCall {
+		return client.Users.History.List("me").StartHistoryId(startHistoryID).PageToken(pageToken)
+	}, nil
+}
+
+// messageCalls returns the list and get calls to use: the injected GmailAPI if set, otherwise
+// a client for token. maxResults of 0 leaves Gmail's default page size; GmailAPI ignores it.
+func (s *GmailService) messageCalls(ctx context.Context, token *oauth2.Token) (func(pageToken string, maxResults int64) UsersMessagesListCall, func(msgID string) UsersMessagesGetCall, error) {
+	if s.GmailAPI != nil {
+		listCall := func(pageToken string, maxResults int64) UsersMessagesListCall {
+			return s.GmailAPI.UsersMessagesList("me", pageToken)
+		}
+		getCall := func(msgID string) UsersMessagesGetCall {
+			return s.GmailAPI.UsersMessagesGet("me", msgID)
+		}
+		return listCall, getCall, nil
+	}
+	client, err := s.getGmailClient(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	listCall := func(pageToken string, maxResults int64) UsersMessagesListCall {
+		call := client.Users.Messages.List("me").PageToken(pageToken)
+		if maxResults > 0 {
+			call = call.MaxResults(maxResults)
+		}
+		return call
+	}
+	getCall := func(msgID string) UsersMessagesGetCall {
+		return client.Users.Messages.Get("me", msgID)
+	}
+	return listCall, getCall, nil
+}
+
+// cachePolicy returns the user's caching opt-outs; both are off without a settings store. A
+// settings read failure turns both on: skipping a cache write is harmless, storing mail
+// against the user's wishes is not.
+func (s *GmailService) cachePolicy(ctx context.Context, userID string) (disabled, metadataOnly bool) {
+	if s.Settings == nil || userID == "" {
+		return false, false
+	}
+	settings, err := s.Settings.Get(ctx, userID)
+	if err != nil {
+		log.Printf("failed to read settings for user %s, applying the strictest caching: %v", userID, err)
+		return true, true
+	}
+	return settings.DisableLocalCache, settings.MetadataOnlyCache
+}
+
+// cacheKey returns the public key the user's cached bodies are sealed to, or nil when their
+// cache is not encrypted
+func (s *GmailService) cacheKey(ctx context.Context, userID string) ([]byte, error) {
+	if s.EncryptionKeys == nil || userID == "" {
+		return nil, nil
+	}
+	key, err := s.EncryptionKeys.Get(ctx, userID)
+	if errors.Is(err, data.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key.PublicKey, nil
+}
+
+// localCacheDisabled reports whether the user opted out of local caching altogether
+func (s *GmailService) localCacheDisabled(ctx context.Context, userID string) bool {
+	disabled, _ := s.cachePolicy(ctx, userID)
+	return disabled
+}
+
+// ListPassthrough lists one page of message summaries straight from Gmail, for users who
+// disabled local caching. pageToken is Gmail's token from the previous page (empty for the
+// newest messages); the returned token is empty on the last page. Nothing is cached and no
+// categorization runs, so summaries carry only Gmail's own classification.
+func (s *GmailService) ListPassthrough(ctx context.Context, token *oauth2.Token, pageToken string, limit int) ([]*models.EmailMessage, string, error) {
+	userID := ctxkeys.UserID(ctx)
+	listCall, getCall, err := s.messageCalls(ctx, token)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := doCall("messages.list", listCall(pageToken, int64(limit)).Do)
+	if err != nil {
+		return nil, "", classifyError(err)
+	}
+	msgs := make([]*models.EmailMessage, 0, len(resp.Messages))
+	for _, listed := range resp.Messages {
+		if listed == nil {
+			continue
+		}
+		msg, err := doCall("messages.get", getCall(listed.Id).Do)
+		if err != nil {
+			err = classifyError(err)
+			if errors.Is(err, ErrNotFound) {
+				// Deleted between list and get
+				continue
+			}
+			return nil, "", err
+		}
+		if msg == nil {
+			continue
+		}
+		msgs = append(msgs, summaryMessage(userID, msg))
+	}
+	return msgs, resp.NextPageToken, nil
+}
+
+// syncState returns the user's sync cursor, or the zero state when cursors are not stored
+func (s *GmailService) syncState(ctx context.Context, userID string) (*models.SyncState, error) {
+	if s.SyncState == nil {
+		return &models.SyncState{UserID: userID}, nil
+	}
+	return s.SyncState.GetSyncState(ctx, userID)
+}
+
+// syncPage fetches a page of listed messages and writes them together with the cursor
+// advanced to nextPageToken. Messages Gmail no longer has are removed from the cache in the
+// same writes. With Tx set the writes are one transaction, so a crash or database error leaves
+// the page unwritten and the cursor where it was, and the next run retries the page. Messages
+// that fail to fetch are dead-lettered rather than failing the page. Rules and update events
+// run only once the page is written. Outcomes are counted in run.
+func (s *GmailService) syncPage(ctx context.Context, token *oauth2.Token, state *models.SyncState, run *models.SyncRun, msgs []*gmail.Message, nextPageToken string, getCall func(msgID string) UsersMessagesGetCall) error {
+	userID := state.UserID
+	var fetched []*pendingMessage
+	var gone []string
+	for _, msg := range msgs {
+		if msg == nil {
+			continue
+		}
+		p, err := s.fetchForSync(ctx, userID, msg.Id, getCall)
+		switch {
+		case err == nil:
+			fetched = append(fetched, p)
+		case errors.Is(err, ErrNotFound):
+			// Deleted at Gmail, between list and get or since the last history sync
+			gone = append(gone, msg.Id)
+			run.Skipped++
+		case abortsSync(err):
+			return err
+		default:
+			s.recordSyncFailure(ctx, run, msg.Id, models.SyncStageFetch, err)
+		}
+	}
+	// The raw payload of a full-format get carries the body
+	if _, metadataOnly := s.cachePolicy(ctx, userID); metadataOnly {
+		for _, p := range fetched {
+			p.msg = withoutContent(p.msg)
+		}
+	}
+	run.Fetched += len(fetched)
+	next := *state
+	next.PageToken = nextPageToken
+	for _, p := range fetched {
+		if p.msg.HistoryID > next.HistoryID {
+			next.HistoryID = p.msg.HistoryID
+		}
+	}
+
+	written := fetched
+	if s.Tx != nil {
+		err := s.Tx.WithTx(ctx, func(tx *data.Tx) error {
+			for _, p := range fetched {
+				if err := tx.Messages.UpsertMessage(ctx, p.msg); err != nil {
+					return fmt.Errorf("upsert message %s: %w", p.msg.EmailMessageID, err)
+				}
+			}
+			for _, id := range gone {
+				if err := tx.Messages.DeleteMessage(ctx, userID, id); err != nil && !errors.Is(err, data.ErrNotFound) {
+					return fmt.Errorf("delete message %s: %w", id, err)
+				}
+			}
+			// Earlier failures of these messages are settled with the writes, so the retry job
+			// does not fetch them again
+			if s.FailedItems != nil {
+				for _, id := range settledIDs(fetched, gone) {
+					if err := tx.FailedItems.Resolve(ctx, userID, id); err != nil {
+						return fmt.Errorf("resolve sync failure %s: %w", id, err)
+					}
+				}
+			}
+			return tx.SyncState.SaveSyncState(ctx, &next)
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		// Without transactions each message is written on its own, as a best effort
+		written = nil
+		for _, p := range fetched {
+			if err := s.Repo.UpsertMessage(ctx, p.msg); err != nil {
+				s.recordSyncFailure(ctx, run, p.msg.EmailMessageID, models.SyncStageUpsert, err)
+				continue
+			}
+			written = append(written, p)
+		}
+		for _, id := range gone {
+			s.dropCachedMessage(ctx, userID, id)
+		}
+		if s.FailedItems != nil {
+			for _, id := range settledIDs(written, gone) {
+				if err := s.FailedItems.Resolve(ctx, userID, id); err != nil {
+					log.Printf("failed to resolve sync failure for message %s: %v", id, err)
+				}
+			}
+		}
+		if s.SyncState != nil {
+			if err := s.SyncState.SaveSyncState(ctx, &next); err != nil {
+				run.Upserted += len(written)
+				return err
+			}
+		}
+	}
+	run.Upserted += len(written)
+	*state = next
+	for _, p := range written {
+		s.afterSync(ctx, token, userID, p)
 	}
 	return nil
 }
 
+// settledIDs lists the messages a page wrote or found deleted, whose earlier sync failures no
+// longer need a retry
+func settledIDs(written []*pendingMessage, gone []string) []string {
+	ids := make([]string, 0, len(written)+len(gone))
+	for _, p := range written {
+		ids = append(ids, p.msg.EmailMessageID)
+	}
+	return append(ids, gone...)
+}
+
+// pendingMessage is a fetched message waiting to be written, with what to do once it is
+type pendingMessage struct {
+	msg     *models.EmailMessage
+	isNew   bool
+	updated bool
+}
+
+// syncMessage fetches a single message summary and upserts it into the cache,
+// running user rules on messages that were not cached before.
+// On failure it returns the stage (fetch or upsert) that failed.
+func (s *GmailService) syncMessage(ctx context.Context, token *oauth2.Token, userID, msgID string, getCall func(msgID string) UsersMessagesGetCall) (string, error) {
+	p, err := s.fetchForSync(ctx, userID, msgID, getCall)
+	if errors.Is(err, ErrNotFound) {
+		s.dropCachedMessage(ctx, userID, msgID)
+	}
+	if err != nil {
+		return models.SyncStageFetch, err
+	}
+	if err := s.Repo.UpsertMessage(ctx, p.msg); err != nil {
+		return models.SyncStageUpsert, err
+	}
+	s.afterSync(ctx, token, userID, p)
+	return "", nil
+}
+
+// fetchForSync fetches a message summary and prepares it for the cache: change detection
+// against the cached copy and categorization of new or changed content
+func (s *GmailService) fetchForSync(ctx context.Context, userID, msgID string, getCall func(msgID string) UsersMessagesGetCall) (*pendingMessage, error) {
+	msg, err := doCall("messages.get", getCall(msgID).Do)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	// Trashed messages are as good as deleted: history sync reports them when they are
+	// trashed, and ErrNotFound has syncPage and syncMessage remove the cached copy, as
+	// MessageActionService.Trash does for messages trashed here
+	if msg == nil || slices.Contains(msg.LabelIds, "TRASH") {
+		return nil, ErrNotFound
+	}
+	dbMsg := summaryMessage(userID, msg)
+	if s.DropRawJSON {
+		dbMsg.RawJSON = nil
+	}
+	// Summaries carry no body to seal, but the raw payload and the snippet quote it. A key
+	// lookup failure is treated as encrypted.
+	if publicKey, err := s.cacheKey(ctx, userID); err != nil || publicKey != nil {
+		dbMsg.RawJSON = nil
+		dbMsg.Snippet = ""
+	}
+	// A failed lookup fails the message rather than treating it as new, which would run the
+	// rules and a paid categorization again; the retry finds it as it is
+	cached, err := s.cachedMessage(ctx, userID, msg.Id)
+	if err != nil {
+		return nil, err
+	}
+	isNew := cached == nil
+	// Both hashes cover the full payload, so a copy cached with its body by
+	// FetchMessageContent still matches an unchanged summary
+	changed := contentChanged(cached, dbMsg)
+	updated := markIfUpdated(cached, dbMsg)
+	// Re-categorize when the content changed on re-sync; unchanged messages keep their category,
+	// and so do messages the user or a rule categorized, stored with full confidence
+	userSet := cached != nil && cached.CategorizationConfidence.Valid && cached.CategorizationConfidence.Float64 >= 1
+	if (isNew || changed && !userSet) && s.Categorizer != nil {
+		// Categorization failures do not fail the sync. Nothing revisits a message cached
+		// uncategorized, so it gets the local heuristics' category instead.
+		c, err := s.Categorizer.Categorize(ctx, userID, dbMsg)
+		if err != nil {
+			log.Printf("categorization failed for message %s, using heuristics: %v", msg.Id, err)
+			c = ai.CategorizeHeuristic(dbMsg)
+		}
+		dbMsg.Category = sql.NullString{String: c.Category, Valid: true}
+		dbMsg.CategorizationConfidence = sql.NullFloat64{Float64: c.Confidence, Valid: true}
+	}
+	return &pendingMessage{msg: dbMsg, isNew: isNew, updated: updated}, nil
+}
+
+// summaryMessage converts a fetched Gmail message to its summary form, without bodies
+func summaryMessage(userID string, msg *gmail.Message) *models.EmailMessage {
+	dbMsg := &models.EmailMessage{
+		UserID:         userID,
+		EmailMessageID: msg.Id,
+		ThreadID:       msg.ThreadId,
+		Subject:        getHeader(msg.Payload.Headers, "Subject"),
+		Sender:         getHeader(msg.Payload.Headers, "From"),
+		Recipient:      getHeader(msg.Payload.Headers, "To"),
+		Snippet:        msg.Snippet,
+		InternalDate:   msg.InternalDate,
+		SizeEstimate:   msg.SizeEstimate,
+		HasAttachments: hasAttachments(msg.Payload),
+		Date:           getHeader(msg.Payload.Headers, "Date"),
+		HistoryID:      int64(msg.HistoryId),
+		CachedAt:       time.Now(),
+		RawJSON:        mustMarshalRawJSON(msg),
+		Headers:        messageHeaders(msg.Payload),
+	}
+	dbMsg.ParseSender()
+	dbMsg.ParseRecipients()
+	// Gmail's own classification is stored as a baseline signal before our categorizer runs
+	applyLabelSignals(dbMsg, msg.LabelIds)
+	dbMsg.ContentHash = contentHash(msg)
+	dbMsg.LabelHash = labelHash(msg)
+	return dbMsg
+}
+
+// afterSync publishes updates and runs user rules on new messages once a message is cached
+func (s *GmailService) afterSync(ctx context.Context, token *oauth2.Token, userID string, p *pendingMessage) {
+	if p.updated {
+		notify.Publish(notify.WithMessageID(ctx, p.msg.EmailMessageID), notify.EventMessageUpdated, userID)
+	}
+	if p.isNew && s.Rules != nil {
+		// Rule failures do not fail the sync; the message itself is cached
+		if err := s.Rules.ApplyRules(ctx, userID, token, p.msg); err != nil {
+			log.Printf("rules failed for message %s: %v", p.msg.EmailMessageID, err)
+		}
+	}
+}
+
+// cachedMessage returns the cached copy of the message, or nil if it is not yet cached
+func (s *GmailService) cachedMessage(ctx context.Context, userID, msgID string) (*models.EmailMessage, error) {
+	cached, err := s.Repo.GetMessageByID(ctx, userID, msgID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("look up cached message: %w", err)
+	}
+	return cached, nil
+}
+
+// dropCachedMessage removes a message Gmail no longer has from the cache. Failures are only
+// logged; the next sync that sees the message gone tries again.
+func (s *GmailService) dropCachedMessage(ctx context.Context, userID, msgID string) {
+	if err := s.Repo.DeleteMessage(ctx, userID, msgID); err != nil && !errors.Is(err, data.ErrNotFound) {
+		log.Printf("failed to remove deleted message %s from the cache: %v", msgID, err)
+	}
+}
+
+// recordSyncFailure counts a failed message in run and stores it in the dead-letter table so it
+// is retried on the next sync
+func (s *GmailService) recordSyncFailure(ctx context.Context, run *models.SyncRun, msgID, stage string, syncErr error) {
+	run.Errors++
+	if s.FailedItems == nil {
+		return
+	}
+	if err := s.FailedItems.RecordFailure(ctx, run.UserID, msgID, stage, syncErr.Error()); err != nil {
+		log.Printf("failed to record sync failure for message %s: %v", msgID, err)
+	}
+}
+
+// abortsSync reports whether an error affects the whole account rather than a single
+// message, so continuing the sync run would only fail again (and burn retry attempts).
+func abortsSync(err error) bool {
+	return errors.Is(err, provider.ErrAuthExpired) || errors.Is(err, provider.ErrRateLimited)
+}
+
+// retryFailedSyncItems retries previously failed messages that still have attempts left.
+// Messages that sync successfully, or no longer exist in Gmail, are removed from the dead-letter table.
+// It returns an error only when the sync run should be aborted.
+func (s *GmailService) retryFailedSyncItems(ctx context.Context, token *oauth2.Token, run *models.SyncRun, getCall func(msgID string) UsersMessagesGetCall) error {
+	if s.FailedItems == nil {
+		return nil
+	}
+	userID := run.UserID
+	items, err := s.FailedItems.ListRetryable(ctx, userID, MaxSyncAttempts, retryBatchSize)
+	if err != nil {
+		log.Printf("failed to list failed sync items for user %s: %v", userID, err)
+		return nil
+	}
+	for _, item := range items {
+		stage, err := s.syncMessage(ctx, token, userID, item.EmailMessageID, getCall)
+		switch {
+		case err == nil:
+			run.Fetched++
+			run.Upserted++
+		case errors.Is(err, ErrNotFound):
+			run.Skipped++
+		case abortsSync(err):
+			return err
+		default:
+			if stage == models.SyncStageUpsert {
+				run.Fetched++
+			}
+			s.recordSyncFailure(ctx, run, item.EmailMessageID, stage, err)
+			continue
+		}
+		if err := s.FailedItems.Resolve(ctx, userID, item.EmailMessageID); err != nil {
+			log.Printf("failed to resolve sync failure for message %s: %v", item.EmailMessageID, err)
+		}
+	}
+	return nil
+}
+
+// finishSyncRun logs the run as one structured event and stores it. Runs that stopped on rate
+// or quota limits count the refused call as a quota hit.
+func (s *GmailService) finishSyncRun(ctx context.Context, run *models.SyncRun, d time.Duration, err error) {
+	run.DurationMS = d.Milliseconds()
+	if err != nil {
+		run.Error = err.Error()
+		if errors.Is(err, provider.ErrRateLimited) {
+			run.QuotaHits++
+		}
+	}
+	event := debuglog.Logger(ctx).Info()
+	if err != nil {
+		event = debuglog.Logger(ctx).Warn().Err(err)
+	}
+	event.Str("user_id", run.UserID).Str("provider", run.Provider).Int64("duration_ms", run.DurationMS).
+		Int("listed", run.Listed).Int("fetched", run.Fetched).Int("upserted", run.Upserted).
+		Int("skipped", run.Skipped).Int("errors", run.Errors).Int("quota_hits", run.QuotaHits).
+		Msg("sync run")
+	if s.SyncRuns == nil {
+		return
+	}
+	// The sync's own context may be cancelled by now; the summary is still worth keeping
+	if err := s.SyncRuns.RecordSyncRun(context.WithoutCancel(ctx), run); err != nil {
+		log.Printf("failed to record sync run for user %s: %v", run.UserID, err)
+	}
+}
+
 // getHeader returns the value for a given header name (case-insensitive)
 func getHeader(headers []*gmail.MessagePartHeader, name string) string {
 	for _, h := range headers {
@@ -282,48 +1232,51 @@ func getHeader(headers []*gmail.MessagePartHeader, name string) string {
 	return ""
 }
 
-// extractPlainTextBody decodes the plain text body from a Gmail message payload
+// extractPlainTextBody decodes the plain text body from a Gmail message payload, searching nested
+// multiparts for the first text/plain part that is not an attachment
 func extractPlainTextBody(payload *gmail.MessagePart) string {
 	if payload == nil {
 		return ""
 	}
-	// Single-part: try direct body
-	if payload.Body != nil && payload.Body.Data != "" {
-		if decoded, err := decodeGmailBody(payload.Body.Data); err == nil {
-			return decoded
-		}
+	// A single-part message without a declared type is plain text
+	if payload.MimeType == "" && len(payload.Parts) == 0 {
+		return decodePart(payload)
 	}
-	// Multi-part: search for text/plain part
-	for _, part := range payload.Parts {
-		if part.MimeType == "text/plain" && part.Body != nil && part.Body.Data != "" {
-			if decoded, err := decodeGmailBody(part.Body.Data); err == nil {
-				return decoded
-			}
-		}
-	}
-	return ""
+	return decodePart(findPart(payload, "text/plain"))
 }
 
 // extractHTMLBody decodes the HTML body from a Gmail message payload
 func extractHTMLBody(payload *gmail.MessagePart) string {
-	if payload == nil {
-		return ""
+	return decodePart(findPart(payload, "text/html"))
+}
+
+// findPart returns the first inline part of the given type with a body, depth first
+func findPart(part *gmail.MessagePart, mimeType string) *gmail.MessagePart {
+	if part == nil {
+		return nil
 	}
-	// Single-part: try direct body if HTML
-	if payload.MimeType == "text/html" && payload.Body != nil && payload.Body.Data != "" {
-		if decoded, err := decodeGmailBody(payload.Body.Data); err == nil {
-			return decoded
-		}
+	if part.MimeType == mimeType && part.Filename == "" && part.Body != nil && part.Body.Data != "" {
+		return part
 	}
-	// Multi-part: search for text/html part
-	for _, part := range payload.Parts {
-		if part.MimeType == "text/html" && part.Body != nil && part.Body.Data != "" {
-			if decoded, err := decodeGmailBody(part.Body.Data); err == nil {
-				return decoded
-			}
+	for _, child := range part.Parts {
+		if found := findPart(child, mimeType); found != nil {
+			return found
 		}
 	}
-	return ""
+	return nil
+}
+
+// decodePart decodes a part's body and converts it to UTF-8 from the charset its Content-Type names
+func decodePart(part *gmail.MessagePart) string {
+	if part == nil || part.Body == nil || part.Body.Data == "" {
+		return ""
+	}
+	decoded, err := decodeGmailBody(part.Body.Data)
+	if err != nil {
+		return ""
+	}
+	_, params, _ := mime.ParseMediaType(getHeader(part.Headers, "Content-Type"))
+	return plaintext.Decode([]byte(decoded), params["charset"])
 }
 
 // decodeGmailBody decodes a base64url-encoded Gmail message body
@@ -2,12 +2,12 @@ package gmail
 
 import (
 	"context"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
 	"github.com/desponda/inbox-whisperer/internal/data"
 	"testing"
 	"time"
 
 	"github.com/desponda/inbox-whisperer/internal/models"
-	"github.com/desponda/inbox-whisperer/internal/session"
 )
 
 // This test covers cache hit, miss, and staleness for Gmail message caching
@@ -18,7 +18,7 @@ func TestGmailService_Caching(t *testing.T) {
 	ctx := context.Background()
 	userID := "user123"
 	msgID := "gmail_msg_1"
-	testCtx := session.ContextWithUserID(ctx, userID)
+	testCtx := ctxkeys.WithUserID(ctx, userID)
 	_ = NewGmailService(repo, &mockGmailAPI{}) // for completeness, but not used in this unit test
 
 	// Insert a fresh message (cache hit)
@@ -2,11 +2,22 @@ package gmail
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/desponda/inbox-whisperer/internal/ai"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/e2ee"
+	"github.com/desponda/inbox-whisperer/internal/maintenance"
 	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/notify"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/metrics"
 	"golang.org/x/oauth2"
 )
 
@@ -23,6 +34,35 @@ type mockGmailAPI struct {
 	listErr  error
 	msgMap   map[string]*gmail.Message
 	getErr   error
+	// pages, if set, answers list calls by page token instead of listResp
+	pages map[string]*gmail.ListMessagesResponse
+	// history answers history list calls by page token; without it history is reported expired
+	history      map[string]*gmail.ListHistoryResponse
+	historyStart []uint64
+	listCalls    int
+}
+
+func (m *mockGmailAPI) UsersHistoryList(userID string, startHistoryID uint64, pageToken string) UsersHistoryListCall {
+	m.historyStart = append(m.historyStart, startHistoryID)
+	if m.history == nil {
+		return &mockUsersHistoryListCall{err: &googleapi.Error{Code: 404}}
+	}
+	return &mockUsersHistoryListCall{resp: m.history[pageToken]}
+}
+
+type mockUsersHistoryListCall struct {
+	resp *gmail.ListHistoryResponse
+	err  error
+}
+
+func (c *mockUsersHistoryListCall) Do(...googleapi.CallOption) (*gmail.ListHistoryResponse, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.resp == nil {
+		return &gmail.ListHistoryResponse{}, nil
+	}
+	return c.resp, nil
 }
 
 // Implements GmailAPI
@@ -48,10 +88,26 @@ func (c *mockUsersMessagesGetCall) Do(...googleapi.CallOption) (*gmail.Message,
 	return c.msg, c.err
 }
 
-func (m *mockGmailAPI) UsersMessagesList(userID string) UsersMessagesListCall {
+func (m *mockGmailAPI) UsersMessagesList(userID, pageToken string) UsersMessagesListCall {
+	m.listCalls++
+	if m.pages != nil {
+		return &mockUsersMessagesListCall{resp: m.pages[pageToken], err: m.listErr}
+	}
 	return &mockUsersMessagesListCallWithPaging{allMessages: m.listResp, err: m.listErr}
 }
 
+type mockUsersMessagesListCall struct {
+	resp *gmail.ListMessagesResponse
+	err  error
+}
+
+func (c *mockUsersMessagesListCall) Do(...googleapi.CallOption) (*gmail.ListMessagesResponse, error) {
+	if c.resp == nil {
+		return &gmail.ListMessagesResponse{}, c.err
+	}
+	return c.resp, c.err
+}
+
 type mockUsersMessagesListCallWithPaging struct {
 	allMessages *gmail.ListMessagesResponse
 	err         error
@@ -186,20 +242,50 @@ func TestGmailService_syncLatestSummariesFromGmail(t *testing.T) {
 	if repo.upsertCount == 0 {
 		t.Errorf("expected upsert to be called, got 0")
 	}
+
+	// Maintenance mode pauses syncs
+	svc.Maintenance = maintenance.New()
+	svc.Maintenance.Set(true, "", 0)
+	repo.upsertCount = 0
+	if err := svc.SyncUser(ctx, "user1", tok); !errors.Is(err, maintenance.ErrActive) || repo.upsertCount != 0 {
+		t.Errorf("expected the sync to be skipped during maintenance, got %v (%d upserts)", err, repo.upsertCount)
+	}
 }
 
 type fakeUpsertRepo struct {
 	upsertCount int
+	cached      map[string]bool // msgID -> upserted; GetMessageByID reports these as cached
+	last        *models.EmailMessage
+	stored      map[string]models.EmailMessage
+	getErr      error // returned by GetMessageByID
+	deleted     []string
 }
 
 func (f *fakeUpsertRepo) UpsertMessage(ctx context.Context, msg *models.EmailMessage) error {
 	f.upsertCount++
+	f.last = msg
+	if f.cached != nil {
+		f.cached[msg.EmailMessageID] = true
+		if f.stored == nil {
+			f.stored = map[string]models.EmailMessage{}
+		}
+		f.stored[msg.EmailMessageID] = *msg
+	}
 	return nil
 }
 func (f *fakeUpsertRepo) GetMessage(ctx context.Context, userID, msgID string) (*models.EmailMessage, error) {
 	return nil, nil
 }
 func (f *fakeUpsertRepo) GetMessageByID(ctx context.Context, userID, msgID string) (*models.EmailMessage, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	if m, ok := f.stored[msgID]; ok {
+		return &m, nil
+	}
+	if f.cached[msgID] {
+		return &models.EmailMessage{EmailMessageID: msgID}, nil
+	}
 	return nil, nil
 }
 func (f *fakeUpsertRepo) ListMessages(ctx context.Context, userID string, pageSize int, afterInternalDate int64, afterID string) ([]*models.EmailMessage, error) {
@@ -212,6 +298,22 @@ func (f *fakeUpsertRepo) GetMessagesForUserCursor(ctx context.Context, userID st
 	return nil, nil
 }
 func (f *fakeUpsertRepo) DeleteMessagesForUser(ctx context.Context, userID string) error { return nil }
+func (f *fakeUpsertRepo) ClearMessageContent(ctx context.Context, userID string) error   { return nil }
+func (f *fakeUpsertRepo) SetCategory(ctx context.Context, userID, emailMessageID, category string, confidence float64) error {
+	return nil
+}
+func (f *fakeUpsertRepo) SetRead(ctx context.Context, userID, emailMessageID string, read bool) error {
+	return nil
+}
+func (f *fakeUpsertRepo) SetArchived(ctx context.Context, userID, emailMessageID string) error {
+	return nil
+}
+func (f *fakeUpsertRepo) DeleteMessage(ctx context.Context, userID, emailMessageID string) error {
+	f.deleted = append(f.deleted, emailMessageID)
+	delete(f.cached, emailMessageID)
+	delete(f.stored, emailMessageID)
+	return nil
+}
 
 type dummyRepo struct{}
 
@@ -232,3 +334,971 @@ func (d *dummyRepo) GetMessagesForUserCursor(ctx context.Context, userID string,
 	return nil, nil
 }
 func (d *dummyRepo) DeleteMessagesForUser(ctx context.Context, userID string) error { return nil }
+func (d *dummyRepo) ClearMessageContent(ctx context.Context, userID string) error   { return nil }
+func (d *dummyRepo) SetCategory(ctx context.Context, userID, emailMessageID, category string, confidence float64) error {
+	return nil
+}
+func (d *dummyRepo) SetRead(ctx context.Context, userID, emailMessageID string, read bool) error {
+	return nil
+}
+func (d *dummyRepo) SetArchived(ctx context.Context, userID, emailMessageID string) error {
+	return nil
+}
+func (d *dummyRepo) DeleteMessage(ctx context.Context, userID, emailMessageID string) error {
+	return nil
+}
+
+type fakeFailedItems struct {
+	recorded  map[string]string // msgID -> stage
+	retryable []*models.FailedSyncItem
+	resolved  []string
+}
+
+func (f *fakeFailedItems) RecordFailure(ctx context.Context, userID, emailMessageID, stage, errMsg string) error {
+	if f.recorded == nil {
+		f.recorded = map[string]string{}
+	}
+	f.recorded[emailMessageID] = stage
+	return nil
+}
+func (f *fakeFailedItems) ListRetryable(ctx context.Context, userID string, maxAttempts, limit int) ([]*models.FailedSyncItem, error) {
+	return f.retryable, nil
+}
+func (f *fakeFailedItems) ListExhausted(ctx context.Context, userID string, maxAttempts int) ([]*models.FailedSyncItem, error) {
+	return nil, nil
+}
+func (f *fakeFailedItems) Resolve(ctx context.Context, userID, emailMessageID string) error {
+	f.resolved = append(f.resolved, emailMessageID)
+	return nil
+}
+
+func TestGmailService_syncRecordsAndRetriesFailures(t *testing.T) {
+	repo := &fakeUpsertRepo{}
+	failed := &fakeFailedItems{}
+	mockAPI := &mockGmailAPI{
+		listResp: &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "id1"}}},
+		msgMap: map[string]*gmail.Message{
+			"id1": {Id: "id1", Payload: &gmail.MessagePart{}},
+			"id0": {Id: "id0", Payload: &gmail.MessagePart{}},
+		},
+		getErr: errors.New("get error"),
+	}
+	svc := NewGmailService(repo, mockAPI)
+	svc.FailedItems = failed
+	ctx := context.Background()
+	tok := &oauth2.Token{AccessToken: "dummy"}
+
+	// Failed fetch is recorded instead of silently dropped
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if failed.recorded["id1"] != models.SyncStageFetch {
+		t.Errorf("expected id1 recorded at fetch stage, got %+v", failed.recorded)
+	}
+
+	// Previously failed message is retried and resolved on the next sync, and so is the listed
+	// message once written
+	mockAPI.getErr = nil
+	failed.retryable = []*models.FailedSyncItem{{UserID: "user1", EmailMessageID: "id0", Attempts: 1}}
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(failed.resolved) != 2 || failed.resolved[0] != "id0" || failed.resolved[1] != "id1" {
+		t.Errorf("expected id0 and id1 to be resolved, got %v", failed.resolved)
+	}
+	if repo.upsertCount != 2 {
+		t.Errorf("expected retried and listed messages to be upserted, got %d", repo.upsertCount)
+	}
+}
+
+func TestGmailService_syncHonorsProviderErrors(t *testing.T) {
+	ctx := context.Background()
+	tok := &oauth2.Token{AccessToken: "dummy"}
+	listResp := &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "id1"}, {Id: "id2"}}}
+
+	// Deleted messages are neither dead-lettered nor reported as sync errors
+	failed := &fakeFailedItems{retryable: []*models.FailedSyncItem{{UserID: "user1", EmailMessageID: "gone"}}}
+	svc := NewGmailService(&fakeUpsertRepo{}, &mockGmailAPI{listResp: listResp, msgMap: map[string]*gmail.Message{}, getErr: &googleapi.Error{Code: 404}})
+	svc.FailedItems = failed
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(failed.recorded) != 0 {
+		t.Errorf("expected no failures recorded for deleted messages, got %+v", failed.recorded)
+	}
+	if len(failed.resolved) != 3 || failed.resolved[0] != "gone" {
+		t.Errorf("expected the deleted dead-letter item and listed messages to be resolved, got %v", failed.resolved)
+	}
+
+	// Rate limiting aborts the run without burning per-message retry attempts
+	failed = &fakeFailedItems{}
+	svc = NewGmailService(&fakeUpsertRepo{}, &mockGmailAPI{listResp: listResp, msgMap: map[string]*gmail.Message{}, getErr: &googleapi.Error{Code: 429}})
+	svc.FailedItems = failed
+	err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1")
+	if !errors.Is(err, provider.ErrRateLimited) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	if len(failed.recorded) != 0 {
+		t.Errorf("expected no failures recorded when rate limited, got %+v", failed.recorded)
+	}
+}
+
+type fakeSyncRuns struct {
+	runs []models.SyncRun
+}
+
+func (f *fakeSyncRuns) RecordSyncRun(ctx context.Context, run *models.SyncRun) error {
+	f.runs = append(f.runs, *run)
+	return nil
+}
+func (f *fakeSyncRuns) ListSyncRuns(ctx context.Context, userID string, since time.Time, limit int) ([]models.SyncRun, error) {
+	return f.runs, nil
+}
+func (f *fakeSyncRuns) SyncRunStats(ctx context.Context, since time.Time) ([]models.SyncRunStats, error) {
+	return nil, nil
+}
+
+func TestGmailService_syncRecordsRunSummaries(t *testing.T) {
+	ctx := context.Background()
+	tok := &oauth2.Token{AccessToken: "dummy"}
+	runs := &fakeSyncRuns{}
+	mockAPI := &mockGmailAPI{
+		listResp: &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "id1"}, {Id: "id2"}}},
+		msgMap:   map[string]*gmail.Message{"id1": {Id: "id1", Payload: &gmail.MessagePart{}}},
+	}
+	svc := NewGmailService(&fakeUpsertRepo{}, mockAPI)
+	svc.FailedItems = &fakeFailedItems{retryable: []*models.FailedSyncItem{{UserID: "user1", EmailMessageID: "gone"}}}
+	svc.SyncRuns = runs
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(runs.runs) != 1 {
+		t.Fatalf("expected one run recorded, got %+v", runs.runs)
+	}
+	got := runs.runs[0]
+	if got.UserID != "user1" || got.Provider != "gmail" || got.Listed != 2 || got.Fetched != 1 ||
+		got.Upserted != 1 || got.Skipped != 2 || got.Errors != 0 || got.Error != "" || got.StartedAt.IsZero() {
+		t.Errorf("unexpected run %+v", got)
+	}
+
+	// A rate-limited run is recorded with its error and the quota hit
+	mockAPI.getErr = &googleapi.Error{Code: 429}
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); !errors.Is(err, provider.ErrRateLimited) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	if got := runs.runs[len(runs.runs)-1]; got.QuotaHits != 1 || got.Error == "" {
+		t.Errorf("unexpected rate-limited run %+v", got)
+	}
+}
+
+type fakeRules struct {
+	seen []string
+}
+
+func (f *fakeRules) ApplyRules(ctx context.Context, userID string, token *oauth2.Token, msg *models.EmailMessage) error {
+	f.seen = append(f.seen, msg.EmailMessageID)
+	return errors.New("label quota exceeded")
+}
+
+func TestGmailService_syncAppliesRulesToNewMessages(t *testing.T) {
+	repo := &fakeUpsertRepo{cached: map[string]bool{}}
+	rules := &fakeRules{}
+	mockAPI := &mockGmailAPI{
+		listResp: &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "id1"}}},
+		msgMap:   map[string]*gmail.Message{"id1": {Id: "id1", Payload: &gmail.MessagePart{}}},
+	}
+	svc := NewGmailService(repo, mockAPI)
+	svc.Rules = rules
+	failed := &fakeFailedItems{}
+	svc.FailedItems = failed
+	ctx := context.Background()
+	tok := &oauth2.Token{AccessToken: "dummy"}
+
+	// Rule errors are logged, not dead-lettered; already cached messages are not re-processed
+	for i := 0; i < 2; i++ {
+		if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	if len(rules.seen) != 1 || rules.seen[0] != "id1" {
+		t.Errorf("expected rules to run once for id1, got %v", rules.seen)
+	}
+	if len(failed.recorded) != 0 {
+		t.Errorf("expected rule failure not to be recorded as a sync failure, got %+v", failed.recorded)
+	}
+}
+
+type fakeCategorizer struct {
+	calls int
+	err   error
+}
+
+func (f *fakeCategorizer) Categorize(ctx context.Context, userID string, msg *models.EmailMessage) (*ai.Categorization, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &ai.Categorization{Category: "Updates", Confidence: 0.5, Source: ai.SourceHeuristic}, nil
+}
+
+func TestGmailService_syncCategorizesNewMessages(t *testing.T) {
+	repo := &fakeUpsertRepo{cached: map[string]bool{}}
+	cat := &fakeCategorizer{}
+	mockAPI := &mockGmailAPI{
+		listResp: &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "id1"}}},
+		msgMap:   map[string]*gmail.Message{"id1": {Id: "id1", Payload: &gmail.MessagePart{}, LabelIds: []string{"INBOX", "CATEGORY_UPDATES"}}},
+	}
+	svc := NewGmailService(repo, mockAPI)
+	svc.Categorizer = cat
+	ctx := context.Background()
+	tok := &oauth2.Token{AccessToken: "dummy"}
+
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.last == nil || repo.last.Category.String != "Updates" || !repo.last.CategorizationConfidence.Valid {
+		t.Errorf("expected upserted message to be categorized, got %+v", repo.last)
+	}
+	if repo.last.ProviderCategory != "Updates" || repo.last.ProviderImportant {
+		t.Errorf("expected Gmail's Updates tab to be stored as the provider category, got %+v", repo.last)
+	}
+	// Already cached messages keep their category and are not re-categorized
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cat.calls != 1 {
+		t.Errorf("expected one categorization, got %d", cat.calls)
+	}
+	// Changed content on re-sync invalidates the category
+	mockAPI.msgMap["id1"].Snippet = "edited"
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cat.calls != 2 {
+		t.Errorf("expected changed message to be re-categorized, got %d calls", cat.calls)
+	}
+	// Reading, starring or archiving changes only the labels and keeps the category
+	mockAPI.msgMap["id1"].LabelIds = []string{"CATEGORY_UPDATES", "STARRED"}
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cat.calls != 2 {
+		t.Errorf("expected a label change not to re-categorize, got %d calls", cat.calls)
+	}
+	// A category the user set is stored with full confidence and survives changed content
+	corrected := repo.stored["id1"]
+	corrected.Category = sql.NullString{String: "Personal", Valid: true}
+	corrected.CategorizationConfidence = sql.NullFloat64{Float64: 1, Valid: true}
+	repo.stored["id1"] = corrected
+	mockAPI.msgMap["id1"].Snippet = "edited again"
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cat.calls != 2 || repo.last.Category.Valid {
+		t.Errorf("expected the user's category to be left alone, got %d calls and %+v", cat.calls, repo.last.Category)
+	}
+}
+
+func TestGmailService_syncKeepsCategoryOfOpenedMessages(t *testing.T) {
+	repo := &fakeUpsertRepo{cached: map[string]bool{}}
+	cat := &fakeCategorizer{}
+	body := base64.RawURLEncoding.EncodeToString([]byte("the body"))
+	mockAPI := &mockGmailAPI{
+		listResp: &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "id1"}}},
+		msgMap: map[string]*gmail.Message{"id1": {Id: "id1", Snippet: "the bo", Payload: &gmail.MessagePart{
+			MimeType: "text/plain",
+			Body:     &gmail.MessagePartBody{Data: body},
+		}}},
+	}
+	svc := NewGmailService(repo, mockAPI)
+	svc.Categorizer = cat
+	ctx := context.Background()
+	tok := &oauth2.Token{AccessToken: "dummy"}
+
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// Opening the message caches it with its body, as FetchMessageContent does
+	opened := repo.stored["id1"]
+	opened.Body = "the body"
+	repo.stored["id1"] = opened
+
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cat.calls != 1 {
+		t.Errorf("expected the opened, unchanged message not to be re-categorized, got %d calls", cat.calls)
+	}
+}
+
+func TestGmailService_syncFailsMessagesWhoseLookupFails(t *testing.T) {
+	repo := &fakeUpsertRepo{cached: map[string]bool{}, getErr: errors.New("db down")}
+	cat, failed, rules := &fakeCategorizer{}, &fakeFailedItems{}, &fakeRules{}
+	mockAPI := &mockGmailAPI{
+		listResp: &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "id1"}}},
+		msgMap:   map[string]*gmail.Message{"id1": {Id: "id1", Payload: &gmail.MessagePart{}}},
+	}
+	svc := NewGmailService(repo, mockAPI)
+	svc.Categorizer, svc.FailedItems, svc.Rules = cat, failed, rules
+
+	if err := svc.syncLatestSummariesFromGmail(context.Background(), &oauth2.Token{AccessToken: "dummy"}, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if failed.recorded["id1"] != models.SyncStageFetch || cat.calls != 0 || len(rules.seen) != 0 || repo.upsertCount != 0 {
+		t.Errorf("expected the message failed for a retry without categorizing or rules, got %v, %d categorizations, %d rule runs, %d upserts",
+			failed.recorded, cat.calls, len(rules.seen), repo.upsertCount)
+	}
+}
+
+func TestGmailService_syncFallsBackToHeuristicCategory(t *testing.T) {
+	repo := &fakeUpsertRepo{cached: map[string]bool{}}
+	mockAPI := &mockGmailAPI{
+		listResp: &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "id1"}}},
+		msgMap: map[string]*gmail.Message{"id1": {Id: "id1", Snippet: "Your invoice is ready", Payload: &gmail.MessagePart{
+			Headers: []*gmail.MessagePartHeader{{Name: "Subject", Value: "Invoice"}},
+		}}},
+	}
+	svc := NewGmailService(repo, mockAPI)
+	svc.Categorizer = &fakeCategorizer{err: errors.New("consent lookup failed")}
+
+	if err := svc.syncLatestSummariesFromGmail(context.Background(), &oauth2.Token{AccessToken: "dummy"}, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.last == nil || repo.last.Category.String != "Updates" || repo.last.CategorizationConfidence.Float64 != 0.5 {
+		t.Errorf("expected the heuristic category after a categorizer failure, got %+v", repo.last)
+	}
+}
+
+func TestGmailService_syncDetectsUpdatedMessages(t *testing.T) {
+	repo := &fakeUpsertRepo{cached: map[string]bool{}}
+	mockAPI := &mockGmailAPI{
+		listResp: &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "id1"}}},
+		msgMap:   map[string]*gmail.Message{"id1": {Id: "id1", Payload: &gmail.MessagePart{}, LabelIds: []string{"DRAFT"}}},
+	}
+	svc := NewGmailService(repo, mockAPI)
+	ctx := context.Background()
+	tok := &oauth2.Token{AccessToken: "dummy"}
+	var updated []string
+	unsubscribe := notify.Subscribe(notify.EventMessageUpdated, func(ctx context.Context, userID string) {
+		updated = append(updated, userID+"/"+notify.MessageID(ctx))
+	})
+	defer unsubscribe()
+
+	// New and unchanged messages are not updates
+	for i := 0; i < 2; i++ {
+		if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	if len(updated) != 0 || repo.last.ContentHash == "" || repo.last.ChangedAt.Valid {
+		t.Fatalf("expected a hashed, unchanged message and no events, got %v / %+v", updated, repo.last)
+	}
+
+	// A label change on the provider is detected, stamped and published
+	mockAPI.msgMap["id1"].LabelIds = []string{"DRAFT", "STARRED"}
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(updated) != 1 || updated[0] != "user1/id1" {
+		t.Errorf("expected one message_updated event for id1, got %v", updated)
+	}
+	if !repo.last.ChangedAt.Valid {
+		t.Errorf("expected changed_at to be set, got %+v", repo.last)
+	}
+	changedAt := repo.last.ChangedAt
+
+	// changed_at is kept across later unchanged syncs
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(updated) != 1 || repo.last.ChangedAt != changedAt {
+		t.Errorf("expected no further events and changed_at kept, got %v / %+v", updated, repo.last.ChangedAt)
+	}
+}
+
+type fakeSyncState struct {
+	state *models.SyncState
+	saves int
+	// pageTokens are the cursors saved, in order
+	pageTokens []string
+}
+
+func (f *fakeSyncState) GetSyncState(ctx context.Context, userID string) (*models.SyncState, error) {
+	if f.state == nil {
+		return &models.SyncState{UserID: userID}, nil
+	}
+	s := *f.state
+	return &s, nil
+}
+
+func (f *fakeSyncState) SaveSyncState(ctx context.Context, s *models.SyncState) error {
+	saved := *s
+	f.state = &saved
+	f.saves++
+	f.pageTokens = append(f.pageTokens, s.PageToken)
+	return nil
+}
+
+// fakeTx stages writes and applies them only when fn succeeds, like a database transaction
+type fakeTx struct {
+	repo    *fakeUpsertRepo
+	state   *fakeSyncState
+	failed  *fakeFailedItems
+	failOn  string // message ID whose upsert fails
+	commits int
+}
+
+func (f *fakeTx) WithTx(ctx context.Context, fn func(tx *data.Tx) error) error {
+	staged := &stagedWrites{failOn: f.failOn}
+	if err := fn(&data.Tx{Messages: staged, SyncState: staged, FailedItems: staged}); err != nil {
+		return err
+	}
+	if f.failed != nil {
+		f.failed.resolved = append(f.failed.resolved, staged.resolved...)
+	}
+	for _, m := range staged.msgs {
+		_ = f.repo.UpsertMessage(ctx, m)
+	}
+	for _, id := range staged.deletes {
+		_ = f.repo.DeleteMessage(ctx, "", id)
+	}
+	if staged.state != nil {
+		_ = f.state.SaveSyncState(ctx, staged.state)
+	}
+	f.commits++
+	return nil
+}
+
+type stagedWrites struct {
+	fakeUpsertRepo
+	fakeFailedItems
+	failOn  string
+	msgs    []*models.EmailMessage
+	deletes []string
+	state   *models.SyncState
+}
+
+func (s *stagedWrites) UpsertMessage(ctx context.Context, msg *models.EmailMessage) error {
+	if msg.EmailMessageID == s.failOn {
+		return errors.New("connection reset")
+	}
+	s.msgs = append(s.msgs, msg)
+	return nil
+}
+
+func (s *stagedWrites) DeleteMessage(ctx context.Context, userID, emailMessageID string) error {
+	s.deletes = append(s.deletes, emailMessageID)
+	return nil
+}
+
+func (s *stagedWrites) SaveSyncState(ctx context.Context, st *models.SyncState) error {
+	s.state = st
+	return nil
+}
+
+func (s *stagedWrites) GetSyncState(ctx context.Context, userID string) (*models.SyncState, error) {
+	return nil, nil
+}
+
+func TestGmailService_syncPagesPerRun(t *testing.T) {
+	defer func(n int) { SyncPagesPerRun = n }(SyncPagesPerRun)
+	mockAPI := &mockGmailAPI{
+		pages: map[string]*gmail.ListMessagesResponse{
+			"":   {Messages: []*gmail.Message{{Id: "id1"}}, NextPageToken: "p2"},
+			"p2": {Messages: []*gmail.Message{{Id: "id2"}}, NextPageToken: "p3"},
+			"p3": {Messages: []*gmail.Message{{Id: "id3"}}},
+		},
+		msgMap: map[string]*gmail.Message{
+			"id1": {Id: "id1", HistoryId: 10, Payload: &gmail.MessagePart{}},
+			"id2": {Id: "id2", HistoryId: 9, Payload: &gmail.MessagePart{}},
+			"id3": {Id: "id3", HistoryId: 8, Payload: &gmail.MessagePart{}},
+		},
+	}
+	tok := &oauth2.Token{AccessToken: "dummy"}
+
+	// By default a run caches the newest page only and never stores a cursor
+	repo, state := &fakeUpsertRepo{cached: map[string]bool{}}, &fakeSyncState{}
+	svc := NewGmailService(repo, mockAPI)
+	svc.SyncState = state
+	if err := svc.syncLatestSummariesFromGmail(context.Background(), tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(repo.cached) != 1 || strings.Join(state.pageTokens, ",") != "" || state.state.HistoryID != 10 {
+		t.Errorf("expected the first page only, got %v with cursors %q", repo.cached, state.pageTokens)
+	}
+
+	// Raised, the cursor advances page by page and is reset once the run ends
+	SyncPagesPerRun = 3
+	repo, state = &fakeUpsertRepo{cached: map[string]bool{}}, &fakeSyncState{}
+	svc = NewGmailService(repo, mockAPI)
+	svc.SyncState = state
+	if err := svc.syncLatestSummariesFromGmail(context.Background(), tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(repo.cached) != 3 || strings.Join(state.pageTokens, ",") != "p2,p3," {
+		t.Errorf("expected every page walked, got %v with cursors %q", repo.cached, state.pageTokens)
+	}
+}
+
+func TestGmailService_syncCommitsPagesAtomically(t *testing.T) {
+	defer func(n int) { SyncPagesPerRun = n }(SyncPagesPerRun)
+	SyncPagesPerRun = 2
+	repo := &fakeUpsertRepo{cached: map[string]bool{}}
+	state := &fakeSyncState{}
+	tx := &fakeTx{repo: repo, state: state, failOn: "id3"}
+	msg := func(id string, history uint64) *gmail.Message {
+		return &gmail.Message{Id: id, HistoryId: history, Payload: &gmail.MessagePart{}}
+	}
+	mockAPI := &mockGmailAPI{
+		pages: map[string]*gmail.ListMessagesResponse{
+			"":   {Messages: []*gmail.Message{{Id: "id1"}, {Id: "id2"}}, NextPageToken: "p2"},
+			"p2": {Messages: []*gmail.Message{{Id: "id3"}}, NextPageToken: "p3"},
+		},
+		msgMap: map[string]*gmail.Message{"id1": msg("id1", 10), "id2": msg("id2", 9), "id3": msg("id3", 8)},
+	}
+	svc := NewGmailService(repo, mockAPI)
+	svc.SyncState = state
+	svc.Tx = tx
+	ctx := context.Background()
+	tok := &oauth2.Token{AccessToken: "dummy"}
+
+	// The second page fails to write: the first stays committed with the cursor pointing at
+	// the second, and nothing of the second is written
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err == nil {
+		t.Fatal("expected the failed page to fail the sync")
+	}
+	if tx.commits != 1 || repo.upsertCount != 2 || repo.cached["id3"] {
+		t.Errorf("expected only the first page to be written, got %d commits, %v", tx.commits, repo.cached)
+	}
+	if state.state.PageToken != "p2" || state.state.HistoryID != 10 {
+		t.Errorf("expected the cursor at p2 after history 10, got %+v", state.state)
+	}
+
+	// The retry resumes at the failed page and, as the last page of the run, resets the cursor
+	tx.failOn = ""
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !repo.cached["id3"] || state.state.PageToken != "" || state.state.HistoryID != 10 {
+		t.Errorf("expected id3 written and the cursor reset, got %v / %+v", repo.cached, state.state)
+	}
+}
+
+func TestGmailService_FetchMessagesColdCache(t *testing.T) {
+	state := &fakeSyncState{}
+	svc := NewGmailService(&fakeUpsertRepo{}, &mockGmailAPI{})
+	svc.SyncState = state
+	ctx := ctxkeys.WithUserID(context.Background(), "user1")
+	tok := &oauth2.Token{AccessToken: "dummy"}
+	// A sync is already running, so no new one is started
+	svc.inFlight.Store("user1", make(chan struct{}))
+
+	msgs, err := svc.FetchMessages(ctx, tok)
+	if !errors.Is(err, provider.ErrSyncing) || provider.RetryAfter(err) != ColdCacheRetryAfter || msgs != nil {
+		t.Fatalf("expected a cold cache to report syncing, got %v, %v", msgs, err)
+	}
+
+	// Once a page has been committed an empty cache is an empty inbox
+	_ = state.SaveSyncState(ctx, &models.SyncState{UserID: "user1", UpdatedAt: time.Now()})
+	if msgs, err := svc.FetchMessages(ctx, tok); err != nil || len(msgs) != 0 {
+		t.Errorf("expected an empty inbox, got %v, %v", msgs, err)
+	}
+
+	// Without a sync running there is nothing to wait for
+	svc.inFlight.Delete("user1")
+	state.state = nil
+	if _, err := svc.FetchMessages(ctx, nil); err != nil {
+		t.Errorf("expected no syncing error without a sync, got %v", err)
+	}
+}
+
+func TestGmailService_OneSyncPerUser(t *testing.T) {
+	svc := NewGmailService(&fakeUpsertRepo{}, &mockGmailAPI{})
+	ctx := ctxkeys.WithUserID(context.Background(), "user1")
+	tok := &oauth2.Token{AccessToken: "dummy"}
+
+	// A page load while a sync runs does not start another
+	running := make(chan struct{})
+	svc.inFlight.Store("user1", running)
+	if !svc.startBackgroundSync(ctx, tok, "user1") {
+		t.Error("expected the running sync to be reported")
+	}
+	if v, _ := svc.inFlight.Load("user1"); v != running {
+		t.Error("expected the running sync to keep the slot")
+	}
+
+	// A scheduled sync waits for it instead of being dropped
+	synced := make(chan error, 1)
+	go func() { synced <- svc.SyncUser(context.Background(), "user1", tok) }()
+	select {
+	case <-synced:
+		t.Fatal("expected SyncUser to wait for the running sync")
+	case <-time.After(20 * time.Millisecond):
+	}
+	svc.finishSync("user1", running)
+	select {
+	case <-synced:
+	case <-time.After(time.Second):
+		t.Fatal("expected SyncUser to run once the other sync finished")
+	}
+	if _, running := svc.inFlight.Load("user1"); running {
+		t.Error("expected SyncUser to release the slot")
+	}
+
+	// Right after a sync, page loads are served from the cache
+	if svc.startBackgroundSync(ctx, tok, "user1") {
+		t.Error("expected no sync within the cooldown")
+	}
+	var out strings.Builder
+	metrics.Write(&out)
+	for _, reason := range []string{"in_flight", "cooldown"} {
+		if !strings.Contains(out.String(), `inbox_whisperer_syncs_suppressed_total{provider="gmail",reason="`+reason+`"}`) {
+			t.Errorf("expected a suppressed sync counted as %s", reason)
+		}
+	}
+}
+
+type fakeSettings struct {
+	disableLocalCache bool
+	metadataOnly      bool
+}
+
+func (f fakeSettings) Get(ctx context.Context, userID string) (*models.UserSettings, error) {
+	return &models.UserSettings{UserID: userID, DisableLocalCache: f.disableLocalCache, MetadataOnlyCache: f.metadataOnly}, nil
+}
+func (f fakeSettings) Upsert(ctx context.Context, settings *models.UserSettings) error { return nil }
+
+func TestGmailService_ListPassthroughWritesNothing(t *testing.T) {
+	repo := &fakeUpsertRepo{cached: map[string]bool{}}
+	cat := &fakeCategorizer{}
+	state := &fakeSyncState{}
+	mockAPI := &mockGmailAPI{
+		pages: map[string]*gmail.ListMessagesResponse{
+			"":   {Messages: []*gmail.Message{{Id: "id1"}}, NextPageToken: "p2"},
+			"p2": {Messages: []*gmail.Message{{Id: "id2"}}},
+		},
+		msgMap: map[string]*gmail.Message{
+			"id1": {Id: "id1", Payload: &gmail.MessagePart{}, LabelIds: []string{"INBOX", "IMPORTANT"}},
+			"id2": {Id: "id2", Payload: &gmail.MessagePart{}},
+		},
+	}
+	svc := NewGmailService(repo, mockAPI)
+	svc.Categorizer = cat
+	svc.SyncState = state
+	svc.Settings = fakeSettings{disableLocalCache: true}
+	ctx := ctxkeys.WithUserID(context.Background(), "user1")
+	tok := &oauth2.Token{AccessToken: "dummy"}
+
+	msgs, next, err := svc.ListPassthrough(ctx, tok, "", 10)
+	if err != nil || len(msgs) != 1 || msgs[0].EmailMessageID != "id1" || next != "p2" {
+		t.Fatalf("first page: got %v, %q, %v", msgs, next, err)
+	}
+	if !msgs[0].ProviderImportant {
+		t.Errorf("expected Gmail's own signals on passthrough summaries, got %+v", msgs[0])
+	}
+	msgs, next, err = svc.ListPassthrough(ctx, tok, "p2", 10)
+	if err != nil || len(msgs) != 1 || msgs[0].EmailMessageID != "id2" || next != "" {
+		t.Fatalf("second page: got %v, %q, %v", msgs, next, err)
+	}
+
+	// Syncs and content fetches leave the cache alone for users who opted out
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected the sync to be skipped, got %v", err)
+	}
+	mockAPI.msg = mockAPI.msgMap["id1"]
+	if _, err := svc.FetchMessageContent(ctx, tok, "id1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.upsertCount != 0 || cat.calls != 0 || state.state != nil {
+		t.Errorf("expected nothing written or categorized, got %d upserts, %d categorizations, state %+v", repo.upsertCount, cat.calls, state.state)
+	}
+}
+
+func TestGmailService_BackfillPage(t *testing.T) {
+	repo := &fakeUpsertRepo{cached: map[string]bool{"id1": true}}
+	state := &fakeSyncState{}
+	mockAPI := &mockGmailAPI{
+		pages: map[string]*gmail.ListMessagesResponse{
+			"":   {Messages: []*gmail.Message{{Id: "id1"}, {Id: "id2"}}, NextPageToken: "p2"},
+			"p2": {Messages: []*gmail.Message{{Id: "id3"}}},
+		},
+		msgMap: map[string]*gmail.Message{
+			"id1": {Id: "id1", Payload: &gmail.MessagePart{}},
+			"id2": {Id: "id2", Payload: &gmail.MessagePart{}},
+			"id3": {Id: "id3", Payload: &gmail.MessagePart{}},
+		},
+	}
+	svc := NewGmailService(repo, mockAPI)
+	svc.SyncState = state
+	tok := &oauth2.Token{AccessToken: "dummy"}
+
+	next, cached, err := svc.BackfillPage(context.Background(), "user1", tok, "")
+	if err != nil || next != "p2" || cached != 1 || repo.upsertCount != 1 {
+		t.Fatalf("expected only the uncached message written, got %q, %d, %d upserts (%v)", next, cached, repo.upsertCount, err)
+	}
+	next, cached, err = svc.BackfillPage(context.Background(), "user1", tok, "p2")
+	if err != nil || next != "" || cached != 1 || !repo.cached["id3"] {
+		t.Fatalf("expected the last page, got %q, %d (%v)", next, cached, err)
+	}
+	if state.state != nil {
+		t.Errorf("expected the sync cursor left alone, got %+v", state.state)
+	}
+
+	svc.Settings = fakeSettings{disableLocalCache: true}
+	if next, cached, err := svc.BackfillPage(context.Background(), "user1", tok, ""); err != nil || next != "" || cached != 0 {
+		t.Errorf("expected nothing cached for users who opted out, got %q, %d (%v)", next, cached, err)
+	}
+}
+
+func TestGmailService_metadataOnlyCaching(t *testing.T) {
+	repo := &fakeUpsertRepo{cached: map[string]bool{}}
+	body := base64.RawURLEncoding.EncodeToString([]byte("secret body"))
+	mockAPI := &mockGmailAPI{
+		listResp: &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "id1"}}},
+		msgMap: map[string]*gmail.Message{"id1": {Id: "id1", Snippet: "hi", Payload: &gmail.MessagePart{
+			Headers: []*gmail.MessagePartHeader{{Name: "Subject", Value: "Hello"}},
+			Body:    &gmail.MessagePartBody{Data: body},
+		}}},
+	}
+	svc := NewGmailService(repo, mockAPI)
+	svc.Settings = fakeSettings{metadataOnly: true}
+	ctx := ctxkeys.WithUserID(context.Background(), "user1")
+	tok := &oauth2.Token{AccessToken: "dummy"}
+
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	stored := repo.stored["id1"]
+	if stored.Subject != "Hello" || stored.Snippet != "hi" || stored.RawJSON != nil || stored.Body != "" {
+		t.Errorf("expected only metadata cached, got %+v", stored)
+	}
+
+	// The cached copy is fresh but has no body, so the body comes from Gmail
+	msg, err := svc.FetchMessageContent(ctx, tok, "id1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if msg.Body != "secret body" {
+		t.Errorf("expected the body from Gmail, got %q", msg.Body)
+	}
+}
+
+type fakeEncryptionKeys struct {
+	key *models.EncryptionKey
+}
+
+func (f fakeEncryptionKeys) Get(ctx context.Context, userID string) (*models.EncryptionKey, error) {
+	if f.key == nil {
+		return nil, data.ErrNotFound
+	}
+	return f.key, nil
+}
+func (f fakeEncryptionKeys) Create(ctx context.Context, key *models.EncryptionKey) error { return nil }
+func (f fakeEncryptionKeys) Delete(ctx context.Context, userID string) error             { return nil }
+
+func TestGmailService_encryptedCache(t *testing.T) {
+	salt, public, private, err := e2ee.NewKey("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &fakeUpsertRepo{cached: map[string]bool{}}
+	body := base64.RawURLEncoding.EncodeToString([]byte("gmail body"))
+	mockAPI := &mockGmailAPI{
+		listResp: &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "id1"}}},
+		msgMap: map[string]*gmail.Message{"id1": {Id: "id1", Snippet: "quoted body", Payload: &gmail.MessagePart{
+			Headers: []*gmail.MessagePartHeader{{Name: "Subject", Value: "Hello"}},
+			Body:    &gmail.MessagePartBody{Data: body},
+		}}},
+	}
+	svc := NewGmailService(repo, mockAPI)
+	svc.EncryptionKeys = fakeEncryptionKeys{key: &models.EncryptionKey{UserID: "user1", Salt: salt, PublicKey: public}}
+	ctx := ctxkeys.WithUserID(context.Background(), "user1")
+	tok := &oauth2.Token{AccessToken: "dummy"}
+
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stored := repo.stored["id1"]; stored.Subject != "Hello" || stored.Snippet != "" || stored.RawJSON != nil {
+		t.Errorf("expected summaries without content, got %+v", stored)
+	}
+
+	sealed, err := sealContent(&models.EmailMessage{EmailMessageID: "id1", Body: "cached body", Snippet: "quoted", CachedAt: time.Now()}, public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e2ee.IsSealed(sealed.Body) || sealed.Snippet != "" {
+		t.Fatalf("expected a sealed body and no snippet, got %+v", sealed)
+	}
+	repo.stored["id1"] = *sealed
+
+	msg, err := svc.FetchMessageContent(ctxkeys.WithEncryptionKey(ctx, private), tok, "id1")
+	if err != nil || msg.Body != "cached body" {
+		t.Errorf("expected the opened cached body, got %+v, %v", msg, err)
+	}
+	// A locked session cannot read the cache and gets the body from Gmail
+	msg, err = svc.FetchMessageContent(ctx, tok, "id1")
+	if err != nil || msg.Body != "gmail body" {
+		t.Errorf("expected the body from Gmail, got %+v, %v", msg, err)
+	}
+}
+
+func TestGmailService_dropRawJSONKeepsHeaders(t *testing.T) {
+	repo := &fakeUpsertRepo{cached: map[string]bool{}}
+	mockAPI := &mockGmailAPI{
+		listResp: &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "id1"}}},
+		msgMap: map[string]*gmail.Message{"id1": {Id: "id1", Payload: &gmail.MessagePart{
+			Headers: []*gmail.MessagePartHeader{{Name: "List-Unsubscribe", Value: "<mailto:u@example.com>"}},
+		}}},
+	}
+	svc := NewGmailService(repo, mockAPI)
+	svc.DropRawJSON = true
+
+	if err := svc.syncLatestSummariesFromGmail(context.Background(), &oauth2.Token{AccessToken: "dummy"}, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	stored := repo.stored["id1"]
+	if stored.RawJSON != nil {
+		t.Errorf("expected no raw payload cached, got %s", stored.RawJSON)
+	}
+	if got := stored.Header("list-unsubscribe"); got != "<mailto:u@example.com>" {
+		t.Errorf("expected the header to survive, got %q", got)
+	}
+}
+
+func TestGmailService_syncIncrementalFromHistory(t *testing.T) {
+	repo := &fakeUpsertRepo{cached: map[string]bool{}}
+	state := &fakeSyncState{}
+	msg := func(id string, history uint64) *gmail.Message {
+		return &gmail.Message{Id: id, HistoryId: history, Payload: &gmail.MessagePart{}}
+	}
+	mockAPI := &mockGmailAPI{
+		listResp: &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "id1"}, {Id: "id2"}}},
+		msgMap:   map[string]*gmail.Message{"id1": msg("id1", 10), "id2": msg("id2", 9), "id3": msg("id3", 12)},
+	}
+	trashed := msg("trashed", 11)
+	trashed.LabelIds = []string{"TRASH"}
+	mockAPI.msgMap["trashed"] = trashed
+	svc := NewGmailService(repo, mockAPI)
+	svc.SyncState = state
+	ctx := context.Background()
+	tok := &oauth2.Token{AccessToken: "dummy"}
+
+	// Without a history ID the first run walks the list
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if mockAPI.listCalls != 1 || len(mockAPI.historyStart) != 0 || state.state.HistoryID != 10 {
+		t.Fatalf("expected a full walk up to history 10, got %d lists, %v / %+v", mockAPI.listCalls, mockAPI.historyStart, state.state)
+	}
+
+	// Later runs fetch only what changed since, once each, and drop deleted and trashed messages
+	// from the cache
+	repo.cached["deleted"], repo.cached["trashed"] = true, true
+	mockAPI.history = map[string]*gmail.ListHistoryResponse{
+		"": {History: []*gmail.History{
+			{Messages: []*gmail.Message{{Id: "id3"}}},
+			{Messages: []*gmail.Message{{Id: "id3"}, {Id: "deleted"}, {Id: "trashed"}}},
+		}, NextPageToken: "h2", HistoryId: 13},
+		"h2": {History: []*gmail.History{{Messages: []*gmail.Message{{Id: "id1"}}}}, HistoryId: 14},
+	}
+	before := repo.upsertCount
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if mockAPI.listCalls != 1 || len(mockAPI.historyStart) != 2 || mockAPI.historyStart[0] != 10 {
+		t.Errorf("expected two history pages from 10 and no list, got %d lists, %v", mockAPI.listCalls, mockAPI.historyStart)
+	}
+	if repo.upsertCount-before != 2 || !repo.cached["id3"] || state.state.HistoryID != 14 {
+		t.Errorf("expected id3 and id1 written and history 14, got %d / %v / %+v", repo.upsertCount-before, repo.cached, state.state)
+	}
+	if repo.cached["deleted"] || repo.cached["trashed"] || len(repo.deleted) != 2 {
+		t.Errorf("expected the deleted and trashed messages removed from the cache, deleted %v", repo.deleted)
+	}
+
+	// History Gmail no longer keeps falls back to a full walk
+	mockAPI.history = nil
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if mockAPI.listCalls != 2 {
+		t.Errorf("expected a full walk after expired history, got %d lists", mockAPI.listCalls)
+	}
+}
+
+func TestGmailService_syncHistoryDropsDeletedMessagesWithTheHistoryID(t *testing.T) {
+	repo := &fakeUpsertRepo{cached: map[string]bool{"gone": true}}
+	state := &fakeSyncState{state: &models.SyncState{UserID: "user1", HistoryID: 10}}
+	tx := &fakeTx{repo: repo, state: state, failOn: "id1"}
+	mockAPI := &mockGmailAPI{
+		msgMap: map[string]*gmail.Message{"id1": {Id: "id1", HistoryId: 11, Payload: &gmail.MessagePart{}}},
+		history: map[string]*gmail.ListHistoryResponse{
+			"": {History: []*gmail.History{{Messages: []*gmail.Message{{Id: "id1"}, {Id: "gone"}}}}, HistoryId: 12},
+		},
+	}
+	svc := NewGmailService(repo, mockAPI)
+	svc.SyncState, svc.Tx = state, tx
+	ctx := context.Background()
+	tok := &oauth2.Token{AccessToken: "dummy"}
+
+	// A failed write keeps the cached copy and the history ID, so the retry sees the deletion again
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err == nil {
+		t.Fatal("expected the failed write to fail the sync")
+	}
+	if !repo.cached["gone"] || state.state.HistoryID != 10 {
+		t.Errorf("expected nothing removed and history 10 kept, got %v / %+v", repo.cached, state.state)
+	}
+
+	tx.failOn = ""
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if repo.cached["gone"] || !repo.cached["id1"] || state.state.HistoryID != 12 {
+		t.Errorf("expected gone removed with history 12, got %v / %+v", repo.cached, state.state)
+	}
+}
+
+func TestGmailService_syncResolvesEarlierFailures(t *testing.T) {
+	repo := &fakeUpsertRepo{cached: map[string]bool{"gone": true}}
+	state := &fakeSyncState{state: &models.SyncState{UserID: "user1", HistoryID: 10}}
+	failed := &fakeFailedItems{}
+	tx := &fakeTx{repo: repo, state: state, failed: failed, failOn: "id1"}
+	mockAPI := &mockGmailAPI{
+		msgMap: map[string]*gmail.Message{"id1": {Id: "id1", HistoryId: 11, Payload: &gmail.MessagePart{}}},
+		history: map[string]*gmail.ListHistoryResponse{
+			"": {History: []*gmail.History{{Messages: []*gmail.Message{{Id: "id1"}, {Id: "gone"}}}}, HistoryId: 12},
+		},
+	}
+	svc := NewGmailService(repo, mockAPI)
+	svc.SyncState, svc.Tx, svc.FailedItems = state, tx, failed
+	ctx := context.Background()
+	tok := &oauth2.Token{AccessToken: "dummy"}
+
+	// Failures are resolved in the transaction that writes the page, so a failed write keeps them
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err == nil {
+		t.Fatal("expected the failed write to fail the sync")
+	}
+	if len(failed.resolved) != 0 {
+		t.Errorf("expected nothing resolved after a failed write, got %v", failed.resolved)
+	}
+	tx.failOn = ""
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(failed.resolved) != 2 || failed.resolved[0] != "id1" || failed.resolved[1] != "gone" {
+		t.Errorf("expected the written and deleted messages resolved, got %v", failed.resolved)
+	}
+
+	// Without transactions the written messages are resolved after their upserts
+	failed = &fakeFailedItems{}
+	svc = NewGmailService(&fakeUpsertRepo{cached: map[string]bool{}}, &mockGmailAPI{
+		listResp: &gmail.ListMessagesResponse{Messages: []*gmail.Message{{Id: "id1"}}},
+		msgMap:   map[string]*gmail.Message{"id1": {Id: "id1", Payload: &gmail.MessagePart{}}},
+	})
+	svc.FailedItems = failed
+	if err := svc.syncLatestSummariesFromGmail(ctx, tok, "user1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(failed.resolved) != 1 || failed.resolved[0] != "id1" {
+		t.Errorf("expected id1 resolved, got %v", failed.resolved)
+	}
+}
@@ -6,13 +6,15 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
 	"github.com/desponda/inbox-whisperer/internal/models"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/desponda/inbox-whisperer/internal/data"
 
-	"github.com/desponda/inbox-whisperer/internal/session"
 	"golang.org/x/oauth2"
 	"google.golang.org/api/gmail/v1"
 	// "github.com/golang/mock/gomock"
@@ -25,7 +27,6 @@ func mockToken() *oauth2.Token {
 
 func TestGmailService_CachingE2E(t *testing.T) {
 
-	debug := func(msg string, args ...interface{}) { t.Logf("[REPO_DEBUG] "+msg, args...) }
 	t.Log("[DEBUG] TestGmailService_CachingE2E: starting")
 	db, cleanup := data.SetupTestDB(t)
 	t.Log("[DEBUG] SetupTestDB done")
@@ -40,9 +41,7 @@ func TestGmailService_CachingE2E(t *testing.T) {
 	// Simulate session context using handler+middleware pattern
 	// For service tests, create a context with the userID manually (since session.SetSession is for HTTP)
 	tok := mockToken()
-	testCtx := session.ContextWithUserID(ctx, userID)
-	type testCtxKey string
-	testCtx = context.WithValue(testCtx, testCtxKey("_test_debug"), debug)
+	testCtx := ctxkeys.WithUserID(ctx, userID)
 
 	msgID := "gmail_msg_123"
 	msg := &models.EmailMessage{
@@ -133,6 +132,75 @@ func TestExtractPlainTextBody(t *testing.T) {
 	if got := extractPlainTextBody(empty); got != "" {
 		t.Errorf("expected empty for no plain text, got %q", got)
 	}
+
+	// Nested multipart with a Latin-1 text part, and an attached text file that is not the body
+	nested := &gmail.MessagePart{
+		MimeType: "multipart/mixed",
+		Parts: []*gmail.MessagePart{
+			{
+				MimeType: "multipart/alternative",
+				Parts: []*gmail.MessagePart{{
+					MimeType: "text/plain",
+					Headers:  []*gmail.MessagePartHeader{{Name: "Content-Type", Value: `text/plain; charset="ISO-8859-1"`}},
+					Body:     &gmail.MessagePartBody{Data: base64.RawURLEncoding.EncodeToString([]byte("caf\xe9"))},
+				}},
+			},
+			{
+				MimeType: "text/plain",
+				Filename: "notes.txt",
+				Body:     &gmail.MessagePartBody{Data: encoded2},
+			},
+		},
+	}
+	if got := extractPlainTextBody(nested); got != "café" {
+		t.Errorf("expected nested Latin-1 part as UTF-8, got %q", got)
+	}
+	// HTML-only: the HTML is not mistaken for the plain text body
+	htmlOnly := &gmail.MessagePart{
+		MimeType: "text/html",
+		Body:     &gmail.MessagePartBody{Data: base64.RawURLEncoding.EncodeToString([]byte("<b>HTML</b>"))},
+	}
+	if got := extractPlainTextBody(htmlOnly); got != "" {
+		t.Errorf("expected no plain text for an HTML-only message, got %q", got)
+	}
+	if got := extractHTMLBody(htmlOnly); got != "<b>HTML</b>" {
+		t.Errorf("expected HTML body, got %q", got)
+	}
+}
+
+// BenchmarkExtractBodies extracts both bodies of a newsletter-sized multipart message with an
+// attachment, as each synced message does
+func BenchmarkExtractBodies(b *testing.B) {
+	plain := strings.Repeat("Weekly roundup: the stories you missed this week, with links. ", 200)
+	html := strings.Repeat(`<tr><td style="padding:8px"><a href="https://example.com/story">Story</a> <p>Summary of the story.</p></td></tr>`, 200)
+	payload := &gmail.MessagePart{
+		MimeType: "multipart/mixed",
+		Parts: []*gmail.MessagePart{
+			{
+				MimeType: "multipart/alternative",
+				Parts: []*gmail.MessagePart{
+					{
+						MimeType: "text/plain",
+						Headers:  []*gmail.MessagePartHeader{{Name: "Content-Type", Value: `text/plain; charset="UTF-8"`}},
+						Body:     &gmail.MessagePartBody{Data: base64.RawURLEncoding.EncodeToString([]byte(plain))},
+					},
+					{
+						MimeType: "text/html",
+						Headers:  []*gmail.MessagePartHeader{{Name: "Content-Type", Value: `text/html; charset="UTF-8"`}},
+						Body:     &gmail.MessagePartBody{Data: base64.RawURLEncoding.EncodeToString([]byte(html))},
+					},
+				},
+			},
+			{MimeType: "application/pdf", Filename: "issue.pdf", Body: &gmail.MessagePartBody{AttachmentId: "att1"}},
+		},
+	}
+	b.SetBytes(int64(len(plain) + len(html)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if extractPlainTextBody(payload) == "" || extractHTMLBody(payload) == "" {
+			b.Fatal("expected both bodies")
+		}
+	}
 }
 
 func TestGetHeader(t *testing.T) {
@@ -156,6 +224,64 @@ func TestGetHeader(t *testing.T) {
 	}
 }
 
+func TestSummaryMessage_ParsesSender(t *testing.T) {
+	cases := []struct {
+		from, address, name string
+	}{
+		{`"Lee, Ann" <Ann.Lee@Example.com>`, "ann.lee@example.com", "Lee, Ann"},
+		{"=?UTF-8?q?J=C3=BCrgen?= <jurgen@example.de>", "jurgen@example.de", "Jürgen"},
+		{"billing@example.com", "billing@example.com", ""},
+		{"Not an address", "not an address", ""},
+		{"", "", ""},
+	}
+	for _, tc := range cases {
+		msg := summaryMessage("user1", &gmail.Message{Id: "m1", Payload: &gmail.MessagePart{
+			Headers: []*gmail.MessagePartHeader{{Name: "From", Value: tc.from}},
+		}})
+		if msg.Sender != tc.from || msg.SenderAddress != tc.address || msg.SenderName != tc.name {
+			t.Errorf("From %q: got address %q and name %q, want %q and %q", tc.from, msg.SenderAddress, msg.SenderName, tc.address, tc.name)
+		}
+	}
+}
+
+func TestSummaryMessage_ParsesRecipients(t *testing.T) {
+	msg := summaryMessage("user1", &gmail.Message{Id: "m1", Payload: &gmail.MessagePart{
+		Headers: []*gmail.MessagePartHeader{
+			{Name: "From", Value: "Ann <ann@example.com>"},
+			{Name: "Cc", Value: "Bob <Bob@example.com>, carol@example.com"},
+			{Name: "Reply-To", Value: "support@example.com"},
+		},
+	}})
+	want := []models.EmailAddress{{Name: "Bob", Address: "bob@example.com"}, {Address: "carol@example.com"}}
+	if !reflect.DeepEqual(msg.Cc, want) || msg.Bcc != nil {
+		t.Errorf("expected Cc %+v and no Bcc, got %+v and %+v", want, msg.Cc, msg.Bcc)
+	}
+	if got := msg.ReplyRecipients(); len(got) != 1 || got[0].Address != "support@example.com" {
+		t.Errorf("expected replies to go to Reply-To, got %+v", got)
+	}
+	msg.ReplyTo = nil
+	if got := msg.ReplyRecipients(); len(got) != 1 || got[0] != (models.EmailAddress{Name: "Ann", Address: "ann@example.com"}) {
+		t.Errorf("expected replies to go to the sender without Reply-To, got %+v", got)
+	}
+}
+
+func TestSummaryMessage_SizeAndAttachments(t *testing.T) {
+	plain := &gmail.Message{Id: "m1", SizeEstimate: 2048, Payload: &gmail.MessagePart{MimeType: "multipart/alternative", Parts: []*gmail.MessagePart{
+		{MimeType: "text/plain"}, {MimeType: "text/html"},
+	}}}
+	// Attachments sit in nested parts: multipart/mixed around the alternative bodies
+	withFile := &gmail.Message{Id: "m2", Payload: &gmail.MessagePart{MimeType: "multipart/mixed", Parts: []*gmail.MessagePart{
+		{MimeType: "multipart/alternative", Parts: []*gmail.MessagePart{{MimeType: "text/plain"}}},
+		{MimeType: "application/pdf", Filename: "invoice.pdf", Body: &gmail.MessagePartBody{AttachmentId: "a1"}},
+	}}}
+	if msg := summaryMessage("user1", plain); msg.SizeEstimate != 2048 || msg.HasAttachments {
+		t.Errorf("expected 2048 bytes and no attachments, got %d and %v", msg.SizeEstimate, msg.HasAttachments)
+	}
+	if msg := summaryMessage("user1", withFile); !msg.HasAttachments {
+		t.Error("expected the nested PDF to count as an attachment")
+	}
+}
+
 func TestGmailService_FetchMessages_Pagination(t *testing.T) {
 	db, cleanup := data.SetupTestDB(t)
 	defer cleanup()
@@ -165,7 +291,7 @@ func TestGmailService_FetchMessages_Pagination(t *testing.T) {
 	ctx := context.Background()
 	userID := "user-pagination"
 	tok := mockToken()
-	testCtx := session.ContextWithUserID(ctx, userID)
+	testCtx := ctxkeys.WithUserID(ctx, userID)
 
 	// Insert 15 messages with descending InternalDate (newest first)
 	var now = time.Now().Unix()
@@ -230,8 +356,7 @@ func TestGmailService_FetchMessages_Pagination(t *testing.T) {
 			HistoryId:    uint64(m.HistoryID),
 		})
 	}
-	ctx2 := context.WithValue(testCtx, CtxKeyAfterID{}, last.EmailMessageID)
-	ctx2 = context.WithValue(ctx2, CtxKeyAfterInternalDate{}, last.InternalDate)
+	ctx2 := ctxkeys.WithCursor(testCtx, ctxkeys.Cursor{AfterID: last.EmailMessageID, AfterInternalDate: last.InternalDate})
 	err = svc.syncLatestSummariesFromGmail(ctx2, tok, "user1")
 	if err != nil {
 		t.Fatalf("syncLatestSummariesFromGmail page 2 failed: %v", err)
@@ -240,8 +365,7 @@ func TestGmailService_FetchMessages_Pagination(t *testing.T) {
 	// Third page: no messages
 	mockAPI.listResp = &gmail.ListMessagesResponse{Messages: []*gmail.Message{}}
 	last2 := allMsgs[14]
-	ctx3 := context.WithValue(testCtx, CtxKeyAfterID{}, last2.EmailMessageID)
-	ctx3 = context.WithValue(ctx3, CtxKeyAfterInternalDate{}, last2.InternalDate)
+	ctx3 := ctxkeys.WithCursor(testCtx, ctxkeys.Cursor{AfterID: last2.EmailMessageID, AfterInternalDate: last2.InternalDate})
 	msgs3, err := svc.FetchMessages(ctx3, tok)
 	if err != nil {
 		t.Fatalf("FetchMessages page 3 failed: %v", err)
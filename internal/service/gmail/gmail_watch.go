@@ -0,0 +1,25 @@
+package gmail
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/gmail/v1"
+)
+
+// Watch asks Gmail to publish changes to the token's inbox to a Pub/Sub topic, replacing any
+// earlier watch, and returns the mailbox's current history ID and when the watch expires.
+// Watches last about a week and must be renewed before then.
+func (s *GmailService) Watch(ctx context.Context, token *oauth2.Token, topic string) (uint64, time.Time, error) {
+	client, err := s.getGmailClient(ctx, token)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	req := &gmail.WatchRequest{TopicName: topic, LabelIds: []string{"INBOX"}, LabelFilterBehavior: "include"}
+	resp, err := doCall("watch", client.Users.Watch("me", req).Do)
+	if err != nil {
+		return 0, time.Time{}, classifyError(err)
+	}
+	return resp.HistoryId, time.UnixMilli(resp.Expiration).UTC(), nil
+}
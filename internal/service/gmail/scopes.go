@@ -0,0 +1,53 @@
+package gmail
+
+import "github.com/desponda/inbox-whisperer/internal/service/provider"
+
+// Google OAuth scopes the app asks for
+const (
+	ScopeReadonly       = "https://www.googleapis.com/auth/gmail.readonly"
+	ScopeModify         = "https://www.googleapis.com/auth/gmail.modify"
+	ScopeSend           = "https://www.googleapis.com/auth/gmail.send"
+	ScopeFullMail       = "https://mail.google.com/"
+	ScopeCalendarEvents = "https://www.googleapis.com/auth/calendar.events"
+)
+
+// LoginScopes are requested on every sign-in; features needing more ask for it incrementally
+var LoginScopes = []string{ScopeReadonly, "openid", "profile", "email"}
+
+// FeatureScopes lists the scopes each feature needs
+var FeatureScopes = map[provider.Feature][]string{
+	provider.FeatureRead:     {ScopeReadonly},
+	provider.FeatureModify:   {ScopeModify},
+	provider.FeatureSend:     {ScopeSend},
+	provider.FeatureCalendar: {ScopeCalendarEvents},
+}
+
+// impliedBy lists the broader scopes that also grant a scope
+var impliedBy = map[string][]string{
+	ScopeReadonly: {ScopeModify, ScopeFullMail},
+	ScopeModify:   {ScopeFullMail},
+	ScopeSend:     {ScopeModify, ScopeFullMail},
+}
+
+// MissingScopes returns the scopes feature needs that granted does not cover, in the order
+// FeatureScopes lists them
+func MissingScopes(feature provider.Feature, granted []string) []string {
+	have := make(map[string]bool, len(granted))
+	for _, s := range granted {
+		have[s] = true
+	}
+	var missing []string
+	for _, s := range FeatureScopes[feature] {
+		if have[s] {
+			continue
+		}
+		covered := false
+		for _, broader := range impliedBy[s] {
+			covered = covered || have[broader]
+		}
+		if !covered {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
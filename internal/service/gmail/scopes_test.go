@@ -0,0 +1,32 @@
+package gmail
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+)
+
+func TestMissingScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		feature provider.Feature
+		granted []string
+		want    []string
+	}{
+		{"login scopes read", provider.FeatureRead, LoginScopes, nil},
+		{"login scopes cannot modify", provider.FeatureModify, LoginScopes, []string{ScopeModify}},
+		{"modify", provider.FeatureModify, []string{ScopeModify}, nil},
+		{"modify implies read and send", provider.FeatureSend, []string{ScopeModify}, nil},
+		{"full mail access", provider.FeatureModify, []string{ScopeFullMail}, nil},
+		{"calendar is separate", provider.FeatureCalendar, []string{ScopeFullMail}, []string{ScopeCalendarEvents}},
+		{"nothing granted", provider.FeatureRead, nil, []string{ScopeReadonly}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MissingScopes(tt.feature, tt.granted); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MissingScopes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,387 @@
+package imap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxLiteral caps the size of one literal read from the server, so a broken or hostile server
+// cannot exhaust memory
+const maxLiteral = 32 << 20
+
+// errProtocol marks responses the client cannot make sense of
+var errProtocol = errors.New("imap: protocol error")
+
+// commandError is a NO or BAD completion of a command
+type commandError struct {
+	Command string
+	Status  string
+	Text    string
+}
+
+func (e *commandError) Error() string {
+	return fmt.Sprintf("imap: %s failed: %s %s", e.Command, e.Status, e.Text)
+}
+
+// line is one server response. Status responses (OK, NO, BAD, BYE, PREAUTH) keep their text;
+// other untagged responses are parsed into fields, with literals inlined as strings, lists as
+// []any and NIL as nil.
+type line struct {
+	tag    string
+	status string
+	text   string
+	fields []any
+}
+
+// client is a minimal IMAP4rev1 (RFC 3501) client: just enough to log in, open a mailbox
+// read-only and fetch messages by UID. It is not safe for concurrent use.
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// newClient reads the server greeting from conn
+func newClient(conn net.Conn) (*client, error) {
+	c := &client{conn: conn, r: bufio.NewReader(conn)}
+	greeting, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if greeting.tag != "*" || (greeting.status != "OK" && greeting.status != "PREAUTH") {
+		return nil, fmt.Errorf("%w: unexpected greeting %s %s", errProtocol, greeting.status, greeting.text)
+	}
+	return c, nil
+}
+
+// startTLS upgrades the connection; the server must offer STARTTLS
+func (c *client) startTLS(cfg *tls.Config) error {
+	if _, err := c.cmd("STARTTLS"); err != nil {
+		return err
+	}
+	conn := tls.Client(c.conn, cfg)
+	if err := conn.Handshake(); err != nil {
+		return err
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+	return nil
+}
+
+func (c *client) login(username, password string) error {
+	user, err := quote(username)
+	if err != nil {
+		return err
+	}
+	pass, err := quote(password)
+	if err != nil {
+		return err
+	}
+	_, err = c.cmd("LOGIN " + user + " " + pass)
+	return err
+}
+
+// examine opens a mailbox read-only and returns its UIDVALIDITY
+func (c *client) examine(mailbox string) (uint32, error) {
+	name, err := quote(mailbox)
+	if err != nil {
+		return 0, err
+	}
+	lines, err := c.cmd("EXAMINE " + name)
+	if err != nil {
+		return 0, err
+	}
+	for _, l := range lines {
+		if l.status == "OK" && strings.HasPrefix(strings.ToUpper(l.text), "[UIDVALIDITY ") {
+			code, _, _ := strings.Cut(l.text[len("[UIDVALIDITY "):], "]")
+			v, err := strconv.ParseUint(code, 10, 32)
+			if err != nil {
+				return 0, fmt.Errorf("%w: bad UIDVALIDITY %q", errProtocol, code)
+			}
+			return uint32(v), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: no UIDVALIDITY for %s", errProtocol, mailbox)
+}
+
+// uidSearch returns the UIDs matching criteria, in ascending order as servers send them
+func (c *client) uidSearch(criteria string) ([]uint32, error) {
+	lines, err := c.cmd("UID SEARCH " + criteria)
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, l := range lines {
+		if len(l.fields) == 0 || !strings.EqualFold(atom(l.fields[0]), "SEARCH") {
+			continue
+		}
+		for _, f := range l.fields[1:] {
+			uid, err := strconv.ParseUint(atom(f), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%w: bad UID %v", errProtocol, f)
+			}
+			uids = append(uids, uint32(uid))
+		}
+	}
+	return uids, nil
+}
+
+// uidFetch fetches items for the UIDs in set. Each result maps upper-cased item names to their
+// values; any BODY[...] section is keyed "BODY[]".
+func (c *client) uidFetch(set, items string) ([]map[string]any, error) {
+	lines, err := c.cmd("UID FETCH " + set + " (" + items + ")")
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]any
+	for _, l := range lines {
+		if len(l.fields) != 3 || !strings.EqualFold(atom(l.fields[1]), "FETCH") {
+			continue
+		}
+		list, ok := l.fields[2].([]any)
+		if !ok || len(list)%2 != 0 {
+			return nil, fmt.Errorf("%w: malformed FETCH response", errProtocol)
+		}
+		m := make(map[string]any, len(list)/2)
+		for i := 0; i < len(list); i += 2 {
+			key := strings.ToUpper(atom(list[i]))
+			if strings.HasPrefix(key, "BODY[") {
+				key = "BODY[]"
+			}
+			m[key] = list[i+1]
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// logout ends the session politely; errors do not matter since the connection is closed next
+func (c *client) logout() {
+	c.cmd("LOGOUT")
+}
+
+// cmd sends a command and reads responses up to its completion. It returns the untagged
+// responses, or a *commandError if the command did not complete with OK.
+func (c *client) cmd(command string) ([]*line, error) {
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	if _, err := io.WriteString(c.conn, tag+" "+command+"\r\n"); err != nil {
+		return nil, err
+	}
+	name, _, _ := strings.Cut(command, " ")
+	var untagged []*line
+	for {
+		l, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case l.tag == tag:
+			if l.status != "OK" {
+				return nil, &commandError{Command: name, Status: l.status, Text: l.text}
+			}
+			return untagged, nil
+		case l.tag == "*" && l.status == "BYE" && name != "LOGOUT":
+			return nil, &commandError{Command: name, Status: l.status, Text: l.text}
+		case l.tag == "*":
+			untagged = append(untagged, l)
+		default:
+			// Continuation requests are never expected: commands carry no literals
+			return nil, fmt.Errorf("%w: unexpected %q response to %s", errProtocol, l.tag, name)
+		}
+	}
+}
+
+// readLine reads one response
+func (c *client) readLine() (*line, error) {
+	tag, err := c.readAtom()
+	if err != nil {
+		return nil, err
+	}
+	l := &line{tag: tag}
+	if tag == "+" {
+		l.text, err = c.readRest()
+		return l, err
+	}
+	if err := c.expect(' '); err != nil {
+		return nil, err
+	}
+	first, err := c.readValue()
+	if err != nil {
+		return nil, err
+	}
+	switch s := strings.ToUpper(atom(first)); s {
+	case "OK", "NO", "BAD", "BYE", "PREAUTH":
+		l.status = s
+		l.text, err = c.readRest()
+		return l, err
+	}
+	l.fields = []any{first}
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch b {
+		case '\r':
+			return l, c.expect('\n')
+		case ' ':
+			v, err := c.readValue()
+			if err != nil {
+				return nil, err
+			}
+			l.fields = append(l.fields, v)
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q", errProtocol, b)
+		}
+	}
+}
+
+// readValue reads an atom, number, quoted string, literal, list or NIL
+func (c *client) readValue() (any, error) {
+	b, err := c.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch b {
+	case '(':
+		list := []any{}
+		for {
+			b, err := c.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			switch b {
+			case ')':
+				return list, nil
+			case ' ':
+				continue
+			}
+			if err := c.r.UnreadByte(); err != nil {
+				return nil, err
+			}
+			v, err := c.readValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+	case '"':
+		var sb strings.Builder
+		for {
+			b, err := c.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			switch b {
+			case '"':
+				return sb.String(), nil
+			case '\\':
+				if b, err = c.r.ReadByte(); err != nil {
+					return nil, err
+				}
+			case '\r', '\n':
+				return nil, fmt.Errorf("%w: line break in quoted string", errProtocol)
+			}
+			sb.WriteByte(b)
+		}
+	case '{':
+		size, err := c.r.ReadString('}')
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(size, "}"))
+		if err != nil || n < 0 || n > maxLiteral {
+			return nil, fmt.Errorf("%w: bad literal size %q", errProtocol, size)
+		}
+		if err := c.expect('\r'); err != nil {
+			return nil, err
+		}
+		if err := c.expect('\n'); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf), nil
+	}
+	if err := c.r.UnreadByte(); err != nil {
+		return nil, err
+	}
+	a, err := c.readAtom()
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(a, "NIL") {
+		return nil, nil
+	}
+	return a, nil
+}
+
+// readAtom reads up to a space, parenthesis or line end. Spaces and parentheses inside square
+// brackets are part of the atom, as in BODY[HEADER.FIELDS (FROM)].
+func (c *client) readAtom() (string, error) {
+	var sb strings.Builder
+	depth := 0
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if depth == 0 && (b == ' ' || b == '(' || b == ')' || b == '\r') {
+			if sb.Len() == 0 {
+				return "", fmt.Errorf("%w: empty atom", errProtocol)
+			}
+			return sb.String(), c.r.UnreadByte()
+		}
+		switch b {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '\n':
+			return "", fmt.Errorf("%w: bare line feed", errProtocol)
+		}
+		sb.WriteByte(b)
+	}
+}
+
+// readRest reads the remainder of the line, dropping a leading space and the CRLF
+func (c *client) readRest() (string, error) {
+	s, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(strings.TrimSuffix(s, "\r\n"), " "), nil
+}
+
+func (c *client) expect(want byte) error {
+	b, err := c.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b != want {
+		return fmt.Errorf("%w: expected %q, got %q", errProtocol, want, b)
+	}
+	return nil
+}
+
+// quote encodes s as an IMAP quoted string; line breaks cannot be sent that way
+func quote(s string) (string, error) {
+	if strings.ContainsAny(s, "\r\n\x00") {
+		return "", errors.New("imap: value contains a line break")
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`, nil
+}
+
+// atom returns v if it is a string, or "" otherwise
+func atom(v any) string {
+	s, _ := v.(string)
+	return s
+}
@@ -0,0 +1,312 @@
+// Package imap reads mailboxes on hosts other than Google and Microsoft over IMAP, logging in
+// with the username and password the user linked. Like Outlook, IMAP mailboxes are not synced
+// into the local cache: each request opens a session and reads the inbox read-only.
+package imap
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+)
+
+// ProviderName labels summaries served by this provider
+const ProviderName = "imap"
+
+// DefaultTimeout bounds one IMAP session when IMAPProvider.Timeout is not set
+const DefaultTimeout = 30 * time.Second
+
+const (
+	defaultLimit = 10
+	maxLimit     = 100
+	inbox        = "INBOX"
+	// idPrefix marks message IDs served by this provider; the rest is UIDVALIDITY and UID
+	idPrefix = "imap-"
+	// summaryItems are fetched for lists: the headers only, without marking anything read
+	summaryItems = "UID INTERNALDATE RFC822.SIZE FLAGS BODY.PEEK[HEADER.FIELDS (FROM TO CC REPLY-TO SUBJECT DATE MESSAGE-ID REFERENCES IN-REPLY-TO)]"
+	messageItems = "UID INTERNALDATE RFC822.SIZE FLAGS BODY.PEEK[]"
+)
+
+// IMAPProvider reads a user's linked IMAP inbox
+type IMAPProvider struct {
+	Accounts data.IMAPAccountRepository
+	// Timeout bounds each session; DefaultTimeout when 0
+	Timeout time.Duration
+	// TLSConfig, if set, replaces the default TLS settings, e.g. to trust a private CA. The
+	// server name is filled in from the account.
+	TLSConfig *tls.Config
+}
+
+func NewIMAPProvider(accounts data.IMAPAccountRepository) *IMAPProvider {
+	return &IMAPProvider{Accounts: accounts}
+}
+
+var _ gmail.EmailProvider = (*IMAPProvider)(nil)
+
+// Capabilities reports the optional features supported over IMAP; so far it is read-only
+func (p *IMAPProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{}
+}
+
+// Verify logs in to account and opens its inbox, so bad settings are caught when linking
+func (p *IMAPProvider) Verify(ctx context.Context, account *models.IMAPAccount) error {
+	return p.session(ctx, account, func(*client, uint32) error { return nil })
+}
+
+// FetchSummaries lists the newest inbox messages, older than params.AfterInternalDate when set.
+// Summaries carry headers only; snippets need the message body and are left empty.
+func (p *IMAPProvider) FetchSummaries(ctx context.Context, userID string, params gmail.FetchParams) ([]models.EmailSummary, error) {
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	account, err := p.account(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []*models.EmailMessage
+	err = p.session(ctx, account, func(c *client, validity uint32) error {
+		criteria := "ALL"
+		if params.AfterInternalDate > 0 {
+			// SEARCH only knows dates; later messages of the day are skipped below
+			day := time.UnixMilli(params.AfterInternalDate).UTC().AddDate(0, 0, 1)
+			criteria = "BEFORE " + day.Format("2-Jan-2006")
+		}
+		uids, err := c.uidSearch(criteria)
+		if err != nil {
+			return err
+		}
+		// UIDs grow with arrival, so the newest messages are at the end
+		for len(uids) > 0 && len(msgs) < limit {
+			batch := uids[max(0, len(uids)-limit):]
+			uids = uids[:len(uids)-len(batch)]
+			fetched, err := p.fetch(c, validity, uidSet(batch), summaryItems)
+			if err != nil {
+				return err
+			}
+			for _, m := range fetched {
+				if params.AfterInternalDate > 0 && m.InternalDate >= params.AfterInternalDate {
+					continue
+				}
+				if len(msgs) < limit {
+					msgs = append(msgs, m)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]models.EmailSummary, 0, len(msgs))
+	for _, m := range msgs {
+		out = append(out, models.EmailSummary{
+			ID:                m.EmailMessageID,
+			ThreadID:          m.ThreadID,
+			Subject:           m.Subject,
+			Sender:            m.Sender,
+			SenderAddress:     m.SenderAddress,
+			SenderName:        m.SenderName,
+			InternalDate:      m.InternalDate,
+			SizeEstimate:      m.SizeEstimate,
+			Date:              m.Date,
+			Provider:          ProviderName,
+			ProviderImportant: m.ProviderImportant,
+			IsRead:            m.IsRead,
+		})
+	}
+	return out, nil
+}
+
+// FetchMessage reads one message with its body. The token argument is ignored; the user's IMAP
+// account is used. IDs from other providers, or from before the server renumbered the inbox,
+// are not found.
+func (p *IMAPProvider) FetchMessage(ctx context.Context, _ interface{}, messageID string) (*models.EmailMessage, error) {
+	validity, uid, ok := parseID(messageID)
+	if !ok {
+		return nil, &provider.Error{Kind: provider.ErrNotFound, Err: fmt.Errorf("imap: %q is not an IMAP message ID", messageID)}
+	}
+	userID := ctxkeys.UserID(ctx)
+	if userID == "" {
+		return nil, errors.New("imap: no user ID in context")
+	}
+	account, err := p.account(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var msg *models.EmailMessage
+	err = p.session(ctx, account, func(c *client, current uint32) error {
+		if current != validity {
+			return nil
+		}
+		fetched, err := p.fetch(c, validity, strconv.FormatUint(uint64(uid), 10), messageItems)
+		if err != nil || len(fetched) == 0 {
+			return err
+		}
+		msg = fetched[0]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		return nil, &provider.Error{Kind: provider.ErrNotFound, Err: fmt.Errorf("imap: message %s not found", messageID)}
+	}
+	msg.UserID = userID
+	return msg, nil
+}
+
+// fetch runs a UID FETCH and parses the results, newest first
+func (p *IMAPProvider) fetch(c *client, validity uint32, set, items string) ([]*models.EmailMessage, error) {
+	results, err := c.uidFetch(set, items)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*models.EmailMessage, 0, len(results))
+	for _, f := range results {
+		uid, err := strconv.ParseUint(atom(f["UID"]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: FETCH response without UID", errProtocol)
+		}
+		m, err := toMessage(messageID(validity, uint32(uid)), f)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].InternalDate > msgs[j].InternalDate })
+	return msgs, nil
+}
+
+func (p *IMAPProvider) account(ctx context.Context, userID string) (*models.IMAPAccount, error) {
+	account, err := p.Accounts.Get(ctx, userID)
+	if errors.Is(err, data.ErrNotFound) {
+		return nil, &provider.Error{Kind: provider.ErrAuthExpired, Err: errors.New("no IMAP account linked")}
+	}
+	if errors.Is(err, data.ErrCredentialUnreadable) {
+		return nil, &provider.Error{Kind: provider.ErrAuthExpired, Err: err}
+	}
+	return account, err
+}
+
+// session connects and logs in to account, opens the inbox read-only and runs fn with its
+// UIDVALIDITY
+func (p *IMAPProvider) session(ctx context.Context, account *models.IMAPAccount, fn func(c *client, validity uint32) error) error {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	addr := net.JoinHostPort(account.Host, strconv.Itoa(account.Port))
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return &provider.Error{Kind: provider.ErrTemporary, Err: err}
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	// Unblock reads and writes as soon as the caller gives up
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	tlsConfig := &tls.Config{}
+	if p.TLSConfig != nil {
+		tlsConfig = p.TLSConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = account.Host
+	}
+	if account.TLS {
+		tc := tls.Client(conn, tlsConfig)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return &provider.Error{Kind: provider.ErrTemporary, Err: err}
+		}
+		conn = tc
+	}
+	c, err := newClient(conn)
+	if err != nil {
+		return classifyError(err)
+	}
+	if !account.TLS {
+		if err := c.startTLS(tlsConfig); err != nil {
+			return classifyError(err)
+		}
+	}
+	if err := c.login(account.Username, account.Password); err != nil {
+		var cerr *commandError
+		if errors.As(err, &cerr) && cerr.Status == "NO" {
+			return &provider.Error{Kind: provider.ErrAuthExpired, Err: err}
+		}
+		return classifyError(err)
+	}
+	defer c.logout()
+	validity, err := c.examine(inbox)
+	if err != nil {
+		return classifyError(err)
+	}
+	if err := fn(c, validity); err != nil {
+		return classifyError(err)
+	}
+	return nil
+}
+
+// classifyError maps session failures onto provider errors: network trouble is temporary,
+// anything else the server refused is passed through
+func classifyError(err error) error {
+	var perr *provider.Error
+	var cerr *commandError
+	switch {
+	case errors.As(err, &perr):
+		return err
+	case errors.As(err, &cerr), errors.Is(err, errProtocol):
+		return err
+	default:
+		return &provider.Error{Kind: provider.ErrTemporary, Err: err}
+	}
+}
+
+func messageID(validity, uid uint32) string {
+	return fmt.Sprintf("%s%d-%d", idPrefix, validity, uid)
+}
+
+func parseID(id string) (validity, uid uint32, ok bool) {
+	rest, found := strings.CutPrefix(id, idPrefix)
+	if !found {
+		return 0, 0, false
+	}
+	v, u, found := strings.Cut(rest, "-")
+	if !found {
+		return 0, 0, false
+	}
+	pv, err1 := strconv.ParseUint(v, 10, 32)
+	pu, err2 := strconv.ParseUint(u, 10, 32)
+	if err1 != nil || err2 != nil || pu == 0 {
+		return 0, 0, false
+	}
+	return uint32(pv), uint32(pu), true
+}
+
+// uidSet formats UIDs as an IMAP sequence set
+func uidSet(uids []uint32) string {
+	parts := make([]string, len(uids))
+	for i, uid := range uids {
+		parts[i] = strconv.FormatUint(uint64(uid), 10)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,231 @@
+package imap
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+)
+
+// testMessages are the fake inbox by UID
+var testMessages = map[int]struct{ date, raw string }{
+	10: {"13-Oct-2026 08:00:00 +0000", "From: Ann <ann@example.com>\r\nTo: me@example.com\r\nSubject: First\r\nMessage-ID: <a@x>\r\n\r\nHello one\r\n"},
+	11: {"14-Oct-2026 08:00:00 +0000", "From: bob@example.com\r\nTo: me@example.com\r\nSubject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=\r\nReferences: <a@x>\r\n\r\nHello two\r\n"},
+	12: {" 5-Oct-2026 08:00:00 +0000", "From: carol@example.com\r\nSubject: Third\r\n\r\nHello three\r\n"},
+}
+
+// fakeServer is an IMAP server over TLS that serves testMessages to me/secret
+type fakeServer struct {
+	ln       net.Listener
+	tlsCfg   *tls.Config
+	commands chan string
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	s := &fakeServer{
+		tlsCfg:   &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+		commands: make(chan string, 100),
+	}
+	if s.ln, err = tls.Listen("tcp", "127.0.0.1:0", s.tlsCfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.ln.Close() })
+	go s.serve()
+	return s, &tls.Config{RootCAs: pool}
+}
+
+func (s *fakeServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK [CAPABILITY IMAP4rev1] ready\r\n")
+	for {
+		l, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, cmd, _ := strings.Cut(strings.TrimSuffix(l, "\r\n"), " ")
+		s.commands <- cmd
+		switch {
+		case strings.HasPrefix(cmd, "LOGIN "):
+			if cmd == `LOGIN "me" "secret"` {
+				fmt.Fprintf(conn, "%s OK logged in\r\n", tag)
+			} else {
+				fmt.Fprintf(conn, "%s NO [AUTHENTICATIONFAILED] Authentication failed.\r\n", tag)
+			}
+		case cmd == `EXAMINE "INBOX"`:
+			fmt.Fprintf(conn, "* 3 EXISTS\r\n* FLAGS (\\Seen \\Flagged)\r\n* OK [UIDVALIDITY 7] UIDs valid\r\n%s OK [READ-ONLY] done\r\n", tag)
+		case cmd == "UID SEARCH ALL":
+			fmt.Fprintf(conn, "* SEARCH 10 11 12\r\n%s OK done\r\n", tag)
+		case strings.HasPrefix(cmd, "UID SEARCH BEFORE "):
+			fmt.Fprintf(conn, "* SEARCH 10 12\r\n%s OK done\r\n", tag)
+		case strings.HasPrefix(cmd, "UID FETCH "):
+			set, items, _ := strings.Cut(strings.TrimPrefix(cmd, "UID FETCH "), " ")
+			for seq, uid := range strings.Split(set, ",") {
+				n, _ := strconv.Atoi(uid)
+				m, ok := testMessages[n]
+				if !ok {
+					continue
+				}
+				body, section := m.raw, "BODY[]"
+				if strings.Contains(items, "HEADER.FIELDS") {
+					body, _, _ = strings.Cut(m.raw, "\r\n\r\n")
+					body += "\r\n\r\n"
+					section = "BODY[HEADER.FIELDS (FROM TO SUBJECT)]"
+				}
+				flags := "()"
+				if n == 11 {
+					flags = `(\Seen \Flagged)`
+				}
+				fmt.Fprintf(conn, "* %d FETCH (UID %d INTERNALDATE \"%s\" RFC822.SIZE %d FLAGS %s %s {%d}\r\n%s)\r\n",
+					seq+1, n, m.date, len(m.raw), flags, section, len(body), body)
+			}
+			fmt.Fprintf(conn, "%s OK done\r\n", tag)
+		case cmd == "LOGOUT":
+			fmt.Fprintf(conn, "* BYE bye\r\n%s OK done\r\n", tag)
+			return
+		default:
+			fmt.Fprintf(conn, "%s BAD unknown command\r\n", tag)
+		}
+	}
+}
+
+// stubAccounts holds one IMAP account
+type stubAccounts struct {
+	account *models.IMAPAccount
+}
+
+func (s *stubAccounts) Save(ctx context.Context, a *models.IMAPAccount) error {
+	s.account = a
+	return nil
+}
+func (s *stubAccounts) Get(ctx context.Context, userID string) (*models.IMAPAccount, error) {
+	if s.account == nil || s.account.UserID != userID {
+		return nil, data.ErrNotFound
+	}
+	return s.account, nil
+}
+func (s *stubAccounts) Delete(ctx context.Context, userID string) error { return nil }
+
+func newTestProvider(t *testing.T) (*IMAPProvider, *fakeServer, *models.IMAPAccount) {
+	server, clientTLS := newFakeServer(t)
+	account := &models.IMAPAccount{UserID: "u1", Host: "127.0.0.1", Port: server.port(), TLS: true, Username: "me", Password: "secret"}
+	p := NewIMAPProvider(&stubAccounts{account: account})
+	p.TLSConfig = clientTLS
+	p.Timeout = 5 * time.Second
+	return p, server, account
+}
+
+func TestFetchSummaries(t *testing.T) {
+	p, _, _ := newTestProvider(t)
+	got, err := p.FetchSummaries(context.Background(), "u1", gmail.FetchParams{Limit: 2})
+	if err != nil {
+		t.Fatalf("FetchSummaries failed: %v", err)
+	}
+	// UIDs 11 and 12 are the newest by arrival; they are returned newest first by date
+	if len(got) != 2 || got[0].ID != "imap-7-11" || got[1].ID != "imap-7-12" {
+		t.Fatalf("unexpected summaries: %+v", got)
+	}
+	first := got[0]
+	if first.Subject != "Grüße" || first.SenderAddress != "bob@example.com" || first.ThreadID != "a@x" ||
+		!first.ProviderImportant || first.Provider != ProviderName || first.SizeEstimate == 0 {
+		t.Errorf("unexpected summary: %+v", first)
+	}
+	if !first.IsRead || got[1].IsRead {
+		t.Errorf("read state = %v/%v, want true/false from the \\Seen flag", first.IsRead, got[1].IsRead)
+	}
+	if want := time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC).UnixMilli(); first.InternalDate != want {
+		t.Errorf("InternalDate = %d, want %d", first.InternalDate, want)
+	}
+
+	after := time.Date(2026, 10, 13, 8, 0, 0, 0, time.UTC).UnixMilli()
+	older, err := p.FetchSummaries(context.Background(), "u1", gmail.FetchParams{Limit: 10, AfterInternalDate: after})
+	if err != nil || len(older) != 1 || older[0].ID != "imap-7-12" {
+		t.Errorf("paged FetchSummaries = %+v, %v", older, err)
+	}
+}
+
+func TestFetchMessage(t *testing.T) {
+	p, _, _ := newTestProvider(t)
+	ctx := ctxkeys.WithUserID(context.Background(), "u1")
+	msg, err := p.FetchMessage(ctx, nil, "imap-7-10")
+	if err != nil {
+		t.Fatalf("FetchMessage failed: %v", err)
+	}
+	if msg.Subject != "First" || msg.SenderName != "Ann" || strings.TrimSpace(msg.Body) != "Hello one" || msg.Snippet != "Hello one" || msg.UserID != "u1" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+	for _, id := range []string{"imap-8-10", "imap-7-99", "18c2f0a1b2"} {
+		if _, err := p.FetchMessage(ctx, nil, id); !errors.Is(err, provider.ErrNotFound) {
+			t.Errorf("FetchMessage(%q): expected ErrNotFound, got %v", id, err)
+		}
+	}
+}
+
+func TestVerifyRejectsBadLogin(t *testing.T) {
+	p, server, account := newTestProvider(t)
+	bad := *account
+	bad.Password = `wrong"pass`
+	if err := p.Verify(context.Background(), &bad); !errors.Is(err, provider.ErrAuthExpired) {
+		t.Errorf("expected ErrAuthExpired, got %v", err)
+	}
+	if cmd := <-server.commands; cmd != `LOGIN "me" "wrong\"pass"` {
+		t.Errorf("login sent as %s", cmd)
+	}
+	if err := p.Verify(context.Background(), account); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+	if _, err := p.FetchSummaries(context.Background(), "nobody", gmail.FetchParams{}); !errors.Is(err, provider.ErrAuthExpired) {
+		t.Errorf("expected ErrAuthExpired without an account, got %v", err)
+	}
+}
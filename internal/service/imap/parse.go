@@ -0,0 +1,147 @@
+package imap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/plaintext"
+)
+
+// internalDateLayout is the format of INTERNALDATE; the day is space-padded
+const internalDateLayout = "_2-Jan-2006 15:04:05 -0700"
+
+// maxSnippet is the length in runes of the snippet taken from a message's text
+const maxSnippet = 200
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: plaintext.CharsetReader}
+
+// toMessage builds a message from a FETCH result: UID, INTERNALDATE, RFC822.SIZE, FLAGS and a
+// BODY[] section holding either the header block or the whole message
+func toMessage(id string, f map[string]any) (*models.EmailMessage, error) {
+	raw := atom(f["BODY[]"])
+	parsed, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	msg := &models.EmailMessage{EmailMessageID: id}
+	for name, values := range parsed.Header {
+		for _, v := range values {
+			msg.Headers = append(msg.Headers, models.MessageHeader{Name: name, Value: v})
+		}
+	}
+	msg.Subject = decodeHeader(parsed.Header.Get("Subject"))
+	msg.Sender = decodeHeader(parsed.Header.Get("From"))
+	msg.ParseSender()
+	msg.Recipient = decodeHeader(parsed.Header.Get("To"))
+	msg.ParseRecipients()
+	msg.ThreadID = threadID(parsed.Header)
+	msg.Date = parsed.Header.Get("Date")
+	if t, err := time.Parse(internalDateLayout, atom(f["INTERNALDATE"])); err == nil {
+		msg.InternalDate = t.UnixMilli()
+		if msg.Date == "" {
+			msg.Date = t.UTC().Format(time.RFC3339)
+		}
+	}
+	if size, err := strconv.ParseInt(atom(f["RFC822.SIZE"]), 10, 64); err == nil {
+		msg.SizeEstimate = size
+	}
+	if flags, ok := f["FLAGS"].([]any); ok {
+		for _, flag := range flags {
+			switch {
+			case strings.EqualFold(atom(flag), `\Flagged`):
+				msg.ProviderImportant = true
+			case strings.EqualFold(atom(flag), `\Seen`):
+				msg.IsRead = true
+			}
+		}
+	}
+	body, err := io.ReadAll(parsed.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > 0 {
+		var p textParts
+		p.walk(parsed.Header.Get("Content-Type"), parsed.Header.Get("Content-Transfer-Encoding"), "", bytes.NewReader(body))
+		msg.Body, msg.HTMLBody, msg.HasAttachments = plaintext.Body(p.text, p.html), p.html, p.attachments
+		msg.Snippet = plaintext.Snippet(msg.Body, maxSnippet)
+	}
+	return msg, nil
+}
+
+// threadID is the first message of the thread as named by References or In-Reply-To, or the
+// message's own Message-ID when it starts a thread
+func threadID(h mail.Header) string {
+	for _, name := range []string{"References", "In-Reply-To", "Message-Id"} {
+		if fields := strings.Fields(h.Get(name)); len(fields) > 0 {
+			return strings.Trim(fields[0], "<>")
+		}
+	}
+	return ""
+}
+
+func decodeHeader(v string) string {
+	if d, err := wordDecoder.DecodeHeader(v); err == nil {
+		return d
+	}
+	return v
+}
+
+// textParts collects the first plain text and HTML parts of a message
+type textParts struct {
+	text, html  string
+	attachments bool
+}
+
+func (p *textParts) walk(contentType, encoding, disposition string, r io.Reader) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	if d, _, err := mime.ParseMediaType(disposition); err == nil && d == "attachment" {
+		p.attachments = true
+		return
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				return
+			}
+			// multipart.Part undoes quoted-printable itself and drops the header
+			p.walk(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"),
+				part.Header.Get("Content-Disposition"), part)
+		}
+	case mediaType == "text/plain" && p.text == "":
+		p.text = decodeText(r, encoding, params["charset"])
+	case mediaType == "text/html" && p.html == "":
+		p.html = decodeText(r, encoding, params["charset"])
+	case !strings.HasPrefix(mediaType, "text/"):
+		p.attachments = true
+	}
+}
+
+// decodeText undoes the transfer encoding and converts the text to UTF-8
+func decodeText(r io.Reader, encoding, charset string) string {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil && len(b) == 0 {
+		return ""
+	}
+	return plaintext.Decode(b, charset)
+}
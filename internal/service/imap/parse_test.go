@@ -0,0 +1,60 @@
+package imap
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToMessageMultipart(t *testing.T) {
+	raw := strings.Join([]string{
+		"From: =?utf-8?q?J=C3=BCrgen?= <J@Example.com>",
+		"To: me@example.com",
+		"Cc: Ann <ann@example.com>",
+		"Subject: Report",
+		"In-Reply-To: <root@x>",
+		"Content-Type: multipart/mixed; boundary=outer",
+		"",
+		"--outer",
+		"Content-Type: multipart/alternative; boundary=inner",
+		"",
+		"--inner",
+		"Content-Type: text/plain; charset=iso-8859-1",
+		"Content-Transfer-Encoding: quoted-printable",
+		"",
+		"Gr=FC=DFe   aus",
+		"Berlin",
+		"--inner",
+		"Content-Type: text/html; charset=utf-8",
+		"Content-Transfer-Encoding: base64",
+		"",
+		"PHA+SGk8L3A+",
+		"--inner--",
+		"--outer",
+		"Content-Type: application/pdf",
+		"Content-Disposition: attachment; filename=report.pdf",
+		"",
+		"%PDF",
+		"--outer--",
+		"",
+	}, "\r\n")
+	msg, err := toMessage("imap-1-2", map[string]any{"BODY[]": raw, "INTERNALDATE": "15-Oct-2026 09:30:00 +0200", "RFC822.SIZE": "512"})
+	if err != nil {
+		t.Fatalf("toMessage failed: %v", err)
+	}
+	if msg.SenderName != "Jürgen" || msg.SenderAddress != "j@example.com" {
+		t.Errorf("sender = %q <%s>", msg.SenderName, msg.SenderAddress)
+	}
+	if len(msg.Cc) != 1 || msg.Cc[0].Address != "ann@example.com" || msg.ThreadID != "root@x" {
+		t.Errorf("cc = %+v, thread = %q", msg.Cc, msg.ThreadID)
+	}
+	if !strings.HasPrefix(msg.Body, "Grüße   aus") || msg.Snippet != "Grüße aus Berlin" {
+		t.Errorf("body = %q, snippet = %q", msg.Body, msg.Snippet)
+	}
+	if msg.HTMLBody != "<p>Hi</p>" || !msg.HasAttachments || msg.SizeEstimate != 512 {
+		t.Errorf("html = %q, attachments = %v, size = %d", msg.HTMLBody, msg.HasAttachments, msg.SizeEstimate)
+	}
+	if want := time.Date(2026, 10, 15, 7, 30, 0, 0, time.UTC).UnixMilli(); msg.InternalDate != want {
+		t.Errorf("InternalDate = %d", msg.InternalDate)
+	}
+}
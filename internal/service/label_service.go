@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+// LabelServiceInterface provides label listing and editing backed by the provider
+type LabelServiceInterface interface {
+	Capabilities() provider.Capabilities
+	ListLabels(ctx context.Context, userID string, token *oauth2.Token) ([]*models.Label, error)
+	UpdateLabel(ctx context.Context, userID string, token *oauth2.Token, labelID string, update models.LabelUpdate) (*models.Label, error)
+	CreateLabel(ctx context.Context, userID string, token *oauth2.Token, create models.LabelCreate) (*models.Label, error)
+}
+
+// LabelService keeps the label cache in sync with the user's provider
+type LabelService struct {
+	repo     data.LabelRepository
+	provider EmailProvider
+}
+
+func NewLabelService(repo data.LabelRepository, p EmailProvider) *LabelService {
+	return &LabelService{repo: repo, provider: p}
+}
+
+func (s *LabelService) Capabilities() provider.Capabilities {
+	return s.provider.Capabilities()
+}
+
+// checkEditable returns ErrUnsupported unless the provider can edit labels (and colors, if requested)
+func (s *LabelService) checkEditable(withColors bool) error {
+	caps := s.provider.Capabilities()
+	if !caps.EditLabels || (withColors && !caps.LabelColors) {
+		return provider.ErrUnsupported
+	}
+	return nil
+}
+
+// labelProvider returns the provider's label API, or ErrUnsupported if it has none
+func (s *LabelService) labelProvider() (provider.LabelProvider, error) {
+	lp, ok := s.provider.(provider.LabelProvider)
+	if !ok || !s.provider.Capabilities().Labels {
+		return nil, provider.ErrUnsupported
+	}
+	return lp, nil
+}
+
+// ListLabels refreshes the cache from the provider and returns it.
+// If the provider is unreachable the cached labels are served instead.
+func (s *LabelService) ListLabels(ctx context.Context, userID string, token *oauth2.Token) ([]*models.Label, error) {
+	lp, err := s.labelProvider()
+	if err != nil {
+		return nil, err
+	}
+	labels, err := lp.ListLabels(ctx, token)
+	if err != nil {
+		if !provider.IsRetryable(err) {
+			return nil, err
+		}
+		log.Warn().Str("userID", userID).Err(err).Msg("ListLabels: provider unavailable, serving cached labels")
+		return s.repo.ListLabels(ctx, userID)
+	}
+	if err := s.repo.ReplaceLabels(ctx, userID, labels); err != nil {
+		log.Error().Str("userID", userID).Err(err).Msg("ListLabels: failed to cache labels")
+	}
+	return labels, nil
+}
+
+// UpdateLabel renames or recolors a user label at the provider and updates the cache
+func (s *LabelService) UpdateLabel(ctx context.Context, userID string, token *oauth2.Token, labelID string, update models.LabelUpdate) (*models.Label, error) {
+	lp, err := s.labelProvider()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkEditable(update.BackgroundColor != nil || update.TextColor != nil); err != nil {
+		return nil, err
+	}
+	cached, err := s.repo.GetLabel(ctx, userID, labelID)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && cached.Type == models.LabelTypeSystem {
+		return nil, ErrSystemLabel
+	}
+	label, err := lp.UpdateLabel(ctx, token, labelID, update)
+	if err != nil {
+		return nil, err
+	}
+	label.UserID = userID
+	if err := s.repo.UpsertLabel(ctx, label); err != nil {
+		log.Error().Str("userID", userID).Err(err).Msg("UpdateLabel: failed to cache label")
+	}
+	return label, nil
+}
+
+// CreateLabel creates a user label at the provider and caches it
+func (s *LabelService) CreateLabel(ctx context.Context, userID string, token *oauth2.Token, create models.LabelCreate) (*models.Label, error) {
+	lp, err := s.labelProvider()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkEditable(create.BackgroundColor != "" || create.TextColor != ""); err != nil {
+		return nil, err
+	}
+	label, err := lp.CreateLabel(ctx, token, create)
+	if err != nil {
+		return nil, err
+	}
+	label.UserID = userID
+	if err := s.repo.UpsertLabel(ctx, label); err != nil {
+		log.Error().Str("userID", userID).Err(err).Msg("CreateLabel: failed to cache label")
+	}
+	return label, nil
+}
+
+// ApplyLabel adds the label with the given name to a message, creating the label
+// at the provider first if the user does not have it yet.
+func (s *LabelService) ApplyLabel(ctx context.Context, userID string, token *oauth2.Token, messageID, labelName string) error {
+	lp, err := s.labelProvider()
+	if err != nil {
+		return err
+	}
+	if !s.provider.Capabilities().ApplyLabels {
+		return provider.ErrUnsupported
+	}
+	label, err := s.ensureLabel(ctx, userID, token, labelName)
+	if err != nil {
+		return err
+	}
+	return lp.ApplyLabel(ctx, token, messageID, label.ProviderLabelID)
+}
+
+// RemoveLabel takes the label with the given name off a message. A label the user does not
+// have cannot be on the message, so there is nothing to remove.
+func (s *LabelService) RemoveLabel(ctx context.Context, userID string, token *oauth2.Token, messageID, labelName string) error {
+	lp, err := s.labelProvider()
+	if err != nil {
+		return err
+	}
+	if !s.provider.Capabilities().ApplyLabels {
+		return provider.ErrUnsupported
+	}
+	label, err := s.findLabel(ctx, userID, token, labelName)
+	if err != nil || label == nil {
+		return err
+	}
+	return lp.RemoveLabel(ctx, token, messageID, label.ProviderLabelID)
+}
+
+// ensureLabel finds a label by name, refreshing the cache before creating it so a
+// label added outside Whisperer is reused rather than conflicting.
+func (s *LabelService) ensureLabel(ctx context.Context, userID string, token *oauth2.Token, name string) (*models.Label, error) {
+	label, err := s.findLabel(ctx, userID, token, name)
+	if err != nil || label != nil {
+		return label, err
+	}
+	return s.CreateLabel(ctx, userID, token, models.LabelCreate{Name: name})
+}
+
+// findLabel finds a label by name in the cache, then at the provider; nil if the user has none
+func (s *LabelService) findLabel(ctx context.Context, userID string, token *oauth2.Token, name string) (*models.Label, error) {
+	cached, err := s.repo.GetLabelByName(ctx, userID, name)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		return cached, nil
+	}
+	labels, err := s.ListLabels(ctx, userID, token)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range labels {
+		if strings.EqualFold(l.Name, name) {
+			return l, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"golang.org/x/oauth2"
+)
+
+type fakeLabelRepo struct {
+	labels map[string]*models.Label
+}
+
+func (f *fakeLabelRepo) UpsertLabel(ctx context.Context, label *models.Label) error {
+	f.labels[label.ProviderLabelID] = label
+	return nil
+}
+func (f *fakeLabelRepo) ReplaceLabels(ctx context.Context, userID string, labels []*models.Label) error {
+	f.labels = map[string]*models.Label{}
+	for _, l := range labels {
+		f.labels[l.ProviderLabelID] = l
+	}
+	return nil
+}
+func (f *fakeLabelRepo) ListLabels(ctx context.Context, userID string) ([]*models.Label, error) {
+	var out []*models.Label
+	for _, l := range f.labels {
+		out = append(out, l)
+	}
+	return out, nil
+}
+func (f *fakeLabelRepo) GetLabel(ctx context.Context, userID, providerLabelID string) (*models.Label, error) {
+	return f.labels[providerLabelID], nil
+}
+
+func (f *fakeLabelRepo) GetLabelByName(ctx context.Context, userID, name string) (*models.Label, error) {
+	for _, l := range f.labels {
+		if strings.EqualFold(l.Name, name) {
+			return l, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeLabelProvider struct {
+	labels  []*models.Label
+	err     error
+	created []string
+	applied map[string]string // msgID -> labelID
+}
+
+func (p *fakeLabelProvider) FetchSummaries(ctx context.Context, userID string, params gmail.FetchParams) ([]models.EmailSummary, error) {
+	return nil, nil
+}
+func (p *fakeLabelProvider) FetchMessage(ctx context.Context, token interface{}, messageID string) (*models.EmailMessage, error) {
+	return nil, nil
+}
+func (p *fakeLabelProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{Labels: true, LabelColors: true, EditLabels: true, ApplyLabels: true}
+}
+func (p *fakeLabelProvider) ListLabels(ctx context.Context, token *oauth2.Token) ([]*models.Label, error) {
+	return p.labels, p.err
+}
+func (p *fakeLabelProvider) UpdateLabel(ctx context.Context, token *oauth2.Token, labelID string, update models.LabelUpdate) (*models.Label, error) {
+	return &models.Label{ProviderLabelID: labelID, Name: *update.Name, Type: models.LabelTypeUser}, nil
+}
+
+func (p *fakeLabelProvider) CreateLabel(ctx context.Context, token *oauth2.Token, create models.LabelCreate) (*models.Label, error) {
+	p.created = append(p.created, create.Name)
+	l := &models.Label{ProviderLabelID: "Label_" + create.Name, Name: create.Name, Type: models.LabelTypeUser}
+	p.labels = append(p.labels, l)
+	return l, nil
+}
+func (p *fakeLabelProvider) ApplyLabel(ctx context.Context, token *oauth2.Token, messageID, labelID string) error {
+	if p.applied == nil {
+		p.applied = map[string]string{}
+	}
+	p.applied[messageID] = labelID
+	return nil
+}
+
+func (p *fakeLabelProvider) RemoveLabel(ctx context.Context, token *oauth2.Token, messageID, labelID string) error {
+	if p.applied[messageID] == labelID {
+		delete(p.applied, messageID)
+	}
+	return nil
+}
+
+func TestLabelService(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeLabelRepo{labels: map[string]*models.Label{}}
+	prov := &fakeLabelProvider{labels: []*models.Label{
+		{ProviderLabelID: "INBOX", Name: "INBOX", Type: models.LabelTypeSystem},
+		{ProviderLabelID: "Label_1", Name: "Finance", Type: models.LabelTypeUser},
+	}}
+	svc := NewLabelService(repo, prov)
+
+	labels, err := svc.ListLabels(ctx, "user1", nil)
+	if err != nil || len(labels) != 2 || len(repo.labels) != 2 {
+		t.Fatalf("expected labels to be listed and cached, got %d (cached %d), err %v", len(labels), len(repo.labels), err)
+	}
+
+	// Cached labels are served while the provider is temporarily down
+	prov.err = &provider.Error{Kind: provider.ErrTemporary, Err: errors.New("503")}
+	if labels, err = svc.ListLabels(ctx, "user1", nil); err != nil || len(labels) != 2 {
+		t.Fatalf("expected cached fallback, got %d labels, err %v", len(labels), err)
+	}
+	prov.err = &provider.Error{Kind: provider.ErrAuthExpired, Err: errors.New("401")}
+	if _, err = svc.ListLabels(ctx, "user1", nil); !errors.Is(err, provider.ErrAuthExpired) {
+		t.Fatalf("expected auth error to propagate, got %v", err)
+	}
+
+	name := "Finance/Receipts"
+	if _, err := svc.UpdateLabel(ctx, "user1", nil, "INBOX", models.LabelUpdate{Name: &name}); !errors.Is(err, ErrSystemLabel) {
+		t.Errorf("expected ErrSystemLabel, got %v", err)
+	}
+	l, err := svc.UpdateLabel(ctx, "user1", nil, "Label_1", models.LabelUpdate{Name: &name})
+	if err != nil || l.Name != name || repo.labels["Label_1"].Name != name {
+		t.Errorf("expected label renamed and cached, got %+v, err %v", l, err)
+	}
+}
+
+func TestLabelService_ApplyLabelCreatesOnDemand(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeLabelRepo{labels: map[string]*models.Label{}}
+	prov := &fakeLabelProvider{labels: []*models.Label{{ProviderLabelID: "Label_1", Name: "Travel", Type: models.LabelTypeUser}}}
+	svc := NewLabelService(repo, prov)
+
+	// A label that exists at the provider but not yet in the cache is reused
+	if err := svc.ApplyLabel(ctx, "user1", nil, "m1", "travel"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if prov.applied["m1"] != "Label_1" || len(prov.created) != 0 {
+		t.Errorf("expected existing label reused, applied %v created %v", prov.applied, prov.created)
+	}
+
+	// A missing label is created once, then served from the cache
+	for _, msgID := range []string{"m2", "m3"} {
+		if err := svc.ApplyLabel(ctx, "user1", nil, msgID, "Finance/Receipts"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(prov.created) != 1 || prov.applied["m3"] != "Label_Finance/Receipts" {
+		t.Errorf("expected label created once and applied, created %v applied %v", prov.created, prov.applied)
+	}
+	if err := svc.RemoveLabel(ctx, "user1", nil, "m3", "finance/receipts"); err != nil || prov.applied["m3"] != "" {
+		t.Errorf("expected the label removed from m3, applied %v, err %v", prov.applied, err)
+	}
+	// Removing a label the user does not have creates nothing
+	if err := svc.RemoveLabel(ctx, "user1", nil, "m2", "Unknown"); err != nil || len(prov.created) != 1 {
+		t.Errorf("expected nothing to remove, created %v, err %v", prov.created, err)
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/legalhold"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+// MessageCache is the local message store kept in step with actions taken at the provider
+type MessageCache interface {
+	SetRead(ctx context.Context, userID, emailMessageID string, read bool) error
+	SetArchived(ctx context.Context, userID, emailMessageID string) error
+	DeleteMessage(ctx context.Context, userID, emailMessageID string) error
+}
+
+// MessageActionService archives, trashes and marks messages read or unread at the user's provider
+type MessageActionService struct {
+	provider EmailProvider
+	// Messages, when set, is updated after a read state change, archive or trash succeeds at the provider
+	Messages MessageCache
+	// Holds refuses trashing the messages of users under legal hold
+	Holds *legalhold.Service
+}
+
+func NewMessageActionService(p EmailProvider) *MessageActionService {
+	return &MessageActionService{provider: p}
+}
+
+// actionProvider returns the provider's message action API, or ErrUnsupported if it has none
+func (s *MessageActionService) actionProvider() (provider.MessageActionProvider, error) {
+	ap, ok := s.provider.(provider.MessageActionProvider)
+	if !ok {
+		return nil, provider.ErrUnsupported
+	}
+	return ap, nil
+}
+
+// Archive removes the message from the user's inbox and then relabels the cached copy. The row
+// is kept, since sync caches all mail and newsletter digests list archived messages. A cache
+// failure is logged rather than returned; the next sync corrects it.
+func (s *MessageActionService) Archive(ctx context.Context, userID string, token *oauth2.Token, messageID string) error {
+	if models.IsDigestMessage(messageID) {
+		return s.dropDigest(ctx, userID, messageID)
+	}
+	ap, err := s.actionProvider()
+	if err != nil {
+		return err
+	}
+	if err := ap.Archive(ctx, token, messageID); err != nil {
+		return err
+	}
+	if s.Messages != nil {
+		if err := s.Messages.SetArchived(ctx, userID, messageID); err != nil && !errors.Is(err, data.ErrNotFound) {
+			log.Error().Str("userID", userID).Str("messageID", messageID).Err(err).Msg("Archive: failed to update cached labels")
+		}
+	}
+	return nil
+}
+
+// Trash moves the message to the provider's trash and drops it from the local cache. Returns
+// ErrUnsupported if the provider cannot trash messages, and legalhold.ErrHeld if the user is
+// under legal hold.
+func (s *MessageActionService) Trash(ctx context.Context, userID string, token *oauth2.Token, messageID string) error {
+	tp, ok := s.provider.(provider.TrashProvider)
+	if !ok {
+		return provider.ErrUnsupported
+	}
+	if err := s.Holds.Guard(ctx, userID, legalhold.ActionTrash); err != nil {
+		return err
+	}
+	if models.IsDigestMessage(messageID) {
+		return s.dropDigest(ctx, userID, messageID)
+	}
+	if err := tp.Trash(ctx, token, messageID); err != nil {
+		return err
+	}
+	if s.Messages != nil {
+		if err := s.Messages.DeleteMessage(ctx, userID, messageID); err != nil && !errors.Is(err, data.ErrNotFound) {
+			log.Error().Str("userID", userID).Str("messageID", messageID).Err(err).Msg("Trash: failed to remove cached message")
+		}
+	}
+	return nil
+}
+
+// MarkRead marks the message as read
+func (s *MessageActionService) MarkRead(ctx context.Context, userID string, token *oauth2.Token, messageID string) error {
+	return s.SetRead(ctx, userID, token, messageID, true)
+}
+
+// MarkUnread marks the message as unread
+func (s *MessageActionService) MarkUnread(ctx context.Context, userID string, token *oauth2.Token, messageID string) error {
+	return s.SetRead(ctx, userID, token, messageID, false)
+}
+
+// SetRead writes the message's read state to the provider and then to the local cache.
+// A cache failure is logged rather than returned; the next sync corrects it.
+func (s *MessageActionService) SetRead(ctx context.Context, userID string, token *oauth2.Token, messageID string, read bool) error {
+	if models.IsDigestMessage(messageID) {
+		return s.setDigestRead(ctx, userID, messageID, read)
+	}
+	ap, err := s.actionProvider()
+	if err != nil {
+		return err
+	}
+	if read {
+		err = ap.MarkRead(ctx, token, messageID)
+	} else {
+		err = ap.MarkUnread(ctx, token, messageID)
+	}
+	if err != nil {
+		return err
+	}
+	if s.Messages != nil {
+		if err := s.Messages.SetRead(ctx, userID, messageID, read); err != nil && !errors.Is(err, data.ErrNotFound) {
+			log.Error().Str("userID", userID).Str("messageID", messageID).Err(err).Msg("SetRead: failed to update cached read state")
+		}
+	}
+	return nil
+}
+
+// Digest messages (see package digest) exist only in the cache, so their actions never reach
+// the provider: archiving or trashing one drops it and its read state is kept locally.
+
+func (s *MessageActionService) dropDigest(ctx context.Context, userID, messageID string) error {
+	if s.Messages == nil {
+		return provider.ErrUnsupported
+	}
+	err := s.Messages.DeleteMessage(ctx, userID, messageID)
+	if errors.Is(err, data.ErrNotFound) {
+		return provider.ErrNotFound
+	}
+	return err
+}
+
+func (s *MessageActionService) setDigestRead(ctx context.Context, userID, messageID string, read bool) error {
+	if s.Messages == nil {
+		return provider.ErrUnsupported
+	}
+	err := s.Messages.SetRead(ctx, userID, messageID, read)
+	if errors.Is(err, data.ErrNotFound) {
+		return provider.ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/legalhold"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"golang.org/x/oauth2"
+)
+
+type fakeActionProvider struct {
+	fakeLabelProvider
+	archived []string
+	read     []string
+	unread   []string
+	trashed  []string
+}
+
+func (p *fakeActionProvider) Archive(ctx context.Context, token *oauth2.Token, messageID string) error {
+	p.archived = append(p.archived, messageID)
+	return nil
+}
+
+func (p *fakeActionProvider) MarkRead(ctx context.Context, token *oauth2.Token, messageID string) error {
+	p.read = append(p.read, messageID)
+	return nil
+}
+
+func (p *fakeActionProvider) MarkUnread(ctx context.Context, token *oauth2.Token, messageID string) error {
+	p.unread = append(p.unread, messageID)
+	return nil
+}
+
+func (p *fakeActionProvider) Trash(ctx context.Context, token *oauth2.Token, messageID string) error {
+	p.trashed = append(p.trashed, messageID)
+	return nil
+}
+
+type fakeMessageCache struct {
+	state    map[string]bool
+	archived []string
+}
+
+func (c *fakeMessageCache) SetArchived(ctx context.Context, userID, emailMessageID string) error {
+	if _, ok := c.state[emailMessageID]; !ok {
+		return data.ErrNotFound
+	}
+	c.archived = append(c.archived, emailMessageID)
+	return nil
+}
+
+func (c *fakeMessageCache) DeleteMessage(ctx context.Context, userID, emailMessageID string) error {
+	if _, ok := c.state[emailMessageID]; !ok {
+		return data.ErrNotFound
+	}
+	delete(c.state, emailMessageID)
+	return nil
+}
+
+func (c *fakeMessageCache) SetRead(ctx context.Context, userID, emailMessageID string, read bool) error {
+	if _, ok := c.state[emailMessageID]; !ok {
+		return data.ErrNotFound
+	}
+	c.state[emailMessageID] = read
+	return nil
+}
+
+func TestMessageActionService(t *testing.T) {
+	p := &fakeActionProvider{}
+	svc := NewMessageActionService(p)
+	if err := svc.Archive(context.Background(), "u1", nil, "m1"); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	if err := svc.MarkRead(context.Background(), "u1", nil, "m2"); err != nil {
+		t.Fatalf("MarkRead failed: %v", err)
+	}
+	if len(p.archived) != 1 || p.archived[0] != "m1" || len(p.read) != 1 || p.read[0] != "m2" {
+		t.Errorf("unexpected actions: archived=%v read=%v", p.archived, p.read)
+	}
+
+	// Providers without message actions are rejected up front
+	svc = NewMessageActionService(&fakeLabelProvider{})
+	if err := svc.Archive(context.Background(), "u1", nil, "m1"); !errors.Is(err, provider.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestMessageActionService_SetReadUpdatesCache(t *testing.T) {
+	p := &fakeActionProvider{}
+	cache := &fakeMessageCache{state: map[string]bool{"m1": true}}
+	svc := NewMessageActionService(p)
+	svc.Messages = cache
+
+	if err := svc.MarkUnread(context.Background(), "u1", nil, "m1"); err != nil {
+		t.Fatalf("MarkUnread failed: %v", err)
+	}
+	if len(p.unread) != 1 || p.unread[0] != "m1" || cache.state["m1"] {
+		t.Errorf("unexpected state after MarkUnread: unread=%v cached=%v", p.unread, cache.state)
+	}
+	// Messages that were never synced are still changed at the provider
+	if err := svc.SetRead(context.Background(), "u1", nil, "m2", true); err != nil {
+		t.Fatalf("SetRead on uncached message failed: %v", err)
+	}
+	if len(p.read) != 1 || p.read[0] != "m2" {
+		t.Errorf("unexpected read calls: %v", p.read)
+	}
+}
+
+func TestMessageActionService_ArchiveUpdatesCache(t *testing.T) {
+	p := &fakeActionProvider{}
+	cache := &fakeMessageCache{state: map[string]bool{"m1": false}}
+	svc := NewMessageActionService(p)
+	svc.Messages = cache
+
+	if err := svc.Archive(context.Background(), "u1", nil, "m1"); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	// The row stays cached for digests and the next sync, relabeled
+	if _, cached := cache.state["m1"]; len(p.archived) != 1 || !cached || len(cache.archived) != 1 || cache.archived[0] != "m1" {
+		t.Errorf("expected m1 archived and relabeled in the cache, got archived=%v cache=%v relabeled=%v", p.archived, cache.state, cache.archived)
+	}
+	if err := svc.Archive(context.Background(), "u1", nil, "m2"); err != nil {
+		t.Errorf("Archive of an uncached message failed: %v", err)
+	}
+}
+
+func TestMessageActionService_DigestStaysLocal(t *testing.T) {
+	p := &fakeActionProvider{}
+	id := models.DigestMessagePrefix + "2026-10-15"
+	cache := &fakeMessageCache{state: map[string]bool{id: false}}
+	svc := NewMessageActionService(p)
+	svc.Messages = cache
+
+	if err := svc.MarkRead(context.Background(), "u1", nil, id); err != nil || !cache.state[id] {
+		t.Fatalf("expected the digest to be marked read in the cache, got %v (state %v)", err, cache.state)
+	}
+	if err := svc.Archive(context.Background(), "u1", nil, id); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	if _, ok := cache.state[id]; ok {
+		t.Error("expected archiving the digest to drop it from the cache")
+	}
+	if err := svc.Archive(context.Background(), "u1", nil, id); !errors.Is(err, provider.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a dropped digest, got %v", err)
+	}
+	if len(p.archived) != 0 || len(p.read) != 0 {
+		t.Errorf("expected no provider calls, got archived=%v read=%v", p.archived, p.read)
+	}
+}
+
+// heldRepo holds every user
+type heldRepo struct {
+	data.LegalHoldRepository
+}
+
+func (heldRepo) Get(ctx context.Context, userID string) (*models.LegalHold, error) {
+	return &models.LegalHold{UserID: userID}, nil
+}
+
+func (heldRepo) RecordEvent(ctx context.Context, e *models.LegalHoldEvent) error {
+	return nil
+}
+
+func TestMessageActionService_Trash(t *testing.T) {
+	p := &fakeActionProvider{}
+	cache := &fakeMessageCache{state: map[string]bool{"m1": true}}
+	svc := NewMessageActionService(p)
+	svc.Messages = cache
+	if err := svc.Trash(context.Background(), "u1", nil, "m1"); err != nil {
+		t.Fatalf("Trash failed: %v", err)
+	}
+	if _, cached := cache.state["m1"]; len(p.trashed) != 1 || cached {
+		t.Errorf("expected m1 trashed and uncached, got trashed=%v cache=%v", p.trashed, cache.state)
+	}
+	if err := svc.Trash(context.Background(), "u1", nil, "m2"); err != nil {
+		t.Errorf("Trash of an uncached message failed: %v", err)
+	}
+
+	svc.Holds = legalhold.NewService(heldRepo{})
+	cache.state["m3"] = true
+	if err := svc.Trash(context.Background(), "u1", nil, "m3"); !errors.Is(err, legalhold.ErrHeld) {
+		t.Errorf("expected ErrHeld, got %v", err)
+	}
+	if _, cached := cache.state["m3"]; len(p.trashed) != 2 || !cached {
+		t.Errorf("expected a held message to stay, got trashed=%v cache=%v", p.trashed, cache.state)
+	}
+
+	svc = NewMessageActionService(&fakeLabelProvider{})
+	if err := svc.Trash(context.Background(), "u1", nil, "m1"); !errors.Is(err, provider.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package outlook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+)
+
+// GraphError is an error response from Microsoft Graph
+type GraphError struct {
+	Status  int
+	Code    string
+	Message string
+	Header  http.Header
+}
+
+func (e *GraphError) Error() string {
+	return fmt.Sprintf("graph: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// notFoundCodes are the Graph error codes for message IDs that are not the mailbox's; IDs
+// from other providers are rejected as malformed rather than missing
+var notFoundCodes = map[string]bool{
+	"ErrorItemNotFound":       true,
+	"ErrorInvalidIdMalformed": true,
+}
+
+// readError reads a Graph error response
+func readError(resp *http.Response) *GraphError {
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	return &GraphError{Status: resp.StatusCode, Code: body.Error.Code, Message: body.Error.Message, Header: resp.Header}
+}
+
+// classifyError maps a Graph error onto the provider error taxonomy.
+// Errors that do not match a known category are returned unchanged.
+func classifyError(err error) error {
+	var gErr *GraphError
+	if !errors.As(err, &gErr) {
+		return err
+	}
+	switch {
+	case gErr.Status == http.StatusNotFound, notFoundCodes[gErr.Code]:
+		return provider.ErrNotFound
+	case gErr.Status == http.StatusUnauthorized:
+		return &provider.Error{Kind: provider.ErrAuthExpired, Err: err}
+	case gErr.Status == http.StatusForbidden:
+		return &provider.Error{Kind: provider.ErrMissingScope, Err: err}
+	case gErr.Status == http.StatusBadRequest:
+		return &provider.Error{Kind: provider.ErrInvalidRequest, Err: err}
+	case gErr.Status == http.StatusTooManyRequests:
+		return &provider.Error{Kind: provider.ErrRateLimited, Err: err, RetryAfter: parseRetryAfter(gErr.Header)}
+	case gErr.Status >= http.StatusInternalServerError:
+		return &provider.Error{Kind: provider.ErrTemporary, Err: err, RetryAfter: parseRetryAfter(gErr.Header)}
+	}
+	return err
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds; HTTP-date values are ignored
+func parseRetryAfter(h http.Header) time.Duration {
+	secs, err := strconv.Atoi(h.Get("Retry-After"))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
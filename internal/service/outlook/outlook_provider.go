@@ -0,0 +1,273 @@
+// Package outlook reads Outlook and Microsoft 365 mailboxes through Microsoft Graph. Unlike
+// Gmail, Outlook mailboxes are not synced into the local cache: summaries and messages are read
+// from Graph on each request.
+package outlook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"golang.org/x/oauth2"
+)
+
+// DefaultBaseURL is the Microsoft Graph v1.0 endpoint
+const DefaultBaseURL = "https://graph.microsoft.com/v1.0"
+
+// ProviderName labels summaries served by this provider
+const ProviderName = "outlook"
+
+const (
+	defaultLimit = 10
+	maxLimit     = 100
+	// summaryFields and messageFields are the Graph properties read for lists and single messages
+	summaryFields = "id,conversationId,subject,from,bodyPreview,receivedDateTime,hasAttachments,importance,isRead"
+	messageFields = summaryFields + ",toRecipients,ccRecipients,bccRecipients,replyTo,body"
+)
+
+// OutlookProvider reads a user's default Outlook mailbox. It loads the user's Outlook token
+// itself, since the token passed in by handlers is the Gmail one, and stores refreshed tokens.
+type OutlookProvider struct {
+	Tokens data.UserTokenRepository
+	// OAuthConfig refreshes expired access tokens
+	OAuthConfig *oauth2.Config
+	// BaseURL is the Graph endpoint; DefaultBaseURL when empty
+	BaseURL string
+	// HTTPClient carries Graph and token refresh calls; optional (http.DefaultClient when nil)
+	HTTPClient *http.Client
+}
+
+func NewOutlookProvider(oauthCfg *oauth2.Config, tokens data.UserTokenRepository) *OutlookProvider {
+	return &OutlookProvider{Tokens: tokens, OAuthConfig: oauthCfg}
+}
+
+var _ gmail.EmailProvider = (*OutlookProvider)(nil)
+
+// Capabilities reports the optional features supported by Outlook; so far it is read-only
+func (p *OutlookProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{}
+}
+
+// graphMessage is the subset of a Graph message resource that is read
+type graphMessage struct {
+	ID               string           `json:"id"`
+	ConversationID   string           `json:"conversationId"`
+	Subject          string           `json:"subject"`
+	From             *graphRecipient  `json:"from"`
+	BodyPreview      string           `json:"bodyPreview"`
+	ReceivedDateTime time.Time        `json:"receivedDateTime"`
+	HasAttachments   bool             `json:"hasAttachments"`
+	Importance       string           `json:"importance"`
+	IsRead           bool             `json:"isRead"`
+	ToRecipients     []graphRecipient `json:"toRecipients"`
+	CcRecipients     []graphRecipient `json:"ccRecipients"`
+	BccRecipients    []graphRecipient `json:"bccRecipients"`
+	ReplyTo          []graphRecipient `json:"replyTo"`
+	Body             struct {
+		ContentType string `json:"contentType"`
+		Content     string `json:"content"`
+	} `json:"body"`
+}
+
+type graphRecipient struct {
+	EmailAddress struct {
+		Name    string `json:"name"`
+		Address string `json:"address"`
+	} `json:"emailAddress"`
+}
+
+// FetchSummaries lists the newest inbox messages, older than params.AfterInternalDate when set
+func (p *OutlookProvider) FetchSummaries(ctx context.Context, userID string, params gmail.FetchParams) ([]models.EmailSummary, error) {
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	q := url.Values{
+		"$top":     {fmt.Sprint(limit)},
+		"$select":  {summaryFields},
+		"$orderby": {"receivedDateTime desc"},
+	}
+	if params.AfterInternalDate > 0 {
+		q.Set("$filter", "receivedDateTime lt "+time.UnixMilli(params.AfterInternalDate).UTC().Format(time.RFC3339))
+	}
+	var page struct {
+		Value []graphMessage `json:"value"`
+	}
+	if err := p.get(ctx, userID, "/me/mailFolders/inbox/messages?"+q.Encode(), &page); err != nil {
+		return nil, err
+	}
+	out := make([]models.EmailSummary, 0, len(page.Value))
+	for i := range page.Value {
+		m := toMessage(&page.Value[i])
+		out = append(out, models.EmailSummary{
+			ID:                m.EmailMessageID,
+			ThreadID:          m.ThreadID,
+			Subject:           m.Subject,
+			Sender:            m.Sender,
+			SenderAddress:     m.SenderAddress,
+			SenderName:        m.SenderName,
+			Snippet:           m.Snippet,
+			InternalDate:      m.InternalDate,
+			HasAttachments:    m.HasAttachments,
+			Date:              m.Date,
+			Provider:          ProviderName,
+			ProviderImportant: m.ProviderImportant,
+			IsRead:            m.IsRead,
+		})
+	}
+	return out, nil
+}
+
+// FetchMessage reads one message with its plain text body. The token argument is ignored; the
+// user's Outlook token is used.
+func (p *OutlookProvider) FetchMessage(ctx context.Context, _ interface{}, messageID string) (*models.EmailMessage, error) {
+	userID := ctxkeys.UserID(ctx)
+	if userID == "" {
+		return nil, errors.New("outlook: no user ID in context")
+	}
+	var gm graphMessage
+	path := "/me/messages/" + url.PathEscape(messageID) + "?" + url.Values{"$select": {messageFields}}.Encode()
+	if err := p.get(ctx, userID, path, &gm); err != nil {
+		return nil, err
+	}
+	msg := toMessage(&gm)
+	msg.UserID = userID
+	msg.Body = gm.Body.Content
+	return msg, nil
+}
+
+// Account returns the address of the mailbox tok belongs to, which its token is stored under
+func (p *OutlookProvider) Account(ctx context.Context, tok *oauth2.Token) (string, error) {
+	var me struct {
+		Mail              string `json:"mail"`
+		UserPrincipalName string `json:"userPrincipalName"`
+	}
+	if err := p.do(ctx, tok, "/me?$select=mail,userPrincipalName", &me); err != nil {
+		return "", err
+	}
+	account := me.Mail
+	if account == "" {
+		account = me.UserPrincipalName
+	}
+	if account == "" {
+		return "", errors.New("outlook: account has no address")
+	}
+	return strings.ToLower(account), nil
+}
+
+// get calls Graph as the user, refreshing and storing their token first if it expired
+func (p *OutlookProvider) get(ctx context.Context, userID, path string, out any) error {
+	tok, err := p.Tokens.GetUserToken(ctx, userID, data.ProviderOutlook, "")
+	if errors.Is(err, data.ErrNotFound) {
+		return &provider.Error{Kind: provider.ErrAuthExpired, Err: errors.New("no Outlook account linked")}
+	}
+	if err != nil {
+		return err
+	}
+	if !tok.Valid() {
+		if tok, err = p.refresh(ctx, userID, tok); err != nil {
+			return err
+		}
+	}
+	return p.do(ctx, tok, path, out)
+}
+
+func (p *OutlookProvider) refresh(ctx context.Context, userID string, tok *oauth2.Token) (*oauth2.Token, error) {
+	if p.HTTPClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, p.HTTPClient)
+	}
+	fresh, err := p.OAuthConfig.TokenSource(ctx, tok).Token()
+	if err != nil {
+		return nil, &provider.Error{Kind: provider.ErrAuthExpired, Err: err}
+	}
+	account, err := p.Account(ctx, fresh)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Tokens.SaveUserToken(ctx, userID, data.ProviderOutlook, account, fresh); err != nil {
+		return nil, err
+	}
+	return fresh, nil
+}
+
+// do sends a GET to Graph with tok and decodes the JSON response into out
+func (p *OutlookProvider) do(ctx context.Context, tok *oauth2.Token, path string, out any) error {
+	base := p.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(base, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	tok.SetAuthHeader(req)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Prefer", `outlook.body-content-type="text"`)
+	client := p.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return &provider.Error{Kind: provider.ErrTemporary, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return classifyError(readError(resp))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// toMessage converts the list fields of a Graph message
+func toMessage(gm *graphMessage) *models.EmailMessage {
+	msg := &models.EmailMessage{
+		EmailMessageID:    gm.ID,
+		ThreadID:          gm.ConversationID,
+		Subject:           gm.Subject,
+		Snippet:           gm.BodyPreview,
+		InternalDate:      gm.ReceivedDateTime.UnixMilli(),
+		Date:              gm.ReceivedDateTime.UTC().Format(time.RFC3339),
+		HasAttachments:    gm.HasAttachments,
+		ProviderImportant: gm.Importance == "high",
+		IsRead:            gm.IsRead,
+		Cc:                addresses(gm.CcRecipients),
+		Bcc:               addresses(gm.BccRecipients),
+		ReplyTo:           addresses(gm.ReplyTo),
+	}
+	if gm.From != nil {
+		msg.SenderAddress = strings.ToLower(gm.From.EmailAddress.Address)
+		msg.SenderName = gm.From.EmailAddress.Name
+		msg.Sender = (&mail.Address{Name: msg.SenderName, Address: msg.SenderAddress}).String()
+	}
+	to := make([]string, 0, len(gm.ToRecipients))
+	for _, a := range addresses(gm.ToRecipients) {
+		to = append(to, (&mail.Address{Name: a.Name, Address: a.Address}).String())
+	}
+	msg.Recipient = strings.Join(to, ", ")
+	return msg
+}
+
+func addresses(rs []graphRecipient) []models.EmailAddress {
+	if len(rs) == 0 {
+		return nil
+	}
+	out := make([]models.EmailAddress, len(rs))
+	for i, r := range rs {
+		out[i] = models.EmailAddress{Name: r.EmailAddress.Name, Address: strings.ToLower(r.EmailAddress.Address)}
+	}
+	return out
+}
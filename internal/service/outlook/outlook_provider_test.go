@@ -0,0 +1,141 @@
+package outlook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/service/gmail"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"golang.org/x/oauth2"
+)
+
+// memoryTokens holds one Outlook token per user and records saves
+type memoryTokens struct {
+	tokens map[string]*oauth2.Token
+	saved  string // account of the last save
+}
+
+func (m *memoryTokens) SaveUserToken(ctx context.Context, userID, prov, accountID string, token *oauth2.Token) error {
+	m.tokens[userID], m.saved = token, accountID
+	return nil
+}
+func (m *memoryTokens) GetUserToken(ctx context.Context, userID, prov, accountID string) (*oauth2.Token, error) {
+	if tok, ok := m.tokens[userID]; ok && prov == data.ProviderOutlook {
+		return tok, nil
+	}
+	return nil, data.ErrNotFound
+}
+
+const messageJSON = `{"id":"AAMk1","conversationId":"c1","subject":"Hello","bodyPreview":"Hi there",
+	"receivedDateTime":"2026-10-01T12:00:00Z","hasAttachments":true,"importance":"high",
+	"from":{"emailAddress":{"name":"Ann Lee","address":"Ann@Example.com"}},
+	"toRecipients":[{"emailAddress":{"name":"","address":"me@example.com"}}],
+	"ccRecipients":[{"emailAddress":{"name":"Bob","address":"bob@example.com"}}],
+	"body":{"contentType":"text","content":"Hi there, full text"}}`
+
+func newTestProvider(t *testing.T, handler http.HandlerFunc) (*OutlookProvider, *memoryTokens) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	tokens := &memoryTokens{tokens: map[string]*oauth2.Token{
+		"u1": {AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)},
+	}}
+	p := NewOutlookProvider(&oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: srv.URL + "/token"}}, tokens)
+	p.BaseURL = srv.URL
+	return p, tokens
+}
+
+func TestFetchSummaries(t *testing.T) {
+	p, _ := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			t.Errorf("unexpected auth header %q", r.Header.Get("Authorization"))
+		}
+		if r.URL.Path != "/me/mailFolders/inbox/messages" || r.URL.Query().Get("$top") != "5" ||
+			r.URL.Query().Get("$filter") != "receivedDateTime lt 2026-10-02T00:00:00Z" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		fmt.Fprintf(w, `{"value":[%s]}`, messageJSON)
+	})
+	after := time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC).UnixMilli()
+	got, err := p.FetchSummaries(context.Background(), "u1", gmail.FetchParams{Limit: 5, AfterInternalDate: after})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected one summary, got %+v", got)
+	}
+	s := got[0]
+	if s.ID != "AAMk1" || s.ThreadID != "c1" || s.SenderAddress != "ann@example.com" || s.SenderName != "Ann Lee" ||
+		s.Sender != `"Ann Lee" <ann@example.com>` || s.Provider != "outlook" || !s.ProviderImportant || !s.HasAttachments ||
+		s.InternalDate != time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC).UnixMilli() {
+		t.Errorf("unexpected summary %+v", s)
+	}
+}
+
+func TestFetchMessage(t *testing.T) {
+	p, _ := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/me/messages/AAMk1":
+			if !strings.Contains(r.Header.Get("Prefer"), "text") {
+				t.Errorf("expected a plain text body to be requested")
+			}
+			fmt.Fprint(w, messageJSON)
+		case "/me/messages/gmail-id":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"code":"ErrorInvalidIdMalformed","message":"Id is malformed."}}`)
+		default:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	})
+	ctx := ctxkeys.WithUserID(context.Background(), "u1")
+	msg, err := p.FetchMessage(ctx, nil, "AAMk1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Body != "Hi there, full text" || msg.Recipient != "<me@example.com>" || len(msg.Cc) != 1 || msg.Cc[0].Name != "Bob" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	if _, err := p.FetchMessage(ctx, nil, "gmail-id"); !errors.Is(err, provider.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a foreign ID, got %v", err)
+	}
+	_, err = p.FetchMessage(ctx, nil, "busy")
+	if !errors.Is(err, provider.ErrRateLimited) || provider.RetryAfter(err) != 7*time.Second {
+		t.Errorf("expected a rate limit with Retry-After, got %v", err)
+	}
+	if _, err := p.FetchMessage(ctxkeys.WithUserID(context.Background(), "nobody"), nil, "AAMk1"); !errors.Is(err, provider.ErrAuthExpired) {
+		t.Errorf("expected ErrAuthExpired without a linked account, got %v", err)
+	}
+}
+
+func TestRefreshStoresToken(t *testing.T) {
+	p, tokens := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"fresh","refresh_token":"refresh2","token_type":"Bearer","expires_in":3600}`)
+		case "/me":
+			fmt.Fprint(w, `{"mail":null,"userPrincipalName":"Me@Contoso.com"}`)
+		default:
+			if r.Header.Get("Authorization") != "Bearer fresh" {
+				t.Errorf("expected the refreshed token, got %q", r.Header.Get("Authorization"))
+			}
+			fmt.Fprint(w, `{"value":[]}`)
+		}
+	})
+	tokens.tokens["u1"].Expiry = time.Now().Add(-time.Minute)
+	if _, err := p.FetchSummaries(context.Background(), "u1", gmail.FetchParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if tokens.tokens["u1"].AccessToken != "fresh" || tokens.saved != "me@contoso.com" {
+		t.Errorf("expected the refreshed token saved under the account, got %+v under %q", tokens.tokens["u1"], tokens.saved)
+	}
+}
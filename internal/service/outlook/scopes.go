@@ -0,0 +1,33 @@
+package outlook
+
+import (
+	"github.com/desponda/inbox-whisperer/internal/config"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/microsoft"
+)
+
+// Microsoft Graph scopes the app asks for when linking a mailbox
+const (
+	// ScopeOfflineAccess grants the refresh token that lets syncing continue without the user
+	ScopeOfflineAccess = "offline_access"
+	ScopeUserRead      = "User.Read"
+	ScopeMailRead      = "Mail.Read"
+)
+
+// LinkScopes are requested when a user links an Outlook mailbox
+var LinkScopes = []string{"openid", "email", ScopeOfflineAccess, ScopeUserRead, ScopeMailRead}
+
+// NewOAuthConfig returns the OAuth2 config of the app users link Outlook mailboxes through
+func NewOAuthConfig(cfg config.OutlookConfig) *oauth2.Config {
+	tenant := cfg.Tenant
+	if tenant == "" {
+		tenant = "common"
+	}
+	return &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       LinkScopes,
+		Endpoint:     microsoft.AzureADEndpoint(tenant),
+	}
+}
@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen marks an attempt the breaker refused; it is not a new provider failure
+var ErrCircuitOpen = errors.New("provider circuit open")
+
+// DefaultBreakerCooldown is how long a breaker stays open when the provider gave no Retry-After
+const DefaultBreakerCooldown = 5 * time.Minute
+
+// BreakerState is whether a user's provider calls are currently allowed
+type BreakerState string
+
+const (
+	BreakerClosed BreakerState = "closed"
+	BreakerOpen   BreakerState = "open"
+)
+
+// BreakerStatus is a point-in-time view of one user's breaker
+type BreakerStatus struct {
+	State BreakerState `json:"state"`
+	// Reason is the failure that opened the breaker: "rate_limited" or "auth_expired"
+	Reason string `json:"reason,omitempty"`
+	// OpenUntil is when the next attempt is let through while open
+	OpenUntil *time.Time `json:"open_until,omitempty"`
+	// ConsecutiveFailures counts account-wide failures since the last success
+	ConsecutiveFailures int `json:"consecutive_failures"`
+}
+
+// Breaker stops background work for a user after an account-wide provider failure (rate
+// limiting or expired authorization), so a failing account is not hammered on every tick.
+// It opens on such a failure, lets one attempt through once the cooldown passes, and closes
+// on the next success. Failures that only affect single messages do not trip it.
+// A nil *Breaker allows everything.
+type Breaker struct {
+	// Cooldown applies when the error carries no RetryAfter; DefaultBreakerCooldown if zero
+	Cooldown time.Duration
+
+	mu    sync.Mutex
+	users map[string]*breakerEntry
+	now   func() time.Time
+}
+
+type breakerEntry struct {
+	reason    string
+	openUntil time.Time
+	failures  int
+}
+
+func NewBreaker() *Breaker {
+	return &Breaker{users: make(map[string]*breakerEntry), now: time.Now}
+}
+
+// Allow returns an error wrapping ErrCircuitOpen, and the kind of failure that opened the
+// breaker, while it is open
+func (b *Breaker) Allow(userID string) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e, ok := b.users[userID]
+	if !ok || !b.now().Before(e.openUntil) {
+		return nil
+	}
+	kind := ErrRateLimited
+	if e.reason == "auth_expired" {
+		kind = ErrAuthExpired
+	}
+	return &Error{Kind: kind, Err: fmt.Errorf("%w until %s", ErrCircuitOpen, e.openUntil.Format(time.RFC3339)), RetryAfter: e.openUntil.Sub(b.now())}
+}
+
+// Record updates the breaker with the outcome of an attempt. nil closes it; rate limiting
+// and expired authorization open it; other errors leave it as it is.
+func (b *Breaker) Record(userID string, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		delete(b.users, userID)
+		return
+	}
+	var reason string
+	switch {
+	case errors.Is(err, ErrAuthExpired):
+		reason = "auth_expired"
+	case errors.Is(err, ErrRateLimited):
+		reason = "rate_limited"
+	default:
+		return
+	}
+	cooldown := RetryAfter(err)
+	if cooldown <= 0 {
+		cooldown = b.Cooldown
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	e, ok := b.users[userID]
+	if !ok {
+		e = &breakerEntry{}
+		b.users[userID] = e
+	}
+	e.reason = reason
+	e.failures++
+	e.openUntil = b.now().Add(cooldown)
+}
+
+// Status reports the user's breaker. It keeps the last failure's reason while half-open, so
+// callers can still tell an account that has not recovered yet.
+func (b *Breaker) Status(userID string) BreakerStatus {
+	if b == nil {
+		return BreakerStatus{State: BreakerClosed}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e, ok := b.users[userID]
+	if !ok {
+		return BreakerStatus{State: BreakerClosed}
+	}
+	status := BreakerStatus{State: BreakerClosed, Reason: e.reason, ConsecutiveFailures: e.failures}
+	if b.now().Before(e.openUntil) {
+		until := e.openUntil
+		status.State = BreakerOpen
+		status.OpenUntil = &until
+	}
+	return status
+}
@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	b := NewBreaker()
+	b.now = func() time.Time { return now }
+
+	b.Record("u1", &Error{Kind: ErrTemporary, Err: errors.New("503")})
+	if err := b.Allow("u1"); err != nil {
+		t.Fatalf("a per-message failure must not open the breaker: %v", err)
+	}
+
+	b.Record("u1", &Error{Kind: ErrRateLimited, Err: errors.New("429"), RetryAfter: time.Minute})
+	err := b.Allow("u1")
+	if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected an open rate-limited breaker, got %v", err)
+	}
+	if got := RetryAfter(err); got != time.Minute {
+		t.Errorf("expected RetryAfter 1m, got %v", got)
+	}
+	if b.Allow("u2") != nil {
+		t.Error("breakers are per user")
+	}
+	st := b.Status("u1")
+	if st.State != BreakerOpen || st.Reason != "rate_limited" || st.ConsecutiveFailures != 1 || st.OpenUntil == nil {
+		t.Errorf("unexpected status %+v", st)
+	}
+
+	// Half-open after the cooldown: one attempt goes through, the reason is kept
+	now = now.Add(time.Minute)
+	if err := b.Allow("u1"); err != nil {
+		t.Fatalf("expected the attempt after cooldown to pass, got %v", err)
+	}
+	if st := b.Status("u1"); st.State != BreakerClosed || st.Reason != "rate_limited" {
+		t.Errorf("unexpected half-open status %+v", st)
+	}
+
+	b.Record("u1", &Error{Kind: ErrAuthExpired, Err: errors.New("401")})
+	if err := b.Allow("u1"); !errors.Is(err, ErrAuthExpired) {
+		t.Fatalf("expected auth expired, got %v", err)
+	}
+	if st := b.Status("u1"); st.ConsecutiveFailures != 2 || st.OpenUntil.Sub(now) != DefaultBreakerCooldown {
+		t.Errorf("unexpected status %+v", st)
+	}
+
+	b.Record("u1", nil)
+	if st := b.Status("u1"); st != (BreakerStatus{State: BreakerClosed}) {
+		t.Errorf("expected success to reset the breaker, got %+v", st)
+	}
+}
+
+func TestNilBreakerAllowsEverything(t *testing.T) {
+	var b *Breaker
+	b.Record("u1", ErrRateLimited)
+	if err := b.Allow("u1"); err != nil {
+		t.Fatal(err)
+	}
+	if st := b.Status("u1"); st.State != BreakerClosed {
+		t.Errorf("unexpected status %+v", st)
+	}
+}
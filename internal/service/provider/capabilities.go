@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"golang.org/x/oauth2"
+)
+
+// Capabilities advertises the optional features a provider supports, so handlers
+// and clients can hide or reject operations instead of failing at the provider.
+type Capabilities struct {
+	Labels      bool `json:"labels"`       // labels can be listed
+	LabelColors bool `json:"label_colors"` // labels carry colors
+	EditLabels  bool `json:"edit_labels"`  // user labels can be created, renamed and recolored
+	ApplyLabels bool `json:"apply_labels"` // labels can be added to messages
+	MuteThreads bool `json:"mute_threads"` // threads can be muted at the provider
+	Trash       bool `json:"trash"`        // messages can be moved to the trash
+	Star        bool `json:"star"`         // messages can be starred
+	// Passthrough: summaries can be listed straight from the provider, paged by its own page
+	// tokens, without caching anything (see PassthroughProvider)
+	Passthrough bool `json:"passthrough"`
+}
+
+// LabelProvider is implemented by providers that expose labels
+type LabelProvider interface {
+	ListLabels(ctx context.Context, token *oauth2.Token) ([]*models.Label, error)
+	UpdateLabel(ctx context.Context, token *oauth2.Token, labelID string, update models.LabelUpdate) (*models.Label, error)
+	CreateLabel(ctx context.Context, token *oauth2.Token, create models.LabelCreate) (*models.Label, error)
+	ApplyLabel(ctx context.Context, token *oauth2.Token, messageID, labelID string) error
+	RemoveLabel(ctx context.Context, token *oauth2.Token, messageID, labelID string) error
+}
+
+// MessageActionProvider is implemented by providers that can archive messages and change their
+// read state
+type MessageActionProvider interface {
+	Archive(ctx context.Context, token *oauth2.Token, messageID string) error
+	MarkRead(ctx context.Context, token *oauth2.Token, messageID string) error
+	MarkUnread(ctx context.Context, token *oauth2.Token, messageID string) error
+}
+
+// TrashProvider is implemented by providers that can move messages to the trash, where the
+// provider deletes them for good after its retention period
+type TrashProvider interface {
+	Trash(ctx context.Context, token *oauth2.Token, messageID string) error
+}
+
+// StarProvider is implemented by providers that can star (flag) messages
+type StarProvider interface {
+	SetStarred(ctx context.Context, token *oauth2.Token, messageID string, starred bool) error
+}
+
+// PassthroughProvider is implemented by providers that can serve message lists without the
+// local cache, for users who disabled caching. Nothing may be persisted while listing.
+type PassthroughProvider interface {
+	ListPassthrough(ctx context.Context, token *oauth2.Token, pageToken string, limit int) (*models.SummaryPage, error)
+}
+
+// ThreadMuteProvider is implemented by providers with native thread muting, which keeps
+// future messages in the thread out of the inbox without our help
+type ThreadMuteProvider interface {
+	MuteThread(ctx context.Context, token *oauth2.Token, threadID string) error
+	UnmuteThread(ctx context.Context, token *oauth2.Token, threadID string) error
+}
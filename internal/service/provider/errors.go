@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"errors"
+	"time"
+)
+
+// Provider error taxonomy. Provider implementations classify their native errors
+// into one of these sentinels so handlers and the sync loop can pick status codes
+// and retry behavior without knowing which provider produced them.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrRateLimited = errors.New("provider rate limit exceeded")
+	ErrAuthExpired = errors.New("provider authorization expired")
+	ErrTemporary   = errors.New("temporary provider error")
+	// ErrInvalidRequest means the provider rejected the request itself (e.g. an unsupported label color)
+	ErrInvalidRequest = errors.New("invalid provider request")
+	// ErrConflict means the resource already exists at the provider (e.g. a label name is taken)
+	ErrConflict = errors.New("conflicts with existing provider resource")
+	// ErrUnsupported means the provider does not offer the operation; see Capabilities
+	ErrUnsupported = errors.New("operation not supported by provider")
+	// ErrSyncing means the cache has nothing to serve yet because the first sync is still running
+	ErrSyncing = errors.New("mailbox is still syncing")
+)
+
+// Error wraps a provider's native error with its classification.
+// errors.Is matches both the Kind sentinel and the underlying error.
+type Error struct {
+	Kind error
+	Err  error
+	// RetryAfter is the provider's requested back-off, if it sent one
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Kind.Error()
+	}
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// IsRetryable reports whether the operation may succeed if attempted again later
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTemporary)
+}
+
+// RetryAfter returns the back-off requested by the provider, or zero if none was given
+func RetryAfter(err error) time.Duration {
+	var pErr *Error
+	if errors.As(err, &pErr) {
+		return pErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestError_IsAndRetryable(t *testing.T) {
+	native := errors.New("googleapi: Error 429: Too many requests")
+	err := fmt.Errorf("list messages: %w", &Error{Kind: ErrRateLimited, Err: native, RetryAfter: 30 * time.Second})
+
+	if !errors.Is(err, ErrRateLimited) {
+		t.Error("expected errors.Is to match the Kind sentinel")
+	}
+	if !errors.Is(err, native) {
+		t.Error("expected errors.Is to match the underlying error")
+	}
+	if !IsRetryable(err) {
+		t.Error("expected rate limit to be retryable")
+	}
+	if got := RetryAfter(err); got != 30*time.Second {
+		t.Errorf("expected RetryAfter 30s, got %v", got)
+	}
+
+	authErr := &Error{Kind: ErrAuthExpired, Err: errors.New("invalid_grant")}
+	if IsRetryable(authErr) {
+		t.Error("expected expired auth not to be retryable")
+	}
+	if RetryAfter(authErr) != 0 {
+		t.Error("expected zero RetryAfter when the provider sent none")
+	}
+	if authErr.Error() != "provider authorization expired: invalid_grant" {
+		t.Errorf("unexpected message: %q", authErr.Error())
+	}
+}
@@ -0,0 +1,39 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMissingScope means the account did not grant a permission the operation needs; the user
+// must consent again with the broader scope
+var ErrMissingScope = errors.New("missing OAuth scope")
+
+// Feature is a group of operations gated on the same OAuth scopes
+type Feature string
+
+const (
+	FeatureRead Feature = "read"
+	// FeatureModify covers changing labels on messages, archiving, marking read and editing labels
+	FeatureModify   Feature = "modify"
+	FeatureSend     Feature = "send"
+	FeatureCalendar Feature = "calendar"
+)
+
+// ScopeError reports which scopes a feature is missing and where the user can grant them.
+// errors.Is matches ErrMissingScope.
+type ScopeError struct {
+	Feature Feature
+	Missing []string
+	// ConsentURL starts the OAuth flow again asking for the missing scopes
+	ConsentURL string
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("%s: %s requires %s", ErrMissingScope, e.Feature, strings.Join(e.Missing, " "))
+}
+
+func (e *ScopeError) Unwrap() error {
+	return ErrMissingScope
+}
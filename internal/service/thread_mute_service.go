@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"golang.org/x/oauth2"
+)
+
+// ThreadMuteService mutes conversations. Providers with native muting handle future messages
+// themselves; for the rest, the thread's cached messages are archived here and the rules engine
+// archives new ones during sync (see rules.Engine.Muted).
+type ThreadMuteService struct {
+	provider EmailProvider
+	repo     data.MutedThreadRepository
+}
+
+func NewThreadMuteService(p EmailProvider, repo data.MutedThreadRepository) *ThreadMuteService {
+	return &ThreadMuteService{provider: p, repo: repo}
+}
+
+// Mute records the thread as muted and takes its current messages out of the inbox.
+// Muting is idempotent, so a failed archive can be retried by muting again.
+func (s *ThreadMuteService) Mute(ctx context.Context, userID string, token *oauth2.Token, threadID string) (*models.MutedThread, error) {
+	muter, native := s.provider.(provider.ThreadMuteProvider)
+	actions, canArchive := s.provider.(provider.MessageActionProvider)
+	if !native && !canArchive {
+		return nil, provider.ErrUnsupported
+	}
+	muted, err := s.repo.Mute(ctx, userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+	if native {
+		return muted, muter.MuteThread(ctx, token, threadID)
+	}
+	ids, err := s.repo.ThreadMessageIDs(ctx, userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if err := actions.Archive(ctx, token, id); err != nil {
+			return nil, err
+		}
+	}
+	return muted, nil
+}
+
+// Unmute stops archiving new messages in the thread; messages already archived stay archived.
+// Returns data.ErrNotFound if the thread was not muted.
+func (s *ThreadMuteService) Unmute(ctx context.Context, userID string, token *oauth2.Token, threadID string) error {
+	if err := s.repo.Unmute(ctx, userID, threadID); err != nil {
+		return err
+	}
+	if muter, ok := s.provider.(provider.ThreadMuteProvider); ok {
+		return muter.UnmuteThread(ctx, token, threadID)
+	}
+	return nil
+}
+
+// IsMuted reports whether the user muted the thread
+func (s *ThreadMuteService) IsMuted(ctx context.Context, userID, threadID string) (bool, error) {
+	return s.repo.IsMuted(ctx, userID, threadID)
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"golang.org/x/oauth2"
+)
+
+type fakeMutedRepo struct {
+	muted   map[string]bool
+	threads map[string][]string
+}
+
+func (f *fakeMutedRepo) Mute(ctx context.Context, userID, threadID string) (*models.MutedThread, error) {
+	f.muted[threadID] = true
+	return &models.MutedThread{UserID: userID, ThreadID: threadID}, nil
+}
+
+func (f *fakeMutedRepo) Unmute(ctx context.Context, userID, threadID string) error {
+	if !f.muted[threadID] {
+		return data.ErrNotFound
+	}
+	delete(f.muted, threadID)
+	return nil
+}
+
+func (f *fakeMutedRepo) IsMuted(ctx context.Context, userID, threadID string) (bool, error) {
+	return f.muted[threadID], nil
+}
+
+func (f *fakeMutedRepo) ThreadMessageIDs(ctx context.Context, userID, threadID string) ([]string, error) {
+	return f.threads[threadID], nil
+}
+
+type fakeMuteProvider struct {
+	fakeActionProvider
+	muted []string
+}
+
+func (p *fakeMuteProvider) MuteThread(ctx context.Context, token *oauth2.Token, threadID string) error {
+	p.muted = append(p.muted, threadID)
+	return nil
+}
+
+func (p *fakeMuteProvider) UnmuteThread(ctx context.Context, token *oauth2.Token, threadID string) error {
+	return nil
+}
+
+func TestThreadMuteService_ArchivesWithoutNativeMute(t *testing.T) {
+	repo := &fakeMutedRepo{muted: map[string]bool{}, threads: map[string][]string{"t1": {"m1", "m2"}}}
+	p := &fakeActionProvider{}
+	svc := NewThreadMuteService(p, repo)
+
+	if _, err := svc.Mute(context.Background(), "u1", nil, "t1"); err != nil {
+		t.Fatalf("Mute failed: %v", err)
+	}
+	if len(p.archived) != 2 {
+		t.Errorf("expected the thread's messages to be archived, got %v", p.archived)
+	}
+	if muted, _ := svc.IsMuted(context.Background(), "u1", "t1"); !muted {
+		t.Error("expected t1 to be muted")
+	}
+	if err := svc.Unmute(context.Background(), "u1", nil, "t1"); err != nil {
+		t.Fatalf("Unmute failed: %v", err)
+	}
+	if err := svc.Unmute(context.Background(), "u1", nil, "t1"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestThreadMuteService_UsesNativeMute(t *testing.T) {
+	repo := &fakeMutedRepo{muted: map[string]bool{}, threads: map[string][]string{"t1": {"m1"}}}
+	p := &fakeMuteProvider{}
+	if _, err := NewThreadMuteService(p, repo).Mute(context.Background(), "u1", nil, "t1"); err != nil {
+		t.Fatalf("Mute failed: %v", err)
+	}
+	if len(p.muted) != 1 || len(p.archived) != 0 {
+		t.Errorf("expected the provider to mute natively, got muted=%v archived=%v", p.muted, p.archived)
+	}
+
+	// Providers that can neither mute nor archive are rejected before anything is recorded
+	repo = &fakeMutedRepo{muted: map[string]bool{}}
+	if _, err := NewThreadMuteService(&fakeLabelProvider{}, repo).Mute(context.Background(), "u1", nil, "t1"); !errors.Is(err, provider.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if repo.muted["t1"] {
+		t.Error("expected unsupported mute not to be recorded")
+	}
+}
@@ -1,14 +1,14 @@
 package session
 
 import (
-	"context"
+	"encoding/json"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
-	"encoding/json"
 )
 
 // ClearSession expires the session_id cookie and removes the session from the store
@@ -34,14 +34,6 @@ func ClearSession(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-type ctxKey int
-
-const (
-	userIDKey ctxKey = iota
-	tokenKey
-	sessionIDKey
-)
-
 // InMemoryStore is a demo in-memory session/token store
 var store = struct {
 	sync.RWMutex
@@ -83,21 +75,16 @@ func Middleware(next http.Handler) http.Handler {
 		store.RUnlock()
 
 		ctx := r.Context()
-		ctx = context.WithValue(ctx, sessionIDKey, sessionID)
+		ctx = ctxkeys.WithSessionID(ctx, sessionID)
 		if ok {
-			ctx = context.WithValue(ctx, userIDKey, data.UserID)
-			ctx = context.WithValue(ctx, tokenKey, data.Token)
+			ctx = ctxkeys.WithUserID(ctx, data.UserID)
+			ctx = ctxkeys.WithSessionToken(ctx, data.Token)
 		}
 		// Pass updated context to the next handler
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// ContextWithUserID returns a new context with the user ID set. Used for testing and service logic.
-func ContextWithUserID(ctx context.Context, userID string) context.Context {
-	return context.WithValue(ctx, userIDKey, userID)
-}
-
 func SetSession(w http.ResponseWriter, r *http.Request, userID, token string) {
 
 	// Log all cookies received
@@ -132,11 +119,6 @@ func SetSession(w http.ResponseWriter, r *http.Request, userID, token string) {
 	store.Unlock()
 }
 
-func GetToken(ctx context.Context) string {
-	tok, _ := ctx.Value(tokenKey).(string)
-	return tok
-}
-
 // SetSessionValue sets a custom key-value pair in the session (e.g., CSRF state)
 func SetSessionValue(w http.ResponseWriter, r *http.Request, key, value string) {
 
@@ -146,7 +128,7 @@ func SetSessionValue(w http.ResponseWriter, r *http.Request, key, value string)
 		sessionID = cookie.Value
 	} else {
 		// Try context if cookie not found
-		if sid, ok := r.Context().Value(sessionIDKey).(string); ok && sid != "" {
+		if sid := ctxkeys.SessionID(r.Context()); sid != "" {
 			sessionID = sid
 
 		} else {
@@ -177,7 +159,7 @@ func GetSessionValue(r *http.Request, key string) string {
 		sessionID = cookie.Value
 	} else {
 		// Try context if cookie not found
-		if sid, ok := r.Context().Value(sessionIDKey).(string); ok && sid != "" {
+		if sid := ctxkeys.SessionID(r.Context()); sid != "" {
 			sessionID = sid
 
 		} else {
@@ -193,9 +175,3 @@ func GetSessionValue(r *http.Request, key string) string {
 	}
 	return ""
 }
-
-// GetUserID gets the user ID from context
-func GetUserID(ctx context.Context) string {
-	uid, _ := ctx.Value(userIDKey).(string)
-	return uid
-}
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -75,8 +76,8 @@ func TestSessionMiddlewareAndSetSession(t *testing.T) {
 		}
 	})
 	mux.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
-		uid := GetUserID(r.Context())
-		tok := GetToken(r.Context())
+		uid := ctxkeys.UserID(r.Context())
+		tok := ctxkeys.SessionToken(r.Context())
 		if _, err := w.Write([]byte(uid + ":" + tok)); err != nil {
 			t.Fatalf("failed to write response: %v", err)
 		}
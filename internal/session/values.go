@@ -0,0 +1,218 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Keys of the typed session values; use the accessors below rather than the raw keys
+const (
+	keyOAuthState = "oauth_state"
+	keyOAuthToken = "oauth_token"
+	keyReturnTo   = "return_to"
+	keyCacheKey   = "cache_key"
+	keyChallenge  = "webauthn_challenge"
+	keyStepUp     = "step_up"
+	keyReadOnly   = "read_only"
+	// keyReadOnlyLogin holds the read-only choice of a login in progress, applied by the callback
+	keyReadOnlyLogin = "read_only_login"
+)
+
+// valueVersion is the encoding version written by the typed accessors. Bump it when a value's
+// shape changes and keep decoding the older versions, so sessions survive a deploy.
+const valueVersion = 1
+
+// envelope is how typed values are stored in the string map. Decoding into the caller's type
+// keeps numbers as the declared type instead of float64.
+type envelope struct {
+	V    int             `json:"v"`
+	Data json.RawMessage `json:"data"`
+}
+
+func setValue(w http.ResponseWriter, r *http.Request, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode session value %s: %w", key, err)
+	}
+	raw, err := json.Marshal(envelope{V: valueVersion, Data: data})
+	if err != nil {
+		return fmt.Errorf("encode session value %s: %w", key, err)
+	}
+	SetSessionValue(w, r, key, string(raw))
+	return nil
+}
+
+// getValue decodes the value at key into dst and reports whether one was set. Values stored
+// before versioning are decoded as bare JSON.
+func getValue(r *http.Request, key string, dst any) (bool, error) {
+	raw := GetSessionValue(r, key)
+	if raw == "" {
+		return false, nil
+	}
+	var env envelope
+	if err := json.Unmarshal([]byte(raw), &env); err == nil && env.V > 0 && env.Data != nil {
+		if env.V > valueVersion {
+			return false, fmt.Errorf("session value %s has version %d, newer than supported %d", key, env.V, valueVersion)
+		}
+		return true, json.Unmarshal(env.Data, dst)
+	}
+	return true, json.Unmarshal([]byte(raw), dst)
+}
+
+// SetState stores the OAuth state issued at login for the callback to check
+func SetState(w http.ResponseWriter, r *http.Request, state string) {
+	// A string always encodes
+	_ = setValue(w, r, keyOAuthState, state)
+}
+
+// GetState returns the OAuth state stored by SetState, or "" if there is none
+func GetState(r *http.Request) string {
+	var state string
+	if _, err := getValue(r, keyOAuthState, &state); err != nil {
+		// Sessions from before versioning hold the state as a plain string
+		if raw := GetSessionValue(r, keyOAuthState); !json.Valid([]byte(raw)) {
+			return raw
+		}
+		return ""
+	}
+	return state
+}
+
+// SetOAuthToken stores the full provider token in the session
+func SetOAuthToken(w http.ResponseWriter, r *http.Request, tok *oauth2.Token) error {
+	return setValue(w, r, keyOAuthToken, tok)
+}
+
+// GetOAuthToken returns the token stored by SetOAuthToken, or nil if there is none
+func GetOAuthToken(r *http.Request) (*oauth2.Token, error) {
+	var tok oauth2.Token
+	ok, err := getValue(r, keyOAuthToken, &tok)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &tok, nil
+}
+
+// SetReturnTo stores where to send the user after login; the caller validates it
+func SetReturnTo(w http.ResponseWriter, r *http.Request, returnTo string) {
+	// A string always encodes
+	_ = setValue(w, r, keyReturnTo, returnTo)
+}
+
+// GetReturnTo returns the target stored by SetReturnTo, or "" if there is none
+func GetReturnTo(r *http.Request) string {
+	var returnTo string
+	if _, err := getValue(r, keyReturnTo, &returnTo); err != nil {
+		return ""
+	}
+	return returnTo
+}
+
+// SetEncryptionKey stores the user's unlocked cache key (see package e2ee). Sessions are held in
+// memory only, so the key is never written to disk.
+func SetEncryptionKey(w http.ResponseWriter, r *http.Request, key []byte) {
+	// A byte slice always encodes
+	_ = setValue(w, r, keyCacheKey, key)
+}
+
+// GetEncryptionKey returns the key stored by SetEncryptionKey, or nil while the cache is locked
+func GetEncryptionKey(r *http.Request) []byte {
+	var key []byte
+	if _, err := getValue(r, keyCacheKey, &key); err != nil {
+		return nil
+	}
+	return key
+}
+
+// ClearEncryptionKey locks the cache again for this session
+func ClearEncryptionKey(w http.ResponseWriter, r *http.Request) {
+	SetSessionValue(w, r, keyCacheKey, "")
+}
+
+// Challenge is a WebAuthn challenge issued to this session, kept until the ceremony it was
+// issued for completes
+type Challenge struct {
+	UserID string `json:"user_id"`
+	// Ceremony is the client data type the answer must carry: "webauthn.create" or "webauthn.get"
+	Ceremony  string    `json:"ceremony"`
+	Value     string    `json:"value"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SetChallenge stores c, replacing any challenge issued earlier
+func SetChallenge(w http.ResponseWriter, r *http.Request, c Challenge) {
+	// The struct always encodes
+	_ = setValue(w, r, keyChallenge, c)
+}
+
+// TakeChallenge returns the challenge stored by SetChallenge and removes it, so each challenge
+// is answered at most once. Returns nil if there is none.
+func TakeChallenge(w http.ResponseWriter, r *http.Request) *Challenge {
+	var c Challenge
+	ok, err := getValue(r, keyChallenge, &c)
+	if !ok {
+		return nil
+	}
+	SetSessionValue(w, r, keyChallenge, "")
+	if err != nil {
+		return nil
+	}
+	return &c
+}
+
+// StepUp records that the session's user recently confirmed their identity with a passkey
+type StepUp struct {
+	UserID     string    `json:"user_id"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// SetStepUp marks the session as recently verified
+func SetStepUp(w http.ResponseWriter, r *http.Request, s StepUp) {
+	// The struct always encodes
+	_ = setValue(w, r, keyStepUp, s)
+}
+
+// GetStepUp returns the marker stored by SetStepUp, or nil if the session was never verified
+func GetStepUp(r *http.Request) *StepUp {
+	var s StepUp
+	if ok, err := getValue(r, keyStepUp, &s); !ok || err != nil {
+		return nil
+	}
+	return &s
+}
+
+// SetReadOnlyLogin records whether the login being started asked for a read-only session
+func SetReadOnlyLogin(w http.ResponseWriter, r *http.Request, readOnly bool) {
+	// A bool always encodes
+	_ = setValue(w, r, keyReadOnlyLogin, readOnly)
+}
+
+// GetReadOnlyLogin returns the choice stored by SetReadOnlyLogin, false if there is none
+func GetReadOnlyLogin(r *http.Request) bool {
+	var readOnly bool
+	if _, err := getValue(r, keyReadOnlyLogin, &readOnly); err != nil {
+		return false
+	}
+	return readOnly
+}
+
+// SetReadOnly flags the session as read-only. The flag lasts until the session is replaced
+// by the next login.
+func SetReadOnly(w http.ResponseWriter, r *http.Request) {
+	// A bool always encodes
+	_ = setValue(w, r, keyReadOnly, true)
+}
+
+// IsReadOnly reports whether the session was flagged by SetReadOnly
+func IsReadOnly(r *http.Request) bool {
+	var readOnly bool
+	if _, err := getValue(r, keyReadOnly, &readOnly); err != nil {
+		// Fail closed: a value that cannot be read is treated as set
+		return GetSessionValue(r, keyReadOnly) != ""
+	}
+	return readOnly
+}
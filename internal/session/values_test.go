@@ -0,0 +1,99 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// newValuesRequest returns a request bound to a fresh stored session
+func newValuesRequest(t *testing.T, values map[string]string) *http.Request {
+	t.Helper()
+	sessionID := "values-" + t.Name()
+	store.Lock()
+	store.data[sessionID] = &SessionData{Values: values}
+	store.Unlock()
+	t.Cleanup(func() {
+		store.Lock()
+		delete(store.data, sessionID)
+		store.Unlock()
+	})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+	return r
+}
+
+func TestOAuthTokenRoundTrip(t *testing.T) {
+	r := newValuesRequest(t, map[string]string{})
+	if tok, err := GetOAuthToken(r); tok != nil || err != nil {
+		t.Fatalf("expected no token, got %v, %v", tok, err)
+	}
+	expiry := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	want := &oauth2.Token{AccessToken: "a", RefreshToken: "r", TokenType: "Bearer", Expiry: expiry, ExpiresIn: 3599}
+	if err := SetOAuthToken(httptest.NewRecorder(), r, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetOAuthToken(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccessToken != "a" || got.RefreshToken != "r" || !got.Expiry.Equal(expiry) || got.ExpiresIn != 3599 {
+		t.Errorf("unexpected token %+v", got)
+	}
+}
+
+func TestStateVersions(t *testing.T) {
+	tests := []struct {
+		name, raw, want string
+	}{
+		{"current", `{"v":1,"data":"abc"}`, "abc"},
+		{"legacy plain string", "abc-_=", "abc-_="},
+		{"legacy bare JSON", `"abc"`, "abc"},
+		{"newer version", `{"v":99,"data":"abc"}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newValuesRequest(t, map[string]string{keyOAuthState: tt.raw})
+			if got := GetState(r); got != tt.want {
+				t.Errorf("GetState = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	r := newValuesRequest(t, map[string]string{})
+	SetState(httptest.NewRecorder(), r, "xyz")
+	if got := GetState(r); got != "xyz" {
+		t.Errorf("GetState after SetState = %q", got)
+	}
+}
+
+func TestChallengeIsTakenOnce(t *testing.T) {
+	r := newValuesRequest(t, map[string]string{})
+	w := httptest.NewRecorder()
+	if c := TakeChallenge(w, r); c != nil {
+		t.Fatalf("expected no challenge, got %+v", c)
+	}
+	want := Challenge{UserID: "u1", Ceremony: "webauthn.get", Value: "abc", ExpiresAt: time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)}
+	SetChallenge(w, r, want)
+	got := TakeChallenge(w, r)
+	if got == nil || *got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if c := TakeChallenge(w, r); c != nil {
+		t.Errorf("expected the challenge to be gone, got %+v", c)
+	}
+}
+
+func TestStepUpRoundTrip(t *testing.T) {
+	r := newValuesRequest(t, map[string]string{})
+	if s := GetStepUp(r); s != nil {
+		t.Fatalf("expected no step-up, got %+v", s)
+	}
+	at := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	SetStepUp(httptest.NewRecorder(), r, StepUp{UserID: "u1", VerifiedAt: at})
+	if s := GetStepUp(r); s == nil || s.UserID != "u1" || !s.VerifiedAt.Equal(at) {
+		t.Errorf("unexpected step-up %+v", s)
+	}
+}
@@ -0,0 +1,290 @@
+// Package status backs the public status page: it checks the health of the deployment's main
+// components once a minute, keeps the results for rolling uptime percentages, and reports both
+// coarsely enough to be served without authentication.
+package status
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ai"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/health"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// Component states, from best to worst. Unknown is reported before the first check.
+const (
+	Operational = "operational"
+	Degraded    = "degraded"
+	Down        = "down"
+	Unknown     = "unknown"
+)
+
+// Components reported by the server
+const (
+	ComponentAPI      = "api"
+	ComponentSync     = "sync"
+	ComponentAI       = "ai"
+	ComponentProvider = "provider"
+)
+
+// Defaults for the Service's pacing
+const (
+	DefaultInterval  = time.Minute
+	DefaultRetention = 90 * 24 * time.Hour
+	DefaultCacheFor  = 30 * time.Second
+)
+
+// checkTimeout bounds each check
+const checkTimeout = 5 * time.Second
+
+// Window is a span the uptime is computed over
+type Window struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Windows are the uptime spans reported, shortest first
+var Windows = []Window{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+	{"90d", 90 * 24 * time.Hour},
+}
+
+// Check returns a component's state; "" means the component is not in use and is left out
+type Check func(ctx context.Context) string
+
+// ComponentStatus is one component on the status page
+type ComponentStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Uptime is, per window name, the percentage of checks that did not find the component
+	// down. Windows without checks are left out.
+	Uptime map[string]float64 `json:"uptime"`
+}
+
+// Report is the status page: the overall state, the worst of the components', and each component
+type Report struct {
+	Status     string            `json:"status"`
+	Components []ComponentStatus `json:"components"`
+	CheckedAt  *time.Time        `json:"checked_at,omitempty"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Service runs the checks and builds the report
+type Service struct {
+	repo   data.StatusCheckRepository
+	checks []namedCheck
+
+	// Interval is how often the checks run
+	Interval time.Duration
+	// Retention is how long check results are kept
+	Retention time.Duration
+	// CacheFor is how long a report is reused, so the public endpoint cannot load the database
+	CacheFor time.Duration
+	// Health, if set, receives heartbeats and check run outcomes
+	Health *health.Worker
+
+	mu        sync.Mutex
+	states    map[string]string
+	checkedAt time.Time
+	cached    *Report
+	cachedAt  time.Time
+
+	now func() time.Time
+}
+
+func NewService(repo data.StatusCheckRepository) *Service {
+	return &Service{
+		repo:      repo,
+		Interval:  DefaultInterval,
+		Retention: DefaultRetention,
+		CacheFor:  DefaultCacheFor,
+		states:    map[string]string{},
+		now:       time.Now,
+	}
+}
+
+// Add registers a component's check; components are reported in the order they were added
+func (s *Service) Add(name string, check Check) {
+	s.checks = append(s.checks, namedCheck{name, check})
+}
+
+// Start runs the checks until ctx is cancelled
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			s.RunChecks(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunChecks runs every check, stores the results and drops those past the retention. A failure
+// to store them is logged; the current states are still reported.
+func (s *Service) RunChecks(ctx context.Context) {
+	s.Health.Beat()
+	states := make(map[string]string, len(s.checks))
+	for _, c := range s.checks {
+		cctx, cancel := context.WithTimeout(ctx, checkTimeout)
+		if state := c.check(cctx); state != "" {
+			states[c.name] = state
+		}
+		cancel()
+	}
+	now := s.now().UTC()
+	s.mu.Lock()
+	s.states, s.checkedAt, s.cached = states, now, nil
+	s.mu.Unlock()
+
+	err := s.repo.Record(ctx, now, states)
+	if err != nil {
+		log.Error().Err(err).Msg("status: failed to record checks")
+	} else if _, err = s.repo.Prune(ctx, now.Add(-s.Retention)); err != nil {
+		log.Error().Err(err).Msg("status: failed to prune checks")
+	}
+	s.Health.Record("status_check", err)
+}
+
+// Report returns the status page, reusing the last one for CacheFor. The uptime is left out
+// when the history cannot be read, so the page still shows the current states during an outage.
+func (s *Service) Report(ctx context.Context) *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.cached != nil && now.Sub(s.cachedAt) < s.CacheFor {
+		return s.cached
+	}
+	uptime := make(map[string]map[string]float64)
+	for _, w := range Windows {
+		pcts, err := s.repo.Uptime(ctx, now.Add(-w.Duration))
+		if err != nil {
+			log.Error().Err(err).Msg("status: failed to read uptime")
+			uptime = nil
+			break
+		}
+		for component, pct := range pcts {
+			if uptime[component] == nil {
+				uptime[component] = map[string]float64{}
+			}
+			uptime[component][w.Name] = pct
+		}
+	}
+	r := &Report{Status: Unknown, Components: []ComponentStatus{}}
+	if !s.checkedAt.IsZero() {
+		at := s.checkedAt
+		r.CheckedAt, r.Status = &at, Operational
+	}
+	for _, c := range s.checks {
+		state, ok := s.states[c.name]
+		if !ok && r.CheckedAt != nil {
+			// Not in use
+			continue
+		}
+		if !ok {
+			state = Unknown
+		}
+		cs := ComponentStatus{Name: c.name, Status: state, Uptime: uptime[c.name]}
+		if cs.Uptime == nil {
+			cs.Uptime = map[string]float64{}
+		}
+		r.Components = append(r.Components, cs)
+		r.Status = worst(r.Status, state)
+	}
+	s.cached, s.cachedAt = r, now
+	return r
+}
+
+var severity = map[string]int{Operational: 0, Degraded: 1, Unknown: 2, Down: 3}
+
+func worst(a, b string) string {
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
+}
+
+// Pinger is the database (see data.DB)
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// PingCheck reports down while the database cannot be reached; the API cannot serve without it
+func PingCheck(p Pinger) Check {
+	return func(ctx context.Context) string {
+		if err := p.Ping(ctx); err != nil {
+			log.Warn().Err(err).Msg("status: database ping failed")
+			return Down
+		}
+		return Operational
+	}
+}
+
+// WorkerCheck reports down while any of the named workers is stuck
+func WorkerCheck(m *health.Monitor, names ...string) Check {
+	return func(ctx context.Context) string {
+		for _, w := range m.Status(ctx) {
+			for _, name := range names {
+				if w.Name == name && w.Stuck {
+					return Down
+				}
+			}
+		}
+		return Operational
+	}
+}
+
+// AIHealth reports the external AI provider's health (see ai.Gateway)
+type AIHealth interface {
+	ProviderStatus() ai.ProviderStatus
+}
+
+// AICheck reports degraded while the AI provider is failing; AI features then fall back to
+// heuristics rather than stop. It leaves AI out when no provider is set up.
+func AICheck(h AIHealth) Check {
+	return func(ctx context.Context) string {
+		switch h.ProviderStatus().Status {
+		case ai.ProviderOK:
+			return Operational
+		case ai.ProviderDegraded:
+			return Degraded
+		}
+		return ""
+	}
+}
+
+// ProviderCheck judges the mail provider by the calls made to it since the previous check:
+// degraded when some failed with a server error or no response, down when most did. Rate
+// limiting and other client errors are about single accounts and do not count.
+func ProviderCheck(provider string) Check {
+	var mu sync.Mutex
+	lastTotal, lastFailed := metrics.ProviderCalls(provider)
+	return func(ctx context.Context) string {
+		mu.Lock()
+		defer mu.Unlock()
+		total, failed := metrics.ProviderCalls(provider)
+		calls, failures := total-lastTotal, failed-lastFailed
+		lastTotal, lastFailed = total, failed
+		switch {
+		case failures == 0:
+			return Operational
+		case failures*2 >= calls:
+			return Down
+		}
+		return Degraded
+	}
+}
@@ -0,0 +1,145 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ai"
+	"github.com/desponda/inbox-whisperer/internal/health"
+	"github.com/desponda/inbox-whisperer/internal/telemetry/metrics"
+)
+
+// memoryChecks keeps check results in memory
+type memoryChecks struct {
+	rows []struct {
+		component, state string
+		at               time.Time
+	}
+	err     error
+	queries int
+}
+
+func (m *memoryChecks) Record(ctx context.Context, checkedAt time.Time, states map[string]string) error {
+	for component, state := range states {
+		m.rows = append(m.rows, struct {
+			component, state string
+			at               time.Time
+		}{component, state, checkedAt})
+	}
+	return nil
+}
+
+func (m *memoryChecks) Uptime(ctx context.Context, since time.Time) (map[string]float64, error) {
+	m.queries++
+	if m.err != nil {
+		return nil, m.err
+	}
+	up, all := map[string]float64{}, map[string]float64{}
+	for _, r := range m.rows {
+		if r.at.Before(since) {
+			continue
+		}
+		all[r.component]++
+		if r.state != Down {
+			up[r.component]++
+		}
+	}
+	out := map[string]float64{}
+	for c, n := range all {
+		out[c] = 100 * up[c] / n
+	}
+	return out, nil
+}
+
+func (m *memoryChecks) Prune(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestService_Report(t *testing.T) {
+	repo := &memoryChecks{}
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	s := NewService(repo)
+	s.now = func() time.Time { return now }
+	apiState := Operational
+	s.Add(ComponentAPI, func(ctx context.Context) string { return apiState })
+	s.Add(ComponentAI, func(ctx context.Context) string { return "" })
+
+	if r := s.Report(context.Background()); r.Status != Unknown || r.CheckedAt != nil || len(r.Components) != 2 {
+		t.Fatalf("expected every component unknown before the first check, got %+v", r)
+	}
+
+	// Three checks 10 days ago, one down, and one now
+	now = now.Add(-10 * 24 * time.Hour)
+	for _, state := range []string{Operational, Down, Operational} {
+		apiState = state
+		s.RunChecks(context.Background())
+	}
+	now = now.Add(10 * 24 * time.Hour)
+	apiState = Degraded
+	s.RunChecks(context.Background())
+
+	r := s.Report(context.Background())
+	if r.Status != Degraded || r.CheckedAt == nil || len(r.Components) != 1 {
+		t.Fatalf("expected the degraded API alone, AI being unused, got %+v", r)
+	}
+	up := r.Components[0].Uptime
+	if up["24h"] != 100 || up["30d"] != 75 {
+		t.Errorf("unexpected uptime %v", up)
+	}
+
+	// Reports are reused for CacheFor, and served without uptime if the history is unreadable
+	queries := repo.queries
+	s.Report(context.Background())
+	if repo.queries != queries {
+		t.Error("expected the cached report to be reused")
+	}
+	now = now.Add(DefaultCacheFor)
+	repo.err = errors.New("db down")
+	if r := s.Report(context.Background()); r.Status != Degraded || len(r.Components[0].Uptime) != 0 {
+		t.Errorf("expected the current state without uptime, got %+v", r)
+	}
+}
+
+type fakeAI struct{ status string }
+
+func (f fakeAI) ProviderStatus() ai.ProviderStatus {
+	return ai.ProviderStatus{Status: f.status}
+}
+
+func TestChecks(t *testing.T) {
+	ctx := context.Background()
+	for status, want := range map[string]string{ai.ProviderOK: Operational, ai.ProviderDegraded: Degraded, ai.ProviderDisabled: ""} {
+		if got := AICheck(fakeAI{status})(ctx); got != want {
+			t.Errorf("AI %s: expected %q, got %q", status, want, got)
+		}
+	}
+
+	m := health.NewMonitor()
+	m.Register("sync_scheduler", 1, time.Nanosecond, nil)
+	m.Register("reports", 1, 0, nil)
+	time.Sleep(time.Millisecond)
+	if got := WorkerCheck(m, "reports")(ctx); got != Operational {
+		t.Errorf("expected the healthy worker to be operational, got %q", got)
+	}
+	if got := WorkerCheck(m, "sync_scheduler", "gmail_watch")(ctx); got != Down {
+		t.Errorf("expected the stuck worker to be down, got %q", got)
+	}
+
+	check := ProviderCheck("status-test")
+	if got := check(ctx); got != Operational {
+		t.Errorf("expected no calls to be operational, got %q", got)
+	}
+	metrics.ObserveProviderCall("status-test", "get", metrics.Status2xx, 0)
+	metrics.ObserveProviderCall("status-test", "get", metrics.Status5xx, 0)
+	metrics.ObserveProviderCall("status-test", "get", metrics.Status2xx, 0)
+	metrics.ObserveProviderCall("status-test", "get", metrics.StatusRateLimited, 0)
+	if got := check(ctx); got != Degraded {
+		t.Errorf("expected some server errors to be degraded, got %q", got)
+	}
+	metrics.ObserveProviderCall("status-test", "get", metrics.StatusError, 0)
+	if got := check(ctx); got != Down {
+		t.Errorf("expected only failures since the last check to be down, got %q", got)
+	}
+}
@@ -0,0 +1,184 @@
+// Package suggestions watches for repeated manual actions (archiving everything from
+// a sender, marking a category read, correcting a sender's category) and proposes
+// rules that would automate them.
+package suggestions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/feedback"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/rules"
+)
+
+// ErrAlreadyDecided is returned when accepting or dismissing a suggestion that is no longer pending
+var ErrAlreadyDecided = errors.New("suggestion was already accepted or dismissed")
+
+// How many repetitions make a pattern worth suggesting
+const (
+	MinArchivesFromSender = 3
+	MinReadsInCategory    = 3
+)
+
+// Service records manual actions and manages rule suggestions
+type Service struct {
+	repo  data.SuggestionRepository
+	rules data.RuleRepository
+}
+
+func NewService(repo data.SuggestionRepository, rules data.RuleRepository) *Service {
+	return &Service{repo: repo, rules: rules}
+}
+
+// Observe records a manual action the user took on msg
+func (s *Service) Observe(ctx context.Context, userID, action string, msg *models.EmailMessage) error {
+	sender := msg.SenderAddress
+	if sender == "" {
+		sender = msg.Sender
+	}
+	return s.repo.RecordAction(ctx, &models.UserAction{
+		UserID:    userID,
+		Action:    action,
+		MessageID: msg.EmailMessageID,
+		Sender:    sender,
+		Category:  msg.Category.String,
+	})
+}
+
+// Suggestions refreshes the user's suggestions from their recent behavior and returns the pending ones
+func (s *Service) Suggestions(ctx context.Context, userID string) ([]*models.RuleSuggestion, error) {
+	candidates, err := s.candidates(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.rules.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	covered := map[string]bool{}
+	for _, r := range existing {
+		covered[ruleKey(r)] = true
+	}
+	for _, c := range candidates {
+		if covered[c.Key] {
+			continue
+		}
+		if err := s.repo.UpsertSuggestion(ctx, c); err != nil {
+			return nil, err
+		}
+	}
+	list, err := s.repo.ListSuggestions(ctx, userID, models.SuggestionPending)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []*models.RuleSuggestion{}
+	}
+	return list, nil
+}
+
+// candidates turns repeated actions into suggested rules
+func (s *Service) candidates(ctx context.Context, userID string) ([]*models.RuleSuggestion, error) {
+	var out []*models.RuleSuggestion
+	archives, err := s.repo.CountActionsBySender(ctx, userID, models.UserActionArchive, MinArchivesFromSender)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range archives {
+		out = append(out, suggestion(userID, c.Count,
+			fmt.Sprintf("Archive messages from %s", c.Sender),
+			fmt.Sprintf("You archived %d messages from %s", c.Count, c.Sender),
+			models.RuleCondition{Field: models.RuleFieldFrom, Contains: c.Sender},
+			models.RuleAction{Type: models.RuleActionArchive}))
+	}
+	reads, err := s.repo.CountActionsByCategory(ctx, userID, models.UserActionMarkRead, MinReadsInCategory)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range reads {
+		out = append(out, suggestion(userID, c.Count,
+			fmt.Sprintf("Mark %s as read", c.Category),
+			fmt.Sprintf("You marked %d %s messages as read", c.Count, c.Category),
+			models.RuleCondition{Field: models.RuleFieldCategory, Contains: c.Category},
+			models.RuleAction{Type: models.RuleActionMarkRead}))
+	}
+	corrections, err := s.repo.CountCorrections(ctx, userID, feedback.SuggestionThreshold)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range corrections {
+		out = append(out, suggestion(userID, c.Count,
+			fmt.Sprintf("Categorize %s as %s", c.Sender, c.Category),
+			fmt.Sprintf("You moved %d messages from %s to %s", c.Count, c.Sender, c.Category),
+			models.RuleCondition{Field: models.RuleFieldFrom, Contains: c.Sender},
+			models.RuleAction{Type: models.RuleActionSetCategory, Category: c.Category}))
+	}
+	return out, nil
+}
+
+func suggestion(userID string, evidence int, name, reason string, cond models.RuleCondition, action models.RuleAction) *models.RuleSuggestion {
+	rule := models.Rule{
+		Name:       name,
+		Conditions: []models.RuleCondition{cond},
+		Actions:    []models.RuleAction{action},
+		Enabled:    true,
+	}
+	return &models.RuleSuggestion{UserID: userID, Key: ruleKey(&rule), Rule: rule, Reason: reason, Evidence: evidence}
+}
+
+// ruleKey identifies single-condition, single-action rules so suggestions can be matched
+// against each other and against the user's existing rules. Other rules get an empty key.
+func ruleKey(r *models.Rule) string {
+	if len(r.Conditions) != 1 || len(r.Actions) != 1 {
+		return ""
+	}
+	c, a := r.Conditions[0], r.Actions[0]
+	action := a.Type
+	if a.Type == models.RuleActionSetCategory {
+		action += ":" + a.Category
+	}
+	return action + "|" + c.Field + "|" + strings.ToLower(c.Contains)
+}
+
+// Accept creates the suggested rule and marks the suggestion accepted
+func (s *Service) Accept(ctx context.Context, userID string, id int64) (*models.Rule, error) {
+	sug, err := s.pending(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	rule := sug.Rule
+	rule.UserID = userID
+	if err := rules.Validate(&rule); err != nil {
+		return nil, err
+	}
+	if err := s.rules.Create(ctx, &rule); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetSuggestionStatus(ctx, userID, id, models.SuggestionAccepted, &rule.ID); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// Dismiss marks the suggestion dismissed so it is not offered again
+func (s *Service) Dismiss(ctx context.Context, userID string, id int64) error {
+	if _, err := s.pending(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.repo.SetSuggestionStatus(ctx, userID, id, models.SuggestionDismissed, nil)
+}
+
+func (s *Service) pending(ctx context.Context, userID string, id int64) (*models.RuleSuggestion, error) {
+	sug, err := s.repo.GetSuggestion(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if sug.Status != models.SuggestionPending {
+		return nil, ErrAlreadyDecided
+	}
+	return sug, nil
+}
@@ -0,0 +1,190 @@
+package suggestions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+// fakeRepo keeps actions and suggestions in memory, mirroring the SQL grouping
+type fakeRepo struct {
+	actions     []*models.UserAction
+	corrections []models.ActionCount
+	suggestions []*models.RuleSuggestion
+}
+
+func (f *fakeRepo) RecordAction(ctx context.Context, a *models.UserAction) error {
+	a.Sender = strings.ToLower(a.Sender)
+	f.actions = append(f.actions, a)
+	return nil
+}
+
+func (f *fakeRepo) count(action string, key func(*models.UserAction) string, min int) map[string]int {
+	counts := map[string]int{}
+	for _, a := range f.actions {
+		if a.Action == action && key(a) != "" {
+			counts[key(a)]++
+		}
+	}
+	for k, n := range counts {
+		if n < min {
+			delete(counts, k)
+		}
+	}
+	return counts
+}
+
+func (f *fakeRepo) CountActionsBySender(ctx context.Context, userID, action string, min int) ([]models.ActionCount, error) {
+	var out []models.ActionCount
+	for s, n := range f.count(action, func(a *models.UserAction) string { return a.Sender }, min) {
+		out = append(out, models.ActionCount{Sender: s, Count: n})
+	}
+	return out, nil
+}
+
+func (f *fakeRepo) CountActionsByCategory(ctx context.Context, userID, action string, min int) ([]models.ActionCount, error) {
+	var out []models.ActionCount
+	for c, n := range f.count(action, func(a *models.UserAction) string { return a.Category }, min) {
+		out = append(out, models.ActionCount{Category: c, Count: n})
+	}
+	return out, nil
+}
+
+func (f *fakeRepo) CountCorrections(ctx context.Context, userID string, min int) ([]models.ActionCount, error) {
+	return f.corrections, nil
+}
+
+func (f *fakeRepo) UpsertSuggestion(ctx context.Context, s *models.RuleSuggestion) error {
+	for _, existing := range f.suggestions {
+		if existing.Key == s.Key {
+			if existing.Status == models.SuggestionPending {
+				existing.Evidence = s.Evidence
+			}
+			s.ID, s.Status = existing.ID, existing.Status
+			return nil
+		}
+	}
+	s.ID = int64(len(f.suggestions) + 1)
+	s.Status = models.SuggestionPending
+	f.suggestions = append(f.suggestions, s)
+	return nil
+}
+
+func (f *fakeRepo) ListSuggestions(ctx context.Context, userID, status string) ([]*models.RuleSuggestion, error) {
+	var out []*models.RuleSuggestion
+	for _, s := range f.suggestions {
+		if s.Status == status {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeRepo) GetSuggestion(ctx context.Context, userID string, id int64) (*models.RuleSuggestion, error) {
+	if id < 1 || id > int64(len(f.suggestions)) {
+		return nil, data.ErrNotFound
+	}
+	return f.suggestions[id-1], nil
+}
+
+func (f *fakeRepo) SetSuggestionStatus(ctx context.Context, userID string, id int64, status string, ruleID *int64) error {
+	s, err := f.GetSuggestion(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	s.Status, s.RuleID = status, ruleID
+	return nil
+}
+
+type fakeRuleRepo struct {
+	rules []*models.Rule
+}
+
+func (f *fakeRuleRepo) Create(ctx context.Context, rule *models.Rule) error {
+	rule.ID = int64(len(f.rules) + 1)
+	f.rules = append(f.rules, rule)
+	return nil
+}
+func (f *fakeRuleRepo) ListByUser(ctx context.Context, userID string) ([]*models.Rule, error) {
+	return f.rules, nil
+}
+func (f *fakeRuleRepo) Delete(ctx context.Context, userID string, id int64) error { return nil }
+
+func TestSuggestions_FromRepeatedActions(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeRepo{corrections: []models.ActionCount{{Sender: "alerts@bank.example", Category: "Finance", Count: 2}}}
+	svc := NewService(repo, &fakeRuleRepo{})
+
+	promo := sql.NullString{String: "Promotions/Ads", Valid: true}
+	for i := 0; i < 3; i++ {
+		if err := svc.Observe(ctx, "u1", models.UserActionArchive, &models.EmailMessage{EmailMessageID: "a", Sender: "news@shop.example"}); err != nil {
+			t.Fatalf("Observe failed: %v", err)
+		}
+		_ = svc.Observe(ctx, "u1", models.UserActionMarkRead, &models.EmailMessage{EmailMessageID: "r", Category: promo})
+	}
+	// Below threshold: only two archives from this sender
+	for i := 0; i < 2; i++ {
+		_ = svc.Observe(ctx, "u1", models.UserActionArchive, &models.EmailMessage{EmailMessageID: "b", Sender: "rare@else.example"})
+	}
+
+	list, err := svc.Suggestions(ctx, "u1")
+	if err != nil {
+		t.Fatalf("Suggestions failed: %v", err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("expected 3 suggestions, got %d: %+v", len(list), list)
+	}
+	got := map[string]bool{}
+	for _, s := range list {
+		got[s.Key] = true
+	}
+	for _, key := range []string{"archive|from|news@shop.example", "mark_read|category|promotions/ads", "set_category:Finance|from|alerts@bank.example"} {
+		if !got[key] {
+			t.Errorf("expected suggestion %q, got %v", key, got)
+		}
+	}
+}
+
+func TestSuggestions_AcceptAndDismiss(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeRepo{}
+	ruleRepo := &fakeRuleRepo{}
+	svc := NewService(repo, ruleRepo)
+	for i := 0; i < 3; i++ {
+		_ = svc.Observe(ctx, "u1", models.UserActionArchive, &models.EmailMessage{Sender: "news@shop.example"})
+		_ = svc.Observe(ctx, "u1", models.UserActionArchive, &models.EmailMessage{Sender: "deals@shop.example"})
+	}
+	list, _ := svc.Suggestions(ctx, "u1")
+	if len(list) != 2 {
+		t.Fatalf("expected 2 suggestions, got %d", len(list))
+	}
+
+	rule, err := svc.Accept(ctx, "u1", list[0].ID)
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	if rule.ID == 0 || len(ruleRepo.rules) != 1 || rule.Actions[0].Type != models.RuleActionArchive {
+		t.Errorf("expected archive rule to be created, got %+v", rule)
+	}
+	if err := svc.Dismiss(ctx, "u1", list[1].ID); err != nil {
+		t.Fatalf("Dismiss failed: %v", err)
+	}
+	if _, err := svc.Accept(ctx, "u1", list[1].ID); !errors.Is(err, ErrAlreadyDecided) {
+		t.Errorf("expected ErrAlreadyDecided, got %v", err)
+	}
+	if err := svc.Dismiss(ctx, "u1", 99); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// Decided suggestions are not offered again, even as the behavior continues
+	_ = svc.Observe(ctx, "u1", models.UserActionArchive, &models.EmailMessage{Sender: "deals@shop.example"})
+	list, _ = svc.Suggestions(ctx, "u1")
+	if len(list) != 0 {
+		t.Errorf("expected no pending suggestions, got %+v", list)
+	}
+}
@@ -0,0 +1,173 @@
+// Package debuglog turns on debug logging for a single user for a limited time, so production
+// issues can be diagnosed without debug output for everyone. Requests and background jobs of a
+// toggled user carry a context mark (ctxkeys.WithDebug); Logger and Printf log at debug level
+// only for marked contexts. Toggles live in memory and apply to the instance that set them.
+package debuglog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Limits on how long a toggle lasts
+const (
+	DefaultTTL = 30 * time.Minute
+	MaxTTL     = 24 * time.Hour
+)
+
+// Toggle is an active debug toggle
+type Toggle struct {
+	UserID    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Toggles holds the users with debug logging on. A nil Toggles has none.
+type Toggles struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+	now   func() time.Time
+}
+
+func New() *Toggles {
+	return &Toggles{until: map[string]time.Time{}, now: time.Now}
+}
+
+// Enable turns debug logging on for the user for ttl, replacing any earlier toggle. ttl is
+// clamped to (0, MaxTTL]; 0 means DefaultTTL.
+func (t *Toggles) Enable(userID string, ttl time.Duration) Toggle {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	ttl = min(ttl, MaxTTL)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	expires := t.now().Add(ttl).UTC()
+	t.until[userID] = expires
+	return Toggle{UserID: userID, ExpiresAt: expires}
+}
+
+// Disable turns debug logging off for the user and reports whether it was on
+func (t *Toggles) Disable(userID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	on := t.active(userID)
+	delete(t.until, userID)
+	return on
+}
+
+// Enabled reports whether the user has an unexpired toggle
+func (t *Toggles) Enabled(userID string) bool {
+	if t == nil || userID == "" {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active(userID)
+}
+
+// active expires the user's toggle if due; callers hold mu
+func (t *Toggles) active(userID string) bool {
+	until, ok := t.until[userID]
+	if ok && !t.now().Before(until) {
+		delete(t.until, userID)
+		return false
+	}
+	return ok
+}
+
+// List returns the unexpired toggles, sorted by user
+func (t *Toggles) List() []Toggle {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := []Toggle{}
+	for userID, until := range t.until {
+		if t.active(userID) {
+			out = append(out, Toggle{UserID: userID, ExpiresAt: until})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UserID < out[j].UserID })
+	return out
+}
+
+// Context marks ctx for debug logging if the user has a toggle
+func (t *Toggles) Context(ctx context.Context, userID string) context.Context {
+	if t.Enabled(userID) {
+		return ctxkeys.WithDebug(ctx, userID)
+	}
+	return ctx
+}
+
+// level is the minimum level of ordinary (unmarked) logs. zerolog's global level has to stay
+// at debug for marked contexts to log, so ordinary logs are filtered by the writer instead.
+var level atomic.Int32
+
+// debugOut receives debug logs of marked contexts, unfiltered
+var debugOut atomic.Pointer[zerolog.Logger]
+
+func init() {
+	level.Store(int32(zerolog.TraceLevel))
+}
+
+// Setup points the global logger at out, filtered by SetLevel, and marked contexts at out
+// unfiltered
+func Setup(out io.Writer) {
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	log.Logger = log.Output(filter{out})
+	l := log.Output(out)
+	debugOut.Store(&l)
+}
+
+// SetLevel sets the minimum level of ordinary logs
+func SetLevel(l zerolog.Level) {
+	level.Store(int32(l))
+}
+
+// filter drops events below the configured level
+type filter struct {
+	out io.Writer
+}
+
+func (f filter) Write(p []byte) (int, error) {
+	return f.out.Write(p)
+}
+
+func (f filter) WriteLevel(l zerolog.Level, p []byte) (int, error) {
+	if l < zerolog.Level(level.Load()) {
+		return len(p), nil
+	}
+	return f.out.Write(p)
+}
+
+// Logger returns a debug-level logger tagged with the user for a marked ctx, and the global
+// logger otherwise
+func Logger(ctx context.Context) *zerolog.Logger {
+	userID := ctxkeys.DebugUser(ctx)
+	if userID == "" {
+		return &log.Logger
+	}
+	base := debugOut.Load()
+	if base == nil {
+		l := log.Output(os.Stderr)
+		base = &l
+	}
+	l := base.Level(zerolog.DebugLevel).With().Str("user_id", userID).Bool("debug_toggle", true).Logger()
+	return &l
+}
+
+// Printf logs a debug message for a marked ctx and does nothing otherwise, for packages that
+// log with the standard library
+func Printf(ctx context.Context, format string, args ...any) {
+	if ctxkeys.DebugUser(ctx) != "" {
+		Logger(ctx).Debug().Msg(fmt.Sprintf(format, args...))
+	}
+}
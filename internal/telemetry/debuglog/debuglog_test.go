@@ -0,0 +1,87 @@
+package debuglog
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func TestTogglesExpire(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	toggles := New()
+	toggles.now = func() time.Time { return now }
+
+	got := toggles.Enable("user1", 0)
+	if !got.ExpiresAt.Equal(now.Add(DefaultTTL)) {
+		t.Errorf("expected default ttl, got expiry %v", got.ExpiresAt)
+	}
+	if got := toggles.Enable("user2", 48*time.Hour); !got.ExpiresAt.Equal(now.Add(MaxTTL)) {
+		t.Errorf("expected ttl clamped to max, got expiry %v", got.ExpiresAt)
+	}
+	if !toggles.Enabled("user1") || toggles.Enabled("user3") {
+		t.Fatal("expected only toggled users to be enabled")
+	}
+	if list := toggles.List(); len(list) != 2 || list[0].UserID != "user1" {
+		t.Errorf("unexpected list %+v", list)
+	}
+
+	now = now.Add(DefaultTTL)
+	if toggles.Enabled("user1") {
+		t.Error("expected toggle to expire")
+	}
+	if list := toggles.List(); len(list) != 1 || list[0].UserID != "user2" {
+		t.Errorf("unexpected list after expiry %+v", list)
+	}
+	if !toggles.Disable("user2") || toggles.Disable("user2") {
+		t.Error("expected Disable to report whether the toggle was on")
+	}
+	var nilToggles *Toggles
+	if nilToggles.Enabled("user1") {
+		t.Error("expected nil toggles to have none")
+	}
+}
+
+func TestContextAndPrintf(t *testing.T) {
+	prevLogger, prevLevel := log.Logger, zerolog.GlobalLevel()
+	defer func() {
+		log.Logger = prevLogger
+		zerolog.SetGlobalLevel(prevLevel)
+		SetLevel(zerolog.TraceLevel)
+		debugOut.Store(nil)
+	}()
+	var buf bytes.Buffer
+	Setup(&buf)
+	SetLevel(zerolog.InfoLevel)
+
+	toggles := New()
+	toggles.Enable("user1", time.Minute)
+	marked := toggles.Context(context.Background(), "user1")
+	if ctxkeys.DebugUser(marked) != "user1" {
+		t.Fatal("expected toggled user's context to be marked")
+	}
+	unmarked := toggles.Context(context.Background(), "user2")
+	if ctxkeys.DebugUser(unmarked) != "" {
+		t.Fatal("expected other users' contexts to be unmarked")
+	}
+
+	Printf(unmarked, "hidden %d", 1)
+	log.Debug().Msg("hidden global")
+	if buf.Len() != 0 {
+		t.Fatalf("expected ordinary debug logs to be filtered, got %q", buf.String())
+	}
+	Printf(marked, "shown %d", 2)
+	if !strings.Contains(buf.String(), "shown 2") || !strings.Contains(buf.String(), `"user_id":"user1"`) {
+		t.Errorf("expected marked debug log with user, got %q", buf.String())
+	}
+	buf.Reset()
+	log.Info().Msg("info")
+	if !strings.Contains(buf.String(), "info") {
+		t.Errorf("expected info logs to pass the filter, got %q", buf.String())
+	}
+}
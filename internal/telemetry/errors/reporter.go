@@ -0,0 +1,58 @@
+// Package errors forwards unexpected failures, such as recovered panics, to an external
+// error aggregation service. Reporting is best effort: it never blocks the caller on the
+// network and never fails the work that produced the error.
+package errors
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Event levels
+const (
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+// Event describes one failure
+type Event struct {
+	Level   string
+	Message string
+	// Stack is the goroutine stack at the point of failure, if captured
+	Stack []byte
+	// Method and URL describe the HTTP request being served, if any
+	Method string
+	URL    string
+	// UserID is the affected user; reporters send only its hash
+	UserID string
+	Tags   map[string]string
+	Time   time.Time
+}
+
+// Reporter sends events to an error aggregation service
+type Reporter interface {
+	Report(ctx context.Context, ev Event)
+}
+
+// Nop discards every event; it is used when no service is configured
+type Nop struct{}
+
+func (Nop) Report(ctx context.Context, ev Event) {}
+
+// Capture reports err from a background job to r, tagged with the affected user (if any) and
+// tags. A nil r discards the event.
+func Capture(ctx context.Context, r Reporter, err error, userID string, tags map[string]string) {
+	if r == nil || err == nil {
+		return
+	}
+	r.Report(ctx, Event{Level: LevelError, Message: err.Error(), UserID: userID, Tags: tags})
+}
+
+// HashUserID returns a stable pseudonym for userID, so events for the same user can be
+// grouped without sending the ID itself to a third party
+func HashUserID(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return hex.EncodeToString(sum[:8])
+}
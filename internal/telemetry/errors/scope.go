@@ -0,0 +1,40 @@
+package errors
+
+import (
+	"context"
+	"sync"
+)
+
+type scopeKey struct{}
+
+// scope carries details learned while serving a request, such as the user, back out to the
+// middleware that reports a panic
+type scope struct {
+	mu     sync.Mutex
+	userID string
+}
+
+// WithScope returns a context that SetUser can annotate
+func WithScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeKey{}, &scope{})
+}
+
+// SetUser records the user being served in ctx's scope, if it has one
+func SetUser(ctx context.Context, userID string) {
+	if sc, ok := ctx.Value(scopeKey{}).(*scope); ok {
+		sc.mu.Lock()
+		sc.userID = userID
+		sc.mu.Unlock()
+	}
+}
+
+// UserID returns the user recorded in ctx's scope, or "" if none was
+func UserID(ctx context.Context) string {
+	sc, ok := ctx.Value(scopeKey{}).(*scope)
+	if !ok {
+		return ""
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.userID
+}
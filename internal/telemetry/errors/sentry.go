@@ -0,0 +1,145 @@
+package errors
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// sendTimeout bounds each delivery to the Sentry server
+const sendTimeout = 5 * time.Second
+
+// SentryReporter sends events to Sentry, or any server speaking its store API, identified by
+// a DSN of the form https://<public key>@<host>/<project id>
+type SentryReporter struct {
+	endpoint    string
+	auth        string
+	Environment string
+	// Release identifies the running build, e.g. its git SHA
+	Release    string
+	HTTPClient *http.Client
+}
+
+// NewSentryReporter parses dsn; environment (e.g. "production") and release are attached to
+// every event
+func NewSentryReporter(dsn, environment, release string) (*SentryReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sentry dsn: %w", err)
+	}
+	key := u.User.Username()
+	projectID := strings.Trim(u.Path, "/")
+	if u.Host == "" || key == "" || projectID == "" {
+		return nil, fmt.Errorf("invalid sentry dsn: expected <scheme>://<key>@<host>/<project id>")
+	}
+	// Projects hosted under a path prefix keep it; the project ID is always the last segment
+	prefix := ""
+	if i := strings.LastIndex(projectID, "/"); i >= 0 {
+		prefix, projectID = "/"+projectID[:i], projectID[i+1:]
+	}
+	return &SentryReporter{
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, projectID),
+		auth:        fmt.Sprintf("Sentry sentry_version=7, sentry_client=inbox-whisperer/1.0, sentry_key=%s", key),
+		Environment: environment,
+		Release:     release,
+		HTTPClient:  http.DefaultClient,
+	}, nil
+}
+
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Message     string            `json:"message"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	User        *sentryUser       `json:"user,omitempty"`
+	Request     *sentryRequest    `json:"request,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+type sentryUser struct {
+	ID string `json:"id"`
+}
+
+type sentryRequest struct {
+	Method string `json:"method,omitempty"`
+	URL    string `json:"url,omitempty"`
+}
+
+// Report queues the event for delivery and returns immediately; delivery failures are logged
+func (s *SentryReporter) Report(ctx context.Context, ev Event) {
+	payload, err := json.Marshal(s.event(ev))
+	if err != nil {
+		log.Error().Err(err).Msg("telemetry: failed to encode error event")
+		return
+	}
+	go s.send(payload)
+}
+
+func (s *SentryReporter) event(ev Event) sentryEvent {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	if ev.Level == "" {
+		ev.Level = LevelError
+	}
+	out := sentryEvent{
+		EventID:     newEventID(),
+		Timestamp:   ev.Time.UTC().Format(time.RFC3339),
+		Level:       ev.Level,
+		Platform:    "go",
+		Environment: s.Environment,
+		Release:     s.Release,
+		Message:     ev.Message,
+		Tags:        ev.Tags,
+	}
+	if ev.UserID != "" {
+		out.User = &sentryUser{ID: HashUserID(ev.UserID)}
+	}
+	if ev.Method != "" || ev.URL != "" {
+		out.Request = &sentryRequest{Method: ev.Method, URL: ev.URL}
+	}
+	if len(ev.Stack) > 0 {
+		out.Extra = map[string]string{"stack": string(ev.Stack)}
+	}
+	return out
+}
+
+func (s *SentryReporter) send(payload []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		log.Error().Err(err).Msg("telemetry: failed to build error report")
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		log.Warn().Err(err).Msg("telemetry: failed to send error report")
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Warn().Int("status", resp.StatusCode).Msg("telemetry: error report rejected")
+	}
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
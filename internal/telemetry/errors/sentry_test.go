@@ -0,0 +1,68 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSentryReporter_DSN(t *testing.T) {
+	r, err := NewSentryReporter("https://abc123@sentry.example.com/42", "prod", "")
+	if err != nil {
+		t.Fatalf("NewSentryReporter failed: %v", err)
+	}
+	if r.endpoint != "https://sentry.example.com/api/42/store/" || !strings.Contains(r.auth, "sentry_key=abc123") {
+		t.Errorf("unexpected endpoint %q / auth %q", r.endpoint, r.auth)
+	}
+	r, err = NewSentryReporter("https://abc123@example.com/sentry/42", "", "")
+	if err != nil || r.endpoint != "https://example.com/sentry/api/42/store/" {
+		t.Errorf("expected a path prefix to be kept, got %+v (%v)", r, err)
+	}
+	for _, dsn := range []string{"https://sentry.example.com/42", "https://abc@sentry.example.com/", "::"} {
+		if _, err := NewSentryReporter(dsn, "", ""); err == nil {
+			t.Errorf("expected %q to be rejected", dsn)
+		}
+	}
+}
+
+func TestSentryReporter_Report(t *testing.T) {
+	got := make(chan sentryEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/7/store/" || !strings.Contains(r.Header.Get("X-Sentry-Auth"), "sentry_key=key") {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		var ev sentryEvent
+		json.NewDecoder(r.Body).Decode(&ev)
+		got <- ev
+	}))
+	defer srv.Close()
+	r, err := NewSentryReporter(strings.Replace(srv.URL, "://", "://key@", 1)+"/7", "test", "abc1234")
+	if err != nil {
+		t.Fatalf("NewSentryReporter failed: %v", err)
+	}
+
+	r.Report(context.Background(), Event{Message: "panic: boom", Stack: []byte("goroutine 1"), Method: "GET", URL: "/api/x", UserID: "user-1"})
+	select {
+	case ev := <-got:
+		if ev.Message != "panic: boom" || ev.Level != LevelError || ev.Environment != "test" || len(ev.EventID) != 32 ||
+			ev.Request == nil || ev.Request.URL != "/api/x" || ev.Extra["stack"] != "goroutine 1" || ev.Release != "abc1234" {
+			t.Errorf("unexpected event %+v", ev)
+		}
+		if ev.User == nil || ev.User.ID != HashUserID("user-1") {
+			t.Errorf("expected the hashed user ID, got %+v", ev.User)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the event to be delivered")
+	}
+}
+
+func TestHashUserID(t *testing.T) {
+	h := HashUserID("user-1")
+	if h == "user-1" || len(h) != 16 || h != HashUserID("user-1") || h == HashUserID("user-2") {
+		t.Errorf("unexpected hash %q", h)
+	}
+}
@@ -0,0 +1,253 @@
+// Package metrics keeps process-wide counters and histograms and serves them in the
+// Prometheus text exposition format, so operators can scrape provider quota pressure and sync
+// throughput without a client library. Series are created on first use and live for the
+// life of the process.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status labels for provider calls. Quota rejections are counted as StatusRateLimited even
+// when the provider answers 403, since that is the signal operators alert on.
+const (
+	Status2xx         = "2xx"
+	Status4xx         = "4xx"
+	StatusRateLimited = "429"
+	Status5xx         = "5xx"
+	// StatusError is a call that failed without an HTTP status, e.g. a network error
+	StatusError = "error"
+)
+
+// DurationBuckets are upper bounds in seconds for provider call and sync durations
+var DurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// CountBuckets are upper bounds for per-sync message counts
+var CountBuckets = []float64{0, 1, 5, 10, 25, 50, 100, 250, 500}
+
+var (
+	providerCalls = newCounterVec("inbox_whisperer_provider_api_calls_total",
+		"Provider API calls by method and status class.", "provider", "method", "status")
+	providerCallDuration = newHistogramVec("inbox_whisperer_provider_api_call_duration_seconds",
+		"Provider API call latency.", DurationBuckets, "provider", "method")
+	syncDuration = newHistogramVec("inbox_whisperer_sync_duration_seconds",
+		"Duration of a sync run.", DurationBuckets, "provider", "result")
+	syncUpserted = newHistogramVec("inbox_whisperer_sync_messages_upserted",
+		"Messages written to the cache per sync run.", CountBuckets, "provider")
+
+	droppedWrites = newCounterVec("inbox_whisperer_background_writes_dropped_total",
+		"Fire-and-forget writes given up on after their retries.", "op")
+
+	legacyRequests = newCounterVec("inbox_whisperer_legacy_api_requests_total",
+		"Requests to deprecated API paths by route.", "route")
+
+	suppressedSyncs = newCounterVec("inbox_whisperer_syncs_suppressed_total",
+		"Syncs not started because one was running (in_flight) or had just finished (cooldown).", "provider", "reason")
+
+	tokenRefreshes = newCounterVec("inbox_whisperer_token_refreshes_total",
+		"OAuth access token refreshes by result (ok, revoked, error).", "provider", "result")
+
+	registry = []collector{providerCalls, providerCallDuration, syncDuration, syncUpserted, droppedWrites, legacyRequests, suppressedSyncs, tokenRefreshes}
+)
+
+// ObserveProviderCall records one provider API call
+func ObserveProviderCall(provider, method, status string, d time.Duration) {
+	providerCalls.add(1, provider, method, status)
+	providerCallDuration.observe(d.Seconds(), provider, method)
+}
+
+// ProviderCalls returns how many calls to the provider have been recorded since the process
+// started, and how many of them failed with a server error or without a response
+func ProviderCalls(provider string) (total, failed float64) {
+	providerCalls.mu.Lock()
+	defer providerCalls.mu.Unlock()
+	for k, v := range providerCalls.values {
+		labels := strings.Split(k, "\xff")
+		if labels[0] != provider {
+			continue
+		}
+		total += v
+		if status := labels[2]; status == Status5xx || status == StatusError {
+			failed += v
+		}
+	}
+	return total, failed
+}
+
+// ObserveSync records one sync run and how many messages it wrote
+func ObserveSync(provider string, d time.Duration, upserted int, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	syncDuration.observe(d.Seconds(), provider, result)
+	syncUpserted.observe(float64(upserted), provider)
+}
+
+// ObserveDroppedWrite records a background write that was given up on
+func ObserveDroppedWrite(op string) {
+	droppedWrites.add(1, op)
+}
+
+// ObserveLegacyRequest records a request to a deprecated API route, so operators can tell
+// when its traffic has drained
+func ObserveLegacyRequest(route string) {
+	legacyRequests.add(1, route)
+}
+
+// ObserveSuppressedSync records a sync that was not started for a user; reason is in_flight
+// or cooldown
+func ObserveSuppressedSync(provider, reason string) {
+	suppressedSyncs.add(1, provider, reason)
+}
+
+// ObserveTokenRefresh records one refresh of an expired access token; result is ok, revoked
+// when the user must sign in again, or error
+func ObserveTokenRefresh(provider, result string) {
+	tokenRefreshes.add(1, provider, result)
+}
+
+// Handler serves all metrics in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+}
+
+// Write renders all metrics in the Prometheus text format
+func Write(w io.Writer) {
+	for _, c := range registry {
+		c.write(w)
+	}
+}
+
+type collector interface {
+	write(w io.Writer)
+}
+
+// series is the shared bookkeeping of a labelled metric family
+type series struct {
+	name, help string
+	labels     []string
+}
+
+func (s *series) key(values []string) string {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d labels, got %d", s.name, len(s.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs renders {a="x",b="y"} plus any extra pair, e.g. le for histogram buckets
+func (s *series) labelPairs(key string, extra ...string) string {
+	var parts []string
+	if len(s.labels) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			parts = append(parts, s.labels[i]+"="+strconv.Quote(v))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, extra[i]+"="+strconv.Quote(extra[i+1]))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+type counterVec struct {
+	series
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{series: series{name: name, help: help, labels: labels}, values: map[string]float64{}}
+}
+
+func (c *counterVec) add(v float64, labels ...string) {
+	k := c.key(labels)
+	c.mu.Lock()
+	c.values[k] += v
+	c.mu.Unlock()
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, k := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(k), formatFloat(c.values[k]))
+	}
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+type histogramVec struct {
+	series
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogram
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{series: series{name: name, help: help, labels: labels}, buckets: buckets, values: map[string]*histogram{}}
+}
+
+func (h *histogramVec) observe(v float64, labels ...string) {
+	k := h.key(labels)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hist, ok := h.values[k]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[k] = hist
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		hist.counts[i]++
+	}
+	hist.sum += v
+	hist.count++
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, k := range sortedKeys(h.values) {
+		hist := h.values[k]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += hist.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(k, "le", formatFloat(le)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(k, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(k), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(k), hist.count)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerExposesObservations(t *testing.T) {
+	ObserveProviderCall("gmail", "messages.get", StatusRateLimited, 300*time.Millisecond)
+	ObserveProviderCall("gmail", "messages.get", StatusRateLimited, 2*time.Second)
+	ObserveSync("gmail", 4*time.Second, 7, nil)
+	ObserveLegacyRequest("/api/email/messages")
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	for _, want := range []string{
+		"# TYPE inbox_whisperer_provider_api_calls_total counter",
+		`inbox_whisperer_provider_api_calls_total{provider="gmail",method="messages.get",status="429"} 2`,
+		`inbox_whisperer_provider_api_call_duration_seconds_bucket{provider="gmail",method="messages.get",le="0.25"} 0`,
+		`inbox_whisperer_provider_api_call_duration_seconds_bucket{provider="gmail",method="messages.get",le="0.5"} 1`,
+		`inbox_whisperer_provider_api_call_duration_seconds_bucket{provider="gmail",method="messages.get",le="+Inf"} 2`,
+		`inbox_whisperer_provider_api_call_duration_seconds_sum{provider="gmail",method="messages.get"} 2.3`,
+		`inbox_whisperer_sync_duration_seconds_count{provider="gmail",result="ok"} 1`,
+		`inbox_whisperer_sync_messages_upserted_bucket{provider="gmail",le="10"} 1`,
+		`inbox_whisperer_sync_messages_upserted_sum{provider="gmail"} 7`,
+		`inbox_whisperer_legacy_api_requests_total{route="/api/email/messages"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a missing label")
+		}
+	}()
+	providerCalls.add(1, "gmail")
+}
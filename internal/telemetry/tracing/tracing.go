@@ -0,0 +1,153 @@
+// Package tracing records OpenTelemetry spans along the request path, from the HTTP router
+// through the email service and providers to Gmail API calls and database queries, and exports
+// them over OTLP. Until Setup installs an exporter every span is a no-op, so instrumented code
+// costs next to nothing when tracing is off.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation names the tracer the application's own spans come from
+const instrumentation = "github.com/desponda/inbox-whisperer"
+
+// DefaultServiceName is the service.name spans are exported under when none is configured
+const DefaultServiceName = "inbox-whisperer"
+
+// Options configures Setup
+type Options struct {
+	// Endpoint is the OTLP/HTTP collector, e.g. http://otel-collector:4318; empty leaves tracing off
+	Endpoint string
+	// Headers are sent with every export, e.g. the collector's API key
+	Headers map[string]string
+	// ServiceName defaults to DefaultServiceName
+	ServiceName string
+	// SampleRatio is the share of new traces recorded, from 0 to 1; 0 records them all. Requests
+	// continuing a trace follow the caller's decision.
+	SampleRatio float64
+}
+
+// Setup installs the OTLP exporter and W3C trace context propagation. The returned function
+// flushes the spans still buffered and must be called before the process exits. With no
+// endpoint it installs nothing and the function does nothing.
+func Setup(ctx context.Context, opts Options) (shutdown func(context.Context) error, err error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporterOpts := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(opts.Endpoint)}
+	if len(opts.Headers) > 0 {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithHeaders(opts.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: create exporter: %w", err)
+	}
+	name := opts.ServiceName
+	if name == "" {
+		name = DefaultServiceName
+	}
+	sampler := sdktrace.AlwaysSample()
+	if opts.SampleRatio > 0 && opts.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(opts.SampleRatio)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", name))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Start starts a span named name, a child of the span in ctx if there is one. End it with End.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on span, if set, and ends it. Deferred with a named error result it covers
+// every return of a function:
+//
+//	ctx, span := tracing.Start(ctx, "gmail.SyncUser")
+//	defer func() { tracing.End(span, err) }()
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Middleware starts a server span for every request, continuing the caller's trace when the
+// request carries one. Spans are named by the chi route pattern rather than the path, so
+// requests for different messages group together.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(instrumentation).Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("http.request.method", r.Method), attribute.String("url.path", r.URL.Path)))
+		defer span.End()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				span.SetName(r.Method + " " + pattern)
+				span.SetAttributes(attribute.String("http.route", pattern))
+			}
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// statusRecorder remembers the status a handler wrote
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Transport records a client span for every request sent through base, and passes the trace on
+// to the server
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method + " " + r.URL.Host
+	}))
+}
+
+// maxStatementLength bounds the SQL recorded on query spans
+const maxStatementLength = 2000
+
+// statement returns sql with its whitespace collapsed, cut to maxStatementLength
+func statement(sql string) string {
+	sql = strings.Join(strings.Fields(sql), " ")
+	if len(sql) > maxStatementLength {
+		sql = sql[:maxStatementLength] + "..."
+	}
+	return sql
+}
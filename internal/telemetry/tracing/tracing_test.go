@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// record installs a provider that keeps every ended span, restoring the previous one afterwards
+func record(t *testing.T) *tracetest.SpanRecorder {
+	rec := tracetest.NewSpanRecorder()
+	prev, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	rec := record(t)
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/api/emails/messages/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "email.FetchMessageContent")
+		span.End()
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	req := httptest.NewRequest("GET", "/api/emails/messages/m1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected the handler's and the server span, got %d", len(spans))
+	}
+	child, server := spans[0], spans[1]
+	if server.Name() != "GET /api/emails/messages/{id}" {
+		t.Errorf("expected the span named by route, got %q", server.Name())
+	}
+	if server.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the caller's trace to continue, got %s", server.SpanContext().TraceID())
+	}
+	if child.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("expected the handler's span to be a child of the server span")
+	}
+	if server.Status().Code != codes.Error {
+		t.Errorf("expected a 502 to mark the span failed, got %v", server.Status())
+	}
+}
+
+func TestQueryTracer(t *testing.T) {
+	rec := record(t)
+	var qt QueryTracer
+
+	// Outside a trace nothing is recorded
+	ctx := qt.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	qt.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	if n := len(rec.Ended()); n != 0 {
+		t.Fatalf("expected no spans outside a trace, got %d", n)
+	}
+
+	ctx, parent := Start(context.Background(), "job")
+	qctx := qt.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT *\n\t FROM users WHERE id=$1"})
+	qt.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{Err: pgx.ErrNoRows})
+	qctx = qt.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "UPDATE users SET x=1"})
+	qt.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("UPDATE 0"), Err: errors.New("boom")})
+	parent.End()
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 2 query spans and the job's, got %d", len(spans))
+	}
+	for _, a := range spans[0].Attributes() {
+		if a.Key == "db.statement" && a.Value.AsString() != "SELECT * FROM users WHERE id=$1" {
+			t.Errorf("expected the statement with its whitespace collapsed, got %q", a.Value.AsString())
+		}
+	}
+	if spans[0].Status().Code == codes.Error {
+		t.Error("expected no rows not to fail the span")
+	}
+	if spans[1].Status().Code != codes.Error {
+		t.Error("expected the failed query to fail its span")
+	}
+}
+
+func TestStatement(t *testing.T) {
+	long := "SELECT " + strings.Repeat("x, ", maxStatementLength)
+	if got := statement(long); len(got) != maxStatementLength+3 || !strings.HasSuffix(got, "...") {
+		t.Errorf("expected the statement cut to %d characters, got %d", maxStatementLength, len(got))
+	}
+}
+
+func TestSetup_Off(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	shutdown, err := Setup(context.Background(), Options{})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if otel.GetTracerProvider() != prev {
+		t.Error("expected no provider installed without an endpoint")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("expected shutdown to do nothing, got %v", err)
+	}
+}
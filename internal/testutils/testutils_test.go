@@ -0,0 +1,24 @@
+package testutils
+
+import (
+	"testing"
+
+	"github.com/desponda/inbox-whisperer/internal/ctxkeys"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestNewAuthedRequest(t *testing.T) {
+	r := NewAuthedRequest("GET", "/x", nil)
+	if ctxkeys.UserID(r.Context()) != UserID || ctxkeys.Token(r.Context()).AccessToken != AccessToken {
+		t.Fatal("expected the default user and token")
+	}
+
+	r = NewAuthedRequest("GET", "/x", nil, WithUser("other"), WithToken(nil), WithAccount("me@example.com"),
+		WithURLParam("id", "1"), WithURLParam("step", "labels"))
+	if ctxkeys.UserID(r.Context()) != "other" || ctxkeys.Token(r.Context()) != nil || ctxkeys.AccountID(r.Context()) != "me@example.com" {
+		t.Error("options did not override the defaults")
+	}
+	if chi.URLParam(r, "id") != "1" || chi.URLParam(r, "step") != "labels" {
+		t.Error("expected both URL params")
+	}
+}
@@ -0,0 +1,209 @@
+// Package unsubscribe carries out the unsubscribe a message's sender offers in its
+// List-Unsubscribe headers: the one-click POST of RFC 8058 when offered, otherwise an email to
+// the mailto address. The outcome is kept per sender.
+package unsubscribe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/analytics"
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrNotOffered means the message has no List-Unsubscribe header the server can act on
+	ErrNotOffered = errors.New("the message offers no way to unsubscribe")
+	// ErrManualOnly means the sender only offers a web page, which the user has to open
+	ErrManualOnly = errors.New("the sender only offers a web page to unsubscribe")
+)
+
+// defaultSubject is sent when the mailto URI sets none
+const defaultSubject = "unsubscribe"
+
+// MessageGetter loads a cached message (see data.EmailMessageRepository)
+type MessageGetter interface {
+	GetMessageByID(ctx context.Context, userID, emailMessageID string) (*models.EmailMessage, error)
+}
+
+// RawSender sends an RFC 2822 message from the token's mailbox (see gmail.GmailService.SendRaw)
+type RawSender interface {
+	SendRaw(ctx context.Context, token *oauth2.Token, raw []byte) error
+}
+
+// ActionRecorder counts actions taken on the user's behalf (see analytics.Service)
+type ActionRecorder interface {
+	Record(ctx context.Context, userID, action, targetType string) error
+}
+
+// Service unsubscribes users from senders
+type Service struct {
+	messages MessageGetter
+	repo     data.UnsubscribeRepository
+	sender   RawSender
+
+	// HTTPClient makes the one-click requests; http.DefaultClient when nil
+	HTTPClient *http.Client
+	// Analytics, if set, counts successful unsubscribes
+	Analytics ActionRecorder
+
+	// allowPrivate permits one-click URLs over plain http and on loopback and private addresses
+	// (tests)
+	allowPrivate bool
+	now          func() time.Time
+}
+
+func NewService(messages MessageGetter, repo data.UnsubscribeRepository, sender RawSender) *Service {
+	return &Service{messages: messages, repo: repo, sender: sender, now: time.Now}
+}
+
+// Unsubscribe unsubscribes the user from the sender of a cached message and records the outcome.
+// A sender that refuses the request is a failed outcome, not an error; the errors are
+// data.ErrNotFound for an unknown message, ErrNotOffered, ErrManualOnly, and a missing send
+// scope for mailto unsubscribes, after which nothing was attempted.
+func (s *Service) Unsubscribe(ctx context.Context, userID string, token *oauth2.Token, messageID string) (*models.Unsubscribe, error) {
+	msg, err := s.messages.GetMessageByID(ctx, userID, messageID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && msg == nil) {
+		return nil, data.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	offer := msg.ListUnsubscribe
+	if offer == nil {
+		return nil, ErrNotOffered
+	}
+	sender := msg.SenderAddress
+	if sender == "" {
+		sender, _ = models.ParseAddress(msg.Sender)
+	}
+	u := &models.Unsubscribe{UserID: userID, SenderAddress: sender, EmailMessageID: messageID}
+	switch {
+	case offer.OneClick:
+		u.Method = models.UnsubscribeOneClick
+		err = s.oneClick(ctx, offer.URL)
+	case offer.Mailto != "":
+		u.Method = models.UnsubscribeMailto
+		err = s.mailto(ctx, token, offer.Mailto)
+		if errors.Is(err, provider.ErrMissingScope) {
+			return nil, err
+		}
+	default:
+		return nil, ErrManualOnly
+	}
+	u.Status = models.UnsubscribeSucceeded
+	if err != nil {
+		u.Status, u.Detail = models.UnsubscribeFailed, err.Error()
+	}
+	if err := s.repo.Record(ctx, u); err != nil {
+		return nil, err
+	}
+	if u.Status == models.UnsubscribeSucceeded && s.Analytics != nil {
+		if err := s.Analytics.Record(ctx, userID, analytics.ActionUnsubscribed, "sender"); err != nil {
+			log.Warn().Err(err).Str("user_id", userID).Msg("unsubscribe: failed to record action")
+		}
+	}
+	return u, nil
+}
+
+// oneClick makes the RFC 8058 POST. Any 2xx answer counts as done.
+func (s *Service) oneClick(ctx context.Context, target string) error {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid unsubscribe url")
+	}
+	if !s.allowPrivate && (u.Scheme != "https" || privateHost(u.Hostname())) {
+		return fmt.Errorf("unsubscribe url must be https on a public address")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader("List-Unsubscribe=One-Click"))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sender answered HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// mailto sends the request from the user's mailbox, as the sender expects
+func (s *Service) mailto(ctx context.Context, token *oauth2.Token, uri string) error {
+	raw, err := mailtoMessage(uri, s.now())
+	if err != nil {
+		return err
+	}
+	return s.sender.SendRaw(ctx, token, raw)
+}
+
+// mailtoMessage writes the message a mailto URI (RFC 6068) asks for: to its addresses, with its
+// subject and body
+func mailtoMessage(uri string, date time.Time) ([]byte, error) {
+	u, err := url.Parse(uri)
+	if err != nil || !strings.EqualFold(u.Scheme, "mailto") {
+		return nil, fmt.Errorf("invalid mailto uri")
+	}
+	to, err := url.PathUnescape(u.Opaque)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mailto uri")
+	}
+	addrs, err := mail.ParseAddressList(to)
+	if err != nil || len(addrs) == 0 {
+		return nil, fmt.Errorf("invalid mailto address %q", to)
+	}
+	list := make([]string, len(addrs))
+	for i, a := range addrs {
+		list[i] = a.Address
+	}
+	q := u.Query()
+	subject := strings.Join(strings.Fields(q.Get("subject")), " ")
+	if subject == "" {
+		subject = defaultSubject
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(list, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	body := q.Get("body")
+	if body == "" {
+		body = defaultSubject
+	}
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes(), nil
+}
+
+// privateHost reports whether host is a loopback, private or link-local address, or localhost.
+// Names are not resolved, so a public name pointing at a private address still passes.
+func privateHost(host string) bool {
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified())
+}
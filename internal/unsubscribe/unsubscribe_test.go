@@ -0,0 +1,152 @@
+package unsubscribe
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/data"
+	"github.com/desponda/inbox-whisperer/internal/models"
+	"github.com/desponda/inbox-whisperer/internal/service/provider"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/oauth2"
+)
+
+type fakeMessages map[string]*models.EmailMessage
+
+func (f fakeMessages) GetMessageByID(ctx context.Context, userID, id string) (*models.EmailMessage, error) {
+	if m, ok := f[id]; ok {
+		return m, nil
+	}
+	return nil, pgx.ErrNoRows
+}
+
+type fakeRepo struct{ recorded []models.Unsubscribe }
+
+func (f *fakeRepo) Record(ctx context.Context, u *models.Unsubscribe) error {
+	u.AttemptedAt = time.Now()
+	f.recorded = append(f.recorded, *u)
+	return nil
+}
+
+type fakeSender struct {
+	raw []byte
+	err error
+}
+
+func (f *fakeSender) SendRaw(ctx context.Context, token *oauth2.Token, raw []byte) error {
+	f.raw = raw
+	return f.err
+}
+
+type fakeAnalytics struct{ actions []string }
+
+func (f *fakeAnalytics) Record(ctx context.Context, userID, action, targetType string) error {
+	f.actions = append(f.actions, action)
+	return nil
+}
+
+func TestParseListUnsubscribe(t *testing.T) {
+	for _, tc := range []struct {
+		header, post string
+		want         *models.ListUnsubscribe
+	}{
+		{"<mailto:u@example.com?subject=stop>, <https://example.com/u?id=1>", "List-Unsubscribe=One-Click",
+			&models.ListUnsubscribe{Mailto: "mailto:u@example.com?subject=stop", URL: "https://example.com/u?id=1", OneClick: true}},
+		{"<https://example.com/u>", "", &models.ListUnsubscribe{URL: "https://example.com/u"}},
+		{"<http://example.com/u>, <mailto:u@example.com>", "List-Unsubscribe=One-Click", &models.ListUnsubscribe{Mailto: "mailto:u@example.com"}},
+		{"https://example.com/u", "", nil},
+		{"", "", nil},
+	} {
+		got := models.ParseListUnsubscribe(tc.header, tc.post)
+		if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+			t.Errorf("%q: expected %+v, got %+v", tc.header, tc.want, got)
+		}
+	}
+}
+
+func TestService_OneClick(t *testing.T) {
+	var form string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		form = r.Method + " " + string(b)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	msgs := fakeMessages{"m1": {EmailMessageID: "m1", Sender: "News <news@example.com>",
+		ListUnsubscribe: &models.ListUnsubscribe{URL: server.URL + "/u", OneClick: true}}}
+	repo, stats := &fakeRepo{}, &fakeAnalytics{}
+	s := NewService(msgs, repo, &fakeSender{})
+	s.Analytics = stats
+
+	// Off in production: the test server is plain http on loopback
+	u, err := s.Unsubscribe(context.Background(), "u1", nil, "m1")
+	if err != nil || u.Status != models.UnsubscribeFailed || form != "" {
+		t.Fatalf("expected a local url to be refused, got %+v (%v, %q)", u, err, form)
+	}
+
+	s.allowPrivate = true
+	u, err = s.Unsubscribe(context.Background(), "u1", nil, "m1")
+	if err != nil || u.Status != models.UnsubscribeSucceeded || u.Method != models.UnsubscribeOneClick || u.SenderAddress != "news@example.com" {
+		t.Fatalf("expected a one-click unsubscribe, got %+v (%v)", u, err)
+	}
+	if form != "POST List-Unsubscribe=One-Click" {
+		t.Errorf("unexpected request %q", form)
+	}
+
+	status = http.StatusInternalServerError
+	if u, err = s.Unsubscribe(context.Background(), "u1", nil, "m1"); err != nil || u.Status != models.UnsubscribeFailed || !strings.Contains(u.Detail, "500") {
+		t.Errorf("expected the refusal recorded, got %+v (%v)", u, err)
+	}
+	if len(repo.recorded) != 3 || len(stats.actions) != 1 {
+		t.Errorf("expected every outcome recorded and one unsubscribe counted, got %+v and %v", repo.recorded, stats.actions)
+	}
+}
+
+func TestService_Mailto(t *testing.T) {
+	msgs := fakeMessages{
+		"m1": {EmailMessageID: "m1", SenderAddress: "news@example.com",
+			ListUnsubscribe: &models.ListUnsubscribe{Mailto: "mailto:leave@example.com?subject=Remove%20me", URL: "https://example.com/u"}},
+		"m2": {EmailMessageID: "m2", ListUnsubscribe: &models.ListUnsubscribe{URL: "https://example.com/u"}},
+		"m3": {EmailMessageID: "m3"},
+	}
+	sender := &fakeSender{}
+	s := NewService(msgs, &fakeRepo{}, sender)
+	ctx := context.Background()
+
+	u, err := s.Unsubscribe(ctx, "u1", &oauth2.Token{}, "m1")
+	if err != nil || u.Status != models.UnsubscribeSucceeded || u.Method != models.UnsubscribeMailto {
+		t.Fatalf("expected a mailto unsubscribe, got %+v (%v)", u, err)
+	}
+	raw := string(sender.raw)
+	if !strings.Contains(raw, "To: leave@example.com\r\n") || !strings.Contains(raw, "Subject: Remove me\r\n") {
+		t.Errorf("unexpected message %q", raw)
+	}
+
+	sender.err = &provider.ScopeError{Feature: provider.FeatureSend}
+	if _, err := s.Unsubscribe(ctx, "u1", &oauth2.Token{}, "m1"); !errors.Is(err, provider.ErrMissingScope) {
+		t.Errorf("expected the missing scope, got %v", err)
+	}
+	if _, err := s.Unsubscribe(ctx, "u1", &oauth2.Token{}, "m2"); !errors.Is(err, ErrManualOnly) {
+		t.Errorf("expected a web page only, got %v", err)
+	}
+	if _, err := s.Unsubscribe(ctx, "u1", &oauth2.Token{}, "m3"); !errors.Is(err, ErrNotOffered) {
+		t.Errorf("expected nothing offered, got %v", err)
+	}
+	if _, err := s.Unsubscribe(ctx, "u1", &oauth2.Token{}, "nope"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
+
+func TestMailtoMessage_RejectsHeaderInjection(t *testing.T) {
+	if _, err := mailtoMessage("mailto:a@example.com%0D%0ABcc:%20b@example.com", time.Now()); err == nil {
+		t.Error("expected the injected header to be refused")
+	}
+}
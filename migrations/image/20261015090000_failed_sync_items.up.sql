@@ -0,0 +1,17 @@
+-- Inbox Whisperer: dead-letter table for messages that failed to sync
+
+-- Messages whose fetch or upsert failed during a provider sync. Rows are retried
+-- with capped attempts and removed once the message is cached successfully.
+CREATE TABLE IF NOT EXISTS failed_sync_items (
+    id SERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    email_message_id TEXT NOT NULL,
+    stage VARCHAR(32) NOT NULL,
+    last_error TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    first_failed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_failed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE(user_id, email_message_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_failed_sync_items_user_id ON failed_sync_items(user_id);
@@ -0,0 +1,19 @@
+-- Inbox Whisperer: cached provider labels
+
+-- Labels as reported by the user's email provider. provider_label_id is the
+-- provider's own identifier (e.g. Gmail "INBOX" or "Label_12").
+CREATE TABLE IF NOT EXISTS labels (
+    id SERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    provider_label_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    type VARCHAR(16) NOT NULL,
+    background_color VARCHAR(16),
+    text_color VARCHAR(16),
+    message_list_visibility VARCHAR(16),
+    label_list_visibility VARCHAR(32),
+    cached_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE(user_id, provider_label_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_labels_user_id ON labels(user_id);
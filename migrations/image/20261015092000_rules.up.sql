@@ -0,0 +1,15 @@
+-- Inbox Whisperer: user-defined rules evaluated against newly synced messages
+
+-- conditions: [{"field": "from|subject|snippet", "contains": "..."}] (all must match)
+-- actions:    [{"type": "apply_label", "label": "Finance/Receipts"}]
+CREATE TABLE IF NOT EXISTS rules (
+    id SERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    conditions JSONB NOT NULL,
+    actions JSONB NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rules_user_id ON rules(user_id);
@@ -0,0 +1,9 @@
+-- Inbox Whisperer: onboarding progress
+
+-- One row per completed onboarding step; missing rows are incomplete steps.
+CREATE TABLE IF NOT EXISTS user_onboarding (
+    user_id TEXT NOT NULL,
+    step VARCHAR(64) NOT NULL,
+    completed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, step)
+);
@@ -0,0 +1,11 @@
+-- Inbox Whisperer: daily inbox size samples for activity stats
+
+-- One sample per user per day, taken after a sync completes (latest sync wins).
+CREATE TABLE IF NOT EXISTS inbox_size_snapshots (
+    user_id TEXT NOT NULL,
+    day DATE NOT NULL,
+    size INTEGER NOT NULL,
+    PRIMARY KEY (user_id, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_action_logs_user_action_created ON action_logs(user_id, action, created_at);
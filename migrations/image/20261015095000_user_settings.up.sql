@@ -0,0 +1,9 @@
+-- Inbox Whisperer: per-user settings
+
+-- Missing rows mean defaults. ai_data_sharing is opt-in: message content is only
+-- sent to external LLM providers once the user has consented.
+CREATE TABLE IF NOT EXISTS user_settings (
+    user_id TEXT PRIMARY KEY,
+    ai_data_sharing BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
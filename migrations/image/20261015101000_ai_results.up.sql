@@ -0,0 +1,15 @@
+-- Inbox Whisperer: cached AI outputs
+
+-- One row per message and kind (summary, category). content_hash is the hash of the
+-- subject and body the result was computed from; a mismatch means the result is stale.
+CREATE TABLE IF NOT EXISTS ai_results (
+    user_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    content_hash TEXT NOT NULL,
+    result TEXT NOT NULL,
+    confidence FLOAT,
+    source TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, message_id, kind)
+);
@@ -0,0 +1,16 @@
+-- Inbox Whisperer: user feedback on message categorization
+
+-- correct=true confirms the category the message had; correct=false records a correction.
+-- sender is the normalized address so corrections can be aggregated into rule suggestions.
+CREATE TABLE IF NOT EXISTS category_feedback (
+    id SERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    sender TEXT NOT NULL DEFAULT '',
+    previous_category TEXT NOT NULL DEFAULT '',
+    category TEXT NOT NULL,
+    correct BOOLEAN NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_category_feedback_user_sender ON category_feedback(user_id, sender);
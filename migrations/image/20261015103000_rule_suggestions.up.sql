@@ -0,0 +1,30 @@
+-- Inbox Whisperer: manual action history and rule suggestions
+
+-- Manual actions the user took on a message, with the message attributes suggestions group by.
+CREATE TABLE IF NOT EXISTS user_actions (
+    id SERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    sender TEXT NOT NULL DEFAULT '',
+    category TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_actions_user_action ON user_actions(user_id, action);
+
+-- Rules proposed from repeated behavior. key identifies the proposal (e.g. archive by sender)
+-- so a dismissed or accepted suggestion is never offered again.
+CREATE TABLE IF NOT EXISTS rule_suggestions (
+    id SERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    key TEXT NOT NULL,
+    rule JSONB NOT NULL,
+    reason TEXT NOT NULL,
+    evidence INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'pending',
+    rule_id INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, key)
+);
@@ -0,0 +1,21 @@
+-- Inbox Whisperer: batch re-categorization jobs
+
+-- A job re-runs categorization over cached messages, for one user or (user_id = '') all users.
+-- estimated_tokens is the LLM cost estimate taken when the job was enqueued.
+CREATE TABLE IF NOT EXISTS recategorize_jobs (
+    id SERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL DEFAULT '',
+    requested_by TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued',
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    ai_messages INTEGER NOT NULL DEFAULT 0,
+    estimated_tokens INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_recategorize_jobs_status ON recategorize_jobs(status);
@@ -0,0 +1,6 @@
+-- Inbox Whisperer: provider classification signals
+
+-- The provider's own category (Gmail tabs, mapped onto Whisperer category names) and importance
+-- marker, stored apart from the Whisperer category so both can be shown side by side.
+ALTER TABLE email_messages ADD COLUMN IF NOT EXISTS provider_category TEXT NOT NULL DEFAULT '';
+ALTER TABLE email_messages ADD COLUMN IF NOT EXISTS provider_important BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,9 @@
+-- Inbox Whisperer: muted threads
+
+-- New messages in a muted thread are archived during sync.
+CREATE TABLE IF NOT EXISTS muted_threads (
+    user_id TEXT NOT NULL,
+    thread_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, thread_id)
+);
@@ -0,0 +1,18 @@
+-- Inbox Whisperer: per-contact conversation aggregates
+
+-- Recomputed from cached threads after each sync. Reply counts and average latencies cover
+-- consecutive thread messages where the user answered the contact or the contact answered the user.
+CREATE TABLE IF NOT EXISTS contact_stats (
+    user_id TEXT NOT NULL,
+    address TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    messages_received INTEGER NOT NULL DEFAULT 0,
+    threads INTEGER NOT NULL DEFAULT 0,
+    user_replies INTEGER NOT NULL DEFAULT 0,
+    avg_user_reply_seconds BIGINT NOT NULL DEFAULT 0,
+    contact_replies INTEGER NOT NULL DEFAULT 0,
+    avg_contact_reply_seconds BIGINT NOT NULL DEFAULT 0,
+    last_message_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, address)
+);
@@ -0,0 +1,11 @@
+-- Inbox Whisperer: per-user background sync schedule
+
+-- tier '' means the deploy's default tier. interval_seconds, when set, overrides the tier
+-- (0 = manual-only). last_synced_at is the last scheduled sync attempt.
+CREATE TABLE IF NOT EXISTS user_sync_settings (
+    user_id TEXT PRIMARY KEY,
+    tier TEXT NOT NULL DEFAULT '',
+    interval_seconds INTEGER,
+    last_synced_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,6 @@
+-- Inbox Whisperer: detect provider-side changes to cached messages
+
+-- content_hash fingerprints the provider's copy (headers, body and labels); '' means not yet
+-- hashed. changed_at is when a re-sync last found a different hash, NULL if never.
+ALTER TABLE email_messages ADD COLUMN IF NOT EXISTS content_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE email_messages ADD COLUMN IF NOT EXISTS changed_at TIMESTAMP;
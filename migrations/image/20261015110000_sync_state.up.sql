@@ -0,0 +1,11 @@
+-- Inbox Whisperer: per-user provider sync cursor
+
+-- Written in the same transaction as the messages of each synced page. page_token is where an
+-- interrupted run resumes ('' = start from the newest messages); history_id is the highest
+-- provider history ID committed.
+CREATE TABLE IF NOT EXISTS sync_state (
+    user_id TEXT PRIMARY KEY,
+    page_token TEXT NOT NULL DEFAULT '',
+    history_id BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,5 @@
+-- Inbox Whisperer: opt out of local message caching
+
+-- When set, message lists are proxied to the provider page by page and nothing about the
+-- user's messages is written to email_messages.
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS disable_local_cache BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+-- Inbox Whisperer: metadata-only caching
+
+-- When set, only headers and snippets are cached: email_messages.body, html_body and raw_json
+-- stay NULL for the user, and message bodies are always fetched from the provider.
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS metadata_only_cache BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,6 @@
+-- Inbox Whisperer: keep message headers apart from the raw provider payload
+
+-- Headers are stored on their own so raw_json can be dropped (see storage.drop_raw_json)
+-- without losing access to them. Rows cached earlier are backfilled from raw_json by the
+-- prune job before their payload is dropped.
+ALTER TABLE email_messages ADD COLUMN IF NOT EXISTS headers JSONB;
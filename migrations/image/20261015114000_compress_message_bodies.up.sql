@@ -0,0 +1,16 @@
+-- Inbox Whisperer: compressed message bodies
+
+-- Bodies become bytea prefixed with a one-byte codec marker: 0 for raw UTF-8, 1 for gzip.
+-- Existing bodies are converted as raw; the compress_bodies backfill then compresses the large
+-- ones. Empty bodies are stored as NULL. Guarded so re-running is a no-op.
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_name = 'email_messages' AND column_name = 'body') = 'text' THEN
+        ALTER TABLE email_messages
+            ALTER COLUMN body TYPE BYTEA USING
+                CASE WHEN body IS NULL OR body = '' THEN NULL ELSE '\x00'::bytea || convert_to(body, 'UTF8') END,
+            ALTER COLUMN html_body TYPE BYTEA USING
+                CASE WHEN html_body IS NULL OR html_body = '' THEN NULL ELSE '\x00'::bytea || convert_to(html_body, 'UTF8') END;
+    END IF;
+END $$;
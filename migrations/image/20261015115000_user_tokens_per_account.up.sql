@@ -0,0 +1,21 @@
+-- Inbox Whisperer: OAuth tokens per provider account
+
+-- Tokens were keyed by user only. Existing rows become the user's Gmail account, identified by
+-- the user's email (or the user ID when no user row exists). created_at orders a user's
+-- accounts so the first linked one stays the default. Guarded so re-running is a no-op.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'user_tokens' AND column_name = 'account_id') THEN
+        ALTER TABLE user_tokens
+            ADD COLUMN provider TEXT NOT NULL DEFAULT 'gmail',
+            ADD COLUMN account_id TEXT,
+            ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT now();
+        UPDATE user_tokens t SET
+            account_id = COALESCE((SELECT NULLIF(u.email, '') FROM users u WHERE u.id = t.user_id), t.user_id),
+            created_at = t.updated_at;
+        ALTER TABLE user_tokens ALTER COLUMN account_id SET NOT NULL;
+        ALTER TABLE user_tokens DROP CONSTRAINT user_tokens_pkey;
+        ALTER TABLE user_tokens ADD PRIMARY KEY (user_id, provider, account_id);
+    END IF;
+END $$;
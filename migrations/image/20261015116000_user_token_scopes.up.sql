@@ -0,0 +1,14 @@
+-- Inbox Whisperer: granted OAuth scopes per account
+
+-- Scopes the account granted, from the token response. Existing accounts signed in with the
+-- login scopes of the time, so they are backfilled with those. NULL means unknown and is
+-- not gated.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'user_tokens' AND column_name = 'granted_scopes') THEN
+        ALTER TABLE user_tokens ADD COLUMN granted_scopes TEXT[];
+        UPDATE user_tokens SET granted_scopes =
+            ARRAY['https://www.googleapis.com/auth/gmail.readonly', 'openid', 'profile', 'email'];
+    END IF;
+END $$;
@@ -0,0 +1,11 @@
+-- Inbox Whisperer: OAuth states
+
+-- States issued at login, deleted when a callback consumes them so each is accepted once.
+-- Kept in the database rather than the session so any instance can validate the callback.
+CREATE TABLE IF NOT EXISTS oauth_states (
+    state TEXT PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_states_expires_at ON oauth_states(expires_at);
@@ -0,0 +1,9 @@
+-- Inbox Whisperer: parsed sender address and display name
+
+-- Parsed from the raw From header (sender) at sync time, so analytics and contacts group by
+-- address rather than by "Name <addr>" strings. Rows cached earlier are filled in by the
+-- parse_senders backfill; NULL marks a row it has not reached yet.
+ALTER TABLE email_messages ADD COLUMN IF NOT EXISTS sender_address TEXT;
+ALTER TABLE email_messages ADD COLUMN IF NOT EXISTS sender_name TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_email_messages_user_sender_address ON email_messages(user_id, sender_address);
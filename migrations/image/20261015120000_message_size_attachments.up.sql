@@ -0,0 +1,6 @@
+-- Inbox Whisperer: message size and attachment flag
+
+-- Captured at sync time for has:attachment and larger: style filters. NULL marks rows cached
+-- before they were captured; those are filled in when the message is next re-synced.
+ALTER TABLE email_messages ADD COLUMN IF NOT EXISTS size_estimate BIGINT;
+ALTER TABLE email_messages ADD COLUMN IF NOT EXISTS has_attachments BOOLEAN;
@@ -0,0 +1,8 @@
+-- Inbox Whisperer: working hours for digest and notification delivery
+
+-- working_hours_start/end are "HH:MM" in timezone (IANA name, empty = UTC); both empty means
+-- deliveries may go out at any time. weekend_pause defers Saturday and Sunday deliveries.
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT '';
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS working_hours_start TEXT NOT NULL DEFAULT '';
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS working_hours_end TEXT NOT NULL DEFAULT '';
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS weekend_pause BOOLEAN NOT NULL DEFAULT FALSE;
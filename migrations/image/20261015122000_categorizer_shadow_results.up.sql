@@ -0,0 +1,16 @@
+-- Inbox Whisperer: shadow evaluation of candidate categorizers
+
+-- One row per message and shadow version, with the category the active categorizer chose at
+-- the same time. Rows are only read for agreement reports and never shown to users.
+CREATE TABLE IF NOT EXISTS categorizer_shadow_results (
+    user_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    shadow_version TEXT NOT NULL,
+    active_version TEXT NOT NULL,
+    active_category TEXT NOT NULL,
+    shadow_category TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, message_id, shadow_version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_categorizer_shadow_results_created ON categorizer_shadow_results (created_at);
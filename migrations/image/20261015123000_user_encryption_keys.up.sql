@@ -0,0 +1,11 @@
+-- Inbox Whisperer: end-to-end encryption of cached message bodies
+
+-- The stored half of each user's cache key: the passphrase salt and the X25519 public key that
+-- new bodies are sealed to. The private key is derived from the passphrase on unlock and kept
+-- in the session only.
+CREATE TABLE IF NOT EXISTS user_encryption_keys (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    salt BYTEA NOT NULL,
+    public_key BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
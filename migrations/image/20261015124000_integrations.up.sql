@@ -0,0 +1,32 @@
+-- Inbox Whisperer: send-to integrations (Notion, Obsidian, Readwise)
+
+-- Per-user credentials and options for each integration, as the integration's own key/value config
+CREATE TABLE IF NOT EXISTS user_integrations (
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    integration TEXT NOT NULL,
+    config JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, integration)
+);
+
+-- One row per message sent to an integration; the table doubles as the delivery queue. item holds
+-- the formatted message until the delivery finishes and is cleared then.
+CREATE TABLE IF NOT EXISTS integration_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_id TEXT NOT NULL,
+    integration TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    external_url TEXT NOT NULL DEFAULT '',
+    item JSONB,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_integration_deliveries_due ON integration_deliveries (next_attempt_at) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS idx_integration_deliveries_user ON integration_deliveries (user_id, id DESC);
@@ -0,0 +1,14 @@
+-- Inbox Whisperer: Atom feeds of a sender's messages for feed readers
+
+-- One feed per user and sender. The feed URL carries a random token; only its hash is stored.
+-- content holds the rendered Atom document, regenerated after each sync.
+CREATE TABLE IF NOT EXISTS feeds (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    sender_address TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    content BYTEA,
+    generated_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, sender_address)
+);
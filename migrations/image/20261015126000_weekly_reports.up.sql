@@ -0,0 +1,33 @@
+-- Inbox Whisperer: opt-in weekly report emails
+
+-- One row per user who has saved report settings. next_due_at is when the worker sends the next
+-- report; it is NULL while reports are off.
+CREATE TABLE IF NOT EXISTS report_settings (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    via TEXT NOT NULL DEFAULT 'account',
+    weekday INTEGER NOT NULL DEFAULT 1,
+    subject TEXT NOT NULL DEFAULT '',
+    intro TEXT NOT NULL DEFAULT '',
+    sections TEXT[] NOT NULL DEFAULT '{}',
+    next_due_at TIMESTAMP,
+    last_sent_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_settings_due ON report_settings (next_due_at) WHERE enabled;
+
+-- One row per report the worker tried to send
+CREATE TABLE IF NOT EXISTS report_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    via TEXT NOT NULL,
+    recipient TEXT NOT NULL DEFAULT '',
+    subject TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    period_start TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_deliveries_user ON report_deliveries (user_id, id DESC);
@@ -0,0 +1,14 @@
+-- Inbox Whisperer: mailboxes linked over IMAP
+
+-- One IMAP mailbox per user. password is encrypted with the deployment's imap.credential_key
+-- (AES-256-GCM, nonce first).
+CREATE TABLE IF NOT EXISTS imap_accounts (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    host TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    tls BOOLEAN NOT NULL DEFAULT TRUE,
+    username TEXT NOT NULL,
+    password BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
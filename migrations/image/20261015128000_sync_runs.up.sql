@@ -0,0 +1,20 @@
+-- Inbox Whisperer: per-run sync summaries
+
+-- One row per provider sync run. error is empty for runs that completed.
+CREATE TABLE IF NOT EXISTS sync_runs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    listed INTEGER NOT NULL DEFAULT 0,
+    fetched INTEGER NOT NULL DEFAULT 0,
+    upserted INTEGER NOT NULL DEFAULT 0,
+    skipped INTEGER NOT NULL DEFAULT 0,
+    errors INTEGER NOT NULL DEFAULT 0,
+    quota_hits INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_runs_started_at ON sync_runs (started_at);
+CREATE INDEX IF NOT EXISTS idx_sync_runs_user ON sync_runs (user_id, started_at DESC);
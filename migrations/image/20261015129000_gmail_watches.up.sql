@@ -0,0 +1,17 @@
+-- Inbox Whisperer: Gmail push notification watches
+
+-- The Gmail watch registered for each user's default Gmail account. Gmail expires watches after
+-- seven days; renew_after is when the next registration is attempted, a day before expiry or
+-- an hour after a failed attempt.
+CREATE TABLE IF NOT EXISTS gmail_watches (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    account_id TEXT NOT NULL,
+    history_id BIGINT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP,
+    renew_after TIMESTAMP NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_gmail_watches_account ON gmail_watches (LOWER(account_id));
+CREATE INDEX IF NOT EXISTS idx_gmail_watches_renew_after ON gmail_watches (renew_after);
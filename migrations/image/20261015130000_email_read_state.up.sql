@@ -0,0 +1,5 @@
+-- Inbox Whisperer: read state of cached messages
+
+-- Whether the message has been read at the provider (Gmail: no UNREAD label). Rows cached
+-- before read state was tracked count as read until a re-sync or fetch rewrites them.
+ALTER TABLE email_messages ADD COLUMN IF NOT EXISTS is_read BOOLEAN NOT NULL DEFAULT TRUE;
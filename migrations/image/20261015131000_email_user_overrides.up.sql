@@ -0,0 +1,14 @@
+-- Inbox Whisperer: per-message user overrides
+
+-- Changes a user made to a message through PATCH /api/emails/{id}. NULL category or starred
+-- means the user has not overridden it. labels are the label names the user added; they are
+-- kept even when the provider cannot apply labels, so they survive a re-sync.
+CREATE TABLE IF NOT EXISTS email_user_overrides (
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email_message_id TEXT NOT NULL,
+    labels TEXT[] NOT NULL DEFAULT '{}',
+    category TEXT,
+    starred BOOLEAN,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, email_message_id)
+);
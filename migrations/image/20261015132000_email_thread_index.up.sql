@@ -0,0 +1,4 @@
+-- Inbox Whisperer: thread lookups
+
+-- Serves GET /api/threads/{id} and the thread grouping of GET /api/threads
+CREATE INDEX IF NOT EXISTS idx_email_messages_user_thread ON email_messages (user_id, thread_id, internal_date);
@@ -0,0 +1,16 @@
+-- Inbox Whisperer: OAuth app credentials rotated at runtime
+
+-- The OAuth app credentials set through the admin API, one row per provider, overriding the
+-- configured ones. Secrets are encrypted with the configured credential key. The previous
+-- credentials stay usable until previous_valid_until, so token refreshes that still need them
+-- keep working while the rotation settles.
+CREATE TABLE IF NOT EXISTS oauth_client_credentials (
+    provider TEXT PRIMARY KEY,
+    client_id TEXT NOT NULL,
+    client_secret BYTEA NOT NULL,
+    previous_client_id TEXT NOT NULL DEFAULT '',
+    previous_client_secret BYTEA,
+    previous_valid_until TIMESTAMP,
+    rotated_by TEXT NOT NULL DEFAULT '',
+    rotated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,17 @@
+-- Inbox Whisperer: passkeys (WebAuthn credentials) for step-up authentication
+
+-- A user's registered passkeys. The public key is stored as DER-encoded SubjectPublicKeyInfo;
+-- sign_count is the authenticator's counter from the last verified assertion.
+CREATE TABLE IF NOT EXISTS passkeys (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id TEXT NOT NULL UNIQUE,
+    public_key BYTEA NOT NULL,
+    algorithm INTEGER NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_passkeys_user_id ON passkeys(user_id);
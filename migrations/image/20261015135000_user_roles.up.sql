@@ -0,0 +1,7 @@
+-- Inbox Whisperer: user roles
+
+-- The user's role: 'user', or 'admin' for access to the /api/admin endpoints. Users listed in
+-- server.admin_user_ids are admins whatever their role, so the first admin can grant others.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user';
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'));
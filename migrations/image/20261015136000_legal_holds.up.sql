@@ -0,0 +1,24 @@
+-- Inbox Whisperer: legal holds
+
+-- Users whose cached mail must be preserved: while a hold is in place nothing deletes or prunes
+-- their messages, whether the user, an admin or a background job asks. Deleting a held user is
+-- refused as well, as it would cascade to their messages.
+CREATE TABLE IF NOT EXISTS legal_holds (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE RESTRICT,
+    reason TEXT NOT NULL DEFAULT '',
+    placed_by TEXT NOT NULL,
+    placed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- The audit trail of holds: when each was placed and released, and every deletion it blocked.
+-- Kept without a foreign key so the trail outlives the hold and the user.
+CREATE TABLE IF NOT EXISTS legal_hold_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    event TEXT NOT NULL CHECK (event IN ('placed', 'released', 'blocked')),
+    action TEXT NOT NULL DEFAULT '',
+    actor TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_legal_hold_events_user ON legal_hold_events(user_id, created_at DESC);
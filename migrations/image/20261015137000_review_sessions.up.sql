@@ -0,0 +1,23 @@
+-- Inbox Whisperer: review sessions
+
+-- A guided triage pass over a user's oldest unhandled messages, queued when the session is
+-- created and decided one message at a time
+CREATE TABLE IF NOT EXISTS review_sessions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    completed_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_review_sessions_user ON review_sessions(user_id, created_at DESC);
+
+-- The queue of a session in review order. action is empty until the message is decided: one of
+-- the message actions, 'keep' to leave it as it is or 'skip' to bring it back in a later session.
+CREATE TABLE IF NOT EXISTS review_session_items (
+    session_id BIGINT NOT NULL REFERENCES review_sessions(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    email_message_id TEXT NOT NULL,
+    action TEXT NOT NULL DEFAULT '',
+    acted_at TIMESTAMPTZ,
+    PRIMARY KEY (session_id, position)
+);
+CREATE INDEX IF NOT EXISTS idx_review_session_items_message ON review_session_items(email_message_id);
@@ -0,0 +1,13 @@
+-- Inbox Whisperer: client state
+
+-- Small JSON documents the frontend syncs across a user's devices, such as collapsed bundles,
+-- selected views and reading position. version counts the writes to a key, so a client writing
+-- over a change it has not seen is refused.
+CREATE TABLE IF NOT EXISTS client_state (
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value JSONB NOT NULL,
+    version BIGINT NOT NULL DEFAULT 1,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, key)
+);
@@ -0,0 +1,16 @@
+-- Inbox Whisperer: daily newsletter digest
+
+-- Categories whose messages are archived during the day and gathered into one
+-- "Today's newsletters" message; empty turns the digest off
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS digest_categories TEXT[] NOT NULL DEFAULT '{}';
+
+-- Messages archived into a digest. day is the user's local day; a message is digested once, so
+-- moving it back to the inbox keeps it there.
+CREATE TABLE IF NOT EXISTS newsletter_digest_items (
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email_message_id TEXT NOT NULL,
+    day DATE NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, email_message_id)
+);
+CREATE INDEX IF NOT EXISTS idx_newsletter_digest_items_day ON newsletter_digest_items(user_id, day);
@@ -0,0 +1,10 @@
+-- Inbox Whisperer: public status page history
+
+-- One row per component per health check run, kept for the uptime percentages of
+-- GET /api/status. state is operational, degraded or down.
+CREATE TABLE IF NOT EXISTS status_checks (
+    component TEXT NOT NULL,
+    state TEXT NOT NULL,
+    checked_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_status_checks_checked_at ON status_checks(checked_at);
@@ -0,0 +1,17 @@
+-- Inbox Whisperer: unsubscribe assistant
+
+-- The List-Unsubscribe and List-Unsubscribe-Post headers, parsed at sync time; NULL when the
+-- message offers no way to unsubscribe or was cached before they were parsed
+ALTER TABLE email_messages ADD COLUMN IF NOT EXISTS list_unsubscribe JSONB;
+
+-- The outcome of the latest unsubscribe from each sender
+CREATE TABLE IF NOT EXISTS unsubscribes (
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    sender_address TEXT NOT NULL,
+    email_message_id TEXT NOT NULL,
+    method TEXT NOT NULL,
+    status TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, sender_address)
+);
@@ -0,0 +1,4 @@
+-- Inbox Whisperer: sender and volume analytics
+
+-- The analytics endpoints filter a user's messages by when they arrived
+CREATE INDEX IF NOT EXISTS idx_email_messages_user_internal_date ON email_messages(user_id, internal_date);
@@ -0,0 +1,24 @@
+-- Inbox Whisperer: bulk actions
+
+-- A job applies one action to every cached message of a user matching a filter. Empty filter
+-- fields match everything; before_internal_date (milliseconds) is 0 when unset.
+CREATE TABLE IF NOT EXISTS bulk_jobs (
+    id SERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    to_category TEXT NOT NULL DEFAULT '',
+    sender TEXT NOT NULL DEFAULT '',
+    category TEXT NOT NULL DEFAULT '',
+    before_internal_date BIGINT NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'queued',
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_bulk_jobs_status ON bulk_jobs(status);
@@ -0,0 +1,41 @@
+-- Inbox Whisperer: background job queue
+
+-- One row per unit of background work. Workers claim queued jobs whose run_at has passed, and
+-- running jobs whose lease (locked_until) expired because their worker died. ref_id points at
+-- the kind's own record of the work, e.g. bulk_jobs for kind 'bulk'; user_id is NULL for jobs
+-- covering all users. checkpoint is where an interrupted job resumes.
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+    ref_id BIGINT NOT NULL DEFAULT 0,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'queued',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 1,
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    checkpoint TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_status_run_at ON jobs(status, run_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_kind_ref ON jobs(kind, ref_id);
+
+-- Re-categorization and bulk jobs were run by their own workers until now; queue the unfinished
+-- ones so they are not stranded
+INSERT INTO jobs (kind, user_id, ref_id, max_attempts, total)
+SELECT 'recategorize', NULLIF(r.user_id, ''), r.id, 3, r.total FROM recategorize_jobs r
+WHERE r.status IN ('queued', 'running')
+  AND NOT EXISTS (SELECT 1 FROM jobs j WHERE j.kind = 'recategorize' AND j.ref_id = r.id);
+
+INSERT INTO jobs (kind, user_id, ref_id, max_attempts, total)
+SELECT 'bulk', b.user_id, b.id, 3, b.total FROM bulk_jobs b
+WHERE b.status IN ('queued', 'running')
+  AND NOT EXISTS (SELECT 1 FROM jobs j WHERE j.kind = 'bulk' AND j.ref_id = b.id);
@@ -0,0 +1,8 @@
+-- Inbox Whisperer: fingerprint labels apart from message content
+
+-- content_hash used to cover labels too, so reading, starring or archiving a message looked
+-- like new content and re-ran categorization. label_hash now covers labels and content_hash
+-- only headers and bodies; '' means not yet hashed. Existing content hashes include labels,
+-- so they are cleared and re-hashed on the next sync instead of reporting every message changed.
+ALTER TABLE email_messages ADD COLUMN IF NOT EXISTS label_hash TEXT NOT NULL DEFAULT '';
+UPDATE email_messages SET content_hash = '' WHERE content_hash <> '';
@@ -0,0 +1,153 @@
+// Package client is a Go client for the Inbox Whisperer API described in api/openapi.yaml.
+// It authenticates with an API key (see the device flow in device.go) or a browser session
+// cookie, and returns API errors as *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SessionCookie is the name of the server's session cookie
+const SessionCookie = "session_id"
+
+// Client calls the API at BaseURL
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	apiKey     string
+	session    string
+	userAgent  string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithAPIKey authenticates requests with an API key ("iw_...")
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithSession authenticates requests with a browser session ID
+func WithSession(sessionID string) Option {
+	return func(c *Client) { c.session = sessionID }
+}
+
+// WithHTTPClient replaces the default client, e.g. to set a proxy or timeout
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.HTTPClient = hc }
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// New returns a client for the server at baseURL, e.g. https://inbox.example.com
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		userAgent:  "inbox-whisperer-go-client",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is a non-2xx API response
+type Error struct {
+	StatusCode int `json:"-"`
+	// Code is the machine-readable error code, when the server sent one
+	Code    string `json:"code"`
+	Message string `json:"error"`
+	// RetryAfter is the server's Retry-After hint, if any
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("inbox whisperer: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("inbox whisperer: %d: %s", e.StatusCode, e.Message)
+}
+
+// IsCode reports whether err is an *Error with the given code
+func IsCode(err error, code string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// do sends a request with an optional JSON body and decodes a JSON response into out, if
+// non-nil. Non-2xx responses are returned as *Error.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) (*http.Response, error) {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var rdr io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rdr = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, rdr)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if c.session != "" {
+		req.AddCookie(&http.Cookie{Name: SessionCookie, Value: c.session})
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, decodeError(resp)
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp, fmt.Errorf("inbox whisperer: decode %s %s: %w", method, path, err)
+		}
+	}
+	return resp, nil
+}
+
+// decodeError reads a JSON error body, falling back to the plain text some endpoints send
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(secs) * time.Second
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(raw, apiErr) != nil || apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(raw))
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
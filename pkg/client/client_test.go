@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_AuthAndErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer iw_test" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid API key"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/users/me/settings":
+			w.Write([]byte(`{"ai_data_sharing":true,"ai_local_only":false,"timezone":"Europe/Berlin"}`))
+		case "/api/emails/m1/archive":
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":"grant modify","code":"missing_scope"}`))
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	if _, err := New(srv.URL).GetSettings(ctx); err == nil {
+		t.Fatal("expected unauthenticated request to fail")
+	}
+	c := New(srv.URL+"/", WithAPIKey("iw_test"))
+	s, err := c.GetSettings(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !s.AIDataSharing || s.Timezone != "Europe/Berlin" {
+		t.Errorf("unexpected settings %+v", s)
+	}
+
+	err = c.ArchiveMessage(ctx, "m1")
+	if !IsCode(err, "missing_scope") {
+		t.Fatalf("expected missing_scope error, got %v", err)
+	}
+	if apiErr := err.(*Error); apiErr.StatusCode != http.StatusForbidden || apiErr.RetryAfter != 7*time.Second {
+		t.Errorf("unexpected error %+v", apiErr)
+	}
+	err = c.MarkRead(ctx, "m1")
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "not found" {
+		t.Errorf("expected plain-text 404 to be decoded, got %v", err)
+	}
+}
+
+func TestClient_ListMessages(t *testing.T) {
+	syncing := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie(SessionCookie); err != nil || c.Value != "sess" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if syncing {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"syncing":true,"messages":[],"retry_after_seconds":5}`))
+			return
+		}
+		if r.URL.Query().Get("page_token") != "p1" {
+			t.Errorf("expected page token, got %q", r.URL.RawQuery)
+		}
+		w.Header().Set("X-Next-Page-Token", "p2")
+		json.NewEncoder(w).Encode([]Message{{EmailMessageID: "m1", Subject: "hello"}})
+	}))
+	defer srv.Close()
+	c := New(srv.URL, WithSession("sess"))
+
+	page, err := c.ListMessages(context.Background(), nil)
+	if err != nil || !page.Syncing || page.RetryAfter != 5*time.Second {
+		t.Fatalf("expected syncing page, got %+v, %v", page, err)
+	}
+	syncing = false
+	page, err = c.ListMessages(context.Background(), &ListMessagesOptions{PageToken: "p1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Messages) != 1 || page.Messages[0].Subject != "hello" || page.NextPageToken != "p2" {
+		t.Errorf("unexpected page %+v", page)
+	}
+}
+
+func TestClient_WaitForDeviceToken(t *testing.T) {
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/auth/device/token":
+			polls++
+			if polls == 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"the user has not approved the device yet","code":"authorization_pending"}`))
+				return
+			}
+			w.Write([]byte(`{"id":1,"name":"CLI","prefix":"iw_abcd","key":"iw_secret"}`))
+		case "/api/users/me/api-keys":
+			if r.Header.Get("Authorization") != "Bearer iw_secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer srv.Close()
+	c := New(srv.URL)
+
+	key, err := c.WaitForDeviceToken(context.Background(), &DeviceAuthorization{DeviceCode: "dc", Interval: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if key.Key != "iw_secret" || key.Name != "CLI" || polls != 2 {
+		t.Errorf("unexpected key %+v after %d polls", key, polls)
+	}
+	if _, err := c.ListAPIKeys(context.Background()); err != nil {
+		t.Errorf("expected the client to use the new key: %v", err)
+	}
+}
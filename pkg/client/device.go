@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Device flow error codes returned by PollDeviceToken (RFC 8628)
+const (
+	CodeAuthorizationPending = "authorization_pending"
+	CodeSlowDown             = "slow_down"
+	CodeAccessDenied         = "access_denied"
+	CodeExpiredToken         = "expired_token"
+)
+
+// DeviceAuthorization starts a device login: show the user VerificationURIComplete (or
+// VerificationURI and UserCode), then wait with WaitForDeviceToken
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// StartDeviceAuthorization begins the device flow; it needs no authentication
+func (c *Client) StartDeviceAuthorization(ctx context.Context) (*DeviceAuthorization, error) {
+	var auth DeviceAuthorization
+	if _, err := c.do(ctx, http.MethodPost, "/api/auth/device/code", nil, nil, &auth); err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// PollDeviceToken checks once whether the user approved the device. Until then it fails with
+// an *Error whose Code is one of the Code* constants.
+func (c *Client) PollDeviceToken(ctx context.Context, deviceCode string) (*CreatedAPIKey, error) {
+	var key CreatedAPIKey
+	body := struct {
+		DeviceCode string `json:"device_code"`
+	}{deviceCode}
+	if _, err := c.do(ctx, http.MethodPost, "/api/auth/device/token", nil, body, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// WaitForDeviceToken polls at the server's interval until the user approves or denies the
+// device, the code expires, or ctx is done. On approval the client switches to the new key.
+func (c *Client) WaitForDeviceToken(ctx context.Context, auth *DeviceAuthorization) (*CreatedAPIKey, error) {
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		key, err := c.PollDeviceToken(ctx, auth.DeviceCode)
+		switch {
+		case err == nil:
+			c.apiKey, c.session = key.Key, ""
+			return key, nil
+		case IsCode(err, CodeAuthorizationPending):
+		case IsCode(err, CodeSlowDown):
+			interval += 5 * time.Second
+		default:
+			return nil, err
+		}
+	}
+}
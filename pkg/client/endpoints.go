@@ -0,0 +1,237 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/desponda/inbox-whisperer/internal/models"
+)
+
+// Types shared with the server, so responses decode exactly as they are encoded
+type (
+	Message       = models.EmailMessage
+	Label         = models.Label
+	Rule          = models.Rule
+	RuleCondition = models.RuleCondition
+	RuleAction    = models.RuleAction
+	UserSettings  = models.UserSettings
+	Stats         = models.UserStats
+	APIKey        = models.APIKey
+)
+
+// ListMessagesOptions pages through GET /api/email/messages
+type ListMessagesOptions struct {
+	// AfterID and AfterInternalDate continue after the last message of the previous page
+	AfterID           string
+	AfterInternalDate int64
+	// PageToken continues a list served straight from the provider (see MessagePage)
+	PageToken string
+	// AccountID picks a linked account other than the default one
+	AccountID string
+}
+
+// MessagePage is one page of messages
+type MessagePage struct {
+	Messages []Message
+	// NextPageToken is set when the list came straight from the provider
+	NextPageToken string
+	// Syncing means the user's first sync is still filling the cache; ask again after RetryAfter
+	Syncing    bool
+	RetryAfter time.Duration
+}
+
+// ListMessages lists the user's newest messages, or the page after opts
+func (c *Client) ListMessages(ctx context.Context, opts *ListMessagesOptions) (*MessagePage, error) {
+	q := url.Values{}
+	if opts != nil {
+		if opts.AfterID != "" {
+			q.Set("after_id", opts.AfterID)
+		}
+		if opts.AfterInternalDate != 0 {
+			q.Set("after_internal_date", strconv.FormatInt(opts.AfterInternalDate, 10))
+		}
+		if opts.PageToken != "" {
+			q.Set("page_token", opts.PageToken)
+		}
+		if opts.AccountID != "" {
+			q.Set("account_id", opts.AccountID)
+		}
+	}
+	// The body is a list of messages, or a syncing object with 202 Accepted
+	var raw json.RawMessage
+	resp, err := c.do(ctx, http.MethodGet, "/api/email/messages", q, nil, &raw)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusAccepted {
+		var syncing struct {
+			RetryAfterSeconds int `json:"retry_after_seconds"`
+		}
+		if err := json.Unmarshal(raw, &syncing); err != nil {
+			return nil, err
+		}
+		return &MessagePage{Messages: []Message{}, Syncing: true, RetryAfter: time.Duration(syncing.RetryAfterSeconds) * time.Second}, nil
+	}
+	page := &MessagePage{NextPageToken: resp.Header.Get("X-Next-Page-Token")}
+	if err := json.Unmarshal(raw, &page.Messages); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetMessage returns a message with its body
+func (c *Client) GetMessage(ctx context.Context, id string) (*Message, error) {
+	var msg Message
+	if _, err := c.do(ctx, http.MethodGet, "/api/email/messages/"+url.PathEscape(id), nil, nil, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// SummarizeMessage returns a short AI summary of the message
+func (c *Client) SummarizeMessage(ctx context.Context, id string) (string, error) {
+	var out struct {
+		Summary string `json:"summary"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/api/email/messages/"+url.PathEscape(id)+"/summary", nil, nil, &out); err != nil {
+		return "", err
+	}
+	return out.Summary, nil
+}
+
+// ArchiveMessage archives the message at the provider
+func (c *Client) ArchiveMessage(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodPost, "/api/emails/"+url.PathEscape(id)+"/archive", nil, nil, nil)
+	return err
+}
+
+// MarkRead marks the message read at the provider
+func (c *Client) MarkRead(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodPost, "/api/emails/"+url.PathEscape(id)+"/read", nil, nil, nil)
+	return err
+}
+
+// ListLabels returns the account's labels
+func (c *Client) ListLabels(ctx context.Context) ([]*Label, error) {
+	var out struct {
+		Labels []*Label `json:"labels"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/api/labels", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Labels, nil
+}
+
+// ListRules returns the user's rules
+func (c *Client) ListRules(ctx context.Context) ([]*Rule, error) {
+	var rules []*Rule
+	if _, err := c.do(ctx, http.MethodGet, "/api/rules", nil, nil, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// NewRule is a rule to create
+type NewRule struct {
+	Name       string          `json:"name"`
+	Conditions []RuleCondition `json:"conditions"`
+	Actions    []RuleAction    `json:"actions"`
+	// Enabled defaults to true when nil
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// CreateRule creates a rule
+func (c *Client) CreateRule(ctx context.Context, rule *NewRule) (*Rule, error) {
+	var created Rule
+	if _, err := c.do(ctx, http.MethodPost, "/api/rules", nil, rule, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// DeleteRule deletes a rule
+func (c *Client) DeleteRule(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, http.MethodDelete, "/api/rules/"+strconv.FormatInt(id, 10), nil, nil, nil)
+	return err
+}
+
+// Settings is the user's settings plus the deploy-level settings that constrain them
+type Settings struct {
+	UserSettings
+	AILocalOnly bool `json:"ai_local_only"`
+}
+
+// SettingsUpdate changes the settings that are set; see UserSettings for their meaning
+type SettingsUpdate struct {
+	AIDataSharing     *bool   `json:"ai_data_sharing,omitempty"`
+	DisableLocalCache *bool   `json:"disable_local_cache,omitempty"`
+	MetadataOnlyCache *bool   `json:"metadata_only_cache,omitempty"`
+	Timezone          *string `json:"timezone,omitempty"`
+	WorkingHoursStart *string `json:"working_hours_start,omitempty"`
+	WorkingHoursEnd   *string `json:"working_hours_end,omitempty"`
+	WeekendPause      *bool   `json:"weekend_pause,omitempty"`
+}
+
+// GetSettings returns the user's settings
+func (c *Client) GetSettings(ctx context.Context) (*Settings, error) {
+	var s Settings
+	if _, err := c.do(ctx, http.MethodGet, "/api/users/me/settings", nil, nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UpdateSettings applies update and returns the resulting settings
+func (c *Client) UpdateSettings(ctx context.Context, update *SettingsUpdate) (*Settings, error) {
+	var s Settings
+	if _, err := c.do(ctx, http.MethodPut, "/api/users/me/settings", nil, update, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetStats returns the user's triage activity over the last 7 days
+func (c *Client) GetStats(ctx context.Context) (*Stats, error) {
+	var s Stats
+	if _, err := c.do(ctx, http.MethodGet, "/api/users/me/stats", nil, nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CreatedAPIKey is a new key with its secret, which the server never shows again
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// ListAPIKeys returns the user's active API keys, without secrets
+func (c *Client) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	var keys []*APIKey
+	if _, err := c.do(ctx, http.MethodGet, "/api/users/me/api-keys", nil, nil, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CreateAPIKey issues a new API key named name
+func (c *Client) CreateAPIKey(ctx context.Context, name string) (*CreatedAPIKey, error) {
+	var key CreatedAPIKey
+	body := struct {
+		Name string `json:"name"`
+	}{name}
+	if _, err := c.do(ctx, http.MethodPost, "/api/users/me/api-keys", nil, body, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey revokes an API key
+func (c *Client) RevokeAPIKey(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, http.MethodDelete, "/api/users/me/api-keys/"+strconv.FormatInt(id, 10), nil, nil, nil)
+	return err
+}
@@ -0,0 +1,177 @@
+// Package webhookverify verifies the signature on webhooks sent by Inbox Whisperer.
+// Each delivery carries a Signature header of the form "t=<unix seconds>,v1=<hex hmac>",
+// where the HMAC-SHA256 is computed with the endpoint's secret over "<t>.<body>".
+// Sign produces the same header on the sending side.
+package webhookverify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureHeader is the request header carrying the delivery signature
+const SignatureHeader = "X-Inbox-Whisperer-Signature"
+
+// DefaultTolerance is how far a delivery's timestamp may be from the receiver's clock
+const DefaultTolerance = 5 * time.Minute
+
+// maxBodySize bounds how much of a request body VerifyRequest reads
+const maxBodySize = 1 << 20
+
+var (
+	ErrMissingSignature = errors.New("webhookverify: missing signature header")
+	ErrInvalidHeader    = errors.New("webhookverify: malformed signature header")
+	ErrInvalidSignature = errors.New("webhookverify: signature does not match")
+	ErrTimestampExpired = errors.New("webhookverify: timestamp outside tolerance")
+	ErrReplayed         = errors.New("webhookverify: delivery already seen")
+)
+
+// Sign returns the signature header value for body sent at ts
+func Sign(secret, body []byte, ts time.Time) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac(secret, t, body))
+}
+
+func mac(secret []byte, t string, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(t))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// ReplayCache remembers signatures that were already accepted. Seen records sig until
+// expiresAt and reports whether it was recorded before; implementations shared between
+// receiver instances (e.g. Redis SET NX) make replay protection work behind a load balancer.
+type ReplayCache interface {
+	Seen(sig string, expiresAt time.Time) (bool, error)
+}
+
+// Verifier checks signatures for one endpoint secret
+type Verifier struct {
+	// Secrets are tried in order, so a rotated secret can be accepted alongside the new one
+	Secrets   [][]byte
+	Tolerance time.Duration    // zero means DefaultTolerance
+	Replay    ReplayCache      // optional; nil disables replay protection
+	Now       func() time.Time // optional; defaults to time.Now
+}
+
+// New returns a Verifier for secret with the default tolerance and no replay cache
+func New(secret []byte) *Verifier {
+	return &Verifier{Secrets: [][]byte{secret}}
+}
+
+// Verify checks header against body
+func (v *Verifier) Verify(header string, body []byte) error {
+	if header == "" {
+		return ErrMissingSignature
+	}
+	var t string
+	var sigs [][]byte
+	for _, part := range strings.Split(header, ",") {
+		k, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrInvalidHeader
+		}
+		switch k {
+		case "t":
+			t = val
+		case "v1":
+			sig, err := hex.DecodeString(val)
+			if err != nil {
+				return ErrInvalidHeader
+			}
+			sigs = append(sigs, sig)
+		}
+	}
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidHeader
+	}
+
+	if !v.matches(t, body, sigs) {
+		return ErrInvalidSignature
+	}
+	tolerance := v.Tolerance
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	ts := time.Unix(unix, 0)
+	if d := now().Sub(ts); d > tolerance || d < -tolerance {
+		return ErrTimestampExpired
+	}
+	if v.Replay != nil {
+		seen, err := v.Replay.Seen(header, ts.Add(tolerance))
+		if err != nil {
+			return fmt.Errorf("webhookverify: replay cache: %w", err)
+		}
+		if seen {
+			return ErrReplayed
+		}
+	}
+	return nil
+}
+
+func (v *Verifier) matches(t string, body []byte, sigs [][]byte) bool {
+	for _, secret := range v.Secrets {
+		expected := mac(secret, t, body)
+		for _, sig := range sigs {
+			if hmac.Equal(expected, sig) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// VerifyRequest reads and verifies r's body and returns it. The body must be read
+// before it is parsed, because the signature covers the raw bytes.
+func (v *Verifier) VerifyRequest(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		return nil, err
+	}
+	if err := v.Verify(r.Header.Get(SignatureHeader), body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// MemoryReplayCache is an in-process ReplayCache for receivers running a single instance
+type MemoryReplayCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// Seen implements ReplayCache, dropping expired entries as it goes
+func (c *MemoryReplayCache) Seen(sig string, expiresAt time.Time) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.seen == nil {
+		c.seen = make(map[string]time.Time)
+	}
+	for k, exp := range c.seen {
+		if now.After(exp) {
+			delete(c.seen, k)
+		}
+	}
+	if _, ok := c.seen[sig]; ok {
+		return true, nil
+	}
+	c.seen[sig] = expiresAt
+	return false, nil
+}
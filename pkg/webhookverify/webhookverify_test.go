@@ -0,0 +1,61 @@
+package webhookverify
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("whsec_test")
+	body := []byte(`{"event":"sync_complete"}`)
+	now := time.Unix(1_800_000_000, 0)
+	header := Sign(secret, body, now)
+
+	v := New(secret)
+	v.Now = func() time.Time { return now.Add(time.Minute) }
+
+	tests := []struct {
+		name   string
+		v      *Verifier
+		header string
+		body   []byte
+		want   error
+	}{
+		{"valid", v, header, body, nil},
+		{"missing", v, "", body, ErrMissingSignature},
+		{"malformed", v, "garbage", body, ErrInvalidHeader},
+		{"tampered body", v, header, []byte(`{}`), ErrInvalidSignature},
+		{"wrong secret", &Verifier{Secrets: [][]byte{[]byte("other")}, Now: v.Now}, header, body, ErrInvalidSignature},
+		{"rotated secret", &Verifier{Secrets: [][]byte{[]byte("new"), secret}, Now: v.Now}, header, body, nil},
+		{"expired", &Verifier{Secrets: v.Secrets, Now: func() time.Time { return now.Add(time.Hour) }}, header, body, ErrTimestampExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.v.Verify(tt.header, tt.body); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyRequest_Replay(t *testing.T) {
+	secret := []byte("whsec_test")
+	body := []byte(`{"event":"account_linked"}`)
+	v := &Verifier{Secrets: [][]byte{secret}, Replay: &MemoryReplayCache{}}
+	header := Sign(secret, body, time.Now())
+
+	for i, want := range []error{nil, ErrReplayed} {
+		req := httptest.NewRequest("POST", "/hook", bytes.NewReader(body))
+		req.Header.Set(SignatureHeader, header)
+		got, err := v.VerifyRequest(req)
+		if !errors.Is(err, want) {
+			t.Fatalf("delivery %d: got %v, want %v", i, err, want)
+		}
+		if err == nil && !bytes.Equal(got, body) {
+			t.Errorf("expected the raw body back, got %q", got)
+		}
+	}
+}
@@ -30,7 +30,7 @@ const getApiEmailMessages = <TData = AxiosResponse<EmailSummary[]>>(
      options?: AxiosRequestConfig
  ): Promise<TData> => {
     return axios.get(
-      `/api/email/messages`,options
+      `/api/emails/messages`,options
     );
   }
 /**
@@ -41,7 +41,7 @@ const getApiEmailMessagesId = <TData = AxiosResponse<EmailContent>>(
     id: string, options?: AxiosRequestConfig
  ): Promise<TData> => {
     return axios.get(
-      `/api/email/messages/${id}`,options
+      `/api/emails/messages/${id}`,options
     );
   }
 return {getApiEmailMessages,getApiEmailMessagesId}};
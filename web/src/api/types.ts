@@ -108,7 +108,7 @@ export interface paths {
     patch?: never;
     trace?: never;
   };
-  '/api/email/messages': {
+  '/api/emails/messages': {
     parameters: {
       query?: never;
       header?: never;
@@ -174,7 +174,7 @@ export interface paths {
     patch?: never;
     trace?: never;
   };
-  '/api/email/messages/{id}': {
+  '/api/emails/messages/{id}': {
     parameters: {
       query?: never;
       header?: never;